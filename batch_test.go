@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+)
+
+// TestBatchHeaderPrecedence checks that the headers of a batch file's front
+// matter win over AUTH_TOKEN, which wins over -H, which wins over the config
+// file, and that skipped files are not sent.
+func TestBatchHeaderPrecedence(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]http.Header)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		doc, err := gql.Parse(body.Query)
+		if err != nil {
+			t.Errorf("the server received %q: %v", body.Query, err)
+			return
+		}
+		mu.Lock()
+		received[doc.Operations[0].Name] = r.Header.Clone()
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"me":{"id":"1"}}}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "graphspecter.yaml")
+	if err := os.WriteFile(config, []byte("headers:\n  x-tenant: config\n  X-Env: config\n  X-Trace: config\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	batch := filepath.Join(dir, "batch")
+	files := map[string]string{
+		"acme.graphql":  "# graphspecter-header: X-TENANT: acme\n# graphspecter-header: authorization: Bearer acme\n# captured from the acme tenant\nquery Acme { me { id } }\n",
+		"plain.graphql": "query Plain { me { id } }\n",
+		"off.graphql":   "# graphspecter-skip: true\nquery Off { me { id } }\n",
+		"on.graphql":    "# graphspecter-skip: false\nquery On { me { id } }\n",
+	}
+	if err := os.Mkdir(batch, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(batch, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("AUTH_TOKEN", "env")
+
+	code := runArgs(t, "--config", config, "--base", srv.URL, "--batch-dir", batch,
+		"-H", "X-Tenant: cli", "-H", "X-Env: cli", "-H", "Authorization: Basic Y2xp")
+	if code != 0 {
+		t.Fatalf("exit status %d", code)
+	}
+
+	if _, sent := received["Off"]; sent || len(received) != 3 {
+		t.Fatalf("the server received %d operations; want all but the skipped one", len(received))
+	}
+	for op, want := range map[string]map[string]string{
+		"Acme":  {"X-Tenant": "acme", "Authorization": "Bearer acme", "X-Env": "cli", "X-Trace": "config"},
+		"Plain": {"X-Tenant": "cli", "Authorization": "Bearer env", "X-Env": "cli", "X-Trace": "config"},
+		"On":    {"X-Tenant": "cli", "Authorization": "Bearer env", "X-Env": "cli", "X-Trace": "config"},
+	} {
+		for name, value := range want {
+			if got := received[op].Values(name); len(got) != 1 || got[0] != value {
+				t.Errorf("%s: %s = %q, want %q", op, name, got, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// storeServer is a GraphQL server whose canary query reports the number of
+// orders placed and when they were last read. With drifting set, every
+// request other than the canary places an order, as a scan with side
+// effects would.
+func storeServer(t *testing.T, drifting bool) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	orders, reads := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		if !strings.Contains(body.Query, "query Canary") {
+			if drifting {
+				orders++
+			}
+			w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+			return
+		}
+		reads++
+		fmt.Fprintf(w, `{"data":{"stats":{"orders":%d,"readAt":"2024-05-01T00:00:0%dZ"}},"extensions":{"tracing":{"duration":%d}}}`, orders, reads, reads*1000)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCanaryDetectsStateChanges(t *testing.T) {
+	canary := filepath.Join(t.TempDir(), "canary.graphql")
+	if err := os.WriteFile(canary, []byte("query Canary { stats { orders readAt } }\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		drifting bool
+		ignore   string
+		code     int
+		diff     string
+	}{
+		{name: "unchanged", ignore: "data.stats.readAt", code: 0},
+		{name: "volatile field compared", code: exitStateChanged, diff: `~ data.stats.readAt: "2024-05-01T00:00:01Z" -> "2024-05-01T00:00:02Z"`},
+		{name: "drift", drifting: true, ignore: "data.*.readAt", code: exitStateChanged, diff: "~ data.stats.orders: 0 -> 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := storeServer(t, tt.drifting)
+			reportFile := filepath.Join(t.TempDir(), "report.json")
+			args := []string{"--base", srv.URL, "--checks", "query-policy", "--canary-query", canary, "--report", reportFile}
+			if tt.ignore != "" {
+				args = append(args, "--canary-ignore", tt.ignore)
+			}
+			if code := runArgs(t, args...); code != tt.code {
+				t.Errorf("exit status %d, want %d", code, tt.code)
+			}
+
+			content, err := os.ReadFile(reportFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rep report.Report
+			if err := json.Unmarshal(content, &rep); err != nil {
+				t.Fatal(err)
+			}
+			if len(rep.Canaries) != 1 {
+				t.Fatalf("canaries = %+v, want one for the target", rep.Canaries)
+			}
+			c := rep.Canaries[0]
+			if c.Endpoint != srv.URL || c.Query != canary || c.Error != "" || c.Drift != (tt.diff != "") || rep.StateChanged() != c.Drift {
+				t.Errorf("canary = %+v", c)
+			}
+			// The diff names the changed member only; extensions are never compared.
+			if c.Diff != tt.diff {
+				t.Errorf("diff = %q, want %q", c.Diff, tt.diff)
+			}
+			if c.Drift && (!strings.Contains(c.Before, `"orders"`) || strings.Contains(c.After, "extensions")) {
+				t.Errorf("before = %s, after = %s; want the normalized responses", c.Before, c.After)
+			}
+			// The read-only scan and the canary sent no mutation.
+			if len(rep.NonQueryOperations) != 0 {
+				t.Errorf("non-query operations = %+v", rep.NonQueryOperations)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/cli"
+	"github.com/CyberRoute/graphspecter/pkg/cmd"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// countingServer is a mock GraphQL server that counts the requests it
+// receives.
+func countingServer(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var n atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+// runArgs parses args and runs them as the command line would.
+func runArgs(t *testing.T, args ...string) int {
+	t.Helper()
+	cfg, err := cmd.ParseArgs(append([]string{"--log-level", "error"}, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	t.Cleanup(func() { network.SetOffline(false) })
+	return runModes(&runLifecycle{ctx: ctx, cancel: cancel}, cfg)
+}
+
+// TestDryRunSendsNothing checks that --dry-run plans every mode without a
+// request reaching the server.
+func TestDryRunSendsNothing(t *testing.T) {
+	srv, sent := countingServer(t)
+	batch := t.TempDir()
+	if err := os.WriteFile(filepath.Join(batch, "me.graphql"), []byte("query Me { me { id } }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runs := []struct {
+		name string
+		args []string
+	}{
+		{name: "audit", args: []string{"--base", srv.URL}},
+		{name: "aggressive audit", args: []string{"--base", srv.URL, "--audit-dos", "--audit-injection"}},
+		{name: "detection", args: []string{"--base", srv.URL, "--detect"}},
+		{name: "execute", args: []string{"--base", srv.URL, "--execute", "--query-string", "mutation { deleteUser(id: 1) }"}},
+		{name: "batch", args: []string{"--base", srv.URL, "--batch-dir", batch}},
+		{name: "subscribe", args: []string{"--base", srv.URL, "--subscribe", "--ws-url", "ws" + strings.TrimPrefix(srv.URL, "http"), "--sub-query", "subscription { events }"}},
+	}
+	for _, tt := range runs {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := runArgs(t, append(tt.args, "--dry-run")...); code != 0 {
+				t.Fatalf("exit status %d", code)
+			}
+			if n := sent.Load(); n != 0 {
+				t.Errorf("the dry run sent %d request(s)", n)
+			}
+		})
+	}
+}
+
+// TestDryRunPlanMatchesRealRun checks that an audit sends as many requests as
+// its dry run plans.
+func TestDryRunPlanMatchesRealRun(t *testing.T) {
+	const list = "query-policy,batching,csrf,content-negotiation,transport-features,parsing-differential,federation,engine"
+	srv, sent := countingServer(t)
+	selected, err := checks.Select(list, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := cli.PlanAudit([]string{srv.URL}, false, nil, cli.AuditOptions{Checks: selected})
+	min, max := 0, 0
+	for _, step := range plan.Steps {
+		if step.Unknown {
+			t.Fatalf("step %s is not planned", step.Step)
+		}
+		min += step.Requests
+		max += step.MaxRequests
+	}
+
+	if code := runArgs(t, "--base", srv.URL, "--checks", list, "--report", filepath.Join(t.TempDir(), "report.json")); code != 0 {
+		t.Fatalf("exit status %d", code)
+	}
+	if n := int(sent.Load()); n < min || n > max {
+		t.Errorf("the audit sent %d request(s), its dry run planned %d-%d", n, min, max)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/CyberRoute/graphspecter
 go 1.20
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/gorilla/websocket v1.5.3
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -4,250 +4,1471 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/attacks"
+	"github.com/CyberRoute/graphspecter/pkg/auth"
+	"github.com/CyberRoute/graphspecter/pkg/checks"
 	"github.com/CyberRoute/graphspecter/pkg/cli"
 	"github.com/CyberRoute/graphspecter/pkg/cmd"
 	"github.com/CyberRoute/graphspecter/pkg/config"
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/inference"
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
 	"github.com/CyberRoute/graphspecter/pkg/logger"
 	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/server"
 	"github.com/CyberRoute/graphspecter/pkg/subscription"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+	"github.com/CyberRoute/graphspecter/pkg/vulndb"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
 )
 
+// exitStateChanged is the exit status of a run whose canary query detected a
+// change of server state.
+const exitStateChanged = 3
+
 func main() {
+	// Subcommands take their own flags and never fall through to the flag-driven modes.
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runSubcommand(os.Args[1], os.Args[2:]))
+	}
+
 	// Parse all command-line flags.
 	cfg := cmd.ParseFlags()
+	os.Exit(run(cfg, os.Args[1:]))
+}
+
+// run executes the flag-driven mode of cfg, invoked with args, and returns the
+// exit status. The run manifest is finalized however the run ends; a panic is
+// recorded in it before the panic carries on.
+func run(cfg *types.CLIConfig, args []string) (code int) {
+	r, err := startRun(cfg.RunManifest, cmd.RedactedArgs(args))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer func() {
+		logger.CloseLogFile()
+		r.artifact("log", cfg.LogFile)
+		if p := recover(); p != nil {
+			r.manifest.Fail(fmt.Sprintf("panic: %v", p))
+			r.finish(2)
+			panic(p)
+		}
+		code = r.finish(code)
+	}()
+	return runModes(r, cfg)
+}
 
+// runModes runs the mode cfg selects and returns its exit status.
+func runModes(r *runLifecycle, cfg *types.CLIConfig) int {
+	endSetup := r.phase("setup")
 	if cfg.ConfigFile != "" {
 		fileCfg, err := config.LoadConfigFile(cfg.ConfigFile)
 		if err != nil {
-			logger.Fatal("Error loading config file: %v", err)
+			return r.fail("Error loading config file: %v", err)
 		}
 		config.ApplyFileConfigToCLIConfig(fileCfg, cfg)
 	}
-	// Batch execution mode: execute all .graphql files in a directory with vars
-	if cfg.BatchDir != "" {
-		if cfg.BaseURL == "" {
-			logger.Fatal("--base is required for batch execution")
+
+	if cfg.Version {
+		fmt.Println(version.String())
+		return 0
+	}
+
+	if cfg.StopOnFinding != "" && !report.ValidSeverity(cfg.StopOnFinding) {
+		return r.fail("Invalid --stop-on-finding severity %q: use info, low, medium, high or critical", cfg.StopOnFinding)
+	}
+
+	if cfg.ReportFormat != "" && !report.ValidFormat(cfg.ReportFormat) {
+		return r.fail("Invalid --report-format %q (valid: 'json', 'markdown', 'html', 'csv', 'sarif')", cfg.ReportFormat)
+	}
+	switch cfg.SubTransport {
+	case "ws", "sse", "auto":
+	default:
+		return r.fail("Invalid --sub-transport %q (valid: 'ws', 'sse', 'auto')", cfg.SubTransport)
+	}
+	if cfg.LogMaxBackups < 0 {
+		return r.fail("Invalid --log-max-backups %d: use 0 or more", cfg.LogMaxBackups)
+	}
+	logger.SetRotation(logger.Rotation{MaxSize: cfg.LogMaxSize, MaxBackups: cfg.LogMaxBackups, Compress: cfg.LogCompress})
+	if !report.ValidDetail(cfg.ReportDetail) {
+		return r.fail("Invalid --report-detail %q (valid: 'summary', 'standard', 'full')", cfg.ReportDetail)
+	}
+	if cfg.ReportTemplate != "" {
+		// Printed directly so that template errors show however logging is configured.
+		if cfg.ReportFile == "" {
+			return r.fail("--report-template needs --report")
 		}
-		logger.Info("Batch mode: scanning directory %s", cfg.BatchDir)
-		files, err := filepath.Glob(filepath.Join(cfg.BatchDir, "*.graphql"))
-		if err != nil {
-			logger.Fatal("Error scanning batch directory: %v", err)
+		if _, err := report.LoadTemplate(cfg.ReportTemplate); err != nil {
+			return r.fail("%v", err)
 		}
-		// regex to find operation definitions
-		opRegex := regexp.MustCompile(`(?m)^(?:query|mutation)\s+([A-Za-z0-9_]+)`)
-		for _, qf := range files {
-			contentBytes, err := os.ReadFile(qf)
-			if err != nil {
-				logger.Error("Skipping %s: %v", qf, err)
-				continue
+	}
+	var notes schema.Notes
+	if cfg.Notes != "" {
+		var err error
+		if notes, err = schema.LoadNotes(cfg.Notes); err != nil {
+			return r.fail("Error loading notes: %v", err)
+		}
+	}
+	if cfg.Tag != "" && (cfg.List == "" || cfg.Notes == "") {
+		return r.fail("--tag needs --list and --notes")
+	}
+	if cfg.ResumeInference != "" && cfg.RecoverSchema == "" {
+		return r.fail("--resume-inference requires --recover-schema")
+	}
+	if cfg.VHosts != "" && (cfg.Offline || cfg.Watch > 0) {
+		return r.fail("--vhosts cannot be used with --offline or --watch")
+	}
+	if cfg.Offline {
+		if cfg.IntrospectionFile == "" && cfg.SchemaFile == "" {
+			return r.fail("--offline needs --introspection-file or --schema-file")
+		}
+		for _, conflict := range []struct {
+			flag string
+			set  bool
+		}{
+			{"--detect", cfg.Detect},
+			{"--canary-query", cfg.CanaryQuery != ""},
+			{"--execute", cfg.Execute},
+			{"--batch-dir", cfg.BatchDir != ""},
+			{"--subscribe", cfg.Subscribe},
+			{"--watch", cfg.Watch > 0},
+		} {
+			if conflict.set {
+				return r.fail("--offline cannot be combined with %s", conflict.flag)
 			}
-			content := string(contentBytes)
-			locs := opRegex.FindAllStringSubmatchIndex(content, -1)
-			if len(locs) == 0 {
-				logger.Error("No operations found in %s", qf)
-				continue
+		}
+		// Anything that still tries to send a request fails with gerrors.ErrOfflineMode.
+		network.SetOffline(true)
+	}
+	if cfg.Safe {
+		for _, conflict := range []struct {
+			flag string
+			set  bool
+		}{
+			{"--aggressive", cfg.Aggressive},
+			{"--audit-dos", cfg.AuditDoS},
+			{"--audit-ws", cfg.AuditWS},
+			{"--audit-injection", cfg.AuditInjection},
+			{"--fuzz-coercion", cfg.FuzzCoercion},
+			{"--duplicate-query", cfg.DuplicateQuery != ""},
+		} {
+			if conflict.set {
+				return r.fail("--safe cannot be combined with %s", conflict.flag)
 			}
+		}
+	}
+	var requestEncoding network.Encoding
+	if cfg.RequestEncoding != "" {
+		if cfg.Detect {
+			return r.fail("--request-encoding cannot be combined with --detect, which finds the encoding of each endpoint")
+		}
+		var err error
+		if requestEncoding, err = network.ParseEncoding(cfg.RequestEncoding); err != nil {
+			return r.fail("Invalid --request-encoding: %v", err)
+		}
+		network.SetEncoding(cfg.BaseURL, requestEncoding)
+	}
+	if err := auth.ValidateWhoami(cfg.Whoami); err != nil {
+		return r.fail("Invalid --whoami-query: %v", err)
+	}
+	if cfg.Watch > 0 && cfg.Resume {
+		return r.fail("--watch cannot be combined with --resume")
+	}
+	if cfg.WebhookURL != "" && cfg.Watch <= 0 {
+		return r.fail("--webhook-url needs --watch")
+	}
+	if len(cfg.Sinks) > 0 && cfg.Watch > 0 {
+		return r.fail("--sink cannot be combined with --watch, whose events go to standard output and --webhook-url")
+	}
+	if cfg.SignKey != "" {
+		if cfg.RunManifest == "" {
+			return r.fail("--sign-key needs --run-manifest, which records the digests and signatures of the artifacts")
+		}
+		key, err := artifacts.LoadPrivateKey(cfg.SignKey)
+		if err != nil {
+			return r.fail("%v", err)
+		}
+		r.manifest.SignWith(key)
+	}
+	if cfg.Preview && !cfg.Execute && cfg.BatchDir == "" {
+		return r.fail("--preview needs --execute or --batch-dir")
+	}
+	if cfg.DryRun || cfg.Preview {
+		// The run is only planned; refuse anything that would still send a request.
+		network.SetOffline(true)
+	}
+	if cfg.FollowPagination && cfg.MaxPages < 1 {
+		return r.fail("--max-pages must be at least 1")
+	}
+	if cfg.CatalogFormat != "json" && cfg.CatalogFormat != "csv" {
+		return r.fail("Invalid --catalog-format %q (valid: 'json', 'csv')", cfg.CatalogFormat)
+	}
 
-			// load variables file if present
-			varsFile := strings.TrimSuffix(qf, ".graphql") + ".json"
-			var vars map[string]interface{}
-			if data, err := os.ReadFile(varsFile); err == nil {
-				json.Unmarshal(data, &vars)
-			}
+	if !schema.ValidSortMode(cfg.Sort) {
+		return r.fail("Invalid --sort value %q (valid: 'schema', 'alpha')", cfg.Sort)
+	}
+	if !schema.ValidSelection(cfg.Selection) {
+		return r.fail("Invalid --selection value %q (valid: 'minimal', 'standard', 'full')", cfg.Selection)
+	}
+	if cfg.Sample < 0 {
+		return r.fail("--sample must be 0 or more")
+	}
+	if !schema.ValidSampleStrategy(cfg.SampleStrategy) {
+		return r.fail("Invalid --sample-strategy value %q (valid: 'random', 'sensitive-first', 'coverage')", cfg.SampleStrategy)
+	}
+	sample := schema.Sample{Size: cfg.Sample, Strategy: cfg.SampleStrategy, Seed: cfg.SampleSeed}
 
-			// prepare headers
-			headers := map[string]string{"Content-Type": "application/json"}
-			if auth := os.Getenv("AUTH_TOKEN"); auth != "" {
-				headers["Authorization"] = "Bearer " + auth
-			}
+	// Custom checks are registered before anything lists or selects checks.
+	if cfg.CustomChecks != "" {
+		if _, err := checks.LoadCustom(cfg.CustomChecks); err != nil {
+			return r.fail("Error loading custom checks: %v", err)
+		}
+	}
 
-			// execute each operation separately
-			for i, loc := range locs {
-				start := loc[0]
-				end := len(content)
-				if i+1 < len(locs) {
-					end = locs[i+1][0]
-				}
-				opDoc := content[start:end]
-				// extract operation name
-				hdr := opRegex.FindStringSubmatch(opDoc)
-				opName := hdr[1]
-
-				res, err := network.SendGraphQLRequestWithContext(context.Background(), cfg.BaseURL, opDoc, vars, headers)
-				if err != nil {
-					logger.Error("%s (in %s) failed: %v", opName, filepath.Base(qf), err)
-					continue
-				}
-				out, _ := json.MarshalIndent(res, "", "  ")
-				fmt.Printf("Result for %s (from %s):\n%s\n", opName, filepath.Base(qf), string(out))
-			}
+	if cfg.ListChecks {
+		cli.PrintChecks()
+		return 0
+	}
+	if cfg.ListWordlists {
+		cli.PrintWordlists()
+		return 0
+	}
+	if err := setupNetwork(r, cfg); err != nil {
+		return r.fail("%v", err)
+	}
+	if err := loadData(cfg); err != nil {
+		return r.fail("%v", err)
+	}
+	endSetup()
+
+	if cfg.PreflightURL != "" && cfg.Preview {
+		logger.Warn("WARNING: --preview does not run --preflight-url, the headers of its session are not shown")
+	}
+	if cfg.PreflightURL != "" && !cfg.Offline && !cfg.DryRun && !cfg.Preview {
+		endPreflight := r.phase("preflight")
+		if err := setupPreflight(r.ctx, cfg); err != nil {
+			return r.fail("%v", err)
 		}
-		os.Exit(0)
+		endPreflight()
 	}
 
-	// If execute flag is set, run provided query or mutation
-	if cfg.Execute {
-		if cfg.BaseURL == "" {
-			logger.Fatal("--base is required when using --execute")
+	// --history logs every request of the run.
+	if cfg.History != "" && !cfg.DryRun && !cfg.Preview {
+		history, err := workspace.OpenHistory(cfg.History)
+		if err != nil {
+			return r.fail("%v", err)
 		}
-		// Load query
-		var query string
-		if cfg.QueryString != "" {
-			query = cfg.QueryString
-		} else if cfg.QueryFile != "" {
-			data, err := os.ReadFile(cfg.QueryFile)
-			if err != nil {
-				logger.Fatal("Error reading query file: %v", err)
+		network.SetRequestHook(func(sent network.SentRequest) {
+			if err := history.Record(sent); err != nil {
+				logger.Error("%v", err)
 			}
-			query = string(data)
-		} else {
-			logger.Fatal("No query provided: use --query-string or --query-file")
-		}
+		})
+		defer history.Close()
+		r.artifact("history", cfg.History)
+	}
 
-		// Parse variables
-		var variables map[string]interface{}
-		if cfg.Variables != "" {
-			if err := json.Unmarshal([]byte(cfg.Variables), &variables); err != nil {
-				logger.Fatal("Error parsing variables JSON: %v", err)
-			}
-		} else if cfg.VariablesFile != "" {
-			data, err := os.ReadFile(cfg.VariablesFile)
-			if err != nil {
-				logger.Fatal("Error reading variables file: %v", err)
-			}
-			if err := json.Unmarshal(data, &variables); err != nil {
-				logger.Fatal("Error parsing variables file JSON: %v", err)
-			}
+	// --observe-schema builds a schema from the responses of the run.
+	var observer *inference.Observer
+	if cfg.ObserveSchema != "" && !cfg.DryRun && !cfg.Preview {
+		observer = inference.NewObserver()
+		defer writeObservedSchema(r, cfg.ObserveSchema, observer)
+	}
+
+	// Extraction responses are scanned for secrets with the built-in
+	// detectors and those of --secret-patterns.
+	scanner, err := redact.LoadScanner(cfg.SecretPatterns)
+	if err != nil {
+		return r.fail("Invalid --secret-patterns: %v", err)
+	}
+
+	// An optional schema lets batch and execute modes synthesize enum and
+	// input object placeholders for variables that were not supplied.
+	var varsSchema *types.GQLSchema
+	if (cfg.BatchDir != "" || cfg.Execute) && cfg.SchemaFile != "" {
+		s, err := schema.LoadFromFile(cfg.SchemaFile)
+		if err != nil {
+			cli.PrintSchemaError(cfg.SchemaFile, err)
+			return 1
 		}
+		varsSchema = s
+	}
 
-		// Configure logging before request
-		logger.SetupLogging(cfg.LogLevel, cfg.LogFile, !cfg.NoColor)
+	in := modeInputs{notes: notes, sample: sample, requestEncoding: requestEncoding, observer: observer, scanner: scanner, varsSchema: varsSchema}
+	switch {
+	case cfg.BatchDir != "":
+		return runBatch(r, cfg, in)
+	case cfg.Execute:
+		return runExecute(r, cfg, in)
+	case cfg.Subscribe:
+		return runSubscribe(r, cfg)
+	case cfg.RecoverSchema != "":
+		return runRecover(r, cfg)
+	}
 
-		// Prepare context
-		timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), cfg.Timeout)
-		defer timeoutCancel()
+	// If neither a schema file nor a target is provided, show usage and exit.
+	// Offline audits of a saved introspection result need no target.
+	if cfg.SchemaFile == "" && cfg.BaseURL == "" && cfg.TargetsFile == "" && !cfg.Offline {
+		flag.Usage()
+		return 0
+	}
 
-		// Prepare headers
-		headers := map[string]string{"Content-Type": "application/json"}
-		if cfg.Headers != nil {
-			for k, v := range cfg.Headers {
-				headers[k] = v
-			}
+	// Configure logging.
+	logger.SetUTC(cfg.LogUTC)
+	logger.SetupLogging(cfg.LogLevel, cfg.LogFile, !cfg.NoColor)
+
+	if cfg.SchemaFile != "" {
+		return runSchemaFile(r, cfg, in)
+	}
+	return runAudits(r, cfg, in)
+}
+
+// setupNetwork configures the shared client for the run: its rate and
+// concurrency, the bounty profile and scope it enforces, its client
+// certificate, name resolution and request signer.
+func setupNetwork(r *runLifecycle, cfg *types.CLIConfig) error {
+	network.SetRateLimit(cfg.Rate)
+	concurrency, err := network.ParseConcurrency(cfg.Concurrency)
+	if err != nil {
+		return fmt.Errorf("Invalid --concurrency value: %w", err)
+	}
+	network.SetConcurrency(concurrency)
+	if cfg.ProfileBounty != "" {
+		profile, err := config.LoadBountyProfile(cfg.ProfileBounty)
+		if err != nil {
+			return err
+		}
+		if err := applyBountyProfile(cfg, profile); err != nil {
+			return err
+		}
+		r.profile = profile
+	}
+	if err := applyScope(cfg); err != nil {
+		return fmt.Errorf("Invalid --scope: %w", err)
+	}
+
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		if cfg.ClientCert == "" || cfg.ClientKey == "" {
+			return errors.New("--client-cert and --client-key must be given together")
+		}
+		if err := network.SetClientCertificate(cfg.ClientCert, cfg.ClientKey, cfg.ClientKeyPassword); err != nil {
+			return err
+		}
+	}
+	if len(cfg.Resolve) > 0 || cfg.DNSServer != "" || cfg.IPVersion != 0 {
+		res := network.Resolution{Pins: cfg.Resolve, DNSServer: cfg.DNSServer, IPVersion: cfg.IPVersion}
+		if err := network.SetResolution(res); err != nil {
+			return err
+		}
+	}
+	if cfg.AWSSign {
+		region := cfg.AWSRegion
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
 		}
-		if authToken := os.Getenv("AUTH_TOKEN"); authToken != "" {
-			headers["Authorization"] = "Bearer " + authToken
+		if region == "" {
+			region = os.Getenv("AWS_DEFAULT_REGION")
 		}
+		if region == "" {
+			return errors.New("--aws-sign needs a region: set --aws-region or AWS_REGION")
+		}
+		creds, err := auth.LoadAWSCredentials()
+		if err != nil {
+			return err
+		}
+		network.SetSigner(&auth.SigV4Signer{Credentials: creds, Region: region, Service: cfg.AWSService})
+	}
+	return nil
+}
 
-		// Execute request
-		resp, err := network.SendGraphQLRequestWithContext(timeoutCtx, cfg.BaseURL, query, variables, headers)
+// loadData loads the data files of --data-dir and --error-patterns and the
+// vulnerability database of --vuln-db.
+func loadData(cfg *types.CLIConfig) error {
+	if cfg.DataDir != "" {
+		if err := data.LoadDir(cfg.DataDir); err != nil {
+			return err
+		}
+	}
+	if cfg.ErrorPatterns != "" {
+		if err := data.LoadFile("error-patterns", cfg.ErrorPatterns); err != nil {
+			return err
+		}
+	}
+	if cfg.VulnDB != "" {
+		db, err := vulndb.Load(cfg.VulnDB)
 		if err != nil {
-			logger.Fatal("Execution error: %v", err)
+			return err
 		}
+		vulndb.Use(db)
+	}
+	return nil
+}
+
+// modeInputs are what the setup of a run prepares for the mode it runs.
+type modeInputs struct {
+	notes  schema.Notes
+	sample schema.Sample
+	// requestEncoding is the --request-encoding of every target, empty for
+	// the default encoding.
+	requestEncoding network.Encoding
+	// observer builds the --observe-schema schema, nil without it.
+	observer *inference.Observer
+	scanner  *redact.Scanner
+	// varsSchema synthesizes the variables batch and execute modes were not
+	// given, nil without --schema-file.
+	varsSchema *types.GQLSchema
+}
 
-		// Pretty-print the JSON response
-		output, err := json.MarshalIndent(resp, "", "  ")
+// buildHeaders returns the headers of the requests of the run: those of -H
+// and the config file, and the bearer token of AUTH_TOKEN. contentType, when
+// not empty, is the Content-Type they can override.
+func buildHeaders(cfg *types.CLIConfig, contentType string) map[string]string {
+	headers := make(map[string]string, len(cfg.Headers)+2)
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	if authToken := os.Getenv("AUTH_TOKEN"); authToken != "" {
+		headers["Authorization"] = "Bearer " + authToken
+	}
+	return headers
+}
+
+// runBatch executes all .graphql files of --batch-dir with their variables
+// and returns the exit status.
+func runBatch(r *runLifecycle, cfg *types.CLIConfig, in modeInputs) int {
+	if cfg.BaseURL == "" {
+		return r.fail("--base is required for batch execution")
+	}
+	logger.Info("Batch mode: scanning directory %s", cfg.BatchDir)
+	endBatch := r.phase("batch")
+	batchCtx, stopBatch := network.StartModule(r.ctx, "batch")
+	defer stopBatch()
+
+	// Per-file front matter headers override these.
+	headers := buildHeaders(cfg, "application/json")
+	result, err := cli.RunBatch(batchCtx, cfg.BatchDir, cfg.BaseURL, headers, cli.BatchOptions{
+		VarsSchema:       in.varsSchema,
+		KeepAllFragments: cfg.KeepAllFragments,
+		DryRun:           cfg.DryRun,
+		Preview:          cfg.Preview,
+		Observer:         in.observer,
+	})
+	if err != nil {
+		return r.fail("%v", err)
+	}
+	stopBatch()
+	endBatch()
+	if cfg.Preview {
+		cli.PrintPreviews(result.Previews, cfg.Redact)
+		if len(result.Failures) > 0 && !cfg.IgnoreFailures {
+			return 1
+		}
+		return 0
+	}
+	cli.PrintBatchSummary(result)
+	if cfg.DryRun {
+		cli.PrintPlan(result.Plan(cfg.BaseURL), cfg.Rate)
+		return 0
+	}
+	cli.PrintNonQueryOperations(network.NonQueryOperations())
+	errorsFile := filepath.Join(cfg.BatchDir, cli.BatchErrorsFile)
+	if err := cli.WriteBatchErrors(result, errorsFile); err != nil {
+		logger.Error("%v", err)
+	} else if len(result.Failures) > 0 {
+		logger.Info("Batch failures saved to %s", errorsFile)
+	}
+	r.artifact("batch-errors", errorsFile)
+	if cfg.Stats {
+		cli.PrintStats(network.Stats())
+	}
+	if len(result.Failures) > 0 && !cfg.IgnoreFailures {
+		return 1
+	}
+	return 0
+}
+
+// runExecute runs the query or mutation of --execute and returns the exit
+// status.
+func runExecute(r *runLifecycle, cfg *types.CLIConfig, in modeInputs) int {
+	if cfg.BaseURL == "" {
+		return r.fail("--base is required when using --execute")
+	}
+	// Load query
+	var query string
+	if cfg.QueryString != "" {
+		query = cfg.QueryString
+	} else if cfg.QueryFile != "" {
+		data, err := os.ReadFile(cfg.QueryFile)
 		if err != nil {
-			logger.Fatal("Error formatting response: %v", err)
+			return r.fail("Error reading query file: %v", err)
 		}
-		fmt.Println(string(output))
-		os.Exit(0)
+		query = string(data)
+	} else if cfg.DuplicateQuery == "" {
+		return r.fail("No query provided: use --query-string, --query-file or --duplicate-query")
 	}
 
-	// If subscribe flag is set, wait for user input before subscribing.
-	if cfg.Subscribe {
-		var query string
-		if cfg.SubQuery != "" {
-			query = cfg.SubQuery
-		} else {
-			fmt.Println("Subscription mode enabled. Please enter your subscription query:")
-			reader := bufio.NewReader(os.Stdin)
-			input, err := reader.ReadString('\n')
+	// Parse variables
+	var variables map[string]interface{}
+	if cfg.Variables != "" {
+		if err := json.Unmarshal([]byte(cfg.Variables), &variables); err != nil {
+			return r.fail("Error parsing variables JSON: %v", err)
+		}
+	} else if cfg.VariablesFile != "" {
+		data, err := os.ReadFile(cfg.VariablesFile)
+		if err != nil {
+			return r.fail("Error reading variables file: %v", err)
+		}
+		if err := json.Unmarshal(data, &variables); err != nil {
+			return r.fail("Error parsing variables file JSON: %v", err)
+		}
+	}
+
+	// Configure logging before request
+	logger.SetUTC(cfg.LogUTC)
+	logger.SetupLogging(cfg.LogLevel, cfg.LogFile, !cfg.NoColor)
+
+	if !cfg.KeepAllFragments {
+		query = cli.PruneFragments(query)
+	}
+	supplied := variables
+	variables = cli.FillVariables(query, variables, in.varsSchema)
+
+	// Prepare context
+	timeoutCtx, timeoutCancel := context.WithTimeout(r.ctx, cfg.Timeout)
+	defer timeoutCancel()
+
+	headers := buildHeaders(cfg, "application/json")
+
+	// Execute request, placing the query as --param-name and --duplicate-query ask
+	position := cfg.ParamName
+	if position == "" && cfg.DuplicateQuery != "" {
+		position = cli.DefaultDuplicatePosition
+	}
+	var strategy network.Strategy
+	if position != "" {
+		s, err := network.ParseStrategy(position)
+		if err != nil {
+			return r.fail("%v", err)
+		}
+		strategy = s
+	}
+	if cfg.Preview {
+		if cfg.FuzzCoercion {
+			return r.fail("--preview does not apply to --fuzz-coercion")
+		}
+		return previewExecute(r, timeoutCtx, cfg, strategy, query, supplied, variables, headers, in.varsSchema)
+	}
+	if cfg.FuzzCoercion {
+		return fuzzCoercion(r, cfg, query, headers)
+	}
+	if cfg.DryRun {
+		var ops []cli.PlannedOperation
+		if cfg.DuplicateQuery != "" {
+			benign, real, err := cli.ParseDuplicateQuery(cfg.DuplicateQuery)
 			if err != nil {
-				logger.Error("Error reading input: %v", err)
-				os.Exit(1)
+				return r.fail("%v", err)
 			}
-			query = strings.TrimSpace(input)
+			ops = append(cli.PlanOperations("--duplicate-query benign", benign), cli.PlanOperations("--duplicate-query real", real)...)
+		} else if cfg.QueryFile != "" {
+			ops = cli.PlanOperations(cfg.QueryFile, query)
+		} else {
+			ops = cli.PlanOperations("--query-string", query)
+		}
+		cli.PrintPlan(cli.PlanRequest("execute", cfg.BaseURL, ops), cfg.Rate)
+		return 0
+	}
+	endExecute := r.phase("execute")
+	executeCtx, stopExecute := network.StartModule(timeoutCtx, "execute")
+	var resp map[string]interface{}
+	if cfg.DuplicateQuery != "" {
+		benign, real, err := cli.ParseDuplicateQuery(cfg.DuplicateQuery)
+		if err != nil {
+			return r.fail("%v", err)
+		}
+		variables = cli.FillVariables(benign+"\n"+real, variables, in.varsSchema)
+		var executed string
+		resp, executed, err = cli.SendDuplicateQuery(executeCtx, cfg.BaseURL, strategy, benign, real, variables, headers)
+		if err != nil {
+			return r.fail("Execution error: %v", err)
+		}
+		logger.Info("Server executed the %s query (benign in body:query, real in %s)", executed, strategy)
+		query = real
+		if executed == "benign" {
+			query = benign
+		}
+	} else {
+		sent, err := network.SendWithStrategy(executeCtx, cfg.BaseURL, strategy, query, variables, headers)
+		if err != nil {
+			return r.fail("Execution error: %v", err)
 		}
+		resp = sent
+	}
+	stopExecute()
+	endExecute()
+	if err := in.observer.Observe(query, resp); err != nil {
+		logger.Warn("Not observing the response: %v", err)
+	}
 
-		// Attempt to subscribe using the generic function that tries both message types.
-		conn, err := subscription.SubscribeToQuery(cfg.WSURL, query)
+	// Pretty-print the JSON response
+	output, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return r.fail("Error formatting response: %v", err)
+	}
+	fmt.Println(string(output))
+	if cfg.Stats {
+		cli.PrintStats(network.Stats())
+	}
+	return 0
+}
+
+// runSubscribe subscribes to --sub-query, read from stdin without it, and
+// prints its events until the run is interrupted. It returns the exit status.
+func runSubscribe(r *runLifecycle, cfg *types.CLIConfig) int {
+	var query string
+	if cfg.SubQuery != "" {
+		query = cfg.SubQuery
+	} else {
+		fmt.Println("Subscription mode enabled. Please enter your subscription query:")
+		reader := bufio.NewReader(os.Stdin)
+		input, err := reader.ReadString('\n')
 		if err != nil {
-			logger.Error("Subscription error: %v", err)
-			os.Exit(1)
+			return r.fail("Error reading input: %v", err)
+		}
+		query = strings.TrimSpace(input)
+	}
+	if cfg.DryRun {
+		target := cfg.WSURL
+		if cfg.SubTransport == "sse" {
+			target = sseURL(cfg)
 		}
-		logger.Info("Subscription established. Listening for updates...")
-		subscription.Listen(conn)
-		os.Exit(0)
+		cli.PrintPlan(cli.PlanRequest("subscribe", target, cli.PlanOperations("--sub-query", query)), cfg.Rate)
+		return 0
 	}
 
-	// If neither a schema file nor a base URL is provided, show usage and exit.
-	if cfg.SchemaFile == "" && cfg.BaseURL == "" {
-		flag.Usage()
-		os.Exit(0)
+	// Ctrl-C cancels the handshake or the listen loop; --timeout bounds the handshake.
+	endSubscribe := r.phase("subscribe")
+	subOpts := subscription.SubscribeOptions{
+		HandshakeTimeout: cfg.Timeout,
+		AckTimeout:       cfg.SubAckTimeout,
+		ReadTimeout:      cfg.SubReadTimeout,
 	}
 
-	// Configure logging.
+	if cfg.SubTransport != "sse" {
+		// Attempt to subscribe using the generic function that tries both message types.
+		conn, err := subscription.SubscribeToQueryWithContext(r.ctx, cfg.WSURL, query, subOpts)
+		if err == nil {
+			logger.Info("Subscription established. Listening for updates...")
+			subscription.ListenWithContext(r.ctx, conn, subOpts)
+			endSubscribe()
+			return 0
+		}
+		if cfg.SubTransport == "ws" || r.ctx.Err() != nil {
+			return r.fail("Subscription error: %v", err)
+		}
+		logger.Warn("WARNING: WebSocket subscription failed (%v), trying Server-Sent Events", err)
+	}
+
+	sub, err := subscription.SubscribeSSE(r.ctx, sseURL(cfg), query, nil, buildHeaders(cfg, ""), subOpts)
+	if err != nil {
+		return r.fail("Subscription error: %v", err)
+	}
+	logger.Info("Subscription established over SSE (%s connection mode). Listening for updates...", sub.Mode)
+	sub.Listen(r.ctx)
+	endSubscribe()
+	return 0
+}
+
+// runRecover brute forces the schema of --base into --recover-schema and
+// returns the exit status.
+func runRecover(r *runLifecycle, cfg *types.CLIConfig) int {
+	if cfg.BaseURL == "" {
+		return r.fail("--base is required for --recover-schema")
+	}
+	if cfg.DryRun || cfg.Preview {
+		return r.fail("--recover-schema cannot be used with --dry-run or --preview: its probes depend on the responses to the previous ones")
+	}
+	if cfg.InferenceWorkers < 1 {
+		return r.fail("--inference-workers must be 1 or more")
+	}
+	if cfg.CheckpointEvery < 1 {
+		return r.fail("--checkpoint-every must be 1 or more")
+	}
+	logger.SetUTC(cfg.LogUTC)
 	logger.SetupLogging(cfg.LogLevel, cfg.LogFile, !cfg.NoColor)
 
-	// Handle schema parsing if the file option is provided.
-	if cfg.SchemaFile != "" {
-		cli.HandleSchemaFile(cfg.SchemaFile, cfg.List, cfg.Query, cfg.Mutation, cfg.AllQueries, cfg.AllMutations, cfg.MaxDepth)
-		os.Exit(0)
+	endRecover := r.phase("schema-recovery")
+	written, err := cli.RecoverSchema(r.ctx, cli.RecoverOptions{
+		Endpoint:        cfg.BaseURL,
+		Headers:         buildHeaders(cfg, "application/json"),
+		Wordlist:        cfg.Wordlist,
+		ArgWordlist:     cfg.ArgWordlist,
+		Workers:         cfg.InferenceWorkers,
+		Seed:            cfg.InferenceSeed,
+		Resume:          cfg.ResumeInference,
+		CheckpointEvery: cfg.CheckpointEvery,
+		Out:             cfg.RecoverSchema,
+	})
+	endRecover()
+	if written {
+		r.artifact("recovered-schema", cfg.RecoverSchema)
+	}
+	if _, statErr := os.Stat(cfg.ResumeInference); cfg.ResumeInference != "" && statErr == nil {
+		r.artifact("inference-checkpoint", cfg.ResumeInference)
+	}
+	if err != nil {
+		return r.fail("%v", err)
+	}
+	if cfg.Stats {
+		cli.PrintStats(network.Stats())
+	}
+	return 0
+}
+
+// runSchemaFile lists, generates or exports the operations of --schema-file
+// and returns the exit status.
+func runSchemaFile(r *runLifecycle, cfg *types.CLIConfig, in modeInputs) int {
+	defer r.phase("schema")()
+	if cfg.SDLOut != "" {
+		code := cli.WriteSchemaSDL(cfg.SchemaFile, cfg.SDLOut)
+		r.artifact("sdl", cfg.SDLOut)
+		return code
+	}
+	if cfg.CatalogOut != "" {
+		code := cli.WriteSchemaCatalog(cfg.SchemaFile, cfg.CatalogOut, cfg.CatalogFormat, cfg.MaxDepth, cfg.Selection, cfg.Sort, in.notes, in.sample)
+		r.artifact("catalog", cfg.CatalogOut)
+		return code
+	}
+	if cfg.OutDir != "" || cfg.VarsSchemaOut != "" {
+		code := 0
+		if cfg.OutDir != "" {
+			code = cli.ExportSchemaOperations(cfg.SchemaFile, cfg.OutDir, cfg.MaxDepth, cfg.Selection, cfg.Sort, in.sample)
+			r.artifact("operations", cfg.OutDir)
+		}
+		if code == 0 && cfg.VarsSchemaOut != "" {
+			code = cli.ExportSchemaVariables(cfg.SchemaFile, cfg.VarsSchemaOut, cfg.MaxDepth, cfg.Selection, cfg.Sort, in.sample)
+			r.artifact("variables-schemas", cfg.VarsSchemaOut)
+		}
+		return code
 	}
+	return cli.HandleSchemaFile(cfg.SchemaFile, cfg.List, cfg.Query, cfg.Mutation, cfg.AllQueries, cfg.AllMutations, cfg.MaxDepth, cfg.Selection, cfg.Sort, in.notes, cfg.Tag, in.sample)
+}
 
+// runAudits audits the targets of the run, once or every --watch, and
+// returns the exit status.
+func runAudits(r *runLifecycle, cfg *types.CLIConfig, in modeInputs) int {
 	cli.DisplayLogo()
-	logger.Info("GraphSpecter v1.0.0 starting...")
+	logger.Info("%s starting...", version.String())
 	logger.Debug("→ Timeout set to %s", cfg.Timeout)
 
+	// Set up target URLs for network operations.
+	bases := []string{cfg.BaseURL}
+	if cfg.BaseURL == "" && cfg.TargetsFile == "" {
+		// Findings of an offline audit without a target name the saved result.
+		bases = []string{cfg.IntrospectionFile}
+	}
+	if cfg.TargetsFile != "" {
+		var err error
+		if bases, err = cli.LoadTargets(cfg.TargetsFile); err != nil {
+			return r.fail("Error loading targets: %v", err)
+		}
+		logger.Info("Loaded %d target(s) from %s", len(bases), cfg.TargetsFile)
+		for _, base := range bases {
+			if err := network.CheckScope(base); err != nil {
+				return r.fail("Refusing to scan %s: %v", base, err)
+			}
+			if in.requestEncoding != "" {
+				network.SetEncoding(base, in.requestEncoding)
+			}
+		}
+	}
+	if cfg.Detect || cfg.VHosts != "" {
+		// Detection appends its paths to the bases, which must allow it.
+		for _, base := range bases {
+			if _, err := network.ParseBase(base); err != nil {
+				return r.fail("%v", err)
+			}
+		}
+	}
+
+	// Common headers for all requests.
+	headers := buildHeaders(cfg, "application/json")
+	logger.Debug("→ Using headers: %+v", cfg.Headers)
+	if os.Getenv("AUTH_TOKEN") != "" {
+		logger.Debug("→ Using authentication token from environment")
+	}
+
+	var groups []string
+	if cfg.AuditDoS {
+		groups = append(groups, checks.GroupDoS)
+	}
+	if cfg.AuditWS {
+		groups = append(groups, checks.GroupWS)
+	}
+	if cfg.AuditInjection {
+		groups = append(groups, checks.GroupInjection)
+	}
+	// The ws-url default is only a placeholder; the WebSocket check derives
+	// the URL from each target unless one was given explicitly.
+	wsURL := ""
+	if cmd.IsSet("ws-url") {
+		wsURL = cfg.WSURL
+	}
+	preset := ""
+	switch {
+	case cfg.Safe:
+		preset = checks.PresetSafe
+	case cfg.Aggressive:
+		preset = checks.PresetAggressive
+	}
+	selectedChecks, err := checks.SelectPreset(preset, cfg.Checks, cfg.SkipChecks, groups...)
+	if err != nil {
+		return r.fail("Invalid check selection: %v", err)
+	}
+	// The injection settings only matter to the checks that use them.
+	for _, c := range selectedChecks {
+		if g, ok := c.(checks.Grouped); !ok || g.Group() != checks.GroupInjection {
+			continue
+		}
+		if cfg.InjectionDelay < time.Second || cfg.InjectionDelay >= network.DefaultTimeout {
+			return r.fail("--injection-delay must be at least 1s and below the %s request timeout", network.DefaultTimeout)
+		}
+		if cfg.InjectionFactor <= 1 || cfg.InjectionTrials < 1 {
+			return r.fail("--injection-factor must be above 1 and --injection-trials at least 1")
+		}
+		break
+	}
+	checkTimeouts, err := checks.ParseTimeouts(cfg.CheckTimeouts)
+	if err != nil {
+		return r.fail("Invalid --check-timeout: %v", err)
+	}
+
+	opts := cli.AuditOptions{
+		OutputFile: cfg.OutputFile,
+		Checks:     selectedChecks,
+		Preset:     preset,
+		Extract:    cfg.Extract,
+		ExtractDir: cfg.ExtractDir,
+		Pagination: attacks.ExtractOptions{
+			FollowPagination: cfg.FollowPagination,
+			MaxPages:         cfg.MaxPages,
+			Observer:         in.observer,
+			Secrets:          in.scanner,
+		},
+		Injection: attacks.InjectionOptions{
+			Delay:  cfg.InjectionDelay,
+			Factor: cfg.InjectionFactor,
+			Trials: cfg.InjectionTrials,
+		},
+		WSURL:     wsURL,
+		MaxDepth:  cfg.MaxDepth,
+		Selection: cfg.Selection,
+		Notes:     in.notes,
+		Sample:    in.sample,
+
+		ChunkedIntrospection:   cfg.ChunkedIntrospection,
+		IntrospectionChunkSize: cfg.IntrospectionChunkSize,
+
+		RedactArtifacts: cfg.RedactArtifacts,
+		Policy: checks.Policy{
+			StopOnSeverity:  cfg.StopOnFinding,
+			ContinueOnError: cfg.ContinueOnError,
+			CheckTimeouts:   checkTimeouts,
+		},
+		Offline: cfg.Offline,
+		Whoami:  cfg.Whoami,
+	}
+	if cfg.VHosts != "" {
+		if opts.VirtualHosts, err = cli.LoadVirtualHosts(cfg.VHosts); err != nil {
+			return r.fail("Error loading virtual hosts: %v", err)
+		}
+		logger.Info("Loaded %d virtual host(s) from %s", len(opts.VirtualHosts), cfg.VHosts)
+	}
+	if cfg.IntrospectionFile != "" {
+		if opts.Saved, err = cli.LoadSavedIntrospection(cfg.IntrospectionFile); err != nil {
+			cli.PrintSchemaError(cfg.IntrospectionFile, err)
+			return 1
+		}
+	}
+	if cfg.MatrixDir != "" {
+		if !cfg.Extract {
+			return r.fail("--matrix-dir requires --extract")
+		}
+		if opts.Matrix, err = report.NewMatrixRecorder(cfg.MatrixDir); err != nil {
+			return r.fail("%v", err)
+		}
+	}
+	if cfg.CanaryQuery != "" {
+		if opts.Canary, err = cli.LoadCanary(cfg.CanaryQuery); err != nil {
+			return r.fail("%v", err)
+		}
+		opts.Canary.Diff = jsondiff.Options{Ignore: jsondiff.ParsePaths(cfg.CanaryIgnore), Unordered: jsondiff.ParsePaths(cfg.CanaryUnordered)}
+		if err := opts.Canary.Diff.Validate(); err != nil {
+			return r.fail("Invalid --canary-ignore or --canary-unordered: %v", err)
+		}
+	}
+	if cfg.IdentitiesFile != "" {
+		if opts.Identities, err = config.LoadIdentities(cfg.IdentitiesFile); err != nil {
+			return r.fail("Error loading identities: %v", err)
+		}
+		opts.AuthzDiff = jsondiff.Options{Ignore: jsondiff.ParsePaths(cfg.AuthzIgnore), Unordered: jsondiff.ParsePaths(cfg.AuthzUnordered)}
+		if err := opts.AuthzDiff.Validate(); err != nil {
+			return r.fail("Invalid --authz-ignore or --authz-unordered: %v", err)
+		}
+	} else if cfg.AuthzIgnore != "" || cfg.AuthzUnordered != "" {
+		return r.fail("--authz-ignore and --authz-unordered require --identities")
+	}
+	// Every iteration of a watch run audits all targets again, so the progress
+	// of one must not skip them in the next.
+	if (cfg.TargetsFile != "" || cfg.Resume) && cfg.Watch <= 0 {
+		if opts.State, err = openState(cfg, bases); err != nil {
+			return r.fail("%v", err)
+		}
+	}
+
+	if cfg.DryRun {
+		cli.PrintPlan(cli.PlanAudit(bases, cfg.Detect, headers, opts), cfg.Rate)
+		return 0
+	}
+
+	if cfg.Watch > 0 {
+		return watchAudits(r, cfg, bases, headers, opts)
+	}
+	sinks, err := report.NewDispatcher(cfg.Sinks, cfg.Redact, redact.Secrets(headers))
+	if err != nil {
+		return r.fail("%v", err)
+	}
+	opts.Sinks = sinks
+	rep, code := runAudit(r, cfg, bases, headers, opts)
+	summary := report.Summary{}
+	if rep != nil {
+		summary = report.NewSummary(rep)
+	}
+	sinks.Close(summary)
+	for _, spec := range cfg.Sinks {
+		if kind, file, _ := strings.Cut(spec, "="); (kind == report.SinkJSON || kind == report.SinkNDJSON) && file != "" && file != "-" {
+			r.artifact("sink", file)
+		}
+	}
+	return code
+}
+
+// runAudit audits bases once, within --timeout, and writes the report and
+// artifacts of the run. It returns the report, nil when the audit could not
+// run, and the exit status of the run.
+func runAudit(r *runLifecycle, cfg *types.CLIConfig, bases []string, headers map[string]string, opts cli.AuditOptions) (*report.Report, int) {
 	// Create a context with the user-specified timeout.
-	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	timeoutCtx, timeoutCancel := context.WithTimeout(r.ctx, cfg.Timeout)
 	defer timeoutCancel()
 
-	// Set up target URLs for network operations.
-	var targetURLs []string
+	endAudit := r.phase("audit")
+	var rep *report.Report
+	var err error
+	switch {
+	case len(opts.VirtualHosts) > 0:
+		rep, err = cli.DetectVirtualHosts(timeoutCtx, bases, opts.VirtualHosts, headers)
+		if err != nil {
+			return nil, r.fail("%v", err)
+		}
+		cli.PrintVirtualHosts(rep.VirtualHosts)
+		for _, f := range rep.Findings {
+			opts.Sinks.Emit(f)
+		}
+	case cfg.Detect:
+		// Detection mode: endpoints are audited as soon as they are confirmed.
+		rep, err = cli.DetectAndAudit(timeoutCtx, bases, headers, opts)
+		if err != nil {
+			return nil, r.fail("%v", err)
+		}
+	default:
+		// Use the base URLs directly if no detection is provided.
+		if cfg.TargetsFile == "" {
+			logger.Info("Using base URL as target: %s", cfg.BaseURL)
+		}
+		logger.Info("Starting GraphQL security audit...")
+		rep = cli.AuditEndpoints(timeoutCtx, bases, headers, opts)
+	}
+	endAudit()
+	r.manifest.Findings(rep.Findings)
+	for _, endpoint := range rep.Endpoints {
+		r.artifact("introspection", artifacts.Resolve(endpoint, introspection.OutputFileName(cfg.OutputFile, endpoint)))
+		r.artifact("catalog", artifacts.Resolve(endpoint, introspection.CatalogFileName(cfg.OutputFile, endpoint)))
+		if len(opts.Identities) > 0 {
+			r.artifact("authz", artifacts.Resolve(endpoint, introspection.AuthzFileName(cfg.OutputFile, endpoint)))
+		}
+		r.artifact("federation-sdl", artifacts.Resolve(endpoint, filepath.Join(filepath.Dir(cfg.OutputFile), "federation_"+introspection.EndpointSuffix(endpoint)+".graphql")))
+	}
+	if cfg.Extract {
+		r.artifact("extraction", cfg.ExtractDir)
+	}
+	if opts.Matrix != nil {
+		r.artifact("matrix", cfg.MatrixDir)
+	}
+	if opts.State != nil {
+		r.artifact("state", cfg.StateFile)
+	}
+	if cfg.Stats {
+		stats := network.Stats()
+		rep.Stats = &stats
+		cli.PrintStats(stats)
+	}
+	rep.NonQueryOperations = network.NonQueryOperations()
+	rep.Profile = r.profile
+	cli.PrintNonQueryOperations(rep.NonQueryOperations)
+	rep.SuppressedRequests = network.SuppressedRequests()
+	cli.PrintSuppressedRequests(rep.SuppressedRequests)
+	if cfg.ReportFile != "" {
+		if err := writeReport(r, cfg, rep, headers); err != nil {
+			return rep, r.fail("Error writing report: %v", err)
+		}
+	}
+	if rep.StateChanged() {
+		r.fail("WARNING: the scan changed server state: a canary query returned a different response after the scan")
+		return rep, exitStateChanged
+	}
+	if rep.Stopped != nil && rep.Stopped.Error {
+		r.manifest.Fail(rep.Stopped.Reason)
+		return rep, 1
+	}
+	return rep, 0
+}
 
-	if cfg.Detect {
-		// Detection mode.
-		logger.Info("Detection mode enabled. Scanning for GraphQL endpoints...")
-		detectedEndpoints, err := network.DetectAllGraphQLEndpointsWithContext(timeoutCtx, cfg.BaseURL, false)
+// applyBountyProfile enforces the constraints of p for the rest of the run,
+// see enforceBountyProfile. The URLs of cfg are checked up front so that a
+// target out of scope fails the run before any request is sent.
+func applyBountyProfile(cfg *types.CLIConfig, p *types.BountyProfile) error {
+	if err := enforceBountyProfile(cfg.ProfileBounty, p); err != nil {
+		return err
+	}
+	if err := checkTargetScope(cfg); err != nil {
+		return err
+	}
+	// Dry-run plans estimate durations at the capped rate.
+	cfg.Rate = network.RateLimit()
+	return nil
+}
+
+// enforceBountyProfile applies p, loaded from path, to the whole process: the
+// checks it forbids leave the registry, and the shared client refuses hosts
+// outside its allowed hosts, sets its headers and caps the rate and
+// concurrency.
+func enforceBountyProfile(path string, p *types.BountyProfile) error {
+	reason := "the bounty profile does not allow it"
+	if p.Program != "" {
+		reason = fmt.Sprintf("the %s bounty profile does not allow it", p.Program)
+	}
+	if err := checks.Forbid(reason, p.ForbiddenChecks...); err != nil {
+		return fmt.Errorf("invalid bounty profile %s: %w", path, err)
+	}
+	network.SetScope(p.AllowedHosts)
+	network.SetRequiredHeaders(p.Headers)
+	network.SetConcurrencyCap(p.MaxConcurrency)
+	network.SetRateCap(p.MaxRate)
+	return nil
+}
+
+// checkTargetScope fails when a URL given on the command line is out of scope,
+// so that the run stops before any request is sent. The --targets entries are
+// checked as they are loaded.
+func checkTargetScope(cfg *types.CLIConfig) error {
+	urls := map[string]string{"--base": cfg.BaseURL, "--preflight-url": cfg.PreflightURL}
+	if cmd.IsSet("ws-url") || cfg.Subscribe {
+		urls["--ws-url"] = cfg.WSURL
+	}
+	for _, name := range []string{"--base", "--preflight-url", "--ws-url"} {
+		if urls[name] == "" {
+			continue
+		}
+		if err := network.CheckScope(urls[name]); err != nil {
+			return fmt.Errorf("%s %s: %w", name, urls[name], err)
+		}
+	}
+	if cfg.VHosts != "" {
+		vhosts, err := cli.LoadVirtualHosts(cfg.VHosts)
 		if err != nil {
-			logger.Error("Detection failed: %v", err)
-			os.Exit(1)
+			// Reported when the virtual hosts are loaded.
+			return nil
 		}
-		if len(detectedEndpoints) == 0 {
-			logger.Error("No GraphQL endpoints detected")
-			os.Exit(1)
+		for _, vh := range vhosts {
+			if !network.InScope(vh) {
+				return fmt.Errorf("--vhosts %s: %s is out of scope", cfg.VHosts, vh)
+			}
+		}
+	}
+	return nil
+}
+
+// applyScope restricts the shared client to the hosts of --scope or, without
+// it, to the hosts of the explicit targets, so that redirects and URLs found
+// in responses cannot lead the run elsewhere. Under a bounty profile --scope
+// can only narrow the allowed hosts, which already restrict the run.
+func applyScope(cfg *types.CLIConfig) error {
+	if cfg.Scope != "" {
+		if err := network.NarrowScope(checks.ParseList(cfg.Scope)); err != nil {
+			return err
 		}
-		targetURLs = detectedEndpoints
-		logger.Info("Found %d GraphQL endpoints", len(targetURLs))
+		return checkTargetScope(cfg)
+	}
+	if cfg.ProfileBounty != "" {
+		return nil
+	}
+	urls := []string{cfg.BaseURL, cfg.PreflightURL}
+	if cmd.IsSet("ws-url") || cfg.Subscribe {
+		urls = append(urls, cfg.WSURL)
+	}
+	if cfg.TargetsFile != "" {
+		targets, err := cli.LoadTargets(cfg.TargetsFile)
+		if err != nil {
+			// Reported when the targets are loaded.
+			return nil
+		}
+		urls = append(urls, targets...)
+	}
+	if cfg.VHosts != "" {
+		vhosts, err := cli.LoadVirtualHosts(cfg.VHosts)
+		if err != nil {
+			// Reported when the virtual hosts are loaded.
+			return nil
+		}
+		for _, vh := range vhosts {
+			urls = append(urls, "//"+vh)
+		}
+	}
+	var hosts []string
+	seen := map[string]bool{}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" || seen[strings.ToLower(u.Hostname())] {
+			continue
+		}
+		seen[strings.ToLower(u.Hostname())] = true
+		hosts = append(hosts, u.Hostname())
+	}
+	network.SetScope(hosts)
+	return nil
+}
+
+// writeObservedSchema writes the schema observer built to path.
+func writeObservedSchema(r *runLifecycle, path string, observer *inference.Observer) {
+	if observer.Responses() == 0 {
+		logger.Warn("No response carried data, not writing the observed schema to %s", path)
+		return
+	}
+	if err := observer.WriteFile(path); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	logger.Info("Schema observed in %d response(s) saved to %s", observer.Responses(), path)
+	r.artifact("observed-schema", path)
+}
+
+// fuzzCoercion runs --execute --fuzz-coercion on query and returns the exit code.
+func fuzzCoercion(r *runLifecycle, cfg *types.CLIConfig, query string, headers map[string]string) int {
+	if cfg.DuplicateQuery != "" || cfg.ParamName != "" {
+		return r.fail("--fuzz-coercion cannot be combined with --duplicate-query or --param-name")
+	}
+	source := "--query-string"
+	if cfg.QueryString == "" {
+		source = cfg.QueryFile
+	}
+	if cfg.DryRun {
+		cli.PrintPlan(cli.PlanCoercion(cfg.BaseURL, source, query), cfg.Rate)
+		return 0
+	}
+	if cfg.Variables != "" || cfg.VariablesFile != "" {
+		logger.Warn("--fuzz-coercion sends placeholders for the variables it does not probe, ignoring --vars and --vars-file")
+	}
+
+	timeoutCtx, timeoutCancel := context.WithTimeout(r.ctx, cfg.Timeout)
+	defer timeoutCancel()
+	endExecute := r.phase("execute")
+	fuzzCtx, stopFuzz := network.StartModule(timeoutCtx, "fuzz-coercion")
+	findings, err := cli.FuzzCoercion(fuzzCtx, cfg.BaseURL, query, headers)
+	stopFuzz()
+	endExecute()
+	if err != nil {
+		return r.fail("%v", err)
+	}
+	report.SortFindings(findings)
+	r.manifest.Findings(findings)
+	for _, f := range findings {
+		logger.Info("[%s] %s: %s", f.Severity, f.Title, f.Evidence)
+	}
+	if len(findings) == 0 {
+		logger.Info("Every type-confused value was rejected")
+	}
+	if cfg.ReportFile == "" {
+		return 0
+	}
+
+	rep := &report.Report{Metadata: report.NewMetadata(), Endpoints: []string{cfg.BaseURL}, Findings: findings, Profile: r.profile}
+	if err := writeReport(r, cfg, rep, headers); err != nil {
+		return r.fail("Error writing report: %v", err)
+	}
+	return 0
+}
+
+// writeReport writes rep to --report in the format or template of the run,
+// with the reproduction commands of its findings next to it. The secrets of
+// headers are masked with --redact.
+func writeReport(r *runLifecycle, cfg *types.CLIConfig, rep *report.Report, headers map[string]string) error {
+	endReport := r.phase("report")
+	bodyDir := strings.TrimSuffix(cfg.ReportFile, filepath.Ext(cfg.ReportFile)) + "-requests"
+	if err := report.PrepareReproductions(rep, bodyDir, cfg.Redact); err != nil {
+		logger.Error("Error preparing reproduction commands: %v", err)
+	}
+	if err := report.ShapeEvidence(rep, cfg.ReportDetail, cfg.ReportFile, bodyDir, cfg.Redact); err != nil {
+		logger.Error("Error shaping report evidence: %v", err)
+	}
+	if cfg.Redact {
+		rep.Redact(redact.Secrets(headers))
+	}
+	rep.Redactions = redact.Count()
+	var err error
+	if cfg.ReportTemplate != "" {
+		err = report.WriteTemplate(rep, cfg.ReportFile, cfg.ReportTemplate)
 	} else {
-		// Use the base URL directly if no detection is provided.
-		targetURLs = append(targetURLs, cfg.BaseURL)
-		logger.Info("Using base URL as target: %s", cfg.BaseURL)
+		err = report.WriteFormat(rep, cfg.ReportFile, cfg.ReportFormat)
 	}
+	if err != nil {
+		return err
+	}
+	logger.Info("Report saved to %s", cfg.ReportFile)
+	endReport()
+	r.artifact("report", cfg.ReportFile)
+	r.artifact("reproductions", bodyDir)
+	return nil
+}
 
-	logger.Info("Starting GraphQL security audit...")
+// runSubcommand dispatches "graphspecter <name> ..." invocations and returns the exit code.
+func runSubcommand(name string, args []string) int {
+	switch name {
+	case "lint":
+		return cli.Lint(cmd.ParseLintFlags(args))
+	case "hash":
+		return cli.Hash(cmd.ParseHashFlags(args))
+	case "har":
+		return cli.HAR(cmd.ParseHARFlags(args))
+	case "server":
+		return runServer(cmd.ParseServerFlags(args))
+	case "compare":
+		return cli.Compare(cmd.ParseCompareFlags(args))
+	case "data":
+		return cli.Data(cmd.ParseDataFlags(args))
+	case "schema":
+		return cli.Schema(cmd.ParseSchemaFlags(args))
+	case "history":
+		return cli.History(cmd.ParseHistoryFlags(args))
+	case "bundle":
+		return cli.Bundle(cmd.ParseBundleFlags(args))
+	case "explain":
+		return cli.Explain(cmd.ParseExplainFlags(args))
+	case "verify":
+		return cli.Verify(cmd.ParseVerifyFlags(args))
+	case "repro":
+		return cli.Repro(cmd.ParseReproFlags(args))
+	case "completion":
+		return completion(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", name)
+		flag.Usage()
+		return 2
+	}
+}
 
-	// Common headers for all requests.
-	headers := map[string]string{"Content-Type": "application/json"}
-	if cfg.Headers != nil {
-		for k, v := range cfg.Headers {
-			headers[k] = v
+// completion prints the completion script of the shell named by args.
+func completion(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: graphspecter completion %s\n", strings.Join(cmd.CompletionShells, "|"))
+		return 2
+	}
+	script, err := cmd.CompletionScript(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Print(script)
+	return 0
+}
+
+// runServer serves the scan API until SIGINT or SIGTERM, then stops accepting
+// requests, cancels the running scans and waits for them to return.
+func runServer(cfg *types.ServerConfig) int {
+	if cfg.LogMaxBackups < 0 {
+		fmt.Fprintf(os.Stderr, "Invalid --log-max-backups %d: use 0 or more\n", cfg.LogMaxBackups)
+		return 2
+	}
+	logger.SetRotation(logger.Rotation{MaxSize: cfg.LogMaxSize, MaxBackups: cfg.LogMaxBackups, Compress: cfg.LogCompress})
+	logger.SetUTC(cfg.LogUTC)
+	logger.SetupLogging(cfg.LogLevel, cfg.LogFile, !cfg.NoColor)
+	defer logger.CloseLogFile()
+	token := os.Getenv(server.TokenEnv)
+	if token == "" {
+		logger.Warn("WARNING: %s is not set, the API accepts unauthenticated requests", server.TokenEnv)
+	}
+
+	var profile *types.BountyProfile
+	if cfg.ProfileBounty != "" {
+		var err error
+		if profile, err = config.LoadBountyProfile(cfg.ProfileBounty); err != nil {
+			logger.Error("%v", err)
+			return 1
+		}
+		if err := enforceBountyProfile(cfg.ProfileBounty, profile); err != nil {
+			logger.Error("%v", err)
+			return 1
 		}
+		logger.Info("Queued scans follow the bounty profile %s: only %s may be contacted", cfg.ProfileBounty, strings.Join(profile.AllowedHosts, ", "))
 	}
-	logger.Debug("→ Using headers: %+v", headers)
-	// Add Authorization header if AUTH_TOKEN environment variable is set.
-	if authToken := os.Getenv("AUTH_TOKEN"); authToken != "" {
-		logger.Debug("→ Using authentication token from environment")
-		headers["Authorization"] = "Bearer " + authToken
+
+	srv, err := server.New(server.Config{
+		Pipeline:    server.AuditPipeline{MaxDepth: cfg.MaxDepth, Profile: profile},
+		Workers:     cfg.Workers,
+		QueueSize:   cfg.QueueSize,
+		Dir:         cfg.Dir,
+		Persist:     cfg.Persist,
+		Token:       token,
+		ScanTimeout: cfg.ScanTimeout,
+	})
+	if err != nil {
+		logger.Error("%v", err)
+		return 1
+	}
+	httpServer := &http.Server{Addr: cfg.Listen, Handler: srv.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		logger.Info("Scan API listening on %s", cfg.Listen)
+		errc <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		srv.Close()
+		logger.Error("%v", err)
+		return 1
+	case <-ctx.Done():
+	}
+	logger.Info("Shutting down, canceling running scans...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Info("Error shutting down the API: %v", err)
 	}
+	srv.Close()
+	return 0
+}
+
+// setupPreflight fetches the preflight token and installs it for every request.
+func setupPreflight(ctx context.Context, cfg *types.CLIConfig) error {
+	if cfg.PreflightTokenExtract == "" {
+		return errors.New("--preflight-token-extract is required with --preflight-url")
+	}
+	preflight, err := auth.NewPreflight(auth.PreflightConfig{
+		URL:     cfg.PreflightURL,
+		Extract: cfg.PreflightTokenExtract,
+		Header:  cfg.PreflightTokenHeader,
+		Expired: cfg.PreflightExpired,
+		Headers: buildHeaders(cfg, ""),
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	if err := preflight.Refresh(ctx); err != nil {
+		return fmt.Errorf("Preflight failed: %w", err)
+	}
+	network.SetSession(preflight)
+	return nil
+}
 
-	cli.AuditEndpoints(timeoutCtx, targetURLs, headers, cfg.OutputFile)
+// openState returns the scan state for a multi-target run. With --resume the
+// saved state is loaded and its completed targets are skipped; otherwise the
+// state file starts over.
+func openState(cfg *types.CLIConfig, bases []string) (*workspace.State, error) {
+	state := workspace.NewState(cfg.StateFile)
+	if cfg.Resume {
+		var err error
+		if state, err = workspace.LoadState(cfg.StateFile); err != nil {
+			return nil, err
+		}
+	}
+	// Detection audits the endpoints it finds rather than the bases, so only
+	// direct targets are queued up front.
+	if !cfg.Detect {
+		if err := state.Add(bases...); err != nil {
+			return nil, fmt.Errorf("Error saving scan state: %w", err)
+		}
+	}
+	if cfg.Resume {
+		counts := state.Counts()
+		logger.Info("Resuming from %s: %d target(s) done, %d pending", cfg.StateFile, counts[workspace.StatusDone], counts[workspace.StatusPending])
+	}
+	return state, nil
+}
+
+// previewExecute prints the request --execute would send for query, or for
+// the pair of --duplicate-query, without sending it. variables are the
+// supplied ones completed with placeholders.
+func previewExecute(r *runLifecycle, ctx context.Context, cfg *types.CLIConfig, strategy network.Strategy, query string, supplied, variables map[string]interface{}, headers map[string]string, varsSchema *types.GQLSchema) int {
+	label := "--query-string"
+	if cfg.QueryFile != "" {
+		label = cfg.QueryFile
+	}
+	var req network.RequestPreview
+	var err error
+	if cfg.DuplicateQuery != "" {
+		benign, real, parseErr := cli.ParseDuplicateQuery(cfg.DuplicateQuery)
+		if parseErr != nil {
+			return r.fail("%v", parseErr)
+		}
+		label = "--duplicate-query"
+		variables = cli.FillVariables(benign+"\n"+real, variables, varsSchema)
+		req, err = cli.PreviewDuplicateQuery(ctx, cfg.BaseURL, strategy, benign, real, variables, headers)
+	} else {
+		req, err = network.PreviewWithStrategy(ctx, cfg.BaseURL, strategy, query, variables, headers)
+	}
+	if err != nil {
+		return r.fail("Preview error: %v", err)
+	}
+	cli.PrintPreviews([]cli.OperationPreview{{Label: label, Request: req, Synthesized: cli.Synthesized(supplied, variables)}}, cfg.Redact)
+	return 0
+}
+
+// sseURL is the endpoint of SSE subscriptions: --base when set, --ws-url with
+// an HTTP scheme otherwise.
+func sseURL(cfg *types.CLIConfig) string {
+	if cfg.BaseURL != "" {
+		return cfg.BaseURL
+	}
+	if rest, ok := strings.CutPrefix(cfg.WSURL, "wss://"); ok {
+		return "https://" + rest
+	}
+	if rest, ok := strings.CutPrefix(cfg.WSURL, "ws://"); ok {
+		return "http://" + rest
+	}
+	return cfg.WSURL
 }
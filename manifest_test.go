@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/cmd"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/manifest"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// runManifest runs args in-process as main does, with a run manifest, and
+// returns the exit status and the manifest the run left.
+func runManifest(t *testing.T, args ...string) (int, manifest.Manifest) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	args = append([]string{"--log-level", "error", "--run-manifest", path}, args...)
+	cfg, err := cmd.ParseArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { network.SetOffline(false) })
+	code := run(cfg, args)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest.Manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		t.Fatalf("the manifest is not JSON: %v\n%s", err, content)
+	}
+	if m.Version != 1 || m.Tool != "graphspecter" || m.PID != os.Getpid() || len(m.Args) != len(args) {
+		t.Errorf("manifest header = %+v", m)
+	}
+	// Every run ends with a finalized manifest, whatever stopped it.
+	if m.EndedAt == nil || m.EndedAt.Before(m.StartedAt) || m.ExitCode == nil || *m.ExitCode != code {
+		t.Errorf("manifest was not finalized with exit status %d: ended %v, exit code %v", code, m.EndedAt, m.ExitCode)
+	}
+	return code, m
+}
+
+func TestRunManifestSucceeded(t *testing.T) {
+	srv, _ := countingServer(t)
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	code, m := runManifest(t, "--base", srv.URL, "--checks", "query-policy", "--report", reportFile)
+	if code != 0 {
+		t.Fatalf("exit status %d", code)
+	}
+	if m.Status != manifest.StatusSucceeded || m.Error != "" {
+		t.Errorf("status = %s (%q), want succeeded", m.Status, m.Error)
+	}
+	var phases []string
+	for _, p := range m.Phases {
+		phases = append(phases, p.Name)
+	}
+	if len(phases) == 0 || phases[0] != "setup" {
+		t.Errorf("phases = %v, want setup first", phases)
+	}
+	var report *manifest.Artifact
+	for i, a := range m.Artifacts {
+		if a.Path == reportFile {
+			report = &m.Artifacts[i]
+		}
+	}
+	if report == nil || len(report.SHA256) != 64 {
+		t.Errorf("artifacts = %+v, want the report with its digest", m.Artifacts)
+	}
+	// The open server answers arbitrary queries, an info finding.
+	if m.Findings["info"] != 1 {
+		t.Errorf("findings = %v", m.Findings)
+	}
+}
+
+func TestRunManifestFailed(t *testing.T) {
+	code, m := runManifest(t, "--base", "http://127.0.0.1:1", "--report-format", "pdf")
+	if code != 1 {
+		t.Fatalf("exit status %d, want 1", code)
+	}
+	if m.Status != manifest.StatusFailed || m.Error != `Invalid --report-format "pdf" (valid: 'json', 'markdown', 'html', 'csv', 'sarif')` {
+		t.Errorf("status = %s (%q), want failed with the error", m.Status, m.Error)
+	}
+	if len(m.Artifacts) != 0 {
+		t.Errorf("artifacts = %+v, want none", m.Artifacts)
+	}
+}
+
+// TestRunManifestInterrupted interrupts a run while the server holds its
+// first request, as SIGINT from a terminal would.
+func TestRunManifestInterrupted(t *testing.T) {
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client going away once the body is read.
+		io.Copy(io.Discard, r.Body)
+		once.Do(func() { syscall.Kill(os.Getpid(), syscall.SIGINT) })
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	code, m := runManifest(t, "--base", srv.URL, "--checks", "query-policy")
+	if code != exitInterrupted {
+		t.Fatalf("exit status %d, want %d", code, exitInterrupted)
+	}
+	if m.Status != manifest.StatusInterrupted {
+		t.Errorf("status = %s, want interrupted", m.Status)
+	}
+}
+
+func TestOnSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &runLifecycle{ctx: ctx, cancel: cancel}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	inFlight := []network.InFlightRequest{
+		{Module: "query-policy", Endpoint: "https://api.example.com/graphql", Started: now.Add(-1500 * time.Millisecond)},
+		{Endpoint: "https://api.example.com/", Started: now.Add(-250 * time.Millisecond)},
+	}
+
+	var out bytes.Buffer
+	if r.onSignal(&out, now, inFlight) {
+		t.Fatal("the first signal quits at once")
+	}
+	want := "Interrupted, stopping the run... (interrupt again within 5s to quit at once)\n" +
+		"Waiting for 2 request(s) in flight:\n" +
+		"  query-policy https://api.example.com/graphql (1.5s)\n" +
+		"  - https://api.example.com/ (250ms)\n"
+	if out.String() != want {
+		t.Errorf("printed\n%s\nwant\n%s", out.String(), want)
+	}
+	if ctx.Err() == nil || !r.interrupted.Load() {
+		t.Error("the first signal did not stop the run")
+	}
+
+	out.Reset()
+	if !r.onSignal(&out, now.Add(forceQuitWindow), nil) || out.String() != "Interrupted again, quitting at once\n" {
+		t.Errorf("a second signal within the window did not quit: %q", out.String())
+	}
+	// A signal later than the window after the previous one starts over.
+	out.Reset()
+	if r.onSignal(&out, now.Add(2*forceQuitWindow+time.Millisecond), nil) || strings.Contains(out.String(), "in flight") {
+		t.Errorf("a late signal quit or listed requests: %q", out.String())
+	}
+}
+
+// TestRunManifestForceQuit signals a run twice while a request it sent is
+// held. The run exits with 131 on its own, so it runs in a child process.
+func TestRunManifestForceQuit(t *testing.T) {
+	if dir := os.Getenv("GRAPHSPECTER_FORCE_QUIT_DIR"); dir != "" {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+		}))
+		r, err := startRun(filepath.Join(dir, "manifest.json"), []string{"--base", srv.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The request outlives the run context, as one ignoring it would.
+		ctx, _ := network.StartModule(context.Background(), "query-policy")
+		go network.SendGraphQLRequestWithContext(ctx, srv.URL+"/graphql", "{ __typename }", nil, nil)
+		for len(network.InFlight()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+		<-r.ctx.Done()
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+		time.Sleep(10 * time.Second)
+		os.Exit(0)
+	}
+
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	child := exec.CommandContext(ctx, os.Args[0], "-test.run=^TestRunManifestForceQuit$")
+	child.Env = append(os.Environ(), "GRAPHSPECTER_FORCE_QUIT_DIR="+dir)
+	var stderr bytes.Buffer
+	child.Stderr = &stderr
+	err := child.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != exitForced {
+		t.Fatalf("the run ended with %v, want exit status %d\n%s", err, exitForced, stderr.String())
+	}
+	want := regexp.MustCompile(`Waiting for 1 request\(s\) in flight:\n  query-policy http://127\.0\.0\.1:\d+/graphql \([\d.]+m?s\)\nInterrupted again, quitting at once\n$`)
+	if !want.MatchString(stderr.String()) {
+		t.Errorf("stderr:\n%s\nwant the pending request, then the forced quit", stderr.String())
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest.Manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Status != manifest.StatusInterrupted || m.ExitCode == nil || *m.ExitCode != exitForced || m.EndedAt == nil {
+		t.Errorf("manifest status %s, exit code %v; want interrupted with %d", m.Status, m.ExitCode, exitForced)
+	}
+}
+
+// stamp matches an RFC 3339 timestamp.
+var stamp = regexp.MustCompile(`\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?(Z|[+-]\d\d:\d\d)`)
+
+// TestArtifactTimestampsAreUTC runs with the machine in another time zone and
+// checks that the artifacts the run persists hold RFC 3339 UTC timestamps.
+func TestArtifactTimestampsAreUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("EST", -5*3600)
+	t.Cleanup(func() { time.Local = local })
+
+	srv, _ := countingServer(t)
+	dir := t.TempDir()
+	targets := filepath.Join(dir, "targets.txt")
+	if err := os.WriteFile(targets, []byte(srv.URL+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"state":   filepath.Join(dir, "state.json"),
+		"history": filepath.Join(dir, "history.ndjson"),
+		"log":     filepath.Join(dir, "run.log"),
+	}
+	t.Cleanup(logger.CloseLogFile)
+	code, m := runManifest(t, "--targets", targets, "--checks", "query-policy",
+		"--state-file", files["state"], "--history", files["history"], "--log-file", files["log"], "--log-level", "info")
+	if code != 0 {
+		t.Fatalf("exit status %d", code)
+	}
+	if m.StartedAt.Location() != time.UTC || m.EndedAt.Location() != time.UTC {
+		t.Errorf("manifest times %v and %v, want UTC", m.StartedAt, m.EndedAt)
+	}
+	for name, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := stamp.FindAllString(string(content), -1)
+		if len(found) == 0 {
+			t.Errorf("the %s file holds no timestamp:\n%s", name, content)
+		}
+		for _, s := range found {
+			if !strings.HasSuffix(s, "Z") {
+				t.Errorf("the %s file holds the timestamp %s, want UTC", name, s)
+			}
+		}
+	}
+}
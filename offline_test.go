@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/cli"
+	"github.com/CyberRoute/graphspecter/pkg/cmd"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// panicTransport stands in for the network: any request sent through the
+// shared client fails the test.
+type panicTransport struct{}
+
+func (panicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	panic("offline run sent a request to " + req.URL.String())
+}
+
+// stubNetwork replaces the transport of the shared client by panicTransport
+// and panics on any GraphQL request reaching the network, until the test ends.
+func stubNetwork(t *testing.T) {
+	t.Helper()
+	client := network.Client()
+	transport := client.Transport
+	client.Transport = panicTransport{}
+	network.SetRequestHook(func(r network.SentRequest) {
+		panic("offline run sent a GraphQL request to " + r.URL)
+	})
+	t.Cleanup(func() {
+		client.Transport = transport
+		network.SetRequestHook(nil)
+		network.SetOffline(false)
+	})
+}
+
+// TestOfflineFileWorkflows runs every file-only workflow with --offline and
+// checks that none of them touches the network client.
+func TestOfflineFileWorkflows(t *testing.T) {
+	stubNetwork(t)
+	schemaFile := filepath.Join("testdata", "offline-schema.json")
+	dir := t.TempDir()
+
+	runs := []struct {
+		name string
+		args []string
+		// out is the file or directory the run writes, if any.
+		out string
+	}{
+		{name: "list", args: []string{"--schema-file", schemaFile, "--list", "all"}},
+		{name: "generate", args: []string{"--schema-file", schemaFile, "--all-queries", "--all-mutations"}},
+		{name: "catalog", args: []string{"--schema-file", schemaFile, "--catalog-out", filepath.Join(dir, "catalog.json")}, out: filepath.Join(dir, "catalog.json")},
+		{name: "export", args: []string{"--schema-file", schemaFile, "--out-dir", filepath.Join(dir, "ops")}, out: filepath.Join(dir, "ops")},
+		{name: "sdl", args: []string{"--schema-file", schemaFile, "--sdl-out", filepath.Join(dir, "schema.graphql")}, out: filepath.Join(dir, "schema.graphql")},
+		{name: "audit with stats", args: []string{"--introspection-file", schemaFile, "--stats", "--report", filepath.Join(dir, "report.json")}, out: filepath.Join(dir, "report.json")},
+	}
+	for _, run := range runs {
+		t.Run(run.name, func(t *testing.T) {
+			cfg, err := cmd.ParseArgs(append([]string{"--offline", "--log-level", "error"}, run.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if code := runModes(&runLifecycle{ctx: ctx, cancel: cancel}, cfg); code != 0 {
+				t.Fatalf("exit status %d", code)
+			}
+			if !network.Offline() {
+				t.Error("--offline did not disable the network client")
+			}
+			if run.out != "" {
+				if _, err := os.Stat(run.out); err != nil {
+					t.Errorf("nothing written: %v", err)
+				}
+			}
+		})
+	}
+
+	t.Run("schema lock and verify", func(t *testing.T) {
+		lock := filepath.Join(dir, "schema.lock")
+		if code := cli.Schema(&types.SchemaConfig{Args: []string{"lock"}, SchemaFile: schemaFile, Offline: true, Out: lock}); code != 0 {
+			t.Fatalf("schema lock exit status %d", code)
+		}
+		if code := cli.Schema(&types.SchemaConfig{Args: []string{"verify"}, SchemaFile: schemaFile, Offline: true, Lock: lock}); code != 0 {
+			t.Errorf("schema verify exit status %d, want the saved schema to match its own lock", code)
+		}
+	})
+
+	t.Run("lint", func(t *testing.T) {
+		if code := cli.Lint(&types.LintConfig{Dir: filepath.Join(dir, "ops"), MaxDepth: 10}); code != 0 {
+			t.Errorf("lint exit status %d", code)
+		}
+	})
+}
+
+// TestOfflineRefusesNetworkModes checks that --offline stops before the modes
+// that need the network.
+func TestOfflineRefusesNetworkModes(t *testing.T) {
+	stubNetwork(t)
+	schemaFile := filepath.Join("testdata", "offline-schema.json")
+	for _, flag := range [][]string{{"--detect"}, {"--execute"}, {"--subscribe"}, {"--batch-dir", t.TempDir()}, {"--canary-query", "{ __typename }"}, {"--watch", "1m"}} {
+		t.Run(flag[0], func(t *testing.T) {
+			cfg, err := cmd.ParseArgs(append([]string{"--offline", "--introspection-file", schemaFile, "--base", "http://127.0.0.1:1/graphql"}, flag...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if code := runModes(&runLifecycle{ctx: ctx, cancel: cancel}, cfg); code == 0 {
+				t.Error("exit status 0")
+			}
+		})
+	}
+	if code := cli.Schema(&types.SchemaConfig{Args: []string{"verify"}, BaseURL: "http://127.0.0.1:1/graphql", Offline: true}); code != 2 {
+		t.Errorf("schema verify --offline without --schema-file exit status %d, want 2", code)
+	}
+	if code := cli.Schema(&types.SchemaConfig{Args: []string{"lock"}, BaseURL: "http://127.0.0.1:1/graphql", SchemaFile: schemaFile}); code != 2 {
+		t.Errorf("schema lock with --base and --schema-file exit status %d, want 2", code)
+	}
+}
@@ -0,0 +1,199 @@
+// Package artifacts writes the files a run leaves behind. Every file is
+// written to a temporary file next to it, synced to disk and renamed into
+// place, so that readers and a process killed mid-write never see a partial
+// file, and writes to the same path are serialized. Writers that could pick
+// the same path for different things, such as the dumps of two endpoints
+// sharing a path segment, claim it first and the later ones are given a
+// numbered path instead. What is written is hashed on the way, for the
+// digests and detached signatures of sign.go.
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+)
+
+var (
+	mu sync.Mutex
+	// locks serializes the writes to each path.
+	locks = make(map[string]*sync.Mutex)
+	// owners maps claimed paths to the owner holding them.
+	owners = make(map[string]string)
+	// claims maps an owner and the path it asked for to the path it holds.
+	claims = make(map[claim]string)
+	// digests maps written paths to the hex SHA-256 of what was written.
+	digests = make(map[string]string)
+)
+
+// claim is a path as asked for by an owner.
+type claim struct{ owner, path string }
+
+// key returns the form of path the maps are keyed by.
+func key(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// lockFor returns the lock serializing the writes to path.
+func lockFor(path string) *sync.Mutex {
+	mu.Lock()
+	defer mu.Unlock()
+	k := key(path)
+	l, ok := locks[k]
+	if !ok {
+		l = new(sync.Mutex)
+		locks[k] = l
+	}
+	return l
+}
+
+// Claim returns the path owner is to write in place of path. The first owner
+// to claim a path holds it; a later owner is warned and given the path with a
+// numbered suffix before its extension, "introspection_graphql-2.json". An
+// owner claiming a path again gets the same answer, so that rewriting an
+// artifact, as watch mode does, replaces it.
+func Claim(owner, path string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	c := claim{owner, key(path)}
+	if held, ok := claims[c]; ok {
+		return held
+	}
+	held := path
+	if o, ok := owners[key(path)]; ok && o != owner {
+		ext := filepath.Ext(path)
+		base := strings.TrimSuffix(path, ext)
+		for n := 2; ; n++ {
+			held = fmt.Sprintf("%s-%d%s", base, n, ext)
+			if _, taken := owners[key(held)]; !taken {
+				break
+			}
+		}
+		logger.Info("WARNING: %s is already written for %s; writing the one of %s to %s", path, o, owner, held)
+	}
+	owners[key(held)] = owner
+	claims[c] = held
+	return held
+}
+
+// Resolve returns the path Claim gave owner for path, or path when owner has
+// not claimed it.
+func Resolve(owner, path string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	if held, ok := claims[claim{owner, key(path)}]; ok {
+		return held
+	}
+	return path
+}
+
+// WriteFile writes data to path with permissions perm, replacing any file
+// there only once data is on disk.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	f, err := Create(path, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// File is an artifact being written. Nothing appears at its path until
+// Commit; Abort, or a crash before Commit, leaves the path as it was. Other
+// writes to the path wait until the File is committed or aborted. What is
+// written is hashed on the way, for Digest.
+type File struct {
+	file *os.File
+	hash hash.Hash
+	path string
+	perm os.FileMode
+	lock *sync.Mutex
+	done bool
+}
+
+// Create starts writing the artifact at path, to be given permissions perm.
+func Create(path string, perm os.FileMode) (*File, error) {
+	lock := lockFor(path)
+	lock.Lock()
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	return &File{file: tmp, hash: sha256.New(), path: path, perm: perm, lock: lock}, nil
+}
+
+// Write writes p to the file.
+func (f *File) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	f.hash.Write(p[:n])
+	return n, err
+}
+
+// Name returns the path of the temporary file written until Commit.
+func (f *File) Name() string { return f.file.Name() }
+
+// Commit syncs the file to disk and renames it into place.
+func (f *File) Commit() error {
+	if f.done {
+		return fmt.Errorf("%s already committed or aborted", f.path)
+	}
+	f.done = true
+	defer f.lock.Unlock()
+	tmp := f.Name()
+	err := f.file.Chmod(f.perm)
+	if err == nil {
+		err = f.file.Sync()
+	}
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, f.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(f.path))
+	mu.Lock()
+	digests[key(f.path)] = hex.EncodeToString(f.hash.Sum(nil))
+	mu.Unlock()
+	return nil
+}
+
+// Abort discards the file. It does nothing once the file is committed, so it
+// can be deferred.
+func (f *File) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	defer f.lock.Unlock()
+	f.file.Close()
+	os.Remove(f.Name())
+}
+
+// syncDir syncs the directory holding a renamed file, so that the rename
+// survives a crash. Systems that cannot sync directories are left alone.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
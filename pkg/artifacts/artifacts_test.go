@@ -0,0 +1,179 @@
+package artifacts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// failingReader returns n bytes and then fails, as a writer killed mid-write.
+type failingReader struct{ n int }
+
+var errInjected = errors.New("injected failure")
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, errInjected
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = 'x'
+	}
+	r.n -= len(p)
+	return len(p), nil
+}
+
+// tempFiles returns the temporary files Create left in dir.
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestFailedWriteLeavesNoPartialFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+
+	f, err := Create(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(f, &failingReader{n: 64 << 10}); !errors.Is(err, errInjected) {
+		t.Fatalf("copy error = %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("partial file at the target path before Abort: %v", err)
+	}
+	f.Abort()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("partial file at the target path: %v", err)
+	}
+	if tmp := tempFiles(t, dir); len(tmp) != 0 {
+		t.Errorf("temporary files left: %v", tmp)
+	}
+	// The aborted write released the path.
+	if err := WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKilledWriterKeepsPreviousFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "introspection.json")
+	if err := WriteFile(path, []byte("previous\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The writer goroutine dies halfway without committing or aborting.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f, err := Create(path, 0644)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		f.Write([]byte("half of the new"))
+		runtime.Goexit()
+	}()
+	<-done
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "previous\n" {
+		t.Errorf("target path holds %q, %v; want the previous file", data, err)
+	}
+	for _, tmp := range tempFiles(t, dir) {
+		if !strings.HasPrefix(filepath.Base(tmp), "introspection.json.") {
+			t.Errorf("unexpected temporary file %s", tmp)
+		}
+	}
+}
+
+func TestConcurrentWritesAreNotTorn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evidence.txt")
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data := bytes.Repeat([]byte{byte('a' + i)}, 256<<10)
+			if err := WriteFile(path, data, 0644); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 256<<10 || bytes.Count(data, data[:1]) != len(data) {
+		t.Errorf("file is a mix of writes: %d bytes starting %q", len(data), data[:1])
+	}
+	digest, err := Digest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := hashFile(path); digest != want {
+		t.Errorf("Digest() = %s, want the hash of the last write %s", digest, want)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "introspection_graphql.json")
+
+	if got := Claim("https://a.example/graphql", path); got != path {
+		t.Errorf("first claim = %s, want %s", got, path)
+	}
+	second := filepath.Join(dir, "introspection_graphql-2.json")
+	if got := Claim("https://b.example/graphql", path); got != second {
+		t.Errorf("second claim = %s, want %s", got, second)
+	}
+	if got := Claim("https://c.example/graphql", path); got != filepath.Join(dir, "introspection_graphql-3.json") {
+		t.Errorf("third claim = %s", got)
+	}
+	// Claiming again, as watch mode does, keeps the path.
+	if got := Claim("https://b.example/graphql", path); got != second {
+		t.Errorf("repeated claim = %s, want %s", got, second)
+	}
+	if got := Resolve("https://b.example/graphql", path); got != second {
+		t.Errorf("Resolve() = %s, want %s", got, second)
+	}
+	if got := Resolve("https://d.example/graphql", path); got != path {
+		t.Errorf("Resolve() without a claim = %s, want %s", got, path)
+	}
+}
+
+func TestCommitTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	f, err := Create(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(f, "{}")
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	f.Abort()
+	if err := f.Commit(); err == nil {
+		t.Error("second Commit succeeded")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("committed file: %v, %v", info, err)
+	}
+}
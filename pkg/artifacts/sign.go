@@ -0,0 +1,142 @@
+package artifacts
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SignatureExt is appended to the path of an artifact to name its detached
+// signature.
+const SignatureExt = ".sig"
+
+// ErrDigestMismatch is returned by Verify for a file whose content no longer
+// hashes to the recorded digest.
+var ErrDigestMismatch = errors.New("file does not match its recorded SHA-256")
+
+// LoadPrivateKey reads an Ed25519 private key from a PEM file holding a PKCS #8
+// "PRIVATE KEY" block, as written by "openssl genpkey -algorithm ed25519".
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing signing key %s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an Ed25519 key", path)
+	}
+	return private, nil
+}
+
+// LoadPublicKey reads an Ed25519 public key from a PEM file holding a PKIX
+// "PUBLIC KEY" block, as written by "openssl pkey -pubout".
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key %s: %w", path, err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an Ed25519 key", path)
+	}
+	return public, nil
+}
+
+// readPEM returns the first PEM block of the file at path, which must be of
+// type kind.
+func readPEM(path, kind string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != kind {
+		return nil, fmt.Errorf("%s holds no PEM %q block", path, kind)
+	}
+	return block, nil
+}
+
+// Digest returns the hex SHA-256 of the file at path. For a file this process
+// wrote through Create or WriteFile, it is the hash of what was written, so
+// that a file changed since does not match it; other files are read and
+// hashed.
+func Digest(path string) (string, error) {
+	mu.Lock()
+	digest, ok := digests[key(path)]
+	mu.Unlock()
+	if ok {
+		return digest, nil
+	}
+	return hashFile(path)
+}
+
+// hashFile returns the hex SHA-256 of the content of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Sign writes the detached signature of the file at path, whose hex SHA-256
+// is digest, next to it and returns the path of the signature. The signature
+// is the Ed25519 signature of the 32 bytes of the digest, base64-encoded.
+func Sign(key ed25519.PrivateKey, path, digest string) (string, error) {
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 digest %q", digest)
+	}
+	sigPath := path + SignatureExt
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, sum))
+	if err := WriteFile(sigPath, []byte(sig+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("error writing signature: %w", err)
+	}
+	return sigPath, nil
+}
+
+// Verify checks that the file at path hashes to digest, when digest is set,
+// and that the detached signature at sigPath signs its hash with public.
+func Verify(public ed25519.PublicKey, path, digest, sigPath string) error {
+	actual, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	if digest != "" && !strings.EqualFold(actual, digest) {
+		return ErrDigestMismatch
+	}
+	data, err := os.ReadFile(sigPath)
+	if err != nil {
+		return fmt.Errorf("error reading signature: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("error decoding signature %s: %w", sigPath, err)
+	}
+	sum, _ := hex.DecodeString(actual)
+	if !ed25519.Verify(public, sum, sig) {
+		return fmt.Errorf("signature %s does not match the public key", sigPath)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package artifacts
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKeyPair generates an Ed25519 key pair and writes it to dir as PEM
+// files, returning their paths.
+func writeKeyPair(t *testing.T, dir, name string) (private, public string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, public = filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".pub")
+	if err := os.WriteFile(private, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(public, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return private, public
+}
+
+// signedArtifact writes an artifact to dir and signs it with the key at
+// private, returning the paths of the artifact and its signature and the
+// digest recorded while writing.
+func signedArtifact(t *testing.T, dir, private string) (path, sigPath, digest string) {
+	t.Helper()
+	key, err := LoadPrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir, "report.md")
+	if err := WriteFile(path, []byte("# Findings\n\nIntrospection is enabled.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if digest, err = Digest(path); err != nil {
+		t.Fatal(err)
+	}
+	if sigPath, err = Sign(key, path, digest); err != nil {
+		t.Fatal(err)
+	}
+	if sigPath != path+SignatureExt {
+		t.Errorf("signature written to %s", sigPath)
+	}
+	return path, sigPath, digest
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	private, public := writeKeyPair(t, dir, "key")
+	path, sigPath, digest := signedArtifact(t, dir, private)
+	pub, err := LoadPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := hashFile(path); digest != want {
+		t.Errorf("digest recorded while writing %s, file hashes to %s", digest, want)
+	}
+	if err := Verify(pub, path, digest, sigPath); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+	if err := Verify(pub, path, "", sigPath); err != nil {
+		t.Errorf("Verify() without a digest = %v", err)
+	}
+}
+
+func TestVerifyTamperedArtifact(t *testing.T) {
+	dir := t.TempDir()
+	private, public := writeKeyPair(t, dir, "key")
+	path, sigPath, digest := signedArtifact(t, dir, private)
+	pub, err := LoadPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("# Findings\n\nNothing found.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The digest recorded while writing is kept, so the change shows.
+	if got, _ := Digest(path); got != digest {
+		t.Errorf("Digest() after tampering = %s, want the recorded %s", got, digest)
+	}
+	if err := Verify(pub, path, digest, sigPath); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Verify() = %v, want %v", err, ErrDigestMismatch)
+	}
+	// Without a digest the signature no longer matches the content.
+	if err := Verify(pub, path, "", sigPath); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Verify() without a digest = %v", err)
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	private, _ := writeKeyPair(t, dir, "key")
+	_, other := writeKeyPair(t, dir, "other")
+	path, sigPath, digest := signedArtifact(t, dir, private)
+	pub, err := LoadPublicKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(pub, path, digest, sigPath); err == nil || !strings.Contains(err.Error(), "does not match the public key") {
+		t.Errorf("Verify() with another key = %v", err)
+	}
+}
+
+func TestLoadKeysRejectsWrongBlocks(t *testing.T) {
+	dir := t.TempDir()
+	private, public := writeKeyPair(t, dir, "key")
+	if _, err := LoadPrivateKey(public); err == nil {
+		t.Error("LoadPrivateKey() read a public key")
+	}
+	if _, err := LoadPublicKey(private); err == nil {
+		t.Error("LoadPublicKey() read a private key")
+	}
+	if _, err := Sign(nil, filepath.Join(dir, "x"), "not-a-digest"); err == nil {
+		t.Error("Sign() accepted an invalid digest")
+	}
+}
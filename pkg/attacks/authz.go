@@ -0,0 +1,102 @@
+package attacks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// AuthzResult is how every identity fared with a query of the catalog.
+type AuthzResult struct {
+	Operation string `json:"operation"`
+	Query     string `json:"query"`
+	// AuthHints are the reasons of the catalog to expect the query to
+	// require authorization.
+	AuthHints []string `json:"authHints,omitempty"`
+	// Responses are those of the identities, in their order.
+	Responses []IdentityResponse `json:"responses"`
+}
+
+// IdentityResponse is the response to a query sent as one identity.
+type IdentityResponse struct {
+	Identity string `json:"identity"`
+	// Access is AccessAllowed, AccessDenied or AccessError, as for
+	// ExtractResult.Access.
+	Access  string   `json:"access"`
+	Records int      `json:"records"`
+	Errors  []string `json:"errors,omitempty"`
+	// Fields are the access of the identity to the fields the query
+	// selects, nil when it was not answered.
+	Fields FieldAccessMap `json:"fields,omitempty"`
+	// Data is the value of the operation field, nil when it returned none.
+	// It is never saved, since it holds the data of the identity.
+	Data interface{} `json:"-"`
+}
+
+// IdentityHeaders returns the headers id sends: the Content-Type of headers,
+// which carry the credentials of the run, and its own headers.
+func IdentityHeaders(headers map[string]string, id types.Identity) map[string]string {
+	h := make(map[string]string, len(id.Headers)+1)
+	for k, v := range headers {
+		if strings.EqualFold(k, "Content-Type") {
+			h[k] = v
+		}
+	}
+	for k, v := range id.Headers {
+		h[k] = v
+	}
+	return h
+}
+
+// AuthzMatrix sends the executable document of every query of catalog to url
+// as each of identities, in turn, and records how each of them was answered.
+// Every identity sends the Content-Type of headers and its own headers, and
+// nothing else of headers, which carry the credentials of the run. Mutations
+// are never sent.
+func AuthzMatrix(ctx context.Context, url string, catalog *schema.Catalog, identities []types.Identity, headers map[string]string) ([]AuthzResult, error) {
+	queries := catalog.Queries()
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("schema has no queries")
+	}
+	identityHeaders := make([]map[string]string, len(identities))
+	for i, id := range identities {
+		identityHeaders[i] = IdentityHeaders(headers, id)
+	}
+
+	var results []AuthzResult
+	for _, op := range queries {
+		if op.Executable == "" {
+			logger.Error("Failed to generate query for %s", op.Name)
+			continue
+		}
+		result := AuthzResult{Operation: op.Name, Query: op.Executable, AuthHints: op.AuthHints}
+		for i, id := range identities {
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			logger.Debug("→ Sending %s as %s", op.Name, id.Name)
+			resp, err := network.SendGraphQLRequestWithContext(ctx, url, op.Executable, nil, identityHeaders[i])
+			r := IdentityResponse{Identity: id.Name, Access: access(resp, err, op.Name)}
+			if err != nil {
+				r.Errors = []string{err.Error()}
+			} else {
+				r.Errors = graphQLErrorMessages(resp)
+				if r.Fields, err = MapFieldAccess(op.Executable, "", resp); err != nil {
+					logger.Debug("Failed to map the field access of %s as %s: %v", op.Name, id.Name, err)
+				}
+				if data, ok := resp["data"].(map[string]interface{}); ok {
+					r.Data = data[op.Name]
+					r.Records, _ = countRecords(r.Data)
+				}
+			}
+			result.Responses = append(result.Responses, r)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
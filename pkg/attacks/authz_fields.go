@@ -0,0 +1,217 @@
+package attacks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+)
+
+// Field access outcomes
+const (
+	// FieldAllowed marks a field that returned a value.
+	FieldAllowed = "allowed"
+	// FieldDenied marks a field refused with an authorization error at its
+	// path or at the path of an enclosing field.
+	FieldDenied = "denied"
+	// FieldUnknown marks a field that returned null or nothing without an
+	// authorization error, which tells nothing of its access.
+	FieldUnknown = "unknown"
+)
+
+// fieldOutcomeRank orders outcomes for merging: a field allowed anywhere in a
+// response is readable, one denied and never allowed is gated.
+var fieldOutcomeRank = map[string]int{FieldUnknown: 0, FieldDenied: 1, FieldAllowed: 2}
+
+// FieldAccessMap maps the paths of the fields an operation selects, response
+// keys joined by dots without list indices (e.g. "users.email"), to their
+// access outcomes for the identity that sent it.
+type FieldAccessMap map[string]string
+
+// MapFieldAccess works out from resp, the response to document, which fields
+// selected by its operation the identity could read. Partial responses are
+// what it is for: GraphQL servers enforcing authorization per field return
+// the other fields with an error whose path points at each refused one, and
+// null bubbling turns the enclosing object to null when the refused field is
+// non-null. Errors are classified with the error-patterns dataset; an
+// authorization error without a path, with no data, denies every field.
+// document must hold one operation, or name it with operationName.
+func MapFieldAccess(document, operationName string, resp map[string]interface{}) (FieldAccessMap, error) {
+	doc, err := gql.Parse(document)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing document: %w", err)
+	}
+	var op *gql.Operation
+	switch {
+	case operationName != "":
+		op = doc.OperationByName(operationName)
+	case len(doc.Operations) == 1:
+		op = doc.Operations[0]
+	}
+	if op == nil {
+		return nil, fmt.Errorf("no operation %q in document", operationName)
+	}
+
+	w := fieldWalker{doc: doc, access: make(FieldAccessMap), denied: make(map[string]bool)}
+	wholesale := false
+	errs, _ := resp["errors"].([]interface{})
+	for _, e := range errs {
+		m, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		message, _ := m["message"].(string)
+		var code string
+		if ext, ok := m["extensions"].(map[string]interface{}); ok {
+			code, _ = ext["code"].(string)
+		}
+		if !isAuthError(message, code) {
+			continue
+		}
+		if path := errorPath(m["path"]); path != "" {
+			w.denied[path] = true
+		} else {
+			wholesale = true
+		}
+	}
+	result, _ := resp["data"].(map[string]interface{})
+	inherited := ""
+	if wholesale && result == nil {
+		inherited = FieldDenied
+	}
+	w.walk(op.SelectionSet, []interface{}{result}, "", inherited, nil)
+	return w.access, nil
+}
+
+// isAuthError reports whether an error with message and extensions.code is
+// an authorization error.
+func isAuthError(message, code string) bool {
+	for _, c := range gql.ErrorClasses(message, code) {
+		if c == data.ErrorAuth {
+			return true
+		}
+	}
+	return false
+}
+
+// errorPath joins the response keys of the path of an error, dropping list
+// indices.
+func errorPath(path interface{}) string {
+	elems, _ := path.([]interface{})
+	var keys []string
+	for _, e := range elems {
+		if key, ok := e.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	return strings.Join(keys, ".")
+}
+
+// fieldWalker records the access to the fields of a selection set.
+type fieldWalker struct {
+	doc    *gql.Document
+	access FieldAccessMap
+	// denied holds the paths of authorization errors.
+	denied map[string]bool
+}
+
+// walk records the fields of set, selected on each of objects at prefix. A
+// nil object stands for a null or missing one, whose fields are unknown
+// unless denied. inherited, when set, is the outcome of an enclosing denied
+// field. spreads guards against fragments spreading themselves.
+func (w *fieldWalker) walk(set []gql.Selection, objects []interface{}, prefix, inherited string, spreads map[string]bool) {
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *gql.Field:
+			if s.Name == "__typename" {
+				continue
+			}
+			path := s.ResponseKey()
+			if prefix != "" {
+				path = prefix + "." + path
+			}
+			var values []interface{}
+			outcome := FieldUnknown
+			for _, obj := range objects {
+				m, _ := obj.(map[string]interface{})
+				if v, ok := m[s.ResponseKey()]; ok && v != nil {
+					outcome = FieldAllowed
+					values = append(values, flatten(v)...)
+				}
+			}
+			switch {
+			case outcome == FieldAllowed:
+			case inherited != "":
+				outcome = inherited
+			case w.denied[path]:
+				outcome = FieldDenied
+			}
+			w.record(path, outcome)
+			if len(s.SelectionSet) == 0 {
+				continue
+			}
+			child := inherited
+			if outcome == FieldDenied {
+				child = FieldDenied
+			}
+			if len(values) == 0 {
+				values = []interface{}{nil}
+			}
+			w.walk(s.SelectionSet, values, path, child, spreads)
+		case *gql.InlineFragment:
+			w.walk(s.SelectionSet, objects, prefix, inherited, spreads)
+		case *gql.FragmentSpread:
+			frag := w.doc.FragmentByName(s.Name)
+			if frag == nil || spreads[s.Name] {
+				continue
+			}
+			inner := map[string]bool{s.Name: true}
+			for name := range spreads {
+				inner[name] = true
+			}
+			w.walk(frag.SelectionSet, objects, prefix, inherited, inner)
+		}
+	}
+}
+
+// record merges outcome into the access of path, allowed beating denied and
+// denied beating unknown, so that a field readable on some list items counts
+// as readable.
+func (w *fieldWalker) record(path, outcome string) {
+	if current, ok := w.access[path]; ok && fieldOutcomeRank[current] >= fieldOutcomeRank[outcome] {
+		return
+	}
+	w.access[path] = outcome
+}
+
+// flatten returns the items of v, of nested lists included, or v itself when
+// it is not a list. Null items are dropped.
+func flatten(v interface{}) []interface{} {
+	list, ok := v.([]interface{})
+	if !ok {
+		return []interface{}{v}
+	}
+	var items []interface{}
+	for _, item := range list {
+		if item != nil {
+			items = append(items, flatten(item)...)
+		}
+	}
+	return items
+}
+
+// LoginGatedFields returns the sorted paths of the fields identity reads and
+// anonymous is denied: data on which a login is the only check, which any
+// account, however low its privileges, reads.
+func LoginGatedFields(anonymous, identity FieldAccessMap) []string {
+	var paths []string
+	for path, outcome := range identity {
+		if outcome == FieldAllowed && anonymous[path] == FieldDenied {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,70 @@
+package attacks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fieldAccessFixture is a response to a document and the field access
+// MapFieldAccess should work out from it.
+type fieldAccessFixture struct {
+	Document string                 `json:"document"`
+	Response map[string]interface{} `json:"response"`
+	Want     FieldAccessMap         `json:"want"`
+}
+
+func TestMapFieldAccessFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "field_access", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var f fieldAccessFixture
+			if err := json.Unmarshal(data, &f); err != nil {
+				t.Fatal(err)
+			}
+			got, err := MapFieldAccess(f.Document, "", f.Response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, f.Want) {
+				t.Errorf("MapFieldAccess =\n%v\nwant\n%v", got, f.Want)
+			}
+		})
+	}
+}
+
+func TestMapFieldAccessOperationName(t *testing.T) {
+	doc := "query A { a } query B { b }"
+	resp := map[string]interface{}{"data": map[string]interface{}{"b": 1.0}}
+	got, err := MapFieldAccess(doc, "B", resp)
+	if err != nil || !reflect.DeepEqual(got, FieldAccessMap{"b": FieldAllowed}) {
+		t.Errorf("MapFieldAccess = %v, %v", got, err)
+	}
+	if _, err := MapFieldAccess(doc, "", resp); err == nil {
+		t.Error("MapFieldAccess picked an operation of a document holding two")
+	}
+	if _, err := MapFieldAccess("query {", "", resp); err == nil {
+		t.Error("MapFieldAccess accepted a malformed document")
+	}
+}
+
+func TestLoginGatedFields(t *testing.T) {
+	anonymous := FieldAccessMap{"me": FieldAllowed, "me.id": FieldAllowed, "me.email": FieldDenied, "me.phone": FieldDenied, "me.ssn": FieldDenied, "me.bio": FieldUnknown}
+	user := FieldAccessMap{"me": FieldAllowed, "me.id": FieldAllowed, "me.email": FieldAllowed, "me.phone": FieldAllowed, "me.ssn": FieldDenied, "me.bio": FieldAllowed}
+	if got := LoginGatedFields(anonymous, user); !reflect.DeepEqual(got, []string{"me.email", "me.phone"}) {
+		t.Errorf("LoginGatedFields = %v", got)
+	}
+	if got := LoginGatedFields(nil, user); got != nil {
+		t.Errorf("LoginGatedFields without an anonymous response = %v", got)
+	}
+}
@@ -0,0 +1,172 @@
+package attacks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+// Coercion probe outcomes
+const (
+	// CoercionRejected marks a value refused with GraphQL errors and no data,
+	// as the spec requires for a variable that cannot be coerced.
+	CoercionRejected = "rejected"
+	// CoercionCrashed marks a value answered with an HTTP 5xx status.
+	CoercionCrashed = "crashed"
+	// CoercionAccepted marks a value the operation executed with.
+	CoercionAccepted = "accepted"
+	// CoercionNoResponse marks a probe that got no HTTP response.
+	CoercionNoResponse = "no-response"
+)
+
+// CoercionValue is a value of the wrong JSON type for a variable.
+type CoercionValue struct {
+	// Kind describes the confusion, such as "array" or "string".
+	Kind  string
+	Value interface{}
+}
+
+// coercionValues lists, by built-in scalar, the values a strict server must
+// refuse for it. Int accepts no strings, floats or integers beyond 32 bits,
+// String no numbers or booleans; ID and Float accept integers, so those are
+// not sent.
+var coercionValues = map[string][]CoercionValue{
+	"String":  {{"number", 1}, {"boolean", true}},
+	"ID":      {{"boolean", true}, {"float", 1.5}},
+	"Int":     {{"string", "1"}, {"float", 1.5}, {"out-of-range", int64(1) << 31}, {"boolean", true}},
+	"Float":   {{"string", "1.5"}, {"boolean", true}},
+	"Boolean": {{"string", "true"}, {"number", 0}},
+}
+
+// CoercionProbe is the response to one type-confused variable value.
+type CoercionProbe struct {
+	Variable string
+	// Declared is the type of the variable in the operation.
+	Declared string
+	Kind     string
+	Value    interface{}
+	// Variables are all the variables sent, Value included.
+	Variables map[string]interface{}
+	Outcome   string
+	Status    int
+	Errors    []string
+	Error     string
+}
+
+// CoercionValues returns the type-confused values sent for a variable of type
+// t: an array and an object in place of a built-in scalar and the values of
+// other scalar types it must refuse, an object and confused elements for a
+// list, and null when t is non-null. Custom scalars, enums and input objects
+// only get null, since their coercion rules are up to the server.
+func CoercionValues(t *gql.Type) []CoercionValue {
+	var values []CoercionValue
+	if t.NonNull {
+		values = append(values, CoercionValue{Kind: "null", Value: nil})
+	}
+	if t.Elem != nil {
+		// A single value where a list is expected is coerced to a list of one.
+		values = append(values, CoercionValue{Kind: "object", Value: map[string]interface{}{}})
+		for _, v := range CoercionValues(t.Elem) {
+			values = append(values, CoercionValue{Kind: "element-" + v.Kind, Value: []interface{}{v.Value}})
+		}
+		return values
+	}
+	scalarValues, ok := coercionValues[t.Name]
+	if !ok {
+		return values
+	}
+	valid := schema.PlaceholderValues[t.Name]
+	values = append(values,
+		CoercionValue{Kind: "array", Value: []interface{}{valid}},
+		CoercionValue{Kind: "object", Value: map[string]interface{}{"value": valid}},
+	)
+	return append(values, scalarValues...)
+}
+
+// TestVariableCoercion sends document, a query declaring varDefs, once with
+// placeholder values for its required variables as a baseline, then once per
+// type-confused value of each variable, the other variables keeping their
+// placeholders. Each probe is classified as rejected, crashed or accepted;
+// probes that got no response are recorded as such. It fails when the
+// baseline gets no response.
+func TestVariableCoercion(ctx context.Context, url, document string, varDefs []*gql.VariableDefinition, headers map[string]string) ([]CoercionProbe, error) {
+	base, _ := schema.SynthesizeVariables(nil, varDefs, nil)
+	baseline := sendCoercionProbe(ctx, url, document, base, headers)
+	if baseline.Outcome == CoercionNoResponse {
+		return nil, fmt.Errorf("error sending the baseline request: %s", baseline.Error)
+	}
+	if baseline.Outcome != CoercionAccepted {
+		logger.Warn("The baseline request with placeholder variables was %s; probes may be refused for other reasons", baseline.Outcome)
+	}
+
+	var probes []CoercionProbe
+	for _, def := range varDefs {
+		for _, v := range CoercionValues(def.Type) {
+			if ctx.Err() != nil {
+				return probes, ctx.Err()
+			}
+			vars := make(map[string]interface{}, len(base)+1)
+			for k, value := range base {
+				vars[k] = value
+			}
+			vars[def.Name] = v.Value
+			probe := sendCoercionProbe(ctx, url, document, vars, headers)
+			probe.Variable = def.Name
+			probe.Declared = def.Type.String()
+			probe.Kind = v.Kind
+			probe.Value = v.Value
+			probe.Variables = vars
+			probes = append(probes, probe)
+		}
+	}
+	return probes, nil
+}
+
+// sendCoercionProbe sends document with vars and classifies the response.
+// Partial results with resolver errors count as accepted: the value passed
+// variable coercion.
+func sendCoercionProbe(ctx context.Context, url, document string, vars map[string]interface{}, headers map[string]string) CoercionProbe {
+	var info network.ResponseInfo
+	resp, err := network.SendGraphQLRequestWithContext(network.WithResponseInfo(ctx, &info), url, document, vars, headers)
+	probe := CoercionProbe{Status: info.StatusCode}
+	if errs, ok := resp["errors"].([]interface{}); ok {
+		for _, e := range errs {
+			if m, ok := e.(map[string]interface{}); ok {
+				message, _ := m["message"].(string)
+				probe.Errors = append(probe.Errors, message)
+			}
+		}
+	}
+	if err != nil {
+		probe.Error = err.Error()
+	}
+	switch {
+	case info.StatusCode == 0:
+		probe.Outcome = CoercionNoResponse
+		if probe.Error == "" {
+			probe.Error = fmt.Sprintf("no response from %s", url)
+		}
+	case info.StatusCode >= http.StatusInternalServerError:
+		probe.Outcome = CoercionCrashed
+	case err == nil && resp["data"] != nil:
+		probe.Outcome = CoercionAccepted
+	default:
+		probe.Outcome = CoercionRejected
+	}
+	return probe
+}
+
+// FormatCoercionValue renders a probe value as JSON.
+func FormatCoercionValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
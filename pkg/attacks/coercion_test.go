@@ -0,0 +1,174 @@
+package attacks
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+)
+
+const coercionDocument = `query Search($id: ID!, $n: Int, $tags: [String!]) { search(id: $id, n: $n, tags: $tags) { id } }`
+
+// coercionServer answers each request with the status and body respond
+// returns for its variables.
+func coercionServer(t *testing.T, respond func(vars map[string]interface{}) (int, string)) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		status, body := respond(req.Variables)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// int32Value reports whether v is a JSON number the Int scalar accepts.
+func int32Value(v interface{}) bool {
+	f, ok := v.(float64)
+	return ok && f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxInt32
+}
+
+// strictCoercion coerces the variables of coercionDocument as the GraphQL
+// spec requires.
+func strictCoercion(vars map[string]interface{}) (int, string) {
+	switch id := vars["id"].(type) {
+	case string:
+	case float64:
+		if !int32Value(id) {
+			return 200, `{"errors":[{"message":"Variable \"$id\" got invalid value; ID cannot represent value"}]}`
+		}
+	default:
+		return 200, `{"errors":[{"message":"Variable \"$id\" of non-null type \"ID!\" got an invalid value"}]}`
+	}
+	if n, ok := vars["n"]; ok && n != nil && !int32Value(n) {
+		return 200, `{"errors":[{"message":"Variable \"$n\" got invalid value; Int cannot represent non-integer value"}]}`
+	}
+	switch tags := vars["tags"].(type) {
+	case nil, string:
+	case []interface{}:
+		for _, tag := range tags {
+			if _, ok := tag.(string); !ok {
+				return 200, `{"errors":[{"message":"Variable \"$tags\" got invalid value at \"tags[0]\""}]}`
+			}
+		}
+	default:
+		return 200, `{"errors":[{"message":"Variable \"$tags\" got invalid value; String cannot represent a non string value"}]}`
+	}
+	return 200, `{"data":{"search":[]}}`
+}
+
+// laxCoercion executes with whatever it is sent, and crashes when $n is set
+// to something other than a number.
+func laxCoercion(vars map[string]interface{}) (int, string) {
+	if n, ok := vars["n"]; ok && n != nil {
+		if _, number := n.(float64); !number {
+			return 500, `{"errors":[{"message":"TypeError: n.toFixed is not a function"}]}`
+		}
+	}
+	return 200, `{"data":{"search":[]}}`
+}
+
+func coercionVariables(t *testing.T) []*gql.VariableDefinition {
+	t.Helper()
+	doc, err := gql.Parse(coercionDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc.Operations[0].VariableDefinitions
+}
+
+// outcomes maps each variable to the kinds of its probes by outcome.
+func outcomes(probes []CoercionProbe) map[string]map[string][]string {
+	out := make(map[string]map[string][]string)
+	for _, p := range probes {
+		if out[p.Variable] == nil {
+			out[p.Variable] = make(map[string][]string)
+		}
+		out[p.Variable][p.Outcome] = append(out[p.Variable][p.Outcome], p.Kind)
+	}
+	return out
+}
+
+func TestCoercionValues(t *testing.T) {
+	var kinds []string
+	for _, def := range coercionVariables(t) {
+		for _, v := range CoercionValues(def.Type) {
+			kinds = append(kinds, def.Name+" "+v.Kind)
+		}
+	}
+	want := []string{
+		"id null", "id array", "id object", "id boolean", "id float",
+		"n array", "n object", "n string", "n float", "n out-of-range", "n boolean",
+		"tags object", "tags element-null", "tags element-array", "tags element-object", "tags element-number", "tags element-boolean",
+	}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("CoercionValues() kinds = %q\nwant %q", kinds, want)
+	}
+	if values := CoercionValues(&gql.Type{Name: "DateTime", NonNull: true}); len(values) != 1 || values[0].Kind != "null" {
+		t.Errorf("a custom scalar gets %+v, want only null", values)
+	}
+}
+
+func TestVariableCoercionStrictServer(t *testing.T) {
+	url := coercionServer(t, strictCoercion)
+	probes, err := TestVariableCoercion(context.Background(), url, coercionDocument, coercionVariables(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(probes) != 17 {
+		t.Fatalf("%d probes, want one per confused value", len(probes))
+	}
+	for _, p := range probes {
+		if p.Outcome != CoercionRejected || p.Status != 200 || len(p.Errors) != 1 || !strings.Contains(p.Errors[0], "$"+p.Variable) {
+			t.Errorf("$%s %s: %s (%d) %q, want it rejected naming the variable", p.Variable, p.Kind, p.Outcome, p.Status, p.Errors)
+		}
+		// The other variables keep their placeholders.
+		if p.Variable != "id" && p.Variables["id"] == nil {
+			t.Errorf("$%s %s was sent without $id: %v", p.Variable, p.Kind, p.Variables)
+		}
+	}
+}
+
+func TestVariableCoercionLaxServer(t *testing.T) {
+	url := coercionServer(t, laxCoercion)
+	probes, err := TestVariableCoercion(context.Background(), url, coercionDocument, coercionVariables(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string][]string{
+		"id": {CoercionAccepted: {"null", "array", "object", "boolean", "float"}},
+		"n": {
+			CoercionCrashed:  {"array", "object", "string", "boolean"},
+			CoercionAccepted: {"float", "out-of-range"},
+		},
+		"tags": {CoercionAccepted: {"object", "element-null", "element-array", "element-object", "element-number", "element-boolean"}},
+	}
+	if got := outcomes(probes); !reflect.DeepEqual(got, want) {
+		t.Errorf("outcomes = %v\nwant %v", got, want)
+	}
+	for _, p := range probes {
+		if p.Outcome == CoercionCrashed && (p.Status != 500 || p.Errors[0] != "TypeError: n.toFixed is not a function") {
+			t.Errorf("$%s %s crashed with %d %q", p.Variable, p.Kind, p.Status, p.Errors)
+		}
+	}
+}
+
+func TestVariableCoercionWithoutBaseline(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	probes, err := TestVariableCoercion(context.Background(), srv.URL, coercionDocument, coercionVariables(t), nil)
+	if err == nil || !strings.Contains(err.Error(), "error sending the baseline request") || probes != nil {
+		t.Errorf("TestVariableCoercion() = %d probes, %v; want the baseline error", len(probes), err)
+	}
+}
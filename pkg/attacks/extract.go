@@ -0,0 +1,433 @@
+// Package attacks implements active probes that execute operations against a target
+package attacks
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/inference"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+// sampleValueLimit is the number of characters of a sampled string value kept in the output.
+const sampleValueLimit = 32
+
+// ExtractResult summarises what a single query returned.
+type ExtractResult struct {
+	Operation string      `json:"operation"`
+	Query     string      `json:"query"`
+	NonEmpty  bool        `json:"nonEmpty"`
+	Records   int         `json:"records"`
+	Sample    interface{} `json:"sample,omitempty"`
+	// Redactions is the number of sensitive values masked in Sample.
+	Redactions int      `json:"redactions,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+	File       string   `json:"file,omitempty"`
+	// Pagination is the pagination style followed, Pages the number of pages
+	// fetched and Stop why following ended.
+	Pagination string `json:"pagination,omitempty"`
+	Pages      int    `json:"pages,omitempty"`
+	Stop       string `json:"stop,omitempty"`
+	// Secrets are the secrets found in the data returned, with paths
+	// starting at the operation, such as users.3.apiKey.
+	Secrets []SecretHit `json:"secrets,omitempty"`
+	// Access is whether the operation could be executed, from the response
+	// to its first request: AccessAllowed, AccessDenied or AccessError.
+	Access string `json:"access,omitempty"`
+}
+
+// Values of ExtractResult.Access
+const (
+	// AccessAllowed means the operation returned its field, even null or empty,
+	// without an authorization error.
+	AccessAllowed = "accessible"
+	// AccessDenied means an error refused the caller, as classified by the
+	// error-patterns dataset.
+	AccessDenied = "denied"
+	// AccessError means the request failed or the operation returned other
+	// errors and no data.
+	AccessError = "error"
+)
+
+// SecretHit is a secret returned by an operation.
+type SecretHit struct {
+	redact.SecretHit
+	// Page is the page it was returned in when pagination was followed,
+	// counted from 1.
+	Page int `json:"page,omitempty"`
+}
+
+// ExtractOptions controls how Extract executes the queries of a catalog.
+type ExtractOptions struct {
+	// FollowPagination pages through queries whose pagination shape the catalog
+	// records, up to MaxPages pages per query.
+	FollowPagination bool
+	MaxPages         int
+	// Observer, when set, builds a schema from the responses.
+	Observer *inference.Observer
+	// Secrets, when set, scans the data of every response for secrets.
+	Secrets *redact.Scanner
+}
+
+// Reasons recorded in ExtractResult.Stop
+const (
+	StopLastPage       = "last page"
+	StopMaxPages       = "max pages reached"
+	StopRepeatedCursor = "cursor repeated"
+	StopRepeatedPage   = "page repeated"
+	StopError          = "request failed"
+)
+
+// Extract executes the executable document of every query in the catalog against url
+// and reports which operations returned data. Mutations are never executed.
+func Extract(ctx context.Context, url string, catalog *schema.Catalog, headers map[string]string, opts ExtractOptions) ([]ExtractResult, error) {
+	queries := catalog.Queries()
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("schema has no queries")
+	}
+
+	var results []ExtractResult
+	for _, op := range queries {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		if op.Executable == "" {
+			logger.Error("Failed to generate query for %s", op.Name)
+			continue
+		}
+
+		if opts.FollowPagination && op.Pagination != nil {
+			results = append(results, followPages(ctx, url, op, headers, opts))
+			continue
+		}
+
+		logger.Debug("→ Extracting %s", op.Name)
+		result := ExtractResult{Operation: op.Name, Query: op.Executable}
+		resp, err := network.SendGraphQLRequestWithContext(ctx, url, op.Executable, nil, headers)
+		result.Access = access(resp, err, op.Name)
+		if err != nil {
+			result.Errors = []string{err.Error()}
+			results = append(results, result)
+			continue
+		}
+
+		observe(opts.Observer, op.Executable, resp)
+		result.Errors = graphQLErrorMessages(resp)
+		scanSecrets(opts.Secrets, &result, resp, 0)
+		if data, ok := resp["data"].(map[string]interface{}); ok {
+			value := data[op.Name]
+			result.Records, result.NonEmpty = countRecords(value)
+			result.Sample, result.Redactions = sampleRecord(value)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// followPages requests the pages of op in turn, advancing the cursor or offset,
+// and sums the records returned. It stops on the last page, after
+// opts.MaxPages, on an error, or when the server sends a cursor or page it
+// already sent, which would otherwise loop forever.
+func followPages(ctx context.Context, url string, op schema.CatalogOperation, headers map[string]string, opts ExtractOptions) ExtractResult {
+	p := op.Pagination
+	result := ExtractResult{Operation: op.Name, Query: p.Document, Pagination: p.Style, Stop: StopMaxPages}
+	vars := map[string]interface{}{}
+	if p.Style == schema.PaginationOffset {
+		vars[p.Argument] = 0
+	}
+	seen := make(map[string]bool)
+
+	for result.Pages < opts.MaxPages {
+		if ctx.Err() != nil {
+			result.Errors = append(result.Errors, ctx.Err().Error())
+			result.Stop = StopError
+			break
+		}
+		logger.Debug("→ Extracting %s, page %d", op.Name, result.Pages+1)
+		resp, err := network.SendGraphQLRequestWithContext(ctx, url, p.Document, vars, headers)
+		if result.Access == "" {
+			result.Access = access(resp, err, op.Name)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			result.Stop = StopError
+			break
+		}
+		result.Pages++
+		observe(opts.Observer, p.Document, resp)
+		result.Errors = append(result.Errors, graphQLErrorMessages(resp)...)
+		scanSecrets(opts.Secrets, &result, resp, result.Pages)
+
+		data, _ := resp["data"].(map[string]interface{})
+		value := data[op.Name]
+		records := value
+		if p.Records != "" {
+			conn, _ := value.(map[string]interface{})
+			records = conn[p.Records]
+		}
+		page, _ := records.([]interface{})
+		result.Records += len(page)
+		if result.Sample == nil {
+			sample := records
+			if p.Records == "edges" && len(page) > 0 {
+				if edge, ok := page[0].(map[string]interface{}); ok && edge["node"] != nil {
+					sample = edge["node"]
+				}
+			}
+			result.Sample, result.Redactions = sampleRecord(sample)
+		}
+		if len(page) == 0 {
+			result.Stop = StopLastPage
+			break
+		}
+
+		switch p.Style {
+		case schema.PaginationRelay:
+			conn, _ := value.(map[string]interface{})
+			info, _ := conn["pageInfo"].(map[string]interface{})
+			cursor, _ := info["endCursor"].(string)
+			if more, _ := info["hasNextPage"].(bool); !more || cursor == "" {
+				result.Stop = StopLastPage
+			} else if seen[cursor] {
+				// The page ended where an earlier one did, so it repeats it.
+				result.Records -= len(page)
+				result.Stop = StopRepeatedCursor
+			}
+			seen[cursor] = true
+			vars[p.Argument] = cursor
+		case schema.PaginationOffset:
+			if len(page) < schema.PageSize {
+				result.Stop = StopLastPage
+				break
+			}
+			// A server ignoring the offset sends the same page every time.
+			key, _ := json.Marshal(page)
+			if seen[string(key)] {
+				result.Records -= len(page)
+				result.Stop = StopRepeatedPage
+			}
+			seen[string(key)] = true
+			vars[p.Argument] = vars[p.Argument].(int) + len(page)
+		}
+		if result.Stop != StopMaxPages {
+			break
+		}
+	}
+	result.NonEmpty = result.Records > 0
+	if result.Stop == StopRepeatedCursor || result.Stop == StopRepeatedPage {
+		logger.Warn("Stopped following %s after %d pages: %s", op.Name, result.Pages, result.Stop)
+	}
+	return result
+}
+
+// observe feeds resp, returned for document, to observer.
+func observe(observer *inference.Observer, document string, resp map[string]interface{}) {
+	if err := observer.Observe(document, resp); err != nil {
+		logger.Debug("→ Not observing the response: %v", err)
+	}
+}
+
+// scanSecrets adds to result the secrets scanner finds in the data of resp,
+// returned on page, or 0 when pagination is not followed.
+func scanSecrets(scanner *redact.Scanner, result *ExtractResult, resp map[string]interface{}, page int) {
+	data, ok := resp["data"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	var hits []SecretHit
+	scanner.Scan(data, func(hit redact.SecretHit) {
+		hits = append(hits, SecretHit{SecretHit: hit, Page: page})
+	})
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Path != hits[j].Path {
+			return hits[i].Path < hits[j].Path
+		}
+		return hits[i].Detector < hits[j].Detector
+	})
+	result.Secrets = append(result.Secrets, hits...)
+}
+
+// graphQLErrorMessages returns the messages of the errors array of a response.
+func graphQLErrorMessages(resp map[string]interface{}) []string {
+	var messages []string
+	errs, _ := resp["errors"].([]interface{})
+	for _, e := range errs {
+		if m, ok := e.(map[string]interface{}); ok {
+			if msg, ok := m["message"].(string); ok {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return messages
+}
+
+// access classifies the response to a request of the operation field, for
+// ExtractResult.Access. Authorization errors make it denied even with partial
+// data, since they show that the server checks the caller.
+func access(resp map[string]interface{}, err error, field string) string {
+	if err != nil {
+		return AccessError
+	}
+	if gql.HasErrorClass(resp, data.ErrorAuth) {
+		return AccessDenied
+	}
+	fields, _ := resp["data"].(map[string]interface{})
+	if _, ok := fields[field]; ok {
+		if errs, _ := resp["errors"].([]interface{}); len(errs) == 0 || fields[field] != nil {
+			return AccessAllowed
+		}
+	}
+	return AccessError
+}
+
+// countRecords returns the number of records held in value: the length of a list,
+// 1 for an object or scalar and 0 for null.
+func countRecords(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case nil:
+		return 0, false
+	case []interface{}:
+		return len(v), len(v) > 0
+	default:
+		return 1, true
+	}
+}
+
+// sampleRecord returns a truncated and redacted copy of the first record in value
+// and the number of values redacted. It is redacted before it is truncated so
+// that secrets are recognised whole, and random-looking tokens are masked too.
+func sampleRecord(value interface{}) (interface{}, int) {
+	if list, ok := value.([]interface{}); ok {
+		if len(list) == 0 {
+			return nil, 0
+		}
+		value = list[0]
+	}
+	masked, n := redact.Tree(value)
+	return truncate(masked, &n), n
+}
+
+// truncate shortens long strings and keeps only the first element of nested
+// lists. Strings redact.HighEntropy takes for tokens are masked and counted
+// in n.
+func truncate(value interface{}, n *int) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			out[k] = truncate(child, n)
+		}
+		return out
+	case []interface{}:
+		if len(v) == 0 {
+			return v
+		}
+		return []interface{}{truncate(v[0], n)}
+	case string:
+		if redact.HighEntropy(v) {
+			*n++
+			return redact.Mask
+		}
+		if utf8.RuneCountInString(v) > sampleValueLimit {
+			return string([]rune(v)[:sampleValueLimit]) + "..."
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// WriteExtractResults writes one JSON file per operation, an index.json listing them
+// and an aggregate extract.csv into dir.
+func WriteExtractResults(results []ExtractResult, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	// Operation names come from the schema; the index and CSV share the directory.
+	namer := schema.NewFileNamer("index", "extract")
+	for i := range results {
+		name := namer.Name(results[i].Operation) + ".json"
+		data, err := json.MarshalIndent(results[i], "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling result for %s: %w", results[i].Operation, err)
+		}
+		if err := artifacts.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return fmt.Errorf("error writing result for %s: %w", results[i].Operation, err)
+		}
+		results[i].File = name
+	}
+
+	type indexEntry struct {
+		Operation  string `json:"operation"`
+		NonEmpty   bool   `json:"nonEmpty"`
+		Records    int    `json:"records"`
+		Errors     int    `json:"errors"`
+		Redactions int    `json:"redactions"`
+		Pages      int    `json:"pages,omitempty"`
+		Secrets    int    `json:"secrets,omitempty"`
+		Access     string `json:"access,omitempty"`
+		File       string `json:"file"`
+	}
+	index := make([]indexEntry, 0, len(results))
+	for _, r := range results {
+		index = append(index, indexEntry{r.Operation, r.NonEmpty, r.Records, len(r.Errors), r.Redactions, r.Pages, len(r.Secrets), r.Access, r.File})
+	}
+	indexData, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling index: %w", err)
+	}
+	if err := artifacts.WriteFile(filepath.Join(dir, "index.json"), indexData, 0644); err != nil {
+		return fmt.Errorf("error writing index: %w", err)
+	}
+
+	f, err := artifacts.Create(filepath.Join(dir, "extract.csv"), 0644)
+	if err != nil {
+		return fmt.Errorf("error creating CSV: %w", err)
+	}
+	defer f.Abort()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"operation", "non_empty", "records", "errors", "pages", "secrets", "access"})
+	for _, r := range results {
+		w.Write([]string{r.Operation, strconv.FormatBool(r.NonEmpty), strconv.Itoa(r.Records), strconv.Itoa(len(r.Errors)), strconv.Itoa(r.Pages), strconv.Itoa(len(r.Secrets)), r.Access})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing CSV: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("error writing CSV: %w", err)
+	}
+	return nil
+}
+
+// PlanRequests returns the requests Extract sends for catalog: one per query
+// with an executable document, plus up to opts.MaxPages-1 more for each
+// paginated query when pagination is followed.
+func PlanRequests(catalog *schema.Catalog, opts ExtractOptions) (min, max int) {
+	for _, op := range catalog.Queries() {
+		if op.Executable == "" {
+			continue
+		}
+		min++
+		max++
+		if opts.FollowPagination && op.Pagination != nil && opts.MaxPages > 1 {
+			max += opts.MaxPages - 1
+		}
+	}
+	return min, max
+}
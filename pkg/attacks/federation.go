@@ -0,0 +1,142 @@
+package attacks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+// ServiceQuery fetches the subgraph SDL exposed by Apollo Federation services.
+const ServiceQuery = `query { _service { sdl } }`
+
+// EntitiesQuery resolves entities from caller-supplied representations.
+const EntitiesQuery = `query ($representations: [_Any!]!) { _entities(representations: $representations) { __typename } }`
+
+// maxEntityProbes bounds the number of _entities requests sent per endpoint.
+const maxEntityProbes = 20
+
+// EntityProbe is the outcome of querying _entities for one @key of an entity.
+type EntityProbe struct {
+	Type           string
+	Key            string
+	Representation map[string]interface{}
+	// Resolved is true when the gateway returned the entity instead of an error.
+	Resolved bool
+	Errors   []string
+	Error    string
+}
+
+// FederationResult describes the federation surface of an endpoint.
+type FederationResult struct {
+	SDL      string
+	Entities []gql.Entity
+	Probes   []EntityProbe
+}
+
+// ProbeFederation fetches the SDL through _service, lists the entities declared
+// with @key and asks _entities to resolve each of them from a placeholder
+// representation. It returns nil when the endpoint does not expose _service.
+// Probes run concurrently and share no state, so endpoints can be probed in parallel.
+func ProbeFederation(ctx context.Context, url string, headers map[string]string) (*FederationResult, error) {
+	resp, err := network.SendGraphQLRequestWithContext(ctx, url, ServiceQuery, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := resp["data"].(map[string]interface{})
+	service, _ := data["_service"].(map[string]interface{})
+	sdl, _ := service["sdl"].(string)
+	if sdl == "" {
+		return nil, nil
+	}
+
+	result := &FederationResult{SDL: sdl}
+	if result.Entities, err = gql.Entities(sdl); err != nil {
+		return result, fmt.Errorf("error parsing federation SDL: %w", err)
+	}
+	logger.Info("Federation SDL lists %d entity type(s) on %s", len(result.Entities), url)
+
+	for _, entity := range result.Entities {
+		for _, key := range entity.Keys {
+			if len(result.Probes) >= maxEntityProbes {
+				break
+			}
+			rep, err := Representation(entity.Type, key)
+			if err != nil {
+				logger.Debug("Skipping @key(fields: %q) of %s: %v", key, entity.Type, err)
+				continue
+			}
+			result.Probes = append(result.Probes, EntityProbe{Type: entity.Type, Key: key, Representation: rep})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range result.Probes {
+		wg.Add(1)
+		go func(p *EntityProbe) {
+			defer wg.Done()
+			probeEntity(ctx, url, headers, p)
+		}(&result.Probes[i])
+	}
+	wg.Wait()
+	return result, nil
+}
+
+// probeEntity sends one _entities request and records whether it resolved.
+func probeEntity(ctx context.Context, url string, headers map[string]string, p *EntityProbe) {
+	logger.Debug("→ Resolving %s through _entities", p.Type)
+	variables := map[string]interface{}{"representations": []interface{}{p.Representation}}
+	resp, err := network.SendGraphQLRequestWithContext(ctx, url, EntitiesQuery, variables, headers)
+	if err != nil {
+		p.Error = err.Error()
+		return
+	}
+	p.Errors = graphQLErrorMessages(resp)
+	data, _ := resp["data"].(map[string]interface{})
+	entities, _ := data["_entities"].([]interface{})
+	for _, e := range entities {
+		if m, ok := e.(map[string]interface{}); ok && m["__typename"] == p.Type {
+			p.Resolved = true
+		}
+	}
+}
+
+// Representation builds an _Any representation of typeName whose key fields, as
+// written in a @key fields argument, hold placeholder values. Nested selections
+// such as "organization { id }" produce nested objects.
+func Representation(typeName, fields string) (map[string]interface{}, error) {
+	doc, err := gql.Parse("{" + fields + "}")
+	if err != nil {
+		return nil, err
+	}
+	rep := representationFields(doc.Operations[0].SelectionSet)
+	rep["__typename"] = typeName
+	return rep, nil
+}
+
+func representationFields(set []gql.Selection) map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, sel := range set {
+		field, ok := sel.(*gql.Field)
+		if !ok {
+			continue
+		}
+		if len(field.SelectionSet) > 0 {
+			out[field.Name] = representationFields(field.SelectionSet)
+			continue
+		}
+		out[field.Name] = schema.PlaceholderValues["ID"]
+	}
+	return out
+}
+
+// RepresentationJSON renders a representation for evidence and logs.
+func RepresentationJSON(rep map[string]interface{}) string {
+	b, _ := json.Marshal(rep)
+	return string(b)
+}
@@ -0,0 +1,216 @@
+package attacks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+)
+
+func supergraphSDL(t *testing.T) string {
+	t.Helper()
+	b, err := os.ReadFile("testdata/federation/supergraph.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// gatewayServer is a mock federation gateway serving sdl through _service. Its
+// _entities resolver returns the entities of the types in resolvable and an
+// authorization error for the others. It records the representations it is
+// sent. An empty sdl makes it a plain GraphQL server without _service.
+type gatewayServer struct {
+	*httptest.Server
+	mu              sync.Mutex
+	representations []map[string]interface{}
+}
+
+func newGatewayServer(t *testing.T, sdl string, resolvable ...string) *gatewayServer {
+	t.Helper()
+	g := &gatewayServer{}
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string `json:"query"`
+			Variables struct {
+				Representations []map[string]interface{} `json:"representations"`
+			} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case sdl == "":
+			w.Write([]byte(`{"errors":[{"message":"Cannot query field \"_service\" on type \"Query\"."}]}`))
+		case strings.Contains(req.Query, "_service"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"_service": map[string]interface{}{"sdl": sdl}}})
+		case strings.Contains(req.Query, "_entities"):
+			g.mu.Lock()
+			g.representations = append(g.representations, req.Variables.Representations...)
+			g.mu.Unlock()
+			var entities []interface{}
+			var errs []interface{}
+			for _, rep := range req.Variables.Representations {
+				typeName, _ := rep["__typename"].(string)
+				if contains(resolvable, typeName) {
+					entities = append(entities, map[string]interface{}{"__typename": typeName})
+					continue
+				}
+				entities = append(entities, nil)
+				errs = append(errs, map[string]interface{}{"message": "Unauthorized: cannot resolve " + typeName})
+			}
+			resp := map[string]interface{}{"data": map[string]interface{}{"_entities": entities}}
+			if errs != nil {
+				resp["errors"] = errs
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			http.Error(w, "unknown query", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(g.Close)
+	return g
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFederationEntities(t *testing.T) {
+	entities, err := gql.Entities(supergraphSDL(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gql.Entity{
+		{Type: "Product", Keys: []string{"upc", "sku variation { id }"}},
+		{Type: "User", Keys: []string{"id", "organization { id } username"}},
+		{Type: "Review", Keys: []string{"id"}},
+		{Type: "Node", Keys: []string{"id"}},
+		{Type: "Warehouse", Keys: []string{"id"}},
+	}
+	if !reflect.DeepEqual(entities, want) {
+		t.Errorf("Entities() = %+v, want %+v", entities, want)
+	}
+}
+
+func TestRepresentation(t *testing.T) {
+	tests := []struct {
+		typeName, fields string
+		want             map[string]interface{}
+	}{
+		{"User", "id", map[string]interface{}{"__typename": "User", "id": "1"}},
+		{"Product", "sku upc", map[string]interface{}{"__typename": "Product", "sku": "1", "upc": "1"}},
+		{"User", "organization { id } username", map[string]interface{}{
+			"__typename":   "User",
+			"organization": map[string]interface{}{"id": "1"},
+			"username":     "1",
+		}},
+	}
+	for _, tt := range tests {
+		got, err := Representation(tt.typeName, tt.fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Representation(%q, %q) = %v, want %v", tt.typeName, tt.fields, got, tt.want)
+		}
+	}
+	if _, err := Representation("User", "id {"); err == nil {
+		t.Error("Representation() accepted malformed key fields")
+	}
+}
+
+func TestProbeFederation(t *testing.T) {
+	sdl := supergraphSDL(t)
+	gw := newGatewayServer(t, sdl, "User", "Review")
+	result, err := ProbeFederation(context.Background(), gw.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || result.SDL != sdl {
+		t.Fatalf("result = %+v, want the gateway's SDL", result)
+	}
+	if len(result.Entities) != 5 {
+		t.Errorf("%d entities, want 5", len(result.Entities))
+	}
+
+	// One probe per @key, each resolved only when the gateway resolves its type.
+	var got []string
+	for _, p := range result.Probes {
+		state := "denied"
+		if p.Resolved {
+			state = "resolved"
+		}
+		got = append(got, p.Type+"("+p.Key+"): "+state)
+		if p.Error != "" {
+			t.Errorf("%s: %s", p.Type, p.Error)
+		}
+		if !p.Resolved && (len(p.Errors) != 1 || !strings.Contains(p.Errors[0], "Unauthorized")) {
+			t.Errorf("%s errors = %q, want the gateway's", p.Type, p.Errors)
+		}
+	}
+	want := []string{
+		"Product(upc): denied",
+		"Product(sku variation { id }): denied",
+		"User(id): resolved",
+		"User(organization { id } username): resolved",
+		"Review(id): resolved",
+		"Node(id): denied",
+		"Warehouse(id): denied",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("probes = %q, want %q", got, want)
+	}
+
+	// The gateway received exactly the representations of the probes.
+	var sent, probed []string
+	for _, rep := range gw.representations {
+		sent = append(sent, RepresentationJSON(rep))
+	}
+	for _, p := range result.Probes {
+		probed = append(probed, RepresentationJSON(p.Representation))
+	}
+	sort.Strings(sent)
+	sort.Strings(probed)
+	if !reflect.DeepEqual(sent, probed) {
+		t.Errorf("gateway received %q, want %q", sent, probed)
+	}
+}
+
+func TestProbeFederationWithoutService(t *testing.T) {
+	gw := newGatewayServer(t, "")
+	result, err := ProbeFederation(context.Background(), gw.URL, nil)
+	if err != nil || result != nil {
+		t.Errorf("ProbeFederation() = %+v, %v; want nil, nil", result, err)
+	}
+	if len(gw.representations) != 0 {
+		t.Errorf("sent %d _entities probe(s) without a federation SDL", len(gw.representations))
+	}
+}
+
+func TestProbeFederationBoundsProbes(t *testing.T) {
+	var sdl strings.Builder
+	for i := 0; i < maxEntityProbes+5; i++ {
+		sdl.WriteString("type T" + strings.Repeat("x", i) + ` @key(fields: "id") { id: ID! }` + "\n")
+	}
+	gw := newGatewayServer(t, sdl.String())
+	result, err := ProbeFederation(context.Background(), gw.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Probes) != maxEntityProbes || len(gw.representations) != maxEntityProbes {
+		t.Errorf("%d probes, %d sent; want %d", len(result.Probes), len(gw.representations), maxEntityProbes)
+	}
+}
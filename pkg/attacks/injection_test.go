@@ -0,0 +1,175 @@
+package attacks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// injectionSchema has a query taking ID, String and Int arguments and a
+// mutation taking a String.
+func injectionSchema() *types.GQLSchema {
+	id := types.TypeRef{Kind: types.SCALAR, Name: "ID"}
+	str := types.TypeRef{Kind: types.SCALAR, Name: "String"}
+	user := types.TypeRef{Kind: types.OBJECT, Name: "User"}
+	query := types.Type{Kind: types.OBJECT, Name: "Query", Fields: []types.Field{
+		{Name: "user", Type: user, Args: []types.InputValue{
+			{Name: "id", Type: types.TypeRef{Kind: types.NON_NULL, OfType: &id}},
+			{Name: "name", Type: str},
+			{Name: "limit", Type: types.TypeRef{Kind: types.SCALAR, Name: "Int"}},
+		}},
+		{Name: "ping", Type: str},
+	}}
+	mutation := types.Type{Kind: types.OBJECT, Name: "Mutation", Fields: []types.Field{
+		{Name: "rename", Type: user, Args: []types.InputValue{{Name: "name", Type: str}}},
+	}}
+	return &types.GQLSchema{Query: &query, Mutation: &mutation, Types: map[string]types.Type{
+		"Query":    query,
+		"Mutation": mutation,
+		"User":     {Kind: types.OBJECT, Name: "User", Fields: []types.Field{{Name: "id", Type: id}}},
+		"ID":       {Kind: types.SCALAR, Name: "ID"},
+		"String":   {Kind: types.SCALAR, Name: "String"},
+		"Int":      {Kind: types.SCALAR, Name: "Int"},
+	}}
+}
+
+func TestInjectionTargets(t *testing.T) {
+	var got []string
+	for _, target := range InjectionTargets(injectionSchema()) {
+		got = append(got, target.Operation+"("+target.Argument+")")
+		if !strings.Contains(target.Document, "$"+schema.ProbeVariable) {
+			t.Errorf("%s(%s) document %q does not take the probe variable", target.Operation, target.Argument, target.Document)
+		}
+	}
+	// Int arguments and mutations are never probed.
+	if want := []string{"user(id)", "user(name)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("InjectionTargets() = %v, want %v", got, want)
+	}
+}
+
+// sleepyServer answers every request after latency, plus delay when
+// delayed(value, n) holds for the probe value of the request, sent for the
+// nth time counting from 1. It returns the probe values received in order.
+func sleepyServer(t *testing.T, latency, delay time.Duration, delayed func(value string, n int) bool) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var values []string
+	sent := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]string `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		value := req.Variables[schema.ProbeVariable]
+		mu.Lock()
+		values = append(values, value)
+		sent[value]++
+		n := sent[value]
+		mu.Unlock()
+		wait := latency
+		if delayed(value, n) {
+			wait += delay
+		}
+		select {
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"user":null}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), values...)
+	}
+}
+
+// TestProbeTimingFlagsDelayedPayloads runs the probes against a backend that
+// sleeps on the MySQL payload every time and on the PostgreSQL one only
+// once, as a slow response by chance would.
+func TestProbeTimingFlagsDelayedPayloads(t *testing.T) {
+	opts := InjectionOptions{Delay: time.Second, Trials: 2}
+	mysql, postgres := TimePayloads[0].Value(1), TimePayloads[2].Value(1)
+	srv, received := sleepyServer(t, 10*time.Millisecond, opts.Delay, func(value string, n int) bool {
+		return value == mysql || (value == postgres && n == 1)
+	})
+	target := InjectionTargets(injectionSchema())[1]
+
+	results, err := ProbeTiming(context.Background(), srv.URL, target, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("ProbeTiming() = %+v, want only the MySQL payload", results)
+	}
+	r := results[0]
+	if r.Payload.Technique != "mysql" || r.Value != mysql || r.Target != target || len(r.Latencies) != opts.Trials {
+		t.Errorf("result = %+v", r)
+	}
+	// The threshold is at least half the delay above the baseline.
+	if r.Baseline >= opts.Delay/2 || r.Threshold < r.Baseline+opts.Delay/2 {
+		t.Errorf("baseline %s, threshold %s", r.Baseline, r.Threshold)
+	}
+	for _, d := range r.Latencies {
+		if d < r.Threshold {
+			t.Errorf("latency %s is under the threshold %s", d, r.Threshold)
+		}
+	}
+
+	// Five benign requests, then each payload once, sent again while delayed.
+	values := received()
+	var want []string
+	for i := 0; i < baselineRequests; i++ {
+		want = append(want, benignValue)
+	}
+	for _, p := range TimePayloads {
+		v := p.Value(1)
+		want = append(want, v)
+		if v == mysql || v == postgres {
+			want = append(want, v)
+		}
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("values sent = %q\nwant %q", values, want)
+	}
+	if min, max := PlanInjection([]InjectionTarget{target}, opts); len(values) < min || len(values) > max {
+		t.Errorf("%d requests sent, outside the plan of %d-%d", len(values), min, max)
+	}
+}
+
+// TestProbeTimingIgnoresSlowBackend checks that a backend slow on every
+// request, payloads or not, is not reported.
+func TestProbeTimingIgnoresSlowBackend(t *testing.T) {
+	srv, received := sleepyServer(t, 40*time.Millisecond, 0, func(string, int) bool { return false })
+	results, err := ProbeTiming(context.Background(), srv.URL, InjectionTargets(injectionSchema())[0], nil, InjectionOptions{Delay: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("ProbeTiming() = %+v, want nothing flagged", results)
+	}
+	if n := len(received()); n != baselineRequests+len(TimePayloads) {
+		t.Errorf("%d requests sent, want each payload sent once", n)
+	}
+}
+
+func TestProbeTimingWithoutBaseline(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	_, err := ProbeTiming(context.Background(), url, InjectionTargets(injectionSchema())[0], nil, InjectionOptions{})
+	if err == nil || !strings.Contains(err.Error(), "error measuring the baseline of user(id)") {
+		t.Errorf("ProbeTiming() = %v, want the baseline error", err)
+	}
+}
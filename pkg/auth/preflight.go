@@ -0,0 +1,293 @@
+// Package auth obtains the session state some GraphQL endpoints require before
+// they accept operations
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+)
+
+// TokenPlaceholder is replaced with the extracted token in the header template.
+const TokenPlaceholder = "{token}"
+
+// DefaultExpiredPattern matches the error bodies commonly returned for a missing or stale CSRF token.
+const DefaultExpiredPattern = `(?i)(csrf|xsrf|token)[^"]{0,40}(expired|invalid|missing|mismatch)`
+
+// preflightBodyLimit caps the preflight response body read.
+const preflightBodyLimit = 1 << 20
+
+// PreflightConfig describes how to obtain and send a session token.
+type PreflightConfig struct {
+	// URL is fetched with GET to obtain the token.
+	URL string
+	// Extract locates the token in the preflight response:
+	//   header:<name>   value of a response header
+	//   cookie:<name>   value of a cookie set by the response
+	//   json:<path>     dotted path into a JSON body, e.g. json:data.csrf or json:tokens[0]
+	//   regex:<re>      first capture group (or whole match) of a regular expression on the body
+	// A value without a prefix is a JSON path when it starts with "$." and a regular expression otherwise.
+	Extract string
+	// Header is the header sent with every request, as "Name: value" with TokenPlaceholder in the value.
+	Header string
+	// Expired is a regular expression matched against response bodies. A match
+	// fetches a new token and sends the request again.
+	Expired string
+	// Headers are sent with the preflight request.
+	Headers map[string]string
+}
+
+// Preflight implements network.Session with a token fetched from a preflight request.
+// Cookies set by the preflight response are sent back with every request.
+type Preflight struct {
+	cfg         PreflightConfig
+	extract     func(resp *http.Response, body []byte) (string, error)
+	headerName  string
+	headerValue string
+	expired     *regexp.Regexp
+
+	mu      sync.RWMutex
+	token   string
+	cookies []*http.Cookie
+	// stale is set once a request carrying the current token is rejected.
+	stale bool
+}
+
+// NewPreflight validates cfg and returns a Preflight without fetching a token yet.
+func NewPreflight(cfg PreflightConfig) (*Preflight, error) {
+	p := &Preflight{cfg: cfg}
+
+	name, value, err := network.ParseHeader(cfg.Header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid preflight token header: %w", err)
+	}
+	if !strings.Contains(value, TokenPlaceholder) {
+		return nil, fmt.Errorf("invalid preflight token header %q: value must contain %s", cfg.Header, TokenPlaceholder)
+	}
+	p.headerName, p.headerValue = name, value
+
+	extract, err := newExtractor(cfg.Extract)
+	if err != nil {
+		return nil, err
+	}
+	p.extract = extract
+
+	if cfg.Expired != "" {
+		if p.expired, err = regexp.Compile(cfg.Expired); err != nil {
+			return nil, fmt.Errorf("invalid token expired pattern: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// Apply sets the token header and the preflight cookies on req.
+func (p *Preflight) Apply(req *http.Request) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.token != "" {
+		req.Header.Set(p.headerName, strings.ReplaceAll(p.headerValue, TokenPlaceholder, p.token))
+	}
+	for _, c := range p.cookies {
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+}
+
+// Expired reports whether body matches the token expired pattern. Only a
+// rejection of the current token marks it stale, so requests rejected with a
+// token that was already replaced are retried without another preflight.
+func (p *Preflight) Expired(req *http.Request, statusCode int, body []byte) bool {
+	if p.expired == nil || !p.expired.Match(body) {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if req.Header.Get(p.headerName) == strings.ReplaceAll(p.headerValue, TokenPlaceholder, p.token) {
+		p.stale = true
+	}
+	return true
+}
+
+// Refresh fetches the preflight URL and stores the extracted token. It does
+// nothing while the current token has not been rejected.
+func (p *Preflight) Refresh(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" && !p.stale {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.URL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating preflight request: %w", err)
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	for key, value := range p.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	for _, c := range p.cookies {
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+
+	logger.Debug("→ GET %s (preflight)", p.cfg.URL)
+	resp, err := network.Client().Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending preflight request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, preflightBodyLimit))
+	if err != nil {
+		return fmt.Errorf("error reading preflight response: %w", err)
+	}
+	body = network.DecodeBody(body, resp.Header.Get("Content-Type"))
+
+	token, err := p.extract(resp, body)
+	if err != nil {
+		return fmt.Errorf("preflight %s (HTTP %d): %w", p.cfg.URL, resp.StatusCode, err)
+	}
+	p.token = token
+	p.cookies = mergeCookies(p.cookies, resp.Cookies())
+	p.stale = false
+	logger.Info("Obtained preflight token from %s", p.cfg.URL)
+	return nil
+}
+
+// mergeCookies replaces the cookies in old with the ones of the same name in fresh.
+func mergeCookies(old, fresh []*http.Cookie) []*http.Cookie {
+	merged := make([]*http.Cookie, 0, len(old)+len(fresh))
+	seen := make(map[string]bool)
+	for _, c := range fresh {
+		seen[c.Name] = true
+		merged = append(merged, c)
+	}
+	for _, c := range old {
+		if !seen[c.Name] {
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
+
+// newExtractor parses an extraction spec as described on PreflightConfig.Extract.
+func newExtractor(spec string) (func(*http.Response, []byte) (string, error), error) {
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok || (kind != "header" && kind != "cookie" && kind != "json" && kind != "regex") {
+		kind, arg = "regex", spec
+		if strings.HasPrefix(spec, "$.") {
+			kind = "json"
+		}
+	}
+	if arg == "" {
+		return nil, fmt.Errorf("invalid preflight token extraction %q", spec)
+	}
+
+	switch kind {
+	case "header":
+		return func(resp *http.Response, _ []byte) (string, error) {
+			if v := resp.Header.Get(arg); v != "" {
+				return v, nil
+			}
+			return "", fmt.Errorf("no %s header in preflight response", arg)
+		}, nil
+	case "cookie":
+		return func(resp *http.Response, _ []byte) (string, error) {
+			for _, c := range resp.Cookies() {
+				if c.Name == arg && c.Value != "" {
+					return c.Value, nil
+				}
+			}
+			return "", fmt.Errorf("no %s cookie in preflight response", arg)
+		}, nil
+	case "json":
+		path := strings.TrimPrefix(strings.TrimPrefix(arg, "$"), ".")
+		return func(_ *http.Response, body []byte) (string, error) {
+			var doc interface{}
+			if err := json.Unmarshal(body, &doc); err != nil {
+				return "", fmt.Errorf("preflight response is not JSON: %w", err)
+			}
+			return lookupJSON(doc, path)
+		}, nil
+	default:
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preflight token regex: %w", err)
+		}
+		return func(_ *http.Response, body []byte) (string, error) {
+			m := re.FindSubmatch(body)
+			switch {
+			case m == nil:
+				return "", fmt.Errorf("token pattern %q did not match the preflight response", arg)
+			case len(m) > 1:
+				return string(m[1]), nil
+			default:
+				return string(m[0]), nil
+			}
+		}, nil
+	}
+}
+
+// lookupJSON follows path in doc with LookupJSON and returns the value it
+// points to as a string.
+func lookupJSON(doc interface{}, path string) (string, error) {
+	current, err := LookupJSON(doc, path)
+	if err != nil {
+		return "", fmt.Errorf("%w in preflight response", err)
+	}
+	switch v := current.(type) {
+	case string:
+		if v == "" {
+			return "", fmt.Errorf("JSON path %q is empty in preflight response", path)
+		}
+		return v, nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("JSON path %q does not hold a scalar value", path)
+	}
+}
+
+// LookupJSON follows a dotted path with optional [n] list indexes, such as
+// data.users[0].id, in a decoded JSON document and returns the value it
+// points to.
+func LookupJSON(doc interface{}, path string) (interface{}, error) {
+	current := doc
+	for _, part := range strings.Split(path, ".") {
+		name, indexes := part, []int(nil)
+		if i := strings.Index(part, "["); i >= 0 {
+			name = part[:i]
+			for _, idx := range strings.Split(strings.TrimSuffix(part[i+1:], "]"), "][") {
+				n, err := strconv.Atoi(idx)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index in JSON path %q", path)
+				}
+				indexes = append(indexes, n)
+			}
+		}
+		if name != "" {
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("JSON path %q not found", path)
+			}
+			if current, ok = obj[name]; !ok {
+				return nil, fmt.Errorf("JSON path %q not found", path)
+			}
+		}
+		for _, n := range indexes {
+			list, ok := current.([]interface{})
+			if !ok || n < 0 || n >= len(list) {
+				return nil, fmt.Errorf("JSON path %q not found", path)
+			}
+			current = list[n]
+		}
+	}
+	return current, nil
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultAWSService is the signing name of AWS AppSync.
+const DefaultAWSService = "appsync"
+
+// sigV4Algorithm names the signature scheme in the Authorization header.
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+// unsignedHeaders are left out of the signature: proxies and the transport may
+// change them after signing.
+var unsignedHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+}
+
+// AWSCredentials are the keys requests are signed with.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials and sent as X-Amz-Security-Token.
+	SessionToken string
+	// Source describes where the credentials were found, for logs.
+	Source string
+}
+
+// LoadAWSCredentials resolves credentials the way the AWS CLI does for static
+// keys: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY (with AWS_SESSION_TOKEN),
+// then the AWS_PROFILE profile, or "default", of the shared credentials file
+// named by AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials.
+func LoadAWSCredentials() (AWSCredentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" || secret != "" {
+		if id == "" || secret == "" {
+			return AWSCredentials{}, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together")
+		}
+		return AWSCredentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN"), Source: "environment"}, nil
+	}
+
+	file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return AWSCredentials{}, fmt.Errorf("no AWS credentials in the environment and no home directory: %w", err)
+		}
+		file = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	values, err := readProfile(file, profile)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	creds := AWSCredentials{
+		AccessKeyID:     values["aws_access_key_id"],
+		SecretAccessKey: values["aws_secret_access_key"],
+		SessionToken:    values["aws_session_token"],
+		Source:          fmt.Sprintf("profile %s of %s", profile, file),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return AWSCredentials{}, fmt.Errorf("profile %s of %s has no aws_access_key_id and aws_secret_access_key", profile, file)
+	}
+	return creds, nil
+}
+
+// readProfile returns the keys of the [profile] section of an INI credentials file.
+func readProfile(file, profile string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no AWS credentials found: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or create %s", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading AWS credentials: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	found := false
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == profile
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading AWS credentials: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("no profile %s in %s", profile, file)
+	}
+	return values, nil
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4. It implements
+// network.RequestSigner.
+type SigV4Signer struct {
+	Credentials AWSCredentials
+	Region      string
+	// Service is the signing name, DefaultAWSService for AppSync.
+	Service string
+	// Now returns the signing time; nil means time.Now.
+	Now func() time.Time
+}
+
+// Sign sets the X-Amz-Date, X-Amz-Security-Token and Authorization headers of
+// req. Every other header present is signed, except the ones proxies rewrite.
+func (s *SigV4Signer) Sign(req *http.Request, body []byte) error {
+	if s.Region == "" {
+		return errors.New("SigV4 signing needs a region")
+	}
+	service := s.Service
+	if service == "" {
+		service = DefaultAWSService
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.Credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.Credentials.SessionToken)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+	payload := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		headers,
+		signedHeaders,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, service, "aws4_request"}, "/")
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hex.EncodeToString(hashed[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.Credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.Credentials.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// canonicalHeaders returns the canonical header block, ending with a blank
+// line, and the signed header list of req.
+func canonicalHeaders(req *http.Request) (string, string) {
+	values := map[string][]string{"host": {signingHost(req)}}
+	for name, v := range req.Header {
+		lower := strings.ToLower(name)
+		if unsignedHeaders[lower] || lower == "host" {
+			continue
+		}
+		values[lower] = append(values[lower], v...)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		trimmed := make([]string, len(values[name]))
+		for i, v := range values[name] {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		b.WriteString(name + ":" + strings.Join(trimmed, ",") + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// signingHost returns the Host header sent for req, without a default port.
+func signingHost(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if (port == "443" && req.URL.Scheme == "https") || (port == "80" && req.URL.Scheme == "http") {
+			return h
+		}
+	}
+	return host
+}
+
+// canonicalURI encodes the already escaped path of u once more, as SigV4
+// requires for every service but S3.
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return sigV4Escape(path, false)
+}
+
+// canonicalQuery returns the query parameters of u sorted by name, then value.
+func canonicalQuery(u *url.URL) string {
+	type pair struct{ name, value string }
+	var pairs []pair
+	for name, values := range u.Query() {
+		for _, v := range values {
+			pairs = append(pairs, pair{sigV4Escape(name, true), sigV4Escape(v, true)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].name != pairs[j].name {
+			return pairs[i].name < pairs[j].name
+		}
+		return pairs[i].value < pairs[j].value
+	})
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p.name + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// sigV4Escape percent-encodes every byte but the RFC 3986 unreserved characters,
+// and slashes unless encodeSlash is set.
+func sigV4Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// exampleSigner signs as the AWS Signature Version 4 test suite does.
+func exampleSigner() *SigV4Signer {
+	return &SigV4Signer{
+		Credentials: AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		Region:      "us-east-1",
+		Service:     "service",
+		Now:         func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+}
+
+// TestSigV4Vectors checks the signatures of requests of the AWS Signature
+// Version 4 test suite.
+func TestSigV4Vectors(t *testing.T) {
+	tests := []struct {
+		name          string
+		method, url   string
+		contentType   string
+		body          string
+		signedHeaders string
+		signature     string
+	}{
+		{"get-vanilla", "GET", "https://example.amazonaws.com/", "", "", "host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "", "host;x-amz-date", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"get-vanilla-empty-query-key", "GET", "https://example.amazonaws.com/?Param1=value1", "", "", "host;x-amz-date", "a67d582fa61cc504c4bae71f336f98b97f1ea3c7a6bfe1b6e45aec72011b9aeb"},
+		{"post-vanilla", "POST", "https://example.amazonaws.com/", "", "", "host;x-amz-date", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"post-x-www-form-urlencoded", "POST", "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1", "content-type;host;x-amz-date", "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			var body []byte
+			if tt.body != "" {
+				body = []byte(tt.body)
+			}
+			if err := exampleSigner().Sign(req, body); err != nil {
+				t.Fatal(err)
+			}
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=" + tt.signedHeaders + ", Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %s\nwant %s", got, want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %s", got)
+			}
+		})
+	}
+}
+
+func TestSigV4CanonicalRequest(t *testing.T) {
+	req, err := http.NewRequest("POST", "https://api.example.com:443/graphql?b=2&a=x%20y&a=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Custom", "  several   spaces  ")
+	req.Header.Set("User-Agent", "graphspecter")
+	req.Header.Add("X-Multi", "one")
+	req.Header.Add("X-Multi", "two")
+
+	headers, signed := canonicalHeaders(req)
+	if want := "host:api.example.com\nx-custom:several spaces\nx-multi:one,two\n"; headers != want {
+		t.Errorf("canonical headers = %q, want %q", headers, want)
+	}
+	if signed != "host;x-custom;x-multi" {
+		t.Errorf("signed headers = %s", signed)
+	}
+	if got, want := canonicalQuery(req.URL), "a=1&a=x%20y&b=2"; got != want {
+		t.Errorf("canonical query = %s, want %s", got, want)
+	}
+	if got := canonicalURI(req.URL); got != "/graphql" {
+		t.Errorf("canonical URI = %s", got)
+	}
+	if got := sigV4Escape("a b/c~d+e*", true); got != "a%20b%2Fc~d%2Be%2A" {
+		t.Errorf("sigV4Escape = %s", got)
+	}
+}
+
+func TestSigV4SessionToken(t *testing.T) {
+	s := exampleSigner()
+	s.Credentials.SessionToken = "token"
+	s.Service = ""
+	req, err := http.NewRequest("POST", "https://abc.appsync-api.us-east-1.amazonaws.com/graphql", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer stale")
+	if err := s.Sign(req, []byte(`{"query":"{ __typename }"}`)); err != nil {
+		t.Fatal(err)
+	}
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/appsync/aws4_request, SignedHeaders=host;x-amz-date;x-amz-security-token, Signature=") {
+		t.Errorf("Authorization = %s", auth)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "token" {
+		t.Error("session token not sent")
+	}
+
+	s.Region = ""
+	if err := s.Sign(req, nil); err == nil {
+		t.Error("signing without a region succeeded")
+	}
+}
+
+func TestLoadAWSCredentials(t *testing.T) {
+	for _, k := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_SHARED_CREDENTIALS_FILE"} {
+		t.Setenv(k, "")
+	}
+	file := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(file, []byte("[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = secret1\n\n# comment\n[audit]\nAWS_ACCESS_KEY_ID=AKIDAUDIT\naws_secret_access_key=secret2\naws_session_token=tok\n[empty]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", file)
+
+	creds, err := LoadAWSCredentials()
+	if err != nil || creds.AccessKeyID != "AKIDDEFAULT" || creds.SecretAccessKey != "secret1" {
+		t.Errorf("default profile: %+v, %v", creds, err)
+	}
+	t.Setenv("AWS_PROFILE", "audit")
+	if creds, err := LoadAWSCredentials(); err != nil || creds.AccessKeyID != "AKIDAUDIT" || creds.SessionToken != "tok" {
+		t.Errorf("audit profile: %+v, %v", creds, err)
+	}
+	for _, profile := range []string{"empty", "missing"} {
+		t.Setenv("AWS_PROFILE", profile)
+		if _, err := LoadAWSCredentials(); err == nil {
+			t.Errorf("profile %s gave credentials", profile)
+		}
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+	if _, err := LoadAWSCredentials(); err == nil || !strings.Contains(err.Error(), "must be set together") {
+		t.Errorf("half-set environment: %v", err)
+	}
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret3")
+	if creds, err := LoadAWSCredentials(); err != nil || creds.AccessKeyID != "AKIDENV" || creds.Source != "environment" {
+		t.Errorf("environment: %+v, %v", creds, err)
+	}
+}
+
+// TestSigV4Preview checks that a preview shows the signature and the virtual
+// host of a request as the server receives them.
+func TestSigV4Preview(t *testing.T) {
+	var body, host string
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, host, headers = string(b), r.Host, r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer srv.Close()
+
+	s := exampleSigner()
+	s.Credentials.SessionToken = "session"
+	network.SetSigner(s)
+	defer network.SetSigner(nil)
+
+	ctx := network.WithVirtualHost(context.Background(), "abc.appsync-api.us-east-1.amazonaws.com")
+	query := `{ __typename }`
+	p, err := network.PreviewGraphQLRequest(ctx, srv.URL, query, map[string]interface{}{"a": 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := network.SendGraphQLRequestWithContext(ctx, srv.URL, query, map[string]interface{}{"a": 1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if p.Body != body {
+		t.Errorf("previewed body %q, server received %q", p.Body, body)
+	}
+	if p.Headers["Host"] != host {
+		t.Errorf("Host previewed %q, server received %q", p.Headers["Host"], host)
+	}
+	for _, name := range []string{"Authorization", "X-Amz-Date", "X-Amz-Security-Token"} {
+		if got, want := p.Headers[name], headers.Get(name); got == "" || got != want {
+			t.Errorf("%s previewed %q, server received %q", name, got, want)
+		}
+	}
+	if !strings.Contains(p.Headers["Authorization"], "SignedHeaders=") || !strings.Contains(p.Headers["Authorization"], "host") {
+		t.Errorf("Authorization %q does not sign the host", p.Headers["Authorization"])
+	}
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// DefaultWhoami is the query Verify sends when no whoami query is given.
+const DefaultWhoami = "{ __typename }"
+
+// authErrorPattern matches the GraphQL error messages and codes of requests
+// refused for missing, invalid or expired credentials.
+var authErrorPattern = regexp.MustCompile(`(?i)unauthori[sz]ed|unauthenticated|not authenticated|authentication (?:is )?(?:required|failed)|not logged in|login required|(?:invalid|expired|malformed|bad) (?:access |auth |bearer |api )?(?:token|credentials|api key|jwt)|(?:token|jwt|credentials) (?:is |has )?(?:invalid|expired)|forbidden|access denied`)
+
+// ValidateWhoami checks that whoami parses and holds only query operations,
+// since it is sent with and without credentials to every target.
+func ValidateWhoami(whoami string) error {
+	doc, err := gql.Parse(whoami)
+	if err != nil {
+		return fmt.Errorf("error parsing whoami query: %w", err)
+	}
+	if len(doc.Operations) == 0 {
+		return fmt.Errorf("whoami query holds no operation")
+	}
+	for _, op := range doc.Operations {
+		if op.Kind != gql.OperationQuery {
+			return fmt.Errorf("whoami query must be a query, not a %s", op.Kind)
+		}
+	}
+	return nil
+}
+
+// Verify checks that headers authenticate to url by sending the whoami
+// document, DefaultWhoami when empty. The credentials are rejected when the
+// endpoint answers 401 or 403, with an authentication error or without data.
+// Otherwise the document is sent again with only the Content-Type header: when
+// it still returns data the outcome is types.AuthUnproven, as it is for
+// DefaultWhoami on endpoints that answer anonymous queries. A preflight session
+// or request signer applies to both requests.
+func Verify(ctx context.Context, url string, headers map[string]string, whoami string) types.AuthVerification {
+	if whoami == "" {
+		whoami = DefaultWhoami
+	}
+	v := types.AuthVerification{Endpoint: url}
+	rejected, status, err := sendWhoami(ctx, url, headers, whoami)
+	v.StatusCode = status
+	switch {
+	case rejected != "":
+		v.Status = types.AuthRejected
+		v.Reason = rejected
+	case err != nil:
+		v.Status = types.AuthFailed
+		v.Reason = err.Error()
+	default:
+		v.Status = types.AuthVerified
+		anonymous := make(map[string]string)
+		for k, value := range headers {
+			if strings.EqualFold(k, "Content-Type") {
+				anonymous[k] = value
+			}
+		}
+		if rejected, _, err := sendWhoami(ctx, url, anonymous, whoami); rejected == "" && err == nil {
+			v.Status = types.AuthUnproven
+			v.Reason = "the whoami query also returns data without credentials"
+		}
+	}
+	return v
+}
+
+// sendWhoami sends whoami to url with headers and returns why the endpoint
+// refused it, if it did, along with the HTTP status of the response.
+func sendWhoami(ctx context.Context, url string, headers map[string]string, whoami string) (string, int, error) {
+	var info network.ResponseInfo
+	resp, err := network.SendGraphQLRequestWithContext(network.WithResponseInfo(ctx, &info), url, whoami, nil, headers)
+	if info.StatusCode == http.StatusUnauthorized || info.StatusCode == http.StatusForbidden {
+		return fmt.Sprintf("HTTP %d", info.StatusCode), info.StatusCode, nil
+	}
+	if err != nil {
+		return "", info.StatusCode, err
+	}
+	if msg := authError(resp); msg != "" {
+		return msg, info.StatusCode, nil
+	}
+	if !hasData(resp) {
+		return "the whoami query returned no data", info.StatusCode, nil
+	}
+	return "", info.StatusCode, nil
+}
+
+// authError returns the first error of resp whose message or extensions.code
+// reads as an authentication failure.
+func authError(resp map[string]interface{}) string {
+	errs, _ := resp["errors"].([]interface{})
+	for _, e := range errs {
+		obj, _ := e.(map[string]interface{})
+		msg, _ := obj["message"].(string)
+		code := ""
+		if ext, ok := obj["extensions"].(map[string]interface{}); ok {
+			code, _ = ext["code"].(string)
+		}
+		if authErrorPattern.MatchString(msg) || authErrorPattern.MatchString(code) {
+			if msg == "" {
+				return code
+			}
+			return msg
+		}
+	}
+	return ""
+}
+
+// hasData reports whether resp holds a root field with a value other than null.
+func hasData(resp map[string]interface{}) bool {
+	data, _ := resp["data"].(map[string]interface{})
+	for _, v := range data {
+		if v != nil {
+			return true
+		}
+	}
+	return false
+}
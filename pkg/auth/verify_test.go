@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// whoamiServer answers { me { id } } for the bearer token "valid", with 401 for
+// "expired", and { __typename } to anyone.
+func whoamiServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); {
+		case token == "expired":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errors":[{"message":"jwt expired"}]}`))
+		case token == "revoked":
+			w.Write([]byte(`{"errors":[{"message":"Invalid token","extensions":{"code":"UNAUTHENTICATED"}}],"data":null}`))
+		case strings.Contains(r.URL.RawQuery, "anonymous"), token == "valid":
+			w.Write([]byte(`{"data":{"__typename":"Query","me":{"id":"7"}}}`))
+		default:
+			w.Write([]byte(`{"errors":[{"message":"You must be logged in"}],"data":{"me":null}}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVerify(t *testing.T) {
+	srv := whoamiServer(t)
+	tests := []struct {
+		name       string
+		url        string
+		token      string
+		whoami     string
+		status     string
+		statusCode int
+		reason     string
+	}{
+		{name: "valid token", url: srv.URL, token: "valid", whoami: "{ me { id } }", status: types.AuthVerified, statusCode: 200},
+		{name: "expired token", url: srv.URL, token: "expired", status: types.AuthRejected, statusCode: 401, reason: "HTTP 401"},
+		{name: "authentication error", url: srv.URL, token: "revoked", whoami: "{ me { id } }", status: types.AuthRejected, statusCode: 200, reason: "Invalid token"},
+		{name: "no data", url: srv.URL, token: "unknown", whoami: "{ me { id } }", status: types.AuthRejected, statusCode: 200, reason: "the whoami query returned no data"},
+		// The default { __typename } answers anonymously too on this endpoint.
+		{name: "anonymous typename", url: srv.URL + "?anonymous", token: "valid", status: types.AuthUnproven, statusCode: 200, reason: "the whoami query also returns data without credentials"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"Content-Type": "application/json", "Authorization": "Bearer " + tt.token}
+			v := Verify(context.Background(), tt.url, headers, tt.whoami)
+			if v.Endpoint != tt.url || v.Status != tt.status || v.StatusCode != tt.statusCode || v.Reason != tt.reason {
+				t.Errorf("Verify = %+v, want %s (%d) %q", v, tt.status, tt.statusCode, tt.reason)
+			}
+		})
+	}
+}
+
+func TestVerifyUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	if v := Verify(context.Background(), url, map[string]string{"Authorization": "Bearer valid"}, ""); v.Status != types.AuthFailed || v.Reason == "" {
+		t.Errorf("Verify = %+v, want failed with the error", v)
+	}
+}
+
+func TestValidateWhoami(t *testing.T) {
+	for whoami, valid := range map[string]bool{
+		"{ __typename }":                 true,
+		"query Me { me { id } }":         true,
+		"mutation { logout }":            false,
+		"subscription { events { id } }": false,
+		"fragment F on Query { a }":      false,
+	} {
+		if err := ValidateWhoami(whoami); (err == nil) != valid {
+			t.Errorf("ValidateWhoami(%q) = %v", whoami, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+func init() {
+	Register(queryPolicyCheck{})
+}
+
+// Query postures stored in Deps.QueryPosture
+const (
+	// PostureOpen means arbitrary queries are executed.
+	PostureOpen = "open"
+	// PostureAllowlist means only persisted or allow-listed operations are executed.
+	PostureAllowlist = data.PostureAllowlist
+	// PostureAuth means queries are rejected until the client authenticates.
+	PostureAuth = data.PostureAuth
+	// PostureRejected means the probe was rejected for a reason no rule recognised.
+	PostureRejected = "rejected"
+)
+
+// policyProbe is a trivially benign query no allow-list would contain.
+const policyProbe = `query GraphSpecterPolicyProbe { __typename }`
+
+// queryPolicyCheck finds out whether the endpoint executes arbitrary queries or
+// only persisted operations, and stores the posture for the checks that follow.
+type queryPolicyCheck struct{}
+
+func (queryPolicyCheck) ID() string { return "query-policy" }
+
+func (queryPolicyCheck) Description() string {
+	return "Sends a benign arbitrary query to find out whether only persisted or allow-listed operations are executed"
+}
+
+func (queryPolicyCheck) Severity() string { return report.SeverityInfo }
+
+func (queryPolicyCheck) Safety() string { return SafetyPassive }
+
+// Provides the posture that decides whether the checks requiring arbitrary
+// queries run.
+func (queryPolicyCheck) Provides() Requirement { return RequiresArbitraryQueries }
+
+func (queryPolicyCheck) Plan(target string, deps *Deps) Plan {
+	return Plan{Requests: 1, MaxRequests: 1}
+}
+
+func (c queryPolicyCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	logger.Info("Checking whether %s executes arbitrary queries...", target)
+	resp, err := network.SendGraphQLRequestWithContext(ctx, target, policyProbe, nil, deps.Headers)
+	if err != nil {
+		return nil, err
+	}
+	posture, rule, message := classifyQueryPolicy(resp)
+	deps.QueryPosture = posture
+
+	finding := report.Finding{
+		Severity: c.Severity(),
+		Endpoint: target,
+		Evidence: message,
+		Request:  report.NewGraphQLRequest(target, policyProbe, nil, deps.Headers),
+	}
+	switch posture {
+	case PostureOpen:
+		logger.Info("%s executes arbitrary queries", target)
+		finding.ID = "arbitrary-queries-accepted"
+		finding.Title = "Arbitrary queries are executed"
+		finding.Description = "The endpoint executed a query it cannot have known in advance; no persisted operation allow-list is enforced."
+		finding.Evidence = "data.__typename returned"
+	case PostureAllowlist:
+		logger.Info("%s only executes persisted operations (%s); checks sending their own queries will be skipped", target, rule)
+		finding.ID = "operation-allowlist-enforced"
+		finding.Title = "Only persisted or allow-listed operations are executed"
+		finding.Description = fmt.Sprintf("The endpoint rejected an arbitrary query with the %s allow-list phrasing. Checks that generate their own queries cannot reach it without the persisted operation ids.", rule)
+	case PostureAuth:
+		logger.Info("%s rejects unauthenticated queries (%s)", target, rule)
+		finding.ID = "arbitrary-queries-require-auth"
+		finding.Title = "Queries are rejected without authentication"
+		finding.Description = "The endpoint rejected an arbitrary query for lack of authentication. Supply credentials with -H or AUTH_TOKEN to audit it further."
+	default:
+		logger.Info("%s rejected the probe query for an unrecognised reason: %s", target, message)
+		return nil, nil
+	}
+	return []report.Finding{finding}, nil
+}
+
+// classifyQueryPolicy returns the posture shown by the response to policyProbe,
+// the vendor of the query policy rule that matched and the first error message.
+// Allow-list rules take precedence over authentication ones, since allow-lists
+// are usually enforced before authentication. Errors no rule matches still show
+// the auth posture when they fall in the auth class of the error-patterns
+// dataset, whatever their language.
+func classifyQueryPolicy(resp map[string]interface{}) (posture, vendor, message string) {
+	if d, ok := resp["data"].(map[string]interface{}); ok {
+		if _, ok := d["__typename"]; ok {
+			return PostureOpen, "", ""
+		}
+	}
+	messages, codes := responseErrors(resp)
+	if len(messages) > 0 {
+		message = messages[0]
+	}
+	for _, p := range []string{PostureAllowlist, PostureAuth} {
+		for _, rule := range data.QueryPolicies() {
+			if rule.Posture == p && policyMatches(rule, messages, codes) {
+				return p, rule.Vendor, message
+			}
+		}
+	}
+	if gql.HasErrorClass(resp, data.ErrorAuth) {
+		return PostureAuth, "error-patterns auth class", message
+	}
+	return PostureRejected, "", message
+}
+
+// policyMatches reports whether an error message or code matches rule.
+func policyMatches(rule data.QueryPolicy, messages, codes []string) bool {
+	for _, v := range rule.Values {
+		switch rule.Match {
+		case data.MatchMessage:
+			for _, m := range messages {
+				if strings.Contains(strings.ToLower(m), strings.ToLower(v)) {
+					return true
+				}
+			}
+		case data.MatchCode:
+			for _, c := range codes {
+				if c == v {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// responseErrors returns the messages and extensions.code values of the errors
+// in a GraphQL response.
+func responseErrors(resp map[string]interface{}) (messages, codes []string) {
+	errs, _ := resp["errors"].([]interface{})
+	for _, e := range errs {
+		entry, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if m, ok := entry["message"].(string); ok {
+			messages = append(messages, m)
+		}
+		if ext, ok := entry["extensions"].(map[string]interface{}); ok {
+			if code, ok := ext["code"].(string); ok {
+				codes = append(codes, code)
+			}
+		}
+	}
+	return messages, codes
+}
@@ -0,0 +1,161 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// rejection is a response rejecting a query with message and, when not empty,
+// extensions.code.
+func rejection(message, code string) map[string]interface{} {
+	e := map[string]interface{}{"message": message}
+	if code != "" {
+		e["extensions"] = map[string]interface{}{"code": code}
+	}
+	return map[string]interface{}{"errors": []interface{}{e}}
+}
+
+func TestClassifyQueryPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    map[string]interface{}
+		posture string
+		vendor  string
+	}{
+		{"executed", map[string]interface{}{"data": map[string]interface{}{"__typename": "Query"}}, PostureOpen, ""},
+		{"Apollo Router", rejection("Persisted query '3f1a' not found in the persisted query list", "PERSISTED_QUERY_NOT_IN_LIST"), PostureAllowlist, "Apollo Router"},
+		{"Apollo Router id required", rejection("Persisted query id is required", ""), PostureAllowlist, "Apollo Router"},
+		{"Apollo Server", rejection("PersistedQueryNotFound", "PERSISTED_QUERY_NOT_FOUND"), PostureAllowlist, "Apollo Server"},
+		{"GraphQL Yoga", rejection("Only persisted operations are allowed.", ""), PostureAllowlist, "GraphQL Yoga"},
+		{"Hot Chocolate code", rejection("The query request contains no document.", "HC0020"), PostureAllowlist, "Hot Chocolate"},
+		{"Hot Chocolate message", rejection("Only persisted queries are allowed.", ""), PostureAllowlist, "Hot Chocolate"},
+		{"Hasura", rejection("query is not in any of the allowlists", "validation-failed"), PostureAllowlist, "Hasura"},
+		{"generic", rejection("Operation GraphSpecterPolicyProbe is NOT WHITELISTED", ""), PostureAllowlist, "generic"},
+		{"Hasura auth", rejection("Could not verify JWT: JWTExpired", "invalid-jwt"), PostureAuth, "Hasura"},
+		{"generic auth code", rejection("Context creation failed", "UNAUTHENTICATED"), PostureAuth, "generic"},
+		{"generic auth message", rejection("Authentication required to access this API", ""), PostureAuth, "generic"},
+		// Allow-lists are enforced before authentication, so they win when both show.
+		{"allow-list and auth", map[string]interface{}{"errors": []interface{}{
+			map[string]interface{}{"message": "Unauthorized", "extensions": map[string]interface{}{"code": "UNAUTHENTICATED"}},
+			map[string]interface{}{"message": "PersistedQueryNotFound"},
+		}}, PostureAllowlist, "Apollo Server"},
+		{"localized auth", rejection("Zugriff verweigert: bitte anmelden", ""), PostureAuth, "error-patterns auth class"},
+		{"unrecognised", rejection(`Cannot query field "__typenam" on type "Query".`, "GRAPHQL_VALIDATION_FAILED"), PostureRejected, ""},
+		{"empty", map[string]interface{}{}, PostureRejected, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posture, vendor, message := classifyQueryPolicy(tt.resp)
+			if posture != tt.posture || vendor != tt.vendor {
+				t.Errorf("classifyQueryPolicy() = %s (%s), want %s (%s)", posture, vendor, tt.posture, tt.vendor)
+			}
+			if errs, _ := tt.resp["errors"].([]interface{}); len(errs) > 0 && message != errs[0].(map[string]interface{})["message"] {
+				t.Errorf("message = %q, want the first error message", message)
+			}
+		})
+	}
+}
+
+// TestClassifyQueryPolicyDataset checks that every value of the dataset is
+// recognised, matching messages case-insensitively and codes exactly.
+func TestClassifyQueryPolicyDataset(t *testing.T) {
+	for _, rule := range data.QueryPolicies() {
+		for _, v := range rule.Values {
+			var resp map[string]interface{}
+			if rule.Match == data.MatchCode {
+				resp = rejection("rejected", v)
+			} else {
+				resp = rejection("Error: "+v+".", "")
+			}
+			if posture, _, _ := classifyQueryPolicy(resp); posture != rule.Posture {
+				t.Errorf("%s %s %q classified %s, want %s", rule.Vendor, rule.Match, v, posture, rule.Posture)
+			}
+		}
+	}
+}
+
+// policyServer rejects every query with the response reject and counts the
+// requests it receives.
+func policyServer(t *testing.T, reject map[string]interface{}) (*httptest.Server, *int64) {
+	t.Helper()
+	count := new(int64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(count, 1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reject)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, count
+}
+
+func TestQueryPolicySkipsBlockedChecks(t *testing.T) {
+	srv, count := policyServer(t, rejection("PersistedQueryNotFound", "PERSISTED_QUERY_NOT_FOUND"))
+	selected, err := Select("batching,csrf,query-policy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected[0].ID() != "query-policy" {
+		t.Fatalf("selected %v, want query-policy first", IDs(selected))
+	}
+	ctl, ctx := NewController(context.Background(), Policy{})
+	defer ctl.Close()
+	deps := &Deps{}
+	findings, results := Run(ctx, ctl, selected, srv.URL, deps)
+
+	if deps.QueryPosture != PostureAllowlist || !deps.ArbitraryQueriesBlocked() {
+		t.Errorf("posture = %q, want the allow-list", deps.QueryPosture)
+	}
+	if len(findings) != 1 || findings[0].ID != "operation-allowlist-enforced" || findings[0].Evidence != "PersistedQueryNotFound" {
+		t.Errorf("findings = %+v", findings)
+	}
+	for _, r := range results[1:] {
+		if r.Status != report.StatusSkipped || r.Reason != SkipAllowlist {
+			t.Errorf("%s: %s (%s), want it skipped for the allow-list", r.Check, r.Status, r.Reason)
+		}
+	}
+	if n := atomic.LoadInt64(count); n != 1 {
+		t.Errorf("the server received %d requests, want only the probe", n)
+	}
+}
+
+func TestQueryPolicyFindings(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    map[string]interface{}
+		posture string
+		finding string
+	}{
+		{"open", map[string]interface{}{"data": map[string]interface{}{"__typename": "Query"}}, PostureOpen, "arbitrary-queries-accepted"},
+		{"auth", rejection("Not authenticated", "UNAUTHENTICATED"), PostureAuth, "arbitrary-queries-require-auth"},
+		{"rejected", rejection("Internal server error", ""), PostureRejected, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := policyServer(t, tt.resp)
+			deps := &Deps{}
+			findings, err := queryPolicyCheck{}.Run(context.Background(), srv.URL, deps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deps.QueryPosture != tt.posture || deps.ArbitraryQueriesBlocked() {
+				t.Errorf("posture = %q, want %q without blocking checks", deps.QueryPosture, tt.posture)
+			}
+			if tt.finding == "" {
+				if len(findings) != 0 {
+					t.Errorf("findings = %+v, want none", findings)
+				}
+				return
+			}
+			if len(findings) != 1 || findings[0].ID != tt.finding || findings[0].Request == nil {
+				t.Errorf("findings = %+v, want %s with the probe request", findings, tt.finding)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+func init() {
+	Register(batchingCheck{})
+}
+
+// batchProbes are the operations of the batch sent by the batching check. Each
+// carries a distinct alias so the response shows which were executed.
+var batchProbes = []string{`{ gsBatch1: __typename }`, `{ gsBatch2: __typename }`}
+
+// batchingCheck tells whether the endpoint executes a JSON array of
+// operations sent in one request. Rate limits and lockouts counting HTTP
+// requests then let every request carry as many attempts as it holds, as in
+// batched login or one-time code brute forcing.
+type batchingCheck struct{}
+
+func (batchingCheck) ID() string { return "batching" }
+
+func (batchingCheck) Description() string {
+	return "Checks whether several operations sent as a JSON array in one request are executed"
+}
+
+func (batchingCheck) Severity() string { return report.SeverityLow }
+
+func (batchingCheck) Safety() string { return SafetyPassive }
+
+func (batchingCheck) Requires() Requirement { return RequiresNetwork | RequiresArbitraryQueries }
+
+func (batchingCheck) Plan(target string, deps *Deps) Plan {
+	return Plan{Requests: 1, MaxRequests: 1}
+}
+
+func (c batchingCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	logger.Info("Checking if %s executes batched operations...", target)
+	batch := make([]types.GraphQLRequest, len(batchProbes))
+	for i, q := range batchProbes {
+		batch[i] = types.GraphQLRequest{Query: q}
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := network.ProbeRequest(ctx, http.MethodPost, target, "application/json", body, deps.Headers, batchProbes...)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]interface{}
+	if err := json.Unmarshal(resp.Body, &results); err != nil {
+		logger.Debug("→ %s did not answer the batch with an array (HTTP %d)", target, resp.Status)
+		return nil, nil
+	}
+	executed := 0
+	for i, result := range results {
+		if i < len(batchProbes) && hasMarker(result, fmt.Sprintf("gsBatch%d", i+1)) {
+			executed++
+		}
+	}
+	if executed < len(batchProbes) {
+		logger.Debug("→ %s answered the batch with %d results, %d executed", target, len(results), executed)
+		return nil, nil
+	}
+
+	logger.Warn("WARNING: %s executes batched operations", target)
+	return []report.Finding{{
+		ID:          "batching-allowed",
+		Title:       "Operations batched in a JSON array are executed",
+		Severity:    c.Severity(),
+		Endpoint:    target,
+		Description: fmt.Sprintf("The endpoint executed the %d operations of a JSON array sent in one request. Rate limits, lockouts and monitoring that count HTTP requests then let a single request carry many attempts, such as password or one-time code guesses, and large batches multiply the cost of one request.", len(batchProbes)),
+		Evidence:    fmt.Sprintf("HTTP %d with %d results, each carrying the alias of its operation", resp.Status, len(results)),
+		Request:     batchRequest(target, body, deps.Headers),
+	}}, nil
+}
+
+// batchRequest is the evidence of the batch body sent to target.
+func batchRequest(target string, body []byte, headers map[string]string) *report.RequestEvidence {
+	req := report.NewGraphQLRequest(target, "", nil, headers)
+	req.Body = string(body)
+	return req
+}
@@ -0,0 +1,78 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// graphQLServer answers GraphQL requests like a server executing __typename
+// aliases: an object body gets one result and, with batch, an array body an
+// array of results. With get, queries in the URL of a GET are executed too.
+func graphQLServer(t *testing.T, batch, get bool) *httptest.Server {
+	t.Helper()
+	execute := func(query string) map[string]interface{} {
+		alias, _, _ := strings.Cut(strings.TrimSpace(strings.Trim(query, "{} ")), ":")
+		return map[string]interface{}{"data": map[string]interface{}{alias: "Query"}}
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			if !get {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				w.Write([]byte(`{"errors":[{"message":"GET is not allowed"}]}`))
+				return
+			}
+			json.NewEncoder(w).Encode(execute(r.URL.Query().Get("query")))
+			return
+		}
+		var body json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		var batched []struct{ Query string }
+		if err := json.Unmarshal(body, &batched); err == nil {
+			if !batch {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":[{"message":"batching is disabled"}]}`))
+				return
+			}
+			var results []map[string]interface{}
+			for _, op := range batched {
+				results = append(results, execute(op.Query))
+			}
+			json.NewEncoder(w).Encode(results)
+			return
+		}
+		var single struct{ Query string }
+		json.Unmarshal(body, &single)
+		json.NewEncoder(w).Encode(execute(single.Query))
+	}))
+}
+
+func TestBatchingCheck(t *testing.T) {
+	for _, allowed := range []bool{true, false} {
+		srv := graphQLServer(t, allowed, false)
+		findings, err := batchingCheck{}.Run(context.Background(), srv.URL, &Deps{Headers: map[string]string{"Content-Type": "application/json"}})
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !allowed {
+			if len(findings) != 0 {
+				t.Errorf("batching refused: findings = %+v", findings)
+			}
+			continue
+		}
+		if len(findings) != 1 || findings[0].ID != "batching-allowed" {
+			t.Fatalf("batching allowed: findings = %+v", findings)
+		}
+		// The reproduction sends the batch itself.
+		if curl := report.CurlFor(*findings[0].Request); !strings.Contains(curl, `[{"query":"{ gsBatch1: __typename }"},{"query":"{ gsBatch2: __typename }"}]`) {
+			t.Errorf("reproduction = %s", curl)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// DefaultBudget is the time a check may run on one target unless it declares
+// another budget or --check-timeout overrides it.
+const DefaultBudget = 2 * time.Minute
+
+// abandonGrace is how long a check that ran out of budget has to notice the
+// cancellation and return before the runner moves on without it.
+var abandonGrace = 5 * time.Second
+
+// Budgeted is implemented by checks that need more or less than DefaultBudget.
+type Budgeted interface {
+	Budget() time.Duration
+}
+
+// ParseTimeouts parses a --check-timeout value such as "dos=2m,engine=20s" into
+// budgets keyed by check id or group. A zero duration removes the budget.
+func ParseTimeouts(spec string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range ParseList(spec) {
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid check timeout %q (expected check=duration)", entry)
+		}
+		if _, known := registry[name]; !known && !isGroup(name) {
+			return nil, fmt.Errorf("invalid check timeout %q: no check or group named %s", entry, name)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid check timeout %q: bad duration %q", entry, value)
+		}
+		timeouts[name] = d
+	}
+	return timeouts, nil
+}
+
+// isGroup reports whether name is the group of a registered check.
+func isGroup(name string) bool {
+	for _, c := range registry {
+		if g, ok := c.(Grouped); ok && g.Group() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// BudgetOf returns the time c may run on one target: the override for its id,
+// then the one for its group, then its declared budget or DefaultBudget.
+func BudgetOf(c Check, overrides map[string]time.Duration) time.Duration {
+	if d, ok := overrides[c.ID()]; ok {
+		return d
+	}
+	if g, ok := c.(Grouped); ok {
+		if d, ok := overrides[g.Group()]; ok {
+			return d
+		}
+	}
+	if b, ok := c.(Budgeted); ok {
+		return b.Budget()
+	}
+	return DefaultBudget
+}
+
+// checkOutcome is what a check returned.
+type checkOutcome struct {
+	found []report.Finding
+	err   error
+}
+
+// runBudgeted runs c under a context cancelled once budget has elapsed, zero
+// meaning no budget. The check runs on a private copy of deps, copied back once
+// it returns. A check that ignores the cancellation is abandoned after
+// abandonGrace; its goroutine is left to finish on its own with its copy, and
+// its result is dropped, since every request it sends is bound to the
+// cancelled context. timedOut reports whether the budget, rather than ctx,
+// ended the check.
+func runBudgeted(ctx context.Context, c Check, target string, deps *Deps, budget time.Duration) (out checkOutcome, timedOut bool) {
+	var checkCtx context.Context
+	var cancel context.CancelFunc
+	if budget > 0 {
+		checkCtx, cancel = context.WithTimeout(ctx, budget)
+	} else {
+		checkCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	local := deps.clone()
+	done := make(chan checkOutcome, 1)
+	go func() {
+		found, err := c.Run(checkCtx, target, local)
+		done <- checkOutcome{found, err}
+	}()
+
+	select {
+	case out = <-done:
+		*deps = *local
+		return out, ctx.Err() == nil && checkCtx.Err() == context.DeadlineExceeded
+	case <-checkCtx.Done():
+	}
+	timedOut = ctx.Err() == nil
+	grace := time.NewTimer(abandonGrace)
+	defer grace.Stop()
+	select {
+	case out = <-done:
+		*deps = *local
+	case <-grace.C:
+		logger.Warn("Check %s did not stop within %s of being cancelled on %s; abandoning it", c.ID(), abandonGrace, target)
+		out = checkOutcome{err: checkCtx.Err()}
+	}
+	return out, timedOut
+}
+
+// clone returns a copy of d whose fields, and the capabilities it points to,
+// a check can set without touching d.
+func (d *Deps) clone() *Deps {
+	c := *d
+	if d.Capabilities != nil {
+		capabilities := *d.Capabilities
+		capabilities.Negotiation = append([]types.NegotiationProbe(nil), d.Capabilities.Negotiation...)
+		c.Capabilities = &capabilities
+	}
+	return &c
+}
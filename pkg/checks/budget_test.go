@@ -0,0 +1,153 @@
+package checks
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// stuckCheck never returns before release is closed, whatever its context,
+// and then sets the query posture of the deps it was given.
+type stuckCheck struct {
+	release chan struct{}
+	started chan context.Context
+	wg      *sync.WaitGroup
+}
+
+func (stuckCheck) ID() string          { return "stuck" }
+func (stuckCheck) Description() string { return "never returns" }
+func (stuckCheck) Severity() string    { return report.SeverityInfo }
+
+func (c stuckCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	defer c.wg.Done()
+	c.started <- ctx
+	<-c.release
+	deps.QueryPosture = "late"
+	return nil, nil
+}
+
+// postureCheck sets the query posture and returns at once.
+type postureCheck struct{}
+
+func (postureCheck) ID() string          { return "posture" }
+func (postureCheck) Description() string { return "sets the posture" }
+func (postureCheck) Severity() string    { return report.SeverityInfo }
+
+func (postureCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	deps.QueryPosture = PostureAllowlist
+	deps.capabilities(target).Defer = true
+	return nil, nil
+}
+
+// opaqueContext hides the cancelable context it wraps, so that every context
+// derived from it watches it from a goroutine of its own until canceled.
+type opaqueContext struct{ context.Context }
+
+func (opaqueContext) Value(key interface{}) interface{} { return nil }
+
+// settledGoroutines waits for the number of goroutines to drop to want and
+// returns the number it settled at.
+func settledGoroutines(want int) int {
+	n := runtime.NumGoroutine()
+	for deadline := time.Now().Add(2 * time.Second); n > want && time.Now().Before(deadline); n = runtime.NumGoroutine() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return n
+}
+
+func TestRunBudgetedAbandonsStuckCheck(t *testing.T) {
+	defer func(grace time.Duration) { abandonGrace = grace }(abandonGrace)
+	abandonGrace = 20 * time.Millisecond
+
+	parent, cancelParent := context.WithCancel(context.Background())
+	defer cancelParent()
+	ctx := opaqueContext{parent}
+	before := runtime.NumGoroutine()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	check := stuckCheck{release: make(chan struct{}), started: make(chan context.Context, 1), wg: &wg}
+	deps := &Deps{}
+	out, timedOut := runBudgeted(ctx, check, "https://api.example.com/graphql", deps, 20*time.Millisecond)
+	if !timedOut {
+		t.Error("runBudgeted did not report the budget running out")
+	}
+	if out.err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want %v", out.err, context.DeadlineExceeded)
+	}
+	if checkCtx := <-check.started; checkCtx.Err() == nil {
+		t.Error("the context of the abandoned check is still live")
+	}
+
+	// The abandoned check finishing late writes its own copy of the deps.
+	close(check.release)
+	wg.Wait()
+	if deps.QueryPosture != "" {
+		t.Errorf("the abandoned check set QueryPosture to %q in the shared deps", deps.QueryPosture)
+	}
+	if n := settledGoroutines(before); n > before {
+		t.Errorf("%d goroutine(s) leaked by the abandoned check", n-before)
+	}
+}
+
+func TestRunBudgetedReleasesContext(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	defer cancelParent()
+	ctx := opaqueContext{parent}
+	before := runtime.NumGoroutine()
+	for _, budget := range []time.Duration{0, time.Minute} {
+		for i := 0; i < 10; i++ {
+			runBudgeted(ctx, postureCheck{}, "https://api.example.com/graphql", &Deps{}, budget)
+		}
+	}
+	if n := settledGoroutines(before); n > before {
+		t.Errorf("%d goroutine(s) still watch the parent context after the checks returned", n-before)
+	}
+}
+
+func TestRunBudgetedKeepsDeps(t *testing.T) {
+	deps := &Deps{}
+	out, timedOut := runBudgeted(context.Background(), postureCheck{}, "https://api.example.com/graphql", deps, time.Minute)
+	if out.err != nil || timedOut {
+		t.Fatalf("runBudgeted = %v, timed out %v", out.err, timedOut)
+	}
+	if deps.QueryPosture != PostureAllowlist || deps.Capabilities == nil || !deps.Capabilities.Defer {
+		t.Errorf("deps = %+v, want the posture and capabilities the check set", deps)
+	}
+}
+
+func TestParseTimeouts(t *testing.T) {
+	got, err := ParseTimeouts("blind-injection=2m, injection=0s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["blind-injection"] != 2*time.Minute || got["injection"] != 0 || len(got) != 2 {
+		t.Errorf("ParseTimeouts = %v", got)
+	}
+	for _, spec := range []string{"blind-injection", "unknown=1m", "blind-injection=soon", "blind-injection=-1s"} {
+		if _, err := ParseTimeouts(spec); err == nil {
+			t.Errorf("ParseTimeouts(%q) accepted an invalid timeout", spec)
+		}
+	}
+}
+
+func TestBudgetOf(t *testing.T) {
+	blind := registry["blind-injection"]
+	if got := BudgetOf(blind, nil); got != 10*time.Minute {
+		t.Errorf("declared budget = %s, want 10m", got)
+	}
+	if got := BudgetOf(blind, map[string]time.Duration{GroupInjection: time.Minute}); got != time.Minute {
+		t.Errorf("group override = %s, want 1m", got)
+	}
+	overrides := map[string]time.Duration{GroupInjection: time.Minute, "blind-injection": 0}
+	if got := BudgetOf(blind, overrides); got != 0 {
+		t.Errorf("id override = %s, want no budget", got)
+	}
+	if got := BudgetOf(postureCheck{}, nil); got != DefaultBudget {
+		t.Errorf("default budget = %s, want %s", got, DefaultBudget)
+	}
+}
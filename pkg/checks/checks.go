@@ -0,0 +1,578 @@
+// Package checks provides the registry of audit probes run against GraphQL endpoints
+package checks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/attacks"
+	"github.com/CyberRoute/graphspecter/pkg/fingerprint"
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Deps carries the shared inputs and intermediate results available to checks.
+// Checks running later against the same endpoint can consume what earlier ones stored.
+type Deps struct {
+	Headers    map[string]string
+	OutputFile string
+	// RedactArtifacts masks sensitive values in saved introspection dumps.
+	RedactArtifacts bool
+	// WSURL overrides the subscription endpoint derived from the target URL.
+	WSURL string
+	// MaxDepth bounds the selection sets of generated operation documents.
+	MaxDepth int
+	// Selection is the projection of those selection sets.
+	Selection string
+	// Notes annotate the operations of the catalog.
+	Notes schema.Notes
+	// Sample selects the operations of the catalog.
+	Sample schema.Sample
+	// Injection tunes the time-based injection probes.
+	Injection attacks.InjectionOptions
+	// ChunkedIntrospection fetches the schema in batches of IntrospectionChunkSize types.
+	ChunkedIntrospection   bool
+	IntrospectionChunkSize int
+	// IntrospectionFile names the saved result Introspection, Schema and Catalog
+	// were loaded from. The target is then not queried for its schema.
+	IntrospectionFile string
+
+	// Introspection holds the raw introspection result once a check has fetched it.
+	Introspection map[string]interface{}
+	// IntrospectionTier is the most permissive introspection tier found accessible.
+	IntrospectionTier introspection.Tier
+	// Schema is the introspected schema.
+	Schema *types.GQLSchema
+	// Catalog describes the operations of the introspected schema.
+	Catalog *schema.Catalog
+	// Engines lists the fingerprinted server implementations, most confident first.
+	Engines []fingerprint.EngineMatch
+	// Components lists the GraphQL IDEs served on the endpoint, with their versions.
+	Components []fingerprint.EngineMatch
+	// QueryPosture is the Posture value found by the query-policy check, empty
+	// until it has run.
+	QueryPosture string
+	// Auth is the verification of the supplied credentials, nil when none
+	// were supplied.
+	Auth *types.AuthVerification
+	// Capabilities are the delivery features found by the transport-features
+	// and content-negotiation checks, nil until one of them has run.
+	Capabilities *types.Capabilities
+	// Schemas remembers the schema of each endpoint between the runs of a
+	// watch, nil otherwise.
+	Schemas SchemaStore
+	// SchemaUnchanged is set by the introspection check when the schema is the
+	// one Schemas recorded. The checks reading nothing but the schema are then
+	// not run again; their recorded findings are reported instead.
+	SchemaUnchanged bool
+}
+
+// SchemaStore keeps the hash and ETag of the introspection result of each
+// endpoint, with the findings of the last run, so that a schema that did not
+// change is not processed again. workspace.WatchState implements it.
+type SchemaStore interface {
+	// Schema returns the hash and ETag recorded for endpoint.
+	Schema(endpoint string) (hash, etag string)
+	// SetSchema records the hash and ETag of the schema of endpoint.
+	SetSchema(endpoint, hash, etag string)
+	// CheckFindings returns the findings check reported on endpoint in the
+	// last run.
+	CheckFindings(check, endpoint string) []report.Finding
+}
+
+// SchemaOnly reports whether c reads the schema and nothing else, so that it
+// finds the same with the same schema.
+func SchemaOnly(c Check) bool {
+	return Requires(c) == RequiresSchema
+}
+
+// CredentialsRejected reports whether the endpoint refused the supplied credentials.
+func (d *Deps) CredentialsRejected() bool {
+	return d.Auth != nil && d.Auth.Status == types.AuthRejected
+}
+
+// ArbitraryQueriesBlocked reports whether the endpoint was found to execute
+// only persisted or allow-listed operations.
+func (d *Deps) ArbitraryQueriesBlocked() bool {
+	return d.QueryPosture == PostureAllowlist
+}
+
+// Check is a single audit probe that can be enabled or disabled by name.
+type Check interface {
+	ID() string
+	Description() string
+	Severity() string
+	Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error)
+}
+
+// Grouped is implemented by opt-in checks. They only run when their group is
+// enabled (for example with --audit-dos) or when they are named in --checks.
+type Grouped interface {
+	Group() string
+}
+
+// Check groups
+const (
+	GroupDoS       = "dos"
+	GroupWS        = "ws"
+	GroupInjection = "injection"
+)
+
+// Requirement is a bit set of what a check needs to run.
+type Requirement int
+
+// Check requirements
+const (
+	// RequiresNetwork means the check sends requests to the target.
+	RequiresNetwork Requirement = 1 << iota
+	// RequiresSchema means the check reads Deps.Schema or Deps.Catalog.
+	RequiresSchema
+	// RequiresEngines means the check reads Deps.Engines or Deps.Components.
+	RequiresEngines
+	// RequiresArbitraryQueries means the check sends documents of its own, which
+	// an operation allow-list rejects. Such checks are skipped once the
+	// query-policy check finds one.
+	RequiresArbitraryQueries
+)
+
+// stored are the requirements stored in Deps by other checks. A check
+// requiring one that Deps does not hold when its turn comes is skipped.
+const stored = RequiresSchema | RequiresEngines
+
+// Requirer is implemented by checks that declare what they need. Checks that
+// do not implement it are assumed to send requests.
+type Requirer interface {
+	Requires() Requirement
+}
+
+// Provider is implemented by checks storing in Deps what other checks require:
+// the introspection check provides RequiresSchema, the engine check
+// RequiresEngines, and the query-policy check the posture behind
+// RequiresArbitraryQueries. Select orders every check after the selected
+// providers of its requirements.
+type Provider interface {
+	Provides() Requirement
+}
+
+// Provides returns what c stores in Deps for the checks that follow it.
+func Provides(c Check) Requirement {
+	if p, ok := c.(Provider); ok {
+		return p.Provides()
+	}
+	return 0
+}
+
+// Requires returns the requirements declared by c.
+func Requires(c Check) Requirement {
+	if r, ok := c.(Requirer); ok {
+		return r.Requires()
+	}
+	return RequiresNetwork
+}
+
+// NeedsNetwork reports whether c sends requests to the target.
+func NeedsNetwork(c Check) bool {
+	return Requires(c)&RequiresNetwork != 0
+}
+
+// String lists the requirements for check listings.
+func (r Requirement) String() string {
+	var parts []string
+	if r&RequiresNetwork != 0 {
+		parts = append(parts, "network")
+	}
+	if r&RequiresSchema != 0 {
+		parts = append(parts, "schema")
+	}
+	if r&RequiresEngines != 0 {
+		parts = append(parts, "engines")
+	}
+	if r&RequiresArbitraryQueries != 0 {
+		parts = append(parts, "queries")
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ",")
+}
+
+// Offline splits selected into the checks that can run without sending requests
+// and the ones that cannot.
+func Offline(selected []Check) (offline, online []Check) {
+	for _, c := range selected {
+		if NeedsNetwork(c) {
+			online = append(online, c)
+		} else {
+			offline = append(offline, c)
+		}
+	}
+	return offline, online
+}
+
+var (
+	// registry maps check ids to their implementation
+	registry = map[string]Check{}
+
+	// order keeps checks in registration order so runs are predictable
+	order []string
+)
+
+// Register adds a check to the registry. It panics on duplicate ids since
+// that is always a programming error.
+func Register(c Check) {
+	id := c.ID()
+	if _, exists := registry[id]; exists {
+		panic(fmt.Sprintf("checks: duplicate check id %q", id))
+	}
+	registry[id] = c
+	order = append(order, id)
+}
+
+// All returns every registered check in registration order.
+func All() []Check {
+	all := make([]Check, 0, len(order))
+	for _, id := range order {
+		all = append(all, registry[id])
+	}
+	return all
+}
+
+// Lookup returns the check registered under id.
+func Lookup(id string) (Check, bool) {
+	c, ok := registry[id]
+	return c, ok
+}
+
+// forbidden maps the ids of the checks excluded by Forbid to the reason.
+var forbidden = map[string]string{}
+
+// Forbid excludes the checks named by names, which are check ids or groups,
+// from every selection for reason, such as the rules of a bug bounty program.
+// Unknown names are an error.
+func Forbid(reason string, names ...string) error {
+	var ids, unknown []string
+	for _, name := range names {
+		if _, ok := registry[name]; ok {
+			ids = append(ids, name)
+			continue
+		}
+		group := false
+		for _, id := range order {
+			if g, ok := registry[id].(Grouped); ok && g.Group() == name {
+				ids = append(ids, id)
+				group = true
+			}
+		}
+		if !group {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown check(s) or group(s): %s", strings.Join(unknown, ", "))
+	}
+	for _, id := range ids {
+		forbidden[id] = reason
+	}
+	return nil
+}
+
+// Forbidden returns the reason the check id was excluded by Forbid.
+func Forbidden(id string) (string, bool) {
+	reason, ok := forbidden[id]
+	return reason, ok
+}
+
+// ParseList splits a comma-separated list of check ids, trimming blanks.
+func ParseList(list string) []string {
+	var ids []string
+	for _, id := range strings.Split(list, ",") {
+		id = strings.TrimSpace(id)
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Select resolves the --checks and --skip-checks values into the checks to run.
+// An empty enabled list means all registered checks except opt-in ones whose
+// group is not listed in groups. Checks excluded by Forbid are left out; naming
+// one in enabled is an error, as are unknown ids.
+func Select(enabled, skipped string, groups ...string) ([]Check, error) {
+	enabledIDs := ParseList(enabled)
+	skippedIDs := ParseList(skipped)
+
+	if err := validateIDs(enabledIDs); err != nil {
+		return nil, err
+	}
+	if err := validateIDs(skippedIDs); err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool, len(skippedIDs))
+	for _, id := range skippedIDs {
+		skip[id] = true
+	}
+
+	want := make(map[string]bool, len(enabledIDs))
+	for _, id := range enabledIDs {
+		if reason, ok := forbidden[id]; ok {
+			return nil, fmt.Errorf("check %s is forbidden: %s", id, reason)
+		}
+		want[id] = true
+	}
+
+	enabledGroups := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		enabledGroups[g] = true
+	}
+
+	if cycle := dependencyCycle(All()); cycle != nil {
+		return nil, fmt.Errorf("checks depend on each other: %s", strings.Join(cycle, " -> "))
+	}
+
+	var selected []Check
+	for _, c := range All() {
+		if len(want) > 0 && !want[c.ID()] {
+			continue
+		}
+		if g, ok := c.(Grouped); ok && !want[c.ID()] && !enabledGroups[g.Group()] {
+			continue
+		}
+		if skip[c.ID()] {
+			continue
+		}
+		if _, ok := forbidden[c.ID()]; ok {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	return sortByDependencies(selected), nil
+}
+
+// dependsOn reports whether c requires what p provides. Checks are told
+// apart by id, since not every implementation is comparable.
+func dependsOn(c, p Check) bool {
+	return c.ID() != p.ID() && Requires(c)&Provides(p) != 0
+}
+
+// sortByDependencies orders checks so that each one follows the providers of
+// its requirements, keeping the registration order otherwise. The checks must
+// not depend on each other, which Select verifies.
+func sortByDependencies(checks []Check) []Check {
+	sorted := make([]Check, 0, len(checks))
+	placed := make(map[string]bool, len(checks))
+	for len(sorted) < len(checks) {
+		for _, c := range checks {
+			if placed[c.ID()] || !ready(c, checks, placed) {
+				continue
+			}
+			sorted = append(sorted, c)
+			placed[c.ID()] = true
+			// Start over so that the earliest registered check ready comes next.
+			break
+		}
+	}
+	return sorted
+}
+
+// ready reports whether every provider of c among checks is placed.
+func ready(c Check, checks []Check, placed map[string]bool) bool {
+	for _, p := range checks {
+		if dependsOn(c, p) && !placed[p.ID()] {
+			return false
+		}
+	}
+	return true
+}
+
+// dependencyCycle returns the ids of a chain of checks depending on each other,
+// starting and ending with the same check, or nil when there is none.
+func dependencyCycle(checks []Check) []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(checks))
+	var path []string
+	var visit func(c Check) []string
+	visit = func(c Check) []string {
+		state[c.ID()] = visiting
+		path = append(path, c.ID())
+		for _, p := range checks {
+			if !dependsOn(c, p) {
+				continue
+			}
+			switch state[p.ID()] {
+			case visiting:
+				for i, id := range path {
+					if id == p.ID() {
+						return append(append([]string(nil), path[i:]...), p.ID())
+					}
+				}
+			case 0:
+				if cycle := visit(p); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[c.ID()] = visited
+		return nil
+	}
+	for _, c := range checks {
+		if state[c.ID()] == 0 {
+			if cycle := visit(c); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// validateIDs returns an error naming every id that is not registered.
+func validateIDs(ids []string) error {
+	var unknown []string
+	for _, id := range ids {
+		if _, ok := registry[id]; !ok {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	known := append([]string(nil), order...)
+	sort.Strings(known)
+	return fmt.Errorf("unknown check(s): %s (available: %s)", strings.Join(unknown, ", "), strings.Join(known, ", "))
+}
+
+// SkipAllowlist is the reason recorded for checks skipped because the endpoint
+// only executes persisted operations.
+const SkipAllowlist = "arbitrary queries are blocked by an operation allow-list"
+
+// held returns the artifacts deps holds.
+func held(deps *Deps) Requirement {
+	var r Requirement
+	if deps.Schema != nil {
+		r |= RequiresSchema
+	}
+	if len(deps.Engines) > 0 || len(deps.Components) > 0 {
+		r |= RequiresEngines
+	}
+	return r
+}
+
+// missingReason explains why the artifacts missing, required by a check, are
+// not in Deps, from the results of the checks run before it.
+func missingReason(missing Requirement, selected []Check, results []report.CheckResult) string {
+	status := make(map[string]string, len(results))
+	for _, r := range results {
+		status[r.Check] = r.Status
+	}
+	var providers []string
+	for _, p := range selected {
+		if Provides(p)&missing == 0 {
+			continue
+		}
+		switch s := status[p.ID()]; s {
+		case report.StatusPassed, report.StatusFound:
+			providers = append(providers, p.ID()+" provided none")
+		default:
+			providers = append(providers, p.ID()+" "+s)
+		}
+	}
+	if len(providers) == 0 {
+		return fmt.Sprintf("missing %s: no selected check provides it", missing)
+	}
+	return fmt.Sprintf("missing %s: %s", missing, strings.Join(providers, ", "))
+}
+
+// Run executes the given checks against target, in the order Select returns
+// them. A failing check is recorded and logged but never prevents the remaining
+// checks from running; those requiring an artifact it did not store in deps
+// are skipped. Findings and failures are reported to ctl; once it stops the
+// run the remaining checks are skipped.
+func Run(ctx context.Context, ctl *Controller, selected []Check, target string, deps *Deps) ([]report.Finding, []report.CheckResult) {
+	var findings []report.Finding
+	var results []report.CheckResult
+
+	for _, c := range selected {
+		if ctx.Err() != nil {
+			if ctl.Stopped() != nil {
+				results = append(results, report.CheckResult{Check: c.ID(), Endpoint: target, Status: report.StatusSkipped})
+				continue
+			}
+			break
+		}
+		if Requires(c)&RequiresArbitraryQueries != 0 && deps.ArbitraryQueriesBlocked() {
+			logger.Info("Skipping %s on %s: %s", c.ID(), target, SkipAllowlist)
+			results = append(results, report.CheckResult{Check: c.ID(), Endpoint: target, Status: report.StatusSkipped, Reason: SkipAllowlist})
+			continue
+		}
+		if missing := Requires(c) & stored &^ held(deps); missing != 0 {
+			reason := missingReason(missing, selected, results)
+			logger.Info("Skipping %s on %s: %s", c.ID(), target, reason)
+			results = append(results, report.CheckResult{Check: c.ID(), Endpoint: target, Status: report.StatusSkipped, Reason: reason})
+			continue
+		}
+		if deps.SchemaUnchanged && deps.Schemas != nil && SchemaOnly(c) {
+			found := deps.Schemas.CheckFindings(c.ID(), target)
+			logger.Info("Skipping %s on %s: schema unchanged, reporting its %d previous finding(s)", c.ID(), target, len(found))
+			result := report.CheckResult{Check: c.ID(), Endpoint: target, Status: report.StatusPassed, Reason: "schema unchanged"}
+			if len(found) > 0 {
+				result.Status = report.StatusFound
+			}
+			for _, f := range found {
+				ctl.Finding(f)
+			}
+			findings = append(findings, found...)
+			results = append(results, result)
+			continue
+		}
+		budget := BudgetOf(c, ctl.timeouts())
+		logger.Debug("→ Running check %s on %s (budget %s)", c.ID(), target, budget)
+		checkCtx, stop := network.StartModule(ctx, c.ID())
+		start := time.Now()
+		out, timedOut := runBudgeted(checkCtx, c, target, deps, budget)
+		elapsed := time.Since(start)
+		stop()
+		found, err := out.found, out.err
+		result := report.CheckResult{Check: c.ID(), Endpoint: target, Status: report.StatusPassed, DurationMs: elapsed.Milliseconds()}
+		switch {
+		case timedOut:
+			// Running out of budget says nothing about the target either way.
+			logger.Info("Check %s ran out of its %s budget on %s", c.ID(), budget, target)
+			result.Status = report.StatusInconclusive
+			result.Reason = "timed out after " + budget.String()
+		case err != nil && ctl.Stopped() != nil && ctx.Err() != nil:
+			// Interrupted by the policy rather than failing on its own.
+			result.Status = report.StatusSkipped
+		case err != nil:
+			logger.Error("Check %s failed on %s: %v", c.ID(), target, err)
+			result.Status = report.StatusFailed
+			result.Error = err.Error()
+			ctl.CheckFailed(target)
+		case len(found) > 0:
+			result.Status = report.StatusFound
+		}
+		for i := range found {
+			if found[i].Check == "" {
+				found[i].Check = c.ID()
+			}
+			if found[i].Endpoint == "" {
+				found[i].Endpoint = target
+			}
+			ctl.Finding(found[i])
+		}
+		findings = append(findings, found...)
+		results = append(results, result)
+	}
+	return findings, results
+}
@@ -0,0 +1,381 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ids returns the ids of checks.
+func ids(checks []Check) []string {
+	var out []string
+	for _, c := range checks {
+		out = append(out, c.ID())
+	}
+	return out
+}
+
+func TestForbid(t *testing.T) {
+	defer func() { forbidden = map[string]string{} }()
+
+	if err := Forbid("the acme bounty profile does not allow it", GroupDoS, "introspection", "no-such-check"); err == nil || !strings.Contains(err.Error(), "no-such-check") {
+		t.Fatalf("Forbid with an unknown id: %v", err)
+	}
+	if len(forbidden) != 0 {
+		t.Fatalf("a failed Forbid excluded %v", forbidden)
+	}
+	if err := Forbid("the acme bounty profile does not allow it", GroupDoS, "introspection"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Forbidden checks are left out of every selection, opt-in groups included.
+	selected, err := SelectPreset(PresetAggressive, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids(selected) {
+		if _, ok := Forbidden(id); ok {
+			t.Errorf("forbidden check %s was selected", id)
+		}
+	}
+	if reason, ok := Forbidden("rate-limit"); !ok || reason != "the acme bounty profile does not allow it" {
+		t.Errorf("Forbidden(rate-limit) = %q, %v; want the dos group excluded", reason, ok)
+	}
+
+	// Naming one is an error rather than a silent omission.
+	if _, err := Select("introspection", ""); err == nil || !strings.Contains(err.Error(), "check introspection is forbidden: the acme bounty profile") {
+		t.Errorf("selecting a forbidden check: %v", err)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{"", nil},
+		{"batching", []string{"batching"}},
+		{" batching , csrf,,introspection ", []string{"batching", "csrf", "introspection"}},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseList(tt.list); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseList(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	sorted := func(checks []Check) []string {
+		out := ids(checks)
+		sort.Strings(out)
+		return out
+	}
+	has := func(checks []Check, id string) bool {
+		for _, c := range checks {
+			if c.ID() == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	selected, err := Select("csrf, batching", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sorted(selected); !reflect.DeepEqual(got, []string{"batching", "csrf"}) {
+		t.Errorf("--checks csrf,batching selected %v", got)
+	}
+
+	// Naming an opt-in check selects it without its group.
+	selected, err = Select("rate-limit", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(selected); !reflect.DeepEqual(got, []string{"rate-limit"}) {
+		t.Errorf("--checks rate-limit selected %v", got)
+	}
+
+	all, err := Select("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has(all, "introspection") || has(all, "rate-limit") || has(all, "ws-protocol") {
+		t.Errorf("default selection: %v", ids(all))
+	}
+	withDoS, err := Select("", "", GroupDoS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has(withDoS, "rate-limit") || len(withDoS) != len(all)+1 {
+		t.Errorf("selection with the dos group: %v", ids(withDoS))
+	}
+
+	skipped, err := Select("", "csrf,batching")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has(skipped, "csrf") || has(skipped, "batching") || len(skipped) != len(all)-2 {
+		t.Errorf("--skip-checks csrf,batching selected %v", ids(skipped))
+	}
+
+	// Checks come after the checks providing what they require.
+	selected, err = Select("applied-directives,engine,introspection", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(selected); got[len(got)-1] != "applied-directives" {
+		t.Errorf("applied-directives runs before the checks it depends on: %v", got)
+	}
+}
+
+func TestSelectUnknownCheck(t *testing.T) {
+	for _, tt := range []struct{ enabled, skipped string }{
+		{"batching,no-such-check", ""},
+		{"", "no-such-check"},
+	} {
+		_, err := Select(tt.enabled, tt.skipped)
+		if err == nil {
+			t.Errorf("Select(%q, %q) accepted an unknown check", tt.enabled, tt.skipped)
+			continue
+		}
+		if msg := err.Error(); !strings.Contains(msg, "unknown check(s): no-such-check") || !strings.Contains(msg, "available: ") || !strings.Contains(msg, "batching") {
+			t.Errorf("Select(%q, %q) = %v", tt.enabled, tt.skipped, err)
+		}
+	}
+}
+
+// fakeCheck returns its findings and error from Run and records that it ran.
+type fakeCheck struct {
+	id       string
+	findings []report.Finding
+	err      error
+	ran      *[]string
+}
+
+func (c fakeCheck) ID() string          { return c.id }
+func (c fakeCheck) Description() string { return "fake " + c.id }
+func (c fakeCheck) Severity() string    { return report.SeverityLow }
+
+func (c fakeCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	*c.ran = append(*c.ran, c.id)
+	return c.findings, c.err
+}
+
+func TestRunContinuesAfterFailingCheck(t *testing.T) {
+	var ran []string
+	selected := []Check{
+		fakeCheck{id: "first", ran: &ran, findings: []report.Finding{{ID: "first-finding", Severity: report.SeverityLow}}},
+		fakeCheck{id: "broken", ran: &ran, err: errors.New("connection reset")},
+		fakeCheck{id: "last", ran: &ran, findings: []report.Finding{{ID: "last-finding", Severity: report.SeverityLow}}},
+	}
+	ctl, ctx := NewController(context.Background(), Policy{})
+	const target = "https://api.example.com/graphql"
+	findings, results := Run(ctx, ctl, selected, target, &Deps{})
+
+	if !reflect.DeepEqual(ran, []string{"first", "broken", "last"}) {
+		t.Errorf("ran %v, want every check", ran)
+	}
+	if len(findings) != 2 || findings[0].ID != "first-finding" || findings[1].ID != "last-finding" {
+		t.Fatalf("findings = %+v", findings)
+	}
+	for _, f := range findings {
+		if f.Endpoint != target || f.Check == "" {
+			t.Errorf("finding %s lacks its check or endpoint: %+v", f.ID, f)
+		}
+	}
+	statuses := make(map[string]report.CheckResult)
+	for _, r := range results {
+		statuses[r.Check] = r
+	}
+	if r := statuses["broken"]; r.Status != report.StatusFailed || r.Error != "connection reset" {
+		t.Errorf("broken check result = %+v", r)
+	}
+	if statuses["first"].Status != report.StatusFound || statuses["last"].Status != report.StatusFound {
+		t.Errorf("results = %+v", results)
+	}
+	if ctl.Stopped() != nil {
+		t.Errorf("a failing check stopped the run: %+v", ctl.Stopped())
+	}
+}
+
+// depCheck is a fakeCheck declaring what it requires and provides. Running it
+// stores in Deps what store sets.
+type depCheck struct {
+	fakeCheck
+	requires, provides Requirement
+	store              func(deps *Deps)
+}
+
+func (c depCheck) Requires() Requirement { return c.requires }
+func (c depCheck) Provides() Requirement { return c.provides }
+
+func (c depCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	if c.store != nil {
+		c.store(deps)
+	}
+	return c.fakeCheck.Run(ctx, target, deps)
+}
+
+func TestSortByDependencies(t *testing.T) {
+	var ran []string
+	check := func(id string, requires, provides Requirement) Check {
+		return depCheck{fakeCheck: fakeCheck{id: id, ran: &ran}, requires: requires, provides: provides}
+	}
+	selected := []Check{
+		check("vulns", RequiresEngines, 0),
+		check("secrets", RequiresSchema, 0),
+		check("plain", RequiresNetwork, 0),
+		check("schema", RequiresNetwork|RequiresArbitraryQueries, RequiresSchema),
+		check("engines", RequiresNetwork|RequiresSchema, RequiresEngines),
+		check("policy", RequiresNetwork, RequiresArbitraryQueries),
+	}
+	// Each check follows its providers, the earliest registered ready check
+	// coming first: the policy before the schema, the schema before the
+	// engines and the secrets, and the engines before the vulnerabilities.
+	want := []string{"plain", "policy", "schema", "secrets", "engines", "vulns"}
+	if got := ids(sortByDependencies(selected)); !reflect.DeepEqual(got, want) {
+		t.Errorf("sortByDependencies() = %v, want %v", got, want)
+	}
+	// Without dependencies the registration order is kept.
+	independent := []Check{check("c", RequiresSchema, 0), check("a", RequiresNetwork, 0), check("b", RequiresSchema, 0)}
+	if got := ids(sortByDependencies(independent)); !reflect.DeepEqual(got, []string{"c", "a", "b"}) {
+		t.Errorf("sortByDependencies() = %v, want the registration order", got)
+	}
+	// A check providing what it requires does not wait for itself.
+	if got := ids(sortByDependencies([]Check{check("self", RequiresSchema, RequiresSchema)})); !reflect.DeepEqual(got, []string{"self"}) {
+		t.Errorf("sortByDependencies() = %v", got)
+	}
+
+	// The registered checks follow the same rule.
+	all, err := Select("", "", GroupDoS, GroupWS, GroupInjection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	position := make(map[string]int)
+	for i, c := range all {
+		position[c.ID()] = i
+	}
+	for _, c := range all {
+		for _, p := range all {
+			if dependsOn(c, p) && position[p.ID()] > position[c.ID()] {
+				t.Errorf("%s runs before %s, which provides what it requires", c.ID(), p.ID())
+			}
+		}
+	}
+	for _, pair := range [][2]string{{"introspection", "schema-secrets"}, {"engine", "vulndb"}, {"query-policy", "batching"}} {
+		if position[pair[0]] > position[pair[1]] {
+			t.Errorf("%s runs after %s", pair[0], pair[1])
+		}
+	}
+}
+
+func TestDependencyCycle(t *testing.T) {
+	check := func(id string, requires, provides Requirement) Check {
+		return depCheck{fakeCheck: fakeCheck{id: id}, requires: requires, provides: provides}
+	}
+	if cycle := dependencyCycle(All()); cycle != nil {
+		t.Errorf("the registered checks depend on each other: %v", cycle)
+	}
+	cyclic := []Check{
+		check("plain", RequiresNetwork, 0),
+		check("schema", RequiresEngines, RequiresSchema),
+		check("engines", RequiresArbitraryQueries, RequiresEngines),
+		check("policy", RequiresSchema, RequiresArbitraryQueries),
+	}
+	if cycle := dependencyCycle(cyclic); !reflect.DeepEqual(cycle, []string{"schema", "engines", "policy", "schema"}) {
+		t.Errorf("dependencyCycle() = %v", cycle)
+	}
+
+	// Select refuses a registry holding a cycle.
+	savedRegistry, savedOrder := registry, order
+	defer func() { registry, order = savedRegistry, savedOrder }()
+	registry = map[string]Check{}
+	order = nil
+	for _, c := range cyclic {
+		Register(c)
+	}
+	if _, err := Select("plain", ""); err == nil || err.Error() != "checks depend on each other: schema -> engines -> policy -> schema" {
+		t.Errorf("Select() = %v, want the cycle", err)
+	}
+}
+
+func TestRunSkipsChecksMissingInputs(t *testing.T) {
+	const target = "https://api.example.com/graphql"
+	tests := []struct {
+		name     string
+		provider *depCheck
+		reason   string
+	}{
+		{
+			name:   "no provider selected",
+			reason: "missing schema: no selected check provides it",
+		},
+		{
+			name:     "provider failed",
+			provider: &depCheck{fakeCheck: fakeCheck{id: "introspection", err: errors.New("introspection is disabled")}, provides: RequiresSchema},
+			reason:   "missing schema: introspection failed",
+		},
+		{
+			name:     "provider stored nothing",
+			provider: &depCheck{fakeCheck: fakeCheck{id: "introspection"}, provides: RequiresSchema},
+			reason:   "missing schema: introspection provided none",
+		},
+		{
+			name: "provider stored the schema",
+			provider: &depCheck{fakeCheck: fakeCheck{id: "introspection"}, provides: RequiresSchema, store: func(deps *Deps) {
+				deps.Schema = &types.GQLSchema{}
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran []string
+			var selected []Check
+			if tt.provider != nil {
+				tt.provider.ran = &ran
+				selected = append(selected, *tt.provider)
+			}
+			selected = append(selected,
+				depCheck{fakeCheck: fakeCheck{id: "schema-secrets", ran: &ran}, requires: RequiresSchema},
+				fakeCheck{id: "plain", ran: &ran},
+			)
+			ctl, ctx := NewController(context.Background(), Policy{})
+			_, results := Run(ctx, ctl, sortByDependencies(selected), target, &Deps{})
+			last := results[len(results)-2]
+			if last.Check != "schema-secrets" {
+				t.Fatalf("results = %+v", results)
+			}
+			if tt.reason == "" {
+				if last.Status != report.StatusPassed || !ranCheck(ran, "schema-secrets") {
+					t.Errorf("schema-secrets = %+v, want it run", last)
+				}
+				return
+			}
+			if last.Status != report.StatusSkipped || last.Reason != tt.reason || ranCheck(ran, "schema-secrets") {
+				t.Errorf("schema-secrets = %+v, want it skipped with %q", last, tt.reason)
+			}
+			// A check missing its inputs does not hold up the others.
+			if r := results[len(results)-1]; r.Check != "plain" || r.Status != report.StatusPassed {
+				t.Errorf("plain = %+v", r)
+			}
+		})
+	}
+}
+
+// ranCheck reports whether id is among the checks that ran.
+func ranCheck(ran []string, id string) bool {
+	for _, v := range ran {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
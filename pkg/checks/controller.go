@@ -0,0 +1,146 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// Policy decides when a run across many targets is cut short.
+type Policy struct {
+	// StopOnSeverity stops the run at the first finding of this severity or
+	// higher. Empty disables the policy.
+	StopOnSeverity string
+	// ContinueOnError keeps scanning the remaining targets after a check fails.
+	// Without it the run stops once the target with the failure is finished.
+	ContinueOnError bool
+	// CheckTimeouts overrides the budget of checks by id or group, as parsed
+	// by ParseTimeouts.
+	CheckTimeouts map[string]time.Duration
+}
+
+// Controller receives the findings and failures of a run, evaluates the Policy
+// and cancels the run context when the policy is triggered. A nil Controller
+// never stops anything.
+type Controller struct {
+	policy Policy
+	cancel context.CancelFunc
+	// sinks, when set, receive every finding as it is reported.
+	sinks *report.Dispatcher
+
+	mu      sync.Mutex
+	failed  map[string]bool
+	stopped *report.StopReason
+}
+
+// NewController returns a Controller for policy and the context the run must use.
+func NewController(ctx context.Context, policy Policy) (*Controller, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Controller{policy: policy, cancel: cancel, failed: make(map[string]bool)}, ctx
+}
+
+// PublishTo makes the controller hand every finding to d as it is reported.
+func (c *Controller) PublishTo(d *report.Dispatcher) {
+	if c != nil {
+		c.sinks = d
+	}
+}
+
+// Publish hands a finding to the sinks without evaluating the policy, for the
+// findings made once the checks are over.
+func (c *Controller) Publish(f report.Finding) {
+	if c != nil {
+		c.sinks.Emit(f)
+	}
+}
+
+// Finding reports a finding, publishing it to the sinks, and stops the run if
+// it meets the severity threshold.
+func (c *Controller) Finding(f report.Finding) {
+	if c == nil {
+		return
+	}
+	c.sinks.Emit(f)
+	if c.policy.StopOnSeverity == "" {
+		return
+	}
+	if report.SeverityRank(f.Severity) > report.SeverityRank(c.policy.StopOnSeverity) {
+		return
+	}
+	c.stop(&report.StopReason{
+		Reason:   fmt.Sprintf("finding %s (%s) on %s met --stop-on-finding %s", f.ID, f.Severity, f.Endpoint, c.policy.StopOnSeverity),
+		Finding:  f.ID,
+		Endpoint: f.Endpoint,
+	})
+}
+
+// timeouts returns the budget overrides of the policy.
+func (c *Controller) timeouts() map[string]time.Duration {
+	if c == nil {
+		return nil
+	}
+	return c.policy.CheckTimeouts
+}
+
+// CheckFailed records that a check returned an error on target.
+func (c *Controller) CheckFailed(target string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.failed[target] = true
+	c.mu.Unlock()
+}
+
+// TargetDone is called once every check has run on target. Unless the policy
+// continues on error, a failure on target stops the run here.
+func (c *Controller) TargetDone(target string) {
+	if c == nil || c.policy.ContinueOnError {
+		return
+	}
+	c.mu.Lock()
+	failed := c.failed[target]
+	c.mu.Unlock()
+	if failed {
+		c.stop(&report.StopReason{
+			Reason:   fmt.Sprintf("a check failed on %s (use --continue-on-error to keep going)", target),
+			Endpoint: target,
+			Error:    true,
+		})
+	}
+}
+
+// Stopped returns why the run was cut short, or nil when it was not.
+func (c *Controller) Stopped() *report.StopReason {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopped
+}
+
+// Close releases the run context.
+func (c *Controller) Close() {
+	if c != nil {
+		c.cancel()
+	}
+}
+
+// stop records the first reason the run is stopped for and cancels the run context.
+func (c *Controller) stop(reason *report.StopReason) {
+	c.mu.Lock()
+	if c.stopped != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.stopped = reason
+	c.mu.Unlock()
+
+	logger.Info("Stopping run: %s", reason.Reason)
+	c.cancel()
+}
@@ -0,0 +1,78 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+func init() {
+	Register(csrfCheck{})
+}
+
+// csrfProbe is the query the CSRF check sends in the URL of a GET.
+const csrfProbe = `{ gsGet: __typename }`
+
+// csrfCheck tells whether the endpoint executes queries sent with GET. A GET
+// is a simple request browsers send cross-site with the cookies of the user,
+// so a page on another origin can make them run queries, and the query string
+// ends up in proxy, CDN and server logs.
+type csrfCheck struct{}
+
+func (csrfCheck) ID() string { return "csrf" }
+
+func (csrfCheck) Description() string {
+	return "Checks whether queries sent with GET, which browsers send cross-site, are executed"
+}
+
+func (csrfCheck) Severity() string { return report.SeverityMedium }
+
+func (csrfCheck) Safety() string { return SafetyPassive }
+
+func (csrfCheck) Requires() Requirement { return RequiresNetwork | RequiresArbitraryQueries }
+
+func (csrfCheck) Plan(target string, deps *Deps) Plan {
+	return Plan{Requests: 1, MaxRequests: 1}
+}
+
+func (c csrfCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	logger.Info("Checking if %s executes queries sent with GET...", target)
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing target URL: %w", err)
+	}
+	values := u.Query()
+	values.Set("query", csrfProbe)
+	u.RawQuery = values.Encode()
+
+	resp, err := network.ProbeRequest(ctx, http.MethodGet, u.String(), "", nil, deps.Headers, csrfProbe)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(resp.Body, &result); err != nil || !hasMarker(result, "gsGet") {
+		logger.Debug("→ %s did not execute the GET query (HTTP %d)", target, resp.Status)
+		return nil, nil
+	}
+
+	logger.Warn("WARNING: %s executes queries sent with GET", target)
+	body, _ := json.Marshal(types.GraphQLRequest{Query: csrfProbe})
+	req := report.NewGraphQLRequest(target, csrfProbe, nil, deps.Headers)
+	req.Method, req.Body = http.MethodGet, string(body)
+	return []report.Finding{{
+		ID:          "csrf-get-queries",
+		Title:       "Queries sent with GET are executed",
+		Severity:    c.Severity(),
+		Endpoint:    target,
+		Description: "The endpoint executed a query sent in the URL of a GET request. Browsers send such requests cross-site with the cookies of the user and without a preflight, so with cookie authentication any page the user visits can run queries as them, and the queries and their variables are written to the logs of every proxy on the way.",
+		Evidence:    fmt.Sprintf("HTTP %d (Content-Type: %s) with the data of the query", resp.Status, resp.ContentType),
+		Request:     req,
+	}}, nil
+}
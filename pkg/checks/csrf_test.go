@@ -0,0 +1,33 @@
+package checks
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+func TestCSRFCheck(t *testing.T) {
+	for _, get := range []bool{true, false} {
+		srv := graphQLServer(t, false, get)
+		findings, err := csrfCheck{}.Run(context.Background(), srv.URL+"/graphql?tenant=a", &Deps{Headers: map[string]string{"Content-Type": "application/json"}})
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !get {
+			if len(findings) != 0 {
+				t.Errorf("GET refused: findings = %+v", findings)
+			}
+			continue
+		}
+		if len(findings) != 1 || findings[0].ID != "csrf-get-queries" {
+			t.Fatalf("GET executed: findings = %+v", findings)
+		}
+		curl := report.CurlFor(*findings[0].Request)
+		if strings.Contains(curl, "-X") || strings.Contains(curl, "Content-Type") || !strings.Contains(curl, "tenant=a&query=%7B+gsGet%3A+__typename+%7D") {
+			t.Errorf("reproduction = %s", curl)
+		}
+	}
+}
@@ -0,0 +1,539 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"gopkg.in/yaml.v3"
+)
+
+// customFile is the document of a --custom-checks file.
+type customFile struct {
+	Checks []customDefinition `yaml:"checks" json:"checks"`
+}
+
+// customDefinition is a check declared in a custom checks file: a request and
+// the rules its response must all satisfy for the finding to be reported.
+type customDefinition struct {
+	ID          string        `yaml:"id" json:"id"`
+	Title       string        `yaml:"title" json:"title"`
+	Description string        `yaml:"description" json:"description"`
+	Severity    string        `yaml:"severity" json:"severity"`
+	Safety      string        `yaml:"safety" json:"safety"`
+	Request     customRequest `yaml:"request" json:"request"`
+	Match       []customRule  `yaml:"match" json:"match"`
+}
+
+// customRequest is the request of a custom check. Path is appended to the
+// target URL, and QueryFile is relative to the checks file.
+type customRequest struct {
+	Path      string                 `yaml:"path" json:"path"`
+	Query     string                 `yaml:"query" json:"query"`
+	QueryFile string                 `yaml:"query-file" json:"query-file"`
+	Variables map[string]interface{} `yaml:"variables" json:"variables"`
+	Headers   map[string]string      `yaml:"headers" json:"headers"`
+}
+
+// customRule is one match rule. It tests exactly one of the status code, a
+// JSON path of the response body, a response header or the latency.
+type customRule struct {
+	Status  int         `yaml:"status" json:"status"`
+	Path    string      `yaml:"path" json:"path"`
+	Exists  *bool       `yaml:"exists" json:"exists"`
+	Equals  interface{} `yaml:"equals" json:"equals"`
+	Contain *string     `yaml:"contains" json:"contains"`
+	Header  string      `yaml:"header" json:"header"`
+	Regex   string      `yaml:"regex" json:"regex"`
+	Latency string      `yaml:"latency" json:"latency"`
+}
+
+// customIDPattern matches the ids custom checks may take.
+var customIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// LoadCustom reads the checks of a .yaml, .yml or .json custom checks file and
+// registers them after the built-in ones. Every path, regular expression and
+// duration is compiled first, so a mistake is reported with the check and rule
+// it is in before anything is sent. It returns the number of checks registered.
+func LoadCustom(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read custom checks: %w", err)
+	}
+	var file customFile
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&file); err != nil {
+			return 0, fmt.Errorf("failed to parse YAML custom checks %s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&file); err != nil {
+			return 0, fmt.Errorf("failed to parse JSON custom checks %s: %w", path, err)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported custom checks format: %s", ext)
+	}
+	if len(file.Checks) == 0 {
+		return 0, fmt.Errorf("invalid custom checks %s: no checks", path)
+	}
+
+	compiled := make([]*customCheck, 0, len(file.Checks))
+	seen := map[string]bool{}
+	for i, d := range file.Checks {
+		c, err := compileCustom(d, path)
+		if err != nil {
+			name := d.ID
+			if name == "" {
+				name = "#" + strconv.Itoa(i+1)
+			}
+			return 0, fmt.Errorf("invalid custom checks %s: check %s: %w", path, name, err)
+		}
+		if _, exists := registry[c.id]; exists || seen[c.id] {
+			return 0, fmt.Errorf("invalid custom checks %s: check %s: a check with this id is already registered", path, c.id)
+		}
+		seen[c.id] = true
+		compiled = append(compiled, c)
+	}
+	for _, c := range compiled {
+		Register(c)
+	}
+	return len(compiled), nil
+}
+
+// compileCustom validates d, read from the file source, and compiles its rules.
+func compileCustom(d customDefinition, source string) (*customCheck, error) {
+	if !customIDPattern.MatchString(d.ID) {
+		return nil, fmt.Errorf("id %q must be lowercase letters, digits and dashes", d.ID)
+	}
+	if !report.ValidSeverity(d.Severity) {
+		return nil, fmt.Errorf("invalid severity %q (valid: 'info', 'low', 'medium', 'high', 'critical')", d.Severity)
+	}
+	switch d.Safety {
+	case "":
+		d.Safety = SafetyIntrusive
+	case SafetyPassive, SafetyIntrusive:
+	default:
+		return nil, fmt.Errorf("invalid safety %q (valid: 'passive', 'intrusive')", d.Safety)
+	}
+	if d.Request.Path != "" && !strings.HasPrefix(d.Request.Path, "/") {
+		return nil, fmt.Errorf("request path %q must start with /", d.Request.Path)
+	}
+
+	query := d.Request.Query
+	switch {
+	case query != "" && d.Request.QueryFile != "":
+		return nil, errors.New("request sets both query and query-file")
+	case d.Request.QueryFile != "":
+		file := d.Request.QueryFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(source), file)
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read query file: %w", err)
+		}
+		query = string(content)
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("request needs a query or a query-file")
+	}
+	headers, err := network.NormalizeHeaders("request headers", d.Request.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.Match) == 0 {
+		return nil, errors.New("no match rules")
+	}
+	c := &customCheck{id: d.ID, title: d.Title, description: d.Description, severity: d.Severity, safety: d.Safety,
+		path: d.Request.Path, query: query, variables: d.Request.Variables, headers: headers}
+	if c.title == "" {
+		c.title = "Custom check " + d.ID + " matched"
+	}
+	if c.description == "" {
+		c.summary = "Custom check: " + c.title
+		if d.Title == "" {
+			c.summary = "Custom check from " + filepath.Base(source)
+		}
+	}
+	for i, r := range d.Match {
+		m, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("match rule %d: %w", i+1, err)
+		}
+		c.rules = append(c.rules, m)
+	}
+	return c, nil
+}
+
+// compileRule compiles a match rule, checking that it tests one thing in one
+// way.
+func compileRule(r customRule) (matcher, error) {
+	var subjects []string
+	if r.Status != 0 {
+		subjects = append(subjects, "status")
+	}
+	if r.Path != "" {
+		subjects = append(subjects, "path")
+	}
+	if r.Header != "" {
+		subjects = append(subjects, "header")
+	}
+	if r.Latency != "" {
+		subjects = append(subjects, "latency")
+	}
+	switch len(subjects) {
+	case 0:
+		return nil, errors.New("a rule needs one of status, path, header or latency")
+	case 1:
+	default:
+		return nil, fmt.Errorf("a rule tests one of status, path, header or latency, not %s together", strings.Join(subjects, " and "))
+	}
+	if r.Path == "" && (r.Exists != nil || r.Equals != nil || r.Contain != nil) {
+		return nil, errors.New("exists, equals and contains need a path")
+	}
+	if r.Header == "" && r.Regex != "" {
+		return nil, errors.New("regex needs a header")
+	}
+
+	switch subjects[0] {
+	case "status":
+		if r.Status < 100 || r.Status > 599 {
+			return nil, fmt.Errorf("invalid status %d", r.Status)
+		}
+		return statusMatcher(r.Status), nil
+	case "latency":
+		d, err := time.ParseDuration(r.Latency)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid latency %q (expected a duration such as 2s)", r.Latency)
+		}
+		return latencyMatcher(d), nil
+	case "header":
+		if r.Regex == "" {
+			return nil, fmt.Errorf("header %s needs a regex", r.Header)
+		}
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for header %s: %w", r.Header, err)
+		}
+		return headerMatcher{name: r.Header, re: re}, nil
+	}
+
+	p, err := parseJSONPath(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	m := pathMatcher{raw: r.Path, path: p}
+	n := 0
+	if r.Exists != nil {
+		n++
+		m.test, m.exists = "exists", *r.Exists
+	}
+	if r.Equals != nil {
+		n++
+		// Round-trip through JSON so that YAML integers compare equal to the
+		// float64 numbers of decoded responses.
+		data, err := json.Marshal(r.Equals)
+		if err != nil {
+			return nil, fmt.Errorf("invalid equals value for path %s: %w", r.Path, err)
+		}
+		m.test = "equals"
+		json.Unmarshal(data, &m.value)
+	}
+	if r.Contain != nil {
+		n++
+		m.test, m.value = "contains", *r.Contain
+	}
+	if n != 1 {
+		return nil, fmt.Errorf("path %s needs exactly one of exists, equals or contains", r.Path)
+	}
+	return m, nil
+}
+
+// pathSegment is a member name or a list index of a JSON path. An index of -1
+// is the [*] wildcard.
+type pathSegment struct {
+	member string
+	index  int
+	list   bool
+}
+
+// parseJSONPath parses a dotted path with [n] indices and [*] wildcards, such
+// as data.users[0].email or errors[*].message. A leading $. is allowed.
+func parseJSONPath(path string) ([]pathSegment, error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if rest == "" {
+		return nil, fmt.Errorf("invalid JSON path %q: empty path", path)
+	}
+	var segments []pathSegment
+	for _, part := range strings.Split(rest, ".") {
+		name := part
+		var indices string
+		if i := strings.Index(part, "["); i >= 0 {
+			name, indices = part[:i], part[i:]
+		}
+		if name == "" && indices == "" {
+			return nil, fmt.Errorf("invalid JSON path %q: empty member name", path)
+		}
+		if strings.ContainsAny(name, "]* ") {
+			return nil, fmt.Errorf("invalid JSON path %q: unexpected character in member %q", path, name)
+		}
+		if name != "" {
+			segments = append(segments, pathSegment{member: name})
+		}
+		for indices != "" {
+			end := strings.Index(indices, "]")
+			if !strings.HasPrefix(indices, "[") || end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: unterminated [ in %q", path, part)
+			}
+			idx := indices[1:end]
+			indices = indices[end+1:]
+			if idx == "*" {
+				segments = append(segments, pathSegment{index: -1, list: true})
+				continue
+			}
+			n, err := strconv.Atoi(idx)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: index %q is not a number or *", path, idx)
+			}
+			segments = append(segments, pathSegment{index: n, list: true})
+		}
+	}
+	return segments, nil
+}
+
+// resolveJSONPath returns the values path points to in doc, several when it
+// holds wildcards, and none when it is not found.
+func resolveJSONPath(doc interface{}, path []pathSegment) []interface{} {
+	current := []interface{}{doc}
+	for _, s := range path {
+		var next []interface{}
+		for _, v := range current {
+			switch {
+			case !s.list:
+				if obj, ok := v.(map[string]interface{}); ok {
+					if child, ok := obj[s.member]; ok {
+						next = append(next, child)
+					}
+				}
+			case s.index < 0:
+				list, _ := v.([]interface{})
+				next = append(next, list...)
+			default:
+				if list, ok := v.([]interface{}); ok && s.index < len(list) {
+					next = append(next, list[s.index])
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// customResponse is what the rules of a custom check are evaluated against.
+// Body is nil when the response was not JSON.
+type customResponse struct {
+	Status  int
+	Header  http.Header
+	Latency time.Duration
+	Body    map[string]interface{}
+}
+
+// matcher is a compiled match rule. It returns whether the response satisfies
+// it and what was observed, for the evidence.
+type matcher interface {
+	match(r *customResponse) (bool, string)
+}
+
+type statusMatcher int
+
+func (m statusMatcher) match(r *customResponse) (bool, string) {
+	return r.Status == int(m), fmt.Sprintf("status %d (expected %d)", r.Status, int(m))
+}
+
+type latencyMatcher time.Duration
+
+func (m latencyMatcher) match(r *customResponse) (bool, string) {
+	return r.Latency >= time.Duration(m), fmt.Sprintf("latency %s (threshold %s)", r.Latency.Round(time.Millisecond), time.Duration(m))
+}
+
+type headerMatcher struct {
+	name string
+	re   *regexp.Regexp
+}
+
+func (m headerMatcher) match(r *customResponse) (bool, string) {
+	for _, v := range r.Header.Values(m.name) {
+		if m.re.MatchString(v) {
+			return true, fmt.Sprintf("header %s: %s matches %s", m.name, v, m.re)
+		}
+	}
+	return false, fmt.Sprintf("header %s does not match %s", m.name, m.re)
+}
+
+type pathMatcher struct {
+	raw    string
+	path   []pathSegment
+	test   string
+	exists bool
+	value  interface{}
+}
+
+func (m pathMatcher) match(r *customResponse) (bool, string) {
+	var values []interface{}
+	if r.Body != nil {
+		values = resolveJSONPath(r.Body, m.path)
+	}
+	switch m.test {
+	case "exists":
+		found := len(values) > 0
+		if found {
+			return found == m.exists, fmt.Sprintf("%s exists: %s", m.raw, renderJSON(values[0]))
+		}
+		return found == m.exists, m.raw + " does not exist"
+	case "equals":
+		for _, v := range values {
+			if reflect.DeepEqual(v, m.value) {
+				return true, fmt.Sprintf("%s equals %s", m.raw, renderJSON(v))
+			}
+		}
+	case "contains":
+		want := m.value.(string)
+		for _, v := range values {
+			if contains(v, want) {
+				return true, fmt.Sprintf("%s contains %q: %s", m.raw, want, renderJSON(v))
+			}
+		}
+	}
+	if len(values) == 0 {
+		return false, m.raw + " does not exist"
+	}
+	return false, fmt.Sprintf("%s %s %s is not satisfied by %s", m.raw, m.test, renderJSON(m.value), renderJSON(values[0]))
+}
+
+// contains reports whether v is a string holding want, or a list with a
+// string element holding it.
+func contains(v interface{}, want string) bool {
+	switch val := v.(type) {
+	case string:
+		return strings.Contains(val, want)
+	case []interface{}:
+		for _, e := range val {
+			if s, ok := e.(string); ok && strings.Contains(s, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderJSON renders v for evidence, shortened to 120 bytes.
+func renderJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(data) > 120 {
+		return string(data[:120]) + "..."
+	}
+	return string(data)
+}
+
+// customCheck runs the request of a custom check and reports its finding when
+// the response satisfies every rule.
+type customCheck struct {
+	id          string
+	title       string
+	description string
+	// summary describes checks declared without a description in listings.
+	summary   string
+	severity  string
+	safety    string
+	path      string
+	query     string
+	variables map[string]interface{}
+	headers   map[string]string
+	rules     []matcher
+}
+
+func (c *customCheck) ID() string { return c.id }
+
+func (c *customCheck) Description() string {
+	if c.description != "" {
+		return c.description
+	}
+	return c.summary
+}
+
+func (c *customCheck) Severity() string { return c.severity }
+
+func (c *customCheck) Safety() string { return c.safety }
+
+func (c *customCheck) Requires() Requirement {
+	return RequiresNetwork | RequiresArbitraryQueries
+}
+
+func (c *customCheck) Plan(target string, deps *Deps) Plan {
+	return Plan{Requests: 1, MaxRequests: 1}
+}
+
+func (c *customCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	url := target
+	if c.path != "" {
+		url = strings.TrimSuffix(target, "/") + c.path
+	}
+	headers := make(map[string]string, len(deps.Headers)+len(c.headers))
+	for k, v := range deps.Headers {
+		headers[k] = v
+	}
+	for k, v := range c.headers {
+		headers[k] = v
+	}
+
+	var info network.ResponseInfo
+	body, err := network.SendGraphQLRequestWithContext(network.WithResponseInfo(ctx, &info), url, c.query, c.variables, headers)
+	if err != nil && info.StatusCode == 0 {
+		return nil, err
+	}
+	// A response that is not JSON can still satisfy the status, header and
+	// latency rules.
+	resp := &customResponse{Status: info.StatusCode, Header: info.Header, Latency: info.Duration, Body: body}
+
+	evidence := make([]string, 0, len(c.rules))
+	for _, m := range c.rules {
+		ok, observed := m.match(resp)
+		if !ok {
+			return nil, nil
+		}
+		evidence = append(evidence, observed)
+	}
+	description := c.description
+	if description == "" {
+		description = fmt.Sprintf("The response to the request of the custom check %s satisfied all of its %d match rules.", c.id, len(c.rules))
+	}
+	return []report.Finding{{
+		ID:          c.id,
+		Title:       c.title,
+		Severity:    c.severity,
+		Endpoint:    url,
+		Description: description,
+		Evidence:    strings.Join(evidence, "; "),
+		Request:     report.NewGraphQLRequest(url, c.query, c.variables, headers),
+	}}, nil
+}
@@ -0,0 +1,327 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// useRegistry lets the test register checks, which are dropped afterwards.
+func useRegistry(t *testing.T) {
+	t.Helper()
+	savedRegistry, savedOrder := registry, order
+	registry = make(map[string]Check, len(savedRegistry))
+	for id, c := range savedRegistry {
+		registry[id] = c
+	}
+	order = append([]string(nil), savedOrder...)
+	t.Cleanup(func() { registry, order = savedRegistry, savedOrder })
+}
+
+// rule compiles the match rule of a YAML snippet.
+func rule(t *testing.T, snippet string) matcher {
+	t.Helper()
+	var r customRule
+	if err := yaml.Unmarshal([]byte(snippet), &r); err != nil {
+		t.Fatal(err)
+	}
+	m, err := compileRule(r)
+	if err != nil {
+		t.Fatalf("compileRule(%s) = %v", snippet, err)
+	}
+	return m
+}
+
+// TestCustomMatchers evaluates each kind of rule against a response it
+// satisfies and one it does not.
+func TestCustomMatchers(t *testing.T) {
+	var body map[string]interface{}
+	json.Unmarshal([]byte(`{"data":{"users":[{"email":"a@example.com","roles":["admin","staff"],"age":42},{"email":"b@example.com","roles":[]}],"debug":true},"errors":[{"message":"Cannot query field \"secret\""}]}`), &body)
+	resp := &customResponse{
+		Status:  200,
+		Header:  http.Header{"X-Debug": {"off", "enabled; level=2"}},
+		Latency: 1500 * time.Millisecond,
+		Body:    body,
+	}
+	tests := []struct {
+		name, rule string
+		match      bool
+		observed   string
+	}{
+		{"status", "status: 200", true, "status 200 (expected 200)"},
+		{"other status", "status: 403", false, "status 200 (expected 403)"},
+		{"latency", "latency: 1s", true, "latency 1.5s (threshold 1s)"},
+		{"latency under the threshold", "latency: 2s", false, "latency 1.5s (threshold 2s)"},
+		{"header", "{header: x-debug, regex: '^enabled'}", true, "header x-debug: enabled; level=2 matches ^enabled"},
+		{"missing header", "{header: X-Powered-By, regex: Express}", false, "header X-Powered-By does not match Express"},
+		{"exists", "{path: data.debug, exists: true}", true, "data.debug exists: true"},
+		{"does not exist", "{path: data.admin, exists: false}", true, "data.admin does not exist"},
+		{"exists against", "{path: $.data.debug, exists: false}", false, "$.data.debug exists: true"},
+		// YAML decodes 42 as an int, the response as a float64.
+		{"equals a number", "{path: 'data.users[0].age', equals: 42}", true, "data.users[0].age equals 42"},
+		{"equals with a wildcard", "{path: 'data.users[*].email', equals: b@example.com}", true, `data.users[*].email equals "b@example.com"`},
+		{"equals another value", "{path: data.debug, equals: false}", false, "data.debug equals false is not satisfied by true"},
+		{"equals a missing path", "{path: 'data.users[5].email', equals: x}", false, "data.users[5].email does not exist"},
+		{"contains in a string", "{path: 'errors[*].message', contains: Cannot query}", true, `errors[*].message contains "Cannot query": "Cannot query field \"secret\""`},
+		{"contains in a list", "{path: 'data.users[0].roles', contains: admin}", true, `data.users[0].roles contains "admin": ["admin","staff"]`},
+		{"contains nothing", "{path: 'data.users[1].roles', contains: admin}", false, `data.users[1].roles contains "admin" is not satisfied by []`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, observed := rule(t, tt.rule).match(resp)
+			if match != tt.match || observed != tt.observed {
+				t.Errorf("match = %v, %q; want %v, %q", match, observed, tt.match, tt.observed)
+			}
+		})
+	}
+
+	// Only status, header and latency rules apply to responses that are
+	// not JSON.
+	html := &customResponse{Status: 404, Header: http.Header{"Content-Type": {"text/html"}}}
+	if ok, _ := rule(t, "status: 404").match(html); !ok {
+		t.Error("a status rule did not match a response without a JSON body")
+	}
+	if ok, observed := rule(t, "{path: data, exists: true}").match(html); ok || observed != "data does not exist" {
+		t.Errorf("a path rule on a response without a JSON body = %v, %q", ok, observed)
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	got, err := parseJSONPath("$.data.users[0][*].email")
+	want := []pathSegment{{member: "data"}, {member: "users"}, {index: 0, list: true}, {index: -1, list: true}, {member: "email"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseJSONPath() = %+v, %v; want %+v", got, err, want)
+	}
+	for path, want := range map[string]string{
+		"$":                 "empty path",
+		"data..email":       "empty member name",
+		"data.us*ers":       "unexpected character",
+		"data.users[0":      "unterminated [",
+		"data.users[first]": `index "first" is not a number or *`,
+		"data.users[-1]":    `index "-1" is not a number or *`,
+	} {
+		if _, err := parseJSONPath(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseJSONPath(%q) = %v, want %q", path, err, want)
+		}
+	}
+}
+
+// writeCustom writes a custom checks file named name into dir.
+func writeCustom(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCustomErrors(t *testing.T) {
+	useRegistry(t)
+	dir := t.TempDir()
+	check := func(rules string) string {
+		return "checks:\n  - id: probe\n    severity: low\n    request: {query: '{ __typename }'}\n    match:\n" + rules
+	}
+	for _, tt := range []struct{ content, want string }{
+		{"checks: []\n", "no checks"},
+		{"checks:\n  - id: probe\n    severity: low\n    colour: red\n", "field colour not found"},
+		{"checks:\n  - id: Probe_1\n    severity: low\n", `check Probe_1: id "Probe_1" must be lowercase`},
+		{"checks:\n  - id: probe\n    severity: urgent\n", `invalid severity "urgent"`},
+		{"checks:\n  - id: probe\n    severity: low\n    safety: gentle\n", `invalid safety "gentle"`},
+		{"checks:\n  - severity: low\n", `check #1: id ""`},
+		{"checks:\n  - id: probe\n    severity: low\n    request: {path: admin, query: '{ a }'}\n    match: [{status: 200}]\n", `request path "admin" must start with /`},
+		{"checks:\n  - id: probe\n    severity: low\n    request: {query: '{ a }', query-file: a.graphql}\n    match: [{status: 200}]\n", "both query and query-file"},
+		{"checks:\n  - id: probe\n    severity: low\n    request: {query-file: missing.graphql}\n    match: [{status: 200}]\n", "failed to read query file"},
+		{"checks:\n  - id: probe\n    severity: low\n    match: [{status: 200}]\n", "needs a query or a query-file"},
+		{check("      []\n"), "no match rules"},
+		{check("      - {status: 200, latency: 1s}\n"), "match rule 1: a rule tests one of status, path, header or latency, not status and latency together"},
+		{check("      - {status: 200}\n      - {}\n"), "match rule 2: a rule needs one of"},
+		{check("      - {status: 99}\n"), "invalid status 99"},
+		{check("      - {latency: soon}\n"), `invalid latency "soon"`},
+		{check("      - {header: Server}\n"), "header Server needs a regex"},
+		{check("      - {header: Server, regex: '('}\n"), "invalid regex for header Server"},
+		{check("      - {status: 200, regex: nginx}\n"), "regex needs a header"},
+		{check("      - {status: 200, exists: true}\n"), "exists, equals and contains need a path"},
+		{check("      - {path: data}\n"), "path data needs exactly one of exists, equals or contains"},
+		{check("      - {path: data, exists: true, contains: a}\n"), "needs exactly one of"},
+		{check("      - {path: 'data[x]', exists: true}\n"), "invalid JSON path"},
+		// Ids may not collide with built-in checks or with each other.
+		{"checks:\n  - id: batching\n    severity: low\n    request: {query: '{ a }'}\n    match: [{status: 200}]\n", "check batching: a check with this id is already registered"},
+		{"checks:\n  - {id: twice, severity: low, request: {query: '{ a }'}, match: [{status: 200}]}\n  - {id: twice, severity: low, request: {query: '{ a }'}, match: [{status: 200}]}\n", "check twice: a check with this id is already registered"},
+	} {
+		if _, err := LoadCustom(writeCustom(t, dir, "checks.yaml", tt.content)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadCustom(%q) = %v, want %q", tt.content, err, tt.want)
+		}
+	}
+	if _, err := LoadCustom(writeCustom(t, dir, "checks.toml", "")); err == nil || !strings.Contains(err.Error(), "unsupported custom checks format: .toml") {
+		t.Errorf("LoadCustom(.toml) = %v", err)
+	}
+	if _, err := LoadCustom(writeCustom(t, dir, "checks.json", `{"checks":[{"id":"probe","severity":"low","extra":1}]}`)); err == nil || !strings.Contains(err.Error(), `unknown field "extra"`) {
+		t.Errorf("LoadCustom(.json) = %v", err)
+	}
+	if _, ok := Lookup("probe"); ok {
+		t.Error("a check of an invalid file was registered")
+	}
+}
+
+// customServer serves a debug endpoint at /admin/graphql that matches every
+// rule of customChecks, and 404 pages elsewhere. It returns the URL and the
+// requests it received.
+func customServer(t *testing.T) (string, func() []*http.Request) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		req := r.Clone(context.Background())
+		req.Form = map[string][]string{"query": {body.Query}}
+		if v, err := json.Marshal(body.Variables); err == nil {
+			req.Form["variables"] = []string{string(v)}
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		if r.URL.Path != "/admin/graphql" {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<h1>Not Found</h1>"))
+			return
+		}
+		time.Sleep(30 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Debug-Token", "dbg-7f3a")
+		w.Write([]byte(`{"data":{"debug":{"enabled":true,"env":"staging","users":[{"email":"a@example.com"},{"email":"root@example.com"}]}}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, func() []*http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*http.Request(nil), requests...)
+	}
+}
+
+// customChecks declares a check using every kind of rule, one whose rules the
+// server does not satisfy and one matching its 404 pages.
+const customChecks = `checks:
+  - id: debug-endpoint
+    title: Debug endpoint exposed
+    severity: high
+    safety: passive
+    request:
+      path: /admin/graphql
+      query-file: queries/debug.graphql
+      variables: {verbose: true}
+      headers: {x-tenant: acme}
+    match:
+      - status: 200
+      - {header: x-debug-token, regex: '^dbg-'}
+      - latency: 20ms
+      - {path: data.debug.enabled, exists: true}
+      - {path: data.debug.env, equals: staging}
+      - {path: 'data.debug.users[*].email', contains: root@}
+  - id: debug-production
+    severity: critical
+    request: {path: /admin/graphql, query: '{ debug { env } }'}
+    match:
+      - {path: data.debug.env, equals: production}
+  - id: missing-graphql
+    severity: info
+    request: {query: '{ __typename }'}
+    match:
+      - status: 404
+      - {header: Content-Type, regex: html}
+`
+
+func TestCustomCheckAgainstMock(t *testing.T) {
+	useRegistry(t)
+	url, requests := customServer(t)
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "queries"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeCustom(t, dir, "queries/debug.graphql", "query Debug($verbose: Boolean) { debug(verbose: $verbose) { enabled env users { email } } }")
+	n, err := LoadCustom(writeCustom(t, dir, "checks.yaml", customChecks))
+	if err != nil || n != 3 {
+		t.Fatalf("LoadCustom() = %d, %v", n, err)
+	}
+	if ids := ids(All()); strings.Join(ids[len(ids)-3:], ",") != "debug-endpoint,debug-production,missing-graphql" {
+		t.Errorf("checks %q, want the custom ones registered last", ids)
+	}
+
+	c, ok := Lookup("debug-endpoint")
+	if !ok {
+		t.Fatal("debug-endpoint is not registered")
+	}
+	if Safety(c) != SafetyPassive || c.Severity() != "high" || c.Description() != "Custom check: Debug endpoint exposed" {
+		t.Errorf("debug-endpoint: safety %s, severity %s, description %q", Safety(c), c.Severity(), c.Description())
+	}
+	deps := &Deps{Headers: map[string]string{"Authorization": "Bearer t0k", "X-Tenant": "default"}}
+	findings, err := c.Run(context.Background(), url+"/", deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("%d findings, want 1", len(findings))
+	}
+	f := findings[0]
+	if f.ID != "debug-endpoint" || f.Title != "Debug endpoint exposed" || f.Severity != "high" || f.Endpoint != url+"/admin/graphql" {
+		t.Errorf("finding = %+v", f)
+	}
+	for _, want := range []string{
+		"status 200 (expected 200)",
+		"header x-debug-token: dbg-7f3a matches ^dbg-",
+		"(threshold 20ms)",
+		"data.debug.enabled exists: true",
+		`data.debug.env equals "staging"`,
+		`data.debug.users[*].email contains "root@": "root@example.com"`,
+	} {
+		if !strings.Contains(f.Evidence, want) {
+			t.Errorf("evidence %q lacks %q", f.Evidence, want)
+		}
+	}
+	if f.Request == nil || f.Request.URL != f.Endpoint || f.Request.Headers["X-Tenant"] != "acme" || !strings.Contains(f.Request.Body, `"verbose":true`) {
+		t.Errorf("finding request = %+v, want the request sent", f.Request)
+	}
+
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("%d requests, want 1", len(got))
+	}
+	r := got[0]
+	if r.URL.Path != "/admin/graphql" || !strings.HasPrefix(r.Form.Get("query"), "query Debug(") || r.Form.Get("variables") != `{"verbose":true}` {
+		t.Errorf("the server received %s with %v", r.URL.Path, r.Form)
+	}
+	// The headers of the check win over those of the run.
+	if r.Header.Get("Authorization") != "Bearer t0k" || r.Header.Get("X-Tenant") != "acme" {
+		t.Errorf("the server received the headers %v", r.Header)
+	}
+
+	// A rule the response does not satisfy reports nothing.
+	c, _ = Lookup("debug-production")
+	if findings, err := c.Run(context.Background(), url, deps); err != nil || len(findings) != 0 {
+		t.Errorf("debug-production = %+v, %v; want no finding", findings, err)
+	}
+	if Safety(c) != SafetyIntrusive || c.Description() != "Custom check from checks.yaml" {
+		t.Errorf("debug-production: safety %s, description %q", Safety(c), c.Description())
+	}
+	// Status and header rules match a page that is not JSON.
+	c, _ = Lookup("missing-graphql")
+	findings, err = c.Run(context.Background(), url, deps)
+	if err != nil || len(findings) != 1 || findings[0].Endpoint != url {
+		t.Errorf("missing-graphql = %+v, %v; want the 404 page matched", findings, err)
+	}
+}
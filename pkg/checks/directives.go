@@ -0,0 +1,149 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/schema/metadata"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+func init() {
+	Register(appliedDirectivesCheck{})
+}
+
+// appliedDirectivesCheck reads the directives applied to the fields of the
+// introspected schema through the introspection extensions of the
+// fingerprinted engines, and rebuilds the catalog with them. It needs both the
+// introspection and the engine checks to have run first.
+type appliedDirectivesCheck struct{}
+
+func (appliedDirectivesCheck) ID() string { return "applied-directives" }
+
+func (appliedDirectivesCheck) Description() string {
+	return "Reads the directives applied to schema fields through engine introspection extensions such as appliedDirectives and Hasura permission metadata"
+}
+
+func (appliedDirectivesCheck) Severity() string { return report.SeverityInfo }
+
+func (appliedDirectivesCheck) Safety() string { return SafetyPassive }
+
+func (appliedDirectivesCheck) Requires() Requirement {
+	return RequiresNetwork | RequiresSchema | RequiresEngines | RequiresArbitraryQueries
+}
+
+func (appliedDirectivesCheck) Plan(target string, deps *Deps) Plan {
+	if deps.Schema == nil || deps.Engines == nil {
+		return Plan{Deferred: true, Note: "one per metadata extractor of the fingerprinted engines"}
+	}
+	n := len(metadata.For(engineNames(deps)))
+	return Plan{Requests: n, MaxRequests: n}
+}
+
+func (c appliedDirectivesCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	if deps.Schema == nil {
+		logger.Debug("→ No introspected schema for %s, skipping the applied directive extraction", target)
+		return nil, nil
+	}
+	engines := engineNames(deps)
+	if len(metadata.For(engines)) == 0 {
+		logger.Debug("→ No metadata extractor for the engines of %s, skipping the applied directive extraction", target)
+		return nil, nil
+	}
+
+	annotated := 0
+	var used []string
+	for _, r := range metadata.Enrich(ctx, target, deps.Headers, deps.Schema, engines) {
+		if r.Err != nil {
+			logger.Info("WARNING: %s: %s extractor: %v", target, r.Extractor, r.Err)
+			continue
+		}
+		logger.Info("%s extractor annotated %d fields of %s", r.Extractor, r.Fields, target)
+		if r.Fields > 0 {
+			annotated += r.Fields
+			used = append(used, r.Extractor)
+		}
+	}
+	if annotated == 0 {
+		return nil, nil
+	}
+
+	deps.Catalog = schema.BuildCatalog(deps.Schema, schema.CatalogOptions{MaxDepth: deps.MaxDepth, Selection: deps.Selection, Notes: deps.Notes, Sample: deps.Sample})
+	if deps.OutputFile != "" {
+		catalogName := artifacts.Resolve(target, introspection.CatalogFileName(deps.OutputFile, target))
+		if err := schema.WriteCatalog(deps.Catalog, catalogName); err != nil {
+			logger.Error("Error writing operation catalog: %v", err)
+		} else {
+			logger.Info("Operation catalog with applied directives saved to %s", catalogName)
+		}
+		sdlName := artifacts.Resolve(target, introspection.SDLFileName(deps.OutputFile, target))
+		if err := schema.WriteSDL(deps.Schema, sdlName); err != nil {
+			logger.Error("Error writing schema SDL: %v", err)
+		} else {
+			logger.Info("Schema SDL with applied directives saved to %s", sdlName)
+		}
+	}
+
+	evidence := authSurfaceEvidence(deps.Catalog.AuthSurface)
+	if evidence == "" {
+		evidence = strings.Join(guardedFields(deps.Schema), "; ")
+	}
+	if evidence == "" {
+		evidence = fmt.Sprintf("directives applied to %d fields below the root types", annotated)
+	}
+	return []report.Finding{{
+		ID:          "applied-directives-exposed",
+		Title:       "Applied directives are exposed through introspection",
+		Severity:    c.Severity(),
+		Endpoint:    target,
+		Description: fmt.Sprintf("The server answers an introspection extension (%s) returning the directives applied to %d fields. Directives such as @auth or @requiresScopes describe the authorization rules of the schema, which tells an attacker which fields to target.", strings.Join(used, ", "), annotated),
+		Evidence:    evidence,
+	}}, nil
+}
+
+// engineNames returns the names of the engines fingerprinted on the target.
+func engineNames(deps *Deps) []string {
+	names := make([]string, len(deps.Engines))
+	for i, m := range deps.Engines {
+		names[i] = m.Engine
+	}
+	return names
+}
+
+// authSurfaceEvidence renders the auth surface of the catalog as
+// "guard: operations" groups.
+func authSurfaceEvidence(surface []schema.AuthGroup) string {
+	groups := make([]string, len(surface))
+	for i, g := range surface {
+		groups[i] = g.Guard + ": " + strings.Join(g.Operations, ", ")
+	}
+	return strings.Join(groups, "; ")
+}
+
+// guardedFields lists the root fields of s carrying applied directives, as
+// "Query.field @directive", in schema order.
+func guardedFields(s *types.GQLSchema) []string {
+	var fields []string
+	for _, root := range []*types.Type{s.Query, s.Mutation, s.Subscription} {
+		if root == nil {
+			continue
+		}
+		for _, f := range root.Fields {
+			if len(f.AppliedDirectives) == 0 {
+				continue
+			}
+			rendered := make([]string, len(f.AppliedDirectives))
+			for i, d := range f.AppliedDirectives {
+				rendered[i] = d.String()
+			}
+			fields = append(fields, root.Name+"."+f.Name+" "+strings.Join(rendered, " "))
+		}
+	}
+	return fields
+}
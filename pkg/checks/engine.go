@@ -0,0 +1,73 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/fingerprint"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+func init() {
+	Register(engineCheck{})
+}
+
+// engineCheck fingerprints the GraphQL implementations answering on the endpoint.
+type engineCheck struct{}
+
+func (engineCheck) ID() string { return "engine" }
+
+func (engineCheck) Description() string {
+	return "Fingerprints the GraphQL server implementations from their error signatures"
+}
+
+func (engineCheck) Severity() string { return report.SeverityInfo }
+
+func (engineCheck) Safety() string { return SafetyPassive }
+
+func (engineCheck) Provides() Requirement { return RequiresEngines }
+
+func (engineCheck) Plan(target string, deps *Deps) Plan {
+	min, max := fingerprint.PlanRequests()
+	return Plan{Requests: min, MaxRequests: max, Note: "more when version pages of matched engines are read"}
+}
+
+func (c engineCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	logger.Info("Fingerprinting the GraphQL engine on %s...", target)
+	matches, err := fingerprint.DetectEngineWithContext(ctx, target, deps.Headers)
+	if err != nil && len(matches) == 0 {
+		return nil, err
+	}
+	deps.Components = fingerprint.DetectComponentsWithContext(ctx, target, deps.Headers)
+	for _, c := range deps.Components {
+		logger.Info("Component on %s: %s", target, c)
+	}
+	if len(matches) == 0 {
+		logger.Info("No known GraphQL engine recognised on %s", target)
+		return nil, nil
+	}
+
+	deps.Engines = matches
+	names := make([]string, len(matches))
+	evidence := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.Engine
+		evidence[i] = m.String()
+		logger.Info("Engine on %s: %s", target, m)
+	}
+
+	description := fmt.Sprintf("The endpoint is most likely served by %s.", matches[0].Engine)
+	if len(matches) > 1 {
+		description = fmt.Sprintf("Signatures of %d engines were found (%s), which usually means a gateway or proxy sits in front of the server.", len(matches), strings.Join(names, ", "))
+	}
+	return []report.Finding{{
+		ID:          "engine-fingerprint",
+		Title:       "GraphQL engine identified",
+		Severity:    c.Severity(),
+		Endpoint:    target,
+		Description: description,
+		Evidence:    strings.Join(evidence, "; "),
+	}}, nil
+}
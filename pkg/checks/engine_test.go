@@ -0,0 +1,45 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEngineReportsEveryMatch(t *testing.T) {
+	// Apollo Router in front of Hasura.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case r.Method == http.MethodGet:
+			http.NotFound(w, r)
+		case strings.HasPrefix(req.Query, "queryy"):
+			w.Write([]byte(`{"errors":[{"message":"parsing error: syntax error","extensions":{"code":"PARSING_ERROR"}}]}`))
+		default:
+			w.Write([]byte(`{"data":{"__typename":"query_root"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	deps := &Deps{}
+	findings, err := engineCheck{}.Run(context.Background(), srv.URL, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || !strings.Contains(findings[0].Description, "2 engines were found (Apollo Router, Hasura)") {
+		t.Fatalf("findings = %+v, want one listing both engines", findings)
+	}
+	if ev := findings[0].Evidence; !strings.Contains(ev, "Apollo Router (90%") || !strings.Contains(ev, "Hasura (60%") {
+		t.Errorf("evidence %q lacks a match", ev)
+	}
+	if len(deps.Engines) != 2 || deps.Engines[0].Engine != "Apollo Router" {
+		t.Errorf("deps.Engines = %v, want both matches for the checks that follow", deps.Engines)
+	}
+}
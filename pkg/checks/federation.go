@@ -0,0 +1,102 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/attacks"
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+func init() {
+	Register(federationCheck{})
+}
+
+// federationCheck looks for Apollo Federation subgraph fields reachable from the client.
+type federationCheck struct{}
+
+func (federationCheck) ID() string { return "federation" }
+
+func (federationCheck) Description() string {
+	return "Pulls the federation SDL through _service and checks whether _entities resolves arbitrary representations"
+}
+
+func (federationCheck) Severity() string { return report.SeverityHigh }
+
+func (federationCheck) Safety() string { return SafetyPassive }
+
+func (federationCheck) Requires() Requirement { return RequiresNetwork | RequiresArbitraryQueries }
+
+func (federationCheck) Plan(target string, deps *Deps) Plan {
+	return Plan{Requests: 1, MaxRequests: 1, Note: "plus one _entities probe per entity key when the SDL is exposed"}
+}
+
+func (c federationCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	logger.Info("Checking for federation support on %s...", target)
+	result, err := attacks.ProbeFederation(ctx, target, deps.Headers)
+	if err != nil && result == nil {
+		return nil, err
+	}
+	if result == nil {
+		logger.Info("No federation _service field on %s", target)
+		return nil, nil
+	}
+	if err != nil {
+		logger.Warn("Federation probe on %s incomplete: %v", target, err)
+	}
+
+	var entityNames []string
+	for _, e := range result.Entities {
+		entityNames = append(entityNames, fmt.Sprintf("%s @key(%s)", e.Type, strings.Join(e.Keys, " | ")))
+	}
+	serviceFinding := report.Finding{
+		ID:          "federation-sdl-exposed",
+		Title:       "Federation subgraph SDL is exposed through _service",
+		Severity:    report.SeverityMedium,
+		Endpoint:    target,
+		Description: fmt.Sprintf("The _service field returns the subgraph SDL, declaring %d entity type(s), even when introspection is disabled.", len(result.Entities)),
+		Evidence:    strings.Join(entityNames, ", "),
+		Request:     report.NewGraphQLRequest(target, attacks.ServiceQuery, nil, deps.Headers),
+	}
+
+	if deps.OutputFile != "" {
+		outName := artifacts.Claim(target, filepath.Join(filepath.Dir(deps.OutputFile), "federation_"+introspection.EndpointSuffix(target)+".graphql"))
+		if err := artifacts.WriteFile(outName, []byte(result.SDL), 0644); err != nil {
+			logger.Error("Error writing federation SDL to file: %v", err)
+		} else {
+			logger.Info("Federation SDL saved to %s", outName)
+			if serviceFinding.Evidence != "" {
+				serviceFinding.Evidence += "; "
+			}
+			serviceFinding.Evidence += "SDL saved to " + outName
+		}
+	}
+	findings := []report.Finding{serviceFinding}
+
+	for _, p := range result.Probes {
+		if p.Error != "" {
+			logger.Debug("→ _entities probe for %s failed: %v", p.Type, p.Error)
+			continue
+		}
+		if !p.Resolved {
+			continue
+		}
+		logger.Warn("WARNING: _entities resolves %s from a forged representation on %s", p.Type, target)
+		variables := map[string]interface{}{"representations": []interface{}{p.Representation}}
+		findings = append(findings, report.Finding{
+			ID:          "federation-entities-direct-access",
+			Title:       "Federation _entities can be queried directly",
+			Severity:    c.Severity(),
+			Endpoint:    target,
+			Description: fmt.Sprintf("_entities resolved %s from a client-supplied representation, bypassing the authorization applied on the gateway's root fields.", p.Type),
+			Evidence:    "representation " + attacks.RepresentationJSON(p.Representation),
+			Request:     report.NewGraphQLRequest(target, attacks.EntitiesQuery, variables, deps.Headers),
+		})
+	}
+	return findings, nil
+}
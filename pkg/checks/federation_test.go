@@ -0,0 +1,63 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const federationSDL = `type Query { me: User }
+type User @key(fields: "id") { id: ID! }
+type Invoice @key(fields: "number") { number: String! }`
+
+func TestFederationReportsEntitiesAccess(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string `json:"query"`
+			Variables struct {
+				Representations []map[string]string `json:"representations"`
+			} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(req.Query, "_service") {
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"_service": map[string]string{"sdl": federationSDL}}})
+			return
+		}
+		// Invoices are resolved for anyone; users need a session.
+		if typeName := req.Variables.Representations[0]["__typename"]; typeName == "Invoice" {
+			w.Write([]byte(`{"data":{"_entities":[{"__typename":"Invoice"}]}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"_entities":[null]},"errors":[{"message":"Unauthorized"}]}`))
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "report.json")
+	findings, err := federationCheck{}.Run(context.Background(), srv.URL+"/graphql", &Deps{OutputFile: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("findings = %+v, want the SDL exposure and one _entities access", findings)
+	}
+	if f := findings[0]; f.ID != "federation-sdl-exposed" || !strings.Contains(f.Evidence, `User @key(id), Invoice @key(number)`) {
+		t.Errorf("SDL finding = %+v", f)
+	}
+	if f := findings[1]; f.ID != "federation-entities-direct-access" || !strings.Contains(f.Description, "Invoice") || f.Request == nil {
+		t.Errorf("_entities finding = %+v", f)
+	}
+
+	artifact := filepath.Join(filepath.Dir(out), "federation_graphql.graphql")
+	if sdl, err := os.ReadFile(artifact); err != nil || string(sdl) != federationSDL {
+		t.Errorf("artifact %s = %q, %v; want the SDL", artifact, sdl, err)
+	}
+	if !strings.Contains(findings[0].Evidence, "SDL saved to "+artifact) {
+		t.Errorf("evidence %q does not name the artifact", findings[0].Evidence)
+	}
+}
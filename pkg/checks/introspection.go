@@ -0,0 +1,288 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+func init() {
+	Register(introspectionCheck{})
+}
+
+// introspectionCheck verifies whether the full introspection query is answered
+// and saves the schema when it is.
+type introspectionCheck struct{}
+
+func (introspectionCheck) ID() string { return "introspection" }
+
+func (introspectionCheck) Description() string {
+	return "Checks whether the introspection query is enabled and dumps the schema"
+}
+
+func (introspectionCheck) Severity() string { return report.SeverityMedium }
+
+func (introspectionCheck) Safety() string { return SafetyPassive }
+
+func (introspectionCheck) Requires() Requirement { return RequiresNetwork | RequiresArbitraryQueries }
+
+func (introspectionCheck) Provides() Requirement { return RequiresSchema }
+
+func (introspectionCheck) Plan(target string, deps *Deps) Plan {
+	if deps.IntrospectionFile != "" {
+		return Plan{Note: "uses the saved introspection result"}
+	}
+	opts := introspection.ProbeOptions{Chunked: deps.ChunkedIntrospection, ChunkSize: deps.IntrospectionChunkSize}
+	min, max := introspection.PlanRequests(opts)
+	note := "more when the full query is refused: reductions, then lower tiers"
+	if deps.ChunkedIntrospection {
+		note = "type list plus one request per batch of types"
+	}
+	return Plan{Requests: min, MaxRequests: max, Note: note}
+}
+
+func (c introspectionCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	if deps.IntrospectionFile != "" {
+		logger.Info("Using the introspection result saved in %s for %s", deps.IntrospectionFile, target)
+		return nil, nil
+	}
+	logger.Info("Checking if introspection is enabled on %s...", target)
+	outName := ""
+	if deps.OutputFile != "" {
+		outName = artifacts.Resolve(target, introspection.OutputFileName(deps.OutputFile, target))
+	}
+	var previous string
+	if deps.Schemas != nil {
+		previous, _ = deps.Schemas.Schema(target)
+	}
+	tiers, etag, err := fetchTiers(ctx, target, deps, outName)
+	if err != nil {
+		if banner := introspection.ConsentBanner(nil, err); banner != "" {
+			return consentFindings(target, banner, introspection.IntrospectionQuery, deps), nil
+		}
+		if gerrors.IsNotGraphQL(err) {
+			logger.Warn("The endpoint %s doesn't appear to be a valid GraphQL endpoint: %v", target, err)
+			logger.Info("This may be a false positive or the endpoint requires special headers/authentication")
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	deps.IntrospectionTier = tiers.Level
+	consent := consentFindings(target, tiers.Banner, tiers.Results[0].Query, deps)
+	switch {
+	case tiers.Level.Partial():
+		logger.Warn("WARNING: Introspection is PARTIALLY enabled on %s (%s)", target, tiers.Level)
+		logger.Info("Suggested fallback: %s", tiers.Level.Suggestion())
+		return append(consent, report.Finding{
+			ID:          "introspection-partial",
+			Title:       "GraphQL introspection is partially enabled",
+			Severity:    report.SeverityLow,
+			Endpoint:    target,
+			Description: fmt.Sprintf("introspection: partial — %s. The full introspection query is rejected, but parts of the schema can still be read. %s", tiers.Level, tiers.Level.Suggestion()),
+			Evidence:    tierEvidence(tiers),
+			Request:     report.NewGraphQLRequest(target, tiers.Results[len(tiers.Results)-1].Query, nil, deps.Headers),
+		}), nil
+	case tiers.Level != introspection.TierFull:
+		logger.Info("Introspection appears to be disabled on %s (%s)", target, tiers.Level)
+		if suggestion := tiers.Level.Suggestion(); suggestion != "" {
+			logger.Info("Suggested fallback: %s", suggestion)
+		}
+		return consent, nil
+	}
+
+	result := tiers.Full
+	deps.Introspection = result
+	logger.Warn("WARNING: Introspection is ENABLED on %s!", target)
+	if deps.Schemas != nil {
+		hash := introspection.SchemaHash(result)
+		deps.SchemaUnchanged = previous != "" && hash == previous
+		deps.Schemas.SetSchema(target, hash, etag)
+		if deps.SchemaUnchanged {
+			logger.Info("%s: schema unchanged (hash %s)", target, hash)
+		}
+	}
+
+	finding := report.Finding{
+		ID:          "introspection-enabled",
+		Title:       "GraphQL introspection is enabled",
+		Severity:    c.Severity(),
+		Endpoint:    target,
+		Description: "The endpoint answers the full introspection query, exposing the complete schema.",
+		Request:     report.NewGraphQLRequest(target, tiers.Results[0].Query, nil, deps.Headers),
+	}
+
+	switch {
+	case outName != "" && deps.SchemaUnchanged && exists(outName):
+		finding.Evidence = "schema saved to " + outName
+	case outName != "":
+		outName = artifacts.Claim(target, introspection.OutputFileName(deps.OutputFile, target))
+		dump, redacted := result, 0
+		if deps.RedactArtifacts {
+			dump, redacted = redact.Map(result)
+		}
+		if err := introspection.WriteIntrospectionToFile(dump, outName); err != nil {
+			logger.Error("Error writing introspection result to file: %v", err)
+		} else {
+			logger.Info("Introspection data saved to %s", outName)
+			finding.Evidence = "schema saved to " + outName
+			if redacted > 0 {
+				logger.Info("Redacted %d sensitive values in %s", redacted, outName)
+				finding.Evidence += fmt.Sprintf(" (%d values redacted)", redacted)
+			}
+		}
+	}
+
+	if s, err := schema.LoadFromIntrospection(result); err != nil {
+		logger.Debug("→ Not building operation catalog for %s: %v", target, err)
+	} else {
+		deps.Schema = s
+		deps.Catalog = schema.BuildCatalog(s, schema.CatalogOptions{MaxDepth: deps.MaxDepth, Selection: deps.Selection, Notes: deps.Notes, Sample: deps.Sample})
+		if deps.Sample.Size > 0 {
+			logger.Info("Catalog of %s sampled to %d operations with the %s strategy", target, len(deps.Catalog.Operations), deps.Sample.Strategy)
+		}
+		for _, w := range deps.Notes.Unknown(s) {
+			logger.Info("WARNING: %s: %s", target, w)
+		}
+		catalogName := ""
+		if deps.OutputFile != "" {
+			catalogName = artifacts.Claim(target, introspection.CatalogFileName(deps.OutputFile, target))
+		}
+		switch {
+		case catalogName == "":
+		case deps.SchemaUnchanged && exists(catalogName):
+			// Written from the same schema by an earlier run.
+		default:
+			if err := schema.WriteCatalog(deps.Catalog, catalogName); err != nil {
+				logger.Error("Error writing operation catalog: %v", err)
+			} else {
+				logger.Info("Operation catalog saved to %s", catalogName)
+			}
+		}
+	}
+
+	findings := append(consent, finding)
+	if len(tiers.Reductions) > 0 {
+		logger.Info("Introspection of %s needed reductions: %s", target, strings.Join(tiers.Reductions, ", "))
+		findings = append(findings, report.Finding{
+			ID:          "introspection-reduced",
+			Title:       "Full introspection query answered only after reductions",
+			Severity:    report.SeverityInfo,
+			Endpoint:    target,
+			Description: "The server rejected parts of the canonical introspection query but answered it once they were removed. The saved schema is marked partial and may lack the removed parts, such as deprecated fields, directives or deeply wrapped types.",
+			Evidence:    "reductions: " + strings.Join(tiers.Reductions, ", "),
+			Request:     report.NewGraphQLRequest(target, tiers.Results[0].Query, nil, deps.Headers),
+		})
+	}
+	return findings, nil
+}
+
+// consentFindings reports the terms-of-use or consent notice banner that
+// target answered query with, if any. Such notices often set conditions on
+// testing, such as required headers or request rates, which a bounty profile
+// enforces.
+func consentFindings(target, banner, query string, deps *Deps) []report.Finding {
+	if banner == "" {
+		return nil
+	}
+	logger.Warn("WARNING: %s answered with a terms-of-use or consent notice: %s", target, banner)
+	if network.RateLimit() == 0 {
+		logger.Info("The notice may set conditions on testing: encode them in a --profile-bounty profile, which caps the rate and sets the required headers")
+	}
+	return []report.Finding{{
+		ID:          "consent-banner",
+		Title:       "The endpoint answers with a terms-of-use or consent notice",
+		Severity:    report.SeverityInfo,
+		Endpoint:    target,
+		Description: "The introspection probes were answered with a notice asking to accept terms of use or to give consent. Such notices often set conditions on automated testing, such as required headers, request rates or forbidden techniques; review them before scanning further.",
+		Evidence:    "notice: " + banner,
+		Request:     report.NewGraphQLRequest(target, query, nil, deps.Headers),
+	}}
+}
+
+// fetchTiers probes the introspection tiers of target. With deps.Schemas it
+// also returns the ETag of the full query, which is made conditional on the
+// recorded one when the dump at outName can stand in for an unchanged schema.
+func fetchTiers(ctx context.Context, target string, deps *Deps, outName string) (*introspection.TierReport, string, error) {
+	opts := introspection.ProbeOptions{
+		Chunked:   deps.ChunkedIntrospection,
+		ChunkSize: deps.IntrospectionChunkSize,
+	}
+	if deps.Schemas == nil {
+		tiers, err := introspection.ProbeTiers(ctx, target, deps.Headers, opts)
+		return tiers, "", err
+	}
+	_, recorded := deps.Schemas.Schema(target)
+	opts.Validators = &network.Validators{}
+	// A redacted dump is not the schema the server would have sent.
+	if recorded != "" && outName != "" && !deps.RedactArtifacts && exists(outName) {
+		opts.Validators.IfNoneMatch = recorded
+	}
+	tiers, err := introspection.ProbeTiers(ctx, target, deps.Headers, opts)
+	if !errors.Is(err, gerrors.ErrNotModified) {
+		return tiers, opts.Validators.ETag, err
+	}
+	saved, loadErr := loadDump(outName)
+	if loadErr == nil {
+		logger.Info("Introspection result of %s not modified (ETag %s), using %s", target, recorded, outName)
+		return &introspection.TierReport{
+			Level:   introspection.TierFull,
+			Full:    saved,
+			Results: []introspection.TierResult{{Tier: introspection.TierFull, Query: introspection.IntrospectionQuery, Accessible: true}},
+		}, recorded, nil
+	}
+	logger.Info("WARNING: Introspection result of %s not modified but %v; fetching it again", target, loadErr)
+	opts.Validators = &network.Validators{}
+	tiers, err = introspection.ProbeTiers(ctx, target, deps.Headers, opts)
+	return tiers, opts.Validators.ETag, err
+}
+
+// loadDump reads an introspection result saved by WriteIntrospectionToFile.
+func loadDump(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, fmt.Errorf("%s is not an introspection result: %w", path, err)
+	}
+	if !introspection.IsIntrospectionEnabled(result) {
+		return nil, fmt.Errorf("%s holds no schema", path)
+	}
+	return result, nil
+}
+
+// exists reports whether a file is at path.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// tierEvidence lists the outcome of each probed tier.
+func tierEvidence(tiers *introspection.TierReport) string {
+	var parts []string
+	for _, r := range tiers.Results {
+		outcome := "accessible"
+		if !r.Accessible {
+			outcome = "blocked"
+			if r.Error != "" {
+				outcome += " (" + r.Error + ")"
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", r.Tier, outcome))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,285 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
+)
+
+func TestIntrospectionReportsConsentBanner(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><p>By accessing this API you agree to the Acme testing rules.</p></body></html>`))
+	}))
+	defer srv.Close()
+
+	findings, err := introspectionCheck{}.Run(context.Background(), srv.URL, &Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].ID != "consent-banner" || findings[0].Evidence != "notice: By accessing this API you agree to the Acme testing rules." {
+		t.Fatalf("findings = %+v", findings)
+	}
+	if findings[0].Request == nil || findings[0].Endpoint != srv.URL {
+		t.Errorf("finding lacks its request: %+v", findings[0])
+	}
+}
+
+func TestIntrospectionReportsReductions(t *testing.T) {
+	// The server rejects the directives block of the canonical query.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(req.Query, "directives {") {
+			w.Write([]byte(`{"errors":[{"message":"Cannot query field 'directives' on type '__Schema'."}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"__schema":{"queryType":{"name":"Query"},"types":[{"kind":"OBJECT","name":"Query","fields":[{"name":"hello","args":[],"type":{"kind":"SCALAR","name":"String"}}]},{"kind":"SCALAR","name":"String"}]}}}`))
+	}))
+	defer srv.Close()
+
+	deps := &Deps{}
+	findings, err := introspectionCheck{}.Run(context.Background(), srv.URL, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reduced *report.Finding
+	for i := range findings {
+		if findings[i].ID == "introspection-reduced" {
+			reduced = &findings[i]
+		}
+	}
+	if reduced == nil || reduced.Severity != report.SeverityInfo || reduced.Evidence != "reductions: no-directives" {
+		t.Fatalf("findings = %+v, want an informational introspection-reduced finding", findings)
+	}
+	if reduced.Request == nil || strings.Contains(reduced.Request.Body, "directives") {
+		t.Errorf("the finding does not carry the reduced query: %+v", reduced.Request)
+	}
+	if deps.Schema == nil || deps.Schema.Query == nil {
+		t.Error("the reduced schema was not handed to the checks that follow")
+	}
+}
+
+// schemaServer answers the introspection query with its types, listed in
+// the order they are set. With an etag it sends it and answers 304 to a
+// request carrying it in If-None-Match. It records the If-None-Match header of
+// every introspection query.
+type schemaServer struct {
+	mu          sync.Mutex
+	types       []string
+	etag        string
+	ifNoneMatch []string
+}
+
+func (s *schemaServer) set(etag string, types ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.etag, s.types = etag, types
+}
+
+// sent returns the If-None-Match headers received since the last call.
+func (s *schemaServer) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := s.ifNoneMatch
+	s.ifNoneMatch = nil
+	return sent
+}
+
+func (s *schemaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query string `json:"query"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !strings.Contains(req.Query, "__schema") {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+		return
+	}
+	s.ifNoneMatch = append(s.ifNoneMatch, r.Header.Get("If-None-Match"))
+	if s.etag != "" {
+		w.Header().Set("ETag", s.etag)
+		if r.Header.Get("If-None-Match") == s.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	types := []string{`{"kind":"OBJECT","name":"Query","fields":[{"name":"hello","args":[],"type":{"kind":"SCALAR","name":"String"}}]}`}
+	for _, name := range s.types {
+		types = append(types, `{"kind":"SCALAR","name":"`+name+`"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"data":{"__schema":{"queryType":{"name":"Query"},"types":[` + strings.Join(types, ",") + `]}}}`))
+}
+
+// watchIteration runs the introspection check and a check reading only the
+// schema on target, as one iteration of a watch does, and records the
+// findings in state. It returns the deps and the results of the run.
+func watchIteration(t *testing.T, state *workspace.WatchState, target, outputFile string, ran *[]string) (*Deps, []report.CheckResult) {
+	t.Helper()
+	secrets := depCheck{fakeCheck: fakeCheck{id: "secrets", ran: ran, findings: []report.Finding{{ID: "secret", Check: "secrets", Endpoint: target, Title: "A secret in the schema", Severity: report.SeverityLow}}}, requires: RequiresSchema}
+	ctl, ctx := NewController(context.Background(), Policy{})
+	deps := &Deps{OutputFile: outputFile, Schemas: state}
+	findings, results := Run(ctx, ctl, []Check{introspectionCheck{}, secrets}, target, deps)
+	if _, _, err := state.Record(findings); err != nil {
+		t.Fatal(err)
+	}
+	return deps, results
+}
+
+// TestIntrospectionSchemaChanges runs the introspection check through the
+// iterations of a watch, against a schema that stays the same, changes, and
+// is then served with an ETag.
+func TestIntrospectionSchemaChanges(t *testing.T) {
+	server := &schemaServer{}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "introspection.json")
+	dump := introspection.OutputFileName(outputFile, srv.URL)
+	state, err := workspace.LoadWatchState(filepath.Join(dir, "watch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran []string
+	// age backdates the dump and reports later whether it was rewritten.
+	age := func() func() bool {
+		old := time.Now().Add(-time.Hour).Truncate(time.Second)
+		if err := os.Chtimes(dump, old, old); err != nil {
+			t.Fatal(err)
+		}
+		return func() bool {
+			info, err := os.Stat(dump)
+			return err == nil && !info.ModTime().Equal(old)
+		}
+	}
+	secretsResult := func(results []report.CheckResult) report.CheckResult {
+		for _, r := range results {
+			if r.Check == "secrets" {
+				return r
+			}
+		}
+		return report.CheckResult{}
+	}
+
+	server.set("", "String", "Boolean")
+	deps, results := watchIteration(t, state, srv.URL, outputFile, &ran)
+	first, _ := state.Schema(srv.URL)
+	if deps.SchemaUnchanged || first == "" || !reflect.DeepEqual(ran, []string{"secrets"}) {
+		t.Fatalf("first iteration: unchanged %v, hash %q, ran %v", deps.SchemaUnchanged, first, ran)
+	}
+	server.sent()
+
+	t.Run("unchanged", func(t *testing.T) {
+		// The same schema listed in another order.
+		server.set("", "Boolean", "String")
+		rewritten := age()
+		ran = nil
+		deps, results = watchIteration(t, state, srv.URL, outputFile, &ran)
+		if hash, _ := state.Schema(srv.URL); !deps.SchemaUnchanged || hash != first {
+			t.Errorf("unchanged %v, hash %q; want the recorded %q", deps.SchemaUnchanged, hash, first)
+		}
+		if rewritten() {
+			t.Error("the dump of an unchanged schema was rewritten")
+		}
+		if ran != nil {
+			t.Errorf("ran %v again on an unchanged schema", ran)
+		}
+		if r := secretsResult(results); r.Status != report.StatusFound || r.Reason != "schema unchanged" {
+			t.Errorf("secrets result = %+v, want its previous finding reported", r)
+		}
+		if found := state.CheckFindings("secrets", srv.URL); len(found) != 1 {
+			t.Errorf("the previous finding was not recorded again: %+v", found)
+		}
+		if sent := server.sent(); len(sent) != 1 || sent[0] != "" {
+			t.Errorf("If-None-Match %q, want none without an ETag", sent)
+		}
+	})
+
+	var changed string
+	t.Run("changed", func(t *testing.T) {
+		server.set("", "Boolean", "String", "ID")
+		rewritten := age()
+		ran = nil
+		deps, _ = watchIteration(t, state, srv.URL, outputFile, &ran)
+		changed, _ = state.Schema(srv.URL)
+		if deps.SchemaUnchanged || changed == first || changed == "" {
+			t.Errorf("unchanged %v, hash %q after %q; want a new hash", deps.SchemaUnchanged, changed, first)
+		}
+		if !rewritten() {
+			t.Error("the dump of a changed schema was not rewritten")
+		}
+		if !reflect.DeepEqual(ran, []string{"secrets"}) {
+			t.Errorf("ran %v, want the schema checks run again", ran)
+		}
+		server.sent()
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		server.set(`"v3"`, "Boolean", "String", "ID")
+		watchIteration(t, state, srv.URL, outputFile, &ran)
+		if hash, etag := state.Schema(srv.URL); hash != changed || etag != `"v3"` {
+			t.Fatalf("recorded %q %q, want the ETag of the same schema", hash, etag)
+		}
+		server.sent()
+
+		// The server answers 304 and the schema is read from the dump.
+		rewritten := age()
+		ran = nil
+		deps, _ = watchIteration(t, state, srv.URL, outputFile, &ran)
+		if sent := server.sent(); !reflect.DeepEqual(sent, []string{`"v3"`}) {
+			t.Errorf("If-None-Match %q, want the recorded ETag", sent)
+		}
+		if !deps.SchemaUnchanged || deps.Schema == nil || ran != nil || rewritten() {
+			t.Errorf("unchanged %v, ran %v, dump rewritten %v; want the schema of the dump", deps.SchemaUnchanged, ran, rewritten())
+		}
+		if _, ok := deps.Schema.Types["ID"]; !ok {
+			t.Error("the schema read from the dump lacks the ID type")
+		}
+		if hash, etag := state.Schema(srv.URL); hash != changed || etag != `"v3"` {
+			t.Errorf("recorded %q %q after a 304", hash, etag)
+		}
+
+		// A dump holding no schema cannot stand in for the 304, so the query
+		// is sent again unconditionally.
+		if err := os.WriteFile(dump, []byte(`{"data":null}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		deps, _ = watchIteration(t, state, srv.URL, outputFile, &ran)
+		if sent := server.sent(); !reflect.DeepEqual(sent, []string{`"v3"`, ""}) {
+			t.Errorf("If-None-Match %q, want the conditional query then the plain one", sent)
+		}
+		if !deps.SchemaUnchanged || deps.Schema == nil {
+			t.Errorf("unchanged %v, want the refetched schema recognized", deps.SchemaUnchanged)
+		}
+
+		// Without a dump the query is not made conditional at all.
+		if err := os.Remove(dump); err != nil {
+			t.Fatal(err)
+		}
+		deps, _ = watchIteration(t, state, srv.URL, outputFile, &ran)
+		if sent := server.sent(); !reflect.DeepEqual(sent, []string{""}) {
+			t.Errorf("If-None-Match %q without a dump, want none", sent)
+		}
+		if _, err := os.Stat(dump); err != nil {
+			t.Errorf("the dump was not written again: %v", err)
+		}
+	})
+}
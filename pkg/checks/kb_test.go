@@ -0,0 +1,150 @@
+package checks
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/report/kb"
+)
+
+// findingSources are the packages whose code reports findings: the checks,
+// the audits run outside the registry and the WebSocket weaknesses of the
+// ws-protocol check.
+var findingSources = []string{".", "../cli", "../subscription", "../.."}
+
+// findingIDs returns the finding ids set in the code of dir: the ID of a
+// report.Finding literal, an assignment to the ID of a finding and the first
+// element of a subscription.Weakness literal. An id built from a literal
+// prefix, such as "schema-secret-" + name, is returned as the prefix followed
+// by "*". The ids map to the position they were found at.
+func findingIDs(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	fset := token.NewFileSet()
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]string)
+	add := func(e ast.Expr) {
+		id := ""
+		switch e := e.(type) {
+		case *ast.BasicLit:
+			id, _ = strconv.Unquote(e.Value)
+		case *ast.BinaryExpr:
+			if lit, ok := e.X.(*ast.BasicLit); ok && e.Op == token.ADD {
+				prefix, _ := strconv.Unquote(lit.Value)
+				id = prefix + "*"
+			}
+		}
+		if id != "" {
+			ids[id] = fset.Position(e.Pos()).String()
+		}
+	}
+	addFinding := func(lit *ast.CompositeLit) {
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok && isIdent(kv.Key, "ID") {
+				add(kv.Value)
+			}
+		}
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CompositeLit:
+				switch typeName(n.Type) {
+				case "Finding":
+					addFinding(n)
+				case "[]Finding":
+					// The elements of a []report.Finding literal elide their type.
+					for _, elt := range n.Elts {
+						if lit, ok := elt.(*ast.CompositeLit); ok && lit.Type == nil {
+							addFinding(lit)
+						}
+					}
+				case "Weakness":
+					if len(n.Elts) > 0 {
+						add(n.Elts[0])
+					}
+				}
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					if sel, ok := lhs.(*ast.SelectorExpr); ok && sel.Sel.Name == "ID" && i < len(n.Rhs) && isFinding(sel.X) {
+						add(n.Rhs[i])
+					}
+				}
+			}
+			return true
+		})
+	}
+	return ids
+}
+
+// typeName returns the name of the type of a composite literal, without its
+// package, such as Finding or []Finding.
+func typeName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + typeName(e.Elt)
+	}
+	return ""
+}
+
+// isIdent reports whether e is the identifier name.
+func isIdent(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+// isFinding reports whether e names a finding by the variable names the code
+// uses for them.
+func isFinding(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && (id.Name == "f" || id.Name == "finding")
+}
+
+func TestFindingIDsHaveKBEntries(t *testing.T) {
+	all := make(map[string]string)
+	for _, dir := range findingSources {
+		for id, pos := range findingIDs(t, dir) {
+			all[id] = pos
+		}
+	}
+	// A check that stopped being found would make the test vacuous.
+	for _, id := range []string{"introspection-enabled", "batching-allowed", "csrf-get-queries", "ws-pre-ack-operation", "schema-secret-*", "variable-coercion-crash"} {
+		if _, ok := all[id]; !ok {
+			t.Errorf("finding %s is not found in the sources", id)
+		}
+	}
+
+	ids := make([]string, 0, len(all))
+	for id := range all {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		lookup := id
+		if prefix, ok := strings.CutSuffix(id, "*"); ok {
+			lookup = prefix + "example"
+		}
+		if _, ok := kb.Lookup(lookup); !ok {
+			t.Errorf("finding %s (%s) has no knowledge base entry", id, all[id])
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+func init() {
+	Register(contentNegotiationCheck{})
+}
+
+// Media types of GraphQL responses
+const (
+	mediaJSON            = "application/json"
+	mediaGraphQLResponse = "application/graphql-response+json"
+)
+
+// Probes of the content-negotiation check. The invalid query selects a field
+// no schema has, a request error that the GraphQL over HTTP specification
+// answers with a 4xx status under application/graphql-response+json.
+const (
+	negotiationValid   = `{ __typename }`
+	negotiationInvalid = `{ gsNegotiationProbe }`
+)
+
+// negotiationAccepts are the Accept headers each probe is sent with.
+var negotiationAccepts = []string{
+	mediaJSON,
+	mediaGraphQLResponse,
+	mediaGraphQLResponse + ", " + mediaJSON,
+}
+
+// Values of types.Capabilities.ResponseMedia
+const (
+	ResponseMediaLegacy       = "legacy"
+	ResponseMediaCompliant    = "spec-compliant"
+	ResponseMediaInconsistent = "inconsistent"
+)
+
+// contentNegotiationCheck tells whether an endpoint follows the
+// application/graphql-response+json media type of the GraphQL over HTTP
+// specification, under which request errors take a 4xx status instead of
+// 200. Clients, proxies and WAF rules that decide on the status code need to
+// know which of the two conventions the endpoint uses, and one mixing them is
+// read wrongly by both.
+type contentNegotiationCheck struct{}
+
+func (contentNegotiationCheck) ID() string { return "content-negotiation" }
+
+func (contentNegotiationCheck) Description() string {
+	return "Checks the status codes and content types of responses against the Accept header (application/graphql-response+json)"
+}
+
+func (contentNegotiationCheck) Severity() string { return report.SeverityLow }
+
+func (contentNegotiationCheck) Safety() string { return SafetyPassive }
+
+func (contentNegotiationCheck) Requires() Requirement {
+	return RequiresNetwork | RequiresArbitraryQueries
+}
+
+func (contentNegotiationCheck) Plan(target string, deps *Deps) Plan {
+	n := 2 * len(negotiationAccepts)
+	return Plan{Requests: n, MaxRequests: n}
+}
+
+func (c contentNegotiationCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	logger.Info("Probing %s for content negotiation...", target)
+	var probes []types.NegotiationProbe
+	var lastErr error
+	for _, accept := range negotiationAccepts {
+		for _, valid := range []bool{true, false} {
+			query := negotiationInvalid
+			if valid {
+				query = negotiationValid
+			}
+			d, err := network.ProbeDelivery(ctx, target, query, accept, deps.Headers)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, err
+				}
+				logger.Debug("→ Negotiation probe on %s (Accept: %s) failed: %v", target, accept, err)
+				lastErr = err
+				continue
+			}
+			probes = append(probes, types.NegotiationProbe{Accept: accept, Valid: valid, Status: d.Status, ContentType: d.ContentType})
+		}
+	}
+	if len(probes) == 0 {
+		return nil, lastErr
+	}
+
+	media, issues := classifyNegotiation(probes)
+	caps := deps.capabilities(target)
+	caps.ResponseMedia = media
+	caps.Negotiation = probes
+	logger.Info("Response media types of %s: %s", target, media)
+	if media != ResponseMediaInconsistent {
+		return nil, nil
+	}
+	for _, issue := range issues {
+		logger.Info("WARNING: %s %s", target, issue)
+	}
+	return []report.Finding{{
+		ID:          "graphql-response-inconsistent",
+		Title:       "Status codes do not match the response media type",
+		Severity:    c.Severity(),
+		Endpoint:    target,
+		Description: "The endpoint answers with application/graphql-response+json but does not follow its status code semantics, or picks the media type differently for valid and failing queries: " + strings.Join(issues, "; ") + ".",
+		Evidence:    negotiationEvidence(probes),
+		Request:     deliveryRequest(target, negotiationInvalid, mediaGraphQLResponse, deps.Headers),
+	}}, nil
+}
+
+// classifyNegotiation classifies the responses of an endpoint. It is legacy
+// when it never answers with application/graphql-response+json, and
+// spec-compliant when it does so only when asked, with a 4xx status for the
+// failing query and a 2xx one for the valid query. It is inconsistent
+// otherwise, for the reasons returned.
+func classifyNegotiation(probes []types.NegotiationProbe) (string, []string) {
+	var issues []string
+	seen := make(map[string]bool)
+	addIssue := func(format string, args ...interface{}) {
+		if issue := fmt.Sprintf(format, args...); !seen[issue] {
+			seen[issue] = true
+			issues = append(issues, issue)
+		}
+	}
+	usesNew := false
+	mediaOf := make(map[string]map[bool]string)
+	for _, p := range probes {
+		media := mediaType(p.ContentType)
+		if mediaOf[p.Accept] == nil {
+			mediaOf[p.Accept] = make(map[bool]string)
+		}
+		mediaOf[p.Accept][p.Valid] = media
+		if media != mediaGraphQLResponse {
+			continue
+		}
+		usesNew = true
+		success := p.Status >= 200 && p.Status < 300
+		switch {
+		case p.Accept == mediaJSON:
+			addIssue("answers %s to Accept: %s", mediaGraphQLResponse, mediaJSON)
+		case !p.Valid && success:
+			addIssue("answers a query failing validation with HTTP %d and %s (Accept: %s), which requires a 4xx status", p.Status, mediaGraphQLResponse, p.Accept)
+		case p.Valid && !success:
+			addIssue("answers a valid query with HTTP %d and %s (Accept: %s)", p.Status, mediaGraphQLResponse, p.Accept)
+		}
+	}
+	if !usesNew {
+		return ResponseMediaLegacy, nil
+	}
+	for _, accept := range negotiationAccepts {
+		valid, invalid := mediaOf[accept][true], mediaOf[accept][false]
+		if valid != "" && invalid != "" && valid != invalid {
+			addIssue("answers a valid query with %s and a failing one with %s (Accept: %s)", valid, invalid, accept)
+		}
+	}
+	if len(issues) > 0 {
+		return ResponseMediaInconsistent, issues
+	}
+	return ResponseMediaCompliant, nil
+}
+
+// mediaType returns the media type of contentType, without its parameters.
+func mediaType(contentType string) string {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return media
+}
+
+// negotiationEvidence lists the status and content type of each probe.
+func negotiationEvidence(probes []types.NegotiationProbe) string {
+	lines := make([]string, 0, len(probes))
+	for _, p := range probes {
+		query := "failing query"
+		if p.Valid {
+			query = "valid query"
+		}
+		contentType := p.ContentType
+		if contentType == "" {
+			contentType = "no Content-Type"
+		}
+		lines = append(lines, fmt.Sprintf("Accept %s, %s: HTTP %d %s", p.Accept, query, p.Status, contentType))
+	}
+	return strings.Join(lines, "; ")
+}
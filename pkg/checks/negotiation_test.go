@@ -0,0 +1,193 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// negotiationServer answers the probes of the content-negotiation check the
+// way one profile of server does:
+//
+//   - "legacy" answers application/json and 200 whatever the query and the
+//     Accept header.
+//   - "compliant" answers application/graphql-response+json when the client
+//     accepts it, with 400 for the failing query, and application/json
+//     otherwise.
+//   - "errors-200" uses the new media type when asked but answers the
+//     failing query with 200.
+//   - "mixed" uses the new media type for valid queries only.
+//   - "ignores-accept" answers the new media type even to Accept:
+//     application/json.
+func negotiationServer(t *testing.T, profile string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		failing := req.Query == negotiationInvalid
+		media := mediaJSON
+		if strings.Contains(r.Header.Get("Accept"), mediaGraphQLResponse) || profile == "ignores-accept" {
+			media = mediaGraphQLResponse
+		}
+		status := http.StatusOK
+		switch profile {
+		case "legacy":
+			media = mediaJSON
+		case "compliant", "ignores-accept":
+			if failing && media == mediaGraphQLResponse {
+				status = http.StatusBadRequest
+			}
+		case "mixed":
+			if failing {
+				media = mediaJSON
+			}
+		}
+		w.Header().Set("Content-Type", media+"; charset=utf-8")
+		w.WriteHeader(status)
+		if failing {
+			w.Write([]byte(`{"errors":[{"message":"Cannot query field \"gsNegotiationProbe\" on type \"Query\"."}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestContentNegotiation(t *testing.T) {
+	tests := []struct {
+		profile string
+		media   string
+		issues  []string
+	}{
+		{profile: "legacy", media: ResponseMediaLegacy},
+		{profile: "compliant", media: ResponseMediaCompliant},
+		{
+			profile: "errors-200",
+			media:   ResponseMediaInconsistent,
+			issues: []string{
+				"answers a query failing validation with HTTP 200 and application/graphql-response+json (Accept: application/graphql-response+json), which requires a 4xx status",
+				"answers a query failing validation with HTTP 200 and application/graphql-response+json (Accept: application/graphql-response+json, application/json), which requires a 4xx status",
+			},
+		},
+		{
+			profile: "mixed",
+			media:   ResponseMediaInconsistent,
+			issues: []string{
+				"answers a valid query with application/graphql-response+json and a failing one with application/json (Accept: application/graphql-response+json)",
+				"answers a valid query with application/graphql-response+json and a failing one with application/json (Accept: application/graphql-response+json, application/json)",
+			},
+		},
+		{
+			profile: "ignores-accept",
+			media:   ResponseMediaInconsistent,
+			issues:  []string{"answers application/graphql-response+json to Accept: application/json"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			target := negotiationServer(t, tt.profile)
+			deps := &Deps{}
+			findings, err := contentNegotiationCheck{}.Run(context.Background(), target, deps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			caps := deps.Capabilities
+			if caps == nil || caps.Endpoint != target || caps.ResponseMedia != tt.media {
+				t.Fatalf("capabilities = %+v, want response media %s", caps, tt.media)
+			}
+			if len(caps.Negotiation) != 2*len(negotiationAccepts) {
+				t.Errorf("%d probes recorded, want %d", len(caps.Negotiation), 2*len(negotiationAccepts))
+			}
+			for _, p := range caps.Negotiation {
+				if !strings.HasSuffix(p.ContentType, "; charset=utf-8") || p.Status == 0 {
+					t.Errorf("probe %+v", p)
+				}
+			}
+			if tt.issues == nil {
+				if len(findings) > 0 {
+					t.Errorf("findings %+v, want none", findings)
+				}
+				return
+			}
+			if len(findings) != 1 {
+				t.Fatalf("%d findings, want 1: %+v", len(findings), findings)
+			}
+			f := findings[0]
+			if f.ID != "graphql-response-inconsistent" || f.Severity != report.SeverityLow || f.Endpoint != target {
+				t.Errorf("finding %+v", f)
+			}
+			if want := strings.Join(tt.issues, "; ") + "."; !strings.HasSuffix(f.Description, ": "+want) {
+				t.Errorf("description %q, want the issues\n%s", f.Description, want)
+			}
+			if f.Evidence != negotiationEvidence(caps.Negotiation) {
+				t.Errorf("evidence %q", f.Evidence)
+			}
+			if f.Request == nil || f.Request.Headers["Accept"] != mediaGraphQLResponse {
+				t.Errorf("request evidence %+v", f.Request)
+			}
+		})
+	}
+}
+
+// TestContentNegotiationProbes checks the probes recorded for a compliant
+// endpoint, in the order they are sent.
+func TestContentNegotiationProbes(t *testing.T) {
+	deps := &Deps{}
+	if _, err := (contentNegotiationCheck{}).Run(context.Background(), negotiationServer(t, "compliant"), deps); err != nil {
+		t.Fatal(err)
+	}
+	const plain, graphql = mediaJSON + "; charset=utf-8", mediaGraphQLResponse + "; charset=utf-8"
+	both := mediaGraphQLResponse + ", " + mediaJSON
+	want := []types.NegotiationProbe{
+		{Accept: mediaJSON, Valid: true, Status: 200, ContentType: plain},
+		{Accept: mediaJSON, Valid: false, Status: 200, ContentType: plain},
+		{Accept: mediaGraphQLResponse, Valid: true, Status: 200, ContentType: graphql},
+		{Accept: mediaGraphQLResponse, Valid: false, Status: 400, ContentType: graphql},
+		{Accept: both, Valid: true, Status: 200, ContentType: graphql},
+		{Accept: both, Valid: false, Status: 400, ContentType: graphql},
+	}
+	if !reflect.DeepEqual(deps.Capabilities.Negotiation, want) {
+		t.Errorf("probes\n%+v\nwant\n%+v", deps.Capabilities.Negotiation, want)
+	}
+}
+
+// TestContentNegotiationSharesCapabilities runs transport-features and
+// content-negotiation on one endpoint: their results end up in the same
+// capabilities.
+func TestContentNegotiationSharesCapabilities(t *testing.T) {
+	target := transportServer(t, "inline")
+	deps := &Deps{}
+	if _, err := (transportFeaturesCheck{}).Run(context.Background(), target, deps); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (contentNegotiationCheck{}).Run(context.Background(), target, deps); err != nil {
+		t.Fatal(err)
+	}
+	caps := deps.Capabilities
+	if caps == nil || !caps.Defer || caps.DeferDelivery != "json" || caps.ResponseMedia != ResponseMediaLegacy || len(caps.Negotiation) == 0 {
+		t.Errorf("capabilities = %+v, want those of both checks", caps)
+	}
+}
+
+func TestMediaType(t *testing.T) {
+	for _, tt := range []struct{ contentType, want string }{
+		{"application/json", mediaJSON},
+		{"Application/GraphQL-Response+JSON; charset=utf-8", mediaGraphQLResponse},
+		{" text/html ", "text/html"},
+		{"", ""},
+	} {
+		if got := mediaType(tt.contentType); got != tt.want {
+			t.Errorf("mediaType(%q) = %q, want %q", tt.contentType, got, tt.want)
+		}
+	}
+}
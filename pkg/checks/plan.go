@@ -0,0 +1,36 @@
+package checks
+
+// Plan describes the requests a check intends to send to one target.
+type Plan struct {
+	// Requests is the number sent when the target answers as the check expects.
+	Requests int
+	// MaxRequests bounds the requests when answers lead to retries or further
+	// probes. It equals Requests for checks that always send the same ones.
+	MaxRequests int
+	// Note explains what the count depends on.
+	Note string
+	// Deferred means the requests depend on what earlier checks find, such as
+	// the operations of the introspected schema, and cannot be counted yet.
+	Deferred bool
+}
+
+// Planner is implemented by checks that can describe the requests they would
+// send without sending any.
+type Planner interface {
+	Plan(target string, deps *Deps) Plan
+}
+
+// PlanOf returns the plan of c for target. Checks that send no requests plan
+// none. known is false for network checks that do not implement Planner or
+// whose plan is deferred.
+func PlanOf(c Check, target string, deps *Deps) (plan Plan, known bool) {
+	if !NeedsNetwork(c) {
+		return Plan{}, true
+	}
+	p, ok := c.(Planner)
+	if !ok {
+		return Plan{}, false
+	}
+	plan = p.Plan(target, deps)
+	return plan, !plan.Deferred
+}
@@ -0,0 +1,96 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/fingerprint"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+// planSchema is a schema with String and ID query arguments.
+const planSchema = `{"data":{"__schema":{
+	"queryType":{"name":"Query"},
+	"types":[
+		{"kind":"OBJECT","name":"Query","fields":[
+			{"name":"user","args":[{"name":"id","type":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"ID"}}}],"type":{"kind":"SCALAR","name":"String"}},
+			{"name":"search","args":[{"name":"term","type":{"kind":"SCALAR","name":"String"}}],"type":{"kind":"SCALAR","name":"String"}}
+		]},
+		{"kind":"SCALAR","name":"String"},
+		{"kind":"SCALAR","name":"ID"}
+	]
+}}}`
+
+// countingServer answers every request as a GraphQL server with no schema
+// exposed and counts the requests it receives.
+func countingServer(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var n atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+// TestPlansMatchRequestsSent runs each check that plans its requests against
+// a mock server and checks that it sends as many as planned.
+func TestPlansMatchRequestsSent(t *testing.T) {
+	defer func(rates []int) { rampRates = rates }(rampRates)
+	rampRates = []int{5, 10}
+
+	s, err := schema.Parse([]byte(planSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range All() {
+		if !NeedsNetwork(c) {
+			continue
+		}
+		if _, ok := c.(Planner); !ok {
+			continue
+		}
+		t.Run(c.ID(), func(t *testing.T) {
+			srv, sent := countingServer(t)
+			deps := &Deps{Headers: map[string]string{"Content-Type": "application/json"}}
+			if _, known := PlanOf(c, srv.URL, deps); !known {
+				// Plans deferred until the schema is known are counted with one.
+				deps.Schema, deps.Engines = s, []fingerprint.EngineMatch{}
+			}
+			plan, known := PlanOf(c, srv.URL, deps)
+			if !known {
+				t.Skipf("plan deferred until the schema is known: %s", plan.Note)
+			}
+			if plan.MaxRequests < plan.Requests {
+				t.Fatalf("plan %+v bounds its requests below their count", plan)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if _, err := c.Run(ctx, srv.URL, deps); err != nil {
+				t.Logf("run: %v", err)
+			}
+			if n := int(sent.Load()); n < plan.Requests || n > plan.MaxRequests {
+				t.Errorf("sent %d request(s), planned %d-%d", n, plan.Requests, plan.MaxRequests)
+			}
+		})
+	}
+}
+
+// TestPlanOfChecksWithoutNetwork checks that checks working on the schema
+// alone plan no requests.
+func TestPlanOfChecksWithoutNetwork(t *testing.T) {
+	for _, c := range All() {
+		if NeedsNetwork(c) {
+			continue
+		}
+		if plan, known := PlanOf(c, "http://127.0.0.1:1/graphql", &Deps{}); !known || plan.Requests != 0 || plan.MaxRequests != 0 {
+			t.Errorf("%s plans %+v, known %v, want no requests", c.ID(), plan, known)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+func init() {
+	Register(rateLimitCheck{})
+}
+
+// rampRates are the request rates, in requests per second, tried in turn by the rate-limit probe.
+var rampRates = []int{5, 10, 20, 50}
+
+// rateLimitCheck ramps up the request rate until the server throttles the client.
+type rateLimitCheck struct{}
+
+func (rateLimitCheck) ID() string { return "rate-limit" }
+
+func (rateLimitCheck) Description() string {
+	return "Ramps up the request rate to estimate the rate-limiting threshold (--audit-dos)"
+}
+
+func (rateLimitCheck) Severity() string { return report.SeverityLow }
+
+func (rateLimitCheck) Safety() string { return SafetyIntrusive }
+
+func (rateLimitCheck) Group() string { return GroupDoS }
+
+// Budget leaves room for the paced ramp and the pauses servers impose with Retry-After.
+func (rateLimitCheck) Budget() time.Duration { return 3 * time.Minute }
+
+func (rateLimitCheck) Plan(target string, deps *Deps) Plan {
+	total := 0
+	for _, rate := range rampRates {
+		total += rate
+	}
+	return Plan{Requests: total, MaxRequests: total, Note: "fewer once the server throttles"}
+}
+
+func (c rateLimitCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	query := `query { __typename }`
+	sustained := 0
+	seen := countEvents(target)
+
+	for _, rate := range rampRates {
+		logger.Info("Probing %s at %d req/s...", target, rate)
+		interval := time.Second / time.Duration(rate)
+		for i := 0; i < rate; i++ {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			sent := time.Now()
+			network.SendGraphQLRequestWithContext(ctx, target, query, nil, deps.Headers)
+			if countEvents(target) > seen {
+				finding := RateLimitFinding(target, latestEvent(target))
+				finding.Description = fmt.Sprintf("Requests were throttled while ramping to %d req/s; the threshold is at most %d req/s.", rate, rate)
+				if sustained > 0 {
+					finding.Title = fmt.Sprintf("Rate limiting observed (threshold ~%d-%d req/s)", sustained, rate)
+				}
+				return []report.Finding{finding}, nil
+			}
+			if wait := interval - time.Since(sent); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		sustained = rate
+	}
+
+	return []report.Finding{{
+		ID:          "rate-limit-absent",
+		Title:       fmt.Sprintf("No rate limiting observed up to %d req/s", sustained),
+		Severity:    c.Severity(),
+		Endpoint:    target,
+		Description: "The endpoint answered every request of the ramp without throttling, which eases brute force and denial-of-service attacks.",
+	}}, nil
+}
+
+// countEvents returns the number of rate-limit events recorded for url, so
+// that throttling of other endpoints or concurrent scans is not taken for the
+// response to the probe.
+func countEvents(url string) int {
+	n := 0
+	for _, event := range network.RateLimitEvents() {
+		if event.URL == url {
+			n++
+		}
+	}
+	return n
+}
+
+// latestEvent returns the most recent rate-limit event recorded for url.
+func latestEvent(url string) network.RateLimitEvent {
+	events := network.RateLimitEvents()
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].URL == url {
+			return events[i]
+		}
+	}
+	return network.RateLimitEvent{URL: url}
+}
+
+// RateLimitFinding converts a rate-limit event observed by the network layer into a finding.
+func RateLimitFinding(endpoint string, event network.RateLimitEvent) report.Finding {
+	title := "Rate limiting observed"
+	if event.ObservedRate > 0 {
+		title = fmt.Sprintf("Rate limiting observed (threshold ~%.0f req/s)", event.ObservedRate)
+	}
+	evidence := fmt.Sprintf("HTTP %d, retry after %s", event.StatusCode, event.RetryAfter)
+	if event.Evidence != "" {
+		evidence += ": " + event.Evidence
+	}
+	return report.Finding{
+		ID:          "rate-limiting-observed",
+		Check:       "rate-limit",
+		Title:       title,
+		Severity:    report.SeverityInfo,
+		Endpoint:    endpoint,
+		Description: "The server throttled requests; GraphSpecter paused for the requested time before continuing.",
+		Evidence:    evidence,
+	}
+}
@@ -0,0 +1,47 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+func TestRateLimitIgnoresOtherEndpoints(t *testing.T) {
+	defer func(rates []int) { rampRates = rates }(rampRates)
+	rampRates = []int{20}
+	network.ResetStats()
+	defer network.ResetStats()
+
+	throttling := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"errors":[{"message":"Too many requests"}]}`))
+	}))
+	defer throttling.Close()
+	// The first probe of the target is answered once another endpoint, as
+	// in a concurrent scan, has been throttled.
+	var once sync.Once
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			network.SendGraphQLRequestWithContext(context.Background(), throttling.URL, "{ __typename }", nil, nil)
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer target.Close()
+
+	findings, err := rateLimitCheck{}.Run(context.Background(), target.URL, &Deps{Headers: map[string]string{"Content-Type": "application/json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(network.RateLimitEvents()) == 0 {
+		t.Fatal("the other endpoint was not throttled")
+	}
+	if len(findings) != 1 || findings[0].ID != "rate-limit-absent" {
+		t.Errorf("findings = %+v, want the target reported as not throttled", findings)
+	}
+}
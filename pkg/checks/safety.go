@@ -0,0 +1,98 @@
+package checks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Safety classes
+const (
+	// SafetyPassive checks send nothing, or only the benign requests any client
+	// of the endpoint sends, and read what it answers.
+	SafetyPassive = "passive"
+	// SafetyIntrusive checks send attack payloads, malformed requests or load.
+	SafetyIntrusive = "intrusive"
+)
+
+// Classified is implemented by checks declaring their safety class. Checks that
+// do not implement it are intrusive.
+type Classified interface {
+	Safety() string
+}
+
+// Safety returns the safety class of c.
+func Safety(c Check) string {
+	if s, ok := c.(Classified); ok {
+		return s.Safety()
+	}
+	return SafetyIntrusive
+}
+
+// Check presets
+const (
+	// PresetSafe keeps only the passive checks.
+	PresetSafe = "safe"
+	// PresetAggressive enables every group of opt-in checks.
+	PresetAggressive = "aggressive"
+)
+
+// Groups returns the groups of the registered opt-in checks in registration order.
+func Groups() []string {
+	var groups []string
+	seen := map[string]bool{}
+	for _, c := range All() {
+		if g, ok := c.(Grouped); ok && !seen[g.Group()] {
+			seen[g.Group()] = true
+			groups = append(groups, g.Group())
+		}
+	}
+	return groups
+}
+
+// SelectPreset resolves preset together with the --checks and --skip-checks
+// values and the enabled groups. Without a preset it is Select, and
+// PresetAggressive enables every group. PresetSafe keeps only the passive
+// checks; enabling a group or naming an intrusive check in enabled is an error
+// rather than a way to widen the preset.
+func SelectPreset(preset, enabled, skipped string, groups ...string) ([]Check, error) {
+	switch preset {
+	case "":
+		return Select(enabled, skipped, groups...)
+	case PresetAggressive:
+		return Select(enabled, skipped, Groups()...)
+	case PresetSafe:
+		if len(groups) > 0 {
+			return nil, fmt.Errorf("the %s preset runs only passive checks and cannot enable the %s group(s)", preset, strings.Join(groups, ", "))
+		}
+		var intrusive []string
+		for _, id := range ParseList(enabled) {
+			if c, ok := registry[id]; ok && Safety(c) != SafetyPassive {
+				intrusive = append(intrusive, id)
+			}
+		}
+		if len(intrusive) > 0 {
+			return nil, fmt.Errorf("the %s preset runs only passive checks and cannot run the intrusive check(s) %s", preset, strings.Join(intrusive, ", "))
+		}
+		selected, err := Select(enabled, skipped)
+		if err != nil {
+			return nil, err
+		}
+		var passive []Check
+		for _, c := range selected {
+			if Safety(c) == SafetyPassive {
+				passive = append(passive, c)
+			}
+		}
+		return passive, nil
+	}
+	return nil, fmt.Errorf("unknown check preset %q (valid: '%s', '%s')", preset, PresetSafe, PresetAggressive)
+}
+
+// IDs returns the ids of checks.
+func IDs(checks []Check) []string {
+	ids := make([]string, len(checks))
+	for i, c := range checks {
+		ids[i] = c.ID()
+	}
+	return ids
+}
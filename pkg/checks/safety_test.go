@@ -0,0 +1,74 @@
+package checks
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectPresetSafe(t *testing.T) {
+	selected, err := SelectPreset(PresetSafe, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, c := range selected {
+		got[c.ID()] = true
+		if Safety(c) != SafetyPassive {
+			t.Errorf("the safe preset runs the %s check %s", Safety(c), c.ID())
+		}
+	}
+	// Every passive check outside the opt-in groups is kept.
+	for _, c := range All() {
+		if _, grouped := c.(Grouped); !grouped && Safety(c) == SafetyPassive && !got[c.ID()] {
+			t.Errorf("the safe preset leaves out the passive check %s", c.ID())
+		}
+	}
+	if len(selected) == 0 || len(selected) == len(All()) {
+		t.Fatalf("the safe preset selects %d of %d checks", len(selected), len(All()))
+	}
+
+	// Passive checks can be picked and skipped as usual.
+	passive := IDs(selected)
+	if only, err := SelectPreset(PresetSafe, passive[0], ""); err != nil || len(only) != 1 || only[0].ID() != passive[0] {
+		t.Errorf("SelectPreset(safe, %s) = %v, %v", passive[0], IDs(only), err)
+	}
+	if rest, err := SelectPreset(PresetSafe, "", passive[0]); err != nil || len(rest) != len(selected)-1 {
+		t.Errorf("SelectPreset(safe, skip %s) = %v, %v", passive[0], IDs(rest), err)
+	}
+
+	var intrusive []string
+	for _, c := range All() {
+		if Safety(c) != SafetyPassive {
+			intrusive = append(intrusive, c.ID())
+		}
+	}
+	if len(intrusive) == 0 {
+		t.Fatal("no intrusive check is registered")
+	}
+	for _, tt := range []struct {
+		enabled string
+		groups  []string
+		want    string
+	}{
+		{enabled: passive[0] + "," + intrusive[0], want: "cannot run the intrusive check(s) " + intrusive[0]},
+		{groups: []string{GroupDoS}, want: "cannot enable the dos group(s)"},
+		{enabled: "no-such-check", want: "unknown check(s): no-such-check"},
+	} {
+		if checks, err := SelectPreset(PresetSafe, tt.enabled, "", tt.groups...); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("SelectPreset(safe, %q, %v) = %v, %v; want %q", tt.enabled, tt.groups, IDs(checks), err, tt.want)
+		}
+	}
+}
+
+func TestSelectPresetAggressive(t *testing.T) {
+	selected, err := SelectPreset(PresetAggressive, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != len(All()) {
+		t.Errorf("the aggressive preset selects %v, want every check", IDs(selected))
+	}
+	if _, err := SelectPreset("careful", "", ""); err == nil || !strings.Contains(err.Error(), `unknown check preset "careful"`) {
+		t.Errorf("SelectPreset(careful) = %v", err)
+	}
+}
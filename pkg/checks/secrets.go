@@ -0,0 +1,55 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+func init() {
+	Register(schemaSecretsCheck{})
+}
+
+// schemaSecretsCheck scans the introspected schema for secrets left in default
+// values and descriptions. It needs the introspection check to have run first.
+type schemaSecretsCheck struct{}
+
+func (schemaSecretsCheck) ID() string { return "schema-secrets" }
+
+func (schemaSecretsCheck) Description() string {
+	return "Scans argument defaults and descriptions of the introspected schema for URLs, keys and emails"
+}
+
+func (schemaSecretsCheck) Severity() string { return report.SeverityInfo }
+
+func (schemaSecretsCheck) Safety() string { return SafetyPassive }
+
+func (schemaSecretsCheck) Requires() Requirement { return RequiresSchema }
+
+func (c schemaSecretsCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	if deps.Schema == nil {
+		logger.Debug("→ No introspected schema for %s, skipping the default value scan", target)
+		return nil, nil
+	}
+	matches := schema.ScanDefaults(deps.Schema, schema.DefaultSecretPatterns)
+	if len(matches) > 0 {
+		logger.Info("Found %d secret-looking values in the schema of %s", len(matches), target)
+	}
+
+	findings := make([]report.Finding, 0, len(matches))
+	for _, m := range matches {
+		findings = append(findings, report.Finding{
+			ID:          "schema-secret-" + m.Pattern,
+			Title:       fmt.Sprintf("Secret-looking %s in %s of %s", m.Pattern, m.Source, m.Path),
+			Severity:    c.Severity(),
+			Endpoint:    target,
+			Description: "Default values and descriptions are returned to every client that can introspect the schema. Values that look like credentials, internal addresses or personal data should be removed from the schema.",
+			Evidence:    fmt.Sprintf("%s %s: %s", m.Path, m.Source, redact.Excerpt(m.Text, m.Start, m.End)),
+		})
+	}
+	return findings, nil
+}
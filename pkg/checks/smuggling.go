@@ -0,0 +1,118 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+func init() {
+	Register(parsingDifferentialCheck{})
+}
+
+// alternatePositions are the non-standard places where some servers read the query.
+var alternatePositions = []network.Strategy{
+	{Param: "query", InURL: true},
+	{Param: "q", InURL: true},
+	{Param: "q"},
+	{Param: "doc"},
+	{Param: "operations"},
+}
+
+// Probe queries. Each carries a distinct alias so the response shows which was executed.
+const (
+	standardProbe  = `{ gsStd: __typename }`
+	alternateProbe = `{ gsAlt: __typename }`
+)
+
+// parsingDifferentialCheck looks for query positions other than the JSON "query"
+// member that the server executes, and for positions that take precedence over it.
+// A gateway that only inspects one position can then be bypassed with the other.
+type parsingDifferentialCheck struct{}
+
+func (parsingDifferentialCheck) ID() string { return "parsing-differential" }
+
+func (parsingDifferentialCheck) Description() string {
+	return "Sends queries in non-standard JSON members and URL parameters to find parsing differentials"
+}
+
+func (parsingDifferentialCheck) Plan(target string, deps *Deps) Plan {
+	n := len(alternatePositions)
+	return Plan{Requests: n, MaxRequests: 2 * n, Note: "a second request per accepted position"}
+}
+
+func (parsingDifferentialCheck) Severity() string { return report.SeverityMedium }
+
+func (parsingDifferentialCheck) Safety() string { return SafetyIntrusive }
+
+func (parsingDifferentialCheck) Requires() Requirement {
+	return RequiresNetwork | RequiresArbitraryQueries
+}
+
+func (c parsingDifferentialCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	logger.Info("Probing %s for alternate query positions...", target)
+	var accepted, overriding []string
+	for _, s := range alternatePositions {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		resp, err := network.SendWithStrategy(ctx, target, s, alternateProbe, nil, deps.Headers)
+		if err != nil {
+			logger.Debug("→ Query in %s failed: %v", s, err)
+			continue
+		}
+		if !hasMarker(resp, "gsAlt") {
+			continue
+		}
+		accepted = append(accepted, s.String())
+
+		dup := s
+		dup.Decoy = standardProbe
+		resp, err = network.SendWithStrategy(ctx, target, dup, alternateProbe, nil, deps.Headers)
+		if err != nil {
+			logger.Debug("→ Duplicate query with %s failed: %v", s, err)
+			continue
+		}
+		if hasMarker(resp, "gsAlt") {
+			logger.Info("Query in %s takes precedence over body:query on %s", s, target)
+			overriding = append(overriding, s.String())
+		}
+	}
+
+	var findings []report.Finding
+	if len(overriding) > 0 {
+		findings = append(findings, report.Finding{
+			ID:          "parsing-differential",
+			Title:       "Alternate query position overrides the JSON query",
+			Severity:    c.Severity(),
+			Endpoint:    target,
+			Description: "When a request carries queries in both positions the server executes the alternate one. A gateway or WAF inspecting only the JSON \"query\" member sees a benign query while another one runs; replay with --duplicate-query.",
+			Evidence:    fmt.Sprintf("executed instead of body:query: %s", strings.Join(overriding, ", ")),
+		})
+	}
+	if len(accepted) > 0 {
+		findings = append(findings, report.Finding{
+			ID:          "alternate-query-position",
+			Title:       "Queries accepted outside the JSON query member",
+			Severity:    report.SeverityLow,
+			Endpoint:    target,
+			Description: "The server executes queries sent in non-standard positions. Filtering that only inspects the standard position may be bypassed with --param-name.",
+			Evidence:    fmt.Sprintf("accepted positions: %s", strings.Join(accepted, ", ")),
+		})
+	}
+	if len(findings) == 0 {
+		logger.Info("No alternate query positions accepted by %s", target)
+	}
+	return findings, nil
+}
+
+// hasMarker reports whether the data of resp contains the marker alias.
+func hasMarker(resp map[string]interface{}, marker string) bool {
+	data, _ := resp["data"].(map[string]interface{})
+	_, ok := data[marker]
+	return ok
+}
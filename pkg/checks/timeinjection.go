@@ -0,0 +1,98 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/attacks"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+func init() {
+	Register(blindInjectionCheck{})
+}
+
+// blindInjectionCheck sends time-based injection payloads in the string
+// arguments of queries and compares response times with a benign baseline.
+type blindInjectionCheck struct{}
+
+func (blindInjectionCheck) ID() string { return "blind-injection" }
+
+func (blindInjectionCheck) Description() string {
+	return "Sends time-based SQL, NoSQL and shell injection payloads in String and ID query arguments and flags consistent delays (--audit-injection)"
+}
+
+func (blindInjectionCheck) Severity() string { return report.SeverityHigh }
+
+func (blindInjectionCheck) Safety() string { return SafetyIntrusive }
+
+func (blindInjectionCheck) Group() string { return GroupInjection }
+
+func (blindInjectionCheck) Requires() Requirement {
+	return RequiresNetwork | RequiresSchema | RequiresArbitraryQueries
+}
+
+// Budget leaves room for the delayed trials of several arguments.
+func (blindInjectionCheck) Budget() time.Duration { return 10 * time.Minute }
+
+func (blindInjectionCheck) Plan(target string, deps *Deps) Plan {
+	if deps.Schema == nil {
+		min, max := attacks.PlanInjection(make([]attacks.InjectionTarget, 1), deps.Injection)
+		return Plan{Deferred: true, Note: fmt.Sprintf("%d-%d per String or ID query argument of the introspected schema", min, max)}
+	}
+	targets := attacks.InjectionTargets(deps.Schema)
+	min, max := attacks.PlanInjection(targets, deps.Injection)
+	return Plan{Requests: min, MaxRequests: max, Note: fmt.Sprintf("%d argument(s); more when payloads are delayed and sent again", len(targets))}
+}
+
+func (c blindInjectionCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	if deps.Schema == nil {
+		logger.Debug("→ No introspected schema for %s, skipping the time-based injection probes", target)
+		return nil, nil
+	}
+	targets := attacks.InjectionTargets(deps.Schema)
+	if len(targets) == 0 {
+		logger.Info("No String or ID query arguments to probe for injection on %s", target)
+		return nil, nil
+	}
+	logger.Info("Probing %d query argument(s) of %s for time-based injection...", len(targets), target)
+
+	var findings []report.Finding
+	for _, t := range targets {
+		results, err := attacks.ProbeTiming(ctx, target, t, deps.Headers, deps.Injection)
+		for _, r := range results {
+			findings = append(findings, c.finding(target, r, deps.Headers))
+		}
+		if ctx.Err() != nil {
+			return findings, ctx.Err()
+		}
+		if err != nil {
+			logger.Warn("%v", err)
+		}
+	}
+	return findings, nil
+}
+
+// finding reports the payload of r that delayed every response.
+func (c blindInjectionCheck) finding(target string, r attacks.TimingResult, headers map[string]string) report.Finding {
+	latencies := make([]string, len(r.Latencies))
+	for i, d := range r.Latencies {
+		latencies[i] = d.Round(time.Millisecond).String()
+	}
+	return report.Finding{
+		ID:       "time-based-injection",
+		Check:    c.ID(),
+		Title:    fmt.Sprintf("Time-based %s injection in %s(%s)", r.Payload.Technique, r.Target.Operation, r.Target.Argument),
+		Severity: c.Severity(),
+		Endpoint: target,
+		Description: fmt.Sprintf("A %s payload in the %s argument of %s delayed the response in each of %d trials, while benign values answered promptly. "+
+			"The value likely reaches a backend query or command unescaped.", r.Payload.Technique, r.Target.Argument, r.Target.Operation, len(r.Latencies)),
+		Evidence: fmt.Sprintf("payload %q took %s; baseline median %s, threshold %s",
+			r.Value, strings.Join(latencies, ", "), r.Baseline.Round(time.Millisecond), r.Threshold.Round(time.Millisecond)),
+		Request: report.NewGraphQLRequest(target, r.Target.Document, map[string]interface{}{schema.ProbeVariable: r.Value}, headers),
+	}
+}
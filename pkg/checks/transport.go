@@ -0,0 +1,164 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+func init() {
+	Register(transportFeaturesCheck{})
+}
+
+// Probes of the transport-features check
+const (
+	deferProbe  = `{ __typename ... @defer(label: "gsDeferred") { gsDeferred: __typename } }`
+	deferAccept = "multipart/mixed; deferSpec=20220824, application/graphql-response+json, application/json"
+	sseProbe    = `{ __typename }`
+	sseAccept   = "text/event-stream"
+)
+
+// transportFeaturesCheck detects incremental delivery with @defer and
+// @stream, and GraphQL over Server-Sent Events. Both let responses outlive a
+// single request, which per-response limits and proxies may not account for,
+// and both need dedicated client support to be tested further.
+type transportFeaturesCheck struct{}
+
+func (transportFeaturesCheck) ID() string { return "transport-features" }
+
+func (transportFeaturesCheck) Description() string {
+	return "Detects incremental delivery (@defer, @stream) and GraphQL over Server-Sent Events"
+}
+
+func (transportFeaturesCheck) Severity() string { return report.SeverityInfo }
+
+func (transportFeaturesCheck) Safety() string { return SafetyPassive }
+
+func (transportFeaturesCheck) Requires() Requirement {
+	return RequiresNetwork | RequiresArbitraryQueries
+}
+
+func (transportFeaturesCheck) Plan(target string, deps *Deps) Plan {
+	return Plan{Requests: 2, MaxRequests: 2}
+}
+
+func (c transportFeaturesCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	logger.Info("Probing %s for incremental delivery and SSE...", target)
+	caps := deps.capabilities(target)
+	caps.Stream = declaresDirective(deps.Introspection, "stream")
+	var findings []report.Finding
+
+	d, err := network.ProbeDelivery(ctx, target, deferProbe, deferAccept, deps.Headers)
+	if err != nil {
+		return nil, err
+	}
+	if deferAccepted(d) {
+		caps.Defer = true
+		caps.DeferIncremental = d.Incremental
+		caps.DeferDelivery = d.Kind
+		delivery := "inlined in a single JSON response"
+		if d.Incremental {
+			delivery = fmt.Sprintf("delivered incrementally as %s", d.ContentType)
+		}
+		logger.Info("%s executes @defer, %s", target, delivery)
+		findings = append(findings, report.Finding{
+			ID:          "defer-supported",
+			Title:       "The endpoint executes @defer",
+			Severity:    c.Severity(),
+			Endpoint:    target,
+			Description: fmt.Sprintf("A fragment marked @defer was executed and %s.", delivery),
+			Evidence:    deliveryEvidence(d),
+			Request:     deliveryRequest(target, deferProbe, deferAccept, deps.Headers),
+		})
+	}
+
+	d, err = network.ProbeDelivery(ctx, target, sseProbe, sseAccept, deps.Headers)
+	if err != nil {
+		logger.Debug("→ SSE probe on %s failed: %v", target, err)
+	} else if d.Kind == network.DeliverySSE && d.Parts > 0 {
+		caps.SSE = true
+		logger.Info("%s answers queries over Server-Sent Events", target)
+		findings = append(findings, report.Finding{
+			ID:          "sse-transport",
+			Title:       "Queries are served over Server-Sent Events",
+			Severity:    c.Severity(),
+			Endpoint:    target,
+			Description: "A query sent with Accept: text/event-stream was answered with an event stream, the GraphQL over SSE transport. Subscriptions are then likely reachable over plain HTTP as well as WebSocket.",
+			Evidence:    deliveryEvidence(d),
+			Request:     deliveryRequest(target, sseProbe, sseAccept, deps.Headers),
+		})
+	}
+	if caps.Stream {
+		logger.Info("The schema of %s declares @stream", target)
+	}
+	return findings, nil
+}
+
+// capabilities returns the capabilities recorded for target, creating them
+// for the first check to find some.
+func (d *Deps) capabilities(target string) *types.Capabilities {
+	if d.Capabilities == nil {
+		d.Capabilities = &types.Capabilities{Endpoint: target}
+	}
+	return d.Capabilities
+}
+
+// deferAccepted reports whether the response to deferProbe shows the deferred
+// fragment executed: incremental payloads, or data without errors.
+func deferAccepted(d network.Delivery) bool {
+	if d.Incremental {
+		return true
+	}
+	if d.Result == nil {
+		return false
+	}
+	if errs, ok := d.Result["errors"].([]interface{}); ok && len(errs) > 0 {
+		return false
+	}
+	data, ok := d.Result["data"].(map[string]interface{})
+	return ok && data["__typename"] != nil
+}
+
+// declaresDirective reports whether the introspection result declares the
+// directive name. There is none to tell before the introspection check has run.
+func declaresDirective(result map[string]interface{}, name string) bool {
+	data, _ := result["data"].(map[string]interface{})
+	schema, _ := data["__schema"].(map[string]interface{})
+	directives, _ := schema["directives"].([]interface{})
+	for _, d := range directives {
+		if d, ok := d.(map[string]interface{}); ok && d["name"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// deliveryEvidence describes the framing of d.
+func deliveryEvidence(d network.Delivery) string {
+	parts := []string{fmt.Sprintf("HTTP %d", d.Status)}
+	if d.ContentType != "" {
+		parts = append(parts, "Content-Type: "+d.ContentType)
+	}
+	switch d.Kind {
+	case network.DeliveryMultipart:
+		parts = append(parts, fmt.Sprintf("%d part(s)", d.Parts))
+	case network.DeliverySSE:
+		parts = append(parts, fmt.Sprintf("%d event(s)", d.Parts))
+	}
+	if d.Incremental {
+		parts = append(parts, "incremental payloads")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// deliveryRequest is the evidence of a probe sent with the Accept header accept.
+func deliveryRequest(target, query, accept string, headers map[string]string) *report.RequestEvidence {
+	req := report.NewGraphQLRequest(target, query, nil, headers)
+	req.Headers["Accept"] = accept
+	return req
+}
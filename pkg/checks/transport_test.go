@@ -0,0 +1,112 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// transportServer answers the probes of the transport-features check the
+// way one kind of server does: with delivery "multipart" it streams @defer
+// incrementally and answers text/event-stream with events, with "inline" it
+// executes @defer in one JSON response, and with "reject" it refuses the
+// directive. The latter two answer JSON whatever the Accept header.
+func transportServer(t *testing.T, delivery string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		deferred := strings.Contains(req.Query, "@defer")
+		accept := r.Header.Get("Accept")
+		switch {
+		case delivery == "multipart" && deferred && strings.HasPrefix(accept, "multipart/mixed"):
+			w.Header().Set("Content-Type", `multipart/mixed; boundary="-"; deferSpec=20220824`)
+			w.Write([]byte("\r\n---\r\nContent-Type: application/json\r\n\r\n{\"data\":{\"__typename\":\"Query\"},\"hasNext\":true}" +
+				"\r\n---\r\nContent-Type: application/json\r\n\r\n{\"incremental\":[{\"data\":{\"gsDeferred\":\"Query\"},\"path\":[]}],\"hasNext\":false}\r\n-----\r\n"))
+		case delivery == "multipart" && accept == "text/event-stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: next\ndata: {\"data\":{\"__typename\":\"Query\"}}\n\nevent: complete\ndata:\n\n"))
+		case delivery == "reject" && deferred:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"errors":[{"message":"Unknown directive \"@defer\"."}]}`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"__typename":"Query","gsDeferred":"Query"}}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestTransportFeatures(t *testing.T) {
+	withStream := map[string]interface{}{"data": map[string]interface{}{"__schema": map[string]interface{}{
+		"directives": []interface{}{map[string]interface{}{"name": "defer"}, map[string]interface{}{"name": "stream"}},
+	}}}
+	tests := []struct {
+		delivery      string
+		introspection map[string]interface{}
+		findings      []string
+		caps          types.Capabilities
+		deferEvidence string
+	}{
+		{
+			delivery:      "multipart",
+			introspection: withStream,
+			findings:      []string{"defer-supported", "sse-transport"},
+			caps:          types.Capabilities{Defer: true, DeferIncremental: true, DeferDelivery: "multipart", Stream: true, SSE: true},
+			deferEvidence: `HTTP 200; Content-Type: multipart/mixed; boundary="-"; deferSpec=20220824; 2 part(s); incremental payloads`,
+		},
+		{
+			delivery:      "inline",
+			findings:      []string{"defer-supported"},
+			caps:          types.Capabilities{Defer: true, DeferDelivery: "json"},
+			deferEvidence: "HTTP 200; Content-Type: application/json",
+		},
+		{delivery: "reject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.delivery, func(t *testing.T) {
+			target := transportServer(t, tt.delivery)
+			deps := &Deps{Introspection: tt.introspection}
+			findings, err := transportFeaturesCheck{}.Run(context.Background(), target, deps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.ID)
+				if f.Severity != report.SeverityInfo || f.Endpoint != target || f.Request == nil {
+					t.Errorf("finding %+v", f)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.findings, ",") {
+				t.Fatalf("findings %v, want %v", got, tt.findings)
+			}
+			want := tt.caps
+			want.Endpoint = target
+			if deps.Capabilities == nil || !reflect.DeepEqual(*deps.Capabilities, want) {
+				t.Errorf("capabilities = %+v, want %+v", deps.Capabilities, want)
+			}
+			if len(findings) > 0 {
+				if findings[0].Evidence != tt.deferEvidence {
+					t.Errorf("@defer evidence %q, want %q", findings[0].Evidence, tt.deferEvidence)
+				}
+				if accept := findings[0].Request.Headers["Accept"]; accept != deferAccept {
+					t.Errorf("the @defer request evidence has Accept: %s", accept)
+				}
+			}
+			if len(findings) > 1 && (findings[1].Evidence != "HTTP 200; Content-Type: text/event-stream; 2 event(s)" || findings[1].Request.Headers["Accept"] != sseAccept) {
+				t.Errorf("SSE finding evidence %q, request %+v", findings[1].Evidence, findings[1].Request)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/fingerprint"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/vulndb"
+)
+
+func init() {
+	Register(vulnDBCheck{})
+}
+
+// vulnDBCheck looks the fingerprinted engines and IDEs up in the vulnerability
+// knowledge base. It needs the engine check to have run first.
+type vulnDBCheck struct{}
+
+func (vulnDBCheck) ID() string { return "vulndb" }
+
+func (vulnDBCheck) Description() string {
+	return "Matches the fingerprinted engines and versions against known CVEs and insecure-default advisories"
+}
+
+func (vulnDBCheck) Severity() string { return report.SeverityHigh }
+
+func (vulnDBCheck) Safety() string { return SafetyPassive }
+
+func (vulnDBCheck) Requires() Requirement { return RequiresEngines }
+
+func (c vulnDBCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	identified := append(append([]fingerprint.EngineMatch(nil), deps.Engines...), deps.Components...)
+	if len(identified) == 0 {
+		logger.Debug("→ No fingerprinted engine for %s, skipping the vulnerability lookup", target)
+		return nil, nil
+	}
+
+	var findings []report.Finding
+	for _, m := range identified {
+		for _, e := range vulndb.Lookup(m.Engine, m.Version) {
+			name := m.Engine
+			if m.Version != "" {
+				name += " " + m.Version
+			}
+			logger.Info("%s on %s is affected by %s", name, target, e.ID)
+			findings = append(findings, report.Finding{
+				ID:          "vulndb-" + strings.ToLower(e.ID),
+				Title:       fmt.Sprintf("%s affected by %s: %s", name, e.ID, e.Title),
+				Severity:    e.Severity,
+				Endpoint:    target,
+				Description: e.Description,
+				Evidence:    fmt.Sprintf("%s (affected: %s); identified as %s", e.ID, e.Ranges(), m),
+				References:  e.References,
+			})
+		}
+	}
+	return findings, nil
+}
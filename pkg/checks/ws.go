@@ -0,0 +1,81 @@
+package checks
+
+import (
+	"context"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/subscription"
+)
+
+func init() {
+	Register(wsProtocolCheck{})
+}
+
+// wsProtocolCheck sends malformed and out-of-order subscription protocol frames.
+type wsProtocolCheck struct{}
+
+func (wsProtocolCheck) ID() string { return "ws-protocol" }
+
+func (wsProtocolCheck) Description() string {
+	return "Fuzzes the subscription WebSocket protocol with out-of-order and malformed frames (--audit-ws)"
+}
+
+func (wsProtocolCheck) Severity() string { return report.SeverityMedium }
+
+func (wsProtocolCheck) Safety() string { return SafetyIntrusive }
+
+func (wsProtocolCheck) Requires() Requirement { return RequiresNetwork | RequiresArbitraryQueries }
+
+func (wsProtocolCheck) Group() string { return GroupWS }
+
+func (wsProtocolCheck) Plan(target string, deps *Deps) Plan {
+	n := subscription.ScenarioCount()
+	return Plan{Requests: n, MaxRequests: n, Note: "WebSocket connections"}
+}
+
+func (wsProtocolCheck) Run(ctx context.Context, target string, deps *Deps) ([]report.Finding, error) {
+	wsURL := deps.WSURL
+	if wsURL == "" {
+		wsURL = WebSocketURL(target)
+	}
+	logger.Info("Fuzzing subscription protocol on %s...", wsURL)
+
+	results := subscription.FuzzProtocol(ctx, wsURL, subscription.FuzzOptions{Headers: deps.Headers})
+
+	var findings []report.Finding
+	connected := false
+	for _, r := range results {
+		if r.Error != "" {
+			logger.Debug("→ Scenario %s on %s: %s", r.Scenario, wsURL, r.Error)
+			continue
+		}
+		connected = true
+		for _, w := range r.Weaknesses {
+			findings = append(findings, report.Finding{
+				ID:          w.ID,
+				Title:       w.Title,
+				Severity:    w.Severity,
+				Endpoint:    wsURL,
+				Description: "Observed during the " + r.Scenario + " scenario.",
+				Evidence:    strings.Join(r.Transcript, "\n"),
+			})
+		}
+	}
+	if !connected && len(results) > 0 {
+		logger.Info("No WebSocket endpoint reachable at %s", wsURL)
+	}
+	return findings, nil
+}
+
+// WebSocketURL derives the WebSocket URL of an HTTP GraphQL endpoint.
+func WebSocketURL(target string) string {
+	switch {
+	case strings.HasPrefix(target, "https://"):
+		return "wss://" + strings.TrimPrefix(target, "https://")
+	case strings.HasPrefix(target, "http://"):
+		return "ws://" + strings.TrimPrefix(target, "http://")
+	}
+	return target
+}
@@ -0,0 +1,328 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/attacks"
+	"github.com/CyberRoute/graphspecter/pkg/auth"
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// runAuthz sends every query of the catalog fetched by the introspection
+// check to targetURL as each of identities and writes the authorization
+// matrix next to the introspection dump. The credentials of each identity are
+// first verified with whoami, as those of the run are, and the identities
+// whose credentials are rejected are left out. The data returned to each
+// identity are compared with those of the last one under diff. It returns the
+// matrix, nil when it could not be built, and its findings.
+func runAuthz(ctx context.Context, targetURL string, headers map[string]string, deps *checks.Deps, identities []types.Identity, whoami string, diff jsondiff.Options) (*report.AuthzMatrix, []report.Finding) {
+	if deps.ArbitraryQueriesBlocked() {
+		logger.Info("Skipping the authorization matrix of %s: %s", targetURL, checks.SkipAllowlist)
+		return nil, nil
+	}
+	if deps.CredentialsRejected() {
+		logger.Info("Skipping the authorization matrix of %s: the supplied credentials were rejected", targetURL)
+		return nil, nil
+	}
+	if deps.Catalog == nil {
+		logger.Warn("Skipping the authorization matrix of %s: no introspection result available", targetURL)
+		return nil, nil
+	}
+
+	authzCtx, stop := network.StartModule(ctx, "authz")
+	defer stop()
+	var verified []types.Identity
+	var verifications []types.AuthVerification
+	for _, id := range identities {
+		if len(id.Headers) > 0 {
+			v := auth.Verify(authzCtx, targetURL, attacks.IdentityHeaders(headers, id), whoami)
+			v.Identity = id.Name
+			logVerification(v)
+			verifications = append(verifications, v)
+			if v.Status == types.AuthRejected {
+				continue
+			}
+		}
+		verified = append(verified, id)
+	}
+	if len(verified) == 0 {
+		logger.Warn("Skipping the authorization matrix of %s: the credentials of every identity were rejected", targetURL)
+		return nil, nil
+	}
+
+	logger.Info("Sending the queries of %s as %d identities...", targetURL, len(verified))
+	results, err := attacks.AuthzMatrix(authzCtx, targetURL, deps.Catalog, verified, headers)
+	if err != nil {
+		logger.Error("Authorization matrix of %s stopped early: %v", targetURL, err)
+	}
+
+	m := authzMatrix(targetURL, verified, results, diff)
+	m.Auth = verifications
+	if deps.OutputFile != "" {
+		name := artifacts.Claim(targetURL, introspection.AuthzFileName(deps.OutputFile, targetURL))
+		if err := writeAuthzMatrix(m, name); err != nil {
+			logger.Error("%v", err)
+		} else {
+			logger.Info("Authorization matrix of %d queries saved to %s", len(m.Rows), name)
+		}
+	}
+	logger.Info("%d of %d queries of %s are not answered alike for every identity", len(m.Divergent()), len(m.Rows), targetURL)
+	return m, authzFindings(targetURL, verified, results, diff)
+}
+
+// authzMatrix returns the authorization matrix of results, with queries
+// identified by the canonical hash of their document.
+func authzMatrix(targetURL string, identities []types.Identity, results []attacks.AuthzResult, diff jsondiff.Options) *report.AuthzMatrix {
+	m := &report.AuthzMatrix{Endpoint: targetURL}
+	for _, id := range identities {
+		m.Identities = append(m.Identities, id.Name)
+	}
+	for _, r := range results {
+		hash, err := gql.CanonicalHash(r.Query)
+		if err != nil {
+			hash = r.Operation
+		}
+		row := report.AuthzRow{Hash: hash, Operation: r.Operation}
+		for _, resp := range r.Responses {
+			row.Cells = append(row.Cells, resp.Access)
+		}
+		row.Fields = authzFields(r)
+		if diffs := authzDiffs(r, diff); len(diffs) > 0 {
+			row.Diffs = make([]string, len(r.Responses)-1)
+			for i, d := range diffs {
+				if len(d) > 0 {
+					row.Diffs[i] = jsondiff.Render(d)
+				}
+			}
+		}
+		m.Rows = append(m.Rows, row)
+	}
+	return m
+}
+
+// authzFields returns the access of the identities of r to each field the
+// query selects, ordered by path.
+func authzFields(r attacks.AuthzResult) []report.AuthzField {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, resp := range r.Responses {
+		for path := range resp.Fields {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	fields := make([]report.AuthzField, len(paths))
+	for i, path := range paths {
+		fields[i] = report.AuthzField{Path: path, Cells: make([]string, len(r.Responses))}
+		for j, resp := range r.Responses {
+			fields[i].Cells[j] = resp.Fields[path]
+		}
+	}
+	return fields
+}
+
+// authzDiffs compares the data returned to each identity of r but the last
+// with those of the last. The element of an identity is nil when its data are
+// equal or when either identity was returned none; authzDiffs returns nil
+// when every element is.
+func authzDiffs(r attacks.AuthzResult, diff jsondiff.Options) [][]jsondiff.Difference {
+	last := len(r.Responses) - 1
+	if last < 1 || r.Responses[last].Data == nil {
+		return nil
+	}
+	privileged := authzDocument(r.Operation, r.Responses[last].Data)
+	diffs := make([][]jsondiff.Difference, last)
+	found := false
+	for i, resp := range r.Responses[:last] {
+		if resp.Data == nil {
+			continue
+		}
+		diffs[i] = jsondiff.Compare(authzDocument(r.Operation, resp.Data), privileged, diff)
+		found = found || len(diffs[i]) > 0
+	}
+	if !found {
+		return nil
+	}
+	return diffs
+}
+
+// authzDocument returns data, the value of operation, as in the response it
+// was read from, so that the paths of jsondiff.Options start at data.
+func authzDocument(operation string, data interface{}) map[string]interface{} {
+	return map[string]interface{}{"data": map[string]interface{}{operation: data}}
+}
+
+// writeAuthzMatrix writes m as indented JSON to filename.
+func writeAuthzMatrix(m *report.AuthzMatrix, filename string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling the authorization matrix: %w", err)
+	}
+	if err := artifacts.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("error writing the authorization matrix: %w", err)
+	}
+	return nil
+}
+
+// anonymousIdentity returns the index of the first identity without
+// headers, -1 when every identity has credentials.
+func anonymousIdentity(identities []types.Identity) int {
+	for i, id := range identities {
+		if len(id.Headers) == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// authzFindings reports the queries of results that the catalog expects to
+// require authorization and that returned data to the anonymous identity, or
+// the same data to a less privileged identity as to the last one.
+func authzFindings(targetURL string, identities []types.Identity, results []attacks.AuthzResult, diff jsondiff.Options) []report.Finding {
+	var findings []report.Finding
+	anon := anonymousIdentity(identities)
+	if f := anonymousAccessFinding(targetURL, identities, anon, results); f != nil {
+		findings = append(findings, *f)
+	}
+	if f := privilegedDataFinding(targetURL, identities, anon, results, diff); f != nil {
+		findings = append(findings, *f)
+	}
+	if f := loginGatedFinding(targetURL, identities, anon, results); f != nil {
+		findings = append(findings, *f)
+	}
+	return findings
+}
+
+// loginGatedFinding reports the fields that identities with credentials read
+// and that identities[anon] is denied in a partial response, nil when there
+// are none or no identity is anonymous. Queries refused to identities[anon]
+// as a whole are left to the matrix: a login is expected to guard the
+// current user and the like. The last identity is only considered when it is
+// the only one with credentials, since the most privileged identity is
+// expected to read what the anonymous one cannot.
+func loginGatedFinding(targetURL string, identities []types.Identity, anon int, results []attacks.AuthzResult) *report.Finding {
+	if anon < 0 {
+		return nil
+	}
+	var readers []int
+	for i, id := range identities[:len(identities)-1] {
+		if i != anon && len(id.Headers) > 0 {
+			readers = append(readers, i)
+		}
+	}
+	if len(readers) == 0 && anon != len(identities)-1 {
+		readers = []int{len(identities) - 1}
+	}
+
+	var gated []string
+	count := 0
+	for _, r := range results {
+		if r.Responses[anon].Fields[r.Operation] != attacks.FieldAllowed {
+			continue
+		}
+		for _, i := range readers {
+			fields := attacks.LoginGatedFields(r.Responses[anon].Fields, r.Responses[i].Fields)
+			if len(fields) == 0 {
+				continue
+			}
+			count += len(fields)
+			gated = append(gated, fmt.Sprintf("%s as %s: %s", r.Operation, identities[i].Name, strings.Join(fields, ", ")))
+		}
+	}
+	if len(gated) == 0 {
+		return nil
+	}
+	return &report.Finding{
+		ID:          "authz-login-gated-fields",
+		Check:       "authz",
+		Title:       "Fields are readable by any logged-in identity",
+		Severity:    report.SeverityLow,
+		Endpoint:    targetURL,
+		Description: fmt.Sprintf("%d fields were refused to the %s identity with an authorization error, in responses holding the other fields of their queries, and returned to less privileged identities with credentials. A login may be the only check guarding them; review whether every account should read them.", count, identities[anon].Name),
+		Evidence:    strings.Join(gated, "; "),
+	}
+}
+
+// anonymousAccessFinding reports the queries expected to require
+// authorization that returned records to identities[anon], nil when there
+// are none or no identity is anonymous.
+func anonymousAccessFinding(targetURL string, identities []types.Identity, anon int, results []attacks.AuthzResult) *report.Finding {
+	if anon < 0 {
+		return nil
+	}
+	var exposed []string
+	for _, r := range results {
+		resp := r.Responses[anon]
+		if len(r.AuthHints) == 0 || resp.Access != attacks.AccessAllowed || resp.Records == 0 {
+			continue
+		}
+		exposed = append(exposed, fmt.Sprintf("%s (%d records; %s)", r.Operation, resp.Records, strings.Join(r.AuthHints, "; ")))
+	}
+	if len(exposed) == 0 {
+		return nil
+	}
+	return &report.Finding{
+		ID:          "authz-anonymous-access",
+		Check:       "authz",
+		Title:       "Queries expected to require authorization return data without credentials",
+		Severity:    report.SeverityMedium,
+		Endpoint:    targetURL,
+		Description: fmt.Sprintf("%d queries whose name, description or directives suggest they require authorization returned data to the %s identity, which sends no credentials.", len(exposed), identities[anon].Name),
+		Evidence:    strings.Join(exposed, ", "),
+	}
+}
+
+// privilegedDataFinding reports the queries expected to require
+// authorization that returned records to an identity with credentials, other
+// than the last, equal under diff to those returned to the last, most
+// privileged, identity. The anonymous identity is left to
+// anonymousAccessFinding.
+func privilegedDataFinding(targetURL string, identities []types.Identity, anon int, results []attacks.AuthzResult, diff jsondiff.Options) *report.Finding {
+	last := len(identities) - 1
+	var same []string
+	for _, r := range results {
+		privileged := r.Responses[last]
+		if len(r.AuthHints) == 0 || privileged.Access != attacks.AccessAllowed || privileged.Records == 0 {
+			continue
+		}
+		var names []string
+		for i, resp := range r.Responses[:last] {
+			if i == anon || resp.Access != attacks.AccessAllowed || resp.Records == 0 {
+				continue
+			}
+			if jsondiff.Equal(authzDocument(r.Operation, resp.Data), authzDocument(r.Operation, privileged.Data), diff) {
+				names = append(names, identities[i].Name)
+			}
+		}
+		if len(names) > 0 {
+			same = append(same, fmt.Sprintf("%s (%s; %s)", r.Operation, strings.Join(names, ", "), strings.Join(r.AuthHints, "; ")))
+		}
+	}
+	if len(same) == 0 {
+		return nil
+	}
+	return &report.Finding{
+		ID:          "authz-privileged-data",
+		Check:       "authz",
+		Title:       "Less privileged identities are returned the data of the most privileged one",
+		Severity:    report.SeverityMedium,
+		Endpoint:    targetURL,
+		Description: fmt.Sprintf("%d queries whose name, description or directives suggest they require authorization returned to less privileged identities the same records as to the %s identity, leaving out the paths ignored by --authz-ignore. The server may not scope them to the caller.", len(same), identities[last].Name),
+		Evidence:    strings.Join(same, ", "),
+	}
+}
@@ -0,0 +1,277 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/attacks"
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// authzServer answers the queries of authzCatalog by the bearer token of the
+// request: none, "user" or "admin".
+func authzServer(t *testing.T, responses map[string]map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		identity := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		for field, resp := range responses[identity] {
+			if strings.Contains(req.Query, field) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(resp))
+				return
+			}
+		}
+		http.Error(w, "unknown query", http.StatusBadRequest)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+const (
+	unauthenticated = `{"errors":[{"message":"You must be logged in","extensions":{"code":"UNAUTHENTICATED"}}],"data":null}`
+	forbidden       = `{"errors":[{"message":"Forbidden","extensions":{"code":"FORBIDDEN"}}],"data":null}`
+)
+
+// authzCatalog holds a public query, one for logged-in users and an admin
+// query the catalog expects to require authorization.
+var authzCatalog = &schema.Catalog{Operations: []schema.CatalogOperation{
+	{Kind: schema.KindQuery, Name: "posts", Executable: "query { posts { id } }"},
+	{Kind: schema.KindQuery, Name: "me", Executable: "query { me { id } }", AuthHints: []string{"name suggests the current user"}},
+	{Kind: schema.KindQuery, Name: "adminUsers", Executable: "query { adminUsers { id } }", AuthHints: []string{"name mentions admin"}},
+}}
+
+var authzIdentities = []types.Identity{
+	{Name: "anonymous"},
+	{Name: "user", Headers: map[string]string{"Authorization": "Bearer user"}},
+	{Name: "admin", Headers: map[string]string{"Authorization": "Bearer admin"}},
+}
+
+func TestRunAuthz(t *testing.T) {
+	srv := authzServer(t, map[string]map[string]string{
+		"": {
+			"posts":      `{"data":{"posts":[{"id":"1"}]}}`,
+			"me":         unauthenticated,
+			"adminUsers": `{"data":{"adminUsers":[{"id":"1"},{"id":"2"}]}}`,
+		},
+		"user": {
+			"posts":      `{"data":{"posts":[{"id":"1"}]}}`,
+			"me":         `{"data":{"me":{"id":"7"}}}`,
+			"adminUsers": forbidden,
+		},
+		"admin": {
+			"posts":      `{"data":{"posts":[{"id":"1"}]}}`,
+			"me":         `{"data":{"me":{"id":"1"}}}`,
+			"adminUsers": `{"data":{"adminUsers":[{"id":"1"},{"id":"2"}]}}`,
+		},
+	})
+	dir := t.TempDir()
+	deps := &checks.Deps{Catalog: authzCatalog, OutputFile: filepath.Join(dir, "introspection.json")}
+	// The credentials of the run are not sent by the identities.
+	headers := map[string]string{"Content-Type": "application/json", "Authorization": "Bearer admin"}
+
+	m, findings := runAuthz(context.Background(), srv.URL+"/graphql", headers, deps, authzIdentities, "", jsondiff.Options{})
+	if m == nil {
+		t.Fatal("no authorization matrix")
+	}
+	want := map[string][]string{
+		"posts":      {report.CellAccessible, report.CellAccessible, report.CellAccessible},
+		"me":         {report.CellDenied, report.CellAccessible, report.CellAccessible},
+		"adminUsers": {report.CellAccessible, report.CellDenied, report.CellAccessible},
+	}
+	if strings.Join(m.Identities, ",") != "anonymous,user,admin" || len(m.Rows) != len(want) {
+		t.Fatalf("matrix = %+v", m)
+	}
+	for _, row := range m.Rows {
+		if strings.Join(row.Cells, ",") != strings.Join(want[row.Operation], ",") {
+			t.Errorf("%s = %v, want %v", row.Operation, row.Cells, want[row.Operation])
+		}
+		if row.Hash == "" || row.Hash == row.Operation {
+			t.Errorf("%s is not identified by the hash of its document", row.Operation)
+		}
+	}
+	if divergent := m.Divergent(); len(divergent) != 2 {
+		t.Errorf("%d divergent rows, want me and adminUsers", len(divergent))
+	}
+	for _, row := range m.Rows {
+		if row.Operation == "me" && (len(row.Diffs) != 2 || row.Diffs[0] != "" || row.Diffs[1] != `~ data.me.id: "7" -> "1"`) {
+			t.Errorf("diffs of me = %q", row.Diffs)
+		}
+		if row.Operation == "posts" && row.Diffs != nil {
+			t.Errorf("diffs of posts = %q, want none", row.Diffs)
+		}
+	}
+
+	if len(findings) != 1 || findings[0].ID != "authz-anonymous-access" {
+		t.Fatalf("findings = %+v, want authz-anonymous-access", findings)
+	}
+	if !strings.Contains(findings[0].Evidence, "adminUsers (2 records") || strings.Contains(findings[0].Evidence, "posts") {
+		t.Errorf("evidence = %q, want adminUsers only", findings[0].Evidence)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "authz_graphql.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved report.AuthzMatrix
+	if err := json.Unmarshal(data, &saved); err != nil || len(saved.Rows) != 3 {
+		t.Errorf("saved matrix = %s (%v)", data, err)
+	}
+}
+
+func TestRunAuthzSkipsAllowlist(t *testing.T) {
+	deps := &checks.Deps{Catalog: authzCatalog, QueryPosture: checks.PostureAllowlist}
+	if m, findings := runAuthz(context.Background(), "http://127.0.0.1:1/graphql", nil, deps, authzIdentities, "", jsondiff.Options{}); m != nil || findings != nil {
+		t.Errorf("runAuthz = %v, %v on an endpoint executing only allow-listed operations", m, findings)
+	}
+}
+
+// authzResult returns the result of operation, with an AuthHint, for the
+// identities of authzIdentities returned data, nil for a denied one.
+func authzResult(operation string, data ...string) attacks.AuthzResult {
+	r := attacks.AuthzResult{Operation: operation, Query: "query { " + operation + " { id } }", AuthHints: []string{"name mentions admin"}}
+	for i, d := range data {
+		resp := attacks.IdentityResponse{Identity: authzIdentities[i].Name, Access: attacks.AccessDenied}
+		if d != "" {
+			if err := json.Unmarshal([]byte(d), &resp.Data); err != nil {
+				panic(err)
+			}
+			resp.Access, resp.Records = attacks.AccessAllowed, 1
+		}
+		r.Responses = append(r.Responses, resp)
+	}
+	return r
+}
+
+func TestAuthzDiffIgnore(t *testing.T) {
+	results := []attacks.AuthzResult{
+		// Only the timestamp differs between user and admin.
+		authzResult("adminUsers", "", `[{"id":"1","seenAt":"t1"}]`, `[{"id":"1","seenAt":"t2"}]`),
+		authzResult("auditLog", "", `{"id":"1","entries":["b","a"]}`, `{"id":"1","entries":["a","b"]}`),
+		authzResult("adminStats", "", `{"id":"u"}`, `{"id":"a"}`),
+	}
+
+	m := authzMatrix("http://api/graphql", authzIdentities, results, jsondiff.Options{})
+	for _, row := range m.Rows {
+		if len(row.Diffs) != 2 || row.Diffs[0] != "" || row.Diffs[1] == "" {
+			t.Errorf("diffs of %s = %q without options", row.Operation, row.Diffs)
+		}
+	}
+	if f := authzFindings("http://api/graphql", authzIdentities, results, jsondiff.Options{}); len(f) != 0 {
+		t.Errorf("findings = %+v without options", f)
+	}
+
+	diff := jsondiff.Options{Ignore: []string{"data.*.*.seenAt"}, Unordered: []string{"data.auditLog.entries"}}
+	m = authzMatrix("http://api/graphql", authzIdentities, results, diff)
+	for _, row := range m.Rows {
+		if want := row.Operation == "adminStats"; (row.Diffs != nil) != want {
+			t.Errorf("diffs of %s = %q", row.Operation, row.Diffs)
+		}
+		// Every row is divergent by the denial of anonymous.
+		if !row.Divergent() {
+			t.Errorf("%s is not divergent", row.Operation)
+		}
+	}
+	findings := authzFindings("http://api/graphql", authzIdentities, results, diff)
+	if len(findings) != 1 || findings[0].ID != "authz-privileged-data" {
+		t.Fatalf("findings = %+v, want authz-privileged-data", findings)
+	}
+	if e := findings[0].Evidence; !strings.Contains(e, "adminUsers (user;") || !strings.Contains(e, "auditLog (user;") || strings.Contains(e, "adminStats") {
+		t.Errorf("evidence = %q", e)
+	}
+}
+
+func TestRunAuthzFields(t *testing.T) {
+	catalog := &schema.Catalog{Operations: []schema.CatalogOperation{
+		{Kind: schema.KindQuery, Name: "users", Executable: "query { users { id email } }"},
+		// A query refused to anonymous as a whole is not reported field by field.
+		{Kind: schema.KindQuery, Name: "me", Executable: "query { me { id } }"},
+	}}
+	partial := `{"data":{"users":[{"id":"1","email":null}]},"errors":[{"message":"Not authorized","path":["users",0,"email"],"extensions":{"code":"FORBIDDEN"}}]}`
+	full := `{"data":{"users":[{"id":"1","email":"a@example.com"}]}}`
+	srv := authzServer(t, map[string]map[string]string{
+		"":      {"users": partial, "me": unauthenticated},
+		"user":  {"users": full, "me": `{"data":{"me":{"id":"7"}}}`},
+		"admin": {"users": full, "me": `{"data":{"me":{"id":"1"}}}`},
+	})
+	m, findings := runAuthz(context.Background(), srv.URL+"/graphql", nil, &checks.Deps{Catalog: catalog}, authzIdentities, "", jsondiff.Options{})
+	if m == nil || len(m.Rows) != 2 {
+		t.Fatalf("matrix = %+v", m)
+	}
+	var fields []string
+	for _, f := range m.Rows[0].Fields {
+		fields = append(fields, f.Path+"="+strings.Join(f.Cells, ","))
+	}
+	want := "users=allowed,allowed,allowed users.email=denied,allowed,allowed users.id=allowed,allowed,allowed"
+	if strings.Join(fields, " ") != want {
+		t.Errorf("fields of users = %v, want %s", fields, want)
+	}
+
+	var gated *report.Finding
+	for i := range findings {
+		if findings[i].ID == "authz-login-gated-fields" {
+			gated = &findings[i]
+		}
+	}
+	if gated == nil || gated.Evidence != "users as user: users.email" {
+		t.Errorf("findings = %+v, want users.email gated by login for user", findings)
+	}
+}
+
+func TestRunAuthzLeavesOutRejectedIdentities(t *testing.T) {
+	srv := authzServer(t, map[string]map[string]string{
+		"": {
+			"__typename": `{"data":{"__typename":"Query"}}`,
+			"posts":      `{"data":{"posts":[{"id":"1"}]}}`,
+			"me":         unauthenticated,
+			"adminUsers": forbidden,
+		},
+		"user": {
+			"me":         `{"data":{"me":{"id":"7"}}}`,
+			"posts":      `{"data":{"posts":[{"id":"1"}]}}`,
+			"adminUsers": forbidden,
+		},
+		// The token of admin has expired: every query is refused.
+		"admin": {"": unauthenticated},
+	})
+	m, _ := runAuthz(context.Background(), srv.URL+"/graphql", nil, &checks.Deps{Catalog: authzCatalog}, authzIdentities, "{ me { id } }", jsondiff.Options{})
+	if m == nil {
+		t.Fatal("no authorization matrix")
+	}
+	if strings.Join(m.Identities, ",") != "anonymous,user" {
+		t.Errorf("identities = %v, want admin left out", m.Identities)
+	}
+	for _, row := range m.Rows {
+		if len(row.Cells) != 2 {
+			t.Errorf("%s has %d cells, want 2", row.Operation, len(row.Cells))
+		}
+	}
+	// Only identities carrying credentials are verified.
+	if len(m.Auth) != 2 || m.Auth[0].Identity != "user" || m.Auth[0].Status != types.AuthVerified ||
+		m.Auth[1].Identity != "admin" || m.Auth[1].Status != types.AuthRejected {
+		t.Errorf("verifications = %+v", m.Auth)
+	}
+}
+
+func TestRunAuthzSkipsRejectedCredentials(t *testing.T) {
+	deps := &checks.Deps{Catalog: authzCatalog, Auth: &types.AuthVerification{Status: types.AuthRejected, Reason: "HTTP 401"}}
+	if m, findings := runAuthz(context.Background(), "http://127.0.0.1:1/graphql", nil, deps, authzIdentities, "", jsondiff.Options{}); m != nil || findings != nil {
+		t.Errorf("runAuthz = %v, %v on an endpoint that rejected the credentials of the run", m, findings)
+	}
+}
@@ -0,0 +1,483 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/inference"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// BatchErrorsFile is the name of the failure list written into the batch directory.
+const BatchErrorsFile = "batch-errors.json"
+
+// Classes of batch failures
+const (
+	FailureRead        = "read"
+	FailureFrontMatter = "front-matter"
+	FailureSplit       = "split"
+	FailureVariables   = "variables"
+	FailureTransport   = "transport"
+	FailureGraphQL     = "graphql"
+	FailureChain       = "chain"
+)
+
+// batchOpRegex finds the operation definitions of batch files that do not parse.
+var batchOpRegex = regexp.MustCompile(`(?m)^(?:query|mutation)\s+([A-Za-z0-9_]+)`)
+
+// Front matter directives are comments at the top of a batch file, before its
+// first definition:
+//
+//	# graphspecter-header: X-Tenant: acme
+//	# graphspecter-skip: true
+const (
+	directivePrefix = "graphspecter-"
+	directiveHeader = "graphspecter-header"
+	directiveSkip   = "graphspecter-skip"
+)
+
+// frontMatter holds the directives of a batch file.
+type frontMatter struct {
+	// Headers are sent with the operations of the file, over the global headers.
+	Headers map[string]string
+	Skip    bool
+}
+
+// parseFrontMatter reads the directives from the leading comment block of
+// content and returns the content with the directive lines removed. Other
+// comments are kept; unknown or malformed directives are an error.
+func parseFrontMatter(content string) (*frontMatter, string, error) {
+	fm := &frontMatter{}
+	lines := strings.SplitAfter(content, "\n")
+	var kept []string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			kept = append(kept, lines[i:]...)
+			break
+		}
+		comment := strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+		if !strings.HasPrefix(comment, directivePrefix) {
+			kept = append(kept, line)
+			continue
+		}
+		name, value, ok := strings.Cut(comment, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok {
+			return nil, "", fmt.Errorf("line %d: directive %q has no value", i+1, name)
+		}
+		switch name {
+		case directiveHeader:
+			header, headerValue, err := network.ParseHeader(value)
+			if err != nil {
+				return nil, "", fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if fm.Headers == nil {
+				fm.Headers = make(map[string]string)
+			}
+			fm.Headers[header] = headerValue
+		case directiveSkip:
+			skip, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, "", fmt.Errorf("line %d: %s must be true or false, got %q", i+1, name, value)
+			}
+			fm.Skip = skip
+		default:
+			return nil, "", fmt.Errorf("line %d: unknown directive %q (valid: %s, %s)", i+1, name, directiveHeader, directiveSkip)
+		}
+	}
+	return fm, strings.Join(kept, ""), nil
+}
+
+// mergeHeaders returns global with the headers of override set over it. Names
+// are compared case-insensitively, as in HTTP.
+func mergeHeaders(global, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return global
+	}
+	merged := make(map[string]string, len(global)+len(override))
+	for k, v := range global {
+		merged[k] = v
+	}
+	for k, v := range override {
+		for existing := range merged {
+			if strings.EqualFold(existing, k) {
+				delete(merged, existing)
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// BatchFailure is a single file or operation of a batch run that did not succeed.
+type BatchFailure struct {
+	File string `json:"file"`
+	// Operation is empty for failures that affect the whole file.
+	Operation string `json:"operation,omitempty"`
+	Class     string `json:"class"`
+	Error     string `json:"error"`
+}
+
+// BatchEntry indexes an operation of a batch run by its canonical hash.
+type BatchEntry struct {
+	File      string `json:"file"`
+	Operation string `json:"operation"`
+	Hash      string `json:"hash"`
+	// DuplicateOf names the earlier operation ("file:operation") with the same
+	// hash, variables and front matter headers. Duplicates are not sent again.
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+}
+
+// BatchResult summarises a batch run.
+type BatchResult struct {
+	Files      int `json:"files"`
+	Operations int `json:"operations"`
+	// Skipped lists the files excluded with graphspecter-skip.
+	Skipped  []string       `json:"skipped,omitempty"`
+	Failures []BatchFailure `json:"failures"`
+	Index    []BatchEntry   `json:"index"`
+	// Planned lists the operations a dry run would have sent.
+	Planned []PlannedOperation `json:"planned,omitempty"`
+	// Previews are the requests a preview would have sent.
+	Previews []OperationPreview `json:"-"`
+}
+
+// BatchOptions controls how RunBatch prepares and sends operations.
+type BatchOptions struct {
+	// VarsSchema, when set, supplies enum and input object placeholders for
+	// variables that were not given.
+	VarsSchema *types.GQLSchema
+	// KeepAllFragments sends every fragment of a file with each of its operations.
+	KeepAllFragments bool
+	// DryRun records the operations in BatchResult.Planned instead of sending them.
+	DryRun bool
+	// Preview records the requests in BatchResult.Previews instead of sending them.
+	Preview bool
+	// Observer, when set, builds a schema from the responses.
+	Observer *inference.Observer
+}
+
+// Plan returns the requests of a dry run of the batch against url.
+func (r *BatchResult) Plan(url string) *RequestPlan {
+	return &RequestPlan{
+		Endpoints:  []string{url},
+		Steps:      []PlanStep{{Step: "batch", Target: url, Plan: checks.Plan{Requests: len(r.Planned), MaxRequests: len(r.Planned)}}},
+		Operations: r.Planned,
+	}
+}
+
+// batchOperation is an operation of a batch file and the document sent for it.
+type batchOperation struct {
+	name string
+	doc  string
+	// err reports why the operation cannot be sent on its own.
+	err error
+}
+
+// splitBatchFile returns the queries and mutations of a batch file, each as a
+// standalone document carrying the fragments it spreads, or every fragment of
+// the file with keepAllFragments. Files that do not parse are split on their
+// operation keywords instead, so that the server can report the problem itself.
+func splitBatchFile(content string, keepAllFragments bool) ([]batchOperation, error) {
+	doc, err := gql.Parse(content)
+	if err != nil {
+		logger.Debug("→ Splitting on operation keywords: %v", err)
+		locs := batchOpRegex.FindAllStringSubmatchIndex(content, -1)
+		if len(locs) == 0 {
+			return nil, fmt.Errorf("no named query or mutation definitions found")
+		}
+		ops := make([]batchOperation, 0, len(locs))
+		for i, loc := range locs {
+			end := len(content)
+			if i+1 < len(locs) {
+				end = locs[i+1][0]
+			}
+			ops = append(ops, batchOperation{name: content[loc[2]:loc[3]], doc: content[loc[0]:end]})
+		}
+		return ops, nil
+	}
+
+	var ops []batchOperation
+	for _, op := range doc.Operations {
+		if op.Kind == gql.OperationSubscription {
+			logger.Info("Skipping subscription %s: batch mode only sends queries and mutations", op.Name)
+			continue
+		}
+		name := op.Name
+		if name == "" {
+			name = "(anonymous)"
+		}
+		opDoc, err := doc.OperationDocument(op, keepAllFragments)
+		ops = append(ops, batchOperation{name: name, doc: opDoc, err: err})
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no query or mutation definitions found")
+	}
+	return ops, nil
+}
+
+// RunBatch executes every operation of the .graphql files in dir against url,
+// printing each result. A file.json next to file.graphql supplies variables,
+// a file.chain.yaml exports values of the responses of its operations to the
+// operations declaring them, and front matter directives add headers to the
+// operations of a file or skip it. Operations are sent in file order, except
+// that an operation is sent after the ones it takes values from. Each
+// operation is sent with only the fragments it uses unless
+// opts.KeepAllFragments is set. Failures are collected rather than stopping the
+// run; conflicting or cyclic chains stop it before anything is sent.
+func RunBatch(ctx context.Context, dir, url string, headers map[string]string, opts BatchOptions) (*BatchResult, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.graphql"))
+	if err != nil {
+		return nil, fmt.Errorf("error scanning batch directory: %w", err)
+	}
+
+	result := &BatchResult{Files: len(files), Failures: []BatchFailure{}, Index: []BatchEntry{}}
+	fail := func(file, op, class string, err error) {
+		logger.Info("%s failed (%s): %v", batchLabel(file, op), class, err)
+		result.Failures = append(result.Failures, BatchFailure{File: filepath.Base(file), Operation: op, Class: class, Error: err.Error()})
+	}
+
+	var steps []*batchStep
+files:
+	for _, qf := range files {
+		contentBytes, err := os.ReadFile(qf)
+		if err != nil {
+			fail(qf, "", FailureRead, err)
+			continue
+		}
+		fm, content, err := parseFrontMatter(string(contentBytes))
+		if err != nil {
+			fail(qf, "", FailureFrontMatter, err)
+			continue
+		}
+		if fm.Skip {
+			logger.Info("Skipping %s: %s is set", filepath.Base(qf), directiveSkip)
+			result.Skipped = append(result.Skipped, filepath.Base(qf))
+			continue
+		}
+		fileHeaders := mergeHeaders(headers, fm.Headers)
+		// Operations sent with other headers, e.g. for another tenant, are not duplicates.
+		headersJSON, _ := json.Marshal(fm.Headers)
+		ops, err := splitBatchFile(content, opts.KeepAllFragments)
+		if err != nil {
+			fail(qf, "", FailureSplit, err)
+			continue
+		}
+
+		// load variables file if present
+		varsFile := strings.TrimSuffix(qf, ".graphql") + ".json"
+		var vars map[string]interface{}
+		if data, err := os.ReadFile(varsFile); err == nil {
+			if err := json.Unmarshal(data, &vars); err != nil {
+				fail(qf, "", FailureVariables, fmt.Errorf("invalid JSON in %s: %w", filepath.Base(varsFile), err))
+				continue
+			}
+		} else if !os.IsNotExist(err) {
+			fail(qf, "", FailureRead, err)
+			continue
+		}
+
+		// load chain file if present
+		chainFile := strings.TrimSuffix(qf, ".graphql") + ChainSuffix
+		var links []ChainLink
+		if _, err := os.Stat(chainFile); err == nil {
+			if links, err = loadChain(chainFile); err != nil {
+				fail(qf, "", FailureChain, err)
+				continue
+			}
+		} else if !os.IsNotExist(err) {
+			fail(qf, "", FailureRead, err)
+			continue
+		}
+
+		fileSteps := make([]*batchStep, len(ops))
+		for i, op := range ops {
+			fileSteps[i] = &batchStep{file: qf, op: op, headers: fileHeaders, headersJSON: headersJSON, vars: vars, declared: declaredVariables(op.doc)}
+		}
+	links:
+		for _, l := range links {
+			for _, st := range fileSteps {
+				if st.op.name == l.From {
+					st.exports = append(st.exports, l)
+					continue links
+				}
+			}
+			fail(qf, "", FailureChain, fmt.Errorf("%s exports from %s, which is not an operation of the file", filepath.Base(chainFile), l.From))
+			continue files
+		}
+		steps = append(steps, fileSteps...)
+	}
+
+	steps, err = orderBatch(steps)
+	if err != nil {
+		return nil, err
+	}
+
+	// executed maps canonical hash and variables to the operation first sent with them.
+	executed := make(map[string]*batchStep)
+	// responses are the responses of the operations that succeeded, for the
+	// operations taking values from them.
+	responses := make(map[*batchStep]map[string]interface{})
+	for _, step := range steps {
+		qf, opDoc, opName := step.file, step.op.doc, step.op.name
+		result.Operations++
+		if step.op.err != nil {
+			fail(qf, opName, FailureSplit, step.op.err)
+			continue
+		}
+
+		vars := step.vars
+		if !opts.DryRun && !opts.Preview {
+			if vars, err = chainVariables(step, responses); err != nil {
+				fail(qf, opName, FailureChain, err)
+				continue
+			}
+		}
+		opVars := FillVariables(opDoc, vars, opts.VarsSchema)
+		if hash, err := gql.CanonicalHash(opDoc); err != nil {
+			logger.Debug("→ Not indexing %s: %v", batchLabel(qf, opName), err)
+		} else {
+			entry := BatchEntry{File: filepath.Base(qf), Operation: opName, Hash: hash}
+			varsJSON, _ := json.Marshal(opVars)
+			key := hash + "\x00" + string(varsJSON) + "\x00" + string(step.headersJSON)
+			first, seen := executed[key]
+			if seen {
+				entry.DuplicateOf = first.label()
+			} else {
+				executed[key] = step
+			}
+			result.Index = append(result.Index, entry)
+			if seen {
+				logger.Info("Skipping %s: same operation and variables as %s", batchLabel(qf, opName), first.label())
+				if resp, ok := responses[first]; ok {
+					responses[step] = resp
+				}
+				continue
+			}
+		}
+		if opts.DryRun {
+			for _, planned := range PlanOperations(filepath.Base(qf), opDoc) {
+				if planned.Name == "" {
+					planned.Name = opName
+				}
+				result.Planned = append(result.Planned, planned)
+			}
+			continue
+		}
+		if opts.Preview {
+			req, err := network.PreviewGraphQLRequest(ctx, url, opDoc, opVars, step.headers)
+			if err != nil {
+				fail(qf, opName, FailureTransport, err)
+				continue
+			}
+			result.Previews = append(result.Previews, OperationPreview{
+				Label:       batchLabel(qf, opName),
+				Request:     req,
+				Synthesized: Synthesized(vars, opVars),
+			})
+			continue
+		}
+		res, err := network.SendGraphQLRequestWithContext(ctx, url, opDoc, opVars, step.headers)
+		if err != nil {
+			fail(qf, opName, FailureTransport, err)
+			continue
+		}
+		if err := opts.Observer.Observe(opDoc, res); err != nil {
+			logger.Debug("→ Not observing %s: %v", batchLabel(qf, opName), err)
+		}
+		out, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Printf("Result for %s (from %s):\n%s\n", opName, filepath.Base(qf), string(out))
+		if messages := errorMessages(res); len(messages) > 0 {
+			fail(qf, opName, FailureGraphQL, fmt.Errorf("%s", strings.Join(messages, "; ")))
+			continue
+		}
+		responses[step] = res
+	}
+	return result, nil
+}
+
+// batchLabel names a failing file or operation in log messages.
+func batchLabel(file, op string) string {
+	if op == "" {
+		return filepath.Base(file)
+	}
+	return fmt.Sprintf("%s (in %s)", op, filepath.Base(file))
+}
+
+// errorMessages returns the messages of the errors array of a GraphQL response.
+func errorMessages(resp map[string]interface{}) []string {
+	var messages []string
+	errs, _ := resp["errors"].([]interface{})
+	for _, e := range errs {
+		if m, ok := e.(map[string]interface{}); ok {
+			if msg, ok := m["message"].(string); ok {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return messages
+}
+
+// PrintBatchSummary prints the failures of a batch run grouped by file, then
+// operation, then failure class.
+func PrintBatchSummary(r *BatchResult) {
+	duplicates := 0
+	for _, e := range r.Index {
+		if e.DuplicateOf != "" {
+			duplicates++
+		}
+	}
+	fmt.Printf("Batch summary: %d operation(s) from %d file(s), %d file(s) and %d duplicate(s) skipped, %d failure(s)\n", r.Operations, r.Files, len(r.Skipped), duplicates, len(r.Failures))
+	byFile := make(map[string][]BatchFailure)
+	var files []string
+	for _, f := range r.Failures {
+		if _, ok := byFile[f.File]; !ok {
+			files = append(files, f.File)
+		}
+		byFile[f.File] = append(byFile[f.File], f)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		fmt.Printf("  %s\n", file)
+		failures := byFile[file]
+		sort.SliceStable(failures, func(i, j int) bool {
+			if failures[i].Operation != failures[j].Operation {
+				return failures[i].Operation < failures[j].Operation
+			}
+			return failures[i].Class < failures[j].Class
+		})
+		for _, f := range failures {
+			op := f.Operation
+			if op == "" {
+				op = "(file)"
+			}
+			fmt.Printf("    %-24s %-10s %s\n", op, f.Class, f.Error)
+		}
+	}
+}
+
+// WriteBatchErrors writes the batch result, including its failure list and
+// operation index, as JSON to filename.
+func WriteBatchErrors(r *BatchResult, filename string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling batch errors: %w", err)
+	}
+	if err := artifacts.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("error writing batch errors: %w", err)
+	}
+	return nil
+}
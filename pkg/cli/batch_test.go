@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFrontMatterHeaders(t *testing.T) {
+	fm, rest, err := parseFrontMatter("# graphspecter-header: x-tenant:  acme \n# a comment\nquery Me { me { id } }\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"X-Tenant": "acme"}; !reflect.DeepEqual(fm.Headers, want) {
+		t.Errorf("headers = %v, want %v", fm.Headers, want)
+	}
+	if rest != "# a comment\nquery Me { me { id } }\n" {
+		t.Errorf("content = %q", rest)
+	}
+
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{"# graphspecter-header: X Tenant: acme\n{ me { id } }", "line 1: header name \"X Tenant\""},
+		{"# note\n# graphspecter-header: Host: internal.example\n{ me { id } }", "line 2: the Host header cannot be set"},
+		{"# graphspecter-skip: false\n# graphspecter-header: X-Tenant: a\x01b\n{ me { id } }", "line 2: value of header X-Tenant contains the control character"},
+	}
+	for _, tt := range tests {
+		if _, _, err := parseFrontMatter(tt.content); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseFrontMatter(%q) error = %v, want one containing %q", tt.content, err, tt.err)
+		}
+	}
+}
+
+func TestSplitBatchFile(t *testing.T) {
+	content := `query A { me { ...User } }
+subscription Events { events { id } }
+fragment User on User { id ...Org }
+fragment Org on User { org { name } }
+fragment Unused on User { secret }
+mutation { reset { ok } }
+query Broken { ...Gone }
+`
+	ops, err := splitBatchFile(content, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 3 || ops[0].name != "A" || ops[1].name != "(anonymous)" || ops[2].name != "Broken" {
+		t.Fatalf("split into %+v, want A, the anonymous mutation and Broken without the subscription", ops)
+	}
+	if want := "query A { me { ...User } }\n\nfragment User on User { id ...Org }\n\nfragment Org on User { org { name } }\n"; ops[0].doc != want || ops[0].err != nil {
+		t.Errorf("A = %q (%v), want %q", ops[0].doc, ops[0].err, want)
+	}
+	if ops[1].doc != "mutation { reset { ok } }\n" {
+		t.Errorf("the mutation = %q, want no fragments", ops[1].doc)
+	}
+	if ops[2].err == nil || !strings.Contains(ops[2].err.Error(), "undefined fragment(s) Gone") {
+		t.Errorf("Broken error = %v", ops[2].err)
+	}
+
+	ops, err = splitBatchFile(content, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ops[1].doc, "fragment Unused") || strings.Count(ops[0].doc, "fragment ") != 3 {
+		t.Errorf("with every fragment kept, A = %q and the mutation = %q", ops[0].doc, ops[1].doc)
+	}
+
+	if _, err := splitBatchFile("subscription Events { events { id } }", false); err == nil {
+		t.Error("a file of subscriptions only was split")
+	}
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
+)
+
+// bundleUsage is printed for invalid bundle invocations.
+const bundleUsage = "usage: bundle --workspace dir --out engagement.tar.gz [--no-redact] [-H header] | bundle extract --workspace dir engagement.tar.gz"
+
+// Bundle packages the workspace of cfg into a bundle, or with the extract
+// action restores a bundle into a new workspace, and returns the process exit
+// code.
+func Bundle(cfg *types.BundleConfig) int {
+	switch {
+	case len(cfg.Args) == 0:
+		if cfg.Workspace == "" || cfg.Out == "" {
+			fmt.Fprintln(os.Stderr, bundleUsage)
+			return 2
+		}
+		headers := make(map[string]string, len(cfg.Headers)+1)
+		for k, v := range cfg.Headers {
+			headers[k] = v
+		}
+		if token := os.Getenv("AUTH_TOKEN"); token != "" {
+			// Masked as the runs that sent it would have sent it.
+			headers["Authorization"] = "Bearer " + token
+		}
+		index, err := workspace.CreateBundle(cfg.Workspace, cfg.Out, workspace.BundleOptions{Redact: !cfg.NoRedact, Secrets: redact.Secrets(headers)})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if index.Redacted {
+			fmt.Printf("Bundled %d file(s) of %s into %s, %d value(s) redacted\n", len(index.Files), cfg.Workspace, cfg.Out, index.Redactions())
+		} else {
+			fmt.Printf("Bundled %d file(s) of %s into %s without redaction\n", len(index.Files), cfg.Workspace, cfg.Out)
+		}
+		return 0
+	case cfg.Args[0] == "extract" && len(cfg.Args) == 2 && cfg.Workspace != "":
+		index, err := workspace.ExtractBundle(cfg.Args[1], cfg.Workspace)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Restored %d file(s) into %s from the bundle created %s by %s %s\n", len(index.Files), cfg.Workspace, index.Created.Format("2006-01-02 15:04:05 MST"), index.Tool, index.Version)
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, bundleUsage)
+	return 2
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// Canary is a read query sent before and after the audit of each target. A
+// difference between the two responses means the scan changed server state.
+type Canary struct {
+	Path  string
+	Query string
+	// Diff selects the paths of the responses that are compared. The
+	// "extensions" member, which servers use for timings and tracing that
+	// change on every request, is always ignored.
+	Diff jsondiff.Options
+}
+
+// canaryIgnored are the paths of canary responses never compared.
+var canaryIgnored = []string{"extensions"}
+
+// LoadCanary reads the canary query in path. It must hold only query operations,
+// so that the canary itself cannot change anything.
+func LoadCanary(path string) (*Canary, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading canary query: %w", err)
+	}
+	doc, err := gql.Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing canary query %s: %w", path, err)
+	}
+	if len(doc.Operations) == 0 {
+		return nil, fmt.Errorf("canary query %s holds no operation", path)
+	}
+	for _, op := range doc.Operations {
+		if op.Kind != gql.OperationQuery {
+			return nil, fmt.Errorf("canary query %s must be a query, not a %s", path, op.Kind)
+		}
+	}
+	return &Canary{Path: path, Query: string(content)}, nil
+}
+
+// snapshot sends the canary to targetURL and returns the response. The
+// snapshot taken after a scan uses its own deadline, since the run context
+// may already have expired.
+func (c *Canary) snapshot(ctx context.Context, targetURL string, headers map[string]string) (map[string]interface{}, error) {
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), network.DefaultTimeout)
+		defer cancel()
+	}
+	return network.SendGraphQLRequestWithContext(ctx, targetURL, c.Query, nil, headers)
+}
+
+// normalizeCanary encodes a canary response with sorted keys and without
+// "extensions" for the report.
+func normalizeCanary(result map[string]interface{}) ([]byte, error) {
+	trimmed := make(map[string]interface{}, len(result))
+	for k, v := range result {
+		if k != "extensions" {
+			trimmed[k] = v
+		}
+	}
+	return json.Marshal(trimmed)
+}
+
+// compareCanary builds the canary result of targetURL from the snapshots taken
+// before and after its audit.
+func compareCanary(c *Canary, targetURL string, before map[string]interface{}, beforeErr error, after map[string]interface{}, afterErr error) report.CanaryResult {
+	result := report.CanaryResult{Endpoint: targetURL, Query: c.Path}
+	switch {
+	case beforeErr != nil:
+		result.Error = fmt.Sprintf("canary query failed before the scan: %v", beforeErr)
+	case afterErr != nil:
+		result.Error = fmt.Sprintf("canary query failed after the scan: %v", afterErr)
+	default:
+		opts := c.Diff
+		opts.Ignore = append(append([]string(nil), canaryIgnored...), opts.Ignore...)
+		differences := jsondiff.Compare(before, after, opts)
+		if len(differences) == 0 {
+			break
+		}
+		result.Drift = true
+		result.Diff = jsondiff.Render(differences)
+		beforeJSON, _ := normalizeCanary(before)
+		afterJSON, _ := normalizeCanary(after)
+		result.Before = string(beforeJSON)
+		result.After = string(afterJSON)
+	}
+
+	switch {
+	case result.Drift:
+		logger.Info("WARNING: STATE CHANGED on %s: the canary query %s returned a different response after the scan", targetURL, c.Path)
+	case result.Error != "":
+		logger.Info("WARNING: Could not verify the state of %s: %s", targetURL, result.Error)
+	default:
+		logger.Info("Canary query unchanged on %s", targetURL)
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
+)
+
+func TestLoadCanary(t *testing.T) {
+	dir := t.TempDir()
+	for name, tt := range map[string]struct{ content, err string }{
+		"read.graphql":     {"query A { me { id } }\nquery B { stats { orders } }", ""},
+		"mutation.graphql": {"query A { me { id } }\nmutation Reset { reset }", "must be a query, not a mutation"},
+		"fragment.graphql": {"fragment F on User { id }", "holds no operation"},
+		"broken.graphql":   {"query { me { id }", "error parsing canary query"},
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		c, err := LoadCanary(path)
+		if tt.err == "" {
+			if err != nil || c.Query != tt.content || c.Path != path {
+				t.Errorf("LoadCanary(%s) = %+v, %v", name, c, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("LoadCanary(%s) error = %v, want %q", name, err, tt.err)
+		}
+	}
+}
+
+func TestCompareCanary(t *testing.T) {
+	c := &Canary{Path: "canary.graphql", Diff: jsondiff.Options{Unordered: jsondiff.ParsePaths("data.ids")}}
+	snapshot := func(ids ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"data": map[string]interface{}{"ids": ids}, "extensions": map[string]interface{}{"cost": len(ids)}}
+	}
+	if got := compareCanary(c, "https://a.example/graphql", snapshot(1.0, 2.0), nil, snapshot(2.0, 1.0), nil); got.Drift || got.Outcome() != "unchanged" {
+		t.Errorf("reordered unordered array: %+v", got)
+	}
+	got := compareCanary(c, "https://a.example/graphql", snapshot(1.0), nil, snapshot(1.0, 2.0), nil)
+	if !got.Drift || got.Diff != "+ data.ids.1: 2" || got.Before != `{"data":{"ids":[1]}}` || got.After != `{"data":{"ids":[1,2]}}` {
+		t.Errorf("added element: %+v", got)
+	}
+
+	// A failing snapshot leaves the state unverified rather than changed.
+	failed := errors.New("connection refused")
+	for _, r := range []struct {
+		before, after error
+		want          string
+	}{
+		{failed, nil, "canary query failed before the scan: connection refused"},
+		{nil, failed, "canary query failed after the scan: connection refused"},
+	} {
+		got := compareCanary(c, "https://a.example/graphql", snapshot(1.0), r.before, snapshot(2.0), r.after)
+		if got.Drift || got.Error != r.want || got.Outcome() != "not verified: "+r.want {
+			t.Errorf("compareCanary() = %+v, want the error %q", got, r.want)
+		}
+	}
+}
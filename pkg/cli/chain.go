@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/auth"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"gopkg.in/yaml.v3"
+)
+
+// ChainSuffix names the file next to a batch file that exports values of the
+// responses of its operations: create_user.chain.yaml for create_user.graphql.
+const ChainSuffix = ".chain.yaml"
+
+// variableNameRegex matches GraphQL variable names.
+var variableNameRegex = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// ChainLink passes a value of the response of operation From, found at the
+// JSON path Path, to every other operation of the batch declaring the
+// variable Into, as the @export directive of some servers does:
+//
+//	from: CreateUser
+//	path: data.createUser.id
+//	into: $userId
+type ChainLink struct {
+	From string `yaml:"from"`
+	Path string `yaml:"path"`
+	Into string `yaml:"into"`
+}
+
+// loadChain reads the links of a chain file, a single link or a list of them.
+func loadChain(path string) ([]ChainLink, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return nil, fmt.Errorf("invalid YAML in %s: %w", filepath.Base(path), err)
+	}
+	var links []ChainLink
+	if len(node.Content) > 0 {
+		var target interface{} = &links
+		if node.Content[0].Kind == yaml.MappingNode {
+			links = make([]ChainLink, 1)
+			target = &links[0]
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		if err := dec.Decode(target); err != nil {
+			return nil, fmt.Errorf("invalid chain in %s: %w", filepath.Base(path), err)
+		}
+	}
+	if len(links) == 0 {
+		return nil, fmt.Errorf("%s declares no links", filepath.Base(path))
+	}
+	for i := range links {
+		l := &links[i]
+		l.Into = strings.TrimPrefix(strings.TrimSpace(l.Into), "$")
+		l.Path = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(l.Path), "$"), ".")
+		switch {
+		case l.From == "" || l.Path == "" || l.Into == "":
+			return nil, fmt.Errorf("link %d of %s needs from, path and into", i+1, filepath.Base(path))
+		case !variableNameRegex.MatchString(l.Into):
+			return nil, fmt.Errorf("link %d of %s: %q is not a variable name", i+1, filepath.Base(path), l.Into)
+		}
+	}
+	return links, nil
+}
+
+// batchStep is an operation of a batch run with what it is sent with.
+type batchStep struct {
+	file        string
+	op          batchOperation
+	headers     map[string]string
+	headersJSON []byte
+	vars        map[string]interface{}
+	// declared are the variables the operation defines.
+	declared map[string]bool
+	// exports are the links taking values from the response of the operation.
+	exports []ChainLink
+	// needs are the steps exporting variables the operation declares.
+	needs []*batchStep
+}
+
+// label names the step as BatchEntry.DuplicateOf does.
+func (s *batchStep) label() string {
+	return filepath.Base(s.file) + ":" + s.op.name
+}
+
+// declaredVariables returns the names of the variables a document defines.
+func declaredVariables(document string) map[string]bool {
+	doc, err := gql.Parse(document)
+	if err != nil {
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, op := range doc.Operations {
+		for _, def := range op.VariableDefinitions {
+			names[def.Name] = true
+		}
+	}
+	return names
+}
+
+// orderBatch links the steps exporting a variable to the steps declaring it
+// and orders them so that every step comes after the steps it needs. Steps
+// keep their order otherwise. A variable exported twice and a cycle of links are
+// an error.
+func orderBatch(steps []*batchStep) ([]*batchStep, error) {
+	exporters := make(map[string]*batchStep)
+	for _, s := range steps {
+		for _, l := range s.exports {
+			if other, ok := exporters[l.Into]; ok && other != s {
+				return nil, fmt.Errorf("$%s is exported by both %s and %s", l.Into, other.label(), s.label())
+			}
+			exporters[l.Into] = s
+		}
+	}
+	if len(exporters) == 0 {
+		return steps, nil
+	}
+
+	pending := make(map[*batchStep]int, len(steps))
+	for _, s := range steps {
+		seen := make(map[*batchStep]bool)
+		for name := range s.declared {
+			if from, ok := exporters[name]; ok && from != s && !seen[from] {
+				seen[from] = true
+				s.needs = append(s.needs, from)
+			}
+		}
+		pending[s] = len(s.needs)
+	}
+
+	ordered := make([]*batchStep, 0, len(steps))
+	done := make(map[*batchStep]bool, len(steps))
+	for len(ordered) < len(steps) {
+		var next *batchStep
+		for _, s := range steps {
+			if !done[s] && pending[s] == 0 {
+				next = s
+				break
+			}
+		}
+		if next == nil {
+			var cycle []string
+			for _, s := range steps {
+				if !done[s] {
+					cycle = append(cycle, s.label())
+				}
+			}
+			return nil, fmt.Errorf("chained operations form a cycle; cannot order %s", strings.Join(cycle, ", "))
+		}
+		done[next] = true
+		ordered = append(ordered, next)
+		for _, s := range steps {
+			for _, n := range s.needs {
+				if n == next {
+					pending[s]--
+				}
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// chainVariables returns the variables of step with the values exported by
+// the steps it needs taken from their responses. A step whose response is
+// missing, because it failed or was not sent, leaves its variables without a
+// value, which is an error.
+func chainVariables(step *batchStep, responses map[*batchStep]map[string]interface{}) (map[string]interface{}, error) {
+	if len(step.needs) == 0 {
+		return step.vars, nil
+	}
+	vars := make(map[string]interface{}, len(step.vars)+len(step.needs))
+	for k, v := range step.vars {
+		vars[k] = v
+	}
+	for _, from := range step.needs {
+		for _, l := range from.exports {
+			if !step.declared[l.Into] {
+				continue
+			}
+			resp, ok := responses[from]
+			if !ok {
+				return nil, fmt.Errorf("no value for $%s: %s did not succeed", l.Into, batchLabel(from.file, from.op.name))
+			}
+			value, err := auth.LookupJSON(resp, l.Path)
+			if err != nil {
+				return nil, fmt.Errorf("no value for $%s: %v in the response of %s", l.Into, err, batchLabel(from.file, from.op.name))
+			}
+			if value == nil {
+				return nil, fmt.Errorf("no value for $%s: %s is null in the response of %s", l.Into, l.Path, batchLabel(from.file, from.op.name))
+			}
+			vars[l.Into] = value
+		}
+	}
+	return vars, nil
+}
@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// chainDocument holds a three-operation chain in reverse file order: GetPost
+// needs the post CreatePost makes for the user CreateUser makes.
+const chainDocument = `query GetPost($postId: Int!) { post(id: $postId) { title } }
+mutation CreatePost($userId: ID!, $title: String) { createPost(author: $userId, title: $title) { id } }
+mutation CreateUser { createUser(name: "alice") { id } }
+`
+
+const chainLinks = `- from: CreateUser
+  path: data.createUser.id
+  into: $userId
+- from: CreatePost
+  path: $.data.createPost.id
+  into: postId
+`
+
+// chainServer answers the operations of chainDocument, CreateUser with
+// createUser, and records the operations and variables it receives.
+type chainServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	received []string
+}
+
+func newChainServer(t *testing.T, createUser string) *chainServer {
+	t.Helper()
+	s := &chainServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.GraphQLRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		vars, _ := json.Marshal(req.Variables)
+		var name, response string
+		switch {
+		case strings.HasPrefix(req.Query, "mutation CreateUser"):
+			name, response = "CreateUser", createUser
+		case strings.HasPrefix(req.Query, "mutation CreatePost"):
+			name, response = "CreatePost", `{"data":{"createPost":{"id":42}}}`
+		case strings.HasPrefix(req.Query, "query GetPost"):
+			name, response = "GetPost", `{"data":{"post":{"title":"hello"}}}`
+		}
+		s.mu.Lock()
+		s.received = append(s.received, name+" "+string(vars))
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// chainBatch writes chainDocument, its variables and chainLinks to a batch
+// directory.
+func chainBatch(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"posts.graphql":    chainDocument,
+		"posts.json":       `{"title": "hello", "userId": "from-the-file"}`,
+		"posts.chain.yaml": chainLinks,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunBatchChainsThreeOperations(t *testing.T) {
+	srv := newChainServer(t, `{"data":{"createUser":{"id":"u-1"}}}`)
+	var result *BatchResult
+	var err error
+	captureStdout(t, func() { result, err = RunBatch(context.Background(), chainBatch(t), srv.URL, nil, BatchOptions{}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Failures) != 0 || result.Operations != 3 {
+		t.Fatalf("result = %+v", result)
+	}
+	// The operations run in dependency order. The exported values keep their
+	// JSON type and take over the one of the variables file.
+	want := []string{
+		`CreateUser {"title":"hello","userId":"from-the-file"}`,
+		`CreatePost {"title":"hello","userId":"u-1"}`,
+		`GetPost {"postId":42,"title":"hello","userId":"from-the-file"}`,
+	}
+	if !reflect.DeepEqual(srv.received, want) {
+		t.Errorf("the server received\n%s\nwant\n%s", strings.Join(srv.received, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A dry run plans the same order without sending anything.
+	srv.received = nil
+	result, err = RunBatch(context.Background(), chainBatch(t), srv.URL, nil, BatchOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var planned []string
+	for _, op := range result.Planned {
+		planned = append(planned, op.Name)
+	}
+	if !reflect.DeepEqual(planned, []string{"CreateUser", "CreatePost", "GetPost"}) || srv.received != nil {
+		t.Errorf("planned %v and sent %v", planned, srv.received)
+	}
+}
+
+func TestRunBatchChainSourceFails(t *testing.T) {
+	for _, tt := range []struct {
+		name, createUser, reason string
+	}{
+		{"errors", `{"data":null,"errors":[{"message":"name taken"}]}`, "no value for $userId: CreateUser (in posts.graphql) did not succeed"},
+		{"missing path", `{"data":{"createUser":{}}}`, "no value for $userId: "},
+		{"null value", `{"data":{"createUser":{"id":null}}}`, "no value for $userId: data.createUser.id is null in the response of CreateUser (in posts.graphql)"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newChainServer(t, tt.createUser)
+			var result *BatchResult
+			var err error
+			captureStdout(t, func() { result, err = RunBatch(context.Background(), chainBatch(t), srv.URL, nil, BatchOptions{}) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Only the source is sent; both dependents fail without a request.
+			if len(srv.received) != 1 || !strings.HasPrefix(srv.received[0], "CreateUser") {
+				t.Errorf("the server received %v", srv.received)
+			}
+			failures := make(map[string]BatchFailure)
+			for _, f := range result.Failures {
+				failures[f.Operation] = f
+			}
+			if f := failures["CreatePost"]; f.Class != FailureChain || !strings.Contains(f.Error, tt.reason) {
+				t.Errorf("CreatePost failure = %+v, want %q", f, tt.reason)
+			}
+			if f := failures["GetPost"]; f.Class != FailureChain || f.Error != "no value for $postId: CreatePost (in posts.graphql) did not succeed" {
+				t.Errorf("GetPost failure = %+v", f)
+			}
+		})
+	}
+}
+
+func TestRunBatchChainErrors(t *testing.T) {
+	srv := newChainServer(t, `{"data":{"createUser":{"id":"u-1"}}}`)
+	for _, tt := range []struct {
+		name, links, want string
+	}{
+		{"cycle", "- {from: CreatePost, path: data.createPost.id, into: postId}\n- {from: GetPost, path: data.post.title, into: title}\n", "chained operations form a cycle; cannot order posts.graphql:GetPost, posts.graphql:CreatePost"},
+		{"duplicate export", "- {from: CreateUser, path: data.createUser.id, into: userId}\n- {from: GetPost, path: data.post.title, into: userId}\n", "$userId is exported by both posts.graphql:"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := chainBatch(t)
+			if err := os.WriteFile(filepath.Join(dir, "posts.chain.yaml"), []byte(tt.links), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := RunBatch(context.Background(), dir, srv.URL, nil, BatchOptions{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("RunBatch() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if len(srv.received) != 0 {
+		t.Errorf("the server received %v before the chain was refused", srv.received)
+	}
+
+	// Invalid chain files fail their batch file.
+	for links, want := range map[string]string{
+		"from: CreateUser\npath: data.createUser.id\n":                   "link 1 of posts.chain.yaml needs from, path and into",
+		"from: CreateUser\npath: data.createUser.id\ninto: $user-id\n":   `"user-id" is not a variable name`,
+		"from: CreateUser\npath: data.createUser.id\ninto: a\nwhen: x\n": "field when not found",
+		"from: DeleteUser\npath: data.deleteUser.id\ninto: userId\n":     "exports from DeleteUser, which is not an operation of the file",
+	} {
+		dir := chainBatch(t)
+		if err := os.WriteFile(filepath.Join(dir, "posts.chain.yaml"), []byte(links), 0644); err != nil {
+			t.Fatal(err)
+		}
+		result, err := RunBatch(context.Background(), dir, srv.URL, nil, BatchOptions{})
+		if err != nil || len(result.Failures) != 1 || result.Failures[0].Class != FailureChain || !strings.Contains(result.Failures[0].Error, want) {
+			t.Errorf("chain %q: %+v, %v; want %q", links, result, err, want)
+		}
+	}
+}
@@ -3,17 +3,31 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/CyberRoute/graphspecter/pkg/attacks"
+	"github.com/CyberRoute/graphspecter/pkg/auth"
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/inference"
 	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
 	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/report"
 	"github.com/CyberRoute/graphspecter/pkg/schema"
 	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
 )
 
 func DisplayLogo() {
@@ -47,19 +61,33 @@ func SetupSignalHandler(parent context.Context) (context.Context, context.Cancel
 	return ctx, cancel
 }
 
-// HandleSchemaFile processes an introspection JSON file and handles schema-related operations.
-func HandleSchemaFile(filePath, listOption, queryOption, mutationOption string, allQueries, allMutations bool, maxDepth int) {
+// PrintSchemaError reports to stderr why filePath could not be loaded as a schema,
+// with advice on fixing the file when the error has any.
+func PrintSchemaError(filePath string, err error) {
+	fmt.Fprintf(os.Stderr, "Failed to load schema from %s: %v\n", filePath, err)
+	if hint := schema.Hint(err); hint != "" {
+		fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+	}
+}
+
+// HandleSchemaFile processes an introspection JSON file and handles schema-related
+// operations, returning the exit code. Listings show the notes of each
+// operation and, with tag set, only the operations tagged with it.
+func HandleSchemaFile(filePath, listOption, queryOption, mutationOption string, allQueries, allMutations bool, maxDepth int, selection, sortMode string, notes schema.Notes, tag string, sample schema.Sample) int {
 	// Load the schema from file
 	schemaObj, err := schema.LoadFromFile(filePath)
 	if err != nil {
-		logger.Error("Failed to load schema: %v", err)
-		os.Exit(1)
+		PrintSchemaError(filePath, err)
+		return 1
+	}
+	for _, w := range notes.Unknown(schemaObj) {
+		logger.Info("WARNING: %s", w)
 	}
 
 	// Handle the list option to print available queries and mutations
 	if listOption != "" {
-		PrintAvailableOperations(schemaObj, listOption)
-		return
+		PrintAvailableOperations(schemaObj, listOption, sortMode, notes, tag)
+		return 0
 	}
 
 	// Determine whether to print specific queries/mutations or all.
@@ -71,38 +99,171 @@ func HandleSchemaFile(filePath, listOption, queryOption, mutationOption string,
 		allMutations = true
 	}
 
+	if queryOption != "" && schemaObj.Query == nil {
+		fmt.Fprintf(os.Stderr, "%s declares no query type\n", filePath)
+		return 1
+	}
+	if mutationOption != "" && schemaObj.Mutation == nil {
+		fmt.Fprintf(os.Stderr, "%s declares no mutation type\n", filePath)
+		return 1
+	}
+	if allQueries && schemaObj.Query == nil {
+		logger.Info("The schema declares no query type")
+	}
+	keep := sampleOperations(schemaObj, sample)
+	index := schema.NewIndex(schemaObj)
+
 	// Print queries
-	if allQueries || queryOption != "" {
+	if (allQueries || queryOption != "") && schemaObj.Query != nil {
 		var queryNames []string
 		if allQueries {
-			queryNames = schema.ListQueries(schemaObj)
+			queryNames = schema.SortNames(sampled(keep, schema.KindQuery, schema.ListQueries(schemaObj)), sortMode)
 		} else {
 			queryNames = strings.Split(queryOption, ",")
 		}
-		GenerateAndPrintOperations(schema.GenerateQuery, schemaObj, queryNames, maxDepth, "query")
+		GenerateAndPrintOperations(index.GenerateQuery, queryNames, maxDepth, selection, "query")
 	}
 
 	// Print mutations
 	if (allMutations || mutationOption != "") && schemaObj.Mutation != nil {
 		var mutationNames []string
 		if allMutations {
-			mutationNames = schema.ListMutations(schemaObj)
+			mutationNames = schema.SortNames(sampled(keep, schema.KindMutation, schema.ListMutations(schemaObj)), sortMode)
 		} else {
 			mutationNames = strings.Split(mutationOption, ",")
 		}
-		GenerateAndPrintOperations(schema.GenerateMutation, schemaObj, mutationNames, maxDepth, "mutation")
+		GenerateAndPrintOperations(index.GenerateMutation, mutationNames, maxDepth, selection, "mutation")
+	}
+	return 0
+}
+
+// sampleOperations returns the operations of schemaObj sample keeps, nil for
+// all of them, and says how many it kept.
+func sampleOperations(schemaObj *types.GQLSchema, sample schema.Sample) schema.OperationSet {
+	keep, err := sample.Select(schemaObj)
+	if err != nil {
+		logger.Info("WARNING: %v; keeping every operation", err)
+		return nil
+	}
+	if keep != nil {
+		logger.Info("Sampled %d operations with the %s strategy", len(keep), sample.Strategy)
 	}
+	return keep
+}
+
+// sampled returns the names of the kind operations keep holds.
+func sampled(keep schema.OperationSet, kind string, names []string) []string {
+	if keep == nil {
+		return names
+	}
+	var kept []string
+	for _, name := range names {
+		if keep.Has(kind, name) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// WriteSchemaCatalog builds the operation catalog of an introspection JSON file
+// and writes it to catalogFile as JSON or, with format "csv", as CSV. It returns
+// the exit code.
+func WriteSchemaCatalog(filePath, catalogFile, format string, maxDepth int, selection, sortMode string, notes schema.Notes, sample schema.Sample) int {
+	schemaObj, err := schema.LoadFromFile(filePath)
+	if err != nil {
+		PrintSchemaError(filePath, err)
+		return 1
+	}
+	for _, w := range notes.Unknown(schemaObj) {
+		logger.Info("WARNING: %s", w)
+	}
+
+	sampleOperations(schemaObj, sample)
+	catalog := schema.BuildCatalog(schemaObj, schema.CatalogOptions{MaxDepth: maxDepth, Selection: selection, Sort: sortMode, Notes: notes, Sample: sample})
+	write := schema.WriteCatalog
+	if format == "csv" {
+		write = report.WriteCatalogCSV
+	}
+	if err := write(catalog, catalogFile); err != nil {
+		logger.Error("%v", err)
+		return 1
+	}
+	logger.Info("Operation catalog with %d operations saved to %s", len(catalog.Operations), catalogFile)
+	return 0
+}
+
+// WriteSchemaSDL writes the introspection JSON file filePath as SDL to
+// sdlFile. It returns the exit code.
+func WriteSchemaSDL(filePath, sdlFile string) int {
+	schemaObj, err := schema.LoadFromFile(filePath)
+	if err != nil {
+		PrintSchemaError(filePath, err)
+		return 1
+	}
+	if err := schema.WriteSDL(schemaObj, sdlFile); err != nil {
+		logger.Error("%v", err)
+		return 1
+	}
+	logger.Info("Schema with %d types saved as SDL to %s", len(schemaObj.Types), sdlFile)
+	return 0
+}
+
+// ExportSchemaOperations writes the executable operations of an introspection
+// JSON file to dir with schema.ExportOperations. It returns the exit code.
+func ExportSchemaOperations(filePath, dir string, maxDepth int, selection, sortMode string, sample schema.Sample) int {
+	schemaObj, err := schema.LoadFromFile(filePath)
+	if err != nil {
+		PrintSchemaError(filePath, err)
+		return 1
+	}
+
+	sampleOperations(schemaObj, sample)
+	catalog := schema.BuildCatalog(schemaObj, schema.CatalogOptions{MaxDepth: maxDepth, Selection: selection, Sort: sortMode, Sample: sample})
+	manifest, err := schema.ExportOperations(catalog, dir)
+	if err != nil {
+		logger.Error("%v", err)
+		return 1
+	}
+	for _, s := range manifest.Skipped {
+		logger.Info("Skipped %s %s: %s", s.Kind, s.Operation, s.Error)
+	}
+	logger.Info("Exported %d operations to %s (see %s)", len(manifest.Operations), dir, schema.ManifestFile)
+	return 0
+}
+
+// ExportSchemaVariables writes the JSON Schema of the variables of every
+// operation of an introspection JSON file to dir with
+// schema.ExportVariablesSchemas. It returns the exit code.
+func ExportSchemaVariables(filePath, dir string, maxDepth int, selection, sortMode string, sample schema.Sample) int {
+	schemaObj, err := schema.LoadFromFile(filePath)
+	if err != nil {
+		PrintSchemaError(filePath, err)
+		return 1
+	}
+
+	sampleOperations(schemaObj, sample)
+	catalog := schema.BuildCatalog(schemaObj, schema.CatalogOptions{MaxDepth: maxDepth, Selection: selection, Sort: sortMode, Sample: sample})
+	manifest, err := schema.ExportVariablesSchemas(schemaObj, catalog, dir)
+	if err != nil {
+		logger.Error("%v", err)
+		return 1
+	}
+	for _, s := range manifest.Skipped {
+		logger.Info("Skipped %s %s: %s", s.Kind, s.Operation, s.Error)
+	}
+	logger.Info("Exported the variables schemas of %d operations to %s", len(manifest.Operations), dir)
+	return 0
 }
 
 func GenerateAndPrintOperations(
-	generateFn func(*types.GQLSchema, string, int) (string, error),
-	schemaObj *types.GQLSchema,
+	generateFn func(string, int, string) (string, error),
 	names []string,
 	maxDepth int,
+	selection string,
 	opType string,
 ) {
 	for _, name := range names {
-		op, err := generateFn(schemaObj, name, maxDepth)
+		op, err := generateFn(name, maxDepth, selection)
 		if err != nil {
 			logger.Error("Failed to generate %s for %s: %v", opType, name, err)
 			continue
@@ -111,80 +272,806 @@ func GenerateAndPrintOperations(
 	}
 }
 
-// PrintAvailableOperations prints the names of queries and/or mutations in the schema.
-func PrintAvailableOperations(schemaObj *types.GQLSchema, listOption string) {
+// PrintAvailableOperations prints the names of queries and/or mutations in the
+// schema, each followed by its tags and notes. When tag is set only the
+// operations tagged with it are printed.
+func PrintAvailableOperations(schemaObj *types.GQLSchema, listOption, sortMode string, notes schema.Notes, tag string) {
 	if listOption == "queries" || listOption == "all" {
-		for _, queryName := range schema.ListQueries(schemaObj) {
-			fmt.Printf("query %s\n", queryName)
+		for _, queryName := range schema.SortNames(schema.ListQueries(schemaObj), sortMode) {
+			printListedOperation(schemaObj.Query, schema.KindQuery, queryName, notes, tag)
 		}
 	}
 
 	if (listOption == "mutations" || listOption == "all") && schemaObj.Mutation != nil {
-		for _, mutationName := range schema.ListMutations(schemaObj) {
-			fmt.Printf("mutation %s\n", mutationName)
+		for _, mutationName := range schema.SortNames(schema.ListMutations(schemaObj), sortMode) {
+			printListedOperation(schemaObj.Mutation, schema.KindMutation, mutationName, notes, tag)
 		}
 	}
 }
 
-// AuditEndpoints checks each target URL for introspection and writes the results to file.
-func AuditEndpoints(timeoutCtx context.Context, targetURLs []string, headers map[string]string, outputFile string) {
-	// Track if we found at least one endpoint with introspection enabled.
-	introspectionEnabled := false
-	var lastIntrospectionResult map[string]interface{}
+// printListedOperation prints a line of PrintAvailableOperations for the root
+// field name of root, unless tag is set and the field is not tagged with it.
+func printListedOperation(root *types.Type, kind, name string, notes schema.Notes, tag string) {
+	var field types.Field
+	for _, f := range root.Fields {
+		if f.Name == name {
+			field = f
+			break
+		}
+	}
+	texts, tags := notes.Operation(kind, field)
+	if tag != "" && !schema.HasTag(tags, tag) {
+		return
+	}
+	line := kind + " " + name
+	if len(tags) > 0 {
+		line += " [" + strings.Join(tags, ", ") + "]"
+	}
+	if len(texts) > 0 {
+		line += " - " + strings.Join(texts, "; ")
+	}
+	fmt.Println(line)
+}
+
+// PrintChecks prints every registered audit check with its severity, requirements and description.
+func PrintChecks() {
+	for _, c := range checks.All() {
+		fmt.Printf("%-20s %-8s %-9s %-8s %s\n", c.ID(), c.Severity(), checks.Safety(c), checks.Requires(c), c.Description())
+	}
+}
+
+// PrintWordlists prints the built-in wordlists with their size and description.
+func PrintWordlists() {
+	for _, w := range inference.BuiltinWordlists() {
+		fmt.Printf("%-30s %6d  %s\n", inference.BuiltinPrefix+w.Name, w.Words, w.Description)
+	}
+}
+
+// AuditOptions controls which checks and follow-up modules AuditEndpoints runs.
+type AuditOptions struct {
+	OutputFile string
+	Checks     []checks.Check
+	Preset     string
+	Extract    bool
+	ExtractDir string
+	// Pagination controls whether extraction pages through paginated queries.
+	Pagination attacks.ExtractOptions
+	WSURL      string
+	// MaxDepth bounds the selection sets of the generated operation catalog.
+	MaxDepth int
+	// Selection is the projection of those selection sets.
+	Selection string
+	// Notes annotate the operations of the catalog.
+	Notes schema.Notes
+	// Sample selects the operations of the catalog, and so those extracted.
+	Sample schema.Sample
+	// ChunkedIntrospection fetches schemas in batches of IntrospectionChunkSize types.
+	ChunkedIntrospection   bool
+	IntrospectionChunkSize int
+	// RedactArtifacts masks sensitive values in saved introspection dumps.
+	RedactArtifacts bool
+	// Injection tunes the time-based injection probes.
+	Injection attacks.InjectionOptions
+	// Policy decides when the run is cut short.
+	Policy checks.Policy
+	// State, when set, records finished targets and skips the ones it already holds.
+	State *workspace.State
+	// Saved, when set, is audited instead of the introspection result of each target.
+	Saved *SavedIntrospection
+	// Offline runs only the checks that send no requests.
+	Offline bool
+	// Canary, when set, is sent before and after the audit of each target to
+	// detect state changed by the scan.
+	Canary *Canary
+	// Whoami is the query verifying the supplied credentials on each target,
+	// auth.DefaultWhoami when empty.
+	Whoami string
+	// Schemas remembers the schemas of the previous iteration of a watch.
+	Schemas checks.SchemaStore
+	// Sinks, when set, receive the findings as they are found.
+	Sinks *report.Dispatcher
+	// VirtualHosts are the host names of --vhosts. A run given some detects
+	// the endpoints served under each with DetectVirtualHosts instead of
+	// auditing.
+	VirtualHosts []string
+	// Matrix, when set, records the access state of every extracted
+	// operation on each target, written out as the access matrix of the run.
+	Matrix *report.MatrixRecorder
+	// Identities, when set, send every query of the catalog of each target
+	// as each of them, for the authorization matrix of the target.
+	Identities []types.Identity
+	// AuthzDiff leaves out of the comparison of the data returned to the
+	// identities the paths expected to differ between them.
+	AuthzDiff jsondiff.Options
+}
+
+// AuditEndpoints runs the selected checks against each target URL and returns the collected report.
+func AuditEndpoints(timeoutCtx context.Context, targetURLs []string, headers map[string]string, opts AuditOptions) *report.Report {
+	targets := make(chan string, len(targetURLs))
+	for _, u := range targetURLs {
+		targets <- u
+	}
+	close(targets)
+	return AuditStream(timeoutCtx, targets, headers, opts)
+}
+
+// AuditStream is AuditEndpoints for targets that arrive while the audit runs,
+// such as endpoints streamed by detection. It returns once targets is closed or
+// the run is stopped by the policy.
+func AuditStream(timeoutCtx context.Context, targets <-chan string, headers map[string]string, opts AuditOptions) *report.Report {
+	rep := &report.Report{Metadata: report.NewMetadata()}
+	ctl, runCtx := checks.NewController(timeoutCtx, opts.Policy)
+	defer ctl.Close()
+	ctl.PublishTo(opts.Sinks)
+
+	selected := opts.Checks
+	if opts.Offline {
+		selected = offlineChecks(selected)
+	}
+	rep.Metadata.Preset = opts.Preset
+	rep.Metadata.Checks = checks.IDs(selected)
+	logger.Info("Checks: %s", strings.Join(rep.Metadata.Checks, ", "))
+	var savedCatalog *schema.Catalog
+	if opts.Saved != nil {
+		savedCatalog = schema.BuildCatalog(opts.Saved.Schema, schema.CatalogOptions{MaxDepth: opts.MaxDepth, Selection: opts.Selection, Notes: opts.Notes, Sample: opts.Sample})
+		for _, w := range opts.Notes.Unknown(opts.Saved.Schema) {
+			logger.Info("WARNING: %s", w)
+		}
+	}
 
 	// Loop through each target URL.
-	for _, targetURL := range targetURLs {
-		logger.Info("Checking target: %s", targetURL)
-		logger.Info("Checking if introspection is enabled on %s...", targetURL)
-		introspectionResult, err := introspection.CheckIntrospectionWithContext(timeoutCtx, targetURL, headers)
-		if err != nil {
-			if strings.Contains(err.Error(), "HTML response") || strings.Contains(err.Error(), "non-JSON response") {
-				logger.Warn("The endpoint %s doesn't appear to be a valid GraphQL endpoint: %v", targetURL, err)
-				logger.Info("This may be a false positive or the endpoint requires special headers/authentication")
+	for targetURL := range targets {
+		if ctl.Stopped() != nil {
+			logger.Info("Skipping remaining targets")
+			break
+		}
+		// Interrupted: the targets not started yet stay pending in the state.
+		if runCtx.Err() != nil {
+			logger.Info("Run interrupted, skipping remaining targets")
+			break
+		}
+		rep.Endpoints = append(rep.Endpoints, targetURL)
+		if opts.State != nil {
+			if findings, done := opts.State.Done(targetURL); done {
+				logger.Info("Skipping %s: completed in a previous run", targetURL)
+				for _, f := range findings {
+					ctl.Publish(f)
+				}
+				rep.Findings = append(rep.Findings, findings...)
 				continue
 			}
-			logger.Error("Error checking introspection on %s: %v", targetURL, err)
-			continue
+			if err := opts.State.Start(targetURL); err != nil {
+				logger.Error("Error saving scan state: %v", err)
+			}
 		}
+		logger.Info("Checking target: %s", targetURL)
+		deps := &checks.Deps{
+			Headers:         headers,
+			OutputFile:      opts.OutputFile,
+			RedactArtifacts: opts.RedactArtifacts,
+			WSURL:           opts.WSURL,
+			MaxDepth:        opts.MaxDepth,
+			Selection:       opts.Selection,
+			Notes:           opts.Notes,
+			Sample:          opts.Sample,
+			Injection:       opts.Injection,
 
-		lastIntrospectionResult = introspectionResult
+			ChunkedIntrospection:   opts.ChunkedIntrospection,
+			IntrospectionChunkSize: opts.IntrospectionChunkSize,
 
-		if introspection.IsIntrospectionEnabled(introspectionResult) {
-			logger.Warn("WARNING: Introspection is ENABLED on %s!", targetURL)
-			outName := outputFile
-			outName = generateOutputFileName(outputFile, targetURL)
-			err = introspection.WriteIntrospectionToFile(introspectionResult, outName)
-			if err != nil {
-				logger.Error("Error writing introspection result to file: %v", err)
-				continue
+			IntrospectionTier: introspection.TierNone,
+			Schemas:           opts.Schemas,
+		}
+		if opts.Saved != nil {
+			opts.Saved.apply(deps, savedCatalog)
+		}
+		if hasCredentials(headers) && !opts.Offline {
+			verification := auth.Verify(runCtx, targetURL, headers, opts.Whoami)
+			logVerification(verification)
+			deps.Auth = &verification
+			rep.Metadata.Auth = append(rep.Metadata.Auth, verification)
+		}
+		var canaryBefore map[string]interface{}
+		var canaryErr error
+		if opts.Canary != nil {
+			canaryBefore, canaryErr = opts.Canary.snapshot(runCtx, targetURL, headers)
+		}
+		findings, results := checks.Run(runCtx, ctl, selected, targetURL, deps)
+		rep.Checks = append(rep.Checks, results...)
+		if len(deps.Engines) > 0 {
+			if rep.Engines == nil {
+				rep.Engines = make(map[string]string)
 			}
-			logger.Info("Introspection data saved to %s", outName)
-			introspectionEnabled = true
-		} else {
-			logger.Info("Introspection appears to be disabled on %s", targetURL)
+			rep.Engines[targetURL] = deps.Engines[0].Engine
+		}
+		if deps.Capabilities != nil {
+			rep.Capabilities = append(rep.Capabilities, *deps.Capabilities)
+		}
+		if deps.Catalog != nil {
+			rep.Catalogs = append(rep.Catalogs, report.EndpointCatalog{Endpoint: targetURL, Catalog: deps.Catalog})
 		}
+
+		if opts.Extract && !opts.Offline && ctl.Stopped() == nil {
+			extracted := runExtraction(runCtx, targetURL, headers, deps, opts.ExtractDir, opts.Pagination, opts.Matrix)
+			for _, f := range extracted {
+				ctl.Finding(f)
+			}
+			findings = append(findings, extracted...)
+		}
+		if len(opts.Identities) > 0 && !opts.Offline && ctl.Stopped() == nil {
+			m, authz := runAuthz(runCtx, targetURL, headers, deps, opts.Identities, opts.Whoami, opts.AuthzDiff)
+			if m != nil {
+				rep.Authz = append(rep.Authz, *m)
+				rep.Metadata.Auth = append(rep.Metadata.Auth, m.Auth...)
+			}
+			for _, f := range authz {
+				ctl.Finding(f)
+			}
+			findings = append(findings, authz...)
+		}
+		rep.Findings = append(rep.Findings, findings...)
+		if opts.Canary != nil {
+			canaryAfter, afterErr := opts.Canary.snapshot(runCtx, targetURL, headers)
+			rep.Canaries = append(rep.Canaries, compareCanary(opts.Canary, targetURL, canaryBefore, canaryErr, canaryAfter, afterErr))
+		}
+
+		// A target cut short by the run context stays in progress and is
+		// scanned again on resume.
+		if opts.State != nil && runCtx.Err() == nil {
+			if err := opts.State.Finish(targetURL, findings); err != nil {
+				logger.Error("Error saving scan state: %v", err)
+			}
+		}
+		ctl.TargetDone(targetURL)
 	}
+	rep.Stopped = ctl.Stopped()
+	rep.Encodings = endpointEncodings(rep.Endpoints)
+	rep.OperationNotes = report.AnnotatedOperations(rep.Catalogs)
+	rep.Metadata.CheckTimesMs = report.CheckTimes(rep.Checks)
+
+	var late []report.Finding
+	late = append(late, rateLimitFindings(rep)...)
+	late = append(late, contentTypeFindings(rep.Endpoints)...)
+	late = append(late, bodyFindings(rep.Endpoints)...)
+	if opts.Matrix != nil {
+		late = append(late, writeMatrix(rep, opts.Matrix)...)
+	}
+	for _, f := range late {
+		ctl.Publish(f)
+	}
+	rep.Findings = append(rep.Findings, late...)
 
 	// Output summary.
-	if introspectionEnabled {
+	if rep.HasFinding("introspection-enabled") {
 		logger.Warn("WARNING: Introspection is ENABLED on at least one endpoint!")
-	} else if lastIntrospectionResult != nil {
+	} else if rep.HasFinding("introspection-partial") {
+		logger.Warn("WARNING: Introspection is PARTIALLY enabled on at least one endpoint!")
+	} else if introspectionChecked(rep) {
 		logger.Info("Introspection appears to be disabled on all checked endpoints")
 	}
-	logger.Info("Audit completed")
+	if rep.Stopped != nil {
+		logger.Info("Audit cut short: %s", rep.Stopped.Reason)
+	}
+	logger.Info("Audit completed: %d finding(s) from %d check run(s)", len(rep.Findings), len(rep.Checks))
+	return rep
+}
+
+// DetectAndAudit scans each base URL for GraphQL endpoints and audits every
+// endpoint as soon as it is confirmed, while detection of the others continues.
+// The report lists the audited endpoints in detection order. An error is returned
+// when no endpoint was detected; endpoints confirmed before detection timed out
+// are still audited.
+func DetectAndAudit(ctx context.Context, bases []string, headers map[string]string, opts AuditOptions) (*report.Report, error) {
+	logger.Info("Detection mode enabled. Scanning for GraphQL endpoints...")
+	detectCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	targets := make(chan string)
+	send := func(endpoint string) {
+		select {
+		case targets <- endpoint:
+		case <-detectCtx.Done():
+		}
+	}
+	var detected []string
+	var candidates []types.AuthCandidate
+	var detectErr error
+	credentialed := hasCredentials(headers)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(targets)
+		detectCtx, stop := network.StartModule(detectCtx, "detection")
+		defer stop()
+		for _, base := range bases {
+			result, err := network.DetectEndpoints(detectCtx, base, false, send)
+			if result != nil {
+				detected = append(detected, result.Endpoints...)
+				for _, c := range result.AuthCandidates {
+					// Detection probes without credentials; retry the
+					// candidates with the ones supplied.
+					if credentialed && detectCtx.Err() == nil {
+						if ok, _ := network.IsGraphQLEndpointWithHeaders(detectCtx, c.URL, headers); ok {
+							logger.Info("Candidate %s answers as GraphQL with the supplied credentials", c.URL)
+							c.Confirmed = true
+							detected = append(detected, c.URL)
+							send(c.URL)
+						}
+					}
+					candidates = append(candidates, c)
+				}
+			}
+			if errors.Is(err, gerrors.ErrIncomplete) {
+				found := 0
+				if result != nil {
+					found = len(result.Endpoints)
+				}
+				logger.Info("WARNING: Detection on %s %v; continuing with the %d endpoint(s) found", base, err, found)
+				detectErr = err
+				continue
+			}
+			if err != nil {
+				logger.Error("Detection failed on %s: %v", base, err)
+				detectErr = err
+			}
+		}
+	}()
+
+	logger.Info("Starting GraphQL security audit...")
+	rep := AuditStream(ctx, targets, headers, opts)
+	// Unblock detection if the audit was stopped before consuming every endpoint.
+	cancel()
+	<-done
+	rep.AuthCandidates = candidates
+
+	if len(detected) == 0 && len(candidates) > 0 {
+		logger.Info("No GraphQL endpoint confirmed; %d candidate endpoint(s) require authentication, retry them with -H or AUTH_TOKEN", len(candidates))
+		return rep, nil
+	}
+	if len(detected) == 0 {
+		if detectErr != nil && (len(bases) == 1 || errors.Is(detectErr, gerrors.ErrIncomplete)) {
+			return rep, fmt.Errorf("detection failed: %w", detectErr)
+		}
+		return rep, fmt.Errorf("no GraphQL endpoints detected")
+	}
+	logger.Info("Found %d GraphQL endpoints", len(detected))
+
+	// Endpoints confirmed as the context ended never reached the audit; list
+	// them anyway so that the report shows what detection found.
+	audited := make(map[string]bool, len(rep.Endpoints))
+	for _, e := range rep.Endpoints {
+		audited[e] = true
+	}
+	for _, e := range detected {
+		if !audited[e] {
+			logger.Info("Endpoint %s was detected but not audited", e)
+			rep.Endpoints = append(rep.Endpoints, e)
+		}
+	}
+
+	order := make(map[string]int, len(detected))
+	for i, e := range detected {
+		order[e] = i
+	}
+	sort.SliceStable(rep.Endpoints, func(i, j int) bool { return order[rep.Endpoints[i]] < order[rep.Endpoints[j]] })
+	return rep, nil
 }
 
-func generateOutputFileName(defaultFile, targetURL string) string {
-	parsed, err := url.Parse(targetURL)
+// hasCredentials reports whether headers carry anything beyond the content type,
+// such as an Authorization header, cookies or API keys.
+func hasCredentials(headers map[string]string) bool {
+	for k := range headers {
+		if !strings.EqualFold(k, "Content-Type") {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointEncodings returns the request encodings of the endpoints that are
+// not sent the standard JSON body.
+func endpointEncodings(endpoints []string) map[string]string {
+	set := network.Encodings()
+	var out map[string]string
+	for _, e := range endpoints {
+		if enc, ok := set[e]; ok {
+			if out == nil {
+				out = make(map[string]string)
+			}
+			out[e] = string(enc)
+		}
+	}
+	return out
+}
+
+// logVerification logs the outcome of verifying the supplied credentials.
+func logVerification(v types.AuthVerification) {
+	switch v.Status {
+	case types.AuthVerified:
+		logger.Info("Authentication on %s: %s", v.Target(), v.Summary())
+	case types.AuthRejected:
+		logger.Info("WARNING: %s on %s; its results reflect unauthenticated access", v.Summary(), v.Target())
+	case types.AuthUnproven:
+		logger.Info("WARNING: %s on %s; pass --whoami-query a query only authenticated users can run", v.Summary(), v.Target())
+	default:
+		logger.Info("WARNING: %s on %s", v.Summary(), v.Target())
+	}
+}
+
+// runExtraction executes every generated query against targetURL using the schema
+// fetched by the introspection check, and writes the results below extractDir.
+// The access state of each query is recorded in matrix, when set.
+func runExtraction(ctx context.Context, targetURL string, headers map[string]string, deps *checks.Deps, extractDir string, extractOpts attacks.ExtractOptions, matrix *report.MatrixRecorder) []report.Finding {
+	if deps.ArbitraryQueriesBlocked() {
+		logger.Info("Skipping data extraction on %s: %s", targetURL, checks.SkipAllowlist)
+		return nil
+	}
+	if deps.CredentialsRejected() {
+		logger.Info("Skipping data extraction on %s: the supplied credentials were rejected", targetURL)
+		return nil
+	}
+	if deps.Catalog == nil {
+		logger.Warn("Skipping data extraction on %s: no introspection result available", targetURL)
+		return nil
+	}
+
+	logger.Info("Extracting data from %s...", targetURL)
+	extractCtx, stop := network.StartModule(ctx, "extract")
+	results, err := attacks.Extract(extractCtx, targetURL, deps.Catalog, headers, extractOpts)
+	stop()
 	if err != nil {
-		return defaultFile
+		logger.Error("Data extraction on %s stopped early: %v", targetURL, err)
+	}
+
+	dir := filepath.Join(extractDir, introspection.EndpointSuffix(targetURL))
+	if err := attacks.WriteExtractResults(results, dir); err != nil {
+		logger.Error("Error writing extraction results: %v", err)
+	} else {
+		logger.Info("Extraction results saved to %s", dir)
+	}
+	if matrix != nil {
+		if err := matrix.Record(targetURL, matrixCells(results)); err != nil {
+			logger.Error("%v", err)
+		}
+	}
+
+	var exposed []string
+	for _, r := range results {
+		switch {
+		case r.NonEmpty && r.Pages > 0:
+			exposed = append(exposed, fmt.Sprintf("%s (%d records over %d pages, %s)", r.Operation, r.Records, r.Pages, r.Stop))
+		case r.NonEmpty:
+			exposed = append(exposed, fmt.Sprintf("%s (%d records)", r.Operation, r.Records))
+		}
+	}
+	logger.Info("%d of %d queries returned data on %s", len(exposed), len(results), targetURL)
+	findings := secretFindings(targetURL, headers, results)
+	if len(exposed) == 0 {
+		return findings
+	}
+	return append([]report.Finding{{
+		ID:          "data-exposed",
+		Check:       "extract",
+		Title:       "Queries return data with the supplied credentials",
+		Severity:    report.SeverityInfo,
+		Endpoint:    targetURL,
+		Description: fmt.Sprintf("%d of %d generated queries returned non-empty data.", len(exposed), len(results)),
+		Evidence:    strings.Join(exposed, ", "),
+	}}, findings...)
+}
+
+// matrixCells returns the access matrix cells of results, identified by the
+// canonical hash of their query so that the same operation lines up across
+// targets. Results without an access state, such as queries not sent, are left
+// out.
+func matrixCells(results []attacks.ExtractResult) []report.MatrixCell {
+	var cells []report.MatrixCell
+	for _, r := range results {
+		if r.Access == "" {
+			continue
+		}
+		hash, err := gql.CanonicalHash(r.Query)
+		if err != nil {
+			hash = r.Operation
+		}
+		cells = append(cells, report.MatrixCell{Hash: hash, Operation: r.Operation, State: r.Access})
+	}
+	return cells
+}
+
+// writeMatrix builds the access matrix recorded during the run, writes it
+// next to the spooled cells and attaches it to rep. It returns a finding for
+// each operation that a single target serves while all the others deny it.
+func writeMatrix(rep *report.Report, recorder *report.MatrixRecorder) []report.Finding {
+	m, err := recorder.Build()
+	if err != nil {
+		logger.Error("Error building the access matrix: %v", err)
+		return nil
+	}
+	if err := report.WriteMatrix(m, recorder.Dir()); err != nil {
+		logger.Error("%v", err)
+	} else {
+		logger.Info("Access matrix of %d operations over %d targets saved to %s", len(m.Rows), len(m.Targets), recorder.Dir())
+	}
+	rep.Matrix = m
+
+	var findings []report.Finding
+	for _, row := range m.Rows {
+		if row.Outlier == "" {
+			continue
+		}
+		var denied []string
+		outlierAccessible := false
+		for i, cell := range row.Cells {
+			switch {
+			case m.Targets[i] == row.Outlier:
+				outlierAccessible = cell == report.CellAccessible
+			case cell == report.CellDenied:
+				denied = append(denied, m.Targets[i])
+			}
+		}
+		if !outlierAccessible || len(denied) == 0 {
+			continue
+		}
+		findings = append(findings, report.Finding{
+			ID:          "operation-access-outlier",
+			Check:       "extract",
+			Title:       "Operation accessible on one target and denied on the others",
+			Severity:    report.SeverityMedium,
+			Endpoint:    row.Outlier,
+			Description: fmt.Sprintf("%s returned data on %s while the %d other targets serving it denied it.", row.Operation, row.Outlier, len(denied)),
+			Evidence:    fmt.Sprintf("%s (%s) denied on %s", row.Operation, row.Hash, strings.Join(denied, ", ")),
+		})
+	}
+	return findings
+}
+
+// secretSeverity is the severity of the secrets found by each detector in
+// extracted data. Secrets of other detectors are medium.
+var secretSeverity = map[string]string{
+	redact.DetectorPrivateKey:     report.SeverityHigh,
+	redact.DetectorAWSAccessKey:   report.SeverityHigh,
+	redact.DetectorURLCredentials: report.SeverityHigh,
+	redact.DetectorEntropy:        report.SeverityLow,
+}
+
+// secretFindings reports the secrets found in the data extracted from
+// targetURL: one finding per operation, detector and field, with the indices
+// of lists left out, so that a key returned for every user is reported once.
+// The evidence gives the exact path of the first value, with the value masked.
+func secretFindings(targetURL string, headers map[string]string, results []attacks.ExtractResult) []report.Finding {
+	var findings []report.Finding
+	for _, r := range results {
+		type group struct {
+			first attacks.SecretHit
+			count int
+		}
+		groups := make(map[string]*group)
+		var keys []string
+		for _, hit := range r.Secrets {
+			key := hit.Detector + " " + fieldPath(hit.Path)
+			if g, ok := groups[key]; ok {
+				g.count++
+				if hit.Page < g.first.Page || hit.Page == g.first.Page && hit.Path < g.first.Path {
+					g.first = hit
+				}
+				continue
+			}
+			groups[key] = &group{first: hit, count: 1}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			g := groups[key]
+			field := fieldPath(g.first.Path)
+			severity, ok := secretSeverity[g.first.Detector]
+			if !ok {
+				severity = report.SeverityMedium
+			}
+			location := g.first.Path
+			if g.first.Page > 0 {
+				location += fmt.Sprintf(" (page %d)", g.first.Page)
+			}
+			evidence := fmt.Sprintf("%s: %s", location, g.first.Excerpt)
+			if g.count > 1 {
+				evidence += fmt.Sprintf(" (%d values)", g.count)
+			}
+			logger.Info("WARNING: %s returned a secret (%s) in %s on %s", r.Operation, g.first.Detector, field, targetURL)
+			findings = append(findings, report.Finding{
+				ID:          "response-secret-" + g.first.Detector,
+				Check:       "extract",
+				Title:       fmt.Sprintf("Secret-looking %s returned in %s", g.first.Detector, field),
+				Severity:    severity,
+				Endpoint:    targetURL,
+				Description: fmt.Sprintf("The %s query returned a value matching the %s secret detector. Data returned to clients should never hold credentials, keys or tokens of the service or of other users.", r.Operation, g.first.Detector),
+				Evidence:    evidence,
+				Request:     report.NewGraphQLRequest(targetURL, r.Query, nil, headers),
+			})
+		}
+	}
+	return findings
+}
+
+// fieldPath returns a path of SecretHit with its list indices replaced by *,
+// users.3.apiKey becoming users.*.apiKey.
+func fieldPath(path string) string {
+	segments := strings.Split(path, ".")
+	for i, s := range segments {
+		if _, err := strconv.Atoi(s); err == nil {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+// rateLimitFindings reports throttling seen by the network layer on the audited
+// endpoints of rep that the rate-limit probe has not already reported.
+func rateLimitFindings(rep *report.Report) []report.Finding {
+	audited := make(map[string]bool, len(rep.Endpoints))
+	for _, u := range rep.Endpoints {
+		audited[u] = true
+	}
+	reported := make(map[string]bool)
+	for _, f := range rep.Findings {
+		if f.ID == "rate-limiting-observed" {
+			reported[f.Endpoint] = true
+		}
+	}
+
+	var findings []report.Finding
+	for _, event := range network.RateLimitEvents() {
+		if !audited[event.URL] || reported[event.URL] {
+			continue
+		}
+		reported[event.URL] = true
+		findings = append(findings, checks.RateLimitFinding(event.URL, event))
+	}
+	return findings
+}
+
+// contentTypeFindings reports the audited endpoints that answered JSON under a
+// non-JSON Content-Type.
+func contentTypeFindings(targetURLs []string) []report.Finding {
+	audited := make(map[string]bool, len(targetURLs))
+	for _, u := range targetURLs {
+		audited[u] = true
+	}
+
+	var findings []report.Finding
+	for _, event := range network.ContentTypeEvents() {
+		if !audited[event.URL] {
+			continue
+		}
+		findings = append(findings, report.Finding{
+			ID:          "incorrect-content-type",
+			Check:       "transport",
+			Title:       "GraphQL responses use an incorrect Content-Type",
+			Severity:    report.SeverityLow,
+			Endpoint:    event.URL,
+			Description: "The endpoint returns JSON bodies labelled with a non-JSON media type, which can enable content sniffing and breaks strict clients.",
+			Evidence:    "Content-Type: " + event.ContentType,
+		})
+	}
+	return findings
+}
+
+// PrintStats prints the network metrics collected during the run.
+func PrintStats(stats types.NetworkStats) {
+	fmt.Println("Network statistics:")
+	fmt.Printf("  Requests:          %d\n", stats.Requests)
+	codes := make([]int, 0, len(stats.StatusCodes))
+	for code := range stats.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Printf("    HTTP %d:       %d\n", code, stats.StatusCodes[code])
+	}
+	kinds := make([]string, 0, len(stats.Operations))
+	for kind := range stats.Operations {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	if len(kinds) > 0 {
+		fmt.Println("  Operations:")
+	}
+	for _, kind := range kinds {
+		fmt.Printf("    %-16s %d\n", kind+":", stats.Operations[kind])
 	}
-	cleanPath := strings.Trim(parsed.Path, "/")
-	suffix := "root"
-	if cleanPath != "" {
-		segments := strings.Split(cleanPath, "/")
-		suffix = segments[len(segments)-1]
+	fmt.Printf("  Bytes sent:        %d\n", stats.BytesSent)
+	fmt.Printf("  Bytes received:    %d\n", stats.BytesReceived)
+	fmt.Printf("  Retries:           %d\n", stats.Retries)
+	fmt.Printf("  Rate-limit waits:  %d\n", stats.RateLimitWaits)
+	fmt.Printf("  Connection reuse:  %.0f%% (%d new, %d reused)\n", stats.ConnectionReuseRatio*100, stats.NewConns, stats.ReusedConns)
+	if c := stats.Concurrency; c != nil {
+		if c.Mode == "adaptive" {
+			fmt.Printf("  Concurrency:       adaptive, %d now (peak %d, %d increases, %d decreases)\n", c.Limit, c.Peak, c.Increases, c.Decreases)
+		} else {
+			fmt.Printf("  Concurrency:       %d (peak %d)\n", c.Limit, c.Peak)
+		}
+	}
+	modules := make([]string, 0, len(stats.Modules))
+	for name := range stats.Modules {
+		modules = append(modules, name)
+	}
+	sort.Strings(modules)
+	if len(modules) > 0 {
+		fmt.Println("  Module timings:")
+	}
+	for _, name := range modules {
+		fmt.Printf("    %-16s %s\n", name+":", stats.Modules[name])
+	}
+	fmt.Printf("  Wall time:         %s\n", stats.WallTime)
+}
+
+// PrintNonQueryOperations lists the mutations and subscriptions sent during the run.
+func PrintNonQueryOperations(ops []types.SentOperation) {
+	if len(ops) == 0 {
+		logger.Info("No mutations or subscriptions were sent")
+		return
+	}
+	logger.Info("WARNING: %d non-query operation(s) were sent:", len(ops))
+	for _, op := range ops {
+		name := op.Name
+		if name == "" {
+			name = "(anonymous)"
+		}
+		logger.Info("  %s %s on %s (%d time(s))", op.Kind, name, op.Endpoint, op.Count)
+	}
+}
+
+// PrintSuppressedRequests lists the requests refused because their host is out of scope.
+func PrintSuppressedRequests(requests []types.SuppressedRequest) {
+	if len(requests) == 0 {
+		return
+	}
+	logger.Info("WARNING: %d out-of-scope request target(s) were suppressed:", len(requests))
+	for _, r := range requests {
+		logger.Info("  %s (%d time(s))", r.URL, r.Count)
+	}
+}
+
+// introspectionChecked reports whether the introspection check completed on any endpoint.
+func introspectionChecked(rep *report.Report) bool {
+	for _, r := range rep.Checks {
+		if r.Check == "introspection" && r.Status != report.StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyFindings reports the audited endpoints whose response bodies did not
+// match their Content-Encoding or Content-Length headers.
+func bodyFindings(targetURLs []string) []report.Finding {
+	audited := make(map[string]bool, len(targetURLs))
+	for _, u := range targetURLs {
+		audited[u] = true
+	}
+
+	issues := make(map[string][]string)
+	var urls []string
+	for _, event := range network.BodyEvents() {
+		if !audited[event.URL] {
+			continue
+		}
+		if issues[event.URL] == nil {
+			urls = append(urls, event.URL)
+		}
+		issues[event.URL] = append(issues[event.URL], event.Issue)
+	}
+
+	var findings []report.Finding
+	for _, u := range urls {
+		findings = append(findings, report.Finding{
+			ID:          "misdeclared-response-encoding",
+			Check:       "transport",
+			Title:       "GraphQL responses misdeclare their encoding or length",
+			Severity:    report.SeverityLow,
+			Endpoint:    u,
+			Description: "Response bodies did not match their Content-Encoding or Content-Length headers. GraphSpecter decoded them anyway, but clients, caches and proxies that trust the headers may fail or store corrupted responses.",
+			Evidence:    strings.Join(issues[u], "; "),
+		})
 	}
-	baseName := strings.TrimSuffix(defaultFile, ".json")
-	return fmt.Sprintf("%s_%s.json", baseName, suffix)
+	return findings
 }
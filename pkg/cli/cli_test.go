@@ -0,0 +1,297 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
+)
+
+func TestRateLimitFindingsFollowAuditedEndpoints(t *testing.T) {
+	network.ResetStats()
+	defer network.ResetStats()
+	throttling := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"errors":[{"message":"Too many requests"}]}`))
+	}))
+	defer throttling.Close()
+	network.SendGraphQLRequestWithContext(context.Background(), throttling.URL, "{ __typename }", nil, nil)
+
+	if got := rateLimitFindings(&report.Report{Endpoints: []string{"https://other.example/graphql"}}); len(got) != 0 {
+		t.Errorf("an audit of another endpoint reports %+v", got)
+	}
+	got := rateLimitFindings(&report.Report{Endpoints: []string{throttling.URL}})
+	if len(got) != 1 || got[0].ID != "rate-limiting-observed" || got[0].Endpoint != throttling.URL {
+		t.Fatalf("rateLimitFindings() = %+v", got)
+	}
+	// The probe's own finding is not repeated.
+	if got := rateLimitFindings(&report.Report{Endpoints: []string{throttling.URL}, Findings: got}); len(got) != 0 {
+		t.Errorf("reported again: %+v", got)
+	}
+
+	// The next audit, like the next iteration of --watch, starts afresh.
+	network.ResetStats()
+	if got := rateLimitFindings(&report.Report{Endpoints: []string{throttling.URL}}); len(got) != 0 {
+		t.Errorf("after ResetStats: %+v", got)
+	}
+}
+
+// TestAuditReportCarriesVersion checks that the report of an audit, its
+// summary and the requests of the run carry the build the version package
+// exposes.
+func TestAuditReportCarriesVersion(t *testing.T) {
+	defer func(v, c, d string) { version.Version, version.Commit, version.BuildDate = v, c, d }(version.Version, version.Commit, version.BuildDate)
+	version.Version, version.Commit, version.BuildDate = "v9.8.7", "abc1234", "2026-10-14T00:00:00Z"
+
+	var userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer srv.Close()
+
+	selected, err := checks.Select("query-policy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := AuditEndpoints(context.Background(), []string{srv.URL}, nil, AuditOptions{Checks: selected})
+	want := report.Metadata{Tool: "graphspecter", Version: "v9.8.7", Commit: "abc1234", BuildDate: "2026-10-14T00:00:00Z"}
+	if got := rep.Metadata; got.Tool != want.Tool || got.Version != want.Version || got.Commit != want.Commit || got.BuildDate != want.BuildDate {
+		t.Errorf("metadata = %+v, want the build %+v", got, want)
+	}
+	if got := report.NewSummary(rep).Version; got != "v9.8.7" {
+		t.Errorf("summary version = %q", got)
+	}
+	if userAgent != "GraphSpecter/v9.8.7" {
+		t.Errorf("User-Agent = %q, want the version in it", userAgent)
+	}
+}
+
+// policyTargets starts n mock GraphQL servers. The one at index enabled has
+// introspection enabled, the others refuse it. The returned counts hold the
+// number of requests each server received.
+func policyTargets(t *testing.T, n, enabled int) ([]string, []*int64) {
+	t.Helper()
+	var urls []string
+	var counts []*int64
+	for i := 0; i < n; i++ {
+		count := new(int64)
+		open := i == enabled
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(count, 1)
+			w.Header().Set("Content-Type", "application/json")
+			if open {
+				w.Write([]byte(policySchema))
+				return
+			}
+			w.Write([]byte(`{"errors":[{"message":"GraphQL introspection is not allowed"}]}`))
+		}))
+		t.Cleanup(srv.Close)
+		urls = append(urls, srv.URL)
+		counts = append(counts, count)
+	}
+	return urls, counts
+}
+
+const policySchema = `{"data":{"__schema":{"queryType":{"name":"Query"},"mutationType":null,"subscriptionType":null,"directives":[],
+	"types":[{"kind":"OBJECT","name":"Query","fields":[{"name":"me","args":[],"type":{"kind":"SCALAR","name":"String","ofType":null}}]},
+	{"kind":"SCALAR","name":"String"}]}}}`
+
+func TestAuditStopsOnFinding(t *testing.T) {
+	selected, err := checks.Select("introspection", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls, counts := policyTargets(t, 5, 1)
+	rep := AuditEndpoints(context.Background(), urls, nil, AuditOptions{
+		Checks: selected,
+		Policy: checks.Policy{StopOnSeverity: report.SeverityMedium},
+	})
+
+	if rep.Stopped == nil || rep.Stopped.Finding != "introspection-enabled" || rep.Stopped.Endpoint != urls[1] || rep.Stopped.Error {
+		t.Fatalf("Stopped = %+v, want the introspection finding on target 2", rep.Stopped)
+	}
+	for i, n := range counts {
+		if sent := atomic.LoadInt64(n); (i <= 1) != (sent > 0) {
+			t.Errorf("target %d received %d request(s)", i+1, sent)
+		}
+	}
+	var audited []string
+	for _, r := range rep.Checks {
+		audited = append(audited, r.Endpoint)
+	}
+	if !reflect.DeepEqual(audited, urls[:2]) {
+		t.Errorf("checks ran on %q, want the first two targets", audited)
+	}
+	if s := report.NewSummary(rep); s.Stopped != rep.Stopped || !strings.Contains(s.Stopped.Reason, "introspection-enabled (medium) on "+urls[1]) {
+		t.Errorf("summary stop = %+v, want the triggering finding", s.Stopped)
+	}
+}
+
+func TestAuditStopsOnError(t *testing.T) {
+	selected, err := checks.Select("introspection", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, continueOnError := range []bool{false, true} {
+		urls, counts := policyTargets(t, 5, -1)
+		// Target 2 is down, so the introspection check fails on it.
+		down := httptest.NewServer(http.NotFoundHandler())
+		urls[1] = down.URL
+		down.Close()
+
+		rep := AuditEndpoints(context.Background(), urls, nil, AuditOptions{
+			Checks: selected,
+			Policy: checks.Policy{ContinueOnError: continueOnError},
+		})
+		if continueOnError {
+			if rep.Stopped != nil || len(rep.Checks) != 5 {
+				t.Errorf("--continue-on-error: stopped %+v after %d check run(s)", rep.Stopped, len(rep.Checks))
+			}
+			continue
+		}
+		if rep.Stopped == nil || !rep.Stopped.Error || rep.Stopped.Endpoint != urls[1] {
+			t.Fatalf("Stopped = %+v, want the failure on target 2", rep.Stopped)
+		}
+		for i, n := range counts[2:] {
+			if sent := atomic.LoadInt64(n); sent != 0 {
+				t.Errorf("target %d received %d request(s) after the failure", i+3, sent)
+			}
+		}
+		if len(rep.Checks) != 2 || rep.Checks[1].Status != report.StatusFailed {
+			t.Errorf("checks = %+v, want the first two targets, the second failed", rep.Checks)
+		}
+	}
+}
+
+func TestAuditResumesAfterInterrupt(t *testing.T) {
+	selected, err := checks.Select("introspection", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, interrupt := context.WithCancel(context.Background())
+	defer interrupt()
+	var urls []string
+	counts := make([]int64, 10)
+	for i := range counts {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The first run is interrupted while it scans target 4.
+			if atomic.AddInt64(&counts[i], 1) == 1 && i == 3 {
+				interrupt()
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(policySchema))
+		}))
+		defer srv.Close()
+		urls = append(urls, srv.URL)
+	}
+
+	path := filepath.Join(t.TempDir(), workspace.DefaultStateFile)
+	state := workspace.NewState(path)
+	if err := state.Add(urls...); err != nil {
+		t.Fatal(err)
+	}
+	// Failures do not stop the run, so that only the interrupt does.
+	policy := checks.Policy{ContinueOnError: true}
+	first := AuditEndpoints(ctx, urls, nil, AuditOptions{Checks: selected, State: state, Policy: policy})
+	if len(first.Findings) != 3 {
+		t.Errorf("interrupted run: %d finding(s), want those of targets 1-3", len(first.Findings))
+	}
+	// Target 4 was left in progress, the targets after it were never started.
+	if want := map[string]int{workspace.StatusDone: 3, workspace.StatusInProgress: 1, workspace.StatusPending: 6}; !reflect.DeepEqual(state.Counts(), want) {
+		t.Errorf("state of the interrupted run = %v, want %v", state.Counts(), want)
+	}
+
+	resumed, err := workspace.LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{workspace.StatusDone: 3, workspace.StatusPending: 7}; !reflect.DeepEqual(resumed.Counts(), want) {
+		t.Fatalf("state after the interrupt = %v, want %v", resumed.Counts(), want)
+	}
+	before := make([]int64, len(counts))
+	for i := range counts {
+		before[i] = atomic.LoadInt64(&counts[i])
+	}
+	rep := AuditEndpoints(context.Background(), urls, nil, AuditOptions{Checks: selected, State: resumed, Policy: policy})
+
+	for i := range counts {
+		sent := atomic.LoadInt64(&counts[i]) - before[i]
+		if (i >= 3) != (sent > 0) {
+			t.Errorf("resumed run sent %d request(s) to target %d", sent, i+1)
+		}
+	}
+	var audited []string
+	for _, r := range rep.Checks {
+		audited = append(audited, r.Endpoint)
+	}
+	if !reflect.DeepEqual(audited, urls[3:]) {
+		t.Errorf("resumed run checked %q, want targets 4-10", audited)
+	}
+	if len(rep.Findings) != 10 {
+		t.Errorf("resumed report has %d finding(s), want one per target, carried over ones included", len(rep.Findings))
+	}
+	if counts := resumed.Counts(); counts[workspace.StatusDone] != 10 {
+		t.Errorf("state after resuming = %v, want every target done", counts)
+	}
+}
+
+func TestPrintAvailableOperationsByTag(t *testing.T) {
+	str := types.TypeRef{Kind: types.SCALAR, Name: "String"}
+	user := types.TypeRef{Kind: types.OBJECT, Name: "User"}
+	query := types.Type{Kind: types.OBJECT, Name: "Query", Fields: []types.Field{{Name: "users", Type: user}, {Name: "search", Type: str}, {Name: "version", Type: str}}}
+	mutation := types.Type{Kind: types.OBJECT, Name: "Mutation", Fields: []types.Field{{Name: "deleteUser", Type: user}}}
+	s := &types.GQLSchema{Query: &query, Mutation: &mutation, Types: map[string]types.Type{
+		"Query": query, "Mutation": mutation, "String": {Kind: types.SCALAR, Name: "String"},
+		"User": {Kind: types.OBJECT, Name: "User", Fields: []types.Field{{Name: "email", Type: str}}},
+	}}
+	notes := schema.Notes{
+		"users":               {Text: "Lists every account"},
+		"query.search":        {Text: "Slow on long terms", Tags: []string{"Slow"}},
+		"mutation.deleteUser": {Text: "Irreversible", Tags: []string{"destructive"}},
+		"User":                {Tags: []string{"PII"}},
+	}
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{"", []string{
+			"query users [pii] - Lists every account",
+			"query search [slow] - Slow on long terms",
+			"query version",
+			"mutation deleteUser [destructive, pii] - Irreversible",
+		}},
+		// Tags are matched case-insensitively, whichever note holds them.
+		{"pii", []string{"query users [pii] - Lists every account", "mutation deleteUser [destructive, pii] - Irreversible"}},
+		{"SLOW", []string{"query search [slow] - Slow on long terms"}},
+		{"billing", nil},
+	}
+	for _, tt := range tests {
+		out := captureStdout(t, func() { PrintAvailableOperations(s, "all", schema.SortSchema, notes, tt.tag) })
+		var lines []string
+		if out != "" {
+			lines = strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+		}
+		if !reflect.DeepEqual(lines, tt.want) {
+			t.Errorf("--tag %q listed\n%s\nwant\n%s", tt.tag, strings.Join(lines, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+	if out := captureStdout(t, func() { PrintAvailableOperations(s, "mutations", schema.SortSchema, nil, "") }); out != "mutation deleteUser\n" {
+		t.Errorf("without notes, listed %q", out)
+	}
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/attacks"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// CoercionCheck names the findings of --fuzz-coercion.
+const CoercionCheck = "variable-coercion"
+
+// FuzzCoercion sends the type-confused values of attacks.TestVariableCoercion
+// for the variables of document, which must hold a single query, prints the
+// outcome of every probe and returns findings for the values that crashed the
+// server or were silently accepted.
+func FuzzCoercion(ctx context.Context, url, document string, headers map[string]string) ([]report.Finding, error) {
+	doc, err := gql.Parse(document)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the --fuzz-coercion document: %w", err)
+	}
+	if len(doc.Operations) != 1 {
+		return nil, fmt.Errorf("--fuzz-coercion needs a document with a single operation, found %d", len(doc.Operations))
+	}
+	op := doc.Operations[0]
+	if op.Kind != gql.OperationQuery {
+		return nil, fmt.Errorf("--fuzz-coercion only sends queries, not a %s", op.Kind)
+	}
+	if len(op.VariableDefinitions) == 0 {
+		return nil, errors.New("--fuzz-coercion needs an operation that declares variables")
+	}
+	name := op.Name
+	if name == "" {
+		name = "(anonymous)"
+	}
+
+	logger.Info("Sending type-confused values of %d variable(s) of %s to %s...", len(op.VariableDefinitions), name, url)
+	probes, err := attacks.TestVariableCoercion(ctx, url, document, op.VariableDefinitions, headers)
+	PrintCoercionProbes(probes)
+	if err != nil {
+		return nil, err
+	}
+	return coercionFindings(url, name, document, probes, headers), nil
+}
+
+// PrintCoercionProbes prints one line per probe: the variable and its type,
+// the confused value, the outcome and the HTTP status.
+func PrintCoercionProbes(probes []attacks.CoercionProbe) {
+	fmt.Printf("Variable coercion: %d probe(s)\n", len(probes))
+	for _, p := range probes {
+		detail := p.Error
+		if detail == "" && len(p.Errors) > 0 {
+			detail = p.Errors[0]
+		}
+		fmt.Printf("  %-24s %-14s %-20s %-11s %3d %s\n", "$"+p.Variable+": "+p.Declared, p.Kind, attacks.FormatCoercionValue(p.Value), p.Outcome, p.Status, detail)
+	}
+}
+
+// coercionFindings returns a finding per variable whose confused values
+// crashed the server, and one per variable whose values were accepted.
+func coercionFindings(url, operation, document string, probes []attacks.CoercionProbe, headers map[string]string) []report.Finding {
+	type key struct{ variable, outcome string }
+	grouped := make(map[key][]attacks.CoercionProbe)
+	var keys []key
+	for _, p := range probes {
+		if p.Outcome != attacks.CoercionCrashed && p.Outcome != attacks.CoercionAccepted {
+			continue
+		}
+		k := key{p.Variable, p.Outcome}
+		if _, ok := grouped[k]; !ok {
+			keys = append(keys, k)
+		}
+		grouped[k] = append(grouped[k], p)
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].outcome < keys[j].outcome })
+
+	var findings []report.Finding
+	for _, k := range keys {
+		group := grouped[k]
+		values := make([]string, len(group))
+		for i, p := range group {
+			values[i] = attacks.FormatCoercionValue(p.Value)
+			if values[i] != p.Kind {
+				values[i] = p.Kind + " " + values[i]
+			}
+		}
+		f := report.Finding{
+			Check:    CoercionCheck,
+			Endpoint: url,
+			Evidence: fmt.Sprintf("$%s: %s sent %s", k.variable, group[0].Declared, strings.Join(values, ", ")),
+			Request:  report.NewGraphQLRequest(url, document, group[0].Variables, headers),
+		}
+		if k.outcome == attacks.CoercionCrashed {
+			f.ID = "variable-coercion-crash"
+			f.Title = fmt.Sprintf("Type-confused $%s crashes %s", k.variable, operation)
+			f.Severity = report.SeverityMedium
+			f.Description = fmt.Sprintf("Values of the wrong type for $%s were answered with HTTP %d instead of a validation error. "+
+				"Unvalidated variables reach code that does not expect them, which can leak stack traces or take the server down.", k.variable, group[0].Status)
+		} else {
+			f.ID = "variable-coercion-accepted"
+			f.Title = fmt.Sprintf("%s silently coerces $%s", operation, k.variable)
+			f.Severity = report.SeverityLow
+			f.Description = fmt.Sprintf("The server executed %s with values of the wrong type for $%s, which the GraphQL spec requires it to refuse. "+
+				"Lax coercion lets values bypass the declared types and can differ from what the gateways and validators in front of it accept.", operation, k.variable)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// PlanCoercion returns the requests FuzzCoercion would send for document, read
+// from source: the baseline and one per type-confused value.
+func PlanCoercion(url, source, document string) *RequestPlan {
+	p := PlanRequest("fuzz-coercion", url, PlanOperations(source, document))
+	doc, err := gql.Parse(document)
+	if err != nil || len(doc.Operations) != 1 {
+		return p
+	}
+	for _, def := range doc.Operations[0].VariableDefinitions {
+		p.Steps[0].Requests += len(attacks.CoercionValues(def.Type))
+	}
+	p.Steps[0].MaxRequests = p.Steps[0].Requests
+	return p
+}
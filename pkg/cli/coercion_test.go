@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestFuzzCoercionFindings runs --fuzz-coercion against a server that
+// executes with any variable values and crashes on a non-numeric $n.
+func TestFuzzCoercionFindings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		if n, ok := req.Variables["n"]; ok && n != nil {
+			if _, number := n.(float64); !number {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"errors":[{"message":"internal error"}]}`))
+				return
+			}
+		}
+		w.Write([]byte(`{"data":{"search":[]}}`))
+	}))
+	defer srv.Close()
+
+	findings, err := FuzzCoercion(context.Background(), srv.URL, `query Search($id: ID!, $n: Int) { search(id: $id, n: $n) { id } }`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ id, evidence string }{
+		{"variable-coercion-accepted", `$id: ID! sent null, array ["1"], object {"value":"1"}, boolean true, float 1.5`},
+		{"variable-coercion-accepted", "$n: Int sent float 1.5, out-of-range 2147483648"},
+		{"variable-coercion-crash", `$n: Int sent array [1], object {"value":1}, string "1", boolean true`},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("findings = %+v, want %d", findings, len(want))
+	}
+	for i, w := range want {
+		f := findings[i]
+		if f.ID != w.id || f.Evidence != w.evidence || f.Check != CoercionCheck || f.Request == nil {
+			t.Errorf("finding %d = %s %q, want %s %q", i, f.ID, f.Evidence, w.id, w.evidence)
+		}
+	}
+	if !strings.Contains(findings[2].Description, "HTTP 500") {
+		t.Errorf("crash description = %q", findings[2].Description)
+	}
+
+	for document, want := range map[string]string{
+		`mutation Drop($id: ID!) { drop(id: $id) }`: "only sends queries, not a mutation",
+		`{ search { id } }`:                         "needs an operation that declares variables",
+		`query A($a: Int) { a } query B { b }`:      "single operation, found 2",
+		`query A($a: Int) { a(`:                     "error parsing the --fuzz-coercion document",
+	} {
+		if _, err := FuzzCoercion(context.Background(), srv.URL, document, nil); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("FuzzCoercion(%q) = %v, want %q", document, err, want)
+		}
+	}
+}
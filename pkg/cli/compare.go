@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/CyberRoute/graphspecter/pkg/compare"
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Compare runs the operation catalog of the schema file against both endpoints
+// and prints the divergences. It returns the process exit code: 1 when the
+// endpoints diverge or the comparison cannot run, 2 for invalid options.
+func Compare(cfg *types.CompareConfig) int {
+	logger.SetUTC(cfg.LogUTC)
+	logger.SetupLogging(cfg.LogLevel, cfg.LogFile, !cfg.NoColor)
+	if cfg.BaseA == "" || cfg.BaseB == "" || cfg.SchemaFile == "" {
+		fmt.Fprintln(os.Stderr, "compare needs --base-a, --base-b and --schema-file")
+		return 2
+	}
+	if cfg.Format != "text" && cfg.Format != "json" {
+		fmt.Fprintf(os.Stderr, "Invalid --format %q (valid: 'text', 'json')\n", cfg.Format)
+		return 2
+	}
+	diff := jsondiff.Options{Ignore: jsondiff.ParsePaths(cfg.Ignore), Unordered: jsondiff.ParsePaths(cfg.Unordered)}
+	if err := diff.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --ignore or --unordered: %v\n", err)
+		return 2
+	}
+
+	schemaObj, err := schema.LoadFromFile(cfg.SchemaFile)
+	if err != nil {
+		PrintSchemaError(cfg.SchemaFile, err)
+		return 1
+	}
+	catalog := schema.BuildCatalog(schemaObj, schema.CatalogOptions{MaxDepth: cfg.MaxDepth})
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	if authToken := os.Getenv("AUTH_TOKEN"); authToken != "" {
+		headers["Authorization"] = "Bearer " + authToken
+	}
+
+	ctx, cancel := SetupSignalHandler(context.Background())
+	defer cancel()
+	ctx, timeoutCancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer timeoutCancel()
+
+	result, err := compare.Run(ctx, catalog, cfg.BaseA, cfg.BaseB, headers, compare.Options{
+		Mutations:      cfg.Mutations,
+		LatencyFactor:  cfg.LatencyFactor,
+		LatencyMinimum: cfg.LatencyMinimum,
+		Data:           cfg.Data,
+		Diff:           diff,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Comparison stopped after %d operations: %v\n", len(result.Operations), err)
+	}
+
+	if cfg.Format == "json" {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		PrintComparison(result)
+	}
+	if err != nil || result.Divergent > 0 {
+		return 1
+	}
+	return 0
+}
+
+// PrintComparison prints the operations on which the endpoints diverge as a table.
+func PrintComparison(r *compare.Result) {
+	fmt.Printf("A: %s\nB: %s\n\n", r.BaseA, r.BaseB)
+	if r.Divergent == 0 {
+		fmt.Printf("No divergences in %d operations.\n", len(r.Operations))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OPERATION\tDIVERGENCE\tSTATUS A\tSTATUS B\tLATENCY A\tLATENCY B\tDETAIL")
+	for _, op := range r.Operations {
+		if len(op.Divergences) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\t%dms\t%dms\t%s\n", op.Kind, op.Operation, strings.Join(op.Divergences, ","),
+			op.A.Status, op.B.Status, op.A.LatencyMs, op.B.LatencyMs, comparisonDetail(op))
+	}
+	w.Flush()
+	for _, op := range r.Operations {
+		if op.Diff != "" {
+			fmt.Printf("\n%s %s data:\n%s\n", op.Kind, op.Operation, "  "+strings.ReplaceAll(op.Diff, "\n", "\n  "))
+		}
+	}
+	fmt.Printf("\n%d of %d operations diverge.\n", r.Divergent, len(r.Operations))
+}
+
+// comparisonDetail shows the error shapes or messages behind a divergence.
+func comparisonDetail(op compare.OperationResult) string {
+	describe := func(o compare.Outcome) string {
+		if o.Shape != "" {
+			return o.Shape
+		}
+		if o.Error != "" {
+			return o.Error
+		}
+		return "-"
+	}
+	a, b := describe(op.A), describe(op.B)
+	if a == b {
+		return ""
+	}
+	return fmt.Sprintf("A: %s | B: %s", a, b)
+}
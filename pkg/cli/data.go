@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Data runs the data subcommand and returns the process exit code. "data show
+// <dataset>" prints the effective dataset after the overrides of --data-dir;
+// "data list" and "data show" alone list the datasets.
+func Data(cfg *types.DataConfig) int {
+	if cfg.DataDir != "" {
+		if err := data.LoadDir(cfg.DataDir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	action := "list"
+	if len(cfg.Args) > 0 {
+		action = cfg.Args[0]
+	}
+	switch {
+	case action == "list", action == "show" && len(cfg.Args) == 1:
+		for _, name := range data.Names() {
+			fmt.Println(name)
+		}
+		return 0
+	case action == "show" && len(cfg.Args) == 2:
+		out, err := data.Show(cfg.Args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		fmt.Println(string(out))
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, "usage: data [--data-dir dir] list | show <dataset>")
+	return 2
+}
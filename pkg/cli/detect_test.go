@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// stallingTarget answers as GraphQL on /fast at once and never answers on
+// /slow. Detection probes paths.
+func stallingTarget(t *testing.T, paths ...string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "paths.json")
+	if err := os.WriteFile(file, []byte(`{"version":1,"entries":["`+strings.Join(paths, `","`)+`"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := data.LoadFile("paths", file); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(data.Reset)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client going away once the body is read.
+		io.Copy(io.Discard, r.Body)
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestDetectAndAuditKeepsPartialResults(t *testing.T) {
+	base := stallingTarget(t, "/fast", "/slow")
+	selected, err := checks.Select("query-policy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	rep, err := DetectAndAudit(ctx, []string{base}, nil, AuditOptions{Checks: selected})
+	if err != nil {
+		t.Fatalf("DetectAndAudit() = %v, want the endpoint found before the timeout audited", err)
+	}
+	if want := []string{base + "/fast"}; !reflect.DeepEqual(rep.Endpoints, want) {
+		t.Errorf("Endpoints = %q, want %q", rep.Endpoints, want)
+	}
+	if len(rep.Checks) != 1 || rep.Checks[0].Endpoint != base+"/fast" {
+		t.Errorf("checks = %+v, want query-policy run on the fast endpoint", rep.Checks)
+	}
+}
+
+func TestDetectAndAuditTimesOutEmpty(t *testing.T) {
+	base := stallingTarget(t, "/slow")
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	rep, err := DetectAndAudit(ctx, []string{base}, nil, AuditOptions{})
+	if err == nil {
+		t.Fatalf("DetectAndAudit() found %q", rep.Endpoints)
+	}
+	if !errors.Is(err, gerrors.ErrIncomplete) || !errors.Is(err, gerrors.ErrTimeout) || !strings.Contains(err.Error(), "detection failed") {
+		t.Errorf("error = %v, want the incomplete detection", err)
+	}
+}
+
+// TestDetectAndAuditRetriesAuthCandidates checks that a path refusing the
+// anonymous detection probe is audited once it answers as GraphQL with the
+// supplied credentials, and only reported as a candidate without them.
+func TestDetectAndAuditRetriesAuthCandidates(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "paths.json")
+	if err := os.WriteFile(file, []byte(`{"version":1,"entries":["/private/graphql","/missing"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := data.LoadFile("paths", file); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(data.Reset)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.URL.Path != "/private/graphql" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"Unauthorized"}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer srv.Close()
+	endpoint := srv.URL + "/private/graphql"
+	selected, err := checks.Select("query-policy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := DetectAndAudit(context.Background(), []string{srv.URL}, nil, AuditOptions{Checks: selected})
+	if err != nil {
+		t.Fatalf("DetectAndAudit() = %v, want the candidate reported without an error", err)
+	}
+	want := []types.AuthCandidate{{URL: endpoint, Reason: "HTTP 401 with WWW-Authenticate: Bearer", StatusCode: 401}}
+	if len(rep.Endpoints) != 0 || !reflect.DeepEqual(rep.AuthCandidates, want) {
+		t.Errorf("without credentials, endpoints = %q and candidates = %+v, want %+v", rep.Endpoints, rep.AuthCandidates, want)
+	}
+
+	rep, err = DetectAndAudit(context.Background(), []string{srv.URL}, map[string]string{"Authorization": "Bearer valid"}, AuditOptions{Checks: selected})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want[0].Confirmed = true
+	if !reflect.DeepEqual(rep.Endpoints, []string{endpoint}) || !reflect.DeepEqual(rep.AuthCandidates, want) {
+		t.Errorf("with credentials, endpoints = %q and candidates = %+v, want the candidate confirmed", rep.Endpoints, rep.AuthCandidates)
+	}
+	if len(rep.Checks) != 1 || rep.Checks[0].Endpoint != endpoint {
+		t.Errorf("checks = %+v, want query-policy run on the confirmed candidate", rep.Checks)
+	}
+}
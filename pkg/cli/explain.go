@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CyberRoute/graphspecter/pkg/report/kb"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// explainUsage is printed for invalid explain invocations.
+const explainUsage = "usage: explain [--engine name] finding-id... | explain --list"
+
+// Explain prints the knowledge base entries of the finding ids of cfg, or
+// lists the ids with --list, and returns the process exit code.
+func Explain(cfg *types.ExplainConfig) int {
+	if cfg.List {
+		if len(cfg.Args) > 0 {
+			fmt.Fprintln(os.Stderr, explainUsage)
+			return 2
+		}
+		for _, e := range kb.All() {
+			fmt.Printf("%-36s %s\n", e.ID, e.Title)
+		}
+		return 0
+	}
+	if len(cfg.Args) == 0 {
+		fmt.Fprintln(os.Stderr, explainUsage)
+		return 2
+	}
+
+	status := 0
+	for i, id := range cfg.Args {
+		e, ok := kb.Lookup(id)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "No explanation for finding %q; explain --list shows the known ids\n", id)
+			status = 1
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		printEntry(id, e, cfg.Engine)
+	}
+	return status
+}
+
+// printEntry prints e, the entry explaining the finding id. With an engine
+// only its specific steps are printed, otherwise those of every engine.
+func printEntry(id string, e kb.Entry, engine string) {
+	fmt.Printf("%s: %s\n\n", id, e.Title)
+	fmt.Printf("Background\n\n%s\n\n", e.Background)
+	fmt.Printf("Impact\n\n%s\n\n", e.Impact)
+	fmt.Printf("Remediation\n\n")
+	printSteps(e.Remediation)
+
+	names := e.EngineNames()
+	if engine != "" {
+		name, _ := e.ForEngine(engine)
+		if name == "" {
+			fmt.Printf("\nNo remediation steps specific to %s.\n", engine)
+			names = nil
+		} else {
+			names = []string{name}
+		}
+	}
+	for _, name := range names {
+		fmt.Printf("\nRemediation on %s\n\n", name)
+		printSteps(e.Engines[name])
+	}
+	if len(e.References) > 0 {
+		fmt.Printf("\nReferences\n\n")
+		printSteps(e.References)
+	}
+}
+
+// printSteps prints items as a bulleted list.
+func printSteps(items []string) {
+	for _, item := range items {
+		fmt.Printf("- %s\n", item)
+	}
+}
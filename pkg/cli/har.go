@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/inference"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
+)
+
+// harUsage is printed for invalid har invocations.
+const harUsage = "usage: har [--out-dir dir] [--cache file] [--observe-schema file [--replay [--base url] [-H header]]] capture.har..."
+
+// HAR imports the GraphQL operations of the HAR archives of cfg, merged by
+// canonical hash, lists them, adds them to the operation cache of the
+// workspace and with --out-dir writes them as a batch directory. With
+// --observe-schema the recorded responses, or with --replay those of the
+// queries sent again, build a schema. It returns the process exit code.
+func HAR(cfg *types.HARConfig) int {
+	replayOptions := cfg.BaseURL != "" || len(cfg.Headers) > 0
+	if len(cfg.Files) == 0 || (replayOptions && !cfg.Replay) || (cfg.Replay && cfg.ObserveSchema == "") {
+		fmt.Fprintln(os.Stderr, harUsage)
+		return 2
+	}
+	var cache *workspace.OperationCache
+	if cfg.Cache != "" {
+		var err error
+		if cache, err = workspace.LoadOperationCache(cfg.Cache); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	imp := &workspace.HARImport{}
+	for _, file := range cfg.Files {
+		one, err := workspace.ImportHAR(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		logger.Info("%s: %d GraphQL requests in %d entries", file, len(one.Calls), one.Entries)
+		if one.Skipped > 0 {
+			logger.Warn("WARNING: %s: %d GraphQL requests without a document that parses, such as persisted queries, were skipped", file, one.Skipped)
+		}
+		if cache != nil {
+			added := 0
+			for _, op := range one.Operations() {
+				if cache.Add(op, filepath.Base(file)) {
+					added++
+				}
+			}
+			logger.Info("%s: %d operations not seen before", file, added)
+		}
+		imp.Entries += one.Entries
+		imp.Calls = append(imp.Calls, one.Calls...)
+		imp.Skipped += one.Skipped
+	}
+
+	ops := imp.Operations()
+	PrintHAROperations(ops)
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		logger.Info("Operation cache %s holds %d operations", cfg.Cache, cache.Len())
+	}
+	if cfg.OutDir != "" {
+		if err := writeHAROperations(ops, cfg.OutDir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		logger.Info("%d operations written to %s", len(ops), cfg.OutDir)
+	}
+	if cfg.ObserveSchema != "" {
+		observer := inference.NewObserver()
+		if cfg.Replay {
+			replayHAR(cfg, ops, observer)
+		} else {
+			for _, c := range imp.Calls {
+				observeHARCall(observer, c.Query, c.OperationName, c.Response)
+			}
+		}
+		if observer.Responses() == 0 {
+			fmt.Fprintln(os.Stderr, "No response carried data, not writing the observed schema")
+			return 1
+		}
+		if err := observer.WriteFile(cfg.ObserveSchema); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		logger.Info("Schema observed in %d response(s) saved to %s", observer.Responses(), cfg.ObserveSchema)
+	}
+	return 0
+}
+
+// replayHAR sends the queries of ops again, with the variables of their first
+// call, to the base URL of cfg or to each endpoint they were recorded at, and
+// feeds the responses to observer. Mutations and subscriptions are not sent.
+func replayHAR(cfg *types.HARConfig, ops []workspace.HAROperation, observer *inference.Observer) {
+	headers := map[string]string{"Content-Type": "application/json"}
+	if token := os.Getenv("AUTH_TOKEN"); token != "" {
+		headers["Authorization"] = "Bearer " + token
+	}
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	replayCtx, stop := network.StartModule(context.Background(), "har-replay")
+	defer stop()
+	for _, op := range ops {
+		if op.Kind != gql.OperationQuery {
+			logger.Info("Not replaying the %s %s", op.Kind, harLabel(op))
+			continue
+		}
+		endpoints := op.Endpoints
+		if cfg.BaseURL != "" {
+			endpoints = []string{cfg.BaseURL}
+		}
+		for _, endpoint := range endpoints {
+			ctx, cancel := context.WithTimeout(replayCtx, network.DefaultTimeout)
+			resp, err := network.SendGraphQLRequestWithContext(ctx, endpoint, op.Query, op.Variables, headers)
+			cancel()
+			if err != nil {
+				logger.Error("Replaying %s against %s: %v", harLabel(op), endpoint, err)
+				continue
+			}
+			observeHARCall(observer, op.Query, op.Name, resp)
+		}
+	}
+}
+
+// observeHARCall feeds resp, returned for the operation named name of query,
+// to observer.
+func observeHARCall(observer *inference.Observer, query, name string, resp map[string]interface{}) {
+	if resp == nil {
+		return
+	}
+	doc, err := gql.Parse(query)
+	if err != nil {
+		return
+	}
+	if len(doc.Operations) > 1 {
+		op := doc.OperationByName(name)
+		if op == nil {
+			logger.Debug("→ Not observing a document of %d operations without an operation name", len(doc.Operations))
+			return
+		}
+		if query, err = doc.OperationDocument(op, false); err != nil {
+			logger.Debug("→ Not observing %s: %v", name, err)
+			return
+		}
+	}
+	if err := observer.Observe(query, resp); err != nil {
+		logger.Debug("→ Not observing %s: %v", name, err)
+	}
+}
+
+// harLabel names op in log messages.
+func harLabel(op workspace.HAROperation) string {
+	if op.Name != "" {
+		return op.Name
+	}
+	return "operation " + op.Hash[:12]
+}
+
+// PrintHAROperations lists ops one per line: the start of the hash, the kind
+// and name, the number of calls and the endpoints.
+func PrintHAROperations(ops []workspace.HAROperation) {
+	for _, op := range ops {
+		name := op.Name
+		if name == "" {
+			name = "(anonymous)"
+		}
+		fmt.Printf("%s  %-12s %-30s %4d call(s)  %v\n", op.Hash[:12], op.Kind, name, op.Calls, op.Endpoints)
+	}
+}
+
+// writeHAROperations writes each of ops to dir as kind_name.graphql, with the
+// variables of its first call, masked like those of the history log, in
+// kind_name.json, so that dir can be run with --batch-dir.
+func writeHAROperations(ops []workspace.HAROperation, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating %s: %w", dir, err)
+	}
+	namer := schema.NewFileNamer("manifest", "batch-errors")
+	for _, op := range ops {
+		name := op.Name
+		if name == "" {
+			name = "anonymous_" + op.Hash[:8]
+		}
+		stem := filepath.Join(dir, namer.Name(op.Kind+"_"+name))
+		if err := artifacts.WriteFile(stem+".graphql", []byte(op.Query+"\n"), 0644); err != nil {
+			return fmt.Errorf("error writing operation %s: %w", name, err)
+		}
+		if len(op.Variables) == 0 {
+			continue
+		}
+		vars, _ := redact.Map(op.Variables)
+		data, err := json.MarshalIndent(vars, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling the variables of %s: %w", name, err)
+		}
+		if err := artifacts.WriteFile(stem+".json", data, 0644); err != nil {
+			return fmt.Errorf("error writing the variables of %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
+)
+
+func TestHARWritesBatchDirectory(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &types.HARConfig{
+		Files:  []string{filepath.Join("..", "workspace", "testdata", "capture.har")},
+		OutDir: filepath.Join(dir, "ops"),
+		Cache:  filepath.Join(dir, workspace.DefaultOperationCache),
+	}
+	if code := HAR(cfg); code != 0 {
+		t.Fatalf("HAR = %d", code)
+	}
+	names, _ := filepath.Glob(filepath.Join(cfg.OutDir, "*"))
+	for i := range names {
+		names[i] = filepath.Base(names[i])
+	}
+	want := "mutation_anonymous_aeb648f8.graphql query_GetUser.graphql query_GetUser.json query_anonymous_0119c7b9.graphql"
+	if strings.Join(names, " ") != want {
+		t.Errorf("files = %v, want %s", names, want)
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.OutDir, "query_GetUser.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vars map[string]interface{}
+	if err := json.Unmarshal(data, &vars); err != nil || vars["id"] != "1" || vars["password"] == "hunter2" {
+		t.Errorf("variables = %s, want the password masked", data)
+	}
+
+	cache, err := workspace.LoadOperationCache(cfg.Cache)
+	if err != nil || cache.Len() != 3 {
+		t.Errorf("cache of %d operations (%v), want 3", cache.Len(), err)
+	}
+}
+
+func TestHARUsage(t *testing.T) {
+	if code := HAR(&types.HARConfig{}); code != 2 {
+		t.Errorf("HAR without archives = %d, want 2", code)
+	}
+}
+
+func TestHARObserveSchema(t *testing.T) {
+	har := filepath.Join("..", "workspace", "testdata", "capture.har")
+	out := filepath.Join(t.TempDir(), "observed.json")
+	if code := HAR(&types.HARConfig{Files: []string{har}, ObserveSchema: out}); code != 0 {
+		t.Fatalf("HAR = %d", code)
+	}
+	fields := observedFields(t, out)
+	for _, f := range []string{"Query.user", "Query.posts", "Mutation.logout", "ObservedUser.name"} {
+		if !fields[f] {
+			t.Errorf("observed schema lacks %s: %v", f, fields)
+		}
+	}
+}
+
+func TestHARReplay(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req types.GraphQLRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		sent = append(sent, req.Query+" "+r.Header.Get("X-Test"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(req.Query, "user") {
+			_, _ = w.Write([]byte(`{"data":{"user":{"id":"1","name":"a"}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"posts":[{"id":"p1"}]}}`))
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "observed.json")
+	cfg := &types.HARConfig{
+		Files:         []string{filepath.Join("..", "workspace", "testdata", "capture.har")},
+		ObserveSchema: out,
+		Replay:        true,
+		BaseURL:       srv.URL,
+		Headers:       map[string]string{"X-Test": "1"},
+	}
+	if code := HAR(cfg); code != 0 {
+		t.Fatalf("HAR = %d", code)
+	}
+	// GetUser and posts are sent once each, the mutation never.
+	if len(sent) != 2 || strings.Contains(strings.Join(sent, "\n"), "logout") || !strings.HasSuffix(sent[0], " 1") {
+		t.Errorf("sent = %q", sent)
+	}
+	fields := observedFields(t, out)
+	if !fields["ObservedUser.name"] || !fields["ObservedPosts.id"] || fields["Mutation.logout"] {
+		t.Errorf("observed schema = %v, want the replayed responses only", fields)
+	}
+}
+
+func TestHARUsageReplay(t *testing.T) {
+	har := filepath.Join("..", "workspace", "testdata", "capture.har")
+	for _, cfg := range []*types.HARConfig{
+		{Files: []string{har}, Replay: true},
+		{Files: []string{har}, ObserveSchema: "x.json", BaseURL: "http://127.0.0.1:1"},
+	} {
+		if code := HAR(cfg); code != 2 {
+			t.Errorf("HAR(%+v) = %d, want 2", cfg, code)
+		}
+	}
+}
+
+// observedFields returns the Type.field names of the observed schema at path.
+func observedFields(t *testing.T, path string) map[string]bool {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc types.IntrospectionResponse
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string]bool)
+	for _, typ := range doc.Data.Schema.Types {
+		for _, f := range typ.Fields {
+			fields[typ.Name+"."+f.Name] = true
+		}
+	}
+	return fields
+}
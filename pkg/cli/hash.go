@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Hash prints the canonical hash, or with --canonical the canonical form, of
+// each configured document and returns the process exit code: 1 when any
+// document cannot be read or parsed. Files are listed like sha256sum does.
+func Hash(cfg *types.HashConfig) int {
+	files := append([]string(nil), cfg.Files...)
+	if cfg.QueryFile != "" {
+		files = append([]string{cfg.QueryFile}, files...)
+	}
+	if cfg.QueryString == "" && len(files) == 0 {
+		logger.Error("No document to hash: use --query-file, --query-string or pass files as arguments")
+		return 1
+	}
+
+	output := func(doc string) (string, error) {
+		if cfg.Canonical {
+			return gql.Canonicalize(doc)
+		}
+		return gql.CanonicalHash(doc)
+	}
+
+	status := 0
+	if cfg.QueryString != "" {
+		out, err := output(cfg.QueryString)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "query string: %v\n", err)
+			status = 1
+		} else if cfg.Canonical {
+			fmt.Print(out)
+		} else {
+			fmt.Println(out)
+		}
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			status = 1
+			continue
+		}
+		out, err := output(string(content))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			status = 1
+			continue
+		}
+		if cfg.Canonical {
+			fmt.Print(out)
+		} else {
+			fmt.Printf("%s  %s\n", out, file)
+		}
+	}
+	return status
+}
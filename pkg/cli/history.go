@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
+)
+
+// historyUsage is printed for invalid history invocations.
+const historyUsage = "usage: history [--file history.ndjson] list [--since 2h] [--op-name name] | show <id> | replay <id> [--base url] [-H header] [--vars json]"
+
+// History lists, shows or replays the entries of the history log of cfg and
+// returns the process exit code.
+func History(cfg *types.HistoryConfig) int {
+	action := "list"
+	if len(cfg.Args) > 0 {
+		action = cfg.Args[0]
+	}
+	if (action == "list" && len(cfg.Args) > 1) || ((action == "show" || action == "replay") && len(cfg.Args) != 2) {
+		fmt.Fprintln(os.Stderr, historyUsage)
+		return 2
+	}
+	entries, err := workspace.LoadHistory(cfg.File)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch action {
+	case "list":
+		filter := workspace.HistoryFilter{Operation: cfg.OpName}
+		if cfg.Since != "" {
+			if filter.Since, err = ParseSince(cfg.Since, time.Now()); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+		}
+		PrintHistory(pageHistory(workspace.FilterHistory(entries, filter), cfg.Offset, cfg.Limit))
+		return 0
+	case "show":
+		e, err := workspace.FindHistory(entries, cfg.Args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		out, _ := json.MarshalIndent(e, "", "  ")
+		fmt.Println(string(out))
+		return 0
+	case "replay":
+		e, err := workspace.FindHistory(entries, cfg.Args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := replayHistory(cfg, e); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, historyUsage)
+	return 2
+}
+
+// ParseSince parses a --since value: a duration before now, or an RFC 3339 time.
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q: use a duration such as 2h or an RFC 3339 time", value)
+	}
+	return t, nil
+}
+
+// pageHistory skips the offset most recent entries and returns up to limit of
+// the ones before them, oldest first. A limit of 0 returns them all.
+func pageHistory(entries []workspace.HistoryEntry, offset, limit int) []workspace.HistoryEntry {
+	end := len(entries) - offset
+	if end < 0 {
+		end = 0
+	}
+	start := 0
+	if limit > 0 && end-limit > 0 {
+		start = end - limit
+	}
+	return entries[start:end]
+}
+
+// PrintHistory prints one line per entry: its id, time, status, duration,
+// module, operation and endpoint.
+func PrintHistory(entries []workspace.HistoryEntry) {
+	for _, e := range entries {
+		status := fmt.Sprint(e.Status)
+		if e.Status == 0 {
+			status = "-"
+		}
+		operation := e.Operation
+		if operation == "" {
+			operation = "(anonymous)"
+			if e.Body != nil {
+				operation = "(raw body)"
+			}
+		}
+		module := e.Module
+		if module == "" {
+			module = "-"
+		}
+		fmt.Printf("%-8s  %s  %3s  %6dms  %-20s  %-24s  %s\n", e.ID, e.Time.Local().Format("2006-01-02 15:04:05"), status, e.DurationMs, module, operation, e.Endpoint)
+	}
+}
+
+// replayHistory sends e again, to cfg.BaseURL when set, and prints the
+// response. The replay is appended to the history log.
+func replayHistory(cfg *types.HistoryConfig, e workspace.HistoryEntry) error {
+	target := e.Endpoint
+	if cfg.BaseURL != "" {
+		target = cfg.BaseURL
+	}
+	if strings.Contains(target, redact.Mask) {
+		return fmt.Errorf("the endpoint of entry %s was redacted: replay it with --base", e.ID)
+	}
+
+	headers := make(map[string]string)
+	var masked []string
+	for k, v := range e.Headers {
+		if v == redact.Mask {
+			masked = append(masked, k)
+			continue
+		}
+		headers[k] = v
+	}
+	if token := os.Getenv("AUTH_TOKEN"); token != "" {
+		headers["Authorization"] = "Bearer " + token
+	}
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	for _, k := range masked {
+		if _, ok := headers[k]; !ok {
+			fmt.Fprintf(os.Stderr, "WARNING: the %s header of entry %s was redacted and is not sent; set it with -H\n", k, e.ID)
+		}
+	}
+
+	var body []byte
+	var documents []string
+	if e.Query != "" {
+		documents = append(documents, e.Query)
+	}
+	if e.Body != nil {
+		if cfg.Variables != "" {
+			return errors.New("--vars cannot be applied to an entry recorded as a raw body")
+		}
+		body = e.Body
+	} else {
+		variables := make(map[string]interface{}, len(e.Variables))
+		for k, v := range e.Variables {
+			variables[k] = v
+		}
+		if cfg.Variables != "" {
+			var overrides map[string]interface{}
+			if err := json.Unmarshal([]byte(cfg.Variables), &overrides); err != nil {
+				return fmt.Errorf("error parsing --vars JSON: %w", err)
+			}
+			for k, v := range overrides {
+				variables[k] = v
+			}
+		}
+		var redacted []string
+		for k, v := range variables {
+			if v == redact.Mask {
+				redacted = append(redacted, "$"+k)
+			}
+		}
+		if len(redacted) > 0 {
+			sort.Strings(redacted)
+			fmt.Fprintf(os.Stderr, "WARNING: variables %s of entry %s were redacted and are sent masked; set them with --vars\n", strings.Join(redacted, ", "), e.ID)
+		}
+		var err error
+		body, err = json.Marshal(types.GraphQLRequest{Query: e.Query, Variables: variables, OperationName: e.Operation})
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+	}
+
+	history, err := workspace.OpenHistory(cfg.File)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+	network.SetRequestHook(func(r network.SentRequest) {
+		if err := history.Record(r); err != nil {
+			logger.Error("%v", err)
+		}
+	})
+	defer network.SetRequestHook(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), network.DefaultTimeout)
+	defer cancel()
+	ctx, stop := network.StartModule(ctx, "replay")
+	var info network.ResponseInfo
+	resp, err := network.SendRawWithContext(network.WithResponseInfo(ctx, &info), target, body, headers, documents...)
+	stop()
+	if err != nil {
+		return fmt.Errorf("error replaying entry %s to %s: %w", e.ID, target, err)
+	}
+	fmt.Fprintf(os.Stderr, "Replayed %s to %s: HTTP %d in %s\n", e.ID, target, info.StatusCode, info.Duration.Round(time.Millisecond))
+	out, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error formatting response: %w", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
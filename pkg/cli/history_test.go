@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
+)
+
+// captureStdout returns what run prints to standard output.
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan string)
+	go func() {
+		var b bytes.Buffer
+		b.ReadFrom(r)
+		done <- b.String()
+	}()
+	run()
+	w.Close()
+	return <-done
+}
+
+// writeHistory writes entries to a new history log and returns its path.
+func writeHistory(t *testing.T, entries ...workspace.HistoryEntry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), workspace.DefaultHistoryFile)
+	h, err := workspace.OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	for _, e := range entries {
+		if err := h.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestHistoryListFilters(t *testing.T) {
+	now := time.Now().UTC()
+	file := writeHistory(t,
+		workspace.HistoryEntry{ID: "old00001", Time: now.Add(-50 * time.Hour), Operation: "Me", Endpoint: "https://api.example.com/graphql"},
+		workspace.HistoryEntry{ID: "new00002", Time: now.Add(-90 * time.Minute), Operation: "Orders", Endpoint: "https://api.example.com/graphql"},
+		workspace.HistoryEntry{ID: "new00003", Time: now.Add(-30 * time.Minute), Operation: "Me", Endpoint: "https://api.example.com/graphql"},
+		workspace.HistoryEntry{ID: "new00004", Time: now.Add(-time.Minute), Body: json.RawMessage(`[]`), Endpoint: "https://api.example.com/graphql"},
+	)
+	tests := []struct {
+		name string
+		cfg  types.HistoryConfig
+		want []string
+	}{
+		{"all", types.HistoryConfig{}, []string{"old00001", "new00002", "new00003", "new00004"}},
+		{"since duration", types.HistoryConfig{Since: "2h"}, []string{"new00002", "new00003", "new00004"}},
+		{"since time", types.HistoryConfig{Since: now.Add(-time.Hour).Format(time.RFC3339)}, []string{"new00003", "new00004"}},
+		{"op-name", types.HistoryConfig{OpName: "Me"}, []string{"old00001", "new00003"}},
+		{"since and op-name", types.HistoryConfig{Since: "2h", OpName: "Me"}, []string{"new00003"}},
+		{"limit keeps the latest", types.HistoryConfig{Limit: 2}, []string{"new00003", "new00004"}},
+		{"offset skips the latest", types.HistoryConfig{Limit: 2, Offset: 1}, []string{"new00002", "new00003"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.File = file
+			var code int
+			out := captureStdout(t, func() { code = History(&cfg) })
+			if code != 0 {
+				t.Fatalf("exit status %d", code)
+			}
+			var ids []string
+			for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+				if fields := strings.Fields(line); len(fields) > 0 {
+					ids = append(ids, fields[0])
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("listed %v, want %v\n%s", ids, tt.want, out)
+			}
+		})
+	}
+
+	if code := History(&types.HistoryConfig{File: file, Since: "yesterday"}); code != 2 {
+		t.Errorf("an invalid --since exited with %d, want 2", code)
+	}
+	if _, err := ParseSince("yesterday", now); err == nil || !strings.Contains(err.Error(), "RFC 3339") {
+		t.Errorf("ParseSince() = %v", err)
+	}
+}
+
+func TestHistoryReplay(t *testing.T) {
+	var received struct {
+		header http.Header
+		body   types.GraphQLRequest
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.header = r.Header.Clone()
+		json.NewDecoder(r.Body).Decode(&received.body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"login":{"ok":true}}}`))
+	}))
+	defer srv.Close()
+	t.Setenv("AUTH_TOKEN", "")
+
+	file := writeHistory(t,
+		workspace.HistoryEntry{
+			ID:        "ab12cd34",
+			Time:      time.Now().UTC(),
+			Endpoint:  "https://" + redact.Mask + "@api.example.com/graphql",
+			Operation: "Login",
+			Query:     "query Login($user: String, $password: String) { login(user: $user, password: $password) { ok } }",
+			Variables: map[string]interface{}{"user": "alice", "password": redact.Mask, "remember": true},
+			Headers:   map[string]string{"Authorization": redact.Mask, "X-Tenant": "acme"},
+		},
+		workspace.HistoryEntry{ID: "ff00ee11", Time: time.Now().UTC(), Endpoint: srv.URL, Body: json.RawMessage(`[{"query":"{ a }"}]`)},
+	)
+
+	// The recorded endpoint was redacted, so it needs --base.
+	if code := History(&types.HistoryConfig{File: file, Args: []string{"replay", "ab12"}}); code != 1 {
+		t.Errorf("replaying to a redacted endpoint exited with %d, want 1", code)
+	}
+
+	cfg := &types.HistoryConfig{
+		File:      file,
+		Args:      []string{"replay", "ab12"},
+		BaseURL:   srv.URL,
+		Headers:   map[string]string{"Authorization": "Bearer fresh"},
+		Variables: `{"password": "correct horse", "user": "bob"}`,
+	}
+	var code int
+	out := captureStdout(t, func() { code = History(cfg) })
+	if code != 0 {
+		t.Fatalf("exit status %d", code)
+	}
+	if !strings.Contains(out, `"ok": true`) {
+		t.Errorf("printed %q, want the response", out)
+	}
+	want := map[string]interface{}{"user": "bob", "password": "correct horse", "remember": true}
+	if received.body.OperationName != "Login" || !strings.HasPrefix(received.body.Query, "query Login(") || !reflect.DeepEqual(received.body.Variables, want) {
+		t.Errorf("the server received %+v, want the recorded query with the --vars overrides", received.body)
+	}
+	if received.header.Get("Authorization") != "Bearer fresh" || received.header.Get("X-Tenant") != "acme" {
+		t.Errorf("the server received the headers %v", received.header)
+	}
+
+	entries, err := workspace.LoadHistory(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("the log holds %d entries, want the replay appended", len(entries))
+	}
+	replay := entries[2]
+	if replay.Module != "replay" || replay.Endpoint != srv.URL || replay.Operation != "Login" || replay.Status != 200 || replay.Variables["password"] != redact.Mask {
+		t.Errorf("replay entry = %+v", replay)
+	}
+
+	// Variables cannot be merged into a raw body.
+	if code := History(&types.HistoryConfig{File: file, Args: []string{"replay", "ff00"}, Variables: `{"a": 1}`}); code != 1 {
+		t.Errorf("--vars on a raw body exited with %d, want 1", code)
+	}
+	if code := History(&types.HistoryConfig{File: file, Args: []string{"replay"}}); code != 2 {
+		t.Errorf("replay without an id exited with %d, want 2", code)
+	}
+}
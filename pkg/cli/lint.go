@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Lint measures every operation in the configured documents against the limits,
+// prints a per-file table and returns the process exit code: 1 when any document
+// fails to parse or violates a limit.
+func Lint(cfg *types.LintConfig) int {
+	files := append([]string(nil), cfg.Files...)
+	if cfg.Dir != "" {
+		matches, err := filepath.Glob(filepath.Join(cfg.Dir, "*.graphql"))
+		if err != nil {
+			logger.Error("Error scanning directory: %v", err)
+			return 1
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		logger.Error("No documents to lint: use --dir or pass files as arguments")
+		return 1
+	}
+
+	limits := gql.Limits{
+		MaxDepth:      cfg.MaxDepth,
+		MaxAliases:    cfg.MaxAliases,
+		MaxRootFields: cfg.MaxRootFields,
+		MaxSelections: cfg.MaxSelections,
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tOPERATION\tDEPTH\tALIASES\tROOT FIELDS\tSELECTIONS\tRESULT")
+	for _, file := range files {
+		name := filepath.Base(file)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\tread error: %v\n", name, err)
+			failed++
+			continue
+		}
+		doc, err := gql.Parse(string(content))
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\t%v\n", name, err)
+			failed++
+			continue
+		}
+		metrics, err := gql.ComputeMetrics(doc)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\t%v\n", name, err)
+			failed++
+			continue
+		}
+		for _, m := range metrics {
+			result := "ok"
+			if v := m.Violations(limits); len(v) > 0 {
+				result = strings.Join(v, "; ")
+				failed++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", name, m.Operation, m.Depth, m.Aliases, m.RootFields, m.Selections, result)
+		}
+	}
+	w.Flush()
+
+	if failed > 0 {
+		fmt.Printf("\n%d problem(s) found\n", failed)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+const tenantSchema = `{"data":{"__schema":{"queryType":{"name":"Query"},"mutationType":null,"subscriptionType":null,"directives":[],
+	"types":[{"kind":"OBJECT","name":"Query","fields":[
+		{"name":"me","args":[],"type":{"kind":"SCALAR","name":"String","ofType":null}},
+		{"name":"invoices","args":[],"type":{"kind":"SCALAR","name":"String","ofType":null}},
+		{"name":"users","args":[],"type":{"kind":"SCALAR","name":"String","ofType":null}}]},
+	{"kind":"SCALAR","name":"String"}]}}}`
+
+// tenantServer serves tenantSchema, me to everyone and users to no one. It
+// serves invoices when invoices is set and denies them otherwise.
+func tenantServer(t *testing.T, invoices bool) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		denied := `{"data":null,"errors":[{"message":"Forbidden","extensions":{"code":"FORBIDDEN"}}]}`
+		switch {
+		case strings.Contains(req.Query, "__schema"):
+			w.Write([]byte(tenantSchema))
+		case strings.Contains(req.Query, "invoices") && invoices:
+			w.Write([]byte(`{"data":{"invoices":"INV-1"}}`))
+		case strings.Contains(req.Query, "me"):
+			w.Write([]byte(`{"data":{"me":"alice"}}`))
+		default:
+			w.Write([]byte(denied))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// TestAccessMatrixThreeTenants extracts three tenants of which only the
+// second serves invoices: the matrix aggregates the three and reports that
+// tenant as the outlier.
+func TestAccessMatrixThreeTenants(t *testing.T) {
+	urls := []string{tenantServer(t, false), tenantServer(t, true), tenantServer(t, false)}
+	selected, err := checks.Select("introspection", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	recorder, err := report.NewMatrixRecorder(filepath.Join(dir, "matrix"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := AuditEndpoints(context.Background(), urls, nil, AuditOptions{
+		Checks:     selected,
+		Extract:    true,
+		ExtractDir: filepath.Join(dir, "extract"),
+		Matrix:     recorder,
+	})
+
+	m := rep.Matrix
+	if m == nil || !reflect.DeepEqual(m.Targets, urls) {
+		t.Fatalf("matrix = %+v, want the three targets", m)
+	}
+	rows := make(map[string]report.MatrixRow)
+	for _, r := range m.Rows {
+		rows[r.Operation] = r
+	}
+	want := map[string][]string{
+		"me":       {report.CellAccessible, report.CellAccessible, report.CellAccessible},
+		"invoices": {report.CellDenied, report.CellAccessible, report.CellDenied},
+		"users":    {report.CellDenied, report.CellDenied, report.CellDenied},
+	}
+	if len(rows) != len(want) {
+		t.Errorf("matrix rows %+v, want %d", m.Rows, len(want))
+	}
+	for op, cells := range want {
+		if !reflect.DeepEqual(rows[op].Cells, cells) {
+			t.Errorf("%s: cells %q, want %q", op, rows[op].Cells, cells)
+		}
+	}
+	if rows["invoices"].Outlier != urls[1] || rows["me"].Outlier != "" || rows["users"].Outlier != "" {
+		t.Errorf("outliers: invoices %q, me %q, users %q; want only invoices on %s", rows["invoices"].Outlier, rows["me"].Outlier, rows["users"].Outlier, urls[1])
+	}
+	if divergent := m.Divergent(); len(divergent) != 1 || divergent[0].Operation != "invoices" {
+		t.Errorf("divergent rows %+v, want invoices only", divergent)
+	}
+
+	var outliers []report.Finding
+	for _, f := range rep.Findings {
+		if f.ID == "operation-access-outlier" {
+			outliers = append(outliers, f)
+		}
+	}
+	if len(outliers) != 1 {
+		t.Fatalf("%d outlier findings, want 1: %+v", len(outliers), outliers)
+	}
+	f := outliers[0]
+	if f.Endpoint != urls[1] || !strings.Contains(f.Description, "the 2 other targets serving it denied it") ||
+		!strings.HasSuffix(f.Evidence, "denied on "+urls[0]+", "+urls[2]) || !strings.HasPrefix(f.Evidence, "invoices ("+rows["invoices"].Hash+")") {
+		t.Errorf("outlier finding = %+v", f)
+	}
+}
@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/attacks"
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+// eachDetected is the target of the steps repeated on every endpoint that
+// detection confirms.
+const eachDetected = "(each detected endpoint)"
+
+// PlanStep is a part of a run and the requests it would send to one target.
+type PlanStep struct {
+	Step   string
+	Target string
+	checks.Plan
+	// Unknown marks network steps whose requests cannot be counted in advance.
+	Unknown bool
+}
+
+// PlannedOperation is an operation a run would execute.
+type PlannedOperation struct {
+	Source string
+	Kind   string
+	Name   string
+	// Destructive marks mutations with a root field named like a deletion,
+	// reset or revocation.
+	Destructive bool
+}
+
+// RequestPlan is what a run would send, as printed by --dry-run.
+type RequestPlan struct {
+	// Endpoints are the URLs probed by detection or audited directly.
+	Endpoints []string
+	Steps     []PlanStep
+	// PerEndpoint holds the steps repeated on every endpoint detection confirms.
+	PerEndpoint []PlanStep
+	Operations  []PlannedOperation
+}
+
+// PlanAudit returns the requests an audit of bases with opts would send,
+// detecting their endpoints first with detect. Nothing is sent.
+func PlanAudit(bases []string, detect bool, headers map[string]string, opts AuditOptions) *RequestPlan {
+	p := &RequestPlan{}
+	if !detect {
+		p.Endpoints = bases
+		for _, target := range bases {
+			if opts.State != nil {
+				if _, done := opts.State.Done(target); done {
+					p.Steps = append(p.Steps, PlanStep{Step: "(resume)", Target: target, Plan: checks.Plan{Note: "completed in a previous run"}})
+					continue
+				}
+			}
+			p.Steps = append(p.Steps, planTarget(target, headers, opts)...)
+		}
+		return p
+	}
+
+	paths := data.Paths()
+	for _, base := range bases {
+		u, err := network.ParseBase(base)
+		if err != nil {
+			p.Steps = append(p.Steps, PlanStep{Step: "detection", Target: base, Plan: checks.Plan{Note: err.Error()}})
+			continue
+		}
+		for _, path := range paths {
+			p.Endpoints = append(p.Endpoints, network.JoinEndpoint(u, path))
+		}
+		step := PlanStep{Step: "detection", Target: base, Plan: checks.Plan{Requests: len(paths), MaxRequests: len(paths)}}
+		if hasCredentials(headers) {
+			// Paths that require authentication are probed again with the credentials.
+			step.MaxRequests *= 2
+			step.Note = "paths that require authentication are retried with credentials"
+		}
+		p.Steps = append(p.Steps, step)
+	}
+	p.PerEndpoint = planTarget(eachDetected, headers, opts)
+	return p
+}
+
+// planTarget returns the steps of the audit of one target.
+func planTarget(target string, headers map[string]string, opts AuditOptions) []PlanStep {
+	deps := &checks.Deps{
+		Headers:    headers,
+		WSURL:      opts.WSURL,
+		MaxDepth:   opts.MaxDepth,
+		Selection:  opts.Selection,
+		OutputFile: opts.OutputFile,
+		Injection:  opts.Injection,
+
+		ChunkedIntrospection:   opts.ChunkedIntrospection,
+		IntrospectionChunkSize: opts.IntrospectionChunkSize,
+
+		IntrospectionTier: introspection.TierNone,
+	}
+	var catalog *schema.Catalog
+	if opts.Saved != nil {
+		catalog = schema.BuildCatalog(opts.Saved.Schema, schema.CatalogOptions{MaxDepth: opts.MaxDepth, Selection: opts.Selection})
+		opts.Saved.apply(deps, catalog)
+	}
+
+	var steps []PlanStep
+	if opts.Canary != nil {
+		// Sent once before the checks and once after the audit.
+		steps = append(steps, PlanStep{Step: "canary", Target: target, Plan: checks.Plan{Requests: 2, MaxRequests: 2}})
+	}
+	if hasCredentials(headers) && !opts.Offline {
+		// Sent with the credentials, then without them unless they were rejected.
+		steps = append(steps, PlanStep{Step: "auth-verify", Target: target, Plan: checks.Plan{Requests: 1, MaxRequests: 2}})
+	}
+	selected := opts.Checks
+	if opts.Offline {
+		selected = offlineChecks(selected)
+	}
+	for _, c := range selected {
+		plan, known := checks.PlanOf(c, target, deps)
+		steps = append(steps, PlanStep{Step: c.ID(), Target: target, Plan: plan, Unknown: !known})
+	}
+	if opts.Extract && !opts.Offline {
+		step := PlanStep{Step: "extraction", Target: target}
+		if catalog != nil {
+			step.Requests, step.MaxRequests = attacks.PlanRequests(catalog, opts.Pagination)
+		} else {
+			step.Unknown = true
+			step.Note = "one request per query of the introspected schema"
+		}
+		steps = append(steps, step)
+	}
+	if len(opts.Identities) > 0 && !opts.Offline {
+		step := PlanStep{Step: "authz", Target: target}
+		if catalog != nil {
+			n := len(catalog.Queries()) * len(opts.Identities)
+			step.Requests, step.MaxRequests = n, n
+		} else {
+			step.Unknown = true
+			step.Note = fmt.Sprintf("one request per query of the introspected schema and identity, %d identities", len(opts.Identities))
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// PlanRequest returns the single request that step, such as --execute, would
+// send to url with ops, the operations of its document.
+func PlanRequest(step, url string, ops []PlannedOperation) *RequestPlan {
+	return &RequestPlan{
+		Endpoints:  []string{url},
+		Steps:      []PlanStep{{Step: step, Target: url, Plan: checks.Plan{Requests: 1, MaxRequests: 1}}},
+		Operations: ops,
+	}
+}
+
+// PlanOperations classifies the operations of document, read from source.
+func PlanOperations(source, document string) []PlannedOperation {
+	var ops []PlannedOperation
+	parsed, err := gql.Parse(document)
+	if err != nil || len(parsed.Operations) == 0 {
+		for _, op := range network.ClassifyDocument(document) {
+			ops = append(ops, PlannedOperation{Source: source, Kind: op.Kind, Name: op.Name})
+		}
+		return ops
+	}
+	for _, op := range parsed.Operations {
+		planned := PlannedOperation{Source: source, Kind: op.Kind, Name: op.Name}
+		if op.Kind == gql.OperationMutation {
+			for _, sel := range op.SelectionSet {
+				if f, ok := sel.(*gql.Field); ok && schema.IsDestructiveName(f.Name) {
+					planned.Destructive = true
+				}
+			}
+		}
+		ops = append(ops, planned)
+	}
+	return ops
+}
+
+// totals sums the requests of steps. unknown counts the network steps that
+// could not be planned.
+func totals(steps []PlanStep) (min, max, unknown int) {
+	for _, s := range steps {
+		if s.Unknown {
+			unknown++
+			continue
+		}
+		min += s.Requests
+		max += s.MaxRequests
+	}
+	return min, max, unknown
+}
+
+// PrintPlan prints the endpoints, steps and operations of p with the total
+// request count and, when rate limits requests per second, the time they take.
+func PrintPlan(p *RequestPlan, rate float64) {
+	fmt.Println("Dry run: no requests were sent")
+	fmt.Printf("\nEndpoints (%d):\n", len(p.Endpoints))
+	for _, e := range p.Endpoints {
+		fmt.Printf("  %s\n", e)
+	}
+
+	printSteps := func(title string, steps []PlanStep) {
+		if len(steps) == 0 {
+			return
+		}
+		fmt.Printf("\n%s:\n", title)
+		fmt.Printf("  %-22s %-40s %-11s %s\n", "STEP", "TARGET", "REQUESTS", "NOTE")
+		for _, s := range steps {
+			fmt.Printf("  %-22s %-40s %-11s %s\n", s.Step, s.Target, requestRange(s.Requests, s.MaxRequests, s.Unknown), s.Note)
+		}
+	}
+	printSteps("Steps", p.Steps)
+	printSteps("Steps per detected endpoint", p.PerEndpoint)
+
+	if len(p.Operations) > 0 {
+		fmt.Printf("\nOperations (%d):\n", len(p.Operations))
+		fmt.Printf("  %-13s %-30s %-12s %s\n", "KIND", "NAME", "DESTRUCTIVE", "SOURCE")
+		for _, op := range p.Operations {
+			name := op.Name
+			if name == "" {
+				name = "(anonymous)"
+			}
+			destructive := "no"
+			if op.Destructive {
+				destructive = "YES"
+			}
+			fmt.Printf("  %-13s %-30s %-12s %s\n", op.Kind, name, destructive, op.Source)
+		}
+	}
+
+	min, max, unknown := totals(p.Steps)
+	fmt.Printf("\nEstimated requests: %s", requestRange(min, max, false))
+	if len(p.PerEndpoint) > 0 {
+		epMin, epMax, epUnknown := totals(p.PerEndpoint)
+		fmt.Printf(", plus %s per detected endpoint", requestRange(epMin, epMax, false))
+		unknown += epUnknown
+	}
+	fmt.Println()
+	if unknown > 0 {
+		fmt.Printf("  %d step(s) marked ? send requests that depend on the target and are not counted\n", unknown)
+	}
+	if rate > 0 {
+		fmt.Printf("Estimated duration at %g request(s)/s: %s", rate, durationRange(min, max, rate))
+		if len(p.PerEndpoint) > 0 {
+			epMin, epMax, _ := totals(p.PerEndpoint)
+			fmt.Printf(", plus %s per detected endpoint", durationRange(epMin, epMax, rate))
+		}
+		fmt.Println()
+	} else {
+		fmt.Println("Estimated duration: requests are not rate limited (--rate 0), so it depends on server latency")
+	}
+}
+
+// requestRange formats min and max as "n" or "min-max", or "?" when unknown.
+func requestRange(min, max int, unknown bool) string {
+	if unknown {
+		return "?"
+	}
+	if min == max {
+		return fmt.Sprintf("%d", min)
+	}
+	return fmt.Sprintf("%d-%d", min, max)
+}
+
+// durationRange formats the time min and max requests take at rate per second.
+func durationRange(min, max int, rate float64) string {
+	at := func(n int) time.Duration {
+		return (time.Duration(float64(n) / rate * float64(time.Second))).Round(time.Second)
+	}
+	if min == max {
+		return at(min).String()
+	}
+	return at(min).String() + "-" + at(max).String()
+}
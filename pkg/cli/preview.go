@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+)
+
+// OperationPreview is the request --preview shows for an operation.
+type OperationPreview struct {
+	Label   string
+	Request network.RequestPreview
+	// Synthesized names the variables filled with placeholders.
+	Synthesized []string
+}
+
+// Synthesized returns the sorted names of the variables of filled that were
+// not in supplied, the placeholders FillVariables added.
+func Synthesized(supplied, filled map[string]interface{}) []string {
+	var names []string
+	for name := range filled {
+		if _, ok := supplied[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PrintPreviews prints the method, URL, headers and body of each request as
+// it would be sent, followed by the placeholders it carries and the transport
+// settings in effect. With redactHeaders, credential headers are masked.
+func PrintPreviews(previews []OperationPreview, redactHeaders bool) {
+	fmt.Println("Preview: no requests were sent")
+	for _, p := range previews {
+		req := p.Request
+		headers := req.Headers
+		if redactHeaders {
+			headers, _ = redact.Headers(headers)
+		}
+		fmt.Printf("\n%s:\n", p.Label)
+		fmt.Printf("  %s %s\n", req.Method, req.URL)
+		for _, name := range req.HeaderNames() {
+			fmt.Printf("  %s: %s\n", name, headers[name])
+		}
+		fmt.Printf("\n  %s\n\n", req.Body)
+		if len(p.Synthesized) > 0 {
+			names := make([]string, len(p.Synthesized))
+			for i, n := range p.Synthesized {
+				names[i] = "$" + n
+			}
+			fmt.Printf("  Placeholders: %s\n", strings.Join(names, ", "))
+		}
+		fmt.Printf("  Transport:    %s\n", transportSummary(req))
+	}
+}
+
+// transportSummary describes the transport settings of req on one line.
+func transportSummary(req network.RequestPreview) string {
+	parts := []string{"encoding " + req.Encoding.String()}
+	if req.Proxy != "" {
+		parts = append(parts, "proxy "+req.Proxy)
+	} else {
+		parts = append(parts, "no proxy")
+	}
+	if req.Connect != "" {
+		parts = append(parts, "connecting to "+req.Connect)
+	}
+	if req.VirtualHost != "" {
+		parts = append(parts, "Host "+req.VirtualHost)
+	}
+	if req.ClientCertificate {
+		parts = append(parts, "TLS client certificate")
+	} else {
+		parts = append(parts, "default TLS")
+	}
+	if req.Signed {
+		parts = append(parts, "signed")
+	}
+	parts = append(parts, fmt.Sprintf("timeout %s", req.Timeout), fmt.Sprintf("%d retries on rate limiting", req.Retries))
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CyberRoute/graphspecter/pkg/inference"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// RecoverOptions configure a schema recovery run.
+type RecoverOptions struct {
+	Endpoint string
+	Headers  map[string]string
+	// Wordlist and ArgWordlist are the references of the field and argument
+	// names probed, see inference.OpenWordlist. Without ArgWordlist no
+	// argument is probed.
+	Wordlist    string
+	ArgWordlist string
+	Workers     int
+	Seed        int64
+	// Resume is the checkpoint the run resumes from when it exists and saves
+	// its progress to every CheckpointEvery requests and when it stops.
+	Resume          string
+	CheckpointEvery int
+	// Out is the file the recovered schema is written to.
+	Out string
+}
+
+// RecoverSchema brute forces the schema of the endpoint of opts with the
+// names of its wordlist and writes what it found to opts.Out, also when the
+// run is interrupted or a probe fails. It reports whether it wrote opts.Out.
+func RecoverSchema(ctx context.Context, opts RecoverOptions) (bool, error) {
+	words, err := recoveryWords(opts.Wordlist)
+	if err != nil {
+		return false, err
+	}
+	var args []string
+	if opts.ArgWordlist != "" {
+		if args, err = recoveryWords(opts.ArgWordlist); err != nil {
+			return false, err
+		}
+	}
+
+	c := inference.NewCheckpoint(opts.Endpoint, opts.Seed)
+	if opts.Resume != "" {
+		saved, err := inference.LoadCheckpoint(opts.Resume)
+		if err != nil {
+			return false, err
+		}
+		if saved != nil {
+			if saved.Endpoint != opts.Endpoint {
+				return false, fmt.Errorf("checkpoint %s was saved for %s, not %s", opts.Resume, saved.Endpoint, opts.Endpoint)
+			}
+			logger.Info("Resuming the recovery saved in %s after %d requests, with its seed %d", opts.Resume, saved.Requests, saved.Seed)
+			c = saved
+		}
+	}
+
+	ctx, stop := network.StartModule(ctx, "schema-recovery")
+	defer stop()
+	rc := &inference.Recovery{
+		Send:       recoverySend(opts),
+		Fields:     words,
+		Arguments:  args,
+		Workers:    opts.Workers,
+		Checkpoint: opts.Resume,
+		Every:      opts.CheckpointEvery,
+	}
+	runErr := rc.Run(ctx, c)
+	stop()
+	if opts.Resume != "" {
+		if err := c.Save(opts.Resume); err != nil {
+			return false, err
+		}
+		logger.Info("Recovery progress saved to %s after %d requests", opts.Resume, c.Requests)
+	}
+
+	fields := 0
+	for _, names := range c.Fields {
+		fields += len(names)
+	}
+	if fields == 0 {
+		if runErr != nil {
+			return false, runErr
+		}
+		return false, fmt.Errorf("no field of %s was confirmed in %d requests: the server may not validate probes field by field", opts.Endpoint, c.Requests)
+	}
+	if err := c.WriteSchema(opts.Out); err != nil {
+		return false, err
+	}
+	logger.Info("%d fields of %d types recovered in %d requests saved to %s", fields, len(c.Fields), c.Requests, opts.Out)
+	return true, runErr
+}
+
+// recoveryWords reads the wordlist named by ref.
+func recoveryWords(ref string) ([]string, error) {
+	var words []string
+	stats, err := inference.EachWord(ref, func(word string) error {
+		words = append(words, word)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stats.Words == 0 {
+		return nil, fmt.Errorf("wordlist %s holds no valid name", ref)
+	}
+	logger.Info("Probing the %d names of %s (%d duplicates and %d invalid entries left out)", stats.Words, ref, stats.Duplicates, stats.Invalid)
+	return words, nil
+}
+
+// recoverySend sends the probes of a recovery run to the endpoint of opts.
+func recoverySend(opts RecoverOptions) inference.Send {
+	return func(ctx context.Context, document string) (map[string]interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, network.DefaultTimeout)
+		defer cancel()
+		return network.SendGraphQLRequestWithContext(ctx, opts.Endpoint, document, nil, opts.Headers)
+	}
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/inference"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// probedField matches the field and argument of a recovery probe on Query.
+var probedField = regexp.MustCompile(`^query \{ (\w+)(?:\((\w+): 0\))? \}$`)
+
+// recoveryServer answers recovery probes for a schema whose only field is
+// Query.version(format: String), counting the requests.
+func recoveryServer(t *testing.T, requests *int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		var req types.GraphQLRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		m := probedField.FindStringSubmatch(req.Query)
+		switch {
+		case m == nil:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"Syntax Error"}]}`))
+		case m[1] != "version":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"errors":[{"message":"Cannot query field \"%s\" on type \"Query\"."}]}`, m[1])
+		case m[2] == "format":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"String cannot represent a non string value: 0"}]}`))
+		case m[2] != "":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"errors":[{"message":"Unknown argument \"%s\" on field \"Query.version\"."}]}`, m[2])
+		default:
+			_, _ = w.Write([]byte(`{"data":{"version":"1.0"}}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRecoverSchemaResumes(t *testing.T) {
+	var requests int32
+	srv := recoveryServer(t, &requests)
+	dir := t.TempDir()
+	fields := filepath.Join(dir, "fields.txt")
+	args := filepath.Join(dir, "args.txt")
+	if err := os.WriteFile(fields, []byte("# fields\nversion\nname\nversion\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(args, []byte("limit\nformat\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := RecoverOptions{
+		Endpoint:        srv.URL,
+		Wordlist:        fields,
+		ArgWordlist:     args,
+		Workers:         2,
+		Seed:            1,
+		Resume:          filepath.Join(dir, "state.json"),
+		CheckpointEvery: 1,
+		Out:             filepath.Join(dir, "recovered.json"),
+	}
+	written, err := RecoverSchema(context.Background(), opts)
+	if err != nil || !written {
+		t.Fatalf("RecoverSchema = %v, %v", written, err)
+	}
+	// version and name, then the two arguments of version.
+	if requests != 4 {
+		t.Errorf("%d requests, want 4", requests)
+	}
+	c, err := inference.LoadCheckpoint(opts.Resume)
+	if err != nil || c == nil {
+		t.Fatalf("LoadCheckpoint = %v, %v", c, err)
+	}
+	if strings.Join(c.Fields["Query"], " ") != "version" || strings.Join(c.Arguments["Query.version"], " ") != "format" || c.Types["Query.version.format"] != "String" {
+		t.Errorf("checkpoint = %+v", c)
+	}
+	data, err := os.ReadFile(opts.Out)
+	if err != nil || !strings.Contains(string(data), `"name": "format"`) {
+		t.Errorf("recovered schema = %s, %v", data, err)
+	}
+
+	// A finished checkpoint leaves nothing to probe.
+	if _, err := RecoverSchema(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 4 {
+		t.Errorf("the resumed run sent %d requests", requests-4)
+	}
+
+	opts.Endpoint = srv.URL + "/other"
+	if _, err := RecoverSchema(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "was saved for") {
+		t.Errorf("resuming against another endpoint: %v", err)
+	}
+}
+
+func TestRecoverSchemaConfirmsNothing(t *testing.T) {
+	var requests int32
+	srv := recoveryServer(t, &requests)
+	dir := t.TempDir()
+	fields := filepath.Join(dir, "fields.txt")
+	if err := os.WriteFile(fields, []byte("name\nadmin\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "recovered.json")
+	written, err := RecoverSchema(context.Background(), RecoverOptions{Endpoint: srv.URL, Wordlist: fields, Workers: 4, Out: out})
+	if err == nil || written || !strings.Contains(err.Error(), "no field") {
+		t.Errorf("RecoverSchema = %v, %v", written, err)
+	}
+	if _, statErr := os.Stat(out); !os.IsNotExist(statErr) {
+		t.Errorf("a schema was written: %v", statErr)
+	}
+	if _, err := RecoverSchema(context.Background(), RecoverOptions{Endpoint: srv.URL, Wordlist: inference.BuiltinPrefix + "nope", Out: out}); err == nil {
+		t.Error("an unknown wordlist was accepted")
+	}
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// reproUsage is printed for invalid repro invocations.
+const reproUsage = "usage: repro --finding id [--endpoint url] [--report report.json] [--out repro_test.go]"
+
+// Repro writes a standalone Go program reproducing a finding of a JSON report
+// and returns the process exit code: 1 when the finding or its request cannot
+// be found, 2 for invalid options.
+func Repro(cfg *types.ReproConfig) int {
+	if cfg.Finding == "" || cfg.Out == "" {
+		fmt.Fprintln(os.Stderr, reproUsage)
+		return 2
+	}
+	rep, err := report.LoadReport(cfg.Report)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	f, err := reproFinding(rep, cfg.Finding, cfg.Endpoint)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	req, err := report.ReproRequest(f, filepath.Dir(cfg.Report))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	test := strings.HasSuffix(cfg.Out, "_test.go")
+	src, err := report.Repro(f, *req, test)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := artifacts.WriteFile(cfg.Out, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing reproduction: %v\n", err)
+		return 1
+	}
+	run := "go run " + cfg.Out
+	if test {
+		run = "go test -v in its directory"
+	}
+	fmt.Printf("Reproduction of %s on %s written to %s (run with %s)\n", f.ID, f.Endpoint, cfg.Out, run)
+	return 0
+}
+
+// reproFinding returns the first finding id of rep, on endpoint when set. A
+// finding reported on several endpoints needs one.
+func reproFinding(rep *report.Report, id, endpoint string) (report.Finding, error) {
+	var matches []report.Finding
+	for _, f := range rep.Findings {
+		if f.ID == id && (endpoint == "" || f.Endpoint == endpoint) {
+			matches = append(matches, f)
+		}
+	}
+	if len(matches) == 0 {
+		if endpoint != "" {
+			return report.Finding{}, fmt.Errorf("no finding %s on %s in the report", id, endpoint)
+		}
+		return report.Finding{}, fmt.Errorf("no finding %s in the report", id)
+	}
+	var endpoints []string
+	seen := make(map[string]bool)
+	for _, f := range matches {
+		if !seen[f.Endpoint] {
+			seen[f.Endpoint] = true
+			endpoints = append(endpoints, f.Endpoint)
+		}
+	}
+	if len(endpoints) > 1 {
+		return report.Finding{}, fmt.Errorf("finding %s is reported on several endpoints, pick one with --endpoint: %s", id, strings.Join(endpoints, ", "))
+	}
+	return matches[0], nil
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// SavedIntrospection is an introspection result loaded from a previous run,
+// audited in place of the one the introspection check would fetch.
+type SavedIntrospection struct {
+	Path   string
+	Result map[string]interface{}
+	Schema *types.GQLSchema
+}
+
+// LoadSavedIntrospection reads and validates the introspection result in path.
+func LoadSavedIntrospection(path string) (*SavedIntrospection, error) {
+	s, err := schema.LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	if _, ok := result["data"]; !ok {
+		// A bare {"__schema": ...} document; checks expect a full response.
+		result = map[string]interface{}{"data": result}
+	}
+	return &SavedIntrospection{Path: path, Result: result, Schema: s}, nil
+}
+
+// apply stores the saved result in deps as if the introspection check had fetched it.
+func (s *SavedIntrospection) apply(deps *checks.Deps, catalog *schema.Catalog) {
+	deps.IntrospectionFile = s.Path
+	deps.Introspection = s.Result
+	deps.Schema = s.Schema
+	deps.Catalog = catalog
+}
+
+// offlineChecks keeps the checks of selected that send no requests and logs the
+// ones left out.
+func offlineChecks(selected []checks.Check) []checks.Check {
+	offline, online := checks.Offline(selected)
+	if len(online) > 0 {
+		ids := make([]string, 0, len(online))
+		for _, c := range online {
+			ids = append(ids, c.ID())
+		}
+		logger.Info("Offline mode: skipping network checks %s", strings.Join(ids, ", "))
+	}
+	return offline
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// schemaUsage is printed for invalid schema invocations.
+const schemaUsage = "usage: schema lock --base url | --schema-file file [--offline] [--out schema.lock] | verify --base url | --schema-file file [--offline] [--lock schema.lock] [-H header]"
+
+// Schema writes the lock of the schema of an endpoint or saved introspection
+// result, or verifies the schema against a lock, and returns the process exit
+// code: 1 when the schema drifted from the lock or cannot be read, 2 for
+// invalid options.
+func Schema(cfg *types.SchemaConfig) int {
+	if len(cfg.Args) != 1 || (cfg.BaseURL == "") == (cfg.SchemaFile == "") || (cfg.Args[0] != "lock" && cfg.Args[0] != "verify") {
+		fmt.Fprintln(os.Stderr, schemaUsage)
+		return 2
+	}
+	if cfg.Offline {
+		if cfg.SchemaFile == "" {
+			fmt.Fprintln(os.Stderr, "--offline needs --schema-file")
+			return 2
+		}
+		network.SetOffline(true)
+	}
+	source := cfg.BaseURL
+	if cfg.SchemaFile != "" {
+		source = cfg.SchemaFile
+	}
+	ctx, cancel := SetupSignalHandler(context.Background())
+	defer cancel()
+	ctx, timeoutCancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer timeoutCancel()
+
+	live, err := fetchLock(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if cfg.Args[0] == "lock" {
+		if err := schema.WriteLock(live, cfg.Out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Schema lock of %s written to %s (%d types, %s)\n", source, cfg.Out, len(live.Types), live.Hash)
+		return 0
+	}
+
+	locked, err := schema.LoadLock(cfg.Lock)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if locked.Hash == live.Hash {
+		fmt.Printf("Schema of %s matches %s (%s)\n", source, cfg.Lock, live.Hash)
+		return 0
+	}
+	drift := schema.LockDrift(locked, live)
+	fmt.Printf("Schema of %s drifted from %s: %d difference(s)\n", source, cfg.Lock, len(drift))
+	for _, d := range drift {
+		fmt.Println(jsondiff.Render([]jsondiff.Difference{d}))
+	}
+	return 1
+}
+
+// fetchLock introspects the endpoint of cfg, or reads its schema file, and
+// returns the lock of its schema.
+func fetchLock(ctx context.Context, cfg *types.SchemaConfig) (*schema.Lock, error) {
+	if cfg.SchemaFile != "" {
+		s, err := schema.LoadFromFile(cfg.SchemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading the schema of %s: %w", cfg.SchemaFile, err)
+		}
+		return schema.BuildLock(s), nil
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	if token := os.Getenv("AUTH_TOKEN"); token != "" {
+		headers["Authorization"] = "Bearer " + token
+	}
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	result, err := introspection.CheckIntrospectionWithContext(ctx, cfg.BaseURL, headers)
+	if err != nil {
+		return nil, fmt.Errorf("error introspecting %s: %w", cfg.BaseURL, err)
+	}
+	if !introspection.IsIntrospectionEnabled(result) {
+		reason := "no schema in the response"
+		if messages := errorMessages(result); len(messages) > 0 {
+			reason = messages[0]
+		}
+		return nil, fmt.Errorf("introspection is disabled on %s (%s): the schema cannot be locked or verified without it; check the headers sent with -H or AUTH_TOKEN", cfg.BaseURL, reason)
+	}
+	s, err := schema.LoadFromIntrospection(result)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the schema of %s: %w", cfg.BaseURL, err)
+	}
+	return schema.BuildLock(s), nil
+}
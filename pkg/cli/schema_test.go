@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Introspection results of one schema as lockServer serves them.
+const (
+	lockUser  = `{"kind":"OBJECT","name":"User","fields":[{"name":"id","args":[],"type":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"ID"}}},{"name":"name","args":[],"type":{"kind":"SCALAR","name":"String"}}]}`
+	lockQuery = `{"kind":"OBJECT","name":"Query","fields":[{"name":"user","args":[{"name":"id","type":{"kind":"SCALAR","name":"ID"}}],"type":{"kind":"OBJECT","name":"User"}}]}`
+	lockTail  = `{"kind":"SCALAR","name":"ID"},{"kind":"SCALAR","name":"String"}]}}}`
+)
+
+var lockSchemas = map[string]string{
+	"locked": `{"data":{"__schema":{"queryType":{"name":"Query"},"types":[` + lockQuery + `,` + lockUser + `,` + lockTail,
+	// The same schema, listed in another order with descriptions.
+	"reordered": `{"data":{"__schema":{"queryType":{"name":"Query"},"types":[` + strings.Replace(lockUser, `"name":"User",`, `"name":"User","description":"A user",`, 1) + `,` + lockQuery + `,` + lockTail,
+	// User gains an email field.
+	"added":    `{"data":{"__schema":{"queryType":{"name":"Query"},"types":[` + lockQuery + `,` + strings.Replace(lockUser, `]}`, `,{"name":"email","args":[],"type":{"kind":"SCALAR","name":"String"}}]}`, 1) + `,` + lockTail,
+	"disabled": `{"errors":[{"message":"GraphQL introspection is not allowed"}]}`,
+}
+
+// lockServer serves the introspection result of lockSchemas its returned
+// function is set to last.
+func lockServer(t *testing.T) (*httptest.Server, func(variant string)) {
+	t.Helper()
+	var mu sync.Mutex
+	variant := "locked"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		body := lockSchemas[variant]
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func(v string) {
+		mu.Lock()
+		variant = v
+		mu.Unlock()
+	}
+}
+
+// runSchema runs the schema subcommand and returns its exit code and output.
+func runSchema(t *testing.T, cfg types.SchemaConfig) (int, string) {
+	t.Helper()
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	var code int
+	out := captureStdout(t, func() { code = Schema(&cfg) })
+	return code, out
+}
+
+func TestSchemaLockVerify(t *testing.T) {
+	srv, serve := lockServer(t)
+	dir := t.TempDir()
+	lock := filepath.Join(dir, "schema.lock")
+
+	code, out := runSchema(t, types.SchemaConfig{Args: []string{"lock"}, BaseURL: srv.URL, Out: lock})
+	if code != 0 || !strings.Contains(out, "written to "+lock+" (4 types, sha256:") {
+		t.Fatalf("schema lock exited with %d:\n%s", code, out)
+	}
+	locked, err := os.ReadFile(lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("no drift", func(t *testing.T) {
+		serve("reordered")
+		// The lock of the schema listed in another order is the same file.
+		relock := filepath.Join(dir, "reordered.lock")
+		if code, out := runSchema(t, types.SchemaConfig{Args: []string{"lock"}, BaseURL: srv.URL, Out: relock}); code != 0 {
+			t.Fatalf("schema lock exited with %d:\n%s", code, out)
+		}
+		if data, _ := os.ReadFile(relock); !bytes.Equal(data, locked) {
+			t.Errorf("the reordered schema locked as\n%s\nwant\n%s", data, locked)
+		}
+		code, out := runSchema(t, types.SchemaConfig{Args: []string{"verify"}, BaseURL: srv.URL, Lock: lock})
+		if code != 0 || !strings.Contains(out, "Schema of "+srv.URL+" matches "+lock) {
+			t.Errorf("schema verify exited with %d:\n%s", code, out)
+		}
+	})
+
+	t.Run("added field", func(t *testing.T) {
+		serve("added")
+		code, out := runSchema(t, types.SchemaConfig{Args: []string{"verify"}, BaseURL: srv.URL, Lock: lock})
+		if code != 1 || !strings.Contains(out, "drifted from "+lock+": 1 difference(s)") || !strings.Contains(out, "types.User.fields.email") {
+			t.Errorf("schema verify exited with %d:\n%s\nwant the email field as the only drift", code, out)
+		}
+	})
+
+	t.Run("introspection disabled", func(t *testing.T) {
+		serve("disabled")
+		cfg := types.SchemaConfig{Args: []string{"verify"}, BaseURL: srv.URL, Lock: lock, Timeout: 10 * time.Second}
+		if code, out := runSchema(t, cfg); code != 1 || strings.Contains(out, "matches") || strings.Contains(out, "drifted") {
+			t.Errorf("schema verify exited with %d:\n%s\nwant an error", code, out)
+		}
+		_, err := fetchLock(context.Background(), &cfg)
+		if err == nil || !strings.Contains(err.Error(), "introspection is disabled on "+srv.URL+" (GraphQL introspection is not allowed)") {
+			t.Errorf("fetchLock() = %v", err)
+		}
+		if code, _ := runSchema(t, types.SchemaConfig{Args: []string{"lock"}, BaseURL: srv.URL, Out: filepath.Join(dir, "disabled.lock")}); code != 1 {
+			t.Errorf("schema lock exited with %d, want 1", code)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "disabled.lock")); !os.IsNotExist(err) {
+			t.Errorf("a lock was written without introspection: %v", err)
+		}
+	})
+
+	// A lock edited by hand no longer matches its hash.
+	edited := filepath.Join(dir, "edited.lock")
+	if err := os.WriteFile(edited, bytes.Replace(locked, []byte(`"name": "name"`), []byte(`"name": "nickname"`), 1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schema.LoadLock(edited); err == nil || !strings.Contains(err.Error(), "edited by hand") {
+		t.Errorf("LoadLock() of an edited lock = %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// Marker aliases added to the two queries of a --duplicate-query request, so
+// the response shows which one the server executed.
+const (
+	BenignMarker = "gsBenign"
+	RealMarker   = "gsReal"
+)
+
+// Outcomes of a duplicate query
+const (
+	ExecutedBenign  = "benign"
+	ExecutedReal    = "real"
+	ExecutedBoth    = "both"
+	ExecutedNeither = "neither"
+)
+
+// DefaultDuplicatePosition is where the real query of --duplicate-query goes
+// when --param-name is not given.
+const DefaultDuplicatePosition = "url:query"
+
+// ParseDuplicateQuery splits a "benign=<query> real=<query>" specification.
+// Either part may come first; everything up to the other key belongs to a query.
+func ParseDuplicateQuery(spec string) (benign, real string, err error) {
+	b := strings.Index(spec, "benign=")
+	r := strings.Index(spec, "real=")
+	if b < 0 || r < 0 {
+		return "", "", fmt.Errorf("invalid --duplicate-query %q: expected benign=<query> real=<query>", spec)
+	}
+	if b < r {
+		benign, real = spec[b+len("benign="):r], spec[r+len("real="):]
+	} else {
+		real, benign = spec[r+len("real="):b], spec[b+len("benign="):]
+	}
+	benign, real = strings.TrimSpace(benign), strings.TrimSpace(real)
+	if benign == "" || real == "" {
+		return "", "", fmt.Errorf("invalid --duplicate-query %q: both queries are required", spec)
+	}
+	return benign, real, nil
+}
+
+// MarkQuery adds a `<marker>: __typename` field to the top-level selection set
+// of every operation in query.
+func MarkQuery(query, marker string) (string, error) {
+	doc, err := gql.Parse(query)
+	if err != nil {
+		return "", err
+	}
+	ops := append([]*gql.Operation(nil), doc.Operations...)
+	sort.Slice(ops, func(i, j int) bool { return ops[i].End > ops[j].End })
+	for _, op := range ops {
+		// End is just past the closing brace of the operation's selection set.
+		query = query[:op.End-1] + " " + marker + ": __typename " + query[op.End-1:]
+	}
+	return query, nil
+}
+
+// ExecutedQuery reports which of the marked queries produced resp.
+func ExecutedQuery(resp map[string]interface{}) string {
+	data, _ := resp["data"].(map[string]interface{})
+	_, benign := data[BenignMarker]
+	_, real := data[RealMarker]
+	switch {
+	case benign && real:
+		return ExecutedBoth
+	case benign:
+		return ExecutedBenign
+	case real:
+		return ExecutedReal
+	default:
+		return ExecutedNeither
+	}
+}
+
+// SendDuplicateQuery sends benign in the standard position and real in the
+// position described by s, both marked, and reports which one was executed.
+func SendDuplicateQuery(ctx context.Context, url string, s network.Strategy, benign, real string, variables map[string]interface{}, headers map[string]string) (map[string]interface{}, string, error) {
+	s, markedReal, err := duplicateStrategy(s, benign, real)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := network.SendWithStrategy(ctx, url, s, markedReal, variables, headers)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp, ExecutedQuery(resp), nil
+}
+
+// PreviewDuplicateQuery returns the request SendDuplicateQuery would send,
+// without sending it.
+func PreviewDuplicateQuery(ctx context.Context, url string, s network.Strategy, benign, real string, variables map[string]interface{}, headers map[string]string) (network.RequestPreview, error) {
+	s, markedReal, err := duplicateStrategy(s, benign, real)
+	if err != nil {
+		return network.RequestPreview{}, err
+	}
+	return network.PreviewWithStrategy(ctx, url, s, markedReal, variables, headers)
+}
+
+// duplicateStrategy marks benign and real, setting the former as the decoy of
+// s, and returns s and the marked real query.
+func duplicateStrategy(s network.Strategy, benign, real string) (network.Strategy, string, error) {
+	markedBenign, err := MarkQuery(benign, BenignMarker)
+	if err != nil {
+		return s, "", fmt.Errorf("error parsing benign query: %w", err)
+	}
+	markedReal, err := MarkQuery(real, RealMarker)
+	if err != nil {
+		return s, "", fmt.Errorf("error parsing real query: %w", err)
+	}
+	s.Decoy = markedBenign
+	return s, markedReal, nil
+}
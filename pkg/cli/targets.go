@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadTargets reads one target URL per line from filename. Blank lines and
+// lines starting with # are ignored.
+func LoadTargets(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening targets file: %w", err)
+	}
+	defer f.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading targets file: %w", err)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets in %s", filename)
+	}
+	return targets, nil
+}
+
+// LoadVirtualHosts reads one host name, optionally with a port, per line from
+// filename. Blank lines and lines starting with # are ignored, and so are
+// repeated names.
+func LoadVirtualHosts(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening virtual hosts file: %w", err)
+	}
+	defer f.Close()
+
+	var hosts []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Contains(line, "://") || strings.ContainsAny(line, "/ \t") {
+			return nil, fmt.Errorf("line %d of %s: %q is not a host name", n, filename, line)
+		}
+		host := strings.ToLower(line)
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading virtual hosts file: %w", err)
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no virtual hosts in %s", filename)
+	}
+	return hosts, nil
+}
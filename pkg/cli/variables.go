@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"encoding/json"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// FillVariables completes vars with placeholders for required variables declared
+// by the operation in document that were not supplied. Documents that fail to
+// parse are returned unchanged so the server can report the problem itself.
+func FillVariables(document string, vars map[string]interface{}, schemaObj *types.GQLSchema) map[string]interface{} {
+	doc, err := gql.Parse(document)
+	if err != nil {
+		logger.Debug("→ Not synthesizing variables: %v", err)
+		return vars
+	}
+
+	var defs []*gql.VariableDefinition
+	for _, op := range doc.Operations {
+		defs = append(defs, op.VariableDefinitions...)
+	}
+	if len(defs) == 0 {
+		return vars
+	}
+
+	merged, synthesized := schema.SynthesizeVariables(schemaObj, defs, vars)
+	for _, v := range synthesized {
+		value, _ := json.Marshal(v.Value)
+		logger.Warn("No value supplied for $%s (%s), using placeholder %s", v.Name, v.Type, value)
+	}
+	return merged
+}
+
+// PruneFragments drops the fragments of a single-operation document that the
+// operation does not use. Documents that fail to parse, hold several operations
+// or spread undefined fragments are returned unchanged.
+func PruneFragments(document string) string {
+	doc, err := gql.Parse(document)
+	if err != nil || len(doc.Operations) != 1 {
+		return document
+	}
+	used, _ := doc.FragmentsUsed(doc.Operations[0])
+	if len(used) == len(doc.Fragments) {
+		return document
+	}
+	pruned, err := doc.OperationDocument(doc.Operations[0], false)
+	if err != nil {
+		logger.Debug("→ Not pruning fragments: %v", err)
+		return document
+	}
+	logger.Debug("→ Dropped %d unused fragment(s)", len(doc.Fragments)-len(used))
+	return pruned
+}
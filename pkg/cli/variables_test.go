@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFillVariables(t *testing.T) {
+	supplied := map[string]interface{}{"id": "42"}
+	got := FillVariables(`query Q($id: ID!, $first: Int!, $after: String) { users(first: $first, after: $after) { id } }`, supplied, nil)
+	if want := map[string]interface{}{"id": "42", "first": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FillVariables() = %v, want %v", got, want)
+	}
+	if _, ok := supplied["first"]; ok {
+		t.Error("FillVariables modified the supplied variables")
+	}
+
+	// A document that does not parse is left for the server to reject.
+	if got := FillVariables(`query Q($id: ID!) { user(id: $id) {`, supplied, nil); !reflect.DeepEqual(got, supplied) {
+		t.Errorf("FillVariables() on an invalid document = %v, want the supplied variables", got)
+	}
+	if got := FillVariables(`{ users { id } }`, nil, nil); got != nil {
+		t.Errorf("FillVariables() without definitions = %v, want nil", got)
+	}
+}
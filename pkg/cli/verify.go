@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/manifest"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// verifyUsage is printed when the verify subcommand is missing an option.
+const verifyUsage = "Usage: graphspecter verify --manifest run.json --pub key.pub [--dir <run directory>]"
+
+// Verify checks the signed run manifest of cfg and every file artifact it
+// lists: the signature of the manifest, the SHA-256 it records for each file
+// and the detached signature of each. It prints one line per file and returns
+// the exit code: 1 when anything fails to verify, 2 on a usage error.
+func Verify(cfg *types.VerifyConfig) int {
+	if cfg.Manifest == "" || cfg.PublicKey == "" {
+		fmt.Fprintln(os.Stderr, verifyUsage)
+		return 2
+	}
+	public, err := artifacts.LoadPublicKey(cfg.PublicKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+	if err := artifacts.Verify(public, cfg.Manifest, "", cfg.Manifest+artifacts.SignatureExt); err != nil {
+		fmt.Fprintf(tw, "FAILED\tmanifest\t%s\t%v\n", cfg.Manifest, err)
+		return 1
+	}
+	fmt.Fprintf(tw, "OK\tmanifest\t%s\n", cfg.Manifest)
+	m, err := manifest.Load(cfg.Manifest)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(cfg.Dir, path)
+	}
+	verified, failed := 0, 0
+	for _, a := range m.Artifacts {
+		switch {
+		case a.SHA256 == "":
+			// Directories, and files gone when the run ended, are not hashed.
+			fmt.Fprintf(tw, "SKIPPED\t%s\t%s\tnot hashed\n", a.Kind, a.Path)
+			continue
+		case a.Signature == "":
+			err = fmt.Errorf("no signature recorded")
+		default:
+			err = artifacts.Verify(public, resolve(a.Path), a.SHA256, resolve(a.Signature))
+		}
+		if err != nil {
+			fmt.Fprintf(tw, "FAILED\t%s\t%s\t%v\n", a.Kind, a.Path, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(tw, "OK\t%s\t%s\n", a.Kind, a.Path)
+		verified++
+	}
+	tw.Flush()
+	if failed > 0 {
+		fmt.Printf("%d of %d artifact(s) failed verification\n", failed, failed+verified)
+		return 1
+	}
+	fmt.Printf("Verified the manifest and %d artifact(s)\n", verified)
+	return 0
+}
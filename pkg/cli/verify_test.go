@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/manifest"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// writePublicKey writes pub to path as a PEM "PUBLIC KEY" block.
+func writePublicKey(t *testing.T, path string, pub ed25519.PublicKey) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// signedRun writes a run manifest listing a report and an introspection
+// dump, all signed with a new key, and returns the verify config for it.
+func signedRun(t *testing.T) (*types.VerifyConfig, map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &types.VerifyConfig{Manifest: filepath.Join(dir, "run.json"), PublicKey: filepath.Join(dir, "key.pub"), Dir: dir}
+	writePublicKey(t, cfg.PublicKey, pub)
+
+	w, err := manifest.Start(cfg.Manifest, []string{"graphspecter", "--sign-key", "key.pem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SignWith(priv)
+	files := map[string]string{
+		"report": filepath.Join(dir, "report.json"),
+		"dump":   filepath.Join(dir, "introspection.json"),
+	}
+	for kind, path := range files {
+		if err := artifacts.WriteFile(path, []byte(`{"kind": "`+kind+`"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		w.Artifact(kind, path)
+	}
+	if err := w.Finish(0, false); err != nil {
+		t.Fatal(err)
+	}
+	return cfg, files
+}
+
+func TestVerifySignedRun(t *testing.T) {
+	cfg, files := signedRun(t)
+	if code := Verify(cfg); code != 0 {
+		t.Fatalf("Verify() = %d, want 0", code)
+	}
+	m, err := manifest.Load(cfg.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Artifacts) != len(files) {
+		t.Fatalf("manifest lists %d artifacts, want %d", len(m.Artifacts), len(files))
+	}
+	for _, a := range m.Artifacts {
+		if a.SHA256 == "" || a.Signature != a.Path+artifacts.SignatureExt {
+			t.Errorf("artifact %+v is not hashed and signed", a)
+		}
+	}
+}
+
+func TestVerifyTamperedRun(t *testing.T) {
+	cfg, files := signedRun(t)
+	if err := os.WriteFile(files["report"], []byte(`{"kind": "altered"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := Verify(cfg); code != 1 {
+		t.Errorf("Verify() of a tampered artifact = %d, want 1", code)
+	}
+
+	// Any change to the manifest breaks its own signature.
+	cfg, _ = signedRun(t)
+	data, err := os.ReadFile(cfg.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.Manifest, append(data, ' '), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := Verify(cfg); code != 1 {
+		t.Errorf("Verify() of a tampered manifest = %d, want 1", code)
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	cfg, _ := signedRun(t)
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePublicKey(t, cfg.PublicKey, other)
+	if code := Verify(cfg); code != 1 {
+		t.Errorf("Verify() with another key = %d, want 1", code)
+	}
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/fingerprint"
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// DetectVirtualHosts runs endpoint detection on every base URL under its own
+// host, then again at the same address under each host of vhosts, and
+// identifies the engine and schema of every endpoint found. Endpoints that the
+// base URL's own host does not serve, or serves with another engine or
+// schema, are reported as findings. Nothing is audited.
+func DetectVirtualHosts(ctx context.Context, bases, vhosts []string, headers map[string]string) (*report.Report, error) {
+	logger.Info("Virtual host mode enabled. Detecting GraphQL endpoints under %d host name(s)...", len(vhosts))
+	ctx, stop := network.StartModule(ctx, "vhosts")
+	defer stop()
+
+	rep := &report.Report{Metadata: report.NewMetadata()}
+	found := 0
+	for _, base := range bases {
+		u, err := url.Parse(base)
+		if err != nil {
+			return rep, fmt.Errorf("invalid base URL %s: %w", base, err)
+		}
+		baseline := detectVirtualHost(ctx, base, u.Host, "", headers)
+		known := make(map[string]types.VirtualHostEndpoint, len(baseline))
+		for _, e := range baseline {
+			known[e.Endpoint] = e
+			rep.Endpoints = append(rep.Endpoints, e.Endpoint)
+		}
+		rep.VirtualHosts = append(rep.VirtualHosts, baseline...)
+		found += len(baseline)
+
+		for _, vh := range vhosts {
+			if ctx.Err() != nil {
+				return rep, fmt.Errorf("virtual host detection stopped: %w", ctx.Err())
+			}
+			for _, e := range detectVirtualHost(ctx, base, vh, vh, headers) {
+				k, ok := known[e.Endpoint]
+				e.Differs = !ok || k.Engine != e.Engine || k.SchemaHash != e.SchemaHash
+				if e.Differs {
+					rep.Findings = append(rep.Findings, virtualHostFinding(e, k, ok, u.Host))
+				}
+				rep.VirtualHosts = append(rep.VirtualHosts, e)
+				found++
+			}
+		}
+	}
+	if found == 0 {
+		return rep, fmt.Errorf("no GraphQL endpoints detected under any host")
+	}
+	return rep, nil
+}
+
+// detectVirtualHost detects the endpoints of base sent with the Host header
+// vhost, or with the host of base when vhost is empty, and identifies them.
+// label names the host in the results.
+func detectVirtualHost(ctx context.Context, base, label, vhost string, headers map[string]string) []types.VirtualHostEndpoint {
+	if vhost != "" {
+		ctx = network.WithVirtualHost(ctx, vhost)
+		logger.Info("Detecting endpoints of %s with Host: %s", base, vhost)
+	}
+	result, err := network.DetectEndpoints(ctx, base, false, nil)
+	if err != nil {
+		logger.Info("WARNING: Detection on %s under %s: %v", base, label, err)
+	}
+	if result == nil {
+		return nil
+	}
+	var endpoints []types.VirtualHostEndpoint
+	for _, endpoint := range result.Endpoints {
+		e := types.VirtualHostEndpoint{Host: label, Endpoint: endpoint}
+		if matches, err := fingerprint.DetectEngineWithContext(ctx, endpoint, headers); err == nil && len(matches) > 0 {
+			e.Engine = matches[0].Engine
+		}
+		e.SchemaHash = schemaHash(ctx, endpoint, headers)
+		endpoints = append(endpoints, e)
+	}
+	return endpoints
+}
+
+// schemaHash returns the SchemaHash of the introspection result of endpoint,
+// or "" when introspection is disabled or fails.
+func schemaHash(ctx context.Context, endpoint string, headers map[string]string) string {
+	result, err := introspection.CheckIntrospectionWithContext(ctx, endpoint, headers)
+	if err != nil || !introspection.IsIntrospectionEnabled(result) {
+		return ""
+	}
+	return introspection.SchemaHash(result)
+}
+
+// virtualHostFinding reports e, served under a virtual host differently from
+// baseline, the endpoint the base host serves at the same URL if any.
+func virtualHostFinding(e, baseline types.VirtualHostEndpoint, served bool, baseHost string) report.Finding {
+	var diff []string
+	switch {
+	case !served:
+		diff = append(diff, fmt.Sprintf("%s does not serve GraphQL at this URL", baseHost))
+	default:
+		if baseline.Engine != e.Engine {
+			diff = append(diff, fmt.Sprintf("engine %s instead of %s", orUnknown(e.Engine), orUnknown(baseline.Engine)))
+		}
+		if baseline.SchemaHash != e.SchemaHash {
+			diff = append(diff, fmt.Sprintf("schema %s instead of %s", orUnknown(e.SchemaHash), orUnknown(baseline.SchemaHash)))
+		}
+	}
+	return report.Finding{
+		ID:          "virtual-host-graphql",
+		Check:       "vhosts",
+		Title:       "A virtual host serves a different GraphQL API",
+		Severity:    report.SeverityInfo,
+		Endpoint:    e.Endpoint,
+		Description: fmt.Sprintf("Under the host name %s the address answers GraphQL differently: %s.", e.Host, strings.Join(diff, ", ")),
+		Evidence:    fmt.Sprintf("Host: %s; engine %s; schema %s", e.Host, orUnknown(e.Engine), orUnknown(e.SchemaHash)),
+	}
+}
+
+// orUnknown returns s, or "unknown" when s is empty.
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// PrintVirtualHosts lists the endpoints detected under each host name.
+func PrintVirtualHosts(endpoints []types.VirtualHostEndpoint) {
+	if len(endpoints) == 0 {
+		return
+	}
+	logger.Info("GraphQL endpoints by host name:")
+	for _, e := range endpoints {
+		mark := ""
+		if e.Differs {
+			mark = " [differs]"
+		}
+		logger.Info("  %s %s (engine %s, schema %s)%s", e.Host, e.Endpoint, orUnknown(e.Engine), orUnknown(e.SchemaHash), mark)
+	}
+}
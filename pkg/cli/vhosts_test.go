@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+)
+
+// vhostSchema is the introspection result of a schema whose query type has
+// the single field name.
+func vhostSchema(name string) string {
+	return `{"data":{"__schema":{"queryType":{"name":"Query"},"types":[{"kind":"OBJECT","name":"Query","fields":[{"name":"` + name + `","args":[],"type":{"kind":"SCALAR","name":"String"}}]},{"kind":"SCALAR","name":"String"}]}}}`
+}
+
+// vhostServer serves a different API depending on the Host header, the way a
+// shared ingress does:
+//   - its own address and same.internal serve the public schema at /graphql;
+//   - admin.internal serves another schema at /graphql;
+//   - staff.internal serves the public schema at /graphql and also /internal/graphql;
+//   - any other host gets 404.
+//
+// It returns the server and the Host headers it received.
+func vhostServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "paths.json")
+	if err := os.WriteFile(file, []byte(`{"version":1,"entries":["/graphql","/internal/graphql"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := data.LoadFile("paths", file); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(data.Reset)
+
+	var mu sync.Mutex
+	hosts := make(map[string]bool)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts[r.Host] = true
+		mu.Unlock()
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		schema := ""
+		switch host := r.Host; {
+		case host == srv.Listener.Addr().String() || host == "same.internal":
+			if r.URL.Path == "/graphql" {
+				schema = vhostSchema("posts")
+			}
+		case host == "admin.internal":
+			if r.URL.Path == "/graphql" {
+				schema = vhostSchema("users")
+			}
+		case host == "staff.internal":
+			if r.URL.Path == "/graphql" || r.URL.Path == "/internal/graphql" {
+				schema = vhostSchema("posts")
+			}
+		}
+		if schema == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(req.Query, "__schema") {
+			w.Write([]byte(schema))
+			return
+		}
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		var out []string
+		for h := range hosts {
+			out = append(out, h)
+		}
+		sort.Strings(out)
+		return out
+	}
+}
+
+func TestDetectVirtualHosts(t *testing.T) {
+	srv, received := vhostServer(t)
+	own := srv.Listener.Addr().String()
+	vhosts := []string{"same.internal", "admin.internal", "staff.internal", "missing.internal"}
+	rep, err := DetectVirtualHosts(context.Background(), []string{srv.URL}, vhosts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantHosts := append([]string{own}, vhosts...)
+	sort.Strings(wantHosts)
+	if got := received(); strings.Join(got, ",") != strings.Join(wantHosts, ",") {
+		t.Errorf("the server received the hosts %q, want %q", got, wantHosts)
+	}
+	if len(rep.Endpoints) != 1 || rep.Endpoints[0] != srv.URL+"/graphql" {
+		t.Errorf("Endpoints = %q, want only the endpoint of the own host", rep.Endpoints)
+	}
+
+	type entry struct {
+		host, endpoint string
+		differs        bool
+	}
+	var got []entry
+	hashes := make(map[string]string)
+	for _, e := range rep.VirtualHosts {
+		got = append(got, entry{e.Host, strings.TrimPrefix(e.Endpoint, srv.URL), e.Differs})
+		if e.SchemaHash == "" {
+			t.Errorf("%s %s has no schema hash", e.Host, e.Endpoint)
+		}
+		hashes[e.Host+e.Endpoint] = e.SchemaHash
+	}
+	want := []entry{
+		{own, "/graphql", false},
+		{"same.internal", "/graphql", false},
+		{"admin.internal", "/graphql", true},
+		{"staff.internal", "/graphql", false},
+		{"staff.internal", "/internal/graphql", true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("VirtualHosts = %+v\nwant %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("VirtualHosts[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if hashes["admin.internal"+srv.URL+"/graphql"] == hashes[own+srv.URL+"/graphql"] {
+		t.Error("admin.internal has the schema hash of the own host")
+	}
+
+	if len(rep.Findings) != 2 {
+		t.Fatalf("%d findings, want admin.internal and staff.internal: %+v", len(rep.Findings), rep.Findings)
+	}
+	admin, staff := rep.Findings[0], rep.Findings[1]
+	for _, f := range rep.Findings {
+		if f.ID != "virtual-host-graphql" || f.Check != "vhosts" {
+			t.Errorf("finding %s from %s", f.ID, f.Check)
+		}
+	}
+	if admin.Endpoint != srv.URL+"/graphql" || !strings.Contains(admin.Description, "admin.internal") || !strings.Contains(admin.Description, "schema ") || strings.Contains(admin.Description, "engine ") {
+		t.Errorf("admin finding = %+v, want the schema difference only", admin)
+	}
+	if staff.Endpoint != srv.URL+"/internal/graphql" || !strings.Contains(staff.Description, own+" does not serve GraphQL at this URL") {
+		t.Errorf("staff finding = %+v, want the endpoint the own host lacks", staff)
+	}
+}
+
+func TestDetectVirtualHostsNothingFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	if _, err := DetectVirtualHosts(context.Background(), []string{srv.URL}, []string{"admin.internal"}, nil); err == nil || !strings.Contains(err.Error(), "no GraphQL endpoints detected under any host") {
+		t.Errorf("DetectVirtualHosts() = %v", err)
+	}
+}
+
+func TestLoadVirtualHosts(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	hosts, err := LoadVirtualHosts(write("hosts.txt", "# ingress names\nAdmin.Internal\n\nstaff.internal:8443\nadmin.internal\n"))
+	if err != nil || strings.Join(hosts, ",") != "admin.internal,staff.internal:8443" {
+		t.Errorf("LoadVirtualHosts() = %q, %v", hosts, err)
+	}
+	for content, want := range map[string]string{
+		"https://admin.internal\n": "line 1",
+		"a\nadmin internal\n":      "line 2",
+		"# none\n":                 "no virtual hosts",
+	} {
+		if _, err := LoadVirtualHosts(write("bad.txt", content)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadVirtualHosts(%q) = %v, want %q", content, err, want)
+		}
+	}
+}
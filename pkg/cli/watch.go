@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+)
+
+// Watch event kinds
+const (
+	// WatchIteration ends every iteration of a watch run.
+	WatchIteration = "iteration"
+	// WatchNewFinding is a finding the previous iteration did not report.
+	WatchNewFinding = "new-finding"
+	// WatchResolvedFinding is a finding of the previous iteration no longer reported.
+	WatchResolvedFinding = "resolved-finding"
+)
+
+// webhookTimeout bounds the delivery of a webhook alert.
+const webhookTimeout = 10 * time.Second
+
+// WatchEvent is a line of the NDJSON event stream of a watch run.
+type WatchEvent struct {
+	Event     string          `json:"event"`
+	Time      time.Time       `json:"time"`
+	Iteration int             `json:"iteration"`
+	Finding   *report.Finding `json:"finding,omitempty"`
+	// Findings, New and Resolved count the findings of an iteration event.
+	Findings int `json:"findings,omitempty"`
+	New      int `json:"new,omitempty"`
+	Resolved int `json:"resolved,omitempty"`
+	// Error is set on an iteration that failed or whose alert was not delivered.
+	Error string `json:"error,omitempty"`
+}
+
+// WatchAlert is the body POSTed to the webhook of a watch run when an
+// iteration reports new findings.
+type WatchAlert struct {
+	Tool        string           `json:"tool"`
+	Version     string           `json:"version"`
+	Iteration   int              `json:"iteration"`
+	Time        time.Time        `json:"time"`
+	Endpoints   []string         `json:"endpoints"`
+	NewFindings []report.Finding `json:"newFindings"`
+}
+
+// WriteWatchEvent writes event to w as a single JSON line.
+func WriteWatchEvent(w io.Writer, event WatchEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshalling watch event: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// PostWatchAlert POSTs alert as JSON to url. The webhook is not a scan target,
+// so it is sent with a client of its own, outside the scope, rate limit and
+// signing of the scan traffic.
+func PostWatchAlert(ctx context.Context, url string, alert WatchAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("error marshalling webhook alert: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending webhook alert: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
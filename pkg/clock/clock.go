@@ -0,0 +1,40 @@
+// Package clock is the source of the timestamps written to artifacts, which
+// are RFC 3339 in UTC whatever the time zone of the machine
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Layout is RFC 3339 with milliseconds, the format of the timestamps of text
+// artifacts such as log files.
+const Layout = "2006-01-02T15:04:05.000Z07:00"
+
+var (
+	mu  sync.RWMutex
+	now = time.Now
+)
+
+// Now returns the current time in UTC.
+func Now() time.Time {
+	mu.RLock()
+	defer mu.RUnlock()
+	return now().UTC()
+}
+
+// Set replaces the source of Now, so that artifacts can be reproduced with a
+// fixed time. A nil f restores the system clock.
+func Set(f func() time.Time) {
+	if f == nil {
+		f = time.Now
+	}
+	mu.Lock()
+	now = f
+	mu.Unlock()
+}
+
+// Format returns t in UTC in Layout.
+func Format(t time.Time) string {
+	return t.UTC().Format(Layout)
+}
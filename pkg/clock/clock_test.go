@@ -0,0 +1,30 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNowAndFormatAreUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("EST", -5*3600)
+	t.Cleanup(func() { time.Local = local })
+
+	fixed := time.Date(2024, 5, 1, 7, 30, 0, 250e6, time.Local)
+	Set(func() time.Time { return fixed })
+	t.Cleanup(func() { Set(nil) })
+	if now := Now(); now.Location() != time.UTC || !now.Equal(fixed) {
+		t.Errorf("Now() = %v, want %v in UTC", now, fixed)
+	}
+	if got := Format(fixed); got != "2024-05-01T12:30:00.250Z" {
+		t.Errorf("Format() = %s", got)
+	}
+	if parsed, err := time.Parse(time.RFC3339, Format(fixed)); err != nil || !parsed.Equal(fixed) {
+		t.Errorf("Format() does not parse back as RFC 3339: %v, %v", parsed, err)
+	}
+
+	Set(nil)
+	if now := Now(); now.Location() != time.UTC || time.Since(now) > time.Minute || time.Since(now) < 0 {
+		t.Errorf("Now() = %v after Set(nil), want the system clock in UTC", now)
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+)
+
+// RedactedArgs returns the command-line arguments args, as parsed by
+// ParseFlags, with secrets masked: the values of flags named like credentials
+// such as --client-key-password, the values of -H headers that carry
+// credentials and the credentials embedded in URLs.
+func RedactedArgs(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") || arg == "-" {
+			// Flag parsing stops at the first positional argument.
+			for _, rest := range args[i:] {
+				out = append(out, redact.URL(rest))
+			}
+			break
+		}
+		dashes := "-"
+		if strings.HasPrefix(arg, "--") {
+			dashes = "--"
+		}
+		name, value, inline := strings.Cut(strings.TrimPrefix(arg, dashes), "=")
+		f := flag.Lookup(name)
+		switch {
+		case f == nil:
+			out = append(out, redact.URL(arg))
+		case inline:
+			out = append(out, dashes+name+"="+redactFlagValue(name, value))
+		case isBoolFlag(f) || i+1 == len(args):
+			out = append(out, arg)
+		default:
+			i++
+			out = append(out, arg, redactFlagValue(name, args[i]))
+		}
+	}
+	return out
+}
+
+// redactFlagValue masks value, given to the flag name, when it is a secret.
+func redactFlagValue(name, value string) string {
+	if name == "H" {
+		if header, _, ok := strings.Cut(value, ":"); ok && redact.IsSensitiveHeader(strings.TrimSpace(header)) {
+			return header + ": " + redact.Mask
+		}
+		return redact.URL(value)
+	}
+	if redact.IsSensitiveHeader(name) {
+		return redact.Mask
+	}
+	return redact.URL(value)
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
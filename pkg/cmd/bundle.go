@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ParseBundleFlags parses the arguments of the bundle subcommand. Flags may
+// come before or after the action and its arguments.
+func ParseBundleFlags(args []string) *types.BundleConfig {
+	cfg := &types.BundleConfig{}
+	fs := bundleFlags(cfg)
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		cfg.Args = append(cfg.Args, args[0])
+		args = args[1:]
+	}
+	return cfg
+}
+
+// bundleFlags returns the flag set of the bundle subcommand, bound to cfg.
+func bundleFlags(cfg *types.BundleConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	fs.StringVar(&cfg.Workspace, "workspace", "", "Directory bundled, or restored into by extract")
+	fs.StringVar(&cfg.Out, "out", "", "Compressed tarball the workspace is bundled into (.tar.gz)")
+	fs.BoolVar(&cfg.NoRedact, "no-redact", false, "Bundle the files as they are instead of masking sensitive values")
+	fs.Var((*headerFlag)(&cfg.Headers), "H", "Header \"Name: value\" (repeatable) whose credential is masked wherever it appears in the bundle")
+	return fs
+}
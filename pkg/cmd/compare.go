@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"flag"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/compare"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ParseCompareFlags parses the arguments of the compare subcommand.
+func ParseCompareFlags(args []string) *types.CompareConfig {
+	cfg := &types.CompareConfig{}
+	compareFlags(cfg).Parse(args)
+	return cfg
+}
+
+// compareFlags returns the flag set of the compare subcommand, bound to cfg.
+func compareFlags(cfg *types.CompareConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.StringVar(&cfg.BaseA, "base-a", "", "GraphQL endpoint A")
+	fs.StringVar(&cfg.BaseB, "base-b", "", "GraphQL endpoint B")
+	fs.StringVar(&cfg.SchemaFile, "schema-file", "", "File with the GraphQL schema (introspection JSON) whose operations are compared")
+	fs.StringVar(&cfg.Format, "format", "text", "Output format (valid: 'text', 'json')")
+	fs.BoolVar(&cfg.Mutations, "mutations", false, "Also execute mutations on both endpoints")
+	fs.Float64Var(&cfg.LatencyFactor, "latency-factor", compare.DefaultLatencyFactor, "Report operations this many times slower on one endpoint")
+	fs.DurationVar(&cfg.LatencyMinimum, "latency-min", compare.DefaultLatencyMinimum, "Smallest latency difference reported")
+	fs.BoolVar(&cfg.Data, "data", false, "Also compare the data of the operations both endpoints answer")
+	fs.StringVar(&cfg.Ignore, "ignore", "", "Comma-separated data paths left out of --data, with * matching a segment and ** any number (e.g. data.*.updatedAt)")
+	fs.StringVar(&cfg.Unordered, "unordered", "", "Comma-separated paths of arrays compared by --data regardless of order")
+	fs.DurationVar(&cfg.Timeout, "timeout", 5*time.Minute, "Timeout of the whole comparison")
+	fs.IntVar(&cfg.MaxDepth, "max-depth", 10, "Maximum depth for selection sets")
+	fs.StringVar(&cfg.LogLevel, "log-level", "", "Log level (debug, info, warn, error)")
+	fs.StringVar(&cfg.LogFile, "log-file", "", "Log to file in addition to stdout")
+	fs.BoolVar(&cfg.LogUTC, "log-utc", false, "Print terminal log timestamps in UTC instead of local time; log files always use UTC")
+	fs.BoolVar(&cfg.NoColor, "no-color", false, "Disable colored output (also off with NO_COLOR set or when stdout is not a terminal)")
+	return fs
+}
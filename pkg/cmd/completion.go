@@ -0,0 +1,411 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/report/kb"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// CompletionShells are the shells the completion subcommand writes scripts for.
+var CompletionShells = []string{"bash", "zsh", "fish"}
+
+// validValues matches the "(valid: 'a', 'b')" list of a flag usage.
+var validValues = regexp.MustCompile(`\(valid: ([^)]*)\)`)
+
+// quotedValue matches a value of a validValues list.
+var quotedValue = regexp.MustCompile(`'([^']*)'`)
+
+// pathFlags are the flags outside the -file and -dir naming scheme that take a
+// file path.
+var pathFlags = map[string]bool{
+	"output":           true,
+	"catalog-out":      true,
+	"sdl-out":          true,
+	"targets":          true,
+	"config":           true,
+	"report":           true,
+	"report-template":  true,
+	"observe-schema":   true,
+	"recover-schema":   true,
+	"wordlist":         true,
+	"arg-wordlist":     true,
+	"resume-inference": true,
+	"client-cert":      true,
+	"client-key":       true,
+	"vulndb":           true,
+	"error-patterns":   true,
+	"run-manifest":     true,
+	"profile-bounty":   true,
+	"history":          true,
+	"watch-state":      true,
+	"file":             true,
+	"out":              true,
+	"custom-checks":    true,
+}
+
+// dirFlags are the flags outside the -dir naming scheme that take a directory.
+var dirFlags = map[string]bool{
+	"vars-schema-out": true,
+	"workspace":       true,
+}
+
+// completionCommand is a subcommand, or the run without one when name is
+// empty, as the completion scripts describe it.
+type completionCommand struct {
+	name        string
+	description string
+	flags       *flag.FlagSet
+	// args are the words completed as positional arguments; files completes
+	// file paths instead.
+	args  []string
+	files bool
+}
+
+// completionCommands returns the commands with flag sets defined by the same
+// functions that parse them, so the scripts cannot drift from the flags.
+func completionCommands() []completionCommand {
+	root := flag.NewFlagSet("graphspecter", flag.ContinueOnError)
+	defineFlags(root, &types.CLIConfig{})
+	return []completionCommand{
+		{flags: root},
+		{name: "lint", description: "Check GraphQL documents against query limits", flags: lintFlags(&types.LintConfig{}), files: true},
+		{name: "hash", description: "Print the canonical hash of GraphQL documents", flags: hashFlags(&types.HashConfig{}), files: true},
+		{name: "har", description: "Import the GraphQL operations of HAR archives", flags: harFlags(&types.HARConfig{}), files: true},
+		{name: "server", description: "Run the scan API", flags: serverFlags(&types.ServerConfig{})},
+		{name: "compare", description: "Compare the responses of two endpoints", flags: compareFlags(&types.CompareConfig{})},
+		{name: "data", description: "List or show the embedded datasets", flags: dataFlags(&types.DataConfig{}), args: []string{"list", "show"}},
+		{name: "schema", description: "Lock the schema of an endpoint, or verify it against a lock", flags: schemaFlags(&types.SchemaConfig{}), args: []string{"lock", "verify"}},
+		{name: "history", description: "List, show or replay the requests of a history log", flags: historyFlags(&types.HistoryConfig{}), args: []string{"list", "show", "replay"}},
+		{name: "bundle", description: "Package a workspace into a bundle, or extract one", flags: bundleFlags(&types.BundleConfig{}), args: []string{"extract"}},
+		{name: "explain", description: "Explain a finding and how to remediate it", flags: explainFlags(&types.ExplainConfig{}), args: explainIDs()},
+		{name: "verify", description: "Verify the signatures of a run manifest and its artifacts", flags: verifyFlags(&types.VerifyConfig{})},
+		{name: "repro", description: "Write a standalone Go program reproducing a finding of a report", flags: reproFlags(&types.ReproConfig{})},
+		{name: "completion", description: "Print a shell completion script", flags: flag.NewFlagSet("completion", flag.ContinueOnError), args: CompletionShells},
+	}
+}
+
+// explainIDs are the finding ids completed after explain. Prefix entries such
+// as schema-secret-* are left out since they are not ids themselves.
+func explainIDs() []string {
+	var ids []string
+	for _, id := range kb.IDs() {
+		if !strings.HasSuffix(id, "*") {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// completionFlag is a flag and how its value is completed.
+type completionFlag struct {
+	name  string
+	usage string
+	// boolean flags take no value.
+	boolean bool
+	values  []string
+	// list values are comma-separated.
+	list       bool
+	file       bool
+	dir        bool
+	repeatable bool
+}
+
+// completionFlags describes the flags of fs, sorted by name.
+func completionFlags(fs *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		c := completionFlag{name: f.Name, usage: f.Usage, boolean: isBoolFlag(f), repeatable: strings.Contains(f.Usage, "(repeatable)")}
+		if !c.boolean {
+			c.values, c.list = flagValues(f)
+			c.dir = f.Name == "dir" || strings.HasSuffix(f.Name, "-dir") || dirFlags[f.Name]
+			c.file = !c.dir && (strings.HasSuffix(f.Name, "-file") || pathFlags[f.Name])
+		}
+		flags = append(flags, c)
+	})
+	return flags
+}
+
+// flagValues returns the values f accepts, read from the "(valid: ...)" list
+// of its usage or from the registries behind it, and whether several can be
+// given separated by commas.
+func flagValues(f *flag.Flag) ([]string, bool) {
+	switch f.Name {
+	case "log-level":
+		return []string{"debug", "info", "warn", "error", "fatal"}, false
+	case "concurrency":
+		return []string{"auto"}, false
+	case "checks", "skip-checks":
+		var ids []string
+		for _, c := range checks.All() {
+			ids = append(ids, c.ID())
+		}
+		return ids, true
+	case "stop-on-finding":
+		return []string{report.SeverityInfo, report.SeverityLow, report.SeverityMedium, report.SeverityHigh, report.SeverityCritical}, false
+	case "report-template":
+		return []string{report.TemplateExecutive, report.TemplateTechnical}, false
+	}
+	m := validValues.FindStringSubmatch(f.Usage)
+	if m == nil {
+		return nil, false
+	}
+	var values []string
+	for _, v := range quotedValue.FindAllStringSubmatch(m[1], -1) {
+		values = append(values, v[1])
+	}
+	return values, false
+}
+
+// dashed returns the option name of flag as typed: one dash for single
+// letters, two otherwise.
+func dashed(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// CompletionScript returns the completion script of shell, one of CompletionShells.
+func CompletionScript(shell string) (string, error) {
+	commands := completionCommands()
+	switch shell {
+	case "bash":
+		return bashCompletion(commands), nil
+	case "zsh":
+		return zshCompletion(commands), nil
+	case "fish":
+		return fishCompletion(commands), nil
+	default:
+		return "", fmt.Errorf("unknown shell %q (valid: %s)", shell, strings.Join(CompletionShells, ", "))
+	}
+}
+
+// functionName returns the shell function completing cmd.
+func functionName(cmd completionCommand) string {
+	if cmd.name == "" {
+		return "_graphspecter_root"
+	}
+	return "_graphspecter_" + cmd.name
+}
+
+// subcommandNames returns the names of the subcommands of commands.
+func subcommandNames(commands []completionCommand) []string {
+	var names []string
+	for _, cmd := range commands {
+		if cmd.name != "" {
+			names = append(names, cmd.name)
+		}
+	}
+	return names
+}
+
+func bashCompletion(commands []completionCommand) string {
+	var b strings.Builder
+	b.WriteString(`# bash completion for graphspecter, generated by "graphspecter completion bash".
+# Source it from ~/.bashrc or save it in the bash-completion directory.
+
+# _graphspecter_list completes the last item of a comma-separated list.
+_graphspecter_list() {
+    local prefix=""
+    if [[ "$cur" == *,* ]]; then
+        prefix="${cur%,*},"
+    fi
+    COMPREPLY=($(compgen -P "$prefix" -W "$1" -- "${cur##*,}"))
+}
+
+`)
+	for _, cmd := range commands {
+		flags := completionFlags(cmd.flags)
+		fmt.Fprintf(&b, "%s() {\n", functionName(cmd))
+		b.WriteString("    case \"$prev\" in\n")
+		var plain []string
+		for _, f := range flags {
+			if f.boolean {
+				continue
+			}
+			pattern := "-" + f.name + "|--" + f.name
+			switch {
+			case len(f.values) > 0 && f.list:
+				fmt.Fprintf(&b, "        %s) _graphspecter_list %q; return ;;\n", pattern, strings.Join(f.values, " "))
+			case len(f.values) > 0 && f.file:
+				fmt.Fprintf(&b, "        %s) compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -W %q -- \"$cur\") $(compgen -f -- \"$cur\")); return ;;\n", pattern, strings.Join(f.values, " "))
+			case len(f.values) > 0:
+				fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", pattern, strings.Join(f.values, " "))
+			case f.dir:
+				fmt.Fprintf(&b, "        %s) compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -d -- \"$cur\")); return ;;\n", pattern)
+			case f.file:
+				fmt.Fprintf(&b, "        %s) compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", pattern)
+			default:
+				plain = append(plain, pattern)
+			}
+		}
+		if len(plain) > 0 {
+			fmt.Fprintf(&b, "        %s) return ;;\n", strings.Join(plain, "|"))
+		}
+		b.WriteString("    esac\n")
+
+		var names []string
+		for _, f := range flags {
+			names = append(names, dashed(f.name))
+		}
+		b.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+		fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+		b.WriteString("        return\n    fi\n")
+		switch {
+		case cmd.name == "":
+			fmt.Fprintf(&b, "    if [[ $COMP_CWORD -eq 1 ]]; then\n        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n    fi\n", strings.Join(subcommandNames(commands), " "))
+		case cmd.files:
+			b.WriteString("    compopt -o filenames 2>/dev/null\n    COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+		case len(cmd.args) > 0:
+			fmt.Fprintf(&b, "    COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(cmd.args, " "))
+		}
+		b.WriteString("}\n\n")
+	}
+
+	b.WriteString(`_graphspecter() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local prev="${COMP_WORDS[COMP_CWORD-1]}"
+    COMPREPLY=()
+    if [[ $COMP_CWORD -gt 1 ]]; then
+        case "${COMP_WORDS[1]}" in
+`)
+	for _, name := range subcommandNames(commands) {
+		fmt.Fprintf(&b, "            %s) _graphspecter_%s; return ;;\n", name, name)
+	}
+	b.WriteString(`        esac
+    fi
+    _graphspecter_root
+}
+
+complete -F _graphspecter graphspecter
+`)
+	return b.String()
+}
+
+// zshEscape escapes s for a single-quoted _arguments spec.
+func zshEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+}
+
+func zshCompletion(commands []completionCommand) string {
+	var b strings.Builder
+	b.WriteString(`#compdef graphspecter
+# zsh completion for graphspecter, generated by "graphspecter completion zsh".
+# Save it as _graphspecter in a directory of $fpath.
+
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "%s() {\n    _arguments", functionName(cmd))
+		for _, f := range completionFlags(cmd.flags) {
+			spec := dashed(f.name) + "[" + zshEscape(f.usage) + "]"
+			if f.repeatable {
+				spec = "*" + spec
+			}
+			switch {
+			case f.boolean:
+			case len(f.values) > 0 && f.list:
+				spec += ":" + f.name + ":_values -s , " + f.name + " " + strings.Join(f.values, " ")
+			case len(f.values) > 0 && f.file:
+				action := "_alternative 'values:" + f.name + ":(" + strings.Join(f.values, " ") + ")' 'files:file:_files'"
+				spec += ":" + f.name + ":" + strings.ReplaceAll(action, "'", `'\''`)
+			case len(f.values) > 0:
+				spec += ":" + f.name + ":(" + strings.Join(f.values, " ") + ")"
+			case f.dir:
+				spec += ":directory:_files -/"
+			case f.file:
+				spec += ":file:_files"
+			default:
+				spec += ":value: "
+			}
+			fmt.Fprintf(&b, " \\\n        '%s'", spec)
+		}
+		switch {
+		case cmd.files:
+			b.WriteString(" \\\n        '*:file:_files'")
+		case len(cmd.args) > 0:
+			fmt.Fprintf(&b, " \\\n        '1:argument:(%s)'", strings.Join(cmd.args, " "))
+		}
+		b.WriteString("\n}\n\n")
+	}
+
+	b.WriteString("_graphspecter() {\n    local -a commands\n    commands=(\n")
+	for _, cmd := range commands {
+		if cmd.name != "" {
+			fmt.Fprintf(&b, "        '%s:%s'\n", cmd.name, zshEscape(cmd.description))
+		}
+	}
+	b.WriteString("    )\n    if (( CURRENT > 2 )); then\n        case $words[2] in\n")
+	for _, name := range subcommandNames(commands) {
+		fmt.Fprintf(&b, "            %s) shift words; (( CURRENT-- )); _graphspecter_%s; return ;;\n", name, name)
+	}
+	b.WriteString(`        esac
+    fi
+    if (( CURRENT == 2 )) && [[ $PREFIX != -* ]]; then
+        _describe -t commands 'graphspecter command' commands
+        return
+    fi
+    _graphspecter_root
+}
+
+_graphspecter "$@"
+`)
+	return b.String()
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func fishCompletion(commands []completionCommand) string {
+	var b strings.Builder
+	names := strings.Join(subcommandNames(commands), " ")
+	fmt.Fprintf(&b, `# fish completion for graphspecter, generated by "graphspecter completion fish".
+# Save it as ~/.config/fish/completions/graphspecter.fish.
+
+complete -c graphspecter -f
+`)
+	for _, cmd := range commands {
+		condition := fmt.Sprintf("'not __fish_seen_subcommand_from %s'", names)
+		if cmd.name != "" {
+			condition = fmt.Sprintf("'__fish_seen_subcommand_from %s'", cmd.name)
+			fmt.Fprintf(&b, "complete -c graphspecter -n 'not __fish_seen_subcommand_from %s' -a %s -d %s\n", names, cmd.name, fishQuote(cmd.description))
+		}
+		for _, f := range completionFlags(cmd.flags) {
+			option := "-l " + f.name
+			if len(f.name) == 1 {
+				option = "-s " + f.name
+			}
+			line := fmt.Sprintf("complete -c graphspecter -n %s %s -d %s", condition, option, fishQuote(f.usage))
+			switch {
+			case f.boolean:
+			case len(f.values) > 0 && f.list:
+				line += " -x -a " + fishQuote(fmt.Sprintf("(__fish_complete_list , 'printf \"%%s\\n\" %s')", strings.Join(f.values, " ")))
+			case len(f.values) > 0 && f.file:
+				line += " -r -F -a " + fishQuote(strings.Join(f.values, " "))
+			case len(f.values) > 0:
+				line += " -x -a " + fishQuote(strings.Join(f.values, " "))
+			case f.dir:
+				line += " -x -a '(__fish_complete_directories)'"
+			case f.file:
+				line += " -r -F"
+			default:
+				line += " -x"
+			}
+			b.WriteString(line + "\n")
+		}
+		switch {
+		case cmd.files:
+			fmt.Fprintf(&b, "complete -c graphspecter -n %s -F\n", condition)
+		case len(cmd.args) > 0:
+			fmt.Fprintf(&b, "complete -c graphspecter -n %s -a %s\n", condition, fishQuote(strings.Join(cmd.args, " ")))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"flag"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// bashWords returns the words the bash function name completes after pattern,
+// such as `if [[ "$cur" == -* ]]; then` for the option names.
+func bashWords(t *testing.T, script, name, pattern string) []string {
+	t.Helper()
+	start := strings.Index(script, "\n"+name+"() {\n")
+	if start < 0 {
+		t.Fatalf("the script has no %s function", name)
+	}
+	body := script[start+1:]
+	body = body[:strings.Index(body, "\n}\n")]
+	m := regexp.MustCompile(regexp.QuoteMeta(pattern) + `[^"]*"([^"]*)"`).FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("%s completes nothing after %s", name, pattern)
+	}
+	return strings.Fields(m[1])
+}
+
+// TestBashCompletionCoversFlags checks that the bash script completes every
+// flag of the run and of each subcommand, and every registered check.
+func TestBashCompletionCoversFlags(t *testing.T) {
+	script, err := CompletionScript("bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sets := make(map[string]*flag.FlagSet)
+	for _, cmd := range completionCommands() {
+		sets[functionName(cmd)] = cmd.flags
+	}
+	// The run flags come from the parser rather than the completion commands.
+	root := flag.NewFlagSet("graphspecter", flag.ContinueOnError)
+	defineFlags(root, &types.CLIConfig{})
+	sets["_graphspecter_root"] = root
+	for name, fs := range sets {
+		var words []string
+		fs.VisitAll(func(f *flag.Flag) {
+			if words == nil {
+				words = bashWords(t, script, name, `if [[ "$cur" == -* ]]; then`)
+			}
+			if !contains(words, dashed(f.Name)) {
+				t.Errorf("%s does not complete %s", name, dashed(f.Name))
+			}
+		})
+	}
+
+	for _, option := range []string{"checks", "skip-checks"} {
+		ids := make(map[string]bool)
+		for _, id := range bashWords(t, script, "_graphspecter_root", "-"+option+"|--"+option+") _graphspecter_list") {
+			ids[id] = true
+		}
+		for _, c := range checks.All() {
+			if !ids[c.ID()] {
+				t.Errorf("--%s does not complete the %s check", option, c.ID())
+			}
+		}
+		if len(ids) != len(checks.All()) {
+			t.Errorf("--%s completes %d checks, want the %d registered", option, len(ids), len(checks.All()))
+		}
+	}
+
+	subcommands := bashWords(t, script, "_graphspecter_root", "if [[ $COMP_CWORD -eq 1 ]]; then")
+	for _, cmd := range completionCommands() {
+		if cmd.name == "" {
+			continue
+		}
+		if !contains(subcommands, cmd.name) || !strings.Contains(script, "            "+cmd.name+") _graphspecter_"+cmd.name+"; return ;;") {
+			t.Errorf("the script does not complete or dispatch the %s subcommand", cmd.name)
+		}
+	}
+
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Log("bash not found; the script syntax is not checked")
+		return
+	}
+	check := exec.Command(bash, "-n")
+	check.Stdin = strings.NewReader(script)
+	if out, err := check.CombinedOutput(); err != nil {
+		t.Errorf("bash -n: %v\n%s", err, out)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompletionScriptShells(t *testing.T) {
+	for _, shell := range CompletionShells {
+		script, err := CompletionScript(shell)
+		if err != nil || !strings.Contains(script, "graphspecter completion "+shell) || !strings.Contains(script, "run-manifest") {
+			t.Errorf("CompletionScript(%s) = %d bytes, %v", shell, len(script), err)
+		}
+	}
+	if _, err := CompletionScript("powershell"); err == nil || !strings.Contains(err.Error(), "valid: bash, zsh, fish") {
+		t.Errorf("CompletionScript(powershell) = %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ParseDataFlags parses the arguments of the data subcommand. Flags may come
+// before or after the action and its arguments.
+func ParseDataFlags(args []string) *types.DataConfig {
+	cfg := &types.DataConfig{}
+	fs := dataFlags(cfg)
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		cfg.Args = append(cfg.Args, args[0])
+		args = args[1:]
+	}
+	return cfg
+}
+
+// dataFlags returns the flag set of the data subcommand, bound to cfg.
+func dataFlags(cfg *types.DataConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("data", flag.ExitOnError)
+	fs.StringVar(&cfg.DataDir, "data-dir", "", "Directory of dataset overrides applied before showing the data")
+	return fs
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ParseExplainFlags parses the arguments of the explain subcommand. Flags may
+// come before or after the finding id.
+func ParseExplainFlags(args []string) *types.ExplainConfig {
+	cfg := &types.ExplainConfig{}
+	fs := explainFlags(cfg)
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		cfg.Args = append(cfg.Args, args[0])
+		args = args[1:]
+	}
+	return cfg
+}
+
+// explainFlags returns the flag set of the explain subcommand, bound to cfg.
+func explainFlags(cfg *types.ExplainConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	fs.StringVar(&cfg.Engine, "engine", "", "Show only the remediation steps of this engine, as named by the engine fingerprint")
+	fs.BoolVar(&cfg.List, "list", false, "List the finding ids with an explanation")
+	return fs
+}
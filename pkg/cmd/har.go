@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
+)
+
+// ParseHARFlags parses the arguments of the har subcommand. Flags may come
+// before or after the archives.
+func ParseHARFlags(args []string) *types.HARConfig {
+	cfg := &types.HARConfig{}
+	fs := harFlags(cfg)
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		cfg.Files = append(cfg.Files, args[0])
+		args = args[1:]
+	}
+	return cfg
+}
+
+// harFlags returns the flag set of the har subcommand, bound to cfg.
+func harFlags(cfg *types.HARConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("har", flag.ExitOnError)
+	fs.StringVar(&cfg.OutDir, "out-dir", "", "Write each operation to this directory as a .graphql file, with its variables, to run with --batch-dir")
+	fs.StringVar(&cfg.Cache, "cache", workspace.DefaultOperationCache, "Operation cache of the workspace the operations are added to (\"\" disables it)")
+	fs.StringVar(&cfg.ObserveSchema, "observe-schema", "", "Build a schema from the recorded responses and write it as introspection JSON to this file")
+	fs.BoolVar(&cfg.Replay, "replay", false, "Send the queries again and observe the new responses instead of the recorded ones (mutations are never sent)")
+	fs.StringVar(&cfg.BaseURL, "base", "", "Replay against this endpoint instead of the recorded ones")
+	fs.Var((*headerFlag)(&cfg.Headers), "H", "Request header \"Name: value\" (repeatable) sent when replaying")
+	return fs
+}
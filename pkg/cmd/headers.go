@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// headerFlag collects repeated -H "Name: value" flags into a header map.
+type headerFlag map[string]string
+
+func (h *headerFlag) String() string {
+	if h == nil {
+		return ""
+	}
+	var pairs []string
+	for k, v := range *h {
+		pairs = append(pairs, k+": "+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+func (h *headerFlag) Set(value string) error {
+	name, val, err := network.ParseHeader(value)
+	if err != nil {
+		return err
+	}
+	if *h == nil {
+		*h = make(headerFlag)
+	}
+	(*h)[name] = val
+	return nil
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeaderFlag(t *testing.T) {
+	cfg, err := ParseArgs([]string{"-H", "authorization:  Bearer abc ", "-H", "x-tenant: acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"Authorization": "Bearer abc", "X-Tenant": "acme"}; !reflect.DeepEqual(cfg.Headers, want) {
+		t.Errorf("headers = %v, want %v", cfg.Headers, want)
+	}
+
+	for _, value := range []string{"X Api: v", "X-Api: a\r\nX-Injected: b", "Host: internal.example"} {
+		_, err := ParseArgs([]string{"-H", value})
+		if err == nil || !strings.Contains(err.Error(), "flag -H") {
+			t.Errorf("-H %q: error %v, want one naming the flag", value, err)
+		}
+	}
+}
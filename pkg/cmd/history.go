@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
+)
+
+// ParseHistoryFlags parses the arguments of the history subcommand. Flags may
+// come before or after the action and its arguments.
+func ParseHistoryFlags(args []string) *types.HistoryConfig {
+	cfg := &types.HistoryConfig{}
+	fs := historyFlags(cfg)
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		cfg.Args = append(cfg.Args, args[0])
+		args = args[1:]
+	}
+	return cfg
+}
+
+// historyFlags returns the flag set of the history subcommand, bound to cfg.
+func historyFlags(cfg *types.HistoryConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.StringVar(&cfg.File, "file", workspace.DefaultHistoryFile, "History log written with --history")
+	fs.StringVar(&cfg.Since, "since", "", "List the entries sent within this duration (e.g. 2h) or since this RFC 3339 time")
+	fs.StringVar(&cfg.OpName, "op-name", "", "List the entries of the operation with this name")
+	fs.IntVar(&cfg.Limit, "limit", 50, "Maximum number of entries listed (0 = all)")
+	fs.IntVar(&cfg.Offset, "offset", 0, "Number of most recent entries skipped before listing")
+	fs.StringVar(&cfg.BaseURL, "base", "", "Replay against this endpoint instead of the recorded one")
+	fs.Var((*headerFlag)(&cfg.Headers), "H", "Request header \"Name: value\" (repeatable) set when replaying, replacing the recorded one")
+	fs.StringVar(&cfg.Variables, "vars", "", "Variables as a JSON object replacing the recorded ones of the same name when replaying")
+	return fs
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ParseLintFlags parses the arguments of the lint subcommand. Positional
+// arguments are treated as additional document files.
+func ParseLintFlags(args []string) *types.LintConfig {
+	cfg := &types.LintConfig{}
+	fs := lintFlags(cfg)
+	fs.Parse(args)
+	cfg.Files = fs.Args()
+	return cfg
+}
+
+// lintFlags returns the flag set of the lint subcommand, bound to cfg.
+func lintFlags(cfg *types.LintConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fs.StringVar(&cfg.Dir, "dir", "", "Directory of .graphql documents to lint")
+	fs.IntVar(&cfg.MaxDepth, "max-query-depth", 10, "Maximum selection depth (0 = unlimited)")
+	fs.IntVar(&cfg.MaxAliases, "max-aliases", 30, "Maximum number of aliased fields (0 = unlimited)")
+	fs.IntVar(&cfg.MaxRootFields, "max-root-fields", 0, "Maximum number of root fields per operation (0 = unlimited)")
+	fs.IntVar(&cfg.MaxSelections, "max-selections", 0, "Maximum total number of selected fields (0 = unlimited)")
+	return fs
+}
+
+// ParseHashFlags parses the arguments of the hash subcommand. Positional
+// arguments are treated as additional document files.
+func ParseHashFlags(args []string) *types.HashConfig {
+	cfg := &types.HashConfig{}
+	fs := hashFlags(cfg)
+	fs.Parse(args)
+	cfg.Files = fs.Args()
+	return cfg
+}
+
+// hashFlags returns the flag set of the hash subcommand, bound to cfg.
+func hashFlags(cfg *types.HashConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("hash", flag.ExitOnError)
+	fs.StringVar(&cfg.QueryFile, "query-file", "", "Path to file containing the GraphQL document")
+	fs.StringVar(&cfg.QueryString, "query-string", "", "GraphQL document to hash")
+	fs.BoolVar(&cfg.Canonical, "canonical", false, "Print the canonical form instead of its hash")
+	return fs
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ParseReproFlags parses the arguments of the repro subcommand.
+func ParseReproFlags(args []string) *types.ReproConfig {
+	cfg := &types.ReproConfig{}
+	reproFlags(cfg).Parse(args)
+	return cfg
+}
+
+// reproFlags returns the flag set of the repro subcommand, bound to cfg.
+func reproFlags(cfg *types.ReproConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("repro", flag.ExitOnError)
+	fs.StringVar(&cfg.Finding, "finding", "", "Id of the finding reproduced, such as introspection-enabled")
+	fs.StringVar(&cfg.Endpoint, "endpoint", "", "Endpoint of the finding, when the report has it on several")
+	fs.StringVar(&cfg.Report, "report", "report.json", "JSON report the finding is read from")
+	fs.StringVar(&cfg.Out, "out", "repro_test.go", "Go file written; a name ending in _test.go gives a test, any other a main package")
+	return fs
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// resolveFlag collects repeated --resolve host:port:addr flags into a map of
+// "host:port" to addr.
+type resolveFlag map[string]string
+
+func (r *resolveFlag) String() string {
+	if r == nil {
+		return ""
+	}
+	var pins []string
+	for k, v := range *r {
+		pins = append(pins, k+"="+v)
+	}
+	sort.Strings(pins)
+	return strings.Join(pins, ", ")
+}
+
+func (r *resolveFlag) Set(value string) error {
+	hostPort, addr, err := network.ParsePin(value)
+	if err != nil {
+		return err
+	}
+	if *r == nil {
+		*r = make(resolveFlag)
+	}
+	(*r)[hostPort] = addr
+	return nil
+}
@@ -2,40 +2,180 @@ package cmd
 
 import (
 	"flag"
+	"github.com/CyberRoute/graphspecter/pkg/attacks"
+	"github.com/CyberRoute/graphspecter/pkg/auth"
+	"github.com/CyberRoute/graphspecter/pkg/inference"
+	"github.com/CyberRoute/graphspecter/pkg/introspection"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/subscription"
 	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
 	"time"
 )
 
+// ParseFlags parses the command-line flags of a run without a subcommand.
 func ParseFlags() *types.CLIConfig {
 	cfg := &types.CLIConfig{}
+	defineFlags(flag.CommandLine, cfg)
+	flag.Parse()
+	return cfg
+}
 
-	flag.StringVar(&cfg.BaseURL, "base", "", "Base URL of the target (e.g. http://192.168.1.1:5013)")
-	flag.BoolVar(&cfg.Detect, "detect", false, "Enable detection mode to find a GraphQL endpoint")
-	flag.StringVar(&cfg.OutputFile, "output", "introspection.json", "Dump introspection schema")
-	flag.DurationVar(&cfg.Timeout, "timeout", 1*time.Second, "Timeout for operations (e.g., 30s, 1m)")
-	flag.StringVar(&cfg.LogLevel, "log-level", "", "Log level (debug, info, warn, error)")
-	flag.StringVar(&cfg.LogFile, "log-file", "", "Log to file in addition to stdout")
-	flag.BoolVar(&cfg.NoColor, "no-color", false, "Disable colored output")
-	flag.IntVar(&cfg.MaxDepth, "max-depth", 10, "Maximum depth for selection sets")
-	flag.StringVar(&cfg.SchemaFile, "schema-file", "", "File with the GraphQL schema (introspection JSON)")
-	flag.StringVar(&cfg.List, "list", "", "List queries, mutations or both (valid: 'queries', 'mutations', 'all')")
-	flag.StringVar(&cfg.Query, "query", "", "Print named queries (comma-separated)")
-	flag.StringVar(&cfg.Mutation, "mutation", "", "Print named mutations (comma-separated)")
-	flag.BoolVar(&cfg.AllQueries, "all-queries", false, "Print all queries")
-	flag.BoolVar(&cfg.AllMutations, "all-mutations", false, "Print all mutations")
-	flag.BoolVar(&cfg.Subscribe, "subscribe", false, "Enable subscription mode")
-	flag.StringVar(&cfg.SubQuery, "sub-query", "", "Subscription query to execute")
-	flag.StringVar(&cfg.WSURL, "ws-url", "ws://192.168.1.100:5013/subscriptions", "WebSocket URL for subscriptions")
-	flag.StringVar(&cfg.ConfigFile, "config", "", "Path to config file (.yaml or .json)")
+// ParseArgs parses args as the flags of a run without a subcommand, without
+// touching the command-line flag set.
+func ParseArgs(args []string) (*types.CLIConfig, error) {
+	cfg := &types.CLIConfig{}
+	fs := flag.NewFlagSet("graphspecter", flag.ContinueOnError)
+	defineFlags(fs, cfg)
+	return cfg, fs.Parse(args)
+}
+
+// defineFlags defines the flags of a run without a subcommand on fs, bound to cfg.
+func defineFlags(fs *flag.FlagSet, cfg *types.CLIConfig) {
+	fs.StringVar(&cfg.BaseURL, "base", "", "Base URL of the target (e.g. http://192.168.1.1:5013)")
+	fs.BoolVar(&cfg.Detect, "detect", false, "Enable detection mode to find a GraphQL endpoint")
+	fs.StringVar(&cfg.OutputFile, "output", "introspection.json", "Dump introspection schema")
+	fs.DurationVar(&cfg.Timeout, "timeout", 1*time.Second, "Timeout for operations (e.g., 30s, 1m)")
+	fs.StringVar(&cfg.LogLevel, "log-level", "", "Log level (debug, info, warn, error)")
+	fs.StringVar(&cfg.LogFile, "log-file", "", "Log to file in addition to stdout")
+	fs.Var((*sizeFlag)(&cfg.LogMaxSize), "log-max-size", "Rotate --log-file once it reaches this size, such as 50MB (0 never rotates it)")
+	fs.IntVar(&cfg.LogMaxBackups, "log-max-backups", 5, "Number of rotated log files kept with --log-max-size, as <log-file>.1 (newest) to .N")
+	fs.BoolVar(&cfg.LogCompress, "log-compress", false, "Gzip the log files rotated by --log-max-size")
+	fs.BoolVar(&cfg.LogUTC, "log-utc", false, "Print terminal log timestamps in UTC instead of local time; log files always use UTC")
+	fs.BoolVar(&cfg.NoColor, "no-color", false, "Disable colored output (also off with NO_COLOR set or when stdout is not a terminal)")
+	fs.IntVar(&cfg.MaxDepth, "max-depth", 10, "Maximum depth for selection sets")
+	fs.StringVar(&cfg.SchemaFile, "schema-file", "", "File with the GraphQL schema (introspection JSON)")
+	fs.StringVar(&cfg.Sort, "sort", "schema", "Order of listed and generated operations (valid: 'schema', 'alpha')")
+	fs.StringVar(&cfg.Selection, "selection", schema.SelectionStandard, "Fields selected by generated operations: id-like fields and __typename, the fields within --max-depth, or those plus optional nested objects one level deeper (valid: 'minimal', 'standard', 'full')")
+	fs.IntVar(&cfg.Sample, "sample", 0, "Keep only this many operations of the schema for generation, cataloging and extraction (0 keeps them all)")
+	fs.StringVar(&cfg.SampleStrategy, "sample-strategy", schema.SampleCoverage, "How --sample picks operations: at random, those touching sensitive names first, or those reaching the most types (valid: 'random', 'sensitive-first', 'coverage')")
+	fs.Int64Var(&cfg.SampleSeed, "sample-seed", 1, "Seed of the random --sample-strategy, for reproducible samples")
+	fs.StringVar(&cfg.CatalogOut, "catalog-out", "", "Write the operation catalog of --schema-file to this file")
+	fs.StringVar(&cfg.CatalogFormat, "catalog-format", "json", "Format of --catalog-out (valid: 'json', 'csv')")
+	fs.StringVar(&cfg.SDLOut, "sdl-out", "", "Write --schema-file as SDL, with the directives applied to its fields, to this file")
+	fs.StringVar(&cfg.OutDir, "out-dir", "", "Write the executable operations of --schema-file to this directory, one .graphql file each, with a manifest")
+	fs.StringVar(&cfg.VarsSchemaOut, "vars-schema-out", "", "Write a JSON Schema of the variables of each operation of --schema-file to this directory, named after the --out-dir documents")
+	fs.StringVar(&cfg.List, "list", "", "List queries, mutations or both (valid: 'queries', 'mutations', 'all')")
+	fs.StringVar(&cfg.Notes, "notes", "", "YAML or JSON file annotating operations and types with notes and tags, shown in listings, catalogs and reports")
+	fs.StringVar(&cfg.Tag, "tag", "", "List only the operations tagged with this tag in --notes")
+	fs.StringVar(&cfg.Query, "query", "", "Print named queries (comma-separated)")
+	fs.StringVar(&cfg.Mutation, "mutation", "", "Print named mutations (comma-separated)")
+	fs.BoolVar(&cfg.AllQueries, "all-queries", false, "Print all queries")
+	fs.BoolVar(&cfg.AllMutations, "all-mutations", false, "Print all mutations")
+	fs.BoolVar(&cfg.Subscribe, "subscribe", false, "Enable subscription mode")
+	fs.StringVar(&cfg.SubQuery, "sub-query", "", "Subscription query to execute")
+	fs.DurationVar(&cfg.SubAckTimeout, "sub-ack-timeout", subscription.DefaultAckTimeout, "Time to wait for connection_ack in subscription mode")
+	fs.DurationVar(&cfg.SubReadTimeout, "sub-read-timeout", subscription.DefaultReadTimeout, "Time to wait for each subscription message before giving up (negative waits forever)")
+	fs.StringVar(&cfg.SubTransport, "sub-transport", "auto", "Subscription transport: WebSocket, GraphQL over Server-Sent Events at --base (or --ws-url over HTTP), or WebSocket falling back to SSE (valid: 'ws', 'sse', 'auto')")
+	fs.StringVar(&cfg.WSURL, "ws-url", "ws://192.168.1.100:5013/subscriptions", "WebSocket URL for subscriptions")
+	fs.BoolVar(&cfg.Redact, "redact", true, "Mask supplied credentials in report evidence")
+	fs.BoolVar(&cfg.RedactArtifacts, "redact-artifacts", false, "Mask sensitive values in saved introspection dumps")
+	fs.StringVar(&cfg.StopOnFinding, "stop-on-finding", "", "Stop the run at the first finding of this severity or higher (info, low, medium, high, critical)")
+	fs.BoolVar(&cfg.ContinueOnError, "continue-on-error", false, "Keep scanning the remaining targets after a check fails")
+	fs.StringVar(&cfg.CheckTimeouts, "check-timeout", "", "Per-check time budgets by check id or group (e.g. dos=2m,engine=20s; 0 disables)")
+	fs.StringVar(&cfg.TargetsFile, "targets", "", "File with one target URL per line, used instead of -base")
+	fs.StringVar(&cfg.VHosts, "vhosts", "", "File with one host name per line; detection is repeated at the address of each target with every name as Host header and TLS server name, reporting the names that serve different GraphQL endpoints")
+	fs.StringVar(&cfg.StateFile, "state-file", workspace.DefaultStateFile, "File recording the progress of multi-target scans")
+	fs.BoolVar(&cfg.Resume, "resume", false, "Skip the targets completed by a previous run recorded in --state-file")
+	fs.DurationVar(&cfg.Watch, "watch", 0, "Re-run the audit on this interval (e.g. 1h) until interrupted, reporting new and resolved findings as NDJSON events")
+	fs.StringVar(&cfg.WatchState, "watch-state", workspace.DefaultWatchFile, "File recording the findings of the last --watch iteration")
+	fs.StringVar(&cfg.WebhookURL, "webhook-url", "", "URL receiving a JSON POST with the new findings of each --watch iteration")
+	fs.BoolVar(&cfg.Version, "version", false, "Print version information and exit")
+	fs.StringVar(&cfg.ConfigFile, "config", "", "Path to config file (.yaml or .json)")
+	fs.StringVar(&cfg.Checks, "checks", "", "Comma-separated audit checks to run (default: all)")
+	fs.StringVar(&cfg.SkipChecks, "skip-checks", "", "Comma-separated audit checks to skip")
+	fs.StringVar(&cfg.CustomChecks, "custom-checks", "", "YAML or JSON file of declarative checks, each a request and the rules its response must match, run with the built-in ones")
+	fs.BoolVar(&cfg.ListChecks, "list-checks", false, "List available audit checks and exit")
+	fs.BoolVar(&cfg.ListWordlists, "list-wordlists", false, "List the built-in wordlists and exit")
+	fs.BoolVar(&cfg.AuditWS, "audit-ws", false, "Also fuzz the subscription WebSocket protocol")
+	fs.BoolVar(&cfg.AuditDoS, "audit-dos", false, "Also run denial-of-service checks such as the rate-limit ramp")
+	fs.BoolVar(&cfg.AuditInjection, "audit-injection", false, "Also send time-based SQL, NoSQL and shell injection payloads in the String and ID arguments of queries")
+	fs.BoolVar(&cfg.Safe, "safe", false, "Run only the passive checks, which send no attack payloads, malformed requests or load")
+	fs.BoolVar(&cfg.Aggressive, "aggressive", false, "Run every check, as if all of --audit-dos, --audit-ws and --audit-injection were given")
+	fs.DurationVar(&cfg.InjectionDelay, "injection-delay", attacks.DefaultInjectionDelay, "Delay the --audit-injection payloads ask the backend for, in whole seconds")
+	fs.Float64Var(&cfg.InjectionFactor, "injection-factor", attacks.DefaultInjectionFactor, "Times the baseline latency a response must take to count as delayed by an injection payload")
+	fs.IntVar(&cfg.InjectionTrials, "injection-trials", attacks.DefaultInjectionTrials, "Times an injection payload is sent; every response must be delayed")
+	fs.StringVar(&cfg.ReportFile, "report", "", "Write audit findings to a report file (.md, .html, .csv and .sarif select Markdown, HTML, CSV or SARIF, JSON otherwise)")
+	fs.StringVar(&cfg.ReportFormat, "report-format", "", "Format of --report overriding its extension (valid: 'json', 'markdown', 'html', 'csv', 'sarif')")
+	fs.StringVar(&cfg.ReportDetail, "report-detail", report.DetailStandard, "Evidence embedded in --report (valid: 'summary', 'standard', 'full')")
+	fs.StringVar(&cfg.ReportTemplate, "report-template", "", "Render --report with a Go text/template file or a built-in template ('executive', 'technical')")
+	fs.BoolVar(&cfg.Extract, "extract", false, "Execute every generated query after introspection and summarise the returned data")
+	fs.StringVar(&cfg.ExtractDir, "extract-dir", "extract", "Directory for data extraction results")
+	fs.StringVar(&cfg.ObserveSchema, "observe-schema", "", "Build a schema from the responses of --batch-dir, --execute and --extract and write it as introspection JSON to this file")
+	fs.StringVar(&cfg.RecoverSchema, "recover-schema", "", "Recover the schema of --base, which does not answer introspection, by probing the field names of --wordlist, and write it as introspection JSON to this file")
+	fs.StringVar(&cfg.Wordlist, "wordlist", inference.BuiltinPrefix+"fields-medium", "Field names probed by --recover-schema: a built-in wordlist (see --list-wordlists) or a file of one name per line")
+	fs.StringVar(&cfg.ArgWordlist, "arg-wordlist", inference.BuiltinPrefix+"arguments", "Argument names --recover-schema probes on the fields it confirms, a wordlist like --wordlist (\"\" probes none)")
+	fs.IntVar(&cfg.InferenceWorkers, "inference-workers", 4, "Probes --recover-schema sends at once")
+	fs.Int64Var(&cfg.InferenceSeed, "inference-seed", 1, "Seed ordering the --recover-schema candidates of equal score, for reproducible runs")
+	fs.StringVar(&cfg.ResumeInference, "resume-inference", "", "Save the progress of --recover-schema to this file and resume from it when it exists (e.g. state.json)")
+	fs.IntVar(&cfg.CheckpointEvery, "checkpoint-every", 100, "Save --resume-inference every this many requests, and when the run stops")
+	fs.BoolVar(&cfg.FollowPagination, "follow-pagination", false, "Page through relay connections and offset/limit lists during --extract")
+	fs.IntVar(&cfg.MaxPages, "max-pages", 10, "Maximum number of pages fetched per query with --follow-pagination")
+	fs.StringVar(&cfg.MatrixDir, "matrix-dir", "", "Directory for the access matrix of --extract across the targets, matrix.csv and matrix.json, with an operation per row and a target per column")
+	fs.StringVar(&cfg.IdentitiesFile, "identities", "", "Send every generated query as each identity of this .yaml or .json file, least privileged first, and report the authorization matrix of each target")
+	fs.StringVar(&cfg.AuthzIgnore, "authz-ignore", "", "Comma-separated paths of the data left out when comparing the identities of --identities, with * matching a member or index and ** any depth (e.g. data.*.updatedAt)")
+	fs.StringVar(&cfg.AuthzUnordered, "authz-unordered", "", "Comma-separated paths of arrays of the data of --identities compared regardless of order")
+	fs.StringVar(&cfg.SecretPatterns, "secret-patterns", "", "File of name=regexp lines added to the secret detectors of --extract; name= disables a built-in one")
+	fs.Float64Var(&cfg.Rate, "rate", 0, "Maximum requests per second (0 = unlimited)")
+	fs.StringVar(&cfg.Concurrency, "concurrency", "0", "Maximum requests in flight (0 = unlimited), or auto to start low and back off when errors and timeouts rise")
+	fs.BoolVar(&cfg.ChunkedIntrospection, "chunked-introspection", false, "Fetch the schema as a type list followed by batches of __type queries")
+	fs.IntVar(&cfg.IntrospectionChunkSize, "introspection-chunk-size", introspection.DefaultChunkSize, "Number of types per chunked introspection request")
+	fs.StringVar(&cfg.IntrospectionFile, "introspection-file", "", "Audit a saved introspection result instead of querying the target for it")
+	fs.StringVar(&cfg.RequestEncoding, "request-encoding", "", "Wrap the requests to the targets for endpoints tunnelling GraphQL (valid: 'json', 'envelope', 'form', 'jsonrpc')")
+	fs.StringVar(&cfg.Whoami, "whoami-query", auth.DefaultWhoami, "Query only authenticated users can run, sent to each target to verify the supplied credentials before the audit")
+	fs.StringVar(&cfg.CanaryQuery, "canary-query", "", "Read query sent before and after the audit of each target; a different response fails the run with exit status 3")
+	fs.StringVar(&cfg.CanaryIgnore, "canary-ignore", "", "Comma-separated paths of the canary response left out of the comparison, with * matching a member or index and ** any depth (e.g. data.*.updatedAt)")
+	fs.StringVar(&cfg.CanaryUnordered, "canary-unordered", "", "Comma-separated paths of arrays of the canary response compared regardless of order")
+	fs.BoolVar(&cfg.Offline, "offline", false, "Refuse all network access: only run the file-based modes and the checks that send no requests (needs --introspection-file or --schema-file)")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "Print the endpoints, checks, operations and estimated request count and duration of the run without sending anything")
+	fs.BoolVar(&cfg.Preview, "preview", false, "Print the URL, headers, body and transport settings of each request --execute or --batch-dir would send without sending anything")
+	fs.StringVar(&cfg.PreflightURL, "preflight-url", "", "URL fetched with GET before auditing to obtain a session or CSRF token")
+	fs.StringVar(&cfg.PreflightTokenExtract, "preflight-token-extract", "", "Where to find the token in the preflight response (header:<name>, cookie:<name>, json:<path> or a regex)")
+	fs.StringVar(&cfg.PreflightTokenHeader, "preflight-token-header", "X-CSRF-Token: {token}", "Header carrying the preflight token on every request")
+	fs.StringVar(&cfg.PreflightExpired, "preflight-expired", auth.DefaultExpiredPattern, "Regex on response bodies that triggers a new preflight request")
+	fs.StringVar(&cfg.ClientCert, "client-cert", "", "PEM client certificate for mutual TLS")
+	fs.StringVar(&cfg.ClientKey, "client-key", "", "PEM private key of --client-cert")
+	fs.StringVar(&cfg.ClientKeyPassword, "client-key-password", "", "Password of an encrypted --client-key")
+	fs.Var((*sinkFlag)(&cfg.Sinks), "sink", "Also send the findings of an audit to a sink as they are found, as json=<file>, ndjson=<file or ->, table or webhook=<url> (repeatable)")
+	fs.Var((*resolveFlag)(&cfg.Resolve), "resolve", "Connect to addr for host:port instead of resolving host, as host:port:addr (repeatable)")
+	fs.StringVar(&cfg.DNSServer, "dns-server", "", "DNS server host:port resolving the target hosts instead of the system resolver")
+	fs.IntVar(&cfg.IPVersion, "ip-version", 0, "Connect over IPv4 (4) or IPv6 (6) only; both are used by default")
+	fs.BoolVar(&cfg.AWSSign, "aws-sign", false, "Sign every request with AWS SigV4 (AppSync IAM auth) using the credentials of the environment or ~/.aws/credentials")
+	fs.StringVar(&cfg.AWSRegion, "aws-region", "", "AWS region of --aws-sign (default $AWS_REGION or $AWS_DEFAULT_REGION)")
+	fs.StringVar(&cfg.AWSService, "aws-service", auth.DefaultAWSService, "AWS signing name of --aws-sign")
+	fs.StringVar(&cfg.VulnDB, "vulndb", "", "JSON vulnerability knowledge base replacing the embedded one")
+	fs.Var((*headerFlag)(&cfg.Headers), "H", "Request header \"Name: value\" (repeatable), overriding the config file headers")
+	fs.StringVar(&cfg.DataDir, "data-dir", "", "Directory of dataset overrides (paths.json, engines.json, ides.json, sensitive-fields.json, error-patterns.json)")
+	fs.StringVar(&cfg.ErrorPatterns, "error-patterns", "", "File of GraphQL error codes and phrases (an error-patterns dataset document) classifying auth, validation, rate-limit and suggestion errors")
+	fs.BoolVar(&cfg.Stats, "stats", false, "Print network metrics at the end of the run and include them in the report")
+	fs.StringVar(&cfg.History, "history", "", "Append every request sent, with masked credentials, to this NDJSON log (e.g. history.ndjson) read by graphspecter history")
+	fs.StringVar(&cfg.ProfileBounty, "profile-bounty", "", "Enforce the rules of a bug bounty program from this .yaml or .json file: required headers, rate and concurrency caps, forbidden checks and allowed hosts")
+	fs.StringVar(&cfg.Scope, "scope", "", "Comma-separated hosts, *.domain patterns or * that every request, redirect and WebSocket dial is restricted to (default: the hosts of --base, --targets, --ws-url and --preflight-url)")
+	fs.StringVar(&cfg.RunManifest, "run-manifest", "", "Write a JSON manifest of the run (redacted arguments, phase timings, exit code, artifacts and findings by severity) to this file, kept up to date until the run ends")
+	fs.StringVar(&cfg.SignKey, "sign-key", "", "Ed25519 private key (PKCS #8 PEM) signing every artifact of the run and the --run-manifest, each with a detached .sig file next to it")
 
 	// Placeholder for future use
-	flag.BoolVar(&cfg.Execute, "execute", false, "Execute a query or mutation (future feature)")
-	flag.StringVar(&cfg.BatchDir, "batch-dir", "", "Directory of .graphql/.json pairs to execute in bulk")
-	flag.StringVar(&cfg.QueryString, "query-string", "", "GraphQL query string to execute")
-	flag.StringVar(&cfg.QueryFile, "query-file", "", "Path to file containing GraphQL query")
-	flag.StringVar(&cfg.Variables, "vars", "", "Query variables as JSON string")
-	flag.StringVar(&cfg.VariablesFile, "vars-file", "", "Path to JSON file with variables")
+	fs.BoolVar(&cfg.Execute, "execute", false, "Execute a query or mutation (future feature)")
+	fs.StringVar(&cfg.BatchDir, "batch-dir", "", "Directory of .graphql/.json pairs to execute in bulk")
+	fs.BoolVar(&cfg.IgnoreFailures, "ignore-failures", false, "Exit with status 0 even when batch operations fail")
+	fs.BoolVar(&cfg.KeepAllFragments, "keep-all-fragments", false, "Send every fragment of a document in batch and execute modes, not only the ones each operation uses")
+	fs.StringVar(&cfg.QueryString, "query-string", "", "GraphQL query string to execute")
+	fs.StringVar(&cfg.QueryFile, "query-file", "", "Path to file containing GraphQL query")
+	fs.StringVar(&cfg.Variables, "vars", "", "Query variables as JSON string")
+	fs.StringVar(&cfg.VariablesFile, "vars-file", "", "Path to JSON file with variables")
+	fs.StringVar(&cfg.ParamName, "param-name", "", "Send the executed query as this JSON member or URL parameter (e.g. q, body:doc, url:query)")
+	fs.BoolVar(&cfg.FuzzCoercion, "fuzz-coercion", false, "With --execute, send values of the wrong JSON type for each variable of the query and report crashes and silent acceptance")
+	fs.StringVar(&cfg.DuplicateQuery, "duplicate-query", "", "Send \"benign=<query> real=<query>\": the benign query as body:query, the real one at --param-name (default url:query)")
+}
 
-	flag.Parse()
-	return cfg
+// IsSet reports whether the named flag was given explicitly on the command line.
+func IsSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
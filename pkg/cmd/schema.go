@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"flag"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// DefaultLockFile is the schema lock written and verified by default.
+const DefaultLockFile = "schema.lock"
+
+// ParseSchemaFlags parses the arguments of the schema subcommand. Flags may
+// come before or after the action.
+func ParseSchemaFlags(args []string) *types.SchemaConfig {
+	cfg := &types.SchemaConfig{}
+	fs := schemaFlags(cfg)
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		cfg.Args = append(cfg.Args, args[0])
+		args = args[1:]
+	}
+	return cfg
+}
+
+// schemaFlags returns the flag set of the schema subcommand, bound to cfg.
+func schemaFlags(cfg *types.SchemaConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	fs.StringVar(&cfg.BaseURL, "base", "", "GraphQL endpoint whose schema is locked or verified")
+	fs.Var((*headerFlag)(&cfg.Headers), "H", "Request header \"Name: value\" (repeatable) sent with the introspection query")
+	fs.StringVar(&cfg.Out, "out", DefaultLockFile, "Schema lock written by lock")
+	fs.StringVar(&cfg.Lock, "lock", DefaultLockFile, "Schema lock the endpoint is verified against")
+	fs.DurationVar(&cfg.Timeout, "timeout", 30*time.Second, "Timeout of the introspection query")
+	fs.StringVar(&cfg.SchemaFile, "schema-file", "", "Saved introspection result locked or verified instead of the schema of --base")
+	fs.BoolVar(&cfg.Offline, "offline", false, "Refuse all network access (needs --schema-file)")
+	return fs
+}
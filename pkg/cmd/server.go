@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/CyberRoute/graphspecter/pkg/server"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ParseServerFlags parses the arguments of the server subcommand.
+func ParseServerFlags(args []string) *types.ServerConfig {
+	cfg := &types.ServerConfig{}
+	serverFlags(cfg).Parse(args)
+	return cfg
+}
+
+// serverFlags returns the flag set of the server subcommand, bound to cfg.
+func serverFlags(cfg *types.ServerConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	fs.StringVar(&cfg.Listen, "listen", ":8888", "Address the API listens on")
+	fs.IntVar(&cfg.Workers, "workers", server.DefaultWorkers, "Number of scans run at the same time (concurrent scans share their network stats)")
+	fs.IntVar(&cfg.QueueSize, "queue-size", server.DefaultQueueSize, "Number of scans that can wait for a worker")
+	fs.StringVar(&cfg.Dir, "dir", "scans", "Directory for the artifacts of each scan")
+	fs.BoolVar(&cfg.Persist, "persist", false, "Save scan records in --dir and reload them on start")
+	fs.DurationVar(&cfg.ScanTimeout, "scan-timeout", server.DefaultScanTimeout, "Timeout of scans that do not set their own")
+	fs.IntVar(&cfg.MaxDepth, "max-depth", 10, "Maximum depth for selection sets")
+	fs.StringVar(&cfg.ProfileBounty, "profile-bounty", "", "Enforce the rules of a bug bounty program from this .yaml or .json file on every queued scan; targets outside its allowed hosts are refused")
+	fs.StringVar(&cfg.LogLevel, "log-level", "", "Log level (debug, info, warn, error)")
+	fs.StringVar(&cfg.LogFile, "log-file", "", "Log to file in addition to stdout")
+	fs.Var((*sizeFlag)(&cfg.LogMaxSize), "log-max-size", "Rotate --log-file once it reaches this size, such as 50MB (0 never rotates it)")
+	fs.IntVar(&cfg.LogMaxBackups, "log-max-backups", 5, "Number of rotated log files kept with --log-max-size, as <log-file>.1 (newest) to .N")
+	fs.BoolVar(&cfg.LogCompress, "log-compress", false, "Gzip the log files rotated by --log-max-size")
+	fs.BoolVar(&cfg.LogUTC, "log-utc", false, "Print terminal log timestamps in UTC instead of local time; log files always use UTC")
+	fs.BoolVar(&cfg.NoColor, "no-color", false, "Disable colored output (also off with NO_COLOR set or when stdout is not a terminal)")
+	return fs
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// sinkFlag collects repeated --sink kind=target flags. The sinks are opened
+// once the run starts.
+type sinkFlag []string
+
+func (s *sinkFlag) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ", ")
+}
+
+func (s *sinkFlag) Set(value string) error {
+	kind, _, _ := strings.Cut(value, "=")
+	switch kind {
+	case report.SinkJSON, report.SinkNDJSON, report.SinkTable, report.SinkWebhook:
+	default:
+		return fmt.Errorf("unknown sink %q (valid: 'json', 'ndjson', 'table', 'webhook')", kind)
+	}
+	*s = append(*s, value)
+	return nil
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+)
+
+// sizeFlag is a size in bytes given as 50MB, 512KB, 1GB or a number of bytes.
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	if s == nil {
+		return ""
+	}
+	n := int64(*s)
+	for _, u := range []struct {
+		suffix string
+		bytes  int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if n != 0 && n%u.bytes == 0 {
+			return strconv.FormatInt(n/u.bytes, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func (s *sizeFlag) Set(value string) error {
+	n, err := logger.ParseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(n)
+	return nil
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ParseVerifyFlags parses the arguments of the verify subcommand.
+func ParseVerifyFlags(args []string) *types.VerifyConfig {
+	cfg := &types.VerifyConfig{}
+	verifyFlags(cfg).Parse(args)
+	return cfg
+}
+
+// verifyFlags returns the flag set of the verify subcommand, bound to cfg.
+func verifyFlags(cfg *types.VerifyConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.StringVar(&cfg.Manifest, "manifest", "", "Run manifest written with --run-manifest and --sign-key")
+	fs.StringVar(&cfg.PublicKey, "pub", "", "Ed25519 public key (PKIX PEM) matching the --sign-key of the run")
+	fs.StringVar(&cfg.Dir, "dir", ".", "Directory the relative artifact paths of the manifest are taken from, the one the run was started in")
+	return fs
+}
@@ -0,0 +1,258 @@
+// Package compare runs the operation catalog against two endpoints and reports where they behave differently
+package compare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+// Operation statuses
+const (
+	StatusAccessible    = "accessible"
+	StatusAuthRequired  = "auth-required"
+	StatusValidation    = "validation-error"
+	StatusExecution     = "execution-error"
+	StatusRateLimited   = "rate-limited"
+	StatusTimeout       = "timeout"
+	StatusNotGraphQL    = "not-graphql"
+	StatusTransport     = "transport-error"
+	StatusNotExecutable = "not-executable"
+)
+
+// Divergence kinds
+const (
+	DivergenceStatus     = "status"
+	DivergenceErrorShape = "error-shape"
+	DivergenceLatency    = "latency"
+	DivergenceData       = "data"
+)
+
+// Latency thresholds used when Options leaves them unset
+const (
+	DefaultLatencyFactor  = 3.0
+	DefaultLatencyMinimum = 200 * time.Millisecond
+)
+
+// Options configures Run.
+type Options struct {
+	// Mutations also executes the mutations of the catalog. They change data
+	// on both endpoints, so they are left out by default.
+	Mutations bool
+	// LatencyFactor is the ratio between the slower and faster latency above
+	// which an operation is reported; LatencyMinimum is the smallest absolute
+	// difference reported.
+	LatencyFactor  float64
+	LatencyMinimum time.Duration
+	// Data also compares the data of the operations both endpoints answer,
+	// leaving out the paths of Diff.Ignore.
+	Data bool
+	Diff jsondiff.Options
+}
+
+// Outcome is how one endpoint answered an operation.
+type Outcome struct {
+	Status string `json:"status"`
+	// Shape summarises the errors array: their codes and members.
+	Shape   string        `json:"shape,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Latency time.Duration `json:"-"`
+	// LatencyMs is Latency in milliseconds.
+	LatencyMs int64 `json:"latencyMs"`
+	// Data is the data member of the response.
+	Data interface{} `json:"-"`
+}
+
+// OperationResult compares the outcomes of one operation on both endpoints.
+type OperationResult struct {
+	Kind        string   `json:"kind"`
+	Operation   string   `json:"operation"`
+	A           Outcome  `json:"a"`
+	B           Outcome  `json:"b"`
+	Divergences []string `json:"divergences,omitempty"`
+	// Diff lists the differences of the data, rendered by jsondiff.Render.
+	Diff string `json:"diff,omitempty"`
+}
+
+// Result is the outcome of a comparison run.
+type Result struct {
+	BaseA      string            `json:"baseA"`
+	BaseB      string            `json:"baseB"`
+	Operations []OperationResult `json:"operations"`
+	// Divergent is the number of operations with at least one divergence.
+	Divergent int `json:"divergent"`
+}
+
+// Run executes the executable document of each catalog operation against a
+// and b, one endpoint after the other, and compares their status, error shape
+// and latency, and with opts.Data their data. Mutations and subscriptions are skipped unless opts allows
+// mutations.
+func Run(ctx context.Context, catalog *schema.Catalog, a, b string, headers map[string]string, opts Options) (*Result, error) {
+	if opts.LatencyFactor <= 1 {
+		opts.LatencyFactor = DefaultLatencyFactor
+	}
+	if opts.LatencyMinimum <= 0 {
+		opts.LatencyMinimum = DefaultLatencyMinimum
+	}
+
+	result := &Result{BaseA: a, BaseB: b, Operations: []OperationResult{}}
+	for _, op := range catalog.Operations {
+		if op.Kind == schema.KindSubscription || (op.Kind == schema.KindMutation && !opts.Mutations) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		r := OperationResult{Kind: op.Kind, Operation: op.Name}
+		if op.Executable == "" {
+			r.A = Outcome{Status: StatusNotExecutable}
+			r.B = r.A
+		} else {
+			logger.Debug("→ Comparing %s %s", op.Kind, op.Name)
+			r.A = execute(ctx, a, op, headers)
+			r.B = execute(ctx, b, op, headers)
+		}
+		r.Divergences = diverge(r.A, r.B, opts)
+		if opts.Data && r.A.Status == StatusAccessible && r.B.Status == StatusAccessible {
+			diffs := jsondiff.Compare(map[string]interface{}{"data": r.A.Data}, map[string]interface{}{"data": r.B.Data}, opts.Diff)
+			if len(diffs) > 0 {
+				r.Divergences = append(r.Divergences, DivergenceData)
+				r.Diff = jsondiff.Render(diffs)
+			}
+		}
+		if len(r.Divergences) > 0 {
+			result.Divergent++
+		}
+		result.Operations = append(result.Operations, r)
+	}
+	return result, nil
+}
+
+// execute sends op to url and classifies the response.
+func execute(ctx context.Context, url string, op schema.CatalogOperation, headers map[string]string) Outcome {
+	start := time.Now()
+	resp, err := network.SendGraphQLRequestWithContext(ctx, url, op.Executable, nil, headers)
+	latency := time.Since(start)
+	o := Outcome{Latency: latency, LatencyMs: latency.Milliseconds()}
+	if err != nil {
+		o.Error = err.Error()
+		switch {
+		case errors.Is(err, gerrors.ErrRateLimited):
+			o.Status = StatusRateLimited
+		case errors.Is(err, gerrors.ErrTimeout):
+			o.Status = StatusTimeout
+		case gerrors.IsNotGraphQL(err):
+			o.Status = StatusNotGraphQL
+		default:
+			o.Status = StatusTransport
+		}
+		return o
+	}
+
+	o.Data = resp["data"]
+	errs, _ := resp["errors"].([]interface{})
+	if len(errs) == 0 {
+		o.Status = StatusAccessible
+		return o
+	}
+	classes := gql.ClassifyErrors(errs)
+	authRequired, rejected := classes[data.ErrorAuth], classes[data.ErrorValidation]
+	data, _ := resp["data"].(map[string]interface{})
+	o.Shape = errorShape(errs, data, op.Name)
+	o.Error = firstMessage(errs)
+	switch {
+	case authRequired:
+		o.Status = StatusAuthRequired
+	case data == nil && rejected:
+		o.Status = StatusValidation
+	case data != nil && data[op.Name] != nil:
+		// Partial data: the field resolved but some of its children failed.
+		o.Status = StatusAccessible
+	default:
+		o.Status = StatusExecution
+	}
+	return o
+}
+
+// errorShape describes the structure of an errors array independently of the
+// messages, which often embed request-specific values.
+func errorShape(errs []interface{}, data map[string]interface{}, field string) string {
+	codes := map[string]bool{}
+	keys := map[string]bool{}
+	for _, e := range errs {
+		m, ok := e.(map[string]interface{})
+		if !ok {
+			keys["(non-object)"] = true
+			continue
+		}
+		for k := range m {
+			keys[k] = true
+		}
+		if ext, ok := m["extensions"].(map[string]interface{}); ok {
+			if code, ok := ext["code"].(string); ok {
+				codes[code] = true
+			}
+			for k := range ext {
+				keys["extensions."+k] = true
+			}
+		}
+	}
+	dataState := "null"
+	if data != nil {
+		dataState = "present"
+		if data[field] == nil {
+			dataState = "field-null"
+		}
+	}
+	return fmt.Sprintf("errors=%d codes=[%s] keys=[%s] data=%s", len(errs), strings.Join(sortedKeys(codes), ","), strings.Join(sortedKeys(keys), ","), dataState)
+}
+
+func firstMessage(errs []interface{}) string {
+	if m, ok := errs[0].(map[string]interface{}); ok {
+		if msg, ok := m["message"].(string); ok {
+			return msg
+		}
+	}
+	return fmt.Sprint(errs[0])
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diverge lists the ways in which outcomes a and b differ.
+func diverge(a, b Outcome, opts Options) []string {
+	var d []string
+	if a.Status != b.Status {
+		d = append(d, DivergenceStatus)
+	} else if a.Shape != b.Shape {
+		d = append(d, DivergenceErrorShape)
+	}
+	if a.Status == StatusNotExecutable {
+		return d
+	}
+	fast, slow := a.Latency, b.Latency
+	if fast > slow {
+		fast, slow = slow, fast
+	}
+	if slow-fast >= opts.LatencyMinimum && float64(slow) > opts.LatencyFactor*float64(fast) {
+		d = append(d, DivergenceLatency)
+	}
+	return d
+}
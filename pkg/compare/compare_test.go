@@ -0,0 +1,244 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// compareSchema has a root field for each way two replicas can diverge, a
+// mutation and a subscription.
+func compareSchema() *types.GQLSchema {
+	scalar := func(name string) types.TypeRef { return types.TypeRef{Kind: types.SCALAR, Name: name} }
+	fields := func(names ...string) []types.Field {
+		var out []types.Field
+		for _, n := range names {
+			out = append(out, types.Field{Name: n, Type: scalar("String")})
+		}
+		return out
+	}
+	query := types.Type{Kind: types.OBJECT, Name: "Query", Fields: append(fields("same", "secret", "broken", "slow", "missing", "report"),
+		types.Field{Name: "price", Type: scalar("Int")},
+		types.Field{Name: "tags", Type: types.TypeRef{Kind: types.LIST, OfType: &types.TypeRef{Kind: types.SCALAR, Name: "String"}}},
+		types.Field{Name: "item", Type: types.TypeRef{Kind: types.OBJECT, Name: "Item"}},
+	)}
+	mutation := types.Type{Kind: types.OBJECT, Name: "Mutation", Fields: []types.Field{{Name: "reset", Type: scalar("Boolean")}}}
+	subscription := types.Type{Kind: types.OBJECT, Name: "Subscription", Fields: fields("events")}
+	return &types.GQLSchema{Query: &query, Mutation: &mutation, Subscription: &subscription, Types: map[string]types.Type{
+		"Query":        query,
+		"Mutation":     mutation,
+		"Subscription": subscription,
+		"Item":         {Kind: types.OBJECT, Name: "Item", Fields: fields("id", "updatedAt")},
+		"String":       {Kind: types.SCALAR, Name: "String"},
+		"Int":          {Kind: types.SCALAR, Name: "Int"},
+		"Boolean":      {Kind: types.SCALAR, Name: "Boolean"},
+	}}
+}
+
+// replica is a mock endpoint serving compareSchema. The primary answers every
+// operation; the misconfigured one requires authentication for secret,
+// reports broken without error codes, is slow on slow, lacks the missing
+// field, is behind a WAF page for report and returns other data for price,
+// tags and item. It records the root fields it was asked for.
+type replica struct {
+	*httptest.Server
+	mu     sync.Mutex
+	fields []string
+}
+
+func newReplica(t *testing.T, misconfigured bool) *replica {
+	t.Helper()
+	r := &replica{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		doc, err := gql.Parse(body.Query)
+		if err != nil || len(doc.Operations) != 1 {
+			t.Errorf("unexpected document %q", body.Query)
+			return
+		}
+		field := doc.Operations[0].SelectionSet[0].(*gql.Field).Name
+		r.mu.Lock()
+		r.fields = append(r.fields, field)
+		r.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		write := func(s string) { w.Write([]byte(s)) }
+		switch {
+		case field == "report" && misconfigured:
+			w.Header().Set("Content-Type", "text/html")
+			write("<html><body>Request blocked</body></html>")
+		case field == "secret" && misconfigured:
+			write(`{"data":{"secret":null},"errors":[{"message":"Not authenticated","extensions":{"code":"UNAUTHENTICATED"}}]}`)
+		case field == "broken" && misconfigured:
+			write(`{"data":{"broken":null},"errors":[{"message":"Internal error"}]}`)
+		case field == "broken":
+			write(`{"data":{"broken":null},"errors":[{"message":"Internal error","path":["broken"],"extensions":{"code":"INTERNAL_SERVER_ERROR"}}]}`)
+		case field == "missing" && misconfigured:
+			write(`{"errors":[{"message":"Cannot query field \"missing\" on type \"Query\".","extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}]}`)
+		case field == "slow" && misconfigured:
+			time.Sleep(300 * time.Millisecond)
+			write(`{"data":{"slow":"ok"}}`)
+		case field == "price" && misconfigured:
+			write(`{"data":{"price":12}}`)
+		case field == "price":
+			write(`{"data":{"price":10}}`)
+		case field == "tags" && misconfigured:
+			write(`{"data":{"tags":["b","a"]}}`)
+		case field == "tags":
+			write(`{"data":{"tags":["a","b"]}}`)
+		case field == "item" && misconfigured:
+			write(`{"data":{"item":{"id":"1","updatedAt":"2024-05-02"}}}`)
+		case field == "item":
+			write(`{"data":{"item":{"id":"1","updatedAt":"2024-05-01"}}}`)
+		default:
+			write(`{"data":{"` + field + `":"ok"}}`)
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// divergences returns the divergences of each divergent operation.
+func divergences(r *Result) map[string][]string {
+	out := make(map[string][]string)
+	for _, op := range r.Operations {
+		if len(op.Divergences) > 0 {
+			out[op.Operation] = op.Divergences
+		}
+	}
+	return out
+}
+
+func TestRunReportsDivergences(t *testing.T) {
+	a, b := newReplica(t, false), newReplica(t, true)
+	catalog := schema.BuildCatalog(compareSchema(), schema.CatalogOptions{MaxDepth: 2})
+	result, err := Run(context.Background(), catalog, a.URL, b.URL, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BaseA != a.URL || result.BaseB != b.URL {
+		t.Errorf("bases = %s, %s", result.BaseA, result.BaseB)
+	}
+
+	want := map[string][]string{
+		"secret":  {DivergenceStatus},
+		"broken":  {DivergenceErrorShape},
+		"slow":    {DivergenceLatency},
+		"missing": {DivergenceStatus},
+		"report":  {DivergenceStatus},
+	}
+	if got := divergences(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("divergences = %v, want %v", got, want)
+	}
+	if result.Divergent != len(want) {
+		t.Errorf("Divergent = %d, want %d", result.Divergent, len(want))
+	}
+
+	outcomes := make(map[string][2]string)
+	for _, op := range result.Operations {
+		outcomes[op.Operation] = [2]string{op.A.Status, op.B.Status}
+	}
+	for op, statuses := range map[string][2]string{
+		"same":    {StatusAccessible, StatusAccessible},
+		"secret":  {StatusAccessible, StatusAuthRequired},
+		"broken":  {StatusExecution, StatusExecution},
+		"missing": {StatusAccessible, StatusValidation},
+		"report":  {StatusAccessible, StatusNotGraphQL},
+		"price":   {StatusAccessible, StatusAccessible},
+	} {
+		if outcomes[op] != statuses {
+			t.Errorf("%s statuses = %v, want %v", op, outcomes[op], statuses)
+		}
+	}
+
+	// Mutations and subscriptions are left out by default; queries go to
+	// both endpoints once.
+	if len(result.Operations) != 9 {
+		t.Errorf("compared %d operations, want the 9 queries", len(result.Operations))
+	}
+	for _, r := range []*replica{a, b} {
+		fields := append([]string(nil), r.fields...)
+		sort.Strings(fields)
+		if want := []string{"broken", "item", "missing", "price", "report", "same", "secret", "slow", "tags"}; !reflect.DeepEqual(fields, want) {
+			t.Errorf("%s received %v, want %v", r.URL, fields, want)
+		}
+	}
+}
+
+func TestRunComparesData(t *testing.T) {
+	a, b := newReplica(t, false), newReplica(t, true)
+	catalog := schema.BuildCatalog(compareSchema(), schema.CatalogOptions{MaxDepth: 2})
+	result, err := Run(context.Background(), catalog, a.URL, b.URL, nil, Options{
+		Data:      true,
+		Mutations: true,
+		Diff:      jsondiff.Options{Ignore: jsondiff.ParsePaths("data.item.updatedAt"), Unordered: jsondiff.ParsePaths("data.tags")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := divergences(result)
+	if !reflect.DeepEqual(got["price"], []string{DivergenceData}) {
+		t.Errorf("price divergences = %v, want the data", got["price"])
+	}
+	if got["tags"] != nil || got["item"] != nil {
+		t.Errorf("divergences = %v, want the unordered and ignored paths left out", got)
+	}
+	for _, op := range result.Operations {
+		if op.Operation == "price" && (!strings.Contains(op.Diff, "10") || !strings.Contains(op.Diff, "12")) {
+			t.Errorf("price diff = %q, want both values", op.Diff)
+		}
+		if op.Kind == schema.KindSubscription {
+			t.Errorf("subscription %s was executed", op.Operation)
+		}
+	}
+	if !contains(a.fields, "reset") || !contains(b.fields, "reset") {
+		t.Error("the mutation was not executed on both endpoints")
+	}
+}
+
+func TestRunWithIdenticalEndpoints(t *testing.T) {
+	a, b := newReplica(t, false), newReplica(t, false)
+	catalog := schema.BuildCatalog(compareSchema(), schema.CatalogOptions{MaxDepth: 2})
+	result, err := Run(context.Background(), catalog, a.URL, b.URL, nil, Options{Data: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Divergent != 0 {
+		t.Errorf("divergences = %v, want none", divergences(result))
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	a, b := newReplica(t, false), newReplica(t, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	catalog := schema.BuildCatalog(compareSchema(), schema.CatalogOptions{MaxDepth: 2})
+	result, err := Run(ctx, catalog, a.URL, b.URL, nil, Options{})
+	if err != context.Canceled || len(result.Operations) != 0 || len(a.fields)+len(b.fields) != 0 {
+		t.Errorf("Run() = %d operations, %v; want none sent and the cancellation", len(result.Operations), err)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
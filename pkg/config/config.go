@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/CyberRoute/graphspecter/pkg/network"
 	"github.com/CyberRoute/graphspecter/pkg/types"
 	"gopkg.in/yaml.v3"
 	"os"
@@ -39,6 +40,9 @@ func LoadConfigFile(path string) (*types.FileConfig, error) {
 		}
 		cfg.Timeout = parsedTimeout
 	}
+	if cfg.Headers, err = network.NormalizeHeaders("config file "+path, cfg.Headers); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
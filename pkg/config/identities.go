@@ -0,0 +1,98 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// identitiesFile is the format of the file of --identities.
+type identitiesFile struct {
+	Identities []types.Identity `yaml:"identities" json:"identities"`
+}
+
+// LoadIdentities reads the identities of the authorization matrix from a
+// .yaml, .yml or .json file, in the order they are listed. Header values may
+// reference environment variables as ${NAME}, so that tokens need not be
+// written to the file; an unset variable is an error.
+func LoadIdentities(path string) ([]types.Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identities: %w", err)
+	}
+
+	var f identitiesFile
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML identities %s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON identities %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported identities format: %s", ext)
+	}
+
+	if err := validateIdentities(f.Identities); err != nil {
+		return nil, fmt.Errorf("invalid identities %s: %w", path, err)
+	}
+	return f.Identities, nil
+}
+
+// validateIdentities checks the names of identities, expands the variables
+// of their headers and normalizes them.
+func validateIdentities(identities []types.Identity) error {
+	if len(identities) < 2 {
+		return errors.New("identities must list at least two identities to compare")
+	}
+	seen := make(map[string]bool, len(identities))
+	for i := range identities {
+		id := &identities[i]
+		id.Name = strings.TrimSpace(id.Name)
+		if id.Name == "" {
+			return fmt.Errorf("identity %d has no name", i+1)
+		}
+		if seen[id.Name] {
+			return fmt.Errorf("identity %s is listed more than once", id.Name)
+		}
+		seen[id.Name] = true
+		for name, value := range id.Headers {
+			var missing []string
+			id.Headers[name] = os.Expand(value, func(v string) string {
+				value, ok := os.LookupEnv(v)
+				if !ok {
+					missing = append(missing, v)
+				}
+				return value
+			})
+			if len(missing) > 0 {
+				return fmt.Errorf("identity %s: header %s uses the unset variable %s", id.Name, name, missing[0])
+			}
+		}
+		headers, err := network.NormalizeHeaders("identity "+id.Name, id.Headers)
+		if err != nil {
+			return err
+		}
+		for name := range headers {
+			if name == "Content-Type" || name == "Content-Length" {
+				return fmt.Errorf("identity %s: header %s cannot be set by an identity", id.Name, name)
+			}
+		}
+		id.Headers = headers
+	}
+	return nil
+}
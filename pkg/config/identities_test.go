@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeIdentities(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadIdentities(t *testing.T) {
+	t.Setenv("GS_TEST_TOKEN", "s3cret")
+	path := writeIdentities(t, "identities.yaml", `identities:
+  - name: anonymous
+  - name: " user "
+    headers:
+      authorization: Bearer ${GS_TEST_TOKEN}
+`)
+	identities, err := LoadIdentities(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 2 || identities[0].Name != "anonymous" || identities[1].Name != "user" {
+		t.Fatalf("identities = %+v", identities)
+	}
+	if got := identities[1].Headers["Authorization"]; got != "Bearer s3cret" {
+		t.Errorf("Authorization = %q, want the expanded and canonical header", got)
+	}
+}
+
+func TestLoadIdentitiesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, file, content, want string
+	}{
+		{"one identity", "i.json", `{"identities":[{"name":"admin"}]}`, "at least two"},
+		{"duplicate", "i.json", `{"identities":[{"name":"a"},{"name":"a"}]}`, "more than once"},
+		{"no name", "i.json", `{"identities":[{"name":"a"},{"name":" "}]}`, "no name"},
+		{"unset variable", "i.yaml", "identities:\n  - name: a\n  - name: b\n    headers:\n      Authorization: ${GS_TEST_UNSET}\n", "unset variable GS_TEST_UNSET"},
+		{"content type", "i.json", `{"identities":[{"name":"a"},{"name":"b","headers":{"content-type":"text/plain"}}]}`, "cannot be set"},
+		{"unknown field", "i.yaml", "identities:\n  - name: a\n    token: x\n  - name: b\n", "field token not found"},
+		{"format", "i.txt", "", "unsupported"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadIdentities(writeIdentities(t, tc.file, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
-	"github.com/CyberRoute/graphspecter/pkg/types"
+	"strings"
 	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
 )
 
 func ApplyFileConfigToCLIConfig(fileCfg *types.FileConfig, cliCfg *types.CLIConfig) {
@@ -30,13 +32,35 @@ func ApplyFileConfigToCLIConfig(fileCfg *types.FileConfig, cliCfg *types.CLIConf
 	if cliCfg.SchemaFile == "" {
 		cliCfg.SchemaFile = fileCfg.SchemaFile
 	}
-	if cliCfg.Headers == nil && len(fileCfg.Headers) > 0 {
-		cliCfg.Headers = make(map[string]string, len(fileCfg.Headers))
-		for k, v := range fileCfg.Headers {
+	// Headers given with -H win over config file headers of the same name.
+	for k, v := range fileCfg.Headers {
+		if cliCfg.Headers == nil {
+			cliCfg.Headers = make(map[string]string, len(fileCfg.Headers))
+		}
+		if !hasHeader(cliCfg.Headers, k) {
 			cliCfg.Headers[k] = v
 		}
 	}
 	if !cliCfg.Detect && fileCfg.Detect {
 		cliCfg.Detect = true
 	}
+	if cliCfg.ClientCert == "" {
+		cliCfg.ClientCert = fileCfg.ClientCert
+	}
+	if cliCfg.ClientKey == "" {
+		cliCfg.ClientKey = fileCfg.ClientKey
+	}
+	if cliCfg.ClientKeyPassword == "" {
+		cliCfg.ClientKeyPassword = fileCfg.ClientKeyPassword
+	}
+}
+
+// hasHeader reports whether headers holds name, compared case-insensitively.
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
 }
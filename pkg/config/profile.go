@@ -0,0 +1,79 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadBountyProfile reads a bug bounty program profile from a .yaml, .yml or
+// .json file. Unknown keys are an error, since a misspelled constraint would
+// otherwise be silently dropped, and so is a profile without allowed hosts.
+func LoadBountyProfile(path string) (*types.BountyProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bounty profile: %w", err)
+	}
+
+	var p types.BountyProfile
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML bounty profile %s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON bounty profile %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported bounty profile format: %s", ext)
+	}
+
+	if err := validateBountyProfile(&p); err != nil {
+		return nil, fmt.Errorf("invalid bounty profile %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// validateBountyProfile checks the limits and hosts of p and lowercases the hosts.
+func validateBountyProfile(p *types.BountyProfile) error {
+	if len(p.AllowedHosts) == 0 {
+		return errors.New("allowed-hosts must list at least one host")
+	}
+	for i, host := range p.AllowedHosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" || host == "*" || strings.Contains(host, "/") || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			return fmt.Errorf("allowed host %q must be a host name or *.domain", p.AllowedHosts[i])
+		}
+		p.AllowedHosts[i] = host
+	}
+	if p.MaxRate < 0 {
+		return errors.New("max-rate cannot be negative")
+	}
+	if p.MaxConcurrency < 0 {
+		return errors.New("max-concurrency cannot be negative")
+	}
+	headers, err := network.NormalizeHeaders("headers", p.Headers)
+	if err != nil {
+		return err
+	}
+	for name := range headers {
+		if name == "Content-Type" || name == "Content-Length" {
+			return fmt.Errorf("header %s cannot be set by a profile", name)
+		}
+	}
+	p.Headers = headers
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadBountyProfile(t *testing.T) {
+	path := writeIdentities(t, "program.yaml", `program: acme
+headers:
+  x-bug-bounty: researcher@example.com
+max-rate: 2
+max-concurrency: 1
+forbidden-checks: [dos]
+allowed-hosts: [API.acme.example, " *.staging.acme.example "]
+`)
+	p, err := LoadBountyProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Program != "acme" || p.MaxRate != 2 || p.MaxConcurrency != 1 || !reflect.DeepEqual(p.ForbiddenChecks, []string{"dos"}) {
+		t.Errorf("profile = %+v", p)
+	}
+	if want := []string{"api.acme.example", "*.staging.acme.example"}; !reflect.DeepEqual(p.AllowedHosts, want) {
+		t.Errorf("allowed hosts = %v, want %v", p.AllowedHosts, want)
+	}
+	if want := map[string]string{"X-Bug-Bounty": "researcher@example.com"}; !reflect.DeepEqual(p.Headers, want) {
+		t.Errorf("headers = %v, want %v", p.Headers, want)
+	}
+
+	json := writeIdentities(t, "program.json", `{"program":"acme","maxRate":1,"allowedHosts":["api.acme.example"]}`)
+	if p, err := LoadBountyProfile(json); err != nil || p.MaxRate != 1 {
+		t.Errorf("JSON profile = %+v, %v", p, err)
+	}
+}
+
+func TestLoadBountyProfileErrors(t *testing.T) {
+	tests := []struct {
+		name, file, content, want string
+	}{
+		{"unknown key", "p.yaml", "allowed-hosts: [a.example]\nmax-rps: 2\n", "field max-rps not found"},
+		{"unknown JSON key", "p.json", `{"allowedHosts":["a.example"],"maxRps":2}`, `unknown field "maxRps"`},
+		{"no hosts", "p.yaml", "program: acme\n", "allowed-hosts must list at least one host"},
+		{"every host", "p.yaml", "allowed-hosts: ['*']\n", `allowed host "*" must be a host name or *.domain`},
+		{"url", "p.yaml", "allowed-hosts: [https://a.example/]\n", "must be a host name"},
+		{"inner wildcard", "p.yaml", "allowed-hosts: ['a.*.example']\n", "must be a host name"},
+		{"negative rate", "p.yaml", "allowed-hosts: [a.example]\nmax-rate: -1\n", "max-rate cannot be negative"},
+		{"negative concurrency", "p.yaml", "allowed-hosts: [a.example]\nmax-concurrency: -1\n", "max-concurrency cannot be negative"},
+		{"content type", "p.yaml", "allowed-hosts: [a.example]\nheaders: {content-type: text/plain}\n", "header Content-Type cannot be set by a profile"},
+		{"format", "p.toml", "", "unsupported bounty profile format: .toml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadBountyProfile(writeIdentities(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
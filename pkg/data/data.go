@@ -0,0 +1,278 @@
+// Package data holds the detection paths, engine signatures and schema heuristics
+// shipped with the binary as embedded JSON datasets, and loads overrides from a
+// directory given with --data-dir
+package data
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// formatVersion is the version of the dataset document format.
+const formatVersion = 1
+
+// Merge modes of an override document
+const (
+	// ModeReplace discards the embedded entries. It is the default.
+	ModeReplace = "replace"
+	// ModeAppend adds the override entries to the embedded ones. Entries with
+	// the key of an embedded entry replace it in place.
+	ModeAppend = "append"
+)
+
+//go:embed datasets/*.json
+var embedded embed.FS
+
+// Document is the format of embedded datasets and of their overrides.
+type Document[T any] struct {
+	Version int    `json:"version"`
+	Mode    string `json:"mode,omitempty"`
+	Entries []T    `json:"entries"`
+}
+
+// dataset is the part of a typed dataset used by the loader.
+type dataset interface {
+	override(content []byte) error
+	reset()
+	document() interface{}
+}
+
+// set is a dataset of entries of type T.
+type set[T any] struct {
+	name string
+	// key identifies entries for ModeAppend; nil means entries are never merged.
+	key func(T) string
+	// validate checks an entry and fills in its derived fields.
+	validate func(*T) error
+
+	mu      sync.RWMutex
+	entries []T
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]dataset{}
+)
+
+// register adds a dataset loaded from datasets/<name>.json.
+func register[T any](s *set[T]) *set[T] {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[s.name]; exists {
+		panic(fmt.Sprintf("data: duplicate dataset %q", s.name))
+	}
+	registry[s.name] = s
+	s.reset()
+	return s
+}
+
+// reset restores the embedded entries.
+func (s *set[T]) reset() {
+	content, err := embedded.ReadFile("datasets/" + s.name + ".json")
+	if err != nil {
+		panic("data: missing embedded dataset " + s.name)
+	}
+	doc, err := s.parse(content)
+	if err != nil {
+		panic(fmt.Sprintf("data: invalid embedded dataset %s: %v", s.name, err))
+	}
+	s.mu.Lock()
+	s.entries = doc.Entries
+	s.mu.Unlock()
+}
+
+// parse decodes and validates a dataset document.
+func (s *set[T]) parse(content []byte) (*Document[T], error) {
+	var doc Document[T]
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, describeJSONError(content, err)
+	}
+	if doc.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported version %d (expected %d)", doc.Version, formatVersion)
+	}
+	switch doc.Mode {
+	case "":
+		doc.Mode = ModeReplace
+	case ModeReplace, ModeAppend:
+	default:
+		return nil, fmt.Errorf("unknown mode %q (valid: '%s', '%s')", doc.Mode, ModeReplace, ModeAppend)
+	}
+	for i := range doc.Entries {
+		if err := s.validate(&doc.Entries[i]); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+	}
+	return &doc, nil
+}
+
+// override merges an override document into the entries.
+func (s *set[T]) override(content []byte) error {
+	doc, err := s.parse(content)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if doc.Mode == ModeReplace {
+		s.entries = doc.Entries
+		return nil
+	}
+	merged := append([]T(nil), s.entries...)
+	index := make(map[string]int, len(merged))
+	if s.key != nil {
+		for i, e := range merged {
+			index[s.key(e)] = i
+		}
+	}
+	for _, e := range doc.Entries {
+		if s.key != nil {
+			if i, ok := index[s.key(e)]; ok {
+				merged[i] = e
+				continue
+			}
+			index[s.key(e)] = len(merged)
+		}
+		merged = append(merged, e)
+	}
+	s.entries = merged
+	return nil
+}
+
+// get returns the effective entries.
+func (s *set[T]) get() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.entries
+}
+
+// document returns the effective entries as a dataset document.
+func (s *set[T]) document() interface{} {
+	return Document[T]{Version: formatVersion, Entries: s.get()}
+}
+
+// describeJSONError adds the line and column of syntax and type errors, those
+// of the byte the decoder stopped at.
+func describeJSONError(content []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+	// The decoder stops after the offending byte; report its position.
+	if offset > 0 {
+		offset--
+	}
+	line, col := 1, 1
+	for _, b := range content[:offset] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Errorf("line %d, column %d: %w", line, col, err)
+}
+
+// Names returns the names of the datasets, sorted.
+func Names() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadDir applies the overrides in dir, one <dataset>.json file per dataset.
+// Datasets without a file keep their embedded entries. Files that do not name
+// a dataset are an error, so that misspelt overrides are not silently ignored.
+func LoadDir(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("data directory: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		ds, ok := registry[name]
+		if !ok {
+			known := make([]string, 0, len(registry))
+			for n := range registry {
+				known = append(known, n+".json")
+			}
+			sort.Strings(known)
+			return fmt.Errorf("%s: unknown dataset %q (known: %s)", file, name, strings.Join(known, ", "))
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := ds.override(content); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// LoadFile applies the override document in file to the named dataset.
+func LoadFile(name, file string) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	ds, ok := registry[name]
+	if !ok {
+		return fmt.Errorf("unknown dataset %q", name)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := ds.override(content); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// Reset restores the embedded entries of every dataset.
+func Reset() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, ds := range registry {
+		ds.reset()
+	}
+}
+
+// Show returns the effective entries of the named dataset as an indented
+// dataset document.
+func Show(name string) ([]byte, error) {
+	registryMu.Lock()
+	ds, ok := registry[name]
+	registryMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown dataset %q (known: %s)", name, strings.Join(Names(), ", "))
+	}
+	return json.MarshalIndent(ds.document(), "", "  ")
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// overrides writes each file of files, a map of names to contents, to a new
+// directory. The embedded entries are restored when the test ends.
+func overrides(t *testing.T, files map[string]string) string {
+	t.Helper()
+	t.Cleanup(Reset)
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestEmbeddedDatasets(t *testing.T) {
+	want := []string{"engines", "error-patterns", "ides", "paths", "query-policies", "sensitive-fields"}
+	if got := Names(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Names() = %v, want %v", got, want)
+	}
+	for _, name := range want {
+		out, err := Show(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc Document[json.RawMessage]
+		if err := json.Unmarshal(out, &doc); err != nil || doc.Version != formatVersion || len(doc.Entries) == 0 {
+			t.Errorf("Show(%s) = %d entries, version %d, %v", name, len(doc.Entries), doc.Version, err)
+		}
+	}
+	if len(Paths()) == 0 || Paths()[0] != "/" || len(Engines()) == 0 || len(IDEs()) == 0 {
+		t.Error("the embedded datasets are empty")
+	}
+	// Validation fills in the derived fields of embedded entries too.
+	for _, e := range Engines() {
+		for _, x := range e.Versions {
+			if x.Where == "" || (x.Kind != ExtractJSON && x.Pattern() == nil) {
+				t.Errorf("%s extractor %+v was not validated", e.Name, x)
+			}
+		}
+	}
+	if _, err := Show("signatures"); err == nil || !strings.Contains(err.Error(), "known: engines") {
+		t.Errorf("Show() of an unknown dataset = %v", err)
+	}
+}
+
+func TestOverrideReplace(t *testing.T) {
+	embedded := Engines()
+	dir := overrides(t, map[string]string{
+		"paths.json": `{"version": 1, "entries": ["/internal/graphql", "/gql"]}`,
+		// The mode defaults to replace.
+		"sensitive-fields.json": `{"version": 1, "mode": "replace", "entries": ["Api_Key", "ssn"]}`,
+	})
+	if err := LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := Paths(); !reflect.DeepEqual(got, []string{"/internal/graphql", "/gql"}) {
+		t.Errorf("Paths() = %v", got)
+	}
+	if got := SensitiveFields(); !reflect.DeepEqual(got, []string{"apikey", "ssn"}) {
+		t.Errorf("SensitiveFields() = %v, want the fragments normalized", got)
+	}
+	if !reflect.DeepEqual(Engines(), embedded) {
+		t.Error("a dataset without an override file changed")
+	}
+	out, _ := Show("paths")
+	var doc Document[string]
+	if err := json.Unmarshal(out, &doc); err != nil || !reflect.DeepEqual(doc.Entries, Paths()) {
+		t.Errorf("Show(paths) = %s, want the effective entries", out)
+	}
+
+	Reset()
+	if got := Paths(); len(got) < 3 || got[0] != "/" {
+		t.Errorf("after Reset, Paths() = %v", got)
+	}
+}
+
+func TestOverrideAppend(t *testing.T) {
+	embeddedPaths, embeddedEngines := Paths(), Engines()
+	first := embeddedEngines[0].Name
+	dir := overrides(t, map[string]string{
+		"paths.json": `{"version": 1, "mode": "append", "entries": ["/graphql", "/internal/graphql"]}`,
+		"engines.json": `{"version": 1, "mode": "append", "entries": [
+			{"name": "Homegrown", "signatures": [{"probe": "{ __typename }", "weight": 1, "match": "typename", "values": ["RootQuery"]}]},
+			{"name": "` + first + `", "signatures": [{"probe": "{ ping }", "weight": 0.5, "match": "raw", "values": ["pong"]}]}
+		]}`,
+		"error-patterns.json": `{"version": 1, "mode": "append", "entries": [{"class": "auth", "language": "nl", "match": "message", "values": ["Niet Ingelogd"]}]}`,
+	})
+	if err := LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries with the key of an embedded one replace it in place; the others
+	// follow the embedded entries.
+	if got := Paths(); !reflect.DeepEqual(got, append(append([]string(nil), embeddedPaths...), "/internal/graphql")) {
+		t.Errorf("Paths() = %v", got)
+	}
+	engines := Engines()
+	if len(engines) != len(embeddedEngines)+1 || engines[len(engines)-1].Name != "Homegrown" {
+		t.Fatalf("Engines() = %d engines, want the embedded ones and Homegrown", len(engines))
+	}
+	if engines[0].Name != first || len(engines[0].Signatures) != 1 || engines[0].Signatures[0].Probe != "{ ping }" {
+		t.Errorf("%s = %+v, want the override in its place", first, engines[0])
+	}
+	if !reflect.DeepEqual(engines[1:len(embeddedEngines)], embeddedEngines[1:]) {
+		t.Error("the other embedded engines changed")
+	}
+	patterns := ErrorPatterns()
+	if last := patterns[len(patterns)-1]; last.Language != "nl" || last.Values[0] != "niet ingelogd" {
+		t.Errorf("last error pattern = %+v, want the lowercased override", last)
+	}
+
+	// Overrides apply on top of the effective entries.
+	file := filepath.Join(dir, "more-paths.json")
+	if err := os.WriteFile(file, []byte(`{"version": 1, "mode": "append", "entries": ["/v9/graphql"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFile("paths", file); err != nil {
+		t.Fatal(err)
+	}
+	if got := Paths(); got[len(got)-1] != "/v9/graphql" || got[len(got)-2] != "/internal/graphql" {
+		t.Errorf("Paths() = %v", got)
+	}
+}
+
+func TestOverrideValidation(t *testing.T) {
+	signature := `{"probe": "{ __typename }", "weight": 0.5, "match": "raw", "values": ["x"]}`
+	tests := []struct {
+		name, file, content, want string
+	}{
+		{"syntax error", "paths.json", "{\n  \"version\": 1,\n  \"entries\": [\"/a\",]\n}", "line 3, column 20"},
+		{"wrong type", "paths.json", `{"version": 1, "entries": [1]}`, "line 1, column 28"},
+		{"unknown field", "paths.json", `{"version": 1, "entreis": ["/a"]}`, `unknown field "entreis"`},
+		{"missing version", "paths.json", `{"entries": ["/a"]}`, "unsupported version 0 (expected 1)"},
+		{"future version", "paths.json", `{"version": 2, "entries": ["/a"]}`, "unsupported version 2"},
+		{"unknown mode", "paths.json", `{"version": 1, "mode": "merge", "entries": ["/a"]}`, `unknown mode "merge"`},
+		{"relative path", "paths.json", `{"version": 1, "entries": ["/a", "graphql"]}`, `entry 1: path "graphql" must start with /`},
+		{"unnamed engine", "engines.json", `{"version": 1, "entries": [{"signatures": [` + signature + `]}]}`, "engine needs a name"},
+		{"engine without signatures", "engines.json", `{"version": 1, "entries": [{"name": "X", "signatures": []}]}`, "engine X needs at least one signature"},
+		{"weight out of range", "engines.json", `{"version": 1, "entries": [{"name": "X", "signatures": [{"probe": "{ a }", "weight": 2, "match": "raw", "values": ["x"]}]}]}`, "weight 2 must be in (0, 1]"},
+		{"unknown match", "engines.json", `{"version": 1, "entries": [{"name": "X", "signatures": [{"probe": "{ a }", "weight": 1, "match": "regex", "values": ["x"]}]}]}`, `unknown match "regex"`},
+		{"unknown error class", "engines.json", `{"version": 1, "entries": [{"name": "X", "signatures": [{"probe": "{ a }", "weight": 1, "match": "class", "values": ["teapot"]}]}]}`, `unknown error class "teapot"`},
+		{"regex without group", "engines.json", `{"version": 1, "entries": [{"name": "X", "signatures": [` + signature + `], "versions": [{"kind": "body", "regex": "v[0-9.]+"}]}]}`, "has no capturing group"},
+		{"invalid regex", "ides.json", `{"version": 1, "entries": [{"name": "X", "versions": [{"kind": "body", "regex": "v([0-9.]+"}]}]}`, "X version extractor 0: error parsing regexp"},
+		{"header extractor without header", "ides.json", `{"version": 1, "entries": [{"name": "X", "versions": [{"kind": "header", "regex": "(.+)"}]}]}`, "needs a header"},
+		{"unknown posture", "query-policies.json", `{"version": 1, "entries": [{"vendor": "X", "posture": "open", "match": "code", "values": ["x"]}]}`, `unknown posture "open"`},
+		{"empty sensitive fragment", "sensitive-fields.json", `{"version": 1, "entries": ["_-"]}`, "sensitive field fragment is empty"},
+		{"empty error value", "error-patterns.json", `{"version": 1, "entries": [{"class": "auth", "match": "message", "values": [" "]}]}`, "error pattern auth has an empty value"},
+		{"unknown dataset", "path.json", `{"version": 1, "entries": ["/a"]}`, `unknown dataset "path" (known: engines.json, error-patterns.json`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedded := map[string]interface{}{"paths": Paths(), "engines": Engines(), "ides": IDEs()}
+			dir := overrides(t, map[string]string{tt.file: tt.content})
+			err := LoadDir(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), tt.file) {
+				t.Fatalf("LoadDir() = %v, want an error naming %s and holding %q", err, tt.file, tt.want)
+			}
+			// A rejected override leaves the embedded entries in effect.
+			if got := map[string]interface{}{"paths": Paths(), "engines": Engines(), "ides": IDEs()}; !reflect.DeepEqual(got, embedded) {
+				t.Error("a rejected override changed the entries")
+			}
+		})
+	}
+
+	if err := LoadDir(filepath.Join(t.TempDir(), "missing")); err == nil || !strings.Contains(err.Error(), "data directory") {
+		t.Errorf("LoadDir() of a missing directory = %v", err)
+	}
+	if err := LoadFile("signatures", "signatures.json"); err == nil || !strings.Contains(err.Error(), `unknown dataset "signatures"`) {
+		t.Errorf("LoadFile() of an unknown dataset = %v", err)
+	}
+}
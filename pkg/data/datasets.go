@@ -0,0 +1,334 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Signature match kinds
+const (
+	// MatchMessage matches when an error message contains one of the values.
+	MatchMessage = "message"
+	// MatchCode matches when an error carries one of the values as extensions.code.
+	MatchCode = "code"
+	// MatchTypename matches when data.__typename equals one of the values.
+	MatchTypename = "typename"
+	// MatchRaw matches when the encoded response contains one of the values.
+	MatchRaw = "raw"
+	// MatchClass matches when an error falls in one of the values, classes of
+	// the error-patterns dataset.
+	MatchClass = "class"
+)
+
+// Error classes assigned by the error-patterns dataset
+const (
+	// ErrorAuth marks errors refusing unauthenticated or unauthorized callers.
+	ErrorAuth = "auth"
+	// ErrorValidation marks documents rejected before execution.
+	ErrorValidation = "validation"
+	// ErrorRateLimit marks throttled requests.
+	ErrorRateLimit = "rate-limit"
+	// ErrorSuggestion marks errors suggesting field, argument or type names.
+	ErrorSuggestion = "suggestion"
+)
+
+// errorClasses lists the error classes in the order they are reported.
+var errorClasses = []string{ErrorAuth, ErrorValidation, ErrorRateLimit, ErrorSuggestion}
+
+// Query postures recognised by query policy rules
+const (
+	// PostureAllowlist means the server only executes persisted or allow-listed operations.
+	PostureAllowlist = "allowlist"
+	// PostureAuth means the server rejects unauthenticated queries.
+	PostureAuth = "auth"
+)
+
+// Version extractor kinds
+const (
+	// ExtractBody applies Regex to the body of a GET request to the endpoint.
+	ExtractBody = "body"
+	// ExtractHeader applies Regex to the Header response header of the endpoint.
+	ExtractHeader = "header"
+	// ExtractJSON reads the string Field of the JSON document served at Path
+	// on the host of the endpoint.
+	ExtractJSON = "json"
+)
+
+// Signature is one indicator of an engine in the response to a probe query.
+type Signature struct {
+	// Probe is the query sent; each distinct probe is sent once per detection.
+	Probe string `json:"probe"`
+	// Weight is added to the confidence of the engine when the signature matches.
+	Weight   float64  `json:"weight"`
+	Evidence string   `json:"evidence"`
+	Match    string   `json:"match"`
+	Values   []string `json:"values"`
+}
+
+// Extractor finds a version number. Regex must have a capturing group holding
+// the version.
+type Extractor struct {
+	Kind   string `json:"kind"`
+	Regex  string `json:"regex,omitempty"`
+	Header string `json:"header,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Field  string `json:"field,omitempty"`
+	// Where describes the source of the version in evidence; it defaults to
+	// the header name or path.
+	Where string `json:"where,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Pattern returns the compiled Regex, nil for ExtractJSON.
+func (e Extractor) Pattern() *regexp.Regexp {
+	return e.re
+}
+
+// QueryPolicy recognises the rejection of an arbitrary query. Message values
+// match case-insensitively anywhere in an error message; code values must equal
+// extensions.code.
+type QueryPolicy struct {
+	Vendor  string   `json:"vendor"`
+	Posture string   `json:"posture"`
+	Match   string   `json:"match"`
+	Values  []string `json:"values"`
+}
+
+// ErrorPattern assigns GraphQL errors to a class. Code values equal
+// extensions.code, ignoring case; message values match case-insensitively
+// anywhere in an error message.
+type ErrorPattern struct {
+	Class string `json:"class"`
+	// Language is the language of message values, as an ISO 639-1 code.
+	Language string   `json:"language,omitempty"`
+	Match    string   `json:"match"`
+	Values   []string `json:"values"`
+}
+
+// Engine is a GraphQL server implementation recognised by fingerprinting.
+type Engine struct {
+	Name       string      `json:"name"`
+	Signatures []Signature `json:"signatures"`
+	// Versions are tried in order until one finds the version of the engine.
+	Versions []Extractor `json:"versions,omitempty"`
+}
+
+// IDE is a GraphQL IDE served alongside an endpoint. It is reported when one
+// of its extractors finds a version.
+type IDE struct {
+	Name     string      `json:"name"`
+	Versions []Extractor `json:"versions"`
+}
+
+var (
+	paths = register(&set[string]{
+		name:     "paths",
+		key:      func(p string) string { return p },
+		validate: validatePath,
+	})
+	engines = register(&set[Engine]{
+		name:     "engines",
+		key:      func(e Engine) string { return e.Name },
+		validate: validateEngine,
+	})
+	ides = register(&set[IDE]{
+		name:     "ides",
+		key:      func(i IDE) string { return i.Name },
+		validate: validateIDE,
+	})
+	queryPolicies = register(&set[QueryPolicy]{
+		name:     "query-policies",
+		key:      func(q QueryPolicy) string { return q.Vendor + "/" + q.Posture + "/" + q.Match },
+		validate: validateQueryPolicy,
+	})
+	sensitiveFields = register(&set[string]{
+		name:     "sensitive-fields",
+		key:      func(f string) string { return f },
+		validate: validateSensitiveField,
+	})
+	errorPatterns = register(&set[ErrorPattern]{
+		name:     "error-patterns",
+		key:      func(p ErrorPattern) string { return p.Class + "/" + p.Language + "/" + p.Match },
+		validate: validateErrorPattern,
+	})
+)
+
+// Paths returns the paths probed for GraphQL endpoints during detection.
+func Paths() []string { return paths.get() }
+
+// Engines returns the engines recognised by fingerprinting.
+func Engines() []Engine { return engines.get() }
+
+// IDEs returns the GraphQL IDEs whose versions are detected.
+func IDEs() []IDE { return ides.get() }
+
+// QueryPolicies returns the rules classifying rejected arbitrary queries.
+func QueryPolicies() []QueryPolicy { return queryPolicies.get() }
+
+// SensitiveFields returns the name fragments that identify secrets or personal
+// data. They are lowercase, without underscores or dashes.
+func SensitiveFields() []string { return sensitiveFields.get() }
+
+// ErrorPatterns returns the codes and phrases classifying GraphQL errors.
+// Message values are lowercase.
+func ErrorPatterns() []ErrorPattern { return errorPatterns.get() }
+
+// ErrorClasses returns the classes errors can be assigned to.
+func ErrorClasses() []string { return errorClasses }
+
+func validatePath(p *string) error {
+	if !strings.HasPrefix(*p, "/") {
+		return fmt.Errorf("path %q must start with /", *p)
+	}
+	if _, err := url.Parse(*p); err != nil {
+		return fmt.Errorf("path %q: %w", *p, err)
+	}
+	return nil
+}
+
+func validateEngine(e *Engine) error {
+	if e.Name == "" {
+		return errors.New("engine needs a name")
+	}
+	if len(e.Signatures) == 0 {
+		return fmt.Errorf("engine %s needs at least one signature", e.Name)
+	}
+	for i, s := range e.Signatures {
+		if strings.TrimSpace(s.Probe) == "" {
+			return fmt.Errorf("engine %s signature %d needs a probe", e.Name, i)
+		}
+		if s.Weight <= 0 || s.Weight > 1 {
+			return fmt.Errorf("engine %s signature %d: weight %v must be in (0, 1]", e.Name, i, s.Weight)
+		}
+		switch s.Match {
+		case MatchMessage, MatchCode, MatchTypename, MatchRaw:
+		case MatchClass:
+			for _, v := range s.Values {
+				if !validErrorClass(v) {
+					return fmt.Errorf("engine %s signature %d: unknown error class %q (valid: %s)", e.Name, i, v, strings.Join(errorClasses, ", "))
+				}
+			}
+		default:
+			return fmt.Errorf("engine %s signature %d: unknown match %q (valid: '%s', '%s', '%s', '%s', '%s')",
+				e.Name, i, s.Match, MatchMessage, MatchCode, MatchTypename, MatchRaw, MatchClass)
+		}
+		if len(s.Values) == 0 {
+			return fmt.Errorf("engine %s signature %d needs at least one value", e.Name, i)
+		}
+	}
+	return validateExtractors(e.Name, e.Versions)
+}
+
+func validateIDE(i *IDE) error {
+	if i.Name == "" {
+		return errors.New("IDE needs a name")
+	}
+	if len(i.Versions) == 0 {
+		return fmt.Errorf("IDE %s needs at least one version extractor", i.Name)
+	}
+	return validateExtractors(i.Name, i.Versions)
+}
+
+// validateExtractors checks each extractor of name and compiles its regex.
+func validateExtractors(name string, extractors []Extractor) error {
+	for i := range extractors {
+		e := &extractors[i]
+		switch e.Kind {
+		case ExtractBody, ExtractHeader:
+			if e.Kind == ExtractHeader && e.Header == "" {
+				return fmt.Errorf("%s version extractor %d needs a header", name, i)
+			}
+			re, err := regexp.Compile(e.Regex)
+			if err != nil {
+				return fmt.Errorf("%s version extractor %d: %w", name, i, err)
+			}
+			if re.NumSubexp() == 0 {
+				return fmt.Errorf("%s version extractor %d: regex %q has no capturing group", name, i, e.Regex)
+			}
+			e.re = re
+			if e.Where == "" && e.Kind == ExtractHeader {
+				e.Where = e.Header + " header"
+			}
+		case ExtractJSON:
+			if !strings.HasPrefix(e.Path, "/") || e.Field == "" {
+				return fmt.Errorf("%s version extractor %d needs a path starting with / and a field", name, i)
+			}
+			if e.Where == "" {
+				e.Where = e.Path
+			}
+		default:
+			return fmt.Errorf("%s version extractor %d: unknown kind %q (valid: '%s', '%s', '%s')",
+				name, i, e.Kind, ExtractBody, ExtractHeader, ExtractJSON)
+		}
+		if e.Where == "" {
+			e.Where = "landing page"
+		}
+	}
+	return nil
+}
+
+func validateQueryPolicy(q *QueryPolicy) error {
+	if q.Vendor == "" {
+		return errors.New("query policy needs a vendor")
+	}
+	switch q.Posture {
+	case PostureAllowlist, PostureAuth:
+	default:
+		return fmt.Errorf("query policy %s: unknown posture %q (valid: '%s', '%s')", q.Vendor, q.Posture, PostureAllowlist, PostureAuth)
+	}
+	switch q.Match {
+	case MatchMessage, MatchCode:
+	default:
+		return fmt.Errorf("query policy %s: unknown match %q (valid: '%s', '%s')", q.Vendor, q.Match, MatchMessage, MatchCode)
+	}
+	if len(q.Values) == 0 {
+		return fmt.Errorf("query policy %s needs at least one value", q.Vendor)
+	}
+	return nil
+}
+
+func validateSensitiveField(f *string) error {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(*f))
+	if normalized == "" {
+		return errors.New("sensitive field fragment is empty")
+	}
+	*f = normalized
+	return nil
+}
+
+func validateErrorPattern(p *ErrorPattern) error {
+	if !validErrorClass(p.Class) {
+		return fmt.Errorf("error pattern: unknown class %q (valid: %s)", p.Class, strings.Join(errorClasses, ", "))
+	}
+	switch p.Match {
+	case MatchCode:
+	case MatchMessage:
+		for i, v := range p.Values {
+			p.Values[i] = strings.ToLower(v)
+		}
+	default:
+		return fmt.Errorf("error pattern %s: unknown match %q (valid: '%s', '%s')", p.Class, p.Match, MatchMessage, MatchCode)
+	}
+	if len(p.Values) == 0 {
+		return fmt.Errorf("error pattern %s needs at least one value", p.Class)
+	}
+	for _, v := range p.Values {
+		if strings.TrimSpace(v) == "" {
+			return fmt.Errorf("error pattern %s has an empty value", p.Class)
+		}
+	}
+	return nil
+}
+
+func validErrorClass(class string) bool {
+	for _, c := range errorClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
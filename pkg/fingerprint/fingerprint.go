@@ -0,0 +1,213 @@
+// Package fingerprint identifies the GraphQL server implementations behind an endpoint
+package fingerprint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// EngineMatch is an implementation whose signatures were found in the responses of an endpoint.
+type EngineMatch struct {
+	Engine string `json:"engine"`
+	// Version is empty unless a response gave it away.
+	Version string `json:"version,omitempty"`
+	// Confidence is the combined weight of the matched signatures, between 0 and 1.
+	Confidence float64  `json:"confidence"`
+	Evidence   []string `json:"evidence"`
+}
+
+// String formats the match for logs and report evidence.
+func (m EngineMatch) String() string {
+	name := m.Engine
+	if m.Version != "" {
+		name += " " + m.Version
+	}
+	return fmt.Sprintf("%s (%.0f%%: %s)", name, m.Confidence*100, strings.Join(m.Evidence, ", "))
+}
+
+// probeTypename is sent first; a transport error on it means the endpoint cannot
+// be fingerprinted. Each probe is sent at most once per detection however many
+// signatures inspect its response.
+const probeTypename = `query { __typename }`
+
+// signatureMatches reports whether the response to the probe of sig shows the signature.
+func signatureMatches(sig data.Signature, r *probeResponse) bool {
+	for _, v := range sig.Values {
+		switch sig.Match {
+		case data.MatchMessage:
+			for _, m := range r.messages {
+				if strings.Contains(m, v) {
+					return true
+				}
+			}
+		case data.MatchCode:
+			for _, c := range r.codes {
+				if c == v {
+					return true
+				}
+			}
+		case data.MatchTypename:
+			if r.typename == v {
+				return true
+			}
+		case data.MatchRaw:
+			if strings.Contains(r.raw, v) {
+				return true
+			}
+		case data.MatchClass:
+			if r.classes[v] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// probeResponse is the part of a probe response inspected by signatures.
+type probeResponse struct {
+	messages []string
+	codes    []string
+	typename string
+	raw      string
+	// classes are the error-patterns classes of the errors.
+	classes map[string]bool
+	err     error
+}
+
+// prober sends each probe once and shares the response between detectors.
+type prober struct {
+	ctx     context.Context
+	url     string
+	headers map[string]string
+
+	mu      sync.Mutex
+	results map[string]*probeCall
+}
+
+type probeCall struct {
+	once sync.Once
+	resp *probeResponse
+}
+
+func (p *prober) get(query string) *probeResponse {
+	p.mu.Lock()
+	call, ok := p.results[query]
+	if !ok {
+		call = &probeCall{}
+		p.results[query] = call
+	}
+	p.mu.Unlock()
+
+	call.once.Do(func() {
+		call.resp = p.send(query)
+	})
+	return call.resp
+}
+
+func (p *prober) send(query string) *probeResponse {
+	resp, err := network.SendGraphQLRequestWithContext(p.ctx, p.url, query, nil, p.headers)
+	if err != nil {
+		logger.Debug("→ Fingerprint probe %q on %s failed: %v", query, p.url, err)
+		return &probeResponse{err: err}
+	}
+
+	r := &probeResponse{}
+	raw, _ := json.Marshal(resp)
+	r.raw = string(raw)
+	if data, ok := resp["data"].(map[string]interface{}); ok {
+		r.typename, _ = data["__typename"].(string)
+	}
+	errs, _ := resp["errors"].([]interface{})
+	r.classes = gql.ClassifyErrors(errs)
+	for _, e := range errs {
+		m, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if msg, ok := m["message"].(string); ok {
+			r.messages = append(r.messages, msg)
+		}
+		if ext, ok := m["extensions"].(map[string]interface{}); ok {
+			if code, ok := ext["code"].(string); ok {
+				r.codes = append(r.codes, code)
+			}
+		}
+	}
+	return r
+}
+
+// DetectEngine returns the most likely engine behind url, or nil when none matched.
+// This is a backward compatibility wrapper for the context-aware version.
+func DetectEngine(url string, headers map[string]string) (*EngineMatch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), network.DefaultTimeout)
+	defer cancel()
+	matches, err := DetectEngineWithContext(ctx, url, headers)
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+	return &matches[0], nil
+}
+
+// DetectEngineWithContext runs the signatures of every engine in the engines
+// dataset against url and returns all the engines with at least one matching
+// signature, most confident first. Gateways in front of a server usually make
+// several engines match. The version of a match is filled in when its landing
+// page, headers or version endpoint show it.
+func DetectEngineWithContext(ctx context.Context, url string, headers map[string]string) ([]EngineMatch, error) {
+	p := &prober{ctx: ctx, url: url, headers: headers, results: make(map[string]*probeCall)}
+	if baseline := p.get(probeTypename); baseline.err != nil {
+		return nil, fmt.Errorf("fingerprinting %s: %w", url, baseline.err)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		matches []EngineMatch
+	)
+	for _, e := range data.Engines() {
+		wg.Add(1)
+		go func(e data.Engine) {
+			defer wg.Done()
+			match := EngineMatch{Engine: e.Name}
+			for _, sig := range e.Signatures {
+				if ctx.Err() != nil {
+					return
+				}
+				if r := p.get(sig.Probe); r.err == nil && signatureMatches(sig, r) {
+					match.Confidence += sig.Weight
+					match.Evidence = append(match.Evidence, sig.Evidence)
+				}
+			}
+			if match.Confidence == 0 {
+				return
+			}
+			if match.Confidence > 1 {
+				match.Confidence = 1
+			}
+			mu.Lock()
+			matches = append(matches, match)
+			mu.Unlock()
+		}(e)
+	}
+	wg.Wait()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Confidence != matches[j].Confidence {
+			return matches[i].Confidence > matches[j].Confidence
+		}
+		return matches[i].Engine < matches[j].Engine
+	})
+	if ctx.Err() == nil {
+		addVersions(newPageProber(ctx, headers), url, matches)
+	}
+	return matches, ctx.Err()
+}
@@ -0,0 +1,138 @@
+package fingerprint
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// routerOverHasura is a mock of an Apollo Router in front of Hasura: the query
+// root, the error paths and the version document are Hasura's, the syntax
+// errors, the validation messages and the Server header the Router's. It
+// counts the GraphQL probes and the pages it serves.
+type routerOverHasura struct {
+	*httptest.Server
+	mu     sync.Mutex
+	probes map[string]int
+	pages  int
+}
+
+func newRouterOverHasura(t *testing.T) *routerOverHasura {
+	t.Helper()
+	m := &routerOverHasura{probes: make(map[string]int)}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "apollo-router/1.45.0")
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			m.mu.Lock()
+			m.pages++
+			m.mu.Unlock()
+			if r.URL.Path == "/v1/version" {
+				w.Write([]byte(`{"version":"v2.36.0"}`))
+				return
+			}
+			http.Error(w, "GET query missing", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		m.mu.Lock()
+		m.probes[req.Query]++
+		m.mu.Unlock()
+		switch {
+		case req.Query == probeTypename:
+			w.Write([]byte(`{"data":{"__typename":"query_root"}}`))
+		case strings.HasPrefix(req.Query, "queryy"):
+			w.Write([]byte(`{"errors":[{"message":"parsing error: syntax error: expected definition","extensions":{"code":"PARSING_ERROR"}}]}`))
+		case strings.Contains(req.Query, "@skip"):
+			w.Write([]byte(`{"errors":[{"message":"missing argument if for directive skip","extensions":{"path":"$.selectionSet[0]"}}]}`))
+		default:
+			w.Write([]byte(`{"errors":[{"message":"unexpected"}]}`))
+		}
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestDetectEngineReportsEveryMatch(t *testing.T) {
+	m := newRouterOverHasura(t)
+	matches, err := DetectEngineWithContext(context.Background(), m.URL+"/graphql", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EngineMatch{
+		{Engine: "Apollo Router", Version: "1.45.0", Confidence: 1, Evidence: []string{
+			"syntax errors carry code PARSING_ERROR",
+			`syntax errors start with "parsing error"`,
+			`@skip without "if" is reported with a Router validation message`,
+			"version 1.45.0 from Server header",
+		}},
+		{Engine: "Hasura", Version: "v2.36.0", Confidence: 0.8, Evidence: []string{
+			`the query root is named "query_root"`,
+			`errors carry extensions.path "$"`,
+			"version v2.36.0 from /v1/version",
+		}},
+	}
+	if len(matches) != len(want) {
+		t.Fatalf("matches = %v, want %v", matches, want)
+	}
+	for i := range want {
+		got := matches[i]
+		if got.Engine != want[i].Engine || got.Version != want[i].Version ||
+			got.Confidence < want[i].Confidence-1e-9 || got.Confidence > want[i].Confidence+1e-9 {
+			t.Errorf("match %d = %v, want %v", i, got, want[i])
+		}
+		if !reflect.DeepEqual(got.Evidence, want[i].Evidence) {
+			t.Errorf("%s evidence = %q, want %q", got.Engine, got.Evidence, want[i].Evidence)
+		}
+	}
+
+	// Probes shared by several signatures are sent once.
+	for query, n := range m.probes {
+		if n != 1 {
+			t.Errorf("probe %q sent %d times", query, n)
+		}
+	}
+	if _, max := PlanRequests(); len(m.probes)+m.pages > max {
+		t.Errorf("sent %d request(s), more than the plan's %d", len(m.probes)+m.pages, max)
+	}
+}
+
+func TestDetectEngineReturnsTopMatch(t *testing.T) {
+	m := newRouterOverHasura(t)
+	top, err := DetectEngine(m.URL+"/graphql", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top == nil || top.Engine != "Apollo Router" {
+		t.Errorf("DetectEngine() = %v, want the most confident match", top)
+	}
+}
+
+func TestDetectEngineWithoutMatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer srv.Close()
+	matches, err := DetectEngineWithContext(context.Background(), srv.URL, nil)
+	if err != nil || len(matches) != 0 {
+		t.Errorf("DetectEngineWithContext() = %v, %v; want no match", matches, err)
+	}
+}
+
+func TestDetectEngineUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	if matches, err := DetectEngineWithContext(context.Background(), url, nil); err == nil {
+		t.Errorf("DetectEngineWithContext() = %v, want the error of the first probe", matches)
+	}
+}
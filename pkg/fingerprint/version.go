@@ -0,0 +1,230 @@
+package fingerprint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+)
+
+// pageLimit is the number of bytes of a landing page or version document read.
+const pageLimit = 1 << 20
+
+// Components are served alongside the engine rather than being one. They appear
+// in DetectComponentsWithContext results only. These are the names of the
+// built-in entries of the ides dataset.
+const (
+	ComponentPlayground = "GraphQL Playground"
+	ComponentGraphiQL   = "GraphiQL"
+)
+
+// pageResponse is a GET response inspected by version extractors.
+type pageResponse struct {
+	status int
+	header http.Header
+	body   string
+	err    error
+}
+
+// pageProber fetches each URL once and shares the response between extractors.
+type pageProber struct {
+	ctx     context.Context
+	headers map[string]string
+
+	mu    sync.Mutex
+	pages map[string]*pageCall
+}
+
+type pageCall struct {
+	once sync.Once
+	resp *pageResponse
+}
+
+func newPageProber(ctx context.Context, headers map[string]string) *pageProber {
+	return &pageProber{ctx: ctx, headers: headers, pages: make(map[string]*pageCall)}
+}
+
+func (p *pageProber) get(pageURL string) *pageResponse {
+	p.mu.Lock()
+	call, ok := p.pages[pageURL]
+	if !ok {
+		call = &pageCall{}
+		p.pages[pageURL] = call
+	}
+	p.mu.Unlock()
+
+	call.once.Do(func() {
+		call.resp = p.fetch(pageURL)
+	})
+	return call.resp
+}
+
+func (p *pageProber) fetch(pageURL string) *pageResponse {
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return &pageResponse{err: err}
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	req.Header.Set("Accept", "text/html, application/json")
+	for key, value := range p.headers {
+		if !strings.EqualFold(key, "Content-Type") {
+			req.Header.Set(key, value)
+		}
+	}
+	logger.Debug("→ GET %s (version probe)", pageURL)
+	resp, err := network.Client().Do(req)
+	if err != nil {
+		logger.Debug("→ Version probe %s failed: %v", pageURL, err)
+		return &pageResponse{err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, pageLimit))
+	if err != nil {
+		return &pageResponse{err: err}
+	}
+	body = network.DecodeBody(body, resp.Header.Get("Content-Type"))
+	return &pageResponse{status: resp.StatusCode, header: resp.Header, body: string(body)}
+}
+
+// extractVersion applies a version extractor to the endpoint, returning the
+// version and a description of where it was found.
+func extractVersion(p *pageProber, target string, e data.Extractor) (string, string) {
+	switch e.Kind {
+	case data.ExtractBody, data.ExtractHeader:
+		r := p.get(target)
+		if r.err != nil {
+			return "", ""
+		}
+		text := r.body
+		if e.Kind == data.ExtractHeader {
+			text = r.header.Get(e.Header)
+		}
+		if m := e.Pattern().FindStringSubmatch(text); m != nil {
+			return m[1], e.Where
+		}
+	case data.ExtractJSON:
+		return jsonVersion(p, target, e)
+	}
+	return "", ""
+}
+
+// jsonVersion reads the version from a JSON document served next to the
+// endpoint, such as the /v1/version document of Hasura.
+func jsonVersion(p *pageProber, target string, e data.Extractor) (string, string) {
+	versionURL, err := siblingURL(target, e.Path)
+	if err != nil {
+		return "", ""
+	}
+	r := p.get(versionURL)
+	if r.err != nil || r.status != http.StatusOK {
+		return "", ""
+	}
+	var doc map[string]interface{}
+	if json.Unmarshal([]byte(r.body), &doc) != nil {
+		return "", ""
+	}
+	if v, _ := doc[e.Field].(string); v != "" {
+		return v, e.Where
+	}
+	return "", ""
+}
+
+// siblingURL replaces the path of target with path.
+func siblingURL(target, path string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	u.Path, u.RawQuery, u.Fragment = path, "", ""
+	return u.String(), nil
+}
+
+// addVersions fills the Version of each match for which an extractor of its
+// engine in the engines dataset finds one.
+func addVersions(p *pageProber, target string, matches []EngineMatch) {
+	extractors := make(map[string][]data.Extractor)
+	for _, e := range data.Engines() {
+		extractors[e.Name] = e.Versions
+	}
+	for i := range matches {
+		for _, extractor := range extractors[matches[i].Engine] {
+			if v, where := extractVersion(p, target, extractor); v != "" {
+				matches[i].Version = v
+				matches[i].Evidence = append(matches[i].Evidence, fmt.Sprintf("version %s from %s", v, where))
+				break
+			}
+		}
+	}
+}
+
+// DetectComponentsWithContext returns the GraphQL IDEs of the ides dataset served
+// on url, with their versions. Their asset URLs in the landing page give the
+// version away.
+func DetectComponentsWithContext(ctx context.Context, url string, headers map[string]string) []EngineMatch {
+	return detectComponents(newPageProber(ctx, headers), url)
+}
+
+func detectComponents(p *pageProber, target string) []EngineMatch {
+	var found []EngineMatch
+	for _, ide := range data.IDEs() {
+		for _, extractor := range ide.Versions {
+			if v, where := extractVersion(p, target, extractor); v != "" {
+				found = append(found, EngineMatch{
+					Engine:     ide.Name,
+					Version:    v,
+					Confidence: 1,
+					Evidence:   []string{fmt.Sprintf("version %s from %s", v, where)},
+				})
+				break
+			}
+		}
+	}
+	return found
+}
+
+// PlanRequests returns how many requests fingerprinting one endpoint sends:
+// min when no engine matches, max when every engine with a version extractor
+// does. Both count the GraphQL probes of the engines dataset and the pages the
+// IDE version extractors read.
+func PlanRequests() (min, max int) {
+	probes := map[string]bool{probeTypename: true}
+	for _, e := range data.Engines() {
+		for _, sig := range e.Signatures {
+			probes[sig.Probe] = true
+		}
+	}
+	var ideExtractors, engineExtractors []data.Extractor
+	for _, ide := range data.IDEs() {
+		ideExtractors = append(ideExtractors, ide.Versions...)
+	}
+	for _, e := range data.Engines() {
+		engineExtractors = append(engineExtractors, e.Versions...)
+	}
+	// Each detection uses its own page prober, so the pages are counted per prober.
+	min = len(probes) + len(extractorPages(ideExtractors))
+	max = min + len(extractorPages(engineExtractors))
+	return min, max
+}
+
+// extractorPages returns the distinct pages read by extractors: the endpoint
+// itself for body and header extractors, and sibling paths for JSON ones.
+func extractorPages(extractors []data.Extractor) map[string]bool {
+	pages := make(map[string]bool)
+	for _, e := range extractors {
+		if e.Kind == data.ExtractJSON {
+			pages[e.Path] = true
+		} else {
+			pages[""] = true
+		}
+	}
+	return pages
+}
@@ -0,0 +1,113 @@
+// Package gerrors defines the errors returned by the network layer so callers can
+// tell failure modes apart with errors.Is instead of matching messages
+package gerrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+var (
+	// ErrNonJSONResponse is returned when the body is not JSON and the Content-Type is not JSON either.
+	ErrNonJSONResponse = errors.New("non-JSON response received")
+	// ErrHTMLResponse is returned when an HTML page is served instead of JSON.
+	ErrHTMLResponse = errors.New("HTML response received instead of expected JSON")
+	// ErrTimeout is returned when a request exceeds its deadline.
+	ErrTimeout = errors.New("request timed out")
+	// ErrCanceled is returned when a request is canceled before it completes.
+	ErrCanceled = errors.New("request canceled")
+	// ErrRateLimited is returned when the server keeps rate limiting after every retry.
+	ErrRateLimited = errors.New("rate limited by server")
+	// ErrTooLarge is returned when a response body exceeds the size limit.
+	ErrTooLarge = errors.New("response too large")
+	// ErrSessionExpired is returned when a response shows the preflight session is no longer accepted.
+	ErrSessionExpired = errors.New("session expired")
+	// ErrIncomplete is returned with partial results when a scan is cut short
+	// before every candidate was checked.
+	ErrIncomplete = errors.New("incomplete")
+	// ErrOfflineMode is returned instead of sending anything when offline mode is enabled.
+	ErrOfflineMode = errors.New("network access disabled in offline mode")
+	// ErrOutOfScope is returned instead of contacting a host outside the
+	// allowed hosts of the bounty profile.
+	ErrOutOfScope = errors.New("host is out of scope")
+	// ErrNotModified is returned when a conditional request is answered with
+	// 304 Not Modified; see network.Validators.
+	ErrNotModified = errors.New("not modified")
+)
+
+// RateLimitError carries the details of a rate-limited response. It matches ErrRateLimited.
+type RateLimitError struct {
+	StatusCode int
+	// RetryAfter is the wait requested by the server, zero when none was given.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v (HTTP %d)", ErrRateLimited, e.StatusCode)
+}
+
+// Is reports whether target is ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// ContentTypeError is returned for bodies that cannot be parsed as JSON. Kind is
+// ErrHTMLResponse or ErrNonJSONResponse and Err the parse error, if any. Body
+// is the start of the response body, so that interstitial pages such as
+// consent banners can be recognized.
+type ContentTypeError struct {
+	Kind        error
+	ContentType string
+	Err         error
+	Body        []byte
+}
+
+func (e *ContentTypeError) Error() string {
+	if e.ContentType == "" {
+		return e.Kind.Error()
+	}
+	return fmt.Sprintf("%v (Content-Type: %s)", e.Kind, e.ContentType)
+}
+
+// Is reports whether target is the kind of the error.
+func (e *ContentTypeError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying parse error.
+func (e *ContentTypeError) Unwrap() error {
+	return e.Err
+}
+
+// Interrupted wraps err in ErrCanceled or ErrTimeout when it was caused by the
+// end of ctx or by a network timeout, keeping err as the cause. Other errors are
+// returned unchanged.
+func Interrupted(ctx context.Context, err error) error {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrCanceled), errors.Is(err, ErrTimeout):
+		return err
+	case ctx.Err() == context.Canceled || errors.Is(err, context.Canceled):
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	case ctx.Err() == context.DeadlineExceeded || errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+// IsNotGraphQL reports whether err means the endpoint served something other than a GraphQL response.
+func IsNotGraphQL(err error) bool {
+	return errors.Is(err, ErrHTMLResponse) || errors.Is(err, ErrNonJSONResponse)
+}
+
+// IsInterrupted reports whether err means the request was canceled or timed out.
+func IsInterrupted(err error) bool {
+	return errors.Is(err, ErrCanceled) || errors.Is(err, ErrTimeout)
+}
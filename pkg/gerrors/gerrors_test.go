@@ -0,0 +1,71 @@
+package gerrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// timeoutError is a net.Error reporting a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestInterrupted(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	background := context.Background()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want error
+	}{
+		{"canceled context", canceled, io.ErrUnexpectedEOF, ErrCanceled},
+		{"expired context", expired, io.ErrUnexpectedEOF, ErrTimeout},
+		{"wrapped cancellation", background, fmt.Errorf("post: %w", context.Canceled), ErrCanceled},
+		{"wrapped deadline", background, fmt.Errorf("post: %w", context.DeadlineExceeded), ErrTimeout},
+		{"network timeout", background, fmt.Errorf("dial: %w", timeoutError{}), ErrTimeout},
+		{"already interrupted", canceled, fmt.Errorf("read: %w", ErrTimeout), ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Interrupted(tt.ctx, tt.err)
+			if !errors.Is(got, tt.want) || !IsInterrupted(got) {
+				t.Errorf("Interrupted() = %v, want one matching %v", got, tt.want)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("Interrupted() = %v lost its cause %v", got, tt.err)
+			}
+		})
+	}
+
+	if err := Interrupted(background, io.EOF); err != io.EOF {
+		t.Errorf("Interrupted() = %v, want other errors unchanged", err)
+	}
+	if Interrupted(canceled, nil) != nil {
+		t.Error("Interrupted(nil) is not nil")
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	rle := fmt.Errorf("send: %w", &RateLimitError{StatusCode: 429})
+	if !errors.Is(rle, ErrRateLimited) || errors.Is(rle, ErrTimeout) {
+		t.Errorf("%v does not match ErrRateLimited only", rle)
+	}
+	cause := errors.New("invalid character '<'")
+	cte := fmt.Errorf("send: %w", &ContentTypeError{Kind: ErrHTMLResponse, Err: cause})
+	if !errors.Is(cte, ErrHTMLResponse) || errors.Is(cte, ErrNonJSONResponse) || !errors.Is(cte, cause) || !IsNotGraphQL(cte) {
+		t.Errorf("%v does not match its kind and cause", cte)
+	}
+	if got := (&ContentTypeError{Kind: ErrNonJSONResponse, ContentType: "text/plain"}).Error(); got != "non-JSON response received (Content-Type: text/plain)" {
+		t.Errorf("Error() = %q", got)
+	}
+}
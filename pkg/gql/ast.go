@@ -0,0 +1,201 @@
+package gql
+
+import "strings"
+
+// Operation kinds
+const (
+	OperationQuery        = "query"
+	OperationMutation     = "mutation"
+	OperationSubscription = "subscription"
+)
+
+// Document is a parsed executable GraphQL document
+type Document struct {
+	Source     string
+	Operations []*Operation
+	Fragments  []*Fragment
+}
+
+// Operation is a query, mutation or subscription definition.
+// Start and End are byte offsets of the definition in the source.
+type Operation struct {
+	Kind                string
+	Name                string
+	VariableDefinitions []*VariableDefinition
+	Directives          []*Directive
+	SelectionSet        []Selection
+	Start               int
+	End                 int
+}
+
+// Fragment is a named fragment definition
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Start         int
+	End           int
+}
+
+// VariableDefinition declares an operation variable such as `$id: ID! = "1"`
+type VariableDefinition struct {
+	Name         string
+	Type         *Type
+	DefaultValue *Value
+	Directives   []*Directive
+}
+
+// Type is a variable type reference. A list type has a non-nil Elem and no Name.
+type Type struct {
+	Name    string
+	Elem    *Type
+	NonNull bool
+}
+
+// String renders the type in GraphQL syntax
+func (t *Type) String() string {
+	if t == nil {
+		return ""
+	}
+	s := t.Name
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		s += "!"
+	}
+	return s
+}
+
+// NamedType returns the innermost named type of t
+func (t *Type) NamedType() string {
+	for t.Elem != nil {
+		t = t.Elem
+	}
+	return t.Name
+}
+
+// Selection is a Field, FragmentSpread or InlineFragment
+type Selection interface {
+	selection()
+}
+
+// Field selects a single field, optionally aliased
+type Field struct {
+	Alias        string
+	Name         string
+	Arguments    []*Argument
+	Directives   []*Directive
+	SelectionSet []Selection
+}
+
+// FragmentSpread references a named fragment with `...Name`
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+}
+
+// InlineFragment is an anonymous `... on Type { }` selection
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+}
+
+func (*Field) selection()          {}
+func (*FragmentSpread) selection() {}
+func (*InlineFragment) selection() {}
+
+// ResponseKey returns the alias of the field, or its name when it has none
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Argument is a `name: value` pair on a field or directive
+type Argument struct {
+	Name  string
+	Value *Value
+}
+
+// Directive is an `@name(args)` annotation
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+}
+
+// ValueKind identifies the kind of an input value
+type ValueKind int
+
+// Value kinds
+const (
+	ValueVariable ValueKind = iota
+	ValueInt
+	ValueFloat
+	ValueString
+	ValueBoolean
+	ValueNull
+	ValueEnum
+	ValueList
+	ValueObject
+)
+
+// Value is an input value literal. Raw holds the source text of scalar values
+// (strings keep their quotes) and the name of variables and enum values.
+type Value struct {
+	Kind   ValueKind
+	Raw    string
+	List   []*Value
+	Fields []*ObjectField
+}
+
+// ObjectField is a `name: value` member of an input object literal
+type ObjectField struct {
+	Name  string
+	Value *Value
+}
+
+// String renders the value in GraphQL syntax
+func (v *Value) String() string {
+	switch v.Kind {
+	case ValueVariable:
+		return "$" + v.Raw
+	case ValueList:
+		parts := make([]string, len(v.List))
+		for i, item := range v.List {
+			parts[i] = item.String()
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case ValueObject:
+		parts := make([]string, len(v.Fields))
+		for i, f := range v.Fields {
+			parts[i] = f.Name + ": " + f.Value.String()
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return v.Raw
+	}
+}
+
+// FragmentByName returns the fragment definition with the given name
+func (d *Document) FragmentByName(name string) *Fragment {
+	for _, f := range d.Fragments {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// OperationByName returns the operation with the given name
+func (d *Document) OperationByName(name string) *Operation {
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package gql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// literalPlaceholder replaces argument literals in the canonical form.
+const literalPlaceholder = "$_"
+
+// Canonicalize parses doc and prints it in a canonical form: ignored tokens
+// (whitespace, commas and comments) are dropped, anonymous queries gain the
+// query keyword, fragments are ordered by name, and literal field arguments and
+// variable defaults become placeholders so that operations differing only in
+// the values they send print the same. Argument and selection order is kept.
+// Directive arguments are kept as written since they change what is selected.
+func Canonicalize(doc string) (string, error) {
+	d, err := Parse(doc)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, op := range d.Operations {
+		b.WriteString(op.Kind)
+		if op.Name != "" {
+			b.WriteString(" " + op.Name)
+		}
+		if len(op.VariableDefinitions) > 0 {
+			b.WriteString("(")
+			for i, v := range op.VariableDefinitions {
+				if i > 0 {
+					b.WriteString(",")
+				}
+				b.WriteString("$" + v.Name + ":" + v.Type.String())
+				if v.DefaultValue != nil {
+					b.WriteString("=" + literalPlaceholder)
+				}
+				writeDirectives(&b, v.Directives)
+			}
+			b.WriteString(")")
+		}
+		writeDirectives(&b, op.Directives)
+		writeSelectionSet(&b, op.SelectionSet)
+		b.WriteString("\n")
+	}
+
+	frags := append([]*Fragment(nil), d.Fragments...)
+	sort.SliceStable(frags, func(i, j int) bool { return frags[i].Name < frags[j].Name })
+	for _, f := range frags {
+		b.WriteString("fragment " + f.Name + " on " + f.TypeCondition)
+		writeDirectives(&b, f.Directives)
+		writeSelectionSet(&b, f.SelectionSet)
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// CanonicalHash returns the hex SHA-256 of the canonical form of doc. Documents
+// with the same hash perform the same operation.
+func CanonicalHash(doc string) (string, error) {
+	canonical, err := Canonicalize(doc)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func writeSelectionSet(b *strings.Builder, set []Selection) {
+	b.WriteString("{")
+	for i, sel := range set {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		switch s := sel.(type) {
+		case *Field:
+			if s.Alias != "" {
+				b.WriteString(s.Alias + ":")
+			}
+			b.WriteString(s.Name)
+			if len(s.Arguments) > 0 {
+				b.WriteString("(")
+				for j, a := range s.Arguments {
+					if j > 0 {
+						b.WriteString(",")
+					}
+					b.WriteString(a.Name + ":")
+					if a.Value.Kind == ValueVariable {
+						b.WriteString(a.Value.String())
+					} else {
+						b.WriteString(literalPlaceholder)
+					}
+				}
+				b.WriteString(")")
+			}
+			writeDirectives(b, s.Directives)
+			if len(s.SelectionSet) > 0 {
+				writeSelectionSet(b, s.SelectionSet)
+			}
+		case *FragmentSpread:
+			b.WriteString("..." + s.Name)
+			writeDirectives(b, s.Directives)
+		case *InlineFragment:
+			b.WriteString("...")
+			if s.TypeCondition != "" {
+				b.WriteString(" on " + s.TypeCondition)
+			}
+			writeDirectives(b, s.Directives)
+			writeSelectionSet(b, s.SelectionSet)
+		}
+	}
+	b.WriteString("}")
+}
+
+func writeDirectives(b *strings.Builder, directives []*Directive) {
+	for _, d := range directives {
+		b.WriteString("@" + d.Name)
+		if len(d.Arguments) > 0 {
+			b.WriteString("(")
+			for i, a := range d.Arguments {
+				if i > 0 {
+					b.WriteString(",")
+				}
+				b.WriteString(a.Name + ":" + canonicalValue(a.Value))
+			}
+			b.WriteString(")")
+		}
+	}
+}
+
+// canonicalValue renders a literal without optional whitespace and commas.
+func canonicalValue(v *Value) string {
+	switch v.Kind {
+	case ValueList:
+		parts := make([]string, len(v.List))
+		for i, item := range v.List {
+			parts[i] = canonicalValue(item)
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	case ValueObject:
+		parts := make([]string, len(v.Fields))
+		for i, f := range v.Fields {
+			parts[i] = f.Name + ":" + canonicalValue(f.Value)
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	default:
+		return v.String()
+	}
+}
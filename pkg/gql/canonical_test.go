@@ -0,0 +1,65 @@
+package gql
+
+import "testing"
+
+func TestCanonicalHashEquivalent(t *testing.T) {
+	base := `query GetUser($id: ID!) { user(id: $id) { id name ...F } } fragment F on User { email }`
+	for name, doc := range map[string]string{
+		"whitespace": "query   GetUser( $id : ID! )\n{\n\tuser( id : $id ) {\n id\n name\n ...F\n }\n}\n\nfragment F on User {\n  email\n}\n",
+		"commas":     `query GetUser($id: ID!,) { user(id: $id,), { id, name, ...F } } fragment F on User { email, }`,
+		"comments":   "# fetch a user\nquery GetUser($id: ID!) { # the user\n user(id: $id) { id name ...F } } # end\nfragment F on User { email }",
+		"fragments":  `fragment F on User { email } query GetUser($id: ID!) { user(id: $id) { id name ...F } }`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			assertSameHash(t, base, doc, true)
+		})
+	}
+}
+
+func TestCanonicalHashLiterals(t *testing.T) {
+	assertSameHash(t, `{ user(id: "1") { id } }`, `query { user(id: "2") { id } }`, true)
+	assertSameHash(t, `{ posts(first: 5, tags: ["a"]) { id } }`, `{ posts(first: 50, tags: ["b", "c"]) { id } }`, true)
+	assertSameHash(t, `query Q($n: Int = 1) { posts(first: $n) { id } }`, `query Q($n: Int = 20) { posts(first: $n) { id } }`, true)
+}
+
+func TestCanonicalHashDifferent(t *testing.T) {
+	base := `query GetUser($id: ID!) { user(id: $id) { id name } }`
+	for name, doc := range map[string]string{
+		"selection":       `query GetUser($id: ID!) { user(id: $id) { id email } }`,
+		"selection order": `query GetUser($id: ID!) { user(id: $id) { name id } }`,
+		"alias":           `query GetUser($id: ID!) { user(id: $id) { id fullName: name } }`,
+		"operation name":  `query FetchUser($id: ID!) { user(id: $id) { id name } }`,
+		"kind":            `mutation GetUser($id: ID!) { user(id: $id) { id name } }`,
+		"variable type":   `query GetUser($id: String!) { user(id: $id) { id name } }`,
+		"argument name":   `query GetUser($id: ID!) { user(uid: $id) { id name } }`,
+		"directive":       `query GetUser($id: ID!) { user(id: $id) { id name @include(if: false) } }`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			assertSameHash(t, base, doc, false)
+		})
+	}
+	assertSameHash(t, `{ a @skip(if: true) }`, `{ a @skip(if: false) }`, false)
+}
+
+func TestCanonicalHashInvalid(t *testing.T) {
+	if _, err := CanonicalHash("query {"); err == nil {
+		t.Error("CanonicalHash accepted a malformed document")
+	}
+}
+
+func assertSameHash(t *testing.T, a, b string, same bool) {
+	t.Helper()
+	ha, err := CanonicalHash(a)
+	if err != nil {
+		t.Fatalf("CanonicalHash(%q): %v", a, err)
+	}
+	hb, err := CanonicalHash(b)
+	if err != nil {
+		t.Fatalf("CanonicalHash(%q): %v", b, err)
+	}
+	if (ha == hb) != same {
+		ca, _ := Canonicalize(a)
+		cb, _ := Canonicalize(b)
+		t.Errorf("hashes equal = %v, want %v:\n%s\n%s", ha == hb, same, ca, cb)
+	}
+}
@@ -0,0 +1,114 @@
+package gql
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+)
+
+// ErrorClasses returns the classes of the error-patterns dataset that a GraphQL
+// error with message and extensions.code falls in. A recognised code decides
+// on its own; the message is only matched against the phrases of every
+// language when code is empty or unknown, so localized and custom messages
+// are classified as well as the English ones of graphql-js.
+func ErrorClasses(message, code string) []string {
+	patterns := data.ErrorPatterns()
+	if code != "" {
+		if classes := matchPatterns(patterns, data.MatchCode, func(v string) bool { return strings.EqualFold(v, code) }); len(classes) > 0 {
+			return classes
+		}
+	}
+	lower := strings.ToLower(message)
+	if lower == "" {
+		return nil
+	}
+	return matchPatterns(patterns, data.MatchMessage, func(v string) bool { return strings.Contains(lower, v) })
+}
+
+// matchPatterns returns the classes, in data.ErrorClasses order, of the
+// patterns of kind match with a value for which matches is true.
+func matchPatterns(patterns []data.ErrorPattern, match string, matches func(string) bool) []string {
+	found := make(map[string]bool)
+	for _, p := range patterns {
+		if p.Match != match || found[p.Class] {
+			continue
+		}
+		for _, v := range p.Values {
+			if matches(v) {
+				found[p.Class] = true
+				break
+			}
+		}
+	}
+	var classes []string
+	for _, c := range data.ErrorClasses() {
+		if found[c] {
+			classes = append(classes, c)
+		}
+	}
+	return classes
+}
+
+// ClassifyErrors returns the classes of the entries of a GraphQL errors array.
+func ClassifyErrors(errs []interface{}) map[string]bool {
+	classes := make(map[string]bool)
+	for _, e := range errs {
+		m, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		message, _ := m["message"].(string)
+		var code string
+		if ext, ok := m["extensions"].(map[string]interface{}); ok {
+			code, _ = ext["code"].(string)
+		}
+		for _, c := range ErrorClasses(message, code) {
+			classes[c] = true
+		}
+	}
+	return classes
+}
+
+// HasErrorClass reports whether an error of the GraphQL response resp falls in class.
+func HasErrorClass(resp map[string]interface{}, class string) bool {
+	errs, _ := resp["errors"].([]interface{})
+	return ClassifyErrors(errs)[class]
+}
+
+// quotedName matches a name quoted in an error message, such as User or
+// Query.user, with the straight, curly, low or corner quotes of localized
+// servers.
+var quotedName = regexp.MustCompile(`["“„「]([_A-Za-z][_0-9A-Za-z]*(?:\.[_A-Za-z][_0-9A-Za-z]*)?)["“”」]`)
+
+// QuotedNames returns the names a GraphQL error message quotes before its
+// first phrase of the suggestion class, such as the field it rejects and the
+// type of the field, and those it quotes after it, the names it suggests.
+// Suggestion phrases are matched in every language of the error-patterns
+// dataset, whatever their case.
+func QuotedNames(message string) (named, suggested []string) {
+	var phrases []string
+	for _, p := range data.ErrorPatterns() {
+		if p.Class != data.ErrorSuggestion || p.Match != data.MatchMessage {
+			continue
+		}
+		for _, v := range p.Values {
+			phrases = append(phrases, regexp.QuoteMeta(v))
+		}
+	}
+	at := len(message)
+	if len(phrases) > 0 {
+		if loc := regexp.MustCompile(`(?i)` + strings.Join(phrases, "|")).FindStringIndex(message); loc != nil {
+			at = loc[0]
+		}
+	}
+	for _, m := range quotedName.FindAllStringSubmatchIndex(message, -1) {
+		name := message[m[2]:m[3]]
+		if m[0] < at {
+			named = append(named, name)
+		} else {
+			suggested = append(suggested, name)
+		}
+	}
+	return named, suggested
+}
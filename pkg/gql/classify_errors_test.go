@@ -0,0 +1,69 @@
+package gql
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// localizedError is an entry of testdata/localized-errors.json: a GraphQL
+// error as a server in language words it, and what the classifier reads
+// from it.
+type localizedError struct {
+	Language  string   `json:"language"`
+	Message   string   `json:"message"`
+	Code      string   `json:"code"`
+	Classes   []string `json:"classes"`
+	Named     []string `json:"named"`
+	Suggested []string `json:"suggested"`
+}
+
+func loadLocalizedErrors(t *testing.T) []localizedError {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "localized-errors.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures []localizedError
+	if err := json.Unmarshal(raw, &fixtures); err != nil {
+		t.Fatal(err)
+	}
+	return fixtures
+}
+
+func TestErrorClassesOfLocalizedErrors(t *testing.T) {
+	for _, f := range loadLocalizedErrors(t) {
+		if got := ErrorClasses(f.Message, f.Code); !reflect.DeepEqual(got, f.Classes) {
+			t.Errorf("%s %q (code %q): classes %v, want %v", f.Language, f.Message, f.Code, got, f.Classes)
+		}
+	}
+}
+
+func TestQuotedNamesOfLocalizedErrors(t *testing.T) {
+	for _, f := range loadLocalizedErrors(t) {
+		named, suggested := QuotedNames(f.Message)
+		if len(named) != len(f.Named) || (len(named) > 0 && !reflect.DeepEqual(named, f.Named)) {
+			t.Errorf("%s %q: named %v, want %v", f.Language, f.Message, named, f.Named)
+		}
+		if len(suggested) != len(f.Suggested) || (len(suggested) > 0 && !reflect.DeepEqual(suggested, f.Suggested)) {
+			t.Errorf("%s %q: suggested %v, want %v", f.Language, f.Message, suggested, f.Suggested)
+		}
+	}
+}
+
+func TestClassifyErrors(t *testing.T) {
+	errs := []interface{}{
+		map[string]interface{}{"message": "Zu viele Anfragen"},
+		map[string]interface{}{"message": "whatever", "extensions": map[string]interface{}{"code": "UNAUTHENTICATED"}},
+		"not an error object",
+	}
+	if got := ClassifyErrors(errs); !reflect.DeepEqual(got, map[string]bool{"rate-limit": true, "auth": true}) {
+		t.Errorf("ClassifyErrors = %v", got)
+	}
+	resp := map[string]interface{}{"errors": errs}
+	if !HasErrorClass(resp, "auth") || HasErrorClass(resp, "validation") {
+		t.Error("HasErrorClass does not follow ClassifyErrors")
+	}
+}
@@ -0,0 +1,73 @@
+package gql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FragmentsUsed returns the fragments op spreads, directly or through other
+// fragments, in document order. Spreads naming undefined fragments are returned
+// in missing, in the order they are first seen.
+func (d *Document) FragmentsUsed(op *Operation) (used []*Fragment, missing []string) {
+	seen := make(map[string]bool)
+	var visit func(set []Selection)
+	visit = func(set []Selection) {
+		for _, sel := range set {
+			switch s := sel.(type) {
+			case *Field:
+				visit(s.SelectionSet)
+			case *InlineFragment:
+				visit(s.SelectionSet)
+			case *FragmentSpread:
+				if seen[s.Name] {
+					continue
+				}
+				seen[s.Name] = true
+				frag := d.FragmentByName(s.Name)
+				if frag == nil {
+					missing = append(missing, s.Name)
+					continue
+				}
+				visit(frag.SelectionSet)
+			}
+		}
+	}
+	visit(op.SelectionSet)
+
+	for _, f := range d.Fragments {
+		if seen[f.Name] {
+			used = append(used, f)
+		}
+	}
+	return used, missing
+}
+
+// OperationDocument returns the source of op followed by the fragments it
+// needs, so that it can be sent on its own. With keepAll every fragment of the
+// document is included instead. Spreads of undefined fragments are an error.
+func (d *Document) OperationDocument(op *Operation, keepAll bool) (string, error) {
+	used, missing := d.FragmentsUsed(op)
+	if len(missing) > 0 {
+		return "", fmt.Errorf("operation %s spreads undefined fragment(s) %s", operationLabel(op), strings.Join(missing, ", "))
+	}
+	if keepAll {
+		used = d.Fragments
+	}
+
+	var b strings.Builder
+	b.WriteString(d.Source[op.Start:op.End])
+	for _, f := range used {
+		b.WriteString("\n\n")
+		b.WriteString(d.Source[f.Start:f.End])
+	}
+	b.WriteString("\n")
+	return b.String(), nil
+}
+
+// operationLabel names op in error messages.
+func operationLabel(op *Operation) string {
+	if op.Name == "" {
+		return "(anonymous " + op.Kind + ")"
+	}
+	return op.Name
+}
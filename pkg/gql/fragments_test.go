@@ -0,0 +1,120 @@
+package gql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fragmentsDoc = `fragment Audit on Node { createdAt }
+
+query Me { me { ...Profile } }
+
+fragment Profile on User { id ...Contact ... on Admin @include(if: true) { ...Permissions } }
+
+mutation Rename { rename { ...Contact ...Contact } }
+
+fragment Unused on User { secret }
+
+fragment Contact on User { email ...Audit }
+
+fragment Permissions on Admin { roles { ...Audit } }
+`
+
+func fragmentNames(frags []*Fragment) []string {
+	names := make([]string, len(frags))
+	for i, f := range frags {
+		names[i] = f.Name
+	}
+	return names
+}
+
+func TestFragmentsUsed(t *testing.T) {
+	doc, err := Parse(fragmentsDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fragments are followed through fields, inline fragments and other
+	// fragments, and returned in document order rather than spread order.
+	for op, want := range map[string][]string{
+		"Me":     {"Audit", "Profile", "Contact", "Permissions"},
+		"Rename": {"Audit", "Contact"},
+	} {
+		used, missing := doc.FragmentsUsed(doc.OperationByName(op))
+		if got := fragmentNames(used); !reflect.DeepEqual(got, want) || len(missing) != 0 {
+			t.Errorf("%s uses %v (missing %v), want %v", op, got, missing, want)
+		}
+	}
+
+	cyclic, err := Parse(`{ ...A ...Gone } fragment A on Q { ...B } fragment B on Q { ...A ...Lost ...Gone }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Missing fragments are listed in the order the depth-first walk meets them.
+	used, missing := cyclic.FragmentsUsed(cyclic.Operations[0])
+	if got := fragmentNames(used); !reflect.DeepEqual(got, []string{"A", "B"}) || !reflect.DeepEqual(missing, []string{"Lost", "Gone"}) {
+		t.Errorf("cyclic spreads use %v and miss %v", got, missing)
+	}
+}
+
+func TestOperationDocument(t *testing.T) {
+	doc, err := Parse(fragmentsDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	me := doc.OperationByName("Me")
+
+	pruned, err := doc.OperationDocument(me, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "query Me { me { ...Profile } }\n\n" +
+		"fragment Audit on Node { createdAt }\n\n" +
+		"fragment Profile on User { id ...Contact ... on Admin @include(if: true) { ...Permissions } }\n\n" +
+		"fragment Contact on User { email ...Audit }\n\n" +
+		"fragment Permissions on Admin { roles { ...Audit } }\n"
+	if pruned != want {
+		t.Errorf("OperationDocument() = %q, want %q", pruned, want)
+	}
+	// The pruned document stands on its own.
+	if sub, err := Parse(pruned); err != nil || len(sub.Operations) != 1 || len(sub.Fragments) != 4 {
+		t.Errorf("the pruned document does not parse on its own: %v", err)
+	}
+
+	rename, err := doc.OperationDocument(doc.OperationByName("Rename"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(rename, "Profile on") || strings.Contains(rename, "Unused") || strings.Count(rename, "fragment Contact") != 1 {
+		t.Errorf("OperationDocument(Rename) = %q, want only Audit and Contact once", rename)
+	}
+
+	all, err := doc.OperationDocument(me, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := Parse(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fragmentNames(sub.Fragments); !reflect.DeepEqual(got, fragmentNames(doc.Fragments)) || len(sub.Operations) != 1 {
+		t.Errorf("with keepAll the document has %v, want every fragment", got)
+	}
+}
+
+func TestOperationDocumentMissingFragment(t *testing.T) {
+	doc, err := Parse(`query Q { ...A } mutation { ...Gone } fragment A on Q { ...B ...C }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{
+		"operation Q spreads undefined fragment(s) B, C",
+		"operation (anonymous mutation) spreads undefined fragment(s) Gone",
+	} {
+		for _, keepAll := range []bool{false, true} {
+			if _, err := doc.OperationDocument(doc.Operations[i], keepAll); err == nil || err.Error() != want {
+				t.Errorf("OperationDocument(keepAll=%v) = %v, want %q", keepAll, err, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Package gql parses GraphQL executable documents into a small AST
+package gql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenKind identifies the lexical class of a token
+type TokenKind int
+
+// Token kinds
+const (
+	TokenEOF TokenKind = iota
+	TokenPunct
+	TokenName
+	TokenInt
+	TokenFloat
+	TokenString
+	TokenBlockString
+)
+
+// Token is a single lexical token with its position in the source
+type Token struct {
+	Kind  TokenKind
+	Value string
+	Start int
+	End   int
+}
+
+// SyntaxError describes a lexing or parsing failure at a source position
+type SyntaxError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// lexer splits a document into tokens, skipping ignored tokens
+// (whitespace, commas, comments and the byte order mark).
+type lexer struct {
+	src string
+	pos int
+}
+
+// errorAt builds a SyntaxError for the given byte offset.
+func errorAt(src string, offset int, format string, args ...interface{}) *SyntaxError {
+	line, col := 1, 1
+	for i := 0; i < offset && i < len(src); i++ {
+		if src[i] == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Line: line, Column: col}
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\uFEFF"):
+			l.pos += len("\uFEFF")
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next significant token.
+func (l *lexer) next() (Token, error) {
+	l.skipIgnored()
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return Token{Kind: TokenEOF, Start: start, End: start}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return Token{Kind: TokenPunct, Value: "...", Start: start, End: l.pos}, nil
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.pos++
+		return Token{Kind: TokenPunct, Value: string(c), Start: start, End: l.pos}, nil
+	case isNameStart(c):
+		for l.pos < len(l.src) && isNameContinue(l.src[l.pos]) {
+			l.pos++
+		}
+		return Token{Kind: TokenName, Value: l.src[start:l.pos], Start: start, End: l.pos}, nil
+	case c == '-' || isDigit(c):
+		return l.number()
+	case strings.HasPrefix(l.src[l.pos:], `"""`):
+		return l.blockString()
+	case c == '"':
+		return l.string()
+	}
+	return Token{}, errorAt(l.src, start, "unexpected character %q", c)
+}
+
+func (l *lexer) number() (Token, error) {
+	start := l.pos
+	kind := TokenInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	if l.pos >= len(l.src) || !isDigit(l.src[l.pos]) {
+		return Token{}, errorAt(l.src, start, "invalid number")
+	}
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = TokenFloat
+		l.pos++
+		if l.pos >= len(l.src) || !isDigit(l.src[l.pos]) {
+			return Token{}, errorAt(l.src, start, "invalid float")
+		}
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = TokenFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if l.pos >= len(l.src) || !isDigit(l.src[l.pos]) {
+			return Token{}, errorAt(l.src, start, "invalid float exponent")
+		}
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+	if l.pos < len(l.src) && (isNameStart(l.src[l.pos]) || l.src[l.pos] == '.') {
+		return Token{}, errorAt(l.src, start, "invalid number")
+	}
+	return Token{Kind: kind, Value: l.src[start:l.pos], Start: start, End: l.pos}, nil
+}
+
+// string lexes a quoted string and keeps its raw source form, quotes included.
+func (l *lexer) string() (Token, error) {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos += 2
+		case '"':
+			l.pos++
+			return Token{Kind: TokenString, Value: l.src[start:l.pos], Start: start, End: l.pos}, nil
+		case '\n', '\r':
+			return Token{}, errorAt(l.src, start, "unterminated string")
+		default:
+			l.pos++
+		}
+	}
+	return Token{}, errorAt(l.src, start, "unterminated string")
+}
+
+// blockString lexes a """ block string and keeps its raw source form.
+func (l *lexer) blockString() (Token, error) {
+	start := l.pos
+	l.pos += 3
+	for l.pos < len(l.src) {
+		if strings.HasPrefix(l.src[l.pos:], `\"""`) {
+			l.pos += 4
+			continue
+		}
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			l.pos += 3
+			return Token{Kind: TokenBlockString, Value: l.src[start:l.pos], Start: start, End: l.pos}, nil
+		}
+		l.pos++
+	}
+	return Token{}, errorAt(l.src, start, "unterminated block string")
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameContinue(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,104 @@
+package gql
+
+import "fmt"
+
+// Metrics describes the size of a single operation after fragment expansion
+type Metrics struct {
+	Operation  string `json:"operation"`
+	Depth      int    `json:"depth"`
+	Aliases    int    `json:"aliases"`
+	RootFields int    `json:"rootFields"`
+	Selections int    `json:"selections"`
+}
+
+// Limits are the thresholds a document is linted against. Zero disables a limit.
+type Limits struct {
+	MaxDepth      int
+	MaxAliases    int
+	MaxRootFields int
+	MaxSelections int
+}
+
+// ComputeMetrics measures every operation of doc. Fragment spreads are expanded
+// in place, so selections reached through fragments count toward depth and totals.
+func ComputeMetrics(doc *Document) ([]Metrics, error) {
+	var all []Metrics
+	for i, op := range doc.Operations {
+		name := op.Name
+		if name == "" {
+			name = fmt.Sprintf("<anonymous #%d>", i+1)
+		}
+		m := Metrics{Operation: name}
+		w := &metricsWalker{doc: doc, metrics: &m, active: make(map[string]bool)}
+		if err := w.walk(op.SelectionSet, 1); err != nil {
+			return nil, fmt.Errorf("operation %s: %w", name, err)
+		}
+		all = append(all, m)
+	}
+	return all, nil
+}
+
+// metricsWalker accumulates Metrics while traversing a selection set
+type metricsWalker struct {
+	doc     *Document
+	metrics *Metrics
+	active  map[string]bool // fragments on the current expansion path, for cycle detection
+}
+
+func (w *metricsWalker) walk(set []Selection, depth int) error {
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *Field:
+			w.metrics.Selections++
+			if s.Alias != "" {
+				w.metrics.Aliases++
+			}
+			if depth == 1 {
+				w.metrics.RootFields++
+			}
+			if depth > w.metrics.Depth {
+				w.metrics.Depth = depth
+			}
+			if err := w.walk(s.SelectionSet, depth+1); err != nil {
+				return err
+			}
+		case *InlineFragment:
+			if err := w.walk(s.SelectionSet, depth); err != nil {
+				return err
+			}
+		case *FragmentSpread:
+			frag := w.doc.FragmentByName(s.Name)
+			if frag == nil {
+				return fmt.Errorf("unknown fragment %q", s.Name)
+			}
+			if w.active[s.Name] {
+				return fmt.Errorf("fragment %q spreads itself", s.Name)
+			}
+			w.active[s.Name] = true
+			err := w.walk(frag.SelectionSet, depth)
+			delete(w.active, s.Name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Violations lists every limit the metrics exceed
+func (m Metrics) Violations(l Limits) []string {
+	var v []string
+	if l.MaxDepth > 0 && m.Depth > l.MaxDepth {
+		v = append(v, fmt.Sprintf("depth %d exceeds %d", m.Depth, l.MaxDepth))
+	}
+	if l.MaxAliases > 0 && m.Aliases > l.MaxAliases {
+		v = append(v, fmt.Sprintf("aliases %d exceed %d", m.Aliases, l.MaxAliases))
+	}
+	if l.MaxRootFields > 0 && m.RootFields > l.MaxRootFields {
+		v = append(v, fmt.Sprintf("root fields %d exceed %d", m.RootFields, l.MaxRootFields))
+	}
+	if l.MaxSelections > 0 && m.Selections > l.MaxSelections {
+		v = append(v, fmt.Sprintf("selections %d exceed %d", m.Selections, l.MaxSelections))
+	}
+	return v
+}
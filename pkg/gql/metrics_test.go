@@ -0,0 +1,120 @@
+package gql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComputeMetrics(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []Metrics
+	}{
+		{
+			name: "flat",
+			doc:  `query Q { a b c }`,
+			want: []Metrics{{Operation: "Q", Depth: 1, RootFields: 3, Selections: 3}},
+		},
+		{
+			name: "nested",
+			doc:  `{ user { posts { comments { author { name } } } } }`,
+			want: []Metrics{{Operation: "<anonymous #1>", Depth: 5, RootFields: 1, Selections: 5}},
+		},
+		{
+			name: "aliases",
+			doc:  `query A { a1: user(id: 1) { id } a2: user(id: 2) { id } a3: user(id: 3) { n: name } }`,
+			want: []Metrics{{Operation: "A", Depth: 2, Aliases: 4, RootFields: 3, Selections: 6}},
+		},
+		{
+			name: "fragment expansion counts toward depth",
+			doc: `query Deep { user { ...U } }
+				fragment U on User { friends { ...F } }
+				fragment F on User { friends { name } }`,
+			want: []Metrics{{Operation: "Deep", Depth: 4, RootFields: 1, Selections: 4}},
+		},
+		{
+			name: "fragment spread at the root counts root fields",
+			doc:  `query R { ...Roots } fragment Roots on Query { a b { c } }`,
+			want: []Metrics{{Operation: "R", Depth: 2, RootFields: 2, Selections: 3}},
+		},
+		{
+			name: "fragment spread twice is expanded twice",
+			doc:  `query T { u1: user { ...N } u2: user { ...N } } fragment N on User { first last }`,
+			want: []Metrics{{Operation: "T", Depth: 2, Aliases: 2, RootFields: 2, Selections: 6}},
+		},
+		{
+			name: "inline fragments add no depth",
+			doc:  `{ node { ... on User { name ... on Admin { level } } } }`,
+			want: []Metrics{{Operation: "<anonymous #1>", Depth: 2, RootFields: 1, Selections: 3}},
+		},
+		{
+			name: "operations are measured apart",
+			doc:  `query One { a { b } } mutation Two { x y z }`,
+			want: []Metrics{
+				{Operation: "One", Depth: 2, RootFields: 1, Selections: 2},
+				{Operation: "Two", Depth: 1, RootFields: 3, Selections: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ComputeMetrics(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ComputeMetrics() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeMetricsErrors(t *testing.T) {
+	tests := []struct {
+		name, doc, err string
+	}{
+		{"unknown fragment", `query Q { user { ...Missing } }`, `operation Q: unknown fragment "Missing"`},
+		{"self spread", `query Q { user { ...A } } fragment A on User { friends { ...A } }`, `fragment "A" spreads itself`},
+		{"cycle", `query Q { user { ...A } } fragment A on User { ...B } fragment B on User { ...A }`, `spreads itself`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ComputeMetrics(doc); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ComputeMetrics() error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMetricsViolations(t *testing.T) {
+	m := Metrics{Depth: 6, Aliases: 10, RootFields: 3, Selections: 40}
+	tests := []struct {
+		name   string
+		limits Limits
+		want   []string
+	}{
+		{"no limits", Limits{}, nil},
+		{"within", Limits{MaxDepth: 6, MaxAliases: 10, MaxRootFields: 3, MaxSelections: 40}, nil},
+		{"all exceeded", Limits{MaxDepth: 5, MaxAliases: 9, MaxRootFields: 2, MaxSelections: 39}, []string{
+			"depth 6 exceeds 5", "aliases 10 exceed 9", "root fields 3 exceed 2", "selections 40 exceed 39",
+		}},
+		{"depth only", Limits{MaxDepth: 3}, []string{"depth 6 exceeds 3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Violations(tt.limits); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Violations() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,442 @@
+package gql
+
+// parser is a recursive-descent parser over the token stream of a document
+type parser struct {
+	lex  *lexer
+	tok  Token
+	prev Token
+}
+
+// Parse parses an executable document made of operations and fragments.
+// Type system definitions (SDL) are rejected.
+func Parse(src string) (*Document, error) {
+	p := &parser{lex: &lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Source: src}
+	if p.tok.Kind == TokenEOF {
+		return nil, p.errorf("document contains no definitions")
+	}
+	for p.tok.Kind != TokenEOF {
+		switch {
+		case p.peekPunct("{"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.tok.Kind == TokenName && (p.tok.Value == OperationQuery || p.tok.Value == OperationMutation || p.tok.Value == OperationSubscription):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.tok.Kind == TokenName && p.tok.Value == "fragment":
+			frag, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			doc.Fragments = append(doc.Fragments, frag)
+		default:
+			return nil, p.errorf("unexpected %q, expected an operation or fragment definition", p.tok.Value)
+		}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.prev = p.tok
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return errorAt(p.lex.src, p.tok.Start, format, args...)
+}
+
+func (p *parser) peekPunct(value string) bool {
+	return p.tok.Kind == TokenPunct && p.tok.Value == value
+}
+
+func (p *parser) expectPunct(value string) error {
+	if !p.peekPunct(value) {
+		return p.errorf("expected %q, found %s", value, p.describe())
+	}
+	return p.advance()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.tok.Kind != TokenName {
+		return "", p.errorf("expected a name, found %s", p.describe())
+	}
+	name := p.tok.Value
+	return name, p.advance()
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if p.tok.Kind != TokenName || p.tok.Value != keyword {
+		return p.errorf("expected %q, found %s", keyword, p.describe())
+	}
+	return p.advance()
+}
+
+func (p *parser) describe() string {
+	if p.tok.Kind == TokenEOF {
+		return "end of document"
+	}
+	return "\"" + p.tok.Value + "\""
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	op := &Operation{Kind: OperationQuery, Start: p.tok.Start}
+	if p.peekPunct("{") {
+		set, err := p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+		op.SelectionSet = set
+		op.End = p.prev.End
+		return op, nil
+	}
+
+	op.Kind = p.tok.Value
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.Kind == TokenName {
+		op.Name = p.tok.Value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peekPunct("(") {
+		defs, err := p.parseVariableDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		op.VariableDefinitions = defs
+	}
+	directives, err := p.parseDirectives()
+	if err != nil {
+		return nil, err
+	}
+	op.Directives = directives
+	set, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.SelectionSet = set
+	op.End = p.prev.End
+	return op, nil
+}
+
+func (p *parser) parseFragment() (*Fragment, error) {
+	frag := &Fragment{Start: p.tok.Start}
+	if err := p.expectKeyword("fragment"); err != nil {
+		return nil, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, errorAt(p.lex.src, p.prev.Start, "fragment cannot be named \"on\"")
+	}
+	frag.Name = name
+	if err := p.expectKeyword("on"); err != nil {
+		return nil, err
+	}
+	if frag.TypeCondition, err = p.expectName(); err != nil {
+		return nil, err
+	}
+	if frag.Directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if frag.SelectionSet, err = p.parseSelectionSet(); err != nil {
+		return nil, err
+	}
+	frag.End = p.prev.End
+	return frag, nil
+}
+
+func (p *parser) parseVariableDefinitions() ([]*VariableDefinition, error) {
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	var defs []*VariableDefinition
+	for !p.peekPunct(")") {
+		if err := p.expectPunct("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		def := &VariableDefinition{Name: name, Type: typ}
+		if p.peekPunct("=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if def.DefaultValue, err = p.parseValue(true); err != nil {
+				return nil, err
+			}
+		}
+		if def.Directives, err = p.parseDirectives(); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	if len(defs) == 0 {
+		return nil, p.errorf("expected at least one variable definition")
+	}
+	return defs, p.advance()
+}
+
+func (p *parser) parseType() (*Type, error) {
+	var typ *Type
+	if p.peekPunct("[") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return nil, err
+		}
+		typ = &Type{Elem: elem}
+	} else {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		typ = &Type{Name: name}
+	}
+	if p.peekPunct("!") {
+		typ.NonNull = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return typ, nil
+}
+
+func (p *parser) parseDirectives() ([]*Directive, error) {
+	var directives []*Directive
+	for p.peekPunct("@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		d := &Directive{Name: name}
+		if p.peekPunct("(") {
+			if d.Arguments, err = p.parseArguments(); err != nil {
+				return nil, err
+			}
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+func (p *parser) parseArguments() ([]*Argument, error) {
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	var args []*Argument
+	for !p.peekPunct(")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, &Argument{Name: name, Value: value})
+	}
+	if len(args) == 0 {
+		return nil, p.errorf("expected at least one argument")
+	}
+	return args, p.advance()
+}
+
+func (p *parser) parseSelectionSet() ([]Selection, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	var set []Selection
+	for !p.peekPunct("}") {
+		sel, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, sel)
+	}
+	if len(set) == 0 {
+		return nil, p.errorf("selection set cannot be empty")
+	}
+	return set, p.advance()
+}
+
+func (p *parser) parseSelection() (Selection, error) {
+	if p.peekPunct("...") {
+		return p.parseFragmentSelection()
+	}
+
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	field := &Field{Name: name}
+	if p.peekPunct(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		field.Alias = name
+		if field.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peekPunct("(") {
+		if field.Arguments, err = p.parseArguments(); err != nil {
+			return nil, err
+		}
+	}
+	if field.Directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if p.peekPunct("{") {
+		if field.SelectionSet, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (p *parser) parseFragmentSelection() (Selection, error) {
+	if err := p.expectPunct("..."); err != nil {
+		return nil, err
+	}
+
+	if p.tok.Kind == TokenName && p.tok.Value != "on" {
+		spread := &FragmentSpread{Name: p.tok.Value}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var err error
+		if spread.Directives, err = p.parseDirectives(); err != nil {
+			return nil, err
+		}
+		return spread, nil
+	}
+
+	inline := &InlineFragment{}
+	var err error
+	if p.tok.Kind == TokenName && p.tok.Value == "on" {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if inline.TypeCondition, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	if inline.Directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if inline.SelectionSet, err = p.parseSelectionSet(); err != nil {
+		return nil, err
+	}
+	return inline, nil
+}
+
+// parseValue parses an input value. Variables are not allowed in constant
+// contexts such as variable default values.
+func (p *parser) parseValue(constant bool) (*Value, error) {
+	tok := p.tok
+	switch tok.Kind {
+	case TokenPunct:
+		switch tok.Value {
+		case "$":
+			if constant {
+				return nil, p.errorf("variables are not allowed in constant values")
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			return &Value{Kind: ValueVariable, Raw: name}, nil
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			v := &Value{Kind: ValueList}
+			for !p.peekPunct("]") {
+				item, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				v.List = append(v.List, item)
+			}
+			return v, p.advance()
+		case "{":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			v := &Value{Kind: ValueObject}
+			for !p.peekPunct("}") {
+				name, err := p.expectName()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expectPunct(":"); err != nil {
+					return nil, err
+				}
+				fieldValue, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				v.Fields = append(v.Fields, &ObjectField{Name: name, Value: fieldValue})
+			}
+			return v, p.advance()
+		}
+	case TokenInt:
+		return &Value{Kind: ValueInt, Raw: tok.Value}, p.advance()
+	case TokenFloat:
+		return &Value{Kind: ValueFloat, Raw: tok.Value}, p.advance()
+	case TokenString, TokenBlockString:
+		return &Value{Kind: ValueString, Raw: tok.Value}, p.advance()
+	case TokenName:
+		switch tok.Value {
+		case "true", "false":
+			return &Value{Kind: ValueBoolean, Raw: tok.Value}, p.advance()
+		case "null":
+			return &Value{Kind: ValueNull, Raw: tok.Value}, p.advance()
+		}
+		return &Value{Kind: ValueEnum, Raw: tok.Value}, p.advance()
+	}
+	return nil, p.errorf("expected a value, found %s", p.describe())
+}
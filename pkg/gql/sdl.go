@@ -0,0 +1,132 @@
+package gql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Entity is a federated entity type: an object or interface type definition
+// carrying one or more @key directives.
+type Entity struct {
+	Type string
+	// Keys holds the fields argument of each @key directive, such as "id" or "sku upc".
+	Keys []string
+}
+
+// Entities scans a subgraph SDL for type definitions and extensions annotated
+// with @key. Only the parts needed for that are interpreted; field definitions
+// and other type system definitions are skipped.
+func Entities(sdl string) ([]Entity, error) {
+	p := &parser{lex: &lexer{src: sdl}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var entities []Entity
+	index := make(map[string]int)
+	for p.tok.Kind != TokenEOF {
+		if p.peekPunct("{") {
+			// Field, enum and input definitions may use "type" as a name.
+			if err := p.skipBlock(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.tok.Kind != TokenName || (p.tok.Value != "type" && p.tok.Value != "interface") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.Kind != TokenName {
+			continue
+		}
+		typeName := p.tok.Value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		keys, err := p.scanKeys()
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		if i, ok := index[typeName]; ok {
+			entities[i].Keys = append(entities[i].Keys, keys...)
+			continue
+		}
+		index[typeName] = len(entities)
+		entities = append(entities, Entity{Type: typeName, Keys: keys})
+	}
+	return entities, nil
+}
+
+// scanKeys consumes the implements clause and directives following a type name
+// up to its field definitions and returns the fields of its @key directives.
+func (p *parser) scanKeys() ([]string, error) {
+	var keys []string
+	for p.tok.Kind != TokenEOF && !p.peekPunct("{") {
+		if !p.peekPunct("@") {
+			if p.tok.Kind == TokenName && (p.tok.Value == "type" || p.tok.Value == "interface" || p.tok.Value == "extend") {
+				// A definition without a body; let the caller look at the next one.
+				return keys, nil
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		directives, err := p.parseDirectives()
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range directives {
+			if d.Name != "key" {
+				continue
+			}
+			for _, arg := range d.Arguments {
+				if arg.Name == "fields" && arg.Value.Kind == ValueString {
+					keys = append(keys, unquote(arg.Value.Raw))
+				}
+			}
+		}
+	}
+	return keys, nil
+}
+
+// skipBlock consumes a brace-delimited block including nested blocks
+func (p *parser) skipBlock() error {
+	depth := 0
+	for p.tok.Kind != TokenEOF {
+		switch {
+		case p.peekPunct("{"):
+			depth++
+		case p.peekPunct("}"):
+			depth--
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+	return p.errorf("unterminated block")
+}
+
+// unquote returns the contents of a raw string or block string token
+func unquote(raw string) string {
+	if strings.HasPrefix(raw, `"""`) {
+		return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(raw, `"""`), `"""`))
+	}
+	if s, err := strconv.Unquote(raw); err == nil {
+		return s
+	}
+	return strings.Trim(raw, `"`)
+}
@@ -0,0 +1,200 @@
+package inference
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checkpointVersion is the version of the checkpoint file format.
+const checkpointVersion = 1
+
+// Checkpoint is the partial schema of a recovery run and what the run has
+// probed so far, saved so that an interrupted run resumes where it stopped.
+type Checkpoint struct {
+	Version  int    `json:"version"`
+	Endpoint string `json:"endpoint"`
+	// Seed orders the words of equal score, see Prioritize.
+	Seed int64 `json:"seed"`
+	// Requests is the number of probes sent so far.
+	Requests int `json:"requests"`
+	// Fields are the confirmed fields by type name.
+	Fields map[string][]string `json:"fields"`
+	// Arguments are the confirmed arguments by "Type.field". Arguments are
+	// only probed on confirmed fields.
+	Arguments map[string][]string `json:"arguments,omitempty"`
+	// Probed are the words already probed on each type or "Type.field".
+	Probed map[string][]string `json:"probed"`
+	// Suggested are the names the errors of the server suggested for each
+	// type or "Type.field", probed before the words of the wordlist.
+	Suggested map[string][]string `json:"suggested,omitempty"`
+	// Types are the types the errors of the server named for the fields
+	// ("Type.field") and arguments ("Type.field.argument"), as written in
+	// GraphQL, such as [User!]!.
+	Types map[string]string `json:"types,omitempty"`
+	// Paths are the fields leading from Query to each object type found.
+	Paths map[string][]string `json:"paths"`
+	// Scorer holds what the run learned from its hits and misses.
+	Scorer *AdaptiveScorer `json:"scorer"`
+}
+
+// NewCheckpoint returns the empty checkpoint of a run against endpoint.
+func NewCheckpoint(endpoint string, seed int64) *Checkpoint {
+	return &Checkpoint{
+		Version:   checkpointVersion,
+		Endpoint:  endpoint,
+		Seed:      seed,
+		Fields:    make(map[string][]string),
+		Arguments: make(map[string][]string),
+		Probed:    make(map[string][]string),
+		Suggested: make(map[string][]string),
+		Types:     make(map[string]string),
+		Paths:     map[string][]string{"Query": {}},
+		Scorer:    NewAdaptiveScorer(),
+	}
+}
+
+// LoadCheckpoint reads the checkpoint saved at path. A missing file yields
+// nil and no error, so the run starts afresh.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %w", err)
+	}
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("error parsing checkpoint %s: %w", path, err)
+	}
+	if c.Version != checkpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d in %s", c.Version, path)
+	}
+	if c.Fields == nil {
+		c.Fields = make(map[string][]string)
+	}
+	if c.Arguments == nil {
+		c.Arguments = make(map[string][]string)
+	}
+	if c.Probed == nil {
+		c.Probed = make(map[string][]string)
+	}
+	if c.Suggested == nil {
+		c.Suggested = make(map[string][]string)
+	}
+	if c.Types == nil {
+		c.Types = make(map[string]string)
+	}
+	if c.Paths == nil {
+		c.Paths = map[string][]string{"Query": {}}
+	}
+	if c.Scorer == nil {
+		c.Scorer = NewAdaptiveScorer()
+	}
+	return &c, nil
+}
+
+// Remaining returns the words not yet probed on key, a type name or
+// "Type.field", in the order the scorer of c gives them. Arguments of a field
+// that is not confirmed are not worth probing, so there are none left.
+func (c *Checkpoint) Remaining(key string, words []string) []string {
+	if typ, field, isArg := strings.Cut(key, "."); isArg && !c.Confirmed(typ, field) {
+		return nil
+	}
+	probed := make(map[string]bool, len(c.Probed[key]))
+	for _, w := range c.Probed[key] {
+		probed[w] = true
+	}
+	var left []string
+	for _, w := range words {
+		if !probed[w] {
+			left = append(left, w)
+		}
+	}
+	return Prioritize(c.Scorer, left, c.Seed)
+}
+
+// Record notes that word was probed on key with one request and whether it
+// was confirmed, as a field of a type or an argument of "Type.field".
+func (c *Checkpoint) Record(key, word string, hit bool) {
+	c.Requests++
+	c.Probed[key] = append(c.Probed[key], word)
+	c.Scorer.Observe(word, hit)
+	if !hit {
+		return
+	}
+	target := c.Fields
+	if strings.Contains(key, ".") {
+		target = c.Arguments
+	}
+	target[key] = append(target[key], word)
+	sort.Strings(target[key])
+}
+
+// Suggest notes that the errors of the server named word on key. It reports
+// whether word is new, neither probed nor suggested yet.
+func (c *Checkpoint) Suggest(key, word string) bool {
+	if contains(c.Probed[key], word) || contains(c.Suggested[key], word) {
+		return false
+	}
+	c.Suggested[key] = append(c.Suggested[key], word)
+	return true
+}
+
+// WasProbed reports whether word was probed on key.
+func (c *Checkpoint) WasProbed(key, word string) bool {
+	return contains(c.Probed[key], word)
+}
+
+// Confirmed reports whether field was confirmed on the type typ.
+func (c *Checkpoint) Confirmed(typ, field string) bool {
+	return contains(c.Fields[typ], field)
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes c to path, replacing the file at once so that a run killed
+// while saving leaves the previous checkpoint.
+func (c *Checkpoint) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling checkpoint: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// SaveEvery saves c to path when its request count is a multiple of every.
+// It does nothing when every is not positive.
+func (c *Checkpoint) SaveEvery(path string, every int) error {
+	if every <= 0 || c.Requests%every != 0 {
+		return nil
+	}
+	return c.Save(path)
+}
@@ -0,0 +1,76 @@
+package inference
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if c, err := LoadCheckpoint(path); c != nil || err != nil {
+		t.Fatalf("LoadCheckpoint of a missing file = %v, %v", c, err)
+	}
+
+	c := NewCheckpoint("http://example/graphql", 5)
+	c.Record("Query", "user", true)
+	c.Record("Query", "admin", false)
+	c.Record("Query.user", "id", true)
+	c.Suggest("Query", "users")
+	if err := c.SaveEvery(path, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint saved after 3 requests with every 2: %v", err)
+	}
+	c.Record("Query", "version", true)
+	if err := c.SaveEvery(path, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Requests != 4 || loaded.Seed != 5 || strings.Join(loaded.Fields["Query"], " ") != "user version" || strings.Join(loaded.Arguments["Query.user"], " ") != "id" {
+		t.Errorf("loaded checkpoint = %+v", loaded)
+	}
+	if got := strings.Join(loaded.Remaining("Query", []string{"user", "admin", "users", "version"}), " "); got != "users" {
+		t.Errorf("Remaining = %s, want users", got)
+	}
+	if loaded.Suggest("Query", "users") {
+		t.Error("users suggested twice")
+	}
+	if loaded.Scorer.Score("userName") <= 0 {
+		t.Errorf("the scorer forgot the hits: %+v", loaded.Scorer)
+	}
+}
+
+func TestCheckpointArgumentsOfUnconfirmedFields(t *testing.T) {
+	c := NewCheckpoint("http://example/graphql", 1)
+	c.Record("Query", "admin", false)
+	if left := c.Remaining("Query.admin", []string{"id"}); len(left) != 0 {
+		t.Errorf("Remaining arguments of a rejected field = %v", left)
+	}
+	c.Record("Query", "user", true)
+	if left := c.Remaining("Query.user", []string{"id"}); len(left) != 1 {
+		t.Errorf("Remaining arguments of a confirmed field = %v", left)
+	}
+}
+
+func TestLoadCheckpointRejects(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"version": `{"version": 2, "endpoint": "http://example/graphql"}`,
+		"syntax":  `{"version": 1,`,
+	} {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCheckpoint(path); err == nil {
+			t.Errorf("%s: checkpoint loaded", name)
+		}
+	}
+}
@@ -0,0 +1,563 @@
+package inference
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// rootTypes names the root type of each operation kind in an observed schema.
+var rootTypes = map[string]string{
+	gql.OperationQuery:        "Query",
+	gql.OperationMutation:     "Mutation",
+	gql.OperationSubscription: "Subscription",
+}
+
+// Descriptions of the observed fields and arguments whose type was guessed
+const (
+	descNullOnly   = "Observed: only null or empty lists were returned, the type is a guess"
+	descUnknownArg = "Observed: passed as a literal of unknown type"
+	descArgType    = "Observed: named only by a variable definition, its kind is unknown"
+)
+
+// Shape kinds of an observed value
+const (
+	shapeNull = iota
+	shapeScalar
+	shapeObject
+)
+
+// shape is the type observed for a field: a scalar or an object type, wrapped
+// in list levels. Fields that only returned null, or empty lists, stay
+// shapeNull until a value is seen.
+type shape struct {
+	kind   int
+	scalar string
+	// object is the key of the observed type in Observer.types.
+	object string
+	lists  int
+}
+
+type observedField struct {
+	name      string
+	shape     shape
+	args      []types.InputValue
+	conflicts []string
+}
+
+type observedType struct {
+	// name is the __typename of the objects, or empty for types only known by
+	// the query path they were returned at.
+	name   string
+	path   string
+	fields map[string]*observedField
+	order  []string
+}
+
+// Observer builds a schema from the responses of executed operations: each
+// object in a response is a type whose fields are the keys the document
+// selected, mapped back to field names through aliases and fragments. Objects
+// are merged across responses by their __typename or, without one, by the
+// query path they were returned at, so a type seen by several operations holds
+// the fields of all of them. The methods of a nil Observer do nothing, and an
+// Observer is safe for concurrent use.
+type Observer struct {
+	mu        sync.Mutex
+	types     map[string]*observedType
+	order     []string
+	pathTypes map[string]string
+	// argTypes are the named types of variables passed as arguments.
+	argTypes  map[string]bool
+	responses int
+}
+
+// NewObserver returns an empty Observer.
+func NewObserver() *Observer {
+	return &Observer{types: make(map[string]*observedType), pathTypes: make(map[string]string), argTypes: make(map[string]bool)}
+}
+
+// Responses returns the number of responses observed.
+func (o *Observer) Responses() int {
+	if o == nil {
+		return 0
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.responses
+}
+
+// Observe merges the data of response, returned for document, into the
+// schema. document must hold a single operation. Responses without data are
+// ignored.
+func (o *Observer) Observe(document string, response map[string]interface{}) error {
+	if o == nil {
+		return nil
+	}
+	data, ok := response["data"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	doc, err := gql.Parse(document)
+	if err != nil {
+		return fmt.Errorf("error parsing observed document: %w", err)
+	}
+	if len(doc.Operations) != 1 {
+		return fmt.Errorf("observed documents need a single operation, found %d", len(doc.Operations))
+	}
+	op := doc.Operations[0]
+	vars := make(map[string]*gql.Type, len(op.VariableDefinitions))
+	for _, def := range op.VariableDefinitions {
+		vars[def.Name] = def.Type
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.responses++
+	root := rootTypes[op.Kind]
+	w := &observeWalk{o: o, doc: doc, vars: vars}
+	w.object(root, o.typeNamed(root, root), op.SelectionSet, data)
+	return nil
+}
+
+// observeWalk carries the document of the response being observed.
+type observeWalk struct {
+	o    *Observer
+	doc  *gql.Document
+	vars map[string]*gql.Type
+}
+
+// object records the fields of value, selected by set at path, in the type key.
+func (w *observeWalk) object(path, key string, set []gql.Selection, value map[string]interface{}) {
+	for _, f := range w.collect(set, nil, map[string]bool{}) {
+		if f.Name == "__typename" {
+			continue
+		}
+		v, ok := value[f.ResponseKey()]
+		if !ok {
+			continue
+		}
+		field := w.o.types[key].field(f.Name)
+		w.args(field, f.Arguments)
+		field.merge(w.value(path+"."+f.Name, f, v), w.o)
+	}
+}
+
+// value returns the shape of v, returned for f at path, recording the fields
+// of the objects it holds.
+func (w *observeWalk) value(path string, f *gql.Field, v interface{}) shape {
+	switch v := v.(type) {
+	case nil:
+		return shape{kind: shapeNull}
+	case []interface{}:
+		elem := &observedField{shape: shape{kind: shapeNull}}
+		for _, item := range v {
+			elem.merge(w.value(path, f, item), w.o)
+		}
+		s := elem.shape
+		s.lists++
+		return s
+	case map[string]interface{}:
+		key := w.o.objectType(path, typename(f, v))
+		w.object(path, key, f.SelectionSet, v)
+		return shape{kind: shapeObject, object: w.o.resolve(key)}
+	case string:
+		return shape{kind: shapeScalar, scalar: "String"}
+	case bool:
+		return shape{kind: shapeScalar, scalar: "Boolean"}
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 {
+			return shape{kind: shapeScalar, scalar: "Int"}
+		}
+		return shape{kind: shapeScalar, scalar: "Float"}
+	default:
+		return shape{kind: shapeScalar, scalar: "String"}
+	}
+}
+
+// collect flattens set into its fields, following fragment spreads and inline
+// fragments. Fields selected several times under one response key are merged.
+func (w *observeWalk) collect(set []gql.Selection, fields []*gql.Field, spread map[string]bool) []*gql.Field {
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *gql.Field:
+			merged := false
+			for i, prev := range fields {
+				if prev.ResponseKey() == s.ResponseKey() {
+					copied := *prev
+					copied.SelectionSet = append(append([]gql.Selection{}, prev.SelectionSet...), s.SelectionSet...)
+					fields[i] = &copied
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				fields = append(fields, s)
+			}
+		case *gql.InlineFragment:
+			fields = w.collect(s.SelectionSet, fields, spread)
+		case *gql.FragmentSpread:
+			frag := w.doc.FragmentByName(s.Name)
+			if frag == nil || spread[s.Name] {
+				continue
+			}
+			spread[s.Name] = true
+			fields = w.collect(frag.SelectionSet, fields, spread)
+		}
+	}
+	return fields
+}
+
+// args records the arguments field was called with. Variables take their
+// declared type, literals the scalar type they are written as; the first
+// type seen for an argument is kept.
+func (w *observeWalk) args(field *observedField, args []*gql.Argument) {
+	for _, arg := range args {
+		in := types.InputValue{Name: arg.Name}
+		switch arg.Value.Kind {
+		case gql.ValueVariable:
+			if t, ok := w.vars[arg.Value.Raw]; ok {
+				in.Type = typeRefOf(t)
+				w.o.argTypes[t.NamedType()] = true
+			}
+		case gql.ValueInt:
+			in.Type = types.TypeRef{Kind: types.SCALAR, Name: "Int"}
+		case gql.ValueFloat:
+			in.Type = types.TypeRef{Kind: types.SCALAR, Name: "Float"}
+		case gql.ValueString:
+			in.Type = types.TypeRef{Kind: types.SCALAR, Name: "String"}
+		case gql.ValueBoolean:
+			in.Type = types.TypeRef{Kind: types.SCALAR, Name: "Boolean"}
+		}
+		if in.Type.Name == "" && in.Type.Kind == "" {
+			in.Type = types.TypeRef{Kind: types.SCALAR, Name: "String"}
+			in.Description = descUnknownArg
+		}
+		field.args = appendArg(field.args, in)
+	}
+}
+
+// typename returns the __typename of v as selected by f, under any alias.
+func typename(f *gql.Field, v map[string]interface{}) string {
+	if name, ok := v["__typename"].(string); ok {
+		return name
+	}
+	for _, sel := range f.SelectionSet {
+		if sf, ok := sel.(*gql.Field); ok && sf.Name == "__typename" {
+			name, _ := v[sf.ResponseKey()].(string)
+			return name
+		}
+	}
+	return ""
+}
+
+// typeNamed returns the key of the type called name, creating it.
+func (o *Observer) typeNamed(name, path string) string {
+	if _, ok := o.types[name]; !ok {
+		o.types[name] = &observedType{name: name, path: path, fields: make(map[string]*observedField)}
+		o.order = append(o.order, name)
+	}
+	return name
+}
+
+// objectType returns the key of the type of an object returned at path with
+// the given __typename, which may be empty. A type first known by its path is
+// merged into the named type once an object at that path tells its name.
+func (o *Observer) objectType(path, name string) string {
+	pathKey, known := o.pathTypes[path]
+	if name == "" {
+		if known {
+			return pathKey
+		}
+		key := "~" + path
+		o.pathTypes[path] = o.typeNamed(key, path)
+		o.types[key].name = ""
+		return key
+	}
+	key := o.typeNamed(name, path)
+	switch {
+	case !known:
+		o.pathTypes[path] = key
+	case o.types[pathKey] != nil && o.types[pathKey].name == "":
+		o.rename(pathKey, key)
+		o.pathTypes[path] = key
+	}
+	return key
+}
+
+// rename merges the unnamed type from into the type to and points every field
+// returning it at to.
+func (o *Observer) rename(from, to string) {
+	src, dst := o.types[from], o.types[to]
+	for _, name := range src.order {
+		f := src.fields[name]
+		target := dst.field(name)
+		for _, arg := range f.args {
+			target.args = appendArg(target.args, arg)
+		}
+		target.conflicts = append(target.conflicts, f.conflicts...)
+		target.merge(f.shape, o)
+	}
+	delete(o.types, from)
+	for i, key := range o.order {
+		if key == from {
+			o.order = append(o.order[:i], o.order[i+1:]...)
+			break
+		}
+	}
+	for path, key := range o.pathTypes {
+		if key == from {
+			o.pathTypes[path] = to
+		}
+	}
+	for _, t := range o.types {
+		for _, f := range t.fields {
+			if f.shape.object == from {
+				f.shape.object = to
+			}
+		}
+	}
+}
+
+// resolve returns the key a type is stored under after any rename.
+func (o *Observer) resolve(key string) string {
+	if _, ok := o.types[key]; ok {
+		return key
+	}
+	if to, ok := o.pathTypes[strings.TrimPrefix(key, "~")]; ok {
+		return to
+	}
+	return key
+}
+
+func (t *observedType) field(name string) *observedField {
+	f, ok := t.fields[name]
+	if !ok {
+		f = &observedField{name: name, shape: shape{kind: shapeNull}}
+		t.fields[name] = f
+		t.order = append(t.order, name)
+	}
+	return f
+}
+
+func appendArg(args []types.InputValue, arg types.InputValue) []types.InputValue {
+	for _, prev := range args {
+		if prev.Name == arg.Name {
+			return args
+		}
+	}
+	return append(args, arg)
+}
+
+// merge combines the shape s observed for f with the ones seen before. Null
+// says nothing about the type; Int widens to Float; any other pair of scalars,
+// for example a custom scalar serialized as numbers and strings, becomes
+// String; objects win over scalars and the deeper list over the shallower.
+// Every disagreement but Int and Float is recorded as a conflict.
+func (f *observedField) merge(s shape, o *Observer) {
+	cur := &f.shape
+	if s.kind != shapeNull && cur.kind != shapeNull && s.lists != cur.lists {
+		f.conflict(fmt.Sprintf("returned %d and %d list levels", cur.lists, s.lists))
+	}
+	if s.lists > cur.lists {
+		cur.lists = s.lists
+	}
+	switch {
+	case s.kind == shapeNull:
+	case cur.kind == shapeNull:
+		cur.kind, cur.scalar, cur.object = s.kind, s.scalar, s.object
+	case cur.kind == shapeScalar && s.kind == shapeScalar:
+		if cur.scalar == s.scalar {
+			return
+		}
+		if (cur.scalar == "Int" && s.scalar == "Float") || (cur.scalar == "Float" && s.scalar == "Int") {
+			cur.scalar = "Float"
+			return
+		}
+		f.conflict(fmt.Sprintf("returned %s and %s", cur.scalar, s.scalar))
+		cur.scalar = "String"
+	case cur.kind == shapeObject && s.kind == shapeObject:
+		if cur.object != s.object {
+			f.conflict(fmt.Sprintf("returned %s and %s", o.typeName(cur.object), o.typeName(s.object)))
+		}
+	case s.kind == shapeObject:
+		f.conflict(fmt.Sprintf("returned %s and objects", cur.scalar))
+		cur.kind, cur.scalar, cur.object = s.kind, "", s.object
+	default:
+		f.conflict(fmt.Sprintf("returned objects and %s", s.scalar))
+	}
+}
+
+func (f *observedField) conflict(c string) {
+	for _, prev := range f.conflicts {
+		if prev == c {
+			return
+		}
+	}
+	f.conflicts = append(f.conflicts, c)
+}
+
+// typeName returns the exported name of the type key: its __typename, or a
+// name made of the query path, such as ObservedUserPosts for Query.user.posts.
+func (o *Observer) typeName(key string) string {
+	t, ok := o.types[key]
+	if !ok {
+		return key
+	}
+	if t.name != "" {
+		return t.name
+	}
+	segments := strings.Split(t.path, ".")
+	var b strings.Builder
+	b.WriteString("Observed")
+	for _, s := range segments[1:] {
+		if s != "" {
+			b.WriteString(strings.ToUpper(s[:1]) + s[1:])
+		}
+	}
+	return b.String()
+}
+
+// typeRefOf converts a variable type to an introspection type reference.
+func typeRefOf(t *gql.Type) types.TypeRef {
+	var ref types.TypeRef
+	if t.Elem != nil {
+		elem := typeRefOf(t.Elem)
+		ref = types.TypeRef{Kind: types.LIST, OfType: &elem}
+	} else {
+		kind := types.SCALAR
+		if _, builtin := builtinScalars[t.Name]; !builtin {
+			// Only the name is known; the schema lists it as a placeholder.
+			kind = types.INPUT_OBJECT
+		}
+		ref = types.TypeRef{Kind: kind, Name: t.Name}
+	}
+	if t.NonNull {
+		inner := ref
+		ref = types.TypeRef{Kind: types.NON_NULL, OfType: &inner}
+	}
+	return ref
+}
+
+// builtinScalars are always part of an observed schema.
+var builtinScalars = map[string]struct{}{"String": {}, "Int": {}, "Float": {}, "Boolean": {}, "ID": {}}
+
+// Schema returns the observed schema in the form of an introspection result.
+// Fields are never non-null, since a response cannot show it, and fields that
+// only returned null or empty lists are typed String with a description saying so.
+func (o *Observer) Schema() types.Schema {
+	s := types.Schema{Types: []types.Type{}, Directives: []types.Directive{}}
+	if o == nil {
+		return s
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	names := make(map[string]string, len(o.types))
+	used := make(map[string]int)
+	for key := range builtinScalars {
+		used[key]++
+	}
+	for _, key := range o.order {
+		name := o.typeName(key)
+		if n := used[name]; n > 0 {
+			name = fmt.Sprintf("%s%d", name, n+1)
+		}
+		used[o.typeName(key)]++
+		names[key] = name
+	}
+
+	for _, key := range o.order {
+		t := o.types[key]
+		out := types.Type{Kind: types.OBJECT, Name: names[key], Fields: []types.Field{}, Interfaces: []types.TypeRef{}}
+		if t.name == "" {
+			out.Description = "Observed at " + t.path
+		}
+		for _, fname := range t.order {
+			f := t.fields[fname]
+			field := types.Field{Name: fname, Args: f.args, Type: o.shapeRef(f.shape, names)}
+			if field.Args == nil {
+				field.Args = []types.InputValue{}
+			}
+			switch {
+			case len(f.conflicts) > 0:
+				field.Description = "Observed: " + strings.Join(f.conflicts, "; ")
+			case f.shape.kind == shapeNull:
+				field.Description = descNullOnly
+			}
+			out.Fields = append(out.Fields, field)
+		}
+		s.Types = append(s.Types, out)
+	}
+
+	scalars := make([]string, 0, len(builtinScalars))
+	for name := range builtinScalars {
+		scalars = append(scalars, name)
+	}
+	sort.Strings(scalars)
+	for _, name := range scalars {
+		s.Types = append(s.Types, types.Type{Kind: types.SCALAR, Name: name})
+	}
+	var argTypes []string
+	for name := range o.argTypes {
+		if _, builtin := builtinScalars[name]; !builtin && used[name] == 0 {
+			argTypes = append(argTypes, name)
+		}
+	}
+	sort.Strings(argTypes)
+	for _, name := range argTypes {
+		s.Types = append(s.Types, types.Type{Kind: types.INPUT_OBJECT, Name: name, Description: descArgType, InputFields: []types.InputValue{}})
+	}
+
+	for kind, root := range map[string]**types.SchemaType{
+		"Query":        &s.QueryType,
+		"Mutation":     &s.MutationType,
+		"Subscription": &s.SubscriptionType,
+	} {
+		if _, ok := o.types[kind]; ok {
+			*root = &types.SchemaType{Name: kind}
+		}
+	}
+	return s
+}
+
+// shapeRef returns the type reference of s, with the exported type names.
+func (o *Observer) shapeRef(s shape, names map[string]string) types.TypeRef {
+	ref := types.TypeRef{Kind: types.SCALAR, Name: "String"}
+	switch s.kind {
+	case shapeScalar:
+		ref.Name = s.scalar
+	case shapeObject:
+		ref = types.TypeRef{Kind: types.OBJECT, Name: names[s.object]}
+	}
+	for i := 0; i < s.lists; i++ {
+		inner := ref
+		ref = types.TypeRef{Kind: types.LIST, OfType: &inner}
+	}
+	return ref
+}
+
+// WriteFile writes the observed schema to path as an introspection result,
+// which --introspection-file and --schema-file load like any other.
+func (o *Observer) WriteFile(path string) error {
+	if o == nil {
+		return errors.New("no schema was observed")
+	}
+	var doc types.IntrospectionResponse
+	doc.Data.Schema = o.Schema()
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling observed schema: %w", err)
+	}
+	if err := artifacts.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing observed schema: %w", err)
+	}
+	return nil
+}
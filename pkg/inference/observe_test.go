@@ -0,0 +1,170 @@
+package inference
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// observeFixture is a response to a document.
+type observeFixture struct {
+	Document string                 `json:"document"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// observeFixtures feeds the fixtures of testdata/observe, in the order of
+// their names, to a new Observer.
+func observeFixtures(t *testing.T) *Observer {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "observe", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	o := NewObserver()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f observeFixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if err := o.Observe(f.Document, f.Response); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+	}
+	return o
+}
+
+// renderRef prints ref in SDL notation, without non-null wrappers.
+func renderRef(ref types.TypeRef) string {
+	if ref.Kind == types.NON_NULL {
+		return renderRef(*ref.OfType)
+	}
+	if ref.Kind == types.LIST {
+		return "[" + renderRef(*ref.OfType) + "]"
+	}
+	return ref.Name
+}
+
+// renderObserved prints the object types of s, a field per line with its
+// arguments and description.
+func renderObserved(s types.Schema) string {
+	var b strings.Builder
+	for _, t := range s.Types {
+		if t.Kind != types.OBJECT {
+			continue
+		}
+		fmt.Fprintf(&b, "type %s", t.Name)
+		if t.Description != "" {
+			fmt.Fprintf(&b, " # %s", t.Description)
+		}
+		b.WriteString("\n")
+		for _, f := range t.Fields {
+			var args []string
+			for _, a := range f.Args {
+				args = append(args, a.Name+": "+renderRef(a.Type))
+			}
+			fmt.Fprintf(&b, "  %s", f.Name)
+			if len(args) > 0 {
+				fmt.Fprintf(&b, "(%s)", strings.Join(args, ", "))
+			}
+			fmt.Fprintf(&b, ": %s", renderRef(f.Type))
+			if f.Description != "" {
+				fmt.Fprintf(&b, " # %s", f.Description)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func TestObserverMergesFixtures(t *testing.T) {
+	o := observeFixtures(t)
+	// The response holding only errors is not counted.
+	if n := o.Responses(); n != 6 {
+		t.Errorf("Responses = %d, want 6", n)
+	}
+	got := renderObserved(o.Schema())
+	want := `type Query
+  user(id: String): User
+  feed(first: Int): [ObservedFeed]
+  search(term: String): [ObservedSearch]
+type User
+  id: String # Observed: returned String and Int
+  name: String
+  age: Float
+  posts: [ObservedUserPosts]
+  score: Float
+  avatar: String # ` + descNullOnly + `
+type ObservedUserPosts # Observed at Query.user.posts
+  id: String
+  title: String
+type ObservedFeed # Observed at Query.feed
+  author: User
+  tags: [[String]] # ` + descNullOnly + `
+type ObservedSearch # Observed at Query.search
+  id: String
+  title: String
+  published: Boolean
+type Mutation
+  like(post: String): Post
+type Post
+  id: String
+  likes: Int
+`
+	if got != want {
+		t.Errorf("observed schema =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestObserverSchemaFile(t *testing.T) {
+	o := observeFixtures(t)
+	path := filepath.Join(t.TempDir(), "observed.json")
+	if err := o.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc types.IntrospectionResponse
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	s := doc.Data.Schema
+	if s.QueryType == nil || s.QueryType.Name != "Query" || s.MutationType == nil || s.SubscriptionType != nil {
+		t.Errorf("root types = %+v %+v %+v", s.QueryType, s.MutationType, s.SubscriptionType)
+	}
+	var inputs []string
+	for _, typ := range s.Types {
+		if typ.Kind == types.INPUT_OBJECT {
+			inputs = append(inputs, typ.Name)
+		}
+	}
+	// $id is declared as ID, a built-in scalar, so no placeholder is listed.
+	if len(inputs) != 0 {
+		t.Errorf("input placeholders = %v", inputs)
+	}
+}
+
+func TestObserverRejects(t *testing.T) {
+	o := NewObserver()
+	resp := map[string]interface{}{"data": map[string]interface{}{"a": 1.0}}
+	if err := o.Observe("query A { a } query B { a }", resp); err == nil {
+		t.Error("Observe accepted a document of two operations")
+	}
+	if err := o.Observe("query {", resp); err == nil {
+		t.Error("Observe accepted a malformed document")
+	}
+	var nilObserver *Observer
+	if err := nilObserver.Observe("{ a }", resp); err != nil || nilObserver.Responses() != 0 {
+		t.Error("a nil Observer does not ignore responses")
+	}
+}
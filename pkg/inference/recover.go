@@ -0,0 +1,594 @@
+package inference
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Description of the recovered fields whose type no error named
+const descUnknownType = "Recovered: no error named the type, it is a guess"
+
+// Validation errors of graphql-js, and of the servers that copy its wording,
+// that show what a probe hit or missed.
+var (
+	// cannotQuery is the error of a field the type does not have, with the
+	// close names of the schema as suggestions.
+	cannotQuery = regexp.MustCompile(`^Cannot query field "([^"]+)" on type "([^"]+)"\.(?: Did you mean (.+)\?)?`)
+	// needsSelection is the error of an object field selected as a leaf.
+	needsSelection = regexp.MustCompile(`^Field "([^"]+)" of type "([^"]+)" must have a selection of subfields`)
+	// requiredArgument is the error of a required argument left out.
+	requiredArgument = regexp.MustCompile(`^Field "([^"]+)" argument "([^"]+)" of type "([^"]+)" is required`)
+	// unknownArgument is the error of an argument the field does not have,
+	// on "Type.field" or, in older versions, on "field" of type "Type".
+	unknownArgument = regexp.MustCompile(`^Unknown argument "([^"]+)" on field "([^"]+)"(?: of type "[^"]+")?\.(?: Did you mean (.+)\?)?`)
+	// expectedType and cannotRepresent are the errors of an argument value of
+	// the wrong type, naming the type of the argument.
+	expectedType    = regexp.MustCompile(`^Expected (?:value of )?type "?([^",]+?)"?, found`)
+	cannotRepresent = regexp.MustCompile(`^(?:Enum )?"?([_A-Za-z][_0-9A-Za-z]*)"? cannot represent`)
+	// quotedName matches the quoted names of a suggestion list.
+	quotedName = regexp.MustCompile(`"([_A-Za-z][_0-9A-Za-z]*)"`)
+)
+
+// Send sends document to the endpoint being recovered and returns the
+// decoded response.
+type Send func(ctx context.Context, document string) (map[string]interface{}, error)
+
+// Recovery brute forces the schema of an endpoint that does not answer
+// introspection: every word of Fields is selected on Query, and on each
+// object type found below it, and the validation errors of the server tell
+// which ones exist, their type and, through their suggestions, more names
+// to probe. The words of Arguments are then passed to each confirmed field.
+// Words are probed in the order the scorer of the checkpoint gives them, in
+// rounds of Workers probes sent at once.
+type Recovery struct {
+	Send Send
+	// Fields and Arguments are the candidate names, such as those read by
+	// EachWord. Without Arguments no argument is probed.
+	Fields    []string
+	Arguments []string
+	// Workers is the number of probes in flight, 1 when not positive.
+	Workers int
+	// Checkpoint, when set, is the file the checkpoint is saved to every
+	// Every requests.
+	Checkpoint string
+	Every      int
+}
+
+// probeResult is what the response to a probe showed.
+type probeResult struct {
+	hit bool
+	// typ is the type of the probed field or argument, when an error named
+	// it.
+	typ string
+	// suggestions are the names of the probed type, or arguments of the
+	// probed field, the errors suggested.
+	suggestions []string
+	// required are the types of the required arguments of the probed field
+	// by name.
+	required map[string]string
+}
+
+// Run probes the types of c, starting from Query, until every word was
+// probed on every type found and every argument on every field confirmed,
+// and records the results in c. Types are recovered one after the other,
+// fields then arguments, in the order of types, so a checkpoint saved by an
+// interrupted run resumes where it stopped. Run stops with the error of ctx
+// when ctx is done and with the error of Send when a probe cannot be sent;
+// c then holds what was found so far.
+func (rc *Recovery) Run(ctx context.Context, c *Checkpoint) error {
+	// The types found while recovering one have a longer path, so they sort
+	// after it and the types before it are left in place.
+	for i := 0; i < len(c.Paths); i++ {
+		if err := rc.recoverType(ctx, c, c.types()[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// types returns the object types of c to probe, Query first and the others
+// in the order of their paths, which grows as the run finds types.
+func (c *Checkpoint) types() []string {
+	names := make([]string, 0, len(c.Paths))
+	for name := range c.Paths {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := c.Paths[names[i]], c.Paths[names[j]]
+		if len(pi) != len(pj) {
+			return len(pi) < len(pj)
+		}
+		return strings.Join(pi, ".") < strings.Join(pj, ".")
+	})
+	return names
+}
+
+// recoverType probes the field names on typ, noting the object types of the
+// fields it confirms, then the argument names on those fields.
+func (rc *Recovery) recoverType(ctx context.Context, c *Checkpoint, typ string) error {
+	for {
+		words := rc.round(c, typ, rc.Fields)
+		if len(words) == 0 {
+			break
+		}
+		err := rc.probe(ctx, c, typ, words, func(word string) string {
+			return probeDocument(c.Paths[typ], word)
+		}, func(word string, resp map[string]interface{}) {
+			c.recordField(typ, word, classifyField(resp, typ, word))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(rc.Arguments) == 0 {
+		return nil
+	}
+	for _, field := range c.Fields[typ] {
+		if err := rc.recoverArguments(ctx, c, typ, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordField records the probe of the field word on typ and the path to the
+// object type the field leads to when no other path reached it yet.
+func (c *Checkpoint) recordField(typ, word string, res probeResult) {
+	c.Record(typ, word, res.hit)
+	for _, s := range res.suggestions {
+		c.Suggest(typ, s)
+	}
+	if !res.hit {
+		return
+	}
+	key := typ + "." + word
+	if res.typ != "" {
+		c.Types[key] = res.typ
+	}
+	c.requireArguments(key, res.required)
+	object := namedType(res.typ)
+	if _, found := c.Paths[object]; object != "" && !found {
+		c.Paths[object] = append(append([]string{}, c.Paths[typ]...), word)
+	}
+}
+
+// requireArguments records the required arguments of the field key,
+// "Type.field", and their types, and suggests probing them.
+func (c *Checkpoint) requireArguments(key string, required map[string]string) {
+	names := make([]string, 0, len(required))
+	for arg := range required {
+		names = append(names, arg)
+	}
+	sort.Strings(names)
+	for _, arg := range names {
+		c.Types[key+"."+arg] = required[arg]
+		c.Suggest(key, arg)
+	}
+}
+
+// recoverArguments probes the argument names on the field of typ.
+func (rc *Recovery) recoverArguments(ctx context.Context, c *Checkpoint, typ, field string) error {
+	key := typ + "." + field
+	selection := ""
+	if _, object := c.Types[key]; object {
+		selection = " { __typename }"
+	}
+	for {
+		words := rc.round(c, key, rc.Arguments)
+		if len(words) == 0 {
+			return nil
+		}
+		err := rc.probe(ctx, c, key, words, func(word string) string {
+			return probeDocument(c.Paths[typ], field+"("+word+": 0)"+selection)
+		}, func(word string, resp map[string]interface{}) {
+			res := classifyArgument(resp, field, word)
+			c.Record(key, word, res.hit)
+			for _, s := range res.suggestions {
+				c.Suggest(key, s)
+			}
+			c.requireArguments(key, res.required)
+			if res.hit && res.typ != "" {
+				c.Types[key+"."+word] = res.typ
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// round returns the words to probe next on key, a type or "Type.field": the
+// suggested names first, then the words not probed yet in the order of the
+// scorer, up to Workers of them. Arguments of a field that is not confirmed
+// are never probed.
+func (rc *Recovery) round(c *Checkpoint, key string, words []string) []string {
+	n := rc.Workers
+	if n < 1 {
+		n = 1
+	}
+	var next []string
+	for _, w := range c.Suggested[key] {
+		if len(next) < n && !c.WasProbed(key, w) {
+			next = append(next, w)
+		}
+	}
+	for _, w := range c.Remaining(key, words) {
+		if len(next) == n {
+			break
+		}
+		if !contains(next, w) {
+			next = append(next, w)
+		}
+	}
+	return next
+}
+
+// probe sends the documents of words on key at once and passes the
+// responses to record in the order of words, saving the checkpoint every
+// Every requests. When a probe cannot be sent, the responses before it are
+// recorded and its error returned; the words after it stay unprobed.
+func (rc *Recovery) probe(ctx context.Context, c *Checkpoint, key string, words []string, document func(string) string, record func(string, map[string]interface{})) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	responses := make([]map[string]interface{}, len(words))
+	errs := make([]error, len(words))
+	var wg sync.WaitGroup
+	for i, w := range words {
+		wg.Add(1)
+		go func(i int, doc string) {
+			defer wg.Done()
+			responses[i], errs[i] = rc.Send(ctx, doc)
+		}(i, document(w))
+	}
+	wg.Wait()
+	for i, w := range words {
+		if errs[i] != nil {
+			return fmt.Errorf("error probing %s with %s: %w", key, w, errs[i])
+		}
+		record(w, responses[i])
+		if rc.Checkpoint != "" {
+			if err := c.SaveEvery(rc.Checkpoint, rc.Every); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// probeDocument returns the query selecting selection below the fields of
+// path, such as query { user { posts { title } } }.
+func probeDocument(path []string, selection string) string {
+	var b strings.Builder
+	b.WriteString("query { ")
+	for _, f := range path {
+		b.WriteString(f + " { ")
+	}
+	b.WriteString(selection)
+	for range path {
+		b.WriteString(" }")
+	}
+	b.WriteString(" }")
+	return b.String()
+}
+
+// responseError is an error of a probe response.
+type responseError struct {
+	message string
+	// code is its extensions.code, if any.
+	code string
+}
+
+// responseErrors returns the errors of resp that carry a message.
+func responseErrors(resp map[string]interface{}) []responseError {
+	errs, _ := resp["errors"].([]interface{})
+	found := make([]responseError, 0, len(errs))
+	for _, e := range errs {
+		m, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		msg, ok := m["message"].(string)
+		if !ok {
+			continue
+		}
+		var code string
+		if ext, ok := m["extensions"].(map[string]interface{}); ok {
+			code, _ = ext["code"].(string)
+		}
+		found = append(found, responseError{message: msg, code: code})
+	}
+	return found
+}
+
+// localizedResult is what the error classifier reads from an error that none
+// of the graphql-js messages matched, such as one a server translated.
+type localizedResult struct {
+	// validated is set for a validation error naming anything.
+	validated bool
+	// named are the names quoted before its suggestions, the probed name
+	// first, and suggested those it suggests.
+	named, suggested []string
+	// rejects is set when it rejects the name it quotes first: its phrases
+	// are those of an unknown name, or it suggests other names.
+	rejects bool
+}
+
+// classifyLocalized reads e through the error classifier. Its phrases, or
+// only its extensions.code, make it a validation error; it rejects the name
+// it quotes first when its phrases do, or when it suggests names, since a
+// code alone also marks the errors of a known field selected without its
+// subfields or arguments. Localized errors never name the type of a field.
+func classifyLocalized(e responseError) localizedResult {
+	var res localizedResult
+	if !hasClass(gql.ErrorClasses(e.message, e.code), data.ErrorValidation) {
+		return res
+	}
+	res.named, res.suggested = gql.QuotedNames(e.message)
+	if len(res.named) == 0 {
+		return res
+	}
+	res.validated = true
+	res.rejects = len(res.suggested) > 0 || hasClass(gql.ErrorClasses(e.message, ""), data.ErrorValidation)
+	return res
+}
+
+// hasClass reports whether classes holds class.
+func hasClass(classes []string, class string) bool {
+	for _, c := range classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyField reads the response to the probe of the field word on typ. A
+// field is confirmed when the server validated the document, answering with
+// data, without errors or with the validation errors of known fields, and
+// did not reject word. Servers that reject every probe with the same error
+// confirm nothing.
+func classifyField(resp map[string]interface{}, typ, word string) probeResult {
+	var res probeResult
+	errs := responseErrors(resp)
+	validated := len(errs) == 0 || resp["data"] != nil
+	rejected := false
+	for _, e := range errs {
+		msg := e.message
+		if m := cannotQuery.FindStringSubmatch(msg); m != nil {
+			validated = true
+			if m[2] != typ {
+				continue
+			}
+			if m[1] == word {
+				rejected = true
+			}
+			res.suggestions = append(res.suggestions, suggestedNames(m[3])...)
+			continue
+		}
+		if m := needsSelection.FindStringSubmatch(msg); m != nil {
+			validated = true
+			if m[1] == word {
+				res.typ = m[2]
+			}
+			continue
+		}
+		if m := requiredArgument.FindStringSubmatch(msg); m != nil {
+			validated = true
+			if m[1] == word {
+				if res.required == nil {
+					res.required = make(map[string]string)
+				}
+				res.required[m[2]] = m[3]
+			}
+			continue
+		}
+		// The second name of an unknown field error is its type.
+		if l := classifyLocalized(e); l.validated {
+			validated = true
+			if l.rejects && len(l.named) > 1 && l.named[1] != typ {
+				continue
+			}
+			if l.rejects && l.named[0] == word {
+				rejected = true
+			}
+			res.suggestions = append(res.suggestions, l.suggested...)
+		}
+	}
+	res.hit = validated && !rejected
+	return res
+}
+
+// classifyArgument reads the response to the probe of the argument word,
+// passed 0, on field. Like a field, an argument is confirmed when the server
+// validated the document and did not reject word; a value error names its
+// type.
+func classifyArgument(resp map[string]interface{}, field, word string) probeResult {
+	var res probeResult
+	errs := responseErrors(resp)
+	validated := len(errs) == 0 || resp["data"] != nil
+	rejected := false
+	for _, e := range errs {
+		msg := e.message
+		if m := unknownArgument.FindStringSubmatch(msg); m != nil {
+			validated = true
+			if m[2] != field && !strings.HasSuffix(m[2], "."+field) {
+				continue
+			}
+			if m[1] == word {
+				rejected = true
+			}
+			res.suggestions = append(res.suggestions, suggestedNames(m[3])...)
+			continue
+		}
+		if m := requiredArgument.FindStringSubmatch(msg); m != nil {
+			validated = true
+			if m[1] == field {
+				if res.required == nil {
+					res.required = make(map[string]string)
+				}
+				res.required[m[2]] = m[3]
+			}
+			continue
+		}
+		if m := expectedType.FindStringSubmatch(msg); m != nil {
+			validated, res.typ = true, m[1]
+			continue
+		}
+		if m := cannotRepresent.FindStringSubmatch(msg); m != nil {
+			validated, res.typ = true, m[1]
+			continue
+		}
+		if cannotQuery.MatchString(msg) || needsSelection.MatchString(msg) {
+			validated = true
+			continue
+		}
+		// The second name of an unknown argument error is its field.
+		if l := classifyLocalized(e); l.validated {
+			validated = true
+			if l.rejects && len(l.named) > 1 && l.named[1] != field && !strings.HasSuffix(l.named[1], "."+field) {
+				continue
+			}
+			if l.rejects && l.named[0] == word {
+				rejected = true
+			}
+			res.suggestions = append(res.suggestions, l.suggested...)
+		}
+	}
+	res.hit = validated && !rejected
+	return res
+}
+
+// suggestedNames returns the names of the "Did you mean" list of an error.
+// Suggestions of an inline fragment name types, not fields, and are left out.
+func suggestedNames(list string) []string {
+	if list == "" || strings.HasPrefix(list, "to use an inline fragment") {
+		return nil
+	}
+	var names []string
+	for _, m := range quotedName.FindAllStringSubmatch(list, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// namedType returns the named type of a type written in GraphQL, such as
+// User for [User!]!, or "" for "".
+func namedType(t string) string {
+	return strings.Trim(t, "[]!")
+}
+
+// typeRef converts a type written in GraphQL to a type reference, with kind
+// the kind of its named type.
+func typeRef(t string, kind types.TypeKind) types.TypeRef {
+	switch {
+	case strings.HasSuffix(t, "!"):
+		inner := typeRef(strings.TrimSuffix(t, "!"), kind)
+		return types.TypeRef{Kind: types.NON_NULL, OfType: &inner}
+	case strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]"):
+		inner := typeRef(t[1:len(t)-1], kind)
+		return types.TypeRef{Kind: types.LIST, OfType: &inner}
+	}
+	return types.TypeRef{Kind: kind, Name: t}
+}
+
+// Schema returns the recovered schema in the form of an introspection
+// result. Fields no error typed are String with a description saying so;
+// object types found but not probed yet have no fields.
+func (c *Checkpoint) Schema() types.Schema {
+	s := types.Schema{Types: []types.Type{}, Directives: []types.Directive{}}
+	for _, name := range c.types() {
+		out := types.Type{Kind: types.OBJECT, Name: name, Fields: []types.Field{}, Interfaces: []types.TypeRef{}}
+		for _, fname := range c.Fields[name] {
+			key := name + "." + fname
+			field := types.Field{Name: fname, Args: []types.InputValue{}, Type: types.TypeRef{Kind: types.SCALAR, Name: "String"}}
+			if t, ok := c.Types[key]; ok {
+				field.Type = typeRef(t, types.OBJECT)
+			} else {
+				field.Description = descUnknownType
+			}
+			for _, arg := range c.arguments(key) {
+				field.Args = append(field.Args, types.InputValue{Name: arg, Type: c.argumentRef(key + "." + arg)})
+			}
+			out.Fields = append(out.Fields, field)
+		}
+		s.Types = append(s.Types, out)
+	}
+
+	scalars := make([]string, 0, len(builtinScalars))
+	for name := range builtinScalars {
+		scalars = append(scalars, name)
+	}
+	sort.Strings(scalars)
+	for _, name := range scalars {
+		s.Types = append(s.Types, types.Type{Kind: types.SCALAR, Name: name})
+	}
+	var inputs []string
+	for key, t := range c.Types {
+		name := namedType(t)
+		_, builtin := builtinScalars[name]
+		_, object := c.Paths[name]
+		if strings.Count(key, ".") == 2 && !builtin && !object && !contains(inputs, name) {
+			inputs = append(inputs, name)
+		}
+	}
+	sort.Strings(inputs)
+	for _, name := range inputs {
+		s.Types = append(s.Types, types.Type{Kind: types.INPUT_OBJECT, Name: name, Description: descArgType, InputFields: []types.InputValue{}})
+	}
+	s.QueryType = &types.SchemaType{Name: "Query"}
+	return s
+}
+
+// arguments returns the names of the arguments of the field key, "Type.field",
+// that were confirmed or that the errors of the server named, sorted.
+func (c *Checkpoint) arguments(key string) []string {
+	names := append([]string{}, c.Arguments[key]...)
+	prefix := key + "."
+	for k := range c.Types {
+		if arg, ok := strings.CutPrefix(k, prefix); ok && !contains(names, arg) {
+			names = append(names, arg)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// argumentRef returns the type reference of the argument key,
+// "Type.field.argument", String when no error typed it.
+func (c *Checkpoint) argumentRef(key string) types.TypeRef {
+	t, ok := c.Types[key]
+	if !ok {
+		return types.TypeRef{Kind: types.SCALAR, Name: "String"}
+	}
+	kind := types.SCALAR
+	if _, builtin := builtinScalars[namedType(t)]; !builtin {
+		kind = types.INPUT_OBJECT
+	}
+	return typeRef(t, kind)
+}
+
+// WriteSchema writes the recovered schema to path as an introspection
+// result, which --introspection-file and --schema-file load like any other.
+func (c *Checkpoint) WriteSchema(path string) error {
+	var doc types.IntrospectionResponse
+	doc.Data.Schema = c.Schema()
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling recovered schema: %w", err)
+	}
+	if err := artifacts.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing recovered schema: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,551 @@
+package inference
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// mockField is a field of a mockSchema: its type as written in GraphQL and
+// the types of its arguments by name.
+type mockField struct {
+	typ  string
+	args map[string]string
+}
+
+// mockSchema validates probes by type name and field name the way graphql-js
+// does, with its error messages, and never executes them.
+type mockSchema struct {
+	types map[string]map[string]mockField
+
+	mu       sync.Mutex
+	requests int
+}
+
+// recoverySchema is the schema the recovery tests brute force.
+func recoverySchema() *mockSchema {
+	return &mockSchema{types: map[string]map[string]mockField{
+		"Query": {
+			"user":    {typ: "User", args: map[string]string{"id": "ID!"}},
+			"users":   {typ: "[User!]!", args: map[string]string{"first": "Int", "filter": "UserFilter"}},
+			"version": {typ: "String"},
+		},
+		"User": {
+			"id":    {typ: "ID!"},
+			"name":  {typ: "String"},
+			"email": {typ: "String"},
+			"posts": {typ: "[Post]", args: map[string]string{"orderBy": "String"}},
+		},
+		"Post": {
+			"id":    {typ: "ID!"},
+			"title": {typ: "String"},
+		},
+	}}
+}
+
+// send implements Send.
+func (m *mockSchema) send(_ context.Context, document string) (map[string]interface{}, error) {
+	m.mu.Lock()
+	m.requests++
+	m.mu.Unlock()
+	doc, err := gql.Parse(document)
+	if err != nil {
+		return nil, err
+	}
+	var messages []string
+	m.validate("Query", doc.Operations[0].SelectionSet, &messages)
+	if len(messages) == 0 {
+		return map[string]interface{}{"data": map[string]interface{}{}}, nil
+	}
+	errs := make([]interface{}, len(messages))
+	for i, msg := range messages {
+		errs[i] = map[string]interface{}{"message": msg}
+	}
+	return map[string]interface{}{"errors": errs}, nil
+}
+
+func (m *mockSchema) validate(typ string, set []gql.Selection, messages *[]string) {
+	for _, s := range set {
+		f := s.(*gql.Field)
+		field, ok := m.types[typ][f.Name]
+		if !ok {
+			msg := fmt.Sprintf("Cannot query field %q on type %q.", f.Name, typ)
+			if close := closeNames(m.types[typ], f.Name); len(close) > 0 {
+				msg = fmt.Sprintf("Cannot query field %q on type %q. Did you mean %s?", f.Name, typ, quotedOr(close))
+			}
+			*messages = append(*messages, msg)
+			continue
+		}
+		for _, a := range f.Arguments {
+			t, ok := field.args[a.Name]
+			if !ok {
+				msg := fmt.Sprintf("Unknown argument %q on field \"%s.%s\".", a.Name, typ, f.Name)
+				if close := closeNames(field.args, a.Name); len(close) > 0 {
+					msg = fmt.Sprintf("Unknown argument %q on field \"%s.%s\". Did you mean %s?", a.Name, typ, f.Name, quotedOr(close))
+				}
+				*messages = append(*messages, msg)
+				continue
+			}
+			switch name := namedType(t); name {
+			case "Int", "Float", "ID":
+			case "String", "Boolean":
+				*messages = append(*messages, fmt.Sprintf("%s cannot represent a non %s value: 0", name, strings.ToLower(name)))
+			default:
+				*messages = append(*messages, fmt.Sprintf("Expected value of type %q, found 0.", t))
+			}
+		}
+		var required []string
+		for name, t := range field.args {
+			if strings.HasSuffix(t, "!") {
+				required = append(required, name)
+			}
+		}
+		sort.Strings(required)
+		for _, name := range required {
+			given := false
+			for _, a := range f.Arguments {
+				given = given || a.Name == name
+			}
+			if !given {
+				*messages = append(*messages, fmt.Sprintf("Field %q argument %q of type %q is required, but it was not provided.", f.Name, name, field.args[name]))
+			}
+		}
+		object := namedType(field.typ)
+		if _, ok := m.types[object]; !ok {
+			continue
+		}
+		if len(f.SelectionSet) == 0 {
+			*messages = append(*messages, fmt.Sprintf("Field %q of type %q must have a selection of subfields. Did you mean \"%s { ... }\"?", f.Name, field.typ, f.Name))
+			continue
+		}
+		m.validate(object, f.SelectionSet, messages)
+	}
+}
+
+// closeNames returns the keys of names that start with name, or that name
+// starts with, sorted.
+func closeNames[T any](names map[string]T, name string) []string {
+	var close []string
+	for n := range names {
+		if n != name && (strings.HasPrefix(n, name) || strings.HasPrefix(name, n)) {
+			close = append(close, n)
+		}
+	}
+	sort.Strings(close)
+	return close
+}
+
+// quotedOr lists names as graphql-js does: "a", "b", or "c".
+func quotedOr(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = `"` + n + `"`
+	}
+	switch len(quoted) {
+	case 1:
+		return quoted[0]
+	case 2:
+		return quoted[0] + " or " + quoted[1]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + ", or " + quoted[len(quoted)-1]
+}
+
+// recoveryWords are the candidates of the recovery tests: users and email
+// are only found through the suggestions of usersList and emailAddress.
+var recoveryWords = []string{"id", "name", "user", "version", "posts", "title", "usersList", "emailAddress", "admin"}
+
+func TestRecoveryFollowsTypesAndSuggestions(t *testing.T) {
+	m := recoverySchema()
+	c := NewCheckpoint("http://example/graphql", 1)
+	rc := &Recovery{Send: m.send, Fields: recoveryWords}
+	if err := rc.Run(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"Query": {"user", "users", "version"},
+		"User":  {"email", "id", "name", "posts"},
+		"Post":  {"id", "title"},
+	}
+	for typ, fields := range want {
+		if got := c.Fields[typ]; strings.Join(got, " ") != strings.Join(fields, " ") {
+			t.Errorf("fields of %s = %v, want %v", typ, got, fields)
+		}
+	}
+	if len(c.Fields) != len(want) {
+		t.Errorf("types = %v, want %d", c.Fields, len(want))
+	}
+	if p := strings.Join(c.Paths["Post"], "."); p != "user.posts" {
+		t.Errorf("path of Post = %q, want user.posts", p)
+	}
+	if c.Requests != m.requests {
+		t.Errorf("Requests = %d, server saw %d", c.Requests, m.requests)
+	}
+
+	got := renderObserved(c.Schema())
+	wantSchema := `type Query
+  user(id: ID): User
+  users: [User]
+  version: String # ` + descUnknownType + `
+type User
+  email: String # ` + descUnknownType + `
+  id: String # ` + descUnknownType + `
+  name: String # ` + descUnknownType + `
+  posts: [Post]
+type Post
+  id: String # ` + descUnknownType + `
+  title: String # ` + descUnknownType + `
+`
+	if got != wantSchema {
+		t.Errorf("recovered schema =\n%s\nwant\n%s", got, wantSchema)
+	}
+}
+
+func TestRecoveryConfirmsNothingWithoutValidationErrors(t *testing.T) {
+	c := NewCheckpoint("http://example/graphql", 1)
+	rc := &Recovery{Send: func(context.Context, string) (map[string]interface{}, error) {
+		return map[string]interface{}{"errors": []interface{}{map[string]interface{}{"message": "Query rejected"}}}, nil
+	}, Fields: recoveryWords}
+	if err := rc.Run(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Fields) != 0 || c.Requests != len(recoveryWords) {
+		t.Errorf("fields = %v after %d requests, want none after %d", c.Fields, c.Requests, len(recoveryWords))
+	}
+}
+
+func TestRecoveryStopsOnSendError(t *testing.T) {
+	m := recoverySchema()
+	failure := errors.New("connection refused")
+	c := NewCheckpoint("http://example/graphql", 1)
+	rc := &Recovery{Send: func(ctx context.Context, doc string) (map[string]interface{}, error) {
+		if m.requests == 3 {
+			return nil, failure
+		}
+		return m.send(ctx, doc)
+	}, Fields: recoveryWords}
+	if err := rc.Run(context.Background(), c); !errors.Is(err, failure) {
+		t.Fatalf("Run = %v, want %v", err, failure)
+	}
+	probed := 0
+	for _, words := range c.Probed {
+		probed += len(words)
+	}
+	if c.Requests != 3 || probed != 3 {
+		t.Errorf("after the failure: %d requests, probed %v", c.Requests, c.Probed)
+	}
+}
+
+func TestRecoveredSchemaFile(t *testing.T) {
+	m := recoverySchema()
+	c := NewCheckpoint("http://example/graphql", 1)
+	if err := (&Recovery{Send: m.send, Fields: recoveryWords}).Run(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "recovered.json")
+	if err := c.WriteSchema(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc types.IntrospectionResponse
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	s := doc.Data.Schema
+	if s.QueryType == nil || s.QueryType.Name != "Query" || s.MutationType != nil {
+		t.Errorf("root types = %+v %+v", s.QueryType, s.MutationType)
+	}
+	var users string
+	for _, typ := range s.Types {
+		for _, f := range typ.Fields {
+			if typ.Name == "Query" && f.Name == "users" {
+				users = f.Type.String()
+			}
+		}
+	}
+	if users != "[User!]!" {
+		t.Errorf("type of Query.users = %q, want [User!]!", users)
+	}
+}
+
+// argumentWords are the argument candidates of the recovery tests: filter is
+// only found through the suggestion of filterBy.
+var argumentWords = []string{"id", "first", "filterBy", "orderBy", "after"}
+
+// recoverAll runs a recovery of recoverySchema with seed and workers, sending
+// with send when it is not nil, and returns its checkpoint.
+func recoverAll(t *testing.T, seed int64, workers int) *Checkpoint {
+	t.Helper()
+	m := recoverySchema()
+	c := NewCheckpoint("http://example/graphql", seed)
+	rc := &Recovery{Send: m.send, Fields: recoveryWords, Arguments: argumentWords, Workers: workers}
+	if err := rc.Run(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Requests != m.requests {
+		t.Errorf("Requests = %d, server saw %d", c.Requests, m.requests)
+	}
+	return c
+}
+
+func TestRecoveryProbesArgumentsOfConfirmedFields(t *testing.T) {
+	c := recoverAll(t, 1, 3)
+	want := map[string][]string{
+		"Query.user":  {"id"},
+		"Query.users": {"filter", "first"},
+		"User.posts":  {"orderBy"},
+	}
+	for key, args := range want {
+		if got := c.Arguments[key]; strings.Join(got, " ") != strings.Join(args, " ") {
+			t.Errorf("arguments of %s = %v, want %v", key, got, args)
+		}
+	}
+	if len(c.Arguments) != len(want) {
+		t.Errorf("arguments = %v", c.Arguments)
+	}
+	// Arguments are probed on confirmed fields only.
+	for key := range c.Probed {
+		if typ, field, isArg := strings.Cut(key, "."); isArg && !c.Confirmed(typ, field) {
+			t.Errorf("arguments probed on %s, which is not a field", key)
+		}
+	}
+
+	got := renderObserved(c.Schema())
+	wantSchema := `type Query
+  user(id: ID): User
+  users(filter: UserFilter, first: String): [User]
+  version: String # ` + descUnknownType + `
+type User
+  email: String # ` + descUnknownType + `
+  id: String # ` + descUnknownType + `
+  name: String # ` + descUnknownType + `
+  posts(orderBy: String): [Post]
+type Post
+  id: String # ` + descUnknownType + `
+  title: String # ` + descUnknownType + `
+`
+	if got != wantSchema {
+		t.Errorf("recovered schema =\n%s\nwant\n%s", got, wantSchema)
+	}
+	var inputs []string
+	for _, typ := range c.Schema().Types {
+		if typ.Kind == types.INPUT_OBJECT {
+			inputs = append(inputs, typ.Name)
+		}
+	}
+	if strings.Join(inputs, " ") != "UserFilter" {
+		t.Errorf("input placeholders = %v", inputs)
+	}
+}
+
+func TestRecoveryIsDeterministicUnderSeed(t *testing.T) {
+	first, err := json.Marshal(recoverAll(t, 7, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Probes of a round are sent at once, yet recorded in the same order
+	// on every run.
+	for i := 0; i < 5; i++ {
+		again, err := json.Marshal(recoverAll(t, 7, 4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(again) != string(first) {
+			t.Fatalf("run %d with seed 7 =\n%s\nwant\n%s", i+2, again, first)
+		}
+	}
+
+	// Another seed probes in another order and finds the same schema.
+	a, b := recoverAll(t, 7, 4), recoverAll(t, 8, 4)
+	if strings.Join(a.Probed["User"], " ") == strings.Join(b.Probed["User"], " ") {
+		t.Errorf("seeds 7 and 8 probed User in the same order: %v", a.Probed["User"])
+	}
+	if renderObserved(a.Schema()) != renderObserved(b.Schema()) {
+		t.Errorf("seeds 7 and 8 recovered different schemas")
+	}
+}
+
+func TestRecoveryResumesFromCheckpoint(t *testing.T) {
+	full := recoverAll(t, 3, 1)
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	m := recoverySchema()
+	failure := errors.New("connection reset")
+	c := NewCheckpoint("http://example/graphql", 3)
+	rc := &Recovery{Send: func(ctx context.Context, doc string) (map[string]interface{}, error) {
+		if m.requests == 10 {
+			return nil, failure
+		}
+		return m.send(ctx, doc)
+	}, Fields: recoveryWords, Arguments: argumentWords, Workers: 1, Checkpoint: path, Every: 4}
+	if err := rc.Run(context.Background(), c); !errors.Is(err, failure) {
+		t.Fatalf("Run = %v, want %v", err, failure)
+	}
+
+	// The checkpoint was saved every 4 requests: after the 8th, not the 10th.
+	saved, err := LoadCheckpoint(path)
+	if err != nil || saved == nil {
+		t.Fatalf("LoadCheckpoint = %v, %v", saved, err)
+	}
+	if saved.Requests != 8 {
+		t.Errorf("checkpoint saved after %d requests, want 8", saved.Requests)
+	}
+
+	resumed := recoverySchema()
+	rc = &Recovery{Send: resumed.send, Fields: recoveryWords, Arguments: argumentWords, Workers: 1, Checkpoint: path, Every: 4}
+	if err := rc.Run(context.Background(), saved); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.requests != full.Requests-8 {
+		t.Errorf("resumed run sent %d requests, want the %d left", resumed.requests, full.Requests-8)
+	}
+	want, _ := json.Marshal(full)
+	got, _ := json.Marshal(saved)
+	if string(got) != string(want) {
+		t.Errorf("resumed checkpoint =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// translation rewrites the graphql-js errors of a mockSchema the way a
+// server in Language words them, read from testdata/localized: each English
+// pattern is replaced by its localized wording in turn. Code, when set, is
+// the extensions.code of every error.
+type translation struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
+	Messages []struct {
+		English   string `json:"english"`
+		Localized string `json:"localized"`
+	} `json:"messages"`
+}
+
+func loadTranslation(t *testing.T, language string) translation {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "localized", language+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tr translation
+	if err := json.Unmarshal(raw, &tr); err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+// localize returns send with the error messages of its responses translated
+// by tr.
+func (tr translation) localize(send Send) Send {
+	return func(ctx context.Context, document string) (map[string]interface{}, error) {
+		resp, err := send(ctx, document)
+		errs, _ := resp["errors"].([]interface{})
+		for _, e := range errs {
+			m := e.(map[string]interface{})
+			msg := m["message"].(string)
+			for _, rule := range tr.Messages {
+				msg = regexp.MustCompile(rule.English).ReplaceAllString(msg, rule.Localized)
+			}
+			m["message"] = msg
+			if tr.Code != "" {
+				m["extensions"] = map[string]interface{}{"code": tr.Code}
+			}
+		}
+		return resp, err
+	}
+}
+
+// TestRecoveryOfLocalizedServers brute forces recoverySchema through servers
+// translating its errors. Fields and arguments are confirmed, and suggestions
+// followed, as in English; only the English errors name types, so nothing is
+// found below Query.
+func TestRecoveryOfLocalizedServers(t *testing.T) {
+	for _, language := range []string{"es", "de"} {
+		t.Run(language, func(t *testing.T) {
+			tr := loadTranslation(t, language)
+			c := NewCheckpoint("http://example/graphql", 1)
+			rc := &Recovery{Send: tr.localize(recoverySchema().send), Fields: recoveryWords, Arguments: argumentWords}
+			if err := rc.Run(context.Background(), c); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(c.Fields["Query"], " "); got != "user users version" {
+				t.Errorf("fields of Query = %q, want users found through the suggestion of usersList", got)
+			}
+			if len(c.Fields) != 1 {
+				t.Errorf("types = %v, want only Query", c.Fields)
+			}
+			want := map[string][]string{
+				"Query.user":  {"id"},
+				"Query.users": {"filter", "first"},
+			}
+			for key, args := range want {
+				if got := c.Arguments[key]; strings.Join(got, " ") != strings.Join(args, " ") {
+					t.Errorf("arguments of %s = %v, want %v", key, got, args)
+				}
+			}
+			if len(c.Arguments) != len(want) {
+				t.Errorf("arguments = %v", c.Arguments)
+			}
+		})
+	}
+}
+
+// TestClassifyLocalizedErrors reads probe responses of localized servers.
+func TestClassifyLocalizedErrors(t *testing.T) {
+	response := func(code string, messages ...string) map[string]interface{} {
+		errs := make([]interface{}, len(messages))
+		for i, msg := range messages {
+			e := map[string]interface{}{"message": msg}
+			if code != "" {
+				e["extensions"] = map[string]interface{}{"code": code}
+			}
+			errs[i] = e
+		}
+		return map[string]interface{}{"errors": errs}
+	}
+	tests := []struct {
+		name        string
+		resp        map[string]interface{}
+		typ, word   string
+		hit         bool
+		suggestions []string
+	}{
+		{name: "french unknown field", resp: response("", `Impossible d'interroger le champ "usr" sur le type "Query". Vouliez-vous dire "user" ?`), typ: "Query", word: "usr", suggestions: []string{"user"}},
+		{name: "japanese unknown field", resp: response("", `フィールド「usr」はクエリできません。もしかして「user」?`), typ: "Query", word: "usr", suggestions: []string{"user"}},
+		{name: "chinese unknown field of another type", resp: response("", `无法查询字段 "usr"，类型 "User"`), typ: "Query", word: "usr", hit: true},
+		// A code alone does not tell a known field with missing subfields
+		// from an unknown one, unless the error suggests names.
+		{name: "coded error naming the field", resp: response("GRAPHQL_VALIDATION_FAILED", `Veld "user" heeft subvelden nodig`), typ: "Query", word: "user", hit: true},
+		{name: "coded error with suggestions", resp: response("GRAPHQL_VALIDATION_FAILED", `Veld "usr" bestaat niet. Did you mean "user"?`), typ: "Query", word: "usr", suggestions: []string{"user"}},
+		{name: "coded error naming nothing", resp: response("GRAPHQL_VALIDATION_FAILED", `Ongeldige query`), typ: "Query", word: "usr"},
+		{name: "unclassified error", resp: response("", `Veld "usr" bestaat niet`), typ: "Query", word: "usr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := classifyField(tt.resp, tt.typ, tt.word)
+			if res.hit != tt.hit || strings.Join(res.suggestions, " ") != strings.Join(tt.suggestions, " ") {
+				t.Errorf("classifyField = hit %v, suggestions %v; want %v, %v", res.hit, res.suggestions, tt.hit, tt.suggestions)
+			}
+		})
+	}
+
+	// An unknown argument of another field is no rejection of the argument.
+	res := classifyArgument(response("", `Argument inconnu "frist" sur le champ "Query.users". Vouliez-vous dire "first" ?`), "users", "frist")
+	if res.hit || strings.Join(res.suggestions, " ") != "first" {
+		t.Errorf("classifyArgument = %+v, want frist rejected with first suggested", res)
+	}
+	if res := classifyArgument(response("", `Argument inconnu "frist" sur le champ "Query.posts".`), "users", "frist"); !res.hit {
+		t.Errorf("classifyArgument = %+v, want an error of another field to validate the probe", res)
+	}
+}
@@ -0,0 +1,133 @@
+package inference
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scorer orders the candidate names of a schema recovery run. A recovery
+// loop reports every probed word to Observe and asks Prioritize for the
+// order of the words it has not probed yet.
+type Scorer interface {
+	// Observe records whether word was confirmed as a name of the schema.
+	Observe(word string, hit bool)
+	// Score ranks word; words with higher scores are probed first.
+	Score(word string) float64
+}
+
+// Prioritize returns words ordered by decreasing score. Words with the same
+// score are ordered by a hash of seed and the word, so a run with the same
+// seed, words and observations always probes in the same order.
+func Prioritize(s Scorer, words []string, seed int64) []string {
+	type ranked struct {
+		word  string
+		score float64
+		tie   uint64
+	}
+	ranks := make([]ranked, len(words))
+	for i, w := range words {
+		ranks[i] = ranked{word: w, score: s.Score(w), tie: tieBreak(seed, w)}
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		if ranks[i].score != ranks[j].score {
+			return ranks[i].score > ranks[j].score
+		}
+		return ranks[i].tie < ranks[j].tie
+	})
+	out := make([]string, len(ranks))
+	for i, r := range ranks {
+		out[i] = r.word
+	}
+	return out
+}
+
+// tieBreak hashes word with seed.
+func tieBreak(seed int64, word string) uint64 {
+	h := fnv.New64a()
+	var b [8]byte
+	for i := range b {
+		b[i] = byte(seed >> (8 * i))
+	}
+	h.Write(b[:])
+	h.Write([]byte(word))
+	return h.Sum64()
+}
+
+// AdaptiveScorer learns from the names confirmed during a run: candidates
+// sharing the first or last word of a confirmed name, such as userEmail and
+// userRoles after userId, are boosted, and words of names probed without a
+// hit lose weight. The zero value is not usable; see NewAdaptiveScorer.
+type AdaptiveScorer struct {
+	// Prefixes and Suffixes count the hits (positive) and misses (negative)
+	// of the first and last words of probed names, lowercased.
+	Prefixes map[string]int `json:"prefixes"`
+	Suffixes map[string]int `json:"suffixes"`
+}
+
+// Weights of the AdaptiveScorer: a hit counts more than a miss, since most
+// probes miss.
+const (
+	hitWeight  = 4
+	missWeight = 1
+)
+
+// NewAdaptiveScorer returns a scorer with no observations.
+func NewAdaptiveScorer() *AdaptiveScorer {
+	return &AdaptiveScorer{Prefixes: make(map[string]int), Suffixes: make(map[string]int)}
+}
+
+// Observe implements Scorer.
+func (a *AdaptiveScorer) Observe(word string, hit bool) {
+	delta := -missWeight
+	if hit {
+		delta = hitWeight
+	}
+	parts := nameWords(word)
+	if len(parts) < 2 {
+		// A single word is both the prefix and the suffix of related names.
+		if len(parts) == 1 && hit {
+			a.Prefixes[parts[0]] += delta
+			a.Suffixes[parts[0]] += delta
+		}
+		return
+	}
+	a.Prefixes[parts[0]] += delta
+	a.Suffixes[parts[len(parts)-1]] += delta
+}
+
+// Score implements Scorer. Words of unknown names score 0.
+func (a *AdaptiveScorer) Score(word string) float64 {
+	parts := nameWords(word)
+	if len(parts) == 0 {
+		return 0
+	}
+	return float64(a.Prefixes[parts[0]] + a.Suffixes[parts[len(parts)-1]])
+}
+
+// nameWords splits a camelCase, PascalCase or snake_case name into lowercase
+// words: userEmail, UserEmail and user_email all give [user email].
+func nameWords(name string) []string {
+	var words []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			words = append(words, strings.ToLower(cur.String()))
+			cur.Reset()
+		}
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-':
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])):
+			flush()
+		}
+		cur.WriteRune(r)
+	}
+	flush()
+	return words
+}
@@ -0,0 +1,60 @@
+package inference
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNameWords(t *testing.T) {
+	for name, want := range map[string]string{
+		"userEmail":   "user email",
+		"UserEmail":   "user email",
+		"user_email":  "user email",
+		"HTTPHeaders": "http headers",
+		"getURLFor":   "get url for",
+		"id":          "id",
+		"_private":    "private",
+	} {
+		if got := strings.Join(nameWords(name), " "); got != want {
+			t.Errorf("nameWords(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestAdaptiveScorerBoostsRelatedNames(t *testing.T) {
+	s := NewAdaptiveScorer()
+	s.Observe("userId", true)
+	s.Observe("accountName", false)
+	words := []string{"accountBalance", "title", "userEmail", "createdId"}
+	got := strings.Join(Prioritize(s, words, 1), " ")
+	// userEmail shares the prefix of a hit, createdId its suffix; accountBalance
+	// shares the prefix of a miss.
+	if !strings.HasPrefix(got, "userEmail createdId ") && !strings.HasPrefix(got, "createdId userEmail ") {
+		t.Errorf("Prioritize = %s, want userEmail and createdId first", got)
+	}
+	if !strings.HasSuffix(got, " accountBalance") {
+		t.Errorf("Prioritize = %s, want accountBalance last", got)
+	}
+}
+
+func TestPrioritizeIsDeterministicUnderSeed(t *testing.T) {
+	words := []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta"}
+	s := NewAdaptiveScorer()
+	first := strings.Join(Prioritize(s, words, 42), " ")
+	for i := 0; i < 10; i++ {
+		if got := strings.Join(Prioritize(s, words, 42), " "); got != first {
+			t.Fatalf("Prioritize with seed 42 = %s, then %s", first, got)
+		}
+	}
+	if other := strings.Join(Prioritize(s, words, 43), " "); other == first {
+		t.Errorf("seeds 42 and 43 give the same order %s", first)
+	}
+	// The order does not depend on the order of the input.
+	reversed := make([]string, len(words))
+	for i, w := range words {
+		reversed[len(words)-1-i] = w
+	}
+	if got := strings.Join(Prioritize(s, reversed, 42), " "); got != first {
+		t.Errorf("Prioritize of the reversed words = %s, want %s", got, first)
+	}
+}
@@ -0,0 +1,141 @@
+// Package inference recovers schema information from servers that do not answer
+// introspection
+package inference
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BuiltinPrefix marks a wordlist reference as one of the embedded wordlists,
+// e.g. "builtin:fields-medium". Any other reference is a file path.
+const BuiltinPrefix = "builtin:"
+
+//go:embed wordlists/*.txt
+var builtinWordlists embed.FS
+
+// graphqlName matches the names GraphQL allows for fields, arguments and operations.
+var graphqlName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// WordlistInfo describes an embedded wordlist.
+type WordlistInfo struct {
+	// Name is the reference without BuiltinPrefix.
+	Name string
+	// Description is the first comment line of the list.
+	Description string
+	Words       int
+}
+
+// BuiltinWordlists lists the embedded wordlists by name.
+func BuiltinWordlists() []WordlistInfo {
+	entries, _ := fs.ReadDir(builtinWordlists, "wordlists")
+	infos := make([]WordlistInfo, 0, len(entries))
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".txt")
+		info := WordlistInfo{Name: name}
+		if f, err := builtinWordlists.Open(path.Join("wordlists", e.Name())); err == nil {
+			info.Description = description(f)
+			f.Close()
+		}
+		stats, _ := EachWord(BuiltinPrefix+name, func(string) error { return nil })
+		info.Words = stats.Words
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// description returns the text of the first line of r when it is a comment.
+func description(r io.Reader) string {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		return ""
+	}
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "#") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "#"))
+}
+
+// OpenWordlist opens the wordlist named by ref, either "builtin:<name>" or a file path.
+func OpenWordlist(ref string) (io.ReadCloser, error) {
+	if name, ok := strings.CutPrefix(ref, BuiltinPrefix); ok {
+		f, err := builtinWordlists.Open(path.Join("wordlists", name+".txt"))
+		if err != nil {
+			var names []string
+			for _, info := range BuiltinWordlists() {
+				names = append(names, BuiltinPrefix+info.Name)
+			}
+			return nil, fmt.Errorf("unknown wordlist %q (available: %s)", ref, strings.Join(names, ", "))
+		}
+		return f, nil
+	}
+	f, err := os.Open(ref)
+	if err != nil {
+		return nil, fmt.Errorf("error opening wordlist: %w", err)
+	}
+	return f, nil
+}
+
+// WordlistStats counts the entries of a wordlist read by ReadWords.
+type WordlistStats struct {
+	// Words is the number of distinct valid words passed on.
+	Words int
+	// Duplicates and Invalid count the entries left out.
+	Duplicates int
+	Invalid    int
+}
+
+// ReadWords streams the words of a wordlist to fn, one line at a time, so large
+// lists are never held in memory whole. Blank lines and lines starting with #
+// are ignored and surrounding whitespace trimmed. Entries that are not GraphQL
+// names, or are reserved names starting with "__", are counted as invalid, and
+// entries seen before as duplicates; neither reaches fn. An error returned by fn
+// stops the read and is returned.
+func ReadWords(r io.Reader, fn func(word string) error) (WordlistStats, error) {
+	var stats WordlistStats
+	seen := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		if !graphqlName.MatchString(word) || strings.HasPrefix(word, "__") {
+			stats.Invalid++
+			continue
+		}
+		if _, dup := seen[word]; dup {
+			stats.Duplicates++
+			continue
+		}
+		seen[word] = struct{}{}
+		stats.Words++
+		if err := fn(word); err != nil {
+			return stats, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("error reading wordlist: %w", err)
+	}
+	return stats, nil
+}
+
+// EachWord opens the wordlist named by ref and streams its words to fn with ReadWords.
+func EachWord(ref string, fn func(word string) error) (WordlistStats, error) {
+	r, err := OpenWordlist(ref)
+	if err != nil {
+		return WordlistStats{}, err
+	}
+	defer r.Close()
+	return ReadWords(r, fn)
+}
@@ -0,0 +1,128 @@
+package inference
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinWordlists(t *testing.T) {
+	infos := BuiltinWordlists()
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+		if info.Words == 0 || info.Description == "" {
+			t.Errorf("%s: %d words, description %q", info.Name, info.Words, info.Description)
+		}
+	}
+	if got := strings.Join(names, " "); got != "arguments fields-medium fields-small operations" {
+		t.Errorf("builtin wordlists = %s", got)
+	}
+
+	// Every builtin reference resolves to the embedded list, with the words
+	// BuiltinWordlists counted and no entry left out.
+	for _, info := range infos {
+		var words []string
+		stats, err := EachWord(BuiltinPrefix+info.Name, func(w string) error {
+			words = append(words, w)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", info.Name, err)
+		}
+		if stats.Words != info.Words || len(words) != info.Words || stats.Duplicates != 0 || stats.Invalid != 0 {
+			t.Errorf("%s: %+v for %d words", info.Name, stats, len(words))
+		}
+	}
+
+	// fields-medium is a superset of fields-small.
+	medium := make(map[string]bool)
+	EachWord(BuiltinPrefix+"fields-medium", func(w string) error {
+		medium[w] = true
+		return nil
+	})
+	EachWord(BuiltinPrefix+"fields-small", func(w string) error {
+		if !medium[w] {
+			t.Errorf("fields-small word %s is not in fields-medium", w)
+		}
+		return nil
+	})
+}
+
+func TestOpenWordlistReferences(t *testing.T) {
+	_, err := OpenWordlist(BuiltinPrefix + "fields-huge")
+	if err == nil || !strings.Contains(err.Error(), `unknown wordlist "builtin:fields-huge"`) || !strings.Contains(err.Error(), BuiltinPrefix+"fields-medium") {
+		t.Errorf("unknown builtin: %v", err)
+	}
+
+	// Any other reference is a path, even one naming a builtin list.
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fields-small")
+	if err := os.WriteFile(path, []byte("account\nbalance\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var words []string
+	if _, err := EachWord(path, func(w string) error {
+		words = append(words, w)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(words, " "); got != "account balance" {
+		t.Errorf("words of %s = %s", path, got)
+	}
+	if _, err := OpenWordlist(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("a missing wordlist file opened")
+	}
+}
+
+func TestReadWordsNormalizes(t *testing.T) {
+	list := strings.Join([]string{
+		"# comment, then a blank line",
+		"",
+		"  user  ",
+		"user",
+		"\tuserId",
+		"User",
+		"  # indented comment",
+		"first-name",
+		"2fa",
+		"__schema",
+		"_private",
+		"user id",
+		"userId\r",
+		"",
+	}, "\n")
+	var words []string
+	stats, err := ReadWords(strings.NewReader(list), func(w string) error {
+		words = append(words, w)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Names are case sensitive, so User is not a duplicate of user.
+	if got := strings.Join(words, " "); got != "user userId User _private" {
+		t.Errorf("words = %s", got)
+	}
+	want := WordlistStats{Words: 4, Duplicates: 2, Invalid: 4}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestReadWordsStopsOnError(t *testing.T) {
+	stop := errors.New("enough")
+	n := 0
+	stats, err := ReadWords(strings.NewReader("a\nb\nc\n"), func(string) error {
+		if n++; n == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || stats.Words != 2 {
+		t.Errorf("ReadWords = %+v, %v; want 2 words and %v", stats, err, stop)
+	}
+}
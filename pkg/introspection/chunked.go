@@ -0,0 +1,150 @@
+package introspection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// DefaultChunkSize is the number of types fetched per chunked introspection request.
+const DefaultChunkSize = 50
+
+// introspectionFragments returns the fragments of IntrospectionQuery from the
+// named one to the end, so queries can reuse the exact same selections.
+func introspectionFragments(from string) string {
+	return IntrospectionQuery[strings.Index(IntrospectionQuery, "fragment "+from):]
+}
+
+// TypeListQuery fetches everything the full introspection query returns except
+// the type definitions, which are listed by name only.
+var TypeListQuery = `
+query IntrospectionTypes {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types {
+      name
+      kind
+    }
+    directives {
+      name
+      description
+      locations
+      args {
+        ...InputValue
+      }
+    }
+  }
+}
+
+` + introspectionFragments("InputValue")
+
+// typeBatchQuery fetches the full definitions of names as aliased __type fields t0, t1, ...
+func typeBatchQuery(names []string) string {
+	var b strings.Builder
+	b.WriteString("query IntrospectionTypeBatch {\n")
+	for i, name := range names {
+		fmt.Fprintf(&b, "  t%d: __type(name: %s) {\n    ...FullType\n  }\n", i, strconv.Quote(name))
+	}
+	b.WriteString("}\n\n")
+	b.WriteString(introspectionFragments("FullType"))
+	return b.String()
+}
+
+// ShouldChunk reports whether a failed full introspection query is worth
+// retrying in chunks: it timed out or its response was too large while the run
+// itself still has time left.
+func ShouldChunk(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && (errors.Is(err, gerrors.ErrTimeout) || errors.Is(err, gerrors.ErrTooLarge))
+}
+
+// FetchChunked rebuilds the result of the full introspection query from a type
+// list followed by batches of chunkSize __type queries. The result has the same
+// shape as the single-shot response, with types in the order the server listed
+// them. When the type list itself is refused, the refusal is returned unchanged
+// so it can be evaluated like any other introspection response.
+func FetchChunked(ctx context.Context, url string, headers map[string]string, chunkSize int) (map[string]interface{}, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	logger.Info("Fetching introspection in chunks of %d types from %s", chunkSize, url)
+	resp, err := network.SendGraphQLRequestWithContext(ctx, url, TypeListQuery, nil, headers)
+	if err != nil {
+		return nil, fmt.Errorf("type list query failed: %w", err)
+	}
+	data, _ := resp["data"].(map[string]interface{})
+	schemaData, _ := data["__schema"].(map[string]interface{})
+	if schemaData == nil {
+		return resp, nil
+	}
+
+	listed, _ := schemaData["types"].([]interface{})
+	names := make([]string, 0, len(listed))
+	for _, t := range listed {
+		if m, ok := t.(map[string]interface{}); ok {
+			if name, ok := m["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	logger.Debug("→ Schema lists %d types", len(names))
+
+	fetched := make(map[string]interface{}, len(names))
+	for start := 0; start < len(names); start += chunkSize {
+		end := start + chunkSize
+		if end > len(names) {
+			end = len(names)
+		}
+		if err := fetchTypes(ctx, url, headers, names[start:end], fetched); err != nil {
+			return nil, err
+		}
+	}
+
+	types := make([]interface{}, 0, len(listed))
+	for i, name := range names {
+		if t, ok := fetched[name]; ok && t != nil {
+			types = append(types, t)
+			continue
+		}
+		logger.Warn("Type %s listed by the schema could not be fetched, keeping its name and kind only", name)
+		types = append(types, listed[i])
+	}
+
+	full := make(map[string]interface{}, len(schemaData))
+	for k, v := range schemaData {
+		full[k] = v
+	}
+	full["types"] = types
+	return map[string]interface{}{"data": map[string]interface{}{"__schema": full}}, nil
+}
+
+// fetchTypes fetches the definitions of names into fetched. A batch that times
+// out or is too large is split in half and retried until single types remain.
+func fetchTypes(ctx context.Context, url string, headers map[string]string, names []string, fetched map[string]interface{}) error {
+	logger.Debug("→ Fetching %d type definitions (%s ... %s)", len(names), names[0], names[len(names)-1])
+	resp, err := network.SendGraphQLRequestWithContext(ctx, url, typeBatchQuery(names), nil, headers)
+	if err != nil {
+		if len(names) > 1 && ShouldChunk(ctx, err) {
+			logger.Debug("→ Batch of %d types failed (%v), splitting it", len(names), err)
+			half := len(names) / 2
+			if err := fetchTypes(ctx, url, headers, names[:half], fetched); err != nil {
+				return err
+			}
+			return fetchTypes(ctx, url, headers, names[half:], fetched)
+		}
+		return fmt.Errorf("type batch query failed: %w", err)
+	}
+
+	data, _ := resp["data"].(map[string]interface{})
+	for i, name := range names {
+		fetched[name] = data["t"+strconv.Itoa(i)]
+	}
+	return nil
+}
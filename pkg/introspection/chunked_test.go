@@ -0,0 +1,256 @@
+package introspection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// chunkSchema returns the __schema of a full introspection response with n
+// object types besides the query root, scalars, an enum and an input object.
+func chunkSchema(n int) map[string]interface{} {
+	ref := func(kind, name string) map[string]interface{} {
+		return map[string]interface{}{"kind": kind, "name": name, "ofType": nil}
+	}
+	nonNull := func(of map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"kind": "NON_NULL", "name": nil, "ofType": of}
+	}
+	inputValue := func(name string, typ map[string]interface{}, def interface{}) map[string]interface{} {
+		return map[string]interface{}{"name": name, "description": nil, "type": typ, "defaultValue": def}
+	}
+	field := func(name string, typ map[string]interface{}, args ...interface{}) map[string]interface{} {
+		if args == nil {
+			args = []interface{}{}
+		}
+		return map[string]interface{}{"name": name, "description": nil, "args": args, "type": typ, "isDeprecated": false, "deprecationReason": nil}
+	}
+	fullType := func(kind, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"kind": kind, "name": name, "description": nil, "fields": nil, "inputFields": nil,
+			"interfaces": nil, "enumValues": nil, "possibleTypes": nil,
+		}
+	}
+
+	query := fullType("OBJECT", "Query")
+	query["interfaces"] = []interface{}{}
+	var rootFields []interface{}
+	types := []interface{}{query}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("Type%02d", i)
+		t := fullType("OBJECT", name)
+		t["description"] = name + " is generated"
+		t["interfaces"] = []interface{}{}
+		t["fields"] = []interface{}{
+			field("id", nonNull(ref("SCALAR", "ID"))),
+			field("children", map[string]interface{}{"kind": "LIST", "name": nil, "ofType": nonNull(ref("OBJECT", name))},
+				inputValue("first", ref("SCALAR", "Int"), "10"),
+				inputValue("filter", ref("INPUT_OBJECT", "Filter"), nil)),
+			field("status", ref("ENUM", "Status")),
+		}
+		types = append(types, t)
+		rootFields = append(rootFields, field(strings.ToLower(name), ref("OBJECT", name), inputValue("id", nonNull(ref("SCALAR", "ID")), nil)))
+	}
+	query["fields"] = rootFields
+
+	status := fullType("ENUM", "Status")
+	status["enumValues"] = []interface{}{
+		map[string]interface{}{"name": "ACTIVE", "description": nil, "isDeprecated": false, "deprecationReason": nil},
+		map[string]interface{}{"name": "LEGACY", "description": nil, "isDeprecated": true, "deprecationReason": "use ACTIVE"},
+	}
+	filter := fullType("INPUT_OBJECT", "Filter")
+	filter["inputFields"] = []interface{}{inputValue("term", ref("SCALAR", "String"), `"all"`)}
+	types = append(types, status, filter)
+	for _, scalar := range []string{"ID", "Int", "String", "Boolean"} {
+		types = append(types, fullType("SCALAR", scalar))
+	}
+
+	return map[string]interface{}{
+		"queryType":        map[string]interface{}{"name": "Query"},
+		"mutationType":     nil,
+		"subscriptionType": nil,
+		"types":            types,
+		"directives": []interface{}{
+			map[string]interface{}{"name": "skip", "description": "Skips the field", "locations": []interface{}{"FIELD", "INLINE_FRAGMENT"},
+				"args": []interface{}{inputValue("if", nonNull(ref("SCALAR", "Boolean")), nil)}},
+		},
+	}
+}
+
+var batchAlias = regexp.MustCompile(`t(\d+): __type\(name: "([^"]+)"\)`)
+
+// chunkServer answers the full introspection query, the type list and type
+// batches from schema, a __schema as built by chunkSchema. With fullTooLarge
+// the full query response exceeds the size limit of the client; batches
+// holding the type named huge along with others do too. It records the
+// number of types of each batch.
+type chunkServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	batches []int
+}
+
+func newChunkServer(t *testing.T, schema map[string]interface{}, fullTooLarge bool, huge string) *chunkServer {
+	t.Helper()
+	byName := make(map[string]interface{})
+	var listed []interface{}
+	for _, typ := range schema["types"].([]interface{}) {
+		m := typ.(map[string]interface{})
+		byName[m["name"].(string)] = m
+		listed = append(listed, map[string]interface{}{"name": m["name"], "kind": m["kind"]})
+	}
+	s := &chunkServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		var data map[string]interface{}
+		switch {
+		case strings.Contains(req.Query, "query IntrospectionQuery"):
+			if fullTooLarge {
+				writeOversized(w)
+				return
+			}
+			data = map[string]interface{}{"__schema": schema}
+		case strings.Contains(req.Query, "query IntrospectionTypes"):
+			list := make(map[string]interface{})
+			for k, v := range schema {
+				list[k] = v
+			}
+			list["types"] = listed
+			data = map[string]interface{}{"__schema": list}
+		case strings.Contains(req.Query, "query IntrospectionTypeBatch"):
+			aliases := batchAlias.FindAllStringSubmatch(req.Query, -1)
+			s.mu.Lock()
+			s.batches = append(s.batches, len(aliases))
+			s.mu.Unlock()
+			data = make(map[string]interface{})
+			for _, a := range aliases {
+				if a[2] == huge && len(aliases) > 1 {
+					writeOversized(w)
+					return
+				}
+				data["t"+a[1]] = byName[a[2]]
+			}
+		default:
+			w.Write([]byte(`{"errors":[{"message":"unexpected query"}]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// writeOversized writes a JSON response larger than network.MaxResponseSize.
+func writeOversized(w http.ResponseWriter) {
+	w.Write([]byte(`{"data":{"padding":"`))
+	chunk := []byte(strings.Repeat("a", 1<<20))
+	for i := 0; i <= network.MaxResponseSize>>20; i++ {
+		if _, err := w.Write(chunk); err != nil {
+			return
+		}
+	}
+}
+
+// singleShot fetches the full introspection query from url as one request.
+func singleShot(t *testing.T, url string) map[string]interface{} {
+	t.Helper()
+	resp, err := CheckIntrospectionWithContext(context.Background(), url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestFetchChunkedMatchesSingleShot(t *testing.T) {
+	schema := chunkSchema(23)
+	nTypes := len(schema["types"].([]interface{}))
+	for _, size := range []int{5, 1, nTypes, 100} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			srv := newChunkServer(t, schema, false, "")
+			want := singleShot(t, srv.URL)
+			got, err := FetchChunked(context.Background(), srv.URL, nil, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				a, _ := json.Marshal(got)
+				b, _ := json.Marshal(want)
+				t.Fatalf("chunked result differs from the single-shot one:\n%s\nwant\n%s", a, b)
+			}
+			if batches := (nTypes + size - 1) / size; len(srv.batches) != batches {
+				t.Errorf("sent %d batches, want %d", len(srv.batches), batches)
+			}
+			for _, n := range srv.batches {
+				if n > size {
+					t.Errorf("batch of %d types, more than the chunk size %d", n, size)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchChunkedSplitsOversizedBatches(t *testing.T) {
+	schema := chunkSchema(3)
+	srv := newChunkServer(t, schema, false, "Type01")
+	want := singleShot(t, srv.URL)
+	got, err := FetchChunked(context.Background(), srv.URL, nil, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatal("chunked result differs from the single-shot one")
+	}
+	// The first batch (Query, Type00 to Type02) holds Type01 and is halved
+	// until Type01 is alone; the other 6 types go in batches of 4 and 2.
+	if want := []int{4, 2, 2, 1, 1, 4, 2}; !reflect.DeepEqual(srv.batches, want) {
+		t.Errorf("batch sizes %v, want %v", srv.batches, want)
+	}
+}
+
+func TestProbeTiersFallsBackToChunks(t *testing.T) {
+	schema := chunkSchema(12)
+	reference := newChunkServer(t, schema, false, "")
+	want := singleShot(t, reference.URL)
+
+	srv := newChunkServer(t, schema, true, "")
+	tr, err := ProbeTiers(context.Background(), srv.URL, nil, ProbeOptions{ChunkSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Level != TierFull || !reflect.DeepEqual(tr.Full, want) {
+		t.Fatalf("Level = %s, want the full schema fetched in chunks", tr.Level)
+	}
+	if len(srv.batches) != 2 {
+		t.Errorf("sent %d batches, want 2", len(srv.batches))
+	}
+	if !IsIntrospectionEnabled(tr.Full) {
+		t.Error("the reassembled schema is not recognised as an introspection result")
+	}
+}
+
+func TestFetchChunkedReturnsRefusal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errors":[{"message":"GraphQL introspection is not allowed"}]}`))
+	}))
+	defer srv.Close()
+	resp, err := FetchChunked(context.Background(), srv.URL, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsIntrospectionEnabled(resp) || firstErrorMessage(resp) != "GraphQL introspection is not allowed" {
+		t.Errorf("FetchChunked() = %v, want the refusal unchanged", resp)
+	}
+}
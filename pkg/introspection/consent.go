@@ -0,0 +1,111 @@
+package introspection
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+)
+
+// consentPattern matches the wording of terms-of-use and consent notices.
+var consentPattern = regexp.MustCompile(`(?i)terms (of (use|service)|and conditions)|acceptable use polic|accept (the|our) (terms|polic|conditions)|consent (is )?required|(must|need to) (give|provide) consent|usage polic(y|ies)|by (using|accessing) this (api|service|site)`)
+
+// markup matches the tags, scripts and styles stripped from HTML pages before
+// their text is matched.
+var markup = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>|<[^>]*>`)
+
+// bannerContext is how many characters of text around the matched wording a
+// banner keeps on each side.
+const bannerContext = 80
+
+// ConsentBanner returns the terms-of-use or consent notice a server answered a
+// probe with: the message or an extension of a GraphQL error, an extension of
+// resp, or the text of the HTML page err was returned for. Such notices gate
+// access behind accepting terms that often set conditions on testing. It
+// returns "" when neither holds one.
+func ConsentBanner(resp map[string]interface{}, err error) string {
+	var cte *gerrors.ContentTypeError
+	if errors.As(err, &cte) {
+		text := html.UnescapeString(markup.ReplaceAllString(string(cte.Body), " "))
+		if banner := consentText(text); banner != "" {
+			return banner
+		}
+	}
+	if resp == nil {
+		return ""
+	}
+	errs, _ := resp["errors"].([]interface{})
+	for _, e := range errs {
+		if banner := consentValue(e); banner != "" {
+			return banner
+		}
+	}
+	return consentValue(resp["extensions"])
+}
+
+// consentValue returns the first consent notice among the strings of v, in
+// the order of the sorted keys of its objects.
+func consentValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return consentText(v)
+	case []interface{}:
+		for _, e := range v {
+			if banner := consentValue(e); banner != "" {
+				return banner
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if banner := consentValue(v[k]); banner != "" {
+				return banner
+			}
+		}
+	}
+	return ""
+}
+
+// consentText returns the text around the consent wording of text, with its
+// whitespace collapsed, or "" when text has none.
+func consentText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	loc := consentPattern.FindStringIndex(text)
+	if loc == nil {
+		return ""
+	}
+	start, end := loc[0]-bannerContext, loc[1]+bannerContext
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(text) {
+		end, suffix = len(text), ""
+	}
+	// Cut at spaces rather than inside a word or a multi-byte rune.
+	if prefix != "" && text[start-1] != ' ' {
+		if i := strings.IndexByte(text[start:loc[0]], ' '); i >= 0 {
+			start += i + 1
+		}
+		for !utf8.RuneStart(text[start]) {
+			start++
+		}
+	}
+	if suffix != "" && text[end] != ' ' {
+		if i := strings.LastIndexByte(text[loc[1]:end], ' '); i >= 0 {
+			end = loc[1] + i
+		}
+		for !utf8.RuneStart(text[end]) {
+			end--
+		}
+	}
+	return prefix + text[start:end] + suffix
+}
@@ -0,0 +1,102 @@
+package introspection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+)
+
+func TestConsentBanner(t *testing.T) {
+	decode := func(body string) map[string]interface{} {
+		var resp map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	long := strings.Repeat("word ", 40)
+	tests := []struct {
+		name string
+		resp string
+		err  error
+		want string
+	}{
+		{
+			name: "error message",
+			resp: `{"errors":[{"message":"You must accept the Terms of Use before querying this API"}]}`,
+			want: "You must accept the Terms of Use before querying this API",
+		},
+		{
+			name: "error extension",
+			resp: `{"errors":[{"message":"Forbidden","extensions":{"code":"CONSENT","notice":"Consent required: see https://example.com/rules"}}]}`,
+			want: "Consent required: see https://example.com/rules",
+		},
+		{
+			name: "response extension",
+			resp: `{"data":{"__typename":"Query"},"extensions":{"banner":{"text":"By using this API you agree to the program rules"}}}`,
+			want: "By using this API you agree to the program rules",
+		},
+		{
+			name: "html interstitial",
+			err: &gerrors.ContentTypeError{Kind: gerrors.ErrHTMLResponse, ContentType: "text/html", Body: []byte(
+				`<html><head><style>.terms of use {}</style><script>var terms = "terms of service";</script></head>` +
+					`<body><h1>Before you continue</h1><p>Please accept the <a href="/tos">terms &amp; conditions</a> and our usage policy.</p></body></html>`)},
+			want: "Before you continue Please accept the terms & conditions and our usage policy.",
+		},
+		{
+			name: "long text is cut at words",
+			resp: fmt.Sprintf(`{"errors":[{"message":"%sAcceptable Use Policy applies %s"}]}`, long, long),
+			want: "…" + strings.Repeat("word ", 16) + "Acceptable Use Policy applies" + strings.Repeat(" word", 14) + "…",
+		},
+		{
+			name: "unrelated errors",
+			resp: `{"errors":[{"message":"Cannot query field \"terms\" on type \"Query\"."}]}`,
+		},
+		{
+			name: "other errors",
+			err:  fmt.Errorf("wrapped: %w", &gerrors.ContentTypeError{Kind: gerrors.ErrNonJSONResponse, Body: []byte("Service Unavailable")}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp map[string]interface{}
+			if tt.resp != "" {
+				resp = decode(tt.resp)
+			}
+			if got := ConsentBanner(resp, tt.err); got != tt.want {
+				t.Errorf("ConsentBanner() = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeTiersRecordsConsentBanner(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var body struct{ Query string }
+		json.NewDecoder(r.Body).Decode(&body)
+		if strings.Contains(body.Query, "__typename") && !strings.Contains(body.Query, "__schema") {
+			w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+			return
+		}
+		w.Write([]byte(`{"errors":[{"message":"Introspection requires that you accept the terms of service"}]}`))
+	}))
+	defer srv.Close()
+
+	tr, err := ProbeTiers(context.Background(), srv.URL, nil, ProbeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Level != TierTypename {
+		t.Errorf("level = %s, want %s", tr.Level, TierTypename)
+	}
+	if tr.Banner != "Introspection requires that you accept the terms of service" {
+		t.Errorf("banner = %q", tr.Banner)
+	}
+}
@@ -2,11 +2,20 @@ package introspection
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
 	"github.com/CyberRoute/graphspecter/pkg/logger"
 	"github.com/CyberRoute/graphspecter/pkg/network"
-	"os"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 )
 
 // IntrospectionQuery contains the full introspection query.
@@ -114,12 +123,15 @@ func CheckIntrospectionWithContext(ctx context.Context, url string, headers map[
 	result, err := network.SendGraphQLRequestWithContext(ctx, url, IntrospectionQuery, nil, headers)
 	if err != nil {
 		// Check for common errors and provide more user-friendly messages
-		if ctx.Err() == context.Canceled {
+		if errors.Is(err, gerrors.ErrCanceled) {
 			logger.Error("Introspection query was canceled")
-			return nil, fmt.Errorf("operation canceled - either by user interruption or another operation completed first")
-		} else if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("introspection query canceled - either by user interruption or another operation completed first: %w", err)
+		} else if errors.Is(err, gerrors.ErrTimeout) {
 			logger.Error("Introspection query timed out")
-			return nil, fmt.Errorf("request timed out - try increasing timeout with the -timeout flag")
+			return nil, fmt.Errorf("introspection query timed out - try increasing timeout with the -timeout flag: %w", err)
+		} else if errors.Is(err, gerrors.ErrNotModified) {
+			logger.Debug("→ Introspection result of %s not modified", url)
+			return nil, err
 		}
 
 		logger.Error("Introspection query failed: %v", err)
@@ -143,14 +155,112 @@ func IsIntrospectionEnabled(response map[string]interface{}) bool {
 	return ok && len(types) > 0
 }
 
-// WriteIntrospectionToFile writes the introspection result to a file.
+// Normalize sorts the types and directives of an introspection result by name so
+// dumps of the same schema are byte-identical. Object keys are already sorted by
+// encoding/json and field order is left as the schema declares it.
+func Normalize(response map[string]interface{}) {
+	data, ok := response["data"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	schema, ok := data["__schema"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range []string{"types", "directives"} {
+		if list, ok := schema[key].([]interface{}); ok {
+			sortByName(list)
+		}
+	}
+}
+
+// SchemaHash returns a short hash identifying the schema of an introspection
+// result. The result is normalized first, so the same schema listed in another
+// order hashes the same.
+func SchemaHash(response map[string]interface{}) string {
+	Normalize(response)
+	data, err := json.Marshal(response["data"])
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// sortByName orders a list of introspection objects by their "name" member.
+func sortByName(list []interface{}) {
+	name := func(v interface{}) string {
+		if m, ok := v.(map[string]interface{}); ok {
+			if n, ok := m["name"].(string); ok {
+				return n
+			}
+		}
+		return ""
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return name(list[i]) < name(list[j])
+	})
+}
+
+// WriteIntrospectionToFile normalizes the introspection result and writes it to a file.
 func WriteIntrospectionToFile(data map[string]interface{}, filename string) error {
+	Normalize(data)
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return fmt.Errorf("error marshalling data: %w", err)
 	}
-	if err := os.WriteFile(filename, jsonData, 0644); err != nil {
+	if err := artifacts.WriteFile(filename, jsonData, 0644); err != nil {
 		return fmt.Errorf("error writing to file: %w", err)
 	}
 	return nil
 }
+
+// OutputFileName derives a per-endpoint file name from the default output file,
+// suffixing it with the last path segment of targetURL.
+func OutputFileName(defaultFile, targetURL string) string {
+	if _, err := url.Parse(targetURL); err != nil {
+		return defaultFile
+	}
+	baseName := strings.TrimSuffix(defaultFile, ".json")
+	return fmt.Sprintf("%s_%s.json", baseName, EndpointSuffix(targetURL))
+}
+
+// CatalogFileName returns the operation catalog file written next to the
+// introspection dump of targetURL.
+func CatalogFileName(defaultFile, targetURL string) string {
+	return filepath.Join(filepath.Dir(defaultFile), "catalog_"+EndpointSuffix(targetURL)+".json")
+}
+
+// SDLFileName returns the SDL file written next to the introspection dump of
+// targetURL.
+func SDLFileName(defaultFile, targetURL string) string {
+	return filepath.Join(filepath.Dir(defaultFile), "schema_"+EndpointSuffix(targetURL)+".graphql")
+}
+
+// AuthzFileName returns the authorization matrix file written next to the
+// introspection dump of targetURL.
+func AuthzFileName(defaultFile, targetURL string) string {
+	return filepath.Join(filepath.Dir(defaultFile), "authz_"+EndpointSuffix(targetURL)+".json")
+}
+
+// unsafeFileChars matches the characters of a path segment replaced in file names.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// EndpointSuffix returns the last non-empty path segment of targetURL, reduced
+// to characters safe in file names, or "root" when it has none. The query,
+// fragment, port and host of targetURL never appear in it.
+func EndpointSuffix(targetURL string) string {
+	parsed, err := url.Parse(targetURL)
+	if err != nil {
+		return "root"
+	}
+	segments := strings.FieldsFunc(parsed.Path, func(r rune) bool { return r == '/' })
+	if len(segments) == 0 {
+		return "root"
+	}
+	suffix := strings.Trim(unsafeFileChars.ReplaceAllString(segments[len(segments)-1], "_"), "._")
+	if suffix == "" {
+		return "root"
+	}
+	return suffix
+}
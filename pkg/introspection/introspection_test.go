@@ -0,0 +1,119 @@
+package introspection
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// golden compares got with testdata/name, rewriting it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, got)
+	}
+}
+
+// Two listings of the same schema, with types, directives and object keys in
+// different orders. Fields keep the order the schema declares.
+const (
+	introspectionA = `{"data": {"__schema": {
+  "queryType": {"name": "Query"},
+  "directives": [
+    {"name": "skip", "locations": ["FIELD"], "args": []},
+    {"name": "include", "locations": ["FIELD"], "args": []}
+  ],
+  "types": [
+    {"kind": "OBJECT", "name": "User", "fields": [{"name": "name", "args": []}, {"name": "id", "args": []}]},
+    {"kind": "OBJECT", "name": "Query", "fields": [{"name": "users", "args": []}, {"name": "me", "args": []}]},
+    {"kind": "SCALAR", "name": "ID"}
+  ]
+}}}`
+	introspectionB = `{"data": {"__schema": {
+  "types": [
+    {"name": "ID", "kind": "SCALAR"},
+    {"fields": [{"args": [], "name": "users"}, {"args": [], "name": "me"}], "name": "Query", "kind": "OBJECT"},
+    {"name": "User", "kind": "OBJECT", "fields": [{"name": "name", "args": []}, {"name": "id", "args": []}]}
+  ],
+  "directives": [
+    {"args": [], "locations": ["FIELD"], "name": "include"},
+    {"name": "skip", "locations": ["FIELD"], "args": []}
+  ],
+  "queryType": {"name": "Query"}
+}}}`
+)
+
+func TestWriteIntrospectionIsDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	var outputs [][]byte
+	for i, doc := range []string{introspectionA, introspectionB, introspectionA} {
+		var resp map[string]interface{}
+		if err := json.Unmarshal([]byte(doc), &resp); err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(dir, fmt.Sprintf("dump%d.json", i))
+		if err := WriteIntrospectionToFile(resp, file); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, data)
+	}
+	for i := 1; i < len(outputs); i++ {
+		if !bytes.Equal(outputs[0], outputs[i]) {
+			t.Errorf("dump %d differs from dump 0:\n%s\n---\n%s", i, outputs[i], outputs[0])
+		}
+	}
+	golden(t, "normalized.golden.json", outputs[0])
+}
+
+func TestSchemaHashIgnoresOrder(t *testing.T) {
+	hash := func(doc string) string {
+		var resp map[string]interface{}
+		if err := json.Unmarshal([]byte(doc), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return SchemaHash(resp)
+	}
+	if a, b := hash(introspectionA), hash(introspectionB); a == "" || a != b {
+		t.Errorf("SchemaHash = %q and %q for the same schema", a, b)
+	}
+}
+
+func TestEndpointSuffix(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://example.com/graphql":         "graphql",
+		"https://example.com/app/graphql/":    "graphql",
+		"https://example.com//v1//gql//":      "gql",
+		"http://[::1]:4000/api/graphql":       "graphql",
+		"https://example.com/graphql?debug=1": "graphql",
+		"https://example.com/my%20api":        "my_api",
+		"https://example.com/..":              "root",
+		"https://example.com":                 "root",
+		"https://example.com/":                "root",
+		"https://example.com/graphql.v2#top":  "graphql.v2",
+		"http://[::1/graphql":                 "root",
+	} {
+		if got := EndpointSuffix(url); got != want {
+			t.Errorf("EndpointSuffix(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
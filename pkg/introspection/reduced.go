@@ -0,0 +1,178 @@
+package introspection
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// ReducedTypeRefDepth is the number of ofType levels kept by the shallow-typeref
+// reduction. It still resolves types such as [[String!]!]!.
+const ReducedTypeRefDepth = 4
+
+// reduction removes a part of the introspection query that some servers reject
+// while answering the rest of it.
+type reduction struct {
+	name string
+	// trigger matches the error messages of servers rejecting the removed part.
+	trigger *regexp.Regexp
+	apply   func(query string) string
+}
+
+// reductions are tried in order. Each is applied at most once and only when the
+// errors of the previous attempt match its trigger.
+var reductions = []reduction{
+	{
+		name:    "no-deprecation-args",
+		trigger: regexp.MustCompile(`(?i)includeDeprecated|unknown argument`),
+		apply: func(query string) string {
+			return strings.ReplaceAll(query, "(includeDeprecated: true)", "")
+		},
+	},
+	{
+		name:    "no-directives",
+		trigger: regexp.MustCompile(`(?i)directives|__Directive|locations`),
+		apply: func(query string) string {
+			return removeBlock(query, "directives {")
+		},
+	},
+	{
+		name:    "shallow-typeref",
+		trigger: regexp.MustCompile(`(?i)depth|too deep|nest|complexity`),
+		apply: func(query string) string {
+			i := strings.Index(query, "fragment TypeRef on __Type")
+			if i < 0 {
+				return query
+			}
+			return query[:i] + typeRefFragment(ReducedTypeRefDepth)
+		},
+	},
+}
+
+// removeBlock removes the selection starting with open, up to its matching
+// closing brace, from query.
+func removeBlock(query, open string) string {
+	start := strings.Index(query, open)
+	if start < 0 {
+		return query
+	}
+	depth := 0
+	for i := start + len(open) - 1; i < len(query); i++ {
+		switch query[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				// Drop the indentation before the block and the newline after it.
+				lineStart := strings.LastIndex(query[:start], "\n") + 1
+				end := i + 1
+				if end < len(query) && query[end] == '\n' {
+					end++
+				}
+				return query[:lineStart] + query[end:]
+			}
+		}
+	}
+	return query
+}
+
+// typeRefFragment renders the TypeRef fragment with depth levels of ofType.
+func typeRefFragment(depth int) string {
+	var b strings.Builder
+	b.WriteString("fragment TypeRef on __Type {\n  kind\n  name\n")
+	for i := 1; i <= depth; i++ {
+		indent := strings.Repeat("  ", i)
+		fmt.Fprintf(&b, "%sofType {\n%s  kind\n%s  name\n", indent, indent, indent)
+	}
+	for i := depth; i >= 1; i-- {
+		fmt.Fprintf(&b, "%s}\n", strings.Repeat("  ", i))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ReducedResult is a full introspection result obtained with a reduced query.
+type ReducedResult struct {
+	Query    string
+	Response map[string]interface{}
+	// Reductions names the reductions applied, in order.
+	Reductions []string
+}
+
+// FetchReduced retries the full introspection query after resp, its rejected
+// response, with reductions for the parts of the query the server's errors
+// point at. It returns nil when no combination of reductions is answered.
+func FetchReduced(ctx context.Context, url string, headers map[string]string, resp map[string]interface{}) *ReducedResult {
+	query := IntrospectionQuery
+	var applied []string
+	used := make([]bool, len(reductions))
+	for {
+		messages := errorMessages(resp)
+		next := -1
+		for i, r := range reductions {
+			if !used[i] && matchesAny(r.trigger, messages) {
+				next = i
+				break
+			}
+		}
+		if next < 0 || ctx.Err() != nil {
+			return nil
+		}
+		used[next] = true
+		query = reductions[next].apply(query)
+		applied = append(applied, reductions[next].name)
+
+		logger.Info("Introspection rejected on %s, retrying with reductions: %s", url, strings.Join(applied, ", "))
+		var err error
+		resp, err = network.SendGraphQLRequestWithContext(ctx, url, query, nil, headers)
+		if err != nil {
+			logger.Debug("→ Reduced introspection query failed: %v", err)
+			return nil
+		}
+		if IsIntrospectionEnabled(resp) {
+			return &ReducedResult{Query: query, Response: resp, Reductions: applied}
+		}
+	}
+}
+
+// MarkPartial records in the extensions of an introspection result that it
+// was fetched with reductions and may lack the removed parts.
+func MarkPartial(resp map[string]interface{}, reductions []string) {
+	ext, _ := resp["extensions"].(map[string]interface{})
+	if ext == nil {
+		ext = make(map[string]interface{})
+		resp["extensions"] = ext
+	}
+	ext["graphspecter"] = map[string]interface{}{
+		"partial":    true,
+		"reductions": reductions,
+	}
+}
+
+// errorMessages returns the messages of the GraphQL errors in resp.
+func errorMessages(resp map[string]interface{}) []string {
+	errs, _ := resp["errors"].([]interface{})
+	var messages []string
+	for _, e := range errs {
+		if m, ok := e.(map[string]interface{}); ok {
+			if msg, ok := m["message"].(string); ok {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return messages
+}
+
+func matchesAny(re *regexp.Regexp, messages []string) bool {
+	for _, m := range messages {
+		if re.MatchString(m) {
+			return true
+		}
+	}
+	return false
+}
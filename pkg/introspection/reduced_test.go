@@ -0,0 +1,205 @@
+package introspection
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Parts of the introspection query a pickyServer can reject
+const (
+	rejectDeprecation = "deprecation"
+	rejectDirectives  = "directives"
+	rejectDepth       = "depth"
+)
+
+// pickyRejections are the errors servers answer the rejected parts with.
+var pickyRejections = map[string]string{
+	rejectDeprecation: `Unknown argument "includeDeprecated" on field "fields" of type "__Type".`,
+	rejectDirectives:  `Cannot query field "directives" on type "__Schema".`,
+	rejectDepth:       "Query is nested too deep: depth 9 exceeds the maximum of 7.",
+}
+
+// pickyServer rejects introspection queries holding the given parts and
+// answers the others with a small schema. With oneAtATime it reports a single
+// error per response, as servers stopping at the first failing rule do. It
+// records the queries it receives.
+type pickyServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	queries []string
+}
+
+func newPickyServer(t *testing.T, oneAtATime bool, rejects ...string) *pickyServer {
+	t.Helper()
+	s := &pickyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		s.mu.Lock()
+		s.queries = append(s.queries, req.Query)
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+
+		var errs []interface{}
+		for _, part := range rejects {
+			if pickyRejects(req.Query, part) {
+				errs = append(errs, map[string]interface{}{"message": pickyRejections[part]})
+			}
+		}
+		if len(errs) > 0 {
+			if oneAtATime {
+				errs = errs[:1]
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": errs})
+			return
+		}
+		w.Write([]byte(`{"data":{"__schema":{"queryType":{"name":"Query"},"mutationType":null,"subscriptionType":null,"types":[` +
+			`{"kind":"OBJECT","name":"Query","fields":[{"name":"hello","args":[],"type":{"kind":"SCALAR","name":"String","ofType":null}}]},` +
+			`{"kind":"SCALAR","name":"String"}]}}}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// pickyRejects reports whether query holds the part a server rejects.
+func pickyRejects(query, part string) bool {
+	switch part {
+	case rejectDeprecation:
+		return strings.Contains(query, "includeDeprecated")
+	case rejectDirectives:
+		return strings.Contains(query, "directives {")
+	case rejectDepth:
+		return typeRefDepth(query) > ReducedTypeRefDepth
+	}
+	return false
+}
+
+// typeRefDepth returns the number of ofType levels of the TypeRef fragment of query.
+func typeRefDepth(query string) int {
+	i := strings.Index(query, "fragment TypeRef on __Type")
+	if i < 0 {
+		return 0
+	}
+	return strings.Count(query[i:], "ofType {")
+}
+
+func TestFetchReduced(t *testing.T) {
+	tests := []struct {
+		name       string
+		rejects    []string
+		oneAtATime bool
+		reductions []string
+	}{
+		{"deprecation args", []string{rejectDeprecation}, false, []string{"no-deprecation-args"}},
+		{"directives", []string{rejectDirectives}, false, []string{"no-directives"}},
+		{"deep type references", []string{rejectDepth}, false, []string{"shallow-typeref"}},
+		{"every part at once", []string{rejectDepth, rejectDirectives, rejectDeprecation}, false, []string{"no-deprecation-args", "no-directives", "shallow-typeref"}},
+		{"every part, one error at a time", []string{rejectDepth, rejectDirectives, rejectDeprecation}, true, []string{"shallow-typeref", "no-directives", "no-deprecation-args"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newPickyServer(t, tt.oneAtATime, tt.rejects...)
+			resp, err := CheckIntrospectionWithContext(context.Background(), srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if IsIntrospectionEnabled(resp) {
+				t.Fatal("the full query was answered")
+			}
+			reduced := FetchReduced(context.Background(), srv.URL, nil, resp)
+			if reduced == nil {
+				t.Fatal("FetchReduced() = nil, want the reduced result")
+			}
+			if !reflect.DeepEqual(reduced.Reductions, tt.reductions) {
+				t.Errorf("Reductions = %v, want %v", reduced.Reductions, tt.reductions)
+			}
+			if !IsIntrospectionEnabled(reduced.Response) {
+				t.Errorf("Response = %v, want the schema", reduced.Response)
+			}
+			// The full query, then one request per reduction.
+			if len(srv.queries) != 1+len(tt.reductions) || srv.queries[len(srv.queries)-1] != reduced.Query {
+				t.Errorf("sent %d queries, want %d ending with the reduced one", len(srv.queries), 1+len(tt.reductions))
+			}
+
+			// Only the rejected parts are removed.
+			rejected := make(map[string]bool)
+			for _, part := range tt.rejects {
+				rejected[part] = true
+			}
+			for _, part := range []string{rejectDeprecation, rejectDirectives, rejectDepth} {
+				if got := pickyRejects(reduced.Query, part); got == rejected[part] {
+					t.Errorf("reduced query holds %s = %v", part, got)
+				}
+			}
+			if rejected[rejectDepth] && typeRefDepth(reduced.Query) != ReducedTypeRefDepth {
+				t.Errorf("TypeRef fragment has %d levels, want %d", typeRefDepth(reduced.Query), ReducedTypeRefDepth)
+			}
+			for _, fragment := range []string{"fragment FullType on __Type", "fragment InputValue on __InputValue", "fragment TypeRef on __Type"} {
+				if !strings.Contains(reduced.Query, fragment) {
+					t.Errorf("reduced query lost %q:\n%s", fragment, reduced.Query)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchReducedGivesUp(t *testing.T) {
+	t.Run("unrelated refusal", func(t *testing.T) {
+		srv := newPickyServer(t, false)
+		resp := map[string]interface{}{"errors": []interface{}{map[string]interface{}{"message": "GraphQL introspection is not allowed"}}}
+		if reduced := FetchReduced(context.Background(), srv.URL, nil, resp); reduced != nil || len(srv.queries) != 0 {
+			t.Errorf("FetchReduced() = %+v after %d requests, want nil without retrying", reduced, len(srv.queries))
+		}
+	})
+	t.Run("still rejected", func(t *testing.T) {
+		// The server keeps complaining about directives once they are gone.
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []interface{}{map[string]interface{}{"message": pickyRejections[rejectDirectives]}}})
+		}))
+		defer srv.Close()
+		resp, err := CheckIntrospectionWithContext(context.Background(), srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reduced := FetchReduced(context.Background(), srv.URL, nil, resp); reduced != nil {
+			t.Errorf("FetchReduced() = %+v, want nil once every matching reduction was tried", reduced)
+		}
+	})
+	t.Run("canceled", func(t *testing.T) {
+		srv := newPickyServer(t, false, rejectDirectives)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		resp := map[string]interface{}{"errors": []interface{}{map[string]interface{}{"message": pickyRejections[rejectDirectives]}}}
+		if reduced := FetchReduced(ctx, srv.URL, nil, resp); reduced != nil || len(srv.queries) != 0 {
+			t.Errorf("FetchReduced() = %+v after %d requests, want nil", reduced, len(srv.queries))
+		}
+	})
+}
+
+func TestProbeTiersMarksReducedSchemaPartial(t *testing.T) {
+	srv := newPickyServer(t, false, rejectDirectives, rejectDeprecation)
+	tr, err := ProbeTiers(context.Background(), srv.URL, nil, ProbeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Level != TierFull || !reflect.DeepEqual(tr.Reductions, []string{"no-deprecation-args", "no-directives"}) {
+		t.Fatalf("Level = %s, Reductions = %v; want the full tier after two reductions", tr.Level, tr.Reductions)
+	}
+	if tr.Results[0].Query != srv.queries[2] {
+		t.Error("the full tier result does not record the reduced query")
+	}
+	ext, _ := tr.Full["extensions"].(map[string]interface{})
+	meta, _ := ext["graphspecter"].(map[string]interface{})
+	if meta["partial"] != true || !reflect.DeepEqual(meta["reductions"], tr.Reductions) {
+		t.Errorf("extensions = %v, want the schema marked partial with its reductions", tr.Full["extensions"])
+	}
+}
@@ -0,0 +1,214 @@
+package introspection
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+)
+
+// Tier is a level of introspection access, ordered from most to least permissive.
+type Tier int
+
+// Introspection tiers
+const (
+	// TierFull means the complete introspection query is answered.
+	TierFull Tier = iota
+	// TierTypesOnly means __schema answers a reduced selection listing type names.
+	TierTypesOnly
+	// TierType means __schema is blocked but __type(name:) is answered.
+	TierType
+	// TierTypename means only __typename is answered.
+	TierTypename
+	// TierNone means no introspection probe succeeded.
+	TierNone
+)
+
+// String returns the name used for the tier in logs and findings
+func (t Tier) String() string {
+	switch t {
+	case TierFull:
+		return "full"
+	case TierTypesOnly:
+		return "__schema types-only"
+	case TierType:
+		return "__type accessible"
+	case TierTypename:
+		return "__typename only"
+	default:
+		return "none"
+	}
+}
+
+// Partial reports whether some, but not all, of the schema can be read through introspection.
+func (t Tier) Partial() bool {
+	return t == TierTypesOnly || t == TierType
+}
+
+// Suggestion returns the follow-up technique for recovering the schema at this tier.
+func (t Tier) Suggestion() string {
+	switch t {
+	case TierTypesOnly:
+		return "List the type names, then query __type(name:) for each of them to rebuild the schema."
+	case TierType:
+		return "Query __type(name:) for Query, Mutation and the types they reference to rebuild the schema piece by piece."
+	case TierTypename:
+		return "Introspection is disabled; recover the schema by brute-forcing field names from server suggestions (--recover-schema)."
+	default:
+		return ""
+	}
+}
+
+// TierResult is the outcome of the probe for a single tier.
+type TierResult struct {
+	Tier       Tier
+	Query      string
+	Accessible bool
+	Error      string
+}
+
+// TierReport summarises a tiered introspection probe.
+type TierReport struct {
+	// Level is the most permissive tier that was accessible.
+	Level   Tier
+	Results []TierResult
+	// Full holds the introspection result when Level is TierFull.
+	Full map[string]interface{}
+	// Reductions names the parts removed from the full query before the server
+	// answered it. Full is then marked partial with MarkPartial.
+	Reductions []string
+	// Banner is the first terms-of-use or consent notice a probe was answered
+	// with, see ConsentBanner.
+	Banner string
+}
+
+// tierProbe is one entry of the tier table
+type tierProbe struct {
+	tier       Tier
+	query      string
+	accessible func(data map[string]interface{}) bool
+}
+
+var tierProbes = []tierProbe{
+	{TierFull, IntrospectionQuery, func(data map[string]interface{}) bool {
+		return IsIntrospectionEnabled(map[string]interface{}{"data": data})
+	}},
+	{TierTypesOnly, `query { __schema { types { name } } }`, func(data map[string]interface{}) bool {
+		schema, _ := data["__schema"].(map[string]interface{})
+		types, _ := schema["types"].([]interface{})
+		return len(types) > 0
+	}},
+	{TierType, `query { __type(name: "Query") { name fields { name } } }`, func(data map[string]interface{}) bool {
+		t, ok := data["__type"].(map[string]interface{})
+		return ok && t["name"] != nil
+	}},
+	{TierTypename, `query { __typename }`, func(data map[string]interface{}) bool {
+		_, ok := data["__typename"].(string)
+		return ok
+	}},
+}
+
+// ProbeOptions controls how the full introspection tier is fetched.
+type ProbeOptions struct {
+	// Chunked fetches the schema with FetchChunked instead of the single full query.
+	Chunked bool
+	// ChunkSize is the number of types per chunked request.
+	ChunkSize int
+	// Validators, when set, make the single full query conditional: a schema
+	// the server reports unchanged returns gerrors.ErrNotModified.
+	Validators *network.Validators
+}
+
+// ProbeTiers runs the tier probes from most to least permissive and stops at the
+// first one that is accessible. An error is returned only when the first probe
+// fails at the transport level, meaning the target could not be queried at all.
+// A full query that times out or is too large is retried in chunks, and one
+// rejected for the parts of it some servers do not support is retried reduced.
+func ProbeTiers(ctx context.Context, url string, headers map[string]string, opts ProbeOptions) (*TierReport, error) {
+	tr := &TierReport{Level: TierNone}
+	for _, probe := range tierProbes {
+		if ctx.Err() != nil {
+			return tr, ctx.Err()
+		}
+
+		result := TierResult{Tier: probe.tier, Query: probe.query}
+		var resp map[string]interface{}
+		var err error
+		if probe.tier == TierFull && opts.Chunked {
+			resp, err = FetchChunked(ctx, url, headers, opts.ChunkSize)
+		} else if probe.tier == TierFull {
+			fullCtx := ctx
+			if opts.Validators != nil {
+				fullCtx = network.WithValidators(ctx, opts.Validators)
+			}
+			resp, err = CheckIntrospectionWithContext(fullCtx, url, headers)
+			if ShouldChunk(ctx, err) {
+				logger.Info("Full introspection query failed on %s (%v), retrying in chunks", url, err)
+				resp, err = FetchChunked(ctx, url, headers, opts.ChunkSize)
+			} else if err == nil && !IsIntrospectionEnabled(resp) {
+				if reduced := FetchReduced(ctx, url, headers, resp); reduced != nil {
+					resp = reduced.Response
+					result.Query = reduced.Query
+					tr.Reductions = reduced.Reductions
+					MarkPartial(resp, reduced.Reductions)
+				}
+			}
+		} else {
+			logger.Debug("→ Probing introspection tier %q", probe.tier)
+			resp, err = network.SendGraphQLRequestWithContext(ctx, url, probe.query, nil, headers)
+		}
+		if tr.Banner == "" {
+			tr.Banner = ConsentBanner(resp, err)
+		}
+		if err != nil {
+			if probe.tier == TierFull {
+				return nil, err
+			}
+			result.Error = err.Error()
+			tr.Results = append(tr.Results, result)
+			continue
+		}
+
+		data, _ := resp["data"].(map[string]interface{})
+		result.Accessible = data != nil && probe.accessible(data)
+		if !result.Accessible {
+			result.Error = firstErrorMessage(resp)
+		}
+		tr.Results = append(tr.Results, result)
+
+		if result.Accessible {
+			tr.Level = probe.tier
+			if probe.tier == TierFull {
+				tr.Full = resp
+			}
+			break
+		}
+	}
+	return tr, nil
+}
+
+// firstErrorMessage returns the message of the first GraphQL error in resp.
+func firstErrorMessage(resp map[string]interface{}) string {
+	errs, _ := resp["errors"].([]interface{})
+	if len(errs) == 0 {
+		return ""
+	}
+	if m, ok := errs[0].(map[string]interface{}); ok {
+		if msg, ok := m["message"].(string); ok {
+			return msg
+		}
+	}
+	return fmt.Sprint(errs[0])
+}
+
+// PlanRequests returns how many requests ProbeTiers sends: min when the full
+// query is answered at once, max when it is refused after every reduction and
+// each lower tier is probed in turn. Chunked fetches add one request per batch
+// of types, which is only known once the type list is read.
+func PlanRequests(opts ProbeOptions) (min, max int) {
+	if opts.Chunked {
+		return 2, 1 + len(tierProbes)
+	}
+	return 1, 1 + len(reductions) + len(tierProbes) - 1
+}
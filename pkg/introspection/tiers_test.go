@@ -0,0 +1,134 @@
+package introspection
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// tieredServer is a mock GraphQL server that answers the introspection probes
+// down to the tier open and refuses the more permissive ones. It counts the
+// requests it receives.
+func tieredServer(t *testing.T, open Tier) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var n atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.Add(1)
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var tier Tier
+		switch {
+		case strings.Contains(req.Query, "queryType"):
+			tier = TierFull
+		case strings.Contains(req.Query, "__schema"):
+			tier = TierTypesOnly
+		case strings.Contains(req.Query, "__type("):
+			tier = TierType
+		default:
+			tier = TierTypename
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if tier < open {
+			w.Write([]byte(`{"errors":[{"message":"GraphQL introspection is not allowed by Apollo Server"}]}`))
+			return
+		}
+		switch tier {
+		case TierFull:
+			w.Write([]byte(introspectionA))
+		case TierTypesOnly:
+			w.Write([]byte(`{"data":{"__schema":{"types":[{"name":"Query"},{"name":"User"}]}}}`))
+		case TierType:
+			w.Write([]byte(`{"data":{"__type":{"name":"Query","fields":[{"name":"me"}]}}}`))
+		default:
+			w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+func TestProbeTiers(t *testing.T) {
+	tests := []struct {
+		open     Tier
+		want     Tier
+		requests int
+		partial  bool
+		// suggestion is a part of the suggested fallback, empty for none.
+		suggestion string
+	}{
+		{open: TierFull, want: TierFull, requests: 1},
+		{open: TierTypesOnly, want: TierTypesOnly, requests: 2, partial: true, suggestion: "List the type names"},
+		{open: TierType, want: TierType, requests: 3, partial: true, suggestion: "Query __type(name:)"},
+		{open: TierTypename, want: TierTypename, requests: 4, suggestion: "--recover-schema"},
+		{open: TierNone, want: TierNone, requests: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.open.String(), func(t *testing.T) {
+			srv, sent := tieredServer(t, tt.open)
+			tr, err := ProbeTiers(context.Background(), srv.URL, nil, ProbeOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tr.Level != tt.want {
+				t.Errorf("Level = %s, want %s", tr.Level, tt.want)
+			}
+			if n := int(sent.Load()); n != tt.requests {
+				t.Errorf("sent %d request(s), want %d", n, tt.requests)
+			}
+			if len(tr.Results) != tt.requests {
+				t.Fatalf("%d results, want one per probe sent", len(tr.Results))
+			}
+			for i, r := range tr.Results {
+				if r.Tier != Tier(i) {
+					t.Errorf("result %d is for tier %s, want the tiers in order", i, r.Tier)
+				}
+				last := i == len(tr.Results)-1
+				if r.Accessible != (last && tt.want != TierNone) {
+					t.Errorf("tier %s accessible = %v", r.Tier, r.Accessible)
+				}
+				if !r.Accessible && !strings.Contains(r.Error, "introspection is not allowed") {
+					t.Errorf("tier %s error = %q, want the server's", r.Tier, r.Error)
+				}
+			}
+			if (tr.Full != nil) != (tt.want == TierFull) {
+				t.Errorf("Full = %v, want it only at the full tier", tr.Full != nil)
+			}
+			if tr.Level.Partial() != tt.partial {
+				t.Errorf("Partial() = %v, want %v", tr.Level.Partial(), tt.partial)
+			}
+			if got := tr.Level.Suggestion(); (tt.suggestion == "") != (got == "") || !strings.Contains(got, tt.suggestion) {
+				t.Errorf("Suggestion() = %q, want one containing %q", got, tt.suggestion)
+			}
+			if min, max := PlanRequests(ProbeOptions{}); tt.requests < min || tt.requests > max {
+				t.Errorf("%d request(s) outside the plan %d-%d", tt.requests, min, max)
+			}
+		})
+	}
+}
+
+func TestProbeTiersUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	if tr, err := ProbeTiers(context.Background(), url, nil, ProbeOptions{}); err == nil {
+		t.Errorf("ProbeTiers() on a closed server = %+v, want an error", tr)
+	}
+}
+
+func TestProbeTiersCanceled(t *testing.T) {
+	srv, sent := tieredServer(t, TierFull)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ProbeTiers(ctx, srv.URL, nil, ProbeOptions{}); err == nil {
+		t.Error("ProbeTiers() with a canceled context succeeded")
+	}
+	if n := sent.Load(); n != 0 {
+		t.Errorf("sent %d request(s) after the cancellation", n)
+	}
+}
@@ -0,0 +1,285 @@
+// Package jsondiff compares decoded JSON values structurally, leaving out the
+// paths expected to change between two responses, such as timestamps and
+// request ids, and renders the differences compactly for reports.
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Difference kinds
+const (
+	// Added is a value only the second document holds
+	Added = "added"
+	// Removed is a value only the first document holds
+	Removed = "removed"
+	// Changed is a value that differs between the documents
+	Changed = "changed"
+)
+
+const (
+	// MaxRendered is the number of differences Render lists before summarising the rest
+	MaxRendered = 20
+
+	// maxValueLength bounds the rendering of a value
+	maxValueLength = 80
+)
+
+// Options configures Compare. Paths are dot-separated member names and array
+// indices starting at the root of the documents, such as data.users.0.id.
+// Each segment of a pattern is matched with path.Match, so * matches any
+// member or index, and a ** segment matches any number of segments:
+// data.*.updatedAt, extensions.requestId or **.cursor.
+type Options struct {
+	// Ignore lists the patterns of the paths left out of the comparison.
+	Ignore []string
+	// Unordered lists the patterns of the arrays compared regardless of the
+	// order of their elements.
+	Unordered []string
+}
+
+// Difference is a path on which two documents differ.
+type Difference struct {
+	Path string      `json:"path"`
+	Kind string      `json:"kind"`
+	A    interface{} `json:"a,omitempty"`
+	B    interface{} `json:"b,omitempty"`
+}
+
+// ParsePaths splits a comma-separated list of path patterns.
+func ParsePaths(list string) []string {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// Validate reports the first malformed pattern of opts.
+func (opts Options) Validate() error {
+	for _, p := range append(append([]string(nil), opts.Ignore...), opts.Unordered...) {
+		for _, segment := range strings.Split(p, ".") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid path pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Compare returns the differences between the decoded JSON documents a and b,
+// ordered by path. Arrays matching opts.Unordered are sorted before they are
+// compared, so the indices of their differences refer to the sorted elements.
+func Compare(a, b interface{}, opts Options) []Difference {
+	c := comparer{ignore: splitPatterns(opts.Ignore), unordered: splitPatterns(opts.Unordered)}
+	a, b = c.normalize(a, nil), c.normalize(b, nil)
+	c.diff(a, b, nil)
+	return c.diffs
+}
+
+// Equal reports whether a and b hold no difference under opts.
+func Equal(a, b interface{}, opts Options) bool {
+	return len(Compare(a, b, opts)) == 0
+}
+
+// Render lists the differences one per line: "~ path: a -> b" for changed
+// values, "- path: a" for removed and "+ path: b" for added ones. Values are
+// shortened and at most MaxRendered differences are listed.
+func Render(diffs []Difference) string {
+	var b strings.Builder
+	for i, d := range diffs {
+		if i == MaxRendered {
+			fmt.Fprintf(&b, "... and %d more differences\n", len(diffs)-MaxRendered)
+			break
+		}
+		p := d.Path
+		if p == "" {
+			p = "(root)"
+		}
+		switch d.Kind {
+		case Added:
+			fmt.Fprintf(&b, "+ %s: %s\n", p, renderValue(d.B))
+		case Removed:
+			fmt.Fprintf(&b, "- %s: %s\n", p, renderValue(d.A))
+		default:
+			fmt.Fprintf(&b, "~ %s: %s -> %s\n", p, renderValue(d.A), renderValue(d.B))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// renderValue encodes v as JSON, shortened to maxValueLength.
+func renderValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	s := []rune(string(data))
+	if len(s) > maxValueLength {
+		s = append(s[:maxValueLength-3], []rune("...")...)
+	}
+	return string(s)
+}
+
+func splitPatterns(patterns []string) [][]string {
+	split := make([][]string, len(patterns))
+	for i, p := range patterns {
+		split[i] = strings.Split(p, ".")
+	}
+	return split
+}
+
+type comparer struct {
+	ignore, unordered [][]string
+	diffs             []Difference
+}
+
+// normalize returns v without its ignored members, with unordered arrays sorted.
+func (c *comparer) normalize(v interface{}, at []string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		n := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			p := appendPath(at, k)
+			if matchAny(c.ignore, p) {
+				continue
+			}
+			n[k] = c.normalize(child, p)
+		}
+		return n
+	case []interface{}:
+		n := make([]interface{}, 0, len(v))
+		for i, child := range v {
+			p := appendPath(at, strconv.Itoa(i))
+			if matchAny(c.ignore, p) {
+				continue
+			}
+			n = append(n, c.normalize(child, p))
+		}
+		if matchAny(c.unordered, at) {
+			keys := make([]string, len(n))
+			for i, child := range n {
+				// encoding/json sorts map keys, so equal values encode the same.
+				data, _ := json.Marshal(child)
+				keys[i] = string(data)
+			}
+			sort.Sort(byKey{n, keys})
+		}
+		return n
+	}
+	return v
+}
+
+func (c *comparer) diff(a, b interface{}, at []string) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			keys := make(map[string]bool, len(av)+len(bv))
+			for k := range av {
+				keys[k] = true
+			}
+			for k := range bv {
+				keys[k] = true
+			}
+			sorted := make([]string, 0, len(keys))
+			for k := range keys {
+				sorted = append(sorted, k)
+			}
+			sort.Strings(sorted)
+			for _, k := range sorted {
+				ac, inA := av[k]
+				bc, inB := bv[k]
+				p := appendPath(at, k)
+				switch {
+				case !inB:
+					c.add(p, Removed, ac, nil)
+				case !inA:
+					c.add(p, Added, nil, bc)
+				default:
+					c.diff(ac, bc, p)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			for i := 0; i < len(av) || i < len(bv); i++ {
+				p := appendPath(at, strconv.Itoa(i))
+				switch {
+				case i >= len(bv):
+					c.add(p, Removed, av[i], nil)
+				case i >= len(av):
+					c.add(p, Added, nil, bv[i])
+				default:
+					c.diff(av[i], bv[i], p)
+				}
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		c.add(at, Changed, a, b)
+	}
+}
+
+func (c *comparer) add(at []string, kind string, a, b interface{}) {
+	c.diffs = append(c.diffs, Difference{Path: strings.Join(at, "."), Kind: kind, A: a, B: b})
+}
+
+// appendPath returns at extended with segment, leaving at unchanged.
+func appendPath(at []string, segment string) []string {
+	return append(append(make([]string, 0, len(at)+1), at...), segment)
+}
+
+// matchAny reports whether p matches one of patterns.
+func matchAny(patterns [][]string, p []string) bool {
+	for _, pattern := range patterns {
+		if match(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether the segments of p match pattern.
+func match(pattern, p []string) bool {
+	if len(pattern) == 0 {
+		return len(p) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(p); i++ {
+			if match(pattern[1:], p[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(p) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], p[0]); !ok {
+		return false
+	}
+	return match(pattern[1:], p[1:])
+}
+
+// byKey sorts values by their keys.
+type byKey struct {
+	values []interface{}
+	keys   []string
+}
+
+func (s byKey) Len() int           { return len(s.values) }
+func (s byKey) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s byKey) Swap(i, j int) {
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
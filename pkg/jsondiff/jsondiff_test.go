@@ -0,0 +1,162 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("decoding %s: %v", s, err)
+	}
+	return v
+}
+
+func TestCompare(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		opts Options
+		want []string
+	}{
+		{
+			name: "equal",
+			a:    `{"data":{"user":{"id":"1","tags":["a","b"]}}}`,
+			b:    `{"data":{"user":{"tags":["a","b"],"id":"1"}}}`,
+		},
+		{
+			name: "changed added and removed",
+			a:    `{"data":{"id":"1","name":"a","old":true}}`,
+			b:    `{"data":{"id":"2","name":"a","new":1}}`,
+			want: []string{"data.id changed", "data.new added", "data.old removed"},
+		},
+		{
+			name: "type change",
+			a:    `{"data":{"user":{"id":"1"}}}`,
+			b:    `{"data":{"user":null}}`,
+			want: []string{"data.user changed"},
+		},
+		{
+			name: "ignored member",
+			a:    `{"data":{"id":"1"},"extensions":{"requestId":"x"}}`,
+			b:    `{"data":{"id":"1"},"extensions":{"requestId":"y"}}`,
+			opts: Options{Ignore: []string{"extensions.requestId"}},
+		},
+		{
+			name: "nested ignore with a wildcard",
+			a:    `{"data":{"orders":[{"id":"1","updatedAt":"t1"},{"id":"2","updatedAt":"t1"}]}}`,
+			b:    `{"data":{"orders":[{"id":"1","updatedAt":"t2"},{"id":"3","updatedAt":"t2"}]}}`,
+			opts: Options{Ignore: []string{"data.orders.*.updatedAt"}},
+			want: []string{"data.orders.1.id changed"},
+		},
+		{
+			name: "ignore at any depth",
+			a:    `{"data":{"a":{"cursor":"1","b":{"cursor":"2","v":1}}}}`,
+			b:    `{"data":{"a":{"cursor":"3","b":{"cursor":"4","v":1}}}}`,
+			opts: Options{Ignore: []string{"**.cursor"}},
+		},
+		{
+			name: "wildcard does not match deeper",
+			a:    `{"data":{"a":{"updatedAt":"1"}}}`,
+			b:    `{"data":{"a":{"updatedAt":"2"}}}`,
+			opts: Options{Ignore: []string{"data.updatedAt", "*.updatedAt"}},
+			want: []string{"data.a.updatedAt changed"},
+		},
+		{
+			name: "ordered arrays",
+			a:    `{"data":[1,2,3]}`,
+			b:    `{"data":[3,2,1]}`,
+			want: []string{"data.0 changed", "data.2 changed"},
+		},
+		{
+			name: "unordered arrays",
+			a:    `{"data":{"users":[{"id":"2","n":{"x":1}},{"id":"1"}]}}`,
+			b:    `{"data":{"users":[{"id":"1"},{"n":{"x":1},"id":"2"}]}}`,
+			opts: Options{Unordered: []string{"data.users"}},
+		},
+		{
+			name: "unordered arrays of different length",
+			a:    `{"data":[3,1]}`,
+			b:    `{"data":[1,2,3]}`,
+			opts: Options{Unordered: []string{"data"}},
+			want: []string{"data.1 changed", "data.2 added"},
+		},
+		{
+			name: "ignored before sorting",
+			a:    `{"data":[{"id":"1","at":"x"},{"id":"2","at":"y"}]}`,
+			b:    `{"data":[{"id":"2","at":"z"},{"id":"1","at":"w"}]}`,
+			opts: Options{Ignore: []string{"data.*.at"}, Unordered: []string{"data"}},
+		},
+		{
+			name: "removed array element",
+			a:    `{"data":[1,2]}`,
+			b:    `{"data":[1]}`,
+			want: []string{"data.1 removed"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, d := range Compare(decode(t, tc.a), decode(t, tc.b), tc.opts) {
+				got = append(got, d.Path+" "+d.Kind)
+			}
+			if strings.Join(got, ", ") != strings.Join(tc.want, ", ") {
+				t.Errorf("Compare = %v, want %v", got, tc.want)
+			}
+			if eq := Equal(decode(t, tc.a), decode(t, tc.b), tc.opts); eq != (len(tc.want) == 0) {
+				t.Errorf("Equal = %v", eq)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	diffs := Compare(
+		decode(t, `{"data":{"id":"1","old":[1],"user":{"name":"a"}}}`),
+		decode(t, `{"data":{"id":"2","new":{"x":true},"user":{"name":"b"}}}`),
+		Options{},
+	)
+	want := `~ data.id: "1" -> "2"
++ data.new: {"x":true}
+- data.old: [1]
+~ data.user.name: "a" -> "b"`
+	if got := Render(diffs); got != want {
+		t.Errorf("Render =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := Render([]Difference{{Kind: Changed, A: 1.0, B: "1"}}); got != `~ (root): 1 -> "1"` {
+		t.Errorf("Render of the root = %q", got)
+	}
+
+	long := strings.Repeat("é", 100)
+	got := Render([]Difference{{Path: "data.s", Kind: Added, B: long}})
+	if want := `+ data.s: "` + strings.Repeat("é", maxValueLength-4) + "..."; got != want {
+		t.Errorf("Render of a long value = %q, want %q", got, want)
+	}
+}
+
+func TestRenderTruncates(t *testing.T) {
+	var diffs []Difference
+	for i := 0; i < MaxRendered+5; i++ {
+		diffs = append(diffs, Difference{Path: fmt.Sprintf("data.%d", i), Kind: Removed, A: i})
+	}
+	lines := strings.Split(Render(diffs), "\n")
+	if len(lines) != MaxRendered+1 || lines[MaxRendered] != "... and 5 more differences" {
+		t.Errorf("Render = %d lines ending %q", len(lines), lines[len(lines)-1])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Options{Ignore: ParsePaths(" data.*.updatedAt , ,**.cursor")}).Validate(); err != nil {
+		t.Errorf("Validate = %v", err)
+	}
+	if err := (Options{Unordered: []string{"data.[a"}}).Validate(); err == nil {
+		t.Error("Validate accepted a malformed pattern")
+	}
+	if got := ParsePaths(" a, ,b "); strings.Join(got, "|") != "a|b" {
+		t.Errorf("ParsePaths = %q", got)
+	}
+}
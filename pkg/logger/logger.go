@@ -5,7 +5,8 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/clock"
 )
 
 // LogLevel represents the severity level of a log message
@@ -27,9 +28,13 @@ var (
 	// output is where log messages are written
 	output io.Writer = os.Stdout
 
-	// logFile is the file handler for log file output
+	// logFile is the file handler for log file output. It gets RFC 3339 UTC
+	// timestamps, the terminal local time unless useUTC is set.
 	logFile *os.File
 
+	// useUTC prints the terminal timestamps in UTC
+	useUTC bool
+
 	// logLevelStrings maps log levels to their string representations
 	logLevelStrings = map[LogLevel]string{
 		LevelDebug: "DEBUG",
@@ -51,24 +56,45 @@ var (
 	// colorReset is the ANSI code to reset color
 	colorReset = "\033[0m"
 
-	// useColors determines if color should be used in log output
-	useColors = true
+	// colorsEnabled is false once colors were turned off, as with --no-color
+	colorsEnabled = true
+
+	// useColors determines if color should be used in log output: colors
+	// are enabled and the output supports them
+	useColors = ColorsSupported(os.Stdout)
+
+	// exitHook runs before the logger exits the process
+	exitHook func(message string)
 )
 
+// SetExitHook installs f to run with the error message before the logger exits
+// the process on a fatal message or an unusable log file. A nil f removes it.
+func SetExitHook(f func(message string)) {
+	exitHook = f
+}
+
 // SetLevel sets the current log level
 func SetLevel(level LogLevel) {
 	currentLevel = level
 }
 
-// SetOutput sets the output writer for logs
+// SetUTC prints the timestamps of terminal output in UTC instead of local time.
+func SetUTC(enable bool) {
+	useUTC = enable
+}
+
+// SetOutput sets the output writer for logs, colored when colors are enabled
+// and w supports them.
 func SetOutput(w io.Writer) {
 	output = w
+	useColors = Colors(w)
 }
 
 // SetupLogging configures logging from CLI flags.
 // level: "debug", "info", "warn", "error", "fatal" (only that level appears)
 // logFilePath: path for file output (append)
-// enableColors: whether to colorize terminal output
+// enableColors: whether to colorize terminal output, which also needs a
+// terminal stdout and NO_COLOR unset
 func SetupLogging(level string, logFilePath string, enableColors bool) {
 	switch level {
 	case "debug":
@@ -88,6 +114,9 @@ func SetupLogging(level string, logFilePath string, enableColors bool) {
 	if logFilePath != "" {
 		if err := SetLogFile(logFilePath); err != nil {
 			fmt.Printf("Error setting up log file: %v\n", err)
+			if exitHook != nil {
+				exitHook(fmt.Sprintf("Error setting up log file: %v", err))
+			}
 			os.Exit(1)
 		}
 	}
@@ -97,6 +126,8 @@ func SetupLogging(level string, logFilePath string, enableColors bool) {
 
 // SetLogFile sets up logging to a file in addition to stdout
 func SetLogFile(filename string) error {
+	mu.Lock()
+	defer mu.Unlock()
 	if logFile != nil {
 		logFile.Close()
 	}
@@ -105,24 +136,51 @@ func SetLogFile(filename string) error {
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %w", err)
 	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
 
 	logFile = file
-	output = io.MultiWriter(os.Stdout, logFile)
+	logFileName = filename
+	logFileSize = info.Size()
 	return nil
 }
 
-// CloseLogFile closes the log file if one is open
+// CloseLogFile flushes and closes the log file if one is open, and waits for
+// the compression of the last rotated file.
 func CloseLogFile() {
+	mu.Lock()
+	defer mu.Unlock()
 	if logFile != nil {
+		logFile.Sync()
 		logFile.Close()
 		logFile = nil
-		output = os.Stdout
 	}
+	compressing.Wait()
 }
 
-// EnableColors toggles colored output
+// EnableColors toggles colored output. Colors stay off on outputs that do not
+// support them (see ColorsSupported).
 func EnableColors(enable bool) {
-	useColors = enable
+	colorsEnabled = enable
+	useColors = Colors(output)
+}
+
+// Colors reports whether output written to w is colored: colors are enabled
+// and w supports them. Tables and summaries printed next to the log use it.
+func Colors(w io.Writer) bool {
+	return colorsEnabled && ColorsSupported(w)
+}
+
+// Color returns s in the ANSI color code when output written to w is
+// colored, and s unchanged otherwise.
+func Color(w io.Writer, code, s string) string {
+	if !Colors(w) {
+		return s
+	}
+	return code + s + colorReset
 }
 
 // log formats and writes a log message at the given level.
@@ -133,23 +191,41 @@ func log(level LogLevel, format string, args ...interface{}) {
 		return
 	}
 
-	now := time.Now().Format("2006-01-02 15:04:05.000")
+	t := clock.Now()
+	now := t.Local().Format("2006-01-02 15:04:05.000")
+	if useUTC {
+		now = clock.Format(t)
+	}
 	msg := fmt.Sprintf(format, args...)
 	levelStr := logLevelStrings[level]
 	var entry string
 
-	if useColors && output == os.Stdout {
+	if useColors {
 		color := logLevelColors[level]
 		entry = fmt.Sprintf("%s [%s%s%s] %s\n", now, color, levelStr, colorReset, msg)
 	} else {
 		entry = fmt.Sprintf("%s [%s] %s\n", now, levelStr, msg)
 	}
 
+	mu.Lock()
 	fmt.Fprint(output, entry)
+	var rotated string
+	var rotateErr error
+	if logFile != nil {
+		rotated, rotateErr = writeLogFile(fmt.Sprintf("%s [%s] %s\n", clock.Format(t), levelStr, msg))
+	}
+	mu.Unlock()
+	// Logged once mu is released, so that the entry goes to the new file.
+	if rotateErr != nil {
+		fmt.Fprintf(os.Stderr, "Error rotating log file, logging to stdout only: %v\n", rotateErr)
+	} else if rotated != "" {
+		Info("Rotated log file to %s", rotated)
+	}
 
 	if level == LevelFatal {
-		if logFile != nil {
-			logFile.Close()
+		CloseLogFile()
+		if exitHook != nil {
+			exitHook(msg)
 		}
 		os.Exit(1)
 	}
@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/clock"
+)
+
+// TestTimestamps logs the same instant with the machine in another time
+// zone: the log file gets RFC 3339 UTC, the terminal local time unless
+// SetUTC is given.
+func TestTimestamps(t *testing.T) {
+	restoreOutput(t)
+	local := time.Local
+	time.Local = time.FixedZone("EST", -5*3600)
+	fixed := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	clock.Set(func() time.Time { return fixed })
+	t.Cleanup(func() {
+		time.Local = local
+		clock.Set(nil)
+		SetUTC(false)
+		CloseLogFile()
+	})
+
+	file := filepath.Join(t.TempDir(), "run.log")
+	if err := SetLogFile(file); err != nil {
+		t.Fatal(err)
+	}
+	var terminal bytes.Buffer
+	SetOutput(&terminal)
+	EnableColors(false)
+	SetLevel(LevelInfo)
+
+	Info("local")
+	SetUTC(true)
+	Info("utc")
+	CloseLogFile()
+
+	if want := "2024-05-01 07:30:00.000 [INFO] local\n2024-05-01T12:30:00.000Z [INFO] utc\n"; terminal.String() != want {
+		t.Errorf("terminal:\n%s\nwant\n%s", terminal.String(), want)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("log file:\n%s\nwant both entries", content)
+	}
+	for _, line := range lines {
+		stamp, _, _ := strings.Cut(line, " ")
+		if parsed, err := time.Parse(time.RFC3339, stamp); err != nil || !strings.HasSuffix(stamp, "Z") || !parsed.Equal(fixed) {
+			t.Errorf("log file line %q, want an RFC 3339 UTC timestamp", line)
+		}
+	}
+}
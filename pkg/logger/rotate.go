@@ -0,0 +1,193 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Rotation configures the size-based rotation of the log file.
+type Rotation struct {
+	// MaxSize is the size in bytes from which the log file is rotated; 0
+	// never rotates it.
+	MaxSize int64
+	// MaxBackups is the number of rotated files kept, name.1 being the most
+	// recent; older ones are removed.
+	MaxBackups int
+	// Compress gzips rotated files to name.1.gz and so on.
+	Compress bool
+}
+
+var (
+	// mu serializes the writes of log entries and the rotation of the log
+	// file, so that no entry is split or lost while the file is swapped.
+	mu sync.Mutex
+
+	// rotation is the rotation of the log file, set by SetRotation.
+	rotation Rotation
+
+	// logFileName and logFileSize are the path and current size of logFile.
+	logFileName string
+	logFileSize int64
+
+	// compressing tracks the gzip of the last rotated file, which runs without
+	// holding mu.
+	compressing sync.WaitGroup
+)
+
+// sizeUnits are the suffixes ParseSize accepts, longest first.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a size such as 50MB, 512KB or 1GB, in powers of 1024, or a
+// plain number of bytes.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, u.suffix))
+			multiplier = u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected a number of bytes or KB, MB, GB)", s)
+	}
+	return n * multiplier, nil
+}
+
+// SetRotation rotates the log file once it reaches r.MaxSize. It applies to
+// the file of SetLogFile, before or after it is opened.
+func SetRotation(r Rotation) {
+	mu.Lock()
+	defer mu.Unlock()
+	rotation = r
+}
+
+// writeLogFile appends entry to the log file, first rotating the file when the
+// entry would take it past the maximum size. It returns the name of the rotated
+// file, or "" when there was no rotation. A failed rotation leaves no log file
+// open. The caller holds mu.
+func writeLogFile(entry string) (string, error) {
+	var rotated string
+	if rotation.MaxSize > 0 && logFileSize > 0 && logFileSize+int64(len(entry)) > rotation.MaxSize {
+		var err error
+		if rotated, err = rotate(); err != nil {
+			if logFile != nil {
+				logFile.Close()
+				logFile = nil
+			}
+			return "", err
+		}
+	}
+	n, _ := io.WriteString(logFile, entry)
+	logFileSize += int64(n)
+	return rotated, nil
+}
+
+// rotate closes the log file, shifts the backups by one, renames the file to
+// name.1 and opens a new one. The oldest backup is removed when there are
+// already MaxBackups of them. The caller holds mu.
+func rotate() (string, error) {
+	// A gzip still running would read name.1 while it is renamed.
+	compressing.Wait()
+	if err := logFile.Close(); err != nil {
+		return "", fmt.Errorf("failed to close log file: %w", err)
+	}
+	logFile = nil
+
+	for i := rotation.MaxBackups; i >= 1; i-- {
+		for _, ext := range []string{"", ".gz"} {
+			from := backupName(logFileName, i) + ext
+			if i == rotation.MaxBackups {
+				os.Remove(from)
+				continue
+			}
+			if _, err := os.Stat(from); err == nil {
+				os.Rename(from, backupName(logFileName, i+1)+ext)
+			}
+		}
+	}
+	rotated := ""
+	if rotation.MaxBackups > 0 {
+		rotated = backupName(logFileName, 1)
+		if err := os.Rename(logFileName, rotated); err != nil {
+			return "", fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+
+	// Without backups the file is truncated instead.
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if rotated == "" {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(logFileName, flags, 0666)
+	if err != nil {
+		return "", fmt.Errorf("failed to open log file: %w", err)
+	}
+	logFile = file
+	logFileSize = 0
+
+	if rotated != "" && rotation.Compress {
+		compressing.Add(1)
+		go func(name string) {
+			defer compressing.Done()
+			compressFile(name)
+		}(rotated)
+		rotated += ".gz"
+	}
+	return rotated, nil
+}
+
+// backupName returns the name of the i-th rotated file of name.
+func backupName(name string, i int) string {
+	return name + "." + strconv.Itoa(i)
+}
+
+// compressFile gzips name to name.gz and removes name. On failure name is kept
+// and the partial name.gz removed.
+func compressFile(name string) {
+	if err := gzipFile(name, name+".gz"); err != nil {
+		os.Remove(name + ".gz")
+		fmt.Fprintf(os.Stderr, "Error compressing rotated log file %s: %v\n", name, err)
+		return
+	}
+	os.Remove(name)
+}
+
+// gzipFile writes the gzip of src to dst.
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
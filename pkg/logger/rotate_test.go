@@ -0,0 +1,253 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{"0": 0, "512": 512, "512KB": 512 << 10, "50mb": 50 << 20, " 1 GB ": 1 << 30, "10B": 10} {
+		if n, err := ParseSize(s); err != nil || n != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", s, n, err, want)
+		}
+	}
+	for _, s := range []string{"", "-1MB", "lots", "1TB"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) succeeded", s)
+		}
+	}
+}
+
+// useRotation logs at the info level to a file of its own directory rotated
+// with r, and returns the file and what the terminal receives.
+func useRotation(t *testing.T, r Rotation) (string, *bytes.Buffer) {
+	t.Helper()
+	restoreOutput(t)
+	var terminal bytes.Buffer
+	SetOutput(&terminal)
+	SetLevel(LevelInfo)
+	SetRotation(r)
+	file := filepath.Join(t.TempDir(), "run.log")
+	if err := SetLogFile(file); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		CloseLogFile()
+		SetRotation(Rotation{})
+	})
+	return file, &terminal
+}
+
+// readLog returns the names of the files of the log, the backups first, the
+// oldest first, and the messages they hold in that order.
+func readLog(t *testing.T, file string) (files []string, messages []string) {
+	t.Helper()
+	backups, _ := filepath.Glob(file + ".*")
+	names := make([]string, len(backups))
+	for _, b := range backups {
+		var i int
+		if _, err := fmt.Sscanf(strings.TrimPrefix(b, file+"."), "%d", &i); err != nil || i < 1 || i > len(backups) {
+			t.Fatalf("unexpected file %s", b)
+		}
+		names[len(backups)-i] = b
+	}
+	names = append(names, file)
+	for _, name := range names {
+		files = append(files, filepath.Base(name))
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(name, ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+		content, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+			if _, msg, ok := strings.Cut(line, "[INFO] "); ok {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return files, messages
+}
+
+// lines returns "line from" to "line to-1".
+func lines(from, to int) []string {
+	var out []string
+	for i := from; i < to; i++ {
+		out = append(out, fmt.Sprintf("line %03d", i))
+	}
+	return out
+}
+
+// withoutRotations returns messages without the notes of the rotations.
+func withoutRotations(messages []string) []string {
+	var out []string
+	for _, m := range messages {
+		if !strings.HasPrefix(m, "Rotated log file to ") {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// logUntilRotated logs numbered lines from next until the log file has been
+// rotated n times in all, and returns the number of the next line.
+func logUntilRotated(t *testing.T, terminal *bytes.Buffer, next, n int) int {
+	t.Helper()
+	for strings.Count(terminal.String(), "Rotated log file to ") < n {
+		if next > 1000 {
+			t.Fatalf("not rotated %d times after %d lines", n, next)
+		}
+		Info("line %03d", next)
+		next++
+	}
+	return next
+}
+
+// TestRotationKeepsBackups rotates the log file twice, then a third time,
+// checking the backups kept and that no line is lost until the oldest backup
+// is removed.
+func TestRotationKeepsBackups(t *testing.T) {
+	const maxSize = 300
+	file, terminal := useRotation(t, Rotation{MaxSize: maxSize, MaxBackups: 2})
+
+	next := logUntilRotated(t, terminal, 0, 2)
+	Info("line %03d", next)
+	next++
+	files, messages := readLog(t, file)
+	if want := []string{"run.log.2", "run.log.1", "run.log"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("files %q after two rotations, want %q", files, want)
+	}
+	if got := withoutRotations(messages); !reflect.DeepEqual(got, lines(0, next)) {
+		t.Errorf("the log holds\n%q\nwant every line in order\n%q", got, lines(0, next))
+	}
+	// Each rotation is noted in the new file and on the terminal.
+	if n := len(messages) - next; n != 2 || !strings.Contains(terminal.String(), "Rotated log file to "+file+".1") {
+		t.Errorf("%d rotations noted in the log, want 2; terminal:\n%s", n, terminal)
+	}
+	for _, name := range []string{file, file + ".1", file + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > maxSize {
+			t.Errorf("%s has %d bytes, want at most %d", name, info.Size(), maxSize)
+		}
+	}
+
+	// The third rotation drops the oldest file: the lines left are the most
+	// recent ones, in order.
+	next = logUntilRotated(t, terminal, next, 3)
+	files, messages = readLog(t, file)
+	if want := []string{"run.log.2", "run.log.1", "run.log"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("files %q after three rotations, want %q", files, want)
+	}
+	got := withoutRotations(messages)
+	if len(got) == 0 || !reflect.DeepEqual(got, lines(next-len(got), next)) || got[0] == "line 000" {
+		t.Errorf("the log holds\n%q\nwant the latest lines in order, without the first file", got)
+	}
+}
+
+func TestRotationCompressesBackups(t *testing.T) {
+	file, terminal := useRotation(t, Rotation{MaxSize: 300, MaxBackups: 2, Compress: true})
+	next := logUntilRotated(t, terminal, 0, 2)
+	CloseLogFile()
+	files, messages := readLog(t, file)
+	if want := []string{"run.log.2.gz", "run.log.1.gz", "run.log"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("files %q, want the backups gzipped only", files)
+	}
+	if got := withoutRotations(messages); !reflect.DeepEqual(got, lines(0, next)) {
+		t.Errorf("the log holds\n%q\nwant every line in order", got)
+	}
+	if !strings.Contains(terminal.String(), "Rotated log file to "+file+".1.gz") {
+		t.Errorf("terminal:\n%s", terminal)
+	}
+}
+
+func TestRotationWithoutBackups(t *testing.T) {
+	const maxSize, n = 300, 30
+	file, terminal := useRotation(t, Rotation{MaxSize: maxSize})
+	for i := 0; i < n; i++ {
+		Info("line %03d", i)
+	}
+	files, messages := readLog(t, file)
+	if want := []string{"run.log"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("files %q, want the log file truncated in place", files)
+	}
+	// The file holds the lines since it was last truncated, and the
+	// truncations are not noted.
+	if len(messages) == 0 || len(messages) == n || !reflect.DeepEqual(messages, lines(n-len(messages), n)) {
+		t.Errorf("the log holds %q, want the latest lines only", messages)
+	}
+	if info, err := os.Stat(file); err != nil || info.Size() > maxSize {
+		t.Errorf("%s: %v, want at most %d bytes", file, err, maxSize)
+	}
+	if strings.Contains(terminal.String(), "Rotated") || strings.Count(terminal.String(), "\n") != n {
+		t.Errorf("terminal:\n%s\nwant every line and no rotation", terminal)
+	}
+}
+
+// TestRotationConcurrentWriters logs from several goroutines through many
+// rotations: every line is in one of the files, whole.
+func TestRotationConcurrentWriters(t *testing.T) {
+	const writers, perWriter = 8, 50
+	file, _ := useRotation(t, Rotation{MaxSize: 2 << 10, MaxBackups: 100})
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				Info("writer %d line %03d", w, i)
+			}
+		}(w)
+	}
+	wg.Wait()
+	CloseLogFile()
+
+	files, messages := readLog(t, file)
+	if len(files) < 3 {
+		t.Errorf("files %q, want several rotations", files)
+	}
+	seen := make(map[string]int)
+	last := make([]int, writers)
+	for i := range last {
+		last[i] = -1
+	}
+	for _, m := range withoutRotations(messages) {
+		var w, i int
+		if _, err := fmt.Sscanf(m, "writer %d line %03d", &w, &i); err != nil || w < 0 || w >= writers {
+			t.Fatalf("unexpected message %q", m)
+		}
+		seen[m]++
+		// The lines of a writer are in the order it logged them.
+		if i != last[w]+1 {
+			t.Errorf("writer %d logged line %d after line %d", w, i, last[w])
+		}
+		last[w] = i
+	}
+	if len(seen) != writers*perWriter {
+		t.Errorf("%d distinct lines in the log, want %d", len(seen), writers*perWriter)
+	}
+	for m, n := range seen {
+		if n != 1 {
+			t.Errorf("%q logged %d times", m, n)
+		}
+	}
+}
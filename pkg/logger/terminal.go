@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"io"
+	"os"
+)
+
+// noColorEnv disables colors when set to a non-empty value, following the
+// NO_COLOR convention (https://no-color.org).
+const noColorEnv = "NO_COLOR"
+
+// terminalWriter is a writer that tells whether it is a terminal, such as the
+// writers tests inject.
+type terminalWriter interface {
+	IsTerminal() bool
+}
+
+// ColorsSupported reports whether ANSI colors can be written to w: NO_COLOR is
+// unset and w is a terminal that interprets escape sequences. On Windows the
+// console is switched to virtual terminal processing; legacy consoles without
+// it, pipes, files and other writers get no colors.
+func ColorsSupported(w io.Writer) bool {
+	if os.Getenv(noColorEnv) != "" {
+		return false
+	}
+	switch w := w.(type) {
+	case terminalWriter:
+		return w.IsTerminal()
+	case *os.File:
+		return terminal(w)
+	}
+	return false
+}
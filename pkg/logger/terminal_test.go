@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeTerminal is a buffer that claims to be a terminal.
+type fakeTerminal struct {
+	bytes.Buffer
+}
+
+func (*fakeTerminal) IsTerminal() bool { return true }
+
+// restoreOutput puts the logger back on a colored stdout at the info level
+// when the test is over.
+func restoreOutput(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		SetLevel(LevelInfo)
+		EnableColors(true)
+		SetOutput(os.Stdout)
+	})
+}
+
+func TestColorsSupported(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	tests := []struct {
+		name    string
+		w       io.Writer
+		noColor string
+		want    bool
+	}{
+		{name: "terminal", w: &fakeTerminal{}, want: true},
+		{name: "terminal with NO_COLOR", w: &fakeTerminal{}, noColor: "1", want: false},
+		{name: "pipe", w: w, want: false},
+		{name: "buffer", w: &bytes.Buffer{}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(noColorEnv, tt.noColor)
+			if got := ColorsSupported(tt.w); got != tt.want {
+				t.Errorf("ColorsSupported() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogColors(t *testing.T) {
+	restoreOutput(t)
+	SetLevel(LevelInfo)
+
+	tests := []struct {
+		name    string
+		enable  bool
+		noColor string
+		want    bool
+	}{
+		{name: "enabled", enable: true, want: true},
+		{name: "disabled", enable: false, want: false},
+		{name: "NO_COLOR", enable: true, noColor: "1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(noColorEnv, tt.noColor)
+			out := &fakeTerminal{}
+			SetOutput(out)
+			EnableColors(tt.enable)
+			Info("hello")
+			if got := strings.Contains(out.String(), "\033["); got != tt.want {
+				t.Errorf("escape codes in %q: %v, want %v", out.String(), got, tt.want)
+			}
+			if !strings.Contains(out.String(), "INFO") || !strings.Contains(out.String(), "hello") {
+				t.Errorf("log entry %q lacks its level or message", out.String())
+			}
+		})
+	}
+}
+
+func TestLogPipedOutputIsPlain(t *testing.T) {
+	restoreOutput(t)
+	SetLevel(LevelInfo)
+	t.Setenv(noColorEnv, "")
+	EnableColors(true)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	SetOutput(w)
+	Info("hello")
+	w.Close()
+	var out bytes.Buffer
+	if _, err := out.ReadFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "\033[") {
+		t.Errorf("piped log entry %q has escape codes", out.String())
+	}
+	if !strings.Contains(out.String(), "[INFO] hello") {
+		t.Errorf("piped log entry = %q", out.String())
+	}
+}
+
+func TestColor(t *testing.T) {
+	restoreOutput(t)
+	t.Setenv(noColorEnv, "")
+	EnableColors(true)
+
+	if got, want := Color(&fakeTerminal{}, "\033[31m", "high"), "\033[31mhigh\033[0m"; got != want {
+		t.Errorf("Color(terminal) = %q, want %q", got, want)
+	}
+	if got := Color(&bytes.Buffer{}, "\033[31m", "high"); got != "high" {
+		t.Errorf("Color(buffer) = %q, want it plain", got)
+	}
+	EnableColors(false)
+	if got := Color(&fakeTerminal{}, "\033[31m", "high"); got != "high" {
+		t.Errorf("Color(terminal) with colors disabled = %q, want it plain", got)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package logger
+
+import "os"
+
+// terminal reports whether f is a terminal.
+func terminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package logger
+
+import (
+	"os"
+	"syscall"
+)
+
+// enableVirtualTerminalProcessing is the console mode flag that makes Windows
+// consoles interpret ANSI escape sequences.
+const enableVirtualTerminalProcessing = 0x0004
+
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// terminal reports whether f is a console that interprets escape sequences,
+// enabling virtual terminal processing when it is off. Consoles older than
+// Windows 10 refuse the mode.
+func terminal(f *os.File) bool {
+	handle := syscall.Handle(f.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+	ok, _, _ := procSetConsoleMode.Call(uintptr(handle), uintptr(mode|enableVirtualTerminalProcessing))
+	return ok != 0
+}
@@ -0,0 +1,303 @@
+// Package manifest writes the run manifest that lets an orchestrator follow a
+// run: how it was invoked, how long each phase took, how it ended and what it
+// produced.
+package manifest
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/clock"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+)
+
+// manifestVersion is the version of the manifest format.
+const manifestVersion = 1
+
+// Run statuses
+const (
+	StatusRunning     = "running"
+	StatusSucceeded   = "succeeded"
+	StatusFailed      = "failed"
+	StatusInterrupted = "interrupted"
+)
+
+// Phase is a timed part of the run, such as the audit or the report.
+type Phase struct {
+	Name       string    `json:"name"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// Artifact is a file or directory the run wrote.
+type Artifact struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+	// SHA256 is the hex digest of a file, set when the run ends.
+	SHA256 string `json:"sha256,omitempty"`
+	// Signature is the path of the detached signature of a file, written
+	// when the run signs its artifacts.
+	Signature string `json:"signature,omitempty"`
+}
+
+// Manifest is the run manifest. It is written with StatusRunning when the run
+// starts and finalized with the exit code when it ends.
+type Manifest struct {
+	Version     int    `json:"version"`
+	Tool        string `json:"tool"`
+	ToolVersion string `json:"toolVersion"`
+	Commit      string `json:"commit"`
+	PID         int    `json:"pid"`
+	// Args are the command-line arguments with secrets masked.
+	Args      []string   `json:"args"`
+	Status    string     `json:"status"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	// DurationMs is the wall time of the run, set when it ends.
+	DurationMs int64 `json:"durationMs"`
+	ExitCode   *int  `json:"exitCode,omitempty"`
+	// Error is the first error that failed the run.
+	Error     string     `json:"error,omitempty"`
+	Phases    []Phase    `json:"phases"`
+	Artifacts []Artifact `json:"artifacts"`
+	// Findings counts the findings of the run by severity.
+	Findings map[string]int `json:"findings"`
+}
+
+// Writer keeps the manifest of the running process up to date in its file.
+// The methods of a nil Writer do nothing, so runs without a manifest need no
+// checks.
+type Writer struct {
+	mu       sync.Mutex
+	path     string
+	m        Manifest
+	finished bool
+	// key signs the artifacts and the manifest when the run ends.
+	key ed25519.PrivateKey
+}
+
+// Start writes the manifest of a run invoked with args to path and returns its
+// writer. An empty path returns a nil Writer.
+func Start(path string, args []string) (*Writer, error) {
+	if path == "" {
+		return nil, nil
+	}
+	w := &Writer{
+		path: path,
+		m: Manifest{
+			Version:     manifestVersion,
+			Tool:        "graphspecter",
+			ToolVersion: version.Version,
+			Commit:      version.Commit,
+			PID:         os.Getpid(),
+			Args:        args,
+			Status:      StatusRunning,
+			StartedAt:   clock.Now(),
+			Phases:      []Phase{},
+			Artifacts:   []Artifact{},
+			Findings:    countFindings(nil),
+		},
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.save(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Phase starts timing the named phase and returns the function that ends it.
+// The manifest is saved as each phase ends, so a run that is killed outright
+// still leaves the phases it completed.
+func (w *Writer) Phase(name string) func() {
+	if w == nil {
+		return func() {}
+	}
+	started := time.Now()
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.finished {
+			return
+		}
+		w.m.Phases = append(w.m.Phases, Phase{Name: name, StartedAt: started.UTC(), DurationMs: time.Since(started).Milliseconds()})
+		// The manifest is saved again when the run ends; an error here is reported then.
+		_ = w.save()
+	}
+}
+
+// Artifact records the file or directory at path, of the given kind, when the
+// run wrote it: a file must have been modified since the run started, so the
+// leftovers of earlier runs are not listed. Paths recorded before are ignored.
+func (w *Writer) Artifact(kind, path string) {
+	if w == nil || path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	// Modification times may be truncated to the second.
+	if err != nil || (!info.IsDir() && info.ModTime().Before(w.m.StartedAt.Truncate(time.Second))) {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, a := range w.m.Artifacts {
+		if a.Path == path {
+			return
+		}
+	}
+	w.m.Artifacts = append(w.m.Artifacts, Artifact{Kind: kind, Path: path})
+}
+
+// SignWith makes Finish sign every file artifact and the manifest itself with
+// key, each with a detached signature next to it.
+func (w *Writer) SignWith(key ed25519.PrivateKey) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.key = key
+}
+
+// Findings records the severity counts of findings.
+func (w *Writer) Findings(findings []report.Finding) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.m.Findings = countFindings(findings)
+}
+
+// Fail records message as the error of the run. Only the first one is kept.
+func (w *Writer) Fail(message string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.m.Error == "" {
+		w.m.Error = message
+	}
+}
+
+// Finish finalizes the manifest with the exit code of the run. A run that was
+// interrupted is marked so whatever its code. Only the first call has any effect.
+func (w *Writer) Finish(code int, interrupted bool) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.finished {
+		return nil
+	}
+	w.finished = true
+
+	ended := clock.Now()
+	w.m.EndedAt = &ended
+	w.m.DurationMs = ended.Sub(w.m.StartedAt).Milliseconds()
+	w.m.ExitCode = &code
+	switch {
+	case interrupted:
+		w.m.Status = StatusInterrupted
+	case code == 0:
+		w.m.Status = StatusSucceeded
+	default:
+		w.m.Status = StatusFailed
+	}
+	err := w.seal()
+	if serr := w.save(); err == nil {
+		err = serr
+	}
+	if err == nil && w.key != nil {
+		err = w.sign(w.path)
+	}
+	return err
+}
+
+// seal records the digest of every file artifact and, with a signing key,
+// signs it. It returns the first error and carries on past it. The caller
+// holds w.mu.
+func (w *Writer) seal() error {
+	var first error
+	for i := range w.m.Artifacts {
+		a := &w.m.Artifacts[i]
+		if info, err := os.Stat(a.Path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		digest, err := artifacts.Digest(a.Path)
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("error hashing %s: %w", a.Path, err)
+			}
+			continue
+		}
+		a.SHA256 = digest
+		if w.key == nil {
+			continue
+		}
+		if a.Signature, err = artifacts.Sign(w.key, a.Path, digest); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// sign writes the detached signature of the file at path. The caller holds w.mu.
+func (w *Writer) sign(path string) error {
+	digest, err := artifacts.Digest(path)
+	if err != nil {
+		return fmt.Errorf("error hashing %s: %w", path, err)
+	}
+	_, err = artifacts.Sign(w.key, path, digest)
+	return err
+}
+
+// Load reads the manifest written to path.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading run manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing run manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// countFindings returns the number of findings of each severity, with every
+// severity present.
+func countFindings(findings []report.Finding) map[string]int {
+	counts := map[string]int{
+		report.SeverityCritical: 0,
+		report.SeverityHigh:     0,
+		report.SeverityMedium:   0,
+		report.SeverityLow:      0,
+		report.SeverityInfo:     0,
+	}
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	return counts
+}
+
+// save writes the manifest with artifacts.WriteFile, so readers never see a
+// partial file. The caller holds w.mu.
+func (w *Writer) save() error {
+	data, err := json.MarshalIndent(w.m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling run manifest: %w", err)
+	}
+	if err := artifacts.WriteFile(w.path, data, 0600); err != nil {
+		return fmt.Errorf("error writing run manifest: %w", err)
+	}
+	return nil
+}
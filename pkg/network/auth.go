@@ -0,0 +1,109 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ResponseInfo describes the last HTTP response received for a request sent
+// with a context from WithResponseInfo.
+type ResponseInfo struct {
+	StatusCode int
+	Header     http.Header
+	// URL is the URL of the response, after any redirects.
+	URL string
+	// Duration is the time from sending the request to receiving the response
+	// headers, without the wait for the rate limiter.
+	Duration time.Duration
+}
+
+// responseInfoKey is the context key of the ResponseInfo filled by sendOnce.
+type responseInfoKey struct{}
+
+// WithResponseInfo returns a context whose requests record their response in info.
+func WithResponseInfo(ctx context.Context, info *ResponseInfo) context.Context {
+	return context.WithValue(ctx, responseInfoKey{}, info)
+}
+
+// recordResponse fills the ResponseInfo of ctx, if any, from resp, received
+// elapsed after its request was sent.
+func recordResponse(ctx context.Context, resp *http.Response, elapsed time.Duration) {
+	info, _ := ctx.Value(responseInfoKey{}).(*ResponseInfo)
+	if info == nil {
+		return
+	}
+	info.StatusCode = resp.StatusCode
+	info.Header = resp.Header
+	info.Duration = elapsed
+	if resp.Request != nil && resp.Request.URL != nil {
+		info.URL = resp.Request.URL.String()
+	}
+}
+
+// ssoPath matches the paths of login pages and identity providers.
+var ssoPath = regexp.MustCompile(`(?i)(?:^|[/._-])(?:login|signin|sign-in|sso|oauth2?|saml2?|openid|oidc|authorize|auth|idp|cas|adfs)(?:$|[/._?-])`)
+
+// ClassifyAuthCandidate reports whether the response to a detection probe of
+// endpoint shows a path that exists but requires authentication: a 401 or 403
+// status, a WWW-Authenticate challenge, or a redirect to a login page or to
+// another host.
+func ClassifyAuthCandidate(endpoint string, info ResponseInfo) (types.AuthCandidate, bool) {
+	candidate := types.AuthCandidate{URL: endpoint, StatusCode: info.StatusCode}
+	challenge := info.Header.Get("WWW-Authenticate")
+	redirect := resolveRedirect(endpoint, info.URL)
+	switch {
+	case info.StatusCode == http.StatusUnauthorized || info.StatusCode == http.StatusForbidden:
+		candidate.Reason = fmt.Sprintf("HTTP %d", info.StatusCode)
+		if challenge != "" {
+			candidate.Reason += " with WWW-Authenticate: " + challenge
+		}
+	case challenge != "":
+		candidate.Reason = "WWW-Authenticate: " + challenge
+	case isSSORedirect(endpoint, redirect):
+		candidate.Reason = "redirected to " + redirect
+		candidate.RedirectURL = redirect
+	default:
+		return types.AuthCandidate{}, false
+	}
+	return candidate, true
+}
+
+// resolveRedirect returns the URL a request to from ended at, to, resolved
+// against from when it is relative.
+func resolveRedirect(from, to string) string {
+	if to == "" {
+		return ""
+	}
+	src, err := url.Parse(from)
+	if err != nil {
+		return to
+	}
+	ref, err := url.Parse(to)
+	if err != nil {
+		return to
+	}
+	return src.ResolveReference(ref).String()
+}
+
+// isSSORedirect reports whether a request to from ended at the login page to.
+func isSSORedirect(from, to string) bool {
+	if to == "" || strings.TrimRight(to, "/") == strings.TrimRight(from, "/") {
+		return false
+	}
+	src, err := url.Parse(from)
+	if err != nil {
+		return false
+	}
+	dst, err := url.Parse(to)
+	if err != nil {
+		return false
+	}
+	return !strings.EqualFold(src.Hostname(), dst.Hostname()) || ssoPath.MatchString(dst.Path)
+}
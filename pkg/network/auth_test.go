@@ -0,0 +1,99 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+func TestClassifyAuthCandidate(t *testing.T) {
+	const endpoint = "https://api.example.com/graphql"
+	challenge := http.Header{"Www-Authenticate": {`Bearer realm="api"`}}
+	tests := []struct {
+		name      string
+		info      ResponseInfo
+		candidate bool
+		reason    string
+		redirect  string
+	}{
+		{name: "401", info: ResponseInfo{StatusCode: 401, Header: http.Header{}}, candidate: true, reason: "HTTP 401"},
+		{name: "403 with challenge", info: ResponseInfo{StatusCode: 403, Header: challenge}, candidate: true, reason: `HTTP 403 with WWW-Authenticate: Bearer realm="api"`},
+		{name: "challenge only", info: ResponseInfo{StatusCode: 200, Header: challenge}, candidate: true, reason: `WWW-Authenticate: Bearer realm="api"`},
+		{name: "login page", info: ResponseInfo{StatusCode: 200, URL: "/login?next=%2Fgraphql"}, candidate: true, reason: "redirected to https://api.example.com/login?next=%2Fgraphql", redirect: "https://api.example.com/login?next=%2Fgraphql"},
+		{name: "identity provider", info: ResponseInfo{StatusCode: 200, URL: "https://idp.example.net/app"}, candidate: true, reason: "redirected to https://idp.example.net/app", redirect: "https://idp.example.net/app"},
+		{name: "trailing slash", info: ResponseInfo{StatusCode: 200, URL: endpoint + "/"}},
+		{name: "same host", info: ResponseInfo{StatusCode: 200, URL: "https://api.example.com/v2/graphql"}},
+		{name: "not found", info: ResponseInfo{StatusCode: 404, Header: http.Header{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := ClassifyAuthCandidate(endpoint, tt.info)
+			if ok != tt.candidate {
+				t.Fatalf("ClassifyAuthCandidate() = %+v, %v; want candidate %v", c, ok, tt.candidate)
+			}
+			if !ok {
+				return
+			}
+			if c.URL != endpoint || c.Reason != tt.reason || c.RedirectURL != tt.redirect || c.StatusCode != tt.info.StatusCode {
+				t.Errorf("candidate = %+v, want reason %q and redirect %q", c, tt.reason, tt.redirect)
+			}
+		})
+	}
+}
+
+// TestDetectEndpointsAuthCandidates checks that paths requiring
+// authentication are listed apart from the confirmed endpoints, and that
+// paths merely missing or serving a page are dropped.
+func TestDetectEndpointsAuthCandidates(t *testing.T) {
+	usePaths(t, "/graphql", "/api/graphql", "/admin/graphql", "/v1/graphql", "/sso/graphql", "/missing", "/docs")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		switch r.URL.Path {
+		case "/graphql":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+		case "/api/graphql":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"Unauthorized"}`))
+		case "/admin/graphql":
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("<html><body>Forbidden</body></html>"))
+		case "/v1/graphql":
+			w.Header().Set("WWW-Authenticate", `Basic realm="v1"`)
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("credentials required"))
+		case "/sso/graphql":
+			http.Redirect(w, r, "/oauth2/authorize?client_id=gql", http.StatusFound)
+		case "/oauth2/authorize", "/docs":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>Sign in</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	result, err := DetectEndpoints(context.Background(), srv.URL, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{srv.URL + "/graphql"}; !reflect.DeepEqual(result.Endpoints, want) {
+		t.Errorf("Endpoints = %q, want %q", result.Endpoints, want)
+	}
+	want := []types.AuthCandidate{
+		{URL: srv.URL + "/api/graphql", Reason: "HTTP 401", StatusCode: 401},
+		{URL: srv.URL + "/admin/graphql", Reason: "HTTP 403", StatusCode: 403},
+		{URL: srv.URL + "/v1/graphql", Reason: `WWW-Authenticate: Basic realm="v1"`, StatusCode: 200},
+		{URL: srv.URL + "/sso/graphql", Reason: "redirected to " + srv.URL + "/oauth2/authorize?client_id=gql", StatusCode: 200, RedirectURL: srv.URL + "/oauth2/authorize?client_id=gql"},
+	}
+	if !reflect.DeepEqual(result.AuthCandidates, want) {
+		t.Errorf("AuthCandidates = %+v\nwant %+v", result.AuthCandidates, want)
+	}
+}
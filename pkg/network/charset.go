@@ -0,0 +1,120 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"mime"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// DecodeBody converts a response body to UTF-8. A byte order mark takes
+// precedence over the charset parameter of contentType; unknown charsets are
+// assumed to be UTF-8.
+func DecodeBody(body []byte, contentType string) []byte {
+	switch {
+	case bytes.HasPrefix(body, bomUTF8):
+		return body[len(bomUTF8):]
+	case bytes.HasPrefix(body, bomUTF16LE):
+		return decodeUTF16(body[len(bomUTF16LE):], binary.LittleEndian)
+	case bytes.HasPrefix(body, bomUTF16BE):
+		return decodeUTF16(body[len(bomUTF16BE):], binary.BigEndian)
+	}
+
+	charset := ""
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		charset = strings.ToLower(strings.TrimSpace(params["charset"]))
+	}
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return body
+	case "utf-16", "utf-16be":
+		// Without a BOM, UTF-16 is big-endian (RFC 2781).
+		return decodeUTF16(body, binary.BigEndian)
+	case "utf-16le":
+		return decodeUTF16(body, binary.LittleEndian)
+	case "iso-8859-1", "latin1", "latin-1":
+		return decodeLatin1(body)
+	default:
+		logger.Debug("→ Unsupported response charset %q, assuming UTF-8", charset)
+		return body
+	}
+}
+
+// decodeUTF16 converts UTF-16 code units in the given byte order to UTF-8.
+// A trailing odd byte is dropped.
+func decodeUTF16(body []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(body)/2)
+	for i := range units {
+		units[i] = order.Uint16(body[2*i:])
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// decodeLatin1 converts ISO-8859-1 bytes, which map one to one onto code points, to UTF-8.
+func decodeLatin1(body []byte) []byte {
+	out := make([]byte, 0, len(body))
+	for _, b := range body {
+		out = utf8.AppendRune(out, rune(b))
+	}
+	return out
+}
+
+// IsJSONContentType reports whether contentType declares a JSON body, such as
+// application/json or application/graphql-response+json.
+func IsJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return containsSubstring(contentType, "json")
+	}
+	return strings.Contains(mediaType, "json")
+}
+
+// ContentTypeEvent records a JSON response served with a non-JSON Content-Type.
+type ContentTypeEvent struct {
+	URL         string
+	ContentType string
+}
+
+var (
+	contentTypeMu     sync.Mutex
+	contentTypeEvents []ContentTypeEvent
+	contentTypeSeen   = make(map[string]bool)
+)
+
+// recordContentTypeMismatch stores the first mismatch observed for each URL.
+func recordContentTypeMismatch(url, contentType string) {
+	contentTypeMu.Lock()
+	defer contentTypeMu.Unlock()
+	if contentTypeSeen[url] {
+		return
+	}
+	contentTypeSeen[url] = true
+	contentTypeEvents = append(contentTypeEvents, ContentTypeEvent{URL: url, ContentType: contentType})
+	logger.Warn("%s returned JSON with Content-Type %q", url, contentType)
+}
+
+// ContentTypeEvents returns every endpoint that answered JSON under a non-JSON Content-Type.
+func ContentTypeEvents() []ContentTypeEvent {
+	contentTypeMu.Lock()
+	defer contentTypeMu.Unlock()
+	return append([]ContentTypeEvent(nil), contentTypeEvents...)
+}
+
+// resetContentTypeEvents forgets the mismatches observed.
+func resetContentTypeEvents() {
+	contentTypeMu.Lock()
+	contentTypeEvents = nil
+	contentTypeSeen = make(map[string]bool)
+	contentTypeMu.Unlock()
+}
@@ -0,0 +1,148 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+)
+
+// charsetFixture is a GraphQL response with characters outside ASCII.
+const charsetFixture = `{"data":{"user":{"name":"Zoë Ståhl"}}}`
+
+// encodeUTF16 encodes s as UTF-16 in order, after bom.
+func encodeUTF16(s string, order binary.ByteOrder, bom []byte) []byte {
+	out := append([]byte(nil), bom...)
+	for _, u := range utf16.Encode([]rune(s)) {
+		unit := make([]byte, 2)
+		order.PutUint16(unit, u)
+		out = append(out, unit...)
+	}
+	return out
+}
+
+// encodeLatin1 encodes s, whose runes are all below U+0100, as ISO-8859-1.
+func encodeLatin1(s string) []byte {
+	var out []byte
+	for _, r := range s {
+		out = append(out, byte(r))
+	}
+	return out
+}
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+	}{
+		{"utf-8", []byte(charsetFixture), "application/json; charset=utf-8"},
+		{"no charset", []byte(charsetFixture), "application/json"},
+		{"utf-8 BOM", append(append([]byte(nil), bomUTF8...), charsetFixture...), "application/json"},
+		{"utf-16le BOM", encodeUTF16(charsetFixture, binary.LittleEndian, bomUTF16LE), "application/json"},
+		{"utf-16be BOM", encodeUTF16(charsetFixture, binary.BigEndian, bomUTF16BE), "application/json"},
+		{"BOM over charset", encodeUTF16(charsetFixture, binary.LittleEndian, bomUTF16LE), "application/json; charset=iso-8859-1"},
+		{"utf-16 without BOM", encodeUTF16(charsetFixture, binary.BigEndian, nil), "application/json; charset=UTF-16"},
+		{"utf-16le", encodeUTF16(charsetFixture, binary.LittleEndian, nil), "application/json; charset=utf-16le"},
+		{"iso-8859-1", encodeLatin1(charsetFixture), "application/json; charset=ISO-8859-1"},
+		{"latin1 quoted", encodeLatin1(charsetFixture), `application/json; charset="latin1"`},
+		{"unknown charset", []byte(charsetFixture), "application/json; charset=x-unknown"},
+		{"odd trailing byte", append(encodeUTF16(charsetFixture, binary.LittleEndian, bomUTF16LE), 'x'), "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DecodeBody(tt.body, tt.contentType); string(got) != charsetFixture {
+				t.Errorf("DecodeBody() = %q, want %q", got, charsetFixture)
+			}
+		})
+	}
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	tests := map[string]bool{
+		"application/json":                                    true,
+		"application/json; charset=utf-8":                     true,
+		"application/graphql-response+json":                   true,
+		"APPLICATION/JSON":                                    true,
+		"text/plain":                                          false,
+		"text/html; charset=utf-8":                            false,
+		"application/json;;":                                  true,
+		"multipart/mixed; boundary=\"-\"; deferSpec=20220824": false,
+	}
+	for contentType, want := range tests {
+		if got := IsJSONContentType(contentType); got != want {
+			t.Errorf("IsJSONContentType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestResponseCharsets(t *testing.T) {
+	ResetStats()
+	defer ResetStats()
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		// mismatch is whether the response is recorded as JSON under a
+		// non-JSON Content-Type.
+		mismatch bool
+		err      error
+	}{
+		{"utf-16le", encodeUTF16(charsetFixture, binary.LittleEndian, bomUTF16LE), "application/json", false, nil},
+		{"latin1", encodeLatin1(charsetFixture), "application/json; charset=iso-8859-1", false, nil},
+		{"json as text", []byte(charsetFixture), "text/plain; charset=utf-8", true, nil},
+		{"utf-16 json as text", encodeUTF16(charsetFixture, binary.BigEndian, bomUTF16BE), "text/plain", true, nil},
+		{"html", []byte("<html><body>Bad gateway</body></html>"), "text/html", false, gerrors.ErrNonJSONResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write(tt.body)
+			}))
+			defer srv.Close()
+
+			result, err := SendGraphQLRequestWithContext(context.Background(), srv.URL, "{ user { name } }", nil, nil)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := result["data"].(map[string]interface{})["user"].(map[string]interface{})["name"]
+			if name != "Zoë Ståhl" {
+				t.Errorf("name = %q", name)
+			}
+			recorded := false
+			for _, event := range ContentTypeEvents() {
+				if event.URL == srv.URL {
+					recorded = event.ContentType == tt.contentType
+				}
+			}
+			if recorded != tt.mismatch {
+				t.Errorf("Content-Type mismatch recorded: %v, want %v", recorded, tt.mismatch)
+			}
+		})
+	}
+}
+
+func TestContentTypeMismatchRecordedOnce(t *testing.T) {
+	ResetStats()
+	defer ResetStats()
+	for i := 0; i < 3; i++ {
+		recordContentTypeMismatch("https://api.example/graphql", "text/plain")
+	}
+	recordContentTypeMismatch("https://other.example/graphql", "text/html")
+	events := ContentTypeEvents()
+	if len(events) != 2 || events[0].URL != "https://api.example/graphql" || events[1].ContentType != "text/html" {
+		t.Errorf("ContentTypeEvents() = %+v", events)
+	}
+}
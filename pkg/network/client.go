@@ -4,48 +4,51 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/http/httptrace"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
 	"github.com/CyberRoute/graphspecter/pkg/logger"
 	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/version"
 )
 
-// CommonPaths contains a list of potential GraphQL endpoints.
-var CommonPaths = []string{
-	"/",
-	"/graphql",
-	"/graphiql",
-	"/v1/graphql",
-	"/v2/graphql",
-	"/v3/graphql",
-	"/api/graphql",
-	"/console",
-	"/playground",
-	"/gql",
-	"/query",
-	"/api",
-	"/graphql/v1",
-	"/graphql/v2",
-	"/api/v1/graphql",
-	"/api/v2/graphql",
-	"/graph",
-	"/graphql-api",
-	"/graphql/console",
-	"/graphql/playground",
-	"/service-name/graphql", // Often used for microservices
-	"/hasura/v1/graphql",    // Hasura specific
-	"/altair",               // Altair GraphQL client
-	"/explorer",             // GraphQL Explorer
-}
-
 // DefaultTimeout is the default timeout for HTTP requests.
 const DefaultTimeout = 10 * time.Second
 
+// MaxResponseSize is the largest response body read before giving up with gerrors.ErrTooLarge.
+const MaxResponseSize = 64 << 20
+
+// httpClient is shared by all requests so connections are kept alive and reused.
+var httpClient = &http.Client{
+	Timeout:   DefaultTimeout,
+	Transport: transportChain(nil),
+}
+
+// transportChain wraps base, or http.DefaultTransport when nil, in the
+// transports every request goes through: the virtual host, offline mode, the
+// bounty profile scope, request signing and the TLS server name of the
+// virtual host, in that order.
+func transportChain(base http.RoundTripper) http.RoundTripper {
+	return vhostTransport{next: offlineTransport{next: scopeTransport{next: signingTransport{next: newSNITransport(base)}}}}
+}
+
+// previewChain wraps capture in the transports of transportChain that change
+// the request: the virtual host, the bounty profile scope and request signing.
+// Offline mode, which a preview runs in, and the TLS server name, which only
+// concerns the connection, are left out.
+func previewChain(capture http.RoundTripper) http.RoundTripper {
+	return vhostTransport{next: scopeTransport{next: signingTransport{next: capture}}}
+}
+
 // SendGraphQLRequest sends a GraphQL request to the given endpoint.
 // This is a backward compatibility wrapper for the context-aware version.
 func SendGraphQLRequest(url string, query string, variables map[string]interface{}, headers map[string]string) (map[string]interface{}, error) {
@@ -55,6 +58,8 @@ func SendGraphQLRequest(url string, query string, variables map[string]interface
 }
 
 // SendGraphQLRequestWithContext sends a GraphQL request to the given endpoint with context support.
+// Rate-limited responses pause the shared limiter for the time requested by the server
+// and are retried up to maxRateLimitRetries times.
 func SendGraphQLRequestWithContext(ctx context.Context, url string, query string, variables map[string]interface{}, headers map[string]string) (map[string]interface{}, error) {
 	reqBody := types.GraphQLRequest{
 		Query:     query,
@@ -65,66 +70,179 @@ func SendGraphQLRequestWithContext(ctx context.Context, url string, query string
 	if err != nil {
 		return nil, fmt.Errorf("error marshalling request: %w", err)
 	}
+	return sendEncoded(ctx, url, jsonData, headers, query)
+}
+
+// sendEncoded sends an encoded GraphQL request carrying documents, refreshing an
+// expired session once and retrying rate-limited responses.
+func sendEncoded(ctx context.Context, url string, jsonData []byte, headers map[string]string, documents ...string) (map[string]interface{}, error) {
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		result, limited, err := sendOnce(ctx, url, jsonData, headers, documents)
+		if errors.Is(err, gerrors.ErrSessionExpired) && !refreshed {
+			// Renew the session once per request and send it again.
+			refreshed = true
+			logger.Info("Session rejected by %s, refreshing it", url)
+			if err := currentSession().Refresh(ctx); err != nil {
+				return nil, fmt.Errorf("error refreshing session: %w", err)
+			}
+			attempt--
+			continue
+		}
+		if !limited || attempt >= maxRateLimitRetries {
+			return result, err
+		}
+		RecordRetry()
+		logger.Debug("→ Retrying rate-limited request to %s (attempt %d)", url, attempt+2)
+	}
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+// newGraphQLRequest builds the POST of an encoded GraphQL request as it goes on
+// the wire, before the transport chain: wrapped in the encoding of the endpoint,
+// with the headers, the validators of ctx and the session applied. It returns
+// the body sent.
+func newGraphQLRequest(ctx context.Context, url string, jsonData []byte, headers map[string]string) (*http.Request, []byte, Encoding, error) {
+	encoding := encodingFor(ctx, url)
+	payload, payloadType, err := encoding.encode(jsonData)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return nil, nil, encoding, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, nil, encoding, fmt.Errorf("error creating request: %w", err)
 	}
 	logger.Debug("→ POST %s", url)
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", payloadType)
+	req.Header.Set("User-Agent", version.UserAgent())
+	req.Header.Set("Accept-Encoding", acceptEncoding)
 	for key, value := range headers {
 		logger.Debug("→ Request header %s: %s", key, value)
 		req.Header.Set(key, value)
 	}
-	logger.Debug("→ Request body: %s", string(jsonData))
+	if encoding != EncodingJSON {
+		// The wrapper decides the body type, whatever the headers say.
+		req.Header.Set("Content-Type", payloadType)
+	}
+	if conditional := validators(ctx); conditional != nil && conditional.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", conditional.IfNoneMatch)
+	}
+	ApplySession(req)
+	logger.Debug("→ Request body: %s", string(payload))
+	return req, payload, encoding, nil
+}
 
-	client := &http.Client{
-		Timeout: DefaultTimeout,
+// sendOnce performs a single POST of an encoded GraphQL request. The boolean result
+// reports whether the server signalled rate limiting.
+func sendOnce(ctx context.Context, url string, jsonData []byte, headers map[string]string, documents []string) (map[string]interface{}, bool, error) {
+	if Offline() {
+		return nil, false, fmt.Errorf("%w: not sending request to %s", gerrors.ErrOfflineMode, url)
+	}
+	if err := checkSend(url); err != nil {
+		return nil, false, err
+	}
+	req, payload, encoding, err := newGraphQLRequest(httptrace.WithClientTrace(ctx, runStats.connTrace()), url, jsonData, headers)
+	if err != nil {
+		return nil, false, err
+	}
+	conditional := validators(ctx)
+	session := currentSession()
+
+	if err := requestLimiter.wait(ctx); err != nil {
+		return nil, false, fmt.Errorf("waiting for rate limit: %w", gerrors.Interrupted(ctx, err))
 	}
 
 	logger.Debug("→ Sending GraphQL request to %s", url)
-	resp, err := client.Do(req)
+	runStats.requests.Add(1)
+	runStats.bytesSent.Add(int64(len(payload)))
+	RecordOperations(url, documents...)
+	sent := time.Now()
+	recentRequests.add(sent)
+	defer trackInFlight(ctx, url)()
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		if ctx.Err() == context.Canceled {
-			logger.Debug("→ Request to %s was canceled", url)
-			return nil, fmt.Errorf("request canceled by user or another operation completed first")
-		} else if ctx.Err() == context.DeadlineExceeded {
-			logger.Debug("→ Request to %s timed out", url)
-			return nil, fmt.Errorf("request timed out, consider increasing timeout")
+		recordSent(ctx, sent, url, jsonData, headers, 0, err)
+		err = gerrors.Interrupted(ctx, err)
+		if gerrors.IsInterrupted(err) {
+			logger.Debug("→ Request to %s was interrupted: %v", url, err)
 		} else {
 			logger.Error("Error sending request: %v", err)
-			return nil, fmt.Errorf("error sending request: %w", err)
 		}
+		return nil, false, fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
+	runStats.recordStatus(resp.StatusCode)
+	recordResponse(ctx, resp, time.Since(sent))
+	recordSent(ctx, sent, url, jsonData, headers, resp.StatusCode, nil)
+	if conditional != nil {
+		conditional.ETag = resp.Header.Get("ETag")
+		if resp.StatusCode == http.StatusNotModified {
+			conditional.NotModified = true
+			return nil, false, fmt.Errorf("%w (HTTP 304 from %s)", gerrors.ErrNotModified, url)
+		}
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, wire, err := readBody(url, resp)
+	runStats.bytesReceived.Add(wire)
+	if errors.Is(err, gerrors.ErrTooLarge) {
+		return nil, false, err
+	}
 	if err != nil {
 		logger.Error("Error reading response: %v", err)
-		return nil, fmt.Errorf("error reading response: %w", err)
+		return nil, false, fmt.Errorf("error reading response: %w", gerrors.Interrupted(ctx, err))
 	}
 
-	// Check content type to make sure we're getting JSON
 	contentType := resp.Header.Get("Content-Type")
-	if contentType != "" && !containsSubstring(contentType, "json") {
-		logger.Debug("→ Non-JSON response detected (Content-Type: %s)", contentType)
-		return nil, fmt.Errorf("non-JSON response received (Content-Type: %s)", contentType)
+	body = DecodeBody(body, contentType)
+	if session != nil && session.Expired(req, resp.StatusCode, body) {
+		return nil, false, fmt.Errorf("%w (HTTP %d from %s)", gerrors.ErrSessionExpired, resp.StatusCode, url)
 	}
 
 	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
+	parseErr := json.Unmarshal(body, &result)
+	if parseErr == nil {
+		result = encoding.decode(result)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || (parseErr == nil && isRateLimitedResult(result)) {
+		wait, _ := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		handleRateLimit(url, resp.StatusCode, wait, body)
+		return nil, true, &gerrors.RateLimitError{StatusCode: resp.StatusCode, RetryAfter: wait}
+	}
+
+	// Check content type to make sure we're getting JSON. Servers that send valid
+	// JSON under another type are accepted and recorded.
+	if contentType != "" && !IsJSONContentType(contentType) {
+		if parseErr != nil {
+			logger.Debug("→ Non-JSON response detected (Content-Type: %s)", contentType)
+			return nil, false, &gerrors.ContentTypeError{Kind: gerrors.ErrNonJSONResponse, ContentType: contentType, Err: parseErr, Body: bodyStart(body)}
+		}
+		recordContentTypeMismatch(url, contentType)
+	}
+
+	if parseErr != nil {
 		// If content starts with "<", it's likely HTML
-		if len(body) > 0 && body[0] == '<' {
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '<' {
 			logger.Debug("→ HTML response detected instead of JSON")
-			return nil, fmt.Errorf("HTML response received instead of expected JSON")
+			return nil, false, &gerrors.ContentTypeError{Kind: gerrors.ErrHTMLResponse, ContentType: contentType, Err: parseErr, Body: bodyStart(body)}
 		}
-		logger.Error("Error parsing response: %v", err)
-		return nil, fmt.Errorf("error parsing response: %w", err)
+		logger.Error("Error parsing response: %v", parseErr)
+		return nil, false, fmt.Errorf("error parsing response: %w", parseErr)
 	}
 
 	logger.Debug("→ Received response from %s, status: %d", url, resp.StatusCode)
-	return result, nil
+	return result, false, nil
+}
+
+// bodyStartSize is how much of a body that is not JSON ContentTypeError keeps.
+const bodyStartSize = 4096
+
+// bodyStart returns a copy of the first bodyStartSize bytes of body.
+func bodyStart(body []byte) []byte {
+	if len(body) > bodyStartSize {
+		body = body[:bodyStartSize]
+	}
+	return append([]byte(nil), body...)
 }
 
 // DetectGraphQLEndpoint scans common endpoints appended to the base URL.
@@ -138,13 +256,12 @@ func DetectGraphQLEndpoint(baseURL string) (string, error) {
 // DetectGraphQLEndpointWithContext scans common endpoints appended to the base URL with context support.
 func DetectGraphQLEndpointWithContext(ctx context.Context, baseURL string) (string, error) {
 	results, err := DetectAllGraphQLEndpointsWithContext(ctx, baseURL, true)
-	if err != nil {
-		return "", err
-	}
-
 	if len(results) > 0 {
 		return results[0], nil
 	}
+	if err != nil {
+		return "", err
+	}
 
 	return "", fmt.Errorf("GraphQL endpoint not detected on any common paths")
 }
@@ -152,11 +269,61 @@ func DetectGraphQLEndpointWithContext(ctx context.Context, baseURL string) (stri
 // DetectAllGraphQLEndpointsWithContext scans and returns all valid GraphQL endpoints
 // If stopOnFirst is true, it will stop after finding the first valid endpoint
 func DetectAllGraphQLEndpointsWithContext(ctx context.Context, baseURL string, stopOnFirst bool) ([]string, error) {
+	return DetectAllGraphQLEndpointsWithCallback(ctx, baseURL, stopOnFirst, nil)
+}
+
+// DetectAllGraphQLEndpointsWithCallback behaves like DetectAllGraphQLEndpointsWithContext
+// and additionally calls onFound, when not nil, as soon as each endpoint is confirmed.
+func DetectAllGraphQLEndpointsWithCallback(ctx context.Context, baseURL string, stopOnFirst bool, onFound func(endpoint string)) ([]string, error) {
+	result, err := DetectEndpoints(ctx, baseURL, stopOnFirst, onFound)
+	if result == nil {
+		return nil, err
+	}
+	return result.Endpoints, err
+}
+
+// DetectionResult is the outcome of endpoint detection on a base URL.
+type DetectionResult struct {
+	// Endpoints are the confirmed GraphQL endpoints.
+	Endpoints []string
+	// AuthCandidates are the paths that did not answer as GraphQL but appear
+	// to require authentication.
+	AuthCandidates []types.AuthCandidate
+}
+
+// DetectEndpoints probes the detection paths appended to the path of baseURL,
+// which ParseBase must accept, and returns
+// the GraphQL endpoints found along with the candidates requiring
+// authentication. onFound, when not nil, is called from a single goroutine as
+// soon as each endpoint is confirmed, in discovery order, while the result
+// follows the order of the paths dataset regardless of which path answered first.
+//
+// When ctx ends before every path was checked, the endpoints confirmed so far are
+// returned together with an error matching gerrors.ErrIncomplete and
+// gerrors.ErrTimeout or gerrors.ErrCanceled.
+func DetectEndpoints(ctx context.Context, baseURL string, stopOnFirst bool, onFound func(endpoint string)) (*DetectionResult, error) {
+	base, err := ParseBase(baseURL)
+	if err != nil {
+		return nil, err
+	}
 	logger.Info("Starting endpoint detection for %s", baseURL)
+	parent := ctx
+
+	// detected is an endpoint together with the position of its path in paths
+	type detected struct {
+		index    int
+		endpoint string
+	}
+	type candidate struct {
+		index int
+		types.AuthCandidate
+	}
+	var candidates []candidate
 
 	// Use concurrency for faster scanning
 	var wg sync.WaitGroup
-	resultChan := make(chan string, len(CommonPaths))
+	paths := data.Paths()
+	resultChan := make(chan detected, len(paths))
 
 	// Create a cancellable context
 	ctx, cancel := context.WithCancel(ctx)
@@ -166,26 +333,42 @@ func DetectAllGraphQLEndpointsWithContext(ctx context.Context, baseURL string, s
 	checkedEndpoints := 0
 	var mutex sync.Mutex
 
-	// Normalize base URL to ensure it doesn't end with a slash
-	baseURL = strings.TrimRight(baseURL, "/")
-
 	// Start concurrent checks for each potential endpoint
-	for _, path := range CommonPaths {
+	for i, path := range paths {
 		wg.Add(1)
-		go func(p string) {
+		go func(index int, p string) {
 			defer wg.Done()
 
 			select {
 			case <-ctx.Done():
 				return // Context was cancelled (timeout or stopOnFirst)
 			default:
-				endpoint := baseURL + p
+				endpoint := JoinEndpoint(base, p)
 				logger.Debug("→ Checking endpoint: %s", endpoint)
-				isValid, err := IsGraphQLEndpointWithContext(ctx, endpoint)
-
-				mutex.Lock()
-				checkedEndpoints++
-				mutex.Unlock()
+				var info ResponseInfo
+				isValid, err := IsGraphQLEndpointWithContext(WithResponseInfo(ctx, &info), endpoint)
+
+				// Paths whose check was cut short by the context do not count as
+				// checked; IsGraphQLEndpointWithContext reports those as not GraphQL.
+				if isValid || ctx.Err() == nil {
+					mutex.Lock()
+					checkedEndpoints++
+					mutex.Unlock()
+				}
+				if !isValid && info.StatusCode != 0 {
+					if c, ok := ClassifyAuthCandidate(endpoint, info); ok {
+						logger.Info("Candidate endpoint requiring authentication: %s (%s)", endpoint, c.Reason)
+						mutex.Lock()
+						candidates = append(candidates, candidate{index, c})
+						mutex.Unlock()
+					} else if err == nil && mayWrap(info.StatusCode) {
+						if e, ok := DetectEncoding(ctx, endpoint, nil); ok {
+							SetEncoding(endpoint, e)
+							logger.Info("GraphQL at %s is wrapped in the %s encoding", endpoint, e)
+							isValid = true
+						}
+					}
+				}
 
 				if err != nil {
 					logger.Debug("→ Error checking %s: %v", endpoint, err)
@@ -194,7 +377,7 @@ func DetectAllGraphQLEndpointsWithContext(ctx context.Context, baseURL string, s
 
 				if isValid {
 					logger.Info("Found GraphQL endpoint at: %s", endpoint)
-					resultChan <- endpoint
+					resultChan <- detected{index, endpoint}
 
 					// If stopOnFirst is true, cancel other goroutines
 					if stopOnFirst {
@@ -202,7 +385,7 @@ func DetectAllGraphQLEndpointsWithContext(ctx context.Context, baseURL string, s
 					}
 				}
 			}
-		}(path)
+		}(i, path)
 	}
 
 	// Wait for all goroutines to complete
@@ -212,7 +395,7 @@ func DetectAllGraphQLEndpointsWithContext(ctx context.Context, baseURL string, s
 	}()
 
 	// Collect all results
-	var results []string
+	var found []detected
 
 	// Keep collecting until channel is closed or context is cancelled
 	for {
@@ -222,27 +405,75 @@ func DetectAllGraphQLEndpointsWithContext(ctx context.Context, baseURL string, s
 				// Channel closed, all goroutines finished
 				goto DONE
 			}
-			if result != "" {
-				results = append(results, result)
+			if result.endpoint != "" {
+				found = append(found, result)
+				if onFound != nil {
+					onFound(result.endpoint)
+				}
 				// If stopOnFirst is true and we got a result, we can stop collecting
 				if stopOnFirst {
 					goto DONE
 				}
 			}
 		case <-ctx.Done():
-			// Context was cancelled
+			// Context was cancelled. The checks still running return promptly,
+			// and the endpoints they confirmed are buffered in resultChan, so
+			// drain it rather than losing them.
+			for result := range resultChan {
+				found = append(found, result)
+				if onFound != nil {
+					onFound(result.endpoint)
+				}
+			}
 			goto DONE
 		}
 	}
 
 DONE:
-	if checkedEndpoints == 0 {
-		return nil, fmt.Errorf("unable to check any GraphQL endpoints, possible network or server issue")
+	mutex.Lock()
+	checked := checkedEndpoints
+	mutex.Unlock()
+
+	sort.Slice(found, func(i, j int) bool { return found[i].index < found[j].index })
+	results := &DetectionResult{Endpoints: make([]string, len(found))}
+	for i, f := range found {
+		results.Endpoints[i] = f.endpoint
 	}
+	// Checks still running after an early return may add candidates; the copy
+	// is taken under the lock.
+	mutex.Lock()
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].index < candidates[j].index })
+	for _, c := range candidates {
+		results.AuthCandidates = append(results.AuthCandidates, c.AuthCandidate)
+	}
+	mutex.Unlock()
 
+	if err := parent.Err(); err != nil && checked < len(paths) && !(stopOnFirst && len(found) > 0) {
+		reason := gerrors.ErrTimeout
+		if err == context.Canceled {
+			reason = gerrors.ErrCanceled
+		}
+		return results, fmt.Errorf("%w: %w after checking %d/%d paths", gerrors.ErrIncomplete, reason, checked, len(paths))
+	}
+	if checked == 0 {
+		return nil, fmt.Errorf("unable to check any GraphQL endpoints, possible network or server issue")
+	}
 	return results, nil
 }
 
+// hasQueryTypename reports whether result answers { __typename } with the name
+// of a query root type.
+func hasQueryTypename(result map[string]interface{}) bool {
+	data, _ := result["data"].(map[string]interface{})
+	typename, _ := data["__typename"].(string)
+	// Accept various query type names (case-insensitive check for common variations)
+	switch strings.ToLower(typename) {
+	case "query", "queryroot", "query_root":
+		return true
+	}
+	return false
+}
+
 // IsGraphQLEndpoint sends a simple query to see if the response looks like GraphQL.
 // This is a backward compatibility wrapper for the context-aware version.
 func IsGraphQLEndpoint(url string) bool {
@@ -263,42 +494,44 @@ func GetFriendlyErrorMessage(err error) string {
 		return ""
 	}
 
-	errMsg := err.Error()
-
-	// Handle context cancellation errors
-	if strings.Contains(errMsg, "context canceled") {
+	switch {
+	case errors.Is(err, gerrors.ErrCanceled), errors.Is(err, context.Canceled):
 		return "Request was canceled - either by user interruption or another endpoint was found"
-	}
-
-	// Handle timeout errors
-	if strings.Contains(errMsg, "context deadline exceeded") || strings.Contains(errMsg, "timeout") {
+	case errors.Is(err, gerrors.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
 		return "Request timed out - consider increasing timeout with -timeout flag"
-	}
-
-	// Handle connection errors
-	if strings.Contains(errMsg, "connection refused") {
+	case errors.Is(err, syscall.ECONNREFUSED):
 		return "Connection refused - server may be down or not accepting connections"
+	case errors.Is(err, gerrors.ErrRateLimited):
+		return "Rate limited by the server - consider lowering the request rate with -rate"
+	case errors.Is(err, gerrors.ErrTooLarge):
+		return "Response exceeded the size limit"
+	case gerrors.IsNotGraphQL(err):
+		return "The server did not answer with JSON - this may not be a GraphQL endpoint"
 	}
 
 	// Return original error message if no friendly version is available
-	return errMsg
+	return err.Error()
 }
 
 // IsGraphQLEndpointWithContext sends a simple query to see if the response looks like GraphQL with context support.
 func IsGraphQLEndpointWithContext(ctx context.Context, url string) (bool, error) {
+	return IsGraphQLEndpointWithHeaders(ctx, url, nil)
+}
+
+// IsGraphQLEndpointWithHeaders is IsGraphQLEndpointWithContext sending headers,
+// such as credentials, with the query.
+func IsGraphQLEndpointWithHeaders(ctx context.Context, url string, headers map[string]string) (bool, error) {
 	query := `query { __typename }`
-	result, err := SendGraphQLRequestWithContext(ctx, url, query, nil, nil)
+	result, err := SendGraphQLRequestWithContext(ctx, url, query, nil, headers)
 	if err != nil {
 		// If we got HTML or non-JSON response, treat this as "not a GraphQL endpoint"
 		// rather than a hard error
-		if strings.Contains(err.Error(), "HTML response") ||
-			strings.Contains(err.Error(), "non-JSON response") {
+		if gerrors.IsNotGraphQL(err) {
 			logger.Debug("→ Endpoint %s is not a GraphQL endpoint: %v", url, err)
 			return false, nil
 		}
 		// Context cancellation and timeouts are normal during parallel endpoint detection
-		if strings.Contains(err.Error(), "canceled") ||
-			strings.Contains(err.Error(), "timed out") {
+		if gerrors.IsInterrupted(err) {
 			logger.Debug("→ Check for endpoint %s was interrupted: %v", url, err)
 			return false, nil
 		}
@@ -306,14 +539,8 @@ func IsGraphQLEndpointWithContext(ctx context.Context, url string) (bool, error)
 	}
 
 	// Check for __typename in data or a non-empty errors array.
-	if data, ok := result["data"].(map[string]interface{}); ok {
-		if typename, exists := data["__typename"].(string); exists {
-			// Accept various query type names (case-insensitive check for common variations)
-			lowerTypename := strings.ToLower(typename)
-			if lowerTypename == "query" || lowerTypename == "queryroot" || lowerTypename == "query_root" {
-				return true, nil
-			}
-		}
+	if hasQueryTypename(result) {
+		return true, nil
 	}
 	if errors, ok := result["errors"].([]interface{}); ok && len(errors) > 0 {
 		return true, nil
@@ -0,0 +1,196 @@
+package network
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding is advertised on GraphQL requests. Setting it ourselves turns
+// off the transparent gzip handling of net/http, so every body reaches
+// readBody as sent, whatever its Content-Encoding claims.
+const acceptEncoding = "gzip, deflate, br"
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// readBody reads the body of resp, a response from url, and decompresses it.
+// Gzip and zlib bodies are recognised by their magic bytes whether or not they
+// are declared. Brotli has none, so it is only decoded when declared. A
+// declared encoding the body does not have is ignored;
+// both are recorded as BodyEvents rather than failing the request. Bodies
+// shorter than their Content-Length are kept and recorded too. The compressed
+// and the decompressed sizes are both limited to MaxResponseSize. It returns
+// the number of bytes read from the wire along with the body.
+func readBody(url string, resp *http.Response) ([]byte, int64, error) {
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize+1))
+	wire := int64(len(raw))
+	if errors.Is(err, io.ErrUnexpectedEOF) && resp.ContentLength > wire && wire > 0 {
+		logger.Debug("→ Body of %s ended after %d of %d bytes", url, wire, resp.ContentLength)
+		recordBodyIssue(url, "body shorter than its Content-Length")
+		err = nil
+	}
+	if err != nil {
+		return nil, wire, err
+	}
+	if wire > MaxResponseSize {
+		return nil, wire, fmt.Errorf("%w: more than %d bytes from %s", gerrors.ErrTooLarge, MaxResponseSize, url)
+	}
+
+	declared := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if resp.Uncompressed {
+		// net/http already decompressed it, as it does when the caller's
+		// headers leave Accept-Encoding unset.
+		declared = ""
+	}
+	switch declared {
+	case "", "identity":
+		if body, ok, err := sniffCompressed(url, raw); ok || err != nil {
+			if err == nil {
+				recordBodyIssue(url, "compressed body sent without a Content-Encoding")
+			}
+			return body, wire, err
+		}
+		return raw, wire, nil
+	case "gzip", "x-gzip", "deflate":
+		if body, ok, err := sniffCompressed(url, raw); ok || err != nil {
+			return body, wire, err
+		}
+		if declared == "deflate" {
+			// Raw DEFLATE, which some servers send for deflate, has no magic bytes.
+			if body, err := decompress(url, flate.NewReader(bytes.NewReader(raw))); err == nil {
+				return body, wire, nil
+			} else if errors.Is(err, gerrors.ErrTooLarge) {
+				return nil, wire, err
+			}
+		}
+		recordBodyIssue(url, fmt.Sprintf("Content-Encoding %s declared on an uncompressed body", declared))
+		return raw, wire, nil
+	case "br":
+		if body, ok, err := sniffCompressed(url, raw); ok || err != nil {
+			recordBodyIssue(url, "Content-Encoding br declared on a gzip or zlib body")
+			return body, wire, err
+		}
+		body, err := decompress(url, brotli.NewReader(bytes.NewReader(raw)))
+		if err == nil || errors.Is(err, gerrors.ErrTooLarge) {
+			return body, wire, err
+		}
+		if looksJSON(raw) {
+			recordBodyIssue(url, "Content-Encoding br declared on an uncompressed body")
+			return raw, wire, nil
+		}
+		return nil, wire, fmt.Errorf("invalid br body in the response from %s: %w", url, err)
+	default:
+		if body, ok, err := sniffCompressed(url, raw); ok || err != nil {
+			recordBodyIssue(url, fmt.Sprintf("Content-Encoding %s declared on a gzip or zlib body", declared))
+			return body, wire, err
+		}
+		if looksJSON(raw) {
+			recordBodyIssue(url, fmt.Sprintf("Content-Encoding %s declared on an uncompressed body", declared))
+			return raw, wire, nil
+		}
+		return nil, wire, fmt.Errorf("unsupported Content-Encoding %q in the response from %s", declared, url)
+	}
+}
+
+// looksJSON reports whether raw starts like a JSON object or array.
+func looksJSON(raw []byte) bool {
+	trimmed := bytes.TrimSpace(raw)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+// sniffCompressed decompresses raw when it starts with the magic bytes of gzip
+// or zlib, reporting whether it did. A body that only looks compressed, and
+// fails to decompress, is returned as not compressed.
+func sniffCompressed(url string, raw []byte) ([]byte, bool, error) {
+	var r io.Reader
+	switch {
+	case bytes.HasPrefix(raw, gzipMagic):
+		zr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, false, nil
+		}
+		r = zr
+	case len(raw) >= 2 && raw[0]&0x0f == 8 && (uint16(raw[0])<<8|uint16(raw[1]))%31 == 0:
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, false, nil
+		}
+		r = zr
+	default:
+		return nil, false, nil
+	}
+	body, err := decompress(url, r)
+	if errors.Is(err, gerrors.ErrTooLarge) {
+		return nil, false, err
+	}
+	if err != nil {
+		logger.Debug("→ Body of %s looks compressed but does not decompress: %v", url, err)
+		return nil, false, nil
+	}
+	return body, true, nil
+}
+
+// decompress reads r up to MaxResponseSize, refusing larger output so that a
+// small compressed body cannot expand without bound.
+func decompress(url string, r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, MaxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > MaxResponseSize {
+		return nil, fmt.Errorf("%w: response from %s decompresses to more than %d bytes", gerrors.ErrTooLarge, url, MaxResponseSize)
+	}
+	return body, nil
+}
+
+// BodyEvent records a response whose body did not match its
+// Content-Encoding or Content-Length headers.
+type BodyEvent struct {
+	URL   string
+	Issue string
+}
+
+var (
+	bodyEventMu   sync.Mutex
+	bodyEvents    []BodyEvent
+	bodyEventSeen = make(map[BodyEvent]bool)
+)
+
+// recordBodyIssue stores each issue once per URL.
+func recordBodyIssue(url, issue string) {
+	bodyEventMu.Lock()
+	defer bodyEventMu.Unlock()
+	event := BodyEvent{URL: url, Issue: issue}
+	if bodyEventSeen[event] {
+		return
+	}
+	bodyEventSeen[event] = true
+	bodyEvents = append(bodyEvents, event)
+	logger.Warn("%s: %s", url, issue)
+}
+
+// BodyEvents returns the misdeclared response bodies observed, in order.
+func BodyEvents() []BodyEvent {
+	bodyEventMu.Lock()
+	defer bodyEventMu.Unlock()
+	return append([]BodyEvent(nil), bodyEvents...)
+}
+
+// resetBodyEvents forgets the misdeclared bodies observed.
+func resetBodyEvents() {
+	bodyEventMu.Lock()
+	bodyEvents = nil
+	bodyEventSeen = make(map[BodyEvent]bool)
+	bodyEventMu.Unlock()
+}
@@ -0,0 +1,145 @@
+package network
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/andybalholm/brotli"
+)
+
+const compressionJSON = `{"data":{"__typename":"Query"}}`
+
+// compress returns body compressed by the writer w returns.
+func compress(t *testing.T, body []byte, w func(io.Writer) io.WriteCloser) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := w(&buf)
+	if _, err := zw.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipped(w io.Writer) io.WriteCloser  { return gzip.NewWriter(w) }
+func zlibbed(w io.Writer) io.WriteCloser  { return zlib.NewWriter(w) }
+func brotlied(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }
+func deflated(w io.Writer) io.WriteCloser {
+	fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+	return fw
+}
+
+func TestCompressedResponses(t *testing.T) {
+	plain := []byte(compressionJSON)
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		// length overrides the Content-Length sent, cutting the body short.
+		length string
+		issue  string
+		err    string
+	}{
+		{name: "plain", body: plain},
+		{name: "gzip", encoding: "gzip", body: compress(t, plain, gzipped)},
+		{name: "x-gzip", encoding: "x-gzip", body: compress(t, plain, gzipped)},
+		{name: "zlib deflate", encoding: "deflate", body: compress(t, plain, zlibbed)},
+		{name: "raw deflate", encoding: "deflate", body: compress(t, plain, deflated)},
+		{name: "brotli", encoding: "br", body: compress(t, plain, brotlied)},
+		{name: "undeclared gzip", body: compress(t, plain, gzipped), issue: "compressed body sent without a Content-Encoding"},
+		{name: "undeclared zlib", encoding: "identity", body: compress(t, plain, zlibbed), issue: "compressed body sent without a Content-Encoding"},
+		{name: "gzip declared on plain", encoding: "gzip", body: plain, issue: "Content-Encoding gzip declared on an uncompressed body"},
+		{name: "deflate declared on plain", encoding: "deflate", body: plain, issue: "Content-Encoding deflate declared on an uncompressed body"},
+		{name: "br declared on plain", encoding: "br", body: plain, issue: "Content-Encoding br declared on an uncompressed body"},
+		{name: "br declared on gzip", encoding: "br", body: compress(t, plain, gzipped), issue: "Content-Encoding br declared on a gzip or zlib body"},
+		{name: "unknown declared on gzip", encoding: "zstd", body: compress(t, plain, gzipped), issue: "Content-Encoding zstd declared on a gzip or zlib body"},
+		{name: "unknown declared on plain", encoding: "zstd", body: plain, issue: "Content-Encoding zstd declared on an uncompressed body"},
+		{name: "unknown encoding", encoding: "zstd", body: []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, err: `unsupported Content-Encoding "zstd"`},
+		{name: "corrupt brotli", encoding: "br", body: []byte{0xff, 0xff, 0xff, 0xff}, err: "invalid br body"},
+		{name: "short body", body: plain, length: "1000", issue: "body shorter than its Content-Length"},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, tt := range tests {
+			if r.URL.Path != "/"+strings.ReplaceAll(tt.name, " ", "-") {
+				continue
+			}
+			if got := r.Header.Get("Accept-Encoding"); got != "gzip, deflate, br" {
+				t.Errorf("Accept-Encoding = %q", got)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if tt.encoding != "" {
+				w.Header().Set("Content-Encoding", tt.encoding)
+			}
+			if tt.length != "" {
+				w.Header().Set("Content-Length", tt.length)
+			}
+			w.Write(tt.body)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := srv.URL + "/" + strings.ReplaceAll(tt.name, " ", "-")
+			resp, err := SendGraphQLRequestWithContext(context.Background(), url, "{ __typename }", nil, nil)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := resp["data"].(map[string]interface{}); data["__typename"] != "Query" {
+				t.Errorf("response = %v", resp)
+			}
+			var issues []string
+			for _, e := range BodyEvents() {
+				if e.URL == url {
+					issues = append(issues, e.Issue)
+				}
+			}
+			if got := strings.Join(issues, "; "); got != tt.issue {
+				t.Errorf("body issues = %q, want %q", got, tt.issue)
+			}
+		})
+	}
+}
+
+func TestDecompressionBomb(t *testing.T) {
+	bomb := bytes.Repeat([]byte{' '}, MaxResponseSize+1)
+	for _, tt := range []struct {
+		encoding string
+		body     []byte
+	}{
+		{"gzip", compress(t, bomb, gzipped)},
+		{"br", compress(t, bomb, brotlied)},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", tt.encoding)
+			w.Write(tt.body)
+		}))
+		_, err := SendGraphQLRequestWithContext(context.Background(), srv.URL, "{ __typename }", nil, nil)
+		srv.Close()
+		if !errors.Is(err, gerrors.ErrTooLarge) {
+			t.Errorf("%s bomb of %d bytes: err = %v, want %v", tt.encoding, len(tt.body), err, gerrors.ErrTooLarge)
+		}
+	}
+}
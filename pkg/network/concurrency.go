@@ -0,0 +1,321 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// AdaptiveConcurrency is the value of SetConcurrency that lets the shared
+// client find its own parallelism, "auto" on the command line.
+const AdaptiveConcurrency = -1
+
+const (
+	// adaptiveStart is the parallelism adaptive concurrency starts at
+	adaptiveStart = 2
+
+	// adaptiveMax bounds adaptive concurrency when no cap is set
+	adaptiveMax = 64
+
+	// adaptiveWindow is the number of recent outcomes the failure rate is computed over
+	adaptiveWindow = 20
+
+	// adaptiveMinSamples is the number of outcomes needed before backing off
+	adaptiveMinSamples = 10
+
+	// adaptiveThreshold is the failure rate above which the parallelism is halved
+	adaptiveThreshold = 0.1
+)
+
+// ParseConcurrency parses the value of --concurrency: a number of requests in
+// flight, 0 for unlimited, or "auto" for AdaptiveConcurrency.
+func ParseConcurrency(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "auto") {
+		return AdaptiveConcurrency, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a non-negative number or auto", value)
+	}
+	return n, nil
+}
+
+// aimdController adjusts a parallelism from the outcomes of requests: the
+// level grows by one after a full round of successes sent while the level was
+// reached and the failure rate of the recent outcomes stays at or below the
+// threshold, and halves when it rises above it (additive increase,
+// multiplicative decrease).
+type aimdController struct {
+	level, min, max int
+	threshold       float64
+
+	// outcomes is a ring of the most recent outcomes, true for a failure
+	outcomes  []bool
+	next      int
+	filled    int
+	failures  int
+	successes int // successes at the level since it last changed
+
+	increases, decreases int64
+}
+
+func newAIMDController(start, min, max int) *aimdController {
+	c := &aimdController{min: min, max: max, threshold: adaptiveThreshold, outcomes: make([]bool, adaptiveWindow)}
+	c.level = c.clamp(start)
+	return c
+}
+
+func (c *aimdController) clamp(level int) int {
+	if level < c.min {
+		level = c.min
+	}
+	if c.max > 0 && level > c.max {
+		level = c.max
+	}
+	return level
+}
+
+// failureRate returns the share of failures in the kept outcomes.
+func (c *aimdController) failureRate() float64 {
+	if c.filled == 0 {
+		return 0
+	}
+	return float64(c.failures) / float64(c.filled)
+}
+
+// record adds the outcome of a request and returns the level that applies
+// from now on. Saturated tells whether the level was reached when the request
+// completed: a level that is not used is not raised.
+func (c *aimdController) record(failed, saturated bool) int {
+	if c.filled == len(c.outcomes) {
+		if c.outcomes[c.next] {
+			c.failures--
+		}
+	} else {
+		c.filled++
+	}
+	c.outcomes[c.next] = failed
+	c.next = (c.next + 1) % len(c.outcomes)
+	if failed {
+		c.failures++
+	}
+
+	if c.filled >= adaptiveMinSamples && c.failureRate() > c.threshold {
+		if level := c.clamp(c.level / 2); level != c.level {
+			logger.Debug("Adaptive concurrency lowered to %d after %.0f%% of the last %d requests failed", level, c.failureRate()*100, c.filled)
+			c.level = level
+			c.decreases++
+		}
+		// The requests that failed at the old level do not count against the new one.
+		c.forget()
+		return c.level
+	}
+	if failed || !saturated {
+		return c.level
+	}
+	c.successes++
+	if c.successes >= c.level {
+		c.successes = 0
+		if level := c.clamp(c.level + 1); level != c.level {
+			logger.Debug("Adaptive concurrency raised to %d", level)
+			c.level = level
+			c.increases++
+		}
+	}
+	return c.level
+}
+
+// forget drops the kept outcomes.
+func (c *aimdController) forget() {
+	c.next, c.filled, c.failures, c.successes = 0, 0, 0, 0
+}
+
+// concurrencyGate bounds the requests of the shared client in flight, from
+// sending a request until its body is closed. The limit is fixed or follows
+// an aimdController.
+type concurrencyGate struct {
+	mu        sync.Mutex
+	requested int // set by SetConcurrency
+	max       int // cap set by SetConcurrencyCap, 0 for none
+	limit     int // 0 lets every request through
+	adaptive  *aimdController
+	inFlight  int
+	peak      int
+	waiters   []chan struct{}
+}
+
+var requestGate = &concurrencyGate{}
+
+// SetConcurrency caps the requests of the shared client in flight at once to
+// n, within the cap of SetConcurrencyCap. A value of 0 removes the limit and
+// AdaptiveConcurrency starts low and raises the limit while requests succeed,
+// halving it when errors, timeouts or throttling responses pile up.
+func SetConcurrency(n int) {
+	requestGate.mu.Lock()
+	defer requestGate.mu.Unlock()
+	requestGate.requested = n
+	requestGate.reset()
+}
+
+// SetConcurrencyCap bounds the limit of SetConcurrency, adaptive or not, to
+// max requests in flight. A value of 0 removes the cap.
+func SetConcurrencyCap(max int) {
+	requestGate.mu.Lock()
+	defer requestGate.mu.Unlock()
+	requestGate.max = max
+	requestGate.reset()
+}
+
+// reset applies the requested limit within the cap. g.mu must be held.
+func (g *concurrencyGate) reset() {
+	g.adaptive = nil
+	if g.requested == AdaptiveConcurrency {
+		max := g.max
+		if max == 0 {
+			max = adaptiveMax
+		}
+		g.adaptive = newAIMDController(adaptiveStart, 1, max)
+		g.limit = g.adaptive.level
+	} else {
+		g.limit = g.requested
+		if g.max > 0 && (g.limit == 0 || g.limit > g.max) {
+			g.limit = g.max
+		}
+	}
+	g.wake()
+}
+
+// active reports whether requests go through the gate.
+func (g *concurrencyGate) active() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limit > 0
+}
+
+// acquire waits for a free slot or for ctx to be done.
+func (g *concurrencyGate) acquire(ctx context.Context) error {
+	g.mu.Lock()
+	if g.limit == 0 || g.inFlight < g.limit {
+		g.take()
+		g.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	g.waiters = append(g.waiters, ready)
+	g.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		select {
+		case <-ready:
+			// The slot was handed over while giving up; pass it on.
+			g.inFlight--
+			g.wake()
+		default:
+			for i, w := range g.waiters {
+				if w == ready {
+					g.waiters = append(g.waiters[:i], g.waiters[i+1:]...)
+					break
+				}
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// take counts a request in flight. g.mu must be held.
+func (g *concurrencyGate) take() {
+	g.inFlight++
+	if g.inFlight > g.peak {
+		g.peak = g.inFlight
+	}
+}
+
+// wake hands the free slots to the waiting requests. g.mu must be held.
+func (g *concurrencyGate) wake() {
+	for len(g.waiters) > 0 && (g.limit == 0 || g.inFlight < g.limit) {
+		close(g.waiters[0])
+		g.waiters = g.waiters[1:]
+		g.take()
+	}
+}
+
+// observe feeds the outcome of a request to the adaptive limit.
+func (g *concurrencyGate) observe(failed bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.adaptive == nil {
+		return
+	}
+	saturated := g.inFlight >= g.limit || len(g.waiters) > 0
+	g.limit = g.adaptive.record(failed, saturated)
+	g.wake()
+}
+
+// release frees the slot of a request.
+func (g *concurrencyGate) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inFlight--
+	g.wake()
+}
+
+// stats describes the limit, nil when requests are not limited.
+func (g *concurrencyGate) stats() *types.ConcurrencyStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.limit == 0 {
+		return nil
+	}
+	s := &types.ConcurrencyStats{Mode: "fixed", Limit: g.limit, Peak: g.peak}
+	if g.adaptive != nil {
+		s.Mode = "adaptive"
+		s.Increases = g.adaptive.increases
+		s.Decreases = g.adaptive.decreases
+	}
+	return s
+}
+
+// overloaded reports whether the outcome of a request is a sign of an
+// overloaded target: a transport error or timeout, or a throttling or
+// gateway status. Other errors, such as a 500 answering a malformed query,
+// say nothing about the load. Requests cancelled by the caller are not
+// outcomes at all, which ok reports.
+func overloaded(ctx context.Context, resp *http.Response, err error) (failed, ok bool) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
+			return false, false
+		}
+		return true, true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, true
+	}
+	return false, true
+}
+
+// gatedBody frees the slot of its request when closed.
+type gatedBody struct {
+	io.ReadCloser
+	once sync.Once
+}
+
+func (b *gatedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(requestGate.release)
+	return err
+}
@@ -0,0 +1,179 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseConcurrency(t *testing.T) {
+	for value, want := range map[string]int{"auto": AdaptiveConcurrency, " AUTO ": AdaptiveConcurrency, "0": 0, "8": 8} {
+		if n, err := ParseConcurrency(value); err != nil || n != want {
+			t.Errorf("ParseConcurrency(%q) = %d, %v; want %d", value, n, err, want)
+		}
+	}
+	for _, value := range []string{"-1", "many", ""} {
+		if _, err := ParseConcurrency(value); err == nil {
+			t.Errorf("ParseConcurrency(%q) succeeded", value)
+		}
+	}
+}
+
+// TestAIMDController feeds a scripted sequence of outcomes to the controller
+// and checks the level after each step.
+func TestAIMDController(t *testing.T) {
+	c := newAIMDController(2, 1, 6)
+	steps := []struct {
+		name              string
+		failed, saturated bool
+		n                 int
+		level             int
+	}{
+		{name: "saturated successes raise one level per round", saturated: true, n: 2, level: 3},
+		{name: "a partial round keeps the level", saturated: true, n: 2, level: 3},
+		{name: "completing the round raises it", saturated: true, n: 1, level: 4},
+		{name: "an unused level is not raised", n: 10, level: 4},
+		// 15 outcomes so far, none failed; 1 failure out of 16 is under 10%.
+		{name: "a failure under the threshold", failed: true, saturated: true, n: 1, level: 4},
+		{name: "failures above the threshold halve the level", failed: true, saturated: true, n: 1, level: 2},
+		// The outcomes were forgotten, so the next round starts afresh.
+		{name: "raising again after backing off", saturated: true, n: 2, level: 3},
+		{name: "the cap bounds the level", saturated: true, n: 3 + 4 + 5 + 6 + 6, level: 6},
+	}
+	for _, step := range steps {
+		var level int
+		for i := 0; i < step.n; i++ {
+			level = c.record(step.failed, step.saturated)
+		}
+		if level != step.level {
+			t.Fatalf("%s: level %d, want %d", step.name, level, step.level)
+		}
+	}
+	if c.increases != 6 || c.decreases != 1 {
+		t.Errorf("%d increases and %d decreases, want 6 and 1", c.increases, c.decreases)
+	}
+
+	// Failing without let-up halves the level to the minimum, 6 to 3 to 1,
+	// and no further.
+	for i := 0; i < 100; i++ {
+		c.record(true, true)
+	}
+	if c.level != 1 || c.decreases != 3 {
+		t.Errorf("after failures, level %d with %d decreases, want 1 and 3", c.level, c.decreases)
+	}
+	// Fewer outcomes than adaptiveMinSamples never back off.
+	c = newAIMDController(8, 1, 0)
+	for i := 0; i < adaptiveMinSamples-1; i++ {
+		c.record(true, true)
+	}
+	if c.level != 8 {
+		t.Errorf("level %d after %d failures, want 8 until enough samples", c.level, adaptiveMinSamples-1)
+	}
+}
+
+// useConcurrency sets the concurrency of the shared client for the test.
+func useConcurrency(t *testing.T, n int) {
+	t.Helper()
+	SetConcurrency(n)
+	requestGate.mu.Lock()
+	requestGate.peak = 0
+	requestGate.mu.Unlock()
+	t.Cleanup(func() { SetConcurrency(0) })
+}
+
+// overloadingServer answers 503 while more than capacity requests are in
+// flight, and after a short delay otherwise. It returns the number of 503s
+// sent and the most requests it held at once.
+func overloadingServer(t *testing.T, capacity int64) (*httptest.Server, func() (rejected, peak int64)) {
+	t.Helper()
+	var inFlight, peak, rejected atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		if n > capacity {
+			rejected.Add(1)
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(2 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() (int64, int64) { return rejected.Load(), peak.Load() }
+}
+
+// flood sends requests to url from workers goroutines at once.
+func flood(url string, workers, requests int) {
+	var wg sync.WaitGroup
+	jobs := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				SendGraphQLRequestWithContext(context.Background(), url, "{ __typename }", nil, nil)
+			}
+		}()
+	}
+	for i := 0; i < requests; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func TestFixedConcurrency(t *testing.T) {
+	srv, counts := overloadingServer(t, 100)
+	useConcurrency(t, 3)
+	flood(srv.URL, 12, 60)
+	if _, peak := counts(); peak > 3 {
+		t.Errorf("the server held %d requests at once, want at most 3", peak)
+	}
+	if s := Stats().Concurrency; s == nil || s.Mode != "fixed" || s.Limit != 3 || s.Peak > 3 {
+		t.Errorf("concurrency stats = %+v", s)
+	}
+}
+
+// TestAdaptiveConcurrencyBacksOff floods a server that fails above four
+// requests in flight, first without a limit, then with adaptive concurrency.
+func TestAdaptiveConcurrencyBacksOff(t *testing.T) {
+	const workers, requests = 16, 400
+	srv, counts := overloadingServer(t, 4)
+	flood(srv.URL, workers, requests)
+	unlimited, _ := counts()
+	if unlimited == 0 {
+		t.Fatal("the server was never overloaded without a limit")
+	}
+
+	useConcurrency(t, AdaptiveConcurrency)
+	flood(srv.URL, workers, requests)
+	rejected, _ := counts()
+	adaptive := rejected - unlimited
+	s := Stats().Concurrency
+	if s == nil || s.Mode != "adaptive" {
+		t.Fatalf("concurrency stats = %+v, want the adaptive mode", s)
+	}
+	t.Logf("unlimited: %d rejected; adaptive: %d rejected, limit %d, peak %d, %d increases, %d decreases", unlimited, adaptive, s.Limit, s.Peak, s.Increases, s.Decreases)
+	if s.Increases == 0 || s.Decreases == 0 {
+		t.Errorf("the limit never moved both ways: %+v", s)
+	}
+	// The limit hovers around the capacity of the server rather than the
+	// number of workers. Each probe above the capacity costs a rejection or
+	// two before the limit halves, so the errors stay a fraction of those
+	// without a limit.
+	if s.Peak >= workers || s.Limit > 8 {
+		t.Errorf("limit %d, peak %d; want them near the capacity of 4", s.Limit, s.Peak)
+	}
+	if adaptive*2 > unlimited || adaptive > requests/4 {
+		t.Errorf("%d requests rejected with adaptive concurrency against %d without a limit", adaptive, unlimited)
+	}
+}
@@ -0,0 +1,29 @@
+package network
+
+import "context"
+
+// Validators are the entity tags of a conditional GraphQL request. The caller
+// sets IfNoneMatch, sent as If-None-Match when not empty; the response fills
+// ETag and NotModified. A 304 response returns gerrors.ErrNotModified.
+type Validators struct {
+	IfNoneMatch string
+	// ETag is the ETag header of the response, empty when it had none.
+	ETag string
+	// NotModified is set when the server answered 304 Not Modified.
+	NotModified bool
+}
+
+// validatorsKey is the context key of the Validators set by WithValidators.
+type validatorsKey struct{}
+
+// WithValidators returns a context whose GraphQL requests are conditional on
+// v and record the validators of their responses in v.
+func WithValidators(ctx context.Context, v *Validators) context.Context {
+	return context.WithValue(ctx, validatorsKey{}, v)
+}
+
+// validators returns the Validators set on ctx, or nil.
+func validators(ctx context.Context) *Validators {
+	v, _ := ctx.Value(validatorsKey{}).(*Validators)
+	return v
+}
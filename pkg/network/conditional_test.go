@@ -0,0 +1,44 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+)
+
+func TestConditionalRequest(t *testing.T) {
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("If-None-Match"))
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer srv.Close()
+
+	v := &Validators{}
+	if _, err := SendGraphQLRequestWithContext(WithValidators(context.Background(), v), srv.URL, "{ __typename }", nil, nil); err != nil || v.ETag != `"v1"` || v.NotModified {
+		t.Fatalf("unconditional request: %v, validators %+v", err, v)
+	}
+	v = &Validators{IfNoneMatch: `"v1"`}
+	_, err := SendGraphQLRequestWithContext(WithValidators(context.Background(), v), srv.URL, "{ __typename }", nil, nil)
+	if !errors.Is(err, gerrors.ErrNotModified) || !v.NotModified || v.ETag != `"v1"` {
+		t.Errorf("conditional request: %v, validators %+v; want ErrNotModified", err, v)
+	}
+	// Requests without validators are never conditional.
+	if _, err := SendGraphQLRequestWithContext(context.Background(), srv.URL, "{ __typename }", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"", `"v1"`, ""}; !reflect.DeepEqual(received, want) {
+		t.Errorf("If-None-Match %q, want %q", received, want)
+	}
+}
@@ -0,0 +1,193 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+)
+
+// Response deliveries recognized by ClassifyDelivery
+const (
+	DeliveryJSON      = "json"
+	DeliveryMultipart = "multipart"
+	DeliverySSE       = "sse"
+	DeliveryOther     = "other"
+)
+
+// deliveryLimit is the number of bytes of a response read to classify it.
+const deliveryLimit = 64 << 10
+
+// deliveryWait is how long a response is read for. Event streams may stay
+// open, and what arrived by then is classified.
+const deliveryWait = 5 * time.Second
+
+// Delivery is how a response was framed, as far as ClassifyDelivery tells.
+type Delivery struct {
+	// Kind is one of DeliveryJSON, DeliveryMultipart, DeliverySSE or DeliveryOther.
+	Kind        string
+	ContentType string
+	Status      int
+	// Parts is the number of multipart parts or SSE events read.
+	Parts int
+	// Incremental reports whether a part or event carried an incremental
+	// delivery payload ("hasNext", "incremental" or "pending").
+	Incremental bool
+	// Result is the JSON body, or the payload of the first part or event.
+	Result map[string]interface{}
+}
+
+// ClassifyDelivery tells a JSON body from a multipart/mixed incremental
+// delivery and a text/event-stream response from their framing. Parts and
+// events are only split, not assembled into a result.
+func ClassifyDelivery(contentType string, body []byte) Delivery {
+	d := Delivery{Kind: DeliveryOther, ContentType: contentType}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	switch {
+	case err == nil && mediaType == "multipart/mixed" && params["boundary"] != "":
+		d.Kind = DeliveryMultipart
+		d.addPayloads(multipartPayloads(body, params["boundary"]))
+	case err == nil && mediaType == "text/event-stream":
+		d.Kind = DeliverySSE
+		d.addPayloads(ssePayloads(body))
+	case json.Unmarshal(body, &d.Result) == nil:
+		d.Kind = DeliveryJSON
+		d.Incremental = isIncremental(d.Result)
+	}
+	return d
+}
+
+// addPayloads counts the parts or events of d and decodes the first one.
+func (d *Delivery) addPayloads(payloads [][]byte) {
+	d.Parts = len(payloads)
+	for _, p := range payloads {
+		var result map[string]interface{}
+		if json.Unmarshal(p, &result) != nil {
+			continue
+		}
+		if d.Result == nil {
+			d.Result = result
+		}
+		if isIncremental(result) {
+			d.Incremental = true
+		}
+	}
+}
+
+// isIncremental reports whether result is a payload of incremental delivery.
+func isIncremental(result map[string]interface{}) bool {
+	for _, key := range []string{"hasNext", "incremental", "pending"} {
+		if _, ok := result[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// multipartPayloads returns the bodies of the parts of a multipart body,
+// without their headers. A truncated last part is kept.
+func multipartPayloads(body []byte, boundary string) [][]byte {
+	var payloads [][]byte
+	for i, part := range bytes.Split(body, []byte("--"+boundary)) {
+		if i == 0 || bytes.HasPrefix(part, []byte("--")) {
+			continue
+		}
+		part = bytes.ReplaceAll(part, []byte("\r\n"), []byte("\n"))
+		if _, content, ok := bytes.Cut(part, []byte("\n\n")); ok {
+			part = content
+		}
+		if part = bytes.TrimSpace(part); len(part) > 0 {
+			payloads = append(payloads, part)
+		}
+	}
+	return payloads
+}
+
+// ssePayloads returns the data of the events of an event stream, the data
+// lines of each event joined by newlines.
+func ssePayloads(body []byte) [][]byte {
+	var payloads [][]byte
+	text := strings.ReplaceAll(string(body), "\r\n", "\n")
+	for _, event := range strings.Split(text, "\n\n") {
+		var data []string
+		for _, line := range strings.Split(event, "\n") {
+			if v, ok := strings.CutPrefix(line, "data:"); ok {
+				data = append(data, strings.TrimPrefix(v, " "))
+			}
+		}
+		if len(data) > 0 {
+			payloads = append(payloads, []byte(strings.Join(data, "\n")))
+		}
+	}
+	return payloads
+}
+
+// ProbeDelivery POSTs query to url asking for the media types of accept and
+// classifies the response. The response is read for at most deliveryWait and
+// deliveryLimit bytes, so that a stream left open still gets classified.
+func ProbeDelivery(ctx context.Context, url, query, accept string, headers map[string]string) (Delivery, error) {
+	if Offline() {
+		return Delivery{}, fmt.Errorf("%w: not sending request to %s", gerrors.ErrOfflineMode, url)
+	}
+	if err := checkSend(url); err != nil {
+		return Delivery{}, err
+	}
+	jsonData, err := json.Marshal(types.GraphQLRequest{Query: query})
+	if err != nil {
+		return Delivery{}, err
+	}
+	readCtx, cancel := context.WithTimeout(ctx, deliveryWait)
+	defer cancel()
+	req, err := http.NewRequestWithContext(readCtx, http.MethodPost, url, bytes.NewReader(jsonData))
+	if err != nil {
+		return Delivery{}, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Accept", accept)
+	if session := currentSession(); session != nil {
+		session.Apply(req)
+	}
+	if err := requestLimiter.wait(ctx); err != nil {
+		return Delivery{}, fmt.Errorf("waiting for rate limit: %w", gerrors.Interrupted(ctx, err))
+	}
+	logger.Debug("→ POST %s (Accept: %s)", url, accept)
+	runStats.requests.Add(1)
+	runStats.bytesSent.Add(int64(len(jsonData)))
+	RecordOperations(url, query)
+	sent := time.Now()
+	defer trackInFlight(ctx, url)()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		recordSent(ctx, sent, url, jsonData, headers, 0, err)
+		return Delivery{}, fmt.Errorf("error sending request: %w", gerrors.Interrupted(ctx, err))
+	}
+	defer resp.Body.Close()
+	runStats.recordStatus(resp.StatusCode)
+	recordSent(ctx, sent, url, jsonData, headers, resp.StatusCode, nil)
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, deliveryLimit))
+	runStats.bytesReceived.Add(int64(len(body)))
+	if err != nil && ctx.Err() != nil {
+		return Delivery{}, fmt.Errorf("error reading response: %w", gerrors.Interrupted(ctx, err))
+	}
+	if err != nil && len(body) == 0 {
+		return Delivery{}, fmt.Errorf("error reading response: %w", err)
+	}
+	d := ClassifyDelivery(resp.Header.Get("Content-Type"), body)
+	d.Status = resp.StatusCode
+	return d, nil
+}
@@ -0,0 +1,69 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const incrementalMultipart = "\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" +
+	`{"data":{"__typename":"Query"},"pending":[{"id":"0","path":[]}],"hasNext":true}` +
+	"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" +
+	`{"incremental":[{"id":"0","data":{"gsDeferred":"Query"}}],"completed":[{"id":"0"}],"hasNext":false}` +
+	"\r\n-----\r\n"
+
+func TestClassifyDelivery(t *testing.T) {
+	tests := []struct {
+		name, contentType, body string
+		kind                    string
+		parts                   int
+		incremental             bool
+		typename                interface{}
+	}{
+		{"json", "application/json", `{"data":{"__typename":"Query"}}`, DeliveryJSON, 0, false, "Query"},
+		{"json with an unknown media type", "text/plain", `{"data":{"__typename":"Query"}}`, DeliveryJSON, 0, false, "Query"},
+		{"incremental json", "application/json", `{"data":{"__typename":"Query"},"hasNext":false}`, DeliveryJSON, 0, true, "Query"},
+		{"multipart", `multipart/mixed; boundary="-"; deferSpec=20220824`, incrementalMultipart, DeliveryMultipart, 2, true, "Query"},
+		{"truncated multipart", "multipart/mixed; boundary=-", "\r\n---\r\nContent-Type: application/json\r\n\r\n{\"data\":{\"__typename\":\"Query\"},\"hasNext\":tr", DeliveryMultipart, 1, false, nil},
+		{"multipart without a boundary", "multipart/mixed", incrementalMultipart, DeliveryOther, 0, false, nil},
+		// A comment is not an event, multi-line data is joined, and the
+		// complete event counts.
+		{"event stream", "text/event-stream", ": keep-alive\n\nevent: next\ndata: {\"data\":\ndata: {\"__typename\":\"Query\"}}\n\nevent: complete\ndata:\n\n", DeliverySSE, 2, false, "Query"},
+		{"event stream with CRLF", "text/event-stream; charset=utf-8", "event: next\r\ndata: {\"data\":{\"__typename\":\"Query\"}}\r\n\r\nevent: next\r\ndata: {\"hasNext\":false}\r\n\r\n", DeliverySSE, 2, true, "Query"},
+		{"html", "text/html", "<html></html>", DeliveryOther, 0, false, nil},
+	}
+	for _, tt := range tests {
+		d := ClassifyDelivery(tt.contentType, []byte(tt.body))
+		var typename interface{}
+		if data, ok := d.Result["data"].(map[string]interface{}); ok {
+			typename = data["__typename"]
+		}
+		if d.Kind != tt.kind || d.Parts != tt.parts || d.Incremental != tt.incremental || typename != tt.typename || d.ContentType != tt.contentType {
+			t.Errorf("%s: ClassifyDelivery() = %s, %d parts, incremental %v, __typename %v; want %s, %d, %v, %v",
+				tt.name, d.Kind, d.Parts, d.Incremental, typename, tt.kind, tt.parts, tt.incremental, tt.typename)
+		}
+	}
+}
+
+func TestProbeDelivery(t *testing.T) {
+	var accept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", `multipart/mixed; boundary="-"`)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(incrementalMultipart))
+	}))
+	defer srv.Close()
+
+	d, err := ProbeDelivery(context.Background(), srv.URL, "{ __typename }", "multipart/mixed", map[string]string{"Accept": "application/json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "multipart/mixed" {
+		t.Errorf("sent Accept: %s, want the probed media type over the headers", accept)
+	}
+	if d.Kind != DeliveryMultipart || d.Status != http.StatusAccepted || d.Parts != 2 || !d.Incremental {
+		t.Errorf("ProbeDelivery() = %+v", d)
+	}
+}
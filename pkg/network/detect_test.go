@@ -0,0 +1,171 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+)
+
+// usePaths replaces the detection paths for the duration of the test.
+func usePaths(t *testing.T, paths ...string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "paths.json")
+	content := `{"version":1,"entries":["` + strings.Join(paths, `","`) + `"]}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := data.LoadFile("paths", file); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(data.Reset)
+}
+
+// delayedServer answers as GraphQL on the paths of delays, each after its
+// delay, and with a 404 page elsewhere.
+func delayedServer(t *testing.T, delays map[string]time.Duration) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay, ok := delays[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		// The server only notices the client going away once the body is read.
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDetectEndpointsStreamsInDiscoveryOrder(t *testing.T) {
+	usePaths(t, "/a", "/b", "/c", "/d", "/e")
+	srv := delayedServer(t, map[string]time.Duration{
+		"/a": 400 * time.Millisecond,
+		"/c": 0,
+		"/e": 200 * time.Millisecond,
+	})
+
+	var mu sync.Mutex
+	var streamed []string
+	var firstAt time.Duration
+	start := time.Now()
+	result, err := DetectEndpoints(context.Background(), srv.URL, false, func(endpoint string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if streamed == nil {
+			firstAt = time.Since(start)
+		}
+		streamed = append(streamed, endpoint)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{srv.URL + "/c", srv.URL + "/e", srv.URL + "/a"}; !reflect.DeepEqual(streamed, want) {
+		t.Errorf("streamed %q, want the fastest first %q", streamed, want)
+	}
+	if want := []string{srv.URL + "/a", srv.URL + "/c", srv.URL + "/e"}; !reflect.DeepEqual(result.Endpoints, want) {
+		t.Errorf("Endpoints = %q, want the paths order %q", result.Endpoints, want)
+	}
+	// The first endpoint is handed over before the slowest path has answered.
+	if firstAt >= 400*time.Millisecond {
+		t.Errorf("first endpoint streamed after %v, want it before detection ends", firstAt)
+	}
+}
+
+func TestDetectEndpointsStopOnFirst(t *testing.T) {
+	usePaths(t, "/a", "/b", "/c")
+	srv := delayedServer(t, map[string]time.Duration{
+		"/a": 500 * time.Millisecond,
+		"/c": 0,
+	})
+	var streamed []string
+	start := time.Now()
+	endpoints, err := DetectAllGraphQLEndpointsWithCallback(context.Background(), srv.URL, true, func(endpoint string) {
+		streamed = append(streamed, endpoint)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{srv.URL + "/c"}; !reflect.DeepEqual(endpoints, want) || !reflect.DeepEqual(streamed, want) {
+		t.Errorf("endpoints %q, streamed %q; want only the first confirmed %q", endpoints, streamed, want)
+	}
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Errorf("returned after %v, want without waiting for the slow path", elapsed)
+	}
+}
+
+func TestDetectEndpointsKeepsPartialResults(t *testing.T) {
+	usePaths(t, "/a", "/slow", "/b", "/missing", "/stuck")
+	srv := delayedServer(t, map[string]time.Duration{
+		"/a":     0,
+		"/slow":  time.Minute,
+		"/b":     50 * time.Millisecond,
+		"/stuck": time.Minute,
+	})
+	for _, tt := range []struct {
+		name   string
+		reason error
+		ctx    func() (context.Context, context.CancelFunc)
+	}{
+		{"timeout", gerrors.ErrTimeout, func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 300*time.Millisecond)
+		}},
+		{"canceled", gerrors.ErrCanceled, func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(300*time.Millisecond, cancel)
+			return ctx, cancel
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			var streamed []string
+			start := time.Now()
+			result, err := DetectEndpoints(ctx, srv.URL, false, func(endpoint string) {
+				streamed = append(streamed, endpoint)
+			})
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("returned after %v, want soon after the context ended", elapsed)
+			}
+			if !errors.Is(err, gerrors.ErrIncomplete) || !errors.Is(err, tt.reason) {
+				t.Fatalf("error = %v, want it incomplete and %v", err, tt.reason)
+			}
+			// The slow paths were not checked; the others were.
+			if !strings.Contains(err.Error(), "after checking 3/5 paths") {
+				t.Errorf("error = %q, want the paths checked counted", err)
+			}
+			want := []string{srv.URL + "/a", srv.URL + "/b"}
+			if result == nil || !reflect.DeepEqual(result.Endpoints, want) {
+				t.Fatalf("result = %+v, want the endpoints confirmed before the context ended", result)
+			}
+			if !reflect.DeepEqual(streamed, want) {
+				t.Errorf("streamed %q, want %q", streamed, want)
+			}
+
+			endpoints, err := DetectAllGraphQLEndpointsWithContext(ctx, srv.URL, false)
+			if len(endpoints) != 0 || !errors.Is(err, gerrors.ErrIncomplete) || !strings.Contains(err.Error(), "after checking 0/5 paths") {
+				t.Errorf("detection with an ended context = %q, %v; want nothing checked", endpoints, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Encoding is how a GraphQL request is wrapped in the HTTP body for endpoints
+// that tunnel GraphQL through another API.
+type Encoding string
+
+// Request encodings
+const (
+	// EncodingJSON is the standard JSON body, {"query": ..., "variables": ...}.
+	EncodingJSON Encoding = "json"
+	// EncodingEnvelope nests the standard body in a JSON envelope, {"data": {...}}.
+	EncodingEnvelope Encoding = "envelope"
+	// EncodingForm sends the members of the standard body as form fields,
+	// variables as JSON.
+	EncodingForm Encoding = "form"
+	// EncodingJSONRPC sends the standard body as the params of a JSON-RPC 2.0
+	// call of the graphql method.
+	EncodingJSONRPC Encoding = "jsonrpc"
+)
+
+// ParseEncoding parses the name of a request encoding.
+func ParseEncoding(name string) (Encoding, error) {
+	switch e := Encoding(name); e {
+	case EncodingJSON, EncodingEnvelope, EncodingForm, EncodingJSONRPC:
+		return e, nil
+	}
+	return "", fmt.Errorf("unknown request encoding %q (valid: '%s', '%s', '%s', '%s')", name, EncodingJSON, EncodingEnvelope, EncodingForm, EncodingJSONRPC)
+}
+
+// WrapperEncodings are the encodings detection tries, in order, on paths
+// where the standard body is not answered as GraphQL.
+var WrapperEncodings = []Encoding{EncodingEnvelope, EncodingForm, EncodingJSONRPC}
+
+// The request encodings of endpoints, set by SetEncoding.
+var (
+	encodingMu sync.RWMutex
+	encodings  = map[string]Encoding{}
+)
+
+// SetEncoding makes every later request to endpoint use e. Requests to the
+// same URL with another query string, as sent by some strategies, use it too.
+func SetEncoding(endpoint string, e Encoding) {
+	encodingMu.Lock()
+	defer encodingMu.Unlock()
+	if e == EncodingJSON || e == "" {
+		delete(encodings, encodingKey(endpoint))
+		return
+	}
+	encodings[encodingKey(endpoint)] = e
+}
+
+// Encodings returns the endpoints set to an encoding other than EncodingJSON.
+func Encodings() map[string]Encoding {
+	encodingMu.RLock()
+	defer encodingMu.RUnlock()
+	if len(encodings) == 0 {
+		return nil
+	}
+	out := make(map[string]Encoding, len(encodings))
+	for k, v := range encodings {
+		out[k] = v
+	}
+	return out
+}
+
+// encodingKey is endpoint without its query string and fragment.
+func encodingKey(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	u.RawQuery, u.ForceQuery, u.Fragment = "", false, ""
+	return u.String()
+}
+
+// encodingKeyCtx is the context key of the encoding forced by WithEncoding.
+type encodingKeyCtx struct{}
+
+// WithEncoding returns a context whose requests use e whatever the encoding
+// set for their endpoint, as detection does to probe it.
+func WithEncoding(ctx context.Context, e Encoding) context.Context {
+	return context.WithValue(ctx, encodingKeyCtx{}, e)
+}
+
+// encodingFor returns the encoding of a request to endpoint sent with ctx.
+func encodingFor(ctx context.Context, endpoint string) Encoding {
+	if e, ok := ctx.Value(encodingKeyCtx{}).(Encoding); ok {
+		return e
+	}
+	encodingMu.RLock()
+	defer encodingMu.RUnlock()
+	if e, ok := encodings[encodingKey(endpoint)]; ok {
+		return e
+	}
+	return EncodingJSON
+}
+
+// mayWrap reports whether a path answering the standard probe with status may
+// tunnel GraphQL: it exists and accepts POST requests.
+func mayWrap(status int) bool {
+	switch status {
+	case 0, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusUnauthorized, http.StatusForbidden:
+		return false
+	}
+	return true
+}
+
+// DetectEncoding sends { __typename } to endpoint in each of WrapperEncodings
+// and returns the first one answered with the name of the query root type. An
+// error answer is not enough, since wrappers reject what they cannot parse
+// with errors of their own.
+func DetectEncoding(ctx context.Context, endpoint string, headers map[string]string) (Encoding, bool) {
+	for _, e := range WrapperEncodings {
+		if ctx.Err() != nil {
+			break
+		}
+		result, err := SendGraphQLRequestWithContext(WithEncoding(ctx, e), endpoint, `query { __typename }`, nil, headers)
+		if err == nil && hasQueryTypename(result) {
+			return e, true
+		}
+	}
+	return "", false
+}
+
+// encode wraps the standard JSON body as e describes and returns it with its
+// content type. Bodies that are not JSON objects, such as batches, are sent as
+// they are.
+func (e Encoding) encode(body []byte) ([]byte, string, error) {
+	const jsonType = "application/json"
+	if e == EncodingJSON || e == "" {
+		return body, jsonType, nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return body, jsonType, nil
+	}
+
+	var wrapped interface{}
+	switch e {
+	case EncodingEnvelope:
+		wrapped = map[string]json.RawMessage{"data": body}
+	case EncodingJSONRPC:
+		wrapped = map[string]interface{}{"jsonrpc": "2.0", "method": "graphql", "params": json.RawMessage(body), "id": 1}
+	case EncodingForm:
+		names := make([]string, 0, len(members))
+		for name := range members {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		values := url.Values{}
+		for _, name := range names {
+			var s string
+			if json.Unmarshal(members[name], &s) == nil {
+				values.Set(name, s)
+			} else {
+				values.Set(name, string(members[name]))
+			}
+		}
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+	default:
+		return nil, "", fmt.Errorf("unknown request encoding %q", e)
+	}
+	data, err := json.Marshal(wrapped)
+	if err != nil {
+		return nil, "", fmt.Errorf("error marshalling request: %w", err)
+	}
+	return data, jsonType, nil
+}
+
+// decode unwraps the GraphQL response carried by result, the response to a
+// request sent with e. Responses that are not wrapped are returned as they are.
+func (e Encoding) decode(result map[string]interface{}) map[string]interface{} {
+	switch e {
+	case EncodingJSONRPC:
+		if inner, ok := result["result"].(map[string]interface{}); ok {
+			return inner
+		}
+		if rpcErr, ok := result["error"].(map[string]interface{}); ok {
+			msg, _ := rpcErr["message"].(string)
+			return map[string]interface{}{"errors": []interface{}{map[string]interface{}{"message": msg, "extensions": map[string]interface{}{"jsonrpc": rpcErr}}}}
+		}
+	case EncodingEnvelope:
+		// {"data": {"data": ..., "errors": ...}} nests the response in the
+		// envelope of the request; a query field named data is left alone.
+		inner, ok := result["data"].(map[string]interface{})
+		if !ok || len(result) != 1 {
+			break
+		}
+		for k := range inner {
+			if k != "data" && k != "errors" && k != "extensions" {
+				return result
+			}
+		}
+		if _, hasData := inner["data"]; hasData {
+			return inner
+		}
+		if _, hasErrors := inner["errors"]; hasErrors {
+			return inner
+		}
+	}
+	return result
+}
+
+// String returns the name of e.
+func (e Encoding) String() string {
+	if e == "" {
+		return string(EncodingJSON)
+	}
+	return string(e)
+}
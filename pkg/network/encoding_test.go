@@ -0,0 +1,211 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// wrappedRequest is the GraphQL request a wrapper mock unwrapped.
+type wrappedRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// wrapperServer tunnels GraphQL at /rpc through the encoding e and rejects
+// every other body, the standard one included, with an error of its own. It
+// answers __typename queries with the query type and records the others.
+func wrapperServer(t *testing.T, e Encoding, received *[]wrappedRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rpc" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req wrappedRequest
+		ok := false
+		switch e {
+		case EncodingEnvelope:
+			var envelope struct {
+				Data *wrappedRequest `json:"data"`
+			}
+			ok = r.Header.Get("Content-Type") == "application/json" && json.Unmarshal(body, &envelope) == nil && envelope.Data != nil
+			if ok {
+				req = *envelope.Data
+			}
+		case EncodingForm:
+			values, err := url.ParseQuery(string(body))
+			ok = r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" && err == nil && values.Get("query") != ""
+			if ok {
+				req.Query = values.Get("query")
+				if v := values.Get("variables"); v != "" {
+					ok = json.Unmarshal([]byte(v), &req.Variables) == nil
+				}
+			}
+		case EncodingJSONRPC:
+			var call struct {
+				Version string          `json:"jsonrpc"`
+				Method  string          `json:"method"`
+				Params  *wrappedRequest `json:"params"`
+				ID      int             `json:"id"`
+			}
+			ok = json.Unmarshal(body, &call) == nil && call.Version == "2.0" && call.Method == "graphql" && call.Params != nil
+			if ok {
+				req = *call.Params
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"unsupported request body"}`))
+			return
+		}
+		response := `{"data":{"__typename":"Query"}}`
+		if !strings.Contains(req.Query, "__typename") {
+			*received = append(*received, req)
+			response = `{"data":{"me":{"id":"1"}}}`
+		}
+		switch e {
+		case EncodingEnvelope:
+			response = `{"data":` + response + `}`
+		case EncodingJSONRPC:
+			response = `{"jsonrpc":"2.0","result":` + response + `,"id":1}`
+		}
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestDetectEndpointsFindsWrappers detects an endpoint behind each wrapper
+// and checks that later requests to it are wrapped and their answers
+// unwrapped.
+func TestDetectEndpointsFindsWrappers(t *testing.T) {
+	for _, e := range WrapperEncodings {
+		t.Run(string(e), func(t *testing.T) {
+			usePaths(t, "/graphql", "/rpc")
+			var received []wrappedRequest
+			srv := wrapperServer(t, e, &received)
+			endpoint := srv.URL + "/rpc"
+			t.Cleanup(func() { SetEncoding(endpoint, EncodingJSON) })
+
+			result, err := DetectEndpoints(context.Background(), srv.URL, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Endpoints, []string{endpoint}) || Encodings()[endpoint] != e {
+				t.Fatalf("detected %v with the encodings %v, want %s at %s", result.Endpoints, Encodings(), e, endpoint)
+			}
+
+			vars := map[string]interface{}{"id": "1", "tags": []interface{}{"a"}}
+			got, err := SendGraphQLRequestWithContext(context.Background(), endpoint+"?op=Me", "query Me($id: ID!) { me(id: $id) { id } }", vars, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[string]interface{}{"data": map[string]interface{}{"me": map[string]interface{}{"id": "1"}}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("response = %v, want it unwrapped", got)
+			}
+			if len(received) != 1 || received[0].Query != "query Me($id: ID!) { me(id: $id) { id } }" || !reflect.DeepEqual(received[0].Variables, vars) {
+				t.Errorf("the server unwrapped %+v", received)
+			}
+		})
+	}
+}
+
+func TestDetectEndpointsIgnoresPlainAPIs(t *testing.T) {
+	usePaths(t, "/rpc")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"unsupported request body"}`))
+	}))
+	defer srv.Close()
+	result, err := DetectEndpoints(context.Background(), srv.URL, false, nil)
+	if err != nil || len(result.Endpoints) != 0 || len(Encodings()) != 0 {
+		t.Errorf("DetectEndpoints() = %+v, %v with the encodings %v", result, err, Encodings())
+	}
+}
+
+func TestJSONRPCErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`))
+	}))
+	defer srv.Close()
+	SetEncoding(srv.URL, EncodingJSONRPC)
+	defer SetEncoding(srv.URL, EncodingJSON)
+
+	result, err := SendGraphQLRequestWithContext(context.Background(), srv.URL, "{ me { id } }", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, _ := result["errors"].([]interface{})
+	if len(errs) != 1 {
+		t.Fatalf("result = %v, want the JSON-RPC error as a GraphQL error", result)
+	}
+	first := errs[0].(map[string]interface{})
+	ext, _ := first["extensions"].(map[string]interface{})
+	if first["message"] != "Method not found" || ext["jsonrpc"].(map[string]interface{})["code"] != float64(-32601) {
+		t.Errorf("error = %v", first)
+	}
+}
+
+func TestEncodingEncode(t *testing.T) {
+	body := []byte(`{"query":"{ me { id } }","variables":{"id":1},"operationName":"Me"}`)
+	tests := []struct {
+		encoding    Encoding
+		body        string
+		contentType string
+	}{
+		{EncodingJSON, string(body), "application/json"},
+		{EncodingEnvelope, `{"data":` + string(body) + `}`, "application/json"},
+		{EncodingJSONRPC, `{"id":1,"jsonrpc":"2.0","method":"graphql","params":` + string(body) + `}`, "application/json"},
+		{EncodingForm, "operationName=Me&query=%7B+me+%7B+id+%7D+%7D&variables=%7B%22id%22%3A1%7D", "application/x-www-form-urlencoded"},
+	}
+	for _, tt := range tests {
+		got, contentType, err := tt.encoding.encode(body)
+		if err != nil || string(got) != tt.body || contentType != tt.contentType {
+			t.Errorf("%s: encode() = %s, %s, %v; want %s, %s", tt.encoding, got, contentType, err, tt.body, tt.contentType)
+		}
+		// Batches are not wrapped.
+		if got, _, _ := tt.encoding.encode([]byte(`[{"query":"{ a }"}]`)); string(got) != `[{"query":"{ a }"}]` {
+			t.Errorf("%s: a batch was encoded as %s", tt.encoding, got)
+		}
+	}
+}
+
+func TestEncodingDecodeEnvelope(t *testing.T) {
+	for doc, want := range map[string]string{
+		`{"data":{"data":{"me":1}}}`:            `{"data":{"me":1}}`,
+		`{"data":{"errors":[{"message":"x"}]}}`: `{"errors":[{"message":"x"}]}`,
+		// A query field named data is not an envelope.
+		`{"data":{"data":1,"me":2}}`:        `{"data":{"data":1,"me":2}}`,
+		`{"data":{"me":1},"extensions":{}}`: `{"data":{"me":1},"extensions":{}}`,
+	} {
+		var result, expected map[string]interface{}
+		json.Unmarshal([]byte(doc), &result)
+		json.Unmarshal([]byte(want), &expected)
+		if got := EncodingEnvelope.decode(result); !reflect.DeepEqual(got, expected) {
+			t.Errorf("decode(%s) = %v, want %s", doc, got, want)
+		}
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for _, name := range []string{"json", "envelope", "form", "jsonrpc"} {
+		if e, err := ParseEncoding(name); err != nil || e.String() != name {
+			t.Errorf("ParseEncoding(%q) = %q, %v", name, e, err)
+		}
+	}
+	if _, err := ParseEncoding("soap"); err == nil || !strings.Contains(err.Error(), `unknown request encoding "soap"`) {
+		t.Errorf("ParseEncoding(soap) = %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package network
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// duplicateSlashes matches the runs of slashes collapsed by JoinEndpoint.
+var duplicateSlashes = regexp.MustCompile(`/{2,}`)
+
+// ParseBase parses the base URL detection appends its paths to. It must be an
+// absolute http or https URL. A query or fragment is refused, since the paths
+// could not be appended after it.
+func ParseBase(base string) (*url.URL, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", base, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: it needs an http or https scheme and a host, such as https://example.com/app", base)
+	}
+	if u.RawQuery != "" || u.ForceQuery {
+		return nil, fmt.Errorf("invalid base URL %q: detection appends paths to it, so it cannot have a query", base)
+	}
+	if u.Fragment != "" {
+		return nil, fmt.Errorf("invalid base URL %q: detection appends paths to it, so it cannot have a fragment", base)
+	}
+	return u, nil
+}
+
+// JoinEndpoint appends the detection path p, which may carry a query, to the
+// path of base, collapsing duplicate slashes: https://host/app joined with
+// /graphql gives https://host/app/graphql, and with / gives https://host/app/.
+func JoinEndpoint(base *url.URL, p string) string {
+	// The path is split by hand, since url.Parse would take the first
+	// segment of //graphql for a host.
+	p, query, _ := strings.Cut(p, "?")
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		p = unescaped
+	}
+	u := *base
+	u.Path = duplicateSlashes.ReplaceAllString(strings.TrimRight(base.Path, "/")+"/"+p, "/")
+	u.RawPath = ""
+	u.RawQuery = query
+	u.Fragment = ""
+	return u.String()
+}
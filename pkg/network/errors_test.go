@@ -0,0 +1,211 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+)
+
+// unanswered holds the request until the client gives up. The body is read
+// so that the server notices the client closing the connection.
+func unanswered(w http.ResponseWriter, r *http.Request) {
+	io.Copy(io.Discard, r.Body)
+	<-r.Context().Done()
+}
+
+// unpause lifts the pause rate-limited responses put on every request, so
+// that it does not slow down the tests that follow.
+func unpause() {
+	requestLimiter.mu.Lock()
+	requestLimiter.pausedUntil = time.Time{}
+	requestLimiter.mu.Unlock()
+}
+
+// TestFailureModes checks that each way a request can fail is reported with
+// its sentinel, keeping the underlying cause, and described to the user.
+func TestFailureModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		// ctx returns the context of the request; nil uses context.Background.
+		ctx      func() (context.Context, context.CancelFunc)
+		sentinel error
+		cause    error
+		friendly string
+	}{
+		{
+			name: "HTML page",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Write([]byte("<!DOCTYPE html><html><body>Login</body></html>"))
+			},
+			sentinel: gerrors.ErrNonJSONResponse,
+			friendly: "did not answer with JSON",
+		},
+		{
+			name: "HTML page without a Content-Type",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header()["Content-Type"] = nil
+				w.Write([]byte("  <html><body>Login</body></html>"))
+			},
+			sentinel: gerrors.ErrHTMLResponse,
+			friendly: "did not answer with JSON",
+		},
+		{
+			name: "plain text",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Write([]byte("Bad Gateway"))
+			},
+			sentinel: gerrors.ErrNonJSONResponse,
+			friendly: "did not answer with JSON",
+		},
+		{
+			name:    "deadline",
+			handler: unanswered,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			sentinel: gerrors.ErrTimeout,
+			cause:    context.DeadlineExceeded,
+			friendly: "Request timed out",
+		},
+		{
+			name:    "cancellation",
+			handler: unanswered,
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			sentinel: gerrors.ErrCanceled,
+			cause:    context.Canceled,
+			friendly: "Request was canceled",
+		},
+		{
+			name: "oversized body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"data":{"s":"`))
+				chunk := []byte(strings.Repeat("a", 1<<20))
+				for i := 0; i <= MaxResponseSize>>20; i++ {
+					if _, err := w.Write(chunk); err != nil {
+						return
+					}
+				}
+			},
+			sentinel: gerrors.ErrTooLarge,
+			friendly: "exceeded the size limit",
+		},
+		{
+			name: "rate limited after every retry",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"errors":[{"message":"Too many requests"}]}`))
+			},
+			sentinel: gerrors.ErrRateLimited,
+			friendly: "Rate limited by the server",
+		},
+	}
+	ResetStats()
+	defer ResetStats()
+	defer unpause()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if tt.ctx != nil {
+				ctx, cancel = tt.ctx()
+			}
+			defer cancel()
+
+			_, err := SendGraphQLRequestWithContext(ctx, srv.URL, "{ __typename }", nil, nil)
+			if !errors.Is(err, tt.sentinel) {
+				t.Fatalf("error = %v, want one matching %v", err, tt.sentinel)
+			}
+			if tt.cause != nil && !errors.Is(err, tt.cause) {
+				t.Errorf("error = %v lost its cause %v", err, tt.cause)
+			}
+			if got := GetFriendlyErrorMessage(err); !strings.Contains(got, tt.friendly) {
+				t.Errorf("GetFriendlyErrorMessage() = %q, want one containing %q", got, tt.friendly)
+			}
+		})
+	}
+}
+
+func TestContentTypeErrorDetails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>By using this API you accept the terms</html>"))
+	}))
+	defer srv.Close()
+	_, err := SendGraphQLRequestWithContext(context.Background(), srv.URL, "{ __typename }", nil, nil)
+	var cte *gerrors.ContentTypeError
+	if !errors.As(err, &cte) {
+		t.Fatalf("error = %v, want a ContentTypeError", err)
+	}
+	if cte.ContentType != "text/html" || !strings.Contains(string(cte.Body), "accept the terms") || cte.Err == nil {
+		t.Errorf("ContentTypeError = %+v, want the Content-Type, the body and the parse error", cte)
+	}
+	if errors.Is(err, gerrors.ErrHTMLResponse) || !gerrors.IsNotGraphQL(err) {
+		t.Errorf("%v matches the wrong kind", err)
+	}
+}
+
+func TestRateLimitErrorDetails(t *testing.T) {
+	ResetStats()
+	defer ResetStats()
+	defer unpause()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, `{"errors":[{"message":"rate limit exceeded","extensions":{"code":"RATE_LIMITED"}}]}`)
+	}))
+	defer srv.Close()
+	_, err := SendGraphQLRequestWithContext(context.Background(), srv.URL, "{ __typename }", nil, nil)
+	var rle *gerrors.RateLimitError
+	if !errors.As(err, &rle) || !errors.Is(err, gerrors.ErrRateLimited) {
+		t.Fatalf("error = %v, want a RateLimitError", err)
+	}
+	if rle.StatusCode != http.StatusServiceUnavailable || rle.RetryAfter != time.Second {
+		t.Errorf("RateLimitError = %+v, want the status and Retry-After of the response", rle)
+	}
+}
+
+func TestRefusedAndBlockedRequests(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	_, err := SendGraphQLRequestWithContext(context.Background(), url, "{ __typename }", nil, nil)
+	if !errors.Is(err, syscall.ECONNREFUSED) || gerrors.IsInterrupted(err) || gerrors.IsNotGraphQL(err) {
+		t.Errorf("error = %v, want a refused connection only", err)
+	}
+	if got := GetFriendlyErrorMessage(err); !strings.HasPrefix(got, "Connection refused") {
+		t.Errorf("GetFriendlyErrorMessage() = %q", got)
+	}
+
+	SetOffline(true)
+	_, err = SendGraphQLRequestWithContext(context.Background(), url, "{ __typename }", nil, nil)
+	SetOffline(false)
+	if !errors.Is(err, gerrors.ErrOfflineMode) {
+		t.Errorf("offline error = %v, want ErrOfflineMode", err)
+	}
+
+	SetScope([]string{"api.example.com"})
+	_, err = SendGraphQLRequestWithContext(context.Background(), url, "{ __typename }", nil, nil)
+	SetScope(nil)
+	if !errors.Is(err, gerrors.ErrOutOfScope) {
+		t.Errorf("out-of-scope error = %v, want ErrOutOfScope", err)
+	}
+}
@@ -0,0 +1,82 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ParseHeader splits a "Name: value" header line and normalizes it with
+// NormalizeHeader.
+func ParseHeader(line string) (name, value string, err error) {
+	name, value, ok := strings.Cut(line, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("header %q must be \"Name: value\"", line)
+	}
+	return NormalizeHeader(name, value)
+}
+
+// NormalizeHeader returns name in canonical form and value without its
+// surrounding whitespace. Names must be RFC 7230 tokens and values may not
+// hold control characters other than tab, so that a header is never mangled
+// or refused by net/http in the middle of a run. Host is refused: it is taken
+// from the URL, or from WithVirtualHost for the --vhosts runs.
+func NormalizeHeader(name, value string) (string, string, error) {
+	if name == "" {
+		return "", "", errors.New("empty header name")
+	}
+	for i := 0; i < len(name); i++ {
+		if !isTokenChar(name[i]) {
+			return "", "", fmt.Errorf("header name %q contains characters not allowed in header names", name)
+		}
+	}
+	name = http.CanonicalHeaderKey(name)
+	if name == "Host" {
+		return "", "", errors.New("the Host header cannot be set; it is taken from the target URL, use --vhosts to send requests under another host name")
+	}
+	value = strings.Trim(value, " \t")
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			return "", "", fmt.Errorf("value of header %s contains the control character %q", name, rune(c))
+		}
+	}
+	return name, value, nil
+}
+
+// NormalizeHeaders returns a copy of headers with every header normalized
+// by NormalizeHeader. Errors name source, the flag or file the headers come
+// from. Names differing only in case are the same header and an error.
+func NormalizeHeaders(source string, headers map[string]string) (map[string]string, error) {
+	if headers == nil {
+		return nil, nil
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	out := make(map[string]string, len(headers))
+	for _, k := range names {
+		name, value, err := NormalizeHeader(k, headers[k])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		if _, dup := out[name]; dup {
+			return nil, fmt.Errorf("%s: header %s is set more than once", source, name)
+		}
+		out[name] = value
+	}
+	return out, nil
+}
+
+// isTokenChar reports whether c may appear in an RFC 7230 token.
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,112 @@
+package network
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		line        string
+		name, value string
+		// err is a part of the error, empty when the line is valid.
+		err string
+	}{
+		{line: "Authorization: Bearer abc", name: "Authorization", value: "Bearer abc"},
+		{line: "x-api-key:secret", name: "X-Api-Key", value: "secret"},
+		{line: "  X-Trace  :  a:b:c \t", name: "X-Trace", value: "a:b:c"},
+		{line: "X-Empty:", name: "X-Empty", value: ""},
+		{line: "X-Tab: a\tb", name: "X-Tab", value: "a\tb"},
+		{line: "X-Token!#$%&'*+-.^_`|~: v", name: "X-Token!#$%&'*+-.^_`|~", value: "v"},
+
+		{line: "Authorization Bearer abc", err: `must be "Name: value"`},
+		{line: ": value", err: `must be "Name: value"`},
+		{line: "X Api: v", err: `header name "X Api" contains characters not allowed`},
+		{line: "X-Ключ: v", err: "contains characters not allowed"},
+		{line: "X-(Comment): v", err: "contains characters not allowed"},
+		{line: "X-Api\"Key: v", err: "contains characters not allowed"},
+		{line: "X-Api: a\r\nX-Injected: b", err: `value of header X-Api contains the control character '\r'`},
+		{line: "X-Api: a\nb", err: `control character '\n'`},
+		{line: "X-Api: a\x00b", err: `control character '\x00'`},
+		{line: "X-Api: a\x7fb", err: `control character '\x7f'`},
+		{line: "Host: internal.example", err: "the Host header cannot be set"},
+		{line: "host: internal.example", err: "use --vhosts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			name, value, err := ParseHeader(tt.line)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ParseHeader(%q) error = %v, want one containing %q", tt.line, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tt.name || value != tt.value {
+				t.Errorf("ParseHeader(%q) = %q, %q, want %q, %q", tt.line, name, value, tt.name, tt.value)
+			}
+		})
+	}
+}
+
+func TestNormalizeHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		headers map[string]string
+		want    map[string]string
+		err     string
+	}{
+		{name: "nil", headers: nil, want: nil},
+		{
+			name:    "canonical names and trimmed values",
+			source:  "config file graphspecter.yaml",
+			headers: map[string]string{"authorization": "Bearer abc  ", "x-API-key": "\tsecret"},
+			want:    map[string]string{"Authorization": "Bearer abc", "X-Api-Key": "secret"},
+		},
+		{
+			name:    "illegal name",
+			source:  "config file graphspecter.yaml",
+			headers: map[string]string{"X Api": "v"},
+			err:     `config file graphspecter.yaml: header name "X Api"`,
+		},
+		{
+			name:    "newline in value",
+			source:  "identity admin",
+			headers: map[string]string{"Cookie": "a=1\r\nSet-Cookie: b=2"},
+			err:     "identity admin: value of header Cookie contains the control character",
+		},
+		{
+			name:    "Host",
+			source:  "ops/me.graphql line 2",
+			headers: map[string]string{"host": "internal.example"},
+			err:     "ops/me.graphql line 2: the Host header cannot be set",
+		},
+		{
+			name:    "names differing in case",
+			source:  "headers",
+			headers: map[string]string{"X-Api-Key": "a", "x-api-key": "b"},
+			err:     "headers: header X-Api-Key is set more than once",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeHeaders(tt.source, tt.headers)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("NormalizeHeaders() error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizeHeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
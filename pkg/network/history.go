@@ -0,0 +1,64 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SentRequest is a GraphQL request sent through the shared client, as passed
+// to the hook of SetRequestHook.
+type SentRequest struct {
+	Time time.Time
+	URL  string
+	// Module is the module of StartModule the request was sent by.
+	Module string
+	// Body is the encoded request, Headers the headers the caller set on it.
+	Body    []byte
+	Headers map[string]string
+	// Status is 0 when no response was received, with Err saying why.
+	Status   int
+	Duration time.Duration
+	Err      error
+}
+
+var (
+	requestHookMu sync.RWMutex
+	requestHook   func(SentRequest)
+)
+
+// SetRequestHook calls hook after every GraphQL request that reached the
+// network, retries included, such as to keep a history of the run. A nil hook
+// removes it. The hook may be called concurrently.
+func SetRequestHook(hook func(SentRequest)) {
+	requestHookMu.Lock()
+	defer requestHookMu.Unlock()
+	requestHook = hook
+}
+
+// recordSent passes a sent request to the request hook.
+func recordSent(ctx context.Context, sent time.Time, url string, body []byte, headers map[string]string, status int, err error) {
+	requestHookMu.RLock()
+	hook := requestHook
+	requestHookMu.RUnlock()
+	if hook == nil {
+		return
+	}
+	hook(SentRequest{
+		Time:     sent,
+		URL:      url,
+		Module:   moduleOf(ctx),
+		Body:     body,
+		Headers:  headers,
+		Status:   status,
+		Duration: time.Since(sent),
+		Err:      err,
+	})
+}
+
+// SendRawWithContext sends body, a GraphQL request encoded by the caller such as
+// a batch of operations, to url. documents are the GraphQL documents body
+// carries, recorded for NonQueryOperations.
+func SendRawWithContext(ctx context.Context, url string, body []byte, headers map[string]string, documents ...string) (map[string]interface{}, error) {
+	return sendEncoded(ctx, url, body, headers, documents...)
+}
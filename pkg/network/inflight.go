@@ -0,0 +1,51 @@
+package network
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InFlightRequest is a GraphQL request sent and not yet answered.
+type InFlightRequest struct {
+	// Module is the module of StartModule the request was sent by.
+	Module   string
+	Endpoint string
+	Started  time.Time
+}
+
+// inFlight holds the requests between trackInFlight and the function it returns.
+var inFlight = struct {
+	mu       sync.Mutex
+	next     uint64
+	requests map[uint64]InFlightRequest
+}{requests: make(map[uint64]InFlightRequest)}
+
+// trackInFlight registers a request to endpoint, sent with ctx, until the
+// returned function is called.
+func trackInFlight(ctx context.Context, endpoint string) func() {
+	req := InFlightRequest{Module: moduleOf(ctx), Endpoint: endpoint, Started: time.Now()}
+	inFlight.mu.Lock()
+	inFlight.next++
+	id := inFlight.next
+	inFlight.requests[id] = req
+	inFlight.mu.Unlock()
+	return func() {
+		inFlight.mu.Lock()
+		delete(inFlight.requests, id)
+		inFlight.mu.Unlock()
+	}
+}
+
+// InFlight returns the requests in flight, the oldest first.
+func InFlight() []InFlightRequest {
+	inFlight.mu.Lock()
+	requests := make([]InFlightRequest, 0, len(inFlight.requests))
+	for _, req := range inFlight.requests {
+		requests = append(requests, req)
+	}
+	inFlight.mu.Unlock()
+	sort.Slice(requests, func(i, j int) bool { return requests[i].Started.Before(requests[j].Started) })
+	return requests
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestInFlightConcurrentRequests holds the requests of three modules at once
+// and checks that the registry lists each with its module until it is
+// answered.
+func TestInFlightConcurrentRequests(t *testing.T) {
+	const modules, perModule = 3, 4
+	release := make(chan struct{})
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer srv.Close()
+	defer unblock()
+
+	var wg sync.WaitGroup
+	for m := 0; m < modules; m++ {
+		name := string(rune('a' + m))
+		ctx, stop := StartModule(context.Background(), "module-"+name)
+		defer stop()
+		for i := 0; i < perModule; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				SendGraphQLRequestWithContext(ctx, srv.URL+"/"+name, "{ __typename }", nil, nil)
+			}()
+		}
+	}
+
+	var pending []InFlightRequest
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if pending = InFlight(); len(pending) == modules*perModule {
+			break
+		}
+	}
+	if len(pending) != modules*perModule {
+		t.Fatalf("%d requests in flight, want %d", len(pending), modules*perModule)
+	}
+	count := make(map[string]int)
+	for i, req := range pending {
+		// Each request is attributed to the module of its context, whatever
+		// the other goroutines started and stopped.
+		if want := srv.URL + "/" + strings.TrimPrefix(req.Module, "module-"); req.Endpoint != want {
+			t.Errorf("%s sent to %s, want %s", req.Module, req.Endpoint, want)
+		}
+		if i > 0 && req.Started.Before(pending[i-1].Started) {
+			t.Errorf("request %d started before request %d; want the oldest first", i, i-1)
+		}
+		count[req.Module]++
+	}
+	for m := 0; m < modules; m++ {
+		if name := "module-" + string(rune('a'+m)); count[name] != perModule {
+			t.Errorf("%d requests of %s in flight, want %d", count[name], name, perModule)
+		}
+	}
+
+	unblock()
+	wg.Wait()
+	if pending := InFlight(); len(pending) != 0 {
+		t.Errorf("%d requests still listed after their responses: %+v", len(pending), pending)
+	}
+}
+
+func TestInFlightFailedRequest(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	if _, err := SendGraphQLRequestWithContext(context.Background(), srv.URL, "{ __typename }", nil, nil); err == nil {
+		t.Fatal("the request to a closed server succeeded")
+	}
+	if pending := InFlight(); len(pending) != 0 {
+		t.Errorf("a failed request is still listed: %+v", pending)
+	}
+}
@@ -0,0 +1,58 @@
+package network
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+)
+
+// offline is set by SetOffline.
+var offline atomic.Bool
+
+// SetOffline makes every request of the shared client and every WebSocket dial
+// fail with gerrors.ErrOfflineMode instead of touching the network.
+func SetOffline(enabled bool) {
+	offline.Store(enabled)
+}
+
+// Offline reports whether offline mode is enabled.
+func Offline() bool {
+	return offline.Load()
+}
+
+// offlineTransport refuses requests in offline mode and hands the others to
+// next, or to http.DefaultTransport when next is nil. It guards the requests
+// sent through Client() as well as the GraphQL ones.
+type offlineTransport struct {
+	next http.RoundTripper
+}
+
+func (t offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if Offline() {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, gerrors.ErrOfflineMode
+	}
+	if t.next == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// DialContext dials addr, following SetResolution, unless offline mode is
+// enabled or its host is out of scope. WebSocket dialers use it as their
+// NetDialContext.
+func DialContext(ctx context.Context, netw, addr string) (net.Conn, error) {
+	if Offline() {
+		return nil, gerrors.ErrOfflineMode
+	}
+	if !InScope(addr) {
+		return nil, suppress(&url.URL{Host: addr})
+	}
+	return dial(ctx, netw, addr)
+}
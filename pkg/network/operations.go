@@ -0,0 +1,109 @@
+package network
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// unparsedKind finds the operation keyword of documents the parser rejects, such
+// as the malformed queries sent by fuzzing checks.
+var unparsedKind = regexp.MustCompile(`(?:^|[\s}])(mutation|subscription)\b`)
+
+var (
+	operationsMu sync.Mutex
+	// operationKinds counts every operation sent, by kind.
+	operationKinds = make(map[string]int64)
+	// nonQuery counts the mutations and subscriptions sent.
+	nonQuery = make(map[operationKey]int64)
+)
+
+// operationKey identifies an operation sent to an endpoint.
+type operationKey struct {
+	endpoint, kind, name string
+}
+
+// ClassifyDocument returns the kind and name of each operation of a GraphQL
+// document. Documents that do not parse are classified by their keywords, as a
+// single unnamed operation.
+func ClassifyDocument(doc string) []types.SentOperation {
+	parsed, err := gql.Parse(doc)
+	if err != nil || len(parsed.Operations) == 0 {
+		kind := gql.OperationQuery
+		if m := unparsedKind.FindStringSubmatch(doc); m != nil {
+			kind = m[1]
+		}
+		return []types.SentOperation{{Kind: kind}}
+	}
+	ops := make([]types.SentOperation, 0, len(parsed.Operations))
+	for _, op := range parsed.Operations {
+		ops = append(ops, types.SentOperation{Kind: op.Kind, Name: op.Name})
+	}
+	return ops
+}
+
+// RecordOperations accounts for the operations of documents sent to endpoint.
+// Requests through the shared client are recorded automatically; transports
+// with their own connections, such as WebSockets, call it themselves. Every
+// operation of a document counts, since the operation the server executes
+// depends on operationName.
+func RecordOperations(endpoint string, documents ...string) {
+	operationsMu.Lock()
+	defer operationsMu.Unlock()
+	for _, doc := range documents {
+		if doc == "" {
+			continue
+		}
+		for _, op := range ClassifyDocument(doc) {
+			operationKinds[op.Kind]++
+			if op.Kind != gql.OperationQuery {
+				nonQuery[operationKey{endpoint, op.Kind, op.Name}]++
+			}
+		}
+	}
+}
+
+// NonQueryOperations returns the mutations and subscriptions sent during the run,
+// sorted by endpoint, kind and name.
+func NonQueryOperations() []types.SentOperation {
+	operationsMu.Lock()
+	ops := make([]types.SentOperation, 0, len(nonQuery))
+	for key, n := range nonQuery {
+		ops = append(ops, types.SentOperation{Endpoint: key.endpoint, Kind: key.kind, Name: key.name, Count: n})
+	}
+	operationsMu.Unlock()
+
+	sort.Slice(ops, func(i, j int) bool {
+		a, b := ops[i], ops[j]
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return ops
+}
+
+// resetOperations forgets the operations sent so far.
+func resetOperations() {
+	operationsMu.Lock()
+	defer operationsMu.Unlock()
+	operationKinds = make(map[string]int64)
+	nonQuery = make(map[operationKey]int64)
+}
+
+// operationCounts returns a copy of the operations sent by kind.
+func operationCounts() map[string]int64 {
+	operationsMu.Lock()
+	defer operationsMu.Unlock()
+	counts := make(map[string]int64, len(operationKinds))
+	for kind, n := range operationKinds {
+		counts[kind] = n
+	}
+	return counts
+}
@@ -0,0 +1,157 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// RequestPreview is the wire form of a GraphQL request and the transport
+// settings it would be sent with, as built by the code that sends it.
+type RequestPreview struct {
+	Method string
+	URL    string
+	// Headers are the final headers, one value per name, as they leave the
+	// transport chain: with the Host header, the virtual host when one is
+	// set, the required headers of the scope and the signature of a
+	// RequestSigner.
+	Headers  map[string]string
+	Body     string
+	Encoding Encoding
+	// Proxy is the proxy URL taken from the environment, empty for a direct
+	// connection.
+	Proxy       string
+	VirtualHost string
+	// Connect is the address the host is pinned to by SetResolution, if any.
+	Connect           string
+	ClientCertificate bool
+	Signed            bool
+	// Timeout is the client timeout, or what is left of the deadline of the
+	// context when that comes first.
+	Timeout time.Duration
+	// Retries is how many times a rate-limited request is sent again.
+	Retries int
+}
+
+// HeaderNames returns the names of p.Headers in sorted order.
+func (p RequestPreview) HeaderNames() []string {
+	names := make([]string, 0, len(p.Headers))
+	for k := range p.Headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PreviewGraphQLRequest returns the request SendGraphQLRequestWithContext would
+// send, without sending it.
+func PreviewGraphQLRequest(ctx context.Context, url, query string, variables map[string]interface{}, headers map[string]string) (RequestPreview, error) {
+	jsonData, err := json.Marshal(types.GraphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return RequestPreview{}, fmt.Errorf("error marshalling request: %w", err)
+	}
+	return preview(ctx, url, jsonData, headers)
+}
+
+// PreviewWithStrategy returns the request SendWithStrategy would send, without
+// sending it.
+func PreviewWithStrategy(ctx context.Context, target string, s Strategy, query string, variables map[string]interface{}, headers map[string]string) (RequestPreview, error) {
+	requestURL, jsonData, err := s.Build(target, query, variables)
+	if err != nil {
+		return RequestPreview{}, err
+	}
+	return preview(ctx, requestURL, jsonData, headers)
+}
+
+// preview builds the request of jsonData with newGraphQLRequest, as sendOnce
+// does, and passes it through previewChain to capture it as it would be sent.
+func preview(ctx context.Context, url string, jsonData []byte, headers map[string]string) (RequestPreview, error) {
+	req, _, encoding, err := newGraphQLRequest(ctx, url, jsonData, headers)
+	if err != nil {
+		return RequestPreview{}, err
+	}
+	capture := &captureTransport{}
+	resp, err := previewChain(capture).RoundTrip(req)
+	if err != nil {
+		return RequestPreview{}, err
+	}
+	resp.Body.Close()
+	wire := capture.req
+	p := RequestPreview{
+		Method:      wire.Method,
+		URL:         wire.URL.String(),
+		Headers:     make(map[string]string, len(wire.Header)+1),
+		Body:        string(capture.body),
+		Encoding:    encoding,
+		VirtualHost: VirtualHost(ctx),
+		Timeout:     httpClient.Timeout,
+		Retries:     maxRateLimitRetries,
+	}
+	for k, v := range wire.Header {
+		p.Headers[k] = strings.Join(v, ", ")
+	}
+	p.Headers["Host"] = wire.URL.Host
+	if wire.Host != "" {
+		p.Headers["Host"] = wire.Host
+	}
+	if proxy, err := http.ProxyFromEnvironment(wire); err == nil && proxy != nil {
+		p.Proxy = proxy.Redacted()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline).Round(time.Second); left < p.Timeout {
+			p.Timeout = left
+		}
+	}
+	if cfg := TLSConfig(); cfg != nil && len(cfg.Certificates) > 0 {
+		p.ClientCertificate = true
+	}
+	if _, ok := currentSigner().(noopSigner); !ok {
+		p.Signed = true
+	}
+	resolveMu.RLock()
+	pinned, ok := resolution.Pins[net.JoinHostPort(strings.ToLower(wire.URL.Hostname()), requestPort(wire))]
+	resolveMu.RUnlock()
+	if ok {
+		p.Connect = pinned
+	}
+	return p, nil
+}
+
+// captureTransport keeps the request it is given and its body instead of
+// sending it, and answers with an empty response.
+type captureTransport struct {
+	req  *http.Request
+	body []byte
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading request body: %w", err)
+		}
+		t.body = body
+	}
+	t.req = req
+	return &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header), Body: http.NoBody, Request: req}, nil
+}
+
+// requestPort returns the port req is sent to, explicit or implied by its scheme.
+func requestPort(req *http.Request) string {
+	if p := req.URL.Port(); p != "" {
+		return p
+	}
+	if req.URL.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
@@ -0,0 +1,112 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// wireRecorder is a mock GraphQL server that records the last request it
+// received.
+type wireRecorder struct {
+	body    string
+	uri     string
+	host    string
+	headers http.Header
+}
+
+func (rec *wireRecorder) server() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.body, rec.uri, rec.host, rec.headers = string(body), r.URL.RequestURI(), r.Host, r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+}
+
+func TestPreviewMatchesTheWire(t *testing.T) {
+	rec := &wireRecorder{}
+	srv := rec.server()
+	defer srv.Close()
+
+	signer := &digestSigner{}
+	SetSigner(signer)
+	defer SetSigner(nil)
+	SetRequiredHeaders(map[string]string{"X-Bug-Bounty": "researcher"})
+	defer SetRequiredHeaders(nil)
+
+	ctx := WithVirtualHost(context.Background(), "internal.example")
+	query := `query Me($id: ID!) { user(id: $id) { name } }`
+	variables := map[string]interface{}{"id": "1", "filter": map[string]interface{}{"q": "<a & b>"}}
+	headers := map[string]string{"X-Custom": "value", "Authorization": "Bearer token"}
+
+	p, err := PreviewGraphQLRequest(ctx, srv.URL, query, variables, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.body != "" {
+		t.Fatalf("the preview reached the server with %q", rec.body)
+	}
+	if _, err := SendGraphQLRequestWithContext(ctx, srv.URL, query, variables, headers); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Body != rec.body {
+		t.Errorf("previewed body\n%q\nserver received\n%q", p.Body, rec.body)
+	}
+	if p.Headers["Host"] != "internal.example" || rec.host != "internal.example" {
+		t.Errorf("Host previewed %q, received %q, want the virtual host", p.Headers["Host"], rec.host)
+	}
+	for _, name := range []string{"X-Signature", "X-Bug-Bounty", "X-Custom", "Authorization", "Content-Type", "User-Agent"} {
+		if got, want := p.Headers[name], rec.headers.Get(name); got == "" || got != want {
+			t.Errorf("%s previewed %q, received %q", name, got, want)
+		}
+	}
+	if !p.Signed || p.VirtualHost != "internal.example" {
+		t.Errorf("preview Signed = %v, VirtualHost = %q", p.Signed, p.VirtualHost)
+	}
+}
+
+func TestPreviewWithStrategyMatchesTheWire(t *testing.T) {
+	rec := &wireRecorder{}
+	srv := rec.server()
+	defer srv.Close()
+
+	query := `{ __typename }`
+	strategy := Strategy{Param: "doc", InURL: true}
+	p, err := PreviewWithStrategy(context.Background(), srv.URL, strategy, query, map[string]interface{}{"a": 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SendWithStrategy(context.Background(), srv.URL, strategy, query, map[string]interface{}{"a": 1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if p.Body != rec.body {
+		t.Errorf("previewed body\n%q\nserver received\n%q", p.Body, rec.body)
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.RequestURI() != rec.uri || u.Query().Get("doc") != query {
+		t.Errorf("previewed URL %s, server received %s", u.RequestURI(), rec.uri)
+	}
+	if p.Headers["Host"] != u.Host {
+		t.Errorf("Host previewed %q, want %q", p.Headers["Host"], u.Host)
+	}
+}
+
+func TestPreviewOfOutOfScopeRequestFails(t *testing.T) {
+	rec := &wireRecorder{}
+	srv := rec.server()
+	defer srv.Close()
+
+	SetScope([]string{"api.example.com"})
+	defer SetScope(nil)
+	if _, err := PreviewGraphQLRequest(context.Background(), srv.URL, "{ __typename }", nil, nil); err == nil {
+		t.Error("previewing an out-of-scope request succeeded, a real send is suppressed")
+	}
+}
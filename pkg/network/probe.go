@@ -0,0 +1,80 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+)
+
+// ProbeResponse is the answer to ProbeRequest.
+type ProbeResponse struct {
+	Status      int
+	ContentType string
+	// Body is the decompressed body, decoded to UTF-8.
+	Body []byte
+}
+
+// ProbeRequest sends a request the GraphQL client does not, such as a GET with
+// the query in the URL or a batch whose answer is a JSON array, with the
+// headers, scope, session and rate limit of the shared client. body may be
+// nil; contentType is then not sent. documents are the GraphQL documents the
+// request carries, recorded for NonQueryOperations.
+func ProbeRequest(ctx context.Context, method, url, contentType string, body []byte, headers map[string]string, documents ...string) (*ProbeResponse, error) {
+	if Offline() {
+		return nil, fmt.Errorf("%w: not sending request to %s", gerrors.ErrOfflineMode, url)
+	}
+	if err := checkSend(url); err != nil {
+		return nil, err
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if body == nil {
+		req.Header.Del("Content-Type")
+	} else {
+		req.Header.Set("Content-Type", contentType)
+	}
+	ApplySession(req)
+	if err := requestLimiter.wait(ctx); err != nil {
+		return nil, fmt.Errorf("waiting for rate limit: %w", gerrors.Interrupted(ctx, err))
+	}
+	logger.Debug("→ %s %s", method, url)
+	runStats.requests.Add(1)
+	runStats.bytesSent.Add(int64(len(body)))
+	RecordOperations(url, documents...)
+	sent := time.Now()
+	defer trackInFlight(ctx, url)()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		recordSent(ctx, sent, url, body, headers, 0, err)
+		return nil, fmt.Errorf("error sending request: %w", gerrors.Interrupted(ctx, err))
+	}
+	defer resp.Body.Close()
+	runStats.recordStatus(resp.StatusCode)
+	recordSent(ctx, sent, url, body, headers, resp.StatusCode, nil)
+
+	data, wire, err := readBody(url, resp)
+	runStats.bytesReceived.Add(wire)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", gerrors.Interrupted(ctx, err))
+	}
+	contentType = resp.Header.Get("Content-Type")
+	return &ProbeResponse{Status: resp.StatusCode, ContentType: contentType, Body: DecodeBody(data, contentType)}, nil
+}
@@ -0,0 +1,249 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/clock"
+	"github.com/CyberRoute/graphspecter/pkg/data"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+)
+
+const (
+	// maxRateLimitRetries is how many times a rate-limited request is sent again
+	maxRateLimitRetries = 2
+
+	// defaultRateLimitPause is used when the server does not say how long to wait
+	defaultRateLimitPause = 1 * time.Second
+
+	// maxRateLimitPause caps the pause a server can impose through Retry-After
+	maxRateLimitPause = 60 * time.Second
+
+	// maxEvidenceBytes bounds the response body kept as rate-limit evidence
+	maxEvidenceBytes = 512
+)
+
+// limiter is a token bucket shared by every request sent through this package.
+type limiter struct {
+	mu          sync.Mutex
+	rate        float64 // tokens per second, 0 disables limiting
+	requested   float64 // rate set by SetRateLimit
+	max         float64 // cap set by SetRateCap, 0 for none
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+var requestLimiter = &limiter{}
+
+// SetRateLimit caps outgoing requests to rps requests per second. A value of 0
+// disables the limit, within the cap of SetRateCap.
+func SetRateLimit(rps float64) {
+	requestLimiter.mu.Lock()
+	defer requestLimiter.mu.Unlock()
+	requestLimiter.requested = rps
+	requestLimiter.reset()
+}
+
+// SetRateCap bounds the rate SetRateLimit sets, now and later, to max requests
+// per second, so that no module can send faster. A value of 0 removes the cap.
+func SetRateCap(max float64) {
+	requestLimiter.mu.Lock()
+	defer requestLimiter.mu.Unlock()
+	requestLimiter.max = max
+	requestLimiter.reset()
+}
+
+// RateLimit returns the rate requests are limited to, 0 when unlimited.
+func RateLimit() float64 {
+	requestLimiter.mu.Lock()
+	defer requestLimiter.mu.Unlock()
+	return requestLimiter.rate
+}
+
+// reset applies the requested rate within the cap and refills the bucket.
+// l.mu must be held.
+func (l *limiter) reset() {
+	l.rate = l.requested
+	if l.max > 0 && (l.rate == 0 || l.rate > l.max) {
+		l.rate = l.max
+	}
+	l.tokens = 1
+	l.last = time.Now()
+}
+
+// reserve takes a token and returns how long the caller has to wait before using it.
+func (l *limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var delay time.Duration
+	if l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > 1 {
+			l.tokens = 1
+		}
+		l.last = now
+		l.tokens--
+		if l.tokens < 0 {
+			delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+		}
+	}
+	if paused := l.pausedUntil.Sub(now); paused > delay {
+		delay = paused
+	}
+	return delay
+}
+
+// pause stops all requests from being sent for d.
+func (l *limiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// wait blocks until the limiter allows another request or ctx is done.
+func (l *limiter) wait(ctx context.Context) error {
+	delay := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	runStats.rateLimitWaits.Add(1)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ParseRetryAfter parses a Retry-After header in either delay-seconds or HTTP-date form.
+// It returns false when the header is missing or malformed.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// isRateLimitedResult reports whether a GraphQL response carries an error of the
+// rate-limit class of the error-patterns dataset.
+func isRateLimitedResult(result map[string]interface{}) bool {
+	return gql.HasErrorClass(result, data.ErrorRateLimit)
+}
+
+// RateLimitEvent records a response in which the server throttled the client.
+type RateLimitEvent struct {
+	URL          string
+	StatusCode   int
+	RetryAfter   time.Duration
+	ObservedRate float64 // requests per second sent just before the event
+	Evidence     string
+	Time         time.Time
+}
+
+var (
+	rateLimitMu     sync.Mutex
+	rateLimitEvents []RateLimitEvent
+)
+
+// handleRateLimit pauses the shared limiter and records the event.
+func handleRateLimit(url string, status int, wait time.Duration, body []byte) {
+	if wait <= 0 {
+		wait = defaultRateLimitPause
+	}
+	if wait > maxRateLimitPause {
+		wait = maxRateLimitPause
+	}
+	evidence := string(body)
+	if len(evidence) > maxEvidenceBytes {
+		evidence = evidence[:maxEvidenceBytes] + "..."
+	}
+
+	event := RateLimitEvent{
+		URL:          url,
+		StatusCode:   status,
+		RetryAfter:   wait,
+		ObservedRate: recentRequests.rate(),
+		Evidence:     evidence,
+		Time:         clock.Now(),
+	}
+	rateLimitMu.Lock()
+	rateLimitEvents = append(rateLimitEvents, event)
+	rateLimitMu.Unlock()
+
+	logger.Warn("Rate limited by %s (HTTP %d), pausing requests for %s", url, status, wait)
+	requestLimiter.pause(wait)
+}
+
+// RateLimitEvents returns every rate-limit response observed since the stats
+// were last reset.
+func RateLimitEvents() []RateLimitEvent {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	return append([]RateLimitEvent(nil), rateLimitEvents...)
+}
+
+// resetRateLimitEvents forgets the rate-limit responses observed.
+func resetRateLimitEvents() {
+	rateLimitMu.Lock()
+	rateLimitEvents = nil
+	rateLimitMu.Unlock()
+}
+
+// requestWindow keeps the send times of the most recent requests to estimate the request rate.
+type requestWindow struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+// requestWindowSize is the number of send times kept by requestWindow
+const requestWindowSize = 50
+
+var recentRequests = &requestWindow{}
+
+func (w *requestWindow) add(t time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.times = append(w.times, t)
+	if len(w.times) > requestWindowSize {
+		w.times = w.times[len(w.times)-requestWindowSize:]
+	}
+}
+
+// rate returns the requests per second over the kept window.
+func (w *requestWindow) rate() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.times) < 2 {
+		return 0
+	}
+	span := w.times[len(w.times)-1].Sub(w.times[0]).Seconds()
+	if span <= 0 {
+		return 0
+	}
+	return float64(len(w.times)-1) / span
+}
@@ -0,0 +1,146 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolution decides the addresses connections are made to.
+type Resolution struct {
+	// Pins map "host:port" to the address dialed instead of resolving host,
+	// as curl --resolve does.
+	Pins map[string]string
+	// DNSServer is the "host:port" of the DNS server resolving the hosts
+	// that are not pinned; the system resolver is used when empty.
+	DNSServer string
+	// IPVersion restricts connections to IPv4 (4) or IPv6 (6); 0 allows both.
+	IPVersion int
+}
+
+var (
+	resolveMu  sync.RWMutex
+	resolution Resolution
+)
+
+// ParsePin parses a curl-style "host:port:addr" resolution. addr is an IPv4
+// or IPv6 address, the latter optionally in brackets.
+func ParsePin(pin string) (hostPort, addr string, err error) {
+	host, rest, ok := strings.Cut(pin, ":")
+	port, ip, ok2 := strings.Cut(rest, ":")
+	if !ok || !ok2 || host == "" {
+		return "", "", fmt.Errorf("resolution %q must be host:port:addr", pin)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", "", fmt.Errorf("resolution %q: invalid port %q", pin, port)
+	}
+	ip = strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")
+	if net.ParseIP(ip) == nil {
+		return "", "", fmt.Errorf("resolution %q: %q is not an IP address", pin, ip)
+	}
+	return net.JoinHostPort(strings.ToLower(host), port), ip, nil
+}
+
+// SetResolution makes every connection, HTTP and WebSocket alike, follow r.
+// Pinned hosts are never looked up.
+func SetResolution(r Resolution) error {
+	if r.IPVersion != 0 && r.IPVersion != 4 && r.IPVersion != 6 {
+		return fmt.Errorf("IP version must be 4 or 6, got %d", r.IPVersion)
+	}
+	if r.DNSServer != "" {
+		if _, _, err := net.SplitHostPort(r.DNSServer); err != nil {
+			return fmt.Errorf("DNS server %q must be host:port: %w", r.DNSServer, err)
+		}
+	}
+	for hostPort, addr := range r.Pins {
+		if err := checkFamily(net.ParseIP(addr), r.IPVersion); err != nil {
+			return fmt.Errorf("resolution of %s: %w", hostPort, err)
+		}
+	}
+
+	resolveMu.Lock()
+	resolution = r
+	resolveMu.Unlock()
+	rebuildTransport()
+	return nil
+}
+
+// checkFamily fails when ip is not of the IP version, if any.
+func checkFamily(ip net.IP, version int) error {
+	switch {
+	case version == 4 && ip.To4() == nil:
+		return fmt.Errorf("%s is not an IPv4 address", ip)
+	case version == 6 && ip.To4() != nil:
+		return fmt.Errorf("%s is not an IPv6 address", ip)
+	}
+	return nil
+}
+
+// dial connects to addr following the resolution set by SetResolution:
+// pinned hosts are dialed at their address, other hosts are resolved through
+// the DNS server, and only addresses of the IP version are tried.
+func dial(ctx context.Context, netw, addr string) (net.Conn, error) {
+	resolveMu.RLock()
+	r := resolution
+	resolveMu.RUnlock()
+
+	d := net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if len(r.Pins) == 0 && r.DNSServer == "" && r.IPVersion == 0 {
+		return d.DialContext(ctx, netw, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if r.IPVersion != 0 {
+		netw = fmt.Sprintf("tcp%d", r.IPVersion)
+	}
+	if pinned, ok := r.Pins[net.JoinHostPort(strings.ToLower(host), port)]; ok {
+		return d.DialContext(ctx, netw, net.JoinHostPort(pinned, port))
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if err := checkFamily(ip, r.IPVersion); err != nil {
+			return nil, fmt.Errorf("cannot connect to %s: %w", addr, err)
+		}
+		return d.DialContext(ctx, netw, addr)
+	}
+
+	resolver := net.DefaultResolver
+	if r.DNSServer != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, netw, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, netw, r.DNSServer)
+			},
+		}
+	}
+	family := "ip"
+	if r.IPVersion != 0 {
+		family = fmt.Sprintf("ip%d", r.IPVersion)
+	}
+	ips, err := resolver.LookupIP(ctx, family, host)
+	if r.IPVersion != 0 && ctx.Err() == nil && (err != nil || len(ips) == 0) {
+		// Tell a host of the other family from one that does not exist.
+		if others, _ := resolver.LookupIP(ctx, "ip", host); len(others) > 0 {
+			return nil, fmt.Errorf("%s has no IPv%d address, only %s", host, r.IPVersion, others[0])
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no address found for %s", host)
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = d.DialContext(ctx, netw, net.JoinHostPort(ip.String(), port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
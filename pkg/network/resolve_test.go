@@ -0,0 +1,299 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// dnsStub answers the A and AAAA queries it receives over UDP from records,
+// which map a name to its addresses, and NXDOMAIN for the names it lacks. It
+// returns its address and the "name type" queries it received.
+func dnsStub(t *testing.T, records map[string][]string) (string, func() []string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	var mu sync.Mutex
+	var queries []string
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			name, qtype, end, ok := parseQuestion(buf[:n])
+			if !ok {
+				continue
+			}
+			mu.Lock()
+			queries = append(queries, name+" "+map[uint16]string{1: "A", 28: "AAAA"}[qtype])
+			mu.Unlock()
+			conn.WriteTo(dnsAnswer(buf[:end], name, qtype, records), from)
+		}
+	}()
+	return conn.LocalAddr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), queries...)
+	}
+}
+
+// parseQuestion returns the lowercased name and the type of the first
+// question of a DNS query, and where the question ends.
+func parseQuestion(msg []byte) (name string, qtype uint16, end int, ok bool) {
+	var labels []string
+	i := 12
+	for i < len(msg) && msg[i] != 0 {
+		l := int(msg[i])
+		if i+1+l > len(msg) {
+			return "", 0, 0, false
+		}
+		labels = append(labels, strings.ToLower(string(msg[i+1:i+1+l])))
+		i += 1 + l
+	}
+	if i+5 > len(msg) {
+		return "", 0, 0, false
+	}
+	return strings.Join(labels, "."), binary.BigEndian.Uint16(msg[i+1:]), i + 5, true
+}
+
+// dnsAnswer builds the response to query, the header and question of a DNS
+// message, from records.
+func dnsAnswer(query []byte, name string, qtype uint16, records map[string][]string) []byte {
+	addrs, known := records[name]
+	var answers [][]byte
+	for _, a := range addrs {
+		ip := net.ParseIP(a)
+		rdata := ip.To4()
+		if qtype == 28 && rdata == nil {
+			rdata = ip.To16()
+		} else if qtype != 1 {
+			rdata = nil
+		}
+		if rdata == nil {
+			continue
+		}
+		rr := []byte{0xc0, 12} // the name of the question
+		rr = binary.BigEndian.AppendUint16(rr, qtype)
+		rr = binary.BigEndian.AppendUint16(rr, 1)
+		rr = binary.BigEndian.AppendUint32(rr, 60)
+		rr = binary.BigEndian.AppendUint16(rr, uint16(len(rdata)))
+		answers = append(answers, append(rr, rdata...))
+	}
+	resp := append([]byte(nil), query...)
+	flags := uint16(0x8180) // a response, recursion desired and available
+	if !known {
+		flags |= 3 // NXDOMAIN
+	}
+	binary.BigEndian.PutUint16(resp[2:], flags)
+	binary.BigEndian.PutUint16(resp[4:], 1)
+	binary.BigEndian.PutUint16(resp[6:], uint16(len(answers)))
+	binary.BigEndian.PutUint32(resp[8:], 0)
+	for _, rr := range answers {
+		resp = append(resp, rr...)
+	}
+	return resp
+}
+
+// dualStackServer serves HTTP on the same port of 127.0.0.1 and, when the
+// host has IPv6, of ::1, answering with the address it was reached at. It
+// returns the port and whether ::1 is served.
+func dualStackServer(t *testing.T) (port string, ipv6 bool) {
+	t.Helper()
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"addr":"` + addr.String() + `"}}`))
+	})}
+	t.Cleanup(func() { srv.Close() })
+	for attempt := 0; attempt < 10; attempt++ {
+		l4, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, port, _ = net.SplitHostPort(l4.Addr().String())
+		l6, err := net.Listen("tcp6", net.JoinHostPort("::1", port))
+		if errors.Is(err, syscall.EADDRINUSE) {
+			l4.Close()
+			continue
+		}
+		go srv.Serve(l4)
+		if err != nil {
+			t.Logf("no IPv6 loopback (%v); IPv6 connections are not checked", err)
+			return port, false
+		}
+		go srv.Serve(l6)
+		return port, true
+	}
+	t.Fatal("no port free on both loopback addresses")
+	return "", false
+}
+
+// useResolution sets the resolution for the test.
+func useResolution(t *testing.T, r Resolution) {
+	t.Helper()
+	if err := SetResolution(r); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetResolution(Resolution{}) })
+}
+
+// dialed returns the address a connection to addr was made to.
+func dialed(t *testing.T, addr string) string {
+	t.Helper()
+	conn, err := DialContext(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("DialContext(%s) = %v", addr, err)
+	}
+	defer conn.Close()
+	return conn.RemoteAddr().String()
+}
+
+func TestParsePin(t *testing.T) {
+	for pin, want := range map[string][2]string{
+		"API.example.com:443:10.0.0.1": {"api.example.com:443", "10.0.0.1"},
+		"api.example.com:8443:[::1]":   {"api.example.com:8443", "::1"},
+		"api.example.com:443:fe80::1":  {"api.example.com:443", "fe80::1"},
+	} {
+		if hostPort, addr, err := ParsePin(pin); err != nil || hostPort != want[0] || addr != want[1] {
+			t.Errorf("ParsePin(%q) = %q, %q, %v; want %q", pin, hostPort, addr, err, want)
+		}
+	}
+	for pin, want := range map[string]string{
+		"api.example.com:443":           "must be host:port:addr",
+		":443:10.0.0.1":                 "must be host:port:addr",
+		"api.example.com:0:10.0.0.1":    "invalid port",
+		"api.example.com:https:1.2.3.4": "invalid port",
+		"api.example.com:443:gateway":   "not an IP address",
+	} {
+		if _, _, err := ParsePin(pin); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParsePin(%q) = %v, want %q", pin, err, want)
+		}
+	}
+}
+
+func TestSetResolutionErrors(t *testing.T) {
+	t.Cleanup(func() { SetResolution(Resolution{}) })
+	for _, tt := range []struct {
+		r    Resolution
+		want string
+	}{
+		{Resolution{IPVersion: 5}, "IP version must be 4 or 6"},
+		{Resolution{DNSServer: "127.0.0.1"}, "must be host:port"},
+		{Resolution{IPVersion: 4, Pins: map[string]string{"api.test:443": "::1"}}, "resolution of api.test:443: ::1 is not an IPv4 address"},
+		{Resolution{IPVersion: 6, Pins: map[string]string{"api.test:443": "127.0.0.1"}}, "127.0.0.1 is not an IPv6 address"},
+	} {
+		if err := SetResolution(tt.r); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("SetResolution(%+v) = %v, want %q", tt.r, err, tt.want)
+		}
+	}
+}
+
+// TestResolution dials names pinned and resolved by a local DNS stub, and
+// checks the address each connection reached.
+func TestResolution(t *testing.T) {
+	port, ipv6 := dualStackServer(t)
+	dns, queries := dnsStub(t, map[string][]string{
+		"v4.test":   {"127.0.0.1"},
+		"dual.test": {"127.0.0.1", "::1"},
+		"v6.test":   {"::1"},
+		"api.test":  {"192.0.2.1"},
+	})
+	v4 := net.JoinHostPort("127.0.0.1", port)
+	v6 := net.JoinHostPort("::1", port)
+
+	t.Run("pins and DNS server", func(t *testing.T) {
+		useResolution(t, Resolution{DNSServer: dns, Pins: map[string]string{"api.test:" + port: "127.0.0.1"}})
+		// The pin wins over the address the DNS server has for the name.
+		if got := dialed(t, "API.test:"+port); got != v4 {
+			t.Errorf("the pinned name dialed %s, want %s", got, v4)
+		}
+		if got := dialed(t, "v4.test:"+port); got != v4 {
+			t.Errorf("v4.test dialed %s, want %s", got, v4)
+		}
+		for _, q := range queries() {
+			if strings.HasPrefix(q, "api.test ") {
+				t.Errorf("the pinned name was looked up: %q", queries())
+			}
+		}
+		if !contains(queries(), "v4.test A") {
+			t.Errorf("queries %q, want v4.test resolved by the stub", queries())
+		}
+
+		// The shared client dials the same way.
+		resp, err := SendGraphQLRequestWithContext(context.Background(), "http://v4.test:"+port+"/graphql", "{ addr }", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr := resp["data"].(map[string]interface{})["addr"]; addr != v4 {
+			t.Errorf("the request reached %v, want %s", addr, v4)
+		}
+		if _, err := DialContext(context.Background(), "tcp", "missing.test:"+port); err == nil {
+			t.Error("a name the DNS server does not know was dialed")
+		}
+	})
+
+	t.Run("IPv4 only", func(t *testing.T) {
+		useResolution(t, Resolution{DNSServer: dns, IPVersion: 4})
+		if got := dialed(t, "dual.test:"+port); got != v4 {
+			t.Errorf("dual.test dialed %s, want %s", got, v4)
+		}
+		_, err := DialContext(context.Background(), "tcp", "v6.test:"+port)
+		if err == nil || err.Error() != "v6.test has no IPv4 address, only ::1" {
+			t.Errorf("dialing an IPv6-only name = %v", err)
+		}
+		_, err = DialContext(context.Background(), "tcp", v6)
+		if err == nil || !strings.Contains(err.Error(), "::1 is not an IPv4 address") {
+			t.Errorf("dialing an IPv6 literal = %v", err)
+		}
+	})
+
+	t.Run("IPv6 only", func(t *testing.T) {
+		useResolution(t, Resolution{DNSServer: dns, IPVersion: 6, Pins: map[string]string{"pinned.test:" + port: "::1"}})
+		_, err := DialContext(context.Background(), "tcp", "v4.test:"+port)
+		if err == nil || err.Error() != "v4.test has no IPv6 address, only 127.0.0.1" {
+			t.Errorf("dialing an IPv4-only name = %v", err)
+		}
+		_, err = DialContext(context.Background(), "tcp", v4)
+		if err == nil || !strings.Contains(err.Error(), "127.0.0.1 is not an IPv6 address") {
+			t.Errorf("dialing an IPv4 literal = %v", err)
+		}
+		if !ipv6 {
+			return
+		}
+		if got := dialed(t, "dual.test:"+port); got != v6 {
+			t.Errorf("dual.test dialed %s, want %s", got, v6)
+		}
+		if got := dialed(t, "pinned.test:"+port); got != v6 {
+			t.Errorf("the name pinned to ::1 dialed %s, want %s", got, v6)
+		}
+		if !contains(queries(), "dual.test AAAA") {
+			t.Errorf("queries %q, want the AAAA record of dual.test", queries())
+		}
+	})
+
+	// Without a resolution, IP literals are dialed as they are.
+	if got := dialed(t, v4); got != v4 {
+		t.Errorf("dialed %s, want %s", got, v4)
+	}
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
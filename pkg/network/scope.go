@@ -0,0 +1,245 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// The constraints of a bug bounty profile, set by SetScope and
+// SetRequiredHeaders.
+var (
+	scopeMu         sync.RWMutex
+	scopeHosts      []string
+	requiredHeaders map[string]string
+)
+
+// SetScope restricts every request of the shared client and every WebSocket
+// dial to hosts, which are host names, "*.domain" patterns matching the
+// subdomains of domain or "*". Requests to other hosts fail with
+// gerrors.ErrOutOfScope before anything is sent and are recorded in
+// SuppressedRequests. An empty list lifts the restriction.
+func SetScope(hosts []string) {
+	scopeMu.Lock()
+	defer scopeMu.Unlock()
+	scopeHosts = nil
+	for _, h := range hosts {
+		scopeHosts = append(scopeHosts, strings.ToLower(h))
+	}
+}
+
+// NarrowScope replaces the hosts set by SetScope with hosts, each of which must
+// already be in scope: a bounty profile's scope can be narrowed down but not
+// widened. Without a current scope it is SetScope.
+func NarrowScope(hosts []string) error {
+	scopeMu.RLock()
+	current := scopeHosts
+	scopeMu.RUnlock()
+	for _, h := range hosts {
+		if !covered(current, strings.ToLower(h)) {
+			return fmt.Errorf("%s is not in the allowed hosts", h)
+		}
+	}
+	SetScope(hosts)
+	return nil
+}
+
+// covered reports whether every host matched by pattern is matched by scope.
+func covered(scope []string, pattern string) bool {
+	if len(scope) == 0 {
+		return true
+	}
+	domain, wildcard := strings.CutPrefix(pattern, "*.")
+	for _, allowed := range scope {
+		switch d, ok := strings.CutPrefix(allowed, "*."); {
+		case allowed == "*":
+			return true
+		case pattern == "*":
+		case !wildcard:
+			if matchHost(allowed, pattern) {
+				return true
+			}
+		case ok && (domain == d || strings.HasSuffix(domain, "."+d)):
+			return true
+		}
+	}
+	return false
+}
+
+// InScope reports whether host, with or without a port, may be contacted.
+func InScope(host string) bool {
+	scopeMu.RLock()
+	defer scopeMu.RUnlock()
+	if len(scopeHosts) == 0 {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	for _, allowed := range scopeHosts {
+		if matchHost(allowed, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHost reports whether host matches allowed, a host name, a "*.domain"
+// pattern matching the subdomains of domain or "*" matching every host.
+func matchHost(allowed, host string) bool {
+	if allowed == "*" {
+		return true
+	}
+	if domain, ok := strings.CutPrefix(allowed, "*."); ok {
+		return strings.HasSuffix(host, "."+domain)
+	}
+	return host == allowed
+}
+
+// CheckScope returns an error matching gerrors.ErrOutOfScope when the host of
+// rawURL may not be contacted.
+func CheckScope(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if !InScope(u.Host) {
+		return fmt.Errorf("%w: %s is not in the allowed hosts", gerrors.ErrOutOfScope, u.Hostname())
+	}
+	return nil
+}
+
+// checkSend is CheckScope for a request about to be sent to rawURL: a refusal
+// is recorded as a suppressed request.
+func checkSend(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if !InScope(u.Host) {
+		return suppress(u)
+	}
+	return nil
+}
+
+// The requests refused for their host, by URL without its query.
+var (
+	suppressedMu    sync.Mutex
+	suppressed      []types.SuppressedRequest
+	suppressedIndex = map[string]int{}
+)
+
+// suppress records the refused request to u, logging the first one to each
+// URL, and returns the error refusing it.
+func suppress(u *url.URL) error {
+	v := *u
+	v.User, v.RawQuery, v.ForceQuery, v.Fragment = nil, "", false, ""
+	target := v.String()
+	if v.Scheme == "" {
+		// WebSocket dials only know the address.
+		target = v.Host
+	}
+
+	suppressedMu.Lock()
+	i, seen := suppressedIndex[target]
+	if !seen {
+		i = len(suppressed)
+		suppressedIndex[target] = i
+		suppressed = append(suppressed, types.SuppressedRequest{URL: target, Host: u.Hostname()})
+	}
+	suppressed[i].Count++
+	suppressedMu.Unlock()
+
+	if !seen {
+		logger.Warn("Out-of-scope request to %s suppressed", target)
+	}
+	return fmt.Errorf("%w: %s is not in the allowed hosts", gerrors.ErrOutOfScope, u.Hostname())
+}
+
+// SuppressedRequests returns the requests and WebSocket dials refused for
+// their host, in the order they were first attempted.
+func SuppressedRequests() []types.SuppressedRequest {
+	suppressedMu.Lock()
+	defer suppressedMu.Unlock()
+	return append([]types.SuppressedRequest(nil), suppressed...)
+}
+
+// resetSuppressed forgets the requests suppressed so far.
+func resetSuppressed() {
+	suppressedMu.Lock()
+	defer suppressedMu.Unlock()
+	suppressed, suppressedIndex = nil, map[string]int{}
+}
+
+// SetRequiredHeaders sets headers on every request of the shared client,
+// replacing any value the request already carries. Nil removes them.
+func SetRequiredHeaders(headers map[string]string) {
+	scopeMu.Lock()
+	defer scopeMu.Unlock()
+	requiredHeaders = headers
+}
+
+// RequiredHeaders returns the headers set by SetRequiredHeaders.
+func RequiredHeaders() map[string]string {
+	scopeMu.RLock()
+	defer scopeMu.RUnlock()
+	return requiredHeaders
+}
+
+// scopeTransport enforces the constraints of the bounty profile and of
+// SetConcurrency and hands the requests in scope to next: out-of-scope hosts
+// are refused, required headers set and requests wait for a free slot when the
+// concurrency is limited.
+type scopeTransport struct {
+	next http.RoundTripper
+}
+
+func (t scopeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := req.URL
+	if req.Host != "" && !InScope(req.Host) {
+		// A virtual host is contacted as much as the address it is sent to.
+		v := *req.URL
+		v.Host = req.Host
+		target = &v
+	}
+	if !InScope(req.URL.Host) || target != req.URL {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, suppress(target)
+	}
+	if headers := RequiredHeaders(); len(headers) > 0 {
+		req = req.Clone(req.Context())
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+	}
+
+	if !requestGate.active() {
+		return t.next.RoundTrip(req)
+	}
+	if err := requestGate.acquire(req.Context()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if failed, ok := overloaded(req.Context(), resp, err); ok {
+		requestGate.observe(failed)
+	}
+	if err != nil {
+		requestGate.release()
+		return nil, err
+	}
+	resp.Body = &gatedBody{ReadCloser: resp.Body}
+	return resp, nil
+}
@@ -0,0 +1,136 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+)
+
+func TestOutOfScopeHostIsNeverContacted(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer srv.Close()
+
+	ResetStats()
+	SetScope([]string{"api.example.com"})
+	defer SetScope(nil)
+
+	_, err := SendGraphQLRequestWithContext(context.Background(), srv.URL+"/graphql?key=secret", "{ __typename }", nil, nil)
+	if !errors.Is(err, gerrors.ErrOutOfScope) {
+		t.Fatalf("err = %v, want %v", err, gerrors.ErrOutOfScope)
+	}
+	if n := atomic.LoadInt32(&hits); n != 0 {
+		t.Errorf("the out-of-scope server received %d requests", n)
+	}
+	suppressed := SuppressedRequests()
+	if len(suppressed) != 1 || suppressed[0].URL != srv.URL+"/graphql" || suppressed[0].Host != "127.0.0.1" || suppressed[0].Count != 1 {
+		t.Errorf("SuppressedRequests() = %+v", suppressed)
+	}
+
+	// Lifting the restriction reaches the server again.
+	SetScope(nil)
+	if _, err := SendGraphQLRequestWithContext(context.Background(), srv.URL, "{ __typename }", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("the server received %d requests once in scope, want 1", n)
+	}
+}
+
+func TestRedirectOutOfScopeIsRefused(t *testing.T) {
+	var hits int32
+	outside := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer outside.Close()
+	// Both servers listen on 127.0.0.1, which is in scope, and the redirect
+	// names the outside one as localhost, which is not.
+	target := strings.Replace(outside.URL, "127.0.0.1", "localhost", 1)
+	inside := httptest.NewServer(http.RedirectHandler(target, http.StatusTemporaryRedirect))
+	defer inside.Close()
+
+	ResetStats()
+	SetScope([]string{"127.0.0.1"})
+	defer SetScope(nil)
+
+	_, err := SendGraphQLRequestWithContext(context.Background(), inside.URL, "{ __typename }", nil, nil)
+	if !errors.Is(err, gerrors.ErrOutOfScope) {
+		t.Fatalf("err = %v, want %v", err, gerrors.ErrOutOfScope)
+	}
+	if n := atomic.LoadInt32(&hits); n != 0 {
+		t.Errorf("the redirect target received %d requests", n)
+	}
+	// The hop is recorded as suppressed, not the request to the target.
+	suppressed := SuppressedRequests()
+	if len(suppressed) != 1 || suppressed[0].URL != target || suppressed[0].Host != "localhost" || suppressed[0].Count != 1 {
+		t.Errorf("SuppressedRequests() = %+v, want the redirect to %s", suppressed, target)
+	}
+}
+
+func TestOutOfScopeDialIsRefused(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+	addr := strings.Replace(strings.TrimPrefix(srv.URL, "http://"), "127.0.0.1", "localhost", 1)
+
+	ResetStats()
+	SetScope([]string{"127.0.0.1"})
+	defer SetScope(nil)
+
+	for i := 0; i < 2; i++ {
+		if conn, err := DialContext(context.Background(), "tcp", addr); !errors.Is(err, gerrors.ErrOutOfScope) {
+			if conn != nil {
+				conn.Close()
+			}
+			t.Fatalf("DialContext(%s) = %v, want %v", addr, err, gerrors.ErrOutOfScope)
+		}
+	}
+	suppressed := SuppressedRequests()
+	if len(suppressed) != 1 || suppressed[0].URL != addr || suppressed[0].Host != "localhost" || suppressed[0].Count != 2 {
+		t.Errorf("SuppressedRequests() = %+v, want the dials to %s", suppressed, addr)
+	}
+
+	conn, err := DialContext(context.Background(), "tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dialing the address in scope: %v", err)
+	}
+	conn.Close()
+}
+
+func TestScopePatterns(t *testing.T) {
+	defer SetScope(nil)
+	SetScope([]string{"API.example.com", "*.staging.example.com"})
+	for host, want := range map[string]bool{
+		"api.example.com":          true,
+		"api.example.com:8443":     true,
+		"eu.staging.example.com":   true,
+		"a.b.staging.example.com":  true,
+		"staging.example.com":      false,
+		"example.com":              false,
+		"api.example.com.evil.net": false,
+		"[::1]:443":                false,
+	} {
+		if got := InScope(host); got != want {
+			t.Errorf("InScope(%s) = %v, want %v", host, got, want)
+		}
+	}
+
+	if err := NarrowScope([]string{"eu.staging.example.com"}); err != nil {
+		t.Errorf("narrowing to a subdomain: %v", err)
+	}
+	if err := NarrowScope([]string{"api.example.com"}); err == nil {
+		t.Error("NarrowScope widened the scope to a host it had dropped")
+	}
+}
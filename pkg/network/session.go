@@ -0,0 +1,58 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Session adds per-session state, such as a CSRF token, to every request sent
+// through the shared client and renews it when the server rejects it.
+type Session interface {
+	// Apply sets the session headers on req.
+	Apply(req *http.Request)
+	// Expired reports whether the response to req shows that the session state
+	// is no longer accepted.
+	Expired(req *http.Request, statusCode int, body []byte) bool
+	// Refresh renews the session state if a response reported it expired.
+	Refresh(ctx context.Context) error
+}
+
+var (
+	sessionMu     sync.RWMutex
+	activeSession Session
+)
+
+// SetSession installs s for all subsequent requests. A nil session removes it.
+func SetSession(s Session) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	activeSession = s
+}
+
+func currentSession() Session {
+	sessionMu.RLock()
+	defer sessionMu.RUnlock()
+	return activeSession
+}
+
+// Client returns the HTTP client shared by all requests.
+func Client() *http.Client {
+	return httpClient
+}
+
+// StreamClient returns a client sharing the transport of Client, with its
+// proxy, TLS, scope and signing settings, but without its overall timeout,
+// for responses read for as long as the server streams them.
+func StreamClient() *http.Client {
+	c := *httpClient
+	c.Timeout = 0
+	return &c
+}
+
+// ApplySession sets the headers of the active session, if any, on req.
+func ApplySession(req *http.Request) {
+	if s := currentSession(); s != nil {
+		s.Apply(req)
+	}
+}
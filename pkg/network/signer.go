@@ -0,0 +1,77 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// RequestSigner signs every request sent through the shared client, such as
+// the SigV4 signatures of AWS AppSync IAM endpoints. Sign is called last, once
+// the session, headers and body of req are final; body is nil for requests
+// without one. Requests retried or redirected are signed again.
+type RequestSigner interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// noopSigner leaves requests as they are. It is installed by default.
+type noopSigner struct{}
+
+func (noopSigner) Sign(*http.Request, []byte) error { return nil }
+
+var (
+	signerMu     sync.RWMutex
+	activeSigner RequestSigner = noopSigner{}
+)
+
+// SetSigner installs s for all subsequent requests. A nil signer removes it.
+func SetSigner(s RequestSigner) {
+	signerMu.Lock()
+	defer signerMu.Unlock()
+	if s == nil {
+		s = noopSigner{}
+	}
+	activeSigner = s
+}
+
+func currentSigner() RequestSigner {
+	signerMu.RLock()
+	defer signerMu.RUnlock()
+	return activeSigner
+}
+
+// signingTransport signs requests with the installed RequestSigner and hands
+// them to next, or to http.DefaultTransport when next is nil.
+type signingTransport struct {
+	next http.RoundTripper
+}
+
+func (t signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	s := currentSigner()
+	if _, ok := s.(noopSigner); ok {
+		return next.RoundTrip(req)
+	}
+
+	// RoundTrip must not modify req, so the signature goes on a copy.
+	signed := req.Clone(req.Context())
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading request body for signing: %w", err)
+		}
+		signed.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if err := s.Sign(signed, body); err != nil {
+		return nil, fmt.Errorf("error signing request: %w", err)
+	}
+	return next.RoundTrip(signed)
+}
@@ -0,0 +1,65 @@
+package network
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// digestSigner signs a request with the digest of its body and its
+// X-Custom header, and records what it saw.
+type digestSigner struct {
+	body   []byte
+	custom string
+}
+
+func (s *digestSigner) Sign(req *http.Request, body []byte) error {
+	s.body, s.custom = body, req.Header.Get("X-Custom")
+	sum := sha256.Sum256(append(body, s.custom...))
+	req.Header.Set("X-Signature", hex.EncodeToString(sum[:]))
+	return nil
+}
+
+func TestSignerSeesFinalRequest(t *testing.T) {
+	var received []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Signature")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer srv.Close()
+
+	s := &digestSigner{}
+	SetSigner(s)
+	defer SetSigner(nil)
+
+	headers := map[string]string{"X-Custom": "value"}
+	if _, err := SendGraphQLRequestWithContext(context.Background(), srv.URL, "{ __typename }", map[string]interface{}{"a": 1}, headers); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) == 0 || string(s.body) != string(received) {
+		t.Errorf("signer saw body %q, server received %q", s.body, received)
+	}
+	if s.custom != "value" {
+		t.Errorf("signer saw X-Custom %q, want the header set on the request", s.custom)
+	}
+	sum := sha256.Sum256(append(received, "value"...))
+	if signature != hex.EncodeToString(sum[:]) {
+		t.Errorf("X-Signature = %q does not cover the body sent", signature)
+	}
+
+	// Without a signer requests go out unsigned.
+	SetSigner(nil)
+	if _, err := SendGraphQLRequestWithContext(context.Background(), srv.URL, "{ __typename }", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if signature != "" {
+		t.Errorf("X-Signature = %q after removing the signer", signature)
+	}
+}
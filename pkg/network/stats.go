@@ -0,0 +1,145 @@
+package network
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// counters collects network metrics for the whole run. All fields are safe for
+// concurrent use by the detection goroutines.
+type counters struct {
+	requests       atomic.Int64
+	bytesSent      atomic.Int64
+	bytesReceived  atomic.Int64
+	retries        atomic.Int64
+	rateLimitWaits atomic.Int64
+	newConns       atomic.Int64
+	reusedConns    atomic.Int64
+
+	mu          sync.Mutex
+	statusCodes map[int]int64
+	modules     map[string]time.Duration
+	start       time.Time
+}
+
+var runStats = &counters{
+	statusCodes: make(map[int]int64),
+	modules:     make(map[string]time.Duration),
+	start:       time.Now(),
+}
+
+// recordStatus counts a response status code.
+func (c *counters) recordStatus(code int) {
+	c.mu.Lock()
+	c.statusCodes[code]++
+	c.mu.Unlock()
+}
+
+// connTrace returns an httptrace hook that counts new and reused connections.
+func (c *counters) connTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				c.reusedConns.Add(1)
+			} else {
+				c.newConns.Add(1)
+			}
+		},
+	}
+}
+
+// RecordRetry counts a request that was sent again after a failure.
+func RecordRetry() {
+	runStats.retries.Add(1)
+}
+
+// moduleKey is the context key of the module requests are attributed to.
+type moduleKey struct{}
+
+// StartModule starts timing a named part of the run (detection, batch, a check...)
+// and returns a context carrying the module, with a function that stops the
+// timer. Repeated runs of a module accumulate. Requests sent with the returned
+// context, or one derived from it, are attributed to the module, so that
+// modules running at the same time on other goroutines, such as detection and
+// the checks of the endpoints it streams, keep their own requests. Only the
+// first call of the returned function stops the timer, so that it can also be
+// deferred.
+func StartModule(ctx context.Context, name string) (context.Context, func()) {
+	started := time.Now()
+	var once sync.Once
+	return context.WithValue(ctx, moduleKey{}, name), func() {
+		once.Do(func() {
+			elapsed := time.Since(started)
+			runStats.mu.Lock()
+			runStats.modules[name] += elapsed
+			runStats.mu.Unlock()
+		})
+	}
+}
+
+// moduleOf returns the module of StartModule requests sent with ctx are
+// attributed to, or "" outside of any.
+func moduleOf(ctx context.Context) string {
+	name, _ := ctx.Value(moduleKey{}).(string)
+	return name
+}
+
+// ResetStats starts the network metrics over, along with every record of the
+// audit: the operations sent, the requests suppressed, and the rate-limit
+// responses, Content-Type mismatches and misdeclared bodies observed. A run
+// auditing its targets again, like each iteration of --watch, thus accounts
+// for one audit at a time.
+func ResetStats() {
+	c := runStats
+	for _, n := range []*atomic.Int64{&c.requests, &c.bytesSent, &c.bytesReceived, &c.retries, &c.rateLimitWaits, &c.newConns, &c.reusedConns} {
+		n.Store(0)
+	}
+	c.mu.Lock()
+	c.statusCodes = make(map[int]int64)
+	c.modules = make(map[string]time.Duration)
+	c.start = time.Now()
+	c.mu.Unlock()
+	resetOperations()
+	resetSuppressed()
+	resetRateLimitEvents()
+	resetContentTypeEvents()
+	resetBodyEvents()
+}
+
+// Stats returns a snapshot of the network metrics collected so far.
+func Stats() types.NetworkStats {
+	c := runStats
+	snapshot := types.NetworkStats{
+		Requests:       c.requests.Load(),
+		BytesSent:      c.bytesSent.Load(),
+		BytesReceived:  c.bytesReceived.Load(),
+		Retries:        c.retries.Load(),
+		RateLimitWaits: c.rateLimitWaits.Load(),
+		NewConns:       c.newConns.Load(),
+		ReusedConns:    c.reusedConns.Load(),
+		StatusCodes:    make(map[int]int64),
+		Modules:        make(map[string]string),
+		Operations:     operationCounts(),
+		Concurrency:    requestGate.stats(),
+	}
+	if total := snapshot.NewConns + snapshot.ReusedConns; total > 0 {
+		snapshot.ConnectionReuseRatio = float64(snapshot.ReusedConns) / float64(total)
+	}
+
+	c.mu.Lock()
+	for code, n := range c.statusCodes {
+		snapshot.StatusCodes[code] = n
+	}
+	for name, d := range c.modules {
+		snapshot.Modules[name] = d.Round(time.Millisecond).String()
+	}
+	snapshot.WallTime = time.Since(c.start).Round(time.Millisecond).String()
+	c.mu.Unlock()
+
+	return snapshot
+}
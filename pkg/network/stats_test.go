@@ -0,0 +1,190 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResetStats(t *testing.T) {
+	RecordOperations("https://api.example.com/graphql", "mutation Login { login }", "{ me { id } }")
+	RecordRetry()
+	u, _ := url.Parse("https://elsewhere.example.com/graphql?token=x")
+	_ = suppress(u)
+	handleRateLimit("https://api.example.com/graphql", 429, time.Millisecond, nil)
+	recordContentTypeMismatch("https://api.example.com/graphql", "text/html")
+	recordBodyIssue("https://api.example.com/graphql", "Content-Length is wrong")
+	if len(NonQueryOperations()) == 0 || len(SuppressedRequests()) == 0 || Stats().Retries == 0 ||
+		len(RateLimitEvents()) == 0 || len(ContentTypeEvents()) == 0 || len(BodyEvents()) == 0 {
+		t.Fatal("the records of the first audit are missing")
+	}
+
+	ResetStats()
+	if ops := NonQueryOperations(); len(ops) != 0 {
+		t.Errorf("NonQueryOperations() = %v after ResetStats, want none", ops)
+	}
+	if requests := SuppressedRequests(); len(requests) != 0 {
+		t.Errorf("SuppressedRequests() = %v after ResetStats, want none", requests)
+	}
+	if n, m, o := len(RateLimitEvents()), len(ContentTypeEvents()), len(BodyEvents()); n+m+o != 0 {
+		t.Errorf("%d rate-limit, %d Content-Type and %d body events after ResetStats, want none", n, m, o)
+	}
+	stats := Stats()
+	if stats.Retries != 0 || len(stats.Operations) != 0 {
+		t.Errorf("Stats() = %+v after ResetStats, want zero counters", stats)
+	}
+
+	RecordOperations("https://api.example.com/graphql", "mutation Logout { logout }")
+	ops := NonQueryOperations()
+	if len(ops) != 1 || ops[0].Name != "Logout" || ops[0].Count != 1 {
+		t.Errorf("NonQueryOperations() = %+v, want only the Logout mutation of the second audit", ops)
+	}
+	// An endpoint seen in the first audit is reported again in the second.
+	recordContentTypeMismatch("https://api.example.com/graphql", "text/html")
+	recordBodyIssue("https://api.example.com/graphql", "Content-Length is wrong")
+	if len(ContentTypeEvents()) != 1 || len(BodyEvents()) != 1 {
+		t.Errorf("events of the second audit = %v, %v", ContentTypeEvents(), BodyEvents())
+	}
+}
+
+func TestStartModuleStopsOnce(t *testing.T) {
+	ResetStats()
+	ctx, stop := StartModule(context.Background(), "batch")
+	if m := moduleOf(ctx); m != "batch" {
+		t.Errorf("moduleOf() = %q, want batch", m)
+	}
+	stop()
+	first := Stats().Modules["batch"]
+	stop()
+	if got := Stats().Modules["batch"]; got != first {
+		t.Errorf("a second stop changed the batch time from %s to %s", first, got)
+	}
+	if m := moduleOf(context.Background()); m != "" {
+		t.Errorf("moduleOf() = %q outside of any module, want none", m)
+	}
+}
+
+// TestStatsScriptedSequence sends four requests answered 200, 400, 429 and,
+// for the retry of the throttled request, 200, and checks every counter.
+func TestStatsScriptedSequence(t *testing.T) {
+	var mu sync.Mutex
+	var received, answered int64
+	var n int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received += int64(len(body))
+		n++
+		response := `{"data":{"__typename":"Query"}}`
+		w.Header().Set("Content-Type", "application/json")
+		switch n {
+		case 2:
+			w.WriteHeader(http.StatusBadRequest)
+			response = `{"errors":[{"message":"Syntax Error"}]}`
+		case 3:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			response = `{"errors":[{"message":"Too many requests"}]}`
+		}
+		answered += int64(len(response))
+		w.Write([]byte(response))
+	}))
+	defer srv.Close()
+
+	ResetStats()
+	defer ResetStats()
+	ctx := context.Background()
+	if _, err := SendGraphQLRequestWithContext(ctx, srv.URL, "{ __typename }", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	SendGraphQLRequestWithContext(ctx, srv.URL, "{ __typename ", nil, nil)
+	if _, err := SendGraphQLRequestWithContext(ctx, srv.URL, "{ __typename }", map[string]interface{}{"a": 1}, nil); err != nil {
+		t.Fatalf("the throttled request was not retried: %v", err)
+	}
+
+	stats := Stats()
+	mu.Lock()
+	defer mu.Unlock()
+	if stats.Requests != 4 || stats.Retries != 1 || stats.RateLimitWaits == 0 {
+		t.Errorf("requests %d, retries %d, rate-limit waits %d; want 4, 1 and at least 1", stats.Requests, stats.Retries, stats.RateLimitWaits)
+	}
+	if want := map[int]int64{200: 2, 400: 1, 429: 1}; !reflect.DeepEqual(stats.StatusCodes, want) {
+		t.Errorf("status codes = %v, want %v", stats.StatusCodes, want)
+	}
+	if stats.BytesSent != received || stats.BytesReceived != answered {
+		t.Errorf("bytes sent %d, received %d; the server received %d and answered %d", stats.BytesSent, stats.BytesReceived, received, answered)
+	}
+	if stats.NewConns+stats.ReusedConns != 4 || stats.ReusedConns == 0 || stats.ConnectionReuseRatio <= 0 {
+		t.Errorf("new connections %d, reused %d, ratio %f", stats.NewConns, stats.ReusedConns, stats.ConnectionReuseRatio)
+	}
+}
+
+// TestModulesOfConcurrentGoroutines runs a module on one goroutine while
+// another, started and stopped meanwhile as detection is while checks run,
+// sends its own requests, and checks each request keeps its module.
+func TestModulesOfConcurrentGoroutines(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	modules := make(map[string]string)
+	SetRequestHook(func(r SentRequest) {
+		mu.Lock()
+		modules[r.URL] = r.Module
+		mu.Unlock()
+	})
+	defer SetRequestHook(nil)
+	ResetStats()
+
+	checkCtx, stopCheck := StartModule(context.Background(), "introspection")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		SendGraphQLRequestWithContext(checkCtx, srv.URL+"/slow", "{ __typename }", nil, nil)
+	}()
+	// The check's request is in flight under its module.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if inFlight := InFlight(); len(inFlight) == 1 {
+			if inFlight[0].Module != "introspection" {
+				t.Errorf("in-flight module = %q, want introspection", inFlight[0].Module)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the request never went in flight")
+		}
+	}
+
+	detectCtx, stopDetection := StartModule(context.Background(), "detection")
+	SendGraphQLRequestWithContext(detectCtx, srv.URL+"/detect", "{ __typename }", nil, nil)
+	stopDetection()
+	// A request of the check sent after detection stopped keeps its module.
+	SendGraphQLRequestWithContext(checkCtx, srv.URL+"/after", "{ __typename }", nil, nil)
+	close(release)
+	<-done
+	stopCheck()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]string{srv.URL + "/slow": "introspection", srv.URL + "/detect": "detection", srv.URL + "/after": "introspection"}
+	if !reflect.DeepEqual(modules, want) {
+		t.Errorf("modules = %v, want %v", modules, want)
+	}
+	if got := Stats().Modules; got["introspection"] == "" || got["detection"] == "" {
+		t.Errorf("module times = %v", got)
+	}
+}
@@ -0,0 +1,100 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Strategy describes where a GraphQL document is placed in the HTTP request.
+// The zero value sends it in the standard "query" member of the JSON body.
+type Strategy struct {
+	// Param is the JSON member or URL parameter carrying the query. Empty means "query".
+	Param string
+	// InURL places the query in the URL query string of the POST instead of the JSON body.
+	InURL bool
+	// Decoy, when set, is sent in the standard "query" member of the body
+	// while the real query goes to the position described by Param and InURL.
+	Decoy string
+}
+
+// ParseStrategy parses a position such as "q", "body:doc" or "url:query".
+// A bare name is a JSON body member.
+func ParseStrategy(position string) (Strategy, error) {
+	where, name, ok := strings.Cut(position, ":")
+	if !ok {
+		where, name = "body", position
+	}
+	if name == "" {
+		return Strategy{}, fmt.Errorf("invalid query position %q: missing parameter name", position)
+	}
+	switch where {
+	case "body":
+		return Strategy{Param: name}, nil
+	case "url":
+		return Strategy{Param: name, InURL: true}, nil
+	default:
+		return Strategy{}, fmt.Errorf("invalid query position %q: use body:<name> or url:<name>", position)
+	}
+}
+
+// String describes the position of the real query.
+func (s Strategy) String() string {
+	param := s.Param
+	if param == "" {
+		param = "query"
+	}
+	if s.InURL {
+		return "url:" + param
+	}
+	return "body:" + param
+}
+
+// Build returns the request URL and JSON body carrying query and variables.
+func (s Strategy) Build(target, query string, variables map[string]interface{}) (string, []byte, error) {
+	param := s.Param
+	if param == "" {
+		param = "query"
+	}
+
+	body := make(map[string]interface{})
+	if len(variables) > 0 {
+		body["variables"] = variables
+	}
+	if s.Decoy != "" {
+		body["query"] = s.Decoy
+	}
+	if s.InURL {
+		parsed, err := url.Parse(target)
+		if err != nil {
+			return "", nil, fmt.Errorf("error parsing target URL: %w", err)
+		}
+		values := parsed.Query()
+		values.Set(param, query)
+		parsed.RawQuery = values.Encode()
+		target = parsed.String()
+	} else {
+		if _, taken := body[param]; taken {
+			return "", nil, fmt.Errorf("query position %s collides with the decoy query", s)
+		}
+		body[param] = query
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return "", nil, fmt.Errorf("error marshalling request: %w", err)
+	}
+	return target, jsonData, nil
+}
+
+// SendWithStrategy sends query to target placed as s describes, with the same
+// rate limiting, session handling and retries as SendGraphQLRequestWithContext.
+func SendWithStrategy(ctx context.Context, target string, s Strategy, query string, variables map[string]interface{}, headers map[string]string) (map[string]interface{}, error) {
+	requestURL, jsonData, err := s.Build(target, query, variables)
+	if err != nil {
+		return nil, err
+	}
+	return sendEncoded(ctx, requestURL, jsonData, headers, query, s.Decoy)
+}
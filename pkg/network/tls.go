@@ -0,0 +1,106 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+var (
+	tlsMu     sync.RWMutex
+	tlsConfig *tls.Config
+)
+
+// SetClientCertificate loads a PEM certificate and private key and presents
+// them on every TLS connection, HTTP and WebSocket alike. password decrypts a
+// legacy encrypted PEM key and is ignored for unencrypted keys.
+func SetClientCertificate(certFile, keyFile, password string) error {
+	cert, err := LoadClientCertificate(certFile, keyFile, password)
+	if err != nil {
+		return err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	tlsMu.Lock()
+	tlsConfig = cfg
+	tlsMu.Unlock()
+	rebuildTransport()
+	return nil
+}
+
+// rebuildTransport replaces the transport of the shared client with one
+// presenting the client certificate and dialing as SetResolution says.
+func rebuildTransport() {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = TLSConfig()
+	transport.DialContext = dial
+	httpClient.Transport = transportChain(transport)
+}
+
+// TLSConfig returns the TLS configuration shared by all connections, or nil
+// when the defaults apply. WebSocket dialers use it as their TLSClientConfig.
+func TLSConfig() *tls.Config {
+	tlsMu.RLock()
+	defer tlsMu.RUnlock()
+	if tlsConfig == nil {
+		return nil
+	}
+	return tlsConfig.Clone()
+}
+
+// LoadClientCertificate reads a certificate and its private key from PEM files.
+func LoadClientCertificate(certFile, keyFile, password string) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("error reading client certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("error reading client key: %w", err)
+	}
+	keyPEM, err = decryptKey(keyPEM, keyFile, password)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("client certificate %s does not match key %s: %w", certFile, keyFile, err)
+	}
+	return cert, nil
+}
+
+// decryptKey returns keyPEM with its private key block decrypted when it is
+// encrypted with a legacy PEM cipher.
+func decryptKey(keyPEM []byte, keyFile, password string) ([]byte, error) {
+	rest := keyPEM
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return keyPEM, nil
+		}
+		if block.Type == "ENCRYPTED PRIVATE KEY" {
+			return nil, fmt.Errorf("client key %s is an encrypted PKCS#8 key, which is not supported: decrypt it first, e.g. with openssl pkey", keyFile)
+		}
+		// Legacy PEM encryption is deprecated but still written by openssl -traditional.
+		if !x509.IsEncryptedPEMBlock(block) {
+			continue
+		}
+		if password == "" {
+			return nil, fmt.Errorf("client key %s is encrypted: use --client-key-password", keyFile)
+		}
+		der, err := x509.DecryptPEMBlock(block, []byte(password))
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, fmt.Errorf("wrong password for client key %s", keyFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting client key %s: %w", keyFile, err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+	}
+}
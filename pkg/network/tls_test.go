@@ -0,0 +1,252 @@
+package network
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testCA is a certificate authority issuing client certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// issue writes a client certificate for name and its key to dir and returns
+// the two paths. A non-empty password encrypts the key with legacy PEM
+// encryption.
+func (ca *testCA) issue(t *testing.T, dir, name, password string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}
+	if password != "" {
+		// Legacy PEM encryption, as openssl -traditional writes it.
+		block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, keyDER, []byte(password), x509.PEMCipherAES256)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// mtlsServer starts a TLS server requiring a client certificate issued by
+// ca. The GraphQL handler answers with the common name of the certificate,
+// every other path upgrades to a WebSocket echoing it.
+func mtlsServer(t *testing.T, ca *testCA) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cn := r.TLS.PeerCertificates[0].Subject.CommonName
+		if r.URL.Path == "/graphql" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"client":"` + cn + `"}}`))
+			return
+		}
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(cn))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: ca.pool()}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// trustServer makes the shared TLS configuration trust the certificate of
+// srv, keeping any client certificate already set. The configuration is
+// reset when the test ends.
+func trustServer(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	tlsMu.Lock()
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig.RootCAs = pool
+	tlsMu.Unlock()
+	rebuildTransport()
+	t.Cleanup(func() {
+		tlsMu.Lock()
+		tlsConfig = nil
+		tlsMu.Unlock()
+		rebuildTransport()
+	})
+}
+
+// dialWebSocket connects to srv as the subscription client does and returns
+// the first message.
+func dialWebSocket(srv *httptest.Server) (string, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = TLSConfig()
+	dialer.NetDialContext = DialContext
+	conn, _, err := dialer.DialContext(context.Background(), "wss"+strings.TrimPrefix(srv.URL, "https")+"/ws", nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	_, msg, err := conn.ReadMessage()
+	return string(msg), err
+}
+
+func TestClientCertificateAccepted(t *testing.T) {
+	ca := newTestCA(t)
+	srv := mtlsServer(t, ca)
+	certFile, keyFile := ca.issue(t, t.TempDir(), "scanner", "")
+	if err := SetClientCertificate(certFile, keyFile, ""); err != nil {
+		t.Fatal(err)
+	}
+	trustServer(t, srv)
+
+	resp, err := SendGraphQLRequestWithContext(context.Background(), srv.URL+"/graphql", "{ client }", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := resp["data"].(map[string]interface{}); data["client"] != "scanner" {
+		t.Errorf("response = %v, want the server to see the scanner certificate", resp)
+	}
+	if msg, err := dialWebSocket(srv); err != nil || msg != "scanner" {
+		t.Errorf("WebSocket = %q, %v; want the scanner certificate presented", msg, err)
+	}
+}
+
+func TestClientCertificateRejected(t *testing.T) {
+	ca := newTestCA(t)
+	srv := mtlsServer(t, ca)
+
+	t.Run("no certificate", func(t *testing.T) {
+		trustServer(t, srv)
+		if _, err := SendGraphQLRequestWithContext(context.Background(), srv.URL+"/graphql", "{ client }", nil, nil); err == nil {
+			t.Error("request without a client certificate succeeded")
+		}
+		if _, err := dialWebSocket(srv); err == nil {
+			t.Error("WebSocket without a client certificate succeeded")
+		}
+	})
+
+	t.Run("untrusted issuer", func(t *testing.T) {
+		certFile, keyFile := newTestCA(t).issue(t, t.TempDir(), "intruder", "")
+		if err := SetClientCertificate(certFile, keyFile, ""); err != nil {
+			t.Fatal(err)
+		}
+		trustServer(t, srv)
+		if _, err := SendGraphQLRequestWithContext(context.Background(), srv.URL+"/graphql", "{ client }", nil, nil); err == nil {
+			t.Error("request with a certificate of another CA succeeded")
+		}
+		if _, err := dialWebSocket(srv); err == nil {
+			t.Error("WebSocket with a certificate of another CA succeeded")
+		}
+	})
+}
+
+func TestLoadClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := ca.issue(t, dir, "plain", "")
+	_, otherKey := ca.issue(t, dir, "other", "")
+	encCert, encKey := ca.issue(t, dir, "encrypted", "s3cret")
+	pkcs8Key := filepath.Join(dir, "pkcs8.key")
+	if err := os.WriteFile(pkcs8Key, pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: []byte{0x30}}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadClientCertificate(certFile, keyFile, "ignored"); err != nil {
+		t.Errorf("unencrypted key with a password: %v", err)
+	}
+	if _, err := LoadClientCertificate(encCert, encKey, "s3cret"); err != nil {
+		t.Errorf("encrypted key with its password: %v", err)
+	}
+	for _, tt := range []struct {
+		name, cert, key, password, want string
+	}{
+		{"mismatched key", certFile, otherKey, "", "does not match key"},
+		{"encrypted key without a password", encCert, encKey, "", "is encrypted: use --client-key-password"},
+		{"wrong password", encCert, encKey, "wrong", "wrong password for client key"},
+		{"encrypted PKCS#8 key", certFile, pkcs8Key, "s3cret", "encrypted PKCS#8 key"},
+		{"missing certificate", filepath.Join(dir, "missing.crt"), keyFile, "", "error reading client certificate"},
+		{"missing key", certFile, filepath.Join(dir, "missing.key"), "", "error reading client key"},
+	} {
+		if _, err := LoadClientCertificate(tt.cert, tt.key, tt.password); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+	if err := SetClientCertificate(certFile, otherKey, ""); err == nil || TLSConfig() != nil {
+		t.Errorf("SetClientCertificate() with a mismatched key = %v, TLSConfig() = %v; want an error and no configuration", err, TLSConfig())
+	}
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// vhostKey is the context key of the virtual host set by WithVirtualHost.
+type vhostKey struct{}
+
+// WithVirtualHost returns a context whose requests, GraphQL and Client() ones
+// alike, are sent to the address of their URL with host as the Host header
+// and, over TLS, as the server name, so that the applications a single
+// address serves for several names can be reached one by one.
+func WithVirtualHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, vhostKey{}, host)
+}
+
+// VirtualHost returns the virtual host set on ctx by WithVirtualHost.
+func VirtualHost(ctx context.Context) string {
+	host, _ := ctx.Value(vhostKey{}).(string)
+	return host
+}
+
+// vhostTransport sets the Host header of the requests sent with a virtual
+// host. It comes first in the transport chain so that the scope, the signature
+// and the TLS server name all apply to the virtual host.
+type vhostTransport struct {
+	next http.RoundTripper
+}
+
+func (t vhostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if host := VirtualHost(req.Context()); host != "" && req.Host != host {
+		req = req.Clone(req.Context())
+		req.Host = host
+	}
+	return t.next.RoundTrip(req)
+}
+
+// sniTransport sends HTTPS requests whose Host differs from the host of their
+// URL over connections presenting that host as the TLS server name. Each server
+// name gets a transport of its own, cloned from base, so pooled connections
+// are never shared between names.
+type sniTransport struct {
+	base http.RoundTripper
+
+	mu     sync.Mutex
+	byName map[string]http.RoundTripper
+}
+
+// newSNITransport returns an sniTransport over base, or http.DefaultTransport
+// when base is nil.
+func newSNITransport(base http.RoundTripper) *sniTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &sniTransport{base: base, byName: make(map[string]http.RoundTripper)}
+}
+
+func (t *sniTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || req.Host == "" {
+		return t.base.RoundTrip(req)
+	}
+	name := req.Host
+	if h, _, err := net.SplitHostPort(name); err == nil {
+		name = h
+	}
+	name = strings.Trim(name, "[]")
+	if strings.EqualFold(name, req.URL.Hostname()) {
+		return t.base.RoundTrip(req)
+	}
+	return t.forName(name).RoundTrip(req)
+}
+
+// forName returns the transport presenting name as the TLS server name.
+// Transports that cannot be cloned are used as they are.
+func (t *sniTransport) forName(name string) http.RoundTripper {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if rt, ok := t.byName[name]; ok {
+		return rt
+	}
+	base, ok := t.base.(*http.Transport)
+	if !ok {
+		return t.base
+	}
+	clone := base.Clone()
+	if clone.TLSClientConfig == nil {
+		clone.TLSClientConfig = &tls.Config{}
+	}
+	clone.TLSClientConfig.ServerName = name
+	t.byName[name] = clone
+	return clone
+}
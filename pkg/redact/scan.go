@@ -0,0 +1,245 @@
+package redact
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Built-in detector names
+const (
+	DetectorPrivateKey     = "private-key"
+	DetectorJWT            = "jwt"
+	DetectorAWSAccessKey   = "aws-access-key"
+	DetectorURLCredentials = "url-credentials"
+	// DetectorEntropy flags long random-looking strings that no pattern
+	// recognises.
+	DetectorEntropy = "high-entropy"
+)
+
+const (
+	// minScanLength is the length below which strings are not scanned
+	// unless patterns of a file are loaded; no built-in detector matches
+	// anything shorter.
+	minScanLength = 16
+	// minEntropyLength is the shortest string the entropy detector considers.
+	minEntropyLength = 32
+	// minEntropy is the Shannon entropy, in bits per character, from which a
+	// string is taken for a secret. Hex strings, such as digests and ids, stay
+	// below it since they have 4 bits per character at most.
+	minEntropy = 4.5
+)
+
+// Detector recognises one kind of secret. Hint, when set, is a substring
+// every match holds, checked before the much slower regular expression.
+type Detector struct {
+	Name   string
+	Hint   string
+	Regexp *regexp.Regexp
+}
+
+// DefaultDetectors are the patterns of the secrets scanned for in responses.
+// Tree masks the string values they match, except URL credentials, of which it
+// masks the userinfo only.
+var DefaultDetectors = []Detector{
+	// The match runs to the end of the block so that the key is masked whole.
+	{DetectorPrivateKey, "PRIVATE KEY", regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----(?s:.*?)(?:-----END (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----|$)`)},
+	{DetectorJWT, "eyJ", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]*`)},
+	{DetectorAWSAccessKey, "", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA)[A-Z0-9]{16}\b`)},
+	{DetectorURLCredentials, "://", urlCredentials},
+}
+
+// uuidPattern matches UUIDs, which look random but are identifiers.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// matchesSecret reports whether s holds a private key, a JWT or an AWS key.
+func matchesSecret(s string) bool {
+	if len(s) < minScanLength {
+		return false
+	}
+	for _, d := range DefaultDetectors {
+		if d.Name == DetectorURLCredentials {
+			continue
+		}
+		if (d.Hint == "" || strings.Contains(s, d.Hint)) && d.Regexp.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// SecretHit is a secret found in a response.
+type SecretHit struct {
+	// Path is the dot-separated member names and array indices leading to the
+	// value, such as users.3.apiKey.
+	Path     string `json:"path"`
+	Detector string `json:"detector"`
+	// Excerpt is the value around the match, with the match masked.
+	Excerpt string `json:"excerpt"`
+}
+
+// Scanner looks for secrets in the string values of decoded JSON documents.
+// It only reads the strings long enough to be secrets, checks the hint of each
+// pattern before running it and builds the path of a value only when it holds
+// a secret, so it is cheap enough to run on every response.
+type Scanner struct {
+	detectors []Detector
+	entropy   bool
+	minLength int
+}
+
+// NewScanner returns a scanner using DefaultDetectors and the entropy
+// detector.
+func NewScanner() *Scanner {
+	return &Scanner{detectors: append([]Detector(nil), DefaultDetectors...), entropy: true, minLength: minScanLength}
+}
+
+// LoadScanner returns a scanner using DefaultDetectors, the entropy detector
+// and the patterns of the file at path, one "name=regexp" per line. A line
+// naming a built-in detector replaces it, and without a regexp, "jwt=",
+// disables it. Blank lines and lines starting with # are skipped. An empty
+// path gives NewScanner.
+func LoadScanner(path string) (*Scanner, error) {
+	s := NewScanner()
+	if path == "" {
+		return s, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading secret patterns: %w", err)
+	}
+	defer f.Close()
+	lines := bufio.NewScanner(f)
+	for n := 1; lines.Scan(); n++ {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, expr, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected name=regexp", path, n)
+		}
+		if expr == "" {
+			if !s.disable(name) {
+				return nil, fmt.Errorf("%s:%d: no detector named %q to disable", path, n, name)
+			}
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %s: %w", path, n, name, err)
+		}
+		s.disable(name)
+		s.detectors = append(s.detectors, Detector{Name: name, Regexp: re})
+		s.minLength = 1
+	}
+	if err := lines.Err(); err != nil {
+		return nil, fmt.Errorf("error reading secret patterns: %w", err)
+	}
+	return s, nil
+}
+
+// disable removes the detector called name and reports whether there was one.
+func (s *Scanner) disable(name string) bool {
+	if name == DetectorEntropy {
+		found := s.entropy
+		s.entropy = false
+		return found
+	}
+	for i, d := range s.detectors {
+		if d.Name == name {
+			s.detectors = append(s.detectors[:i], s.detectors[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Scan calls visit for every secret in the string values of v, a decoded JSON
+// tree of maps, slices and scalars, with paths starting at v. A value holding
+// several secrets is reported once per detector, the first matching first.
+// Map members are visited in no particular order.
+func (s *Scanner) Scan(v interface{}, visit func(SecretHit)) {
+	if s == nil {
+		return
+	}
+	var path []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for k, child := range val {
+				path = append(path, k)
+				walk(child)
+				path = path[:len(path)-1]
+			}
+		case []interface{}:
+			for i, child := range val {
+				path = append(path, strconv.Itoa(i))
+				walk(child)
+				path = path[:len(path)-1]
+			}
+		case string:
+			s.scanString(val, func(detector string, start, end int) {
+				visit(SecretHit{Path: strings.Join(path, "."), Detector: detector, Excerpt: Excerpt(val, start, end)})
+			})
+		}
+	}
+	walk(v)
+}
+
+// scanString calls found with the first match of each detector in value.
+func (s *Scanner) scanString(value string, found func(detector string, start, end int)) {
+	if len(value) < s.minLength {
+		return
+	}
+	matched := false
+	for _, d := range s.detectors {
+		if d.Hint != "" && !strings.Contains(value, d.Hint) {
+			continue
+		}
+		if loc := d.Regexp.FindStringIndex(value); loc != nil {
+			found(d.Name, loc[0], loc[1])
+			matched = true
+		}
+	}
+	if !matched && s.entropy && HighEntropy(value) {
+		found(DetectorEntropy, 0, len(value))
+	}
+}
+
+// HighEntropy reports whether s looks like a random token: a single word of
+// at least minEntropyLength characters, holding letters and digits, with a
+// Shannon entropy of at least minEntropy bits per character. UUIDs are not.
+func HighEntropy(s string) bool {
+	if len(s) < minEntropyLength || strings.ContainsAny(s, " \t\n") || uuidPattern.MatchString(s) {
+		return false
+	}
+	var letters, digits bool
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = true
+		case r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+			letters = true
+		}
+		counts[r]++
+		n++
+	}
+	if !letters || !digits {
+		return false
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= minEntropy
+}
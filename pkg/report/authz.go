@@ -0,0 +1,75 @@
+package report
+
+import "github.com/CyberRoute/graphspecter/pkg/types"
+
+// AuthzMatrix is the authorization matrix of an endpoint: a row per query of
+// its catalog and a cell per identity, in the order of Identities, least
+// privileged first.
+type AuthzMatrix struct {
+	Endpoint   string     `json:"endpoint"`
+	Identities []string   `json:"identities"`
+	Rows       []AuthzRow `json:"rows"`
+	// Auth is the verification of the credentials of every identity that
+	// carries any, including those rejected and left out of Identities.
+	Auth []types.AuthVerification `json:"auth,omitempty"`
+}
+
+// AuthzRow is the access of every identity to a query. Cells hold the states
+// of an access matrix: CellAccessible, CellDenied or CellError.
+type AuthzRow struct {
+	Hash      string   `json:"hash"`
+	Operation string   `json:"operation"`
+	Cells     []string `json:"cells"`
+	// Diffs hold, for each identity but the last, the differences of the
+	// data it was returned from that of the last, most privileged, identity,
+	// rendered by jsondiff.Render. A diff is empty when the data are equal or
+	// either identity was not returned any.
+	Diffs []string `json:"diffs,omitempty"`
+	// Fields are the access of every identity to the fields the query
+	// selects, ordered by path.
+	Fields []AuthzField `json:"fields,omitempty"`
+}
+
+// AuthzField is the access of every identity to a field selected by a query,
+// its response keys joined by dots without list indices (e.g. users.email).
+// Cells are "allowed", "denied", "unknown" for a field that returned null
+// without an authorization error, or empty for an identity whose request
+// failed.
+type AuthzField struct {
+	Path  string   `json:"path"`
+	Cells []string `json:"cells"`
+}
+
+// Divergent reports whether the identities were not all answered alike,
+// either in their access to the query or in the data they were returned.
+func (r AuthzRow) Divergent() bool {
+	for _, c := range r.Cells {
+		if c != r.Cells[0] {
+			return true
+		}
+	}
+	for _, d := range r.Diffs {
+		if d != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Privileged returns the name of the last identity of m, the one the data of
+// the others are compared with.
+func (m *AuthzMatrix) Privileged() string {
+	return m.Identities[len(m.Identities)-1]
+}
+
+// Divergent returns the rows of m whose identities were not all answered
+// alike.
+func (m *AuthzMatrix) Divergent() []AuthzRow {
+	var rows []AuthzRow
+	for _, r := range m.Rows {
+		if r.Divergent() {
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
@@ -0,0 +1,87 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+// FindingsCSVHeader is the column order of the findings CSV.
+var FindingsCSVHeader = []string{"severity", "id", "title", "endpoint", "evidence"}
+
+// CatalogCSVHeader is the column order of the operation catalog CSV.
+var CatalogCSVHeader = []string{"operation", "kind", "arguments", "return_type", "sensitive", "sensitive_fields", "auth_hints", "tags", "notes"}
+
+// WriteCSV writes one row per finding to filename. Findings are sorted first.
+func WriteCSV(r *Report, filename string) error {
+	SortFindings(r.Findings)
+	return writeCSVFile(filename, func(w *csv.Writer) error { return RenderFindingsCSV(w, r.Findings) })
+}
+
+// RenderFindingsCSV writes the header and one row per finding in the given order.
+func RenderFindingsCSV(w *csv.Writer, findings []Finding) error {
+	if err := w.Write(FindingsCSVHeader); err != nil {
+		return err
+	}
+	for _, f := range findings {
+		if err := w.Write([]string{f.Severity, f.ID, f.Title, f.Endpoint, f.Evidence}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// WriteCatalogCSV writes one row per operation of the catalog to filename.
+func WriteCatalogCSV(c *schema.Catalog, filename string) error {
+	return writeCSVFile(filename, func(w *csv.Writer) error { return RenderCatalogCSV(w, c) })
+}
+
+// RenderCatalogCSV writes the header and one row per catalog operation.
+func RenderCatalogCSV(w *csv.Writer, c *schema.Catalog) error {
+	if err := w.Write(CatalogCSVHeader); err != nil {
+		return err
+	}
+	for _, op := range c.Operations {
+		args := make([]string, len(op.Arguments))
+		for i, a := range op.Arguments {
+			args[i] = a.Name + ": " + a.Type
+		}
+		row := []string{
+			op.Name,
+			op.Kind,
+			strings.Join(args, ", "),
+			op.ReturnType,
+			strconv.FormatBool(len(op.Sensitive) > 0),
+			strings.Join(op.Sensitive, ", "),
+			strings.Join(op.AuthHints, "; "),
+			strings.Join(op.Tags, ", "),
+			strings.Join(op.Notes, "; "),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeCSVFile creates filename and renders into it with CRLF line endings as RFC 4180 specifies.
+func writeCSVFile(filename string, render func(*csv.Writer) error) error {
+	f, err := artifacts.Create(filename, 0644)
+	if err != nil {
+		return fmt.Errorf("error creating CSV: %w", err)
+	}
+	defer f.Abort()
+
+	w := csv.NewWriter(f)
+	w.UseCRLF = true
+	if err := render(w); err != nil {
+		return fmt.Errorf("error writing CSV: %w", err)
+	}
+	return f.Commit()
+}
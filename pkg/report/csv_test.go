@@ -0,0 +1,103 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+)
+
+// readCSV parses the CSV file at path as RFC 4180.
+func readCSV(t *testing.T, path string) ([][]string, []byte) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		t.Fatalf("%s is not valid CSV: %v", path, err)
+	}
+	return rows, content
+}
+
+func TestFindingsCSVRoundTrip(t *testing.T) {
+	findings := append(goldenFindings(),
+		Finding{ID: "quoted", Severity: SeverityHigh, Endpoint: "https://a.example/graphql?x=1,2", Title: `Value "quoted", with commas`, Evidence: "line one\nline two\r\nline three"},
+		Finding{ID: "empty", Severity: SeverityCritical},
+	)
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := WriteCSV(&Report{Findings: findings}, path); err != nil {
+		t.Fatal(err)
+	}
+	rows, content := readCSV(t, path)
+
+	if len(rows) != len(findings)+1 {
+		t.Fatalf("%d rows, want a header and one per finding (%d)", len(rows), len(findings)+1)
+	}
+	if !reflect.DeepEqual(rows[0], FindingsCSVHeader) {
+		t.Errorf("header = %q, want %q", rows[0], FindingsCSVHeader)
+	}
+	// WriteCSV sorted the findings in place; the rows follow that order.
+	for i, f := range findings {
+		// encoding/csv reads a quoted CRLF back as LF.
+		want := []string{f.Severity, f.ID, f.Title, f.Endpoint, strings.ReplaceAll(f.Evidence, "\r\n", "\n")}
+		if !reflect.DeepEqual(rows[i+1], want) {
+			t.Errorf("row %d = %q, want %q", i+1, rows[i+1], want)
+		}
+	}
+	if rows[1][1] != "empty" || rows[2][1] != "quoted" {
+		t.Errorf("rows start with %q, %q; want the findings by severity", rows[1][1], rows[2][1])
+	}
+	if !bytes.HasPrefix(content, []byte(strings.Join(FindingsCSVHeader, ",")+"\r\n")) {
+		t.Errorf("records are not terminated by CRLF: %q", content[:40])
+	}
+	if !bytes.Contains(content, []byte(`"Value ""quoted"", with commas"`)) {
+		t.Errorf("quotes are not doubled inside a quoted field:\n%s", content)
+	}
+}
+
+func TestCatalogCSVRoundTrip(t *testing.T) {
+	catalog := &schema.Catalog{Operations: []schema.CatalogOperation{
+		{
+			Kind: schema.KindQuery, Name: "user", ReturnType: "User",
+			Arguments: []schema.CatalogArgument{{Name: "id", Type: "ID!"}, {Name: "filter", Type: "UserFilter"}},
+			Sensitive: []string{"field:User.password", "field:User.apiKey"},
+			AuthHints: []string{"returns User.email; a personal field"},
+			Tags:      []string{"pii", "admin"},
+			Notes:     []string{"see ticket \"SEC-1\", first line\nsecond line"},
+		},
+		{Kind: schema.KindMutation, Name: "logout", ReturnType: "Boolean!"},
+		{Kind: schema.KindSubscription, Name: "events", ReturnType: "[Event!]!"},
+	}}
+	path := filepath.Join(t.TempDir(), "catalog.csv")
+	if err := WriteCatalogCSV(catalog, path); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := readCSV(t, path)
+
+	if len(rows) != len(catalog.Operations)+1 {
+		t.Fatalf("%d rows, want a header and one per operation (%d)", len(rows), len(catalog.Operations)+1)
+	}
+	if !reflect.DeepEqual(rows[0], CatalogCSVHeader) {
+		t.Errorf("header = %q, want %q", rows[0], CatalogCSVHeader)
+	}
+	want := [][]string{
+		{"user", "query", "id: ID!, filter: UserFilter", "User", "true", "field:User.password, field:User.apiKey", "returns User.email; a personal field", "pii, admin", "see ticket \"SEC-1\", first line\nsecond line"},
+		{"logout", "mutation", "", "Boolean!", "false", "", "", "", ""},
+		{"events", "subscription", "", "[Event!]!", "false", "", "", "", ""},
+	}
+	if !reflect.DeepEqual(rows[1:], want) {
+		t.Errorf("rows = %q, want %q", rows[1:], want)
+	}
+	for i, row := range rows {
+		if len(row) != len(CatalogCSVHeader) {
+			t.Errorf("row %d has %d columns, want %d", i, len(row), len(CatalogCSVHeader))
+		}
+	}
+}
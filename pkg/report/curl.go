@@ -0,0 +1,145 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// CurlBodyLimit is the body size above which CurlFor references the body from
+// a file instead of inlining it.
+const CurlBodyLimit = 4096
+
+// RequestEvidence is the HTTP request that demonstrated a finding
+type RequestEvidence struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    string
+	// BodyFile is the file CurlFor references when Body exceeds CurlBodyLimit.
+	BodyFile string
+}
+
+// NewGraphQLRequest builds the evidence for a GraphQL POST request, encoding the
+// body the same way the network client does.
+func NewGraphQLRequest(targetURL, query string, variables map[string]interface{}, headers map[string]string) *RequestEvidence {
+	body, _ := json.Marshal(types.GraphQLRequest{Query: query, Variables: variables})
+	h := map[string]string{"Content-Type": "application/json"}
+	for k, v := range headers {
+		h[k] = v
+	}
+	return &RequestEvidence{Method: http.MethodPost, URL: targetURL, Headers: h, Body: string(body)}
+}
+
+// CurlFor renders req as a copy-pasteable curl command. GET requests carry the
+// GraphQL parameters in the query string; other methods send the body with
+// --data-binary, from BodyFile when the body is longer than CurlBodyLimit, or
+// with --data-raw when it starts with the @ curl reads files from.
+func CurlFor(req RequestEvidence) string {
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	target := req.URL
+	if method == http.MethodGet && req.Body != "" {
+		target = withQueryString(target, req.Body)
+	}
+
+	parts := []string{"curl", "-sS"}
+	if method != http.MethodGet {
+		parts = append(parts, "-X", method)
+	}
+	names := make([]string, 0, len(req.Headers))
+	for name := range req.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if method == http.MethodGet && strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		parts = append(parts, "-H", shellQuote(name+": "+req.Headers[name]))
+	}
+	if method != http.MethodGet && req.Body != "" {
+		switch {
+		case len(req.Body) > CurlBodyLimit && req.BodyFile != "":
+			parts = append(parts, "--data-binary", shellQuote("@"+req.BodyFile))
+		case strings.HasPrefix(req.Body, "@"):
+			// --data-binary would read the file the body names.
+			parts = append(parts, "--data-raw", shellQuote(req.Body))
+		default:
+			parts = append(parts, "--data-binary", shellQuote(req.Body))
+		}
+	}
+	parts = append(parts, shellQuote(target))
+	return strings.Join(parts, " ")
+}
+
+// withQueryString moves the members of a JSON GraphQL body into the query string of
+// target. A body that is not a JSON object is sent as the query parameter as-is.
+func withQueryString(target, body string) string {
+	params := url.Values{}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		params.Set("query", body)
+	} else {
+		for k, v := range decoded {
+			if s, ok := v.(string); ok {
+				params.Set(k, s)
+				continue
+			}
+			encoded, _ := json.Marshal(v)
+			params.Set(k, string(encoded))
+		}
+	}
+
+	sep := "?"
+	if strings.Contains(target, "?") {
+		sep = "&"
+	}
+	return target + sep + params.Encode()
+}
+
+// shellQuote wraps s in single quotes for POSIX shells. Embedded single quotes
+// close the quoting, are escaped with a backslash and reopen it.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// PrepareReproductions fills the Reproduction of every finding that carries a
+// request. Bodies longer than CurlBodyLimit are written below dir and referenced
+// from the command. With redactHeaders, credential headers are masked.
+func PrepareReproductions(r *Report, dir string, redactHeaders bool) error {
+	SortFindings(r.Findings)
+	for i := range r.Findings {
+		f := &r.Findings[i]
+		if f.Request == nil {
+			continue
+		}
+		req := *f.Request
+		if redactHeaders {
+			req.Headers, _ = redact.Headers(req.Headers)
+		}
+		if len(req.Body) > CurlBodyLimit {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("error creating reproduction directory: %w", err)
+			}
+			req.BodyFile = filepath.Join(dir, fmt.Sprintf("%03d-%s.json", i+1, f.ID))
+			if err := artifacts.WriteFile(req.BodyFile, []byte(req.Body), 0644); err != nil {
+				return fmt.Errorf("error writing reproduction body: %w", err)
+			}
+		}
+		f.Reproduction = CurlFor(req)
+	}
+	return nil
+}
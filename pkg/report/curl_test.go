@@ -0,0 +1,107 @@
+package report
+
+import (
+	"net/url"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// shellArgs has sh parse command, a curl command line, and returns its
+// arguments after curl.
+func shellArgs(t *testing.T, command string) []string {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh to parse the command with")
+	}
+	args, ok := strings.CutPrefix(command, "curl ")
+	if !ok {
+		t.Fatalf("command does not start with curl: %s", command)
+	}
+	out, err := exec.Command(sh, "-c", `printf '%s\0' `+args).Output()
+	if err != nil {
+		t.Fatalf("sh rejected %s: %v", command, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", `''`},
+		{"plain", `'plain'`},
+		{"it's", `'it'\''s'`},
+		{"''", `''\'''\'''`},
+		{`$HOME "x" \n`, `'$HOME "x" \n'`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// tricky are payloads the shell would expand, split or end the quoting on.
+var tricky = []string{
+	`{"query":"{ user(name: \"O'Brien\") { id } }"}`,
+	`{"query":"query { a }","variables":{"x":"$(rm -rf /) ` + "`id`" + ` $HOME ${PATH}"}}`,
+	"{\"query\":\"line one\nline two\\\\\"}",
+	`'; echo pwned; '`,
+	`!!` + " \t" + `# not a comment * ? [a] ~ & | ; < > ( )`,
+	"unicode: é 日本 \u200b",
+	"'",
+	`\'`,
+}
+
+func TestCurlForEscapesBodies(t *testing.T) {
+	for _, body := range tricky {
+		cmd := CurlFor(RequestEvidence{Method: "POST", URL: "https://api.example/graphql", Headers: map[string]string{"Content-Type": "application/json", "X-Note": "it's $x"}, Body: body})
+		want := []string{"-sS", "-X", "POST", "-H", "Content-Type: application/json", "-H", "X-Note: it's $x", "--data-binary", body, "https://api.example/graphql"}
+		if got := shellArgs(t, cmd); !reflect.DeepEqual(got, want) {
+			t.Errorf("CurlFor with body %q:\n%s\nparses as %q", body, cmd, got)
+		}
+	}
+}
+
+func TestCurlForBodyStartingWithAt(t *testing.T) {
+	cmd := CurlFor(RequestEvidence{Method: "POST", URL: "https://api.example/graphql", Body: "@/etc/passwd"})
+	if got, want := shellArgs(t, cmd), []string{"-sS", "-X", "POST", "--data-raw", "@/etc/passwd", "https://api.example/graphql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("%s parses as %q, want %q", cmd, got, want)
+	}
+}
+
+func TestCurlForLongBody(t *testing.T) {
+	long := strings.Repeat("x", CurlBodyLimit+1)
+	cmd := CurlFor(RequestEvidence{Method: "POST", URL: "https://api.example/graphql", Body: long, BodyFile: "out/it's-requests/001-batching-allowed.json"})
+	if got, want := shellArgs(t, cmd), []string{"-sS", "-X", "POST", "--data-binary", "@out/it's-requests/001-batching-allowed.json", "https://api.example/graphql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("%s parses as %q, want %q", cmd, got, want)
+	}
+
+	// At the limit the body is still inlined.
+	atLimit := strings.Repeat("x", CurlBodyLimit)
+	if cmd := CurlFor(RequestEvidence{URL: "https://api.example/graphql", Body: atLimit, BodyFile: "body.json"}); !strings.Contains(cmd, shellQuote(atLimit)) {
+		t.Errorf("a body of %d bytes was not inlined", CurlBodyLimit)
+	}
+}
+
+func TestCurlForGET(t *testing.T) {
+	req := RequestEvidence{
+		Method:  "GET",
+		URL:     "https://api.example/graphql?tenant=a'b",
+		Headers: map[string]string{"Content-Type": "application/json", "Authorization": "Bearer t"},
+		Body:    `{"query":"{ user(name: \"O'Brien & co\") { id } }","variables":{"n":1}}`,
+	}
+	args := shellArgs(t, CurlFor(req))
+	if want := []string{"-sS", "-H", "Authorization: Bearer t"}; !reflect.DeepEqual(args[:len(args)-1], want) {
+		t.Errorf("arguments %q, want %q and the URL", args, want)
+	}
+	u, err := url.Parse(args[len(args)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("tenant") != "a'b" || q.Get("query") != `{ user(name: "O'Brien & co") { id } }` || q.Get("variables") != `{"n":1}` {
+		t.Errorf("query string %q", u.RawQuery)
+	}
+}
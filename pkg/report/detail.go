@@ -0,0 +1,117 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+)
+
+// Report detail levels accepted by ShapeEvidence
+const (
+	DetailSummary  = "summary"
+	DetailStandard = "standard"
+	DetailFull     = "full"
+)
+
+// EvidenceLimit is the number of bytes of evidence a standard report keeps
+// per finding.
+const EvidenceLimit = 2048
+
+// ValidDetail reports whether detail names a report detail level.
+func ValidDetail(detail string) bool {
+	switch detail {
+	case DetailSummary, DetailStandard, DetailFull:
+		return true
+	}
+	return false
+}
+
+// ShapeEvidence cuts the evidence of the findings of r down to detail, so
+// that every format renders the same amount of it:
+//
+//   - summary keeps titles and counts but drops evidence and reproductions,
+//   - standard keeps the first EvidenceLimit bytes of evidence,
+//   - full keeps everything and saves the request of each finding below dir
+//     as an HTTP message, linked from EvidenceFile relative to reportFile.
+//
+// It runs after PrepareReproductions, whose files share dir. With
+// redactHeaders, credential headers are masked in the saved requests.
+func ShapeEvidence(r *Report, detail, reportFile, dir string, redactHeaders bool) error {
+	if detail == "" {
+		detail = DetailStandard
+	}
+	if !ValidDetail(detail) {
+		return fmt.Errorf("unknown report detail %q", detail)
+	}
+	r.Detail = detail
+	SortFindings(r.Findings)
+	for i := range r.Findings {
+		f := &r.Findings[i]
+		switch detail {
+		case DetailSummary:
+			f.Evidence = ""
+			f.Reproduction = ""
+			f.Request = nil
+		case DetailStandard:
+			f.Evidence = TruncateEvidence(f.Evidence, EvidenceLimit)
+		case DetailFull:
+			if f.Request == nil {
+				continue
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("error creating evidence directory: %w", err)
+			}
+			file := filepath.Join(dir, fmt.Sprintf("%03d-%s.http", i+1, f.ID))
+			if err := artifacts.WriteFile(file, []byte(httpMessage(*f.Request, redactHeaders)), 0644); err != nil {
+				return fmt.Errorf("error writing evidence: %w", err)
+			}
+			rel, err := filepath.Rel(filepath.Dir(reportFile), file)
+			if err != nil {
+				return fmt.Errorf("error linking evidence: %w", err)
+			}
+			f.EvidenceFile = filepath.ToSlash(rel)
+		}
+	}
+	return nil
+}
+
+// TruncateEvidence returns the first limit bytes of evidence, cut at a
+// character boundary and followed by a note giving the full size.
+func TruncateEvidence(evidence string, limit int) string {
+	if len(evidence) <= limit {
+		return evidence
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(evidence[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... [truncated, %d bytes in total]", evidence[:cut], len(evidence))
+}
+
+// httpMessage renders req as an HTTP/1.1 request message with its headers in
+// sorted order.
+func httpMessage(req RequestEvidence, redactHeaders bool) string {
+	headers := req.Headers
+	if redactHeaders {
+		headers, _ = redact.Headers(headers)
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", req.Method, req.URL)
+	for _, k := range names {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, headers[k])
+	}
+	b.WriteString("\r\n")
+	b.WriteString(req.Body)
+	return b.String()
+}
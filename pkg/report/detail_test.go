@@ -0,0 +1,112 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// detailReport returns a report with a finding carrying evidence of size
+// bytes and a request.
+func detailReport(size int) *Report {
+	return &Report{Findings: []Finding{{
+		ID:           "introspection-enabled",
+		Title:        "Introspection is enabled",
+		Severity:     SeverityLow,
+		Endpoint:     "https://api.example/graphql",
+		Evidence:     strings.Repeat("e", size),
+		Reproduction: "curl https://api.example/graphql",
+		Request:      NewGraphQLRequest("https://api.example/graphql", "{ __schema { types { name } } }", nil, map[string]string{"Authorization": "Bearer secret"}),
+	}}}
+}
+
+func TestTruncateEvidence(t *testing.T) {
+	tests := []struct {
+		name     string
+		evidence string
+		want     string
+	}{
+		{"under the limit", strings.Repeat("a", EvidenceLimit-1), strings.Repeat("a", EvidenceLimit-1)},
+		{"at the limit", strings.Repeat("a", EvidenceLimit), strings.Repeat("a", EvidenceLimit)},
+		{"over the limit", strings.Repeat("a", EvidenceLimit+1), strings.Repeat("a", EvidenceLimit) + "... [truncated, 2049 bytes in total]"},
+		// "é" is two bytes: the limit falls in the middle of the last one,
+		// which is dropped rather than split.
+		{"inside a character", strings.Repeat("a", EvidenceLimit-1) + "é", strings.Repeat("a", EvidenceLimit-1) + "... [truncated, 2049 bytes in total]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TruncateEvidence(tt.evidence, EvidenceLimit); got != tt.want {
+				t.Errorf("TruncateEvidence() = %d bytes ending %q, want %d bytes ending %q", len(got), got[len(got)-40:], len(tt.want), tt.want[len(tt.want)-40:])
+			}
+		})
+	}
+}
+
+func TestShapeEvidenceSummary(t *testing.T) {
+	r := detailReport(10)
+	if err := ShapeEvidence(r, DetailSummary, "report.json", t.TempDir(), true); err != nil {
+		t.Fatal(err)
+	}
+	f := r.Findings[0]
+	if f.Evidence != "" || f.Reproduction != "" || f.Request != nil {
+		t.Errorf("summary kept evidence %q, reproduction %q, request %v", f.Evidence, f.Reproduction, f.Request)
+	}
+	if f.Title == "" || r.Detail != DetailSummary {
+		t.Errorf("summary dropped the title or did not record the detail: %+v", r)
+	}
+}
+
+func TestShapeEvidenceStandard(t *testing.T) {
+	for _, size := range []int{EvidenceLimit, EvidenceLimit + 1} {
+		r := detailReport(size)
+		if err := ShapeEvidence(r, "", "report.json", t.TempDir(), true); err != nil {
+			t.Fatal(err)
+		}
+		f := r.Findings[0]
+		if truncated := strings.Contains(f.Evidence, "[truncated"); truncated != (size > EvidenceLimit) {
+			t.Errorf("%d bytes of evidence: truncated = %v", size, truncated)
+		}
+		if f.EvidenceFile != "" {
+			t.Errorf("standard linked evidence file %s", f.EvidenceFile)
+		}
+	}
+}
+
+func TestShapeEvidenceFullLinksResolve(t *testing.T) {
+	out := t.TempDir()
+	reportFile := filepath.Join(out, "reports", "findings.html")
+	dir := filepath.Join(out, "reports", "findings-requests")
+	r := detailReport(EvidenceLimit * 2)
+	if err := ShapeEvidence(r, DetailFull, reportFile, dir, true); err != nil {
+		t.Fatal(err)
+	}
+	f := r.Findings[0]
+	if len(f.Evidence) != EvidenceLimit*2 {
+		t.Errorf("full cut evidence to %d bytes", len(f.Evidence))
+	}
+	if f.EvidenceFile == "" || filepath.IsAbs(f.EvidenceFile) {
+		t.Fatalf("evidence file = %q, want a relative path", f.EvidenceFile)
+	}
+	path := filepath.Join(filepath.Dir(reportFile), filepath.FromSlash(f.EvidenceFile))
+	if rel, err := filepath.Rel(out, path); err != nil || strings.HasPrefix(rel, "..") {
+		t.Fatalf("evidence file %s resolves outside of %s", path, out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(data)
+	if !strings.HasPrefix(msg, "POST https://api.example/graphql HTTP/1.1\r\n") || !strings.Contains(msg, "__schema") {
+		t.Errorf("evidence file holds %q", msg)
+	}
+	if strings.Contains(msg, "Bearer secret") {
+		t.Errorf("credential header was not redacted: %q", msg)
+	}
+}
+
+func TestShapeEvidenceUnknownDetail(t *testing.T) {
+	if err := ShapeEvidence(detailReport(1), "verbose", "report.json", t.TempDir(), true); err == nil {
+		t.Error("unknown detail level was accepted")
+	}
+}
@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// golden compares got with testdata/name, rewriting it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, got)
+	}
+}
+
+// goldenFindings are findings of two endpoints and several severities, in
+// the order checks might complete in.
+func goldenFindings() []Finding {
+	return []Finding{
+		{ID: "field-suggestions", Check: "suggestions", Title: "Field suggestions are enabled", Severity: SeverityLow, Endpoint: "https://b.example/graphql"},
+		{ID: "introspection-enabled", Check: "introspection", Title: "Introspection is enabled", Severity: SeverityMedium, Endpoint: "https://b.example/graphql"},
+		{ID: "batching-allowed", Check: "batching", Title: "Operations batched in a JSON array are executed", Severity: SeverityLow, Endpoint: "https://a.example/graphql"},
+		{ID: "introspection-enabled", Check: "introspection", Title: "Introspection is enabled", Severity: SeverityMedium, Endpoint: "https://a.example/graphql"},
+		{ID: "csrf-get-queries", Check: "csrf", Title: "Queries sent with GET are executed", Severity: SeverityMedium, Endpoint: "https://a.example/graphql"},
+		{ID: "engine-detected", Check: "engine", Title: "GraphQL engine fingerprinted", Severity: SeverityInfo, Endpoint: "https://a.example/graphql"},
+	}
+}
+
+// TestReportsAreDeterministic writes the findings in several completion
+// orders, in every format, and expects the same bytes each time.
+func TestReportsAreDeterministic(t *testing.T) {
+	orders := [][]int{{0, 1, 2, 3, 4, 5}, {5, 4, 3, 2, 1, 0}, {3, 0, 5, 1, 4, 2}}
+	for _, format := range []string{FormatJSON, FormatMarkdown, FormatHTML, FormatCSV, FormatSARIF} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			var outputs [][]byte
+			for i, order := range orders {
+				all := goldenFindings()
+				findings := make([]Finding, len(order))
+				for j, k := range order {
+					findings[j] = all[k]
+				}
+				r := &Report{
+					Metadata:  Metadata{Tool: "graphspecter", Version: "1.0.0"},
+					Endpoints: []string{"https://a.example/graphql", "https://b.example/graphql"},
+					Findings:  findings,
+				}
+				file := filepath.Join(dir, fmt.Sprintf("%s-%d", format, i))
+				if err := WriteFormat(r, file, format); err != nil {
+					t.Fatal(err)
+				}
+				data, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				outputs = append(outputs, data)
+			}
+			for i := 1; i < len(outputs); i++ {
+				if !bytes.Equal(outputs[0], outputs[i]) {
+					t.Errorf("run %d differs from run 0", i)
+				}
+			}
+			if format == FormatJSON {
+				golden(t, "findings.golden.json", outputs[0])
+			}
+		})
+	}
+}
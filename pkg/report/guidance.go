@@ -0,0 +1,44 @@
+package report
+
+import "github.com/CyberRoute/graphspecter/pkg/report/kb"
+
+// Guidance is the knowledge base explanation of a finding, specialized for the
+// engine fingerprinted on its endpoint.
+type Guidance struct {
+	Background  string
+	Impact      string
+	Remediation []string
+	// Engine is the engine EngineSteps apply to; both are empty when the
+	// entry has no steps for the engine of the endpoint.
+	Engine      string
+	EngineSteps []string
+}
+
+// Guidance returns the explanation of f, or nil when the knowledge base has no
+// entry for its id.
+func (r *Report) Guidance(f Finding) *Guidance {
+	e, ok := kb.Lookup(f.ID)
+	if !ok {
+		return nil
+	}
+	g := &Guidance{Background: e.Background, Impact: e.Impact, Remediation: e.Remediation}
+	g.Engine, g.EngineSteps = e.ForEngine(r.engineFor(f.Endpoint))
+	return g
+}
+
+// engineFor returns the engine fingerprinted on endpoint. Findings reported on
+// another URL of the target, such as its WebSocket endpoint, use the engine of
+// the run when every endpoint has the same one.
+func (r *Report) engineFor(endpoint string) string {
+	if e, ok := r.Engines[endpoint]; ok {
+		return e
+	}
+	engine := ""
+	for _, e := range r.Engines {
+		if engine != "" && e != engine {
+			return ""
+		}
+		engine = e
+	}
+	return engine
+}
@@ -0,0 +1,166 @@
+// Package kb is the knowledge base explaining report findings: the background
+// of each finding id, what an attacker gains from it and how to remediate it,
+// with steps specific to the GraphQL engines that differ. Entries are embedded
+// JSON documents, one per family of findings.
+package kb
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// formatVersion is the version of the knowledge base document format.
+const formatVersion = 1
+
+//go:embed entries/*.json
+var embedded embed.FS
+
+// Entry explains a finding id. An id ending in "*" is a prefix covering the
+// findings generated from a table, such as schema-secret-*; an entry for a
+// full id takes precedence over the prefix.
+type Entry struct {
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	Background string `json:"background"`
+	Impact     string `json:"impact"`
+	// Remediation are the steps that apply whatever the engine.
+	Remediation []string `json:"remediation"`
+	// Engines are additional steps keyed by the engine names of the
+	// fingerprint dataset, such as "Apollo Server" or "Hasura".
+	Engines    map[string][]string `json:"engines,omitempty"`
+	References []string            `json:"references,omitempty"`
+}
+
+// document is the format of the embedded files.
+type document struct {
+	Version int     `json:"version"`
+	Entries []Entry `json:"entries"`
+}
+
+// entries are the embedded entries by id, loaded once at init.
+var entries = load()
+
+// load reads and checks the embedded documents. They ship with the binary, so
+// an invalid one is a programming error.
+func load() map[string]Entry {
+	files, err := embedded.ReadDir("entries")
+	if err != nil {
+		panic(fmt.Sprintf("kb: %v", err))
+	}
+	out := make(map[string]Entry)
+	for _, f := range files {
+		name := path.Join("entries", f.Name())
+		content, err := embedded.ReadFile(name)
+		if err != nil {
+			panic(fmt.Sprintf("kb: %v", err))
+		}
+		var doc document
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&doc); err != nil {
+			panic(fmt.Sprintf("kb: error parsing %s: %v", name, err))
+		}
+		if doc.Version != formatVersion {
+			panic(fmt.Sprintf("kb: %s has version %d, want %d", name, doc.Version, formatVersion))
+		}
+		for _, e := range doc.Entries {
+			if err := validate(e); err != nil {
+				panic(fmt.Sprintf("kb: %s: %v", name, err))
+			}
+			if _, dup := out[e.ID]; dup {
+				panic(fmt.Sprintf("kb: %s: duplicate entry %q", name, e.ID))
+			}
+			out[e.ID] = e
+		}
+	}
+	return out
+}
+
+// validate checks that e has an id and the sections every entry must have.
+func validate(e Entry) error {
+	switch {
+	case e.ID == "" || strings.ContainsAny(e.ID[:len(e.ID)-1], "* "):
+		return fmt.Errorf("invalid entry id %q", e.ID)
+	case e.Title == "" || e.Background == "" || e.Impact == "":
+		return fmt.Errorf("entry %q needs a title, background and impact", e.ID)
+	case len(e.Remediation) == 0:
+		return fmt.Errorf("entry %q has no remediation steps", e.ID)
+	}
+	return nil
+}
+
+// Lookup returns the entry explaining the finding id: the entry for id itself,
+// or else the one with the longest prefix of it.
+func Lookup(id string) (Entry, bool) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if e, ok := entries[id]; ok && !e.prefix() {
+		return e, true
+	}
+	var best Entry
+	found := false
+	for _, e := range entries {
+		if !e.prefix() {
+			continue
+		}
+		p := strings.TrimSuffix(e.ID, "*")
+		if strings.HasPrefix(id, p) && len(id) > len(p) && (!found || len(p) > len(best.ID)-1) {
+			best, found = e, true
+		}
+	}
+	return best, found
+}
+
+// All returns the entries sorted by id.
+func All() []Entry {
+	all := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		all = append(all, e)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all
+}
+
+// IDs returns the ids of the entries, sorted.
+func IDs() []string {
+	all := All()
+	ids := make([]string, len(all))
+	for i, e := range all {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+// prefix reports whether e covers every id starting with its id.
+func (e Entry) prefix() bool {
+	return strings.HasSuffix(e.ID, "*")
+}
+
+// ForEngine returns the steps specific to engine, matched case-insensitively,
+// and the name of the engine as the entry spells it. It returns nothing when
+// the entry has no steps for engine.
+func (e Entry) ForEngine(engine string) (string, []string) {
+	if engine == "" {
+		return "", nil
+	}
+	for name, steps := range e.Engines {
+		if strings.EqualFold(name, engine) {
+			return name, steps
+		}
+	}
+	return "", nil
+}
+
+// EngineNames returns the engines e has specific steps for, sorted.
+func (e Entry) EngineNames() []string {
+	names := make([]string, 0, len(e.Engines))
+	for name := range e.Engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,66 @@
+package kb
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		id, want string
+		found    bool
+	}{
+		{"introspection-enabled", "introspection-enabled", true},
+		{"  Introspection-Enabled ", "introspection-enabled", true},
+		// A full-id entry takes precedence over the prefix covering it.
+		{"schema-secret-aws-access-key", "schema-secret-aws-access-key", true},
+		{"schema-secret-github-token", "schema-secret-*", true},
+		{"vulndb-CVE-2023-1234", "vulndb-*", true},
+		// A prefix entry covers longer ids only.
+		{"schema-secret-", "", false},
+		{"schema-secret-*", "schema-secret-*", true},
+		{"no-such-finding", "", false},
+	}
+	for _, tt := range tests {
+		e, ok := Lookup(tt.id)
+		if ok != tt.found || e.ID != tt.want {
+			t.Errorf("Lookup(%q) = %q, %v; want %q, %v", tt.id, e.ID, ok, tt.want, tt.found)
+		}
+	}
+}
+
+func TestEntries(t *testing.T) {
+	ids := IDs()
+	if !sort.StringsAreSorted(ids) || len(ids) != len(All()) {
+		t.Errorf("IDs() = %v", ids)
+	}
+	for _, e := range All() {
+		if err := validate(e); err != nil {
+			t.Error(err)
+		}
+		if e.ID != strings.ToLower(e.ID) {
+			t.Errorf("entry %s is not lowercase, Lookup would never find it", e.ID)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Entry{ID: "x-*", Title: "t", Background: "b", Impact: "i", Remediation: []string{"r"}}
+	if err := validate(valid); err != nil {
+		t.Fatal(err)
+	}
+	for name, mutate := range map[string]func(*Entry){
+		"inner star":     func(e *Entry) { e.ID = "x-*-y" },
+		"space":          func(e *Entry) { e.ID = "x y" },
+		"no title":       func(e *Entry) { e.Title = "" },
+		"no impact":      func(e *Entry) { e.Impact = "" },
+		"no remediation": func(e *Entry) { e.Remediation = nil },
+	} {
+		e := valid
+		mutate(&e)
+		if err := validate(e); err == nil {
+			t.Errorf("%s: %+v is valid", name, e)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Report formats accepted by WriteFormat
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatCSV      = "csv"
+	FormatSARIF    = "sarif"
+)
+
+// ValidFormat reports whether format names a report format; "md" is accepted for Markdown.
+func ValidFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case FormatJSON, FormatMarkdown, "md", FormatHTML, FormatCSV, FormatSARIF:
+		return true
+	}
+	return false
+}
+
+// FormatFor returns the format selected by the extension of filename:
+// .md for Markdown, .html or .htm for HTML, .csv for CSV, .sarif for SARIF
+// and JSON otherwise.
+func FormatFor(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return FormatMarkdown
+	case ".html", ".htm":
+		return FormatHTML
+	case ".csv":
+		return FormatCSV
+	case ".sarif":
+		return FormatSARIF
+	default:
+		return FormatJSON
+	}
+}
+
+// Write writes the report in the format selected by the extension of filename.
+func Write(r *Report, filename string) error {
+	return WriteFormat(r, filename, "")
+}
+
+// WriteFormat writes the report in format, or in the format selected by the
+// extension of filename when format is empty.
+func WriteFormat(r *Report, filename, format string) error {
+	if format == "" {
+		format = FormatFor(filename)
+	}
+	switch strings.ToLower(format) {
+	case FormatMarkdown, "md":
+		return WriteMarkdown(r, filename)
+	case FormatHTML:
+		return WriteHTML(r, filename)
+	case FormatCSV:
+		return WriteCSV(r, filename)
+	case FormatSARIF:
+		return WriteSARIF(r, filename)
+	case FormatJSON:
+		return WriteJSON(r, filename)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// WriteMarkdown writes the report as a Markdown document. Findings are sorted first.
+func WriteMarkdown(r *Report, filename string) error {
+	SortFindings(r.Findings)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# GraphSpecter report\n\n")
+	fmt.Fprintf(&b, "Generated by %s %s (commit %s).\n\n", r.Metadata.Tool, r.Metadata.Version, r.Metadata.Commit)
+	if len(r.Metadata.Checks) > 0 {
+		fmt.Fprintf(&b, "Checks: %s", strings.Join(r.Metadata.Checks, ", "))
+		if r.Metadata.Preset != "" {
+			fmt.Fprintf(&b, " (%s preset)", r.Metadata.Preset)
+		}
+		fmt.Fprintf(&b, ".\n\n")
+	}
+	for _, c := range r.Canaries {
+		if c.Drift {
+			fmt.Fprintf(&b, "> **WARNING: the scan changed server state.** The canary query `%s` returned a different response from %s after the scan.\n\n", c.Query, c.Endpoint)
+			if c.Diff != "" {
+				fmt.Fprintf(&b, "```\n%s\n```\n\n", c.Diff)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "## Endpoints\n\n")
+	for _, e := range r.Endpoints {
+		if enc := r.Encodings[e]; enc != "" {
+			fmt.Fprintf(&b, "- %s (%s encoding)\n", e, enc)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s\n", e)
+	}
+	if p := r.Profile; p != nil {
+		fmt.Fprintf(&b, "\n## Bounty profile %s\n\n", p.Program)
+		fmt.Fprintf(&b, "- **Allowed hosts:** %s\n", strings.Join(p.AllowedHosts, ", "))
+		if p.MaxRate > 0 {
+			fmt.Fprintf(&b, "- **Maximum rate:** %g req/s\n", p.MaxRate)
+		}
+		if p.MaxConcurrency > 0 {
+			fmt.Fprintf(&b, "- **Maximum concurrency:** %d\n", p.MaxConcurrency)
+		}
+		if len(p.ForbiddenChecks) > 0 {
+			fmt.Fprintf(&b, "- **Forbidden checks:** %s\n", strings.Join(p.ForbiddenChecks, ", "))
+		}
+		names := make([]string, 0, len(p.Headers))
+		for name := range p.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, "- **Required header:** `%s: %s`\n", name, p.Headers[name])
+		}
+	}
+	if len(r.AuthCandidates) > 0 {
+		fmt.Fprintf(&b, "\n## Candidate endpoints requiring authentication\n\n")
+		for _, c := range r.AuthCandidates {
+			confirmed := ""
+			if c.Confirmed {
+				confirmed = " (GraphQL with the supplied credentials)"
+			}
+			fmt.Fprintf(&b, "- %s: %s%s\n", c.URL, c.Reason, confirmed)
+		}
+	}
+
+	if len(r.Metadata.Auth) > 0 {
+		fmt.Fprintf(&b, "\n## Authentication\n\n")
+		for _, v := range r.Metadata.Auth {
+			fmt.Fprintf(&b, "- %s: %s\n", v.Target(), v.Summary())
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Findings (%d)\n", len(r.Findings))
+	if len(r.Findings) == 0 {
+		fmt.Fprintf(&b, "\nNo findings.\n")
+	}
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "\n### [%s] %s\n\n", strings.ToUpper(f.Severity), f.Title)
+		fmt.Fprintf(&b, "- **ID:** `%s`\n- **Check:** `%s`\n- **Endpoint:** %s\n", f.ID, f.Check, f.Endpoint)
+		if f.Description != "" {
+			fmt.Fprintf(&b, "\n%s\n", f.Description)
+		}
+		if f.Evidence != "" {
+			fmt.Fprintf(&b, "\n**Evidence:** %s\n", f.Evidence)
+		}
+		if len(f.References) > 0 {
+			fmt.Fprintf(&b, "\n**References:**\n\n")
+			for _, ref := range f.References {
+				fmt.Fprintf(&b, "- %s\n", ref)
+			}
+		}
+		if f.Reproduction != "" {
+			fmt.Fprintf(&b, "\n**Reproduction:**\n\n```sh\n%s\n```\n", f.Reproduction)
+		}
+		if f.EvidenceFile != "" {
+			fmt.Fprintf(&b, "\n**Request:** [%s](%s)\n", f.EvidenceFile, f.EvidenceFile)
+		}
+		if g := r.Guidance(f); g != nil {
+			fmt.Fprintf(&b, "\n**Background:** %s\n\n**Impact:** %s\n\n**Remediation:**\n\n", g.Background, g.Impact)
+			for _, step := range g.Remediation {
+				fmt.Fprintf(&b, "- %s\n", step)
+			}
+			if g.Engine != "" {
+				fmt.Fprintf(&b, "\n**Remediation on %s:**\n\n", g.Engine)
+				for _, step := range g.EngineSteps {
+					fmt.Fprintf(&b, "- %s\n", step)
+				}
+			}
+		}
+	}
+
+	if len(r.OperationNotes) > 0 {
+		fmt.Fprintf(&b, "\n## Operation notes\n\n| Endpoint | Kind | Operation | Tags | Notes |\n|---|---|---|---|---|\n")
+		for _, n := range r.OperationNotes {
+			notes := strings.ReplaceAll(strings.Join(n.Notes, "; "), "|", `\|`)
+			fmt.Fprintf(&b, "| %s | %s | `%s` | %s | %s |\n", n.Endpoint, n.Kind, n.Name, strings.Join(n.Tags, ", "), notes)
+		}
+	}
+	if len(r.NonQueryOperations) > 0 {
+		fmt.Fprintf(&b, "\n## Non-query operations sent\n\n| Endpoint | Kind | Name | Count |\n|---|---|---|---|\n")
+		for _, op := range r.NonQueryOperations {
+			fmt.Fprintf(&b, "| %s | %s | %s | %d |\n", op.Endpoint, op.Kind, op.Name, op.Count)
+		}
+	}
+	if len(r.SuppressedRequests) > 0 {
+		fmt.Fprintf(&b, "\n## Out-of-scope requests suppressed\n\n| URL | Count |\n|---|---|\n")
+		for _, s := range r.SuppressedRequests {
+			fmt.Fprintf(&b, "| %s | %d |\n", s.URL, s.Count)
+		}
+	}
+	if len(r.Capabilities) > 0 {
+		fmt.Fprintf(&b, "\n## Capabilities\n\n| Endpoint | @defer | @stream | SSE | Response media |\n|---|---|---|---|---|\n")
+		for _, c := range r.Capabilities {
+			media := c.ResponseMedia
+			if media == "" {
+				media = "-"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %t | %t | %s |\n", c.Endpoint, deferSupport(c), c.Stream, c.SSE, media)
+		}
+	}
+	if len(r.VirtualHosts) > 0 {
+		fmt.Fprintf(&b, "\n## Virtual hosts\n\n| Host | Endpoint | Engine | Schema | Differs |\n|---|---|---|---|---|\n")
+		for _, v := range r.VirtualHosts {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %t |\n", v.Host, v.Endpoint, v.Engine, v.SchemaHash, v.Differs)
+		}
+	}
+	if r.Matrix != nil {
+		divergent := r.Matrix.Divergent()
+		fmt.Fprintf(&b, "\n## Access matrix\n\n%d of %d operations differ across the %d targets.\n", len(divergent), len(r.Matrix.Rows), len(r.Matrix.Targets))
+		if len(divergent) > 0 {
+			b.WriteString("\n| Operation |")
+			for _, t := range r.Matrix.Targets {
+				fmt.Fprintf(&b, " %s |", t)
+			}
+			fmt.Fprintf(&b, "\n|---|%s\n", strings.Repeat("---|", len(r.Matrix.Targets)))
+			for _, row := range divergent {
+				fmt.Fprintf(&b, "| `%s` |", row.Operation)
+				for i, c := range row.Cells {
+					if c == "" {
+						c = "-"
+					}
+					if r.Matrix.Targets[i] == row.Outlier {
+						c = "**" + c + "**"
+					}
+					fmt.Fprintf(&b, " %s |", c)
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+	for _, m := range r.Authz {
+		divergent := m.Divergent()
+		fmt.Fprintf(&b, "\n## Authorization matrix of %s\n\n%d of %d queries are not answered alike for the %d identities.\n", m.Endpoint, len(divergent), len(m.Rows), len(m.Identities))
+		if len(divergent) > 0 {
+			b.WriteString("\n| Operation |")
+			for _, id := range m.Identities {
+				fmt.Fprintf(&b, " %s |", id)
+			}
+			fmt.Fprintf(&b, "\n|---|%s\n", strings.Repeat("---|", len(m.Identities)))
+			for _, row := range divergent {
+				fmt.Fprintf(&b, "| `%s` | %s |\n", row.Operation, strings.Join(row.Cells, " | "))
+			}
+			for _, row := range divergent {
+				for i, d := range row.Diffs {
+					if d != "" {
+						fmt.Fprintf(&b, "\nData of `%s` as %s, compared with %s:\n\n```\n%s\n```\n", row.Operation, m.Identities[i], m.Privileged(), d)
+					}
+				}
+			}
+		}
+	}
+	if len(r.Canaries) > 0 {
+		fmt.Fprintf(&b, "\n## Canary\n\n| Endpoint | Result |\n|---|---|\n")
+		for _, c := range r.Canaries {
+			fmt.Fprintf(&b, "| %s | %s |\n", c.Endpoint, strings.ReplaceAll(c.Outcome(), "|", `\|`))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Checks\n\n| Check | Endpoint | Status | Time |\n|---|---|---|---|\n")
+	for _, c := range r.Checks {
+		status := c.Status
+		if c.Error != "" {
+			status += ": " + c.Error
+		}
+		if c.Reason != "" {
+			status += " (" + c.Reason + ")"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %d ms |\n", c.Check, c.Endpoint, strings.ReplaceAll(status, "|", `\|`), c.DurationMs)
+	}
+
+	if err := artifacts.WriteFile(filename, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
+
+// deferSupport describes the @defer support of c: "incremental (multipart)"
+// when deferred results arrive in payloads of their own, "inlined" when they
+// come with the rest of the response, "false" when @defer is rejected.
+func deferSupport(c types.Capabilities) string {
+	switch {
+	case c.DeferIncremental:
+		return "incremental (" + c.DeferDelivery + ")"
+	case c.Defer:
+		return "inlined"
+	}
+	return "false"
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"upper":        strings.ToUpper,
+	"join":         strings.Join,
+	"deferSupport": deferSupport,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>GraphSpecter report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f4f4f4; padding: 1em; overflow-x: auto; white-space: pre-wrap; word-break: break-all; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.critical, .high { color: #b00020; } .medium { color: #c75b00; } .low { color: #8a6d00; } .info { color: #555; }
+td.accessible { background: #c8e6c9; } td.denied { background: #ffcdd2; } td.error { background: #ffe0b2; } td.missing { background: #eee; } td.outlier { font-weight: bold; outline: 2px solid #b00020; }
+</style>
+</head>
+<body>
+<h1>GraphSpecter report</h1>
+<p>Generated by {{.Metadata.Tool}} {{.Metadata.Version}} (commit {{.Metadata.Commit}}).</p>
+{{with .Metadata.Checks}}<p>Checks: {{join . ", "}}{{with $.Metadata.Preset}} ({{.}} preset){{end}}.</p>
+{{end}}{{range .Canaries}}{{if .Drift}}<p class="critical"><strong>WARNING: the scan changed server state.</strong> The canary query <code>{{.Query}}</code> returned a different response from {{.Endpoint}} after the scan.</p>
+{{if .Diff}}<pre>{{.Diff}}</pre>
+{{end}}{{end}}{{end}}<h2>Endpoints</h2>
+<ul>{{range .Endpoints}}<li>{{.}}{{with index $.Encodings .}} ({{.}} encoding){{end}}</li>{{end}}</ul>
+{{with .Profile}}<h2>Bounty profile {{.Program}}</h2>
+<ul><li><strong>Allowed hosts:</strong> {{join .AllowedHosts ", "}}</li>{{if .MaxRate}}<li><strong>Maximum rate:</strong> {{.MaxRate}} req/s</li>{{end}}{{if .MaxConcurrency}}<li><strong>Maximum concurrency:</strong> {{.MaxConcurrency}}</li>{{end}}{{if .ForbiddenChecks}}<li><strong>Forbidden checks:</strong> {{join .ForbiddenChecks ", "}}</li>{{end}}{{range $name, $value := .Headers}}<li><strong>Required header:</strong> <code>{{$name}}: {{$value}}</code></li>{{end}}</ul>{{end}}
+{{if .AuthCandidates}}<h2>Candidate endpoints requiring authentication</h2>
+<ul>{{range .AuthCandidates}}<li>{{.URL}}: {{.Reason}}{{if .Confirmed}} (GraphQL with the supplied credentials){{end}}</li>{{end}}</ul>{{end}}
+{{with .Metadata.Auth}}<h2>Authentication</h2>
+<ul>{{range .}}<li>{{.Target}}: {{.Summary}}</li>{{end}}</ul>{{end}}
+<h2>Findings ({{len .Findings}})</h2>
+{{if not .Findings}}<p>No findings.</p>{{end}}
+{{range .Findings}}<section>
+<h3 class="{{.Severity}}">[{{upper .Severity}}] {{.Title}}</h3>
+<p><strong>ID:</strong> <code>{{.ID}}</code> &middot; <strong>Check:</strong> <code>{{.Check}}</code> &middot; <strong>Endpoint:</strong> {{.Endpoint}}</p>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{if .Evidence}}<p><strong>Evidence:</strong> {{.Evidence}}</p>{{end}}
+{{if .References}}<p><strong>References:</strong></p>
+<ul>{{range .References}}<li><a href="{{.}}">{{.}}</a></li>{{end}}</ul>{{end}}
+{{if .Reproduction}}<p><strong>Reproduction:</strong></p>
+<pre>{{.Reproduction}}</pre>{{end}}
+{{if .EvidenceFile}}<p><strong>Request:</strong> <a href="{{.EvidenceFile}}">{{.EvidenceFile}}</a></p>{{end}}
+{{with $.Guidance .}}<p><strong>Background:</strong> {{.Background}}</p>
+<p><strong>Impact:</strong> {{.Impact}}</p>
+<p><strong>Remediation:</strong></p>
+<ul>{{range .Remediation}}<li>{{.}}</li>{{end}}</ul>
+{{if .Engine}}<p><strong>Remediation on {{.Engine}}:</strong></p>
+<ul>{{range .EngineSteps}}<li>{{.}}</li>{{end}}</ul>{{end}}{{end}}
+</section>
+{{end}}
+{{if .OperationNotes}}<h2>Operation notes</h2>
+<table>
+<tr><th>Endpoint</th><th>Kind</th><th>Operation</th><th>Tags</th><th>Notes</th></tr>
+{{range .OperationNotes}}<tr><td>{{.Endpoint}}</td><td>{{.Kind}}</td><td><code>{{.Name}}</code></td><td>{{join .Tags ", "}}</td><td>{{join .Notes "; "}}</td></tr>
+{{end}}</table>{{end}}
+{{if .NonQueryOperations}}<h2>Non-query operations sent</h2>
+<table>
+<tr><th>Endpoint</th><th>Kind</th><th>Name</th><th>Count</th></tr>
+{{range .NonQueryOperations}}<tr><td>{{.Endpoint}}</td><td>{{.Kind}}</td><td>{{.Name}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+{{if .SuppressedRequests}}<h2>Out-of-scope requests suppressed</h2>
+<table>
+<tr><th>URL</th><th>Count</th></tr>
+{{range .SuppressedRequests}}<tr><td>{{.URL}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Capabilities}}<h2>Capabilities</h2>
+<table>
+<tr><th>Endpoint</th><th>@defer</th><th>@stream</th><th>SSE</th><th>Response media</th></tr>
+{{range .Capabilities}}<tr><td>{{.Endpoint}}</td><td>{{deferSupport .}}</td><td>{{.Stream}}</td><td>{{.SSE}}</td><td>{{or .ResponseMedia "-"}}</td></tr>
+{{end}}</table>{{end}}
+{{if .VirtualHosts}}<h2>Virtual hosts</h2>
+<table>
+<tr><th>Host</th><th>Endpoint</th><th>Engine</th><th>Schema</th><th>Differs</th></tr>
+{{range .VirtualHosts}}<tr><td>{{.Host}}</td><td>{{.Endpoint}}</td><td>{{.Engine}}</td><td>{{.SchemaHash}}</td><td>{{.Differs}}</td></tr>
+{{end}}</table>{{end}}
+{{with .Matrix}}<h2>Access matrix</h2>
+{{$divergent := .Divergent}}<p>{{len $divergent}} of {{len .Rows}} operations differ across the {{len .Targets}} targets.</p>
+{{if $divergent}}<table>
+<tr><th>Operation</th>{{range .Targets}}<th>{{.}}</th>{{end}}</tr>
+{{range $row := $divergent}}<tr><td><code>{{$row.Operation}}</code></td>{{range $i, $cell := $row.Cells}}<td class="{{if $cell}}{{$cell}}{{else}}missing{{end}}{{if eq (index $.Matrix.Targets $i) $row.Outlier}} outlier{{end}}">{{if $cell}}{{$cell}}{{else}}-{{end}}</td>{{end}}</tr>
+{{end}}</table>{{end}}{{end}}
+{{range .Authz}}<h2>Authorization matrix of {{.Endpoint}}</h2>
+{{$divergent := .Divergent}}<p>{{len $divergent}} of {{len .Rows}} queries are not answered alike for the {{len .Identities}} identities.</p>
+{{if $divergent}}<table>
+<tr><th>Operation</th>{{range .Identities}}<th>{{.}}</th>{{end}}</tr>
+{{range $divergent}}<tr><td><code>{{.Operation}}</code></td>{{range .Cells}}<td class="{{.}}">{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{$m := .}}{{range $divergent}}{{$op := .Operation}}{{range $i, $d := .Diffs}}{{if $d}}<p>Data of <code>{{$op}}</code> as {{index $m.Identities $i}}, compared with {{$m.Privileged}}:</p>
+<pre>{{$d}}</pre>
+{{end}}{{end}}{{end}}{{end}}{{end}}
+{{if .Canaries}}<h2>Canary</h2>
+<table>
+<tr><th>Endpoint</th><th>Result</th></tr>
+{{range .Canaries}}<tr><td>{{.Endpoint}}</td><td>{{.Outcome}}</td></tr>
+{{end}}</table>{{end}}
+<h2>Checks</h2>
+<table>
+<tr><th>Check</th><th>Endpoint</th><th>Status</th><th>Time</th></tr>
+{{range .Checks}}<tr><td>{{.Check}}</td><td>{{.Endpoint}}</td><td>{{.Status}}{{if .Error}}: {{.Error}}{{end}}{{if .Reason}} ({{.Reason}}){{end}}</td><td>{{.DurationMs}} ms</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as a standalone HTML page. Findings are sorted first.
+func WriteHTML(r *Report, filename string) error {
+	SortFindings(r.Findings)
+
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, r); err != nil {
+		return fmt.Errorf("error rendering report: %w", err)
+	}
+	if err := artifacts.WriteFile(filename, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
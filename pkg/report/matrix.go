@@ -0,0 +1,217 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+)
+
+// Cell states of an access matrix. A cell is empty when the operation was not
+// generated for the target, whose schema does not have it.
+const (
+	CellAccessible = "accessible"
+	CellDenied     = "denied"
+	CellError      = "error"
+)
+
+// MatrixCell is the state of an operation on one target. Operations are
+// identified across targets by the canonical hash of their document.
+type MatrixCell struct {
+	Hash      string `json:"hash"`
+	Operation string `json:"operation"`
+	State     string `json:"state"`
+}
+
+// Matrix is the access matrix of a multi-target run: a row per operation and
+// a cell per target, in the order of Targets.
+type Matrix struct {
+	Targets []string    `json:"targets"`
+	Rows    []MatrixRow `json:"rows"`
+}
+
+// MatrixRow is the state of an operation on every target.
+type MatrixRow struct {
+	Hash      string   `json:"hash"`
+	Operation string   `json:"operation"`
+	Cells     []string `json:"cells"`
+	// Outlier is the target whose cell differs from those of all the others,
+	// set when at least three targets have the operation and all but one
+	// agree.
+	Outlier string `json:"outlier,omitempty"`
+}
+
+// Divergent reports whether the targets having the operation do not all
+// share its state.
+func (r MatrixRow) Divergent() bool {
+	first := ""
+	for _, c := range r.Cells {
+		if c == "" {
+			continue
+		}
+		if first == "" {
+			first = c
+		} else if c != first {
+			return true
+		}
+	}
+	return false
+}
+
+// Divergent returns the divergent rows of m.
+func (m *Matrix) Divergent() []MatrixRow {
+	var rows []MatrixRow
+	for _, r := range m.Rows {
+		if r.Divergent() {
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
+
+// MatrixRecorder collects the cells of each target of a run in a spool file
+// of its own, so that a run over many targets holds only one target's cells
+// in memory until Build.
+type MatrixRecorder struct {
+	dir     string
+	mu      sync.Mutex
+	targets []string
+}
+
+// NewMatrixRecorder spools the cells under dir/targets.
+func NewMatrixRecorder(dir string) (*MatrixRecorder, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "targets"), 0755); err != nil {
+		return nil, fmt.Errorf("error creating matrix directory: %w", err)
+	}
+	return &MatrixRecorder{dir: dir}, nil
+}
+
+// Dir returns the directory of the recorder.
+func (m *MatrixRecorder) Dir() string { return m.dir }
+
+// spoolName returns the spool file of the i-th target.
+func (m *MatrixRecorder) spoolName(i int) string {
+	return filepath.Join(m.dir, "targets", fmt.Sprintf("%04d.ndjson", i+1))
+}
+
+// Record spools the cells of target as NDJSON, a first line naming the
+// target followed by a line per cell.
+func (m *MatrixRecorder) Record(target string, cells []MatrixCell) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	if err := enc.Encode(map[string]string{"target": target}); err != nil {
+		return err
+	}
+	for _, c := range cells {
+		if err := enc.Encode(c); err != nil {
+			return fmt.Errorf("error encoding matrix cell: %w", err)
+		}
+	}
+	if err := artifacts.WriteFile(m.spoolName(len(m.targets)), b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing matrix cells of %s: %w", target, err)
+	}
+	m.targets = append(m.targets, target)
+	return nil
+}
+
+// Build reads the spooled cells back into the matrix, with rows in the order
+// operations were first seen, and marks the outliers.
+func (m *MatrixRecorder) Build() (*Matrix, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	matrix := &Matrix{Targets: append([]string(nil), m.targets...), Rows: []MatrixRow{}}
+	rows := make(map[string]int)
+	for i := range m.targets {
+		f, err := os.Open(m.spoolName(i))
+		if err != nil {
+			return nil, fmt.Errorf("error reading matrix cells: %w", err)
+		}
+		lines := bufio.NewScanner(f)
+		lines.Buffer(make([]byte, 64*1024), 1024*1024)
+		lines.Scan() // the target line
+		for lines.Scan() {
+			var c MatrixCell
+			if err := json.Unmarshal(lines.Bytes(), &c); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("error reading matrix cells of %s: %w", m.targets[i], err)
+			}
+			row, ok := rows[c.Hash]
+			if !ok {
+				row = len(matrix.Rows)
+				rows[c.Hash] = row
+				matrix.Rows = append(matrix.Rows, MatrixRow{Hash: c.Hash, Operation: c.Operation, Cells: make([]string, len(m.targets))})
+			}
+			matrix.Rows[row].Cells[i] = c.State
+		}
+		err = lines.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading matrix cells of %s: %w", m.targets[i], err)
+		}
+	}
+	for i := range matrix.Rows {
+		matrix.Rows[i].Outlier = outlier(matrix.Rows[i].Cells, matrix.Targets)
+	}
+	return matrix, nil
+}
+
+// outlier returns the target whose cell is the only one to differ from the
+// others, which must be at least two and agree.
+func outlier(cells, targets []string) string {
+	counts := make(map[string]int)
+	present := 0
+	for _, c := range cells {
+		if c != "" {
+			counts[c]++
+			present++
+		}
+	}
+	if present < 3 || len(counts) != 2 {
+		return ""
+	}
+	for i, c := range cells {
+		if c != "" && counts[c] == 1 {
+			return targets[i]
+		}
+	}
+	return ""
+}
+
+// WriteMatrix writes m to dir as matrix.json and matrix.csv, whose columns are
+// the hash, the operation and one per target.
+func WriteMatrix(m *Matrix, dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling matrix: %w", err)
+	}
+	if err := artifacts.WriteFile(filepath.Join(dir, "matrix.json"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing matrix: %w", err)
+	}
+
+	f, err := artifacts.Create(filepath.Join(dir, "matrix.csv"), 0644)
+	if err != nil {
+		return fmt.Errorf("error creating matrix CSV: %w", err)
+	}
+	defer f.Abort()
+	w := csv.NewWriter(f)
+	w.Write(append([]string{"hash", "operation"}, m.Targets...))
+	for _, r := range m.Rows {
+		w.Write(append([]string{r.Hash, r.Operation}, r.Cells...))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing matrix CSV: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("error writing matrix CSV: %w", err)
+	}
+	return nil
+}
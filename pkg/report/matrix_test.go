@@ -0,0 +1,104 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestMatrixThreeTenants records the cells of three tenants, of which only
+// tenant-b serves invoices, and builds their access matrix.
+func TestMatrixThreeTenants(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := NewMatrixRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenants := map[string][]MatrixCell{
+		"https://a.example.com/graphql": {
+			{"h-me", "me", CellAccessible},
+			{"h-invoices", "invoices", CellDenied},
+			{"h-users", "users", CellDenied},
+			{"h-audit", "auditLog", CellAccessible},
+			{"h-billing", "billing", CellAccessible},
+		},
+		"https://b.example.com/graphql": {
+			// Rows keep the order operations were first seen in.
+			{"h-invoices", "invoices", CellAccessible},
+			{"h-me", "me", CellAccessible},
+			{"h-users", "users", CellDenied},
+			{"h-audit", "auditLog", CellDenied},
+			{"h-billing", "billing", CellAccessible},
+		},
+		// The schema of tenant-c has no audit log.
+		"https://c.example.com/graphql": {
+			{"h-me", "me", CellAccessible},
+			{"h-invoices", "invoices", CellDenied},
+			{"h-users", "users", CellDenied},
+			{"h-billing", "billing", CellError},
+		},
+	}
+	targets := []string{"https://a.example.com/graphql", "https://b.example.com/graphql", "https://c.example.com/graphql"}
+	for _, target := range targets {
+		if err := rec.Record(target, tenants[target]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, spool := range []string{"0001.ndjson", "0002.ndjson", "0003.ndjson"} {
+		if _, err := os.Stat(filepath.Join(dir, "targets", spool)); err != nil {
+			t.Errorf("spool file: %v", err)
+		}
+	}
+
+	m, err := rec.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Matrix{Targets: targets, Rows: []MatrixRow{
+		{Hash: "h-me", Operation: "me", Cells: []string{CellAccessible, CellAccessible, CellAccessible}},
+		{Hash: "h-invoices", Operation: "invoices", Cells: []string{CellDenied, CellAccessible, CellDenied}, Outlier: targets[1]},
+		{Hash: "h-users", Operation: "users", Cells: []string{CellDenied, CellDenied, CellDenied}},
+		// Two targets cannot tell which one is the outlier.
+		{Hash: "h-audit", Operation: "auditLog", Cells: []string{CellAccessible, CellDenied, ""}},
+		{Hash: "h-billing", Operation: "billing", Cells: []string{CellAccessible, CellAccessible, CellError}, Outlier: targets[2]},
+	}}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("matrix =\n%+v\nwant\n%+v", m, want)
+	}
+	var divergent []string
+	for _, r := range m.Divergent() {
+		divergent = append(divergent, r.Operation)
+	}
+	if !reflect.DeepEqual(divergent, []string{"invoices", "auditLog", "billing"}) {
+		t.Errorf("divergent rows %q, want invoices, auditLog and billing", divergent)
+	}
+
+	if err := WriteMatrix(m, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "matrix.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written Matrix
+	if err := json.Unmarshal(data, &written); err != nil || !reflect.DeepEqual(&written, want) {
+		t.Errorf("matrix.json = %s, %v", data, err)
+	}
+	f, err := os.Open(filepath.Join(dir, "matrix.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 || !reflect.DeepEqual(records[0], append([]string{"hash", "operation"}, targets...)) ||
+		!reflect.DeepEqual(records[2], []string{"h-invoices", "invoices", CellDenied, CellAccessible, CellDenied}) ||
+		!reflect.DeepEqual(records[4], []string{"h-audit", "auditLog", CellAccessible, CellDenied, ""}) {
+		t.Errorf("matrix.csv = %q", records)
+	}
+}
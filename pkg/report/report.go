@@ -0,0 +1,303 @@
+// Package report defines audit findings and writes them to disk
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+)
+
+// Severity levels used by checks and findings
+const (
+	SeverityInfo     = "info"
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// severityRank orders severities from most to least severe
+var severityRank = map[string]int{
+	SeverityCritical: 0,
+	SeverityHigh:     1,
+	SeverityMedium:   2,
+	SeverityLow:      3,
+	SeverityInfo:     4,
+}
+
+// ValidSeverity reports whether severity is one of the known severity levels
+func ValidSeverity(severity string) bool {
+	_, ok := severityRank[severity]
+	return ok
+}
+
+// SeverityRank returns the position of severity in the ordering critical..info.
+// Unknown severities sort after info.
+func SeverityRank(severity string) int {
+	if rank, ok := severityRank[severity]; ok {
+		return rank
+	}
+	return len(severityRank)
+}
+
+// Finding represents a single issue discovered by an audit check
+type Finding struct {
+	ID          string `json:"id"`
+	Check       string `json:"check"`
+	Title       string `json:"title"`
+	Severity    string `json:"severity"`
+	Endpoint    string `json:"endpoint"`
+	Description string `json:"description,omitempty"`
+	Evidence    string `json:"evidence,omitempty"`
+	// References link to advisories describing the issue.
+	References []string `json:"references,omitempty"`
+	// Reproduction is a curl command replaying Request, filled by PrepareReproductions.
+	Reproduction string `json:"reproduction,omitempty"`
+	// EvidenceFile is the request saved by ShapeEvidence in full reports,
+	// relative to the report.
+	EvidenceFile string `json:"evidenceFile,omitempty"`
+
+	Request *RequestEvidence `json:"-"`
+}
+
+// CheckResult records the outcome of running one check against one endpoint
+type CheckResult struct {
+	Check    string `json:"check"`
+	Endpoint string `json:"endpoint"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	// Reason explains why a check was skipped or inconclusive.
+	Reason string `json:"reason,omitempty"`
+	// DurationMs is the time the check ran for, in milliseconds.
+	DurationMs int64 `json:"durationMs"`
+}
+
+// CheckTimes sums the duration of the results of each check, in milliseconds.
+func CheckTimes(results []CheckResult) map[string]int64 {
+	if len(results) == 0 {
+		return nil
+	}
+	times := make(map[string]int64)
+	for _, r := range results {
+		times[r.Check] += r.DurationMs
+	}
+	return times
+}
+
+// Check result statuses
+const (
+	StatusPassed = "passed"
+	StatusFound  = "found"
+	StatusFailed = "failed"
+	// StatusSkipped marks checks not run because the run was stopped early or
+	// their Reason ruled them out.
+	StatusSkipped = "skipped"
+	// StatusInconclusive marks checks that ran out of their time budget.
+	StatusInconclusive = "inconclusive"
+)
+
+// StopReason explains why a run was cut short
+type StopReason struct {
+	Reason   string `json:"reason"`
+	Finding  string `json:"finding,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// Error is true when a check failure, rather than a finding, stopped the run.
+	Error bool `json:"error,omitempty"`
+}
+
+// CanaryResult compares the canary query responses taken before and after the
+// audit of an endpoint. Before and After are only kept when they differ.
+type CanaryResult struct {
+	Endpoint string `json:"endpoint"`
+	Query    string `json:"query"`
+	Drift    bool   `json:"drift"`
+	Before   string `json:"before,omitempty"`
+	After    string `json:"after,omitempty"`
+	// Diff lists the differences between Before and After (see jsondiff.Render).
+	Diff  string `json:"diff,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Outcome describes the result of the comparison in a few words
+func (c CanaryResult) Outcome() string {
+	switch {
+	case c.Drift:
+		return "changed"
+	case c.Error != "":
+		return "not verified: " + c.Error
+	default:
+		return "unchanged"
+	}
+}
+
+// Metadata identifies the GraphSpecter build that produced a report
+type Metadata struct {
+	Tool      string `json:"tool"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	// CheckTimesMs is the time spent in each check across all endpoints, in
+	// milliseconds.
+	CheckTimesMs map[string]int64 `json:"checkTimesMs,omitempty"`
+	// Preset is the check preset of the run, if any, and Checks the ids of
+	// the checks it ran.
+	Preset string   `json:"preset,omitempty"`
+	Checks []string `json:"checks,omitempty"`
+	// Auth holds the verification of the supplied credentials against each
+	// endpoint, when there were any.
+	Auth []types.AuthVerification `json:"auth,omitempty"`
+}
+
+// NewMetadata returns the metadata of the running build
+func NewMetadata() Metadata {
+	return Metadata{
+		Tool:      "graphspecter",
+		Version:   version.Version,
+		Commit:    version.Commit,
+		BuildDate: version.BuildDate,
+	}
+}
+
+// Report is the full result of an audit run
+type Report struct {
+	Metadata Metadata `json:"metadata"`
+	// Detail is the evidence detail level set by ShapeEvidence.
+	Detail    string   `json:"detail,omitempty"`
+	Endpoints []string `json:"endpoints"`
+	// Encodings are the request encodings of the endpoints reached through a
+	// wrapper rather than the standard JSON body.
+	Encodings map[string]string `json:"encodings,omitempty"`
+	// Engines are the most confident engine fingerprinted on each endpoint,
+	// which selects the engine-specific remediation of its findings.
+	Engines map[string]string `json:"engines,omitempty"`
+	// AuthCandidates are detection paths that appear to require
+	// authentication; they are not confirmed GraphQL endpoints.
+	AuthCandidates []types.AuthCandidate `json:"authCandidates,omitempty"`
+	Checks         []CheckResult         `json:"checks"`
+	Findings       []Finding             `json:"findings"`
+	Stats          *types.NetworkStats   `json:"stats,omitempty"`
+	Stopped        *StopReason           `json:"stopped,omitempty"`
+	// Profile holds the bug bounty program constraints the run enforced.
+	Profile *types.BountyProfile `json:"profile,omitempty"`
+	// Canaries are the canary comparisons of the audited endpoints.
+	Canaries []CanaryResult `json:"canaries,omitempty"`
+	// NonQueryOperations are the mutations and subscriptions sent during the run.
+	NonQueryOperations []types.SentOperation `json:"nonQueryOperations,omitempty"`
+	// SuppressedRequests are the requests refused because their host is out
+	// of the scope of the run.
+	SuppressedRequests []types.SuppressedRequest `json:"suppressedRequests,omitempty"`
+	// Capabilities are the delivery features detected on each endpoint.
+	Capabilities []types.Capabilities `json:"capabilities,omitempty"`
+	// VirtualHosts are the endpoints detected under each host name of a
+	// --vhosts run.
+	VirtualHosts []types.VirtualHostEndpoint `json:"virtualHosts,omitempty"`
+	// Redactions is the number of sensitive values masked in the report and
+	// the artifacts written during the run.
+	Redactions int `json:"redactions"`
+	// Catalogs are the operation catalogs of the introspected endpoints. They
+	// are written to their own files and only rendered by report templates.
+	Catalogs []EndpointCatalog `json:"-"`
+	// Matrix is the access matrix of the operations over the targets, written
+	// to matrix.json and matrix.csv; reports render its divergent rows.
+	Matrix *Matrix `json:"-"`
+	// Authz are the authorization matrices of the endpoints audited as the
+	// identities of --identities; reports render their divergent rows.
+	Authz []AuthzMatrix `json:"authz,omitempty"`
+	// OperationNotes are the operations of Catalogs annotated by --notes.
+	OperationNotes []OperationNote `json:"operationNotes,omitempty"`
+}
+
+// EndpointCatalog is the operation catalog built for an endpoint
+type EndpointCatalog struct {
+	Endpoint string
+	Catalog  *schema.Catalog
+}
+
+// OperationNote is an operation of an endpoint with its notes and tags
+type OperationNote struct {
+	Endpoint string   `json:"endpoint"`
+	Kind     string   `json:"kind"`
+	Name     string   `json:"name"`
+	Tags     []string `json:"tags,omitempty"`
+	Notes    []string `json:"notes,omitempty"`
+}
+
+// AnnotatedOperations returns the operations of catalogs that have notes or
+// tags, in catalog order
+func AnnotatedOperations(catalogs []EndpointCatalog) []OperationNote {
+	var out []OperationNote
+	for _, c := range catalogs {
+		for _, op := range c.Catalog.Operations {
+			if len(op.Notes) == 0 && len(op.Tags) == 0 {
+				continue
+			}
+			out = append(out, OperationNote{Endpoint: c.Endpoint, Kind: op.Kind, Name: op.Name, Tags: op.Tags, Notes: op.Notes})
+		}
+	}
+	return out
+}
+
+// HasFinding reports whether any finding with the given id was recorded
+func (r *Report) HasFinding(id string) bool {
+	for _, f := range r.Findings {
+		if f.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// StateChanged reports whether a canary query returned a different response
+// after the scan
+func (r *Report) StateChanged() bool {
+	for _, c := range r.Canaries {
+		if c.Drift {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact masks every occurrence of secrets in the descriptions, evidence and
+// reproductions of the findings
+func (r *Report) Redact(secrets []string) {
+	for i := range r.Findings {
+		r.Findings[i].Description, _ = redact.Text(r.Findings[i].Description, secrets)
+		r.Findings[i].Evidence, _ = redact.Text(r.Findings[i].Evidence, secrets)
+		r.Findings[i].Reproduction, _ = redact.Text(r.Findings[i].Reproduction, secrets)
+	}
+}
+
+// SortFindings orders findings by severity, then endpoint, then id so reports
+// are identical across runs regardless of the order checks completed in.
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if ra, rb := SeverityRank(a.Severity), SeverityRank(b.Severity); ra != rb {
+			return ra < rb
+		}
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		return a.ID < b.ID
+	})
+}
+
+// WriteJSON writes the report as indented JSON to filename. Findings are sorted first.
+func WriteJSON(r *Report, filename string) error {
+	SortFindings(r.Findings)
+	jsonData, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling report: %w", err)
+	}
+	if err := artifacts.WriteFile(filename, jsonData, 0644); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,541 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+)
+
+// LoadReport reads a report written in JSON with --report.
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading report: %w", err)
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid report %s (a JSON report is required): %w", path, err)
+	}
+	return &r, nil
+}
+
+// ReproRequest returns the request that demonstrated f, as recorded in a report
+// saved in reportDir: the HTTP message of its EvidenceFile in full reports,
+// else its curl Reproduction. Files referenced from either are looked up as
+// given, then relative to reportDir.
+func ReproRequest(f Finding, reportDir string) (*RequestEvidence, error) {
+	if f.EvidenceFile != "" {
+		data, err := readReproFile(f.EvidenceFile, reportDir)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the evidence of %s: %w", f.ID, err)
+		}
+		return parseHTTPMessage(string(data))
+	}
+	if f.Reproduction != "" {
+		return parseCurl(f.Reproduction, reportDir)
+	}
+	return nil, fmt.Errorf("finding %s on %s records no request to reproduce", f.ID, f.Endpoint)
+}
+
+// readReproFile reads path, relative to the working directory or to dir.
+func readReproFile(path, dir string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !filepath.IsAbs(path) {
+		if rel, relErr := os.ReadFile(filepath.Join(dir, path)); relErr == nil {
+			return rel, nil
+		}
+	}
+	return data, err
+}
+
+// parseHTTPMessage parses a request saved by ShapeEvidence.
+func parseHTTPMessage(message string) (*RequestEvidence, error) {
+	head, body, _ := strings.Cut(strings.ReplaceAll(message, "\r\n", "\n"), "\n\n")
+	lines := strings.Split(head, "\n")
+	requestLine := strings.Fields(lines[0])
+	if len(requestLine) < 2 {
+		return nil, fmt.Errorf("invalid request line %q in evidence", lines[0])
+	}
+	req := &RequestEvidence{Method: requestLine[0], URL: requestLine[1], Headers: make(map[string]string), Body: body}
+	for _, line := range lines[1:] {
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			req.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return req, nil
+}
+
+// parseCurl parses a command rendered by CurlFor back into its request. A body
+// referenced with @file is read from the file, one sent with --data-raw is
+// taken as it is. GET requests keep their GraphQL
+// parameters in the query string of the URL.
+func parseCurl(command, dir string) (*RequestEvidence, error) {
+	words, err := shellWords(command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reproduction: %w", err)
+	}
+	if len(words) == 0 || words[0] != "curl" {
+		return nil, fmt.Errorf("invalid reproduction: not a curl command")
+	}
+	req := &RequestEvidence{Method: http.MethodGet, Headers: make(map[string]string)}
+	hasMethod := false
+	for i := 1; i < len(words); i++ {
+		word := words[i]
+		if !strings.HasPrefix(word, "-") {
+			req.URL = word
+			continue
+		}
+		if word == "-sS" {
+			continue
+		}
+		if i+1 == len(words) {
+			return nil, fmt.Errorf("invalid reproduction: %s without a value", word)
+		}
+		i++
+		switch word {
+		case "-X":
+			req.Method, hasMethod = words[i], true
+		case "-H":
+			name, value, _ := strings.Cut(words[i], ":")
+			req.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		case "--data-binary":
+			req.Body = words[i]
+			if file, ok := strings.CutPrefix(req.Body, "@"); ok {
+				data, err := readReproFile(file, dir)
+				if err != nil {
+					return nil, fmt.Errorf("error reading the request body: %w", err)
+				}
+				req.Body = string(data)
+			}
+			if !hasMethod {
+				req.Method = http.MethodPost
+			}
+		case "--data-raw":
+			req.Body = words[i]
+			if !hasMethod {
+				req.Method = http.MethodPost
+			}
+		default:
+			return nil, fmt.Errorf("invalid reproduction: unexpected option %s", word)
+		}
+	}
+	if req.URL == "" {
+		return nil, fmt.Errorf("invalid reproduction: no URL")
+	}
+	return req, nil
+}
+
+// shellWords splits a command quoted by shellQuote into its words.
+func shellWords(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, quoted := false, false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quoted && c == '\'':
+			quoted = false
+		case quoted:
+			word.WriteByte(c)
+		case c == '\'':
+			quoted, inWord = true, true
+		case c == '\\' && i+1 < len(command):
+			i++
+			word.WriteByte(command[i])
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// reproAssertion is the Go code of the check function of a reproduction,
+// given resp and body and returning what it observed or why the finding did
+// not reproduce.
+type reproAssertion struct {
+	// Demonstrates completes "The finding reproduces when ...".
+	Demonstrates string
+	Code         string
+}
+
+// reproSchemaTypes asserts that an introspection response lists types.
+var reproSchemaTypes = reproAssertion{
+	Demonstrates: "introspection returns the types of the schema",
+	Code: `data, err := decode(body)
+if err != nil {
+	return "", err
+}
+types, _ := lookup(data, "data", "__schema", "types").([]interface{})
+if len(types) == 0 {
+	return "", fmt.Errorf("HTTP %d: no types in the response", resp.StatusCode)
+}
+return fmt.Sprintf("introspection returned %d types", len(types)), nil`,
+}
+
+// reproAssertions are the assertions of the findings whose response shows
+// something more specific than data.
+var reproAssertions = map[string]reproAssertion{
+	"introspection-enabled": reproSchemaTypes,
+	"introspection-reduced": reproSchemaTypes,
+	"introspection-partial": {
+		Demonstrates: "introspection returns part of the schema",
+		Code: `data, err := decode(body)
+if err != nil {
+	return "", err
+}
+if lookup(data, "data", "__schema") == nil {
+	return "", fmt.Errorf("HTTP %d: no __schema in the response", resp.StatusCode)
+}
+return "introspection returned a __schema", nil`,
+	},
+	"federation-sdl-exposed": {
+		Demonstrates: "the _service field returns the SDL of the subgraph",
+		Code: `data, err := decode(body)
+if err != nil {
+	return "", err
+}
+sdl, _ := lookup(data, "data", "_service", "sdl").(string)
+if sdl == "" {
+	return "", fmt.Errorf("HTTP %d: no SDL in the response", resp.StatusCode)
+}
+return fmt.Sprintf("_service returned %d bytes of SDL", len(sdl)), nil`,
+	},
+	"federation-entities-direct-access": {
+		Demonstrates: "the _entities field resolves entities for a direct caller",
+		Code: `data, err := decode(body)
+if err != nil {
+	return "", err
+}
+entities, _ := lookup(data, "data", "_entities").([]interface{})
+if len(entities) == 0 {
+	return "", fmt.Errorf("HTTP %d: no entities in the response", resp.StatusCode)
+}
+return fmt.Sprintf("_entities returned %d entities", len(entities)), nil`,
+	},
+	"defer-supported": {
+		Demonstrates: "the fragment marked @defer is executed",
+		Code: `if media := mediaType(resp); media == "multipart/mixed" {
+	return "the response is delivered incrementally as multipart/mixed", nil
+}
+data, err := decode(body)
+if err != nil {
+	return "", err
+}
+if lookup(data, "errors") != nil || lookup(data, "data", "__typename") == nil {
+	return "", fmt.Errorf("HTTP %d: the deferred fragment was rejected", resp.StatusCode)
+}
+return "the deferred fragment was executed", nil`,
+	},
+	"sse-transport": {
+		Demonstrates: "the query is answered with an event stream",
+		Code: `if media := mediaType(resp); media != "text/event-stream" {
+	return "", fmt.Errorf("HTTP %d: answered with %q", resp.StatusCode, media)
+}
+return "the query was answered over Server-Sent Events", nil`,
+	},
+	"incorrect-content-type": {
+		Demonstrates: "a JSON response is served under a non-JSON Content-Type",
+		Code: `media := mediaType(resp)
+if media == "application/json" || strings.HasSuffix(media, "+json") {
+	return "", fmt.Errorf("HTTP %d: served as %s", resp.StatusCode, media)
+}
+if _, err := decode(body); err != nil {
+	return "", err
+}
+return fmt.Sprintf("a JSON response was served as %q", media), nil`,
+	},
+	"graphql-response-inconsistent": {
+		Demonstrates: "the status code of a failing query does not follow its media type",
+		Code: `media := mediaType(resp)
+success := resp.StatusCode >= 200 && resp.StatusCode < 300
+switch {
+case media == "application/graphql-response+json" && success:
+	return fmt.Sprintf("a request error was answered HTTP %d under %s", resp.StatusCode, media), nil
+case media != "application/graphql-response+json" && !success:
+	return fmt.Sprintf("a request error asking for application/graphql-response+json was answered HTTP %d under %s", resp.StatusCode, media), nil
+}
+return "", fmt.Errorf("HTTP %d under %s follows the specification", resp.StatusCode, media)`,
+	},
+}
+
+// reproData is the default assertion: the request is answered with data.
+var reproData = reproAssertion{
+	Demonstrates: "the request is answered with data",
+	Code: `data, err := decode(body)
+if err != nil {
+	return "", err
+}
+if lookup(data, "data") == nil {
+	return "", fmt.Errorf("HTTP %d: no data in the response", resp.StatusCode)
+}
+return fmt.Sprintf("HTTP %d with data", resp.StatusCode), nil`,
+}
+
+// reproHeader is a header of a reproduction. Credentials have no Value and
+// are read from the environment variable Env instead.
+type reproHeader struct {
+	Name  string
+	Value string
+	Env   string
+}
+
+// reproEnvSeparators are the runs of a header name replaced by an underscore
+// in the name of its environment variable.
+var reproEnvSeparators = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// reproEnvName is the environment variable holding the value of a credential
+// header in a reproduction.
+func reproEnvName(header string) string {
+	return "GRAPHSPECTER_" + strings.ToUpper(reproEnvSeparators.ReplaceAllString(header, "_"))
+}
+
+// reproFuncName returns the name of the test of the finding id.
+func reproFuncName(id string) string {
+	var b strings.Builder
+	b.WriteString("TestRepro")
+	upper := true
+	for _, r := range id {
+		switch {
+		case r == '-' || r == '_' || r == '.':
+			upper = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Repro renders a standalone Go program reproducing f with req, a test when
+// test is set and a main package otherwise. The program depends on the
+// standard library only. Credential headers are read from GRAPHSPECTER_*
+// environment variables rather than written into the file; the program
+// asserts what its response must show for the finding to reproduce.
+func Repro(f Finding, req RequestEvidence, test bool) ([]byte, error) {
+	assertion, ok := reproAssertions[f.ID]
+	if !ok {
+		assertion = reproData
+	}
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	names := make([]string, 0, len(req.Headers))
+	for name := range req.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var headers []reproHeader
+	for _, name := range names {
+		value := req.Headers[name]
+		if redact.IsSensitiveHeader(name) {
+			headers = append(headers, reproHeader{Name: name, Env: reproEnvName(name)})
+			continue
+		}
+		headers = append(headers, reproHeader{Name: name, Value: value})
+	}
+
+	var b bytes.Buffer
+	err := reproTemplate.Execute(&b, map[string]interface{}{
+		"Finding":   f,
+		"Method":    method,
+		"URL":       req.URL,
+		"Body":      req.Body,
+		"Headers":   headers,
+		"Assertion": assertion,
+		"Test":      test,
+		"Func":      reproFuncName(f.ID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error rendering reproduction: %w", err)
+	}
+	// Formatting parses the program, so a reproduction that is not valid Go
+	// is never written.
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting reproduction: %w", err)
+	}
+	return src, nil
+}
+
+// reproLiteral returns s as a raw string literal, easier to read and edit
+// than a quoted one, unless s holds characters a raw literal cannot.
+func reproLiteral(s string) string {
+	if strings.ContainsAny(s, "`\r") {
+		return fmt.Sprintf("%q", s)
+	}
+	return "`" + s + "`"
+}
+
+// reproComment turns text into the lines of a // comment.
+func reproComment(text string) string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight("// "+scanner.Text(), " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+var reproTemplate = template.Must(template.New("repro").Funcs(template.FuncMap{
+	"quote":   func(s string) string { return fmt.Sprintf("%q", s) },
+	"raw":     reproLiteral,
+	"comment": reproComment,
+	"indent": func(code string) string {
+		return "\t" + strings.ReplaceAll(code, "\n", "\n\t")
+	},
+}).Parse(`{{comment (printf "Generated by graphspecter repro. Reproduces %s on %s." .Finding.ID .Finding.Endpoint)}}
+//
+{{comment (printf "%s [%s]" .Finding.Title .Finding.Severity)}}
+//
+// The finding reproduces when {{.Assertion.Demonstrates}}.
+{{- range .Headers}}{{if .Env}}
+// The {{.Name}} header is read from {{.Env}}.{{end}}{{end}}
+{{- if .Test}}
+//
+// Run with: go test -run {{.Func}} -v
+package repro
+{{else}}
+//
+// Run with: go run <file>
+package main
+{{end}}
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+{{- if .Test}}
+	"testing"
+{{- end}}
+	"time"
+)
+
+const (
+	finding  = {{quote .Finding.ID}}
+	method   = {{quote .Method}}
+	endpoint = {{quote .URL}}
+	body     = {{raw .Body}}
+)
+
+// headers are the headers of the request, credentials excepted.
+var headers = map[string]string{
+{{- range .Headers}}{{if not .Env}}
+	{{quote .Name}}: {{quote .Value}},{{end}}{{end}}
+}
+
+// credentials are the credential headers and the environment variables
+// holding their values. Those unset are not sent.
+var credentials = map[string]string{
+{{- range .Headers}}{{if .Env}}
+	{{quote .Name}}: {{quote .Env}},{{end}}{{end}}
+}
+{{if .Test}}
+func {{.Func}}(t *testing.T) {
+	observed, err := reproduce()
+	if err != nil {
+		t.Fatalf("%s did not reproduce: %v", finding, err)
+	}
+	t.Logf("%s reproduced: %s", finding, observed)
+}
+{{else}}
+func main() {
+	observed, err := reproduce()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s did not reproduce: %v\n", finding, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s reproduced: %s\n", finding, observed)
+}
+{{end}}
+// reproduce sends the request and checks its response.
+func reproduce() (string, error) {
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return "", err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	for name, env := range credentials {
+		if value := os.Getenv(env); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return check(resp, data)
+}
+
+// check asserts that {{.Assertion.Demonstrates}}.
+func check(resp *http.Response, body []byte) (string, error) {
+{{indent .Assertion.Code}}
+}
+
+// decode decodes a JSON response.
+func decode(body []byte) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("the response is not JSON: %v", err)
+	}
+	return data, nil
+}
+
+// lookup returns the member of v at path, nil when there is none.
+func lookup(v interface{}, path ...string) interface{} {
+	for _, name := range path {
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = object[name]
+	}
+	return v
+}
+
+// mediaType returns the media type of the response, without its parameters.
+func mediaType(resp *http.Response) string {
+	media, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	return strings.ToLower(strings.TrimSpace(media))
+}
+`))
@@ -0,0 +1,171 @@
+package report
+
+import (
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReproRequestFromCurl(t *testing.T) {
+	dir := t.TempDir()
+	long := `{"query":"{ ` + strings.Repeat("a ", CurlBodyLimit) + `}"}`
+	if err := os.WriteFile(filepath.Join(dir, "body.json"), []byte(long), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		req  RequestEvidence
+	}{
+		{"post", RequestEvidence{Method: "POST", URL: "https://api.example/graphql", Headers: map[string]string{"Content-Type": "application/json", "X-Tenant": "it's"}, Body: `{"query":"{ __typename }"}`}},
+		{"body starting with @", RequestEvidence{Method: "POST", URL: "https://api.example/graphql", Headers: map[string]string{"Content-Type": "text/plain"}, Body: "@/etc/passwd"}},
+		{"body file", RequestEvidence{Method: "POST", URL: "https://api.example/graphql", Headers: map[string]string{}, Body: long, BodyFile: "body.json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReproRequest(Finding{ID: "x", Reproduction: CurlFor(tt.req)}, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.req
+			want.BodyFile = ""
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("ReproRequest() = %+v\nwant %+v", *got, want)
+			}
+		})
+	}
+}
+
+// reproFinding is a finding of id on endpoint and the request that showed it,
+// with a credential header.
+func reproFinding(id, endpoint string) (Finding, RequestEvidence) {
+	f := Finding{ID: id, Title: "Introspection enabled", Severity: SeverityMedium, Endpoint: endpoint}
+	req := RequestEvidence{
+		Method:  "POST",
+		URL:     endpoint,
+		Headers: map[string]string{"Content-Type": "application/json", "Authorization": "Bearer s3cr3t"},
+		Body:    `{"query":"{ __schema { types { name } } }"}`,
+	}
+	return f, req
+}
+
+func TestReproParses(t *testing.T) {
+	ids := []string{"unlisted-finding"}
+	for id := range reproAssertions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		for _, test := range []bool{false, true} {
+			f, req := reproFinding(id, "https://api.example/graphql")
+			src, err := Repro(f, req, test)
+			if err != nil {
+				t.Fatalf("%s: %v", id, err)
+			}
+			file, err := parser.ParseFile(token.NewFileSet(), "repro.go", src, parser.ImportsOnly)
+			if err != nil {
+				t.Fatalf("%s: %v", id, err)
+			}
+			wantPackage := map[bool]string{false: "main", true: "repro"}[test]
+			if file.Name.Name != wantPackage {
+				t.Errorf("%s: package %s, want %s", id, file.Name.Name, wantPackage)
+			}
+			for _, imp := range file.Imports {
+				if path, _ := strconv.Unquote(imp.Path.Value); strings.Contains(path, ".") {
+					t.Errorf("%s imports %s, outside the standard library", id, path)
+				}
+			}
+			if strings.Contains(string(src), "s3cr3t") || !strings.Contains(string(src), `"Authorization": "GRAPHSPECTER_AUTHORIZATION"`) {
+				t.Errorf("%s: the credential is not read from the environment:\n%s", id, src)
+			}
+		}
+	}
+}
+
+// runRepro writes src to a new module and runs it with go test or go run,
+// returning its output and whether it succeeded.
+func runRepro(t *testing.T, src []byte, test bool, env ...string) (string, bool) {
+	t.Helper()
+	dir := t.TempDir()
+	name := map[bool]string{false: "main.go", true: "repro_test.go"}[test]
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module repro\n\ngo 1.20\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	vet := exec.Command("go", "vet", ".")
+	vet.Dir, vet.Env = dir, append(os.Environ(), "GOWORK=off", "GOFLAGS=")
+	if out, err := vet.CombinedOutput(); err != nil {
+		t.Fatalf("go vet: %v\n%s\n%s", err, out, src)
+	}
+	args := []string{"run", "."}
+	if test {
+		args = []string{"test", "-count=1", "-v", "."}
+	}
+	cmd := exec.Command("go", args...)
+	cmd.Dir, cmd.Env = dir, append(os.Environ(), append([]string{"GOWORK=off", "GOFLAGS="}, env...)...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err == nil
+}
+
+func TestReproCompilesAndReproduces(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the generated programs with the go command")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is not installed")
+	}
+	var mu sync.Mutex
+	var authorization string
+	vulnerable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorization = r.Header.Get("Authorization")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__schema":{"types":[{"name":"Query"},{"name":"User"}]}}}`))
+	}))
+	defer vulnerable.Close()
+	fixed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":[{"message":"introspection is disabled"}]}`))
+	}))
+	defer fixed.Close()
+
+	for _, test := range []bool{true, false} {
+		f, req := reproFinding("introspection-enabled", vulnerable.URL)
+		src, err := Repro(f, req, test)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, ok := runRepro(t, src, test, "GRAPHSPECTER_AUTHORIZATION=Bearer from-env")
+		if !ok || !strings.Contains(out, "introspection-enabled reproduced: introspection returned 2 types") {
+			t.Errorf("test %v against the vulnerable server: %s", test, out)
+		}
+		mu.Lock()
+		if authorization != "Bearer from-env" {
+			t.Errorf("test %v sent Authorization %q, want the value of the environment", test, authorization)
+		}
+		authorization = ""
+		mu.Unlock()
+
+		f, req = reproFinding("introspection-enabled", fixed.URL)
+		if src, err = Repro(f, req, test); err != nil {
+			t.Fatal(err)
+		}
+		out, ok = runRepro(t, src, test)
+		if ok || !strings.Contains(out, "did not reproduce: HTTP 400: no types in the response") {
+			t.Errorf("test %v against the fixed server: %s", test, out)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+)
+
+// SARIFVersion and SARIFSchema identify the SARIF format WriteSARIF writes.
+const (
+	SARIFVersion = "2.1.0"
+	SARIFSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// sarifLog is the subset of a SARIF 2.1.0 log used by WriteSARIF.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+	// Properties hold the detail level the evidence was shaped to.
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      *sarifMessage      `json:"fullDescription,omitempty"`
+	Help                 *sarifMessage      `json:"help,omitempty"`
+	HelpURI              string             `json:"helpUri,omitempty"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           sarifRuleProps     `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifRuleProps struct {
+	SecuritySeverity string   `json:"security-severity"`
+	Tags             []string `json:"tags"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID      string            `json:"ruleId"`
+	RuleIndex   int               `json:"ruleIndex"`
+	Level       string            `json:"level"`
+	Message     sarifMessage      `json:"message"`
+	Locations   []sarifLocation   `json:"locations"`
+	Attachments []sarifAttachment `json:"attachments,omitempty"`
+	Properties  sarifResultProps  `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifAttachment struct {
+	Description      sarifMessage          `json:"description"`
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifResultProps struct {
+	Severity     string   `json:"severity"`
+	Check        string   `json:"check,omitempty"`
+	Evidence     string   `json:"evidence,omitempty"`
+	Reproduction string   `json:"reproduction,omitempty"`
+	References   []string `json:"references,omitempty"`
+}
+
+// SARIFLevel returns the SARIF level of severity: error for critical and
+// high, warning for medium and note otherwise.
+func SARIFLevel(severity string) string {
+	switch severity {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity is the CVSS-like score code scanning dashboards rank
+// results by.
+func securitySeverity(severity string) string {
+	switch severity {
+	case SeverityCritical:
+		return "9.5"
+	case SeverityHigh:
+		return "8.0"
+	case SeverityMedium:
+		return "5.5"
+	case SeverityLow:
+		return "3.0"
+	default:
+		return "0.0"
+	}
+}
+
+// WriteSARIF writes the findings of r as a SARIF 2.1.0 log to filename, one
+// rule per finding id and one result per finding. Findings are sorted first.
+// The evidence, reproduction and evidence file of each result are those left
+// by ShapeEvidence, so the log follows the detail level of the report.
+func WriteSARIF(r *Report, filename string) error {
+	data, err := RenderSARIF(r)
+	if err != nil {
+		return err
+	}
+	if err := artifacts.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("error writing SARIF: %w", err)
+	}
+	return nil
+}
+
+// RenderSARIF returns the SARIF log of r as indented JSON.
+func RenderSARIF(r *Report) ([]byte, error) {
+	SortFindings(r.Findings)
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "GraphSpecter",
+			Version:        r.Metadata.Version,
+			InformationURI: "https://github.com/CyberRoute/graphspecter",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	if r.Detail != "" {
+		run.Properties = map[string]string{"detail": r.Detail}
+	}
+	rules := make(map[string]int)
+	for _, f := range r.Findings {
+		index, ok := rules[f.ID]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			rules[f.ID] = index
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, r.sarifRule(f))
+		}
+		text := f.Title
+		if f.Description != "" {
+			text = f.Description
+		}
+		result := sarifResult{
+			RuleID:    f.ID,
+			RuleIndex: index,
+			Level:     SARIFLevel(f.Severity),
+			Message:   sarifMessage{Text: text},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: f.Endpoint},
+			}}},
+			Properties: sarifResultProps{
+				Severity:     f.Severity,
+				Check:        f.Check,
+				Evidence:     f.Evidence,
+				Reproduction: f.Reproduction,
+				References:   f.References,
+			},
+		}
+		if f.EvidenceFile != "" {
+			result.Attachments = []sarifAttachment{{
+				Description:      sarifMessage{Text: "Request sent to the endpoint"},
+				ArtifactLocation: sarifArtifactLocation{URI: f.EvidenceFile},
+			}}
+		}
+		run.Results = append(run.Results, result)
+	}
+	data, err := json.MarshalIndent(sarifLog{Schema: SARIFSchema, Version: SARIFVersion, Runs: []sarifRun{run}}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling SARIF: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// sarifRule is the rule of the id of f, described by the knowledge base when
+// it has an entry for it.
+func (r *Report) sarifRule(f Finding) sarifRule {
+	rule := sarifRule{
+		ID:                   f.ID,
+		ShortDescription:     sarifMessage{Text: f.Title},
+		DefaultConfiguration: sarifConfiguration{Level: SARIFLevel(f.Severity)},
+		Properties:           sarifRuleProps{SecuritySeverity: securitySeverity(f.Severity), Tags: []string{"security", "graphql"}},
+	}
+	if len(f.References) > 0 {
+		rule.HelpURI = f.References[0]
+	}
+	g := r.Guidance(f)
+	if g == nil {
+		return rule
+	}
+	rule.FullDescription = &sarifMessage{Text: g.Background}
+	steps := g.Remediation
+	if len(g.EngineSteps) > 0 {
+		steps = append(append([]string(nil), steps...), g.EngineSteps...)
+	}
+	if len(steps) > 0 {
+		rule.Help = &sarifMessage{Text: "- " + strings.Join(steps, "\n- ")}
+	}
+	return rule
+}
@@ -0,0 +1,171 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sarifOutput is the part of a SARIF log the tests read back.
+type sarifOutput struct {
+	Version string `json:"version"`
+	Runs    []struct {
+		Tool struct {
+			Driver struct {
+				Name  string `json:"name"`
+				Rules []struct {
+					ID              string `json:"id"`
+					FullDescription *struct {
+						Text string `json:"text"`
+					} `json:"fullDescription"`
+				} `json:"rules"`
+			} `json:"driver"`
+		} `json:"tool"`
+		Properties map[string]string `json:"properties"`
+		Results    []struct {
+			RuleID    string `json:"ruleId"`
+			RuleIndex int    `json:"ruleIndex"`
+			Level     string `json:"level"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+			Attachments []struct {
+				ArtifactLocation struct {
+					URI string `json:"uri"`
+				} `json:"artifactLocation"`
+			} `json:"attachments"`
+			Properties struct {
+				Evidence     string `json:"evidence"`
+				Reproduction string `json:"reproduction"`
+			} `json:"properties"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+// writeSARIF shapes r to detail, writes it with WriteFormat as selected by
+// the extension of the report and reads it back.
+func writeSARIF(t *testing.T, r *Report, detail string) (*sarifOutput, string) {
+	t.Helper()
+	out := t.TempDir()
+	reportFile := filepath.Join(out, "findings.sarif")
+	if err := ShapeEvidence(r, detail, reportFile, filepath.Join(out, "findings-requests"), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFormat(r, reportFile, ""); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log sarifOutput
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("report is not JSON: %v", err)
+	}
+	if log.Version != SARIFVersion || len(log.Runs) != 1 {
+		t.Fatalf("version %s with %d runs", log.Version, len(log.Runs))
+	}
+	return &log, out
+}
+
+func TestSARIFRulesAndResults(t *testing.T) {
+	r := detailReport(10)
+	r.Findings = append(r.Findings,
+		Finding{ID: "introspection-enabled", Title: "Introspection is enabled", Severity: SeverityLow, Endpoint: "https://b.example/graphql"},
+		Finding{ID: "unlisted-finding", Title: "Unlisted", Severity: SeverityCritical, Endpoint: "https://a.example/graphql"},
+	)
+	log, _ := writeSARIF(t, r, DetailStandard)
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "GraphSpecter" {
+		t.Errorf("driver = %s", run.Tool.Driver.Name)
+	}
+	if len(run.Tool.Driver.Rules) != 2 {
+		t.Fatalf("%d rules, want one per finding id", len(run.Tool.Driver.Rules))
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("%d results, want 3", len(run.Results))
+	}
+	for _, res := range run.Results {
+		rule := run.Tool.Driver.Rules[res.RuleIndex]
+		if rule.ID != res.RuleID {
+			t.Errorf("result %s points at rule %s", res.RuleID, rule.ID)
+		}
+		if len(res.Locations) != 1 || res.Locations[0].PhysicalLocation.ArtifactLocation.URI == "" {
+			t.Errorf("result %s has no endpoint location", res.RuleID)
+		}
+		switch res.RuleID {
+		case "introspection-enabled":
+			if rule.FullDescription == nil || rule.FullDescription.Text == "" {
+				t.Error("rule of a finding with a knowledge base entry has no description")
+			}
+		case "unlisted-finding":
+			if rule.FullDescription != nil {
+				t.Error("rule of a finding without a knowledge base entry has a description")
+			}
+		}
+	}
+	// Findings are sorted by severity first.
+	if res := run.Results[0]; res.RuleID != "unlisted-finding" || res.Level != "error" {
+		t.Errorf("first result is %s at level %s", res.RuleID, res.Level)
+	}
+	if res := run.Results[1]; res.Level != "note" {
+		t.Errorf("low finding at level %s", res.Level)
+	}
+}
+
+func TestSARIFFollowsDetail(t *testing.T) {
+	t.Run("summary", func(t *testing.T) {
+		log, _ := writeSARIF(t, detailReport(10), DetailSummary)
+		run := log.Runs[0]
+		res := run.Results[0]
+		if res.Properties.Evidence != "" || res.Properties.Reproduction != "" || len(res.Attachments) != 0 {
+			t.Errorf("summary result carries evidence: %+v", res)
+		}
+		if run.Properties["detail"] != DetailSummary {
+			t.Errorf("detail = %q", run.Properties["detail"])
+		}
+	})
+	t.Run("standard", func(t *testing.T) {
+		log, _ := writeSARIF(t, detailReport(EvidenceLimit+100), DetailStandard)
+		res := log.Runs[0].Results[0]
+		if !strings.HasPrefix(res.Properties.Evidence, strings.Repeat("e", EvidenceLimit)+"... [truncated") {
+			t.Errorf("standard evidence is not cut at %d bytes: %d bytes", EvidenceLimit, len(res.Properties.Evidence))
+		}
+		if len(res.Attachments) != 0 {
+			t.Error("standard result links an evidence file")
+		}
+	})
+	t.Run("full", func(t *testing.T) {
+		log, out := writeSARIF(t, detailReport(EvidenceLimit+100), DetailFull)
+		res := log.Runs[0].Results[0]
+		if len(res.Properties.Evidence) != EvidenceLimit+100 {
+			t.Errorf("full evidence has %d bytes", len(res.Properties.Evidence))
+		}
+		if len(res.Attachments) != 1 {
+			t.Fatalf("%d attachments, want the saved request", len(res.Attachments))
+		}
+		uri := res.Attachments[0].ArtifactLocation.URI
+		path := filepath.Join(out, filepath.FromSlash(uri))
+		if filepath.IsAbs(uri) || !strings.HasPrefix(path, out+string(filepath.Separator)) {
+			t.Fatalf("attachment %s does not resolve within %s", uri, out)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("attachment %s: %v", uri, err)
+		}
+	})
+}
+
+func TestSARIFFormatSelection(t *testing.T) {
+	if got := FormatFor("out/findings.SARIF"); got != FormatSARIF {
+		t.Errorf("FormatFor(.SARIF) = %s", got)
+	}
+	if !ValidFormat("sarif") {
+		t.Error("sarif is not a valid format")
+	}
+}
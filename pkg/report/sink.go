@@ -0,0 +1,370 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/clock"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+)
+
+// Sink kinds accepted by ParseSink
+const (
+	SinkJSON    = "json"
+	SinkNDJSON  = "ndjson"
+	SinkTable   = "table"
+	SinkWebhook = "webhook"
+)
+
+// sinkWebhookTimeout bounds the delivery of the summary of a webhook sink.
+const sinkWebhookTimeout = 10 * time.Second
+
+// Sink receives the findings of a run as they are found, then a summary of
+// the run once it is over.
+type Sink interface {
+	Emit(f Finding) error
+	Close(s Summary) error
+}
+
+// Summary describes a finished run to the sinks.
+type Summary struct {
+	Tool       string         `json:"tool"`
+	Version    string         `json:"version"`
+	Time       time.Time      `json:"time"`
+	Endpoints  []string       `json:"endpoints"`
+	Findings   int            `json:"findings"`
+	BySeverity map[string]int `json:"bySeverity"`
+	Stopped    *StopReason    `json:"stopped,omitempty"`
+}
+
+// NewSummary summarizes r.
+func NewSummary(r *Report) Summary {
+	s := Summary{
+		Tool:       r.Metadata.Tool,
+		Version:    r.Metadata.Version,
+		Time:       clock.Now(),
+		Endpoints:  r.Endpoints,
+		Findings:   len(r.Findings),
+		BySeverity: make(map[string]int),
+		Stopped:    r.Stopped,
+	}
+	for _, f := range r.Findings {
+		s.BySeverity[f.Severity]++
+	}
+	return s
+}
+
+// ParseSink opens the sink described by spec, "kind=target" or "table":
+//
+//   - json=file writes the findings and the summary to file as one JSON
+//     document at the end of the run,
+//   - ndjson=file writes each finding to file as a JSON line as soon as it
+//     is found, then the summary; "-" is standard output,
+//   - table prints the findings as a table on standard output at the end of
+//     the run,
+//   - webhook=url POSTs the summary and the findings as JSON to url at the
+//     end of the run.
+func ParseSink(spec string) (Sink, error) {
+	kind, target, _ := strings.Cut(spec, "=")
+	switch kind {
+	case SinkJSON:
+		if target == "" {
+			return nil, fmt.Errorf("sink %q needs a file, as json=<file>", spec)
+		}
+		return &jsonSink{file: target}, nil
+	case SinkNDJSON:
+		if target == "" || target == "-" {
+			return &ndjsonSink{w: os.Stdout}, nil
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return nil, fmt.Errorf("error opening sink %s: %w", spec, err)
+		}
+		return &ndjsonSink{w: f, file: f}, nil
+	case SinkTable:
+		if target != "" && target != "-" {
+			return nil, fmt.Errorf("sink %q prints to standard output and takes no target", spec)
+		}
+		return &tableSink{w: os.Stdout}, nil
+	case SinkWebhook:
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return nil, fmt.Errorf("sink %q needs an http or https URL, as webhook=<url>", spec)
+		}
+		return &webhookSink{url: target}, nil
+	}
+	return nil, fmt.Errorf("unknown sink %q (valid: 'json', 'ndjson', 'table', 'webhook')", kind)
+}
+
+// Dispatcher fans the findings of a run out to its sinks, in the order they
+// are emitted and sink by sink in the order given. A sink that fails is
+// warned about and keeps receiving the rest; it never stops the run. A nil
+// Dispatcher discards everything.
+type Dispatcher struct {
+	sinks []Sink
+	names []string
+	// redact masks secrets and credential headers in the findings before
+	// they reach the sinks.
+	redact  bool
+	secrets []string
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewDispatcher opens the sinks of specs. With redactHeaders, the findings
+// reaching them have secrets masked in their text and credential headers
+// masked in their requests.
+func NewDispatcher(specs []string, redactHeaders bool, secrets []string) (*Dispatcher, error) {
+	d := &Dispatcher{redact: redactHeaders, secrets: secrets}
+	for _, spec := range specs {
+		s, err := ParseSink(spec)
+		if err != nil {
+			d.Close(Summary{})
+			return nil, err
+		}
+		d.sinks = append(d.sinks, s)
+		d.names = append(d.names, spec)
+	}
+	return d, nil
+}
+
+// Emit hands f to every sink.
+func (d *Dispatcher) Emit(f Finding) {
+	if d == nil {
+		return
+	}
+	if d.redact {
+		f = maskFinding(f, d.secrets)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	for i, s := range d.sinks {
+		if err := s.Emit(f); err != nil {
+			logger.Info("WARNING: sink %s: %v", d.names[i], err)
+		}
+	}
+}
+
+// Close hands the summary of the run to every sink and closes them. Only the
+// first call has an effect.
+func (d *Dispatcher) Close(summary Summary) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	for i, s := range d.sinks {
+		if err := s.Close(summary); err != nil {
+			logger.Info("WARNING: sink %s: %v", d.names[i], err)
+		}
+	}
+}
+
+// maskFinding returns f with secrets masked in its text and the credential
+// headers of its request masked.
+func maskFinding(f Finding, secrets []string) Finding {
+	f.Description, _ = redact.Text(f.Description, secrets)
+	f.Evidence, _ = redact.Text(f.Evidence, secrets)
+	f.Reproduction, _ = redact.Text(f.Reproduction, secrets)
+	if f.Request != nil {
+		req := *f.Request
+		req.Headers, _ = redact.Headers(req.Headers)
+		f.Request = &req
+	}
+	return f
+}
+
+// jsonSink writes the findings and the summary to file once the run is over.
+type jsonSink struct {
+	file     string
+	findings []Finding
+}
+
+func (s *jsonSink) Emit(f Finding) error {
+	s.findings = append(s.findings, f)
+	return nil
+}
+
+func (s *jsonSink) Close(summary Summary) error {
+	SortFindings(s.findings)
+	data, err := json.MarshalIndent(struct {
+		Summary  Summary   `json:"summary"`
+		Findings []Finding `json:"findings"`
+	}{summary, nonNil(s.findings)}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling findings: %w", err)
+	}
+	if err := artifacts.WriteFile(s.file, data, 0644); err != nil {
+		return fmt.Errorf("error writing findings: %w", err)
+	}
+	return nil
+}
+
+// ndjsonSink writes each finding as a JSON line as soon as it is emitted,
+// then the summary.
+type ndjsonSink struct {
+	w io.Writer
+	// file is closed with the sink; standard output is not.
+	file *os.File
+}
+
+// ndjsonLine is a line of an ndjson sink: a finding or the summary.
+type ndjsonLine struct {
+	Type    string   `json:"type"`
+	Finding *Finding `json:"finding,omitempty"`
+	Summary *Summary `json:"summary,omitempty"`
+}
+
+func (s *ndjsonSink) Emit(f Finding) error {
+	return s.write(ndjsonLine{Type: "finding", Finding: &f})
+}
+
+func (s *ndjsonSink) Close(summary Summary) error {
+	err := s.write(ndjsonLine{Type: "summary", Summary: &summary})
+	if s.file != nil {
+		if cerr := s.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *ndjsonSink) write(line ndjsonLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("error marshalling %s: %w", line.Type, err)
+	}
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+// ANSI color codes of the severities in tables, and of the text around them.
+// They all have the same length.
+var severityTerminalColors = map[string]string{
+	SeverityCritical: "\033[35m", // Magenta
+	SeverityHigh:     "\033[31m", // Red
+	SeverityMedium:   "\033[33m", // Yellow
+	SeverityLow:      "\033[36m", // Cyan
+	SeverityInfo:     "\033[37m", // White
+}
+
+const colorDefault = "\033[39m"
+
+// tableSink prints the findings as a table once the run is over.
+type tableSink struct {
+	w        io.Writer
+	findings []Finding
+}
+
+// severity returns severity in its color when the output of s is colored.
+func (s *tableSink) severity(severity string) string {
+	code, ok := severityTerminalColors[severity]
+	if !ok {
+		code = colorDefault
+	}
+	return logger.Color(s.w, code, severity)
+}
+
+func (s *tableSink) Emit(f Finding) error {
+	s.findings = append(s.findings, f)
+	return nil
+}
+
+func (s *tableSink) Close(summary Summary) error {
+	SortFindings(s.findings)
+	tw := tabwriter.NewWriter(s.w, 0, 4, 2, ' ', 0)
+	// The header is wrapped in a color code of the same length as those of
+	// the severities, which tabwriter counts as text, to stay aligned.
+	fmt.Fprintf(tw, "%s\tID\tENDPOINT\tTITLE\n", logger.Color(s.w, colorDefault, "SEVERITY"))
+	for _, f := range s.findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.severity(f.Severity), f.ID, f.Endpoint, f.Title)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	var counts []string
+	for _, severity := range []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo} {
+		if n := summary.BySeverity[severity]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, s.severity(severity)))
+		}
+	}
+	line := fmt.Sprintf("%d finding(s) on %d endpoint(s)", summary.Findings, len(summary.Endpoints))
+	if len(counts) > 0 {
+		line += ": " + strings.Join(counts, ", ")
+	}
+	if summary.Stopped != nil {
+		line += "\nRun cut short: " + summary.Stopped.Reason
+	}
+	_, err := fmt.Fprintln(s.w, line)
+	return err
+}
+
+// webhookSink POSTs the summary and the findings to url once the run is over.
+// The webhook is not a scan target, so it is sent with a client of its own,
+// outside the scope, rate limit and signing of the scan traffic.
+type webhookSink struct {
+	url      string
+	findings []Finding
+}
+
+func (s *webhookSink) Emit(f Finding) error {
+	s.findings = append(s.findings, f)
+	return nil
+}
+
+func (s *webhookSink) Close(summary Summary) error {
+	SortFindings(s.findings)
+	body, err := json.Marshal(struct {
+		Summary  Summary   `json:"summary"`
+		Findings []Finding `json:"findings"`
+	}{summary, nonNil(s.findings)})
+	if err != nil {
+		return fmt.Errorf("error marshalling webhook summary: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sinkWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending webhook summary: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// nonNil returns findings, or an empty slice when it is nil, so that it
+// encodes as [] rather than null.
+func nonNil(findings []Finding) []Finding {
+	if findings == nil {
+		return []Finding{}
+	}
+	return findings
+}
@@ -0,0 +1,333 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+)
+
+// fakeTerminal is a buffer that claims to be a terminal.
+type fakeTerminal struct {
+	bytes.Buffer
+}
+
+func (*fakeTerminal) IsTerminal() bool { return true }
+
+var ansiCode = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// closeTable prints findings to a table sink writing to w.
+func closeTable(t *testing.T, w io.Writer, findings []Finding) {
+	t.Helper()
+	s := &tableSink{w: w}
+	summary := Summary{Endpoints: []string{"https://api.example.com/graphql"}, Findings: len(findings), BySeverity: map[string]int{}}
+	for _, f := range findings {
+		if err := s.Emit(f); err != nil {
+			t.Fatal(err)
+		}
+		summary.BySeverity[f.Severity]++
+	}
+	if err := s.Close(summary); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var tableFindings = []Finding{
+	{ID: "introspection-enabled", Severity: SeverityMedium, Endpoint: "https://api.example.com/graphql", Title: "Introspection enabled"},
+	{ID: "sqli", Severity: SeverityCritical, Endpoint: "https://api.example.com/graphql", Title: "SQL injection"},
+	{ID: "field-suggestions", Severity: SeverityLow, Endpoint: "https://api.example.com/graphql", Title: "Field suggestions"},
+}
+
+func TestTableSinkColorsSeveritiesOnTerminals(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	logger.EnableColors(true)
+
+	out := &fakeTerminal{}
+	closeTable(t, out, tableFindings)
+	text := out.String()
+	for sev, code := range map[string]string{SeverityCritical: "\033[35m", SeverityMedium: "\033[33m", SeverityLow: "\033[36m"} {
+		if got := strings.Count(text, code+sev+"\033[0m"); got != 2 {
+			t.Errorf("%s colored %d times, want in its row and the summary:\n%s", sev, got, text)
+		}
+	}
+
+	// Once the codes are stripped, the table is the plain one: the codes
+	// do not shift its columns.
+	var plain bytes.Buffer
+	closeTable(t, &plain, tableFindings)
+	if got := ansiCode.ReplaceAllString(text, ""); got != plain.String() {
+		t.Errorf("colored table without its codes =\n%s\nwant\n%s", got, plain.String())
+	}
+}
+
+func TestTableSinkPlainOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		w       func(t *testing.T) (w io.Writer, read func() string)
+		noColor string
+		enable  bool
+	}{
+		{name: "buffer", enable: true, w: func(*testing.T) (io.Writer, func() string) {
+			b := &bytes.Buffer{}
+			return b, b.String
+		}},
+		{name: "NO_COLOR terminal", enable: true, noColor: "1", w: func(*testing.T) (io.Writer, func() string) {
+			b := &fakeTerminal{}
+			return b, b.String
+		}},
+		{name: "no-color terminal", enable: false, w: func(*testing.T) (io.Writer, func() string) {
+			b := &fakeTerminal{}
+			return b, b.String
+		}},
+		{name: "pipe", enable: true, w: func(t *testing.T) (io.Writer, func() string) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { r.Close() })
+			return w, func() string {
+				w.Close()
+				var b bytes.Buffer
+				b.ReadFrom(r)
+				return b.String()
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			logger.EnableColors(tt.enable)
+			defer logger.EnableColors(true)
+
+			w, read := tt.w(t)
+			closeTable(t, w, tableFindings)
+			text := read()
+			if strings.Contains(text, "\033[") {
+				t.Errorf("table has escape codes:\n%q", text)
+			}
+			if !strings.HasPrefix(text, "SEVERITY") || !strings.Contains(text, "3 finding(s) on 1 endpoint(s): 1 critical, 1 medium, 1 low") {
+				t.Errorf("table =\n%s", text)
+			}
+		})
+	}
+}
+
+func TestTableSinkStatesStop(t *testing.T) {
+	var out bytes.Buffer
+	s := &tableSink{w: &out}
+	s.Emit(tableFindings[0])
+	stopped := &StopReason{Reason: "finding introspection-enabled (medium) on https://api.example.com/graphql met --stop-on-finding medium", Finding: "introspection-enabled"}
+	if err := s.Close(Summary{Endpoints: []string{"https://api.example.com/graphql"}, Findings: 1, BySeverity: map[string]int{SeverityMedium: 1}, Stopped: stopped}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1 finding(s) on 1 endpoint(s): 1 medium\nRun cut short: " + stopped.Reason + "\n"; !strings.HasSuffix(out.String(), want) {
+		t.Errorf("table =\n%s\nwant it to end with\n%s", out.String(), want)
+	}
+}
+
+// recordingSink appends what it receives to a log shared between sinks, and
+// fails with err when it is set.
+type recordingSink struct {
+	name string
+	log  *[]string
+	err  error
+}
+
+func (s *recordingSink) Emit(f Finding) error {
+	*s.log = append(*s.log, s.name+" "+f.ID)
+	return s.err
+}
+
+func (s *recordingSink) Close(summary Summary) error {
+	*s.log = append(*s.log, fmt.Sprintf("%s close %d", s.name, summary.Findings))
+	return s.err
+}
+
+// captureLogger returns what the logger prints at the info level during the
+// test.
+func captureLogger(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var b bytes.Buffer
+	logger.SetOutput(&b)
+	logger.SetLevel(logger.LevelInfo)
+	t.Cleanup(func() { logger.SetOutput(os.Stdout) })
+	return &b
+}
+
+func TestDispatcherFanOutOrder(t *testing.T) {
+	var log []string
+	d := &Dispatcher{}
+	for _, name := range []string{"a", "b", "c"} {
+		d.sinks = append(d.sinks, &recordingSink{name: name, log: &log})
+		d.names = append(d.names, name)
+	}
+	for _, id := range []string{"f1", "f2", "f3"} {
+		d.Emit(Finding{ID: id})
+	}
+	d.Close(Summary{Findings: 3})
+	// Nothing reaches the sinks once they are closed.
+	d.Emit(Finding{ID: "late"})
+	d.Close(Summary{Findings: 4})
+
+	want := []string{
+		"a f1", "b f1", "c f1",
+		"a f2", "b f2", "c f2",
+		"a f3", "b f3", "c f3",
+		"a close 3", "b close 3", "c close 3",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("the sinks received\n%q\nwant\n%q", log, want)
+	}
+}
+
+// TestDispatcherConcurrentEmits emits from several goroutines, as the checks
+// of concurrent targets do: every sink sees the findings in the same order.
+func TestDispatcherConcurrentEmits(t *testing.T) {
+	logs := make([][]string, 3)
+	d := &Dispatcher{}
+	for i := range logs {
+		d.sinks = append(d.sinks, &recordingSink{log: &logs[i]})
+		d.names = append(d.names, fmt.Sprint(i))
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				d.Emit(Finding{ID: fmt.Sprintf("g%d-%d", g, i)})
+			}
+		}(g)
+	}
+	wg.Wait()
+	if len(logs[0]) != 200 {
+		t.Fatalf("the first sink received %d findings, want 200", len(logs[0]))
+	}
+	for i := 1; i < len(logs); i++ {
+		if !reflect.DeepEqual(logs[i], logs[0]) {
+			t.Errorf("sink %d received the findings in another order than sink 0", i)
+		}
+	}
+}
+
+// TestDispatcherFailureIsolation runs a dispatcher whose first sinks fail: a
+// sink erroring on every call, a webhook answering 500 and a webhook to a
+// closed port. The sinks after them still receive every finding and the
+// summary, and each failure is only warned about.
+func TestDispatcherFailureIsolation(t *testing.T) {
+	out := captureLogger(t)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "down for maintenance", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	var webhook struct {
+		Summary  Summary   `json:"summary"`
+		Findings []Finding `json:"findings"`
+	}
+	var contentType string
+	recording := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&webhook)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer recording.Close()
+
+	dir := t.TempDir()
+	ndjsonFile := filepath.Join(dir, "findings.ndjson")
+	jsonFile := filepath.Join(dir, "findings.json")
+	d, err := NewDispatcher([]string{
+		"webhook=" + failing.URL,
+		"webhook=" + closed.URL,
+		"ndjson=" + ndjsonFile,
+		"json=" + jsonFile,
+		"webhook=" + recording.URL,
+	}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log []string
+	d.sinks = append([]Sink{&recordingSink{name: "broken", log: &log, err: errors.New("disk full")}}, d.sinks...)
+	d.names = append([]string{"broken"}, d.names...)
+
+	findings := []Finding{
+		{ID: "introspection-enabled", Severity: SeverityMedium, Endpoint: "https://api.example.com/graphql"},
+		{ID: "sqli", Severity: SeverityCritical, Endpoint: "https://api.example.com/graphql"},
+	}
+	for _, f := range findings {
+		d.Emit(f)
+	}
+	d.Close(Summary{Tool: "graphspecter", Endpoints: []string{"https://api.example.com/graphql"}, Findings: 2, BySeverity: map[string]int{SeverityMedium: 1, SeverityCritical: 1}})
+
+	if want := []string{"broken introspection-enabled", "broken sqli", "broken close 2"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("the failing sink received %q, want %q", log, want)
+	}
+	for _, want := range []string{
+		"WARNING: sink broken: disk full",
+		"WARNING: sink webhook=" + failing.URL + ": webhook answered 500 Internal Server Error",
+		"WARNING: sink webhook=" + closed.URL + ": error sending webhook summary",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("log:\n%s\nwant %q", out, want)
+		}
+	}
+	if n := strings.Count(out.String(), "WARNING"); n != 5 {
+		t.Errorf("%d warnings, want 3 of the broken sink and 1 per failing webhook:\n%s", n, out)
+	}
+
+	f, err := os.Open(ndjsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var types []string
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var line ndjsonLine
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("ndjson line %q: %v", sc.Text(), err)
+		}
+		switch {
+		case line.Finding != nil:
+			types = append(types, line.Type+" "+line.Finding.ID)
+		case line.Summary != nil:
+			types = append(types, fmt.Sprintf("%s %d", line.Type, line.Summary.Findings))
+		}
+	}
+	if want := []string{"finding introspection-enabled", "finding sqli", "summary 2"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("ndjson lines %q, want %q", types, want)
+	}
+
+	data, err := os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Summary  Summary   `json:"summary"`
+		Findings []Finding `json:"findings"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	// The json and webhook sinks sort the findings by severity.
+	if len(doc.Findings) != 2 || doc.Findings[0].ID != "sqli" || doc.Summary.Findings != 2 {
+		t.Errorf("json sink wrote %s", data)
+	}
+	if contentType != "application/json" || len(webhook.Findings) != 2 || webhook.Findings[0].ID != "sqli" || webhook.Summary.BySeverity[SeverityCritical] != 1 {
+		t.Errorf("the webhook received %+v with Content-Type %q", webhook, contentType)
+	}
+}
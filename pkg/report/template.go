@@ -0,0 +1,159 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// Built-in report templates selectable by name with --report-template
+const (
+	TemplateExecutive = "executive"
+	TemplateTechnical = "technical"
+)
+
+// severityColors matches the colours of the HTML report.
+var severityColors = map[string]string{
+	SeverityCritical: "#b00020",
+	SeverityHigh:     "#b00020",
+	SeverityMedium:   "#c75b00",
+	SeverityLow:      "#8a6d00",
+	SeverityInfo:     "#555555",
+}
+
+// templateFuncs are the helpers available to report templates.
+var templateFuncs = template.FuncMap{
+	// severityColor returns the hex colour of a severity.
+	"severityColor": func(severity string) string {
+		if c, ok := severityColors[strings.ToLower(severity)]; ok {
+			return c
+		}
+		return severityColors[SeverityInfo]
+	},
+	// truncate shortens s to n runes, marking the cut with "...". It takes s
+	// last so that it chains: {{.Evidence | truncate 80}}.
+	"truncate": func(n int, s string) string {
+		r := []rune(s)
+		if n < 0 || len(r) <= n {
+			return s
+		}
+		if n <= 3 {
+			return string(r[:n])
+		}
+		return string(r[:n-3]) + "..."
+	},
+	// codeblock fences s as a Markdown code block in lang, with a fence longer
+	// than any backtick run in s.
+	"codeblock": func(lang, s string) string {
+		fence := "```"
+		for strings.Contains(s, fence) {
+			fence += "`"
+		}
+		return fence + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + fence
+	},
+	// curl returns the reproduction command of a finding, or an empty string
+	// when it recorded no request.
+	"curl": func(f Finding) string {
+		if f.Reproduction != "" {
+			return f.Reproduction
+		}
+		if f.Request != nil {
+			return CurlFor(*f.Request)
+		}
+		return ""
+	},
+	// bySeverity returns the findings of the given severity.
+	"bySeverity": func(severity string, findings []Finding) []Finding {
+		var matched []Finding
+		for _, f := range findings {
+			if f.Severity == severity {
+				matched = append(matched, f)
+			}
+		}
+		return matched
+	},
+	// severities lists the severity levels from critical to info.
+	"severities": func() []string {
+		return []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  func(sep string, elems []string) string { return strings.Join(elems, sep) },
+}
+
+// BuiltinTemplateNames lists the templates shipped with the binary.
+func BuiltinTemplateNames() []string {
+	return []string{TemplateExecutive, TemplateTechnical}
+}
+
+// LoadTemplate parses the built-in template called name, or the Go text/template
+// at that path otherwise. Parse errors name the file and line of the template.
+func LoadTemplate(name string) (*template.Template, error) {
+	var (
+		text []byte
+		err  error
+	)
+	tmplName := name
+	if isBuiltinTemplate(name) {
+		tmplName = name + ".md.tmpl"
+		text, err = builtinTemplates.ReadFile("templates/" + tmplName)
+	} else {
+		text, err = os.ReadFile(name)
+		tmplName = filepath.Base(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading report template: %w", err)
+	}
+	tmpl, err := template.New(tmplName).Funcs(templateFuncs).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing report template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func isBuiltinTemplate(name string) bool {
+	for _, b := range BuiltinTemplateNames() {
+		if name == b {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderTemplate executes tmpl against the report. Findings are sorted first.
+// Execution errors carry the template name, line and column of the failing action.
+func RenderTemplate(r *Report, tmpl *template.Template) ([]byte, error) {
+	SortFindings(r.Findings)
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, r); err != nil {
+		return nil, fmt.Errorf("error rendering report template: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// WriteTemplate renders the report with the template loaded from name, a
+// built-in template name or a file, and writes it to filename.
+func WriteTemplate(r *Report, filename, name string) error {
+	tmpl, err := LoadTemplate(name)
+	if err != nil {
+		return err
+	}
+	out, err := RenderTemplate(r, tmpl)
+	if err != nil {
+		return err
+	}
+	if err := artifacts.WriteFile(filename, out, 0644); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/schema"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// templateReport is a report using every part of the data model the
+// built-in templates render.
+func templateReport() *Report {
+	findings := goldenFindings()
+	findings[1].Description = "The full schema is returned to anonymous clients."
+	findings[1].Evidence = "__schema returned 42 types\n```\n{\"__schema\":{}}\n```"
+	findings[1].References = []string{"https://graphql.org/learn/introspection/"}
+	findings[1].Request = &RequestEvidence{Method: "POST", URL: "https://b.example/graphql", Headers: map[string]string{"Content-Type": "application/json"}, Body: `{"query":"{ __schema { types { name } } }"}`}
+	findings[4].Reproduction = "curl -s 'https://a.example/graphql?query=%7B__typename%7D'"
+	findings[4].EvidenceFile = "evidence/csrf-get-queries.http"
+	findings = append(findings, Finding{
+		ID: "alias-overloading", Check: "aliases", Title: "Aliases multiply resolver work", Severity: SeverityHigh, Endpoint: "https://a.example/graphql",
+		Description: strings.TrimSpace(strings.Repeat("Each alias resolves the field again. ", 8)),
+	})
+	return &Report{
+		Metadata:  Metadata{Tool: "graphspecter", Version: "1.0.0", Commit: "abc1234", BuildDate: "2024-05-01"},
+		Endpoints: []string{"https://a.example/graphql", "https://b.example/graphql"},
+		Engines:   map[string]string{"https://a.example/graphql": "Apollo Server", "https://b.example/graphql": "Apollo Server"},
+		Checks: []CheckResult{
+			{Check: "introspection", Endpoint: "https://a.example/graphql", Status: "completed", DurationMs: 12},
+			{Check: "batching", Endpoint: "https://b.example/graphql", Status: "failed", Error: "connection reset by peer", DurationMs: 3},
+			{Check: "depth", Endpoint: "https://b.example/graphql", Status: "skipped", Reason: "introspection disabled"},
+		},
+		Findings: findings,
+		Stats:    &types.NetworkStats{Requests: 31, Retries: 2, RateLimitWaits: 1, BytesSent: 4096, BytesReceived: 65536, NewConns: 2, ReusedConns: 29, WallTime: "4.2s"},
+		Stopped:  &StopReason{Reason: "finding at or above high severity", Finding: "alias-overloading", Endpoint: "https://a.example/graphql"},
+		Canaries: []CanaryResult{
+			{Endpoint: "https://a.example/graphql", Query: "{ me { id } }"},
+			{Endpoint: "https://b.example/graphql", Query: "{ stats { orders } }", Drift: true},
+		},
+		Catalogs: []EndpointCatalog{{Endpoint: "https://a.example/graphql", Catalog: &schema.Catalog{Operations: []schema.CatalogOperation{
+			{Kind: schema.KindQuery, Name: "user", ReturnType: "User", Sensitive: []string{"field:User.email"}, AuthHints: []string{"returns User.email; a personal field"}, Tags: []string{"pii"}, Notes: []string{"owner only"}},
+			{Kind: schema.KindMutation, Name: "logout", ReturnType: "Boolean!"},
+		}}}},
+		Redactions: 3,
+	}
+}
+
+// renderBuiltin renders r with the built-in template called name.
+func renderBuiltin(t *testing.T, name string, r *Report) []byte {
+	t.Helper()
+	tmpl, err := LoadTemplate(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := RenderTemplate(r, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	for _, name := range BuiltinTemplateNames() {
+		t.Run(name, func(t *testing.T) {
+			golden(t, name+".golden.md", renderBuiltin(t, name, templateReport()))
+		})
+	}
+}
+
+func TestBuiltinTemplatesWithoutKeyFindings(t *testing.T) {
+	r := &Report{
+		Metadata:  Metadata{Tool: "graphspecter", Version: "1.0.0", Commit: "abc1234", BuildDate: "2024-05-01"},
+		Endpoints: []string{"https://a.example/graphql"},
+		Findings:  []Finding{goldenFindings()[0], goldenFindings()[5]},
+	}
+	golden(t, "executive-clean.golden.md", renderBuiltin(t, TemplateExecutive, r))
+	r.Findings = nil
+	golden(t, "technical-empty.golden.md", renderBuiltin(t, TemplateTechnical, r))
+}
+
+func TestTemplateHelpers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "helpers.md.tmpl")
+	text := `{{severityColor "HIGH"}} {{severityColor "bogus"}}
+{{"abcdefghij" | truncate 6}} {{"abc" | truncate 2}} {{"abc" | truncate 5}}
+{{codeblock "go" "a ` + "```" + ` b\n"}}
+{{range .Findings}}{{curl .}}{{end}}`
+	if err := os.WriteFile(path, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := LoadTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Report{Findings: []Finding{{ID: "x", Severity: SeverityLow, Request: &RequestEvidence{Method: "POST", URL: "https://a.example/graphql", Body: `{"query":"{ a }"}`}}}}
+	out, err := RenderTemplate(r, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#b00020 #555555\nabc... ab abc\n````go\na ``` b\n````\n" + CurlFor(*r.Findings[0].Request)
+	if string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestTemplateErrorsNameTheLine(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name, text, want string
+	}{
+		{"parse.md.tmpl", "# Report\n\n{{range .Findings}}\n- {{.Title}\n{{end}}", "parse.md.tmpl:4"},
+		{"exec.md.tmpl", "# Report\n\n{{.Metadata.Tool}}\n{{.NoSuchField}}\n", "exec.md.tmpl:4:2"},
+		{"func.md.tmpl", "# Report\n{{range .Findings}}\n{{truncate .Title 10}}\n{{end}}", "func.md.tmpl:3:"},
+	} {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte(tt.text), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := WriteTemplate(&Report{Findings: goldenFindings()}, filepath.Join(dir, "out.md"), path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want one naming %s", tt.name, err, tt.want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.md")); !os.IsNotExist(err) {
+		t.Errorf("a failing template left a report behind: %v", err)
+	}
+	if _, err := LoadTemplate(filepath.Join(dir, "missing.md.tmpl")); err == nil || !strings.Contains(err.Error(), "error reading report template") {
+		t.Errorf("LoadTemplate() of a missing file = %v", err)
+	}
+}
@@ -0,0 +1,362 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// CatalogVersion is the version of the catalog format written by WriteCatalog.
+// It is increased whenever a field changes meaning or is removed.
+const CatalogVersion = 1
+
+// Catalog describes the full operation surface of a schema
+type Catalog struct {
+	Version    int                `json:"version"`
+	Operations []CatalogOperation `json:"operations"`
+	// AuthSurface groups the operations by the guard directives applied to
+	// them. It is only set when some operation has one.
+	AuthSurface []AuthGroup `json:"authSurface,omitempty"`
+}
+
+// AuthGroup lists the operations of a catalog guarded by the same directive,
+// or by none for the group named Unguarded.
+type AuthGroup struct {
+	// Guard is the directive as in SDL, such as @requiresScopes(scopes:
+	// [["admin"]]).
+	Guard string `json:"guard"`
+	// Operations are named as "kind name", such as "mutation deleteUser".
+	Operations []string `json:"operations"`
+}
+
+// Unguarded is the Guard of the AuthGroup of the operations no guard
+// directive applies to.
+const Unguarded = "unguarded"
+
+// CatalogOperation describes a single root field of the query, mutation or subscription type
+type CatalogOperation struct {
+	Kind              string            `json:"kind"`
+	Name              string            `json:"name"`
+	Description       string            `json:"description,omitempty"`
+	Arguments         []CatalogArgument `json:"arguments"`
+	ReturnType        string            `json:"returnType"`
+	Deprecated        bool              `json:"deprecated,omitempty"`
+	DeprecationReason string            `json:"deprecationReason,omitempty"`
+	// Depth is the nesting depth of the selection set of Document.
+	Depth int `json:"depth"`
+	// Sensitive lists arguments, input fields and returned fields whose names
+	// pass IsSensitiveName, as "argument:name" or "field:Type.name".
+	Sensitive []string `json:"sensitive,omitempty"`
+	// AuthHints are reasons to expect the operation to require authorization.
+	AuthHints []string `json:"authHints,omitempty"`
+	// Directives are the directives applied to the root field, as read by
+	// pkg/schema/metadata, rendered as in SDL.
+	Directives []string `json:"directives,omitempty"`
+	// Document is the operation generated with the selection set up to
+	// CatalogOptions.MaxDepth, with argument types in place of values.
+	Document string `json:"document"`
+	// Selection is the projection of the selection sets of Document,
+	// Executable and Pagination.
+	Selection string `json:"selection"`
+	// Executable is a minimal executable document with placeholder arguments.
+	Executable string `json:"executable"`
+	// Hash is the canonical hash of Executable, shared by the same operation
+	// in the catalogs of every endpoint.
+	Hash string `json:"hash,omitempty"`
+	// Pagination is set for queries whose result can be paged through.
+	Pagination *Pagination `json:"pagination,omitempty"`
+	// Notes and Tags are the annotations of CatalogOptions.Notes that apply to
+	// the operation.
+	Notes []string `json:"notes,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// CatalogArgument describes an argument of a catalog operation
+type CatalogArgument struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Required     bool   `json:"required"`
+	DefaultValue string `json:"defaultValue,omitempty"`
+}
+
+// CatalogOptions controls how the operations of a catalog are generated
+type CatalogOptions struct {
+	MaxDepth int
+	// Selection is a projection accepted by ValidSelection; empty means
+	// SelectionStandard.
+	Selection string
+	// Sort is a sort mode accepted by SortNames.
+	Sort string
+	// Notes annotate the operations, as loaded by LoadNotes.
+	Notes Notes
+	// Sample selects the operations described; its zero value keeps them
+	// all, as does a strategy ValidSampleStrategy rejects.
+	Sample Sample
+}
+
+// Catalog operation kinds
+const (
+	KindQuery        = "query"
+	KindMutation     = "mutation"
+	KindSubscription = "subscription"
+)
+
+var (
+	// authNamePattern matches root field names that usually sit behind authentication
+	authNamePattern = regexp.MustCompile(`(?i)^(me|viewer|current_?user|whoami)$|admin|internal|private|impersonat|logout|permission|role`)
+	// authDescriptionPattern matches descriptions that mention access requirements
+	authDescriptionPattern = regexp.MustCompile(`(?i)\b(auth\w*|admins?|permissions?|roles?|scopes?|logged[- ]in|requires? login|private|internal)\b`)
+	// authDirectivePattern matches the names of directives that guard fields,
+	// such as @auth, @authenticated, @requiresScopes, @hasRole, @policy or the
+	// @hasuraPermission recorded from Hasura permission metadata
+	authDirectivePattern = regexp.MustCompile(`(?i)^(auth\w*|is_?authenticated|requires\w*|has_?(role|scope|permission)s?|hasura_?permissions?|polic(y|ies)|private|internal)$`)
+)
+
+// BuildCatalog is Index.BuildCatalog on an index of s built for the call.
+func BuildCatalog(s *types.GQLSchema, opts CatalogOptions) *Catalog {
+	return NewIndex(s).BuildCatalog(opts)
+}
+
+// BuildCatalog describes every query, mutation and subscription of the
+// schema, or those opts.Sample selects.
+func (x *Index) BuildCatalog(opts CatalogOptions) *Catalog {
+	c := &Catalog{Version: CatalogVersion, Operations: []CatalogOperation{}}
+	keep, _ := opts.Sample.selectIndexed(x)
+	for _, kind := range []string{KindQuery, KindMutation, KindSubscription} {
+		var names []string
+		for _, f := range x.Operations(kind) {
+			if keep.Has(kind, f.Name) {
+				names = append(names, f.Name)
+			}
+		}
+		for _, name := range SortNames(names, opts.Sort) {
+			f, _ := x.Operation(kind, name)
+			c.Operations = append(c.Operations, x.catalogOperation(kind, f, opts))
+		}
+	}
+	c.AuthSurface = x.authSurface(c.Operations)
+	return c
+}
+
+// authSurface groups ops by the guard directives applied to their root field,
+// groups sorted by guard and the unguarded operations last. An operation
+// with several guards is in each of their groups. It returns nil when no
+// operation has a guard.
+func (x *Index) authSurface(ops []CatalogOperation) []AuthGroup {
+	groups := make(map[string][]string)
+	var unguarded []string
+	for _, op := range ops {
+		f, ok := x.Operation(op.Kind, op.Name)
+		if !ok {
+			continue
+		}
+		name := op.Kind + " " + op.Name
+		guarded := false
+		for _, d := range f.AppliedDirectives {
+			if authDirectivePattern.MatchString(d.Name) {
+				groups[d.String()] = append(groups[d.String()], name)
+				guarded = true
+			}
+		}
+		if !guarded {
+			unguarded = append(unguarded, name)
+		}
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	guards := make([]string, 0, len(groups))
+	for guard := range groups {
+		guards = append(guards, guard)
+	}
+	sort.Strings(guards)
+	surface := make([]AuthGroup, 0, len(guards)+1)
+	for _, guard := range guards {
+		surface = append(surface, AuthGroup{Guard: guard, Operations: groups[guard]})
+	}
+	if len(unguarded) > 0 {
+		surface = append(surface, AuthGroup{Guard: Unguarded, Operations: unguarded})
+	}
+	return surface
+}
+
+func (x *Index) catalogOperation(kind string, f IndexedField, opts CatalogOptions) CatalogOperation {
+	op := CatalogOperation{
+		Kind:              kind,
+		Name:              f.Name,
+		Description:       f.Description,
+		Arguments:         []CatalogArgument{},
+		ReturnType:        f.Type.String(),
+		Deprecated:        f.IsDeprecated,
+		DeprecationReason: f.DeprecationReason,
+		Selection:         opts.Selection,
+	}
+	if op.Selection == "" {
+		op.Selection = SelectionStandard
+	}
+	op.Notes, op.Tags = opts.Notes.Operation(kind, *f.Field)
+
+	for _, arg := range f.Args {
+		op.Arguments = append(op.Arguments, CatalogArgument{
+			Name:         arg.Name,
+			Type:         arg.Type.String(),
+			Required:     arg.Type.Kind == types.NON_NULL && arg.DefaultValue == "",
+			DefaultValue: arg.DefaultValue,
+		})
+		if IsSensitiveName(arg.Name) {
+			op.Sensitive = append(op.Sensitive, "argument:"+arg.Name)
+		}
+		if input, ok := x.Type(unwrapType(&arg.Type).Name); ok && input.Kind == types.INPUT_OBJECT {
+			for _, field := range input.InputFields {
+				if IsSensitiveName(field.Name) {
+					op.Sensitive = append(op.Sensitive, "field:"+input.Name+"."+field.Name)
+				}
+			}
+		}
+	}
+	for _, field := range x.Fields(f.Named.Name) {
+		if IsSensitiveName(field.Name) {
+			op.Sensitive = append(op.Sensitive, "field:"+f.Named.Name+"."+field.Name)
+		}
+	}
+	op.AuthHints = authHints(*f.Field)
+	for _, d := range f.AppliedDirectives {
+		op.Directives = append(op.Directives, d.String())
+	}
+
+	var err error
+	switch kind {
+	case KindQuery:
+		op.Document, err = x.GenerateQuery(f.Name, opts.MaxDepth, op.Selection)
+		if err == nil {
+			op.Executable, err = x.GenerateMinimalQuery(f.Name, op.Selection)
+		}
+		op.Pagination = x.DetectPagination(f, op.Selection)
+	case KindMutation:
+		op.Document, err = x.GenerateMutation(f.Name, opts.MaxDepth, op.Selection)
+		if err == nil {
+			op.Executable, err = x.GenerateMinimalMutation(f.Name, op.Selection)
+		}
+	case KindSubscription:
+		op.Document = x.generateSubscription(f, opts.MaxDepth, op.Selection)
+		op.Executable, err = x.generateMinimalOperation(KindSubscription, f.Name, "", op.Selection)
+	}
+	if err != nil {
+		op.Document = "# " + err.Error()
+	}
+	if op.Executable != "" {
+		op.Hash, _ = gql.CanonicalHash(op.Executable)
+	}
+	op.Depth = selectionDepth(op.Document)
+	return op
+}
+
+// generateSubscription renders a subscription the way GenerateQuery renders queries
+func (x *Index) generateSubscription(f IndexedField, maxDepth int, selection string) string {
+	doc := fmt.Sprintf("subscription %s {\n  %s", f.Name, f.Name)
+	if len(f.Args) > 0 {
+		args := make([]string, len(f.Args))
+		for i, arg := range f.Args {
+			args[i] = fmt.Sprintf("%s: %s", arg.Name, arg.Type.String())
+		}
+		doc += "(" + strings.Join(args, ", ") + ")"
+	}
+	if set := x.generateSelectionSetWithCount(f.Named.Name, maxDepth, "  ", make(map[string]int), selection); set != "" {
+		return doc + " {" + set + "\n  }\n}"
+	}
+	return doc + "\n}"
+}
+
+// authHints explains why an operation probably requires authorization
+func authHints(f types.Field) []string {
+	var hints []string
+	if authNamePattern.MatchString(f.Name) {
+		hints = append(hints, "name suggests privileged or per-user access")
+	}
+	if m := authDescriptionPattern.FindString(f.Description); m != "" {
+		hints = append(hints, fmt.Sprintf("description mentions %q", strings.ToLower(m)))
+	}
+	for _, arg := range f.Args {
+		if IsSensitiveName(arg.Name) {
+			hints = append(hints, "takes credential-like argument "+arg.Name)
+		}
+	}
+	for _, d := range f.AppliedDirectives {
+		if authDirectivePattern.MatchString(d.Name) {
+			hints = append(hints, "applies directive "+d.String())
+		}
+	}
+	return hints
+}
+
+// selectionDepth returns the nesting depth of the selection set of a generated
+// document, not counting the operation's own braces.
+func selectionDepth(doc string) int {
+	depth, max := 0, 0
+	for _, line := range strings.Split(doc, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		for _, c := range line {
+			switch c {
+			case '{':
+				depth++
+				if depth > max {
+					max = depth
+				}
+			case '}':
+				depth--
+			}
+		}
+	}
+	if max == 0 {
+		return 0
+	}
+	return max - 1
+}
+
+// Queries returns the query operations of the catalog
+func (c *Catalog) Queries() []CatalogOperation {
+	var ops []CatalogOperation
+	for _, op := range c.Operations {
+		if op.Kind == KindQuery {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// WriteCatalog writes the catalog as indented JSON to filename
+func WriteCatalog(c *Catalog, filename string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling catalog: %w", err)
+	}
+	if err := artifacts.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("error writing catalog: %w", err)
+	}
+	return nil
+}
+
+// LoadCatalog reads a catalog written by WriteCatalog
+func LoadCatalog(filename string) (*Catalog, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog: %w", err)
+	}
+	if c.Version != CatalogVersion {
+		return nil, fmt.Errorf("unsupported catalog version %d (expected %d)", c.Version, CatalogVersion)
+	}
+	return &c, nil
+}
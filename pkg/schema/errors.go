@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	// ErrInvalidJSON is returned when the document cannot be parsed as JSON.
+	ErrInvalidJSON = errors.New("file is not valid JSON")
+	// ErrSDL is returned when the document looks like a schema in SDL rather than an introspection result.
+	ErrSDL = errors.New("file is not an introspection result (it looks like SDL)")
+	// ErrNotIntrospection is returned when the JSON document holds no __schema.
+	ErrNotIntrospection = errors.New("file is not an introspection result")
+	// ErrNoTypes is returned when __schema lists no types.
+	ErrNoTypes = errors.New("introspection result lists no types")
+	// ErrMissingRootType is returned when queryType, mutationType or subscriptionType
+	// names a type that is not in the type list.
+	ErrMissingRootType = errors.New("root type not defined")
+	// ErrInvalidRootType is returned when a root type is not an object or interface type.
+	ErrInvalidRootType = errors.New("root type is not an object type")
+)
+
+// LoadError describes why a document could not be loaded as a schema. Kind is one
+// of the Err values above and Err the underlying cause, if any.
+type LoadError struct {
+	Kind   error
+	Detail string
+	Err    error
+}
+
+func (e *LoadError) Error() string {
+	if e.Detail == "" {
+		return e.Kind.Error()
+	}
+	return fmt.Sprintf("%v: %s", e.Kind, e.Detail)
+}
+
+// Is reports whether target is the kind of the error.
+func (e *LoadError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying cause.
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// sdlDefinition matches the start of a type system definition in SDL.
+var sdlDefinition = regexp.MustCompile(`(?m)^\s*(?:extend\s+)?(?:schema|type|interface|input|enum|union|scalar|directive)\b[^\n]*[{=@]`)
+
+// looksLikeSDL reports whether content appears to be an SDL schema.
+func looksLikeSDL(content []byte) bool {
+	return sdlDefinition.Match(content)
+}
+
+// Hint returns advice on fixing the document behind a load error, or an empty
+// string when there is none.
+func Hint(err error) string {
+	switch {
+	case errors.Is(err, ErrSDL):
+		return "pass the JSON result of an introspection query (for example the --output of --detect) instead of an SDL file"
+	case errors.Is(err, ErrInvalidJSON):
+		return "pass the JSON result of an introspection query, as saved by --detect"
+	case errors.Is(err, ErrNotIntrospection):
+		return `the file must hold {"data": {"__schema": ...}} or {"__schema": ...}; re-run --detect to save a fresh result`
+	case errors.Is(err, ErrNoTypes):
+		return "the introspection result may be truncated; try --chunked-introspection to fetch it in batches"
+	case errors.Is(err, ErrMissingRootType), errors.Is(err, ErrInvalidRootType):
+		return "the introspection result is incomplete or was edited; re-run --detect to save a fresh result"
+	}
+	return ""
+}
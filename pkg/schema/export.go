@@ -0,0 +1,195 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+)
+
+// ManifestFile is the name of the manifest written by ExportOperations.
+const ManifestFile = "manifest.json"
+
+// MaxFileStem is the length in bytes above which FileNamer truncates names.
+const MaxFileStem = 100
+
+// ExportEntry maps an exported file to the operation it holds.
+type ExportEntry struct {
+	File      string `json:"file"`
+	Kind      string `json:"kind"`
+	Operation string `json:"operation"`
+	Hash      string `json:"hash,omitempty"`
+}
+
+// ExportSkip is an operation left out of the export because its generated
+// document does not parse back to the same operation.
+type ExportSkip struct {
+	Kind      string `json:"kind"`
+	Operation string `json:"operation"`
+	Error     string `json:"error"`
+}
+
+// ExportManifest lists the files written by ExportOperations.
+type ExportManifest struct {
+	Operations []ExportEntry `json:"operations"`
+	Skipped    []ExportSkip  `json:"skipped,omitempty"`
+}
+
+// ExportOperations writes the executable document of every catalog operation
+// to its own .graphql file in dir, ready for --batch-dir, and a manifest
+// mapping the files to operation names. Documents are parsed before they are
+// written so that field names needing escaping cannot produce broken or
+// injected documents.
+func ExportOperations(c *Catalog, dir string) (*ExportManifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	// The manifest and batch error list share the directory with the documents.
+	namer := NewFileNamer("manifest", "batch-errors")
+	manifest := &ExportManifest{Operations: []ExportEntry{}}
+	for _, op := range c.Operations {
+		if err := validateDocument(op); err != nil {
+			manifest.Skipped = append(manifest.Skipped, ExportSkip{Kind: op.Kind, Operation: op.Name, Error: err.Error()})
+			continue
+		}
+		name := namer.Name(op.Kind+"_"+op.Name) + ".graphql"
+		if err := artifacts.WriteFile(filepath.Join(dir, name), []byte(op.Executable+"\n"), 0644); err != nil {
+			return nil, fmt.Errorf("error writing %s %s: %w", op.Kind, op.Name, err)
+		}
+		manifest.Operations = append(manifest.Operations, ExportEntry{File: name, Kind: op.Kind, Operation: op.Name, Hash: op.Hash})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling manifest: %w", err)
+	}
+	if err := artifacts.WriteFile(filepath.Join(dir, ManifestFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("error writing manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// validateDocument checks that the executable document of op parses to a
+// single operation of its kind selecting op.Name first.
+func validateDocument(op CatalogOperation) error {
+	if op.Executable == "" {
+		return fmt.Errorf("no executable document was generated")
+	}
+	doc, err := gql.Parse(op.Executable)
+	if err != nil {
+		return fmt.Errorf("generated document does not parse: %w", err)
+	}
+	if len(doc.Operations) != 1 || len(doc.Fragments) != 0 {
+		return fmt.Errorf("generated document has %d operations and %d fragments, expected a single operation", len(doc.Operations), len(doc.Fragments))
+	}
+	parsed := doc.Operations[0]
+	if parsed.Kind != op.Kind {
+		return fmt.Errorf("generated document is a %s, expected a %s", parsed.Kind, op.Kind)
+	}
+	if len(parsed.SelectionSet) == 0 {
+		return fmt.Errorf("generated document selects nothing")
+	}
+	if f, ok := parsed.SelectionSet[0].(*gql.Field); !ok || f.Name != op.Name {
+		return fmt.Errorf("generated document does not select %q", op.Name)
+	}
+	return nil
+}
+
+// FileNamer turns operation names into unique file name stems that are safe on
+// every filesystem: characters other than ASCII letters, digits, '-' and '_'
+// are transliterated or replaced, long names are truncated to MaxFileStem and
+// names that collide case-insensitively, or with a reserved name, get a short
+// hash of the original name as suffix.
+type FileNamer struct {
+	used map[string]bool
+}
+
+// windowsReserved are device names that cannot be used as file names on Windows.
+var windowsReserved = []string{"con", "prn", "aux", "nul",
+	"com1", "com2", "com3", "com4", "com5", "com6", "com7", "com8", "com9",
+	"lpt1", "lpt2", "lpt3", "lpt4", "lpt5", "lpt6", "lpt7", "lpt8", "lpt9"}
+
+// NewFileNamer returns a FileNamer that never hands out the reserved stems.
+func NewFileNamer(reserved ...string) *FileNamer {
+	n := &FileNamer{used: make(map[string]bool)}
+	for _, r := range append(reserved, windowsReserved...) {
+		n.used[strings.ToLower(r)] = true
+	}
+	return n
+}
+
+// Name returns a unique safe stem for name. The same name always gets the same
+// suffix, so stems are stable across runs over the same schema.
+func (n *FileNamer) Name(name string) string {
+	stem := SafeFileStem(name)
+	if n.used[strings.ToLower(stem)] {
+		sum := sha256.Sum256([]byte(name))
+		suffix := "-" + hex.EncodeToString(sum[:4])
+		if len(stem)+len(suffix) > MaxFileStem {
+			stem = stem[:MaxFileStem-len(suffix)]
+		}
+		base := stem + suffix
+		stem = base
+		// Only names clashing on 32 bits of hash get here.
+		for i := 2; n.used[strings.ToLower(stem)]; i++ {
+			stem = fmt.Sprintf("%s-%d", base, i)
+		}
+	}
+	n.used[strings.ToLower(stem)] = true
+	return stem
+}
+
+// transliterations spell common accented Latin letters in ASCII.
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "ae", 'å': "a", 'æ': "ae",
+	'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "oe", 'ø': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "ue", 'ý': "y", 'ÿ': "y", 'ß': "ss",
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "Ae", 'Å': "A", 'Æ': "Ae",
+	'Ç': "C", 'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E",
+	'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I", 'Ñ': "N",
+	'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "Oe", 'Ø': "O",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "Ue", 'Ý': "Y",
+}
+
+// SafeFileStem maps name to ASCII letters, digits, '-' and '_', replacing runs
+// of other characters, including path separators and dots, with a single '_',
+// and truncates the result to MaxFileStem bytes.
+func SafeFileStem(name string) string {
+	var b strings.Builder
+	pending := false
+	for _, r := range name {
+		var s string
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			s = string(r)
+		default:
+			s = transliterations[r]
+		}
+		if s == "" {
+			pending = true
+			continue
+		}
+		if pending && b.Len() > 0 {
+			b.WriteByte('_')
+		}
+		pending = false
+		b.WriteString(s)
+	}
+	stem := b.String()
+	if len(stem) > MaxFileStem {
+		stem = stem[:MaxFileStem]
+	}
+	if stem == "" {
+		stem = "_"
+	}
+	return stem
+}
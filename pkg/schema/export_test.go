@@ -0,0 +1,150 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// oddSchema returns a schema whose query root has fields colliding
+// case-insensitively, over-long names and names that are not GraphQL names.
+func oddSchema(fields ...string) *types.GQLSchema {
+	str := types.TypeRef{Kind: types.SCALAR, Name: "String"}
+	var roots []types.Field
+	for _, name := range fields {
+		roots = append(roots, types.Field{Name: name, Type: str})
+	}
+	query := types.Type{Kind: types.OBJECT, Name: "Query", Fields: roots}
+	return &types.GQLSchema{Query: &query, Types: map[string]types.Type{
+		"Query":  query,
+		"String": {Kind: types.SCALAR, Name: "String"},
+	}}
+}
+
+func TestExportOperationsOddIdentifiers(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	longToo := strings.Repeat("a", 299) + "b"
+	fields := []string{"User", "user", "USER", long, longToo, "con", "manifest", "évènement", `user { __typename } mutation drop { dropAll`}
+	dir := t.TempDir()
+	manifest, err := ExportOperations(BuildCatalog(oddSchema(fields...), CatalogOptions{MaxDepth: 2}), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The names that are not GraphQL names produce documents that do not
+	// parse back to the operation and are skipped.
+	var skipped []string
+	for _, s := range manifest.Skipped {
+		skipped = append(skipped, s.Operation)
+	}
+	if want := fields[7:]; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped %q, want %q", skipped, want)
+	}
+
+	files := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, e := range manifest.Operations {
+		files[e.Operation] = e.File
+		if seen[strings.ToLower(e.File)] {
+			t.Errorf("%s collides case-insensitively with another file", e.File)
+		}
+		seen[strings.ToLower(e.File)] = true
+		if stem := strings.TrimSuffix(e.File, ".graphql"); len(stem) > MaxFileStem || SafeFileStem(stem) != stem {
+			t.Errorf("%s is not a safe file name", e.File)
+		}
+
+		// Every file holds the document of the operation the manifest maps it to.
+		content, err := os.ReadFile(filepath.Join(dir, e.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := gql.Parse(string(content))
+		if err != nil {
+			t.Fatalf("%s does not parse: %v", e.File, err)
+		}
+		if f := doc.Operations[0].SelectionSet[0].(*gql.Field); f.Name != e.Operation {
+			t.Errorf("%s selects %s, want %s", e.File, f.Name, e.Operation)
+		}
+	}
+	if len(files) != 7 {
+		t.Fatalf("exported %v, want the 7 valid operations", files)
+	}
+	if files["User"] != "query_User.graphql" || !strings.HasPrefix(files["user"], "query_user-") || !strings.HasPrefix(files["USER"], "query_USER-") {
+		t.Errorf("files = %v, want the first of User and user unchanged and the others suffixed", files)
+	}
+	if len(files[long]) != MaxFileStem+len(".graphql") || files[longToo] == files[long] {
+		t.Errorf("long names got %s and %s, want distinct truncated names", files[long], files[longToo])
+	}
+	// Reserved names only clash as whole stems, not behind the kind prefix.
+	if files["con"] != "query_con.graphql" || files["manifest"] != "query_manifest.graphql" {
+		t.Errorf("files = %v", files)
+	}
+
+	// The manifest on disk is the one returned.
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk ExportManifest
+	if err := json.Unmarshal(data, &onDisk); err != nil || !reflect.DeepEqual(&onDisk, manifest) {
+		t.Errorf("manifest file = %+v, %v; want the returned manifest", onDisk, err)
+	}
+}
+
+func TestSafeFileStem(t *testing.T) {
+	tests := map[string]string{
+		"query_user":          "query_user",
+		"../../etc/passwd":    "etc_passwd",
+		`a/b\c`:               "a_b_c",
+		"C:\\Windows\\system": "C_Windows_system",
+		"v1.2.3":              "v1_2_3",
+		"Größe":               "Groesse",
+		"naïve café":          "naive_cafe",
+		"日本語":                 "_",
+		"":                    "_",
+		"trailing/":           "trailing",
+	}
+	for name, want := range tests {
+		if got := SafeFileStem(name); got != want {
+			t.Errorf("SafeFileStem(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if got := SafeFileStem(strings.Repeat("é", 300)); len(got) != MaxFileStem {
+		t.Errorf("SafeFileStem() of 300 transliterated runes is %d bytes, want %d", len(got), MaxFileStem)
+	}
+}
+
+func TestFileNamer(t *testing.T) {
+	names := []string{"a/b", "a_b", "A_B", "a\\b", "nul", "lpt1", "manifest", strings.Repeat("x", 300), strings.Repeat("x", 301)}
+	first := NewFileNamer("manifest")
+	var stems []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		stem := first.Name(name)
+		if seen[strings.ToLower(stem)] {
+			t.Errorf("Name(%q) = %q, already handed out", name, stem)
+		}
+		seen[strings.ToLower(stem)] = true
+		if len(stem) > MaxFileStem {
+			t.Errorf("Name(%q) is %d bytes long", name, len(stem))
+		}
+		stems = append(stems, stem)
+	}
+	if stems[0] != "a_b" || !strings.HasPrefix(stems[1], "a_b-") || stems[4] == "nul" || stems[5] == "lpt1" || stems[6] == "manifest" {
+		t.Errorf("stems = %q", stems)
+	}
+
+	// Another namer over the same names hands out the same stems.
+	second := NewFileNamer("manifest")
+	for i, name := range names {
+		if got := second.Name(name); got != stems[i] {
+			t.Errorf("second run named %q %q, want %q", name, got, stems[i])
+		}
+	}
+}
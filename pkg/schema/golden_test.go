@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// golden compares got with testdata/name, rewriting it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, got)
+	}
+}
+
+func TestListingsFollowSortMode(t *testing.T) {
+	s, err := Parse([]byte(sdlIntrospection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := ListQueries(s)
+	if want := []string{"users", "search", "legacy"}; !reflect.DeepEqual(queries, want) {
+		t.Errorf("ListQueries() = %v, want the declared order %v", queries, want)
+	}
+	if got, want := SortNames(queries, SortAlpha), []string{"legacy", "search", "users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SortNames(alpha) = %v, want %v", got, want)
+	}
+	if got := SortNames(queries, SortSchema); !reflect.DeepEqual(got, queries) {
+		t.Errorf("SortNames(schema) = %v, want %v", got, queries)
+	}
+	if got := ListMutations(s); !reflect.DeepEqual(got, []string{"deleteUser"}) {
+		t.Errorf("ListMutations() = %v", got)
+	}
+}
+
+// TestCatalogIsDeterministic parses the same introspection result several
+// times, so that the type maps iterate in different orders, and expects the
+// same catalog bytes each time.
+func TestCatalogIsDeterministic(t *testing.T) {
+	for _, mode := range []string{SortSchema, SortAlpha} {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+			var outputs [][]byte
+			for i := 0; i < 5; i++ {
+				s, err := Parse([]byte(sdlIntrospection))
+				if err != nil {
+					t.Fatal(err)
+				}
+				file := filepath.Join(dir, fmt.Sprintf("catalog-%d.json", i))
+				if err := WriteCatalog(BuildCatalog(s, CatalogOptions{MaxDepth: 3, Sort: mode}), file); err != nil {
+					t.Fatal(err)
+				}
+				data, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				outputs = append(outputs, data)
+			}
+			for i := 1; i < len(outputs); i++ {
+				if !bytes.Equal(outputs[0], outputs[i]) {
+					t.Errorf("run %d differs from run 0", i)
+				}
+			}
+			golden(t, "catalog-"+mode+".golden.json", outputs[0])
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package schema
+
+import (
+	"sort"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Index holds the lookup tables of a schema, built once by NewIndex, so that
+// generating and describing the operations of a schema with thousands of types
+// does not scan type and field lists for every lookup. The schema must not be
+// modified while the index is in use.
+type Index struct {
+	s *types.GQLSchema
+	// names are the names of the types of s, sorted.
+	names []string
+	types map[string]*types.Type
+	// fields are the fields of each type in declaration order, and byName the
+	// same fields by name.
+	fields map[string][]IndexedField
+	byName map[string]map[string]IndexedField
+	// operations are the root fields of each operation kind, in declaration
+	// order, and opByName the same fields by name.
+	operations map[string][]IndexedField
+	opByName   map[string]map[string]IndexedField
+	returnedBy map[string][]FieldRef
+	acceptedBy map[string][]FieldRef
+}
+
+// IndexedField is a field of an indexed type.
+type IndexedField struct {
+	*types.Field
+	// Named is the named type under the list and non-null wrappers of the
+	// field type.
+	Named *types.TypeRef
+}
+
+// FieldRef names a field of a type, or an argument of that field when
+// Argument is set.
+type FieldRef struct {
+	Type     string `json:"type"`
+	Field    string `json:"field"`
+	Argument string `json:"argument,omitempty"`
+}
+
+// String renders r as Type.field or Type.field(argument).
+func (r FieldRef) String() string {
+	if r.Argument != "" {
+		return r.Type + "." + r.Field + "(" + r.Argument + ")"
+	}
+	return r.Type + "." + r.Field
+}
+
+// NewIndex indexes s in a single pass over its types.
+func NewIndex(s *types.GQLSchema) *Index {
+	x := &Index{
+		s:          s,
+		names:      make([]string, 0, len(s.Types)),
+		types:      make(map[string]*types.Type, len(s.Types)),
+		fields:     make(map[string][]IndexedField, len(s.Types)),
+		byName:     make(map[string]map[string]IndexedField, len(s.Types)),
+		operations: make(map[string][]IndexedField, 3),
+		opByName:   make(map[string]map[string]IndexedField, 3),
+		returnedBy: make(map[string][]FieldRef),
+		acceptedBy: make(map[string][]FieldRef),
+	}
+	for name := range s.Types {
+		x.names = append(x.names, name)
+	}
+	// Sorted names keep the reverse references in the same order every run.
+	sort.Strings(x.names)
+	for _, name := range x.names {
+		t := s.Types[name]
+		x.types[name] = &t
+		fields, byName := indexFields(t.Fields)
+		x.fields[name], x.byName[name] = fields, byName
+		for _, f := range fields {
+			x.returnedBy[f.Named.Name] = append(x.returnedBy[f.Named.Name], FieldRef{Type: name, Field: f.Name})
+			for _, arg := range f.Args {
+				argType := unwrapType(&arg.Type).Name
+				x.acceptedBy[argType] = append(x.acceptedBy[argType], FieldRef{Type: name, Field: f.Name, Argument: arg.Name})
+			}
+		}
+		for _, f := range t.InputFields {
+			fieldType := unwrapType(&f.Type).Name
+			x.acceptedBy[fieldType] = append(x.acceptedBy[fieldType], FieldRef{Type: name, Field: f.Name})
+		}
+	}
+	for _, root := range []struct {
+		kind string
+		typ  *types.Type
+	}{
+		{KindQuery, s.Query},
+		{KindMutation, s.Mutation},
+		{KindSubscription, s.Subscription},
+	} {
+		if root.typ != nil {
+			x.operations[root.kind], x.opByName[root.kind] = indexFields(root.typ.Fields)
+		}
+	}
+	return x
+}
+
+// indexFields lists fields with their named types, in order and by name.
+func indexFields(fields []types.Field) ([]IndexedField, map[string]IndexedField) {
+	list := make([]IndexedField, len(fields))
+	byName := make(map[string]IndexedField, len(fields))
+	for i := range fields {
+		f := IndexedField{Field: &fields[i], Named: unwrapType(&fields[i].Type)}
+		list[i] = f
+		byName[f.Name] = f
+	}
+	return list, byName
+}
+
+// TypeNames returns the names of the types of the schema, sorted. The slice
+// is shared by every call and must not be modified.
+func (x *Index) TypeNames() []string { return x.names }
+
+// Type returns the named type.
+func (x *Index) Type(name string) (*types.Type, bool) {
+	t, ok := x.types[name]
+	return t, ok
+}
+
+// Fields returns the fields of the named type in declaration order, nil for
+// unknown types and types without fields.
+func (x *Index) Fields(typeName string) []IndexedField { return x.fields[typeName] }
+
+// Field returns the field of the named type.
+func (x *Index) Field(typeName, fieldName string) (IndexedField, bool) {
+	f, ok := x.byName[typeName][fieldName]
+	return f, ok
+}
+
+// Operations returns the root fields of the kind of operation, in
+// declaration order.
+func (x *Index) Operations(kind string) []IndexedField { return x.operations[kind] }
+
+// Operation returns the root field name of the kind of operation.
+func (x *Index) Operation(kind, name string) (IndexedField, bool) {
+	f, ok := x.opByName[kind][name]
+	return f, ok
+}
+
+// HasRoot reports whether the schema declares the root type of the kind of
+// operation.
+func (x *Index) HasRoot(kind string) bool {
+	_, ok := x.opByName[kind]
+	return ok
+}
+
+// ReturnedBy returns the fields whose type, under its wrappers, is the named
+// type, ordered by the type declaring them.
+func (x *Index) ReturnedBy(typeName string) []FieldRef { return x.returnedBy[typeName] }
+
+// AcceptedBy returns the arguments and input object fields whose type, under
+// its wrappers, is the named type, ordered by the type declaring them.
+func (x *Index) AcceptedBy(typeName string) []FieldRef { return x.acceptedBy[typeName] }
@@ -0,0 +1,253 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// syntheticSchema returns a schema of n object types T0..Tn-1, each with
+// fields returning the next types and taking an input, and a query root with
+// a field per type.
+func syntheticSchema(n int) *types.GQLSchema {
+	s := &types.GQLSchema{Types: make(map[string]types.Type, n+3)}
+	named := func(kind types.TypeKind, name string) types.TypeRef { return types.TypeRef{Kind: kind, Name: name} }
+	list := func(ref types.TypeRef) types.TypeRef {
+		return types.TypeRef{Kind: types.NON_NULL, OfType: &types.TypeRef{Kind: types.LIST, OfType: &ref}}
+	}
+	var roots []types.Field
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("T%d", i)
+		fields := []types.Field{{Name: "id", Type: named(types.SCALAR, "ID")}}
+		for j := 1; j <= 3; j++ {
+			fields = append(fields, types.Field{
+				Name: fmt.Sprintf("next%d", j),
+				Type: list(named(types.OBJECT, fmt.Sprintf("T%d", (i+j)%n))),
+				Args: []types.InputValue{{Name: "filter", Type: named(types.INPUT_OBJECT, "Filter")}},
+			})
+		}
+		s.Types[name] = types.Type{Kind: types.OBJECT, Name: name, Fields: fields}
+		roots = append(roots, types.Field{
+			Name: fmt.Sprintf("t%d", i),
+			Type: named(types.OBJECT, name),
+			Args: []types.InputValue{{Name: "id", Type: types.TypeRef{Kind: types.NON_NULL, OfType: &types.TypeRef{Kind: types.SCALAR, Name: "ID"}}}},
+		})
+	}
+	s.Types["Filter"] = types.Type{Kind: types.INPUT_OBJECT, Name: "Filter", InputFields: []types.InputValue{
+		{Name: "id", Type: named(types.SCALAR, "ID")},
+		{Name: "and", Type: list(named(types.INPUT_OBJECT, "Filter"))},
+	}}
+	s.Types["ID"] = types.Type{Kind: types.SCALAR, Name: "ID"}
+	s.Types["Query"] = types.Type{Kind: types.OBJECT, Name: "Query", Fields: roots}
+	q := s.Types["Query"]
+	s.Query = &q
+	return s
+}
+
+// The naive lookups scan the schema as the code did before Index.
+
+func naiveField(s *types.GQLSchema, typeName, fieldName string) (*types.Field, bool) {
+	t, ok := s.Types[typeName]
+	if !ok {
+		return nil, false
+	}
+	for i := range t.Fields {
+		if t.Fields[i].Name == fieldName {
+			return &t.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+func naiveOperation(s *types.GQLSchema, kind, name string) (*types.Field, bool) {
+	root := map[string]*types.Type{KindQuery: s.Query, KindMutation: s.Mutation, KindSubscription: s.Subscription}[kind]
+	if root == nil {
+		return nil, false
+	}
+	for i := range root.Fields {
+		if root.Fields[i].Name == name {
+			return &root.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+func sortedTypeNames(s *types.GQLSchema) []string {
+	names := make([]string, 0, len(s.Types))
+	for name := range s.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func naiveReturnedBy(s *types.GQLSchema, typeName string) []FieldRef {
+	var refs []FieldRef
+	for _, name := range sortedTypeNames(s) {
+		for _, f := range s.Types[name].Fields {
+			if unwrapType(&f.Type).Name == typeName {
+				refs = append(refs, FieldRef{Type: name, Field: f.Name})
+			}
+		}
+	}
+	return refs
+}
+
+func naiveAcceptedBy(s *types.GQLSchema, typeName string) []FieldRef {
+	var refs []FieldRef
+	for _, name := range sortedTypeNames(s) {
+		t := s.Types[name]
+		for _, f := range t.Fields {
+			for _, arg := range f.Args {
+				if unwrapType(&arg.Type).Name == typeName {
+					refs = append(refs, FieldRef{Type: name, Field: f.Name, Argument: arg.Name})
+				}
+			}
+		}
+		for _, f := range t.InputFields {
+			if unwrapType(&f.Type).Name == typeName {
+				refs = append(refs, FieldRef{Type: name, Field: f.Name})
+			}
+		}
+	}
+	return refs
+}
+
+func TestIndexMatchesNaiveLookups(t *testing.T) {
+	parsed, err := Parse([]byte(sdlIntrospection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures := []struct {
+		name   string
+		schema *types.GQLSchema
+	}{
+		{"introspection", parsed},
+		{"synthetic", syntheticSchema(50)},
+		{"empty roots", &types.GQLSchema{Types: map[string]types.Type{"Lonely": {Kind: types.OBJECT, Name: "Lonely"}}}},
+	}
+	for _, fx := range fixtures {
+		t.Run(fx.name, func(t *testing.T) {
+			s := fx.schema
+			x := NewIndex(s)
+			if got, want := x.TypeNames(), sortedTypeNames(s); !reflect.DeepEqual(got, want) {
+				t.Errorf("TypeNames() = %v, want %v", got, want)
+			}
+			// Every type and field, plus names the schema does not have.
+			typeNames := append(sortedTypeNames(s), "Missing")
+			for _, typeName := range typeNames {
+				fieldNames := []string{"missing"}
+				for _, f := range s.Types[typeName].Fields {
+					fieldNames = append(fieldNames, f.Name)
+				}
+				for _, fieldName := range fieldNames {
+					got, gotOK := x.Field(typeName, fieldName)
+					want, wantOK := naiveField(s, typeName, fieldName)
+					if gotOK != wantOK || (gotOK && !reflect.DeepEqual(*got.Field, *want)) {
+						t.Errorf("Field(%s, %s) = %v, %v; naive %v, %v", typeName, fieldName, got.Field, gotOK, want, wantOK)
+					}
+					if gotOK && got.Named.Name != unwrapType(&want.Type).Name {
+						t.Errorf("Field(%s, %s) named type %s, want %s", typeName, fieldName, got.Named.Name, unwrapType(&want.Type).Name)
+					}
+				}
+				if got, want := x.ReturnedBy(typeName), naiveReturnedBy(s, typeName); !reflect.DeepEqual(got, want) {
+					t.Errorf("ReturnedBy(%s) = %v, naive %v", typeName, got, want)
+				}
+				if got, want := x.AcceptedBy(typeName), naiveAcceptedBy(s, typeName); !reflect.DeepEqual(got, want) {
+					t.Errorf("AcceptedBy(%s) = %v, naive %v", typeName, got, want)
+				}
+			}
+			for _, kind := range []string{KindQuery, KindMutation, KindSubscription} {
+				names := []string{"missing"}
+				for _, f := range x.Operations(kind) {
+					names = append(names, f.Name)
+				}
+				if s.Query != nil && kind == KindQuery && len(names)-1 != len(s.Query.Fields) {
+					t.Errorf("Operations(query) has %d fields, want %d", len(names)-1, len(s.Query.Fields))
+				}
+				for _, name := range names {
+					got, gotOK := x.Operation(kind, name)
+					want, wantOK := naiveOperation(s, kind, name)
+					if gotOK != wantOK || (gotOK && !reflect.DeepEqual(*got.Field, *want)) {
+						t.Errorf("Operation(%s, %s) = %v, %v; naive %v, %v", kind, name, got.Field, gotOK, want, wantOK)
+					}
+				}
+			}
+		})
+	}
+}
+
+// benchmarkSchemaSize is the number of types of the benchmark schema, the
+// size the index is meant for.
+const benchmarkSchemaSize = 10000
+
+// benchmarkLookups calls lookup on lookups types of the benchmark schema per
+// iteration, spread over the schema.
+func benchmarkLookups(b *testing.B, lookups int, lookup func(typeName string) int) {
+	b.Helper()
+	sink := 0
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < lookups; j++ {
+			sink += lookup(fmt.Sprintf("T%d", (i*7919+j*104729)%benchmarkSchemaSize))
+		}
+	}
+	if sink < 0 {
+		b.Fatal(sink)
+	}
+}
+
+// BenchmarkReverseLookup compares answering lookups reverse-reference
+// questions by scanning the schema each time with building an index for them
+// and asking it. The indexed variants include the cost of NewIndex, so the
+// lookup counts show where the index pays for itself.
+func BenchmarkReverseLookup(b *testing.B) {
+	s := syntheticSchema(benchmarkSchemaSize)
+	for _, lookups := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("naive/%d", lookups), func(b *testing.B) {
+			benchmarkLookups(b, lookups, func(typeName string) int { return len(naiveReturnedBy(s, typeName)) })
+		})
+		b.Run(fmt.Sprintf("indexed/%d", lookups), func(b *testing.B) {
+			sink := 0
+			for i := 0; i < b.N; i++ {
+				x := NewIndex(s)
+				for j := 0; j < lookups; j++ {
+					sink += len(x.ReturnedBy(fmt.Sprintf("T%d", (i*7919+j*104729)%benchmarkSchemaSize)))
+				}
+			}
+			if sink < 0 {
+				b.Fatal(sink)
+			}
+		})
+	}
+}
+
+// BenchmarkFieldLookup compares finding a field by name in the field list of
+// its type with a lookup in a prebuilt index.
+func BenchmarkFieldLookup(b *testing.B) {
+	s := syntheticSchema(benchmarkSchemaSize)
+	b.Run("naive", func(b *testing.B) {
+		benchmarkLookups(b, 1, func(typeName string) int {
+			f, _ := naiveField(s, typeName, "next3")
+			return len(f.Args)
+		})
+	})
+	x := NewIndex(s)
+	b.Run("indexed", func(b *testing.B) {
+		benchmarkLookups(b, 1, func(typeName string) int {
+			f, _ := x.Field(typeName, "next3")
+			return len(f.Args)
+		})
+	})
+}
+
+// BenchmarkNewIndex measures building the index of the benchmark schema.
+func BenchmarkNewIndex(b *testing.B) {
+	s := syntheticSchema(benchmarkSchemaSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewIndex(s)
+	}
+}
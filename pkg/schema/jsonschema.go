@@ -0,0 +1,223 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// JSONSchemaDraft is the dialect of the schemas built by VariablesJSONSchema.
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// VariablesSchemaSuffix ends the names of the files written by ExportVariablesSchemas.
+const VariablesSchemaSuffix = ".schema.json"
+
+// jsonSchema is the subset of JSON Schema used to describe variables. The
+// members are in the order they are written.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Comment              string                 `json:"$comment,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	Default              json.RawMessage        `json:"default,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+// scalarJSONTypes maps the built-in scalars to JSON Schema types. IDs are
+// serialized as strings but accepted as integers too.
+var scalarJSONTypes = map[string]interface{}{
+	"Int":     "integer",
+	"Float":   "number",
+	"String":  "string",
+	"Boolean": "boolean",
+	"ID":      []string{"string", "integer"},
+}
+
+// VariablesJSONSchema returns a JSON Schema (draft 2020-12) of the variables
+// object of op, taking each argument of its root field in s as a variable of the
+// same name. Required arguments are required properties, built-in scalars map
+// to JSON types, enums to enum lists and lists to arrays. Input objects are
+// described once in $defs and referenced with $ref, so recursive input types
+// do not expand forever. Custom scalars accept any value.
+func VariablesJSONSchema(s *types.GQLSchema, op CatalogOperation) ([]byte, error) {
+	return variablesJSONSchema(NewIndex(s), op)
+}
+
+// variablesJSONSchema is VariablesJSONSchema on an indexed schema.
+func variablesJSONSchema(x *Index, op CatalogOperation) ([]byte, error) {
+	field, err := rootField(x, op.Kind, op.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &jsonSchemaBuilder{s: x.s, defs: make(map[string]*jsonSchema)}
+	root := b.object(field.Args)
+	root.Schema = JSONSchemaDraft
+	root.Title = fmt.Sprintf("Variables of %s %s", op.Kind, op.Name)
+	root.Description = field.Description
+	// Input objects referenced from other definitions are added while building.
+	for len(b.pending) > 0 {
+		name := b.pending[0]
+		b.pending = b.pending[1:]
+		b.defs[name] = b.inputObject(name)
+	}
+	if len(b.defs) > 0 {
+		root.Defs = b.defs
+	}
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling variables schema of %s %s: %w", op.Kind, op.Name, err)
+	}
+	return data, nil
+}
+
+// rootField returns the root field name of the kind of operation.
+func rootField(x *Index, kind, name string) (*types.Field, error) {
+	if !x.HasRoot(kind) {
+		return nil, fmt.Errorf("schema has no %s type", kind)
+	}
+	if f, ok := x.Operation(kind, name); ok {
+		return f.Field, nil
+	}
+	return nil, fmt.Errorf("field '%s' not found in %s type", name, kind)
+}
+
+// jsonSchemaBuilder collects the input object definitions of a schema.
+type jsonSchemaBuilder struct {
+	s    *types.GQLSchema
+	defs map[string]*jsonSchema
+	// pending are the input objects referenced but not yet defined.
+	pending []string
+}
+
+// object describes an object holding values, the arguments of a field or the
+// fields of an input object.
+func (b *jsonSchemaBuilder) object(values []types.InputValue) *jsonSchema {
+	closed := false
+	o := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}, AdditionalProperties: &closed}
+	for _, v := range values {
+		prop := b.typeRef(&v.Type)
+		prop.Description = v.Description
+		if v.DefaultValue != "" && json.Valid([]byte(v.DefaultValue)) {
+			prop.Default = json.RawMessage(v.DefaultValue)
+		}
+		o.Properties[v.Name] = prop
+		if v.Type.Kind == types.NON_NULL && v.DefaultValue == "" {
+			o.Required = append(o.Required, v.Name)
+		}
+	}
+	return o
+}
+
+// typeRef describes the values of tr, null included unless it is non-null.
+func (b *jsonSchemaBuilder) typeRef(tr *types.TypeRef) *jsonSchema {
+	if tr.Kind == types.NON_NULL && tr.OfType != nil {
+		return b.nonNull(tr.OfType)
+	}
+	return nullable(b.nonNull(tr))
+}
+
+// nonNull describes the non-null values of tr.
+func (b *jsonSchemaBuilder) nonNull(tr *types.TypeRef) *jsonSchema {
+	if tr.Kind == types.LIST && tr.OfType != nil {
+		return &jsonSchema{Type: "array", Items: b.typeRef(tr.OfType)}
+	}
+	if t, ok := scalarJSONTypes[tr.Name]; ok {
+		return &jsonSchema{Type: t}
+	}
+	typeDef, ok := b.s.Types[tr.Name]
+	if !ok {
+		return &jsonSchema{Comment: "unknown type " + tr.Name}
+	}
+	switch typeDef.Kind {
+	case types.ENUM:
+		values := make([]interface{}, len(typeDef.EnumValues))
+		for i, v := range typeDef.EnumValues {
+			values[i] = v.Name
+		}
+		return &jsonSchema{Enum: values}
+	case types.INPUT_OBJECT:
+		if _, ok := b.defs[tr.Name]; !ok {
+			// A placeholder marks the definition as taken until it is built.
+			b.defs[tr.Name] = nil
+			b.pending = append(b.pending, tr.Name)
+		}
+		return &jsonSchema{Ref: "#/$defs/" + tr.Name}
+	}
+	return &jsonSchema{Comment: "custom scalar " + tr.Name}
+}
+
+// inputObject describes the input object type name.
+func (b *jsonSchemaBuilder) inputObject(name string) *jsonSchema {
+	typeDef := b.s.Types[name]
+	o := b.object(typeDef.InputFields)
+	o.Title = name
+	o.Description = typeDef.Description
+	return o
+}
+
+// nullable extends s to accept null.
+func nullable(s *jsonSchema) *jsonSchema {
+	switch {
+	case s.Enum != nil:
+		s.Enum = append(s.Enum, nil)
+		return s
+	case s.Type == nil:
+		if s.Ref == "" {
+			// Custom and unknown scalars already accept null.
+			return s
+		}
+		return &jsonSchema{AnyOf: []*jsonSchema{s, {Type: "null"}}}
+	}
+	switch t := s.Type.(type) {
+	case string:
+		s.Type = []string{t, "null"}
+	case []string:
+		s.Type = append(append([]string(nil), t...), "null")
+	}
+	return s
+}
+
+// ExportVariablesSchemas writes the JSON Schema of the variables of every
+// catalog operation to dir, named like the documents of ExportOperations with
+// VariablesSchemaSuffix, so both can share a directory. Operations that
+// ExportOperations leaves out are left out here too.
+func ExportVariablesSchemas(s *types.GQLSchema, c *Catalog, dir string) (*ExportManifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	x := NewIndex(s)
+	namer := NewFileNamer("manifest", "batch-errors")
+	manifest := &ExportManifest{Operations: []ExportEntry{}}
+	for _, op := range c.Operations {
+		if err := validateDocument(op); err != nil {
+			manifest.Skipped = append(manifest.Skipped, ExportSkip{Kind: op.Kind, Operation: op.Name, Error: err.Error()})
+			continue
+		}
+		name := namer.Name(op.Kind+"_"+op.Name) + VariablesSchemaSuffix
+		data, err := variablesJSONSchema(x, op)
+		if err != nil {
+			manifest.Skipped = append(manifest.Skipped, ExportSkip{Kind: op.Kind, Operation: op.Name, Error: err.Error()})
+			continue
+		}
+		if err := artifacts.WriteFile(filepath.Join(dir, name), append(data, '\n'), 0644); err != nil {
+			return nil, fmt.Errorf("error writing variables schema of %s %s: %w", op.Kind, op.Name, err)
+		}
+		manifest.Operations = append(manifest.Operations, ExportEntry{File: name, Kind: op.Kind, Operation: op.Name, Hash: op.Hash})
+	}
+	return manifest, nil
+}
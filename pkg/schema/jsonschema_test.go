@@ -0,0 +1,128 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recursiveSchema has a mutation taking a user input whose manager and
+// reports are users too, and a filter combining filters.
+const recursiveSchema = `{"data":{"__schema":{
+	"queryType":{"name":"Query"},
+	"mutationType":{"name":"Mutation"},
+	"types":[
+		{"kind":"OBJECT","name":"Query","fields":[{"name":"me","args":[],"type":{"kind":"SCALAR","name":"String"}}]},
+		{"kind":"OBJECT","name":"Mutation","fields":[{"name":"createUser","description":"Creates a user.","args":[
+			{"name":"input","type":{"kind":"NON_NULL","ofType":{"kind":"INPUT_OBJECT","name":"UserInput"}}},
+			{"name":"where","type":{"kind":"INPUT_OBJECT","name":"Filter"}},
+			{"name":"dryRun","type":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"Boolean"}},"defaultValue":"false"}
+		],"type":{"kind":"SCALAR","name":"String"}}]},
+		{"kind":"INPUT_OBJECT","name":"UserInput","inputFields":[
+			{"name":"name","type":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"String"}}},
+			{"name":"manager","type":{"kind":"INPUT_OBJECT","name":"UserInput"}},
+			{"name":"reports","type":{"kind":"LIST","ofType":{"kind":"NON_NULL","ofType":{"kind":"INPUT_OBJECT","name":"UserInput"}}}},
+			{"name":"address","type":{"kind":"INPUT_OBJECT","name":"Address"}}
+		]},
+		{"kind":"INPUT_OBJECT","name":"Address","inputFields":[
+			{"name":"city","type":{"kind":"SCALAR","name":"String"}}
+		]},
+		{"kind":"INPUT_OBJECT","name":"Filter","inputFields":[
+			{"name":"and","type":{"kind":"NON_NULL","ofType":{"kind":"LIST","ofType":{"kind":"NON_NULL","ofType":{"kind":"INPUT_OBJECT","name":"Filter"}}}}},
+			{"name":"owner","type":{"kind":"INPUT_OBJECT","name":"UserInput"}}
+		]},
+		{"kind":"SCALAR","name":"String"},
+		{"kind":"SCALAR","name":"Boolean"}
+	]
+}}}`
+
+// TestVariablesJSONSchemaRecursiveInputs expects each input object to be
+// defined once in $defs, and referenced with $ref wherever it is used,
+// itself included.
+func TestVariablesJSONSchemaRecursiveInputs(t *testing.T) {
+	s, err := Parse([]byte(recursiveSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := VariablesJSONSchema(s, CatalogOperation{Kind: KindMutation, Name: "createUser"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "Variables of mutation createUser",
+		"description": "Creates a user.",
+		"type": "object",
+		"properties": {
+			"input": {"$ref": "#/$defs/UserInput"},
+			"where": {"anyOf": [{"$ref": "#/$defs/Filter"}, {"type": "null"}]},
+			"dryRun": {"type": "boolean", "default": false}
+		},
+		"required": ["input"],
+		"additionalProperties": false,
+		"$defs": {
+			"UserInput": {
+				"title": "UserInput",
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"manager": {"anyOf": [{"$ref": "#/$defs/UserInput"}, {"type": "null"}]},
+					"reports": {"type": ["array", "null"], "items": {"$ref": "#/$defs/UserInput"}},
+					"address": {"anyOf": [{"$ref": "#/$defs/Address"}, {"type": "null"}]}
+				},
+				"required": ["name"],
+				"additionalProperties": false
+			},
+			"Address": {
+				"title": "Address",
+				"type": "object",
+				"properties": {"city": {"type": ["string", "null"]}},
+				"additionalProperties": false
+			},
+			"Filter": {
+				"title": "Filter",
+				"type": "object",
+				"properties": {
+					"and": {"type": "array", "items": {"$ref": "#/$defs/Filter"}},
+					"owner": {"anyOf": [{"$ref": "#/$defs/UserInput"}, {"type": "null"}]}
+				},
+				"required": ["and"],
+				"additionalProperties": false
+			}
+		}
+	}`
+	var expected interface{}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("VariablesJSONSchema() =\n%s", data)
+	}
+
+	// Every reference resolves to a definition.
+	defs := got.(map[string]interface{})["$defs"].(map[string]interface{})
+	for _, ref := range strings.Split(string(data), `"$ref": "`)[1:] {
+		name := strings.TrimPrefix(ref[:strings.Index(ref, `"`)], "#/$defs/")
+		if defs[name] == nil {
+			t.Errorf("$ref %s has no definition", name)
+		}
+	}
+}
+
+func TestVariablesJSONSchemaUnknownOperation(t *testing.T) {
+	s, err := Parse([]byte(recursiveSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VariablesJSONSchema(s, CatalogOperation{Kind: KindMutation, Name: "dropUser"}); err == nil || !strings.Contains(err.Error(), "field 'dropUser' not found in mutation type") {
+		t.Errorf("VariablesJSONSchema(dropUser) = %v", err)
+	}
+	if _, err := VariablesJSONSchema(s, CatalogOperation{Kind: KindSubscription, Name: "onUser"}); err == nil || !strings.Contains(err.Error(), "schema has no subscription type") {
+		t.Errorf("VariablesJSONSchema(onUser) = %v", err)
+	}
+}
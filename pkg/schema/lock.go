@@ -0,0 +1,250 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/jsondiff"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// LockVersion is the version of the lock format written by WriteLock.
+const LockVersion = 1
+
+// Lock is the reviewable summary of a schema: its types, fields, arguments
+// and deprecations, without descriptions. Everything is sorted by name and
+// the file is indented JSON of structs, so the same schema always gives the
+// same bytes whatever order the server lists it in.
+type Lock struct {
+	Version int `json:"version"`
+	// Hash is the SHA-256 of the rest of the lock, "sha256:" and hex.
+	Hash         string     `json:"hash"`
+	Query        string     `json:"query,omitempty"`
+	Mutation     string     `json:"mutation,omitempty"`
+	Subscription string     `json:"subscription,omitempty"`
+	Types        []LockType `json:"types"`
+}
+
+// LockType is a named type of a lock.
+type LockType struct {
+	Name          string          `json:"name"`
+	Kind          types.TypeKind  `json:"kind"`
+	Interfaces    []string        `json:"interfaces,omitempty"`
+	PossibleTypes []string        `json:"possibleTypes,omitempty"`
+	Fields        []LockField     `json:"fields,omitempty"`
+	InputFields   []LockArgument  `json:"inputFields,omitempty"`
+	EnumValues    []LockEnumValue `json:"enumValues,omitempty"`
+}
+
+// LockField is a field of an object or interface type.
+type LockField struct {
+	Name              string         `json:"name"`
+	Type              string         `json:"type"`
+	Args              []LockArgument `json:"args,omitempty"`
+	Deprecated        bool           `json:"deprecated,omitempty"`
+	DeprecationReason string         `json:"deprecationReason,omitempty"`
+}
+
+// LockArgument is an argument or an input field.
+type LockArgument struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	DefaultValue string `json:"defaultValue,omitempty"`
+}
+
+// LockEnumValue is a value of an enum type.
+type LockEnumValue struct {
+	Name              string `json:"name"`
+	Deprecated        bool   `json:"deprecated,omitempty"`
+	DeprecationReason string `json:"deprecationReason,omitempty"`
+}
+
+// BuildLock summarizes s. Introspection types are left out since every server
+// declares the same ones.
+func BuildLock(s *types.GQLSchema) *Lock {
+	l := &Lock{Version: LockVersion, Types: []LockType{}}
+	if s.Query != nil {
+		l.Query = s.Query.Name
+	}
+	if s.Mutation != nil {
+		l.Mutation = s.Mutation.Name
+	}
+	if s.Subscription != nil {
+		l.Subscription = s.Subscription.Name
+	}
+	for _, t := range s.Types {
+		if strings.HasPrefix(t.Name, "__") {
+			continue
+		}
+		lt := LockType{Name: t.Name, Kind: t.Kind}
+		for _, i := range t.Interfaces {
+			lt.Interfaces = append(lt.Interfaces, i.Name)
+		}
+		for _, p := range t.PossibleTypes {
+			lt.PossibleTypes = append(lt.PossibleTypes, p.Name)
+		}
+		for _, f := range t.Fields {
+			lf := LockField{Name: f.Name, Type: f.Type.String(), Args: lockArguments(f.Args), Deprecated: f.IsDeprecated}
+			if f.IsDeprecated {
+				lf.DeprecationReason = f.DeprecationReason
+			}
+			lt.Fields = append(lt.Fields, lf)
+		}
+		lt.InputFields = lockArguments(t.InputFields)
+		for _, v := range t.EnumValues {
+			lv := LockEnumValue{Name: v.Name, Deprecated: v.IsDeprecated}
+			if v.IsDeprecated {
+				lv.DeprecationReason = v.DeprecationReason
+			}
+			lt.EnumValues = append(lt.EnumValues, lv)
+		}
+		sort.Strings(lt.Interfaces)
+		sort.Strings(lt.PossibleTypes)
+		sort.Slice(lt.Fields, func(i, j int) bool { return lt.Fields[i].Name < lt.Fields[j].Name })
+		sort.Slice(lt.EnumValues, func(i, j int) bool { return lt.EnumValues[i].Name < lt.EnumValues[j].Name })
+		l.Types = append(l.Types, lt)
+	}
+	sort.Slice(l.Types, func(i, j int) bool { return l.Types[i].Name < l.Types[j].Name })
+	l.Hash = l.contentHash()
+	return l
+}
+
+// lockArguments summarizes arguments or input fields, sorted by name.
+func lockArguments(values []types.InputValue) []LockArgument {
+	var args []LockArgument
+	for _, v := range values {
+		args = append(args, LockArgument{Name: v.Name, Type: v.Type.String(), DefaultValue: v.DefaultValue})
+	}
+	sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
+	return args
+}
+
+// contentHash returns the hash of the lock without its Hash.
+func (l *Lock) contentHash() string {
+	unstamped := *l
+	unstamped.Hash = ""
+	data, err := json.Marshal(unstamped)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// WriteLock writes the lock as indented JSON to filename.
+func WriteLock(l *Lock, filename string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling schema lock: %w", err)
+	}
+	if err := artifacts.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing schema lock: %w", err)
+	}
+	return nil
+}
+
+// LoadLock reads a lock written by WriteLock. A lock whose hash does not match
+// its content was edited by hand and is rejected.
+func LoadLock(filename string) (*Lock, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema lock: %w", err)
+	}
+	var l Lock
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("failed to parse schema lock: %w", err)
+	}
+	if l.Version != LockVersion {
+		return nil, fmt.Errorf("unsupported schema lock version %d (expected %d)", l.Version, LockVersion)
+	}
+	if l.Types == nil {
+		l.Types = []LockType{}
+	}
+	if l.Hash != l.contentHash() {
+		return nil, fmt.Errorf("%s does not match its hash %s: it was edited by hand, write it again with schema lock", filename, l.Hash)
+	}
+	return &l, nil
+}
+
+// LockDrift returns the differences from the locked schema a to the schema b,
+// with paths naming types, fields and arguments, such as
+// types.User.fields.email or types.Query.fields.user.args.id.type.
+func LockDrift(a, b *Lock) []jsondiff.Difference {
+	return jsondiff.Compare(a.tree(), b.tree(), jsondiff.Options{})
+}
+
+// tree returns the lock as nested maps keyed by name, for LockDrift.
+func (l *Lock) tree() map[string]interface{} {
+	typeMap := make(map[string]interface{}, len(l.Types))
+	for _, t := range l.Types {
+		entry := map[string]interface{}{"kind": string(t.Kind)}
+		if len(t.Interfaces) > 0 {
+			entry["interfaces"] = strings.Join(t.Interfaces, ", ")
+		}
+		if len(t.PossibleTypes) > 0 {
+			entry["possibleTypes"] = strings.Join(t.PossibleTypes, ", ")
+		}
+		if len(t.Fields) > 0 {
+			fields := make(map[string]interface{}, len(t.Fields))
+			for _, f := range t.Fields {
+				field := map[string]interface{}{"type": f.Type}
+				if len(f.Args) > 0 {
+					field["args"] = argumentTree(f.Args)
+				}
+				if f.Deprecated {
+					field["deprecated"] = deprecation(f.DeprecationReason)
+				}
+				fields[f.Name] = field
+			}
+			entry["fields"] = fields
+		}
+		if len(t.InputFields) > 0 {
+			entry["inputFields"] = argumentTree(t.InputFields)
+		}
+		if len(t.EnumValues) > 0 {
+			values := make(map[string]interface{}, len(t.EnumValues))
+			for _, v := range t.EnumValues {
+				value := map[string]interface{}{}
+				if v.Deprecated {
+					value["deprecated"] = deprecation(v.DeprecationReason)
+				}
+				values[v.Name] = value
+			}
+			entry["enumValues"] = values
+		}
+		typeMap[t.Name] = entry
+	}
+	return map[string]interface{}{
+		"query":        l.Query,
+		"mutation":     l.Mutation,
+		"subscription": l.Subscription,
+		"types":        typeMap,
+	}
+}
+
+// argumentTree keys arguments or input fields by name, for tree.
+func argumentTree(args []LockArgument) map[string]interface{} {
+	tree := make(map[string]interface{}, len(args))
+	for _, a := range args {
+		arg := map[string]interface{}{"type": a.Type}
+		if a.DefaultValue != "" {
+			arg["defaultValue"] = a.DefaultValue
+		}
+		tree[a.Name] = arg
+	}
+	return tree
+}
+
+// deprecation renders a deprecation for tree, its reason or "deprecated".
+func deprecation(reason string) string {
+	if reason == "" {
+		return "deprecated"
+	}
+	return reason
+}
@@ -0,0 +1,133 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+func init() {
+	Register(appliedDirectivesExtractor{})
+}
+
+// AppliedDirectivesQuery reads the directives applied to every field through
+// the appliedDirectives introspection extension of graphql-java and of the
+// Apollo servers that enable it.
+const AppliedDirectivesQuery = `query AppliedDirectives {
+  __schema {
+    types {
+      name
+      fields(includeDeprecated: true) {
+        name
+        appliedDirectives {
+          name
+          args {
+            name
+            value
+          }
+        }
+      }
+    }
+  }
+}`
+
+// appliedDirectivesExtractor fills in types.Field.AppliedDirectives from the
+// appliedDirectives extension.
+type appliedDirectivesExtractor struct{}
+
+func (appliedDirectivesExtractor) Name() string { return "applied-directives" }
+
+func (appliedDirectivesExtractor) Engines() []string {
+	return []string{"Apollo Server", "Apollo Router", "graphql-java"}
+}
+
+func (appliedDirectivesExtractor) Extract(ctx context.Context, target string, headers map[string]string, s *types.GQLSchema) (int, error) {
+	resp, err := network.SendGraphQLRequestWithContext(ctx, target, AppliedDirectivesQuery, nil, headers)
+	if err != nil {
+		return 0, err
+	}
+	data, _ := resp["data"].(map[string]interface{})
+	schemaData, _ := data["__schema"].(map[string]interface{})
+	if schemaData == nil {
+		if msg := firstErrorMessage(resp); msg != "" {
+			return 0, fmt.Errorf("appliedDirectives is not supported: %s", msg)
+		}
+		return 0, fmt.Errorf("appliedDirectives is not supported: no __schema in the response")
+	}
+
+	annotated := 0
+	listed, _ := schemaData["types"].([]interface{})
+	for _, t := range listed {
+		tm, _ := t.(map[string]interface{})
+		name, _ := tm["name"].(string)
+		typ, ok := s.Types[name]
+		if !ok {
+			continue
+		}
+		fields, _ := tm["fields"].([]interface{})
+		for _, f := range fields {
+			fm, _ := f.(map[string]interface{})
+			directives := parseDirectives(fm["appliedDirectives"])
+			if len(directives) == 0 {
+				continue
+			}
+			fieldName, _ := fm["name"].(string)
+			// The root types of s share their field slices with s.Types, so
+			// they see the directives too.
+			for i := range typ.Fields {
+				if typ.Fields[i].Name == fieldName {
+					typ.Fields[i].AppliedDirectives = directives
+					annotated++
+				}
+			}
+		}
+	}
+	return annotated, nil
+}
+
+// parseDirectives reads an appliedDirectives list. Argument values are
+// GraphQL literals; the few servers sending them as JSON values have them
+// printed.
+func parseDirectives(v interface{}) []types.AppliedDirective {
+	list, _ := v.([]interface{})
+	var directives []types.AppliedDirective
+	for _, d := range list {
+		dm, _ := d.(map[string]interface{})
+		name, _ := dm["name"].(string)
+		if name == "" {
+			continue
+		}
+		directive := types.AppliedDirective{Name: name}
+		args, _ := dm["args"].([]interface{})
+		for _, a := range args {
+			am, _ := a.(map[string]interface{})
+			argName, _ := am["name"].(string)
+			if argName == "" {
+				continue
+			}
+			value, ok := am["value"].(string)
+			if !ok && am["value"] != nil {
+				value = fmt.Sprint(am["value"])
+			}
+			directive.Args = append(directive.Args, types.AppliedDirectiveArg{Name: argName, Value: value})
+		}
+		directives = append(directives, directive)
+	}
+	return directives
+}
+
+// firstErrorMessage returns the message of the first GraphQL error in resp.
+func firstErrorMessage(resp map[string]interface{}) string {
+	errs, _ := resp["errors"].([]interface{})
+	if len(errs) == 0 {
+		return ""
+	}
+	if m, ok := errs[0].(map[string]interface{}); ok {
+		if msg, ok := m["message"].(string); ok {
+			return msg
+		}
+	}
+	return fmt.Sprint(errs[0])
+}
@@ -0,0 +1,203 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+func init() {
+	Register(hasuraPermissionsExtractor{})
+}
+
+// HasuraPermissionDirective is the directive the Hasura extractor applies to
+// the fields whose description carries permission metadata.
+const HasuraPermissionDirective = "hasuraPermission"
+
+var (
+	// hasuraRolesLine matches the description lines listing the roles allowed
+	// on a field, such as "roles: user, manager" or "Permissions: user
+	// (filter: {...})".
+	hasuraRolesLine = regexp.MustCompile(`(?im)^[\s*-]*(?:allowed\s+|permitted\s+)?(?:roles?|permissions?)\s*:\s*(.+)$`)
+	// hasuraRoleName matches a role name once its filter is removed.
+	hasuraRoleName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// hasuraSessionVariable matches the session variables a permission
+	// filter compares rows with, such as X-Hasura-User-Id.
+	hasuraSessionVariable = regexp.MustCompile(`(?i)\bx-hasura-[a-z0-9-]*[a-z0-9]`)
+)
+
+// hasuraPermissionsExtractor reads the permission metadata Hasura puts in the
+// descriptions of the root fields, as returned by __type for each root type,
+// and records it as a @hasuraPermission directive.
+type hasuraPermissionsExtractor struct{}
+
+func (hasuraPermissionsExtractor) Name() string { return "hasura-permissions" }
+
+func (hasuraPermissionsExtractor) Engines() []string { return []string{"Hasura"} }
+
+func (hasuraPermissionsExtractor) Extract(ctx context.Context, target string, headers map[string]string, s *types.GQLSchema) (int, error) {
+	var roots []string
+	for _, root := range []*types.Type{s.Query, s.Mutation, s.Subscription} {
+		if root != nil {
+			roots = append(roots, root.Name)
+		}
+	}
+	if len(roots) == 0 {
+		return 0, fmt.Errorf("the schema has no root types")
+	}
+	resp, err := network.SendGraphQLRequestWithContext(ctx, target, HasuraPermissionsQuery(roots), nil, headers)
+	if err != nil {
+		return 0, err
+	}
+	data, _ := resp["data"].(map[string]interface{})
+	if data == nil {
+		if msg := firstErrorMessage(resp); msg != "" {
+			return 0, fmt.Errorf("__type is not answered: %s", msg)
+		}
+		return 0, fmt.Errorf("__type is not answered: no data in the response")
+	}
+
+	annotated := 0
+	for i, name := range roots {
+		tm, _ := data[fmt.Sprintf("root%d", i)].(map[string]interface{})
+		typ, ok := s.Types[name]
+		if tm == nil || !ok {
+			continue
+		}
+		fields, _ := tm["fields"].([]interface{})
+		for _, f := range fields {
+			fm, _ := f.(map[string]interface{})
+			fieldName, _ := fm["name"].(string)
+			description, _ := fm["description"].(string)
+			directive, ok := ParseHasuraPermission(description)
+			if !ok {
+				continue
+			}
+			for j := range typ.Fields {
+				if typ.Fields[j].Name == fieldName && !hasDirective(typ.Fields[j], HasuraPermissionDirective) {
+					typ.Fields[j].AppliedDirectives = append(typ.Fields[j].AppliedDirectives, directive)
+					annotated++
+				}
+			}
+		}
+	}
+	return annotated, nil
+}
+
+// HasuraPermissionsQuery asks __type for the fields of each of roots, aliased
+// root0, root1 and so on.
+func HasuraPermissionsQuery(roots []string) string {
+	var b strings.Builder
+	b.WriteString("query HasuraPermissions {\n")
+	for i, name := range roots {
+		fmt.Fprintf(&b, "  root%d: __type(name: %s) {\n    name\n    fields(includeDeprecated: true) {\n      name\n      description\n    }\n  }\n", i, strconv.Quote(name))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// ParseHasuraPermission reads the roles and session variables a field
+// description lists, as in
+//
+//	fetch data from the table: "orders"
+//	roles: user (filter: {"owner_id": {"_eq": "X-Hasura-User-Id"}}), manager
+//
+// and returns them as @hasuraPermission(roles: ["user", "manager"],
+// sessionVariables: ["x-hasura-user-id"]). It returns false when the
+// description names neither.
+func ParseHasuraPermission(description string) (types.AppliedDirective, bool) {
+	var roles []string
+	seen := make(map[string]bool)
+	for _, m := range hasuraRolesLine.FindAllStringSubmatch(description, -1) {
+		for _, item := range splitTopLevel(m[1]) {
+			role := strings.Trim(strings.TrimSpace(stripParens(item)), `"'`+"`")
+			if hasuraRoleName.MatchString(role) && !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+	var variables []string
+	for _, v := range hasuraSessionVariable.FindAllString(description, -1) {
+		v = strings.ToLower(v)
+		if !seen[v] {
+			seen[v] = true
+			variables = append(variables, v)
+		}
+	}
+	if len(roles) == 0 && len(variables) == 0 {
+		return types.AppliedDirective{}, false
+	}
+	d := types.AppliedDirective{Name: HasuraPermissionDirective}
+	if len(roles) > 0 {
+		d.Args = append(d.Args, types.AppliedDirectiveArg{Name: "roles", Value: listLiteral(roles)})
+	}
+	if len(variables) > 0 {
+		d.Args = append(d.Args, types.AppliedDirectiveArg{Name: "sessionVariables", Value: listLiteral(variables)})
+	}
+	return d, true
+}
+
+// splitTopLevel splits s at the commas outside of brackets, so the filters of
+// a role stay with it.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// stripParens removes the parenthesized parts of s, such as a role filter.
+func stripParens(s string) string {
+	var b strings.Builder
+	depth := 0
+	for _, c := range s {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// listLiteral renders values as a GraphQL list of strings.
+func listLiteral(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// hasDirective reports whether f already has a directive named name applied.
+func hasDirective(f types.Field, name string) bool {
+	for _, d := range f.AppliedDirectives {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+// Package metadata enriches an introspected schema with what the standard
+// introspection query does not return, such as the directives applied to each
+// field, through the introspection extensions some engines expose. Extractors
+// register the engines whose extension they speak and only run on endpoints
+// fingerprinted as one of them.
+package metadata
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Extractor reads the metadata of a schema through the introspection
+// extension of some engines.
+type Extractor interface {
+	// Name identifies the extractor in logs.
+	Name() string
+	// Engines are the fingerprinted engine names the extension is known on.
+	Engines() []string
+	// Extract stores the metadata target exposes in s and returns the number
+	// of fields annotated.
+	Extract(ctx context.Context, target string, headers map[string]string, s *types.GQLSchema) (int, error)
+}
+
+var registry []Extractor
+
+// Register adds an extractor. It is meant to be called from init functions.
+func Register(e Extractor) {
+	for _, r := range registry {
+		if r.Name() == e.Name() {
+			panic(fmt.Sprintf("metadata: duplicate extractor %q", e.Name()))
+		}
+	}
+	registry = append(registry, e)
+}
+
+// For returns the extractors registered for any of engines, in registration
+// order.
+func For(engines []string) []Extractor {
+	var matched []Extractor
+	for _, e := range registry {
+		if supports(e, engines) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// supports reports whether e is registered for one of engines.
+func supports(e Extractor, engines []string) bool {
+	for _, want := range e.Engines() {
+		for _, engine := range engines {
+			if engine == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Result is the outcome of one extractor on a schema.
+type Result struct {
+	Extractor string
+	// Fields is the number of fields annotated.
+	Fields int
+	// Err is set when the endpoint did not answer the extension.
+	Err error
+}
+
+// Enrich runs on s the extractors registered for engines, the engines
+// fingerprinted on target. An extractor that fails leaves s as it was and
+// does not stop the others.
+func Enrich(ctx context.Context, target string, headers map[string]string, s *types.GQLSchema, engines []string) []Result {
+	var results []Result
+	for _, e := range For(engines) {
+		n, err := e.Extract(ctx, target, headers, s)
+		results = append(results, Result{Extractor: e.Name(), Fields: n, Err: err})
+	}
+	return results
+}
@@ -0,0 +1,177 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// testSchema returns a schema whose query root is named query and has the
+// fields users and posts, and whose mutation root has deleteUser.
+func testSchema(query, mutation string) *types.GQLSchema {
+	field := func(name string) types.Field {
+		return types.Field{Name: name, Type: types.TypeRef{Kind: types.SCALAR, Name: "String"}}
+	}
+	s := &types.GQLSchema{Types: map[string]types.Type{
+		query:    {Kind: types.OBJECT, Name: query, Fields: []types.Field{field("users"), field("posts")}},
+		mutation: {Kind: types.OBJECT, Name: mutation, Fields: []types.Field{field("deleteUser")}},
+	}}
+	q, m := s.Types[query], s.Types[mutation]
+	s.Query, s.Mutation = &q, &m
+	return s
+}
+
+// graphQLServer answers every query with the JSON of respond.
+func graphQLServer(t *testing.T, respond func(query string) interface{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Query string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(respond(body.Query))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestForSelectsByEngine(t *testing.T) {
+	names := func(engines ...string) []string {
+		var out []string
+		for _, e := range For(engines) {
+			out = append(out, e.Name())
+		}
+		return out
+	}
+	if got := names("Apollo Server"); !reflect.DeepEqual(got, []string{"applied-directives"}) {
+		t.Errorf("Apollo Server: %v", got)
+	}
+	if got := names("Hasura"); !reflect.DeepEqual(got, []string{"hasura-permissions"}) {
+		t.Errorf("Hasura: %v", got)
+	}
+	if got := names("Juniper"); got != nil {
+		t.Errorf("Juniper: %v", got)
+	}
+}
+
+func TestAppliedDirectivesApollo(t *testing.T) {
+	srv := graphQLServer(t, func(query string) interface{} {
+		if !strings.Contains(query, "appliedDirectives") {
+			t.Errorf("unexpected query %s", query)
+		}
+		return map[string]interface{}{"data": map[string]interface{}{"__schema": map[string]interface{}{"types": []interface{}{
+			map[string]interface{}{"name": "Query", "fields": []interface{}{
+				map[string]interface{}{"name": "users", "appliedDirectives": []interface{}{
+					map[string]interface{}{"name": "requiresScopes", "args": []interface{}{
+						map[string]interface{}{"name": "scopes", "value": `[["admin"]]`},
+					}},
+				}},
+				map[string]interface{}{"name": "posts", "appliedDirectives": []interface{}{}},
+			}},
+			map[string]interface{}{"name": "Mutation", "fields": []interface{}{
+				map[string]interface{}{"name": "deleteUser", "appliedDirectives": []interface{}{
+					map[string]interface{}{"name": "authenticated"},
+				}},
+			}},
+			map[string]interface{}{"name": "Unknown", "fields": []interface{}{
+				map[string]interface{}{"name": "x", "appliedDirectives": []interface{}{map[string]interface{}{"name": "auth"}}},
+			}},
+		}}}}
+	})
+
+	s := testSchema("Query", "Mutation")
+	results := Enrich(context.Background(), srv.URL, nil, s, []string{"Apollo Server"})
+	if len(results) != 1 || results[0].Err != nil || results[0].Fields != 2 {
+		t.Fatalf("results = %+v", results)
+	}
+	if got := s.Query.Fields[0].AppliedDirectives; len(got) != 1 || got[0].String() != `@requiresScopes(scopes: [["admin"]])` {
+		t.Errorf("Query.users directives = %v", got)
+	}
+	if got := s.Query.Fields[1].AppliedDirectives; got != nil {
+		t.Errorf("Query.posts directives = %v", got)
+	}
+	// The root types share their fields with s.Types.
+	if got := s.Types["Mutation"].Fields[0].AppliedDirectives; len(got) != 1 || got[0].String() != "@authenticated" {
+		t.Errorf("Mutation.deleteUser directives = %v", got)
+	}
+}
+
+func TestAppliedDirectivesUnsupported(t *testing.T) {
+	srv := graphQLServer(t, func(string) interface{} {
+		return map[string]interface{}{"errors": []interface{}{
+			map[string]interface{}{"message": `Cannot query field "appliedDirectives" on type "__Field".`},
+		}}
+	})
+	s := testSchema("Query", "Mutation")
+	results := Enrich(context.Background(), srv.URL, nil, s, []string{"graphql-java"})
+	if len(results) != 1 || results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "appliedDirectives") {
+		t.Fatalf("results = %+v", results)
+	}
+	if s.Query.Fields[0].AppliedDirectives != nil {
+		t.Error("a failed extraction changed the schema")
+	}
+}
+
+func TestHasuraPermissions(t *testing.T) {
+	srv := graphQLServer(t, func(query string) interface{} {
+		if !strings.Contains(query, `root0: __type(name: "query_root")`) || !strings.Contains(query, `root1: __type(name: "mutation_root")`) {
+			t.Errorf("unexpected query %s", query)
+		}
+		return map[string]interface{}{"data": map[string]interface{}{
+			"root0": map[string]interface{}{"name": "query_root", "fields": []interface{}{
+				map[string]interface{}{"name": "users", "description": "fetch data from the table: \"users\"\nroles: user (filter: {\"id\": {\"_eq\": \"X-Hasura-User-Id\"}}), manager"},
+				map[string]interface{}{"name": "posts", "description": "fetch data from the table: \"posts\""},
+			}},
+			"root1": map[string]interface{}{"name": "mutation_root", "fields": []interface{}{
+				map[string]interface{}{"name": "deleteUser", "description": "Permissions: admin"},
+			}},
+		}}
+	})
+
+	s := testSchema("query_root", "mutation_root")
+	results := Enrich(context.Background(), srv.URL, nil, s, []string{"Hasura"})
+	if len(results) != 1 || results[0].Err != nil || results[0].Fields != 2 {
+		t.Fatalf("results = %+v", results)
+	}
+	if got := s.Query.Fields[0].AppliedDirectives; len(got) != 1 || got[0].String() != `@hasuraPermission(roles: ["user", "manager"], sessionVariables: ["x-hasura-user-id"])` {
+		t.Errorf("query_root.users directives = %v", got)
+	}
+	if got := s.Query.Fields[1].AppliedDirectives; got != nil {
+		t.Errorf("query_root.posts directives = %v", got)
+	}
+	if got := s.Mutation.Fields[0].AppliedDirectives; len(got) != 1 || got[0].String() != `@hasuraPermission(roles: ["admin"])` {
+		t.Errorf("mutation_root.deleteUser directives = %v", got)
+	}
+}
+
+func TestParseHasuraPermission(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{"roles: user, manager", `@hasuraPermission(roles: ["user", "manager"])`},
+		{"Allowed roles: \"user\", 'anonymous'", `@hasuraPermission(roles: ["user", "anonymous"])`},
+		{"- role: editor (filter: {\"org_id\": {\"_in\": \"X-Hasura-Allowed-Org-Ids\"}}, limit: 10)", `@hasuraPermission(roles: ["editor"], sessionVariables: ["x-hasura-allowed-org-ids"])`},
+		{"Rows visible when owner_id equals X-Hasura-User-Id; x-hasura-user-id again", `@hasuraPermission(sessionVariables: ["x-hasura-user-id"])`},
+		{"roles: {not a role}", ""},
+		{"fetch data from the table: \"users\"", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		d, ok := ParseHasuraPermission(tt.description)
+		got := ""
+		if ok {
+			got = d.String()
+		}
+		if got != tt.want {
+			t.Errorf("ParseHasuraPermission(%q) = %s, want %s", tt.description, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Note is the annotation of an operation or a type kept in a notes file.
+type Note struct {
+	Text string   `yaml:"note" json:"note"`
+	Tags []string `yaml:"tags" json:"tags"`
+}
+
+// UnmarshalYAML accepts a bare string as a note without tags. Decoding a node
+// does not inherit KnownFields, so unknown keys are rejected here.
+func (n *Note) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&n.Text)
+	}
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i < len(value.Content); i += 2 {
+			if key := value.Content[i].Value; key != "note" && key != "tags" {
+				return fmt.Errorf("line %d: unknown key %q (valid: 'note', 'tags')", value.Content[i].Line, key)
+			}
+		}
+	}
+	type plain Note
+	var p plain
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	*n = Note(p)
+	return nil
+}
+
+// Notes maps names to annotations. A name is a root field of any operation
+// type ("users"), a root field of one of them ("mutation.deleteUser") or a
+// type ("User"), whose note applies to every operation returning it.
+type Notes map[string]Note
+
+// LoadNotes reads a notes file in YAML (.yaml, .yml) or JSON (.json).
+func LoadNotes(path string) (Notes, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notes: %w", err)
+	}
+	var n Notes
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		if err := dec.Decode(&n); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML notes %s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&n); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON notes %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported notes format: %s", ext)
+	}
+	for name, note := range n {
+		if note.Text == "" && len(note.Tags) == 0 {
+			return nil, fmt.Errorf("invalid notes %s: %q has neither a note nor tags", path, name)
+		}
+	}
+	return n, nil
+}
+
+// Operation returns the notes and tags of the root field f of the kind
+// operation type: those of its name, of kind.name and of the type it returns,
+// in that order. Tags are lowercased and listed once.
+func (n Notes) Operation(kind string, f types.Field) ([]string, []string) {
+	if len(n) == 0 {
+		return nil, nil
+	}
+	var texts, tags []string
+	seen := map[string]bool{}
+	for _, key := range []string{f.Name, kind + "." + f.Name, unwrapType(&f.Type).Name} {
+		note, ok := n[key]
+		if !ok {
+			continue
+		}
+		if note.Text != "" {
+			texts = append(texts, note.Text)
+		}
+		for _, t := range note.Tags {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if t != "" && !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	return texts, tags
+}
+
+// Unknown returns a warning for each name of n that is neither a root field
+// nor a type of s, with the closest names of s as suggestions.
+func (n Notes) Unknown(s *types.GQLSchema) []string {
+	known := map[string]bool{}
+	for name := range s.Types {
+		known[name] = true
+	}
+	for _, root := range []struct {
+		kind string
+		typ  *types.Type
+	}{
+		{KindQuery, s.Query},
+		{KindMutation, s.Mutation},
+		{KindSubscription, s.Subscription},
+	} {
+		if root.typ == nil {
+			continue
+		}
+		for _, f := range root.typ.Fields {
+			known[f.Name] = true
+			known[root.kind+"."+f.Name] = true
+		}
+	}
+
+	candidates := make([]string, 0, len(known))
+	for name := range known {
+		candidates = append(candidates, name)
+	}
+	var warnings []string
+	for name := range n {
+		if known[name] {
+			continue
+		}
+		w := fmt.Sprintf("notes name %q is not an operation or type of the schema", name)
+		if close := closeMatches(name, candidates, 3); len(close) > 0 {
+			w += fmt.Sprintf(" (did you mean %s?)", strings.Join(close, ", "))
+		}
+		warnings = append(warnings, w)
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// HasTag reports whether tags holds tag, compared case-insensitively.
+func HasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// closeMatches returns up to max names of candidates within a small edit
+// distance of name, closest first, compared case-insensitively.
+func closeMatches(name string, candidates []string, max int) []string {
+	type match struct {
+		name     string
+		distance int
+	}
+	limit := len(name)/3 + 1
+	if limit < 2 {
+		limit = 2
+	}
+	var matches []match
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(c)); d <= limit {
+			matches = append(matches, match{c, d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+	var out []string
+	for i := 0; i < len(matches) && i < max; i++ {
+		out = append(out, matches[i].name)
+	}
+	return out
+}
+
+// editDistance is the Levenshtein distance between a and b, in bytes.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// minInt returns the smallest of values.
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeNotes writes content to a notes file named name and returns its path.
+func writeNotes(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadNotes(t *testing.T) {
+	want := Notes{
+		"users":               {Text: "Lists every account"},
+		"query.search":        {Text: "Full-text search", Tags: []string{"Slow"}},
+		"mutation.deleteUser": {Tags: []string{"destructive"}},
+	}
+	yamlNotes := writeNotes(t, "notes.yaml", `users: Lists every account
+query.search:
+  note: Full-text search
+  tags: [Slow]
+mutation.deleteUser:
+  tags: [destructive]
+`)
+	jsonNotes := writeNotes(t, "notes.json", `{
+  "users": {"note": "Lists every account"},
+  "query.search": {"note": "Full-text search", "tags": ["Slow"]},
+  "mutation.deleteUser": {"tags": ["destructive"]}
+}`)
+	for _, path := range []string{yamlNotes, jsonNotes} {
+		if n, err := LoadNotes(path); err != nil || !reflect.DeepEqual(n, want) {
+			t.Errorf("LoadNotes(%s) = %+v, %v", filepath.Base(path), n, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name, content, want string
+	}{
+		{"unknown.yaml", "users:\n  note: x\n  owner: alice\n", `line 3: unknown key "owner"`},
+		{"unknown.json", `{"users": {"note": "x", "owner": "alice"}}`, `unknown field "owner"`},
+		{"empty.yaml", "users:\n  tags: []\n", `"users" has neither a note nor tags`},
+		{"notes.toml", "users = 'x'", "unsupported notes format: .toml"},
+	} {
+		if _, err := LoadNotes(writeNotes(t, tt.name, tt.content)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadNotes(%s) = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestNotesOperationMerge expects the notes of the field name, of kind.name
+// and of the returned type, in that order, with their tags merged.
+func TestNotesOperationMerge(t *testing.T) {
+	s, err := Parse([]byte(sdlIntrospection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := Notes{
+		"users":          {Text: "Lists every account", Tags: []string{"PII", "paged"}},
+		"query.users":    {Text: "Needs an admin token", Tags: []string{" pii ", "admin"}},
+		"mutation.users": {Text: "Not a mutation", Tags: []string{"wrong"}},
+		"User":           {Text: "Holds personal data", Tags: []string{"Paged", "pii"}},
+		"SearchResult":   {Tags: []string{"slow"}},
+	}
+	field := func(name string) (f IndexedField) {
+		f, _ = NewIndex(s).Operation(KindQuery, name)
+		return f
+	}
+	texts, tags := notes.Operation(KindQuery, *field("users").Field)
+	if want := []string{"Lists every account", "Needs an admin token", "Holds personal data"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("notes = %q, want %q", texts, want)
+	}
+	if want := []string{"pii", "paged", "admin"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %q, want %q", tags, want)
+	}
+	if texts, tags := notes.Operation(KindQuery, *field("search").Field); texts != nil || !reflect.DeepEqual(tags, []string{"slow"}) {
+		t.Errorf("search: %q, %q; want only the tag of its type", texts, tags)
+	}
+	if texts, tags := notes.Operation(KindQuery, *field("legacy").Field); texts != nil || tags != nil {
+		t.Errorf("legacy: %q, %q; want nothing", texts, tags)
+	}
+	if texts, tags := Notes(nil).Operation(KindQuery, *field("users").Field); texts != nil || tags != nil {
+		t.Errorf("without notes: %q, %q", texts, tags)
+	}
+
+	// The catalog carries the merged annotations.
+	for _, op := range BuildCatalog(s, CatalogOptions{MaxDepth: 2, Notes: notes}).Operations {
+		if op.Name == "users" && (len(op.Notes) != 3 || !reflect.DeepEqual(op.Tags, tags)) {
+			t.Errorf("catalog operation users: notes %q, tags %q", op.Notes, op.Tags)
+		}
+		if op.Name == "deleteUser" && (op.Notes != nil || op.Tags != nil) {
+			t.Errorf("catalog operation deleteUser: notes %q, tags %q", op.Notes, op.Tags)
+		}
+	}
+	if !HasTag(tags, "PII") || HasTag(tags, "slow") {
+		t.Errorf("HasTag(%q) is not a case-insensitive membership test", tags)
+	}
+}
+
+func TestNotesUnknown(t *testing.T) {
+	s, err := Parse([]byte(sdlIntrospection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := Notes{
+		"users":               {Text: "ok"},
+		"mutation.deleteUser": {Text: "ok"},
+		"Role":                {Text: "ok"},
+		"usres":               {Text: "typo"},
+		"query.deleteUser":    {Text: "wrong kind"},
+		"Billing":             {Text: "gone"},
+	}
+	want := []string{
+		`notes name "Billing" is not an operation or type of the schema`,
+		`notes name "query.deleteUser" is not an operation or type of the schema (did you mean deleteUser?)`,
+		`notes name "usres" is not an operation or type of the schema (did you mean User, users?)`,
+	}
+	if got := notes.Unknown(s); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unknown() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Pagination styles
+const (
+	// PaginationRelay pages through a connection with pageInfo.endCursor.
+	PaginationRelay = "relay"
+	// PaginationOffset pages through a list with an offset argument.
+	PaginationOffset = "offset"
+)
+
+// PageSize is the number of records requested per page by paginated documents.
+const PageSize = 50
+
+// Pagination describes how a query returning a list can be paged through.
+type Pagination struct {
+	Style string `json:"style"`
+	// Argument is the argument advanced between pages, declared by Document as
+	// a variable of the same name.
+	Argument string `json:"argument"`
+	// Records is the field of a connection holding the page of records, "edges"
+	// or "nodes". It is empty when the root field returns the list itself.
+	Records string `json:"records,omitempty"`
+	// Document requests one page of PageSize records.
+	Document string `json:"document"`
+}
+
+// DetectPagination is Index.DetectPagination on an index of s built for the
+// call.
+func DetectPagination(s *types.GQLSchema, f types.Field, selection string) *Pagination {
+	return NewIndex(s).DetectPagination(IndexedField{Field: &f, Named: unwrapType(&f.Type)}, selection)
+}
+
+// DetectPagination returns the pagination shape of the query field f, or nil
+// when it is not paginated: a relay connection with an after argument and a
+// pageInfo holding hasNextPage and endCursor, or a list with offset and limit
+// Int arguments. The records are selected with the selection projection.
+func (x *Index) DetectPagination(f IndexedField, selection string) *Pagination {
+	if p := x.relayPagination(f, selection); p != nil {
+		return p
+	}
+	return x.offsetPagination(f, selection)
+}
+
+func (x *Index) relayPagination(f IndexedField, selection string) *Pagination {
+	after := fieldArg(f.Field, "after")
+	if after == nil || after.Type.Kind == types.NON_NULL {
+		return nil
+	}
+	conn, ok := x.Type(f.Named.Name)
+	if !ok || conn.Kind != types.OBJECT {
+		return nil
+	}
+	pageInfo, ok := x.Field(conn.Name, "pageInfo")
+	if !ok {
+		return nil
+	}
+	info := pageInfo.Named.Name
+	if _, ok := x.Field(info, "hasNextPage"); !ok {
+		return nil
+	}
+	if _, ok := x.Field(info, "endCursor"); !ok {
+		return nil
+	}
+
+	var records, set string
+	if edges, ok := x.Field(conn.Name, "edges"); ok {
+		records = "edges"
+		set = "\n    edges {"
+		if node, ok := x.Field(edges.Named.Name, "node"); ok {
+			set += "\n      node {" + x.minimalSelection(node.Named.Name, "        ", selection) + "\n      }"
+		} else {
+			set += x.minimalSelection(edges.Named.Name, "      ", selection)
+		}
+		set += "\n    }"
+	} else if nodes, ok := x.Field(conn.Name, "nodes"); ok {
+		records = "nodes"
+		set = "\n    nodes {" + x.minimalSelection(nodes.Named.Name, "      ", selection) + "\n    }"
+	} else {
+		return nil
+	}
+	set += "\n    pageInfo {\n      hasNextPage\n      endCursor\n    }"
+
+	args := requiredArgs(x.s, f.Field, "after")
+	if first := fieldArg(f.Field, "first"); first != nil && unwrapType(&first.Type).Name == "Int" {
+		args = append([]string{fmt.Sprintf("first: %d", PageSize)}, args...)
+	}
+	args = append(args, "after: $after")
+	return &Pagination{
+		Style:    PaginationRelay,
+		Argument: "after",
+		Records:  records,
+		Document: paginatedDocument(f.Name, "after", after.Type.String(), args, set),
+	}
+}
+
+func (x *Index) offsetPagination(f IndexedField, selection string) *Pagination {
+	offset, limit := fieldArg(f.Field, "offset"), fieldArg(f.Field, "limit")
+	if offset == nil || limit == nil || offset.Type.Kind == types.NON_NULL ||
+		unwrapType(&offset.Type).Name != "Int" || unwrapType(&limit.Type).Name != "Int" {
+		return nil
+	}
+	if !returnsList(&f.Type) {
+		return nil
+	}
+	args := append([]string{fmt.Sprintf("limit: %d", PageSize)}, requiredArgs(x.s, f.Field, "offset", "limit")...)
+	args = append(args, "offset: $offset")
+	set := x.minimalSelection(f.Named.Name, "    ", selection)
+	return &Pagination{
+		Style:    PaginationOffset,
+		Argument: "offset",
+		Document: paginatedDocument(f.Name, "offset", offset.Type.String(), args, set),
+	}
+}
+
+// paginatedDocument renders a query on field declaring the page argument as a variable.
+func paginatedDocument(field, argument, argType string, args []string, selection string) string {
+	doc := fmt.Sprintf("query %s($%s: %s) {\n  %s(%s)", field, argument, argType, field, strings.Join(args, ", "))
+	if selection != "" {
+		doc += " {" + selection + "\n  }"
+	}
+	return doc + "\n}"
+}
+
+// requiredArgs returns placeholder arguments for the non-null arguments of f
+// other than skip.
+func requiredArgs(s *types.GQLSchema, f *types.Field, skip ...string) []string {
+	var args []string
+	for _, arg := range f.Args {
+		if arg.Type.Kind != types.NON_NULL || containsName(skip, arg.Name) {
+			continue
+		}
+		args = append(args, fmt.Sprintf("%s: %s", arg.Name, PlaceholderLiteral(s, &arg.Type)))
+	}
+	return args
+}
+
+// returnsList reports whether tr is a list, possibly non-null.
+func returnsList(tr *types.TypeRef) bool {
+	if tr.Kind == types.NON_NULL {
+		tr = tr.OfType
+	}
+	return tr != nil && tr.Kind == types.LIST
+}
+
+func fieldArg(f *types.Field, name string) *types.InputValue {
+	for i := range f.Args {
+		if f.Args[i].Name == name {
+			return &f.Args[i]
+		}
+	}
+	return nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
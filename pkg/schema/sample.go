@@ -0,0 +1,245 @@
+package schema
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Sampling strategies accepted by Sample
+const (
+	// SampleRandom draws operations at random from a seeded generator.
+	SampleRandom = "random"
+	// SampleSensitiveFirst takes the operations touching the most
+	// sensitive-looking arguments and fields first.
+	SampleSensitiveFirst = "sensitive-first"
+	// SampleCoverage takes the operations reaching the most types not yet
+	// reached by the ones already taken.
+	SampleCoverage = "coverage"
+)
+
+// sensitiveDepth is how many levels of object types below the return type of
+// an operation sensitive-first looks for sensitive field names.
+const sensitiveDepth = 2
+
+// Sample selects some of the operations of a schema for generation,
+// cataloging and extraction.
+type Sample struct {
+	// Size is the number of operations kept; 0 keeps them all.
+	Size     int
+	Strategy string
+	// Seed seeds the generator of SampleRandom.
+	Seed int64
+}
+
+// ValidSampleStrategy reports whether strategy names a sampling strategy.
+func ValidSampleStrategy(strategy string) bool {
+	switch strategy {
+	case SampleRandom, SampleSensitiveFirst, SampleCoverage:
+		return true
+	}
+	return false
+}
+
+// OperationSet holds operations by kind and root field name. A nil set holds
+// every operation.
+type OperationSet map[string]bool
+
+// Has reports whether the set holds the kind operation on the root field name.
+func (o OperationSet) Has(kind, name string) bool {
+	return o == nil || o[kind+" "+name]
+}
+
+// sampleCandidate is a root field a Sample may select.
+type sampleCandidate struct {
+	kind  string
+	field IndexedField
+}
+
+// key identifies the candidate in an OperationSet.
+func (c sampleCandidate) key() string { return c.kind + " " + c.field.Name }
+
+// Select returns the operations of s the sample keeps, nil when it keeps them
+// all. Candidates are taken in schema order, queries, then mutations, then
+// subscriptions, so that a strategy and seed always select the same ones.
+func (p Sample) Select(s *types.GQLSchema) (OperationSet, error) {
+	if p.Size <= 0 {
+		return nil, nil
+	}
+	return p.selectIndexed(NewIndex(s))
+}
+
+// selectIndexed is Select on an indexed schema.
+func (p Sample) selectIndexed(x *Index) (OperationSet, error) {
+	if p.Size <= 0 {
+		return nil, nil
+	}
+	if !ValidSampleStrategy(p.Strategy) {
+		return nil, fmt.Errorf("unknown sampling strategy %q (valid: 'random', 'sensitive-first', 'coverage')", p.Strategy)
+	}
+	var candidates []sampleCandidate
+	for _, kind := range []string{KindQuery, KindMutation, KindSubscription} {
+		for _, f := range x.Operations(kind) {
+			candidates = append(candidates, sampleCandidate{kind, f})
+		}
+	}
+	if p.Size >= len(candidates) {
+		return nil, nil
+	}
+
+	var picked []sampleCandidate
+	switch p.Strategy {
+	case SampleRandom:
+		rng := rand.New(rand.NewSource(p.Seed))
+		for _, i := range rng.Perm(len(candidates))[:p.Size] {
+			picked = append(picked, candidates[i])
+		}
+	case SampleSensitiveFirst:
+		scores := make(map[string]int, len(candidates))
+		for _, c := range candidates {
+			scores[c.key()] = x.sensitiveScore(c.field)
+		}
+		sorted := append([]sampleCandidate(nil), candidates...)
+		sort.SliceStable(sorted, func(i, j int) bool { return scores[sorted[i].key()] > scores[sorted[j].key()] })
+		picked = sorted[:p.Size]
+	case SampleCoverage:
+		picked = x.coverageSample(candidates, p.Size)
+	}
+	set := make(OperationSet, len(picked))
+	for _, c := range picked {
+		set[c.key()] = true
+	}
+	return set, nil
+}
+
+// sensitiveScore counts the sensitive-looking names an operation touches: its
+// arguments, the fields of its input object arguments, and the fields of the
+// object types within sensitiveDepth levels of its return type.
+func (x *Index) sensitiveScore(f IndexedField) int {
+	score := 0
+	for _, arg := range f.Args {
+		if IsSensitiveName(arg.Name) {
+			score++
+		}
+		if input, ok := x.Type(unwrapType(&arg.Type).Name); ok && input.Kind == types.INPUT_OBJECT {
+			for _, field := range input.InputFields {
+				if IsSensitiveName(field.Name) {
+					score++
+				}
+			}
+		}
+	}
+	seen := map[string]bool{}
+	level := []string{f.Named.Name}
+	for depth := 0; depth <= sensitiveDepth && len(level) > 0; depth++ {
+		var next []string
+		for _, name := range level {
+			t, ok := x.Type(name)
+			if !ok || seen[name] {
+				continue
+			}
+			seen[name] = true
+			for _, field := range x.Fields(name) {
+				if IsSensitiveName(field.Name) {
+					score++
+				}
+				next = append(next, field.Named.Name)
+			}
+			for _, pt := range t.PossibleTypes {
+				next = append(next, pt.Name)
+			}
+		}
+		level = next
+	}
+	return score
+}
+
+// coverageSample picks size candidates greedily, each the one reaching the
+// most types the ones picked before do not, the earliest in schema order on
+// ties. Once every type is reached, the rest are taken in schema order.
+func (x *Index) coverageSample(candidates []sampleCandidate, size int) []sampleCandidate {
+	reach := make([]map[string]bool, len(candidates))
+	for i, c := range candidates {
+		reach[i] = x.ReachableTypes(c.field)
+	}
+	covered := map[string]bool{}
+	taken := make([]bool, len(candidates))
+	var picked []sampleCandidate
+	for len(picked) < size {
+		best, bestGain := -1, -1
+		for i := range candidates {
+			if taken[i] {
+				continue
+			}
+			gain := 0
+			for t := range reach[i] {
+				if !covered[t] {
+					gain++
+				}
+			}
+			if gain > bestGain {
+				best, bestGain = i, gain
+			}
+		}
+		taken[best] = true
+		picked = append(picked, candidates[best])
+		for t := range reach[best] {
+			covered[t] = true
+		}
+	}
+	return picked
+}
+
+// ReachableTypes is Index.ReachableTypes on an index of s built for the call.
+func ReachableTypes(s *types.GQLSchema, f types.Field) map[string]bool {
+	return NewIndex(s).ReachableTypes(IndexedField{Field: &f, Named: unwrapType(&f.Type)})
+}
+
+// ReachableTypes returns the names of the types an operation on the root
+// field f can reach: its return type, the types of the fields below it, the
+// possible types of its interfaces and unions, and the input types of the
+// arguments along the way. Built-in scalars and introspection types are left
+// out.
+func (x *Index) ReachableTypes(f IndexedField) map[string]bool {
+	seen := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if seen[name] || isBuiltinType(name) {
+			return
+		}
+		t, ok := x.Type(name)
+		if !ok {
+			return
+		}
+		seen[name] = true
+		for _, field := range x.Fields(name) {
+			visit(field.Named.Name)
+			for _, arg := range field.Args {
+				visit(unwrapType(&arg.Type).Name)
+			}
+		}
+		for _, field := range t.InputFields {
+			visit(unwrapType(&field.Type).Name)
+		}
+		for _, pt := range t.PossibleTypes {
+			visit(pt.Name)
+		}
+	}
+	visit(f.Named.Name)
+	for _, arg := range f.Args {
+		visit(unwrapType(&arg.Type).Name)
+	}
+	return seen
+}
+
+// isBuiltinType reports whether name is a built-in scalar or an introspection type.
+func isBuiltinType(name string) bool {
+	switch name {
+	case "String", "Int", "Float", "Boolean", "ID":
+		return true
+	}
+	return strings.HasPrefix(name, "__")
+}
@@ -0,0 +1,193 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// coverageSchema returns a schema whose operations reach overlapping types:
+//   - a reaches A;
+//   - hub reaches Hub, A, B and C;
+//   - d reaches D and E;
+//   - search reaches A and its Filter argument;
+//   - c reaches C;
+//   - the updateB mutation reaches B and its BInput argument.
+func coverageSchema() *types.GQLSchema {
+	object := func(name string) types.TypeRef { return types.TypeRef{Kind: types.OBJECT, Name: name} }
+	input := func(name string) types.TypeRef { return types.TypeRef{Kind: types.INPUT_OBJECT, Name: name} }
+	id := types.Field{Name: "id", Type: types.TypeRef{Kind: types.SCALAR, Name: "ID"}}
+	query := types.Type{Kind: types.OBJECT, Name: "Query", Fields: []types.Field{
+		{Name: "a", Type: object("A")},
+		{Name: "hub", Type: object("Hub")},
+		{Name: "d", Type: object("D")},
+		{Name: "search", Type: object("A"), Args: []types.InputValue{{Name: "filter", Type: input("Filter")}}},
+		{Name: "c", Type: object("C")},
+	}}
+	mutation := types.Type{Kind: types.OBJECT, Name: "Mutation", Fields: []types.Field{
+		{Name: "updateB", Type: object("B"), Args: []types.InputValue{{Name: "input", Type: types.TypeRef{Kind: types.NON_NULL, OfType: &types.TypeRef{Kind: types.INPUT_OBJECT, Name: "BInput"}}}}},
+	}}
+	return &types.GQLSchema{Query: &query, Mutation: &mutation, Types: map[string]types.Type{
+		"Query":    query,
+		"Mutation": mutation,
+		"Hub": {Kind: types.OBJECT, Name: "Hub", Fields: []types.Field{
+			{Name: "a", Type: object("A")}, {Name: "b", Type: object("B")}, {Name: "c", Type: object("C")},
+		}},
+		"A":      {Kind: types.OBJECT, Name: "A", Fields: []types.Field{id}},
+		"B":      {Kind: types.OBJECT, Name: "B", Fields: []types.Field{id}},
+		"C":      {Kind: types.OBJECT, Name: "C", Fields: []types.Field{id}},
+		"D":      {Kind: types.OBJECT, Name: "D", Fields: []types.Field{id, {Name: "e", Type: object("E")}}},
+		"E":      {Kind: types.OBJECT, Name: "E", Fields: []types.Field{id}},
+		"Filter": {Kind: types.INPUT_OBJECT, Name: "Filter", InputFields: []types.InputValue{{Name: "q", Type: types.TypeRef{Kind: types.SCALAR, Name: "String"}}}},
+		"BInput": {Kind: types.INPUT_OBJECT, Name: "BInput", InputFields: []types.InputValue{{Name: "name", Type: types.TypeRef{Kind: types.SCALAR, Name: "String"}}}},
+		"ID":     {Kind: types.SCALAR, Name: "ID"},
+		"String": {Kind: types.SCALAR, Name: "String"},
+	}}
+}
+
+// keys returns the operations of set, sorted.
+func keys(set OperationSet) []string {
+	var out []string
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestReachableTypes(t *testing.T) {
+	s := coverageSchema()
+	for _, tt := range []struct {
+		field *types.Type
+		name  string
+		want  string
+	}{
+		{s.Query, "hub", "A,B,C,Hub"},
+		{s.Query, "d", "D,E"},
+		{s.Query, "search", "A,Filter"},
+		{s.Mutation, "updateB", "B,BInput"},
+	} {
+		var f types.Field
+		for _, field := range tt.field.Fields {
+			if field.Name == tt.name {
+				f = field
+			}
+		}
+		var got []string
+		for name := range ReachableTypes(s, f) {
+			got = append(got, name)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("ReachableTypes(%s) = %q, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestCoverageSample checks each greedy pick against the types the picks
+// before it already reach.
+func TestCoverageSample(t *testing.T) {
+	s := coverageSchema()
+	tests := []struct {
+		size int
+		want []string
+	}{
+		// hub reaches the most types, 4, though a comes first.
+		{1, []string{"query hub"}},
+		// d adds D and E, 2 new types; search and c add at most 1.
+		{2, []string{"query d", "query hub"}},
+		// search and updateB each add 1; the query comes first in schema order.
+		{3, []string{"query d", "query hub", "query search"}},
+		{4, []string{"mutation updateB", "query d", "query hub", "query search"}},
+		// Every type is reached: the rest go in schema order, a before c.
+		{5, []string{"mutation updateB", "query a", "query d", "query hub", "query search"}},
+	}
+	for _, tt := range tests {
+		set, err := Sample{Size: tt.size, Strategy: SampleCoverage}.Select(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := keys(set); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coverage sample of %d = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+
+	// Four operations reach all 8 types of the schema, where the first four
+	// in schema order miss BInput.
+	set, _ := Sample{Size: 4, Strategy: SampleCoverage}.Select(s)
+	covered := map[string]bool{}
+	x := NewIndex(s)
+	for _, kind := range []string{KindQuery, KindMutation} {
+		for _, f := range x.Operations(kind) {
+			if set.Has(kind, f.Name) {
+				for name := range x.ReachableTypes(f) {
+					covered[name] = true
+				}
+			}
+		}
+	}
+	if len(covered) != 8 {
+		t.Errorf("the sample of 4 reaches %d types, want all 8: %v", len(covered), covered)
+	}
+}
+
+// wideSchema returns a schema of n queries, q0 to q(n-1).
+func wideSchema(n int) *types.GQLSchema {
+	query := types.Type{Kind: types.OBJECT, Name: "Query"}
+	for i := 0; i < n; i++ {
+		query.Fields = append(query.Fields, types.Field{Name: fmt.Sprintf("q%d", i), Type: types.TypeRef{Kind: types.SCALAR, Name: "String"}})
+	}
+	return &types.GQLSchema{Query: &query, Types: map[string]types.Type{"Query": query, "String": {Kind: types.SCALAR, Name: "String"}}}
+}
+
+func TestRandomSampleIsSeeded(t *testing.T) {
+	s := wideSchema(40)
+	sample := func(seed int64) []string {
+		t.Helper()
+		set, err := Sample{Size: 5, Strategy: SampleRandom, Seed: seed}.Select(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(set) != 5 {
+			t.Fatalf("seed %d selected %d operations, want 5", seed, len(set))
+		}
+		return keys(set)
+	}
+	// math/rand keeps the sequence of a seed across releases, so the sample
+	// of --sample-seed 1 is pinned.
+	want := []string{"query q10", "query q13", "query q2", "query q21", "query q4"}
+	for i := 0; i < 10; i++ {
+		if got := sample(1); !reflect.DeepEqual(got, want) {
+			t.Fatalf("seed 1 selected %q, then %q", want, got)
+		}
+	}
+	// A copy of the schema with its types in another map orders the same.
+	copied := *s
+	copied.Types = map[string]types.Type{"String": s.Types["String"], "Query": s.Types["Query"]}
+	if set, _ := (Sample{Size: 5, Strategy: SampleRandom, Seed: 1}).Select(&copied); !reflect.DeepEqual(keys(set), want) {
+		t.Errorf("a copy of the schema selected %q, want %q", keys(set), want)
+	}
+	if got := sample(2); reflect.DeepEqual(got, want) {
+		t.Errorf("seeds 1 and 2 both selected %q", got)
+	}
+}
+
+func TestSampleSizes(t *testing.T) {
+	s := coverageSchema()
+	for _, size := range []int{0, 6, 100} {
+		if set, err := (Sample{Size: size, Strategy: SampleRandom}).Select(s); err != nil || set != nil {
+			t.Errorf("a sample of %d of 6 operations = %q, %v; want them all", size, keys(set), err)
+		}
+	}
+	if _, err := (Sample{Size: 2, Strategy: "smart"}).Select(s); err == nil || !strings.Contains(err.Error(), `unknown sampling strategy "smart"`) {
+		t.Errorf("an unknown strategy = %v", err)
+	}
+	var all OperationSet
+	if !all.Has(KindMutation, "updateB") {
+		t.Error("a nil set does not hold every operation")
+	}
+}
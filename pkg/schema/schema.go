@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/CyberRoute/graphspecter/pkg/logger"
@@ -20,10 +21,52 @@ func LoadFromFile(filePath string) (*types.GQLSchema, error) {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Parse JSON
-	var response types.IntrospectionResponse
-	if err := json.Unmarshal(fileContent, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	return Parse(fileContent)
+}
+
+// LoadFromIntrospection builds a schema from an introspection result already held in memory
+func LoadFromIntrospection(result map[string]interface{}) (*types.GQLSchema, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal introspection result: %w", err)
+	}
+	return Parse(data)
+}
+
+// introspectionDocument accepts a full introspection response as well as a bare
+// {"__schema": ...} object.
+type introspectionDocument struct {
+	Data *struct {
+		Schema *types.Schema `json:"__schema"`
+	} `json:"data"`
+	Schema *types.Schema        `json:"__schema"`
+	Errors []types.GraphQLError `json:"errors"`
+}
+
+// Parse builds a schema from raw introspection result JSON. The document is
+// validated and failures are returned as a *LoadError matching one of the Err
+// values of this package.
+func Parse(content []byte) (*types.GQLSchema, error) {
+	var doc introspectionDocument
+	if err := json.Unmarshal(content, &doc); err != nil {
+		if looksLikeSDL(content) {
+			return nil, &LoadError{Kind: ErrSDL}
+		}
+		return nil, &LoadError{Kind: ErrInvalidJSON, Detail: err.Error(), Err: err}
+	}
+
+	result := doc.Schema
+	if doc.Data != nil && doc.Data.Schema != nil {
+		result = doc.Data.Schema
+	}
+	if result == nil {
+		if len(doc.Errors) > 0 {
+			return nil, &LoadError{Kind: ErrNotIntrospection, Detail: "the response holds only errors: " + doc.Errors[0].Message}
+		}
+		return nil, &LoadError{Kind: ErrNotIntrospection, Detail: "no __schema object found"}
+	}
+	if len(result.Types) == 0 {
+		return nil, &LoadError{Kind: ErrNoTypes}
 	}
 
 	// Create and initialize schema
@@ -32,30 +75,49 @@ func LoadFromFile(filePath string) (*types.GQLSchema, error) {
 	}
 
 	// Add all types to the map for easy lookup
-	for _, t := range response.Data.Schema.Types {
+	for _, t := range result.Types {
 		schema.Types[t.Name] = t
 	}
 
-	// Set query type
-	queryTypeName := response.Data.Schema.QueryType.Name
-	if queryType, ok := schema.Types[queryTypeName]; ok {
-		schema.Query = &queryType
-	} else {
-		logger.Warn("Query type '%s' not found in schema", queryTypeName)
+	var err error
+	if schema.Query, err = rootType(schema, "queryType", result.QueryType); err != nil {
+		return nil, err
 	}
-
-	// Set mutation type if it exists
-	mutationTypeName := response.Data.Schema.MutationType.Name
-	if mutationTypeName != "" {
-		if mutationType, ok := schema.Types[mutationTypeName]; ok {
-			schema.Mutation = &mutationType
-		}
+	if schema.Query == nil {
+		logger.Warn("Schema declares no query type")
+	}
+	if schema.Mutation, err = rootType(schema, "mutationType", result.MutationType); err != nil {
+		return nil, err
+	}
+	if schema.Subscription, err = rootType(schema, "subscriptionType", result.SubscriptionType); err != nil {
+		return nil, err
 	}
 
 	logger.Info("Schema loaded successfully")
 	return schema, nil
 }
 
+// rootType resolves a root operation type. A nil or unnamed reference means the
+// schema has no such root; a name missing from the type list is an error.
+func rootType(s *types.GQLSchema, field string, ref *types.SchemaType) (*types.Type, error) {
+	if ref == nil || ref.Name == "" {
+		return nil, nil
+	}
+	t, ok := s.Types[ref.Name]
+	if !ok {
+		return nil, &LoadError{Kind: ErrMissingRootType, Detail: fmt.Sprintf("%s names %q, which is not in the type list", field, ref.Name)}
+	}
+	switch t.Kind {
+	case types.OBJECT, "":
+	case types.INTERFACE:
+		// Some servers expose interface-only roots; their fields are still selectable.
+		logger.Warn("%s %q is an interface type", field, ref.Name)
+	default:
+		return nil, &LoadError{Kind: ErrInvalidRootType, Detail: fmt.Sprintf("%s %q is of kind %s", field, ref.Name, t.Kind)}
+	}
+	return &t, nil
+}
+
 // Helper function to recursively unwrap NON_NULL and LIST wrappers
 func unwrapType(tr *types.TypeRef) *types.TypeRef {
 	for tr.Kind == types.NON_NULL || tr.Kind == types.LIST {
@@ -65,7 +127,9 @@ func unwrapType(tr *types.TypeRef) *types.TypeRef {
 }
 
 // generateSelectionSetWithCount recursively generates a selection set using a count-based cycle detection.
-func generateSelectionSetWithCount(s *types.GQLSchema, typeName string, maxDepth int, indent string, visited map[string]int) string {
+// The selection projection decides which fields are selected, a SelectionFull
+// branch going one level deeper through the first optional object it meets.
+func (x *Index) generateSelectionSetWithCount(typeName string, maxDepth int, indent string, visited map[string]int, selection string) string {
 	if maxDepth <= 0 {
 		return fmt.Sprintf("\n%s!!! MAX RECURSION DEPTH REACHED !!!", indent)
 	}
@@ -81,17 +145,24 @@ func generateSelectionSetWithCount(s *types.GQLSchema, typeName string, maxDepth
 		visited[typeName]--
 	}()
 
-	typeDef, ok := s.Types[typeName]
-	if !ok || len(typeDef.Fields) == 0 {
+	fields := x.Fields(typeName)
+	if len(fields) == 0 {
 		return ""
 	}
 
-	selectionSet := ""
 	newIndent := indent + "    "
-	for _, f := range typeDef.Fields {
-		underlying := unwrapType(&f.Type)
-		if underlying.Kind == types.OBJECT {
-			nested := generateSelectionSetWithCount(s, underlying.Name, maxDepth-1, newIndent, visited)
+	if selection == SelectionMinimal {
+		return idSelection(fields, newIndent)
+	}
+	selectionSet := ""
+	for _, f := range fields {
+		if f.Named.Kind == types.OBJECT {
+			depth, nestedSelection := maxDepth-1, selection
+			if selection == SelectionFull && f.Type.Kind != types.NON_NULL {
+				// The extra level is spent; the branch continues as standard.
+				depth, nestedSelection = maxDepth, SelectionStandard
+			}
+			nested := x.generateSelectionSetWithCount(f.Named.Name, depth, newIndent, visited, nestedSelection)
 			if nested != "" && !strings.Contains(nested, "MAX RECURSION") {
 				selectionSet += fmt.Sprintf("\n%s%s { %s\n%s}", newIndent, f.Name, nested, newIndent)
 			} else {
@@ -104,20 +175,21 @@ func generateSelectionSetWithCount(s *types.GQLSchema, typeName string, maxDepth
 	return selectionSet
 }
 
-// GenerateQuery generates a GraphQL query for the specified field.
-func GenerateQuery(s *types.GQLSchema, fieldName string, maxDepth int) (string, error) {
-	if s.Query == nil {
+// GenerateQuery is Index.GenerateQuery on an index of s built for the call.
+// Callers generating several operations should build the Index once instead.
+func GenerateQuery(s *types.GQLSchema, fieldName string, maxDepth int, selection string) (string, error) {
+	return NewIndex(s).GenerateQuery(fieldName, maxDepth, selection)
+}
+
+// GenerateQuery generates a GraphQL query for the specified field with the
+// selection projection.
+func (x *Index) GenerateQuery(fieldName string, maxDepth int, selection string) (string, error) {
+	if !x.HasRoot(KindQuery) {
 		return "", fmt.Errorf("schema has no query type")
 	}
 
-	var queryField *types.Field
-	for _, field := range s.Query.Fields {
-		if field.Name == fieldName {
-			queryField = &field
-			break
-		}
-	}
-	if queryField == nil {
+	queryField, ok := x.Operation(KindQuery, fieldName)
+	if !ok {
 		return "", fmt.Errorf("field '%s' not found in query type", fieldName)
 	}
 
@@ -133,9 +205,8 @@ func GenerateQuery(s *types.GQLSchema, fieldName string, maxDepth int) (string,
 		query += ")"
 	}
 
-	underlying := unwrapType(&queryField.Type)
 	visited := make(map[string]int)
-	selectionSet := generateSelectionSetWithCount(s, underlying.Name, maxDepth, "  ", visited)
+	selectionSet := x.generateSelectionSetWithCount(queryField.Named.Name, maxDepth, "  ", visited, selection)
 	if selectionSet != "" {
 		query += " {" + selectionSet + "\n  }\n}"
 	} else {
@@ -144,20 +215,21 @@ func GenerateQuery(s *types.GQLSchema, fieldName string, maxDepth int) (string,
 	return query, nil
 }
 
-// / GenerateMutation generates a GraphQL mutation for the specified field.
-func GenerateMutation(s *types.GQLSchema, fieldName string, maxDepth int) (string, error) {
-	if s.Mutation == nil {
+// GenerateMutation is Index.GenerateMutation on an index of s built for the
+// call.
+func GenerateMutation(s *types.GQLSchema, fieldName string, maxDepth int, selection string) (string, error) {
+	return NewIndex(s).GenerateMutation(fieldName, maxDepth, selection)
+}
+
+// / GenerateMutation generates a GraphQL mutation for the specified field with
+// the selection projection.
+func (x *Index) GenerateMutation(fieldName string, maxDepth int, selection string) (string, error) {
+	if !x.HasRoot(KindMutation) {
 		return "", fmt.Errorf("schema has no mutation type")
 	}
 
-	var mutationField *types.Field
-	for _, field := range s.Mutation.Fields {
-		if field.Name == fieldName {
-			mutationField = &field
-			break
-		}
-	}
-	if mutationField == nil {
+	mutationField, ok := x.Operation(KindMutation, fieldName)
+	if !ok {
 		return "", fmt.Errorf("field '%s' not found in mutation type", fieldName)
 	}
 
@@ -173,9 +245,8 @@ func GenerateMutation(s *types.GQLSchema, fieldName string, maxDepth int) (strin
 		mutation += ")"
 	}
 
-	underlying := unwrapType(&mutationField.Type)
 	visited := make(map[string]int)
-	selectionSet := generateSelectionSetWithCount(s, underlying.Name, maxDepth, "  ", visited)
+	selectionSet := x.generateSelectionSetWithCount(mutationField.Named.Name, maxDepth, "  ", visited, selection)
 	if selectionSet != "" {
 		mutation += " {" + selectionSet + "\n  }\n}"
 	} else {
@@ -213,3 +284,25 @@ func ListMutations(s *types.GQLSchema) []string {
 
 	return mutations
 }
+
+// Sort modes for operation listings
+const (
+	// SortSchema keeps operations in the order the schema declares them
+	SortSchema = "schema"
+	// SortAlpha orders operations alphabetically
+	SortAlpha = "alpha"
+)
+
+// ValidSortMode reports whether mode is a supported sort mode
+func ValidSortMode(mode string) bool {
+	return mode == SortSchema || mode == SortAlpha
+}
+
+// SortNames returns names ordered according to mode. The input slice is not modified.
+func SortNames(names []string, mode string) []string {
+	sorted := append([]string(nil), names...)
+	if mode == SortAlpha {
+		sort.Strings(sorted)
+	}
+	return sorted
+}
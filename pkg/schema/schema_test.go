@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// introspectionTypes is a type list holding an object, an interface and a
+// scalar, for fixtures to name as their roots.
+const introspectionTypes = `"types": [
+	{"kind": "OBJECT", "name": "Query", "fields": [{"name": "me", "args": [], "type": {"kind": "SCALAR", "name": "String"}}]},
+	{"kind": "INTERFACE", "name": "Node", "fields": [{"name": "id", "args": [], "type": {"kind": "SCALAR", "name": "ID"}}]},
+	{"kind": "SCALAR", "name": "String"},
+	{"kind": "SCALAR", "name": "ID"}
+]`
+
+// introspectionFixture returns an introspection response with the given root
+// type references, each a JSON value or empty to leave the key out.
+func introspectionFixture(query, mutation, subscription string) string {
+	var roots []string
+	for _, root := range []struct{ key, value string }{{"queryType", query}, {"mutationType", mutation}, {"subscriptionType", subscription}} {
+		if root.value != "" {
+			roots = append(roots, `"`+root.key+`": `+root.value)
+		}
+	}
+	return `{"data": {"__schema": {` + strings.Join(append(roots, introspectionTypes), ", ") + `}}}`
+}
+
+func TestParseMalformedDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		kind     error
+		detail   string
+	}{
+		{"SDL", "type Query {\n  me: String\n}\n", ErrSDL, ""},
+		{"SDL with a schema definition", "schema @link(url: \"x\") {\n  query: Query\n}\n", ErrSDL, ""},
+		{"truncated JSON", `{"data": {"__schema": {"types": [`, ErrInvalidJSON, "unexpected end of JSON input"},
+		{"plain text", "introspection is disabled", ErrInvalidJSON, "invalid character"},
+		{"other JSON", `{"data": {"me": "alice"}}`, ErrNotIntrospection, "no __schema object found"},
+		{"null __schema", `{"data": {"__schema": null}}`, ErrNotIntrospection, "no __schema object found"},
+		{"null data", `{"data": null}`, ErrNotIntrospection, "no __schema object found"},
+		{"error response", `{"data": null, "errors": [{"message": "GraphQL introspection is not allowed"}]}`, ErrNotIntrospection, "GraphQL introspection is not allowed"},
+		{"no types", `{"data": {"__schema": {"queryType": {"name": "Query"}, "types": []}}}`, ErrNoTypes, ""},
+		{"null types", `{"data": {"__schema": {"queryType": {"name": "Query"}, "types": null}}}`, ErrNoTypes, ""},
+		{"missing query type", introspectionFixture(`{"name": "RootQuery"}`, "", ""), ErrMissingRootType, `queryType names "RootQuery"`},
+		{"missing mutation type", introspectionFixture(`{"name": "Query"}`, `{"name": "Mutation"}`, ""), ErrMissingRootType, `mutationType names "Mutation"`},
+		{"missing subscription type", introspectionFixture(`{"name": "Query"}`, "null", `{"name": "Subscription"}`), ErrMissingRootType, `subscriptionType names "Subscription"`},
+		{"scalar root", introspectionFixture(`{"name": "String"}`, "", ""), ErrInvalidRootType, `queryType "String" is of kind SCALAR`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse([]byte(tt.document))
+			if s != nil || !errors.Is(err, tt.kind) {
+				t.Fatalf("Parse() = %v, %v; want %v", s, err, tt.kind)
+			}
+			var loadErr *LoadError
+			if !errors.As(err, &loadErr) || !strings.Contains(loadErr.Detail, tt.detail) {
+				t.Errorf("error = %#v, want a *LoadError with detail %q", err, tt.detail)
+			}
+			if Hint(err) == "" {
+				t.Errorf("Hint(%v) is empty", err)
+			}
+		})
+	}
+}
+
+func TestParseRoots(t *testing.T) {
+	tests := []struct {
+		name                          string
+		document                      string
+		query, mutation, subscription string
+	}{
+		{"null mutation and subscription", introspectionFixture(`{"name": "Query"}`, "null", "null"), "Query", "", ""},
+		{"absent mutation and subscription", introspectionFixture(`{"name": "Query"}`, "", ""), "Query", "", ""},
+		{"unnamed mutation", introspectionFixture(`{"name": "Query"}`, `{"name": ""}`, ""), "Query", "", ""},
+		{"null query", introspectionFixture("null", `{"name": "Query"}`, ""), "", "Query", ""},
+		{"interface-only roots", introspectionFixture(`{"name": "Node"}`, "null", `{"name": "Node"}`), "Node", "", "Node"},
+		{"bare __schema", `{"__schema": {"queryType": {"name": "Query"}, ` + introspectionTypes + `}}`, "Query", "", ""},
+	}
+	name := func(t *types.Type) string {
+		if t == nil {
+			return ""
+		}
+		return t.Name
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse([]byte(tt.document))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name(s.Query) != tt.query || name(s.Mutation) != tt.mutation || name(s.Subscription) != tt.subscription {
+				t.Errorf("roots = %q, %q, %q; want %q, %q, %q", name(s.Query), name(s.Mutation), name(s.Subscription), tt.query, tt.mutation, tt.subscription)
+			}
+			if len(s.Types) != 4 {
+				t.Errorf("loaded %d types, want 4", len(s.Types))
+			}
+			// A schema without some roots still lists and catalogs the others.
+			if got := len(BuildCatalog(s, CatalogOptions{MaxDepth: 2}).Operations); (tt.query != "" || tt.mutation != "") && got == 0 {
+				t.Error("the catalog is empty")
+			}
+		})
+	}
+}
+
+func TestLoadFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.graphql")
+	if err := os.WriteFile(path, []byte("type Query {\n  me: String\n}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFromFile(path); !errors.Is(err, ErrSDL) || !strings.Contains(Hint(err), "instead of an SDL file") {
+		t.Errorf("LoadFromFile() of an SDL file = %v, hint %q", err, Hint(err))
+	}
+	_, err := LoadFromFile(filepath.Join(dir, "missing.json"))
+	if !errors.Is(err, os.ErrNotExist) || Hint(err) != "" {
+		t.Errorf("LoadFromFile() of a missing file = %v, hint %q", err, Hint(err))
+	}
+}
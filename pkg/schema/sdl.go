@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// SDL renders s as a schema definition document, types sorted by name. The
+// directives applied to fields, as read by pkg/schema/metadata, are rendered
+// after their type, and deprecations as @deprecated. Built-in scalars and
+// introspection types are left out.
+func SDL(s *types.GQLSchema) string {
+	var b strings.Builder
+	if def := schemaDefinition(s); def != "" {
+		b.WriteString(def)
+	}
+	names := make([]string, 0, len(s.Types))
+	for name := range s.Types {
+		if !isBuiltinType(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		writeTypeSDL(&b, s.Types[name])
+	}
+	return b.String()
+}
+
+// WriteSDL writes the SDL of s to filename.
+func WriteSDL(s *types.GQLSchema, filename string) error {
+	if err := artifacts.WriteFile(filename, []byte(SDL(s)), 0644); err != nil {
+		return fmt.Errorf("error writing SDL: %w", err)
+	}
+	return nil
+}
+
+// schemaDefinition is the schema block naming the root types, empty when
+// they have the default names Query, Mutation and Subscription.
+func schemaDefinition(s *types.GQLSchema) string {
+	roots := []struct {
+		op, name string
+		typ      *types.Type
+	}{{"query", "Query", s.Query}, {"mutation", "Mutation", s.Mutation}, {"subscription", "Subscription", s.Subscription}}
+	custom := false
+	for _, r := range roots {
+		if r.typ != nil && r.typ.Name != r.name {
+			custom = true
+		}
+	}
+	if !custom {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("schema {\n")
+	for _, r := range roots {
+		if r.typ != nil {
+			fmt.Fprintf(&b, "  %s: %s\n", r.op, r.typ.Name)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeTypeSDL(b *strings.Builder, t types.Type) {
+	writeDescription(b, t.Description, "")
+	switch t.Kind {
+	case types.SCALAR:
+		fmt.Fprintf(b, "scalar %s\n", t.Name)
+	case types.UNION:
+		members := make([]string, len(t.PossibleTypes))
+		for i, p := range t.PossibleTypes {
+			members[i] = p.Name
+		}
+		fmt.Fprintf(b, "union %s = %s\n", t.Name, strings.Join(members, " | "))
+	case types.ENUM:
+		fmt.Fprintf(b, "enum %s {\n", t.Name)
+		for _, v := range t.EnumValues {
+			writeDescription(b, v.Description, "  ")
+			b.WriteString("  " + v.Name + deprecatedDirective(v.IsDeprecated, v.DeprecationReason) + "\n")
+		}
+		b.WriteString("}\n")
+	case types.INPUT_OBJECT:
+		fmt.Fprintf(b, "input %s {\n", t.Name)
+		for _, f := range t.InputFields {
+			writeDescription(b, f.Description, "  ")
+			b.WriteString("  " + inputValueSDL(f) + "\n")
+		}
+		b.WriteString("}\n")
+	default:
+		keyword := "type"
+		if t.Kind == types.INTERFACE {
+			keyword = "interface"
+		}
+		b.WriteString(keyword + " " + t.Name)
+		if len(t.Interfaces) > 0 {
+			names := make([]string, len(t.Interfaces))
+			for i, iface := range t.Interfaces {
+				names[i] = iface.Name
+			}
+			b.WriteString(" implements " + strings.Join(names, " & "))
+		}
+		b.WriteString(" {\n")
+		for _, f := range t.Fields {
+			writeDescription(b, f.Description, "  ")
+			b.WriteString("  " + fieldSDL(f) + "\n")
+		}
+		b.WriteString("}\n")
+	}
+}
+
+// fieldSDL renders the definition of f with its arguments and directives.
+func fieldSDL(f types.Field) string {
+	def := f.Name
+	if len(f.Args) > 0 {
+		args := make([]string, len(f.Args))
+		for i, a := range f.Args {
+			args[i] = inputValueSDL(a)
+		}
+		def += "(" + strings.Join(args, ", ") + ")"
+	}
+	def += ": " + f.Type.String()
+	deprecated := false
+	for _, d := range f.AppliedDirectives {
+		def += " " + d.String()
+		deprecated = deprecated || d.Name == "deprecated"
+	}
+	if !deprecated {
+		def += deprecatedDirective(f.IsDeprecated, f.DeprecationReason)
+	}
+	return def
+}
+
+// inputValueSDL renders an argument or input field with its default value.
+func inputValueSDL(v types.InputValue) string {
+	def := v.Name + ": " + v.Type.String()
+	if v.DefaultValue != "" {
+		def += " = " + v.DefaultValue
+	}
+	return def
+}
+
+// deprecatedDirective is the @deprecated directive of a deprecated element,
+// with a space in front, or nothing.
+func deprecatedDirective(deprecated bool, reason string) string {
+	switch {
+	case !deprecated:
+		return ""
+	case reason == "":
+		return " @deprecated"
+	default:
+		return " @deprecated(reason: " + strconv.Quote(reason) + ")"
+	}
+}
+
+// writeDescription writes description as a block string indented by indent.
+func writeDescription(b *strings.Builder, description, indent string) {
+	if description == "" {
+		return
+	}
+	escaped := strings.ReplaceAll(description, `"""`, `\"""`)
+	b.WriteString(indent + `"""` + "\n")
+	for _, line := range strings.Split(escaped, "\n") {
+		b.WriteString(indent + line + "\n")
+	}
+	b.WriteString(indent + `"""` + "\n")
+}
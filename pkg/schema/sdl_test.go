@@ -0,0 +1,144 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// sdlIntrospection is an introspection result with a custom query root, an
+// interface, an enum, an input, a union and a deprecated field.
+const sdlIntrospection = `{"data": {"__schema": {
+  "queryType": {"name": "query_root"},
+  "mutationType": {"name": "Mutation"},
+  "types": [
+    {"kind": "OBJECT", "name": "query_root", "fields": [
+      {"name": "users", "description": "All users", "args": [
+        {"name": "role", "type": {"kind": "ENUM", "name": "Role"}, "defaultValue": "USER"},
+        {"name": "first", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "Int"}}}
+      ], "type": {"kind": "LIST", "ofType": {"kind": "OBJECT", "name": "User"}}},
+      {"name": "search", "args": [], "type": {"kind": "UNION", "name": "SearchResult"}},
+      {"name": "legacy", "args": [], "type": {"kind": "SCALAR", "name": "String"}, "isDeprecated": true, "deprecationReason": "Use \"users\""}
+    ]},
+    {"kind": "OBJECT", "name": "Mutation", "fields": [
+      {"name": "deleteUser", "args": [{"name": "input", "type": {"kind": "INPUT_OBJECT", "name": "DeleteInput"}}], "type": {"kind": "SCALAR", "name": "Boolean"}}
+    ]},
+    {"kind": "INTERFACE", "name": "Node", "fields": [{"name": "id", "args": [], "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "ID"}}}]},
+    {"kind": "OBJECT", "name": "User", "interfaces": [{"kind": "INTERFACE", "name": "Node"}], "fields": [
+      {"name": "id", "args": [], "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "ID"}}}
+    ]},
+    {"kind": "UNION", "name": "SearchResult", "possibleTypes": [{"kind": "OBJECT", "name": "User"}]},
+    {"kind": "ENUM", "name": "Role", "description": "Access role", "enumValues": [{"name": "USER"}, {"name": "ROOT", "isDeprecated": true}]},
+    {"kind": "INPUT_OBJECT", "name": "DeleteInput", "inputFields": [{"name": "id", "type": {"kind": "SCALAR", "name": "ID"}}, {"name": "hard", "type": {"kind": "SCALAR", "name": "Boolean"}, "defaultValue": "false"}]},
+    {"kind": "SCALAR", "name": "DateTime"},
+    {"kind": "SCALAR", "name": "String"},
+    {"kind": "SCALAR", "name": "ID"},
+    {"kind": "OBJECT", "name": "__Type", "fields": []}
+  ]
+}}}`
+
+const wantSDL = `schema {
+  query: query_root
+  mutation: Mutation
+}
+
+scalar DateTime
+
+input DeleteInput {
+  id: ID
+  hard: Boolean = false
+}
+
+type Mutation {
+  deleteUser(input: DeleteInput): Boolean @hasuraPermission(roles: ["admin"])
+}
+
+interface Node {
+  id: ID!
+}
+
+"""
+Access role
+"""
+enum Role {
+  USER
+  ROOT @deprecated
+}
+
+union SearchResult = User
+
+type User implements Node {
+  id: ID!
+}
+
+type query_root {
+  """
+  All users
+  """
+  users(role: Role = USER, first: Int!): [User] @requiresScopes(scopes: [["read:users"]]) @authenticated
+  search: SearchResult
+  legacy: String @deprecated(reason: "Use \"users\"")
+}
+`
+
+// directiveSchema parses sdlIntrospection and applies directives to
+// query_root.users and Mutation.deleteUser, as the metadata extractors do.
+func directiveSchema(t *testing.T) *types.GQLSchema {
+	t.Helper()
+	s, err := Parse([]byte(sdlIntrospection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Query.Fields[0].AppliedDirectives = []types.AppliedDirective{
+		{Name: "requiresScopes", Args: []types.AppliedDirectiveArg{{Name: "scopes", Value: `[["read:users"]]`}}},
+		{Name: "authenticated"},
+	}
+	s.Mutation.Fields[0].AppliedDirectives = []types.AppliedDirective{
+		{Name: "hasuraPermission", Args: []types.AppliedDirectiveArg{{Name: "roles", Value: `["admin"]`}}},
+	}
+	return s
+}
+
+func TestSDL(t *testing.T) {
+	if got := SDL(directiveSchema(t)); got != wantSDL {
+		t.Errorf("SDL() =\n%s\nwant\n%s", got, wantSDL)
+	}
+}
+
+func TestSDLDefaultRoots(t *testing.T) {
+	s := &types.GQLSchema{Types: map[string]types.Type{
+		"Query": {Kind: types.OBJECT, Name: "Query", Fields: []types.Field{{Name: "ok", Type: types.TypeRef{Kind: types.SCALAR, Name: "Boolean"}}}},
+	}}
+	q := s.Types["Query"]
+	s.Query = &q
+	if got, want := SDL(s), "type Query {\n  ok: Boolean\n}\n"; got != want {
+		t.Errorf("SDL() = %q, want %q", got, want)
+	}
+}
+
+func TestAuthSurface(t *testing.T) {
+	c := BuildCatalog(directiveSchema(t), CatalogOptions{MaxDepth: 2})
+	want := []AuthGroup{
+		{Guard: "@authenticated", Operations: []string{"query users"}},
+		{Guard: `@hasuraPermission(roles: ["admin"])`, Operations: []string{"mutation deleteUser"}},
+		{Guard: `@requiresScopes(scopes: [["read:users"]])`, Operations: []string{"query users"}},
+		{Guard: Unguarded, Operations: []string{"query search", "query legacy"}},
+	}
+	if !reflect.DeepEqual(c.AuthSurface, want) {
+		t.Errorf("AuthSurface = %+v\nwant %+v", c.AuthSurface, want)
+	}
+	for _, op := range c.Operations {
+		if op.Name == "deleteUser" && !reflect.DeepEqual(op.AuthHints, []string{`applies directive @hasuraPermission(roles: ["admin"])`}) {
+			t.Errorf("deleteUser auth hints = %v", op.AuthHints)
+		}
+	}
+
+	s, err := Parse([]byte(sdlIntrospection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := BuildCatalog(s, CatalogOptions{MaxDepth: 2}); c.AuthSurface != nil {
+		t.Errorf("schema without guard directives has auth surface %+v", c.AuthSurface)
+	}
+}
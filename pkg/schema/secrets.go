@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Pattern is a named regular expression for secret-looking text.
+type Pattern struct {
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+// DefaultSecretPatterns is the pattern table used to scan default values and
+// descriptions. Earlier patterns win when matches overlap, so specific ones
+// come before generic ones.
+var DefaultSecretPatterns = []Pattern{
+	{"url-credentials", regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@"']+:[^/\s@"']+@[^\s"']+`)},
+	{"internal-url", regexp.MustCompile(`\bhttps?://(?:localhost|127(?:\.\d{1,3}){3}|10(?:\.\d{1,3}){3}|192\.168(?:\.\d{1,3}){2}|172\.(?:1[6-9]|2\d|3[01])(?:\.\d{1,3}){2}|[\w.-]+\.(?:internal|local|corp|lan))(?::\d+)?[^\s"']*`)},
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA)[A-Z0-9]{16}\b`)},
+	{"email", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{"hex-string", regexp.MustCompile(`\b[0-9a-fA-F]{32,}\b`)},
+	{"base64-string", regexp.MustCompile(`[A-Za-z0-9+/]{40,}={0,2}`)},
+}
+
+// Sources of a secret match
+const (
+	SourceDefaultValue = "defaultValue"
+	SourceDescription  = "description"
+)
+
+// SecretMatch is secret-looking text found in the schema.
+type SecretMatch struct {
+	// Path is Type.field, Type.field(arg) or Type.inputField.
+	Path    string
+	Source  string
+	Pattern string
+	// Text is the full default value or description, Start and End the byte
+	// offsets of the match within it.
+	Text       string
+	Start, End int
+}
+
+// Match returns the matched text.
+func (m SecretMatch) Match() string {
+	return m.Text[m.Start:m.End]
+}
+
+// ScanDefaults scans the default value and description of every argument and
+// input field, and the description of every type and field, for text matching
+// patterns. Matches are ordered by type name, then by position in the type.
+func ScanDefaults(s *types.GQLSchema, patterns []Pattern) []SecretMatch {
+	var matches []SecretMatch
+	scan := func(path, source, text string) {
+		if text != "" {
+			matches = append(matches, scanText(path, source, text, patterns)...)
+		}
+	}
+	scanInput := func(path string, v types.InputValue) {
+		scan(path, SourceDefaultValue, v.DefaultValue)
+		scan(path, SourceDescription, v.Description)
+	}
+
+	names := make([]string, 0, len(s.Types))
+	for name := range s.Types {
+		if !strings.HasPrefix(name, "__") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := s.Types[name]
+		scan(t.Name, SourceDescription, t.Description)
+		for _, f := range t.Fields {
+			path := t.Name + "." + f.Name
+			scan(path, SourceDescription, f.Description)
+			for _, a := range f.Args {
+				scanInput(path+"("+a.Name+")", a)
+			}
+		}
+		for _, f := range t.InputFields {
+			scanInput(t.Name+"."+f.Name, f)
+		}
+	}
+	return matches
+}
+
+// scanText returns the non-overlapping matches of patterns in text, in order of position.
+func scanText(path, source, text string, patterns []Pattern) []SecretMatch {
+	var found []SecretMatch
+	taken := func(start, end int) bool {
+		for _, m := range found {
+			if start < m.End && m.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range patterns {
+		for _, loc := range p.Regexp.FindAllStringIndex(text, -1) {
+			if taken(loc[0], loc[1]) {
+				continue
+			}
+			found = append(found, SecretMatch{Path: path, Source: source, Pattern: p.Name, Text: text, Start: loc[0], End: loc[1]})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Start < found[j].Start })
+	return found
+}
@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"regexp"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// Selection projections of the selection sets of generated operations
+const (
+	// SelectionMinimal selects only the id-like fields of the returned type and __typename
+	SelectionMinimal = "minimal"
+	// SelectionStandard selects the fields reachable within the depth limit
+	SelectionStandard = "standard"
+	// SelectionFull is SelectionStandard with optional nested objects expanded one level deeper
+	SelectionFull = "full"
+)
+
+// ValidSelection reports whether selection is a supported projection
+func ValidSelection(selection string) bool {
+	return selection == SelectionMinimal || selection == SelectionStandard || selection == SelectionFull
+}
+
+// idNamePattern matches the names of identifier fields, such as id, uuid,
+// userId or user_id.
+var idNamePattern = regexp.MustCompile(`^(?i:_?(id|uuid|guid))$|[a-z0-9](Id|ID|Uuid|UUID)$|_(id|ID|uuid|UUID)$`)
+
+// isIDField reports whether f is a scalar identifying the object it belongs
+// to: a field of type ID or with an identifier name.
+func isIDField(f IndexedField) bool {
+	if f.Named.Kind != types.SCALAR {
+		return false
+	}
+	return f.Named.Name == "ID" || idNamePattern.MatchString(f.Name)
+}
+
+// idSelection selects the id-like fields among fields followed by __typename.
+func idSelection(fields []IndexedField, indent string) string {
+	selection := ""
+	for _, f := range fields {
+		if isIDField(f) {
+			selection += "\n" + indent + f.Name
+		}
+	}
+	return selection + "\n" + indent + "__typename"
+}
+
+// hasRequiredArgs reports whether f cannot be selected without arguments.
+func hasRequiredArgs(f *types.Field) bool {
+	for _, arg := range f.Args {
+		if arg.Type.Kind == types.NON_NULL && arg.DefaultValue == "" {
+			return true
+		}
+	}
+	return false
+}
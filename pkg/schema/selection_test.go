@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// projectionSchema returns a schema whose user query reaches a required
+// account and an optional profile holding an optional avatar.
+func projectionSchema() *types.GQLSchema {
+	scalar := func(name string) types.TypeRef { return types.TypeRef{Kind: types.SCALAR, Name: name} }
+	object := func(name string) types.TypeRef { return types.TypeRef{Kind: types.OBJECT, Name: name} }
+	required := func(t types.TypeRef) types.TypeRef { return types.TypeRef{Kind: types.NON_NULL, OfType: &t} }
+	query := types.Type{Kind: types.OBJECT, Name: "Query", Fields: []types.Field{{Name: "user", Type: object("User")}}}
+	return &types.GQLSchema{Query: &query, Types: map[string]types.Type{
+		"Query": query,
+		"User": {Kind: types.OBJECT, Name: "User", Fields: []types.Field{
+			{Name: "id", Type: required(scalar("ID"))},
+			{Name: "name", Type: scalar("String")},
+			{Name: "accountId", Type: scalar("String")},
+			{Name: "account", Type: required(object("Account"))},
+			{Name: "profile", Type: object("Profile")},
+		}},
+		"Account": {Kind: types.OBJECT, Name: "Account", Fields: []types.Field{
+			{Name: "iban", Type: scalar("String")},
+			{Name: "owner", Type: required(object("User"))},
+		}},
+		"Profile": {Kind: types.OBJECT, Name: "Profile", Fields: []types.Field{
+			{Name: "bio", Type: scalar("String")},
+			{Name: "avatar", Type: object("Image")},
+		}},
+		"Image":  {Kind: types.OBJECT, Name: "Image", Fields: []types.Field{{Name: "url", Type: scalar("String")}}},
+		"ID":     {Kind: types.SCALAR, Name: "ID"},
+		"String": {Kind: types.SCALAR, Name: "String"},
+	}}
+}
+
+// TestSelectionProjections generates the user query of one fixture with each
+// projection, as the catalog document and as the executable.
+func TestSelectionProjections(t *testing.T) {
+	tests := []struct {
+		selection            string
+		document, executable []string
+	}{
+		{
+			// Only the identifier fields, whatever their type.
+			selection:  SelectionMinimal,
+			document:   []string{"    id", "    accountId", "    __typename"},
+			executable: []string{"    id", "    accountId", "    __typename"},
+		},
+		{
+			// The objects at the depth limit are selected by name.
+			selection: SelectionStandard,
+			document: []string{
+				"    id", "    name", "    accountId",
+				"    account { ", "        iban", "        owner", "    }",
+				"    profile { ", "        bio", "        avatar", "    }",
+			},
+			executable: []string{"    id", "    name", "    accountId"},
+		},
+		{
+			// The optional profile spends the extra level, so its avatar is
+			// expanded; the required account is not.
+			selection: SelectionFull,
+			document: []string{
+				"    id", "    name", "    accountId",
+				"    account { ", "        iban", "        owner", "    }",
+				"    profile { ", "        bio", "        avatar { ", "            url", "        }", "    }",
+			},
+			executable: []string{"    id", "    name", "    accountId", "    profile {", "      bio", "    }"},
+		},
+	}
+	s := projectionSchema()
+	catalog := make(map[string]CatalogOperation)
+	for _, tt := range tests {
+		catalog[tt.selection] = BuildCatalog(s, CatalogOptions{MaxDepth: 2, Selection: tt.selection}).Operations[0]
+	}
+	for _, tt := range tests {
+		t.Run(tt.selection, func(t *testing.T) {
+			wantDocument := "query user {\n  user {\n  " + strings.Join(tt.document, "\n  ") + "\n  }\n}"
+			if got, err := GenerateQuery(s, "user", 2, tt.selection); err != nil || got != wantDocument {
+				t.Errorf("GenerateQuery() = %v\n%s\nwant\n%s", err, got, wantDocument)
+			}
+			wantExecutable := "query user {\n  user {\n" + strings.Join(tt.executable, "\n") + "\n  }\n}"
+			if got, err := GenerateMinimalQuery(s, "user", tt.selection); err != nil || got != wantExecutable {
+				t.Errorf("GenerateMinimalQuery() = %v\n%s\nwant\n%s", err, got, wantExecutable)
+			}
+			op := catalog[tt.selection]
+			if op.Selection != tt.selection || op.Document != wantDocument || op.Executable != wantExecutable {
+				t.Errorf("catalog operation = %+v", op)
+			}
+		})
+	}
+	if catalog[SelectionMinimal].Hash == catalog[SelectionStandard].Hash || catalog[SelectionStandard].Hash == catalog[SelectionFull].Hash {
+		t.Error("the projections share an executable hash")
+	}
+	if op := BuildCatalog(s, CatalogOptions{MaxDepth: 2}).Operations[0]; op.Selection != SelectionStandard || op.Document != catalog[SelectionStandard].Document {
+		t.Errorf("the default projection is %q, want %q", op.Selection, SelectionStandard)
+	}
+}
@@ -0,0 +1,41 @@
+package schema
+
+import (
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/data"
+)
+
+// IsSensitiveName reports whether a field or argument name contains one of the
+// fragments of the sensitive-fields dataset, which usually identify secrets or
+// personal data. Matching is case-insensitive and ignores underscores and dashes.
+func IsSensitiveName(name string) bool {
+	normalized := strings.ToLower(name)
+	normalized = strings.NewReplacer("_", "", "-", "").Replace(normalized)
+	for _, p := range data.SensitiveFields() {
+		if strings.Contains(normalized, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// destructivePrefixes start the names of mutations that delete or revoke data.
+var destructivePrefixes = []string{
+	"delete", "remove", "drop", "destroy", "purge", "truncate", "wipe", "erase",
+	"clear", "reset", "revoke", "ban", "disable", "deactivate", "cancel", "kill",
+}
+
+// IsDestructiveName reports whether a mutation field name starts with a verb
+// such as delete, drop, reset or revoke. Matching is case-insensitive and
+// ignores underscores and dashes.
+func IsDestructiveName(name string) bool {
+	normalized := strings.ToLower(name)
+	normalized = strings.NewReplacer("_", "", "-", "").Replace(normalized)
+	for _, p := range destructivePrefixes {
+		if strings.HasPrefix(normalized, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// PlaceholderValues maps built-in scalar names to the value substituted for them
+// when a document needs a concrete argument or variable value.
+var PlaceholderValues = map[string]interface{}{
+	"ID":      "1",
+	"String":  "test",
+	"Int":     1,
+	"Float":   1.5,
+	"Boolean": true,
+}
+
+// defaultPlaceholder is used for custom scalars and types missing from the schema.
+const defaultPlaceholder = "test"
+
+// PaginationArgs lists argument names that limit the size of a returned list.
+var PaginationArgs = map[string]bool{
+	"first": true,
+	"last":  true,
+	"limit": true,
+	"take":  true,
+}
+
+// maxPlaceholderDepth bounds recursion through self-referencing input objects.
+const maxPlaceholderDepth = 3
+
+// PlaceholderLiteral returns a GraphQL literal suitable for the given argument type.
+func PlaceholderLiteral(s *types.GQLSchema, tr *types.TypeRef) string {
+	return placeholderLiteral(s, tr, 0)
+}
+
+func placeholderLiteral(s *types.GQLSchema, tr *types.TypeRef, depth int) string {
+	switch tr.Kind {
+	case types.NON_NULL:
+		return placeholderLiteral(s, tr.OfType, depth)
+	case types.LIST:
+		return "[" + placeholderLiteral(s, tr.OfType, depth) + "]"
+	}
+
+	if v, ok := PlaceholderValues[tr.Name]; ok {
+		literal, _ := json.Marshal(v)
+		return string(literal)
+	}
+
+	typeDef, ok := s.Types[tr.Name]
+	if !ok {
+		return strconv.Quote(defaultPlaceholder)
+	}
+	switch typeDef.Kind {
+	case types.ENUM:
+		if len(typeDef.EnumValues) > 0 {
+			return typeDef.EnumValues[0].Name
+		}
+	case types.INPUT_OBJECT:
+		if depth >= maxPlaceholderDepth {
+			return "{}"
+		}
+		var parts []string
+		for _, f := range typeDef.InputFields {
+			// Only required input fields are filled to keep documents small.
+			if f.Type.Kind != types.NON_NULL {
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s: %s", f.Name, placeholderLiteral(s, &f.Type, depth+1)))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return strconv.Quote(defaultPlaceholder)
+}
+
+// GenerateMinimalQuery is Index.GenerateMinimalQuery on an index of s built
+// for the call.
+func GenerateMinimalQuery(s *types.GQLSchema, fieldName, selection string) (string, error) {
+	return NewIndex(s).GenerateMinimalQuery(fieldName, selection)
+}
+
+// GenerateMinimalQuery builds an executable query for the named root field.
+// Required arguments receive placeholder literals, pagination arguments are set
+// to 1 and the selection only includes the scalar fields of the returned type,
+// as projected by selection (see minimalSelection).
+func (x *Index) GenerateMinimalQuery(fieldName, selection string) (string, error) {
+	return x.generateMinimalOperation(KindQuery, fieldName, "", selection)
+}
+
+// GenerateMinimalMutation is Index.GenerateMinimalMutation on an index of s
+// built for the call.
+func GenerateMinimalMutation(s *types.GQLSchema, fieldName, selection string) (string, error) {
+	return NewIndex(s).GenerateMinimalMutation(fieldName, selection)
+}
+
+// GenerateMinimalMutation is GenerateMinimalQuery for mutation fields.
+func (x *Index) GenerateMinimalMutation(fieldName, selection string) (string, error) {
+	return x.generateMinimalOperation(KindMutation, fieldName, "", selection)
+}
+
+// ProbeVariable is the variable GenerateArgumentProbe binds the probed argument to.
+const ProbeVariable = "value"
+
+// GenerateArgumentProbe is Index.GenerateArgumentProbe on an index of s built
+// for the call.
+func GenerateArgumentProbe(s *types.GQLSchema, fieldName, argName string) (string, error) {
+	return NewIndex(s).GenerateArgumentProbe(fieldName, argName)
+}
+
+// GenerateArgumentProbe is GenerateMinimalQuery with the argument argName bound
+// to the variable $value, so that callers can send probe values in it.
+func (x *Index) GenerateArgumentProbe(fieldName, argName string) (string, error) {
+	return x.generateMinimalOperation(KindQuery, fieldName, argName, SelectionStandard)
+}
+
+// generateMinimalOperation renders the minimal operation of fieldName. When
+// probeArg is set, that argument takes the variable ProbeVariable.
+func (x *Index) generateMinimalOperation(kind, fieldName, probeArg, selection string) (string, error) {
+	if !x.HasRoot(kind) {
+		return "", fmt.Errorf("schema has no %s type", kind)
+	}
+	rootField, ok := x.Operation(kind, fieldName)
+	if !ok {
+		return "", fmt.Errorf("field '%s' not found in %s type", fieldName, kind)
+	}
+
+	var args []string
+	variables := ""
+	for _, arg := range rootField.Args {
+		if probeArg != "" && arg.Name == probeArg {
+			args = append(args, fmt.Sprintf("%s: $%s", arg.Name, ProbeVariable))
+			variables = fmt.Sprintf("($%s: %s)", ProbeVariable, arg.Type.String())
+			continue
+		}
+		underlying := unwrapType(&arg.Type)
+		if PaginationArgs[arg.Name] && underlying.Name == "Int" {
+			args = append(args, arg.Name+": 1")
+			continue
+		}
+		if arg.Type.Kind == types.NON_NULL {
+			args = append(args, fmt.Sprintf("%s: %s", arg.Name, PlaceholderLiteral(x.s, &arg.Type)))
+		}
+	}
+
+	if probeArg != "" && variables == "" {
+		return "", fmt.Errorf("field '%s' has no argument '%s'", fieldName, probeArg)
+	}
+
+	doc := fmt.Sprintf("%s %s%s {\n  %s", kind, fieldName, variables, fieldName)
+	if len(args) > 0 {
+		doc += "(" + strings.Join(args, ", ") + ")"
+	}
+	if set := x.minimalSelection(rootField.Named.Name, "    ", selection); set != "" {
+		doc += " {" + set + "\n  }"
+	}
+	return doc + "\n}", nil
+}
+
+// minimalSelection selects the scalar and enum fields of an object type, falling
+// back to __typename when it has none. It returns "" for leaf types. With
+// SelectionMinimal only its id-like fields and __typename are selected, and
+// SelectionFull adds the scalar fields of the optional objects it holds that
+// take no required arguments.
+func (x *Index) minimalSelection(typeName, indent, selection string) string {
+	typeDef, ok := x.Type(typeName)
+	if !ok {
+		return ""
+	}
+	switch typeDef.Kind {
+	case types.OBJECT, types.INTERFACE, types.UNION:
+	default:
+		return ""
+	}
+
+	fields := x.Fields(typeName)
+	if selection == SelectionMinimal {
+		return idSelection(fields, indent)
+	}
+
+	set := ""
+	for _, f := range fields {
+		switch f.Named.Kind {
+		case types.SCALAR, types.ENUM:
+			set += "\n" + indent + f.Name
+		case types.OBJECT:
+			if selection != SelectionFull || f.Type.Kind == types.NON_NULL || hasRequiredArgs(f.Field) {
+				continue
+			}
+			if nested := x.minimalSelection(f.Named.Name, indent+"  ", SelectionStandard); nested != "" {
+				set += "\n" + indent + f.Name + " {" + nested + "\n" + indent + "}"
+			}
+		}
+	}
+	if set == "" {
+		set = "\n" + indent + "__typename"
+	}
+	return set
+}
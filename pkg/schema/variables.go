@@ -0,0 +1,98 @@
+package schema
+
+import (
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// SynthesizedVariable records a placeholder value filled in for a missing variable.
+type SynthesizedVariable struct {
+	Name  string
+	Type  string
+	Value interface{}
+}
+
+// SynthesizeVariables returns the supplied variables completed with placeholders
+// for every required variable of defs that was not supplied. Supplied values always
+// win, and nullable variables or ones with a default value are left out so the
+// server applies its own defaults. s may be nil; it is only used to resolve enum
+// and input object types.
+func SynthesizeVariables(s *types.GQLSchema, defs []*gql.VariableDefinition, supplied map[string]interface{}) (map[string]interface{}, []SynthesizedVariable) {
+	merged := make(map[string]interface{}, len(supplied)+len(defs))
+	for k, v := range supplied {
+		merged[k] = v
+	}
+
+	var synthesized []SynthesizedVariable
+	for _, def := range defs {
+		if _, ok := merged[def.Name]; ok {
+			continue
+		}
+		if !def.Type.NonNull || def.DefaultValue != nil {
+			continue
+		}
+		value := placeholderJSON(s, def.Type, 0)
+		merged[def.Name] = value
+		synthesized = append(synthesized, SynthesizedVariable{Name: def.Name, Type: def.Type.String(), Value: value})
+	}
+	return merged, synthesized
+}
+
+// placeholderJSON builds a JSON-compatible placeholder value for a variable type.
+func placeholderJSON(s *types.GQLSchema, t *gql.Type, depth int) interface{} {
+	if t.Elem != nil {
+		return []interface{}{placeholderJSON(s, t.Elem, depth)}
+	}
+	if v, ok := PlaceholderValues[t.Name]; ok {
+		return v
+	}
+	if s == nil {
+		return defaultPlaceholder
+	}
+	typeDef, ok := s.Types[t.Name]
+	if !ok {
+		return defaultPlaceholder
+	}
+	return typePlaceholderJSON(s, typeDef, depth)
+}
+
+// typePlaceholderJSON builds a placeholder for an enum or input object type definition.
+func typePlaceholderJSON(s *types.GQLSchema, typeDef types.Type, depth int) interface{} {
+	switch typeDef.Kind {
+	case types.ENUM:
+		if len(typeDef.EnumValues) > 0 {
+			return typeDef.EnumValues[0].Name
+		}
+	case types.INPUT_OBJECT:
+		obj := make(map[string]interface{})
+		if depth >= maxPlaceholderDepth {
+			return obj
+		}
+		for _, f := range typeDef.InputFields {
+			if f.Type.Kind != types.NON_NULL {
+				continue
+			}
+			obj[f.Name] = typeRefPlaceholderJSON(s, &f.Type, depth+1)
+		}
+		return obj
+	}
+	return defaultPlaceholder
+}
+
+// typeRefPlaceholderJSON is placeholderJSON for introspection type references.
+func typeRefPlaceholderJSON(s *types.GQLSchema, tr *types.TypeRef, depth int) interface{} {
+	switch tr.Kind {
+	case types.NON_NULL:
+		return typeRefPlaceholderJSON(s, tr.OfType, depth)
+	case types.LIST:
+		return []interface{}{typeRefPlaceholderJSON(s, tr.OfType, depth)}
+	}
+	if v, ok := PlaceholderValues[tr.Name]; ok {
+		return v
+	}
+	typeDef, ok := s.Types[tr.Name]
+	if !ok {
+		return defaultPlaceholder
+	}
+	return typePlaceholderJSON(s, typeDef, depth)
+}
@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+)
+
+// variablesSchema has an enum, a custom scalar and input objects, one of them
+// referencing itself.
+const variablesSchema = `{"data":{"__schema":{
+	"queryType":{"name":"Query"},
+	"types":[
+		{"kind":"OBJECT","name":"Query","fields":[{"name":"a","args":[],"type":{"kind":"SCALAR","name":"String"}}]},
+		{"kind":"ENUM","name":"Role","enumValues":[{"name":"ADMIN"},{"name":"USER"}]},
+		{"kind":"SCALAR","name":"DateTime"},
+		{"kind":"INPUT_OBJECT","name":"UserInput","inputFields":[
+			{"name":"name","type":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"String"}}},
+			{"name":"age","type":{"kind":"SCALAR","name":"Int"}},
+			{"name":"role","type":{"kind":"NON_NULL","ofType":{"kind":"ENUM","name":"Role"}}},
+			{"name":"tags","type":{"kind":"NON_NULL","ofType":{"kind":"LIST","ofType":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"ID"}}}}}
+		]},
+		{"kind":"INPUT_OBJECT","name":"Filter","inputFields":[
+			{"name":"field","type":{"kind":"NON_NULL","ofType":{"kind":"SCALAR","name":"String"}}},
+			{"name":"and","type":{"kind":"NON_NULL","ofType":{"kind":"INPUT_OBJECT","name":"Filter"}}}
+		]},
+		{"kind":"SCALAR","name":"String"},
+		{"kind":"SCALAR","name":"Int"},
+		{"kind":"SCALAR","name":"ID"}
+	]
+}}}`
+
+// definitions returns the variable definitions of the operations of document.
+func definitions(t *testing.T, document string) []*gql.VariableDefinition {
+	t.Helper()
+	doc, err := gql.Parse(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var defs []*gql.VariableDefinition
+	for _, op := range doc.Operations {
+		defs = append(defs, op.VariableDefinitions...)
+	}
+	return defs
+}
+
+func TestSynthesizeVariables(t *testing.T) {
+	s, err := Parse([]byte(variablesSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		document string
+		supplied map[string]interface{}
+		want     map[string]interface{}
+		// synthesized are the names of the variables filled with placeholders.
+		synthesized []string
+	}{
+		{
+			name:        "required scalars",
+			document:    `query Q($id: ID!, $n: Int!, $f: Float!, $b: Boolean!, $s: String!) { a }`,
+			want:        map[string]interface{}{"id": "1", "n": 1, "f": 1.5, "b": true, "s": "test"},
+			synthesized: []string{"id", "n", "f", "b", "s"},
+		},
+		{
+			name:     "optional and defaulted variables are left out",
+			document: `query Q($id: ID, $n: Int! = 10, $list: [ID!]) { a }`,
+			want:     map[string]interface{}{},
+		},
+		{
+			name:        "lists",
+			document:    `query Q($ids: [ID!]!, $matrix: [[Int]!]!, $maybe: [String]!) { a }`,
+			want:        map[string]interface{}{"ids": []interface{}{"1"}, "matrix": []interface{}{[]interface{}{1}}, "maybe": []interface{}{"test"}},
+			synthesized: []string{"ids", "matrix", "maybe"},
+		},
+		{
+			name:     "input object with its required fields only",
+			document: `mutation M($input: UserInput!) { a }`,
+			want: map[string]interface{}{"input": map[string]interface{}{
+				"name": "test", "role": "ADMIN", "tags": []interface{}{"1"},
+			}},
+			synthesized: []string{"input"},
+		},
+		{
+			name:        "enum and custom scalar",
+			document:    `query Q($role: Role!, $at: DateTime!, $unknown: Missing!) { a }`,
+			want:        map[string]interface{}{"role": "ADMIN", "at": "test", "unknown": "test"},
+			synthesized: []string{"role", "at", "unknown"},
+		},
+		{
+			name:     "self-referencing input object is bounded",
+			document: `query Q($filter: Filter!) { a }`,
+			want: map[string]interface{}{"filter": map[string]interface{}{
+				"field": "test",
+				"and": map[string]interface{}{
+					"field": "test",
+					"and": map[string]interface{}{
+						"field": "test",
+						"and":   map[string]interface{}{},
+					},
+				},
+			}},
+			synthesized: []string{"filter"},
+		},
+		{
+			name:        "supplied values win",
+			document:    `query Q($id: ID!, $input: UserInput!, $extra: Int) { a }`,
+			supplied:    map[string]interface{}{"id": "42", "input": nil, "other": "kept"},
+			want:        map[string]interface{}{"id": "42", "input": nil, "other": "kept"},
+			synthesized: nil,
+		},
+		{
+			name:        "partial supply",
+			document:    `query Q($id: ID!, $n: Int!) { a }`,
+			supplied:    map[string]interface{}{"id": "42"},
+			want:        map[string]interface{}{"id": "42", "n": 1},
+			synthesized: []string{"n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, synthesized := SynthesizeVariables(s, definitions(t, tt.document), tt.supplied)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("variables = %#v, want %#v", got, tt.want)
+			}
+			var names []string
+			for _, v := range synthesized {
+				names = append(names, v.Name)
+				if !reflect.DeepEqual(v.Value, got[v.Name]) {
+					t.Errorf("synthesized $%s = %#v, merged %#v", v.Name, v.Value, got[v.Name])
+				}
+			}
+			if !reflect.DeepEqual(names, tt.synthesized) {
+				t.Errorf("synthesized %q, want %q", names, tt.synthesized)
+			}
+		})
+	}
+}
+
+func TestSynthesizeVariablesWithoutSchema(t *testing.T) {
+	got, synthesized := SynthesizeVariables(nil, definitions(t, `query Q($input: UserInput!, $ids: [ID!]!) { a }`), nil)
+	want := map[string]interface{}{"input": "test", "ids": []interface{}{"1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("variables = %#v, want %#v", got, want)
+	}
+	if len(synthesized) != 2 || synthesized[0].Type != "UserInput!" || synthesized[1].Type != "[ID!]!" {
+		t.Errorf("synthesized = %+v, want the declared types", synthesized)
+	}
+}
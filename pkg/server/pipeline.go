@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/cli"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// ReportFile is the name of the report written into every scan directory.
+const ReportFile = "report.json"
+
+// Pipeline runs a single scan, writing its artifacts into dir.
+type Pipeline interface {
+	Scan(ctx context.Context, req ScanRequest, dir string) (*report.Report, error)
+}
+
+// AuditPipeline runs scans through the same audit as the command line.
+type AuditPipeline struct {
+	// MaxDepth is used for requests that do not set their own.
+	MaxDepth int
+	// Profile is the bounty profile the server enforces, recorded in every
+	// report. Enforcing it is up to the caller, see network.SetScope and
+	// checks.Forbid.
+	Profile *types.BountyProfile
+}
+
+// Scan audits req.Target, or every endpoint detected on it, and saves the
+// introspection dumps, operation catalogs and report into dir.
+func (p AuditPipeline) Scan(ctx context.Context, req ScanRequest, dir string) (*report.Report, error) {
+	var groups []string
+	if req.AuditDoS {
+		groups = append(groups, checks.GroupDoS)
+	}
+	if req.AuditWS {
+		groups = append(groups, checks.GroupWS)
+	}
+	if req.AuditInjection {
+		groups = append(groups, checks.GroupInjection)
+	}
+	selected, err := checks.SelectPreset(req.Preset, req.Checks, req.SkipChecks, groups...)
+	if err != nil {
+		return nil, err
+	}
+
+	maxDepth := req.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = p.MaxDepth
+	}
+	opts := cli.AuditOptions{
+		OutputFile: filepath.Join(dir, "introspection.json"),
+		Checks:     selected,
+		Preset:     req.Preset,
+		Whoami:     req.Whoami,
+		MaxDepth:   maxDepth,
+	}
+	// The audit may add to the headers, so every scan gets its own copy.
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range req.Headers {
+		headers[k] = v
+	}
+
+	// Like every watch iteration, each scan reports its own requests and
+	// network events rather than those of the scans before it.
+	network.ResetStats()
+	var rep *report.Report
+	if req.Detect {
+		rep, err = cli.DetectAndAudit(ctx, []string{req.Target}, headers, opts)
+		if err != nil {
+			return rep, err
+		}
+	} else {
+		rep = cli.AuditEndpoints(ctx, []string{req.Target}, headers, opts)
+	}
+	rep.Profile = p.Profile
+	if err := report.WriteJSON(rep, filepath.Join(dir, ReportFile)); err != nil {
+		return rep, fmt.Errorf("error writing report: %w", err)
+	}
+	return rep, nil
+}
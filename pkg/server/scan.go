@@ -0,0 +1,159 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// Scan statuses
+const (
+	StatusQueued   = "queued"
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// scanFile is the name of the persisted scan record inside the scan directory.
+const scanFile = "scan.json"
+
+// ScanRequest is the body of POST /scans.
+type ScanRequest struct {
+	Target string `json:"target"`
+	// Detect scans Target for GraphQL endpoints and audits every one found.
+	Detect     bool              `json:"detect,omitempty"`
+	Checks     string            `json:"checks,omitempty"`
+	SkipChecks string            `json:"skipChecks,omitempty"`
+	AuditDoS   bool              `json:"auditDos,omitempty"`
+	AuditWS    bool              `json:"auditWs,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	MaxDepth   int               `json:"maxDepth,omitempty"`
+	// Timeout bounds the scan, e.g. "5m". The server default applies when empty.
+	Timeout string `json:"timeout,omitempty"`
+	// AuditInjection sends time-based injection payloads with the default options.
+	AuditInjection bool `json:"auditInjection,omitempty"`
+	// Whoami is the query verifying Headers on each target before its audit.
+	Whoami string `json:"whoami,omitempty"`
+	// Preset is a check preset, 'safe' or 'aggressive'.
+	Preset string `json:"preset,omitempty"`
+}
+
+// Scan is a submitted scan and, once finished, its results.
+type Scan struct {
+	ID         string           `json:"id"`
+	Status     string           `json:"status"`
+	Request    ScanRequest      `json:"request"`
+	Error      string           `json:"error,omitempty"`
+	Endpoints  []string         `json:"endpoints,omitempty"`
+	Findings   []report.Finding `json:"findings"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	StartedAt  *time.Time       `json:"startedAt,omitempty"`
+	FinishedAt *time.Time       `json:"finishedAt,omitempty"`
+}
+
+// Artifact is a file written by a scan.
+type Artifact struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// Finished reports whether the scan reached a final status.
+func (s *Scan) Finished() bool {
+	return s.Status == StatusDone || s.Status == StatusFailed || s.Status == StatusCanceled
+}
+
+// newScanID returns a random identifier safe to use as a directory name.
+func newScanID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating scan id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// saveScan writes scan to dir/scanFile, replacing the file atomically.
+func saveScan(dir string, scan *Scan) error {
+	data, err := json.MarshalIndent(scan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling scan: %w", err)
+	}
+	if err := artifacts.WriteFile(filepath.Join(dir, scanFile), data, 0644); err != nil {
+		return fmt.Errorf("error writing scan: %w", err)
+	}
+	return nil
+}
+
+// loadScans reads the scans persisted below root. Scans that were queued or
+// running when the previous server stopped are marked as canceled.
+func loadScans(root string) (map[string]*Scan, error) {
+	scans := make(map[string]*Scan)
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return scans, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading scan directory: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		data, err := os.ReadFile(filepath.Join(dir, scanFile))
+		if err != nil {
+			continue
+		}
+		var scan Scan
+		if err := json.Unmarshal(data, &scan); err != nil || scan.ID != e.Name() {
+			continue
+		}
+		if !scan.Finished() {
+			scan.Status = StatusCanceled
+			scan.Error = "server stopped before the scan finished"
+			if err := saveScan(dir, &scan); err != nil {
+				return nil, err
+			}
+		}
+		scans[scan.ID] = &scan
+	}
+	return scans, nil
+}
+
+// listArtifacts returns the files of a scan directory, excluding the scan record.
+func listArtifacts(dir string) ([]Artifact, error) {
+	artifacts := []Artifact{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == scanFile || rel == scanFile+".tmp" {
+			return nil
+		}
+		artifacts = append(artifacts, Artifact{Name: filepath.ToSlash(rel), Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing artifacts: %w", err)
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Name < artifacts[j].Name })
+	return artifacts, nil
+}
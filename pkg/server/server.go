@@ -0,0 +1,408 @@
+// Package server exposes audits as a JSON HTTP API for running scans on demand
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/clock"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// TokenEnv is the environment variable holding the API token.
+const TokenEnv = "GRAPHSPECTER_API_TOKEN"
+
+// Defaults used for unset Config fields
+const (
+	DefaultWorkers     = 1
+	DefaultQueueSize   = 100
+	DefaultScanTimeout = 10 * time.Minute
+)
+
+// maxRequestSize bounds the body of POST /scans.
+const maxRequestSize = 1 << 20
+
+// Config controls a Server.
+type Config struct {
+	Pipeline Pipeline
+	// Workers is the number of scans run at the same time. The network
+	// stats and events an audit reports are kept for the whole process, so
+	// with more than one worker concurrent scans share them.
+	Workers int
+	// QueueSize is the number of scans that can wait for a worker.
+	QueueSize int
+	// Dir holds one directory of artifacts per scan.
+	Dir string
+	// Persist saves every scan record in its directory and reloads them on start.
+	Persist bool
+	// Token, when set, must be sent as "Authorization: Bearer <token>".
+	Token       string
+	ScanTimeout time.Duration
+}
+
+// Server keeps scans in memory and runs them on a fixed pool of workers.
+type Server struct {
+	cfg    Config
+	ctx    context.Context
+	cancel context.CancelFunc
+	queue  chan string
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	scans map[string]*Scan
+	// requests holds the unredacted requests of queued scans.
+	requests map[string]ScanRequest
+}
+
+// New creates the scan directory, loads persisted scans and starts the workers.
+func New(cfg Config) (*Server, error) {
+	if cfg.Pipeline == nil {
+		return nil, errors.New("server: no pipeline configured")
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.ScanTimeout <= 0 {
+		cfg.ScanTimeout = DefaultScanTimeout
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating scan directory: %w", err)
+	}
+
+	scans := make(map[string]*Scan)
+	if cfg.Persist {
+		loaded, err := loadScans(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		scans = loaded
+		logger.Info("Loaded %d scan(s) from %s", len(scans), cfg.Dir)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		queue:  make(chan string, cfg.QueueSize),
+		scans:  scans,
+
+		requests: make(map[string]ScanRequest),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s, nil
+}
+
+// Close cancels the running scans, waits for the workers to stop and marks
+// the scans still queued as canceled.
+func (s *Server) Close() {
+	s.cancel()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, scan := range s.scans {
+		if scan.Status == StatusQueued {
+			s.finish(scan, StatusCanceled, "server shut down before the scan started")
+		}
+	}
+}
+
+// Handler returns the HTTP handler of the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scans", s.handleScans)
+	mux.HandleFunc("/scans/", s.handleScan)
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the configured token.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.cfg.Token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.cfg.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleScans serves POST /scans and GET /scans.
+func (s *Server) handleScans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.createScan(w, r)
+	case http.MethodGet:
+		s.mu.Lock()
+		list := make([]Scan, 0, len(s.scans))
+		for _, scan := range s.scans {
+			summary := *scan
+			summary.Findings = nil
+			list = append(list, summary)
+		}
+		s.mu.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+		writeJSON(w, http.StatusOK, list)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleScan serves GET /scans/{id}, /scans/{id}/artifacts and /scans/{id}/artifacts/{name}.
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/scans/"), "/")
+	scan, ok := s.get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "scan not found")
+		return
+	}
+
+	dir := filepath.Join(s.cfg.Dir, id)
+	switch {
+	case rest == "":
+		writeJSON(w, http.StatusOK, scan)
+	case rest == "artifacts":
+		artifacts, err := listArtifacts(dir)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, artifacts)
+	case strings.HasPrefix(rest, "artifacts/"):
+		s.serveArtifact(w, r, dir, strings.TrimPrefix(rest, "artifacts/"))
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// serveArtifact sends one listed artifact of a scan directory.
+func (s *Server) serveArtifact(w http.ResponseWriter, r *http.Request, dir, name string) {
+	artifacts, err := listArtifacts(dir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Only names from the listing are served, which rules out path traversal.
+	for _, a := range artifacts {
+		if a.Name == name {
+			http.ServeFile(w, r, filepath.Join(dir, filepath.FromSlash(a.Name)))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "artifact not found")
+}
+
+// createScan validates a scan request and queues it.
+func (s *Server) createScan(w http.ResponseWriter, r *http.Request) {
+	var req ScanRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid scan request: "+err.Error())
+		return
+	}
+	if err := validateRequest(req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	headers, err := network.NormalizeHeaders("headers", req.Headers)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Headers = headers
+
+	id, err := newScanID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Credentials sent to the target are never returned or saved.
+	public := req
+	public.Headers, _ = redact.Headers(req.Headers)
+	scan := &Scan{ID: id, Status: StatusQueued, Request: public, Findings: []report.Finding{}, CreatedAt: clock.Now()}
+	if err := os.MkdirAll(filepath.Join(s.cfg.Dir, id), 0755); err != nil {
+		writeError(w, http.StatusInternalServerError, "error creating scan directory")
+		return
+	}
+
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	select {
+	case s.queue <- id:
+	default:
+		s.mu.Unlock()
+		os.RemoveAll(filepath.Join(s.cfg.Dir, id))
+		writeError(w, http.StatusServiceUnavailable, "scan queue is full")
+		return
+	}
+	s.scans[id] = scan
+	s.requests[id] = req
+	s.persist(scan)
+	snapshot := *scan
+	s.mu.Unlock()
+
+	logger.Info("Scan %s queued for %s", id, req.Target)
+	w.Header().Set("Location", "/scans/"+id)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// validateRequest checks the target and timeout of a scan request. A target
+// outside the scope of the shared client, such as the allowed hosts of a
+// bounty profile, is refused before the scan is queued.
+func validateRequest(req ScanRequest) error {
+	if req.Target == "" {
+		return errors.New("target is required")
+	}
+	u, err := url.Parse(req.Target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid target %q: expected an http or https URL", req.Target)
+	}
+	if err := network.CheckScope(req.Target); err != nil {
+		return fmt.Errorf("target %s refused: %w", req.Target, err)
+	}
+	if req.Timeout != "" {
+		if d, err := time.ParseDuration(req.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", req.Timeout)
+		}
+	}
+	return nil
+}
+
+// get returns a copy of the scan with the given id.
+func (s *Server) get(id string) (Scan, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scan, ok := s.scans[id]
+	if !ok {
+		return Scan{}, false
+	}
+	return *scan, true
+}
+
+// worker runs queued scans until the server is closed.
+func (s *Server) worker() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case id := <-s.queue:
+			s.run(id)
+		}
+	}
+}
+
+// run executes one scan through the pipeline and records its outcome.
+func (s *Server) run(id string) {
+	s.mu.Lock()
+	scan := s.scans[id]
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+	req := s.requests[id]
+	delete(s.requests, id)
+	timeout := s.cfg.ScanTimeout
+	if req.Timeout != "" {
+		timeout, _ = time.ParseDuration(req.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	defer cancel()
+	started := clock.Now()
+	scan.Status = StatusRunning
+	scan.StartedAt = &started
+	s.persist(scan)
+	s.mu.Unlock()
+
+	logger.Info("Scan %s started", id)
+	rep, err := s.cfg.Pipeline.Scan(ctx, req, filepath.Join(s.cfg.Dir, id))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rep != nil {
+		scan.Endpoints = rep.Endpoints
+		if rep.Findings != nil {
+			scan.Findings = rep.Findings
+		}
+	}
+	switch {
+	case s.ctx.Err() != nil:
+		s.finish(scan, StatusCanceled, "server shut down during the scan")
+	case err != nil:
+		s.finish(scan, StatusFailed, err.Error())
+	case ctx.Err() != nil:
+		s.finish(scan, StatusFailed, "scan timed out after "+timeout.String())
+	default:
+		s.finish(scan, StatusDone, "")
+	}
+	logger.Info("Scan %s %s with %d finding(s)", id, scan.Status, len(scan.Findings))
+}
+
+// finish records the final status of scan. The caller holds s.mu.
+func (s *Server) finish(scan *Scan, status, message string) {
+	finished := clock.Now()
+	scan.Status = status
+	scan.Error = message
+	scan.FinishedAt = &finished
+	s.persist(scan)
+}
+
+// persist saves scan when persistence is enabled. The caller holds s.mu.
+func (s *Server) persist(scan *Scan) {
+	if !s.cfg.Persist {
+		return
+	}
+	if err := saveScan(filepath.Join(s.cfg.Dir, scan.ID), scan); err != nil {
+		logger.Info("Error saving scan %s: %v", scan.ID, err)
+	}
+}
+
+// writeJSON sends v as an indented JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeError sends {"error": message}.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
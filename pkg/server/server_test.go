@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// countingPipeline counts the scans it runs and finds nothing.
+type countingPipeline struct{ scans int32 }
+
+func (p *countingPipeline) Scan(ctx context.Context, req ScanRequest, dir string) (*report.Report, error) {
+	atomic.AddInt32(&p.scans, 1)
+	return &report.Report{Endpoints: []string{req.Target}}, nil
+}
+
+// blockingPipeline runs every scan until its context is done.
+type blockingPipeline struct {
+	started chan struct{}
+	err     chan error
+}
+
+func (p *blockingPipeline) Scan(ctx context.Context, req ScanRequest, dir string) (*report.Report, error) {
+	close(p.started)
+	<-ctx.Done()
+	p.err <- ctx.Err()
+	return &report.Report{Endpoints: []string{req.Target}}, ctx.Err()
+}
+
+func TestCloseCancelsRunningScan(t *testing.T) {
+	pipeline := &blockingPipeline{started: make(chan struct{}), err: make(chan error, 1)}
+	s, err := New(Config{Pipeline: pipeline, Dir: t.TempDir(), Persist: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(s.Handler())
+	defer api.Close()
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		resp, err := http.Post(api.URL+"/scans", "application/json", strings.NewReader(`{"target":"http://127.0.0.1/graphql"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var scan Scan
+		json.NewDecoder(resp.Body).Decode(&scan)
+		resp.Body.Close()
+		ids = append(ids, scan.ID)
+	}
+	select {
+	case <-pipeline.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the scan never started")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return while a scan was running")
+	}
+	if err := <-pipeline.err; !errors.Is(err, context.Canceled) {
+		t.Errorf("the running scan's context ended with %v, want it canceled", err)
+	}
+
+	want := map[string]string{ids[0]: "server shut down during the scan", ids[1]: "server shut down before the scan started"}
+	for id, message := range want {
+		scan, _ := s.get(id)
+		if scan.Status != StatusCanceled || scan.Error != message || scan.FinishedAt == nil {
+			t.Errorf("scan %s: %s %q, want canceled with %q", id, scan.Status, scan.Error, message)
+		}
+		// The outcome is persisted for the next start.
+		loaded, err := loadScans(s.cfg.Dir)
+		if err != nil || loaded[id] == nil || loaded[id].Status != StatusCanceled {
+			t.Errorf("persisted scan %s = %+v, %v", id, loaded[id], err)
+		}
+	}
+}
+
+func TestOutOfScopeTargetIsRefused(t *testing.T) {
+	network.SetScope([]string{"api.acme.example"})
+	defer network.SetScope(nil)
+	pipeline := &countingPipeline{}
+	s, err := New(Config{Pipeline: pipeline, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(s.Handler())
+	defer api.Close()
+
+	post := func(target string) (int, string) {
+		body, _ := json.Marshal(ScanRequest{Target: target})
+		resp, err := http.Post(api.URL+"/scans", "application/json", strings.NewReader(string(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var answer struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&answer)
+		return resp.StatusCode, answer.Error
+	}
+
+	status, msg := post("https://other.example/graphql")
+	if status != http.StatusBadRequest || !strings.Contains(msg, "other.example is not in the allowed hosts") {
+		t.Errorf("out-of-scope target: %d %q", status, msg)
+	}
+	if status, _ := post("https://api.acme.example/graphql"); status != http.StatusAccepted {
+		t.Errorf("in-scope target: %d", status)
+	}
+	s.Close()
+	if n := atomic.LoadInt32(&pipeline.scans); n > 1 {
+		t.Errorf("%d scans ran, want the out-of-scope one refused before it was queued", n)
+	}
+	entries, _ := os.ReadDir(s.cfg.Dir)
+	if len(entries) != 1 {
+		t.Errorf("%d scan directories, want only the in-scope one", len(entries))
+	}
+}
+
+func TestAuditPipelineRecordsProfile(t *testing.T) {
+	var bounty int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Bug-Bounty") == "researcher@example.com" {
+			atomic.AddInt32(&bounty, 1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errors":[{"message":"introspection is disabled"}]}`))
+	}))
+	defer target.Close()
+
+	profile := &types.BountyProfile{Program: "acme", Headers: map[string]string{"X-Bug-Bounty": "researcher@example.com"}, AllowedHosts: []string{"127.0.0.1"}}
+	network.SetRequiredHeaders(profile.Headers)
+	defer network.SetRequiredHeaders(nil)
+
+	dir := t.TempDir()
+	rep, err := AuditPipeline{MaxDepth: 3, Profile: profile}.Scan(context.Background(), ScanRequest{Target: target.URL, Checks: "introspection"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Profile != profile {
+		t.Errorf("report profile = %+v", rep.Profile)
+	}
+	if atomic.LoadInt32(&bounty) == 0 {
+		t.Error("no request carried the profile headers")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		Profile *types.BountyProfile `json:"profile"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Profile == nil || saved.Profile.Program != "acme" {
+		t.Errorf("saved report profile = %+v, %v", saved.Profile, err)
+	}
+}
+
+// TestAuditPipelineResetsEventsPerScan scans a server that labels its JSON
+// responses text/plain, then scans it again once fixed, and checks that the
+// second report does not carry the finding of the first.
+func TestAuditPipelineResetsEventsPerScan(t *testing.T) {
+	var fixed atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fixed.Load() {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/plain")
+		}
+		w.Write([]byte(`{"errors":[{"message":"introspection is disabled"}]}`))
+	}))
+	defer target.Close()
+	defer network.ResetStats()
+
+	var found []bool
+	for i := 0; i < 2; i++ {
+		rep, err := AuditPipeline{MaxDepth: 3}.Scan(context.Background(), ScanRequest{Target: target.URL, Checks: "introspection"}, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		mismatch := false
+		for _, f := range rep.Findings {
+			mismatch = mismatch || f.ID == "incorrect-content-type"
+		}
+		found = append(found, mismatch)
+		fixed.Store(true)
+	}
+	if !found[0] || found[1] {
+		t.Errorf("incorrect-content-type reported by the scans before and after the fix = %v, want [true false]", found)
+	}
+}
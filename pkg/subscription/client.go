@@ -2,11 +2,14 @@
 package subscription
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"time"
 
+	"github.com/CyberRoute/graphspecter/pkg/network"
 	"github.com/gorilla/websocket"
 )
 
@@ -17,79 +20,206 @@ type WSMessage struct {
 	Payload json.RawMessage `json:"payload,omitempty"`
 }
 
-// SubscribeToQuery attempts to establish a subscription using both "subscribe" and "start" message types.
-// It returns the open WebSocket connection if one of the attempts is successful.
-func SubscribeToQuery(wsURL string, query string) (*websocket.Conn, error) {
-	msgTypes := []string{"subscribe", "start"}
-	var lastErr error
+// Subscription timeouts used when SubscribeOptions leaves them unset
+const (
+	DefaultHandshakeTimeout = 10 * time.Second
+	DefaultAckTimeout       = 10 * time.Second
+	DefaultReadTimeout      = 5 * time.Minute
+)
 
-	for _, msgType := range msgTypes {
-		// Connect to the WebSocket endpoint.
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to connect: %w", err)
-			continue
-		}
+// SubscribeOptions configures SubscribeToQueryWithContext, ListenWithContext
+// and SubscribeSSE.
+type SubscribeOptions struct {
+	// HandshakeTimeout bounds the WebSocket opening handshake, or the opening
+	// of an SSE stream.
+	HandshakeTimeout time.Duration
+	// AckTimeout is how long to wait for connection_ack after connection_init.
+	AckTimeout time.Duration
+	// ReadTimeout is how long Listen waits for the next server message. A
+	// negative value waits forever.
+	ReadTimeout time.Duration
+}
 
-		// Set a read deadline.
-		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+func (o SubscribeOptions) withDefaults() SubscribeOptions {
+	if o.HandshakeTimeout <= 0 {
+		o.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+	if o.AckTimeout <= 0 {
+		o.AckTimeout = DefaultAckTimeout
+	}
+	if o.ReadTimeout == 0 {
+		o.ReadTimeout = DefaultReadTimeout
+	}
+	return o
+}
 
-		// Send connection_init message.
-		initMsg := WSMessage{
-			Type:    "connection_init",
-			Payload: json.RawMessage(`{}`),
-		}
-		if err := conn.WriteJSON(initMsg); err != nil {
+// closeOnCancel closes conn when ctx is done, unblocking pending reads and
+// writes. The returned function stops the watch.
+func closeOnCancel(ctx context.Context, conn *websocket.Conn) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
 			conn.Close()
-			lastErr = fmt.Errorf("failed to send connection_init: %w", err)
-			continue
+		case <-stop:
 		}
+	}()
+	return func() { close(stop) }
+}
 
-		// Wait for connection_ack.
-		_, ackMsg, err := conn.ReadMessage()
+// dialClosingOnCancel returns a NetDialContext for a WebSocket dialer that
+// closes the connection it dials when ctx is done before done is called.
+// gorilla/websocket only applies the deadline of the context to the opening
+// handshake, so without it cancellation waits for the handshake timeout.
+func dialClosingOnCancel(ctx context.Context) (dial func(context.Context, string, string) (net.Conn, error), done func()) {
+	stop := make(chan struct{})
+	dial = func(dialCtx context.Context, proto, addr string) (net.Conn, error) {
+		conn, err := network.DialContext(dialCtx, proto, addr)
 		if err != nil {
-			conn.Close()
-			lastErr = fmt.Errorf("failed to read connection_ack: %w", err)
-			continue
+			return nil, err
 		}
-		log.Printf("Received ack using msgType %q: %s", msgType, string(ackMsg))
+		go func() {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+			case <-stop:
+			}
+		}()
+		return conn, nil
+	}
+	return dial, func() { close(stop) }
+}
+
+// SubscribeToQuery attempts to establish a subscription using both "subscribe" and "start" message types.
+// This is a backward compatibility wrapper for the context-aware version.
+func SubscribeToQuery(wsURL string, query string) (*websocket.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), network.DefaultTimeout)
+	defer cancel()
+	return SubscribeToQueryWithContext(ctx, wsURL, query, SubscribeOptions{})
+}
+
+// SubscribeToQueryWithContext attempts to establish a subscription using both
+// "subscribe" and "start" message types. It returns the open WebSocket
+// connection if one of the attempts is successful. Canceling ctx aborts the
+// handshake and the wait for connection_ack; once the function has returned,
+// ctx no longer affects the connection.
+func SubscribeToQueryWithContext(ctx context.Context, wsURL string, query string, opts SubscribeOptions) (*websocket.Conn, error) {
+	opts = opts.withDefaults()
+	msgTypes := []string{"subscribe", "start"}
+	var lastErr error
 
-		// Prepare the subscription payload.
-		subPayload := map[string]interface{}{
-			"query": query,
+	for _, msgType := range msgTypes {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("subscription canceled: %w", ctx.Err())
 		}
-		payloadBytes, err := json.Marshal(subPayload)
+		conn, err := subscribeOnce(ctx, wsURL, query, msgType, opts)
 		if err != nil {
-			conn.Close()
-			lastErr = fmt.Errorf("failed to marshal subscription payload: %w", err)
+			lastErr = err
 			continue
 		}
+		return conn, nil
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("subscription canceled: %w", ctx.Err())
+	}
+	return nil, fmt.Errorf("failed to send subscription message using both 'subscribe' and 'start': %w", lastErr)
+}
 
-		// Send the subscription message using the current msgType.
-		subMsg := WSMessage{
-			Type:    msgType,
-			Id:      "1", // Use a unique ID if managing multiple subscriptions.
-			Payload: payloadBytes,
+// subscribeOnce runs the connection_init handshake and subscribes with msgType.
+func subscribeOnce(ctx context.Context, wsURL, query, msgType string, opts SubscribeOptions) (*websocket.Conn, error) {
+	// Connect to the WebSocket endpoint.
+	dialer := *websocket.DefaultDialer
+	dialer.HandshakeTimeout = opts.HandshakeTimeout
+	dialer.TLSClientConfig = network.TLSConfig()
+	dial, handshakeDone := dialClosingOnCancel(ctx)
+	dialer.NetDialContext = dial
+	conn, _, err := dialer.DialContext(ctx, wsURL, nil)
+	handshakeDone()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("subscription canceled during the handshake: %w", ctx.Err())
 		}
-		if err := conn.WriteJSON(subMsg); err != nil {
-			conn.Close()
-			lastErr = fmt.Errorf("failed to send subscription message with type %q: %w", msgType, err)
-			continue
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	stop := closeOnCancel(ctx, conn)
+	defer stop()
+
+	// Send connection_init message.
+	initMsg := WSMessage{
+		Type:    "connection_init",
+		Payload: json.RawMessage(`{}`),
+	}
+	if err := conn.WriteJSON(initMsg); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send connection_init: %w", err)
+	}
+
+	// Wait for connection_ack.
+	conn.SetReadDeadline(time.Now().Add(opts.AckTimeout))
+	_, ackMsg, err := conn.ReadMessage()
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("subscription canceled while waiting for connection_ack: %w", ctx.Err())
 		}
+		return nil, fmt.Errorf("failed to read connection_ack: %w", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	log.Printf("Received ack using msgType %q: %s", msgType, string(ackMsg))
 
-		// If we've reached this point, the subscription message was sent successfully.
-		log.Printf("Subscription message sent successfully using msgType %q", msgType)
-		return conn, nil
+	// Prepare the subscription payload.
+	subPayload := map[string]interface{}{
+		"query": query,
 	}
-	return nil, fmt.Errorf("failed to send subscription message using both 'subscribe' and 'start': %w", lastErr)
+	payloadBytes, err := json.Marshal(subPayload)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to marshal subscription payload: %w", err)
+	}
+
+	// Send the subscription message using the current msgType.
+	subMsg := WSMessage{
+		Type:    msgType,
+		Id:      "1", // Use a unique ID if managing multiple subscriptions.
+		Payload: payloadBytes,
+	}
+	if err := conn.WriteJSON(subMsg); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send subscription message with type %q: %w", msgType, err)
+	}
+
+	network.RecordOperations(wsURL, query)
+
+	// If we've reached this point, the subscription message was sent successfully.
+	log.Printf("Subscription message sent successfully using msgType %q", msgType)
+	return conn, nil
 }
 
 // Listen continuously reads messages from the WebSocket connection and processes them.
+// This is a backward compatibility wrapper for the context-aware version.
 func Listen(conn *websocket.Conn) {
+	ListenWithContext(context.Background(), conn, SubscribeOptions{})
+}
+
+// ListenWithContext reads messages from the WebSocket connection and logs them
+// until the connection fails, no message arrives within opts.ReadTimeout, or
+// ctx is canceled. Cancellation closes the connection.
+func ListenWithContext(ctx context.Context, conn *websocket.Conn, opts SubscribeOptions) {
+	opts = opts.withDefaults()
+	stop := closeOnCancel(ctx, conn)
+	defer stop()
+
 	for {
+		if opts.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(opts.ReadTimeout))
+		}
 		_, message, err := conn.ReadMessage()
 		if err != nil {
-			log.Printf("Error reading message: %v", err)
+			if ctx.Err() != nil {
+				log.Printf("Subscription stopped: %v", ctx.Err())
+			} else {
+				log.Printf("Error reading message: %v", err)
+			}
 			break
 		}
 		log.Printf("Received message: %s", message)
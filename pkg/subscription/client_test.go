@@ -0,0 +1,262 @@
+package subscription
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/gerrors"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/gorilla/websocket"
+)
+
+// promptly is how long a canceled or timed out call may take to return.
+const promptly = time.Second
+
+// silentServer upgrades every connection and reads its messages but never
+// sends connection_ack. It counts the connections and reports on closed each
+// one the client closes.
+type silentServer struct {
+	*httptest.Server
+	conns  atomic.Int32
+	closed chan struct{}
+}
+
+func newSilentServer(t *testing.T) *silentServer {
+	t.Helper()
+	s := &silentServer{closed: make(chan struct{}, 8)}
+	upgrader := websocket.Upgrader{Subprotocols: []string{"graphql-transport-ws"}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.conns.Add(1)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				s.closed <- struct{}{}
+				return
+			}
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// stalledListener accepts TCP connections and never answers the opening
+// handshake. It returns the ws:// URL to dial.
+func stalledListener(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	})
+	return "ws://" + ln.Addr().String() + "/graphql"
+}
+
+func TestSubscribeCanceledWaitingForAck(t *testing.T) {
+	srv := newSilentServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	conn, err := SubscribeToQueryWithContext(ctx, wsURL(srv.Server), "subscription { events }", SubscribeOptions{AckTimeout: time.Minute})
+	elapsed := time.Since(start)
+	if conn != nil {
+		conn.Close()
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want the cancellation", err)
+	}
+	if elapsed > promptly {
+		t.Errorf("returned after %v, want a prompt return on cancellation", elapsed)
+	}
+	// The first attempt was abandoned and its connection closed; no second
+	// attempt is made once canceled.
+	select {
+	case <-srv.closed:
+	case <-time.After(promptly):
+		t.Error("the connection waiting for connection_ack was not closed")
+	}
+	if n := srv.conns.Load(); n != 1 {
+		t.Errorf("server saw %d connections, want 1", n)
+	}
+}
+
+func TestSubscribeCanceledDuringHandshake(t *testing.T) {
+	url := stalledListener(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := SubscribeToQueryWithContext(ctx, url, "subscription { events }", SubscribeOptions{HandshakeTimeout: time.Minute})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want the cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed > promptly {
+		t.Errorf("returned after %v, want a prompt return on cancellation", elapsed)
+	}
+}
+
+func TestSubscribeTimeouts(t *testing.T) {
+	t.Run("handshake", func(t *testing.T) {
+		url := stalledListener(t)
+		start := time.Now()
+		_, err := SubscribeToQueryWithContext(context.Background(), url, "subscription { events }", SubscribeOptions{HandshakeTimeout: 100 * time.Millisecond})
+		if err == nil || !strings.Contains(err.Error(), "failed to connect") {
+			t.Fatalf("error = %v, want the handshake to time out", err)
+		}
+		// Both message types are tried, each with its own handshake.
+		if elapsed := time.Since(start); elapsed > 2*100*time.Millisecond+promptly {
+			t.Errorf("returned after %v", elapsed)
+		}
+	})
+	t.Run("ack", func(t *testing.T) {
+		srv := newSilentServer(t)
+		start := time.Now()
+		_, err := SubscribeToQueryWithContext(context.Background(), wsURL(srv.Server), "subscription { events }", SubscribeOptions{AckTimeout: 100 * time.Millisecond})
+		if err == nil || !strings.Contains(err.Error(), "failed to read connection_ack") || errors.Is(err, context.Canceled) {
+			t.Fatalf("error = %v, want the wait for connection_ack to time out", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*100*time.Millisecond+promptly {
+			t.Errorf("returned after %v", elapsed)
+		}
+		if n := srv.conns.Load(); n != 2 {
+			t.Errorf("server saw %d connections, want one per message type", n)
+		}
+	})
+}
+
+func TestListenStopsOnCancel(t *testing.T) {
+	srv := protocolServer(t, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, err := SubscribeToQueryWithContext(ctx, wsURL(srv), "subscription { events }", SubscribeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Canceling the context of the subscribe call no longer affects conn; a
+	// second context governs Listen.
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ListenWithContext(listenCtx, conn, SubscribeOptions{ReadTimeout: -1})
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("Listen returned before it was canceled")
+	default:
+	}
+
+	stopListening()
+	select {
+	case <-done:
+	case <-time.After(promptly):
+		t.Fatal("Listen did not return promptly on cancellation")
+	}
+	if err := conn.WriteJSON(WSMessage{Type: "ping"}); err == nil {
+		t.Error("the connection is still open after Listen was canceled")
+	}
+}
+
+func TestListenReadTimeout(t *testing.T) {
+	srv := protocolServer(t, true)
+	conn, err := SubscribeToQueryWithContext(context.Background(), wsURL(srv), "subscription { events }", SubscribeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	start := time.Now()
+	ListenWithContext(context.Background(), conn, SubscribeOptions{ReadTimeout: 100 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > promptly {
+		t.Errorf("Listen returned after %v, want the 100ms read timeout", elapsed)
+	}
+}
+
+func TestSubscribeSendsOperation(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	got := make(chan WSMessage, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var init WSMessage
+		if conn.ReadJSON(&init) != nil || init.Type != "connection_init" {
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"connection_ack"}`))
+		var msg WSMessage
+		if conn.ReadJSON(&msg) == nil {
+			got <- msg
+		}
+	}))
+	defer srv.Close()
+	conn, err := SubscribeToQueryWithContext(context.Background(), wsURL(srv), "subscription { events }", SubscribeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	msg := <-got
+	var payload struct {
+		Query string `json:"query"`
+	}
+	json.Unmarshal(msg.Payload, &payload)
+	if msg.Type != "subscribe" || msg.Id != "1" || payload.Query != "subscription { events }" {
+		t.Errorf("server received %+v, want the subscription with id 1", msg)
+	}
+}
+
+func TestSubscribeOutOfScopeIsRefused(t *testing.T) {
+	s := newSilentServer(t)
+	wsURL := "ws://localhost:" + s.URL[strings.LastIndex(s.URL, ":")+1:] + "/graphql"
+	network.ResetStats()
+	network.SetScope([]string{"127.0.0.1"})
+	defer network.SetScope(nil)
+
+	_, err := SubscribeToQueryWithContext(context.Background(), wsURL, "subscription { events }", SubscribeOptions{})
+	if !errors.Is(err, gerrors.ErrOutOfScope) {
+		t.Fatalf("err = %v, want %v", err, gerrors.ErrOutOfScope)
+	}
+	if n := s.conns.Load(); n != 0 {
+		t.Errorf("the out-of-scope server accepted %d connection(s)", n)
+	}
+	// Both message types were attempted, each refused before dialing.
+	suppressed := network.SuppressedRequests()
+	if len(suppressed) != 1 || suppressed[0].Host != "localhost" || suppressed[0].Count != 2 {
+		t.Errorf("SuppressedRequests() = %+v", suppressed)
+	}
+}
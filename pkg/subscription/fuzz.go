@@ -0,0 +1,301 @@
+package subscription
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+	"github.com/gorilla/websocket"
+)
+
+// FuzzOptions configures FuzzProtocol.
+type FuzzOptions struct {
+	// Query is the subscription document sent by the scenarios.
+	Query string
+	// Headers are sent with the WebSocket handshake.
+	Headers map[string]string
+	// StepWait is how long to collect server frames after each sent frame.
+	StepWait time.Duration
+	// OversizedBytes is the size of the query sent by the oversized-payload scenario.
+	OversizedBytes int
+}
+
+// Weakness is a protocol-handling problem observed during a scenario.
+type Weakness struct {
+	ID       string
+	Title    string
+	Severity string
+}
+
+// FuzzResult is the outcome of one scripted scenario.
+type FuzzResult struct {
+	Scenario   string
+	Transcript []string
+	Weaknesses []Weakness
+	Error      string
+}
+
+// fuzzObservation is what a scenario saw on the wire.
+type fuzzObservation struct {
+	received  []string
+	closed    bool
+	closeCode int // websocket close code, or -1 when the connection dropped without one
+}
+
+// fuzzScenario is one entry of the scripted scenario table.
+type fuzzScenario struct {
+	name     string
+	frames   func(opts FuzzOptions) []string
+	classify func(obs *fuzzObservation, opts FuzzOptions) []Weakness
+}
+
+var (
+	// stackTracePattern matches stack frames from common server runtimes
+	stackTracePattern = regexp.MustCompile(`(?i)(\bat [^\s]+ \([^)]*:\d+:\d+\)|Traceback \(most recent call last\)|\.go:\d+|node_modules/|goroutine \d+ \[|Exception in thread|\.java:\d+\))`)
+
+	weaknessPreAck = Weakness{"ws-pre-ack-operation", "WebSocket server executes operations before connection_ack", report.SeverityMedium}
+	weaknessStack  = Weakness{"ws-stack-trace", "WebSocket error payloads leak stack traces", report.SeverityMedium}
+	weaknessCrash  = Weakness{"ws-connection-dropped", "WebSocket connection dropped without a close frame on malformed input", report.SeverityMedium}
+	weaknessDupIDs = Weakness{"ws-duplicate-ids", "WebSocket server accepts duplicate subscription ids", report.SeverityLow}
+	weaknessType   = Weakness{"ws-unknown-type-tolerated", "WebSocket server silently tolerates unknown message types", report.SeverityLow}
+	weaknessSize   = Weakness{"ws-oversized-frame", "WebSocket server accepts oversized operation frames", report.SeverityLow}
+)
+
+func initFrame() string { return `{"type":"connection_init","payload":{}}` }
+
+func subscribeFrame(msgType, id, query string) string {
+	payload, _ := json.Marshal(map[string]string{"query": query})
+	return fmt.Sprintf(`{"type":%q,"id":%q,"payload":%s}`, msgType, id, payload)
+}
+
+// scenarios is the scripted table of out-of-order and malformed protocol exchanges.
+var scenarios = []fuzzScenario{
+	{
+		name: "subscribe-before-init",
+		frames: func(opts FuzzOptions) []string {
+			return []string{subscribeFrame("subscribe", "1", opts.Query), subscribeFrame("start", "2", opts.Query)}
+		},
+		classify: func(obs *fuzzObservation, opts FuzzOptions) []Weakness {
+			if obs.hasType("next", "data") {
+				return []Weakness{weaknessPreAck}
+			}
+			return nil
+		},
+	},
+	{
+		name: "duplicate-ids",
+		frames: func(opts FuzzOptions) []string {
+			return []string{initFrame(), subscribeFrame("subscribe", "1", opts.Query), subscribeFrame("subscribe", "1", opts.Query)}
+		},
+		classify: func(obs *fuzzObservation, opts FuzzOptions) []Weakness {
+			if obs.hasType("connection_ack") && !obs.closed && !obs.hasType("error", "connection_error") {
+				return []Weakness{weaknessDupIDs}
+			}
+			return nil
+		},
+	},
+	{
+		name: "unknown-message-type",
+		frames: func(opts FuzzOptions) []string {
+			return []string{initFrame(), `{"type":"graphspecter_bogus","id":"1"}`}
+		},
+		classify: func(obs *fuzzObservation, opts FuzzOptions) []Weakness {
+			if obs.hasType("connection_ack") && !obs.closed && !obs.hasType("error", "connection_error") {
+				return []Weakness{weaknessType}
+			}
+			return nil
+		},
+	},
+	{
+		name: "invalid-json",
+		frames: func(opts FuzzOptions) []string {
+			return []string{initFrame(), `{"type":"subscribe","id":`}
+		},
+		classify: func(obs *fuzzObservation, opts FuzzOptions) []Weakness { return nil },
+	},
+	{
+		name: "oversized-payload",
+		frames: func(opts FuzzOptions) []string {
+			padding := "#" + strings.Repeat("A", opts.OversizedBytes) + "\n"
+			return []string{initFrame(), subscribeFrame("subscribe", "1", padding+opts.Query)}
+		},
+		classify: func(obs *fuzzObservation, opts FuzzOptions) []Weakness {
+			if obs.hasType("connection_ack") && !obs.closed && !obs.hasType("error", "connection_error") {
+				return []Weakness{weaknessSize}
+			}
+			return nil
+		},
+	},
+}
+
+// ScenarioCount is the number of WebSocket connections FuzzProtocol opens.
+func ScenarioCount() int {
+	return len(scenarios)
+}
+
+// FuzzProtocol runs every scripted scenario against wsURL, each on a fresh
+// connection, and classifies how the server handled it.
+func FuzzProtocol(ctx context.Context, wsURL string, opts FuzzOptions) []FuzzResult {
+	if opts.Query == "" {
+		opts.Query = "subscription { __typename }"
+	}
+	if opts.StepWait <= 0 {
+		opts.StepWait = time.Second
+	}
+	if opts.OversizedBytes <= 0 {
+		opts.OversizedBytes = 1 << 20
+	}
+
+	var results []FuzzResult
+	for _, sc := range scenarios {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, runScenario(ctx, wsURL, sc, opts))
+	}
+	return results
+}
+
+func runScenario(ctx context.Context, wsURL string, sc fuzzScenario, opts FuzzOptions) FuzzResult {
+	result := FuzzResult{Scenario: sc.name}
+
+	header := http.Header{}
+	header.Set("User-Agent", version.UserAgent())
+	for k, v := range opts.Headers {
+		header.Set(k, v)
+	}
+	dial, handshakeDone := dialClosingOnCancel(ctx)
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		Subprotocols:     []string{"graphql-transport-ws", "graphql-ws"},
+		TLSClientConfig:  network.TLSConfig(),
+		NetDialContext:   dial,
+	}
+	conn, _, err := dialer.DialContext(ctx, wsURL, header)
+	handshakeDone()
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to connect: %v", err)
+		return result
+	}
+	defer conn.Close()
+
+	frames := make(chan string, 64)
+	done := make(chan *fuzzObservation, 1)
+	go func() {
+		obs := &fuzzObservation{}
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				obs.closed = true
+				obs.closeCode = -1
+				// gorilla reports a connection dropped without a close
+				// frame as an abnormal closure, a code never sent on the wire.
+				if ce, ok := err.(*websocket.CloseError); ok && ce.Code != websocket.CloseAbnormalClosure {
+					obs.closeCode = ce.Code
+				}
+				close(frames)
+				done <- obs
+				return
+			}
+			frames <- string(msg)
+		}
+	}()
+
+	var received []string
+	open := true
+	for _, frame := range sc.frames(opts) {
+		if !open {
+			break
+		}
+		result.Transcript = append(result.Transcript, "> "+truncateFrame(frame))
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			result.Transcript = append(result.Transcript, fmt.Sprintf("! write failed: %v", err))
+			break
+		}
+		open = collectFrames(ctx, frames, opts.StepWait, &received, &result.Transcript)
+	}
+
+	conn.Close()
+	for msg := range frames {
+		received = append(received, msg)
+		result.Transcript = append(result.Transcript, "< "+truncateFrame(msg))
+	}
+	obs := <-done
+	obs.received = received
+	if open {
+		// We closed the connection ourselves; the server did not.
+		obs.closed = false
+	} else {
+		result.Transcript = append(result.Transcript, fmt.Sprintf("! connection closed (code %d)", obs.closeCode))
+	}
+
+	result.Weaknesses = sc.classify(obs, opts)
+	if obs.leaksStackTrace() {
+		result.Weaknesses = append(result.Weaknesses, weaknessStack)
+	}
+	if obs.closed && obs.closeCode == -1 {
+		result.Weaknesses = append(result.Weaknesses, weaknessCrash)
+	}
+	return result
+}
+
+// collectFrames gathers server frames for wait and reports whether the connection is still open.
+func collectFrames(ctx context.Context, frames <-chan string, wait time.Duration, received, transcript *[]string) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case msg, ok := <-frames:
+			if !ok {
+				return false
+			}
+			*received = append(*received, msg)
+			*transcript = append(*transcript, "< "+truncateFrame(msg))
+		case <-timer.C:
+			return true
+		case <-ctx.Done():
+			return true
+		}
+	}
+}
+
+// hasType reports whether any received frame has one of the given message types.
+func (o *fuzzObservation) hasType(types ...string) bool {
+	for _, raw := range o.received {
+		var msg WSMessage
+		if json.Unmarshal([]byte(raw), &msg) != nil {
+			continue
+		}
+		for _, t := range types {
+			if msg.Type == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (o *fuzzObservation) leaksStackTrace() bool {
+	for _, raw := range o.received {
+		if stackTracePattern.MatchString(raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateFrame keeps transcripts readable when oversized frames are involved.
+func truncateFrame(frame string) string {
+	const limit = 256
+	if len(frame) > limit {
+		return fmt.Sprintf("%s... (%d bytes)", frame[:limit], len(frame))
+	}
+	return frame
+}
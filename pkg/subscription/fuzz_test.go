@@ -0,0 +1,177 @@
+package subscription
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// protocolServer is a local graphql-transport-ws server. A strict one closes
+// the connection with a close code on every protocol violation; a lax one
+// executes operations before the ack, tolerates duplicate ids, unknown types
+// and oversized frames, and crashes with a stack trace on invalid JSON.
+func protocolServer(t *testing.T, strict bool) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{Subprotocols: []string{"graphql-transport-ws"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if strict {
+			conn.SetReadLimit(64 << 10)
+		}
+		reject := func(code int, reason string) {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+		}
+		acked := false
+		ids := map[string]bool{}
+		for {
+			_, raw, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg WSMessage
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				if strict {
+					reject(4400, "invalid message")
+					return
+				}
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"error","payload":[{"message":"SyntaxError: Unexpected end of JSON input\n    at JSON.parse (<anonymous>)\n    at parse (/app/node_modules/ws-server/index.js:42:17)"}]}`))
+				// The server process crashes: no close frame.
+				conn.UnderlyingConn().Close()
+				return
+			}
+			switch msg.Type {
+			case "connection_init":
+				acked = true
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"connection_ack"}`))
+			case "subscribe", "start":
+				if strict && !acked {
+					reject(4401, "Unauthorized")
+					return
+				}
+				if strict && ids[msg.Id] {
+					reject(4409, "Subscriber for "+msg.Id+" already exists")
+					return
+				}
+				ids[msg.Id] = true
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"next","id":"`+msg.Id+`","payload":{"data":{"__typename":"Subscription"}}}`))
+			default:
+				if strict {
+					reject(4400, "Invalid message type")
+					return
+				}
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// weaknessIDs returns the ids of the weaknesses found by each scenario.
+func weaknessIDs(results []FuzzResult) map[string][]string {
+	out := make(map[string][]string)
+	for _, r := range results {
+		ids := []string{}
+		for _, w := range r.Weaknesses {
+			ids = append(ids, w.ID)
+		}
+		sort.Strings(ids)
+		out[r.Scenario] = ids
+	}
+	return out
+}
+
+func TestFuzzProtocolClassification(t *testing.T) {
+	opts := FuzzOptions{StepWait: 200 * time.Millisecond, OversizedBytes: 128 << 10}
+	tests := []struct {
+		name   string
+		strict bool
+		want   map[string][]string
+	}{
+		{
+			name:   "strict",
+			strict: true,
+			want: map[string][]string{
+				"subscribe-before-init": {},
+				"duplicate-ids":         {},
+				"unknown-message-type":  {},
+				"invalid-json":          {},
+				"oversized-payload":     {},
+			},
+		},
+		{
+			name: "lax",
+			want: map[string][]string{
+				"subscribe-before-init": {"ws-pre-ack-operation"},
+				"duplicate-ids":         {"ws-duplicate-ids"},
+				"unknown-message-type":  {"ws-unknown-type-tolerated"},
+				"invalid-json":          {"ws-connection-dropped", "ws-stack-trace"},
+				"oversized-payload":     {"ws-oversized-frame"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := protocolServer(t, tt.strict)
+			results := FuzzProtocol(context.Background(), wsURL(srv), opts)
+			if len(results) != ScenarioCount() {
+				t.Fatalf("%d results, want one per scenario (%d)", len(results), ScenarioCount())
+			}
+			for _, r := range results {
+				if r.Error != "" {
+					t.Fatalf("%s: %s", r.Scenario, r.Error)
+				}
+			}
+			if got := weaknessIDs(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("weaknesses = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFuzzProtocolTranscripts(t *testing.T) {
+	srv := protocolServer(t, true)
+	results := FuzzProtocol(context.Background(), wsURL(srv), FuzzOptions{StepWait: 200 * time.Millisecond, OversizedBytes: 128 << 10})
+	for _, r := range results {
+		if len(r.Transcript) == 0 || !strings.HasPrefix(r.Transcript[0], "> ") {
+			t.Errorf("%s: transcript %q does not start with the first frame sent", r.Scenario, r.Transcript)
+		}
+		if r.Scenario == "subscribe-before-init" && !strings.Contains(strings.Join(r.Transcript, "\n"), "! connection closed (code 4401)") {
+			t.Errorf("transcript %q lacks the close code of the server", r.Transcript)
+		}
+		if r.Scenario == "oversized-payload" {
+			for _, line := range r.Transcript {
+				if len(line) > 512 {
+					t.Errorf("transcript line of %d bytes, want oversized frames truncated", len(line))
+				}
+			}
+		}
+	}
+}
+
+func TestFuzzProtocolUnreachable(t *testing.T) {
+	results := FuzzProtocol(context.Background(), "ws://127.0.0.1:1/graphql", FuzzOptions{StepWait: 10 * time.Millisecond})
+	if len(results) != ScenarioCount() {
+		t.Fatalf("%d results, want one per scenario", len(results))
+	}
+	for _, r := range results {
+		if !strings.Contains(r.Error, "failed to connect") || len(r.Weaknesses) != 0 {
+			t.Errorf("%s: error %q, weaknesses %v", r.Scenario, r.Error, r.Weaknesses)
+		}
+	}
+}
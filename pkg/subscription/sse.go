@@ -0,0 +1,415 @@
+package subscription
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+)
+
+// Modes of the graphql-sse protocol
+const (
+	// SSEDistinct opens one event stream per subscription, with the
+	// operation in the body of the request opening it.
+	SSEDistinct = "distinct"
+	// SSESingle reserves a stream with PUT, opens it with GET and sends the
+	// operation in a separate POST, all identified by a token.
+	SSESingle = "single"
+)
+
+// sseTokenHeader carries the token of a single connection mode stream.
+const sseTokenHeader = "X-GraphQL-Event-Stream-Token"
+
+// sseOperationID identifies the operation in single connection mode.
+const sseOperationID = "1"
+
+// maxSSEReconnects is how many attempts are made to open again a stream that
+// ended without a complete event.
+const maxSSEReconnects = 3
+
+// defaultSSERetry is the wait before reconnecting when the server set none
+// with a retry field.
+const defaultSSERetry = time.Second
+
+// SSEEvent is an event of a text/event-stream.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	// Retry is the reconnection time the event set, zero when it set none.
+	Retry time.Duration
+}
+
+// SSEReader splits an event stream into events. Comment lines, such as the
+// keep-alives servers send on idle streams, are skipped, and the data lines
+// of an event are joined by newlines.
+type SSEReader struct {
+	r *bufio.Reader
+}
+
+// NewSSEReader returns a reader of the events of r.
+func NewSSEReader(r io.Reader) *SSEReader {
+	return &SSEReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next event. A stream ending in the middle of an event
+// drops it and returns io.EOF, or the error that ended the stream.
+func (s *SSEReader) Next() (SSEEvent, error) {
+	var e SSEEvent
+	var data []string
+	started := false
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" {
+				return SSEEvent{}, io.EOF
+			}
+			if err != io.EOF {
+				return SSEEvent{}, err
+			}
+			// A last line without its newline ends the stream, not the event.
+			return SSEEvent{}, io.ErrUnexpectedEOF
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			if started {
+				e.Data = strings.Join(data, "\n")
+				return e, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			e.Event = value
+			started = true
+		case "data":
+			data = append(data, value)
+			started = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				e.ID = value
+				started = true
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				e.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// SSESubscription is a subscription delivered over Server-Sent Events with
+// the graphql-sse protocol.
+type SSESubscription struct {
+	url     string
+	body    []byte
+	headers map[string]string
+	opts    SubscribeOptions
+	client  *http.Client
+
+	// Mode is SSEDistinct or SSESingle.
+	Mode  string
+	token string
+
+	resp        *http.Response
+	events      *SSEReader
+	cancel      context.CancelFunc
+	lastEventID string
+	retry       time.Duration
+}
+
+// SubscribeSSE subscribes to query on url over Server-Sent Events. It tries
+// the distinct connections mode of graphql-sse first, then the single
+// connection mode. The requests go through the shared HTTP client, with its
+// proxy, TLS, scope and authentication settings. opts.HandshakeTimeout bounds
+// the opening of the stream; once the function has returned, ctx no longer
+// affects the subscription. Listen reads the events.
+func SubscribeSSE(ctx context.Context, url, query string, variables map[string]interface{}, headers map[string]string, opts SubscribeOptions) (*SSESubscription, error) {
+	opts = opts.withDefaults()
+	s := &SSESubscription{url: url, headers: headers, opts: opts, client: network.StreamClient(), retry: defaultSSERetry}
+
+	op := map[string]interface{}{"query": query}
+	if variables != nil {
+		op["variables"] = variables
+	}
+	body, err := json.Marshal(op)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal subscription payload: %w", err)
+	}
+	s.body = body
+	s.Mode = SSEDistinct
+	distinctErr := s.open(ctx)
+	if distinctErr == nil {
+		network.RecordOperations(url, query)
+		return s, nil
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("subscription canceled: %w", ctx.Err())
+	}
+	log.Printf("Distinct connections mode failed: %v", distinctErr)
+
+	s.Mode = SSESingle
+	op["extensions"] = map[string]interface{}{"operationId": sseOperationID}
+	s.body, err = json.Marshal(op)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal subscription payload: %w", err)
+	}
+	if err := s.reserve(ctx); err != nil {
+		return nil, fmt.Errorf("SSE subscription failed in distinct connections mode (%v) and single connection mode: %w", distinctErr, err)
+	}
+	if err := s.open(ctx); err != nil {
+		return nil, fmt.Errorf("failed to open the event stream: %w", err)
+	}
+	if err := s.execute(ctx); err != nil {
+		s.Close()
+		return nil, err
+	}
+	network.RecordOperations(url, query)
+	return s, nil
+}
+
+// newRequest builds a request to the subscription URL with the headers of
+// the subscription and the session.
+func (s *SSESubscription) newRequest(ctx context.Context, method string, body []byte) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.url, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.token != "" {
+		req.Header.Set(sseTokenHeader, s.token)
+	}
+	network.ApplySession(req)
+	return req, nil
+}
+
+// reserve obtains the token of a single connection mode stream.
+func (s *SSESubscription) reserve(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.HandshakeTimeout)
+	defer cancel()
+	req, err := s.newRequest(ctx, http.MethodPut, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reserve an event stream: %w", err)
+	}
+	defer resp.Body.Close()
+	token, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("reserving an event stream returned HTTP %d", resp.StatusCode)
+	}
+	s.token = strings.TrimSpace(string(token))
+	if s.token == "" {
+		return errors.New("reserving an event stream returned no token")
+	}
+	return nil
+}
+
+// execute sends the operation of a single connection mode subscription.
+func (s *SSESubscription) execute(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.HandshakeTimeout)
+	defer cancel()
+	req, err := s.newRequest(ctx, http.MethodPost, s.body)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send the subscription: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sending the subscription returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// open opens the event stream: with a POST of the operation in distinct
+// connections mode, with a GET of the reserved stream in single connection
+// mode. After a reconnection, Last-Event-ID resumes after the last event
+// received.
+func (s *SSESubscription) open(ctx context.Context) error {
+	streamCtx, cancel := context.WithCancel(context.Background())
+	stop := cancelWith(ctx, cancel)
+	defer stop()
+	timer := time.AfterFunc(s.opts.HandshakeTimeout, cancel)
+	defer timer.Stop()
+
+	method, body := http.MethodPost, s.body
+	if s.Mode == SSESingle {
+		method, body = http.MethodGet, nil
+	}
+	req, err := s.newRequest(streamCtx, method, body)
+	if err != nil {
+		cancel()
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if s.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", s.lastEventID)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusOK || mediaType != "text/event-stream" {
+		resp.Body.Close()
+		cancel()
+		return fmt.Errorf("HTTP %d with Content-Type %q instead of an event stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	s.resp, s.events, s.cancel = resp, NewSSEReader(resp.Body), cancel
+	return nil
+}
+
+// cancelWith calls cancel when ctx is done. The returned function stops the watch.
+func cancelWith(ctx context.Context, cancel context.CancelFunc) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// Close closes the event stream.
+func (s *SSESubscription) Close() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.resp != nil {
+		s.resp.Body.Close()
+	}
+}
+
+// Listen logs the results of the subscription until the server completes it,
+// no event arrives within opts.ReadTimeout, or ctx is canceled. A stream that
+// ends otherwise is opened again, resuming after the last event received.
+func (s *SSESubscription) Listen(ctx context.Context) {
+	defer s.Close()
+	for {
+		err := s.readEvents(ctx)
+		if err == nil {
+			log.Printf("Subscription completed")
+			return
+		}
+		if ctx.Err() != nil {
+			log.Printf("Subscription stopped: %v", ctx.Err())
+			return
+		}
+		if errors.Is(err, errReadTimeout) {
+			log.Printf("Error reading event stream: %v", err)
+			return
+		}
+		log.Printf("Event stream ended: %v", err)
+		if !s.reconnect(ctx) {
+			return
+		}
+	}
+}
+
+// reconnect opens the stream again, waiting the retry time before each of up
+// to maxSSEReconnects attempts. It reports whether the stream is open.
+func (s *SSESubscription) reconnect(ctx context.Context) bool {
+	s.Close()
+	for attempt := 1; attempt <= maxSSEReconnects; attempt++ {
+		log.Printf("Reconnecting in %s (%d/%d)", s.retry, attempt, maxSSEReconnects)
+		select {
+		case <-ctx.Done():
+			log.Printf("Subscription stopped: %v", ctx.Err())
+			return false
+		case <-time.After(s.retry):
+		}
+		err := s.open(ctx)
+		if err == nil {
+			return true
+		}
+		log.Printf("Error reconnecting: %v", err)
+	}
+	return false
+}
+
+// errReadTimeout reports that no event arrived within opts.ReadTimeout.
+var errReadTimeout = errors.New("no event within the read timeout")
+
+// readEvents logs the events of the open stream. It returns nil on the
+// complete event of the subscription.
+func (s *SSESubscription) readEvents(ctx context.Context) error {
+	stop := cancelWith(ctx, s.cancel)
+	defer stop()
+	var timedOut atomic.Bool
+	var timer *time.Timer
+	if s.opts.ReadTimeout > 0 {
+		timer = time.AfterFunc(s.opts.ReadTimeout, func() {
+			timedOut.Store(true)
+			s.cancel()
+		})
+		defer timer.Stop()
+	}
+	for {
+		e, err := s.events.Next()
+		if err != nil {
+			if timedOut.Load() {
+				return errReadTimeout
+			}
+			if err == io.EOF {
+				return errors.New("the server closed the stream")
+			}
+			return err
+		}
+		if timer != nil {
+			timer.Reset(s.opts.ReadTimeout)
+		}
+		if e.ID != "" {
+			s.lastEventID = e.ID
+		}
+		if e.Retry > 0 {
+			s.retry = e.Retry
+		}
+		switch e.Event {
+		case "complete":
+			return nil
+		case "next", "":
+			if e.Data != "" {
+				log.Printf("Received message: %s", e.Data)
+			}
+		default:
+			log.Printf("Received %s event: %s", e.Event, e.Data)
+		}
+	}
+}
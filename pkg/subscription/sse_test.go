@@ -0,0 +1,233 @@
+package subscription
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSSEReader(t *testing.T) {
+	stream := ": keep-alive\n\n" +
+		"id: 1\nevent: next\ndata: {\"data\":\ndata: {\"n\":1}}\n\n" +
+		"id: 2\r\nretry: 250\r\ndata:{\"data\":{\"n\":2}}\r\n\r\n" +
+		// An id holding NUL is ignored, and so is an invalid retry.
+		"id: a\x00b\nretry: soon\nevent: complete\n\n" +
+		"data: {\"data\":{\"n\":"
+	r := NewSSEReader(strings.NewReader(stream))
+	want := []SSEEvent{
+		{ID: "1", Event: "next", Data: "{\"data\":\n{\"n\":1}}"},
+		{ID: "2", Data: `{"data":{"n":2}}`, Retry: 250 * time.Millisecond},
+		{Event: "complete"},
+	}
+	for i, w := range want {
+		e, err := r.Next()
+		if err != nil || e != w {
+			t.Fatalf("event %d = %+v, %v; want %+v", i, e, err, w)
+		}
+	}
+	// The last event was cut off by the end of the stream.
+	if e, err := r.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated event = %+v, %v; want io.ErrUnexpectedEOF", e, err)
+	}
+	r = NewSSEReader(strings.NewReader(": only a comment\n\n"))
+	if e, err := r.Next(); err != io.EOF {
+		t.Errorf("a stream without events = %+v, %v; want io.EOF", e, err)
+	}
+}
+
+// captureLog returns what the standard logger prints during the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var b bytes.Buffer
+	flags := log.Flags()
+	log.SetOutput(&b)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	return &b
+}
+
+// received returns the messages Listen logged.
+func received(out string) []string {
+	var msgs []string
+	for _, m := range regexp.MustCompile(`Received message: (.*)`).FindAllStringSubmatch(out, -1) {
+		msgs = append(msgs, m[1])
+	}
+	return msgs
+}
+
+// flushEvents writes the events of a script to w, flushing after each.
+func flushEvents(w http.ResponseWriter, events ...string) {
+	for _, e := range events {
+		io.WriteString(w, e)
+		w.(http.Flusher).Flush()
+	}
+}
+
+// TestSSEDistinctReconnects subscribes in distinct connections mode to a
+// server that drops the stream after two events and resumes after the last
+// one on the next connection.
+func TestSSEDistinctReconnects(t *testing.T) {
+	out := captureLog(t)
+	var mu sync.Mutex
+	var lastEventIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var op struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&op)
+		if r.Method != http.MethodPost || r.Header.Get("Accept") != "text/event-stream" || op.Query != "subscription { n }" || op.Variables["room"] != "lobby" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		resumed := len(lastEventIDs) > 1
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		if !resumed {
+			// The stream drops without a complete event.
+			flushEvents(w,
+				": keep-alive\n\n",
+				"id: 1\nevent: next\ndata: {\"data\":{\"n\":1}}\n\n",
+				"id: 2\nretry: 50\nevent: next\ndata: {\"data\":{\"n\":2}}\n\n")
+			return
+		}
+		flushEvents(w,
+			"id: 3\nevent: next\ndata: {\"data\":{\"n\":3}}\n\n",
+			"event: complete\ndata:\n\n")
+	}))
+	defer srv.Close()
+
+	s, err := SubscribeSSE(context.Background(), srv.URL, "subscription { n }", map[string]interface{}{"room": "lobby"}, nil, SubscribeOptions{ReadTimeout: promptly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Mode != SSEDistinct {
+		t.Errorf("mode %s, want %s", s.Mode, SSEDistinct)
+	}
+	s.Listen(context.Background())
+
+	want := []string{`{"data":{"n":1}}`, `{"data":{"n":2}}`, `{"data":{"n":3}}`}
+	if got := received(out.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("received %q, want %q\n%s", got, want, out)
+	}
+	if !reflect.DeepEqual(lastEventIDs, []string{"", "2"}) {
+		t.Errorf("Last-Event-ID headers %q, want the second connection to resume after 2", lastEventIDs)
+	}
+	if !strings.Contains(out.String(), "Reconnecting in 50ms (1/3)") || !strings.HasSuffix(out.String(), "Subscription completed\n") {
+		t.Errorf("log:\n%s\nwant a reconnection after the retry time, then completion", out)
+	}
+}
+
+// TestSSESingleConnectionMode subscribes to a server that refuses the
+// distinct connections mode, so the stream is reserved with PUT, opened with
+// GET and the operation sent with POST.
+func TestSSESingleConnectionMode(t *testing.T) {
+	out := captureLog(t)
+	var mu sync.Mutex
+	var requests []string
+	executed := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var op struct {
+			Extensions struct {
+				OperationID string `json:"operationId"`
+			} `json:"extensions"`
+		}
+		json.NewDecoder(r.Body).Decode(&op)
+		token := r.Header.Get(sseTokenHeader)
+		mu.Lock()
+		requests = append(requests, r.Method+" "+token)
+		mu.Unlock()
+		switch {
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, "tok-1\n")
+		case r.Method == http.MethodGet && token == "tok-1":
+			w.Header().Set("Content-Type", "text/event-stream")
+			flushEvents(w, ": stream open\n\n")
+			<-executed
+			flushEvents(w,
+				"event: next\ndata: {\"id\":\"1\",\"payload\":{\"data\":{\"n\":1}}}\n\n",
+				"event: complete\ndata: {\"id\":\"1\"}\n\n")
+		case r.Method == http.MethodPost && token == "tok-1" && op.Extensions.OperationID == sseOperationID:
+			w.WriteHeader(http.StatusAccepted)
+			close(executed)
+		default:
+			// That is a distinct connections mode request.
+			http.Error(w, "use the single connection mode", http.StatusUnsupportedMediaType)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := SubscribeSSE(context.Background(), srv.URL, "subscription { n }", nil, nil, SubscribeOptions{ReadTimeout: promptly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Mode != SSESingle {
+		t.Errorf("mode %s, want %s", s.Mode, SSESingle)
+	}
+	s.Listen(context.Background())
+
+	if want := []string{"POST ", "PUT ", "GET tok-1", "POST tok-1"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests %q, want %q", requests, want)
+	}
+	if got := received(out.String()); !reflect.DeepEqual(got, []string{`{"id":"1","payload":{"data":{"n":1}}}`}) {
+		t.Errorf("received %q\n%s", got, out)
+	}
+	if !strings.Contains(out.String(), "Distinct connections mode failed: HTTP 415") {
+		t.Errorf("log:\n%s\nwant the distinct mode failure", out)
+	}
+}
+
+func TestSSEReadTimeout(t *testing.T) {
+	out := captureLog(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		flushEvents(w, "event: next\ndata: {\"data\":{\"n\":1}}\n\n")
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	s, err := SubscribeSSE(context.Background(), srv.URL, "subscription { n }", nil, nil, SubscribeOptions{ReadTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	s.Listen(context.Background())
+	if elapsed := time.Since(started); elapsed > promptly {
+		t.Errorf("Listen returned after %s, want the read timeout", elapsed)
+	}
+	if !strings.Contains(out.String(), "Error reading event stream: no event within the read timeout") || strings.Contains(out.String(), "Reconnecting") {
+		t.Errorf("log:\n%s\nwant the read timeout without a reconnection", out)
+	}
+}
+
+func TestSSENotAnEventStream(t *testing.T) {
+	captureLog(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errors":[{"message":"subscriptions are not supported"}]}`))
+	}))
+	defer srv.Close()
+	_, err := SubscribeSSE(context.Background(), srv.URL, "subscription { n }", nil, nil, SubscribeOptions{})
+	if err == nil || !strings.Contains(err.Error(), `HTTP 200 with Content-Type "application/json" instead of an event stream`) {
+		t.Errorf("SubscribeSSE() = %v", err)
+	}
+}
@@ -1,34 +1,346 @@
 package types
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // CLI types
 type CLIConfig struct {
-	ConfigFile    string
+	ConfigFile   string
+	BaseURL      string
+	Detect       bool
+	OutputFile   string
+	Timeout      time.Duration
+	LogLevel     string
+	LogFile      string
+	NoColor      bool
+	LogUTC       bool
+	MaxDepth     int
+	SchemaFile   string
+	List         string
+	Query        string
+	Mutation     string
+	AllQueries   bool
+	AllMutations bool
+	Subscribe    bool
+	SubQuery     string
+	WSURL        string
+	SubTransport string
+	// LogMaxSize rotates LogFile once it reaches this many bytes, keeping
+	// LogMaxBackups rotated files, gzipped with LogCompress.
+	LogMaxSize    int64
+	LogMaxBackups int
+	LogCompress   bool
+	// SubAckTimeout and SubReadTimeout configure the subscription client.
+	SubAckTimeout  time.Duration
+	SubReadTimeout time.Duration
+	Execute        bool
+	BatchDir       string
+	// IgnoreFailures keeps the exit code at 0 when batch operations fail
+	IgnoreFailures bool
+	// KeepAllFragments sends every fragment of a document with each operation
+	// of batch and execute modes instead of only the ones it uses
+	KeepAllFragments bool
+	QueryString      string
+	QueryFile        string
+	Variables        string
+	VariablesFile    string
+	// ParamName places the executed query in a non-standard JSON member or URL parameter
+	ParamName string
+	// FuzzCoercion makes --execute send type-confused values of the query's
+	// variables instead of executing it once
+	FuzzCoercion bool
+	// DuplicateQuery sends a benign query alongside the real one ("benign=... real=...")
+	DuplicateQuery string
+	Headers        map[string]string
+	Checks         string
+	SkipChecks     string
+	ListChecks     bool
+	// ListWordlists prints the built-in wordlists and exits.
+	ListWordlists bool
+	ReportFile    string
+	Extract       bool
+	ExtractDir    string
+	// ObserveSchema writes the schema built from the responses of batch,
+	// execute and extraction runs to this file
+	ObserveSchema string
+	// RecoverSchema writes the schema brute forced with the names of
+	// Wordlist, "builtin:<name>" or a file, to this file
+	RecoverSchema string
+	Wordlist      string
+	// ArgWordlist names the arguments probed on the recovered fields;
+	// InferenceWorkers probes are sent at once, in the order InferenceSeed
+	// breaks ties of, and the run is saved to ResumeInference every
+	// CheckpointEvery requests and resumed from it.
+	ArgWordlist      string
+	InferenceWorkers int
+	InferenceSeed    int64
+	ResumeInference  string
+	CheckpointEvery  int
+	// FollowPagination pages through paginated queries during extraction, up
+	// to MaxPages pages per query.
+	FollowPagination bool
+	MaxPages         int
+	Rate             float64
+	Concurrency      string
+	Sort             string
+	Selection        string
+	Notes            string
+	Tag              string
+	Stats            bool
+	AuditDoS         bool
+	AuditWS          bool
+	AuditInjection   bool
+	Safe             bool
+	Aggressive       bool
+	Version          bool
+	Redact           bool
+	// SecretPatterns is a file of patterns added to the secret detectors run
+	// on the responses of extraction.
+	SecretPatterns string
+	// MatrixDir, when set, receives the access matrix of the operations
+	// extracted from every target.
+	MatrixDir string
+	// CustomChecks is a YAML or JSON file of declarative checks registered
+	// after the built-in ones.
+	CustomChecks string
+	// Sample, when above 0, keeps that many operations for generation,
+	// cataloging and extraction, picked with SampleStrategy.
+	Sample         int
+	SampleStrategy string
+	SampleSeed     int64
+	// RedactArtifacts extends redaction to introspection dumps
+	RedactArtifacts bool
+	StopOnFinding   string
+	ContinueOnError bool
+	TargetsFile     string
+	VHosts          string
+	StateFile       string
+	Resume          bool
+	Watch           time.Duration
+	WatchState      string
+	WebhookURL      string
+	CatalogOut      string
+	OutDir          string
+	VarsSchemaOut   string
+	SDLOut          string
+	// CheckTimeouts overrides check budgets ("dos=2m,engine=20s")
+	CheckTimeouts string
+	// ChunkedIntrospection fetches the schema in batches of IntrospectionChunkSize types
+	ChunkedIntrospection   bool
+	IntrospectionChunkSize int
+	CatalogFormat          string
+	ReportFormat           string
+	// ReportTemplate is a built-in template name or a Go text/template file rendering --report.
+	ReportTemplate string
+	// ReportDetail is the evidence detail level of --report: summary, standard or full.
+	ReportDetail string
+	// Sinks are the --sink specs receiving the findings as they are found.
+	Sinks []string
+	// Preflight session token options
+	PreflightURL          string
+	PreflightTokenExtract string
+	PreflightTokenHeader  string
+	PreflightExpired      string
+	// AWSSign signs every request with AWS SigV4 for AWSRegion and AWSService.
+	AWSSign    bool
+	AWSRegion  string
+	AWSService string
+	// Client certificate for mutual TLS
+	ClientCert        string
+	ClientKey         string
+	ClientKeyPassword string
+	// Name resolution: pinned addresses, DNS server and IP version
+	Resolve   map[string]string
+	DNSServer string
+	IPVersion int
+	// InjectionDelay, InjectionFactor and InjectionTrials tune the time-based
+	// injection probes of --audit-injection.
+	InjectionDelay  time.Duration
+	InjectionFactor float64
+	InjectionTrials int
+	// History is the log every request sent is appended to.
+	History string
+	// ProfileBounty is the bug bounty program profile whose constraints the
+	// run enforces.
+	ProfileBounty string
+	// Scope lists the hosts the run may contact; empty means the hosts of
+	// the explicit targets.
+	Scope string
+	// RunManifest is the file recording the invocation, phase timings, exit
+	// code, artifacts and findings of the run for orchestrators.
+	RunManifest string
+	// SignKey is the Ed25519 private key signing the artifacts of the run and
+	// its manifest.
+	SignKey string
+	// VulnDB replaces the embedded vulnerability knowledge base.
+	VulnDB string
+	// IntrospectionFile is a saved introspection result audited instead of querying the targets.
+	IntrospectionFile string
+	// DryRun prints the requests a run would send instead of sending them.
+	DryRun bool
+	// Preview prints the requests of --execute and --batch-dir as they would
+	// be sent instead of sending them.
+	Preview bool
+	// Offline skips the checks that send requests.
+	Offline bool
+	// ErrorPatterns is an error-patterns dataset document applied after DataDir.
+	ErrorPatterns string
+	// DataDir holds dataset overrides replacing or extending the embedded data.
+	DataDir string
+	// RequestEncoding wraps the requests to the targets for endpoints that
+	// tunnel GraphQL (see network.Encoding).
+	RequestEncoding string
+	// Whoami is the query verifying the supplied credentials on each target.
+	Whoami string
+	// CanaryQuery is a read query compared before and after the audit of each target.
+	CanaryQuery string
+	// CanaryIgnore and CanaryUnordered are comma-separated jsondiff path patterns.
+	CanaryIgnore    string
+	CanaryUnordered string
+	// IdentitiesFile lists the identities of the authorization matrix, least
+	// privileged first.
+	IdentitiesFile string
+	// AuthzIgnore and AuthzUnordered are comma-separated jsondiff path
+	// patterns of the data compared between identities.
+	AuthzIgnore    string
+	AuthzUnordered string
+}
+
+// LintConfig holds the options of the lint subcommand
+type LintConfig struct {
+	Dir           string
+	Files         []string
+	MaxDepth      int
+	MaxAliases    int
+	MaxRootFields int
+	MaxSelections int
+}
+
+// HashConfig holds the options of the hash subcommand
+type HashConfig struct {
+	QueryFile   string
+	QueryString string
+	Files       []string
+	Canonical   bool
+}
+
+// HARConfig holds the options of the har subcommand
+type HARConfig struct {
+	// Files are the HAR archives to import.
+	Files  []string
+	OutDir string
+	Cache  string
+	// ObserveSchema is the file the schema observed in the responses is
+	// written to; with Replay the queries are sent again, to BaseURL when
+	// set, and the new responses observed instead of the recorded ones.
+	ObserveSchema string
+	Replay        bool
 	BaseURL       string
-	Detect        bool
-	OutputFile    string
-	Timeout       time.Duration
+	Headers       map[string]string
+}
+
+// DataConfig holds the options of the data subcommand
+type DataConfig struct {
+	DataDir string
+	// Args are the action and its arguments, e.g. "show paths".
+	Args []string
+}
+
+// HistoryConfig holds the options of the history subcommand
+type HistoryConfig struct {
+	File string
+	// Since and OpName filter the entries listed.
+	Since  string
+	OpName string
+	Limit  int
+	Offset int
+	// BaseURL, Headers and Variables override those of a replayed entry.
+	BaseURL   string
+	Headers   map[string]string
+	Variables string
+	// Args are the action and its arguments, e.g. "show 3f9a".
+	Args []string
+}
+
+// SchemaConfig holds the options of the schema subcommand
+type SchemaConfig struct {
+	BaseURL string
+	Headers map[string]string
+	// Out is the lock written by "schema lock", Lock the one "schema verify"
+	// compares the endpoint with.
+	Out     string
+	Lock    string
+	Timeout time.Duration
+	// SchemaFile, when set, is a saved introspection result locked or
+	// verified instead of the schema of BaseURL.
+	SchemaFile string
+	// Offline refuses all network access; it needs SchemaFile.
+	Offline bool
+	// Args are the action and its arguments, e.g. "verify".
+	Args []string
+}
+
+// BundleConfig holds the options of the bundle subcommand
+type BundleConfig struct {
+	Workspace string
+	Out       string
+	// NoRedact bundles the files as they are.
+	NoRedact bool
+	// Headers carry credentials masked wherever they appear in the bundle.
+	Headers map[string]string
+	// Args are the action and its arguments, e.g. "extract engagement.tar.gz".
+	Args []string
+}
+
+// VerifyConfig holds the options of the verify subcommand
+type VerifyConfig struct {
+	Manifest  string
+	PublicKey string
+	// Dir is the directory the relative paths of the manifest are taken from.
+	Dir string
+}
+
+// ExplainConfig holds the options of the explain subcommand
+type ExplainConfig struct {
+	// Engine limits the engine-specific remediation to one engine.
+	Engine string
+	List   bool
+	// Args are the finding ids explained.
+	Args []string
+}
+
+// ReproConfig holds the options of the repro subcommand
+type ReproConfig struct {
+	Finding string
+	// Endpoint selects the finding among those sharing its id.
+	Endpoint string
+	Report   string
+	Out      string
+}
+
+// ServerConfig holds the options of the server subcommand
+type ServerConfig struct {
+	Listen    string
+	Workers   int
+	QueueSize int
+	Dir       string
+	// Persist saves scan records in Dir so they survive restarts
+	Persist     bool
+	ScanTimeout time.Duration
+	MaxDepth    int
+	// ProfileBounty is the bounty profile enforced on every queued scan.
+	ProfileBounty string
 	LogLevel      string
 	LogFile       string
 	NoColor       bool
-	MaxDepth      int
-	SchemaFile    string
-	List          string
-	Query         string
-	Mutation      string
-	AllQueries    bool
-	AllMutations  bool
-	Subscribe     bool
-	SubQuery      string
-	WSURL         string
-	Execute       bool
-	BatchDir      string
-	QueryString   string
-	QueryFile     string
-	Variables     string
-	VariablesFile string
-	Headers       map[string]string
+	LogUTC        bool
+	// LogMaxSize, LogMaxBackups and LogCompress rotate LogFile as for
+	// CLIConfig.
+	LogMaxSize    int64
+	LogMaxBackups int
+	LogCompress   bool
 }
 
 type FileConfig struct {
@@ -43,6 +355,193 @@ type FileConfig struct {
 	SchemaFile string            `yaml:"schema-file" json:"schema-file"`
 	OutputFile string            `yaml:"output" json:"output"`
 	MaxDepth   int               `yaml:"max-depth" json:"max-depth"`
+
+	ClientCert        string `yaml:"client-cert" json:"client-cert"`
+	ClientKey         string `yaml:"client-key" json:"client-key"`
+	ClientKeyPassword string `yaml:"client-key-password" json:"client-key-password"`
+}
+
+// BountyProfile holds the rules of engagement of a bug bounty program, loaded
+// from the file of --profile-bounty and enforced for the whole run
+type BountyProfile struct {
+	Program string `yaml:"program" json:"program"`
+	// Headers are set on every request, overriding the headers of the run.
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+	// MaxRate caps the requests per second, MaxConcurrency the requests in
+	// flight. Zero leaves them unbounded.
+	MaxRate        float64 `yaml:"max-rate" json:"maxRate,omitempty"`
+	MaxConcurrency int     `yaml:"max-concurrency" json:"maxConcurrency,omitempty"`
+	// ForbiddenChecks are the check ids and groups that may not run.
+	ForbiddenChecks []string `yaml:"forbidden-checks" json:"forbiddenChecks,omitempty"`
+	// AllowedHosts are the hosts that may be contacted. "*.example.com"
+	// matches the subdomains of example.com.
+	AllowedHosts []string `yaml:"allowed-hosts" json:"allowedHosts"`
+}
+
+// Identity is a caller the authorization matrix sends the operations of each
+// target as, loaded from the file of --identities
+type Identity struct {
+	Name string `yaml:"name" json:"name"`
+	// Headers carry the credentials of the identity; an identity without
+	// headers is an anonymous caller.
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+}
+
+// NetworkStats holds the network-level metrics collected during a run.
+type NetworkStats struct {
+	Requests             int64             `json:"requests"`
+	StatusCodes          map[int]int64     `json:"statusCodes"`
+	BytesSent            int64             `json:"bytesSent"`
+	BytesReceived        int64             `json:"bytesReceived"`
+	Retries              int64             `json:"retries"`
+	RateLimitWaits       int64             `json:"rateLimitWaits"`
+	NewConns             int64             `json:"newConnections"`
+	ReusedConns          int64             `json:"reusedConnections"`
+	ConnectionReuseRatio float64           `json:"connectionReuseRatio"`
+	Modules              map[string]string `json:"modules"`
+	// Operations counts the GraphQL operations sent, by kind.
+	Operations map[string]int64 `json:"operations"`
+	// Concurrency is the limit on requests in flight, nil when unlimited.
+	Concurrency *ConcurrencyStats `json:"concurrency,omitempty"`
+	WallTime    string            `json:"wallTime"`
+}
+
+// ConcurrencyStats describes the limit on requests in flight of a run.
+type ConcurrencyStats struct {
+	// Mode is "fixed" or "adaptive".
+	Mode string `json:"mode"`
+	// Limit is the current limit and Peak the most requests seen in flight.
+	Limit int `json:"limit"`
+	Peak  int `json:"peak"`
+	// Increases and Decreases count the changes of an adaptive limit.
+	Increases int64 `json:"increases,omitempty"`
+	Decreases int64 `json:"decreases,omitempty"`
+}
+
+// AuthCandidate is a detection path that did not answer as GraphQL but showed
+// signs of requiring authentication, worth revisiting with credentials.
+type AuthCandidate struct {
+	URL string `json:"url"`
+	// Reason is the evidence: the status, authentication challenge or redirect seen.
+	Reason      string `json:"reason"`
+	StatusCode  int    `json:"statusCode,omitempty"`
+	RedirectURL string `json:"redirectUrl,omitempty"`
+	// Confirmed is set when the candidate answered as GraphQL once retried
+	// with the supplied credentials.
+	Confirmed bool `json:"confirmed,omitempty"`
+}
+
+// Authentication verification outcomes
+const (
+	// AuthVerified means the whoami query returned data with the supplied
+	// credentials and not without them.
+	AuthVerified = "verified"
+	// AuthRejected means the endpoint refused the supplied credentials.
+	AuthRejected = "rejected"
+	// AuthUnproven means the whoami query returned data with the supplied
+	// credentials but also without them, so it proves nothing about them.
+	AuthUnproven = "unproven"
+	// AuthFailed means the whoami query could not be sent or answered.
+	AuthFailed = "failed"
+)
+
+// AuthVerification is the outcome of checking that the supplied credentials
+// authenticate to an endpoint before it is audited.
+type AuthVerification struct {
+	Endpoint string `json:"endpoint"`
+	// Identity is the authorization matrix identity whose credentials were
+	// checked, empty for those of the run.
+	Identity   string `json:"identity,omitempty"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	// Reason is the evidence: the status or error that rejected the
+	// credentials, or why the outcome is not verified.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Target is the endpoint of v, followed by the identity checked, if any.
+func (v AuthVerification) Target() string {
+	if v.Identity == "" {
+		return v.Endpoint
+	}
+	return v.Endpoint + " as " + v.Identity
+}
+
+// Summary describes v in words, such as "credentials rejected (HTTP 401)".
+func (v AuthVerification) Summary() string {
+	var s string
+	switch v.Status {
+	case AuthVerified:
+		s = "auth verified"
+	case AuthRejected:
+		s = "credentials rejected"
+	case AuthUnproven:
+		s = "auth not proven"
+	default:
+		s = "auth not verified"
+	}
+	if v.Reason != "" {
+		s += " (" + v.Reason + ")"
+	}
+	return s
+}
+
+// SentOperation is a GraphQL operation sent during a run. Count is the number
+// of times it was sent to Endpoint.
+type SentOperation struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name,omitempty"`
+	Count    int64  `json:"count,omitempty"`
+}
+
+// SuppressedRequest is a request, or a WebSocket dial, refused because its host
+// is out of scope. URL has no query string; dials only give host:port.
+type SuppressedRequest struct {
+	URL   string `json:"url"`
+	Host  string `json:"host"`
+	Count int64  `json:"count"`
+}
+
+// VirtualHostEndpoint is a GraphQL endpoint detected at the address of a base
+// URL under the host name Host. Engine and SchemaHash identify what it serves;
+// SchemaHash is empty when introspection is disabled. Differs is set when the
+// base URL's own host does not serve the same endpoint.
+type VirtualHostEndpoint struct {
+	Host       string `json:"host"`
+	Endpoint   string `json:"endpoint"`
+	Engine     string `json:"engine,omitempty"`
+	SchemaHash string `json:"schemaHash,omitempty"`
+	Differs    bool   `json:"differs"`
+}
+
+// Capabilities are the delivery features detected on an endpoint. Defer is
+// set when a fragment marked @defer is executed, DeferIncremental when its
+// result comes in incremental payloads rather than in a single response, and
+// Stream when the schema declares @stream. SSE is set when a query sent with
+// Accept: text/event-stream is answered with an event stream.
+type Capabilities struct {
+	Endpoint         string `json:"endpoint"`
+	Defer            bool   `json:"defer"`
+	DeferIncremental bool   `json:"deferIncremental"`
+	DeferDelivery    string `json:"deferDelivery,omitempty"`
+	Stream           bool   `json:"stream"`
+	SSE              bool   `json:"sse"`
+	// ResponseMedia is how the endpoint follows the
+	// application/graphql-response+json media type of the GraphQL over HTTP
+	// specification: "legacy", "spec-compliant" or "inconsistent", empty
+	// when it was not probed. Negotiation holds the responses it was told from.
+	ResponseMedia string             `json:"responseMedia,omitempty"`
+	Negotiation   []NegotiationProbe `json:"negotiation,omitempty"`
+}
+
+// NegotiationProbe is the response to a query sent with one Accept header.
+// Valid tells the query executed from the one failing validation.
+type NegotiationProbe struct {
+	Accept      string `json:"accept"`
+	Valid       bool   `json:"valid"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
 }
 
 // GraphQLRequest represents a GraphQL request structure.
@@ -80,6 +579,35 @@ type Field struct {
 	Type              TypeRef      `json:"type"`
 	IsDeprecated      bool         `json:"isDeprecated"`
 	DeprecationReason string       `json:"deprecationReason"`
+	// AppliedDirectives are the directives applied to the field in the
+	// schema, which standard introspection does not return. They are filled
+	// in by the extractors of pkg/schema/metadata on engines exposing them.
+	AppliedDirectives []AppliedDirective `json:"appliedDirectives,omitempty"`
+}
+
+// AppliedDirective is a directive applied to a schema element, with its
+// arguments as GraphQL literals.
+type AppliedDirective struct {
+	Name string                `json:"name"`
+	Args []AppliedDirectiveArg `json:"args,omitempty"`
+}
+
+// AppliedDirectiveArg is an argument of an applied directive.
+type AppliedDirectiveArg struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// String renders the directive as in SDL, for example @auth(requires: ADMIN).
+func (d AppliedDirective) String() string {
+	if len(d.Args) == 0 {
+		return "@" + d.Name
+	}
+	args := make([]string, len(d.Args))
+	for i, a := range d.Args {
+		args[i] = a.Name + ": " + a.Value
+	}
+	return "@" + d.Name + "(" + strings.Join(args, ", ") + ")"
 }
 
 // InputValue represents an input argument or field
@@ -137,11 +665,12 @@ type SchemaType struct {
 	Name string `json:"name"`
 }
 
-// Schema represents the top-level GraphQL schema
+// Schema represents the top-level GraphQL schema. A root type is nil when the
+// schema has none or the result gives it as null.
 type Schema struct {
-	QueryType        SchemaType  `json:"queryType"`
-	MutationType     SchemaType  `json:"mutationType"`
-	SubscriptionType SchemaType  `json:"subscriptionType"`
+	QueryType        *SchemaType `json:"queryType"`
+	MutationType     *SchemaType `json:"mutationType"`
+	SubscriptionType *SchemaType `json:"subscriptionType"`
 	Types            []Type      `json:"types"`
 	Directives       []Directive `json:"directives"`
 }
@@ -163,7 +692,28 @@ type IntrospectionResponse struct {
 
 // GQLSchema is the main struct that holds the parsed schema information
 type GQLSchema struct {
-	Types    map[string]Type
-	Query    *Type
-	Mutation *Type
+	Types        map[string]Type
+	Query        *Type
+	Mutation     *Type
+	Subscription *Type
+}
+
+// CompareConfig holds the options of the compare subcommand
+type CompareConfig struct {
+	BaseA          string
+	BaseB          string
+	SchemaFile     string
+	Format         string
+	Mutations      bool
+	LatencyFactor  float64
+	LatencyMinimum time.Duration
+	Data           bool
+	Ignore         string
+	Unordered      string
+	Timeout        time.Duration
+	MaxDepth       int
+	LogLevel       string
+	LogFile        string
+	NoColor        bool
+	LogUTC         bool
 }
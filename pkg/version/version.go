@@ -0,0 +1,25 @@
+// Package version exposes build metadata injected at link time, e.g.
+//
+//	go build -ldflags "-X github.com/CyberRoute/graphspecter/pkg/version.Version=v1.1.0 \
+//	  -X github.com/CyberRoute/graphspecter/pkg/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/CyberRoute/graphspecter/pkg/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import "fmt"
+
+// Build metadata, overridden with -ldflags "-X ..."
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// String returns a one-line description of the build
+func String() string {
+	return fmt.Sprintf("GraphSpecter %s (commit %s, built %s)", Version, Commit, BuildDate)
+}
+
+// UserAgent returns the User-Agent header value sent with every request
+func UserAgent() string {
+	return "GraphSpecter/" + Version
+}
@@ -0,0 +1,105 @@
+package vulndb
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// dateVersion matches date-based versions such as 2023-05-01 or 2023.05.01.
+var dateVersion = regexp.MustCompile(`^(\d{4})[-.](\d{1,2})[-.](\d{1,2})(.*)$`)
+
+// Compare orders two versions, returning -1, 0 or 1. It accepts semver-ish
+// versions with an optional leading "v", any number of numeric components and
+// a pre-release suffix after "-", which sorts before the release itself, as
+// well as date-based versions. Missing components count as zero, so 2.1 equals
+// 2.1.0. Build metadata after "+" is ignored.
+func Compare(a, b string) int {
+	ma, pa := parseVersion(a)
+	mb, pb := parseVersion(b)
+	for i := 0; i < len(ma) || i < len(mb); i++ {
+		var x, y int
+		if i < len(ma) {
+			x = ma[i]
+		}
+		if i < len(mb) {
+			y = mb[i]
+		}
+		if x != y {
+			return sign(x - y)
+		}
+	}
+	switch {
+	case pa == pb:
+		return 0
+	case pa == "":
+		return 1
+	case pb == "":
+		return -1
+	}
+	return comparePrerelease(pa, pb)
+}
+
+// parseVersion splits a version into its numeric components and pre-release suffix.
+func parseVersion(v string) ([]int, string) {
+	v = strings.TrimSpace(v)
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "v"), "V")
+	v, _, _ = strings.Cut(v, "+")
+	if m := dateVersion.FindStringSubmatch(v); m != nil {
+		v = m[1] + "." + m[2] + "." + m[3] + m[4]
+	}
+	release, pre, _ := strings.Cut(v, "-")
+
+	var nums []int
+	for _, part := range strings.Split(release, ".") {
+		// Trailing letters, as in 1.2.3beta, start a pre-release.
+		digits := strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' })
+		if digits >= 0 {
+			if pre == "" {
+				pre = part[digits:]
+			}
+			part = part[:digits]
+		}
+		n, _ := strconv.Atoi(part)
+		nums = append(nums, n)
+		if digits >= 0 {
+			break
+		}
+	}
+	return nums, pre
+}
+
+// comparePrerelease orders pre-release identifiers the way semver does:
+// numeric identifiers numerically, others lexically, numeric before others.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, errX := strconv.Atoi(as[i])
+		y, errY := strconv.Atoi(bs[i])
+		switch {
+		case errX == nil && errY == nil:
+			if x != y {
+				return sign(x - y)
+			}
+		case errX == nil:
+			return -1
+		case errY == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(as) - len(bs))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
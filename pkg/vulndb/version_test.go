@@ -0,0 +1,64 @@
+package vulndb
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	// Each version sorts before the next one.
+	ordered := []string{
+		"0.9",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.3beta",
+		"1.2.3",
+		"1.2.10",
+		"1.10",
+		"v2.0.0-alpha.1",
+		"v2.36.0",
+		"10.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			want := sign(i - j)
+			if got := Compare(ordered[i], ordered[j]); got != want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestCompareDates(t *testing.T) {
+	ordered := []string{"2022-12-31", "2023-05-01", "2023-05-02", "2023-10-22", "2024-01-01"}
+	for i := 1; i < len(ordered); i++ {
+		if Compare(ordered[i-1], ordered[i]) != -1 || Compare(ordered[i], ordered[i-1]) != 1 {
+			t.Errorf("%s does not sort before %s", ordered[i-1], ordered[i])
+		}
+	}
+	if Compare("2023-10-22-rc1", "2023-10-22") != -1 {
+		t.Error("a pre-release of a dated version does not sort before it")
+	}
+}
+
+func TestCompareEqual(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"2.1", "2.1.0"},
+		{"v1.4.7", "1.4.7"},
+		{"V1.4.7", "1.4.7"},
+		{" 1.4.7 ", "1.4.7"},
+		{"1.0.0+build.5", "1.0.0"},
+		{"1.0.0-rc.1+sha.abc", "1.0.0-rc.1"},
+		{"2023-05-01", "2023.05.01"},
+		{"2023-5-1", "2023-05-01"},
+		{"v2023.10.22", "2023-10-22"},
+	} {
+		if got := Compare(pair[0], pair[1]); got != 0 {
+			t.Errorf("Compare(%q, %q) = %d, want 0", pair[0], pair[1], got)
+		}
+	}
+}
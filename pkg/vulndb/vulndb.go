@@ -0,0 +1,186 @@
+// Package vulndb maps fingerprinted GraphQL engines and versions to known vulnerabilities
+package vulndb
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// dbVersion is the version of the knowledge base format.
+const dbVersion = 1
+
+//go:embed vulndb.json
+var embedded []byte
+
+// Range is a span of affected versions. Introduced is inclusive and empty for
+// "since the first release"; Fixed is exclusive and LastAffected inclusive.
+// At most one of Fixed and LastAffected is set; neither means still unfixed.
+type Range struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"lastAffected,omitempty"`
+}
+
+// Contains reports whether version falls within the range.
+func (r Range) Contains(version string) bool {
+	if r.Introduced != "" && Compare(version, r.Introduced) < 0 {
+		return false
+	}
+	if r.Fixed != "" && Compare(version, r.Fixed) >= 0 {
+		return false
+	}
+	if r.LastAffected != "" && Compare(version, r.LastAffected) > 0 {
+		return false
+	}
+	return true
+}
+
+// String describes the range, e.g. ">= 1.0.0, < 1.4.7".
+func (r Range) String() string {
+	var parts []string
+	if r.Introduced != "" {
+		parts = append(parts, ">= "+r.Introduced)
+	}
+	if r.Fixed != "" {
+		parts = append(parts, "< "+r.Fixed)
+	}
+	if r.LastAffected != "" {
+		parts = append(parts, "<= "+r.LastAffected)
+	}
+	if len(parts) == 0 {
+		return "all versions"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Entry is a known vulnerability or insecure default of an engine.
+type Entry struct {
+	// ID is a CVE or GHSA identifier, or a GS- id for advisories without one.
+	ID          string `json:"id"`
+	Engine      string `json:"engine"`
+	Title       string `json:"title"`
+	Severity    string `json:"severity"`
+	Description string `json:"description,omitempty"`
+	// Affected lists the affected version ranges. An entry without ranges
+	// applies to every version, including unknown ones.
+	Affected   []Range  `json:"affected,omitempty"`
+	References []string `json:"references,omitempty"`
+}
+
+// Ranges describes the affected versions of the entry.
+func (e Entry) Ranges() string {
+	if len(e.Affected) == 0 {
+		return "all versions"
+	}
+	parts := make([]string, len(e.Affected))
+	for i, r := range e.Affected {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, " or ")
+}
+
+// affects reports whether the entry applies to version. Versioned entries never
+// match an unknown version.
+func (e Entry) affects(version string) bool {
+	if len(e.Affected) == 0 {
+		return true
+	}
+	if version == "" {
+		return false
+	}
+	for _, r := range e.Affected {
+		if r.Contains(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// DB is a knowledge base of entries.
+type DB struct {
+	Version int     `json:"version"`
+	Entries []Entry `json:"entries"`
+}
+
+// Lookup returns the entries for engine that affect version. Engine names are
+// compared case-insensitively; version may be empty when it is unknown.
+func (db *DB) Lookup(engine, version string) []Entry {
+	var found []Entry
+	for _, e := range db.Entries {
+		if strings.EqualFold(e.Engine, engine) && e.affects(version) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Parse decodes a knowledge base in the format of the embedded one.
+func Parse(data []byte) (*DB, error) {
+	var db DB
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("error parsing vulnerability database: %w", err)
+	}
+	if db.Version != dbVersion {
+		return nil, fmt.Errorf("unsupported vulnerability database version %d", db.Version)
+	}
+	for i, e := range db.Entries {
+		if e.ID == "" || e.Engine == "" {
+			return nil, fmt.Errorf("vulnerability database entry %d needs an id and an engine", i)
+		}
+		if !report.ValidSeverity(e.Severity) {
+			return nil, fmt.Errorf("vulnerability database entry %s has invalid severity %q", e.ID, e.Severity)
+		}
+	}
+	return &db, nil
+}
+
+// Load reads a knowledge base from path.
+func Load(path string) (*DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading vulnerability database: %w", err)
+	}
+	db, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+var (
+	activeMu sync.RWMutex
+	active   *DB
+)
+
+// Embedded returns the knowledge base shipped with the binary.
+func Embedded() *DB {
+	db, err := Parse(embedded)
+	if err != nil {
+		panic("vulndb: invalid embedded database: " + err.Error())
+	}
+	return db
+}
+
+// Use replaces the knowledge base consulted by Lookup, e.g. with one loaded by --vulndb.
+func Use(db *DB) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	active = db
+}
+
+// Lookup consults the active knowledge base, the embedded one unless Use replaced it.
+func Lookup(engine, version string) []Entry {
+	activeMu.Lock()
+	if active == nil {
+		active = Embedded()
+	}
+	db := active
+	activeMu.Unlock()
+	return db.Lookup(engine, version)
+}
@@ -0,0 +1,141 @@
+package vulndb
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRangeContains(t *testing.T) {
+	tests := []struct {
+		r       Range
+		version string
+		want    bool
+	}{
+		{Range{Fixed: "1.4.7"}, "1.4.6", true},
+		{Range{Fixed: "1.4.7"}, "1.4.7", false},
+		{Range{Fixed: "1.4.7"}, "v1.4.7-rc.1", true},
+		{Range{Fixed: "1.4.7"}, "0.1", true},
+		{Range{Introduced: "1.0.0", Fixed: "1.4.7"}, "0.9.9", false},
+		{Range{Introduced: "1.0.0", Fixed: "1.4.7"}, "1.0", true},
+		{Range{Introduced: "1.0.0", Fixed: "1.4.7"}, "1.0.0-beta", false},
+		{Range{LastAffected: "2.3"}, "2.3.0", true},
+		{Range{LastAffected: "2.3"}, "2.3.1", false},
+		{Range{Introduced: "2.0.0-alpha.1", LastAffected: "2.11.2"}, "v2.0.0-alpha.1", true},
+		{Range{Introduced: "2.0.0-alpha.1", LastAffected: "2.11.2"}, "2.11.2+cloud", true},
+		{Range{Introduced: "2.0.0-alpha.1", LastAffected: "2.11.2"}, "1.3.3", false},
+		{Range{Introduced: "2022-06-01", Fixed: "2023-10-22"}, "2023.05.01", true},
+		{Range{Introduced: "2022-06-01", Fixed: "2023-10-22"}, "2023-10-22", false},
+		{Range{Introduced: "2022-06-01", Fixed: "2023-10-22"}, "2022-05-31", false},
+		{Range{Introduced: "2022-06-01"}, "2030-01-01", true},
+		{Range{}, "anything", true},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Contains(tt.version); got != tt.want {
+			t.Errorf("%s contains %q = %v, want %v", tt.r, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestRangeString(t *testing.T) {
+	e := Entry{Affected: []Range{{Introduced: "1.0.0", Fixed: "1.4.7"}, {LastAffected: "0.9"}}}
+	if got, want := e.Ranges(), ">= 1.0.0, < 1.4.7 or <= 0.9"; got != want {
+		t.Errorf("Ranges() = %q, want %q", got, want)
+	}
+	if got := (Entry{}).Ranges(); got != "all versions" {
+		t.Errorf("Ranges() without ranges = %q", got)
+	}
+}
+
+func testDB() *DB {
+	return &DB{Version: dbVersion, Entries: []Entry{
+		{ID: "CVE-1", Engine: "Hasura", Severity: "high", Affected: []Range{{Introduced: "2.0.0", Fixed: "2.10.2"}, {Introduced: "2.11.0", Fixed: "2.11.3"}}},
+		{ID: "GS-DATED", Engine: "Dgraph", Severity: "medium", Affected: []Range{{Fixed: "2023-01-01"}}},
+		{ID: "GS-ALL", Engine: "Hasura", Severity: "low"},
+		{ID: "CVE-2", Engine: "GraphiQL", Severity: "high", Affected: []Range{{Fixed: "1.4.7"}}},
+	}}
+}
+
+func ids(entries []Entry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestLookup(t *testing.T) {
+	db := testDB()
+	tests := []struct {
+		engine, version string
+		want            []string
+	}{
+		{"Hasura", "v2.10.1", []string{"CVE-1", "GS-ALL"}},
+		{"hasura", "2.10.2", []string{"GS-ALL"}},
+		{"HASURA", "2.11.2", []string{"CVE-1", "GS-ALL"}},
+		{"Dgraph", "2022-11-30", []string{"GS-DATED"}},
+		{"Dgraph", "2023.01.01", nil},
+		// An unknown version only matches entries for every version.
+		{"Hasura", "", []string{"GS-ALL"}},
+		{"GraphiQL", "1.4.6", []string{"CVE-2"}},
+		{"GraphiQL", "1.4.7", nil},
+		{"Apollo Server", "2.0.0", nil},
+	}
+	for _, tt := range tests {
+		if got := ids(db.Lookup(tt.engine, tt.version)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lookup(%q, %q) = %v, want %v", tt.engine, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	db := Embedded()
+	if len(db.Entries) == 0 {
+		t.Fatal("the embedded database is empty")
+	}
+	if got := ids(db.Lookup("GraphQL Playground", "1.7.27")); !reflect.DeepEqual(got, []string{"CVE-2021-41249", "GS-PLAYGROUND-UNMAINTAINED"}) {
+		t.Errorf("Lookup(GraphQL Playground 1.7.27) = %v", got)
+	}
+	if got := ids(db.Lookup("Hasura", "v1.3.3")); !reflect.DeepEqual(got, []string{"GS-HASURA-V1"}) {
+		t.Errorf("Lookup(Hasura v1.3.3) = %v", got)
+	}
+}
+
+func TestLoadOverridesEmbedded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vulndb.json")
+	if err := os.WriteFile(path, []byte(`{"version":1,"entries":[{"id":"GS-LOCAL","engine":"Hasura","title":"Local advisory","severity":"info"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Use(db)
+	defer Use(nil)
+	if got := ids(Lookup("Hasura", "v1.3.3")); !reflect.DeepEqual(got, []string{"GS-LOCAL"}) {
+		t.Errorf("Lookup() = %v, want the entries of the override only", got)
+	}
+	Use(nil)
+	if got := ids(Lookup("Hasura", "v1.3.3")); !reflect.DeepEqual(got, []string{"GS-HASURA-V1"}) {
+		t.Errorf("Lookup() after resetting = %v, want the embedded entries", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range []struct{ data, want string }{
+		{`{"version":1,"entries":[`, "error parsing"},
+		{`{"version":2,"entries":[]}`, "unsupported vulnerability database version 2"},
+		{`{"version":1,"entries":[{"engine":"Hasura","severity":"low"}]}`, "entry 0 needs an id and an engine"},
+		{`{"version":1,"entries":[{"id":"X","severity":"low"}]}`, "entry 0 needs an id and an engine"},
+		{`{"version":1,"entries":[{"id":"X","engine":"Hasura","severity":"urgent"}]}`, `entry X has invalid severity "urgent"`},
+	} {
+		if _, err := Parse([]byte(tt.data)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%s) error = %v, want one containing %q", tt.data, err, tt.want)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.Contains(err.Error(), "error reading") {
+		t.Errorf("Load() of a missing file = %v", err)
+	}
+}
@@ -0,0 +1,431 @@
+package workspace
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/clock"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+)
+
+// Bundle layout: the files of the workspace below bundleRoot, then the index.
+const (
+	bundleIndex = "index.json"
+	bundleRoot  = "workspace/"
+)
+
+// maxBundleIndex bounds the size of the index read back from a bundle.
+const maxBundleIndex = 64 << 20
+
+// BundleOptions controls what CreateBundle writes.
+type BundleOptions struct {
+	// Redact masks sensitive values in the JSON, NDJSON and text files of the
+	// workspace, as --redact does for reports.
+	Redact bool
+	// Secrets are credential values masked wherever they appear in those files.
+	Secrets []string
+}
+
+// BundleIndex lists the files of a bundle. It is its last entry.
+type BundleIndex struct {
+	Tool     string       `json:"tool"`
+	Version  string       `json:"version"`
+	Created  time.Time    `json:"created"`
+	Redacted bool         `json:"redacted"`
+	Files    []BundleFile `json:"files"`
+}
+
+// BundleFile is a workspace file as stored in a bundle, after redaction.
+type BundleFile struct {
+	// Path is slash-separated and relative to the workspace.
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Redactions counts the values masked in the file.
+	Redactions int `json:"redactions,omitempty"`
+}
+
+// Redactions returns the number of values masked across the bundle.
+func (idx *BundleIndex) Redactions() int {
+	n := 0
+	for _, f := range idx.Files {
+		n += f.Redactions
+	}
+	return n
+}
+
+// CreateBundle packages every regular file below dir into the gzip-compressed
+// tarball out, ending with its index. Files are streamed into the archive;
+// with opts.Redact the redactable ones are first rewritten into a temporary
+// file, so that their size is known before they are written. A bundle written
+// inside dir is not bundled into itself, and symbolic links are left out.
+func CreateBundle(dir, out string, opts BundleOptions) (*BundleIndex, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening workspace: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("workspace %s is not a directory", dir)
+	}
+	outAbs, err := filepath.Abs(out)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving bundle path: %w", err)
+	}
+
+	f, err := artifacts.Create(out, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error creating bundle: %w", err)
+	}
+	defer f.Abort()
+	tmpAbs, err := filepath.Abs(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("error resolving bundle path: %w", err)
+	}
+	index, err := writeBundle(f, dir, map[string]bool{outAbs: true, tmpAbs: true}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Commit(); err != nil {
+		return nil, fmt.Errorf("error writing bundle: %w", err)
+	}
+	return index, nil
+}
+
+// writeBundle writes the bundle of dir to w, skipping the files at the
+// absolute paths of skip: the bundle itself and the temporary file it is
+// written to.
+func writeBundle(w io.Writer, dir string, skip map[string]bool, opts BundleOptions) (*BundleIndex, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	index := &BundleIndex{Tool: "graphspecter", Version: version.Version, Created: clock.Now(), Redacted: opts.Redact, Files: []BundleFile{}}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := bundleRoot + filepath.ToSlash(rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: int64(info.Mode().Perm()), ModTime: info.ModTime()})
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		if abs, err := filepath.Abs(p); err == nil && skip[abs] {
+			return nil
+		}
+		file, err := addBundleFile(tw, p, name, info, opts)
+		if err != nil {
+			return err
+		}
+		file.Path = filepath.ToSlash(rel)
+		index.Files = append(index.Files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error bundling workspace: %w", err)
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling bundle index: %w", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: bundleIndex, Mode: 0600, Size: int64(len(data)), ModTime: index.Created}); err != nil {
+		return nil, fmt.Errorf("error writing bundle index: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, fmt.Errorf("error writing bundle index: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("error writing bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("error writing bundle: %w", err)
+	}
+	return index, nil
+}
+
+// addBundleFile streams the file at p into tw as name.
+func addBundleFile(tw *tar.Writer, p, name string, info fs.FileInfo, opts BundleOptions) (BundleFile, error) {
+	src, err := os.Open(p)
+	if err != nil {
+		return BundleFile{}, err
+	}
+	defer src.Close()
+
+	var file BundleFile
+	content, size := io.Reader(src), info.Size()
+	if kind := redactionKind(p); opts.Redact && kind != "" {
+		tmp, err := os.CreateTemp("", "graphspecter-bundle-*")
+		if err != nil {
+			return BundleFile{}, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if file.Redactions, err = redactFile(tmp, src, kind, opts.Secrets); err != nil {
+			return BundleFile{}, fmt.Errorf("error redacting %s: %w", p, err)
+		}
+		if size, err = tmp.Seek(0, io.SeekCurrent); err != nil {
+			return BundleFile{}, err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return BundleFile{}, err
+		}
+		content = tmp
+	}
+
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: int64(info.Mode().Perm()), Size: size, ModTime: info.ModTime()}); err != nil {
+		return BundleFile{}, err
+	}
+	sum := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(tw, sum), content, size); err != nil {
+		return BundleFile{}, fmt.Errorf("error reading %s: %w", p, err)
+	}
+	file.Size = size
+	file.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	return file, nil
+}
+
+// Redaction kinds of workspace files
+const (
+	redactJSON   = "json"
+	redactNDJSON = "ndjson"
+	redactText   = "text"
+)
+
+// textExtensions are the files masked line by line as plain text.
+var textExtensions = map[string]bool{
+	".md": true, ".html": true, ".csv": true, ".txt": true, ".log": true,
+	".graphql": true, ".gql": true, ".yaml": true, ".yml": true, ".http": true,
+}
+
+// redactionKind returns how the file at p is redacted, or "" when it is
+// bundled as it is.
+func redactionKind(p string) string {
+	switch ext := strings.ToLower(filepath.Ext(p)); {
+	case ext == ".json":
+		return redactJSON
+	case ext == ".ndjson" || ext == ".jsonl":
+		return redactNDJSON
+	case textExtensions[ext]:
+		return redactText
+	}
+	return ""
+}
+
+// redactFile writes src to dst with sensitive values masked and returns the
+// number masked. JSON documents are redacted as a tree, NDJSON and text line by
+// line; a JSON file that does not parse is redacted as text.
+func redactFile(dst io.Writer, src io.ReadSeeker, kind string, secrets []string) (int, error) {
+	if kind == redactJSON {
+		var doc interface{}
+		dec := json.NewDecoder(src)
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err == nil && !dec.More() {
+			masked, n := redact.Tree(doc)
+			data, err := json.MarshalIndent(masked, "", "  ")
+			if err != nil {
+				return 0, err
+			}
+			text, m := maskText(string(data), secrets)
+			_, err = io.WriteString(dst, text+"\n")
+			return n + m, err
+		}
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		kind = redactText
+	}
+
+	r := bufio.NewReader(src)
+	total := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			out, n := redactLine(line, kind, secrets)
+			total += n
+			if _, werr := dst.Write(out); werr != nil {
+				return total, werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// redactLine masks a line of an NDJSON or text file, keeping its line ending.
+func redactLine(line []byte, kind string, secrets []string) ([]byte, int) {
+	body := bytes.TrimRight(line, "\r\n")
+	ending := line[len(body):]
+	n := 0
+	text := string(body)
+	if kind == redactNDJSON {
+		var doc interface{}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if dec.Decode(&doc) == nil {
+			masked, m := redact.Tree(doc)
+			if data, err := json.Marshal(masked); err == nil {
+				text, n = string(data), m
+			}
+		}
+	}
+	text, m := maskText(text, secrets)
+	return append([]byte(text), ending...), n + m
+}
+
+// maskText masks secrets and the credentials of URLs in s.
+func maskText(s string, secrets []string) (string, int) {
+	s, n := redact.Text(s, secrets)
+	if masked := redact.URL(s); masked != s {
+		s = masked
+		n++
+	}
+	return s, n
+}
+
+// ExtractBundle restores the bundle at archive into dir, which must not exist
+// or be empty. Every file is checked against the index; on any error the
+// files written so far are removed.
+func ExtractBundle(archive, dir string) (*BundleIndex, error) {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("workspace %s already exists and is not empty", dir)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error opening workspace: %w", err)
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, fmt.Errorf("error opening bundle: %w", err)
+	}
+	defer f.Close()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating workspace: %w", err)
+	}
+
+	index, err := readBundle(f, dir)
+	if err != nil {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			os.RemoveAll(filepath.Join(dir, e.Name()))
+		}
+		return nil, fmt.Errorf("error extracting bundle %s: %w", archive, err)
+	}
+	return index, nil
+}
+
+// readBundle extracts the bundle read from r into dir and verifies the
+// extracted files against its index.
+func readBundle(r io.Reader, dir string) (*BundleIndex, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	var index *BundleIndex
+	sums := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == bundleIndex {
+			index = &BundleIndex{}
+			if err := json.NewDecoder(io.LimitReader(tr, maxBundleIndex)).Decode(index); err != nil {
+				return nil, fmt.Errorf("invalid bundle index: %w", err)
+			}
+			continue
+		}
+		rel := strings.TrimSuffix(strings.TrimPrefix(hdr.Name, bundleRoot), "/")
+		if !strings.HasPrefix(hdr.Name, bundleRoot) || rel == "" || !filepath.IsLocal(filepath.FromSlash(rel)) || path.Clean(rel) != rel {
+			return nil, fmt.Errorf("unexpected entry %q", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, fs.FileMode(hdr.Mode).Perm()|0700); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			if _, seen := sums[rel]; seen {
+				return nil, fmt.Errorf("duplicate entry %q", hdr.Name)
+			}
+			if sums[rel], err = extractBundleFile(tr, target, fs.FileMode(hdr.Mode).Perm()); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("entry %q is not a regular file or directory", hdr.Name)
+		}
+	}
+
+	if index == nil {
+		return nil, errors.New("the bundle has no index")
+	}
+	var mismatched []string
+	for _, file := range index.Files {
+		sum, ok := sums[file.Path]
+		if !ok || sum != file.SHA256 {
+			mismatched = append(mismatched, file.Path)
+		}
+		delete(sums, file.Path)
+	}
+	for rel := range sums {
+		mismatched = append(mismatched, rel)
+	}
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		return nil, fmt.Errorf("files missing, altered or not in the index: %s", strings.Join(mismatched, ", "))
+	}
+	return index, nil
+}
+
+// extractBundleFile writes the current entry of tr to target and returns its
+// SHA-256 digest.
+func extractBundleFile(tr *tar.Reader, target string, perm fs.FileMode) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return "", err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm|0600)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, sum), tr)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
@@ -0,0 +1,117 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/clock"
+)
+
+// DefaultOperationCache is the operation cache used when none is given.
+const DefaultOperationCache = ".graphspecter-operations.json"
+
+// cacheVersion is the version of the operation cache format.
+const cacheVersion = 1
+
+// CachedOperation is an operation the workspace has seen, under the
+// canonical hash of its document, with the endpoints it was sent to.
+type CachedOperation struct {
+	Hash  string `json:"hash"`
+	Name  string `json:"name,omitempty"`
+	Kind  string `json:"kind"`
+	Query string `json:"query"`
+	// Endpoints and Sources, the files the operation was imported from, are
+	// sorted.
+	Endpoints []string  `json:"endpoints"`
+	Sources   []string  `json:"sources"`
+	Calls     int       `json:"calls"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// OperationCache collects the operations imported into a workspace across
+// runs, so that an operation captured several times, or at several
+// endpoints, is kept once.
+type OperationCache struct {
+	path string
+
+	Version    int                         `json:"version"`
+	Operations map[string]*CachedOperation `json:"operations"`
+}
+
+// LoadOperationCache reads the operation cache saved at path. A missing file
+// yields an empty cache.
+func LoadOperationCache(path string) (*OperationCache, error) {
+	c := &OperationCache{path: path, Version: cacheVersion, Operations: make(map[string]*CachedOperation)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading operation cache: %w", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("error parsing operation cache %s: %w", path, err)
+	}
+	if c.Version != cacheVersion {
+		return nil, fmt.Errorf("unsupported operation cache version %d in %s", c.Version, path)
+	}
+	if c.Operations == nil {
+		c.Operations = make(map[string]*CachedOperation)
+	}
+	return c, nil
+}
+
+// Add merges op, imported from source, into the cache and reports whether
+// the cache did not hold it yet.
+func (c *OperationCache) Add(op HAROperation, source string) bool {
+	now := clock.Now()
+	cached, ok := c.Operations[op.Hash]
+	if !ok {
+		cached = &CachedOperation{Hash: op.Hash, Name: op.Name, Kind: op.Kind, Query: op.Query, FirstSeen: now}
+		c.Operations[op.Hash] = cached
+	}
+	if cached.Name == "" {
+		cached.Name = op.Name
+	}
+	cached.Endpoints = addSorted(cached.Endpoints, op.Endpoints...)
+	cached.Sources = addSorted(cached.Sources, source)
+	cached.Calls += op.Calls
+	cached.LastSeen = now
+	return !ok
+}
+
+// Len returns the number of operations of the cache.
+func (c *OperationCache) Len() int {
+	return len(c.Operations)
+}
+
+// Save writes the cache to its file.
+func (c *OperationCache) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling operation cache: %w", err)
+	}
+	if err := artifacts.WriteFile(c.path, data, 0600); err != nil {
+		return fmt.Errorf("error writing operation cache: %w", err)
+	}
+	return nil
+}
+
+// addSorted inserts the values missing from the sorted list.
+func addSorted(list []string, values ...string) []string {
+	for _, v := range values {
+		i := sort.SearchStrings(list, v)
+		if i < len(list) && list[i] == v {
+			continue
+		}
+		list = append(list, "")
+		copy(list[i+1:], list[i:])
+		list[i] = v
+	}
+	return list
+}
@@ -0,0 +1,242 @@
+package workspace
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// harFile is the part of a HAR 1.2 archive the importer reads.
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request struct {
+		Method      string `json:"method"`
+		URL         string `json:"url"`
+		QueryString []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"queryString"`
+		PostData *struct {
+			Text string `json:"text"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Content struct {
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// HARCall is a GraphQL request recorded in a HAR archive, with the response
+// recorded for it.
+type HARCall struct {
+	// Endpoint is the URL of the request without its query string.
+	Endpoint string
+	types.GraphQLRequest
+	// Hash is the canonical hash of Query (see gql.CanonicalHash).
+	Hash string
+	// Kind is the kind of the operation executed, gql.OperationQuery when the
+	// document cannot be parsed.
+	Kind string
+	// Response is the decoded response of the call, or of its element of a
+	// batch, nil when none was recorded or it is not JSON.
+	Response map[string]interface{}
+}
+
+// HARImport is the GraphQL traffic of a HAR archive.
+type HARImport struct {
+	// Entries is the number of entries of the archive.
+	Entries int
+	// Calls are the GraphQL requests of the archive, in order; the
+	// operations of a batch are calls of their own.
+	Calls []HARCall
+	// Skipped is the number of GraphQL requests without a document that
+	// parses, such as persisted queries sent by hash only.
+	Skipped int
+}
+
+// ImportHAR reads the GraphQL requests recorded in the HAR archive at path:
+// POST requests whose JSON body is a query or a batch of queries, and GET
+// requests with a query parameter.
+func ImportHAR(path string) (*HARImport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading HAR %s: %w", path, err)
+	}
+	var f harFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("error parsing HAR %s: %w", path, err)
+	}
+
+	imp := &HARImport{Entries: len(f.Log.Entries)}
+	for _, e := range f.Log.Entries {
+		requests, isGraphQL := harRequests(e)
+		if !isGraphQL {
+			continue
+		}
+		responses := harResponses(e, len(requests))
+		endpoint := e.Request.URL
+		if u, err := url.Parse(endpoint); err == nil {
+			u.RawQuery, u.Fragment = "", ""
+			endpoint = u.String()
+		}
+		for i, req := range requests {
+			hash, err := gql.CanonicalHash(req.Query)
+			if err != nil {
+				imp.Skipped++
+				continue
+			}
+			call := HARCall{Endpoint: endpoint, GraphQLRequest: req, Hash: hash, Kind: gql.OperationQuery, Response: responses[i]}
+			if doc, err := gql.Parse(req.Query); err == nil {
+				if op := selectedOperation(doc, req.OperationName); op != nil {
+					call.Kind = op.Kind
+					if call.OperationName == "" {
+						call.OperationName = op.Name
+					}
+				}
+			}
+			imp.Calls = append(imp.Calls, call)
+		}
+	}
+	return imp, nil
+}
+
+// selectedOperation returns the operation of doc named name, or its only
+// operation when name is empty.
+func selectedOperation(doc *gql.Document, name string) *gql.Operation {
+	if name != "" {
+		return doc.OperationByName(name)
+	}
+	if len(doc.Operations) == 1 {
+		return doc.Operations[0]
+	}
+	return nil
+}
+
+// harRequests decodes the GraphQL requests of e. It reports whether e is a
+// GraphQL request at all, even when none of its requests holds a query.
+func harRequests(e harEntry) ([]types.GraphQLRequest, bool) {
+	switch {
+	case strings.EqualFold(e.Request.Method, "GET"):
+		var req types.GraphQLRequest
+		found := false
+		for _, p := range e.Request.QueryString {
+			// HAR writers differ in whether they decode parameter values.
+			value := p.Value
+			if unescaped, err := url.QueryUnescape(value); err == nil {
+				value = unescaped
+			}
+			switch p.Name {
+			case "query":
+				req.Query, found = value, true
+			case "operationName":
+				req.OperationName = value
+			case "variables":
+				_ = json.Unmarshal([]byte(value), &req.Variables)
+			case "extensions":
+				found = true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+		if req.Query == "" {
+			return []types.GraphQLRequest{{}}, true
+		}
+		return []types.GraphQLRequest{req}, true
+	case e.Request.PostData != nil:
+		body := strings.TrimSpace(e.Request.PostData.Text)
+		if strings.HasPrefix(body, "[") {
+			var batch []types.GraphQLRequest
+			if err := json.Unmarshal([]byte(body), &batch); err != nil || len(batch) == 0 {
+				return nil, false
+			}
+			return batch, true
+		}
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(body), &members); err != nil {
+			return nil, false
+		}
+		_, hasQuery := members["query"]
+		_, hasExtensions := members["extensions"]
+		if !hasQuery && !hasExtensions {
+			return nil, false
+		}
+		var req types.GraphQLRequest
+		_ = json.Unmarshal([]byte(body), &req)
+		return []types.GraphQLRequest{req}, true
+	}
+	return nil, false
+}
+
+// harResponses decodes the response of e into n responses, one per request
+// of a batch, leaving nil those that were not recorded or are not JSON.
+func harResponses(e harEntry, n int) []map[string]interface{} {
+	responses := make([]map[string]interface{}, n)
+	text := e.Response.Content.Text
+	if e.Response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return responses
+		}
+		text = string(decoded)
+	}
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "[") {
+		var batch []map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &batch); err == nil {
+			copy(responses, batch)
+		}
+		return responses
+	}
+	var resp map[string]interface{}
+	if n == 1 && json.Unmarshal([]byte(text), &resp) == nil {
+		responses[0] = resp
+	}
+	return responses
+}
+
+// HAROperation is an operation of a HAR archive, the calls with the same
+// canonical hash merged.
+type HAROperation struct {
+	Hash string
+	Name string
+	Kind string
+	// Query and Variables are those of the first call.
+	Query     string
+	Variables map[string]interface{}
+	// Endpoints are the endpoints the operation was sent to, sorted.
+	Endpoints []string
+	// Calls is the number of calls of the operation.
+	Calls int
+}
+
+// Operations merges the calls of imp by canonical hash, in the order they
+// were first sent.
+func (imp *HARImport) Operations() []HAROperation {
+	var ops []HAROperation
+	index := make(map[string]int)
+	for _, c := range imp.Calls {
+		i, ok := index[c.Hash]
+		if !ok {
+			i = len(ops)
+			index[c.Hash] = i
+			ops = append(ops, HAROperation{Hash: c.Hash, Name: c.OperationName, Kind: c.Kind, Query: c.Query, Variables: c.Variables})
+		}
+		ops[i].Calls++
+		ops[i].Endpoints = addSorted(ops[i].Endpoints, c.Endpoint)
+	}
+	return ops
+}
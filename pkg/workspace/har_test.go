@@ -0,0 +1,104 @@
+package workspace
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+)
+
+func TestImportHAR(t *testing.T) {
+	imp, err := ImportHAR(filepath.Join("testdata", "capture.har"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The persisted query sent by hash only is skipped, and the script
+	// request is not GraphQL.
+	if imp.Entries != 6 || len(imp.Calls) != 5 || imp.Skipped != 1 {
+		t.Fatalf("entries %d, calls %d, skipped %d", imp.Entries, len(imp.Calls), imp.Skipped)
+	}
+
+	first := imp.Calls[0]
+	if first.Endpoint != "https://api.example/graphql" || first.OperationName != "GetUser" || first.Kind != gql.OperationQuery || first.Variables["id"] != "1" {
+		t.Errorf("first call = %+v", first)
+	}
+	if name := imp.Calls[1].Response["data"].(map[string]interface{})["user"].(map[string]interface{})["id"]; name != "2" {
+		t.Errorf("base64 response not decoded: %v", imp.Calls[1].Response)
+	}
+	if get := imp.Calls[2]; get.Query != "{ posts(first: 5) { id } }" || get.Response == nil {
+		t.Errorf("GET call = %+v", get)
+	}
+	batch := imp.Calls[3:]
+	if batch[0].Kind != gql.OperationQuery || batch[1].Kind != gql.OperationMutation || batch[1].Response["data"] == nil {
+		t.Errorf("batch calls = %+v", batch)
+	}
+}
+
+func TestHAROperations(t *testing.T) {
+	imp, err := ImportHAR(filepath.Join("testdata", "capture.har"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := imp.Operations()
+	if len(ops) != 3 {
+		t.Fatalf("%d operations, want GetUser, posts and logout", len(ops))
+	}
+	// The two captures of GetUser differ in whitespace, comments and
+	// variables, the posts queries in their literals.
+	user, posts := ops[0], ops[1]
+	if user.Name != "GetUser" || user.Calls != 2 || !reflect.DeepEqual(user.Endpoints, []string{"https://api.example/graphql", "https://eu.api.example/graphql"}) {
+		t.Errorf("GetUser = %+v", user)
+	}
+	if user.Variables["id"] != "1" || !strings.HasPrefix(user.Query, "query GetUser") {
+		t.Errorf("GetUser keeps the document and variables of its first call: %+v", user)
+	}
+	if posts.Calls != 2 || len(posts.Endpoints) != 1 {
+		t.Errorf("posts = %+v", posts)
+	}
+	if ops[2].Kind != gql.OperationMutation {
+		t.Errorf("logout = %+v", ops[2])
+	}
+}
+
+func TestImportHARErrors(t *testing.T) {
+	if _, err := ImportHAR(filepath.Join("testdata", "missing.har")); err == nil {
+		t.Error("ImportHAR read a missing file")
+	}
+	if _, err := ImportHAR("testdata"); err == nil {
+		t.Error("ImportHAR read a directory")
+	}
+}
+
+func TestOperationCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultOperationCache)
+	c, err := LoadOperationCache(path)
+	if err != nil || c.Len() != 0 {
+		t.Fatalf("LoadOperationCache of a missing file = %v, %v", c, err)
+	}
+	op := HAROperation{Hash: "h1", Name: "GetUser", Kind: gql.OperationQuery, Query: "query GetUser { me { id } }", Endpoints: []string{"https://b/graphql"}, Calls: 2}
+	if !c.Add(op, "one.har") {
+		t.Error("Add of a new operation reported it known")
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = LoadOperationCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op.Endpoints = []string{"https://a/graphql", "https://b/graphql"}
+	if c.Add(op, "two.har") {
+		t.Error("Add of a saved operation reported it new")
+	}
+	c.Add(HAROperation{Hash: "h2", Kind: gql.OperationQuery, Query: "{ a }", Endpoints: []string{"https://a/graphql"}, Calls: 1}, "two.har")
+	got := c.Operations["h1"]
+	if c.Len() != 2 || got.Calls != 4 || !reflect.DeepEqual(got.Endpoints, []string{"https://a/graphql", "https://b/graphql"}) || !reflect.DeepEqual(got.Sources, []string{"one.har", "two.har"}) {
+		t.Errorf("cached = %+v", got)
+	}
+	if got.FirstSeen.After(got.LastSeen) {
+		t.Errorf("first seen %v after last seen %v", got.FirstSeen, got.LastSeen)
+	}
+}
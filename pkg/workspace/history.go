@@ -0,0 +1,241 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/gql"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// DefaultHistoryFile is the history log used when none is given.
+const DefaultHistoryFile = "history.ndjson"
+
+// maxHistoryLine bounds the size of a history entry read back.
+const maxHistoryLine = 64 << 20
+
+// HistoryEntry is a request of the history log. Sensitive variables and
+// headers are masked before it is written.
+type HistoryEntry struct {
+	// ID is a short random id, unique within a log.
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Endpoint string    `json:"endpoint"`
+	// Module is the part of the run that sent the request, such as "batch" or a check id.
+	Module    string `json:"module,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	// Hash is the canonical hash of Query (see gql.CanonicalHash).
+	Hash      string                 `json:"hash,omitempty"`
+	Query     string                 `json:"query,omitempty"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+	// Body is the request when it is not a single query, such as a batch.
+	Body       json.RawMessage   `json:"body,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Status     int               `json:"status"`
+	DurationMs int64             `json:"durationMs"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// History appends entries to a history log. It is safe for concurrent use.
+type History struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenHistory opens the history log at path for appending, creating it when
+// it does not exist.
+func OpenHistory(path string) (*History, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening history %s: %w", path, err)
+	}
+	return &History{path: path, file: f}, nil
+}
+
+// Path returns the file of the history log.
+func (h *History) Path() string {
+	return h.path
+}
+
+// Append writes e as one line of the log, giving it an id when it has none.
+// Each entry is written with a single append, so a crash never leaves a
+// partial entry before a complete one.
+func (h *History) Append(e HistoryEntry) error {
+	if e.ID == "" {
+		e.ID = newHistoryID()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("error encoding history entry: %w", err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := h.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing history %s: %w", h.path, err)
+	}
+	return nil
+}
+
+// Record appends the entry of a request sent through the shared client.
+func (h *History) Record(r network.SentRequest) error {
+	return h.Append(NewHistoryEntry(r))
+}
+
+// Close closes the log.
+func (h *History) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.file.Close()
+}
+
+// NewHistoryEntry describes r with its credentials masked: variables and body
+// values with sensitive names, credential-bearing headers and their values
+// wherever they appear in the query.
+func NewHistoryEntry(r network.SentRequest) HistoryEntry {
+	e := HistoryEntry{
+		Time:       r.Time.UTC(),
+		Endpoint:   redact.URL(r.URL),
+		Module:     r.Module,
+		Status:     r.Status,
+		DurationMs: r.Duration.Milliseconds(),
+	}
+	if r.Err != nil {
+		e.Error = r.Err.Error()
+	}
+	secrets := redact.Secrets(r.Headers)
+	e.Headers, _ = redact.Headers(r.Headers)
+
+	if single, ok := singleRequest(r.Body); ok {
+		e.Query, _ = redact.Text(single.Query, secrets)
+		e.Operation = single.OperationName
+		if len(single.Variables) > 0 {
+			e.Variables, _ = redact.Map(single.Variables)
+		}
+	} else {
+		var body interface{}
+		if err := json.Unmarshal(r.Body, &body); err == nil {
+			masked, _ := redact.Tree(body)
+			e.Body, _ = json.Marshal(masked)
+		}
+	}
+	if e.Query != "" {
+		e.Hash, _ = gql.CanonicalHash(e.Query)
+		if e.Operation == "" {
+			if doc, err := gql.Parse(e.Query); err == nil && len(doc.Operations) == 1 {
+				e.Operation = doc.Operations[0].Name
+			}
+		}
+	}
+	return e
+}
+
+// singleRequest decodes body when it is a single query with its variables and
+// operation name. Bodies with other members, such as extensions, or holding a
+// batch are not.
+func singleRequest(body []byte) (types.GraphQLRequest, bool) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return types.GraphQLRequest{}, false
+	}
+	for k := range members {
+		if k != "query" && k != "variables" && k != "operationName" {
+			return types.GraphQLRequest{}, false
+		}
+	}
+	var req types.GraphQLRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.Query == "" {
+		return types.GraphQLRequest{}, false
+	}
+	return req, true
+}
+
+// newHistoryID returns a random 8-character hex id.
+func newHistoryID() string {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// LoadHistory reads the entries of the history log at path, oldest first.
+func LoadHistory(path string) ([]HistoryEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxHistoryLine)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("error parsing history %s line %d: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// HistoryFilter selects history entries. Zero fields match every entry.
+type HistoryFilter struct {
+	// Since keeps the entries sent at or after it.
+	Since time.Time
+	// Operation keeps the entries of the operation with this name.
+	Operation string
+}
+
+// FilterHistory returns the entries matching f, in their order.
+func FilterHistory(entries []HistoryEntry, f HistoryFilter) []HistoryEntry {
+	var matched []HistoryEntry
+	for _, e := range entries {
+		if !f.Since.IsZero() && e.Time.Before(f.Since) {
+			continue
+		}
+		if f.Operation != "" && e.Operation != f.Operation {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	return matched
+}
+
+// FindHistory returns the entry whose id is id or starts with it. A prefix
+// shared by several entries is an error.
+func FindHistory(entries []HistoryEntry, id string) (HistoryEntry, error) {
+	var found []HistoryEntry
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+		if id != "" && strings.HasPrefix(e.ID, id) {
+			found = append(found, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return HistoryEntry{}, fmt.Errorf("no history entry %q", id)
+	case 1:
+		return found[0], nil
+	default:
+		return HistoryEntry{}, fmt.Errorf("history id %q is ambiguous: %d entries start with it", id, len(found))
+	}
+}
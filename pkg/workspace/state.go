@@ -0,0 +1,166 @@
+// Package workspace keeps the files that let an interrupted scan pick up where it stopped
+package workspace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/clock"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// DefaultStateFile is the state file used when none is given.
+const DefaultStateFile = ".graphspecter-state.json"
+
+// stateVersion is the version of the state file format.
+const stateVersion = 1
+
+// Target statuses
+const (
+	StatusPending    = "pending"
+	StatusInProgress = "in-progress"
+	StatusDone       = "done"
+)
+
+// TargetState is the progress of a single target.
+type TargetState struct {
+	Status string `json:"status"`
+	// Digest identifies the set of findings of a completed target.
+	Digest    string           `json:"digest,omitempty"`
+	Findings  []report.Finding `json:"findings,omitempty"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+}
+
+// State records which targets of a multi-target scan are done. Every change is
+// written to disk immediately, so a scan killed at any point can be resumed.
+// It is safe for concurrent use.
+type State struct {
+	path string
+
+	mu      sync.Mutex
+	Version int                     `json:"version"`
+	Targets map[string]*TargetState `json:"targets"`
+}
+
+// NewState returns an empty state saved to path.
+func NewState(path string) *State {
+	return &State{path: path, Version: stateVersion, Targets: make(map[string]*TargetState)}
+}
+
+// LoadState reads the state saved at path. A missing file yields an empty state.
+// Targets left in progress by an interrupted run are queued again.
+func LoadState(path string) (*State, error) {
+	s := NewState(path)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("error parsing state file %s: %w", path, err)
+	}
+	if s.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported state file version %d in %s", s.Version, path)
+	}
+	if s.Targets == nil {
+		s.Targets = make(map[string]*TargetState)
+	}
+	for _, t := range s.Targets {
+		if t.Status == StatusInProgress {
+			t.Status = StatusPending
+		}
+	}
+	return s, nil
+}
+
+// Add registers targets as pending unless the state already knows them.
+func (s *State) Add(targets ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, target := range targets {
+		if _, ok := s.Targets[target]; !ok {
+			s.Targets[target] = &TargetState{Status: StatusPending, UpdatedAt: clock.Now()}
+		}
+	}
+	return s.save()
+}
+
+// Done reports whether target was completed, and returns its findings if so.
+func (s *State) Done(target string) ([]report.Finding, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.Targets[target]
+	if !ok || t.Status != StatusDone {
+		return nil, false
+	}
+	return t.Findings, true
+}
+
+// Start marks target as in progress.
+func (s *State) Start(target string) error {
+	return s.set(target, &TargetState{Status: StatusInProgress, UpdatedAt: clock.Now()})
+}
+
+// Finish marks target as done with findings.
+func (s *State) Finish(target string, findings []report.Finding) error {
+	return s.set(target, &TargetState{
+		Status:    StatusDone,
+		Digest:    Digest(findings),
+		Findings:  findings,
+		UpdatedAt: clock.Now(),
+	})
+}
+
+// Counts returns the number of targets in each status.
+func (s *State) Counts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int)
+	for _, t := range s.Targets {
+		counts[t.Status]++
+	}
+	return counts
+}
+
+func (s *State) set(target string, t *TargetState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Targets[target] = t
+	return s.save()
+}
+
+// save writes the state to path. The caller holds s.mu.
+func (s *State) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling state: %w", err)
+	}
+	if err := artifacts.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	return nil
+}
+
+// Digest returns a hash of the identity of findings that does not depend on their order.
+func Digest(findings []report.Finding) string {
+	keys := make([]string, len(findings))
+	for i, f := range findings {
+		keys[i] = f.ID + "\x00" + f.Endpoint + "\x00" + f.Severity + "\x00" + f.Evidence
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
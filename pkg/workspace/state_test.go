@@ -0,0 +1,147 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+func stateTargets(n int) []string {
+	targets := make([]string, n)
+	for i := range targets {
+		targets[i] = fmt.Sprintf("https://t%d.example/graphql", i+1)
+	}
+	return targets
+}
+
+func TestStateResumesAfterInterrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultStateFile)
+	targets := stateTargets(10)
+
+	// The first run finishes targets 1 to 3 and is killed while scanning 4.
+	s := NewState(path)
+	if err := s.Add(targets...); err != nil {
+		t.Fatal(err)
+	}
+	for i, target := range targets[:3] {
+		if err := s.Start(target); err != nil {
+			t.Fatal(err)
+		}
+		findings := []report.Finding{{ID: "introspection-enabled", Endpoint: target, Severity: report.SeverityMedium, Evidence: fmt.Sprint(i)}}
+		if err := s.Finish(target, findings); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Start(targets[3]); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{StatusDone: 3, StatusPending: 7}; !reflect.DeepEqual(resumed.Counts(), want) {
+		t.Errorf("Counts() = %v, want %v", resumed.Counts(), want)
+	}
+	// Add, as a resumed run does, keeps what the state knows.
+	if err := resumed.Add(targets...); err != nil {
+		t.Fatal(err)
+	}
+	var next string
+	for i, target := range targets {
+		findings, done := resumed.Done(target)
+		if done != (i < 3) {
+			t.Errorf("target %d done = %v", i+1, done)
+		}
+		if done && (len(findings) != 1 || findings[0].Endpoint != target) {
+			t.Errorf("target %d findings = %+v, want those of the first run", i+1, findings)
+		}
+		if !done && next == "" {
+			next = target
+		}
+	}
+	if next != targets[3] {
+		t.Errorf("resume continues at %s, want target 4 (%s)", next, targets[3])
+	}
+	if got := resumed.Targets[targets[0]].Digest; got != Digest([]report.Finding{{ID: "introspection-enabled", Endpoint: targets[0], Severity: report.SeverityMedium, Evidence: "0"}}) {
+		t.Errorf("digest of target 1 = %q does not match its findings", got)
+	}
+}
+
+func TestStateConcurrentWorkers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DefaultStateFile)
+	targets := stateTargets(50)
+	s := NewState(path)
+	if err := s.Add(targets...); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			if err := s.Start(target); err != nil {
+				t.Error(err)
+			}
+			if err := s.Finish(target, nil); err != nil {
+				t.Error(err)
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{StatusDone: 50}; !reflect.DeepEqual(loaded.Counts(), want) {
+		t.Errorf("Counts() = %v, want every target done", loaded.Counts())
+	}
+	// Writes go through a temporary file renamed over the state file.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != DefaultStateFile {
+		t.Errorf("directory holds %v, want the state file only", entries)
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	dir := t.TempDir()
+	missing, err := LoadState(filepath.Join(dir, "missing.json"))
+	if err != nil || len(missing.Targets) != 0 {
+		t.Errorf("LoadState() of a missing file = %+v, %v; want an empty state", missing, err)
+	}
+	for name, content := range map[string]string{
+		"corrupt": `{"version":1,"targets":`,
+		"version": `{"version":2,"targets":{}}`,
+	} {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadState(path); err == nil {
+			t.Errorf("LoadState() of a %s file succeeded", name)
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	a := report.Finding{ID: "a", Endpoint: "https://x.example", Severity: report.SeverityLow}
+	b := report.Finding{ID: "b", Endpoint: "https://x.example", Severity: report.SeverityHigh}
+	if Digest([]report.Finding{a, b}) != Digest([]report.Finding{b, a}) {
+		t.Error("Digest() depends on the order of the findings")
+	}
+	changed := b
+	changed.Evidence = "other"
+	if Digest([]report.Finding{a, b}) == Digest([]report.Finding{a, changed}) {
+		t.Error("Digest() ignores the evidence")
+	}
+}
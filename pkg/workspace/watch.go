@@ -0,0 +1,147 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/artifacts"
+	"github.com/CyberRoute/graphspecter/pkg/clock"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// DefaultWatchFile is the watch state file used when none is given.
+const DefaultWatchFile = ".graphspecter-watch.json"
+
+// watchVersion is the version of the watch state file format.
+const watchVersion = 1
+
+// WatchState records the findings of the last iteration of a watch run, so
+// that the next iteration, in this process or after a restart, reports only
+// what changed.
+type WatchState struct {
+	path string
+
+	mu      sync.Mutex
+	Version int `json:"version"`
+	// Iteration is the number of iterations recorded.
+	Iteration int              `json:"iteration"`
+	Findings  []report.Finding `json:"findings"`
+	// Schemas are the schemas introspected by endpoint, see checks.SchemaStore.
+	Schemas   map[string]SchemaRecord `json:"schemas,omitempty"`
+	UpdatedAt time.Time               `json:"updatedAt"`
+}
+
+// SchemaRecord identifies the introspected schema of an endpoint.
+type SchemaRecord struct {
+	// Hash is the introspection.SchemaHash of the schema.
+	Hash string `json:"hash"`
+	// ETag is the entity tag the server sent with it, if any.
+	ETag string `json:"etag,omitempty"`
+}
+
+// LoadWatchState reads the watch state saved at path. A missing file yields
+// an empty state.
+func LoadWatchState(path string) (*WatchState, error) {
+	w := &WatchState{path: path, Version: watchVersion}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading watch state file: %w", err)
+	}
+	if err := json.Unmarshal(data, w); err != nil {
+		return nil, fmt.Errorf("error parsing watch state file %s: %w", path, err)
+	}
+	if w.Version != watchVersion {
+		return nil, fmt.Errorf("unsupported watch state file version %d in %s", w.Version, path)
+	}
+	return w, nil
+}
+
+// Record saves findings as the result of the next iteration and returns the
+// findings added and resolved since the previous one. The first iteration
+// recorded has nothing to compare with and returns neither.
+func (w *WatchState) Record(findings []report.Finding) (added, resolved []report.Finding, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Iteration > 0 {
+		added, resolved = DiffFindings(w.Findings, findings)
+	}
+	w.Iteration++
+	w.Findings = findings
+	w.UpdatedAt = clock.Now()
+
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error marshalling watch state: %w", err)
+	}
+	if err := artifacts.WriteFile(w.path, data, 0600); err != nil {
+		return nil, nil, fmt.Errorf("error writing watch state: %w", err)
+	}
+	return added, resolved, nil
+}
+
+// Schema returns the hash and ETag recorded for the schema of endpoint.
+func (w *WatchState) Schema(endpoint string) (hash, etag string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	r := w.Schemas[endpoint]
+	return r.Hash, r.ETag
+}
+
+// SetSchema records the schema of endpoint, saved with the next iteration.
+func (w *WatchState) SetSchema(endpoint, hash, etag string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Schemas == nil {
+		w.Schemas = make(map[string]SchemaRecord)
+	}
+	w.Schemas[endpoint] = SchemaRecord{Hash: hash, ETag: etag}
+}
+
+// CheckFindings returns the findings of check on endpoint recorded by the
+// last iteration.
+func (w *WatchState) CheckFindings(check, endpoint string) []report.Finding {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var found []report.Finding
+	for _, f := range w.Findings {
+		if f.Check == check && f.Endpoint == endpoint {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// DiffFindings returns the findings of current missing from previous and those
+// of previous missing from current. Findings are matched by check id, endpoint
+// and title, so a finding whose evidence varies from run to run, such as a
+// measured latency, is not reported again.
+func DiffFindings(previous, current []report.Finding) (added, resolved []report.Finding) {
+	before := make(map[string]bool, len(previous))
+	for _, f := range previous {
+		before[findingKey(f)] = true
+	}
+	after := make(map[string]bool, len(current))
+	for _, f := range current {
+		key := findingKey(f)
+		after[key] = true
+		if !before[key] {
+			added = append(added, f)
+		}
+	}
+	for _, f := range previous {
+		if !after[findingKey(f)] {
+			resolved = append(resolved, f)
+		}
+	}
+	return added, resolved
+}
+
+func findingKey(f report.Finding) string {
+	return f.ID + "\x00" + f.Endpoint + "\x00" + f.Title
+}
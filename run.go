@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/manifest"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// exitInterrupted is the exit status of a run stopped by SIGINT or SIGTERM.
+const exitInterrupted = 130
+
+// exitForced is the exit status of a run quit by a second signal before it
+// could stop.
+const exitForced = 131
+
+// forceQuitWindow is how soon after a signal a second one quits the run at once.
+const forceQuitWindow = 5 * time.Second
+
+// runLifecycle is one flag-driven run. Its context is canceled on SIGINT or
+// SIGTERM, and it keeps the run manifest up to date so that every way out of
+// the run, fatal errors included, finalizes the manifest.
+type runLifecycle struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	signals     chan os.Signal
+	interrupted atomic.Bool
+	// lastSignal is when the signal handler last ran.
+	lastSignal time.Time
+	manifest   *manifest.Writer
+	// profile is the bounty profile enforced in the run, recorded in its reports.
+	profile *types.BountyProfile
+}
+
+// startRun starts a run invoked with args, writing its manifest to
+// manifestPath unless it is empty.
+func startRun(manifestPath string, args []string) (*runLifecycle, error) {
+	w, err := manifest.Start(manifestPath, args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &runLifecycle{ctx: ctx, cancel: cancel, signals: make(chan os.Signal, 1), manifest: w}
+
+	signal.Notify(r.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for range r.signals {
+			if r.onSignal(os.Stderr, time.Now(), network.InFlight()) {
+				r.manifest.Finish(exitForced, true)
+				os.Exit(exitForced)
+			}
+		}
+	}()
+	// logger.Fatal and an unusable --log-file exit the process on their own.
+	logger.SetExitHook(func(message string) {
+		r.manifest.Fail(message)
+		r.finish(1)
+	})
+	return r, nil
+}
+
+// onSignal handles a SIGINT or SIGTERM received at now. The first one cancels
+// the run and lists the requests it waits for in w; it reports true when the
+// signal follows the previous one within forceQuitWindow, to quit at once.
+func (r *runLifecycle) onSignal(w io.Writer, now time.Time, inFlight []network.InFlightRequest) bool {
+	last := r.lastSignal
+	r.lastSignal = now
+	if !last.IsZero() && now.Sub(last) <= forceQuitWindow {
+		fmt.Fprintln(w, "Interrupted again, quitting at once")
+		return true
+	}
+	r.interrupted.Store(true)
+	fmt.Fprintf(w, "Interrupted, stopping the run... (interrupt again within %s to quit at once)\n", forceQuitWindow)
+	if len(inFlight) > 0 {
+		fmt.Fprintf(w, "Waiting for %d request(s) in flight:\n", len(inFlight))
+		for _, req := range inFlight {
+			module := req.Module
+			if module == "" {
+				module = "-"
+			}
+			fmt.Fprintf(w, "  %s %s (%s)\n", module, req.Endpoint, now.Sub(req.Started).Round(time.Millisecond))
+		}
+	}
+	r.cancel()
+	return false
+}
+
+// phase starts timing the named phase of the run and returns the function ending it.
+func (r *runLifecycle) phase(name string) func() {
+	return r.manifest.Phase(name)
+}
+
+// artifact records a file or directory the run wrote in the manifest.
+func (r *runLifecycle) artifact(kind, path string) {
+	r.manifest.Artifact(kind, path)
+}
+
+// fail prints the error that stops the run to stderr, records it in the
+// manifest and returns exit status 1.
+func (r *runLifecycle) fail(format string, args ...interface{}) int {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintln(os.Stderr, msg)
+	r.manifest.Fail(msg)
+	return 1
+}
+
+// finish ends the run with exit status code, or exitInterrupted when a signal
+// stopped it, and returns that status once the manifest is finalized.
+func (r *runLifecycle) finish(code int) int {
+	signal.Stop(r.signals)
+	r.cancel()
+	interrupted := r.interrupted.Load()
+	if interrupted {
+		code = exitInterrupted
+	}
+	if err := r.manifest.Finish(code, interrupted); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return code
+}
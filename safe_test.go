@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/report"
+)
+
+// TestSafeRunsOnlyPassiveChecks audits a mock with --safe and expects the
+// report to hold the passive checks and nothing else.
+func TestSafeRunsOnlyPassiveChecks(t *testing.T) {
+	srv, _ := countingServer(t)
+	file := filepath.Join(t.TempDir(), "report.json")
+	if code := runArgs(t, "--base", srv.URL, "--safe", "--report", file); code != 0 {
+		t.Fatalf("exit status %d", code)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r report.Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	want, err := checks.SelectPreset(checks.PresetSafe, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Metadata.Preset != checks.PresetSafe || !reflect.DeepEqual(r.Metadata.Checks, checks.IDs(want)) {
+		t.Errorf("metadata = %+v, want the safe preset and its checks %v", r.Metadata, checks.IDs(want))
+	}
+	if len(r.Checks) == 0 {
+		t.Fatal("the report holds no check results")
+	}
+	for _, result := range r.Checks {
+		c, ok := checks.Lookup(result.Check)
+		if !ok || checks.Safety(c) != checks.SafetyPassive {
+			t.Errorf("--safe ran %s", result.Check)
+		}
+	}
+}
+
+// TestSafeConflictsFailAtStartup expects --safe with any intrusive flag or
+// check to fail before a request is sent.
+func TestSafeConflictsFailAtStartup(t *testing.T) {
+	srv, sent := countingServer(t)
+	for _, args := range [][]string{
+		{"--aggressive"},
+		{"--audit-dos"},
+		{"--audit-ws"},
+		{"--audit-injection"},
+		{"--fuzz-coercion"},
+		{"--duplicate-query", "{ me { id } }"},
+		{"--checks", "batching,parsing-differential"},
+	} {
+		t.Run(args[0], func(t *testing.T) {
+			if code := runArgs(t, append([]string{"--base", srv.URL, "--safe"}, args...)...); code != 1 {
+				t.Errorf("--safe %v exited with %d, want 1", args, code)
+			}
+			if n := sent.Load(); n != 0 {
+				t.Errorf("--safe %v sent %d request(s)", args, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/cli"
+	"github.com/CyberRoute/graphspecter/pkg/clock"
+	"github.com/CyberRoute/graphspecter/pkg/logger"
+	"github.com/CyberRoute/graphspecter/pkg/network"
+	"github.com/CyberRoute/graphspecter/pkg/redact"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+	"github.com/CyberRoute/graphspecter/pkg/version"
+	"github.com/CyberRoute/graphspecter/pkg/workspace"
+)
+
+// watchAudits audits bases every cfg.Watch, counted from the start of each
+// iteration, until the run is interrupted. The findings of each iteration are
+// compared with those of the previous one, recorded in --watch-state, and the
+// new and resolved ones are written to stdout as NDJSON events; new findings
+// are also POSTed to --webhook-url. A failed iteration does not stop the loop,
+// but one whose canary query detected a change of server state does.
+func watchAudits(r *runLifecycle, cfg *types.CLIConfig, bases []string, headers map[string]string, opts cli.AuditOptions) int {
+	state, err := workspace.LoadWatchState(cfg.WatchState)
+	if err != nil {
+		return r.fail("%v", err)
+	}
+	defer r.artifact("watch-state", cfg.WatchState)
+	opts.Schemas = state
+	logger.Info("Watching %d target(s) every %s from iteration %d", len(bases), cfg.Watch, state.Iteration+1)
+
+	for {
+		started := time.Now()
+		// The stats, operations and network events of each report are those of its iteration.
+		network.ResetStats()
+		rep, code := runAudit(r, cfg, bases, headers, opts)
+		if r.ctx.Err() != nil {
+			// The findings of an interrupted iteration are incomplete.
+			return 0
+		}
+
+		event := cli.WatchEvent{Event: cli.WatchIteration, Iteration: state.Iteration + 1}
+		if rep == nil {
+			event.Error = "the audit could not run"
+		} else {
+			if cfg.Redact {
+				rep.Redact(redact.Secrets(headers))
+			}
+			added, resolved, err := state.Record(rep.Findings)
+			if err != nil {
+				logger.Error("Error saving watch state: %v", err)
+			}
+			event.Findings, event.New, event.Resolved = len(rep.Findings), len(added), len(resolved)
+			for i := range added {
+				emitWatchEvent(cli.WatchEvent{Event: cli.WatchNewFinding, Iteration: event.Iteration, Finding: &added[i]})
+			}
+			for i := range resolved {
+				emitWatchEvent(cli.WatchEvent{Event: cli.WatchResolvedFinding, Iteration: event.Iteration, Finding: &resolved[i]})
+			}
+			if len(added) > 0 && cfg.WebhookURL != "" {
+				alert := cli.WatchAlert{Tool: "graphspecter", Version: version.Version, Iteration: event.Iteration, Time: clock.Now(), Endpoints: rep.Endpoints, NewFindings: added}
+				if err := cli.PostWatchAlert(r.ctx, cfg.WebhookURL, alert); err != nil {
+					logger.Error("Error delivering the watch alert: %v", err)
+					event.Error = err.Error()
+				}
+			}
+		}
+		emitWatchEvent(event)
+		if code == exitStateChanged {
+			return code
+		}
+
+		wait := time.NewTimer(time.Until(started.Add(cfg.Watch)))
+		select {
+		case <-r.ctx.Done():
+			wait.Stop()
+			return 0
+		case <-wait.C:
+		}
+	}
+}
+
+// emitWatchEvent writes event to stdout, stamped with the current time.
+func emitWatchEvent(event cli.WatchEvent) {
+	event.Time = clock.Now()
+	if err := cli.WriteWatchEvent(os.Stdout, event); err != nil {
+		logger.Error("Error writing watch event: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/graphspecter/pkg/checks"
+	"github.com/CyberRoute/graphspecter/pkg/cli"
+	"github.com/CyberRoute/graphspecter/pkg/types"
+)
+
+// TestWatchResolvesFixedFinding watches a server that labels its JSON
+// responses text/plain in the first iteration only, and checks that the
+// second iteration reports the finding as resolved.
+func TestWatchResolvesFixedFinding(t *testing.T) {
+	var fixed atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fixed.Load() {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/plain")
+		}
+		w.Write([]byte(`{"errors":[{"message":"introspection is disabled"}]}`))
+	}))
+	defer srv.Close()
+
+	selected, err := checks.Select("introspection", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cfg := &types.CLIConfig{
+		BaseURL:    srv.URL,
+		Watch:      time.Second,
+		WatchState: filepath.Join(dir, "watch.json"),
+		Timeout:    30 * time.Second,
+		OutputFile: filepath.Join(dir, "introspection.json"),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &runLifecycle{ctx: ctx, cancel: cancel}
+
+	// The events are read from stdout as they are written.
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = write
+	defer func() { os.Stdout = stdout }()
+	var events []cli.WatchEvent
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(read)
+		for scanner.Scan() {
+			var event cli.WatchEvent
+			if line := scanner.Text(); !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil || event.Event == "" {
+				continue
+			}
+			events = append(events, event)
+			if event.Event != cli.WatchIteration {
+				continue
+			}
+			// The server is fixed before the second iteration starts, and the
+			// watch stopped after it.
+			fixed.Store(true)
+			if event.Iteration == 2 {
+				cancel()
+			}
+		}
+	}()
+
+	code := watchAudits(r, cfg, []string{srv.URL}, map[string]string{"Content-Type": "application/json"}, cli.AuditOptions{OutputFile: cfg.OutputFile, Checks: selected})
+	write.Close()
+	<-done
+	if code != 0 {
+		t.Fatalf("watchAudits() = %d", code)
+	}
+
+	var iterations []cli.WatchEvent
+	var resolved []string
+	for _, event := range events {
+		switch event.Event {
+		case cli.WatchIteration:
+			iterations = append(iterations, event)
+		case cli.WatchResolvedFinding:
+			resolved = append(resolved, event.Finding.ID)
+		}
+	}
+	if len(iterations) != 2 {
+		t.Fatalf("iteration events = %+v", iterations)
+	}
+	if iterations[1].Resolved != 1 || iterations[1].New != 0 || len(resolved) != 1 || resolved[0] != "incorrect-content-type" {
+		t.Errorf("second iteration = %+v, resolved %v; want the Content-Type finding resolved", iterations[1], resolved)
+	}
+}